@@ -50,6 +50,8 @@ type AddrManager struct {
 
 type serializedKnownAddress struct {
 	Addr        string
+	NetID       wire.NetworkID
+	Services    wire.ServiceFlag
 	Src         string
 	Attempts    int
 	TimeStamp   int64
@@ -155,20 +157,23 @@ const (
 	getAddrPercent = 23
 
 	// serialisationVersion is the current version of the on-disk format.
-	serialisationVersion = 1
+	// Version 2 added the network and services of the addresses so
+	// addresses of networks other than IP, such as Tor v3 and I2P, can be
+	// stored.
+	serialisationVersion = 2
 )
 
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
-func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
+func (a *AddrManager) updateAddress(netAddr *wire.NetAddressV2, srcAddr *wire.NetAddress) {
 	// Filter out non-routable addresses. Note that non-routable
 	// also includes invalid and local addresses.
-	if !IsRoutable(netAddr) {
+	if !IsRoutableV2(netAddr) {
 		return
 	}
 
-	addr := NetAddressKey(netAddr)
-	ka := a.find(netAddr)
+	addr := NetAddressKeyV2(netAddr)
+	ka := a.addrIndex[addr]
 	if ka != nil {
 		// TODO: only update addresses periodically.
 		// Update the last seen time and services.
@@ -182,7 +187,7 @@ func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
 
 			naCopy := *ka.na
 			naCopy.Timestamp = netAddr.Timestamp
-			naCopy.AddService(netAddr.Services)
+			naCopy.Services |= netAddr.Services
 			ka.na = &naCopy
 		}
 
@@ -207,6 +212,7 @@ func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
 		// updated elsewhere in the addrmanager code and would otherwise
 		// change the actual netaddress on the peer.
 		netAddrCopy := *netAddr
+		netAddrCopy.Addr = append([]byte(nil), netAddr.Addr...)
 		ka = &KnownAddress{na: &netAddrCopy, srcAddr: srcAddr}
 		a.addrIndex[addr] = ka
 		a.nNew++
@@ -262,7 +268,7 @@ func (a *AddrManager) expireNew(bucket int) {
 	}
 
 	if oldest != nil {
-		key := NetAddressKey(oldest.na)
+		key := NetAddressKeyV2(oldest.na)
 		log.Tracef("expiring oldest address %v", key)
 
 		delete(a.addrNew[bucket], key)
@@ -291,13 +297,13 @@ func (a *AddrManager) pickTried(bucket int) *list.Element {
 	return oldestElem
 }
 
-func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
+func (a *AddrManager) getNewBucket(netAddr *wire.NetAddressV2, srcAddr *wire.NetAddress) int {
 	// bitcoind:
 	// doublesha256(key + sourcegroup + int64(doublesha256(key + group + sourcegroup))%bucket_per_source_group) % num_new_buckets
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
+	data1 = append(data1, []byte(GroupKeyV2(netAddr))...)
 	data1 = append(data1, []byte(GroupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
@@ -313,12 +319,12 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	return int(binary.LittleEndian.Uint64(hash2) % newBucketCount)
 }
 
-func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
+func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddressV2) int {
 	// bitcoind hashes this as:
 	// doublesha256(key + group + truncate_to_64bits(doublesha256(key)) % buckets_per_group) % num_buckets
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(NetAddressKey(netAddr))...)
+	data1 = append(data1, []byte(NetAddressKeyV2(netAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= triedBucketsPerGroup
@@ -326,7 +332,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, GroupKeyV2(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -370,6 +376,8 @@ func (a *AddrManager) savePeers() {
 	for k, v := range a.addrIndex {
 		ska := new(serializedKnownAddress)
 		ska.Addr = k
+		ska.NetID = v.na.NetID
+		ska.Services = v.na.Services
 		ska.TimeStamp = v.na.Timestamp.Unix()
 		ska.Src = NetAddressKey(v.srcAddr)
 		ska.Attempts = v.attempts
@@ -393,7 +401,7 @@ func (a *AddrManager) savePeers() {
 		j := 0
 		for e := a.addrTried[i].Front(); e != nil; e = e.Next() {
 			ka := e.Value.(*KnownAddress)
-			sam.TriedBuckets[i][j] = NetAddressKey(ka.na)
+			sam.TriedBuckets[i][j] = NetAddressKeyV2(ka.na)
 			j++
 		}
 	}
@@ -451,7 +459,7 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		return fmt.Errorf("error reading %s: %v", filePath, err)
 	}
 
-	if sam.Version != serialisationVersion {
+	if sam.Version < 1 || sam.Version > serialisationVersion {
 		return fmt.Errorf("unknown version %v in serialized "+
 			"addrmanager", sam.Version)
 	}
@@ -459,11 +467,24 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 
 	for _, v := range sam.Addresses {
 		ka := new(KnownAddress)
-		ka.na, err = a.DeserializeNetAddress(v.Addr)
+		if sam.Version == 1 {
+			// Version 1 only stored addresses which can be
+			// represented as a wire.NetAddress without their
+			// network and services.
+			var na *wire.NetAddress
+			na, err = a.DeserializeNetAddress(v.Addr)
+			if err == nil {
+				ka.na = wire.NetAddressV2FromLegacy(na)
+			}
+		} else {
+			ka.na, err = a.deserializeNetAddressV2(v.Addr, v.NetID,
+				v.Services)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Addr, err)
 		}
+		ka.na.Timestamp = time.Unix(v.TimeStamp, 0)
 		ka.srcAddr, err = a.DeserializeNetAddress(v.Src)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
@@ -472,7 +493,7 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		ka.attempts = v.Attempts
 		ka.lastattempt = time.Unix(v.LastAttempt, 0)
 		ka.lastsuccess = time.Unix(v.LastSuccess, 0)
-		a.addrIndex[NetAddressKey(ka.na)] = ka
+		a.addrIndex[NetAddressKeyV2(ka.na)] = ka
 	}
 
 	for i := range sam.NewBuckets {
@@ -534,6 +555,34 @@ func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, erro
 	return a.HostToNetAddress(host, uint16(port), wire.SFNodeNetwork)
 }
 
+// deserializeNetAddressV2 converts the passed address string of the passed
+// network to a *wire.NetAddressV2 with the passed services.
+func (a *AddrManager) deserializeNetAddressV2(addr string, netID wire.NetworkID, services wire.ServiceFlag) (*wire.NetAddressV2, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	na, err := a.HostToNetAddressV2(host, uint16(port), services)
+	if err != nil {
+		return nil, err
+	}
+
+	// CJDNS addresses can't be told apart from IPv6 addresses by their
+	// textual form.
+	if netID == wire.NetIDCJDNS && na.NetID == wire.NetIDIPv6 {
+		na.NetID = wire.NetIDCJDNS
+	}
+	if na.NetID != netID {
+		return nil, fmt.Errorf("%s is not a %v address", addr, netID)
+	}
+	return na, nil
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (a *AddrManager) Start() {
@@ -573,6 +622,20 @@ func (a *AddrManager) AddAddresses(addrs []*wire.NetAddress, srcAddr *wire.NetAd
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
+	for _, na := range addrs {
+		a.updateAddress(wire.NetAddressV2FromLegacy(na), srcAddr)
+	}
+}
+
+// AddAddressesV2 adds new addresses received in an addrv2 message to the
+// address manager, including the addresses of networks which can't be
+// represented as a wire.NetAddress such as Tor v3 and I2P.  It enforces a max
+// number of addresses and silently ignores duplicate addresses and addresses
+// of unknown networks.  It is safe for concurrent access.
+func (a *AddrManager) AddAddressesV2(addrs []*wire.NetAddressV2, srcAddr *wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
 	for _, na := range addrs {
 		a.updateAddress(na, srcAddr)
 	}
@@ -585,7 +648,7 @@ func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddress) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
-	a.updateAddress(addr, srcAddr)
+	a.updateAddress(wire.NetAddressV2FromLegacy(addr), srcAddr)
 }
 
 // AddAddressByIP adds an address where we are given an ip:port and not a
@@ -632,9 +695,34 @@ func (a *AddrManager) NeedMoreAddresses() bool {
 	return a.numAddresses() < needAddressThreshold
 }
 
-// AddressCache returns the current address cache.  It must be treated as
+// AddressCache returns the current address cache.  Only addresses which can be
+// represented as a wire.NetAddress are included.  It must be treated as
 // read-only (but since it is a copy now, this is not as dangerous).
 func (a *AddrManager) AddressCache() []*wire.NetAddress {
+	addrs := a.addressCache(true)
+	if addrs == nil {
+		return nil
+	}
+
+	allAddr := make([]*wire.NetAddress, 0, len(addrs))
+	for _, na := range addrs {
+		legacy, _ := na.ToLegacy()
+		allAddr = append(allAddr, legacy)
+	}
+	return allAddr
+}
+
+// AddressCacheV2 returns the current address cache for use in addrv2
+// messages, which includes the addresses of all networks.  See AddressCache
+// for details.
+func (a *AddrManager) AddressCacheV2() []*wire.NetAddressV2 {
+	return a.addressCache(false)
+}
+
+// addressCache returns a random subset of the known addresses as described by
+// AddressCache.  Addresses which can't be represented as a wire.NetAddress are
+// excluded when legacyOnly is set.
+func (a *AddrManager) addressCache(legacyOnly bool) []*wire.NetAddressV2 {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -643,13 +731,18 @@ func (a *AddrManager) AddressCache() []*wire.NetAddress {
 		return nil
 	}
 
-	allAddr := make([]*wire.NetAddress, 0, addrIndexLen)
+	allAddr := make([]*wire.NetAddressV2, 0, addrIndexLen)
 	// Iteration order is undefined here, but we randomise it anyway.
 	for _, v := range a.addrIndex {
+		if legacyOnly {
+			if _, ok := v.na.ToLegacy(); !ok {
+				continue
+			}
+		}
 		allAddr = append(allAddr, v.na)
 	}
 
-	numAddresses := addrIndexLen * getAddrPercent / 100
+	numAddresses := len(allAddr) * getAddrPercent / 100
 	if numAddresses > getAddrMax {
 		numAddresses = getAddrMax
 	}
@@ -658,7 +751,7 @@ func (a *AddrManager) AddressCache() []*wire.NetAddress {
 	// `numAddresses' since we are throwing the rest.
 	for i := 0; i < numAddresses; i++ {
 		// pick a number between current index and the end
-		j := rand.Intn(addrIndexLen-i) + i
+		j := rand.Intn(len(allAddr)-i) + i
 		allAddr[i], allAddr[j] = allAddr[j], allAddr[i]
 	}
 
@@ -713,6 +806,51 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 	return wire.NewNetAddressIPPort(ip, port, services), nil
 }
 
+// onionEncoding is the encoding of the names of Tor v3 and I2P addresses.
+var onionEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// HostToNetAddressV2 returns a NetAddressV2 given a host address.  Tor v3
+// .onion and I2P .b32.i2p addresses are decoded to the addresses of their
+// networks, and all other hosts are handled like HostToNetAddress.
+func (a *AddrManager) HostToNetAddressV2(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddressV2, error) {
+	lowerHost := strings.ToLower(host)
+	switch {
+	// Tor v3 address is 56 char base32 + ".onion".
+	case len(lowerHost) == 62 && strings.HasSuffix(lowerHost, ".onion"):
+		data, err := onionEncoding.DecodeString(
+			strings.ToUpper(lowerHost[:56]))
+		if err != nil {
+			return nil, err
+		}
+
+		// The name encodes the public key followed by a checksum and
+		// the version, which are verified by encoding it again.
+		na, err := wire.NewNetAddressV2(wire.NetIDTorV3, data[:32],
+			port, services)
+		if err != nil {
+			return nil, err
+		}
+		if na.HostString() != lowerHost {
+			return nil, fmt.Errorf("invalid tor v3 address %s", host)
+		}
+		return na, nil
+
+	case strings.HasSuffix(lowerHost, ".b32.i2p"):
+		data, err := onionEncoding.DecodeString(strings.ToUpper(
+			strings.TrimSuffix(lowerHost, ".b32.i2p")))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(wire.NetIDI2P, data, port, services)
+	}
+
+	na, err := a.HostToNetAddress(host, port, services)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NetAddressV2FromLegacy(na), nil
+}
+
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for Tor addresses then it will be transformed into
 // the relevant .onion address.
@@ -734,6 +872,15 @@ func NetAddressKey(na *wire.NetAddress) string {
 	return net.JoinHostPort(ipString(na), port)
 }
 
+// NetAddressKeyV2 returns a string key in the form of host:port for the passed
+// address.  It is the same key NetAddressKey returns for addresses which can be
+// represented as a wire.NetAddress.
+func NetAddressKeyV2(na *wire.NetAddressV2) string {
+	port := strconv.FormatUint(uint64(na.Port), 10)
+
+	return net.JoinHostPort(na.HostString(), port)
+}
+
 // GetAddress returns a single address that should be routable.  It picks a
 // random one from the possible addresses with preference given to ones that
 // have not been used recently and should not pick 'close' addresses
@@ -749,59 +896,89 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 
 	// Use a 50% chance for choosing between tried and new table entries.
 	if a.nTried > 0 && (a.nNew == 0 || a.rand.Intn(2) == 0) {
-		// Tried entry.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrTried))
-			if a.addrTried[bucket].Len() == 0 {
-				continue
-			}
+		return a.selectTried()
+	}
+	return a.selectNew()
+}
 
-			// Pick a random entry in the list
-			e := a.addrTried[bucket].Front()
-			for i :=
-				a.rand.Int63n(int64(a.addrTried[bucket].Len())); i > 0; i-- {
-				e = e.Next()
-			}
-			ka := e.Value.(*KnownAddress)
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from tried bucket",
-					NetAddressKey(ka.na))
-				return ka
-			}
-			factor *= 1.2
+// GetUntriedAddress returns a single address from the new table, which holds
+// the addresses which have not been connected to successfully yet, like
+// GetAddress.  It is used by feeler connections which test whether those
+// addresses are reachable so they are moved to the tried table.  It returns
+// nil when there are no such addresses.
+func (a *AddrManager) GetUntriedAddress() *KnownAddress {
+	// Protect concurrent access.
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.nNew == 0 {
+		return nil
+	}
+	return a.selectNew()
+}
+
+// selectTried selects a random address from the tried table, with preference
+// given to ones that have not been used recently.
+//
+// This function MUST be called with the address manager lock held and at
+// least one tried address.
+func (a *AddrManager) selectTried() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrTried))
+		if a.addrTried[bucket].Len() == 0 {
+			continue
 		}
-	} else {
-		// new node.
-		// XXX use a closure/function to avoid repeating this.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// Pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrNew))
-			if len(a.addrNew[bucket]) == 0 {
-				continue
-			}
-			// Then, a random entry in it.
-			var ka *KnownAddress
-			nth := a.rand.Intn(len(a.addrNew[bucket]))
-			for _, value := range a.addrNew[bucket] {
-				if nth == 0 {
-					ka = value
-				}
-				nth--
-			}
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
-				return ka
+
+		// Pick a random entry in the list
+		e := a.addrTried[bucket].Front()
+		for i :=
+			a.rand.Int63n(int64(a.addrTried[bucket].Len())); i > 0; i-- {
+			e = e.Next()
+		}
+		ka := e.Value.(*KnownAddress)
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from tried bucket",
+				NetAddressKeyV2(ka.na))
+			return ka
+		}
+		factor *= 1.2
+	}
+}
+
+// selectNew selects a random address from the new table, with preference given
+// to ones that have not been used recently.
+//
+// This function MUST be called with the address manager lock held and at
+// least one new address.
+func (a *AddrManager) selectNew() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// Pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrNew))
+		if len(a.addrNew[bucket]) == 0 {
+			continue
+		}
+		// Then, a random entry in it.
+		var ka *KnownAddress
+		nth := a.rand.Intn(len(a.addrNew[bucket]))
+		for _, value := range a.addrNew[bucket] {
+			if nth == 0 {
+				ka = value
 			}
-			factor *= 1.2
+			nth--
+		}
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from new bucket",
+				NetAddressKeyV2(ka.na))
+			return ka
 		}
+		factor *= 1.2
 	}
 }
 
@@ -931,7 +1108,7 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 	// something back.
 	a.nNew++
 
-	rmkey := NetAddressKey(rmka.na)
+	rmkey := NetAddressKeyV2(rmka.na)
 	log.Tracef("Replacing %s with %s in tried", rmkey, addrKey)
 
 	// We made sure there is space here just above.
@@ -982,6 +1159,14 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPrior
 	return nil
 }
 
+// RemoveLocalAddress removes na from the list of known local addresses to
+// advertise, such as when the external address assigned by a NAT changed.
+func (a *AddrManager) RemoveLocalAddress(na *wire.NetAddress) {
+	a.lamtx.Lock()
+	delete(a.localAddresses, NetAddressKey(na))
+	a.lamtx.Unlock()
+}
+
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
 func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
@@ -5,10 +5,15 @@
 package addrmgr_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -208,6 +213,26 @@ func TestAddLocalAddress(t *testing.T) {
 	}
 }
 
+// TestRemoveLocalAddress ensures removed local addresses are no longer
+// advertised.
+func TestRemoveLocalAddress(t *testing.T) {
+	amgr := addrmgr.New("testremovelocaladdress", nil)
+	localAddr := wire.NetAddress{IP: net.ParseIP("204.124.1.1"), Port: 9888}
+	remoteAddr := wire.NetAddress{IP: net.ParseIP("204.124.8.1")}
+	if err := amgr.AddLocalAddress(&localAddr, addrmgr.UpnpPrio); err != nil {
+		t.Fatalf("AddLocalAddress: unexpected error: %v", err)
+	}
+	if got := amgr.GetBestLocalAddress(&remoteAddr); !got.IP.Equal(localAddr.IP) {
+		t.Fatalf("GetBestLocalAddress: got %s, want %s", got.IP,
+			localAddr.IP)
+	}
+
+	amgr.RemoveLocalAddress(&localAddr)
+	if got := amgr.GetBestLocalAddress(&remoteAddr); got.IP.Equal(localAddr.IP) {
+		t.Fatalf("GetBestLocalAddress: got removed address %s", got.IP)
+	}
+}
+
 func TestAttempt(t *testing.T) {
 	n := addrmgr.New("testattempt", lookupFunc)
 
@@ -314,6 +339,241 @@ func TestGood(t *testing.T) {
 	}
 }
 
+// TestAddAddressesV2 ensures addrv2 addresses of all known networks are added,
+// including those which can't be represented as legacy addresses, and the
+// addresses of unknown networks are ignored.
+func TestAddAddressesV2(t *testing.T) {
+	n := addrmgr.New("testaddaddressesv2", lookupFunc)
+
+	torV3Key := make([]byte, 32)
+	torV3Key[0] = 0x12
+	var addrs []*wire.NetAddressV2
+	for _, addr := range []struct {
+		netID wire.NetworkID
+		addr  []byte
+	}{
+		{wire.NetIDIPv4, []byte{173, 194, 115, 66}},
+		{wire.NetIDTorV3, torV3Key},
+		{wire.NetworkID(99), []byte{1, 2, 3}},
+	} {
+		na, err := wire.NewNetAddressV2(addr.netID, addr.addr, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2: %v", err)
+		}
+		addrs = append(addrs, na)
+	}
+
+	srcAddr := wire.NewNetAddressIPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
+	n.AddAddressesV2(addrs, srcAddr)
+	if numAddrs := n.NumAddresses(); numAddrs != 2 {
+		t.Fatalf("NumAddresses: got %d, want 2", numAddrs)
+	}
+
+	// Tor v3 addresses are only available as addrv2 addresses.
+	seen := make(map[wire.NetworkID]bool)
+	for i := 0; i < 100 && len(seen) < 2; i++ {
+		ka := n.GetAddress()
+		if ka == nil {
+			t.Fatal("GetAddress: did not get an address")
+		}
+		switch ka.NetAddressV2().NetID {
+		case wire.NetIDIPv4:
+			got := addrmgr.NetAddressKey(ka.NetAddress())
+			if got != "173.194.115.66:8333" {
+				t.Errorf("GetAddress: got %s, want "+
+					"173.194.115.66:8333", got)
+			}
+		case wire.NetIDTorV3:
+			if ka.NetAddress() != nil {
+				t.Errorf("GetAddress: got legacy address %v for "+
+					"tor v3 address", ka.NetAddress())
+			}
+			want := addrs[1].String()
+			if got := addrmgr.NetAddressKeyV2(ka.NetAddressV2()); got != want {
+				t.Errorf("GetAddress: got %s, want %s", got, want)
+			}
+		default:
+			t.Errorf("GetAddress: unexpected address %v",
+				ka.NetAddressV2())
+		}
+		seen[ka.NetAddressV2().NetID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("GetAddress: only got addresses of networks %v", seen)
+	}
+
+	// Only the addrv2 cache contains Tor v3 addresses.
+	for i := 0; i < 100; i++ {
+		ipv4, err := wire.NewNetAddressV2(wire.NetIDIPv4,
+			[]byte{173, 194, 115, byte(i)}, 8333, wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2: %v", err)
+		}
+		key := make([]byte, 32)
+		key[0], key[1] = byte(i), 0xff
+		torV3, err := wire.NewNetAddressV2(wire.NetIDTorV3, key, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2: %v", err)
+		}
+		n.AddAddressesV2([]*wire.NetAddressV2{ipv4, torV3}, srcAddr)
+	}
+	for _, na := range n.AddressCache() {
+		if !addrmgr.IsIPv4(na) {
+			t.Errorf("AddressCache: unexpected address %v", na.IP)
+		}
+	}
+	var haveTorV3 bool
+	for _, na := range n.AddressCacheV2() {
+		haveTorV3 = haveTorV3 || na.NetID == wire.NetIDTorV3
+	}
+	if !haveTorV3 {
+		t.Error("AddressCacheV2: no tor v3 addresses")
+	}
+}
+
+// TestHostToNetAddressV2 ensures the hosts of all networks are converted to the
+// addresses of the correct networks.
+func TestHostToNetAddressV2(t *testing.T) {
+	n := addrmgr.New("testhosttonetaddressv2", lookupFunc)
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	torV3, err := wire.NewNetAddressV2(wire.NetIDTorV3, key, 0, 0)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: %v", err)
+	}
+	i2p, err := wire.NewNetAddressV2(wire.NetIDI2P, key, 0, 0)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: %v", err)
+	}
+	torV3Host := torV3.HostString()
+	badChecksum := []byte(torV3Host)
+	if badChecksum[53] == 'a' {
+		badChecksum[53] = 'b'
+	} else {
+		badChecksum[53] = 'a'
+	}
+
+	tests := []struct {
+		host  string
+		netID wire.NetworkID
+		addr  []byte
+		valid bool
+	}{
+		{"173.194.115.66", wire.NetIDIPv4, []byte{173, 194, 115, 66}, true},
+		{"2602:100::1", wire.NetIDIPv6, net.ParseIP("2602:100::1"), true},
+		{"aaaaaaaaaaaaaaaa.onion", wire.NetIDTorV2, make([]byte, 10), true},
+		{torV3Host, wire.NetIDTorV3, key, true},
+		{strings.ToUpper(torV3Host), wire.NetIDTorV3, key, true},
+		{string(badChecksum), 0, nil, false},
+		{i2p.HostString(), wire.NetIDI2P, key, true},
+		{"bad.b32.i2p", 0, nil, false},
+	}
+	for _, test := range tests {
+		na, err := n.HostToNetAddressV2(test.host, 8333,
+			wire.SFNodeNetwork)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("HostToNetAddressV2(%s): unexpected "+
+					"success", test.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("HostToNetAddressV2(%s): %v", test.host, err)
+			continue
+		}
+		if na.NetID != test.netID || !bytes.Equal(na.Addr, test.addr) ||
+			na.Port != 8333 {
+
+			t.Errorf("HostToNetAddressV2(%s): got %v address %x:%d, "+
+				"want %v address %x:8333", test.host, na.NetID,
+				na.Addr, na.Port, test.netID, test.addr)
+		}
+	}
+}
+
+// TestSavePeers ensures addresses of all networks are saved and loaded again,
+// and that peers files of the previous version can still be loaded.
+func TestSavePeers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testsavepeers")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	torV3, err := wire.NewNetAddressV2(wire.NetIDTorV3, make([]byte, 32),
+		8333, wire.SFNodeNetwork|wire.SFNodeBloom)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: %v", err)
+	}
+	ipv4, err := wire.NewNetAddressV2(wire.NetIDIPv4,
+		[]byte{173, 194, 115, 66}, 8333, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: %v", err)
+	}
+	srcAddr := wire.NewNetAddressIPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
+
+	n := addrmgr.New(dir, lookupFunc)
+	n.Start()
+	n.AddAddressesV2([]*wire.NetAddressV2{torV3, ipv4}, srcAddr)
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	n = addrmgr.New(dir, lookupFunc)
+	n.Start()
+	defer n.Stop()
+	if numAddrs := n.NumAddresses(); numAddrs != 2 {
+		t.Fatalf("NumAddresses: got %d, want 2", numAddrs)
+	}
+	var haveTorV3 bool
+	for i := 0; i < 100 && !haveTorV3; i++ {
+		na := n.GetAddress().NetAddressV2()
+		if na.NetID != wire.NetIDTorV3 {
+			continue
+		}
+		haveTorV3 = true
+		if na.String() != torV3.String() || na.Services != torV3.Services {
+			t.Errorf("got address %v with services %v, want %v "+
+				"with services %v", na, na.Services, torV3,
+				torV3.Services)
+		}
+	}
+	if !haveTorV3 {
+		t.Error("tor v3 address was not loaded")
+	}
+
+	// Version 1 peers files don't include the networks of addresses.
+	const peersV1 = `{"Version":1,"Key":[%s],"Addresses":[{"Addr":` +
+		`"173.194.115.66:8333","Src":"173.144.173.111:8333",` +
+		`"Attempts":0,"TimeStamp":%d,"LastAttempt":0,` +
+		`"LastSuccess":0}],"NewBuckets":[%s],"TriedBuckets":[%s]}`
+	keyBytes := strings.TrimSuffix(strings.Repeat("0,", 32), ",")
+	newBuckets := `["173.194.115.66:8333"]` + strings.Repeat(",null", 1023)
+	triedBuckets := strings.TrimSuffix(strings.Repeat("null,", 64), ",")
+	peersFile := filepath.Join(dir, "peers.json")
+	err = ioutil.WriteFile(peersFile, []byte(fmt.Sprintf(peersV1, keyBytes,
+		time.Now().Unix(), newBuckets, triedBuckets)), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	n = addrmgr.New(dir, lookupFunc)
+	n.Start()
+	defer n.Stop()
+	if numAddrs := n.NumAddresses(); numAddrs != 1 {
+		t.Fatalf("NumAddresses: got %d, want 1", numAddrs)
+	}
+	if na := n.GetAddress().NetAddressV2(); na.NetID != wire.NetIDIPv4 {
+		t.Fatalf("got %v address, want %v", na.NetID, wire.NetIDIPv4)
+	}
+}
+
 func TestGetAddress(t *testing.T) {
 	n := addrmgr.New("testgetaddress", lookupFunc)
 
@@ -0,0 +1,342 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// The instructions of an asmap program.
+const (
+	asmapReturn = iota
+	asmapJump
+	asmapMatch
+	asmapDefault
+)
+
+// asmapInvalid is returned when a value can't be decoded from an asmap.
+const asmapInvalid = 0xffffffff
+
+// The sizes of the classes of the variable length encodings of the
+// instructions and their arguments in an asmap program.
+var (
+	asmapTypeBitSizes  = []uint{0, 0, 1}
+	asmapASNBitSizes   = []uint{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// ASMap maps IP addresses to the autonomous systems (ASN) which announce them.
+// It is decoded from the compact binary format used by the asmap files of
+// Bitcoin Core, which encodes a program that matches the bits of an IPv6
+// address, or an IPv4-mapped IPv6 address, to find its ASN.
+//
+// Grouping addresses by autonomous system instead of by /16 network makes it
+// harder for an attacker with addresses in many networks of a single
+// autonomous system to occupy all outbound connections.
+type ASMap struct {
+	bits []bool
+}
+
+// NewASMap decodes the passed asmap and ensures it is well formed.
+func NewASMap(data []byte) (*ASMap, error) {
+	bits := make([]bool, 0, len(data)*8)
+	for _, b := range data {
+		for i := uint(0); i < 8; i++ {
+			bits = append(bits, (b>>i)&1 == 1)
+		}
+	}
+	if !asmapSanityCheck(bits, 128) {
+		return nil, errors.New("malformed asmap")
+	}
+	return &ASMap{bits: bits}, nil
+}
+
+// LoadASMap reads and decodes the asmap file at the passed path.
+func LoadASMap(path string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewASMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// ASN returns the autonomous system the passed address belongs to, or zero
+// when it is unknown or the address is not a routable IP address.  IPv6
+// addresses which embed IPv4 addresses are mapped by the IPv4 address.
+func (m *ASMap) ASN(na *wire.NetAddress) uint32 {
+	if m == nil || !IsRoutable(na) || IsOnionCatTor(na) {
+		return 0
+	}
+
+	ip := na.IP.To16()
+	switch {
+	case IsRFC6145(na) || IsRFC6052(na):
+		ip = net.IP(na.IP[12:16]).To16()
+	case IsRFC3964(na):
+		ip = net.IP(na.IP[2:6]).To16()
+	case IsRFC4380(na):
+		// Teredo tunnels have the last 4 bytes as the v4 address XOR
+		// 0xff.
+		v4 := make(net.IP, 4)
+		for i, b := range na.IP[12:16] {
+			v4[i] = b ^ 0xff
+		}
+		ip = v4.To16()
+	}
+
+	ipBits := make([]bool, 0, 128)
+	for _, b := range ip {
+		for i := uint(0); i < 8; i++ {
+			ipBits = append(ipBits, (b>>(7-i))&1 == 1)
+		}
+	}
+	return asmapInterpret(m.bits, ipBits)
+}
+
+// GroupKey returns a string representing the network group the passed address
+// is part of.  Routable IP addresses with a known autonomous system are grouped
+// by it, and all other addresses use the group returned by the package level
+// GroupKey.  A nil asmap always uses the package level GroupKey.
+func (m *ASMap) GroupKey(na *wire.NetAddress) string {
+	if asn := m.ASN(na); asn != 0 {
+		return fmt.Sprintf("as%d", asn)
+	}
+	return GroupKey(na)
+}
+
+// asmapDecodeBits decodes a variable length integer at the passed position of
+// the asmap and returns it along with the position which follows it.  The
+// integer is encoded as the class it belongs to followed by its offset within
+// the class, where the classes have the passed sizes in bits.
+func asmapDecodeBits(bits []bool, pos int, minVal uint32, sizes []uint) (uint32, int) {
+	val := minVal
+	for i, size := range sizes {
+		var bit bool
+		if i+1 != len(sizes) {
+			if pos == len(bits) {
+				break
+			}
+			bit = bits[pos]
+			pos++
+		}
+		if bit {
+			val += 1 << size
+			continue
+		}
+		for b := uint(0); b < size; b++ {
+			if pos == len(bits) {
+				return asmapInvalid, pos
+			}
+			if bits[pos] {
+				val += 1 << (size - 1 - b)
+			}
+			pos++
+		}
+		return val, pos
+	}
+	return asmapInvalid, pos
+}
+
+// bitLen returns the minimum number of bits required to represent x.
+func bitLen(x uint32) int {
+	n := 0
+	for ; x != 0; x >>= 1 {
+		n++
+	}
+	return n
+}
+
+// asmapInterpret runs the asmap program against the passed bits of an IP
+// address and returns the resulting ASN, or zero when there is none.
+func asmapInterpret(bits []bool, ip []bool) uint32 {
+	var defaultASN uint32
+	pos := 0
+	for pos != len(bits) {
+		var opcode uint32
+		opcode, pos = asmapDecodeBits(bits, pos, 0, asmapTypeBitSizes)
+		switch opcode {
+		case asmapReturn:
+			asn, _ := asmapDecodeBits(bits, pos, 1, asmapASNBitSizes)
+			if asn == asmapInvalid {
+				return 0
+			}
+			return asn
+
+		case asmapJump:
+			var jump uint32
+			jump, pos = asmapDecodeBits(bits, pos, 17, asmapJumpBitSizes)
+			if jump == asmapInvalid || len(ip) == 0 ||
+				int64(jump) >= int64(len(bits)-pos) {
+
+				return 0
+			}
+			if ip[0] {
+				pos += int(jump)
+			}
+			ip = ip[1:]
+
+		case asmapMatch:
+			var match uint32
+			match, pos = asmapDecodeBits(bits, pos, 2, asmapMatchBitSizes)
+			if match == asmapInvalid {
+				return 0
+			}
+			matchLen := bitLen(match) - 1
+			if len(ip) < matchLen {
+				return 0
+			}
+			for i := 0; i < matchLen; i++ {
+				if (match>>uint(matchLen-1-i))&1 == 1 != ip[i] {
+					return defaultASN
+				}
+			}
+			ip = ip[matchLen:]
+
+		case asmapDefault:
+			defaultASN, pos = asmapDecodeBits(bits, pos, 1,
+				asmapASNBitSizes)
+			if defaultASN == asmapInvalid {
+				return 0
+			}
+
+		default:
+			return 0
+		}
+	}
+
+	// Reached the end without a return instruction.
+	return 0
+}
+
+// asmapSanityCheck returns whether the passed asmap program is well formed for
+// addresses of the passed number of bits, which means every instruction can be
+// decoded, all jumps are in range and every path ends with a return
+// instruction.
+func asmapSanityCheck(bits []bool, numBits int) bool {
+	type jumpTarget struct {
+		pos     int
+		numBits int
+	}
+	var jumps []jumpTarget
+	prevOpcode := uint32(asmapJump)
+	hadIncompleteMatch := false
+	pos := 0
+	for pos != len(bits) {
+		if len(jumps) > 0 && pos >= jumps[len(jumps)-1].pos {
+			// There was a jump into the middle of the previous
+			// instruction.
+			return false
+		}
+
+		var opcode uint32
+		opcode, pos = asmapDecodeBits(bits, pos, 0, asmapTypeBitSizes)
+		switch opcode {
+		case asmapReturn:
+			// A default followed by a return could be a single
+			// return.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			var asn uint32
+			asn, pos = asmapDecodeBits(bits, pos, 1, asmapASNBitSizes)
+			if asn == asmapInvalid {
+				return false
+			}
+			if len(jumps) == 0 {
+				// Nothing to execute anymore, so only padding
+				// to the next byte may follow.
+				if len(bits)-pos > 7 {
+					return false
+				}
+				for ; pos != len(bits); pos++ {
+					if bits[pos] {
+						return false
+					}
+				}
+				return true
+			}
+
+			// Continue as if the last jump was taken.
+			target := jumps[len(jumps)-1]
+			if pos != target.pos {
+				// Unreachable code.
+				return false
+			}
+			numBits = target.numBits
+			jumps = jumps[:len(jumps)-1]
+			prevOpcode = asmapJump
+
+		case asmapJump:
+			var jump uint32
+			jump, pos = asmapDecodeBits(bits, pos, 17, asmapJumpBitSizes)
+			if jump == asmapInvalid ||
+				int64(jump) > int64(len(bits)-pos) || numBits == 0 {
+
+				return false
+			}
+			numBits--
+			target := pos + int(jump)
+			if len(jumps) > 0 && target >= jumps[len(jumps)-1].pos {
+				// Intersecting jumps.
+				return false
+			}
+			jumps = append(jumps, jumpTarget{target, numBits})
+			prevOpcode = asmapJump
+
+		case asmapMatch:
+			var match uint32
+			match, pos = asmapDecodeBits(bits, pos, 2, asmapMatchBitSizes)
+			if match == asmapInvalid {
+				return false
+			}
+			matchLen := bitLen(match) - 1
+			if prevOpcode != asmapMatch {
+				hadIncompleteMatch = false
+			}
+
+			// Only one match of a sequence of matches may match
+			// fewer than 8 bits.
+			if matchLen < 8 && hadIncompleteMatch {
+				return false
+			}
+			hadIncompleteMatch = matchLen < 8
+			if numBits < matchLen {
+				return false
+			}
+			numBits -= matchLen
+			prevOpcode = asmapMatch
+
+		case asmapDefault:
+			// Successive defaults could be a single default.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			var asn uint32
+			asn, pos = asmapDecodeBits(bits, pos, 1, asmapASNBitSizes)
+			if asn == asmapInvalid {
+				return false
+			}
+			prevOpcode = asmapDefault
+
+		default:
+			// The instruction straddles the end.
+			return false
+		}
+	}
+
+	// Reached the end without a return instruction.
+	return false
+}
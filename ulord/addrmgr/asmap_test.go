@@ -0,0 +1,147 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ulordsuite/ulord/addrmgr"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// asmapBuilder assembles asmap programs for tests.
+type asmapBuilder struct {
+	bits []bool
+}
+
+// encode appends the passed value using the variable length encoding with the
+// passed minimum value and class sizes.
+func (b *asmapBuilder) encode(val, minVal uint32, sizes []uint) {
+	val -= minVal
+	for i, size := range sizes {
+		last := i+1 == len(sizes)
+		if !last && val >= 1<<size {
+			b.bits = append(b.bits, true)
+			val -= 1 << size
+			continue
+		}
+		if !last {
+			b.bits = append(b.bits, false)
+		}
+		for j := uint(0); j < size; j++ {
+			b.bits = append(b.bits, (val>>(size-1-j))&1 == 1)
+		}
+		return
+	}
+}
+
+func (b *asmapBuilder) opcode(op uint32) {
+	b.encode(op, 0, []uint{0, 0, 1})
+}
+
+func (b *asmapBuilder) ret(asn uint32) {
+	b.opcode(0)
+	b.encode(asn, 1, []uint{15, 16, 17, 18, 19, 20, 21, 22, 23, 24})
+}
+
+func (b *asmapBuilder) jump(offset uint32) {
+	b.opcode(1)
+	b.encode(offset, 17, []uint{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30})
+}
+
+// matchByte appends an instruction matching the next 8 bits of the address.
+func (b *asmapBuilder) matchByte(v byte) {
+	b.opcode(2)
+	b.encode(1<<8|uint32(v), 2, []uint{1, 2, 3, 4, 5, 6, 7, 8})
+}
+
+// bytes returns the program packed least significant bit first.
+func (b *asmapBuilder) bytes() []byte {
+	data := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			data[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// TestASMap ensures asmaps are validated and map addresses to the expected
+// autonomous systems.
+func TestASMap(t *testing.T) {
+	// Map IPv4 addresses with the high bit unset to AS100, IPv4 addresses
+	// with the high bit set to AS200, and everything else to no AS.
+	var b asmapBuilder
+	for i := 0; i < 10; i++ {
+		b.matchByte(0x00)
+	}
+	b.matchByte(0xff)
+	b.matchByte(0xff)
+	b.jump(17)
+	b.ret(100)
+	b.ret(200)
+	m, err := addrmgr.NewASMap(b.bytes())
+	if err != nil {
+		t.Fatalf("NewASMap: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		ip    string
+		asn   uint32
+		group string
+	}{
+		{"1.2.3.4", 100, "as100"},
+		{"127.255.0.1", 0, "local"},
+		{"200.1.1.1", 200, "as200"},
+		{"2002:c801:0101::1", 200, "as200"},
+		{"2001:470::1", 0, "2001:470::"},
+		{"10.0.0.1", 0, "unroutable"},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 9888, 0)
+		if asn := m.ASN(na); asn != test.asn {
+			t.Errorf("ASN(%s): got %d, want %d", test.ip, asn, test.asn)
+		}
+		if group := m.GroupKey(na); group != test.group {
+			t.Errorf("GroupKey(%s): got %q, want %q", test.ip, group,
+				test.group)
+		}
+	}
+
+	// A nil asmap falls back to grouping by network.
+	var nilMap *addrmgr.ASMap
+	na := wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 9888, 0)
+	if group := nilMap.GroupKey(na); group != "1.2.0.0" {
+		t.Errorf("GroupKey: got %q, want %q", group, "1.2.0.0")
+	}
+
+	// A single return maps every address to the same AS.
+	b = asmapBuilder{}
+	b.ret(7)
+	m, err = addrmgr.NewASMap(b.bytes())
+	if err != nil {
+		t.Fatalf("NewASMap: unexpected error: %v", err)
+	}
+	if asn := m.ASN(na); asn != 7 {
+		t.Errorf("ASN: got %d, want %d", asn, 7)
+	}
+
+	// Malformed asmaps are rejected.
+	b = asmapBuilder{}
+	b.jump(17)
+	b.ret(100)
+	malformed := [][]byte{
+		nil,
+		{0xff},
+		b.bytes(),
+	}
+	for i, data := range malformed {
+		if _, err := addrmgr.NewASMap(data); err == nil {
+			t.Errorf("NewASMap #%d: unexpected success", i)
+		}
+	}
+}
@@ -34,5 +34,16 @@ provided by the caller about connected, known good, and attempted addresses to
 periodically purge peers which no longer appear to be good peers as well as
 bias the selection toward known good peers.  The general idea is to make a best
 effort at only providing usable addresses.
+
+Besides IP and Tor v2 addresses, the address manager stores the addresses of the
+other networks addrv2 messages (BIP0155) can describe, such as Tor v3 and I2P.
+Those addresses are grouped by their network so each network is spread over its
+own set of buckets.  Since they can't be represented as a wire.NetAddress, they
+are only available through the V2 variants of the functions, such as
+AddressCacheV2 and KnownAddress.NetAddressV2.
+
+Callers which spread their connections over network groups can load an ASMap,
+which maps IP addresses to the autonomous systems announcing them, to group
+addresses by autonomous system instead of by /16 network.
 */
 package addrmgr
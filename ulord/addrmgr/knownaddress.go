@@ -13,7 +13,7 @@ import (
 // KnownAddress tracks information about a known network address that is used
 // to determine how viable an address is.
 type KnownAddress struct {
-	na          *wire.NetAddress
+	na          *wire.NetAddressV2
 	srcAddr     *wire.NetAddress
 	attempts    int
 	lastattempt time.Time
@@ -22,9 +22,20 @@ type KnownAddress struct {
 	refs        int // reference count of new buckets
 }
 
-// NetAddress returns the underlying wire.NetAddress associated with the
-// known address.
+// NetAddress returns the known address converted to a wire.NetAddress.  It
+// returns nil for addresses which can't be represented as a wire.NetAddress,
+// such as Tor v3 and I2P addresses.  Use NetAddressV2 to access those.
 func (ka *KnownAddress) NetAddress() *wire.NetAddress {
+	na, ok := ka.na.ToLegacy()
+	if !ok {
+		return nil
+	}
+	return na
+}
+
+// NetAddressV2 returns the underlying wire.NetAddressV2 associated with the
+// known address.
+func (ka *KnownAddress) NetAddressV2() *wire.NetAddressV2 {
 	return ka.na
 }
 
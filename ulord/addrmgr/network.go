@@ -90,6 +90,9 @@ var (
 
 	// heNet defines the Hurricane Electric IPv6 address block.
 	heNet = ipNet("2001:470::", 32, 128)
+
+	// cjdnsNet specifies the IPv6 range CJDNS addresses are in.
+	cjdnsNet = ipNet("fc00::", 8, 128)
 )
 
 // ipNet returns a net.IPNet struct given the passed IP address string, number
@@ -279,3 +282,45 @@ func GroupKey(na *wire.NetAddress) string {
 
 	return na.IP.Mask(net.CIDRMask(bits, 128)).String()
 }
+
+// IsRoutableV2 returns whether or not the passed address is routable over the
+// network it belongs to.  Addresses which can be represented as a
+// wire.NetAddress are checked with IsRoutable, Tor v3 and I2P addresses are
+// always routable, and CJDNS addresses must be in the fc00::/8 range.
+// Addresses of unknown networks are never routable.
+func IsRoutableV2(na *wire.NetAddressV2) bool {
+	if legacy, ok := na.ToLegacy(); ok {
+		return IsRoutable(legacy)
+	}
+	switch na.NetID {
+	case wire.NetIDTorV3, wire.NetIDI2P:
+		return len(na.Addr) == 32
+	case wire.NetIDCJDNS:
+		return len(na.Addr) == net.IPv6len &&
+			cjdnsNet.Contains(net.IP(na.Addr))
+	}
+	return false
+}
+
+// GroupKeyV2 returns a string representing the network group an address is
+// part of like GroupKey.  The addresses of networks other than IP are grouped
+// by their network and the first 4 bits of the address, so each network is
+// spread over its own set of buckets.
+func GroupKeyV2(na *wire.NetAddressV2) string {
+	if legacy, ok := na.ToLegacy(); ok {
+		return GroupKey(legacy)
+	}
+	if !IsRoutableV2(na) {
+		return "unroutable"
+	}
+	switch na.NetID {
+	case wire.NetIDTorV3:
+		return fmt.Sprintf("torv3:%d", na.Addr[0]&((1<<4)-1))
+	case wire.NetIDI2P:
+		return fmt.Sprintf("i2p:%d", na.Addr[0]&((1<<4)-1))
+	}
+
+	// All CJDNS addresses share the first byte, so they are keyed off
+	// the 4 bits which follow it instead.
+	return fmt.Sprintf("cjdns:%d", na.Addr[1]>>4)
+}
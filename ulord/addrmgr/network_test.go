@@ -200,3 +200,38 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestGroupKeyV2 ensures the group keys of addresses of networks other than IP
+// are keyed off their network.
+func TestGroupKeyV2(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 0x12
+	tests := []struct {
+		name     string
+		netID    wire.NetworkID
+		addr     []byte
+		expected string
+	}{
+		{name: "ipv4", netID: wire.NetIDIPv4, addr: []byte{12, 1, 2, 3}, expected: "12.1.0.0"},
+		{name: "ipv4 local", netID: wire.NetIDIPv4, addr: []byte{127, 0, 0, 1}, expected: "local"},
+		{name: "tor v2", netID: wire.NetIDTorV2, addr: []byte{0x12, 0x34, 0, 0, 0, 0, 0, 0, 0, 0}, expected: "tor:2"},
+		{name: "tor v3", netID: wire.NetIDTorV3, addr: key, expected: "torv3:2"},
+		{name: "i2p", netID: wire.NetIDI2P, addr: key, expected: "i2p:2"},
+		{name: "cjdns", netID: wire.NetIDCJDNS, addr: net.ParseIP("fc12:3456::1"), expected: "cjdns:1"},
+		{name: "cjdns outside fc00::/8", netID: wire.NetIDCJDNS, addr: net.ParseIP("fd12:3456::1"), expected: "unroutable"},
+		{name: "unknown network", netID: wire.NetworkID(99), addr: []byte{1, 2, 3}, expected: "unroutable"},
+	}
+
+	for i, test := range tests {
+		na, err := wire.NewNetAddressV2(test.netID, test.addr, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("TestGroupKeyV2 #%d (%s): %v", i, test.name, err)
+		}
+		if key := addrmgr.GroupKeyV2(na); key != test.expected {
+			t.Errorf("TestGroupKeyV2 #%d (%s): unexpected group key "+
+				"- got '%s', want '%s'", i, test.name,
+				key, test.expected)
+		}
+	}
+}
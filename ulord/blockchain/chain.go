@@ -93,14 +93,15 @@ type BlockChain struct {
 	// The following fields are set when the instance is created and can't
 	// be changed afterwards, so there is no need to protect them with a
 	// separate mutex.
-	checkpoints         []chaincfg.Checkpoint
-	checkpointsByHeight map[int32]*chaincfg.Checkpoint
-	db                  database.DB
-	chainParams         *chaincfg.Params
-	timeSource          MedianTimeSource
-	sigCache            *txscript.SigCache
-	indexManager        IndexManager
-	hashCache           *txscript.HashCache
+	db             database.DB
+	chainParams    *chaincfg.Params
+	timeSource     MedianTimeSource
+	sigCache       *txscript.SigCache
+	indexManager   IndexManager
+	hashCache      *txscript.HashCache
+	payees         PayeeSource
+	coinbaseChecks []CoinbaseCheck
+	maxReorgDepth  int32
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -126,6 +127,41 @@ type BlockChain struct {
 	index     *blockIndex
 	bestChain *chainView
 
+	// utxoCache holds the changes to the utxo set made by connecting
+	// blocks until they are flushed to the database.  It has its own lock,
+	// however it is only modified with the chain lock held for writes.
+	utxoCache *utxoCache
+
+	// These fields are related to pruning the data of old blocks.  They
+	// are protected by the chain lock.
+	//
+	// pruneTarget is the target size in bytes of the block data kept in
+	// the database or zero when pruning is disabled.
+	//
+	// pruned is whether the data of any blocks has been pruned and
+	// pruneHeight is the height of the first block in the main chain whose
+	// data is still stored.
+	pruneTarget uint64
+	pruned      bool
+	pruneHeight int32
+
+	// These fields are related to validating the blocks before the utxo
+	// set snapshot the chain was bootstrapped from.  They are protected by
+	// the chain lock.
+	//
+	// snapshot describes the snapshot until the blocks before it have been
+	// validated and is nil otherwise.
+	//
+	// snapshotCache holds the utxo set built from the blocks before the
+	// snapshot up to snapshotTip, the last validated block.
+	//
+	// snapshotPending holds blocks which were received before their
+	// parents were validated.
+	snapshot        *UtxoSnapshotInfo
+	snapshotCache   *utxoCache
+	snapshotTip     *blockNode
+	snapshotPending map[chainhash.Hash]*ulordutil.Block
+
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -133,10 +169,16 @@ type BlockChain struct {
 	prevOrphans  map[chainhash.Hash][]*orphanBlock
 	oldestOrphan *orphanBlock
 
-	// These fields are related to checkpoint handling.  They are protected
-	// by the chain lock.
-	nextCheckpoint *chaincfg.Checkpoint
-	checkpointNode *blockNode
+	// These fields are related to checkpoint handling.  The checkpoints and
+	// the map of them by height are protected by the checkpoints lock.
+	// They are replaced rather than modified when checkpoints are added at
+	// runtime, so the returned slices remain valid.  The remaining fields
+	// are protected by the chain lock.
+	checkpointsLock     sync.RWMutex
+	checkpoints         []chaincfg.Checkpoint
+	checkpointsByHeight map[int32]*chaincfg.Checkpoint
+	nextCheckpoint      *chaincfg.Checkpoint
+	checkpointNode      *blockNode
 
 	// The state is used as a fairly efficient way to cache information
 	// about the current best chain state that is returned to callers when
@@ -180,10 +222,10 @@ type BlockChain struct {
 	unknownRulesWarned    bool
 	unknownVersionsWarned bool
 
-	// The notifications field stores a slice of callbacks to be executed on
-	// certain blockchain events.
+	// The notifications field stores the subscriptions whose callbacks are
+	// executed on certain blockchain events.
 	notificationsLock sync.RWMutex
-	notifications     []NotificationCallback
+	notifications     []*Subscription
 }
 
 // HaveBlock returns whether or not the chain instance has the block represented
@@ -622,14 +664,6 @@ func (b *BlockChain) connectBlock(node *blockNode, block *ulordutil.Block,
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails removing all of the utxos spent and adding the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
-		if err != nil {
-			return err
-		}
-
 		// Update the transaction spend journal by adding a record for
 		// the block that contains all txos spent by it.
 		err = dbPutSpendJournalEntry(dbTx, block.Hash(), stxos)
@@ -653,8 +687,14 @@ func (b *BlockChain) connectBlock(node *blockNode, block *ulordutil.Block,
 		return err
 	}
 
+	// Update the utxo set using the state of the utxo view.  This entails
+	// removing all of the utxos spent and adding the new ones created by
+	// the block.  The changes are held in the utxo cache until it is
+	// flushed.
+	b.utxoCache.commit(view)
+
 	// Prune fully spent entries and mark all entries in the view unmodified
-	// now that the modifications have been committed to the database.
+	// now that the modifications have been committed to the utxo cache.
 	view.commit()
 
 	// This node is now the end of the best chain.
@@ -669,6 +709,19 @@ func (b *BlockChain) connectBlock(node *blockNode, block *ulordutil.Block,
 	b.stateSnapshot = state
 	b.stateLock.Unlock()
 
+	// Write the changes to the utxo set to the database when the utxo
+	// cache is due to be flushed.
+	err = b.utxoCache.maybeFlush(&node.hash, node.height, FlushPeriodic)
+	if err != nil {
+		return err
+	}
+
+	// Delete the data of the oldest blocks when pruning is enabled and the
+	// block data exceeds the target size.
+	if err := b.maybePruneBlocks(); err != nil {
+		return err
+	}
+
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
 	// updating wallets.
@@ -690,10 +743,20 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *ulordutil.Block, vi
 			"block at the end of the main chain")
 	}
 
+	// Write the changes to the utxo set held in the utxo cache to the
+	// database first since disconnecting blocks updates the utxo set in the
+	// database directly.  This ensures the utxo set in the database is
+	// always consistent with an ancestor of the best chain, which can be
+	// recovered by connecting blocks.
+	err := b.utxoCache.flush(&node.hash, node.height)
+	if err != nil {
+		return err
+	}
+
 	// Load the previous block since some details for it are needed below.
 	prevNode := node.parent
 	var prevBlock *ulordutil.Block
-	err := b.db.View(func(dbTx database.Tx) error {
+	err = b.db.View(func(dbTx database.Tx) error {
 		var err error
 		prevBlock, err = dbFetchBlockByNode(dbTx, prevNode)
 		return err
@@ -741,6 +804,10 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *ulordutil.Block, vi
 		if err != nil {
 			return err
 		}
+		err = dbPutUtxoStateConsistency(dbTx, &prevNode.hash)
+		if err != nil {
+			return err
+		}
 
 		// Before we delete the spend journal entry for this back,
 		// we'll fetch it as is so the indexers can utilize if needed.
@@ -772,6 +839,11 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *ulordutil.Block, vi
 		return err
 	}
 
+	// Remove the entries updated in the database from the utxo cache so it
+	// doesn't return stale entries.
+	b.utxoCache.purge(view)
+	b.utxoCache.setConsistentState(&prevNode.hash, prevNode.height)
+
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
@@ -848,6 +920,17 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	// Write the changes to the utxo set held in the utxo cache to the
+	// database before disconnecting blocks since the legacy spend journal
+	// entries of the blocks may require searching the utxo set in the
+	// database.
+	if detachNodes.Len() != 0 {
+		err := b.utxoCache.flush(&tip.hash, tip.height)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Track the old and new best chains heads.
 	oldBest := tip
 	newBest := tip
@@ -886,7 +969,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err = view.fetchInputUtxos(b.db, block)
+		err = view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -953,7 +1036,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
 		if b.index.NodeStatus(n).KnownValid() {
-			err = view.fetchInputUtxos(b.db, block)
+			err = view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return err
 			}
@@ -998,6 +1081,19 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 	view = NewUtxoViewpoint()
 	view.SetBestHash(&b.bestChain.Tip().hash)
 
+	// Notify the subscribers about the reorganization.  The notifications
+	// of the disconnected and connected blocks follow.
+	reorgData := &ReorganizeNtfnData{
+		OldHash:   oldBest.hash,
+		OldHeight: oldBest.height,
+		NewHash:   newBest.hash,
+		NewHeight: newBest.height,
+		Depth:     int32(detachNodes.Len()),
+	}
+	b.chainLock.Unlock()
+	b.sendNotification(NTReorganizeStarted, reorgData)
+	b.chainLock.Lock()
+
 	// Disconnect blocks from the main chain.
 	for i, e := 0, detachNodes.Front(); e != nil; i, e = i+1, e.Next() {
 		n := e.Value.(*blockNode)
@@ -1005,7 +1101,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1032,7 +1128,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1054,6 +1150,10 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	b.chainLock.Unlock()
+	b.sendNotification(NTReorganizeFinished, reorgData)
+	b.chainLock.Lock()
+
 	// Log the point where the chain forked and old and new best chain
 	// heads.
 	if forkNode != nil {
@@ -1131,7 +1231,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *ulordutil.Block, f
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
-			err := view.fetchInputUtxos(b.db, block)
+			err := view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return false, err
 			}
@@ -1198,6 +1298,36 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *ulordutil.Block, f
 	// blocks that form the (now) old fork from the main chain, and attach
 	// the blocks that form the new chain to the main chain starting at the
 	// common ancenstor (the point where the chain forked).
+	//
+	// Reorganizations which would disconnect more blocks than the maximum
+	// reorganize depth are rejected since the blocks of the main chain are
+	// considered final by then.  The side chain can still be made the main
+	// chain explicitly with ReconsiderBlock or by invalidating the main
+	// chain with InvalidateBlock.
+	tip := b.bestChain.Tip()
+	fork := b.bestChain.FindFork(node)
+	if depth := tip.height - fork.height; b.maxReorgDepth > 0 &&
+		depth > b.maxReorgDepth {
+
+		log.Warnf("Rejecting reorganize to block %v (height %d) which "+
+			"would disconnect %d blocks back to block %v (height "+
+			"%d)", node.hash, node.height, depth, fork.hash,
+			fork.height)
+		b.chainLock.Unlock()
+		b.sendNotification(NTReorganizeRejected, &ReorganizeNtfnData{
+			OldHash:   tip.hash,
+			OldHeight: tip.height,
+			NewHash:   node.hash,
+			NewHeight: node.height,
+			Depth:     depth,
+		})
+		b.chainLock.Lock()
+
+		str := fmt.Sprintf("block %v causes a reorganize of %d blocks "+
+			"which is deeper than the maximum of %d", node.hash,
+			depth, b.maxReorgDepth)
+		return false, ruleError(ErrReorgTooDeep, str)
+	}
 	detachNodes, attachNodes := b.getReorganizeNodes(node)
 
 	// Reorganize the chain.
@@ -1607,7 +1737,7 @@ func (b *BlockChain) locateHeaders(locator BlockLocator, hashStop *chainhash.Has
 
 // LocateHeaders returns the headers of the blocks after the first known block
 // in the locator until the provided stop hash is reached, or up to a max of
-// wire.MaxBlockHeadersPerMsg headers.
+// wire.DefaultBlockHeadersPerMsg headers.
 //
 // In addition, there are two special cases:
 //
@@ -1620,7 +1750,8 @@ func (b *BlockChain) locateHeaders(locator BlockLocator, hashStop *chainhash.Has
 // This function is safe for concurrent access.
 func (b *BlockChain) LocateHeaders(locator BlockLocator, hashStop *chainhash.Hash) []wire.BlockHeader {
 	b.chainLock.RLock()
-	headers := b.locateHeaders(locator, hashStop, wire.MaxBlockHeadersPerMsg)
+	headers := b.locateHeaders(locator, hashStop,
+		wire.DefaultBlockHeadersPerMsg)
 	b.chainLock.RUnlock()
 	return headers
 }
@@ -1711,6 +1842,49 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// UtxoCacheMaxSize is the maximum size in bytes of the cache holding
+	// unspent transaction outputs and the changes to the utxo set which
+	// have not been written to the database yet.  The changes are written
+	// when the cache is full, periodically, and when FlushUtxoCache is
+	// called.
+	//
+	// This field can be zero to use DefaultUtxoCacheMaxSize.
+	UtxoCacheMaxSize uint64
+
+	// Prune is the target size in bytes of the block data kept in the
+	// database.  When it is exceeded, the data and spend journal entries
+	// of the oldest blocks are deleted while their headers and the utxo
+	// set are kept.  The data of the last MinBlocksToKeep blocks of the
+	// main chain is never deleted.
+	//
+	// This field can be zero to keep the data of all blocks.
+	Prune uint64
+
+	// Payees provides the masternode and superblock payees the coinbase
+	// transactions must pay when the chain parameters require masternode
+	// payments and superblocks.
+	//
+	// This field can be nil in which case masternode and superblock
+	// payments are not checked at all, since the outputs paying them can't
+	// be told apart from the outputs paying the miner.  Superblocks can't
+	// pay out their budget then either.  Founder rewards are always
+	// checked.
+	Payees PayeeSource
+
+	// CoinbaseChecks are additional checks of the payouts made by coinbase
+	// transactions which are run after the checks required by the chain
+	// parameters whenever a block is connected.
+	//
+	// This field can be nil if no additional checks are needed.
+	CoinbaseChecks []CoinbaseCheck
+
+	// MaxReorgDepth is the maximum number of blocks a reorganization of the
+	// main chain may disconnect.  Blocks which would cause a deeper
+	// reorganization are rejected and announced with NTReorganizeRejected.
+	//
+	// This field can be zero to allow reorganizations of any depth.
+	MaxReorgDepth int32
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1744,10 +1918,16 @@ func New(config *Config) (*BlockChain, error) {
 		}
 	}
 
+	utxoCacheMaxSize := config.UtxoCacheMaxSize
+	if utxoCacheMaxSize == 0 {
+		utxoCacheMaxSize = DefaultUtxoCacheMaxSize
+	}
+
 	params := config.ChainParams
 	targetTimespan := int64(params.TargetTimespan / time.Second)
 	targetTimePerBlock := int64(params.TargetTimePerBlock / time.Second)
 	adjustmentFactor := params.RetargetAdjustmentFactor
+	checks := coinbaseChecks(params, config.Payees, config.CoinbaseChecks)
 	b := BlockChain{
 		checkpoints:         config.Checkpoints,
 		checkpointsByHeight: checkpointsByHeight,
@@ -1761,7 +1941,12 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
+		payees:              config.Payees,
+		coinbaseChecks:      checks,
+		maxReorgDepth:       config.MaxReorgDepth,
 		bestChain:           newChainView(nil),
+		utxoCache:           newUtxoCache(config.DB, utxoCacheMaxSize),
+		pruneTarget:         config.Prune,
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
 		warningCaches:       newThresholdCaches(vbNumBits),
@@ -1780,6 +1965,23 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Recover the utxo set when the utxo cache wasn't flushed before the
+	// last shutdown.
+	if err := b.initUtxoState(config.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// Determine whether the data of old blocks has been pruned.
+	if err := b.initPruneState(); err != nil {
+		return nil, err
+	}
+
+	// Resume validating the blocks before the utxo snapshot the chain was
+	// bootstrapped from.
+	if err := b.initSnapshotState(); err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -964,3 +964,80 @@ func TestIntervalBlockHashes(t *testing.T) {
 		}
 	}
 }
+
+// TestMaxReorgDepth ensures reorganizations deeper than the maximum reorganize
+// depth are rejected and announced and can still be made explicitly.
+func TestMaxReorgDepth(t *testing.T) {
+	// Load up blocks such that there is a side chain which has more work
+	// than the main chain once block 5a is added.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*ulordutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("maxreorgdepth",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+	chain.maxReorgDepth = 1
+
+	var rejected []*ReorganizeNtfnData
+	chain.Subscribe(func(n *Notification) {
+		rejected = append(rejected, n.Data.(*ReorganizeNtfnData))
+	}, NTReorganizeRejected)
+
+	for i := 1; i < len(blocks)-1; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+
+	// Block 5a would disconnect blocks 4 and 3.
+	block5a := blocks[len(blocks)-1]
+	_, _, err = chain.ProcessBlock(block5a, BFNone)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrReorgTooDeep {
+		t.Fatalf("ProcessBlock: got error %v, want %v", err,
+			ErrReorgTooDeep)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[4].Hash() {
+		t.Fatalf("got tip %v after rejected reorganize, want %v", tip,
+			blocks[4].Hash())
+	}
+	wantData := ReorganizeNtfnData{
+		OldHash:   *blocks[4].Hash(),
+		OldHeight: 4,
+		NewHash:   *block5a.Hash(),
+		NewHeight: 5,
+		Depth:     2,
+	}
+	if len(rejected) != 1 || *rejected[0] != wantData {
+		t.Fatalf("got rejected reorganize notifications %v, want %+v",
+			rejected, wantData)
+	}
+
+	// Reconsidering the side chain reorganizes the chain regardless of
+	// the maximum depth.
+	if err := chain.ReconsiderBlock(block5a.Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: unexpected error: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *block5a.Hash() {
+		t.Fatalf("got tip %v after reconsidering, want %v", tip,
+			block5a.Hash())
+	}
+}
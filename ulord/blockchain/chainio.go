@@ -67,6 +67,11 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxosetv2")
 
+	// utxoStateConsistencyKeyName is the name of the db key used to store
+	// the hash of the block the utxo set in the database is consistent
+	// with.
+	utxoStateConsistencyKeyName = []byte("utxostateconsistency")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -730,10 +735,19 @@ func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry,
 // When there is no entry for the provided output, nil will be returned for both
 // the entry and the error.
 func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, error) {
+	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	return dbFetchUtxoEntryFromBucket(utxoBucket, outpoint)
+}
+
+// dbFetchUtxoEntryFromBucket fetches the specified transaction output from the
+// utxo set in the passed bucket.
+//
+// When there is no entry for the provided output, nil will be returned for both
+// the entry and the error.
+func dbFetchUtxoEntryFromBucket(utxoBucket database.Bucket, outpoint wire.OutPoint) (*UtxoEntry, error) {
 	// Fetch the unspent transaction output information for the passed
 	// transaction output.  Return now when there is no entry.
 	key := outpointKey(outpoint)
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
 	serializedUtxo := utxoBucket.Get(*key)
 	recycleOutpointKey(key)
 	if serializedUtxo == nil {
@@ -778,35 +792,78 @@ func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
 			continue
 		}
 
-		// Remove the utxo entry if it is spent.
-		if entry.IsSpent() {
-			key := outpointKey(outpoint)
-			err := utxoBucket.Delete(*key)
-			recycleOutpointKey(key)
-			if err != nil {
-				return err
-			}
-
-			continue
-		}
-
-		// Serialize and store the utxo entry.
-		serialized, err := serializeUtxoEntry(entry)
+		err := dbPutUtxoEntry(utxoBucket, outpoint, entry)
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+// dbPutUtxoEntry stores the passed utxo entry in the utxo set bucket or removes
+// it from the bucket when it is spent.
+func dbPutUtxoEntry(utxoBucket database.Bucket, outpoint wire.OutPoint, entry *UtxoEntry) error {
+	// Remove the utxo entry if it is spent.
+	if entry.IsSpent() {
 		key := outpointKey(outpoint)
-		err = utxoBucket.Put(*key, serialized)
-		// NOTE: The key is intentionally not recycled here since the
-		// database interface contract prohibits modifications.  It will
-		// be garbage collected normally when the database is done with
-		// it.
-		if err != nil {
-			return err
+		err := utxoBucket.Delete(*key)
+		recycleOutpointKey(key)
+		return err
+	}
+
+	// Serialize and store the utxo entry.
+	serialized, err := serializeUtxoEntry(entry)
+	if err != nil {
+		return err
+	}
+	key := outpointKey(outpoint)
+	// NOTE: The key is intentionally not recycled here since the database
+	// interface contract prohibits modifications.  It will be garbage
+	// collected normally when the database is done with it.
+	return utxoBucket.Put(*key, serialized)
+}
+
+// dbFetchUtxoStateConsistency uses an existing database transaction to fetch
+// the hash of the block the utxo set in the database is consistent with.  It
+// returns nil when the database predates the utxo cache, in which case the
+// utxo set was always written together with the best chain state.
+func dbFetchUtxoStateConsistency(dbTx database.Tx) (*chainhash.Hash, error) {
+	return dbFetchBlockHashKey(dbTx, utxoStateConsistencyKeyName)
+}
+
+// dbPutUtxoStateConsistency uses an existing database transaction to store the
+// hash of the block the utxo set in the database is consistent with.
+func dbPutUtxoStateConsistency(dbTx database.Tx, hash *chainhash.Hash) error {
+	return dbPutBlockHashKey(dbTx, utxoStateConsistencyKeyName, hash)
+}
+
+// dbFetchBlockHashKey uses an existing database transaction to fetch the block
+// hash stored under the passed metadata key.  It returns nil when there is no
+// such key.
+func dbFetchBlockHashKey(dbTx database.Tx, keyName []byte) (*chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Get(keyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	if len(serialized) != chainhash.HashSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt block hash in %s", keyName),
 		}
 	}
 
-	return nil
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	return &hash, nil
+}
+
+// dbPutBlockHashKey uses an existing database transaction to store the passed
+// block hash under the passed metadata key.
+func dbPutBlockHashKey(dbTx database.Tx, keyName []byte, hash *chainhash.Hash) error {
+	serialized := make([]byte, chainhash.HashSize)
+	copy(serialized, hash[:])
+	return dbTx.Metadata().Put(keyName, serialized)
 }
 
 // -----------------------------------------------------------------------------
@@ -1092,6 +1149,12 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
+		// The empty utxo set is consistent with the genesis block.
+		err = dbPutUtxoStateConsistency(dbTx, &node.hash)
+		if err != nil {
+			return err
+		}
+
 		// Store the genesis block into the database.
 		return dbStoreBlock(dbTx, genesisBlock)
 	})
@@ -1381,6 +1444,9 @@ func (b *BlockChain) BlockByHeight(blockHeight int32) (*ulordutil.Block, error)
 		str := fmt.Sprintf("no block at height %d exists", blockHeight)
 		return nil, errNotInMainChain(str)
 	}
+	if !b.index.NodeStatus(node).HaveData() {
+		return nil, BlockPrunedError(node.hash)
+	}
 
 	// Load the block from the database and return it.
 	var block *ulordutil.Block
@@ -1404,6 +1470,9 @@ func (b *BlockChain) BlockByHash(hash *chainhash.Hash) (*ulordutil.Block, error)
 		str := fmt.Sprintf("block %s is not in the main chain", hash)
 		return nil, errNotInMainChain(str)
 	}
+	if !b.index.NodeStatus(node).HaveData() {
+		return nil, BlockPrunedError(node.hash)
+	}
 
 	// Load the block from the database and return it.
 	var block *ulordutil.Block
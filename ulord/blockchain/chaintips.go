@@ -0,0 +1,131 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// ChainTipStatus describes the state of the branch of the block tree which
+// ends at a chain tip.
+type ChainTipStatus int
+
+const (
+	// ChainTipActive indicates the tip is the tip of the main chain.
+	ChainTipActive ChainTipStatus = iota
+
+	// ChainTipValidFork indicates the tip is the tip of a side chain whose
+	// blocks have been fully validated.
+	ChainTipValidFork
+
+	// ChainTipValidHeaders indicates the blocks of the side chain are
+	// stored but the tip has not been fully validated.
+	ChainTipValidHeaders
+
+	// ChainTipHeadersOnly indicates only the header of the tip is known.
+	ChainTipHeadersOnly
+
+	// ChainTipInvalid indicates the tip or one of its ancestors failed
+	// validation.
+	ChainTipInvalid
+)
+
+// chainTipStatusStrings is a map of chain tip statuses back to their constant
+// names for pretty printing.
+var chainTipStatusStrings = map[ChainTipStatus]string{
+	ChainTipActive:       "active",
+	ChainTipValidFork:    "valid-fork",
+	ChainTipValidHeaders: "valid-headers",
+	ChainTipHeadersOnly:  "headers-only",
+	ChainTipInvalid:      "invalid",
+}
+
+// String returns the status as the human-readable name used by the getchaintips
+// RPC.
+func (s ChainTipStatus) String() string {
+	if str, ok := chainTipStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown ChainTipStatus (%d)", int(s))
+}
+
+// ChainTip describes a block of the block tree which has no children.
+type ChainTip struct {
+	// Height and Hash identify the tip.
+	Height int32
+	Hash   chainhash.Hash
+
+	// BranchLen is the number of blocks between the tip and the block at
+	// which its branch forks from the main chain.  It is zero for the tip
+	// of the main chain.
+	BranchLen int32
+
+	// Status is the state of the branch.
+	Status ChainTipStatus
+}
+
+// chainTipStatus returns the status of the branch ending at the passed side
+// chain tip.
+func chainTipStatus(status blockStatus) ChainTipStatus {
+	switch {
+	case status.KnownInvalid():
+		return ChainTipInvalid
+	case status.KnownValid():
+		return ChainTipValidFork
+	case status.HaveData():
+		return ChainTipValidHeaders
+	default:
+		return ChainTipHeadersOnly
+	}
+}
+
+// ChainTips returns the tips of all known branches of the block tree, including
+// the tip of the main chain, ordered by decreasing height.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTips() []ChainTip {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	b.index.RLock()
+	parents := make(map[*blockNode]struct{}, len(b.index.index))
+	for _, node := range b.index.index {
+		if node.parent != nil {
+			parents[node.parent] = struct{}{}
+		}
+	}
+
+	var tips []ChainTip
+	bestTip := b.bestChain.Tip()
+	for _, node := range b.index.index {
+		if _, ok := parents[node]; ok {
+			continue
+		}
+
+		tip := ChainTip{Height: node.height, Hash: node.hash}
+		if node == bestTip {
+			tip.Status = ChainTipActive
+		} else {
+			fork := b.bestChain.FindFork(node)
+			if fork != nil {
+				tip.BranchLen = node.height - fork.height
+			}
+			tip.Status = chainTipStatus(node.status)
+		}
+		tips = append(tips, tip)
+	}
+	b.index.RUnlock()
+
+	sort.Slice(tips, func(i, j int) bool {
+		if tips[i].Height != tips[j].Height {
+			return tips[i].Height > tips[j].Height
+		}
+		return tips[i].Hash.String() < tips[j].Hash.String()
+	})
+	return tips
+}
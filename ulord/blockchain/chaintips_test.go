@@ -0,0 +1,55 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+)
+
+// TestChainTips ensures the tips of all branches of the block tree are
+// reported with the length and status of their branch.
+func TestChainTips(t *testing.T) {
+	// Construct a synthetic block index with the main chain and three
+	// side chains.
+	// genesis -> 1 -> 2 -> 3 -> 4 -> 5  (main chain)
+	//                 \-> 3a -> 4a      (validated)
+	//                 \-> 3b            (invalid)
+	//            \-> 2c                 (header only)
+	chain := newFakeChain(&chaincfg.MainNetParams)
+	mainNodes := chainedNodes(chain.bestChain.Genesis(), 5)
+	forkA := chainedNodes(mainNodes[1], 2)
+	forkB := chainedNodes(mainNodes[1], 1)
+	forkC := chainedNodes(mainNodes[0], 1)
+	for _, nodes := range [][]*blockNode{mainNodes, forkA, forkB, forkC} {
+		for _, node := range nodes {
+			chain.index.AddNode(node)
+		}
+	}
+	for _, node := range append(mainNodes, forkA...) {
+		node.status = statusDataStored | statusValid
+	}
+	forkB[0].status = statusDataStored | statusValidateFailed
+	chain.bestChain.SetTip(mainNodes[4])
+
+	want := []ChainTip{
+		{Height: 5, Hash: mainNodes[4].hash, Status: ChainTipActive},
+		{Height: 4, Hash: forkA[1].hash, BranchLen: 2,
+			Status: ChainTipValidFork},
+		{Height: 3, Hash: forkB[0].hash, BranchLen: 1,
+			Status: ChainTipInvalid},
+		{Height: 2, Hash: forkC[0].hash, BranchLen: 1,
+			Status: ChainTipHeadersOnly},
+	}
+	if got := chain.ChainTips(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ChainTips: got %+v, want %+v", got, want)
+	}
+
+	if got := ChainTipValidHeaders.String(); got != "valid-headers" {
+		t.Fatalf("String: got %q, want %q", got, "valid-headers")
+	}
+}
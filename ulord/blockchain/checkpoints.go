@@ -33,14 +33,17 @@ func newHashFromStr(hexStr string) *chainhash.Hash {
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) Checkpoints() []chaincfg.Checkpoint {
-	return b.checkpoints
+	b.checkpointsLock.RLock()
+	checkpoints := b.checkpoints
+	b.checkpointsLock.RUnlock()
+	return checkpoints
 }
 
 // HasCheckpoints returns whether this BlockChain has checkpoints defined.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) HasCheckpoints() bool {
-	return len(b.checkpoints) > 0
+	return len(b.Checkpoints()) > 0
 }
 
 // LatestCheckpoint returns the most recent checkpoint (regardless of whether it
@@ -49,10 +52,11 @@ func (b *BlockChain) HasCheckpoints() bool {
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) LatestCheckpoint() *chaincfg.Checkpoint {
-	if !b.HasCheckpoints() {
+	checkpoints := b.Checkpoints()
+	if len(checkpoints) == 0 {
 		return nil
 	}
-	return &b.checkpoints[len(b.checkpoints)-1]
+	return &checkpoints[len(checkpoints)-1]
 }
 
 // verifyCheckpoint returns whether the passed block height and hash combination
@@ -64,7 +68,9 @@ func (b *BlockChain) verifyCheckpoint(height int32, hash *chainhash.Hash) bool {
 	}
 
 	// Nothing to check if there is no checkpoint data for the block height.
+	b.checkpointsLock.RLock()
 	checkpoint, exists := b.checkpointsByHeight[height]
+	b.checkpointsLock.RUnlock()
 	if !exists {
 		return true
 	}
@@ -92,7 +98,7 @@ func (b *BlockChain) findPreviousCheckpoint() (*blockNode, error) {
 	// Perform the initial search to find and cache the latest known
 	// checkpoint if the best chain is not known yet or we haven't already
 	// previously searched.
-	checkpoints := b.checkpoints
+	checkpoints := b.Checkpoints()
 	numCheckpoints := len(checkpoints)
 	if b.checkpointNode == nil && b.nextCheckpoint == nil {
 		// Loop backwards through the available checkpoints to find one
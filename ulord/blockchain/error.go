@@ -220,6 +220,25 @@ const (
 	// current chain tip. This is not a block validation rule, but is required
 	// for block proposals submitted via getblocktemplate RPC.
 	ErrPrevBlockNotBest
+
+	// ErrMissingFounderReward indicates the coinbase transaction of a block
+	// does not pay the founder reward required at its height.
+	ErrMissingFounderReward
+
+	// ErrBadMasternodePayment indicates the coinbase transaction of a
+	// block does not pay the masternode share of the subsidy to the
+	// expected masternode.
+	ErrBadMasternodePayment
+
+	// ErrBadSuperblockPayment indicates the coinbase transaction of a
+	// superblock does not make the payments of the budget approved by the
+	// governance system.
+	ErrBadSuperblockPayment
+
+	// ErrReorgTooDeep indicates a block would cause a reorganization of
+	// the main chain which disconnects more blocks than the maximum
+	// reorganize depth.
+	ErrReorgTooDeep
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -267,6 +286,10 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrPreviousBlockUnknown:      "ErrPreviousBlockUnknown",
 	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:          "ErrPrevBlockNotBest",
+	ErrMissingFounderReward:      "ErrMissingFounderReward",
+	ErrBadMasternodePayment:      "ErrBadMasternodePayment",
+	ErrBadSuperblockPayment:      "ErrBadSuperblockPayment",
+	ErrReorgTooDeep:              "ErrReorgTooDeep",
 }
 
 // String returns the ErrorCode as a human-readable name.
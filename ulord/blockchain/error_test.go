@@ -58,6 +58,10 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPreviousBlockUnknown, "ErrPreviousBlockUnknown"},
 		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
+		{ErrMissingFounderReward, "ErrMissingFounderReward"},
+		{ErrBadMasternodePayment, "ErrBadMasternodePayment"},
+		{ErrBadSuperblockPayment, "ErrBadSuperblockPayment"},
+		{ErrReorgTooDeep, "ErrReorgTooDeep"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
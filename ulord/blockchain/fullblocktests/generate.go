@@ -313,15 +313,16 @@ func calcMerkleRoot(txns []*wire.MsgTx) chainhash.Hash {
 	return *merkles[len(merkles)-1]
 }
 
-// solveBlock attempts to find a nonce which makes the passed block header hash
-// to a value less than the target difficulty.  When a successful solution is
+// solveBlock attempts to find a nonce which makes the proof-of-work hash of the
+// passed block header on the passed network a value less than the target
+// difficulty.  When a successful solution is
 // found true is returned and the nonce field of the passed header is updated
 // with the solution.  False is returned if no solution exists.
 //
 // NOTE: This function will never solve blocks with a nonce of 0.  This is done
 // so the 'nextBlock' function can properly detect when a nonce was modified by
 // a munge function.
-func solveBlock(header *wire.BlockHeader) bool {
+func solveBlock(header *wire.BlockHeader, params *chaincfg.Params) bool {
 	// sbResult is used by the solver goroutines to send results.
 	type sbResult struct {
 		found bool
@@ -342,7 +343,7 @@ func solveBlock(header *wire.BlockHeader) bool {
 				return
 			default:
 				hdr.Nonce = i
-				hash := hdr.BlockHash()
+				hash := params.PowHash(&hdr)
 				if blockchain.HashToBig(&hash).Cmp(
 					targetDifficulty) <= 0 {
 
@@ -533,7 +534,7 @@ func (g *testGenerator) nextBlock(blockName string, spend *spendableOut, mungers
 
 	// Only solve the block if the nonce wasn't manually changed by a munge
 	// function.
-	if block.Header.Nonce == curNonce && !solveBlock(&block.Header) {
+	if block.Header.Nonce == curNonce && !solveBlock(&block.Header, g.params) {
 		panic(fmt.Sprintf("Unable to solve block at height %d",
 			nextHeight))
 	}
@@ -1441,11 +1442,11 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	{
 		origHash := b46.BlockHash()
 		for {
-			// Keep incrementing the nonce until the hash treated as
-			// a uint256 is higher than the limit.
+			// Keep incrementing the nonce until the proof-of-work
+			// hash treated as a uint256 is higher than the limit.
 			b46.Header.Nonce++
-			blockHash := b46.BlockHash()
-			hashNum := blockchain.HashToBig(&blockHash)
+			powHash := g.params.PowHash(&b46.Header)
+			hashNum := blockchain.HashToBig(&powHash)
 			if hashNum.Cmp(g.params.PowLimit) >= 0 {
 				break
 			}
@@ -12,6 +12,7 @@ import (
 	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
 )
 
 // newHashFromStr converts the passed big-endian hex string into a
@@ -98,14 +99,18 @@ var regressionNetParams = &chaincfg.Params{
 	DefaultPort: "18444",
 
 	// Chain parameters
-	GenesisBlock:             &regTestGenesisBlock,
-	GenesisHash:              newHashFromStr("5bec7567af40504e0994db3b573c186fffcc4edefe096ff2e58d00523bd7e8a6"),
-	PowLimit:                 regressionPowLimit,
-	PowLimitBits:             0x207fffff,
-	CoinbaseMaturity:         100,
-	BIP0034Height:            100000000, // Not active - Permit ver 1 blocks
-	BIP0065Height:            1351,      // Used by regression tests
-	BIP0066Height:            1251,      // Used by regression tests
+	GenesisBlock:     &regTestGenesisBlock,
+	GenesisHash:      newHashFromStr("5bec7567af40504e0994db3b573c186fffcc4edefe096ff2e58d00523bd7e8a6"),
+	PowLimit:         regressionPowLimit,
+	PowLimitBits:     0x207fffff,
+	CoinbaseMaturity: 100,
+	BIP0034Height:    100000000, // Not active - Permit ver 1 blocks
+	BIP0065Height:    1351,      // Used by regression tests
+	BIP0066Height:    1251,      // Used by regression tests
+	Subsidy: chaincfg.SubsidySchedule{
+		BaseSubsidy:       50 * ulordutil.SatoshiPerBitcoin,
+		ReductionInterval: 150,
+	},
 	SubsidyReductionInterval: 150,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
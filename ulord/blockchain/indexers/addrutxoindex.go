@@ -0,0 +1,707 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// addrUtxoIndexName is the human-readable name for the index.
+	addrUtxoIndexName = "address utxo index"
+
+	// addrDeltaKeySize is the number of bytes a key in the deltas bucket
+	// consumes.  It consists of the address key + 4 bytes block height +
+	// 4 bytes transaction index + 1 byte direction + 4 bytes input or
+	// output index.
+	addrDeltaKeySize = addrKeySize + 4 + 4 + 1 + 4
+
+	// addrDeltaValueSize is the number of bytes a value in the deltas
+	// bucket consumes.  It consists of the transaction hash + 8 bytes
+	// amount.
+	addrDeltaValueSize = chainhash.HashSize + 8
+
+	// addrUtxoKeySize is the number of bytes a key in the utxos bucket
+	// consumes.  It consists of the address key + the transaction hash + 4
+	// bytes output index.
+	addrUtxoKeySize = addrKeySize + chainhash.HashSize + 4
+
+	// addrUtxoValueSize is the number of bytes a value in the utxos bucket
+	// consumes.  It consists of 8 bytes amount + 4 bytes block height.
+	addrUtxoValueSize = 8 + 4
+
+	// addrBalanceValueSize is the number of bytes a value in the balances
+	// bucket consumes.  It consists of 8 bytes balance + 8 bytes received.
+	addrBalanceValueSize = 8 + 8
+
+	// deltaOutput and deltaInput are the directions of deltas.
+	deltaOutput = 0
+	deltaInput  = 1
+)
+
+var (
+	// addrUtxoIndexKey is the key of the address utxo index and the db
+	// bucket used to house it.
+	addrUtxoIndexKey = []byte("addrutxoidx")
+
+	// addrDeltasBucketName, addrUtxosBucketName, and addrBalancesBucketName
+	// are the names of the buckets within the index bucket which house the
+	// deltas, unspent outputs, and balances of the addresses.
+	addrDeltasBucketName   = []byte("deltas")
+	addrUtxosBucketName    = []byte("utxos")
+	addrBalancesBucketName = []byte("balances")
+)
+
+// -----------------------------------------------------------------------------
+// The address utxo index maps addresses to the outputs which pay to them and
+// the inputs which spend those outputs, the outputs which remain unspent, and
+// the resulting balances.  Only outputs which pay to a single supported address
+// are indexed, so the outputs of bare multisig scripts are not.
+//
+// The index consists of three buckets within the index bucket.
+//
+// The deltas bucket holds an entry for every output paying to an address and
+// every input spending such an output.  The keys are ordered by their block
+// height and position in the block, so iterating the keys with the prefix of
+// an address yields its history in order of appearance in the block chain:
+//
+//   <addr key><height><tx index><direction><index> = <tx hash><amount>
+//
+//   Field           Type              Size
+//   addr key        [21]byte          21 bytes
+//   height          uint32            4 bytes (big endian)
+//   tx index        uint32            4 bytes (big endian)
+//   direction       uint8             1 byte (0 = output, 1 = input)
+//   index           uint32            4 bytes (big endian)
+//   tx hash         chainhash.Hash    32 bytes
+//   amount          int64             8 bytes (negative for inputs)
+//
+// The utxos bucket holds an entry for every output paying to an address which
+// is unspent:
+//
+//   <addr key><tx hash><output index> = <amount><height>
+//
+//   Field           Type              Size
+//   addr key        [21]byte          21 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes (big endian)
+//   amount          int64             8 bytes
+//   height          uint32            4 bytes
+//
+// The balances bucket holds the balance of every address which received any
+// funds along with the total amount it received:
+//
+//   <addr key> = <balance><received>
+//
+//   Field           Type              Size
+//   addr key        [21]byte          21 bytes
+//   balance         int64             8 bytes
+//   received        int64             8 bytes
+//
+// Unless noted otherwise, all integers are little endian.
+// -----------------------------------------------------------------------------
+
+// AddrBalance describes the funds of an address.  The amounts are in satoshi.
+type AddrBalance struct {
+	// Balance is the sum of the unspent outputs paying to the address.
+	Balance int64
+
+	// Received is the sum of all outputs which ever paid to the address.
+	Received int64
+}
+
+// AddrUtxo describes an unspent output paying to an address.
+type AddrUtxo struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	Height   int32
+}
+
+// AddrDelta describes an output which pays to an address or an input which
+// spends such an output.
+type AddrDelta struct {
+	// Hash is the hash of the transaction and Height the height of the
+	// block which contains it.  TxIndex is the position of the transaction
+	// in the block.
+	Hash    chainhash.Hash
+	Height  int32
+	TxIndex uint32
+
+	// Input reports whether the delta is an input of the transaction
+	// rather than an output, and Index is its position in the inputs or
+	// outputs of the transaction.
+	Input bool
+	Index uint32
+
+	// Amount is the amount credited to the address, which is negative for
+	// inputs.
+	Amount int64
+}
+
+// serializeAddrDeltaKey returns the key of the passed delta of the address.
+func serializeAddrDeltaKey(addrKey [addrKeySize]byte, delta *AddrDelta) []byte {
+	key := make([]byte, addrDeltaKeySize)
+	copy(key, addrKey[:])
+	offset := addrKeySize
+	binary.BigEndian.PutUint32(key[offset:], uint32(delta.Height))
+	offset += 4
+	binary.BigEndian.PutUint32(key[offset:], delta.TxIndex)
+	offset += 4
+	if delta.Input {
+		key[offset] = deltaInput
+	} else {
+		key[offset] = deltaOutput
+	}
+	offset++
+	binary.BigEndian.PutUint32(key[offset:], delta.Index)
+	return key
+}
+
+// serializeAddrDeltaValue returns the value of the passed delta.
+func serializeAddrDeltaValue(delta *AddrDelta) []byte {
+	value := make([]byte, addrDeltaValueSize)
+	copy(value, delta.Hash[:])
+	byteOrder.PutUint64(value[chainhash.HashSize:], uint64(delta.Amount))
+	return value
+}
+
+// deserializeAddrDelta decodes the passed key and value of a delta.
+func deserializeAddrDelta(key, value []byte) (*AddrDelta, error) {
+	if len(key) != addrDeltaKeySize || len(value) != addrDeltaValueSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected length %d "+
+			"of address delta key or %d of value", len(key),
+			len(value)))
+	}
+
+	var delta AddrDelta
+	offset := addrKeySize
+	delta.Height = int32(binary.BigEndian.Uint32(key[offset:]))
+	offset += 4
+	delta.TxIndex = binary.BigEndian.Uint32(key[offset:])
+	offset += 4
+	delta.Input = key[offset] == deltaInput
+	offset++
+	delta.Index = binary.BigEndian.Uint32(key[offset:])
+	copy(delta.Hash[:], value)
+	delta.Amount = int64(byteOrder.Uint64(value[chainhash.HashSize:]))
+	return &delta, nil
+}
+
+// serializeAddrUtxoKey returns the key of the passed output of the address.
+func serializeAddrUtxoKey(addrKey [addrKeySize]byte, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, addrUtxoKeySize)
+	copy(key, addrKey[:])
+	copy(key[addrKeySize:], outPoint.Hash[:])
+	binary.BigEndian.PutUint32(key[addrKeySize+chainhash.HashSize:],
+		outPoint.Index)
+	return key
+}
+
+// serializeAddrUtxoValue returns the value of an unspent output with the
+// passed amount and height.
+func serializeAddrUtxoValue(amount int64, height int32) []byte {
+	value := make([]byte, addrUtxoValueSize)
+	byteOrder.PutUint64(value, uint64(amount))
+	byteOrder.PutUint32(value[8:], uint32(height))
+	return value
+}
+
+// deserializeAddrUtxo decodes the passed key and value of an unspent output.
+func deserializeAddrUtxo(key, value []byte) (*AddrUtxo, error) {
+	if len(key) != addrUtxoKeySize || len(value) != addrUtxoValueSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected length %d "+
+			"of address utxo key or %d of value", len(key),
+			len(value)))
+	}
+
+	var utxo AddrUtxo
+	copy(utxo.OutPoint.Hash[:], key[addrKeySize:])
+	utxo.OutPoint.Index = binary.BigEndian.Uint32(
+		key[addrKeySize+chainhash.HashSize:])
+	utxo.Amount = int64(byteOrder.Uint64(value))
+	utxo.Height = int32(byteOrder.Uint32(value[8:]))
+	return &utxo, nil
+}
+
+// serializeAddrBalance returns the serialized balance for storage in the
+// index.
+func serializeAddrBalance(balance *AddrBalance) []byte {
+	value := make([]byte, addrBalanceValueSize)
+	byteOrder.PutUint64(value, uint64(balance.Balance))
+	byteOrder.PutUint64(value[8:], uint64(balance.Received))
+	return value
+}
+
+// deserializeAddrBalance decodes a balance serialized with
+// serializeAddrBalance.
+func deserializeAddrBalance(value []byte) (*AddrBalance, error) {
+	if len(value) != addrBalanceValueSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected length %d "+
+			"for serialized address balance", len(value)))
+	}
+	return &AddrBalance{
+		Balance:  int64(byteOrder.Uint64(value)),
+		Received: int64(byteOrder.Uint64(value[8:])),
+	}, nil
+}
+
+// AddrUtxoIndex implements an index of the outputs paying to addresses, the
+// inputs spending them, and the resulting unspent outputs and balances of the
+// addresses.  Unlike the address index, it does not depend on the transaction
+// index.
+//
+// NOTE: The index only includes transactions confirmed in blocks.
+type AddrUtxoIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the AddrUtxoIndex type implements the Indexer interface.
+var _ Indexer = (*AddrUtxoIndex)(nil)
+
+// Ensure the AddrUtxoIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*AddrUtxoIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *AddrUtxoIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the address utxo index.  This is part of the Indexer
+// interface.
+func (idx *AddrUtxoIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.  This is
+// part of the Indexer interface.
+func (idx *AddrUtxoIndex) Key() []byte {
+	return addrUtxoIndexKey
+}
+
+// Name returns the human-readable name of the index.  This is part of the
+// Indexer interface.
+func (idx *AddrUtxoIndex) Name() string {
+	return addrUtxoIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the index along with
+// the buckets within it.  This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(addrUtxoIndexKey)
+	if err != nil {
+		return err
+	}
+	for _, name := range [][]byte{addrDeltasBucketName,
+		addrUtxosBucketName, addrBalancesBucketName} {
+
+		if _, err := bucket.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addrKeyForScript returns the address key of the single supported address
+// the passed public key script pays to.  False is returned for scripts which
+// are non-standard or pay to several or unsupported addresses.
+func (idx *AddrUtxoIndex) addrKeyForScript(pkScript []byte) ([addrKeySize]byte, bool) {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil || len(addrs) != 1 {
+		return [addrKeySize]byte{}, false
+	}
+	addrKey, err := addrToKey(addrs[0])
+	if err != nil {
+		return [addrKeySize]byte{}, false
+	}
+	return addrKey, true
+}
+
+// addrUtxoBuckets houses the buckets of the index for the duration of a
+// database transaction.
+type addrUtxoBuckets struct {
+	deltas   database.Bucket
+	utxos    database.Bucket
+	balances database.Bucket
+}
+
+// buckets returns the buckets within the index bucket.
+func (idx *AddrUtxoIndex) buckets(dbTx database.Tx) *addrUtxoBuckets {
+	bucket := dbTx.Metadata().Bucket(addrUtxoIndexKey)
+	return &addrUtxoBuckets{
+		deltas:   bucket.Bucket(addrDeltasBucketName),
+		utxos:    bucket.Bucket(addrUtxosBucketName),
+		balances: bucket.Bucket(addrBalancesBucketName),
+	}
+}
+
+// blockDelta houses an output or input of a block along with the address it
+// involves.
+type blockDelta struct {
+	addrKey [addrKeySize]byte
+	delta   AddrDelta
+	height  int32 // Height of the spent output for inputs.
+}
+
+// blockDeltas returns the outputs of the passed block which pay to a single
+// supported address and the inputs which spend such outputs, in the order they
+// appear in the block.
+func (idx *AddrUtxoIndex) blockDeltas(block *ulordutil.Block,
+	stxos []blockchain.SpentTxOut) ([]blockDelta, error) {
+
+	var deltas []blockDelta
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			for inIdx := range tx.MsgTx().TxIn {
+				if stxoIdx >= len(stxos) {
+					return nil, AssertError("spend journal of " +
+						"block " + block.Hash().String() +
+						" is missing entries")
+				}
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+
+				addrKey, ok := idx.addrKeyForScript(stxo.PkScript)
+				if !ok {
+					continue
+				}
+				deltas = append(deltas, blockDelta{
+					addrKey: addrKey,
+					delta: AddrDelta{
+						Hash:    *tx.Hash(),
+						Height:  block.Height(),
+						TxIndex: uint32(txIdx),
+						Input:   true,
+						Index:   uint32(inIdx),
+						Amount:  -stxo.Amount,
+					},
+					height: stxo.Height,
+				})
+			}
+		}
+
+		for outIdx, txOut := range tx.MsgTx().TxOut {
+			addrKey, ok := idx.addrKeyForScript(txOut.PkScript)
+			if !ok {
+				continue
+			}
+			deltas = append(deltas, blockDelta{
+				addrKey: addrKey,
+				delta: AddrDelta{
+					Hash:    *tx.Hash(),
+					Height:  block.Height(),
+					TxIndex: uint32(txIdx),
+					Index:   uint32(outIdx),
+					Amount:  txOut.Value,
+				},
+			})
+		}
+	}
+	if stxoIdx != len(stxos) {
+		return nil, AssertError("spend journal of block " +
+			block.Hash().String() + " has too many entries")
+	}
+	return deltas, nil
+}
+
+// updateBalance adds the passed amounts to the balance of the address and
+// removes the balance once the address never received any funds.
+func updateBalance(bucket database.Bucket, addrKey [addrKeySize]byte,
+	balanceDelta, receivedDelta int64) error {
+
+	balance := &AddrBalance{}
+	if serialized := bucket.Get(addrKey[:]); serialized != nil {
+		var err error
+		balance, err = deserializeAddrBalance(serialized)
+		if err != nil {
+			return err
+		}
+	}
+	balance.Balance += balanceDelta
+	balance.Received += receivedDelta
+	if balance.Received == 0 {
+		return bucket.Delete(addrKey[:])
+	}
+	return bucket.Put(addrKey[:], serializeAddrBalance(balance))
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the deltas of the block,
+// updates the unspent outputs, and updates the balances of the addresses the
+// block involves.  This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) ConnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	deltas, err := idx.blockDeltas(block, stxos)
+	if err != nil {
+		return err
+	}
+
+	// The deltas are applied in the order they appear in the block so
+	// outputs are added before any input in the same block spends them.
+	buckets := idx.buckets(dbTx)
+	for i := range deltas {
+		bd := &deltas[i]
+		key := serializeAddrDeltaKey(bd.addrKey, &bd.delta)
+		err := buckets.deltas.Put(key, serializeAddrDeltaValue(&bd.delta))
+		if err != nil {
+			return err
+		}
+
+		var receivedDelta int64
+		if bd.delta.Input {
+			txIn := block.Transactions()[bd.delta.TxIndex].MsgTx().
+				TxIn[bd.delta.Index]
+			err = buckets.utxos.Delete(serializeAddrUtxoKey(bd.addrKey,
+				&txIn.PreviousOutPoint))
+		} else {
+			outPoint := wire.OutPoint{Hash: bd.delta.Hash,
+				Index: bd.delta.Index}
+			err = buckets.utxos.Put(serializeAddrUtxoKey(bd.addrKey,
+				&outPoint), serializeAddrUtxoValue(bd.delta.Amount,
+				bd.delta.Height))
+			receivedDelta = bd.delta.Amount
+		}
+		if err != nil {
+			return err
+		}
+
+		err = updateBalance(buckets.balances, bd.addrKey,
+			bd.delta.Amount, receivedDelta)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the deltas of the
+// block and reverts the unspent outputs and balances of the addresses the
+// block involves.  This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) DisconnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	deltas, err := idx.blockDeltas(block, stxos)
+	if err != nil {
+		return err
+	}
+
+	// The deltas are reverted in reverse order so outputs spent in the
+	// same block are restored before they are removed.
+	buckets := idx.buckets(dbTx)
+	for i := len(deltas) - 1; i >= 0; i-- {
+		bd := &deltas[i]
+		key := serializeAddrDeltaKey(bd.addrKey, &bd.delta)
+		if err := buckets.deltas.Delete(key); err != nil {
+			return err
+		}
+
+		var receivedDelta int64
+		if bd.delta.Input {
+			txIn := block.Transactions()[bd.delta.TxIndex].MsgTx().
+				TxIn[bd.delta.Index]
+			err = buckets.utxos.Put(serializeAddrUtxoKey(bd.addrKey,
+				&txIn.PreviousOutPoint), serializeAddrUtxoValue(
+				-bd.delta.Amount, bd.height))
+		} else {
+			outPoint := wire.OutPoint{Hash: bd.delta.Hash,
+				Index: bd.delta.Index}
+			err = buckets.utxos.Delete(serializeAddrUtxoKey(bd.addrKey,
+				&outPoint))
+			receivedDelta = -bd.delta.Amount
+		}
+		if err != nil {
+			return err
+		}
+
+		err = updateBalance(buckets.balances, bd.addrKey,
+			-bd.delta.Amount, receivedDelta)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachWithPrefix invokes the passed function with the keys and values of
+// the bucket which start with the passed prefix, in ascending order or in
+// descending order when reverse is set, after skipping the passed number of
+// them.  Iteration stops when the function returns false or an error.
+func forEachWithPrefix(bucket database.Bucket, prefix []byte, numToSkip uint32,
+	reverse bool, fn func(k, v []byte) (bool, error)) error {
+
+	cursor := bucket.Cursor()
+	var ok bool
+	if reverse {
+		// Position the cursor on the last key with the prefix, which
+		// is the one before the first key past all keys with it.
+		end := make([]byte, len(prefix)+1)
+		copy(end, prefix)
+		for i := len(prefix) - 1; i >= 0; i-- {
+			end[i]++
+			if end[i] != 0 {
+				end = end[:i+1]
+				break
+			}
+		}
+		if cursor.Seek(end) {
+			ok = cursor.Prev()
+		} else {
+			ok = cursor.Last()
+		}
+	} else {
+		ok = cursor.Seek(prefix)
+	}
+
+	for ; ok && bytes.HasPrefix(cursor.Key(), prefix); ok = advance(cursor,
+		reverse) {
+
+		if numToSkip > 0 {
+			numToSkip--
+			continue
+		}
+		more, err := fn(cursor.Key(), cursor.Value())
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance moves the passed cursor to the next key, or to the previous one when
+// reverse is set.
+func advance(cursor database.Cursor, reverse bool) bool {
+	if reverse {
+		return cursor.Prev()
+	}
+	return cursor.Next()
+}
+
+// Balance returns the balance of the passed address.  A zero balance is
+// returned for addresses which never received any funds.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) Balance(addr ulordutil.Address) (*AddrBalance, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &AddrBalance{}
+	err = idx.db.View(func(dbTx database.Tx) error {
+		serialized := idx.buckets(dbTx).balances.Get(addrKey[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		balance, err = deserializeAddrBalance(serialized)
+		return err
+	})
+	return balance, err
+}
+
+// Utxos returns the unspent outputs paying to the passed address ordered by
+// their outpoints after skipping the passed number of them.  At most
+// numRequested outputs are returned.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) Utxos(addr ulordutil.Address, numToSkip,
+	numRequested uint32) ([]AddrUtxo, error) {
+
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []AddrUtxo
+	err = idx.db.View(func(dbTx database.Tx) error {
+		bucket := idx.buckets(dbTx).utxos
+		return forEachWithPrefix(bucket, addrKey[:], numToSkip, false,
+			func(k, v []byte) (bool, error) {
+				if uint32(len(utxos)) >= numRequested {
+					return false, nil
+				}
+				utxo, err := deserializeAddrUtxo(k, v)
+				if err != nil {
+					return false, err
+				}
+				utxos = append(utxos, *utxo)
+				return true, nil
+			})
+	})
+	return utxos, err
+}
+
+// Deltas returns the outputs paying to the passed address and the inputs
+// spending them in order of their appearance in the block chain, or the reverse
+// order when reverse is set, after skipping the passed number of them.  At most
+// numRequested deltas are returned.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) Deltas(addr ulordutil.Address, numToSkip,
+	numRequested uint32, reverse bool) ([]AddrDelta, error) {
+
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltas []AddrDelta
+	err = idx.db.View(func(dbTx database.Tx) error {
+		bucket := idx.buckets(dbTx).deltas
+		return forEachWithPrefix(bucket, addrKey[:], numToSkip, reverse,
+			func(k, v []byte) (bool, error) {
+				if uint32(len(deltas)) >= numRequested {
+					return false, nil
+				}
+				delta, err := deserializeAddrDelta(k, v)
+				if err != nil {
+					return false, err
+				}
+				deltas = append(deltas, *delta)
+				return true, nil
+			})
+	})
+	return deltas, err
+}
+
+// NewAddrUtxoIndex returns a new instance of an indexer that is used to create
+// a mapping of the addresses in the block chain to the outputs paying to them,
+// the inputs spending those outputs, their unspent outputs, and their balances.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrUtxoIndex(db database.DB, chainParams *chaincfg.Params) *AddrUtxoIndex {
+	return &AddrUtxoIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropAddrUtxoIndex drops the address utxo index from the provided database if
+// it exists.
+func DropAddrUtxoIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, addrUtxoIndexKey, addrUtxoIndexName, interrupt)
+}
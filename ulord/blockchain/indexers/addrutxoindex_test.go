@@ -0,0 +1,218 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestAddrUtxoIndex ensures the address utxo index tracks the deltas, unspent
+// outputs, and balances of addresses as blocks are connected and disconnected
+// and its queries page through the results.
+func TestAddrUtxoIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "addrutxoindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewAddrUtxoIndex(db, params)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	newAddr := func(b byte) (ulordutil.Address, []byte) {
+		addr, err := ulordutil.NewAddressPubKeyHash(
+			[]byte{b, 19: 0}, params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		return addr, pkScript
+	}
+	addr1, script1 := newAddr(1)
+	addr2, script2 := newAddr(2)
+
+	// The first block pays twice to the first address.  The second block
+	// spends one of those outputs to pay the second address and change
+	// back to the first one, which is spent again in the same block.
+	coinbase := func(height byte, outs ...*wire.TxOut) *wire.MsgTx {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+			[]byte{height}, nil))
+		tx.TxOut = outs
+		return tx
+	}
+	cb1 := coinbase(1, wire.NewTxOut(5000, script1),
+		wire.NewTxOut(3000, script1), wire.NewTxOut(0, []byte{0x6a}))
+	block1 := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{cb1},
+	})
+	block1.SetHeight(1)
+
+	tx1 := wire.NewMsgTx(1)
+	tx1.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: cb1.TxHash()}, nil, nil))
+	tx1.AddTxOut(wire.NewTxOut(1000, script2))
+	tx1.AddTxOut(wire.NewTxOut(3500, script1))
+	tx2 := wire.NewMsgTx(1)
+	tx2.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: tx1.TxHash(), Index: 1},
+		nil, nil))
+	tx2.AddTxOut(wire.NewTxOut(3400, script2))
+	block2 := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase(2), tx1, tx2},
+	})
+	block2.SetHeight(2)
+	stxos2 := []blockchain.SpentTxOut{
+		{Amount: 5000, PkScript: script1, Height: 1, IsCoinBase: true},
+		{Amount: 3500, PkScript: script1, Height: 2},
+	}
+
+	connect := func(block *ulordutil.Block, stxos []blockchain.SpentTxOut) {
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, stxos)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: unexpected error: %v", err)
+		}
+	}
+	checkBalance := func(addr ulordutil.Address, want AddrBalance) {
+		balance, err := idx.Balance(addr)
+		if err != nil {
+			t.Fatalf("Balance: unexpected error: %v", err)
+		}
+		if *balance != want {
+			t.Fatalf("Balance(%v): got %+v, want %+v", addr, balance,
+				want)
+		}
+	}
+	checkUtxos := func(addr ulordutil.Address, want []AddrUtxo) {
+		utxos, err := idx.Utxos(addr, 0, 100)
+		if err != nil {
+			t.Fatalf("Utxos: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(utxos, want) {
+			t.Fatalf("Utxos(%v): got %+v, want %+v", addr, utxos, want)
+		}
+	}
+
+	connect(block1, nil)
+	utxos1 := []AddrUtxo{
+		{OutPoint: wire.OutPoint{Hash: cb1.TxHash()}, Amount: 5000,
+			Height: 1},
+		{OutPoint: wire.OutPoint{Hash: cb1.TxHash(), Index: 1},
+			Amount: 3000, Height: 1},
+	}
+	checkBalance(addr1, AddrBalance{Balance: 8000, Received: 8000})
+	checkUtxos(addr1, utxos1)
+
+	connect(block2, stxos2)
+	checkBalance(addr1, AddrBalance{Balance: 3000, Received: 11500})
+	checkBalance(addr2, AddrBalance{Balance: 4400, Received: 4400})
+	checkUtxos(addr1, utxos1[1:])
+
+	// The deltas of the first address are ordered by their appearance in
+	// the chain, with inputs after outputs of the same transaction.
+	tx1Hash, tx2Hash := tx1.TxHash(), tx2.TxHash()
+	wantDeltas := []AddrDelta{
+		{Hash: cb1.TxHash(), Height: 1, Amount: 5000},
+		{Hash: cb1.TxHash(), Height: 1, Index: 1, Amount: 3000},
+		{Hash: tx1Hash, Height: 2, TxIndex: 1, Index: 1, Amount: 3500},
+		{Hash: tx1Hash, Height: 2, TxIndex: 1, Input: true, Amount: -5000},
+		{Hash: tx2Hash, Height: 2, TxIndex: 2, Input: true, Amount: -3500},
+	}
+	deltas, err := idx.Deltas(addr1, 0, 100, false)
+	if err != nil {
+		t.Fatalf("Deltas: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(deltas, wantDeltas) {
+		t.Fatalf("Deltas: got %+v, want %+v", deltas, wantDeltas)
+	}
+
+	// Ensure the pages are taken from the requested end.
+	tests := []struct {
+		skip    uint32
+		count   uint32
+		reverse bool
+		want    []AddrDelta
+	}{
+		{skip: 1, count: 2, want: wantDeltas[1:3]},
+		{skip: 4, count: 2, want: wantDeltas[4:]},
+		{skip: 5, count: 2, want: nil},
+		{skip: 0, count: 2, reverse: true,
+			want: []AddrDelta{wantDeltas[4], wantDeltas[3]}},
+		{skip: 3, count: 5, reverse: true,
+			want: []AddrDelta{wantDeltas[1], wantDeltas[0]}},
+	}
+	for i, test := range tests {
+		deltas, err := idx.Deltas(addr1, test.skip, test.count,
+			test.reverse)
+		if err != nil {
+			t.Fatalf("Deltas #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(deltas, test.want) {
+			t.Fatalf("Deltas #%d: got %+v, want %+v", i, deltas,
+				test.want)
+		}
+	}
+	utxos, err := idx.Utxos(addr2, 1, 1)
+	if err != nil {
+		t.Fatalf("Utxos: unexpected error: %v", err)
+	}
+	if len(utxos) != 1 {
+		t.Fatalf("Utxos: got %d utxos, want 1", len(utxos))
+	}
+
+	// Disconnecting the second block must restore the state after the
+	// first one and leave nothing for the second address.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block2, stxos2)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	checkBalance(addr1, AddrBalance{Balance: 8000, Received: 8000})
+	checkBalance(addr2, AddrBalance{})
+	checkUtxos(addr1, utxos1)
+	checkUtxos(addr2, nil)
+	deltas, err = idx.Deltas(addr1, 0, 100, true)
+	if err != nil {
+		t.Fatalf("Deltas: unexpected error: %v", err)
+	}
+	want := []AddrDelta{wantDeltas[1], wantDeltas[0]}
+	if !reflect.DeepEqual(deltas, want) {
+		t.Fatalf("Deltas: got %+v, want %+v", deltas, want)
+	}
+
+	// The spend journal must have an entry for each input.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block2, stxos2[:1])
+	})
+	if err == nil {
+		t.Fatal("ConnectBlock: expected error for missing spend " +
+			"journal entries")
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// blockStatsIndexName is the human-readable name for the index.
+	blockStatsIndexName = "block stats index"
+
+	// numFeeRatePercentiles is the number of fee rate percentiles kept
+	// for each block.
+	numFeeRatePercentiles = 5
+)
+
+var (
+	// blockStatsIndexKey is the key of the block stats index and the db
+	// bucket used to house it.
+	blockStatsIndexKey = []byte("blockstatsidx")
+
+	// feeRatePercentiles are the percentiles of the fee rates, weighted
+	// by the weight of the transactions, kept for each block.
+	feeRatePercentiles = [numFeeRatePercentiles]int64{10, 25, 50, 75, 90}
+)
+
+// BlockStats houses the aggregate statistics of the transactions of a block.
+// The coinbase transaction is only included in the transaction, output, and
+// utxo counts.  Fees are in satoshi and fee rates in satoshi per virtual byte.
+type BlockStats struct {
+	Txs          int64
+	Ins          int64
+	Outs         int64
+	TotalSize    int64
+	TotalWeight  int64
+	MinTxSize    int64
+	MaxTxSize    int64
+	MedianTxSize int64
+
+	// SwTxs, SwTotalSize, and SwTotalWeight are the count, size, and
+	// weight of the transactions with witness data.
+	SwTxs         int64
+	SwTotalSize   int64
+	SwTotalWeight int64
+
+	TotalOut   int64
+	TotalFee   int64
+	MinFee     int64
+	MaxFee     int64
+	MedianFee  int64
+	MinFeeRate int64
+	MaxFeeRate int64
+
+	// FeeRatePercentiles are the fee rates at the 10th, 25th, 50th, 75th,
+	// and 90th percentile of the weight of the transactions.
+	FeeRatePercentiles [numFeeRatePercentiles]int64
+
+	// UtxoIncrease is the change in the number of unspent outputs and
+	// UtxoSizeInc the change in their serialized size.  Provably
+	// unspendable outputs never enter the utxo set and are not counted.
+	UtxoIncrease int64
+	UtxoSizeInc  int64
+}
+
+// fields returns the fields of the stats in the order they are serialized.
+func (s *BlockStats) fields() []*int64 {
+	fields := []*int64{&s.Txs, &s.Ins, &s.Outs, &s.TotalSize,
+		&s.TotalWeight, &s.MinTxSize, &s.MaxTxSize, &s.MedianTxSize,
+		&s.SwTxs, &s.SwTotalSize, &s.SwTotalWeight, &s.TotalOut,
+		&s.TotalFee, &s.MinFee, &s.MaxFee, &s.MedianFee, &s.MinFeeRate,
+		&s.MaxFeeRate}
+	for i := range s.FeeRatePercentiles {
+		fields = append(fields, &s.FeeRatePercentiles[i])
+	}
+	return append(fields, &s.UtxoIncrease, &s.UtxoSizeInc)
+}
+
+// -----------------------------------------------------------------------------
+// The block stats index maps the hash of each block in the main chain to the
+// statistics of its transactions.
+//
+// The serialized format for keys and values in the index bucket is:
+//
+//   <block hash> = <stats>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   stats           [25]int64         200 bytes
+//   -----
+//   Total: 232 bytes
+//
+// The stats are the fields of BlockStats in declaration order, each encoded as
+// a little-endian int64.
+// -----------------------------------------------------------------------------
+
+// serializeBlockStats returns the serialized stats for storage in the index.
+func serializeBlockStats(stats *BlockStats) []byte {
+	fields := stats.fields()
+	serialized := make([]byte, 8*len(fields))
+	for i, field := range fields {
+		byteOrder.PutUint64(serialized[8*i:], uint64(*field))
+	}
+	return serialized
+}
+
+// deserializeBlockStats decodes stats serialized with serializeBlockStats.
+func deserializeBlockStats(serialized []byte) (*BlockStats, error) {
+	var stats BlockStats
+	fields := stats.fields()
+	if len(serialized) != 8*len(fields) {
+		return nil, errDeserialize(fmt.Sprintf("unexpected length %d "+
+			"for serialized block stats", len(serialized)))
+	}
+	for i, field := range fields {
+		*field = int64(byteOrder.Uint64(serialized[8*i:]))
+	}
+	return &stats, nil
+}
+
+// median returns the median of the sorted values, which is the mean of the
+// middle two values when there is an even number of them.
+func median(sorted []int64) int64 {
+	n := len(sorted)
+	switch {
+	case n == 0:
+		return 0
+	case n%2 == 0:
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	default:
+		return sorted[n/2]
+	}
+}
+
+// CalcBlockStats returns the statistics of the transactions of a block given
+// the outputs it spends in the order of the spend journal.
+func CalcBlockStats(block *ulordutil.Block, stxos []blockchain.SpentTxOut) (*BlockStats, error) {
+	type feeRate struct {
+		rate   int64
+		weight int64
+	}
+
+	txns := block.Transactions()
+	stats := &BlockStats{Txs: int64(len(txns))}
+	fees := make([]int64, 0, len(txns))
+	sizes := make([]int64, 0, len(txns))
+	feeRates := make([]feeRate, 0, len(txns))
+	var stxoIdx int
+	for i, tx := range txns {
+		msgTx := tx.MsgTx()
+		stats.Outs += int64(len(msgTx.TxOut))
+		for _, txOut := range msgTx.TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			stats.UtxoIncrease++
+			stats.UtxoSizeInc += int64(txOut.SerializeSize())
+		}
+		if i == 0 {
+			continue
+		}
+
+		// Sum the spent outputs to determine the fee.
+		var totalIn int64
+		for range msgTx.TxIn {
+			if stxoIdx >= len(stxos) {
+				return nil, AssertError("spend journal of block " +
+					block.Hash().String() + " is missing entries")
+			}
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+			totalIn += stxo.Amount
+			stats.UtxoIncrease--
+			stats.UtxoSizeInc -= int64(8 +
+				wire.VarIntSerializeSize(uint64(len(stxo.PkScript))) +
+				len(stxo.PkScript))
+		}
+		stats.Ins += int64(len(msgTx.TxIn))
+
+		var totalOut int64
+		for _, txOut := range msgTx.TxOut {
+			totalOut += txOut.Value
+		}
+		stats.TotalOut += totalOut
+
+		size := int64(msgTx.SerializeSize())
+		weight := blockchain.GetTransactionWeight(tx)
+		stats.TotalSize += size
+		stats.TotalWeight += weight
+		if msgTx.HasWitness() {
+			stats.SwTxs++
+			stats.SwTotalSize += size
+			stats.SwTotalWeight += weight
+		}
+
+		fee := totalIn - totalOut
+		vsize := (weight + blockchain.WitnessScaleFactor - 1) /
+			blockchain.WitnessScaleFactor
+		stats.TotalFee += fee
+		fees = append(fees, fee)
+		sizes = append(sizes, size)
+		feeRates = append(feeRates, feeRate{rate: fee / vsize, weight: weight})
+	}
+	if stxoIdx != len(stxos) {
+		return nil, AssertError("spend journal of block " +
+			block.Hash().String() + " has too many entries")
+	}
+	if len(fees) == 0 {
+		return stats, nil
+	}
+
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	sort.Slice(feeRates, func(i, j int) bool {
+		return feeRates[i].rate < feeRates[j].rate
+	})
+	stats.MinFee, stats.MaxFee = fees[0], fees[len(fees)-1]
+	stats.MedianFee = median(fees)
+	stats.MinTxSize, stats.MaxTxSize = sizes[0], sizes[len(sizes)-1]
+	stats.MedianTxSize = median(sizes)
+	stats.MinFeeRate = feeRates[0].rate
+	stats.MaxFeeRate = feeRates[len(feeRates)-1].rate
+
+	// Each percentile is the fee rate of the transaction which brings the
+	// cumulative weight of the transactions sorted by fee rate to that
+	// percentile of the total weight.
+	var cumulativeWeight int64
+	var next int
+	for _, fr := range feeRates {
+		cumulativeWeight += fr.weight
+		for next < numFeeRatePercentiles && cumulativeWeight*100 >=
+			stats.TotalWeight*feeRatePercentiles[next] {
+
+			stats.FeeRatePercentiles[next] = fr.rate
+			next++
+		}
+	}
+
+	return stats, nil
+}
+
+// BlockStatsIndex implements an index of the statistics of the transactions of
+// each block in the main chain, which are computed when the block is connected.
+type BlockStatsIndex struct {
+	db database.DB
+}
+
+// Ensure the BlockStatsIndex type implements the Indexer interface.
+var _ Indexer = (*BlockStatsIndex)(nil)
+
+// Ensure the BlockStatsIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*BlockStatsIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *BlockStatsIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the block stats index.  This is part of the Indexer
+// interface.
+func (idx *BlockStatsIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.  This is
+// part of the Indexer interface.
+func (idx *BlockStatsIndex) Key() []byte {
+	return blockStatsIndexKey
+}
+
+// Name returns the human-readable name of the index.  This is part of the
+// Indexer interface.
+func (idx *BlockStatsIndex) Name() string {
+	return blockStatsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the index.  This is
+// part of the Indexer interface.
+func (idx *BlockStatsIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(blockStatsIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer stores the statistics of the
+// block.  This is part of the Indexer interface.
+func (idx *BlockStatsIndex) ConnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	stats, err := CalcBlockStats(block, stxos)
+	if err != nil {
+		return err
+	}
+	bucket := dbTx.Metadata().Bucket(blockStatsIndexKey)
+	return bucket.Put(block.Hash()[:], serializeBlockStats(stats))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the statistics of
+// the block.  This is part of the Indexer interface.
+func (idx *BlockStatsIndex) DisconnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	_ []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(blockStatsIndexKey)
+	return bucket.Delete(block.Hash()[:])
+}
+
+// BlockStats returns the statistics of the main chain block with the given
+// hash.  When there is no entry for the block, nil is returned for both the
+// stats and the error.
+//
+// This function is safe for concurrent access.
+func (idx *BlockStatsIndex) BlockStats(hash *chainhash.Hash) (*BlockStats, error) {
+	var stats *BlockStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Bucket(blockStatsIndexKey).Get(hash[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		stats, err = deserializeBlockStats(serialized)
+		return err
+	})
+	return stats, err
+}
+
+// NewBlockStatsIndex returns a new instance of an indexer that is used to
+// create a mapping of the hashes of all blocks in the main chain to the
+// statistics of their transactions.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewBlockStatsIndex(db database.DB) *BlockStatsIndex {
+	return &BlockStatsIndex{db: db}
+}
+
+// DropBlockStatsIndex drops the block stats index from the provided database
+// if it exists.
+func DropBlockStatsIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, blockStatsIndexKey, blockStatsIndexName, interrupt)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestCalcBlockStats ensures the statistics of a block are computed from its
+// transactions and the outputs they spend and survive serialization.
+func TestCalcBlockStats(t *testing.T) {
+	trueScript := []byte{0x51}
+	nullDataScript := []byte{0x6a}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		[]byte{0x01, 0x02}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000, trueScript))
+	coinbase.AddTxOut(wire.NewTxOut(0, nullDataScript))
+
+	spend := func(index uint32, amount int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: index}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(amount, trueScript))
+		return tx
+	}
+	tx1 := spend(0, 900)
+	tx2 := spend(1, 800)
+	block := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, tx1, tx2},
+	})
+	stxos := []blockchain.SpentTxOut{
+		{Amount: 1000, PkScript: trueScript},
+		{Amount: 1000, PkScript: trueScript},
+	}
+
+	size := int64(tx1.SerializeSize())
+	outSize := int64(wire.NewTxOut(0, trueScript).SerializeSize())
+	want := &BlockStats{
+		Txs:                3,
+		Ins:                2,
+		Outs:               4,
+		TotalSize:          2 * size,
+		TotalWeight:        2 * size * blockchain.WitnessScaleFactor,
+		MinTxSize:          size,
+		MaxTxSize:          size,
+		MedianTxSize:       size,
+		TotalOut:           1700,
+		TotalFee:           300,
+		MinFee:             100,
+		MaxFee:             200,
+		MedianFee:          150,
+		MinFeeRate:         100 / size,
+		MaxFeeRate:         200 / size,
+		FeeRatePercentiles: [5]int64{100 / size, 100 / size, 100 / size, 200 / size, 200 / size},
+		UtxoIncrease:       1,
+		UtxoSizeInc:        outSize,
+	}
+
+	stats, err := CalcBlockStats(block, stxos)
+	if err != nil {
+		t.Fatalf("CalcBlockStats: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Fatalf("CalcBlockStats: got %+v, want %+v", stats, want)
+	}
+
+	deserialized, err := deserializeBlockStats(serializeBlockStats(stats))
+	if err != nil {
+		t.Fatalf("deserializeBlockStats: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(deserialized, want) {
+		t.Fatalf("deserializeBlockStats: got %+v, want %+v",
+			deserialized, want)
+	}
+	if _, err := deserializeBlockStats(make([]byte, 10)); !isDeserializeErr(err) {
+		t.Fatalf("deserializeBlockStats: got error %v for truncated "+
+			"stats, want deserialize error", err)
+	}
+
+	// The spend journal must have an entry for each input.
+	if _, err := CalcBlockStats(block, stxos[:1]); err == nil {
+		t.Fatal("CalcBlockStats: expected error for missing spend " +
+			"journal entries")
+	}
+	if _, err := CalcBlockStats(block, append(stxos, stxos[0])); err == nil {
+		t.Fatal("CalcBlockStats: expected error for extra spend " +
+			"journal entries")
+	}
+}
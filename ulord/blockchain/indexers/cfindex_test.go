@@ -0,0 +1,123 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	_ "github.com/ulordsuite/ulord/database/ffldb"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/gcs/builder"
+)
+
+// TestCfIndexConnectDisconnect ensures the committed filter index stores the
+// basic filter of connected blocks along with a filter header which commits to
+// the filter header of the previous block, and removes them again when the
+// blocks are disconnected.
+func TestCfIndexConnectDisconnect(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "cfindextest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewCfIndex(db, params)
+	if err := db.Update(idx.Create); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Create a child of the genesis block which spends a previous output
+	// so the filter includes the spent script.
+	genesis := ulordutil.NewBlock(params.GenesisBlock)
+	childMsg := *params.GenesisBlock
+	childMsg.Header.PrevBlock = *genesis.Hash()
+	childMsg.Header.Nonce++
+	child := ulordutil.NewBlock(&childMsg)
+	prevScript := []byte{0x51}
+	stxos := []blockchain.SpentTxOut{{PkScript: prevScript}}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.ConnectBlock(dbTx, genesis, nil); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, child, stxos)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+
+	// Ensure the stored filters and headers match the ones built from the
+	// blocks and that the header of the child commits to the genesis one.
+	prevHeader := zeroHash
+	for _, test := range []struct {
+		block       *ulordutil.Block
+		prevScripts [][]byte
+	}{
+		{genesis, nil},
+		{child, [][]byte{prevScript}},
+	} {
+		hash := test.block.Hash()
+		f, err := builder.BuildBasicFilter(test.block.MsgBlock(),
+			test.prevScripts)
+		if err != nil {
+			t.Fatalf("BuildBasicFilter: %v", err)
+		}
+		wantFilter, _ := f.NBytes()
+		wantHash, _ := builder.GetFilterHash(f)
+		wantHeader, _ := builder.MakeHeaderForFilter(f, prevHeader)
+
+		gotFilter, err := idx.FilterByBlockHash(hash, wire.GCSFilterRegular)
+		if err != nil || !bytes.Equal(gotFilter, wantFilter) {
+			t.Fatalf("filter of %v: got %x (%v), want %x", hash,
+				gotFilter, err, wantFilter)
+		}
+		gotHash, err := idx.FilterHashByBlockHash(hash, wire.GCSFilterRegular)
+		if err != nil || !bytes.Equal(gotHash, wantHash[:]) {
+			t.Fatalf("filter hash of %v: got %x (%v), want %v", hash,
+				gotHash, err, wantHash)
+		}
+		gotHeader, err := idx.FilterHeaderByBlockHash(hash,
+			wire.GCSFilterRegular)
+		if err != nil || !bytes.Equal(gotHeader, wantHeader[:]) {
+			t.Fatalf("filter header of %v: got %x (%v), want %v",
+				hash, gotHeader, err, wantHeader)
+		}
+		prevHeader = wantHeader
+	}
+
+	// Unknown filter types must be rejected.
+	_, err = idx.FilterByBlockHash(genesis.Hash(), wire.FilterType(1))
+	if err == nil {
+		t.Fatal("FilterByBlockHash: unknown filter type accepted")
+	}
+
+	// Disconnecting the child must remove its entries only.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, child, stxos)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	filters, err := idx.FiltersByBlockHashes([]*chainhash.Hash{
+		genesis.Hash(), child.Hash()}, wire.GCSFilterRegular)
+	if err != nil {
+		t.Fatalf("FiltersByBlockHashes: %v", err)
+	}
+	if len(filters[0]) == 0 || filters[1] != nil {
+		t.Fatalf("unexpected filters after disconnect: %x", filters)
+	}
+}
@@ -7,6 +7,7 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"sync"
 
 	"github.com/ulordsuite/ulord/blockchain"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
@@ -132,6 +133,13 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *ulordutil.
 type Manager struct {
 	db             database.DB
 	enabledIndexes []Indexer
+	chain          *blockchain.BlockChain
+
+	// reindexing houses the indexes which are being reindexed.  They are
+	// behind the main chain and are not updated with the blocks connected
+	// to and disconnected from it until they caught up.
+	mtx        sync.Mutex
+	reindexing map[Indexer]*reindexState
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -238,6 +246,7 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	if len(m.enabledIndexes) == 0 {
 		return nil
 	}
+	m.chain = chain
 
 	if interruptRequested(interrupt) {
 		return errInterruptRequested
@@ -505,6 +514,9 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *ulordutil.Block,
 	// Call each of the currently active optional indexes with the block
 	// being connected so they can update accordingly.
 	for _, index := range m.enabledIndexes {
+		if m.isReindexing(index) {
+			continue
+		}
 		err := dbIndexConnectBlock(dbTx, index, block, stxos)
 		if err != nil {
 			return err
@@ -525,6 +537,9 @@ func (m *Manager) DisconnectBlock(dbTx database.Tx, block *ulordutil.Block,
 	// Call each of the currently active optional indexes with the block
 	// being disconnected so they can update accordingly.
 	for _, index := range m.enabledIndexes {
+		if m.isReindexing(index) {
+			continue
+		}
 		err := dbIndexDisconnectBlock(dbTx, index, block, stxo)
 		if err != nil {
 			return err
@@ -541,6 +556,7 @@ func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		reindexing:     make(map[Indexer]*reindexState),
 	}
 }
 
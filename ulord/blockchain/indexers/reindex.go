@@ -0,0 +1,259 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// reindexState tracks the progress of an index which is being reindexed.
+type reindexState struct {
+	// startHeight is the height of the first block which is processed
+	// again by the index.
+	startHeight int32
+
+	// rewound indicates the blocks starting at startHeight have been
+	// disconnected from the index.
+	rewound bool
+}
+
+// Indexes returns the enabled indexes in the order they are updated.
+func (m *Manager) Indexes() []Indexer {
+	indexes := make([]Indexer, len(m.enabledIndexes))
+	copy(indexes, m.enabledIndexes)
+	return indexes
+}
+
+// isReindexing returns whether the passed index is being reindexed.
+func (m *Manager) isReindexing(indexer Indexer) bool {
+	m.mtx.Lock()
+	_, ok := m.reindexing[indexer]
+	m.mtx.Unlock()
+	return ok
+}
+
+// Reindexing returns whether any of the indexes is being reindexed.
+func (m *Manager) Reindexing() bool {
+	m.mtx.Lock()
+	reindexing := len(m.reindexing) != 0
+	m.mtx.Unlock()
+	return reindexing
+}
+
+// Reindex schedules the passed indexes to process the blocks of the main chain
+// starting at the passed height again.  The indexes are no longer updated with
+// the blocks connected to and disconnected from the main chain until they are
+// caught up by ReindexStep.  Indexes which are already being reindexed process
+// the blocks starting at the lower of both heights.
+func (m *Manager) Reindex(indexes []Indexer, startHeight int32) error {
+	if m.chain == nil {
+		return errors.New("the index manager is not initialized")
+	}
+	best := m.chain.BestSnapshot()
+	if startHeight < 0 || startHeight > best.Height {
+		return fmt.Errorf("start height %d is out of range [0, %d]",
+			startHeight, best.Height)
+	}
+	for _, indexer := range indexes {
+		var enabled bool
+		for _, enabledIndexer := range m.enabledIndexes {
+			if indexer == enabledIndexer {
+				enabled = true
+				break
+			}
+		}
+		if !enabled {
+			return fmt.Errorf("the %s is not enabled", indexer.Name())
+		}
+	}
+
+	m.mtx.Lock()
+	for _, indexer := range indexes {
+		state, ok := m.reindexing[indexer]
+		if !ok {
+			state = &reindexState{startHeight: startHeight}
+			m.reindexing[indexer] = state
+		}
+		if startHeight < state.startHeight {
+			state.startHeight = startHeight
+		}
+		state.rewound = false
+	}
+	m.mtx.Unlock()
+	return nil
+}
+
+// indexTip returns the hash and height of the tip of the passed index.
+func (m *Manager) indexTip(indexer Indexer) (*chainhash.Hash, int32, error) {
+	var hash *chainhash.Hash
+	var height int32
+	err := m.db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, height, err = dbFetchIndexerTip(dbTx, indexer.Key())
+		return err
+	})
+	return hash, height, err
+}
+
+// disconnectIndexTip disconnects the block at the tip of the passed index from
+// it.  The block is loaded from the database directly since it might not be
+// part of the main chain.
+func (m *Manager) disconnectIndexTip(indexer Indexer, hash *chainhash.Hash, height int32) error {
+	var block *ulordutil.Block
+	err := m.db.View(func(dbTx database.Tx) error {
+		blockBytes, err := dbTx.FetchBlock(hash)
+		if err != nil {
+			return err
+		}
+		block, err = ulordutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return err
+		}
+		block.SetHeight(height)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	spentTxos, err := m.chain.FetchSpendJournal(block)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(dbTx database.Tx) error {
+		return dbIndexDisconnectBlock(dbTx, indexer, block, spentTxos)
+	})
+}
+
+// ReindexStep performs up to the passed number of block connections and
+// disconnections for the indexes which are being reindexed.  The blocks
+// starting at the height passed to Reindex are disconnected from each index
+// first, along with the blocks of orphaned forks, before the index catches up
+// with the main chain again.  It returns the lowest height of the tips of the
+// indexes which are being reindexed and whether all of them caught up.
+//
+// This function MUST NOT be called concurrently with the processing of blocks
+// by the chain.
+func (m *Manager) ReindexStep(maxBlocks int32) (int32, bool, error) {
+	// Gather the indexes which are being reindexed in the order they are
+	// updated since later indexes can depend on earlier ones.
+	var indexes []Indexer
+	var states []reindexState
+	m.mtx.Lock()
+	for _, indexer := range m.enabledIndexes {
+		if state, ok := m.reindexing[indexer]; ok {
+			indexes = append(indexes, indexer)
+			states = append(states, *state)
+		}
+	}
+	m.mtx.Unlock()
+	best := m.chain.BestSnapshot()
+	if len(indexes) == 0 {
+		return best.Height, true, nil
+	}
+
+	// Disconnect the blocks which are processed again, as well as blocks
+	// of orphaned forks, in reverse order.
+	var processed int32
+	tipHeights := make([]int32, len(indexes))
+	for i := len(indexes) - 1; i >= 0; i-- {
+		indexer, state := indexes[i], states[i]
+		for {
+			hash, height, err := m.indexTip(indexer)
+			if err != nil {
+				return 0, false, err
+			}
+			tipHeights[i] = height
+			if height == -1 || (m.chain.MainChainHasBlock(hash) &&
+				(state.rewound || height < state.startHeight)) {
+
+				break
+			}
+			if processed == maxBlocks {
+				return tipHeights[i], false, nil
+			}
+
+			err = m.disconnectIndexTip(indexer, hash, height)
+			if err != nil {
+				return 0, false, err
+			}
+			processed++
+		}
+
+		if !state.rewound {
+			log.Infof("Reindexing %s from height %d", indexer.Name(),
+				state.startHeight)
+			m.mtx.Lock()
+			m.reindexing[indexer].rewound = true
+			m.mtx.Unlock()
+		}
+	}
+
+	// Connect the blocks of the main chain to the indexes which are behind
+	// until all of them caught up.
+	lowestHeight := func() int32 {
+		lowest := best.Height
+		for _, height := range tipHeights {
+			if height < lowest {
+				lowest = height
+			}
+		}
+		return lowest
+	}
+	for ; processed < maxBlocks; processed++ {
+		height := lowestHeight() + 1
+		if height > best.Height {
+			break
+		}
+		block, err := m.chain.BlockByHeight(height)
+		if err != nil {
+			return 0, false, err
+		}
+
+		var spentTxos []blockchain.SpentTxOut
+		for i, indexer := range indexes {
+			if tipHeights[i] >= height {
+				continue
+			}
+			if spentTxos == nil && indexNeedsInputs(indexer) {
+				spentTxos, err = m.chain.FetchSpendJournal(block)
+				if err != nil {
+					return 0, false, err
+				}
+			}
+
+			err := m.db.Update(func(dbTx database.Tx) error {
+				return dbIndexConnectBlock(dbTx, indexer, block,
+					spentTxos)
+			})
+			if err != nil {
+				return 0, false, err
+			}
+			tipHeights[i] = height
+		}
+	}
+
+	// Resume updating the indexes which caught up with the main chain.
+	caughtUp := true
+	m.mtx.Lock()
+	for i, indexer := range indexes {
+		if tipHeights[i] != best.Height {
+			caughtUp = false
+			continue
+		}
+		log.Infof("Reindexed %s up to height %d", indexer.Name(),
+			best.Height)
+		delete(m.reindexing, indexer)
+	}
+	m.mtx.Unlock()
+	return lowestHeight(), caughtUp, nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"compress/bzip2"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/database"
+	_ "github.com/ulordsuite/ulord/database/ffldb"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// loadTestBlocks reads the blocks of the passed file of the blockchain test
+// data, which includes the genesis block.
+func loadTestBlocks(t *testing.T, filename string) []*ulordutil.Block {
+	f, err := os.Open(filepath.Join("..", "testdata", filename))
+	if err != nil {
+		t.Fatalf("unable to open %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	var blocks []*ulordutil.Block
+	r := bzip2.NewReader(f)
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return blocks
+		} else if err != nil {
+			t.Fatalf("unable to read %s: %v", filename, err)
+		}
+		if binary.LittleEndian.Uint32(header[:4]) != uint32(wire.MainNet) {
+			t.Fatalf("unexpected network in %s", filename)
+		}
+		blockBytes := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(r, blockBytes); err != nil {
+			t.Fatalf("unable to read %s: %v", filename, err)
+		}
+		block, err := ulordutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			t.Fatalf("unable to decode block of %s: %v", filename, err)
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// TestReindex ensures indexes which are reindexed process the blocks after the
+// start height again and resume being updated once they caught up.
+func TestReindex(t *testing.T) {
+	blocks := loadTestBlocks(t, "blk_0_to_4.dat.bz2")
+
+	dbPath, err := ioutil.TempDir("", "reindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	txIndex := NewTxIndex(db)
+	m := NewManager(db, []Indexer{txIndex})
+
+	// The test blocks spend coinbase outputs after a single block.
+	params := chaincfg.MainNetParams
+	params.CoinbaseMaturity = 1
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  &params,
+		TimeSource:   blockchain.NewMedianTime(),
+		SigCache:     txscript.NewSigCache(1000),
+		IndexManager: m,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	processBlock := func(block *ulordutil.Block) {
+		_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+	}
+	for _, block := range blocks[1:4] {
+		processBlock(block)
+	}
+
+	if err := m.Reindex([]Indexer{txIndex}, 4); err == nil {
+		t.Fatal("Reindex: expected error for height after the tip")
+	}
+	if err := m.Reindex([]Indexer{txIndex}, 2); err != nil {
+		t.Fatalf("Reindex: unexpected error: %v", err)
+	}
+	if !m.Reindexing() {
+		t.Fatal("Reindexing: index is not being reindexed")
+	}
+
+	// Blocks connected during the reindex are not indexed until the index
+	// catches up.
+	processBlock(blocks[4])
+	_, height, err := m.indexTip(txIndex)
+	if err != nil {
+		t.Fatalf("indexTip: unexpected error: %v", err)
+	}
+	if height != 3 {
+		t.Fatalf("indexTip: got height %d, want 3", height)
+	}
+
+	// The blocks starting at height 2 are disconnected before the index
+	// catches up again one block at a time.
+	wantHeights := []int32{2, 1, 2, 3, 4}
+	for i, wantHeight := range wantHeights {
+		height, done, err := m.ReindexStep(1)
+		if err != nil {
+			t.Fatalf("ReindexStep #%d: unexpected error: %v", i, err)
+		}
+		if height != wantHeight || done != (i == len(wantHeights)-1) {
+			t.Fatalf("ReindexStep #%d: got height %d and done %v, "+
+				"want height %d", i, height, done, wantHeight)
+		}
+	}
+	if m.Reindexing() {
+		t.Fatal("Reindexing: index is still being reindexed")
+	}
+
+	// All transactions must be indexed.
+	for _, block := range blocks[1:] {
+		for _, tx := range block.Transactions() {
+			region, err := txIndex.TxBlockRegion(tx.Hash())
+			if err != nil || region == nil {
+				t.Fatalf("TxBlockRegion: transaction %v is not "+
+					"indexed (%v)", tx.Hash(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent index"
+
+	// spentKeySize is the number of bytes a key in the spent index
+	// consumes.  It consists of the hash of the transaction of the spent
+	// output + 4 bytes output index.
+	spentKeySize = chainhash.HashSize + 4
+
+	// spentValueSize is the number of bytes a value in the spent index
+	// consumes.  It consists of the hash of the spending transaction + 4
+	// bytes input index + 4 bytes block height.
+	spentValueSize = chainhash.HashSize + 4 + 4
+)
+
+var (
+	// spentIndexKey is the key of the spent index and the db bucket used
+	// to house it.
+	spentIndexKey = []byte("spentidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent index maps every output spent in the main chain to the input which
+// spends it:
+//
+//   <tx hash><output index> = <spending tx hash><input index><height>
+//
+//   Field              Type              Size
+//   tx hash            chainhash.Hash    32 bytes
+//   output index       uint32            4 bytes
+//   spending tx hash   chainhash.Hash    32 bytes
+//   input index        uint32            4 bytes
+//   height             uint32            4 bytes
+//
+// The output index of the key is big endian so the outputs of a transaction
+// are ordered by their index.  The other integers are little endian.
+// -----------------------------------------------------------------------------
+
+// SpentInfo describes the input which spends an output.
+type SpentInfo struct {
+	// Hash is the hash of the spending transaction and Index the position
+	// of the input in it.
+	Hash  chainhash.Hash
+	Index uint32
+
+	// Height is the height of the block which contains the spending
+	// transaction.
+	Height int32
+}
+
+// serializeSpentKey returns the key of the passed outpoint in the spent index.
+func serializeSpentKey(outPoint *wire.OutPoint) []byte {
+	key := make([]byte, spentKeySize)
+	copy(key, outPoint.Hash[:])
+	binary.BigEndian.PutUint32(key[chainhash.HashSize:], outPoint.Index)
+	return key
+}
+
+// serializeSpentInfo returns the serialized spent info for storage in the
+// index.
+func serializeSpentInfo(info *SpentInfo) []byte {
+	value := make([]byte, spentValueSize)
+	copy(value, info.Hash[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint32(value[offset:], info.Index)
+	offset += 4
+	byteOrder.PutUint32(value[offset:], uint32(info.Height))
+	return value
+}
+
+// deserializeSpentInfo decodes spent info serialized with serializeSpentInfo.
+func deserializeSpentInfo(value []byte) (*SpentInfo, error) {
+	if len(value) != spentValueSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected length %d "+
+			"for serialized spent info", len(value)))
+	}
+
+	var info SpentInfo
+	copy(info.Hash[:], value)
+	offset := chainhash.HashSize
+	info.Index = byteOrder.Uint32(value[offset:])
+	offset += 4
+	info.Height = int32(byteOrder.Uint32(value[offset:]))
+	return &info, nil
+}
+
+// SpentIndex implements an index of the outputs spent in the main chain to the
+// inputs which spend them.
+type SpentIndex struct {
+	db database.DB
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Init initializes the spent index.  This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.  This is
+// part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.  This is part of the
+// Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the index.  This is
+// part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer maps the outputs spent by the
+// transactions of the block to their inputs.  This is part of the Indexer
+// interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	_ []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for inIdx, txIn := range tx.MsgTx().TxIn {
+			info := SpentInfo{
+				Hash:   *tx.Hash(),
+				Index:  uint32(inIdx),
+				Height: block.Height(),
+			}
+			err := bucket.Put(serializeSpentKey(&txIn.PreviousOutPoint),
+				serializeSpentInfo(&info))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries of the
+// outputs spent by the transactions of the block.  This is part of the Indexer
+// interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	_ []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			key := serializeSpentKey(&txIn.PreviousOutPoint)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SpentInfo returns the input which spends the passed output in the main chain.
+// When the output is unspent or unknown, nil is returned for both the info and
+// the error.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpentInfo(outPoint *wire.OutPoint) (*SpentInfo, error) {
+	var info *SpentInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(spentIndexKey)
+		serialized := bucket.Get(serializeSpentKey(outPoint))
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		info, err = deserializeSpentInfo(serialized)
+		return err
+	})
+	return info, err
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to create a
+// mapping of the outputs spent in the main chain to the inputs which spend
+// them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{db: db}
+}
+
+// DropSpentIndex drops the spent index from the provided database if it exists.
+func DropSpentIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, spentIndexKey, spentIndexName, interrupt)
+}
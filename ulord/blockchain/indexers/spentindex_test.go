@@ -0,0 +1,91 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestSpentIndex ensures the spent index maps the outputs spent by a connected
+// block to their inputs and forgets them once the block is disconnected.
+func TestSpentIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "spentindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewSpentIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		[]byte{0x01}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{0x51}))
+	spent := []wire.OutPoint{{Hash: coinbase.TxHash()}, {Index: 3}}
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(&spent[0], nil, nil))
+	tx.AddTxIn(wire.NewTxIn(&spent[1], nil, nil))
+	tx.AddTxOut(wire.NewTxOut(4000, []byte{0x51}))
+	block := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, tx},
+	})
+	block.SetHeight(7)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+	for i := range spent {
+		info, err := idx.SpentInfo(&spent[i])
+		if err != nil {
+			t.Fatalf("SpentInfo: unexpected error: %v", err)
+		}
+		want := SpentInfo{Hash: tx.TxHash(), Index: uint32(i), Height: 7}
+		if info == nil || *info != want {
+			t.Fatalf("SpentInfo(%v): got %+v, want %+v", spent[i],
+				info, want)
+		}
+	}
+	unspent := wire.OutPoint{Hash: tx.TxHash()}
+	if info, err := idx.SpentInfo(&unspent); err != nil || info != nil {
+		t.Fatalf("SpentInfo(%v): got %+v, %v, want nil", unspent, info,
+			err)
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	if info, err := idx.SpentInfo(&spent[0]); err != nil || info != nil {
+		t.Fatalf("SpentInfo(%v): got %+v, %v after disconnect, want "+
+			"nil", spent[0], info, err)
+	}
+
+	if _, err := deserializeSpentInfo(make([]byte, 10)); !isDeserializeErr(err) {
+		t.Fatalf("deserializeSpentInfo: got error %v for truncated "+
+			"info, want deserialize error", err)
+	}
+}
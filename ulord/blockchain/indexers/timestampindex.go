@@ -0,0 +1,163 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// timestampIndexName is the human-readable name for the index.
+	timestampIndexName = "timestamp index"
+
+	// timestampKeySize is the number of bytes a key in the timestamp index
+	// consumes.  It consists of 4 bytes timestamp + 4 bytes block height.
+	timestampKeySize = 4 + 4
+)
+
+var (
+	// timestampIndexKey is the key of the timestamp index and the db
+	// bucket used to house it.
+	timestampIndexKey = []byte("timestampidx")
+)
+
+// -----------------------------------------------------------------------------
+// The timestamp index maps the timestamps of the blocks in the main chain to
+// their hashes:
+//
+//   <timestamp><height> = <block hash>
+//
+//   Field           Type              Size
+//   timestamp       uint32            4 bytes (big endian)
+//   height          uint32            4 bytes (big endian)
+//   block hash      chainhash.Hash    32 bytes
+//
+// The timestamps of blocks are not required to increase with their height, so
+// the height is part of the key to keep the keys of blocks with the same
+// timestamp unique.  Both integers are big endian so the keys are ordered by
+// timestamp and then by height.
+// -----------------------------------------------------------------------------
+
+// serializeTimestampKey returns the key of the block with the passed timestamp
+// and height in the timestamp index.
+func serializeTimestampKey(timestamp uint32, height int32) []byte {
+	key := make([]byte, timestampKeySize)
+	binary.BigEndian.PutUint32(key, timestamp)
+	binary.BigEndian.PutUint32(key[4:], uint32(height))
+	return key
+}
+
+// TimestampIndex implements an index of the timestamps of the blocks in the
+// main chain to their hashes.
+type TimestampIndex struct {
+	db database.DB
+}
+
+// Ensure the TimestampIndex type implements the Indexer interface.
+var _ Indexer = (*TimestampIndex)(nil)
+
+// Init initializes the timestamp index.  This is part of the Indexer
+// interface.
+func (idx *TimestampIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.  This is
+// part of the Indexer interface.
+func (idx *TimestampIndex) Key() []byte {
+	return timestampIndexKey
+}
+
+// Name returns the human-readable name of the index.  This is part of the
+// Indexer interface.
+func (idx *TimestampIndex) Name() string {
+	return timestampIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the index.  This is
+// part of the Indexer interface.
+func (idx *TimestampIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(timestampIndexKey)
+	return err
+}
+
+// blockTimestampKey returns the key of the passed block in the timestamp index.
+func blockTimestampKey(block *ulordutil.Block) []byte {
+	timestamp := uint32(block.MsgBlock().Header.Timestamp.Unix())
+	return serializeTimestampKey(timestamp, block.Height())
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer maps the timestamp of the block to
+// its hash.  This is part of the Indexer interface.
+func (idx *TimestampIndex) ConnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	_ []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(timestampIndexKey)
+	return bucket.Put(blockTimestampKey(block), block.Hash()[:])
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entry of the
+// block.  This is part of the Indexer interface.
+func (idx *TimestampIndex) DisconnectBlock(dbTx database.Tx, block *ulordutil.Block,
+	_ []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(timestampIndexKey)
+	return bucket.Delete(blockTimestampKey(block))
+}
+
+// BlockHashes returns the hashes of the blocks in the main chain with a
+// timestamp which is at least low and less than high, ordered by timestamp and
+// then by height.
+//
+// This function is safe for concurrent access.
+func (idx *TimestampIndex) BlockHashes(high, low uint32) ([]chainhash.Hash, error) {
+	var hashes []chainhash.Hash
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(timestampIndexKey).Cursor()
+		end := serializeTimestampKey(high, 0)
+		for ok := cursor.Seek(serializeTimestampKey(low, 0)); ok; ok = cursor.Next() {
+			if bytes.Compare(cursor.Key(), end) >= 0 {
+				break
+			}
+
+			value := cursor.Value()
+			if len(value) != chainhash.HashSize {
+				return errDeserialize(fmt.Sprintf("unexpected "+
+					"length %d for block hash", len(value)))
+			}
+			var hash chainhash.Hash
+			copy(hash[:], value)
+			hashes = append(hashes, hash)
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// NewTimestampIndex returns a new instance of an indexer that is used to create
+// a mapping of the timestamps of the blocks in the main chain to their hashes.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTimestampIndex(db database.DB) *TimestampIndex {
+	return &TimestampIndex{db: db}
+}
+
+// DropTimestampIndex drops the timestamp index from the provided database if it
+// exists.
+func DropTimestampIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, timestampIndexKey, timestampIndexName, interrupt)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestTimestampIndex ensures the timestamp index returns the hashes of the
+// blocks in a time range, including blocks whose timestamps do not increase
+// with their height.
+func TestTimestampIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "timestampindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTimestampIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	// The third block has an earlier timestamp than the second one and
+	// the fourth block the same timestamp as the third one.
+	timestamps := []int64{1000, 1200, 1100, 1100, 1300}
+	blocks := make([]*ulordutil.Block, 0, len(timestamps))
+	for i, timestamp := range timestamps {
+		block := ulordutil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{
+				Timestamp: time.Unix(timestamp, 0),
+				Nonce:     uint32(i),
+			},
+		})
+		block.SetHeight(int32(i))
+		blocks = append(blocks, block)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		for _, block := range blocks {
+			if err := idx.ConnectBlock(dbTx, block, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	hashes := func(heights ...int) []chainhash.Hash {
+		var hashes []chainhash.Hash
+		for _, height := range heights {
+			hashes = append(hashes, *blocks[height].Hash())
+		}
+		return hashes
+	}
+	tests := []struct {
+		high, low uint32
+		want      []chainhash.Hash
+	}{
+		{high: 2000, low: 0, want: hashes(0, 2, 3, 1, 4)},
+		{high: 1200, low: 1000, want: hashes(0, 2, 3)},
+		{high: 1101, low: 1100, want: hashes(2, 3)},
+		{high: 1100, low: 1001, want: nil},
+		{high: 1000, low: 2000, want: nil},
+	}
+	for i, test := range tests {
+		got, err := idx.BlockHashes(test.high, test.low)
+		if err != nil {
+			t.Fatalf("BlockHashes #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("BlockHashes #%d: got %v, want %v", i, got,
+				test.want)
+		}
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[4], nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	got, err := idx.BlockHashes(2000, 0)
+	if err != nil {
+		t.Fatalf("BlockHashes: unexpected error: %v", err)
+	}
+	if want := hashes(0, 2, 3, 1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("BlockHashes: got %v, want %v", got, want)
+	}
+}
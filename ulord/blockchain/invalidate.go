@@ -0,0 +1,156 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// UnknownBlockError identifies a block which is not in the block index.
+type UnknownBlockError chainhash.Hash
+
+// Error implements the error interface.
+func (e UnknownBlockError) Error() string {
+	return fmt.Sprintf("block %v is not known", chainhash.Hash(e))
+}
+
+// descendants returns the nodes in the block index which descend from the
+// passed node.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) descendants(node *blockNode) []*blockNode {
+	var nodes []*blockNode
+	bi.RLock()
+	for _, n := range bi.index {
+		if n.height > node.height && n.Ancestor(node.height) == node {
+			nodes = append(nodes, n)
+		}
+	}
+	bi.RUnlock()
+	return nodes
+}
+
+// bestCandidate returns the block with the most cumulative work which is not
+// known to be invalid and whose data is stored along with the data of all of
+// its ancestors back to the main chain.  The deepest valid block in the main
+// chain is preferred when there are ties.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestCandidate() *blockNode {
+	best := b.bestChain.Tip()
+	for best.parent != nil && b.index.NodeStatus(best).KnownInvalid() {
+		best = best.parent
+	}
+
+	b.index.RLock()
+	defer b.index.RUnlock()
+	for _, n := range b.index.index {
+		if n.workSum.Cmp(best.workSum) <= 0 {
+			continue
+		}
+		usable := !n.status.KnownInvalid() && n.status.HaveData()
+		for an := n; usable && !b.bestChain.Contains(an); an = an.parent {
+			if an.status.KnownInvalid() || !an.status.HaveData() {
+				usable = false
+				break
+			}
+		}
+		if usable {
+			best = n
+		}
+	}
+	return best
+}
+
+// reorganizeToBestCandidate reorganizes the main chain to the best chain which
+// is not known to be invalid.  Candidates which turn out to be invalid when
+// they are connected are marked as such and the next best one is tried.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorganizeToBestCandidate() error {
+	var lastCandidate *blockNode
+	for {
+		candidate := b.bestCandidate()
+		if candidate == b.bestChain.Tip() {
+			return nil
+		}
+
+		detachNodes, attachNodes := b.getReorganizeNodes(candidate)
+		err := b.reorganizeChain(detachNodes, attachNodes)
+		if _, ok := err.(RuleError); ok && candidate != lastCandidate {
+			log.Infof("Skipping invalid chain with tip %v: %v",
+				candidate.hash, err)
+			lastCandidate = candidate
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// InvalidateBlock marks the block with the passed hash and all of its
+// descendants as invalid.  When the block is in the main chain, the chain is
+// reorganized to the best chain which remains valid.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return UnknownBlockError(*hash)
+	}
+	if node.parent == nil {
+		return AssertError("the genesis block can't be invalidated")
+	}
+
+	log.Infof("Invalidating block %v (height %d)", hash, node.height)
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	for _, n := range b.index.descendants(node) {
+		b.index.SetStatusFlags(n, statusInvalidAncestor)
+	}
+
+	err := b.reorganizeToBestCandidate()
+	if flushErr := b.index.flushToDB(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// ReconsiderBlock removes the invalid marks from the block with the passed
+// hash, its ancestors, and its descendants, such as those set by
+// InvalidateBlock, and reorganizes the main chain to the best chain.  Blocks
+// which were not fully validated before are validated again when they are
+// connected.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return UnknownBlockError(*hash)
+	}
+
+	log.Infof("Reconsidering block %v (height %d)", hash, node.height)
+	const invalidFlags = statusValidateFailed | statusInvalidAncestor
+	for n := node; n != nil; n = n.parent {
+		b.index.UnsetStatusFlags(n, invalidFlags)
+	}
+	for _, n := range b.index.descendants(node) {
+		b.index.UnsetStatusFlags(n, invalidFlags)
+	}
+
+	err := b.reorganizeToBestCandidate()
+	if flushErr := b.index.flushToDB(); err == nil {
+		err = flushErr
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestInvalidateReconsiderBlock ensures invalidating and reconsidering blocks
+// reorganizes the chain to the best chain which is not invalid.
+func TestInvalidateReconsiderBlock(t *testing.T) {
+	// Load up blocks such that there is a side chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+	}
+	var blocks []*ulordutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("invalidateblock",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+
+	block3, block4, block3a := blocks[3].Hash(), blocks[4].Hash(),
+		blocks[5].Hash()
+	tests := []struct {
+		name       string
+		invalidate bool
+		hash       *chainhash.Hash
+		wantTip    *chainhash.Hash
+	}{{
+		// Block 3 has the same work as block 3a, so the chain must
+		// remain on block 3.
+		name:       "invalidate 4",
+		invalidate: true,
+		hash:       block4,
+		wantTip:    block3,
+	}, {
+		name:       "invalidate 3",
+		invalidate: true,
+		hash:       block3,
+		wantTip:    block3a,
+	}, {
+		// Reconsidering block 3 also reconsiders its descendant 4.
+		name:    "reconsider 3",
+		hash:    block3,
+		wantTip: block4,
+	}}
+	for _, test := range tests {
+		if test.invalidate {
+			err = chain.InvalidateBlock(test.hash)
+		} else {
+			err = chain.ReconsiderBlock(test.hash)
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if tip := chain.BestSnapshot().Hash; tip != *test.wantTip {
+			t.Fatalf("%s: tip %v, want %v", test.name, tip,
+				test.wantTip)
+		}
+	}
+
+	// Unknown blocks and the genesis block can't be invalidated.
+	unknown := chainhash.Hash{0x01}
+	err = chain.InvalidateBlock(&unknown)
+	if _, ok := err.(UnknownBlockError); !ok {
+		t.Fatalf("InvalidateBlock: unexpected error %v", err)
+	}
+	err = chain.ReconsiderBlock(&unknown)
+	if _, ok := err.(UnknownBlockError); !ok {
+		t.Fatalf("ReconsiderBlock: unexpected error %v", err)
+	}
+	err = chain.InvalidateBlock(chain.chainParams.GenesisHash)
+	if _, ok := err.(AssertError); !ok {
+		t.Fatalf("InvalidateBlock(genesis): unexpected error %v", err)
+	}
+}
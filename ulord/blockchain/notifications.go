@@ -6,6 +6,9 @@ package blockchain
 
 import (
 	"fmt"
+	"sync"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
 
 // NotificationType represents the type of a notification message.
@@ -29,14 +32,32 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTReorganizeStarted indicates the main chain is about to be
+	// reorganized.  It is followed by the notifications of the blocks
+	// disconnected from and connected to the main chain and then by
+	// NTReorganizeFinished.
+	NTReorganizeStarted
+
+	// NTReorganizeFinished indicates the reorganization of the main chain
+	// announced by the previous NTReorganizeStarted has finished.
+	NTReorganizeFinished
+
+	// NTReorganizeRejected indicates a block which would have caused a
+	// reorganization of the main chain deeper than the maximum reorganize
+	// depth was rejected.
+	NTReorganizeRejected
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
 // names for pretty printing.
 var notificationTypeStrings = map[NotificationType]string{
-	NTBlockAccepted:     "NTBlockAccepted",
-	NTBlockConnected:    "NTBlockConnected",
-	NTBlockDisconnected: "NTBlockDisconnected",
+	NTBlockAccepted:      "NTBlockAccepted",
+	NTBlockConnected:     "NTBlockConnected",
+	NTBlockDisconnected:  "NTBlockDisconnected",
+	NTReorganizeStarted:  "NTReorganizeStarted",
+	NTReorganizeFinished: "NTReorganizeFinished",
+	NTReorganizeRejected: "NTReorganizeRejected",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -47,35 +68,207 @@ func (n NotificationType) String() string {
 	return fmt.Sprintf("Unknown Notification Type (%d)", int(n))
 }
 
+// ReorganizeNtfnData is the data of the NTReorganizeStarted,
+// NTReorganizeFinished, and NTReorganizeRejected notifications.  The new tip
+// of a rejected reorganization is the block which was rejected.
+type ReorganizeNtfnData struct {
+	// OldHash and OldHeight identify the tip of the main chain before the
+	// reorganization.
+	OldHash   chainhash.Hash
+	OldHeight int32
+
+	// NewHash and NewHeight identify the tip of the main chain after the
+	// reorganization.
+	NewHash   chainhash.Hash
+	NewHeight int32
+
+	// Depth is the number of blocks disconnected from the main chain.
+	Depth int32
+}
+
 // Notification defines notification that is sent to the caller via the callback
-// function provided during the call to New and consists of a notification type
-// as well as associated data that depends on the type as follows:
-// 	- NTBlockAccepted:     *ulordutil.Block
-// 	- NTBlockConnected:    *ulordutil.Block
-// 	- NTBlockDisconnected: *ulordutil.Block
+// function provided during the call to Subscribe and consists of a notification
+// type as well as associated data that depends on the type as follows:
+// 	- NTBlockAccepted:      *ulordutil.Block
+// 	- NTBlockConnected:     *ulordutil.Block
+// 	- NTBlockDisconnected:  *ulordutil.Block
+// 	- NTReorganizeStarted:  *ReorganizeNtfnData
+// 	- NTReorganizeFinished: *ReorganizeNtfnData
+// 	- NTReorganizeRejected: *ReorganizeNtfnData
 type Notification struct {
 	Type NotificationType
 	Data interface{}
 }
 
+// Subscription is a registration for block chain notifications returned by
+// Subscribe and SubscribeBuffered.
+type Subscription struct {
+	chain    *BlockChain
+	callback NotificationCallback
+	types    map[NotificationType]struct{}
+
+	// The following fields are only used by buffered subscriptions.  The
+	// queue holds the notifications which have not been delivered yet and
+	// wake is signalled whenever a notification is added to it.
+	buffered bool
+	queueMtx sync.Mutex
+	queue    []*Notification
+	wake     chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// wants returns whether the subscriber asked for notifications of the passed
+// type.
+func (s *Subscription) wants(typ NotificationType) bool {
+	if s.types == nil {
+		return true
+	}
+	_, ok := s.types[typ]
+	return ok
+}
+
+// deliver hands the notification to the subscriber.  Notifications of buffered
+// subscriptions are queued for the delivery goroutine, otherwise the callback
+// is executed right away.
+func (s *Subscription) deliver(n *Notification) {
+	if !s.buffered {
+		s.callback(n)
+		return
+	}
+
+	s.queueMtx.Lock()
+	s.queue = append(s.queue, n)
+	s.queueMtx.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverQueued executes the callback of a buffered subscription for each
+// queued notification in order until the subscription is cancelled.
+//
+// This must be run as a goroutine.
+func (s *Subscription) deliverQueued() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.quit:
+			return
+		}
+
+		for {
+			s.queueMtx.Lock()
+			if len(s.queue) == 0 {
+				s.queueMtx.Unlock()
+				break
+			}
+			n := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.queueMtx.Unlock()
+
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			s.callback(n)
+		}
+	}
+}
+
+// Unsubscribe cancels the subscription so the callback is no longer executed
+// for new notifications.  The queued notifications of a buffered subscription
+// are dropped and Unsubscribe waits for a running callback to return, so it
+// must not be called from the callback of a buffered subscription.
+//
+// This function is safe for concurrent access.
+func (s *Subscription) Unsubscribe() {
+	b := s.chain
+	b.notificationsLock.Lock()
+	for i, sub := range b.notifications {
+		if sub == s {
+			copy(b.notifications[i:], b.notifications[i+1:])
+			b.notifications[len(b.notifications)-1] = nil
+			b.notifications = b.notifications[:len(b.notifications)-1]
+			if s.buffered {
+				close(s.quit)
+			}
+			break
+		}
+	}
+	b.notificationsLock.Unlock()
+
+	if s.buffered {
+		<-s.done
+	}
+}
+
+// subscribe registers a new subscription for notifications of the passed
+// types, or all types when none are passed.
+func (b *BlockChain) subscribe(callback NotificationCallback, buffered bool, types []NotificationType) *Subscription {
+	sub := &Subscription{
+		chain:    b,
+		callback: callback,
+		buffered: buffered,
+	}
+	if len(types) > 0 {
+		sub.types = make(map[NotificationType]struct{}, len(types))
+		for _, typ := range types {
+			sub.types[typ] = struct{}{}
+		}
+	}
+	if buffered {
+		sub.wake = make(chan struct{}, 1)
+		sub.quit = make(chan struct{})
+		sub.done = make(chan struct{})
+		go sub.deliverQueued()
+	}
+
+	b.notificationsLock.Lock()
+	b.notifications = append(b.notifications, sub)
+	b.notificationsLock.Unlock()
+	return sub
+}
+
 // Subscribe to block chain notifications. Registers a callback to be executed
 // when various events take place. See the documentation on Notification and
 // NotificationType for details on the types and contents of notifications.
-func (b *BlockChain) Subscribe(callback NotificationCallback) {
-	b.notificationsLock.Lock()
-	b.notifications = append(b.notifications, callback)
-	b.notificationsLock.Unlock()
+//
+// Only notifications of the passed types are delivered, or notifications of all
+// types when none are passed.  The callback is executed synchronously as part
+// of processing the event, so processing waits for it to return.
+func (b *BlockChain) Subscribe(callback NotificationCallback, types ...NotificationType) *Subscription {
+	return b.subscribe(callback, false, types)
+}
+
+// SubscribeBuffered is like Subscribe except the notifications are queued and
+// the callback is executed for each of them in order by a separate goroutine,
+// so slow subscribers don't hold up the processing of blocks.  The queue is not
+// bounded.
+func (b *BlockChain) SubscribeBuffered(callback NotificationCallback, types ...NotificationType) *Subscription {
+	return b.subscribe(callback, true, types)
 }
 
-// sendNotification sends a notification with the passed type and data if the
-// caller requested notifications by providing a callback function in the call
-// to New.
+// sendNotification sends a notification with the passed type and data to all
+// subscribers which asked for notifications of that type.
 func (b *BlockChain) sendNotification(typ NotificationType, data interface{}) {
-	// Generate and send the notification.
+	// Generate and send the notification.  The subscribers are copied so
+	// the callbacks may unsubscribe.
 	n := Notification{Type: typ, Data: data}
 	b.notificationsLock.RLock()
-	for _, callback := range b.notifications {
-		callback(&n)
+	subs := make([]*Subscription, 0, len(b.notifications))
+	for _, sub := range b.notifications {
+		if sub.wants(typ) {
+			subs = append(subs, sub)
+		}
 	}
 	b.notificationsLock.RUnlock()
+
+	for _, sub := range subs {
+		sub.deliver(&n)
+	}
 }
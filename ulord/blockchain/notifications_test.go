@@ -5,9 +5,12 @@
 package blockchain
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
 )
 
 // TestNotifications ensures that notification callbacks are fired on events.
@@ -49,3 +52,127 @@ func TestNotifications(t *testing.T) {
 			"times, found %d", numSubscribers, notificationCount)
 	}
 }
+
+// TestNotificationSubscriptions ensures subscriptions only receive the
+// notifications of the types they asked for, buffered subscriptions receive
+// them in order, reorganizations are announced, and cancelled subscriptions no
+// longer receive notifications.
+func TestNotificationSubscriptions(t *testing.T) {
+	// Load up blocks such that there is a side chain which becomes the
+	// main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*ulordutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("subscriptions",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	var reorgs []*Notification
+	chain.Subscribe(func(n *Notification) {
+		reorgs = append(reorgs, n)
+	}, NTReorganizeStarted, NTReorganizeFinished)
+
+	var cancelledCount int
+	cancelled := chain.Subscribe(func(*Notification) {
+		cancelledCount++
+	})
+
+	var bufferedMtx sync.Mutex
+	var buffered []*Notification
+	bufferedDone := make(chan struct{})
+	chain.SubscribeBuffered(func(n *Notification) {
+		bufferedMtx.Lock()
+		buffered = append(buffered, n)
+		bufferedMtx.Unlock()
+		if n.Type == NTReorganizeFinished {
+			close(bufferedDone)
+		}
+	}, NTBlockConnected, NTBlockDisconnected, NTReorganizeStarted,
+		NTReorganizeFinished)
+
+	for i := 1; i < 5; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+	if cancelledCount != 8 {
+		t.Fatalf("got %d notifications before unsubscribing, want 8",
+			cancelledCount)
+	}
+	cancelled.Unsubscribe()
+	for i := 5; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+	if cancelledCount != 8 {
+		t.Fatalf("got %d notifications after unsubscribing, want 8",
+			cancelledCount)
+	}
+
+	// The reorganization disconnects blocks 4 and 3 and connects blocks
+	// 3a, 4a, and 5a.
+	if len(reorgs) != 2 || reorgs[0].Type != NTReorganizeStarted ||
+		reorgs[1].Type != NTReorganizeFinished {
+
+		t.Fatalf("unexpected reorganize notifications %v", reorgs)
+	}
+	wantData := ReorganizeNtfnData{
+		OldHash:   *blocks[4].Hash(),
+		OldHeight: 4,
+		NewHash:   *blocks[7].Hash(),
+		NewHeight: 5,
+		Depth:     2,
+	}
+	for _, n := range reorgs {
+		data := n.Data.(*ReorganizeNtfnData)
+		if *data != wantData {
+			t.Fatalf("got %v data %+v, want %+v", n.Type, data,
+				wantData)
+		}
+	}
+
+	select {
+	case <-bufferedDone:
+	case <-time.After(time.Second * 5):
+		t.Fatal("buffered notifications were not delivered")
+	}
+	bufferedMtx.Lock()
+	defer bufferedMtx.Unlock()
+	wantTypes := []NotificationType{
+		NTBlockConnected, NTBlockConnected, NTBlockConnected,
+		NTBlockConnected, NTReorganizeStarted, NTBlockDisconnected,
+		NTBlockDisconnected, NTBlockConnected, NTBlockConnected,
+		NTBlockConnected, NTReorganizeFinished,
+	}
+	if len(buffered) != len(wantTypes) {
+		t.Fatalf("got %d buffered notifications, want %d",
+			len(buffered), len(wantTypes))
+	}
+	for i, n := range buffered {
+		if n.Type != wantTypes[i] {
+			t.Fatalf("buffered notification %d: got %v, want %v", i,
+				n.Type, wantTypes[i])
+		}
+	}
+}
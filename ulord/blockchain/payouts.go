@@ -0,0 +1,248 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// PayeeSource provides the payees of coinbase transactions which are chosen
+// outside of the block chain by the masternode list and the governance system.
+type PayeeSource interface {
+	// MasternodePayee returns the script the masternode payment of the
+	// block at the given height, which extends the block with the given
+	// hash, must be paid to.  It returns nil when the payee is not known,
+	// such as for blocks older than the masternode list, in which case the
+	// masternode payment of the block is not checked.
+	MasternodePayee(height int32, prevHash *chainhash.Hash) []byte
+
+	// SuperblockPayments returns the outputs the coinbase transaction of
+	// the superblock at the given height must contain.  It returns nil when
+	// the payments are not known, in which case only the budget is
+	// enforced.
+	SuperblockPayments(height int32) []*wire.TxOut
+}
+
+// CoinbaseCheck checks the payouts made by the coinbase transaction of a
+// block which is connected to the main chain at the given height.  The subsidy
+// is the subsidy of the block without the superblock budget and fees is the
+// sum of the fees of its transactions.  It must return a RuleError when the
+// payouts are not valid.
+type CoinbaseCheck func(block *ulordutil.Block, height int32, subsidy, fees int64) error
+
+// IsSuperblock returns whether the block at the given height is a superblock
+// which may pay out the budget approved by the governance system.
+func IsSuperblock(height int32, chainParams *chaincfg.Params) bool {
+	return chainParams.Subsidy.IsSuperblock(height)
+}
+
+// CalcSuperblockBudget returns the budget of the superblock at the given height,
+// which is the most the payments chosen by the governance system may total on
+// top of the block subsidy and fees.  It is the budget share of the subsidy of
+// each block of the superblock cycle ending at a superblock and zero for all
+// other blocks.
+func CalcSuperblockBudget(height int32, chainParams *chaincfg.Params) int64 {
+	return chainParams.Subsidy.SuperblockBudget(height)
+}
+
+// superblockAllowance returns the amount the coinbase transaction of the block
+// at the given height may pay out on top of the block subsidy and fees.  It is
+// the total of the superblock payments provided by the payee source, up to the
+// budget of the superblock, and zero when there is no payee source or it does
+// not know the payments, since the budget must not be paid to the miner.
+func superblockAllowance(height int32, chainParams *chaincfg.Params, payees PayeeSource) int64 {
+	if payees == nil || !IsSuperblock(height, chainParams) {
+		return 0
+	}
+	var total int64
+	for _, payment := range payees.SuperblockPayments(height) {
+		total += payment.Value
+	}
+	if budget := CalcSuperblockBudget(height, chainParams); total > budget {
+		return budget
+	}
+	return total
+}
+
+// coinbaseOutputs tracks the outputs of a coinbase transaction which were
+// matched to the payments it must make, so that each output counts toward one
+// payment only.
+type coinbaseOutputs struct {
+	tx   *wire.MsgTx
+	used []bool
+}
+
+// newCoinbaseOutputs returns the outputs of the passed coinbase transaction
+// with none of them matched to a payment.
+func newCoinbaseOutputs(tx *wire.MsgTx) *coinbaseOutputs {
+	return &coinbaseOutputs{tx: tx, used: make([]bool, len(tx.TxOut))}
+}
+
+// claim matches the smallest unmatched output paying at least the given amount
+// to the script to the payment and returns whether there is one.  Choosing the
+// smallest output leaves the larger ones to the payments which follow.
+func (o *coinbaseOutputs) claim(pkScript []byte, amount int64) bool {
+	best := -1
+	for i, txOut := range o.tx.TxOut {
+		if o.used[i] || txOut.Value < amount ||
+			!bytes.Equal(txOut.PkScript, pkScript) {
+
+			continue
+		}
+		if best == -1 || txOut.Value < o.tx.TxOut[best].Value {
+			best = i
+		}
+	}
+	if best == -1 {
+		return false
+	}
+	o.used[best] = true
+	return true
+}
+
+// checkFounderRewards ensures the coinbase outputs pay the founder rewards
+// defined by the chain parameters.
+func checkFounderRewards(outs *coinbaseOutputs, height int32, chainParams *chaincfg.Params) error {
+	for _, payout := range chainParams.Subsidy.FounderPayouts(height) {
+		if !outs.claim(payout.PkScript, payout.Amount) {
+			str := fmt.Sprintf("coinbase transaction for block at "+
+				"height %d does not pay the founder reward of "+
+				"%v to script %x", height, payout.Amount,
+				payout.PkScript)
+			return ruleError(ErrMissingFounderReward, str)
+		}
+	}
+	return nil
+}
+
+// checkMasternodePayment ensures the coinbase outputs pay the masternode share
+// of the subsidy defined by the chain parameters to the masternode chosen by
+// the payee source.  The payment is not checked when there is no payee source
+// or it does not know the payee of the block.
+func checkMasternodePayment(outs *coinbaseOutputs, block *ulordutil.Block, height int32, chainParams *chaincfg.Params, payees PayeeSource) error {
+	amount := chainParams.Subsidy.MasternodePayment(height)
+	if amount == 0 || payees == nil {
+		return nil
+	}
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	payee := payees.MasternodePayee(height, prevHash)
+	if payee == nil {
+		return nil
+	}
+
+	if !outs.claim(payee, amount) {
+		str := fmt.Sprintf("coinbase transaction for block at height "+
+			"%d does not pay the masternode share of %v to script "+
+			"%x", height, amount, payee)
+		return ruleError(ErrBadMasternodePayment, str)
+	}
+	return nil
+}
+
+// checkSuperblockPayments ensures the coinbase outputs of superblocks make the
+// payments chosen by the payee source.  The payments are not checked when
+// there is no payee source or it does not know the payments of the block.
+func checkSuperblockPayments(outs *coinbaseOutputs, height int32, chainParams *chaincfg.Params, payees PayeeSource) error {
+	if payees == nil || !IsSuperblock(height, chainParams) {
+		return nil
+	}
+	payments := payees.SuperblockPayments(height)
+	if payments == nil {
+		return nil
+	}
+
+	var total int64
+	for _, payment := range payments {
+		total += payment.Value
+		if !outs.claim(payment.PkScript, payment.Value) {
+			str := fmt.Sprintf("coinbase transaction for superblock "+
+				"at height %d does not pay %v to script %x",
+				height, payment.Value, payment.PkScript)
+			return ruleError(ErrBadSuperblockPayment, str)
+		}
+	}
+	budget := CalcSuperblockBudget(height, chainParams)
+	if total > budget {
+		str := fmt.Sprintf("payments of superblock at height %d total "+
+			"%v which is more than the budget of %v", height, total,
+			budget)
+		return ruleError(ErrBadSuperblockPayment, str)
+	}
+	return nil
+}
+
+// consensusPayoutCheck returns a check ensuring coinbase transactions pay the
+// founder rewards defined by the chain parameters, the masternode share of the
+// subsidy, and the payments of superblocks, in that order.  Each output of the
+// coinbase transaction counts toward one of those payments only.
+func consensusPayoutCheck(chainParams *chaincfg.Params, payees PayeeSource) CoinbaseCheck {
+	return func(block *ulordutil.Block, height int32, subsidy, fees int64) error {
+		outs := newCoinbaseOutputs(block.Transactions()[0].MsgTx())
+		err := checkFounderRewards(outs, height, chainParams)
+		if err != nil {
+			return err
+		}
+		err = checkMasternodePayment(outs, block, height, chainParams,
+			payees)
+		if err != nil {
+			return err
+		}
+		return checkSuperblockPayments(outs, height, chainParams, payees)
+	}
+}
+
+// coinbaseChecks returns the checks of the payouts of coinbase transactions
+// which are enabled by the chain parameters followed by the passed additional
+// checks.
+func coinbaseChecks(chainParams *chaincfg.Params, payees PayeeSource, extra []CoinbaseCheck) []CoinbaseCheck {
+	var checks []CoinbaseCheck
+	subsidy := &chainParams.Subsidy
+	if len(subsidy.FounderRewards) > 0 || (payees != nil &&
+		(subsidy.MasternodePaymentPercent > 0 || subsidy.SuperblockCycle > 0)) {
+
+		checks = append(checks, consensusPayoutCheck(chainParams, payees))
+	}
+	return append(checks, extra...)
+}
+
+// checkCoinbaseValue ensures the total output values of the coinbase
+// transaction of the block which is connected at the given height don't exceed
+// its subsidy plus the given fees and the verified payments of superblocks.
+// It is safe to ignore overflow and out of range errors here because those
+// error conditions would have already been caught by checkTransactionSanity.
+func (b *BlockChain) checkCoinbaseValue(block *ulordutil.Block, height int32, fees int64) error {
+	var totalSatoshiOut int64
+	for _, txOut := range block.Transactions()[0].MsgTx().TxOut {
+		totalSatoshiOut += txOut.Value
+	}
+	expectedSatoshiOut := CalcBlockSubsidy(height, b.chainParams) +
+		superblockAllowance(height, b.chainParams, b.payees) + fees
+	if totalSatoshiOut > expectedSatoshiOut {
+		str := fmt.Sprintf("coinbase transaction for block pays %v "+
+			"which is more than expected value of %v",
+			totalSatoshiOut, expectedSatoshiOut)
+		return ruleError(ErrBadCoinbaseValue, str)
+	}
+	return nil
+}
+
+// checkCoinbasePayouts runs the checks of the payouts made by the coinbase
+// transaction of the block which is connected at the given height after
+// collecting the given fees.
+func (b *BlockChain) checkCoinbasePayouts(block *ulordutil.Block, height int32, fees int64) error {
+	subsidy := CalcBlockSubsidy(height, b.chainParams)
+	for _, check := range b.coinbaseChecks {
+		if err := check(block, height, subsidy, fees); err != nil {
+			return err
+		}
+	}
+	return nil
+}
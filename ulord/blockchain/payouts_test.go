@@ -0,0 +1,395 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// testPayees is a PayeeSource returning fixed payees.
+type testPayees struct {
+	masternode []byte
+	superblock []*wire.TxOut
+}
+
+func (p *testPayees) MasternodePayee(int32, *chainhash.Hash) []byte {
+	return p.masternode
+}
+
+func (p *testPayees) SuperblockPayments(int32) []*wire.TxOut {
+	return p.superblock
+}
+
+// baseSubsidy is the subsidy of the blocks before the first reduction on the
+// regression test network.
+var baseSubsidy = chaincfg.RegressionNetParams.Subsidy.BaseSubsidy
+
+// TestSuperblockBudget ensures superblocks are identified and their budget is
+// the share of the subsidy of each block of the cycle.
+func TestSuperblockBudget(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Subsidy.SuperblockStartHeight = 100
+	params.Subsidy.SuperblockCycle = 50
+	params.Subsidy.SuperblockBudgetPercent = 10
+
+	tests := []struct {
+		height int32
+		budget int64
+	}{
+		{height: 0, budget: 0},
+		{height: 50, budget: 0},
+		{height: 99, budget: 0},
+		{height: 100, budget: 50 * baseSubsidy * 10 / 100},
+		{height: 101, budget: 0},
+		// The budget spans the subsidy halving at height 150.
+		{height: 150, budget: 49*baseSubsidy*10/100 +
+			baseSubsidy/2*10/100},
+	}
+	for _, test := range tests {
+		budget := CalcSuperblockBudget(test.height, &params)
+		if budget != test.budget {
+			t.Errorf("height %d: got budget %d, want %d",
+				test.height, budget, test.budget)
+		}
+		if IsSuperblock(test.height, &params) != (test.budget != 0) {
+			t.Errorf("height %d: unexpected superblock result",
+				test.height)
+		}
+	}
+}
+
+// TestCoinbasePayouts ensures the founder, masternode, and superblock payouts
+// required by the chain parameters are enforced.
+func TestCoinbasePayouts(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Subsidy.ReductionInterval = 0
+	founderScript := []byte{0x51}
+	params.Subsidy.FounderRewards = []chaincfg.FounderReward{{
+		StartHeight: 10,
+		EndHeight:   19,
+		PkScript:    founderScript,
+		Percent:     20,
+	}}
+	params.Subsidy.MasternodePaymentHeight = 5
+	params.Subsidy.MasternodePaymentPercent = 50
+	params.Subsidy.SuperblockStartHeight = 30
+	params.Subsidy.SuperblockCycle = 30
+	params.Subsidy.SuperblockBudgetPercent = 10
+
+	mnScript := []byte{0x52}
+	sbScript := []byte{0x53}
+	payees := &testPayees{
+		masternode: mnScript,
+		superblock: []*wire.TxOut{wire.NewTxOut(baseSubsidy, sbScript)},
+	}
+	var extraCalls int
+	extra := func(*ulordutil.Block, int32, int64, int64) error {
+		extraCalls++
+		return nil
+	}
+	chain := &BlockChain{
+		chainParams:    &params,
+		coinbaseChecks: coinbaseChecks(&params, payees, []CoinbaseCheck{extra}),
+	}
+
+	makeBlock := func(outs ...*wire.TxOut) *ulordutil.Block {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.TxOut = outs
+		return ulordutil.NewBlock(&wire.MsgBlock{
+			Transactions: []*wire.MsgTx{coinbase},
+		})
+	}
+	minerOut := wire.NewTxOut(baseSubsidy/2, []byte{0x00})
+	mnOut := wire.NewTxOut(baseSubsidy/2, mnScript)
+	founderOut := wire.NewTxOut(baseSubsidy/5, founderScript)
+	sbOut := wire.NewTxOut(baseSubsidy, sbScript)
+
+	tests := []struct {
+		name   string
+		height int32
+		block  *ulordutil.Block
+		code   ErrorCode
+		valid  bool
+	}{{
+		name:   "before masternode payments",
+		height: 4,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy, []byte{0x00})),
+		valid:  true,
+	}, {
+		name:   "masternode paid",
+		height: 5,
+		block:  makeBlock(minerOut, mnOut),
+		valid:  true,
+	}, {
+		name:   "masternode not paid",
+		height: 5,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy, []byte{0x00})),
+		code:   ErrBadMasternodePayment,
+	}, {
+		name:   "masternode underpaid",
+		height: 5,
+		block:  makeBlock(minerOut, wire.NewTxOut(baseSubsidy/4, mnScript)),
+		code:   ErrBadMasternodePayment,
+	}, {
+		name:   "founder paid",
+		height: 10,
+		block:  makeBlock(minerOut, mnOut, founderOut),
+		valid:  true,
+	}, {
+		name:   "founder not paid",
+		height: 19,
+		block:  makeBlock(minerOut, mnOut),
+		code:   ErrMissingFounderReward,
+	}, {
+		name:   "after founder reward",
+		height: 20,
+		block:  makeBlock(minerOut, mnOut),
+		valid:  true,
+	}, {
+		name:   "superblock paid",
+		height: 30,
+		block:  makeBlock(minerOut, mnOut, sbOut),
+		valid:  true,
+	}, {
+		name:   "superblock not paid",
+		height: 30,
+		block:  makeBlock(minerOut, mnOut),
+		code:   ErrBadSuperblockPayment,
+	}}
+	for _, test := range tests {
+		calls := extraCalls
+		err := chain.checkCoinbasePayouts(test.block, test.height, 0)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			if extraCalls != calls+1 {
+				t.Errorf("%s: additional check not run", test.name)
+			}
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != test.code {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.code)
+		}
+	}
+
+	// The superblock payments must not exceed the budget.
+	payees.superblock = []*wire.TxOut{wire.NewTxOut(4*baseSubsidy, sbScript)}
+	block := makeBlock(minerOut, mnOut, wire.NewTxOut(4*baseSubsidy, sbScript))
+	err := chain.checkCoinbasePayouts(block, 30, 0)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrBadSuperblockPayment {
+		t.Errorf("payments over budget: got error %v, want %v", err,
+			ErrBadSuperblockPayment)
+	}
+}
+
+// TestCoinbasePayoutsWithoutPayees ensures masternode and superblock payments
+// are not checked when their payees are not known, while founder rewards still
+// are, and that each output of a coinbase transaction counts toward one payment
+// only.
+func TestCoinbasePayoutsWithoutPayees(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Subsidy.ReductionInterval = 0
+	founderScript := []byte{0x51}
+	params.Subsidy.FounderRewards = []chaincfg.FounderReward{{
+		StartHeight: 10,
+		EndHeight:   19,
+		PkScript:    founderScript,
+		Percent:     20,
+	}}
+	params.Subsidy.MasternodePaymentHeight = 5
+	params.Subsidy.MasternodePaymentPercent = 50
+	params.Subsidy.SuperblockStartHeight = 30
+	params.Subsidy.SuperblockCycle = 30
+	params.Subsidy.SuperblockBudgetPercent = 10
+
+	makeBlock := func(outs ...*wire.TxOut) *ulordutil.Block {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.TxOut = outs
+		return ulordutil.NewBlock(&wire.MsgBlock{
+			Transactions: []*wire.MsgTx{coinbase},
+		})
+	}
+	minerOnly := makeBlock(wire.NewTxOut(baseSubsidy, []byte{0x00}))
+	founderOut := wire.NewTxOut(baseSubsidy/5, founderScript)
+	mnScript := []byte{0x52}
+
+	tests := []struct {
+		name   string
+		payees PayeeSource
+		height int32
+		block  *ulordutil.Block
+		code   ErrorCode
+		valid  bool
+	}{{
+		name:   "no payee source, masternode not paid",
+		height: 5,
+		block:  minerOnly,
+		valid:  true,
+	}, {
+		name:   "no payee source, superblock not paid",
+		height: 30,
+		block:  minerOnly,
+		valid:  true,
+	}, {
+		name:   "no payee source, founder not paid",
+		height: 10,
+		block:  minerOnly,
+		code:   ErrMissingFounderReward,
+	}, {
+		name:   "unknown payee, masternode not paid",
+		payees: &testPayees{},
+		height: 5,
+		block:  minerOnly,
+		valid:  true,
+	}, {
+		name:   "unknown payee, founder paid",
+		payees: &testPayees{},
+		height: 10,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy, []byte{0x00}), founderOut),
+		valid:  true,
+	}, {
+		name: "founder output reused for masternode",
+		payees: &testPayees{
+			masternode: founderScript,
+		},
+		height: 10,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy, founderScript)),
+		code:   ErrBadMasternodePayment,
+	}, {
+		name: "founder and masternode paid to one script",
+		payees: &testPayees{
+			masternode: founderScript,
+		},
+		height: 10,
+		block: makeBlock(wire.NewTxOut(baseSubsidy/2, founderScript),
+			wire.NewTxOut(baseSubsidy/5, founderScript)),
+		valid: true,
+	}, {
+		name: "masternode output reused for superblock",
+		payees: &testPayees{
+			masternode: mnScript,
+			superblock: []*wire.TxOut{
+				wire.NewTxOut(baseSubsidy/2, mnScript),
+			},
+		},
+		height: 30,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy/2, mnScript)),
+		code:   ErrBadSuperblockPayment,
+	}}
+	for _, test := range tests {
+		chain := &BlockChain{
+			chainParams:    &params,
+			coinbaseChecks: coinbaseChecks(&params, test.payees, nil),
+		}
+		err := chain.checkCoinbasePayouts(test.block, test.height, 0)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != test.code {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.code)
+		}
+	}
+}
+
+// TestCoinbaseValue ensures superblocks may only pay out their budget on top of
+// the subsidy and fees when the payee source verified their payments, so the
+// budget can't be paid to the miner.
+func TestCoinbaseValue(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Subsidy.ReductionInterval = 0
+	params.Subsidy.SuperblockStartHeight = 30
+	params.Subsidy.SuperblockCycle = 30
+	params.Subsidy.SuperblockBudgetPercent = 10
+	budget := CalcSuperblockBudget(30, &params)
+
+	makeBlock := func(outs ...*wire.TxOut) *ulordutil.Block {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.TxOut = outs
+		return ulordutil.NewBlock(&wire.MsgBlock{
+			Transactions: []*wire.MsgTx{coinbase},
+		})
+	}
+	sbScript := []byte{0x53}
+	minerTakesBudget := makeBlock(wire.NewTxOut(baseSubsidy+budget,
+		[]byte{0x00}))
+	budgetPaid := makeBlock(wire.NewTxOut(baseSubsidy, []byte{0x00}),
+		wire.NewTxOut(budget, sbScript))
+
+	tests := []struct {
+		name   string
+		payees PayeeSource
+		height int32
+		fees   int64
+		block  *ulordutil.Block
+		valid  bool
+	}{{
+		name:   "subsidy and fees",
+		height: 30,
+		fees:   1000,
+		block:  makeBlock(wire.NewTxOut(baseSubsidy+1000, []byte{0x00})),
+		valid:  true,
+	}, {
+		name:   "no payee source, miner takes budget",
+		height: 30,
+		block:  minerTakesBudget,
+	}, {
+		name:   "unknown payments, miner takes budget",
+		payees: &testPayees{},
+		height: 30,
+		block:  minerTakesBudget,
+	}, {
+		name: "verified payments, budget paid",
+		payees: &testPayees{
+			superblock: []*wire.TxOut{wire.NewTxOut(budget, sbScript)},
+		},
+		height: 30,
+		block:  budgetPaid,
+		valid:  true,
+	}, {
+		name: "verified payments below budget, budget paid",
+		payees: &testPayees{
+			superblock: []*wire.TxOut{
+				wire.NewTxOut(budget/2, sbScript),
+			},
+		},
+		height: 30,
+		block:  budgetPaid,
+	}, {
+		name: "verified payments, not a superblock",
+		payees: &testPayees{
+			superblock: []*wire.TxOut{wire.NewTxOut(budget, sbScript)},
+		},
+		height: 31,
+		block:  budgetPaid,
+	}}
+	for _, test := range tests {
+		chain := &BlockChain{chainParams: &params, payees: test.payees}
+		err := chain.checkCoinbaseValue(test.block, test.height, test.fees)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != ErrBadCoinbaseValue {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				ErrBadCoinbaseValue)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+)
+
+const (
+	// MinPruneTargetSize is the minimum recommended target size in bytes
+	// for the block data kept when pruning.  The database stores blocks in
+	// large files which are deleted as a whole, so smaller targets would
+	// not leave room for the blocks which must be kept.
+	MinPruneTargetSize = 1536 * 1024 * 1024
+
+	// MinBlocksToKeep is the number of blocks at the end of the main chain
+	// whose data is never pruned so the chain can still be reorganized.
+	MinBlocksToKeep = 288
+)
+
+var (
+	// errPruneTooRecent is used to abort pruning when the database would
+	// delete the data of blocks which must be kept.
+	errPruneTooRecent = errors.New("prune target is too small to keep " +
+		"the most recent blocks")
+
+	// errPruneNeedsFlush is used to abort pruning when the database would
+	// delete the data of blocks which are needed to recover the utxo set
+	// since the utxo cache was not flushed after they were connected.
+	errPruneNeedsFlush = errors.New("utxo cache must be flushed before " +
+		"pruning")
+)
+
+// BlockPrunedError identifies a block which is known, but whose data has been
+// pruned from the database.
+type BlockPrunedError chainhash.Hash
+
+// Error implements the error interface.
+func (e BlockPrunedError) Error() string {
+	return fmt.Sprintf("data of block %v has been pruned",
+		chainhash.Hash(e))
+}
+
+// initPruneState determines whether blocks have been pruned from the database
+// and the height of the first block in the main chain whose data is stored.
+func (b *BlockChain) initPruneState() error {
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		b.pruned, err = dbTx.BeenPruned()
+		return err
+	})
+	if err != nil || !b.pruned {
+		return err
+	}
+
+	for node := b.bestChain.Genesis(); node != nil; node = b.bestChain.Next(node) {
+		if b.index.NodeStatus(node).HaveData() {
+			b.pruneHeight = node.height
+			break
+		}
+	}
+	return nil
+}
+
+// maybePruneBlocks deletes the data of the oldest blocks when the block data
+// in the database exceeds the configured prune target size.  The headers of the
+// blocks remain in the block index.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) maybePruneBlocks() error {
+	if b.pruneTarget == 0 {
+		return nil
+	}
+
+	// The utxo set is recovered by connecting the blocks after the last
+	// flush of the utxo cache again, so flush it before their data is
+	// deleted.
+	nodes, err := b.pruneBlocks()
+	if err == errPruneNeedsFlush {
+		tip := b.bestChain.Tip()
+		err = b.utxoCache.maybeFlush(&tip.hash, tip.height, FlushRequired)
+		if err != nil {
+			return err
+		}
+		nodes, err = b.pruneBlocks()
+	}
+	if err == errPruneTooRecent {
+		log.Warnf("Unable to prune blocks: %v", err)
+		return nil
+	}
+	if err != nil || len(nodes) == 0 {
+		return err
+	}
+
+	// Update the height of the first block in the main chain whose data is
+	// still stored.
+	b.pruned = true
+	for _, node := range nodes {
+		if b.bestChain.Contains(node) && node.height >= b.pruneHeight {
+			b.pruneHeight = node.height + 1
+		}
+	}
+	log.Infof("Pruned the data of %d blocks (prune height %d)", len(nodes),
+		b.pruneHeight)
+	return nil
+}
+
+// pruneBlocks deletes the data and spend journal entries of the oldest blocks
+// until the configured prune target size is met and returns the nodes of the
+// known blocks which were pruned.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneBlocks() ([]*blockNode, error) {
+	tip := b.bestChain.Tip()
+	var nodes []*blockNode
+	err := b.db.Update(func(dbTx database.Tx) error {
+		hashes, err := dbTx.PruneBlocks(b.pruneTarget)
+		if err != nil {
+			return err
+		}
+
+		// Ensure none of the blocks must be kept before making changes.
+		for i := range hashes {
+			node := b.index.LookupNode(&hashes[i])
+			if node == nil {
+				continue
+			}
+			if b.bestChain.Contains(node) {
+				if node.height > tip.height-MinBlocksToKeep {
+					return errPruneTooRecent
+				}
+				if node.height > b.utxoCache.lastFlushHeight {
+					return errPruneNeedsFlush
+				}
+			}
+			nodes = append(nodes, node)
+		}
+
+		// Remove the spend journal entries and record that the data of
+		// the blocks is no longer stored.
+		for i := range hashes {
+			if err := dbRemoveSpendJournalEntry(dbTx, &hashes[i]); err != nil {
+				return err
+			}
+		}
+		for _, node := range nodes {
+			b.index.UnsetStatusFlags(node, statusDataStored)
+			if err := dbStoreBlockNode(dbTx, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// IsPruned returns whether or not the data of any blocks has been pruned from
+// the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsPruned() bool {
+	b.chainLock.RLock()
+	pruned := b.pruned
+	b.chainLock.RUnlock()
+	return pruned
+}
+
+// PruneHeight returns the height of the first block in the main chain whose
+// data has not been pruned.  It is zero when no blocks have been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneHeight() int32 {
+	b.chainLock.RLock()
+	pruneHeight := b.pruneHeight
+	b.chainLock.RUnlock()
+	return pruneHeight
+}
+
+// IsBlockPruned returns whether or not the block with the passed hash is known,
+// but its data has been pruned from the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsBlockPruned(hash *chainhash.Hash) bool {
+	node := b.index.LookupNode(hash)
+	return node != nil && !b.index.NodeStatus(node).HaveData()
+}
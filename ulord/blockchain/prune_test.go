@@ -0,0 +1,71 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+)
+
+// TestPrunedBlocks ensures blocks whose data is not stored are reported as
+// pruned rather than returned, and that pruning keeps the data of the most
+// recent blocks.
+func TestPrunedBlocks(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("prunedblocks",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1 and prune as much as possible.
+	chain.TstSetCoinbaseMaturity(1)
+	chain.pruneTarget = 1
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+
+	// The blocks are all within the most recent blocks, so their data
+	// must have been kept.
+	if chain.IsPruned() || chain.PruneHeight() != 0 {
+		t.Fatalf("chain pruned: %v, prune height %d", chain.IsPruned(),
+			chain.PruneHeight())
+	}
+	for i := 1; i < len(blocks); i++ {
+		if chain.IsBlockPruned(blocks[i].Hash()) {
+			t.Fatalf("block %d reported as pruned", i)
+		}
+		if _, err := chain.BlockByHash(blocks[i].Hash()); err != nil {
+			t.Fatalf("BlockByHash(%d): %v", i, err)
+		}
+	}
+
+	// Mark the data of the first block as no longer stored.  It must be
+	// reported as pruned by the block fetching functions.
+	hash := blocks[1].Hash()
+	chain.index.UnsetStatusFlags(chain.index.LookupNode(hash),
+		statusDataStored)
+	if !chain.IsBlockPruned(hash) {
+		t.Fatalf("block %v not reported as pruned", hash)
+	}
+	_, err = chain.BlockByHash(hash)
+	if _, ok := err.(BlockPrunedError); !ok {
+		t.Fatalf("BlockByHash: unexpected error %v", err)
+	}
+	_, err = chain.BlockByHeight(1)
+	if _, ok := err.(BlockPrunedError); !ok {
+		t.Fatalf("BlockByHeight: unexpected error %v", err)
+	}
+}
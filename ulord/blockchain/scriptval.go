@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"math"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ulordsuite/ulord/txscript"
@@ -23,171 +25,200 @@ type txValidateItem struct {
 	sigHashes *txscript.TxSigHashes
 }
 
-// txValidator provides a type which asynchronously validates transaction
-// inputs.  It provides several channels for communication and a processing
-// function that is intended to be in run multiple goroutines.
-type txValidator struct {
-	validateChan chan *txValidateItem
-	quitChan     chan struct{}
-	resultChan   chan error
-	utxoView     *UtxoViewpoint
-	flags        txscript.ScriptFlags
-	sigCache     *txscript.SigCache
-	hashCache    *txscript.HashCache
+// maxScriptValBatch is the maximum number of inputs a script validation
+// worker claims at once.  Small batches keep the workers balanced when some
+// inputs are much more expensive to validate than others.
+const maxScriptValBatch = 16
+
+var (
+	// scriptValPoolOnce starts the script validation workers the first
+	// time scripts are validated.
+	scriptValPoolOnce sync.Once
+
+	// scriptValJobs is used to hand script validation jobs to idle
+	// workers.
+	scriptValJobs chan *scriptValJob
+
+	// numScriptValWorkers is the number of script validation workers.
+	numScriptValWorkers int
+)
+
+// startScriptValWorkers starts the persistent pool of script validation
+// workers, one per processor core.  The workers are shared by all validations
+// so connecting blocks and accepting transactions doesn't pay for starting
+// goroutines.
+func startScriptValWorkers() {
+	numScriptValWorkers = runtime.NumCPU()
+	if numScriptValWorkers <= 0 {
+		numScriptValWorkers = 1
+	}
+
+	scriptValJobs = make(chan *scriptValJob)
+	for i := 0; i < numScriptValWorkers; i++ {
+		go scriptValWorker()
+	}
 }
 
-// sendResult sends the result of a script pair validation on the internal
-// result channel while respecting the quit channel.  This allows orderly
-// shutdown when the validation process is aborted early due to a validation
-// error in one of the other goroutines.
-func (v *txValidator) sendResult(result error) {
-	select {
-	case v.resultChan <- result:
-	case <-v.quitChan:
+// scriptValWorker helps validate the jobs it is handed until the process
+// exits.  It must be run as a goroutine.
+func scriptValWorker() {
+	for job := range scriptValJobs {
+		job.run()
+		job.wg.Done()
 	}
 }
 
-// validateHandler consumes items to validate from the internal validate channel
-// and returns the result of the validation on the internal result channel. It
-// must be run as a goroutine.
-func (v *txValidator) validateHandler() {
-out:
+// scriptValJob holds the inputs of a single validation along with the state
+// shared by the goroutines working on it.  Goroutines claim batches of inputs
+// by advancing the next index, so idle goroutines take over the remaining
+// inputs from busy ones and validation stops early once any input fails.
+type scriptValJob struct {
+	next      int64 // atomic, must be 64-bit aligned
+	failed    int32 // atomic
+	items     []*txValidateItem
+	batchSize int
+	utxoView  *UtxoViewpoint
+	flags     txscript.ScriptFlags
+	sigCache  *txscript.SigCache
+	errOnce   sync.Once
+	err       error
+	wg        sync.WaitGroup
+}
+
+// run validates batches of the inputs of the job until all of them are claimed
+// or an input fails.
+func (j *scriptValJob) run() {
+	numItems := int64(len(j.items))
 	for {
-		select {
-		case txVI := <-v.validateChan:
-			// Ensure the referenced input utxo is available.
-			txIn := txVI.txIn
-			utxo := v.utxoView.LookupEntry(txIn.PreviousOutPoint)
-			if utxo == nil {
-				str := fmt.Sprintf("unable to find unspent "+
-					"output %v referenced from "+
-					"transaction %s:%d",
-					txIn.PreviousOutPoint, txVI.tx.Hash(),
-					txVI.txInIndex)
-				err := ruleError(ErrMissingTxOut, str)
-				v.sendResult(err)
-				break out
+		end := atomic.AddInt64(&j.next, int64(j.batchSize))
+		start := end - int64(j.batchSize)
+		if start >= numItems {
+			return
+		}
+		if end > numItems {
+			end = numItems
+		}
+
+		for _, item := range j.items[start:end] {
+			if atomic.LoadInt32(&j.failed) != 0 {
+				return
 			}
 
-			// Create a new script engine for the script pair.
-			sigScript := txIn.SignatureScript
-			witness := txIn.Witness
-			pkScript := utxo.PkScript()
-			inputAmount := utxo.Amount()
-			vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
-				txVI.txInIndex, v.flags, v.sigCache, txVI.sigHashes,
-				inputAmount)
+			err := validateInput(item, j.utxoView, j.flags, j.sigCache)
 			if err != nil {
-				str := fmt.Sprintf("failed to parse input "+
-					"%s:%d which references output %v - "+
-					"%v (input witness %x, input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOutPoint, err, witness,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptMalformed, str)
-				v.sendResult(err)
-				break out
+				j.errOnce.Do(func() {
+					j.err = err
+					atomic.StoreInt32(&j.failed, 1)
+				})
+				return
 			}
+		}
+	}
+}
 
-			// Execute the script pair.
-			if err := vm.Execute(); err != nil {
-				str := fmt.Sprintf("failed to validate input "+
-					"%s:%d which references output %v - "+
-					"%v (input witness %x, input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOutPoint, err, witness,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptValidation, str)
-				v.sendResult(err)
-				break out
-			}
+// validateInput validates the script of a single transaction input.
+func validateInput(txVI *txValidateItem, utxoView *UtxoViewpoint,
+	flags txscript.ScriptFlags, sigCache *txscript.SigCache) error {
+
+	// Ensure the referenced input utxo is available.
+	txIn := txVI.txIn
+	utxo := utxoView.LookupEntry(txIn.PreviousOutPoint)
+	if utxo == nil {
+		str := fmt.Sprintf("unable to find unspent output %v "+
+			"referenced from transaction %s:%d",
+			txIn.PreviousOutPoint, txVI.tx.Hash(), txVI.txInIndex)
+		return ruleError(ErrMissingTxOut, str)
+	}
 
-			// Validation succeeded.
-			v.sendResult(nil)
+	// Create a new script engine for the script pair.
+	sigScript := txIn.SignatureScript
+	witness := txIn.Witness
+	pkScript := utxo.PkScript()
+	inputAmount := utxo.Amount()
+	vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
+		txVI.txInIndex, flags, sigCache, txVI.sigHashes, inputAmount)
+	if err != nil {
+		str := fmt.Sprintf("failed to parse input "+
+			"%s:%d which references output %v - "+
+			"%v (input witness %x, input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOutPoint, err, witness,
+			sigScript, pkScript)
+		return ruleError(ErrScriptMalformed, str)
+	}
 
-		case <-v.quitChan:
-			break out
-		}
+	// Execute the script pair.
+	if err := vm.Execute(); err != nil {
+		str := fmt.Sprintf("failed to validate input "+
+			"%s:%d which references output %v - "+
+			"%v (input witness %x, input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOutPoint, err, witness,
+			sigScript, pkScript)
+		return ruleError(ErrScriptValidation, str)
 	}
+
+	return nil
 }
 
-// Validate validates the scripts for all of the passed transaction inputs using
-// multiple goroutines.
-func (v *txValidator) Validate(items []*txValidateItem) error {
+// validateItems validates the scripts for all of the passed transaction inputs
+// using the script validation workers.  The calling goroutine validates inputs
+// as well, so validation makes progress even when all of the workers are busy
+// with other validations.
+func validateItems(items []*txValidateItem, utxoView *UtxoViewpoint,
+	flags txscript.ScriptFlags, sigCache *txscript.SigCache) error {
+
 	if len(items) == 0 {
 		return nil
 	}
 
-	// Limit the number of goroutines to do script validation based on the
-	// number of processor cores.  This helps ensure the system stays
-	// reasonably responsive under heavy load.
-	maxGoRoutines := runtime.NumCPU() * 3
-	if maxGoRoutines <= 0 {
-		maxGoRoutines = 1
+	scriptValPoolOnce.Do(startScriptValWorkers)
+
+	// Size the batches so each goroutine claims several of them, which
+	// evens out the work when the cost of the inputs varies.
+	batchSize := len(items) / (numScriptValWorkers * 4)
+	if batchSize < 1 {
+		batchSize = 1
 	}
-	if maxGoRoutines > len(items) {
-		maxGoRoutines = len(items)
+	if batchSize > maxScriptValBatch {
+		batchSize = maxScriptValBatch
 	}
-
-	// Start up validation handlers that are used to asynchronously
-	// validate each transaction input.
-	for i := 0; i < maxGoRoutines; i++ {
-		go v.validateHandler()
+	job := &scriptValJob{
+		items:     items,
+		batchSize: batchSize,
+		utxoView:  utxoView,
+		flags:     flags,
+		sigCache:  sigCache,
 	}
 
-	// Validate each of the inputs.  The quit channel is closed when any
-	// errors occur so all processing goroutines exit regardless of which
-	// input had the validation error.
-	numInputs := len(items)
-	currentItem := 0
-	processedItems := 0
-	for processedItems < numInputs {
-		// Only send items while there are still items that need to
-		// be processed.  The select statement will never select a nil
-		// channel.
-		var validateChan chan *txValidateItem
-		var item *txValidateItem
-		if currentItem < numInputs {
-			validateChan = v.validateChan
-			item = items[currentItem]
-		}
-
+	// Hand the job to as many idle workers as there are batches beyond the
+	// one the calling goroutine starts with.  Workers busy with other jobs
+	// are not waited for.
+	numBatches := (len(items) + batchSize - 1) / batchSize
+	helpers := numBatches - 1
+	if helpers > numScriptValWorkers {
+		helpers = numScriptValWorkers
+	}
+out:
+	for i := 0; i < helpers; i++ {
+		job.wg.Add(1)
 		select {
-		case validateChan <- item:
-			currentItem++
-
-		case err := <-v.resultChan:
-			processedItems++
-			if err != nil {
-				close(v.quitChan)
-				return err
-			}
+		case scriptValJobs <- job:
+		default:
+			job.wg.Done()
+			break out
 		}
 	}
 
-	close(v.quitChan)
-	return nil
-}
-
-// newTxValidator returns a new instance of txValidator to be used for
-// validating transaction scripts asynchronously.
-func newTxValidator(utxoView *UtxoViewpoint, flags txscript.ScriptFlags,
-	sigCache *txscript.SigCache, hashCache *txscript.HashCache) *txValidator {
-	return &txValidator{
-		validateChan: make(chan *txValidateItem),
-		quitChan:     make(chan struct{}),
-		resultChan:   make(chan error),
-		utxoView:     utxoView,
-		sigCache:     sigCache,
-		hashCache:    hashCache,
-		flags:        flags,
-	}
+	job.run()
+	job.wg.Wait()
+	return job.err
 }
 
 // ValidateTransactionScripts validates the scripts for the passed transaction
-// using multiple goroutines.
+// using the script validation workers.
 func ValidateTransactionScripts(tx *ulordutil.Tx, utxoView *UtxoViewpoint,
 	flags txscript.ScriptFlags, sigCache *txscript.SigCache,
 	hashCache *txscript.HashCache) error {
@@ -234,12 +265,11 @@ func ValidateTransactionScripts(tx *ulordutil.Tx, utxoView *UtxoViewpoint,
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, flags, sigCache, hashCache)
-	return validator.Validate(txValItems)
+	return validateItems(txValItems, utxoView, flags, sigCache)
 }
 
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
+// the passed block using the script validation workers.
 func checkBlockScripts(block *ulordutil.Block, utxoView *UtxoViewpoint,
 	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
 	hashCache *txscript.HashCache) error {
@@ -295,9 +325,9 @@ func checkBlockScripts(block *ulordutil.Block, utxoView *UtxoViewpoint,
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, scriptFlags, sigCache, hashCache)
 	start := time.Now()
-	if err := validator.Validate(txValItems); err != nil {
+	err := validateItems(txValItems, utxoView, scriptFlags, sigCache)
+	if err != nil {
 		return err
 	}
 	elapsed := time.Since(start)
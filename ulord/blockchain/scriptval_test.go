@@ -7,9 +7,11 @@ package blockchain
 import (
 	"fmt"
 	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulordutil"
 )
 
 // TestCheckBlockScripts ensures that validating the all of the scripts in a
@@ -47,3 +49,61 @@ func TestCheckBlockScripts(t *testing.T) {
 		return
 	}
 }
+
+// TestCheckBlockScriptsConcurrent ensures concurrent validations sharing the
+// script validation workers succeed for a known-good block and that a block
+// spending unknown outputs is rejected.
+func TestCheckBlockScriptsConcurrent(t *testing.T) {
+	testBlockNum := 277647
+	blocks, err := loadBlocks(fmt.Sprintf("%d.dat.bz2", testBlockNum))
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	view, err := loadUtxoView(fmt.Sprintf("%d.utxostore.bz2", testBlockNum))
+	if err != nil {
+		t.Fatalf("Error loading txstore: %v", err)
+	}
+
+	// Each validation gets its own copy of the block since blocks cache
+	// their hashes without synchronization.
+	blockBytes, err := blocks[0].Bytes()
+	if err != nil {
+		t.Fatalf("Error serializing block: %v", err)
+	}
+
+	const numValidations = 4
+	scriptFlags := txscript.ScriptBip16
+	sigCache := txscript.NewSigCache(1000)
+	errs := make(chan error, numValidations)
+	var wg sync.WaitGroup
+	for i := 0; i < numValidations; i++ {
+		block, err := ulordutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			t.Fatalf("Error deserializing block: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- checkBlockScripts(block, view, scriptFlags,
+				sigCache, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Transaction script validation failed: %v", err)
+		}
+	}
+
+	// Validating against an empty view must fail since none of the spent
+	// outputs are available.
+	err = checkBlockScripts(blocks[0], NewUtxoViewpoint(), scriptFlags,
+		nil, nil)
+	rerr, ok := err.(RuleError)
+	if !ok || rerr.ErrorCode != ErrMissingTxOut {
+		t.Fatalf("checkBlockScripts: unexpected error %v, want %v", err,
+			ErrMissingTxOut)
+	}
+}
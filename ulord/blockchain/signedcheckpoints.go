@@ -0,0 +1,200 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// signedCheckpointFile is the JSON encoding of a file of checkpoints signed by
+// one of the keys trusted to ship checkpoints.  The signature is a hex-encoded
+// DER signature of the hash returned by checkpointsSigHash.
+type signedCheckpointFile struct {
+	Checkpoints []signedCheckpoint `json:"checkpoints"`
+	Signature   string             `json:"signature"`
+}
+
+// signedCheckpoint is the JSON encoding of a checkpoint in a signed file.
+type signedCheckpoint struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// checkpointsSigHash returns the hash signed by the signature of a file of
+// checkpoints for the passed network.  It is the double sha256 of the magic of
+// the network, as a little-endian uint32, followed by the height, as a
+// little-endian uint32, and the hash of each checkpoint in order.  Committing
+// to the network prevents a file signed for one network from being loaded on
+// another network which trusts the same key.
+func checkpointsSigHash(net wire.BitcoinNet, checkpoints []chaincfg.Checkpoint) chainhash.Hash {
+	buf := make([]byte, 4, 4+len(checkpoints)*(4+chainhash.HashSize))
+	binary.LittleEndian.PutUint32(buf, uint32(net))
+	for _, checkpoint := range checkpoints {
+		var height [4]byte
+		binary.LittleEndian.PutUint32(height[:], uint32(checkpoint.Height))
+		buf = append(buf, height[:]...)
+		buf = append(buf, checkpoint.Hash[:]...)
+	}
+	return chainhash.DoubleHashH(buf)
+}
+
+// SignCheckpoints returns the contents of a file of the passed checkpoints for
+// the passed network signed with the private key, which can be loaded by
+// ParseSignedCheckpoints on nodes of the network which trust the corresponding
+// public key.
+func SignCheckpoints(net wire.BitcoinNet, checkpoints []chaincfg.Checkpoint, key *ulordec.PrivateKey) ([]byte, error) {
+	sigHash := checkpointsSigHash(net, checkpoints)
+	sig, err := key.Sign(sigHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	file := signedCheckpointFile{
+		Checkpoints: make([]signedCheckpoint, 0, len(checkpoints)),
+		Signature:   hex.EncodeToString(sig.Serialize()),
+	}
+	for _, checkpoint := range checkpoints {
+		file.Checkpoints = append(file.Checkpoints, signedCheckpoint{
+			Height: checkpoint.Height,
+			Hash:   checkpoint.Hash.String(),
+		})
+	}
+	return json.MarshalIndent(&file, "", "  ")
+}
+
+// ParseSignedCheckpoints parses the contents of a file of checkpoints created
+// by SignCheckpoints and returns the checkpoints when the file is signed for
+// the passed network by one of the trusted public keys.
+func ParseSignedCheckpoints(net wire.BitcoinNet, data []byte, keys []*ulordec.PublicKey) ([]chaincfg.Checkpoint, error) {
+	var file signedCheckpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("malformed checkpoint file: %v", err)
+	}
+
+	checkpoints := make([]chaincfg.Checkpoint, 0, len(file.Checkpoints))
+	heights := make(map[int32]struct{}, len(file.Checkpoints))
+	for _, checkpoint := range file.Checkpoints {
+		hash, err := chainhash.NewHashFromStr(checkpoint.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("malformed checkpoint hash %q: %v",
+				checkpoint.Hash, err)
+		}
+		if checkpoint.Height <= 0 {
+			return nil, fmt.Errorf("invalid checkpoint height %d",
+				checkpoint.Height)
+		}
+		if _, ok := heights[checkpoint.Height]; ok {
+			return nil, fmt.Errorf("duplicate checkpoint at height %d",
+				checkpoint.Height)
+		}
+		heights[checkpoint.Height] = struct{}{}
+		checkpoints = append(checkpoints, chaincfg.Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   hash,
+		})
+	}
+
+	sigBytes, err := hex.DecodeString(file.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("malformed checkpoint file signature: %v",
+			err)
+	}
+	sig, err := ulordec.ParseDERSignature(sigBytes, ulordec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("malformed checkpoint file signature: %v",
+			err)
+	}
+	sigHash := checkpointsSigHash(net, checkpoints)
+	for _, key := range keys {
+		if sig.Verify(sigHash[:], key) {
+			return checkpoints, nil
+		}
+	}
+	return nil, errors.New("checkpoint file is not signed by a trusted key")
+}
+
+// AddCheckpoints adds the passed checkpoints to the checkpoints of the chain.
+// Checkpoints which are already known are ignored.  An error is returned
+// without adding any of the checkpoints when two of them have the same height
+// or one of them conflicts with a known checkpoint or with a block in the main
+// chain.  Blocks in the main chain
+// which conflict must be invalidated with InvalidateBlock first.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AddCheckpoints(checkpoints []chaincfg.Checkpoint) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	b.checkpointsLock.Lock()
+	defer b.checkpointsLock.Unlock()
+
+	var added []chaincfg.Checkpoint
+	heights := make(map[int32]struct{}, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Height <= 0 || checkpoint.Hash == nil {
+			return fmt.Errorf("invalid checkpoint at height %d",
+				checkpoint.Height)
+		}
+		if _, ok := heights[checkpoint.Height]; ok {
+			return fmt.Errorf("duplicate checkpoint at height %d",
+				checkpoint.Height)
+		}
+		heights[checkpoint.Height] = struct{}{}
+		if known, ok := b.checkpointsByHeight[checkpoint.Height]; ok {
+			if !known.Hash.IsEqual(checkpoint.Hash) {
+				return fmt.Errorf("checkpoint %v at height %d "+
+					"conflicts with known checkpoint %v",
+					checkpoint.Hash, checkpoint.Height, known.Hash)
+			}
+			continue
+		}
+		node := b.bestChain.NodeByHeight(checkpoint.Height)
+		if node != nil && node.hash != *checkpoint.Hash {
+			return fmt.Errorf("checkpoint %v at height %d conflicts "+
+				"with main chain block %v", checkpoint.Hash,
+				checkpoint.Height, node.hash)
+		}
+		added = append(added, checkpoint)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	// Replace the checkpoints rather than modifying them since callers may
+	// hold on to the previously returned slice.
+	merged := make([]chaincfg.Checkpoint, 0, len(b.checkpoints)+len(added))
+	merged = append(merged, b.checkpoints...)
+	merged = append(merged, added...)
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Height < merged[j].Height
+	})
+	byHeight := make(map[int32]*chaincfg.Checkpoint, len(merged))
+	for i := range merged {
+		byHeight[merged[i].Height] = &merged[i]
+	}
+	b.checkpoints = merged
+	b.checkpointsByHeight = byHeight
+
+	// Search for the latest known checkpoint again the next time it is
+	// needed.
+	b.checkpointNode = nil
+	b.nextCheckpoint = nil
+
+	for _, checkpoint := range added {
+		log.Infof("Added checkpoint at height %d/block %s",
+			checkpoint.Height, checkpoint.Hash)
+	}
+	return nil
+}
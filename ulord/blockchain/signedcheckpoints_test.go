@@ -0,0 +1,148 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// TestSignedCheckpoints ensures files of checkpoints are only loaded when they
+// are signed by a trusted key for the network and were not modified.
+func TestSignedCheckpoints(t *testing.T) {
+	key, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherKey, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+
+	checkpoints := []chaincfg.Checkpoint{
+		{Height: 10, Hash: &chainhash.Hash{0x01}},
+		{Height: 20, Hash: &chainhash.Hash{0x02}},
+	}
+	data, err := SignCheckpoints(wire.MainNet, checkpoints, key)
+	if err != nil {
+		t.Fatalf("SignCheckpoints: unexpected error: %v", err)
+	}
+
+	trusted := []*ulordec.PublicKey{otherKey.PubKey(), key.PubKey()}
+	loaded, err := ParseSignedCheckpoints(wire.MainNet, data, trusted)
+	if err != nil {
+		t.Fatalf("ParseSignedCheckpoints: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, checkpoints) {
+		t.Fatalf("ParseSignedCheckpoints: got %v, want %v", loaded,
+			checkpoints)
+	}
+
+	// The file must be rejected when it is not signed by a trusted key.
+	untrusted := []*ulordec.PublicKey{otherKey.PubKey()}
+	if _, err := ParseSignedCheckpoints(wire.MainNet, data, untrusted); err == nil {
+		t.Fatal("ParseSignedCheckpoints: expected error for untrusted key")
+	}
+
+	// The file must be rejected when a checkpoint was modified.
+	tampered := bytes.Replace(data, []byte(`"height": 20`),
+		[]byte(`"height": 21`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatal("unable to modify the signed checkpoint file")
+	}
+	if _, err := ParseSignedCheckpoints(wire.MainNet, tampered, trusted); err == nil {
+		t.Fatal("ParseSignedCheckpoints: expected error for modified file")
+	}
+
+	// The file must be rejected on other networks.
+	if _, err := ParseSignedCheckpoints(wire.TestNet3, data, trusted); err == nil {
+		t.Fatal("ParseSignedCheckpoints: expected error for other network")
+	}
+
+	// The file must be rejected when it contains a height twice, even when
+	// it is signed.
+	duplicates := []chaincfg.Checkpoint{
+		{Height: 10, Hash: &chainhash.Hash{0x01}},
+		{Height: 10, Hash: &chainhash.Hash{0x02}},
+	}
+	data, err = SignCheckpoints(wire.MainNet, duplicates, key)
+	if err != nil {
+		t.Fatalf("SignCheckpoints: unexpected error: %v", err)
+	}
+	if _, err := ParseSignedCheckpoints(wire.MainNet, data, trusted); err == nil {
+		t.Fatal("ParseSignedCheckpoints: expected error for duplicate " +
+			"height")
+	}
+
+	if _, err := ParseSignedCheckpoints(wire.MainNet, []byte("{"), trusted); err == nil {
+		t.Fatal("ParseSignedCheckpoints: expected error for malformed file")
+	}
+}
+
+// TestAddCheckpoints ensures checkpoints can only be added when they do not
+// conflict with the known checkpoints and the main chain.
+func TestAddCheckpoints(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+	nodes := chainedNodes(chain.bestChain.Genesis(), 10)
+	chain.bestChain.SetTip(tstTip(nodes))
+
+	known := chaincfg.Checkpoint{Height: 5, Hash: &nodes[4].hash}
+	if err := chain.AddCheckpoints([]chaincfg.Checkpoint{known}); err != nil {
+		t.Fatalf("AddCheckpoints: unexpected error: %v", err)
+	}
+	if cp := chain.LatestCheckpoint(); cp == nil || *cp != known {
+		t.Fatalf("LatestCheckpoint: got %v, want %v", cp, known)
+	}
+
+	// A checkpoint above the tip is accepted and a known checkpoint is
+	// ignored.
+	ahead := chaincfg.Checkpoint{Height: 20, Hash: &chainhash.Hash{0x01}}
+	err := chain.AddCheckpoints([]chaincfg.Checkpoint{ahead, known})
+	if err != nil {
+		t.Fatalf("AddCheckpoints: unexpected error: %v", err)
+	}
+	want := []chaincfg.Checkpoint{known, ahead}
+	if got := chain.Checkpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Checkpoints: got %v, want %v", got, want)
+	}
+
+	// Checkpoints conflicting with a known checkpoint or the main chain are
+	// rejected without adding any of the passed checkpoints.
+	conflicts := []chaincfg.Checkpoint{
+		{Height: 5, Hash: &nodes[5].hash},
+		{Height: 8, Hash: &chainhash.Hash{0x02}},
+	}
+	for _, conflict := range conflicts {
+		other := chaincfg.Checkpoint{Height: 30, Hash: &chainhash.Hash{0x03}}
+		err := chain.AddCheckpoints([]chaincfg.Checkpoint{other, conflict})
+		if err == nil {
+			t.Fatalf("AddCheckpoints: expected error for conflicting "+
+				"checkpoint at height %d", conflict.Height)
+		}
+	}
+	if got := chain.Checkpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Checkpoints: got %v, want %v", got, want)
+	}
+
+	// Checkpoints with the same height in one batch are rejected, even
+	// when they don't conflict with the known checkpoints.
+	duplicates := []chaincfg.Checkpoint{
+		{Height: 30, Hash: &chainhash.Hash{0x03}},
+		{Height: 30, Hash: &chainhash.Hash{0x04}},
+	}
+	if err := chain.AddCheckpoints(duplicates); err == nil {
+		t.Fatal("AddCheckpoints: expected error for duplicate height")
+	}
+	if got := chain.Checkpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Checkpoints: got %v, want %v", got, want)
+	}
+}
@@ -271,6 +271,45 @@ func (b *BlockChain) ThresholdState(deploymentID uint32) (ThresholdState, error)
 	return state, err
 }
 
+// ThresholdStateSince returns the current rule change threshold state of the
+// given deployment ID for the block AFTER the end of the current best chain
+// along with the height of the first block which has that state.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ThresholdStateSince(deploymentID uint32) (ThresholdState, int32, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	tip := b.bestChain.Tip()
+	state, err := b.deploymentState(tip, deploymentID)
+	if err != nil {
+		return ThresholdFailed, 0, err
+	}
+
+	// The state only changes at the end of each confirmation window, so go
+	// back one window at a time until the state of the previous window
+	// differs.
+	deployment := &b.chainParams.Deployments[deploymentID]
+	checker := deploymentChecker{deployment: deployment, chain: b}
+	window := int32(checker.MinerConfirmationWindow())
+	if tip.height+1 < window {
+		return state, 0, nil
+	}
+	node := tip.Ancestor(tip.height - (tip.height+1)%window)
+	for node != nil {
+		prevNode := node.RelativeAncestor(window)
+		prevState, err := b.deploymentState(prevNode, deploymentID)
+		if err != nil {
+			return ThresholdFailed, 0, err
+		}
+		if prevState != state {
+			return state, node.height + 1, nil
+		}
+		node = prevNode
+	}
+	return state, 0, nil
+}
+
 // IsDeploymentActive returns true if the target deploymentID is active, and
 // false otherwise.
 //
@@ -297,7 +336,7 @@ func (b *BlockChain) IsDeploymentActive(deploymentID uint32) (bool, error) {
 //
 // This function MUST be called with the chain state lock held (for writes).
 func (b *BlockChain) deploymentState(prevNode *blockNode, deploymentID uint32) (ThresholdState, error) {
-	if deploymentID > uint32(len(b.chainParams.Deployments)) {
+	if deploymentID >= uint32(len(b.chainParams.Deployments)) {
 		return ThresholdFailed, DeploymentError(deploymentID)
 	}
 
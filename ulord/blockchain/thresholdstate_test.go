@@ -5,8 +5,11 @@
 package blockchain
 
 import (
+	"math"
 	"testing"
+	"time"
 
+	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
 
@@ -132,3 +135,64 @@ nextTest:
 		}
 	}
 }
+
+// TestThresholdStateSince ensures the state of a deployment which defines its
+// own confirmation window and activation threshold progresses through the
+// states as miners vote for it and reports the height each state was reached.
+func TestThresholdStateSince(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.Deployments[chaincfg.DeploymentTestDummy] = chaincfg.ConsensusDeployment{
+		BitNumber:                     28,
+		StartTime:                     0,
+		ExpireTime:                    math.MaxInt64,
+		MinerConfirmationWindow:       10,
+		RuleChangeActivationThreshold: 8,
+	}
+	chain := newFakeChain(&params)
+
+	// Create enough blocks voting for the deployment to activate it.
+	node := chain.bestChain.Tip()
+	blockTime := time.Unix(node.timestamp, 0)
+	var nodes []*blockNode
+	for i := 0; i < 40; i++ {
+		blockTime = blockTime.Add(time.Second)
+		node = newFakeNode(node, vbTopBits|1<<28, node.bits, blockTime)
+		chain.index.AddNode(node)
+		nodes = append(nodes, node)
+	}
+
+	tests := []struct {
+		height    int32
+		wantState ThresholdState
+		wantSince int32
+	}{
+		{height: 5, wantState: ThresholdDefined, wantSince: 0},
+		{height: 8, wantState: ThresholdDefined, wantSince: 0},
+		{height: 9, wantState: ThresholdStarted, wantSince: 10},
+		{height: 15, wantState: ThresholdStarted, wantSince: 10},
+		{height: 19, wantState: ThresholdLockedIn, wantSince: 20},
+		{height: 25, wantState: ThresholdLockedIn, wantSince: 20},
+		{height: 29, wantState: ThresholdActive, wantSince: 30},
+		{height: 40, wantState: ThresholdActive, wantSince: 30},
+	}
+	for _, test := range tests {
+		chain.bestChain.SetTip(nodes[test.height-1])
+		state, since, err := chain.ThresholdStateSince(
+			chaincfg.DeploymentTestDummy)
+		if err != nil {
+			t.Fatalf("height %d: unexpected error: %v", test.height,
+				err)
+		}
+		if state != test.wantState || since != test.wantSince {
+			t.Fatalf("height %d: got state %v since %d, want state "+
+				"%v since %d", test.height, state, since,
+				test.wantState, test.wantSince)
+		}
+	}
+
+	// Unknown deployments must be rejected.
+	_, _, err := chain.ThresholdStateSince(chaincfg.DefinedDeployments)
+	if _, ok := err.(DeploymentError); !ok {
+		t.Fatalf("unexpected error for unknown deployment: %v", err)
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// DefaultUtxoCacheMaxSize is the default maximum size in bytes of the
+	// utxo cache.
+	DefaultUtxoCacheMaxSize = 250 * 1024 * 1024
+
+	// utxoFlushPeriodicInterval is the maximum amount of time the changes
+	// to the utxo set are kept in the cache before they are written to the
+	// database while connecting blocks.
+	utxoFlushPeriodicInterval = 5 * time.Minute
+
+	// utxoFlushBlockInterval is the maximum number of blocks which are
+	// connected before the changes to the utxo set are written to the
+	// database.  It bounds the number of blocks which have to be connected
+	// again to recover the utxo set after an unclean shutdown.
+	utxoFlushBlockInterval = 2000
+
+	// utxoCacheEntryOverhead is the approximate number of bytes used by an
+	// entry in the utxo cache besides its public key script.  It accounts
+	// for the outpoint and pointer stored in the map along with the map
+	// overhead and the entry itself.
+	utxoCacheEntryOverhead = 104
+)
+
+// FlushMode is used to indicate the different urgency levels for flushing the
+// utxo cache to the database.
+type FlushMode uint8
+
+const (
+	// FlushRequired writes the changes in the cache to the database.
+	FlushRequired FlushMode = iota
+
+	// FlushPeriodic writes the changes in the cache to the database when
+	// the cache is full or when too much time passed or too many blocks
+	// were connected since the last flush.
+	FlushPeriodic
+)
+
+// isFresh returns whether or not the output is known not to be in the
+// database.
+func (entry *UtxoEntry) isFresh() bool {
+	return entry.packedFlags&tfFresh == tfFresh
+}
+
+// cachedEntrySize returns the approximate number of bytes used by the passed
+// entry in the utxo cache.
+func cachedEntrySize(entry *UtxoEntry) uint64 {
+	return utxoCacheEntryOverhead + uint64(cap(entry.pkScript))
+}
+
+// utxoCache is a write-back cache in front of the utxo set in the database.
+// Connecting a block only updates the cache, and the changes are written to
+// the database in batches when the cache is flushed, which avoids writing
+// outputs which are created and spent between flushes altogether.
+//
+// The utxo set in the database is consistent with the block recorded with it
+// in the database, which is always an ancestor of the best chain tip.  After an
+// unclean shutdown, the blocks connected after that block are connected again
+// to recover the utxo set.
+//
+// Entries in the cache are either clean copies of entries in the database, or
+// modified entries which have to be written to the database.  Spent modified
+// entries record outputs which have to be removed from the database.
+type utxoCache struct {
+	db      database.DB
+	maxSize uint64
+
+	// bucketName is the name of the db bucket holding the utxo set and
+	// consistencyKeyName is the name of the db key used to store the hash
+	// of the block it is consistent with.
+	bucketName         []byte
+	consistencyKeyName []byte
+
+	mtx     sync.Mutex
+	entries map[wire.OutPoint]*UtxoEntry
+	size    uint64
+	dirty   bool
+
+	lastFlushHash   chainhash.Hash
+	lastFlushHeight int32
+	lastFlushTime   time.Time
+}
+
+// newUtxoCache returns a new utxo cache for the utxo set in the passed
+// database which uses about maxSize bytes of memory.
+func newUtxoCache(db database.DB, maxSize uint64) *utxoCache {
+	return newUtxoCacheForBucket(db, maxSize, utxoSetBucketName,
+		utxoStateConsistencyKeyName)
+}
+
+// newUtxoCacheForBucket returns a new utxo cache which uses about maxSize
+// bytes of memory for the utxo set in the passed database bucket.  The hash of
+// the block the utxo set is consistent with is stored under the passed key.
+func newUtxoCacheForBucket(db database.DB, maxSize uint64, bucketName, consistencyKeyName []byte) *utxoCache {
+	return &utxoCache{
+		db:                 db,
+		maxSize:            maxSize,
+		bucketName:         bucketName,
+		consistencyKeyName: consistencyKeyName,
+		entries:            make(map[wire.OutPoint]*UtxoEntry),
+		lastFlushTime:      time.Now(),
+	}
+}
+
+// fetchEntries loads the unspent transaction outputs for the passed outpoints
+// into the view, reading the outputs which aren't cached from the database.
+// Spent outputs, or those which otherwise don't exist, result in a nil entry in
+// the view.
+//
+// The view receives copies of the entries, so it can be modified freely.
+func (c *utxoCache) fetchEntries(view *UtxoViewpoint, outpoints map[wire.OutPoint]struct{}) error {
+	var missing []wire.OutPoint
+	c.mtx.Lock()
+	for outpoint := range outpoints {
+		entry, ok := c.entries[outpoint]
+		if !ok {
+			missing = append(missing, outpoint)
+			continue
+		}
+		if entry.IsSpent() {
+			view.entries[outpoint] = nil
+			continue
+		}
+
+		entry = entry.Clone()
+		entry.packedFlags &^= tfModified | tfFresh
+		view.entries[outpoint] = entry
+	}
+	c.mtx.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Load the outputs which aren't cached from the database.  The cache
+	// can't change while they are loaded since it is only modified with
+	// the chain lock held for writes.
+	fetched := make([]*UtxoEntry, len(missing))
+	err := c.db.View(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(c.bucketName)
+		for i, outpoint := range missing {
+			entry, err := dbFetchUtxoEntryFromBucket(utxoBucket,
+				outpoint)
+			if err != nil {
+				return err
+			}
+
+			fetched[i] = entry
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cache the loaded outputs while there is room for them since they are
+	// likely to be spent soon.
+	c.mtx.Lock()
+	for i, outpoint := range missing {
+		entry := fetched[i]
+		view.entries[outpoint] = entry
+		if entry == nil || c.size >= c.maxSize {
+			continue
+		}
+		if _, ok := c.entries[outpoint]; ok {
+			continue
+		}
+
+		entry = entry.Clone()
+		c.entries[outpoint] = entry
+		c.size += cachedEntrySize(entry)
+	}
+	c.mtx.Unlock()
+
+	return nil
+}
+
+// commit updates the cache with the entries of the passed view which have been
+// modified by connecting blocks.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) commit(view *UtxoViewpoint) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for outpoint, entry := range view.entries {
+		// Only modified entries change the utxo set.
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+		c.dirty = true
+
+		cached := c.entries[outpoint]
+		if cached != nil {
+			c.size -= cachedEntrySize(cached)
+		}
+
+		// Fresh outputs which are spent are simply forgotten since they
+		// were never written to the database.  Otherwise, the output is
+		// recorded as spent so it is removed from the database.
+		if entry.IsSpent() {
+			if cached != nil && cached.isFresh() {
+				delete(c.entries, outpoint)
+				continue
+			}
+
+			spent := &UtxoEntry{packedFlags: tfSpent | tfModified}
+			c.entries[outpoint] = spent
+			c.size += cachedEntrySize(spent)
+			continue
+		}
+
+		// Outputs created by connecting blocks are not in the database
+		// unless they replace a spent output of a transaction with the
+		// same hash which hasn't been removed from the database yet.
+		//
+		// The public key script is copied so the cache doesn't keep the
+		// transaction which created the output in memory.
+		flags := entry.packedFlags&tfCoinBase | tfModified
+		if cached == nil || cached.isFresh() {
+			flags |= tfFresh
+		}
+		pkScript := make([]byte, len(entry.pkScript))
+		copy(pkScript, entry.pkScript)
+		cached = &UtxoEntry{
+			amount:      entry.amount,
+			pkScript:    pkScript,
+			blockHeight: entry.blockHeight,
+			packedFlags: flags,
+		}
+		c.entries[outpoint] = cached
+		c.size += cachedEntrySize(cached)
+	}
+}
+
+// purge removes the entries of the passed view from the cache.  It is used
+// after the view was written to the database directly so the cache doesn't
+// return stale entries.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) purge(view *UtxoViewpoint) {
+	c.mtx.Lock()
+	for outpoint := range view.entries {
+		if cached, ok := c.entries[outpoint]; ok {
+			c.size -= cachedEntrySize(cached)
+			delete(c.entries, outpoint)
+		}
+	}
+	c.mtx.Unlock()
+}
+
+// flush writes the modified entries of the cache to the database along with
+// the hash of the block the utxo set is consistent with after the write.  The
+// cache is trimmed afterwards when it uses more than its maximum size.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) flush(bestHash *chainhash.Hash, bestHeight int32) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !c.dirty && c.lastFlushHash == *bestHash {
+		c.lastFlushTime = time.Now()
+		return nil
+	}
+
+	start := time.Now()
+	var numWritten int
+	err := c.db.Update(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(c.bucketName)
+		for outpoint, entry := range c.entries {
+			if !entry.isModified() {
+				continue
+			}
+
+			err := dbPutUtxoEntry(utxoBucket, outpoint, entry)
+			if err != nil {
+				return err
+			}
+			numWritten++
+		}
+
+		return dbPutBlockHashKey(dbTx, c.consistencyKeyName, bestHash)
+	})
+	if err != nil {
+		return err
+	}
+
+	// All of the entries are now clean copies of the database, and the
+	// spent ones are gone from it.
+	for outpoint, entry := range c.entries {
+		if entry.IsSpent() {
+			c.size -= cachedEntrySize(entry)
+			delete(c.entries, outpoint)
+			continue
+		}
+
+		entry.packedFlags &^= tfModified | tfFresh
+	}
+	c.dirty = false
+
+	// Evict random entries until the cache is back to three quarters of
+	// its maximum size when it is full, leaving room for the outputs of
+	// the next blocks.  Relying on the random starting point of Go's map
+	// iteration is fine here since the entries are clean.
+	if c.size >= c.maxSize {
+		target := c.maxSize / 4 * 3
+		for outpoint, entry := range c.entries {
+			if c.size <= target {
+				break
+			}
+
+			c.size -= cachedEntrySize(entry)
+			delete(c.entries, outpoint)
+		}
+	}
+
+	log.Debugf("Flushed %d utxo changes for block %v (height %d) in %v",
+		numWritten, bestHash, bestHeight, time.Since(start))
+
+	c.lastFlushHash = *bestHash
+	c.lastFlushHeight = bestHeight
+	c.lastFlushTime = time.Now()
+	return nil
+}
+
+// maybeFlush flushes the cache according to the passed flush mode.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) maybeFlush(bestHash *chainhash.Hash, bestHeight int32, mode FlushMode) error {
+	if mode == FlushPeriodic {
+		c.mtx.Lock()
+		needed := c.size >= c.maxSize ||
+			time.Since(c.lastFlushTime) >= utxoFlushPeriodicInterval ||
+			bestHeight-c.lastFlushHeight >= utxoFlushBlockInterval
+		c.mtx.Unlock()
+		if !needed {
+			return nil
+		}
+	}
+
+	return c.flush(bestHash, bestHeight)
+}
+
+// setConsistentState records the block the utxo set in the database is
+// consistent with without writing to the database.
+func (c *utxoCache) setConsistentState(hash *chainhash.Hash, height int32) {
+	c.mtx.Lock()
+	c.lastFlushHash = *hash
+	c.lastFlushHeight = height
+	c.lastFlushTime = time.Now()
+	c.mtx.Unlock()
+}
+
+// FlushUtxoCache writes the changes to the utxo set which are held in memory
+// to the database according to the passed flush mode.  It should be called
+// with FlushRequired on shutdown, after blocks are no longer processed, so the
+// utxo set doesn't have to be recovered on the next start.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FlushUtxoCache(mode FlushMode) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.snapshotCache != nil {
+		err := b.snapshotCache.maybeFlush(&b.snapshotTip.hash,
+			b.snapshotTip.height, mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	tip := b.bestChain.Tip()
+	return b.utxoCache.maybeFlush(&tip.hash, tip.height, mode)
+}
+
+// initUtxoState ensures the utxo set in the database is consistent with the
+// best chain.  When the process exited without flushing the utxo cache, the
+// blocks connected after the last flush are connected to the utxo set again.
+func (b *BlockChain) initUtxoState(interrupt <-chan struct{}) error {
+	var consistentHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		consistentHash, err = dbFetchUtxoStateConsistency(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Databases which predate the utxo cache always updated the utxo set
+	// along with the best chain state, so they are consistent with the
+	// best chain.
+	tip := b.bestChain.Tip()
+	if consistentHash == nil {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbPutUtxoStateConsistency(dbTx, &tip.hash)
+		})
+		if err != nil {
+			return err
+		}
+		consistentHash = &tip.hash
+	}
+
+	node := b.index.LookupNode(consistentHash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return AssertError(fmt.Sprintf("initUtxoState: utxo set is "+
+			"consistent with block %v which is not in the main chain",
+			consistentHash))
+	}
+	b.utxoCache.setConsistentState(&node.hash, node.height)
+	if node == tip {
+		return nil
+	}
+
+	log.Infof("Recovering the utxo set from height %d to %d", node.height+1,
+		tip.height)
+	for n := b.bestChain.Next(node); n != nil; n = b.bestChain.Next(n) {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		var block *ulordutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, n)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		view := NewUtxoViewpoint()
+		err = view.fetchInputUtxos(b.utxoCache, block)
+		if err != nil {
+			return err
+		}
+		err = view.connectTransactions(block, nil)
+		if err != nil {
+			return err
+		}
+		b.utxoCache.commit(view)
+
+		err = b.utxoCache.maybeFlush(&n.hash, n.height, FlushPeriodic)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.utxoCache.flush(&tip.hash, tip.height)
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// fetchDbUtxoEntry returns the entry for the passed outpoint in the utxo set in
+// the database.
+func fetchDbUtxoEntry(t *testing.T, db database.DB, outpoint wire.OutPoint) *UtxoEntry {
+	var entry *UtxoEntry
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo %v: %v", outpoint, err)
+	}
+	return entry
+}
+
+// fetchDbUtxoStateConsistency returns the hash of the block the utxo set in
+// the database is consistent with.
+func fetchDbUtxoStateConsistency(t *testing.T, db database.DB) *chainhash.Hash {
+	var hash *chainhash.Hash
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, err = dbFetchUtxoStateConsistency(dbTx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo state consistency: %v", err)
+	}
+	return hash
+}
+
+// TestUtxoCacheCommitFlush ensures the utxo cache only writes the changes made
+// by connecting blocks to the database when it is flushed, forgets outputs
+// which are created and spent between flushes, and stays within its maximum
+// size.
+func TestUtxoCacheCommitFlush(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("utxocacheflush",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	cache := newUtxoCache(chain.db, DefaultUtxoCacheMaxSize)
+	txOut := &wire.TxOut{Value: 5000, PkScript: []byte{0x51}}
+	outpoint1 := wire.OutPoint{Hash: chainhash.Hash{0x01}}
+	outpoint2 := wire.OutPoint{Hash: chainhash.Hash{0x02}}
+	blockHash := chainhash.Hash{0x03}
+
+	// Add two outputs.  They must be served by the cache but not be
+	// written to the database yet.
+	view := NewUtxoViewpoint()
+	view.addTxOut(outpoint1, txOut, false, 1)
+	view.addTxOut(outpoint2, txOut, false, 1)
+	cache.commit(view)
+	view = NewUtxoViewpoint()
+	neededSet := map[wire.OutPoint]struct{}{outpoint1: {}, outpoint2: {}}
+	if err := view.fetchUtxosMain(cache, neededSet); err != nil {
+		t.Fatalf("fetchUtxosMain: %v", err)
+	}
+	for outpoint := range neededSet {
+		entry := view.LookupEntry(outpoint)
+		if entry == nil || entry.Amount() != txOut.Value ||
+			entry.isModified() || entry.isFresh() {
+			t.Fatalf("unexpected cached entry for %v: %+v", outpoint,
+				entry)
+		}
+		if fetchDbUtxoEntry(t, chain.db, outpoint) != nil {
+			t.Fatalf("utxo %v written to the database before a "+
+				"flush", outpoint)
+		}
+	}
+
+	// Spend the first output before flushing.  It must be forgotten
+	// rather than recorded as spent.
+	view.LookupEntry(outpoint1).Spend()
+	cache.commit(view)
+	if _, ok := cache.entries[outpoint1]; ok {
+		t.Fatalf("spent fresh utxo %v is still cached", outpoint1)
+	}
+
+	// Flush the cache.  Only the second output must be in the database.
+	if err := cache.flush(&blockHash, 1); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if fetchDbUtxoEntry(t, chain.db, outpoint1) != nil {
+		t.Fatalf("spent utxo %v written to the database", outpoint1)
+	}
+	if fetchDbUtxoEntry(t, chain.db, outpoint2) == nil {
+		t.Fatalf("utxo %v not written to the database", outpoint2)
+	}
+	if hash := fetchDbUtxoStateConsistency(t, chain.db); *hash != blockHash {
+		t.Fatalf("utxo state consistency: got %v, want %v", hash,
+			blockHash)
+	}
+
+	// Spend the flushed output.  It must be recorded as spent in the cache
+	// and removed from the database by the next flush.
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxosMain(cache, neededSet); err != nil {
+		t.Fatalf("fetchUtxosMain: %v", err)
+	}
+	view.LookupEntry(outpoint2).Spend()
+	cache.commit(view)
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxosMain(cache, neededSet); err != nil {
+		t.Fatalf("fetchUtxosMain: %v", err)
+	}
+	if view.LookupEntry(outpoint2) != nil {
+		t.Fatalf("spent utxo %v returned by the cache", outpoint2)
+	}
+	if fetchDbUtxoEntry(t, chain.db, outpoint2) == nil {
+		t.Fatalf("utxo %v removed from the database before a flush",
+			outpoint2)
+	}
+	if err := cache.flush(&blockHash, 1); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if fetchDbUtxoEntry(t, chain.db, outpoint2) != nil {
+		t.Fatalf("spent utxo %v not removed from the database",
+			outpoint2)
+	}
+	if len(cache.entries) != 0 || cache.size != 0 {
+		t.Fatalf("cache not empty after flush: %d entries, %d bytes",
+			len(cache.entries), cache.size)
+	}
+
+	// Fill a small cache beyond its maximum size.  Flushing it must trim
+	// it back under the maximum size.
+	cache = newUtxoCache(chain.db, 20*cachedEntrySize(&UtxoEntry{
+		pkScript: txOut.PkScript,
+	}))
+	view = NewUtxoViewpoint()
+	for i := uint32(0); i < 40; i++ {
+		view.addTxOut(wire.OutPoint{Index: i}, txOut, false, 1)
+	}
+	cache.commit(view)
+	if err := cache.maybeFlush(&blockHash, 2, FlushPeriodic); err != nil {
+		t.Fatalf("maybeFlush: %v", err)
+	}
+	if cache.size > cache.maxSize/4*3 {
+		t.Fatalf("cache uses %d bytes after flush, max %d", cache.size,
+			cache.maxSize)
+	}
+	for i := uint32(0); i < 40; i++ {
+		outpoint := wire.OutPoint{Index: i}
+		if fetchDbUtxoEntry(t, chain.db, outpoint) == nil {
+			t.Fatalf("utxo %v not written to the database", outpoint)
+		}
+	}
+}
+
+// TestUtxoCacheRecovery ensures the utxo set is recovered by connecting the
+// blocks which were connected after the last flush of the utxo cache when the
+// chain is created again without flushing it.
+func TestUtxoCacheRecovery(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxocacherecovery",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+
+	// The utxo set in the database must still be consistent with the
+	// genesis block since the cache was not flushed.
+	genesisHash := chain.chainParams.GenesisHash
+	if hash := fetchDbUtxoStateConsistency(t, chain.db); *hash != *genesisHash {
+		t.Fatalf("utxo state consistency: got %v, want %v", hash,
+			genesisHash)
+	}
+
+	// Record the outputs of the blocks as seen by the chain.
+	want := make(map[wire.OutPoint]*UtxoEntry)
+	for _, block := range blocks[1:] {
+		for _, tx := range block.Transactions() {
+			prevOut := wire.OutPoint{Hash: *tx.Hash()}
+			for txOutIdx := range tx.MsgTx().TxOut {
+				prevOut.Index = uint32(txOutIdx)
+				entry, err := chain.FetchUtxoEntry(prevOut)
+				if err != nil {
+					t.Fatalf("FetchUtxoEntry: %v", err)
+				}
+				want[prevOut] = entry
+			}
+		}
+	}
+
+	// Create the chain again without flushing the cache, which must
+	// recover the utxo set.
+	tip := chain.BestSnapshot()
+	_, err = New(&Config{
+		DB:          chain.db,
+		ChainParams: chain.chainParams,
+		TimeSource:  NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	if hash := fetchDbUtxoStateConsistency(t, chain.db); *hash != tip.Hash {
+		t.Fatalf("utxo state consistency: got %v, want %v", hash,
+			tip.Hash)
+	}
+	for outpoint, wantEntry := range want {
+		entry := fetchDbUtxoEntry(t, chain.db, outpoint)
+		if (entry == nil) != (wantEntry == nil) {
+			t.Fatalf("recovered utxo %v: got %v, want %v", outpoint,
+				entry, wantEntry)
+		}
+		if entry != nil && (entry.Amount() != wantEntry.Amount() ||
+			entry.BlockHeight() != wantEntry.BlockHeight()) {
+			t.Fatalf("recovered utxo %v: got %+v, want %+v",
+				outpoint, entry, wantEntry)
+		}
+	}
+}
@@ -0,0 +1,889 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// -----------------------------------------------------------------------------
+// A utxo set snapshot contains everything needed to bootstrap a node at the
+// block it was taken at without downloading and connecting the blocks before
+// it.
+//
+// The serialized format is:
+//
+//   <magic><version><block hash><height><total txns><num headers><headers>
+//   <block><utxos><terminator>
+//
+//   Field         Type             Size
+//   magic         uint32           4 bytes
+//   version       uint32           4 bytes
+//   block hash    chainhash.Hash   chainhash.HashSize
+//   height        int32            4 bytes
+//   total txns    uint64           8 bytes
+//   num headers   VLQ              variable
+//   headers       []BlockHeader    num headers * 80 bytes
+//   block         MsgBlock         variable
+//   utxos         []utxo           variable
+//   terminator    VLQ              1 byte (always zero)
+//
+// The headers are those of the blocks from height one up to the block before
+// the snapshot block in order, and the block is the snapshot block itself.
+//
+// Each utxo is serialized as its key and value in the utxo set bucket, both
+// prefixed by their length as a VLQ.  The utxos are ordered by key.
+//
+// The commitment of a snapshot is the double sha256 of the block hash, height,
+// and total txns fields followed by the utxos and the terminator.
+// -----------------------------------------------------------------------------
+
+const (
+	// utxoSnapshotMagic identifies a utxo set snapshot.  It is the ascii
+	// encoding of "usnp".
+	utxoSnapshotMagic uint32 = 0x706e7375
+
+	// utxoSnapshotVersion is the current version of the utxo set snapshot
+	// format.
+	utxoSnapshotVersion uint32 = 1
+
+	// maxUtxoKeySize is the maximum size of the key of an entry in the utxo
+	// set bucket.
+	maxUtxoKeySize = chainhash.HashSize + 5
+
+	// snapshotImportBatchSize is the number of utxos written to the
+	// database in each transaction when a snapshot is loaded.
+	snapshotImportBatchSize = 100000
+
+	// maxPendingSnapshotBlocks is the maximum number of blocks before the
+	// snapshot block which are kept in memory until their parents have been
+	// validated.
+	maxPendingSnapshotBlocks = 128
+)
+
+var (
+	// utxoSnapshotStateKeyName is the name of the db key used to store the
+	// utxo set snapshot the chain was bootstrapped from until the blocks
+	// before it have been validated.
+	utxoSnapshotStateKeyName = []byte("utxosnapshotstate")
+
+	// snapshotUtxoSetBucketName is the name of the db bucket used to house
+	// the utxo set which is built by validating the blocks before the
+	// snapshot block.
+	snapshotUtxoSetBucketName = []byte("snapshotutxoset")
+
+	// snapshotUtxoConsistencyKeyName is the name of the db key used to store
+	// the hash of the last validated block before the snapshot block.
+	snapshotUtxoConsistencyKeyName = []byte("snapshotutxoconsistency")
+
+	// errNoSnapshotCommitment is returned when a utxo snapshot is loaded
+	// without a commitment and the chain parameters don't define one.
+	errNoSnapshotCommitment = errors.New("no known commitment for the utxo " +
+		"snapshot")
+)
+
+// UtxoSnapshotInfo describes a utxo set snapshot.
+type UtxoSnapshotInfo struct {
+	// Hash and Height identify the block the snapshot was taken at.
+	Hash   chainhash.Hash
+	Height int32
+
+	// TotalTxns is the total number of transactions in the chain up to
+	// and including the snapshot block.
+	TotalTxns uint64
+
+	// NumUtxos is the number of unspent transaction outputs in the
+	// snapshot.
+	NumUtxos uint64
+
+	// Commitment commits to the utxo set of the snapshot.  Snapshots are
+	// only trusted when their commitment is known in advance.
+	Commitment chainhash.Hash
+}
+
+// serializeUtxoSnapshotState returns the serialization of the passed utxo
+// snapshot along with whether or not the validation of the blocks before it
+// failed.
+func serializeUtxoSnapshotState(info *UtxoSnapshotInfo, failed bool) []byte {
+	serialized := make([]byte, chainhash.HashSize*2+21)
+	offset := copy(serialized, info.Hash[:])
+	byteOrder.PutUint32(serialized[offset:], uint32(info.Height))
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], info.TotalTxns)
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], info.NumUtxos)
+	offset += 8
+	offset += copy(serialized[offset:], info.Commitment[:])
+	if failed {
+		serialized[offset] = 1
+	}
+	return serialized
+}
+
+// deserializeUtxoSnapshotState deserializes the passed utxo snapshot state.
+func deserializeUtxoSnapshotState(serialized []byte) (*UtxoSnapshotInfo, bool, error) {
+	if len(serialized) != chainhash.HashSize*2+21 {
+		return nil, false, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo snapshot state",
+		}
+	}
+
+	var info UtxoSnapshotInfo
+	offset := copy(info.Hash[:], serialized)
+	info.Height = int32(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	info.TotalTxns = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	info.NumUtxos = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	offset += copy(info.Commitment[:], serialized[offset:])
+	return &info, serialized[offset] != 0, nil
+}
+
+// newSnapshotHasher returns a hash for the commitment of a utxo snapshot of the
+// passed block which the utxos still have to be written to.
+func newSnapshotHasher(blockHash *chainhash.Hash, height int32, totalTxns uint64) hash.Hash {
+	var prefix [chainhash.HashSize + 12]byte
+	copy(prefix[:], blockHash[:])
+	byteOrder.PutUint32(prefix[chainhash.HashSize:], uint32(height))
+	byteOrder.PutUint64(prefix[chainhash.HashSize+4:], totalTxns)
+
+	hasher := sha256.New()
+	hasher.Write(prefix[:])
+	return hasher
+}
+
+// snapshotCommitment returns the commitment of a utxo snapshot from the passed
+// hash which all of the utxos have been written to.
+func snapshotCommitment(hasher hash.Hash) chainhash.Hash {
+	return chainhash.Hash(sha256.Sum256(hasher.Sum(nil)))
+}
+
+// writeUtxoSet writes the utxos in the passed utxo set bucket in the format
+// used by utxo set snapshots, followed by the terminator, and returns the
+// number of utxos written.
+func writeUtxoSet(w io.Writer, utxoBucket database.Bucket) (uint64, error) {
+	var numUtxos uint64
+	cursor := utxoBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		if err := wire.WriteVarBytes(w, 0, cursor.Key()); err != nil {
+			return 0, err
+		}
+		if err := wire.WriteVarBytes(w, 0, cursor.Value()); err != nil {
+			return 0, err
+		}
+		numUtxos++
+	}
+
+	return numUtxos, wire.WriteVarInt(w, 0, 0)
+}
+
+// WriteUtxoSnapshot writes a snapshot of the utxo set at the end of the main
+// chain to the passed writer and returns a description of it.  The snapshot
+// can be loaded with LoadUtxoSnapshot to bootstrap a new node.
+//
+// The chain is only locked while the utxo cache is flushed, so blocks can be
+// processed while the snapshot is written.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) WriteUtxoSnapshot(w io.Writer) (*UtxoSnapshotInfo, error) {
+	b.chainLock.Lock()
+	tip := b.bestChain.Tip()
+	if tip.height == 0 {
+		b.chainLock.Unlock()
+		return nil, errors.New("a utxo snapshot can't be taken at the " +
+			"genesis block")
+	}
+
+	// Write the changes to the utxo set held in memory to the database and
+	// start a database transaction which sees the utxo set at the tip even
+	// when more blocks are connected.
+	err := b.utxoCache.flush(&tip.hash, tip.height)
+	if err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	dbTx, err := b.db.Begin(false)
+	if err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	totalTxns := b.BestSnapshot().TotalTxns
+	b.chainLock.Unlock()
+	defer dbTx.Rollback()
+
+	block, err := dbFetchBlockByNode(dbTx, tip)
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(w)
+	var fields [chainhash.HashSize + 20]byte
+	byteOrder.PutUint32(fields[:], utxoSnapshotMagic)
+	byteOrder.PutUint32(fields[4:], utxoSnapshotVersion)
+	copy(fields[8:], tip.hash[:])
+	byteOrder.PutUint32(fields[chainhash.HashSize+8:], uint32(tip.height))
+	byteOrder.PutUint64(fields[chainhash.HashSize+12:], totalTxns)
+	if _, err := bw.Write(fields[:]); err != nil {
+		return nil, err
+	}
+
+	// Write the headers of the blocks before the snapshot block in order.
+	// The nodes never change once they are created, so they can be
+	// accessed without the chain lock.
+	headers := make([]*blockNode, tip.height-1)
+	for node := tip.parent; node.parent != nil; node = node.parent {
+		headers[node.height-1] = node
+	}
+	if err := wire.WriteVarInt(bw, 0, uint64(len(headers))); err != nil {
+		return nil, err
+	}
+	for _, node := range headers {
+		header := node.Header()
+		if err := header.Serialize(bw); err != nil {
+			return nil, err
+		}
+	}
+	if err := block.MsgBlock().Serialize(bw); err != nil {
+		return nil, err
+	}
+
+	hasher := newSnapshotHasher(&tip.hash, tip.height, totalTxns)
+	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	numUtxos, err := writeUtxoSet(io.MultiWriter(bw, hasher), utxoBucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &UtxoSnapshotInfo{
+		Hash:       tip.hash,
+		Height:     tip.height,
+		TotalTxns:  totalTxns,
+		NumUtxos:   numUtxos,
+		Commitment: snapshotCommitment(hasher),
+	}, nil
+}
+
+// resetUtxoSet removes all entries from the utxo set in the database.
+func (b *BlockChain) resetUtxoSet() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if err := meta.DeleteBucket(utxoSetBucketName); err != nil {
+			return err
+		}
+		_, err := meta.CreateBucket(utxoSetBucketName)
+		return err
+	})
+}
+
+// importUtxoSet reads the utxos of a utxo set snapshot from the passed reader
+// into the empty utxo set in the database and returns the number of utxos.
+func (b *BlockChain) importUtxoSet(r io.Reader) (uint64, error) {
+	var numUtxos uint64
+	var lastKey []byte
+	for done := false; !done; {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			for i := 0; i < snapshotImportBatchSize; i++ {
+				key, err := wire.ReadVarBytes(r, 0, maxUtxoKeySize,
+					"utxo key")
+				if err != nil {
+					return err
+				}
+				if len(key) == 0 {
+					done = true
+					return nil
+				}
+				if bytes.Compare(key, lastKey) <= 0 {
+					return fmt.Errorf("utxo %d of the snapshot "+
+						"is out of order", numUtxos)
+				}
+
+				serialized, err := wire.ReadVarBytes(r, 0,
+					wire.MaxBlockPayload, "utxo entry")
+				if err != nil {
+					return err
+				}
+				_, err = deserializeUtxoEntry(serialized)
+				if err != nil {
+					return fmt.Errorf("utxo %d of the snapshot "+
+						"is invalid: %v", numUtxos, err)
+				}
+
+				if err := utxoBucket.Put(key, serialized); err != nil {
+					return err
+				}
+				lastKey = key
+				numUtxos++
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return numUtxos, nil
+}
+
+// LoadUtxoSnapshot bootstraps the chain from a utxo set snapshot written by
+// WriteUtxoSnapshot, which makes the snapshot block the end of the main chain.
+// The chain must not contain any blocks other than the genesis block.
+//
+// The snapshot is only loaded when its commitment matches the passed one, or
+// the one defined for the snapshot block by the chain parameters when it is
+// nil.  The headers in the snapshot are fully validated, however the blocks
+// before the snapshot block are assumed to be valid until they have been
+// downloaded with SnapshotBlocksNeeded and validated by ProcessSnapshotBlock.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LoadUtxoSnapshot(r io.Reader, commitment *chainhash.Hash) (*UtxoSnapshotInfo, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.indexManager != nil {
+		return nil, errors.New("a utxo snapshot can't be loaded with " +
+			"optional indexes enabled")
+	}
+	genesis := b.bestChain.Genesis()
+	if b.bestChain.Tip() != genesis {
+		return nil, errors.New("a utxo snapshot can only be loaded " +
+			"into an empty chain")
+	}
+
+	br := bufio.NewReader(r)
+	var fields [chainhash.HashSize + 20]byte
+	if _, err := io.ReadFull(br, fields[:]); err != nil {
+		return nil, err
+	}
+	if byteOrder.Uint32(fields[:]) != utxoSnapshotMagic {
+		return nil, errors.New("not a utxo snapshot")
+	}
+	if version := byteOrder.Uint32(fields[4:]); version != utxoSnapshotVersion {
+		return nil, fmt.Errorf("unsupported utxo snapshot version %d",
+			version)
+	}
+	info := UtxoSnapshotInfo{
+		Height:    int32(byteOrder.Uint32(fields[chainhash.HashSize+8:])),
+		TotalTxns: byteOrder.Uint64(fields[chainhash.HashSize+12:]),
+	}
+	copy(info.Hash[:], fields[8:])
+	if info.Height <= 0 {
+		return nil, fmt.Errorf("invalid utxo snapshot height %d",
+			info.Height)
+	}
+
+	if commitment == nil {
+		for i := range b.chainParams.UtxoSnapshots {
+			snapshot := &b.chainParams.UtxoSnapshots[i]
+			if *snapshot.Hash == info.Hash {
+				commitment = snapshot.Commitment
+				break
+			}
+		}
+		if commitment == nil {
+			return nil, errNoSnapshotCommitment
+		}
+	}
+
+	// Validate the headers of the blocks before the snapshot block.
+	numHeaders, err := wire.ReadVarInt(br, 0)
+	if err != nil {
+		return nil, err
+	}
+	if numHeaders != uint64(info.Height-1) {
+		return nil, fmt.Errorf("utxo snapshot at height %d contains %d "+
+			"headers", info.Height, numHeaders)
+	}
+	log.Infof("Loading the utxo snapshot at block %v (height %d)",
+		info.Hash, info.Height)
+	nodes := make([]*blockNode, 0, numHeaders+1)
+	prevNode := genesis
+	for i := uint64(0); i < numHeaders; i++ {
+		var header wire.BlockHeader
+		if err := header.Deserialize(br); err != nil {
+			return nil, err
+		}
+		if header.PrevBlock != prevNode.hash {
+			return nil, fmt.Errorf("header at height %d of the "+
+				"utxo snapshot does not connect", prevNode.height+1)
+		}
+		err := checkBlockHeaderSanity(&header, b.chainParams,
+			b.timeSource, BFNone)
+		if err != nil {
+			return nil, err
+		}
+		err = b.checkBlockHeaderContext(&header, prevNode, BFNone)
+		if err != nil {
+			return nil, err
+		}
+
+		node := newBlockNode(&header, prevNode)
+		node.status = statusValid
+		nodes = append(nodes, node)
+		prevNode = node
+	}
+
+	// Validate the snapshot block.
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(br); err != nil {
+		return nil, err
+	}
+	block := ulordutil.NewBlock(&msgBlock)
+	block.SetHeight(info.Height)
+	if *block.Hash() != info.Hash {
+		return nil, fmt.Errorf("utxo snapshot contains block %v "+
+			"instead of %v", block.Hash(), info.Hash)
+	}
+	if msgBlock.Header.PrevBlock != prevNode.hash {
+		return nil, errors.New("block of the utxo snapshot does not " +
+			"connect")
+	}
+	err = checkBlockSanity(block, b.chainParams, b.timeSource,
+		BFNone)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.checkBlockContext(block, prevNode, BFNone); err != nil {
+		return nil, err
+	}
+	tip := newBlockNode(&msgBlock.Header, prevNode)
+	tip.status = statusDataStored | statusValid
+	nodes = append(nodes, tip)
+
+	// Load the utxos.  The utxo set at the genesis block is empty, however
+	// an interrupted attempt to load a snapshot might have left utxos
+	// behind, so remove them first.
+	if err := b.resetUtxoSet(); err != nil {
+		return nil, err
+	}
+	hasher := newSnapshotHasher(&info.Hash, info.Height, info.TotalTxns)
+	info.NumUtxos, err = b.importUtxoSet(io.TeeReader(br, hasher))
+	if err == nil {
+		info.Commitment = snapshotCommitment(hasher)
+		if info.Commitment != *commitment {
+			err = fmt.Errorf("commitment %v of the utxo snapshot "+
+				"does not match the expected %v",
+				info.Commitment, commitment)
+		}
+	}
+	if err != nil {
+		if resetErr := b.resetUtxoSet(); resetErr != nil {
+			log.Errorf("Unable to remove the utxos of the snapshot: %v",
+				resetErr)
+		}
+		return nil, err
+	}
+
+	// Make the snapshot block the end of the main chain and record the
+	// snapshot, so the blocks before it are validated.
+	blockSize := uint64(msgBlock.SerializeSize())
+	blockWeight := uint64(GetBlockWeight(block))
+	state := newBestState(tip, blockSize, blockWeight,
+		uint64(len(msgBlock.Transactions)), info.TotalTxns,
+		tip.CalcPastMedianTime())
+	err = b.db.Update(func(dbTx database.Tx) error {
+		for _, node := range nodes {
+			if err := dbStoreBlockNode(dbTx, node); err != nil {
+				return err
+			}
+			err := dbPutBlockIndex(dbTx, &node.hash, node.height)
+			if err != nil {
+				return err
+			}
+		}
+		if err := dbStoreBlock(dbTx, block); err != nil {
+			return err
+		}
+		if err := dbPutBestState(dbTx, state, tip.workSum); err != nil {
+			return err
+		}
+		if err := dbPutUtxoStateConsistency(dbTx, &tip.hash); err != nil {
+			return err
+		}
+
+		meta := dbTx.Metadata()
+		if meta.Bucket(snapshotUtxoSetBucketName) != nil {
+			err := meta.DeleteBucket(snapshotUtxoSetBucketName)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := meta.CreateBucket(snapshotUtxoSetBucketName); err != nil {
+			return err
+		}
+		err := dbPutBlockHashKey(dbTx, snapshotUtxoConsistencyKeyName,
+			&genesis.hash)
+		if err != nil {
+			return err
+		}
+		return meta.Put(utxoSnapshotStateKeyName,
+			serializeUtxoSnapshotState(&info, false))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		b.index.addNode(node)
+	}
+	b.bestChain.SetTip(tip)
+	b.checkpointNode = nil
+	b.nextCheckpoint = nil
+	b.stateLock.Lock()
+	b.stateSnapshot = state
+	b.stateLock.Unlock()
+	b.utxoCache.setConsistentState(&tip.hash, tip.height)
+	b.setSnapshotValidationState(&info, genesis)
+
+	log.Infof("Loaded %d utxos of the snapshot at block %v (height %d)",
+		info.NumUtxos, info.Hash, info.Height)
+	return &info, nil
+}
+
+// setSnapshotValidationState prepares the validation of the blocks before the
+// passed utxo snapshot after the passed last validated block.
+func (b *BlockChain) setSnapshotValidationState(info *UtxoSnapshotInfo, validated *blockNode) {
+	b.snapshot = info
+	b.snapshotCache = newUtxoCacheForBucket(b.db, b.utxoCache.maxSize,
+		snapshotUtxoSetBucketName, snapshotUtxoConsistencyKeyName)
+	b.snapshotCache.setConsistentState(&validated.hash, validated.height)
+	b.snapshotTip = validated
+	b.snapshotPending = make(map[chainhash.Hash]*ulordutil.Block)
+}
+
+// initSnapshotState loads the state of the validation of the blocks before the
+// utxo snapshot the chain was bootstrapped from, if any, and validates the
+// blocks whose data is already stored.
+func (b *BlockChain) initSnapshotState() error {
+	var info *UtxoSnapshotInfo
+	var failed bool
+	var validatedHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Get(utxoSnapshotStateKeyName)
+		if serialized == nil {
+			return nil
+		}
+		var err error
+		info, failed, err = deserializeUtxoSnapshotState(serialized)
+		if err != nil {
+			return err
+		}
+		validatedHash, err = dbFetchBlockHashKey(dbTx,
+			snapshotUtxoConsistencyKeyName)
+		return err
+	})
+	if err != nil || info == nil {
+		return err
+	}
+
+	if failed {
+		return fmt.Errorf("the blocks before the utxo snapshot at block "+
+			"%v (height %d) do not produce its utxo set -- the "+
+			"chain must be downloaded again", info.Hash, info.Height)
+	}
+	if b.indexManager != nil {
+		return fmt.Errorf("optional indexes can't be enabled until the "+
+			"blocks before the utxo snapshot at height %d have "+
+			"been validated", info.Height)
+	}
+	var validated *blockNode
+	if validatedHash != nil {
+		validated = b.index.LookupNode(validatedHash)
+	}
+	if validated == nil || !b.bestChain.Contains(validated) ||
+		validated.height >= info.Height {
+
+		return AssertError(fmt.Sprintf("initSnapshotState: last "+
+			"validated block %v before the utxo snapshot is not "+
+			"in the main chain", validatedHash))
+	}
+
+	log.Infof("Validated the blocks up to height %d of %d before the utxo "+
+		"snapshot", validated.height, info.Height)
+	b.setSnapshotValidationState(info, validated)
+	return b.advanceSnapshotValidation()
+}
+
+// UtxoSnapshot returns a description of the utxo set snapshot the chain was
+// bootstrapped from while the blocks before it have not been validated yet.
+// It returns nil otherwise.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSnapshot() *UtxoSnapshotInfo {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.snapshot == nil {
+		return nil
+	}
+	info := *b.snapshot
+	return &info
+}
+
+// SnapshotBlocksNeeded returns the hashes of up to maxHashes of the next blocks
+// before the utxo snapshot the chain was bootstrapped from which have to be
+// downloaded and passed to ProcessSnapshotBlock to validate them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SnapshotBlocksNeeded(maxHashes int) []chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.snapshot == nil {
+		return nil
+	}
+
+	var hashes []chainhash.Hash
+	node := b.bestChain.Next(b.snapshotTip)
+	for ; node != nil && node.height <= b.snapshot.Height &&
+		len(hashes) < maxHashes; node = b.bestChain.Next(node) {
+
+		if _, ok := b.snapshotPending[node.hash]; ok {
+			continue
+		}
+		if b.index.NodeStatus(node).HaveData() {
+			continue
+		}
+		hashes = append(hashes, node.hash)
+	}
+	return hashes
+}
+
+// IsSnapshotBlock returns whether or not the block with the passed hash is one
+// of the blocks before the utxo snapshot the chain was bootstrapped from which
+// still has to be passed to ProcessSnapshotBlock.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsSnapshotBlock(hash *chainhash.Hash) bool {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.snapshot == nil {
+		return false
+	}
+	node := b.index.LookupNode(hash)
+	return node != nil && node.height > b.snapshotTip.height &&
+		node.height <= b.snapshot.Height && b.bestChain.Contains(node) &&
+		!b.index.NodeStatus(node).HaveData()
+}
+
+// ProcessSnapshotBlock validates the passed block, which must be one of the
+// blocks before the utxo snapshot the chain was bootstrapped from, against the
+// utxo set built from the blocks before it.  Blocks which are passed before
+// their parents are validated are kept until the parents have been validated.
+//
+// Once all of the blocks before the snapshot have been validated, the utxo set
+// they produce is compared with the utxo set of the snapshot.  An error is
+// returned when they differ.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessSnapshotBlock(block *ulordutil.Block) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.snapshot == nil {
+		return errors.New("there are no blocks before a utxo snapshot " +
+			"to validate")
+	}
+	node := b.index.LookupNode(block.Hash())
+	if node == nil || node.height > b.snapshot.Height ||
+		!b.bestChain.Contains(node) {
+
+		return fmt.Errorf("block %v is not before the utxo snapshot",
+			block.Hash())
+	}
+	if node.height <= b.snapshotTip.height {
+		return nil
+	}
+
+	if len(b.snapshotPending) >= maxPendingSnapshotBlocks {
+		log.Debugf("Dropping block %v (height %d) before the utxo "+
+			"snapshot", node.hash, node.height)
+		return nil
+	}
+	b.snapshotPending[node.hash] = block
+	return b.advanceSnapshotValidation()
+}
+
+// advanceSnapshotValidation validates the blocks after the last validated block
+// before the utxo snapshot while they are available, and completes the
+// validation when it reaches the snapshot block.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) advanceSnapshotValidation() error {
+	for b.snapshotTip.height < b.snapshot.Height {
+		node := b.bestChain.Next(b.snapshotTip)
+		block, ok := b.snapshotPending[node.hash]
+		if ok {
+			delete(b.snapshotPending, node.hash)
+		} else if b.index.NodeStatus(node).HaveData() {
+			err := b.db.View(func(dbTx database.Tx) error {
+				var err error
+				block, err = dbFetchBlockByNode(dbTx, node)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			return nil
+		}
+
+		if err := b.connectSnapshotBlock(node, block); err != nil {
+			return err
+		}
+		b.snapshotTip = node
+	}
+
+	return b.finishSnapshotValidation()
+}
+
+// connectSnapshotBlock fully validates the passed block before the utxo
+// snapshot and connects it to the utxo set built from the blocks before it.
+// The data and spend journal entry of the block are stored in the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectSnapshotBlock(node *blockNode, block *ulordutil.Block) error {
+	block.SetHeight(node.height)
+	err := checkBlockSanity(block, b.chainParams, b.timeSource,
+		BFNone)
+	if err != nil {
+		return err
+	}
+	if err := b.checkBlockTxnsContext(block, node.parent); err != nil {
+		return err
+	}
+
+	// Load the utxos the block creates and spends into the view so they
+	// are looked up in the utxo set built from the blocks before it when
+	// the block is checked.
+	fetchSet := make(map[wire.OutPoint]struct{})
+	txInFlight := make(map[chainhash.Hash]struct{})
+	for _, tx := range block.Transactions() {
+		prevOut := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx := range tx.MsgTx().TxOut {
+			prevOut.Index = uint32(txOutIdx)
+			fetchSet[prevOut] = struct{}{}
+		}
+		txInFlight[*tx.Hash()] = struct{}{}
+	}
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			if _, ok := txInFlight[txIn.PreviousOutPoint.Hash]; ok {
+				continue
+			}
+			fetchSet[txIn.PreviousOutPoint] = struct{}{}
+		}
+	}
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&node.parent.hash)
+	if err := view.fetchUtxos(b.snapshotCache, fetchSet); err != nil {
+		return err
+	}
+
+	stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
+	if err := b.checkConnectBlock(node, block, view, &stxos); err != nil {
+		return err
+	}
+	b.snapshotCache.commit(view)
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		if err := dbStoreBlock(dbTx, block); err != nil {
+			return err
+		}
+		err := dbPutSpendJournalEntry(dbTx, &node.hash, stxos)
+		if err != nil {
+			return err
+		}
+		b.index.SetStatusFlags(node, statusDataStored)
+		return dbStoreBlockNode(dbTx, node)
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.snapshotCache.maybeFlush(&node.hash, node.height,
+		FlushPeriodic)
+}
+
+// finishSnapshotValidation compares the utxo set built from the blocks before
+// the utxo snapshot with the one of the snapshot once all of them have been
+// validated.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) finishSnapshotValidation() error {
+	info := b.snapshot
+	err := b.snapshotCache.flush(&b.snapshotTip.hash, b.snapshotTip.height)
+	if err != nil {
+		return err
+	}
+
+	var commitment chainhash.Hash
+	err = b.db.View(func(dbTx database.Tx) error {
+		hasher := newSnapshotHasher(&info.Hash, info.Height,
+			info.TotalTxns)
+		utxoBucket := dbTx.Metadata().Bucket(snapshotUtxoSetBucketName)
+		if _, err := writeUtxoSet(hasher, utxoBucket); err != nil {
+			return err
+		}
+		commitment = snapshotCommitment(hasher)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.snapshotCache = nil
+	b.snapshotPending = nil
+	if commitment != info.Commitment {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbTx.Metadata().Put(utxoSnapshotStateKeyName,
+				serializeUtxoSnapshotState(info, true))
+		})
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("the blocks before the utxo snapshot at "+
+			"block %v (height %d) produce a utxo set with "+
+			"commitment %v instead of %v", info.Hash, info.Height,
+			commitment, info.Commitment)
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if err := meta.DeleteBucket(snapshotUtxoSetBucketName); err != nil {
+			return err
+		}
+		if err := meta.Delete(snapshotUtxoConsistencyKeyName); err != nil {
+			return err
+		}
+		return meta.Delete(utxoSnapshotStateKeyName)
+	})
+	if err != nil {
+		return err
+	}
+	b.snapshot = nil
+	b.snapshotTip = nil
+
+	log.Infof("Validated all blocks before the utxo snapshot at block %v "+
+		"(height %d)", info.Hash, info.Height)
+	return nil
+}
@@ -0,0 +1,382 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// writeTestUtxoSnapshot connects the passed blocks to a new chain and returns
+// the utxo set snapshot taken at the last one along with the utxos created by
+// the blocks.
+func writeTestUtxoSnapshot(blocks []*ulordutil.Block) ([]byte, *UtxoSnapshotInfo, map[wire.OutPoint]*UtxoEntry, error) {
+	chain, teardownFunc, err := chainSetup("snapshotsrc",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The test databases share a directory, so the chain is torn down
+	// before creating another one.
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("ProcessBlock fail on "+
+				"block %v: %v", i, err)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	info, err := chain.WriteUtxoSnapshot(&snapshot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tip := blocks[len(blocks)-1]
+	if info.Hash != *tip.Hash() || info.Height != tip.Height() {
+		return nil, nil, nil, fmt.Errorf("unexpected snapshot %+v", info)
+	}
+
+	utxos := make(map[wire.OutPoint]*UtxoEntry)
+	for i := 1; i < len(blocks); i++ {
+		for _, tx := range blocks[i].Transactions() {
+			outpoint := wire.OutPoint{Hash: *tx.Hash()}
+			utxos[outpoint], err = chain.FetchUtxoEntry(outpoint)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return snapshot.Bytes(), info, utxos, nil
+}
+
+// TestUtxoSnapshot ensures a chain bootstrapped from a utxo set snapshot of
+// another chain has the same utxo set and best block, and that the blocks
+// before the snapshot are validated against it.
+func TestUtxoSnapshot(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	snapshot, info, wantUtxos, err := writeTestUtxoSnapshot(blocks)
+	if err != nil {
+		t.Fatalf("writeTestUtxoSnapshot: %v", err)
+	}
+
+	dstChain, dstTeardownFunc, err := chainSetup("snapshotdst",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer dstTeardownFunc()
+	dstChain.TstSetCoinbaseMaturity(1)
+
+	// Snapshots whose commitment doesn't match must be rejected without
+	// changing the chain.
+	var badCommitment chainhash.Hash
+	_, err = dstChain.LoadUtxoSnapshot(bytes.NewReader(snapshot),
+		&badCommitment)
+	if err == nil {
+		t.Fatal("LoadUtxoSnapshot: snapshot with wrong commitment loaded")
+	}
+	if dstChain.BestSnapshot().Height != 0 {
+		t.Fatalf("chain changed to height %d",
+			dstChain.BestSnapshot().Height)
+	}
+	_, err = dstChain.LoadUtxoSnapshot(bytes.NewReader(snapshot),
+		nil)
+	if err != errNoSnapshotCommitment {
+		t.Fatalf("LoadUtxoSnapshot: unexpected error %v", err)
+	}
+
+	loaded, err := dstChain.LoadUtxoSnapshot(
+		bytes.NewReader(snapshot), &info.Commitment)
+	if err != nil {
+		t.Fatalf("LoadUtxoSnapshot: %v", err)
+	}
+	if *loaded != *info {
+		t.Fatalf("loaded snapshot %+v, want %+v", loaded, info)
+	}
+	best := dstChain.BestSnapshot()
+	if best.Hash != info.Hash || best.Height != info.Height {
+		t.Fatalf("best block %v (height %d) after loading the snapshot",
+			best.Hash, best.Height)
+	}
+	for outpoint, want := range wantUtxos {
+		got, err := dstChain.FetchUtxoEntry(outpoint)
+		if err != nil {
+			t.Fatalf("FetchUtxoEntry: %v", err)
+		}
+		if (got == nil) != (want == nil) || got != nil &&
+			(got.Amount() != want.Amount() ||
+				got.BlockHeight() != want.BlockHeight()) {
+
+			t.Fatalf("utxo %v is %+v instead of %+v", outpoint, got,
+				want)
+		}
+	}
+
+	// Snapshots can only be loaded into an empty chain.
+	_, err = dstChain.LoadUtxoSnapshot(bytes.NewReader(snapshot),
+		&info.Commitment)
+	if err == nil {
+		t.Fatal("LoadUtxoSnapshot: snapshot loaded twice")
+	}
+
+	// The blocks before the snapshot block must be requested, and they
+	// are validated once their parents are.
+	if dstChain.UtxoSnapshot() == nil {
+		t.Fatal("UtxoSnapshot: no snapshot pending validation")
+	}
+	needed := dstChain.SnapshotBlocksNeeded(10)
+	if len(needed) != len(blocks)-2 {
+		t.Fatalf("SnapshotBlocksNeeded: got %d blocks, want %d",
+			len(needed), len(blocks)-2)
+	}
+	for i := len(blocks) - 2; i > 0; i-- {
+		if !dstChain.IsSnapshotBlock(blocks[i].Hash()) {
+			t.Fatalf("IsSnapshotBlock: block %d not needed", i)
+		}
+		if err := dstChain.ProcessSnapshotBlock(blocks[i]); err != nil {
+			t.Fatalf("ProcessSnapshotBlock(%d): %v", i, err)
+		}
+	}
+	if dstChain.UtxoSnapshot() != nil {
+		t.Fatal("UtxoSnapshot: snapshot still pending validation")
+	}
+	for i := 1; i < len(blocks); i++ {
+		if _, err := dstChain.BlockByHash(blocks[i].Hash()); err != nil {
+			t.Fatalf("BlockByHash(%d): %v", i, err)
+		}
+	}
+}
+
+// TestUtxoSnapshotErrors ensures snapshots which are truncated, corrupt, don't
+// match their commitment, or whose block does not extend their headers are
+// rejected without changing the chain.
+func TestUtxoSnapshotErrors(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	snapshot, info, wantUtxos, err := writeTestUtxoSnapshot(blocks)
+	if err != nil {
+		t.Fatalf("writeTestUtxoSnapshot: %v", err)
+	}
+
+	// Offsets of the sections of the snapshot.
+	const headersOffset = chainhash.HashSize + 21
+	tip := blocks[len(blocks)-1]
+	blockOffset := headersOffset + (len(blocks)-2)*wire.MaxBlockHeaderPayload
+	utxosOffset := blockOffset + tip.MsgBlock().SerializeSize()
+
+	badCommitment := info.Commitment
+	badCommitment[0] ^= 0x01
+
+	tests := []struct {
+		name       string
+		mutate     func(snapshot []byte) []byte
+		commitment *chainhash.Hash
+	}{{
+		name: "truncated fields",
+		mutate: func(snapshot []byte) []byte {
+			return snapshot[:headersOffset-2]
+		},
+	}, {
+		name: "truncated headers",
+		mutate: func(snapshot []byte) []byte {
+			return snapshot[:blockOffset-1]
+		},
+	}, {
+		name: "truncated block",
+		mutate: func(snapshot []byte) []byte {
+			return snapshot[:utxosOffset-1]
+		},
+	}, {
+		name: "truncated utxos",
+		mutate: func(snapshot []byte) []byte {
+			return snapshot[:len(snapshot)-1]
+		},
+	}, {
+		name: "bad magic",
+		mutate: func(snapshot []byte) []byte {
+			snapshot[0] ^= 0x01
+			return snapshot
+		},
+	}, {
+		name: "unsupported version",
+		mutate: func(snapshot []byte) []byte {
+			snapshot[4]++
+			return snapshot
+		},
+	}, {
+		name: "corrupt header",
+		mutate: func(snapshot []byte) []byte {
+			snapshot[headersOffset+4] ^= 0x01
+			return snapshot
+		},
+	}, {
+		name: "corrupt utxo",
+		mutate: func(snapshot []byte) []byte {
+			snapshot[len(snapshot)-2] ^= 0x01
+			return snapshot
+		},
+	}, {
+		name: "commitment mismatch",
+		mutate: func(snapshot []byte) []byte {
+			return snapshot
+		},
+		commitment: &badCommitment,
+	}, {
+		// The block before the snapshot block is in the snapshot, so
+		// the block does not extend the last header.
+		name: "block not in the header chain",
+		mutate: func(snapshot []byte) []byte {
+			prev := blocks[len(blocks)-2]
+			var buf bytes.Buffer
+			buf.Write(snapshot[:8])
+			buf.Write(prev.Hash()[:])
+			buf.Write(snapshot[8+chainhash.HashSize : blockOffset])
+			prev.MsgBlock().Serialize(&buf)
+			buf.Write(snapshot[utxosOffset:])
+			return buf.Bytes()
+		},
+	}}
+
+	for _, test := range tests {
+		mutated := test.mutate(append([]byte(nil), snapshot...))
+		commitment := test.commitment
+		if commitment == nil {
+			commitment = &info.Commitment
+		}
+
+		chain, teardownFunc, err := chainSetup("snapshotdst",
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Failed to setup chain instance: %v", err)
+		}
+		_, err = chain.LoadUtxoSnapshot(bytes.NewReader(mutated),
+			commitment)
+		if err == nil {
+			teardownFunc()
+			t.Fatalf("%s: snapshot loaded", test.name)
+		}
+		if height := chain.BestSnapshot().Height; height != 0 {
+			teardownFunc()
+			t.Fatalf("%s: chain changed to height %d", test.name,
+				height)
+		}
+		if chain.UtxoSnapshot() != nil {
+			teardownFunc()
+			t.Fatalf("%s: snapshot pending validation", test.name)
+		}
+		for outpoint := range wantUtxos {
+			entry, err := chain.FetchUtxoEntry(outpoint)
+			if err != nil || entry != nil {
+				teardownFunc()
+				t.Fatalf("%s: utxo %v of the snapshot is %+v "+
+					"(err %v)", test.name, outpoint, entry, err)
+			}
+		}
+		teardownFunc()
+	}
+}
+
+// TestUtxoSnapshotValidationFailure ensures a snapshot whose utxo set is not
+// the one produced by the blocks before it is rejected once they have been
+// validated, and that the chain stays in the failed state.
+func TestUtxoSnapshotValidationFailure(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	snapshot, info, _, err := writeTestUtxoSnapshot(blocks)
+	if err != nil {
+		t.Fatalf("writeTestUtxoSnapshot: %v", err)
+	}
+
+	// Drop the first utxo of the snapshot and commit to the remaining
+	// ones, so the snapshot is loaded but doesn't match the blocks.
+	tip := blocks[len(blocks)-1]
+	utxosOffset := chainhash.HashSize + 21 +
+		(len(blocks)-2)*wire.MaxBlockHeaderPayload +
+		tip.MsgBlock().SerializeSize()
+	r := bytes.NewReader(snapshot[utxosOffset:])
+	for i := 0; i < 2; i++ {
+		if _, err := wire.ReadVarBytes(r, 0, wire.MaxBlockPayload,
+			"utxo"); err != nil {
+
+			t.Fatalf("ReadVarBytes: %v", err)
+		}
+	}
+	utxos := snapshot[len(snapshot)-r.Len():]
+	hasher := newSnapshotHasher(&info.Hash, info.Height, info.TotalTxns)
+	hasher.Write(utxos)
+	commitment := snapshotCommitment(hasher)
+	mutated := append(append([]byte(nil), snapshot[:utxosOffset]...),
+		utxos...)
+
+	chain, teardownFunc, err := chainSetup("snapshotdst",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	loaded, err := chain.LoadUtxoSnapshot(bytes.NewReader(mutated),
+		&commitment)
+	if err != nil {
+		t.Fatalf("LoadUtxoSnapshot: %v", err)
+	}
+	if loaded.NumUtxos != info.NumUtxos-1 {
+		t.Fatalf("loaded %d utxos, want %d", loaded.NumUtxos,
+			info.NumUtxos-1)
+	}
+
+	for i := len(blocks) - 2; i > 1; i-- {
+		if err := chain.ProcessSnapshotBlock(blocks[i]); err != nil {
+			t.Fatalf("ProcessSnapshotBlock(%d): %v", i, err)
+		}
+	}
+	if err := chain.ProcessSnapshotBlock(blocks[1]); err == nil {
+		t.Fatal("ProcessSnapshotBlock: validation of the blocks " +
+			"before the snapshot did not fail")
+	}
+
+	// The failure is recorded, so the chain can't be used again.
+	var failed bool
+	err = chain.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Get(utxoSnapshotStateKeyName)
+		if serialized == nil {
+			return fmt.Errorf("no utxo snapshot state")
+		}
+		var err error
+		_, failed, err = deserializeUtxoSnapshotState(serialized)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch the utxo snapshot state: %v", err)
+	}
+	if !failed {
+		t.Fatal("utxo snapshot state is not marked as failed")
+	}
+	if err := chain.initSnapshotState(); err == nil {
+		t.Fatal("initSnapshotState: chain with a failed snapshot " +
+			"initialized")
+	}
+}
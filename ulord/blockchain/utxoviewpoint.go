@@ -28,6 +28,11 @@ const (
 	// tfModified indicates that a txout has been modified since it was
 	// loaded.
 	tfModified
+
+	// tfFresh indicates that a txout is not in the database.  It is only
+	// used by the utxo cache, which forgets fresh txouts that are spent
+	// instead of removing them from the database.
+	tfFresh
 )
 
 // UtxoEntry houses details about an individual transaction output in a utxo
@@ -459,7 +464,7 @@ func (view *UtxoViewpoint) commit() {
 			continue
 		}
 
-		entry.packedFlags ^= tfModified
+		entry.packedFlags &^= tfModified
 	}
 }
 
@@ -470,7 +475,7 @@ func (view *UtxoViewpoint) commit() {
 // Upon completion of this function, the view will contain an entry for each
 // requested outpoint.  Spent outputs, or those which otherwise don't exist,
 // will result in a nil entry in the view.
-func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxosMain(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -483,24 +488,13 @@ func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.Out
 	// will result in nil entries in the view.  This is intentionally done
 	// so other code can use the presence of an entry in the store as a way
 	// to unnecessarily avoid attempting to reload it from the database.
-	return db.View(func(dbTx database.Tx) error {
-		for outpoint := range outpoints {
-			entry, err := dbFetchUtxoEntry(dbTx, outpoint)
-			if err != nil {
-				return err
-			}
-
-			view.entries[outpoint] = entry
-		}
-
-		return nil
-	})
+	return cache.fetchEntries(view, outpoints)
 }
 
 // fetchUtxos loads the unspent transaction outputs for the provided set of
-// outputs into the view from the database as needed unless they already exist
+// outputs into the view from the utxo cache as needed unless they already exist
 // in the view in which case they are ignored.
-func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxos(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -517,16 +511,16 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 		neededSet[outpoint] = struct{}{}
 	}
 
-	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	// Request the input utxos from the utxo cache.
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // fetchInputUtxos loads the unspent transaction outputs for the inputs
 // referenced by the transactions in the given block into the view from the
-// database as needed.  In particular, referenced entries that are earlier in
+// utxo cache as needed.  In particular, referenced entries that are earlier in
 // the block are added to the view and entries that are already in the view are
 // not modified.
-func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *ulordutil.Block) error {
+func (view *UtxoViewpoint) fetchInputUtxos(cache *utxoCache, block *ulordutil.Block) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
 	// block which are not yet in the chain.
@@ -563,7 +557,7 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *ulordutil.Bloc
 			}
 
 			// Don't request entries that are already in the view
-			// from the database.
+			// from the utxo cache.
 			if _, ok := view.entries[txIn.PreviousOutPoint]; ok {
 				continue
 			}
@@ -572,8 +566,8 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *ulordutil.Bloc
 		}
 	}
 
-	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	// Request the input utxos from the utxo cache.
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // NewUtxoViewpoint returns a new empty unspent transaction output view.
@@ -609,7 +603,7 @@ func (b *BlockChain) FetchUtxoView(tx *ulordutil.Tx) (*UtxoViewpoint, error) {
 	// chain.
 	view := NewUtxoViewpoint()
 	b.chainLock.RLock()
-	err := view.fetchUtxosMain(b.db, neededSet)
+	err := view.fetchUtxosMain(b.utxoCache, neededSet)
 	b.chainLock.RUnlock()
 	return view, err
 }
@@ -628,15 +622,12 @@ func (b *BlockChain) FetchUtxoEntry(outpoint wire.OutPoint) (*UtxoEntry, error)
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
-	var entry *UtxoEntry
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
-		return err
-	})
+	view := NewUtxoViewpoint()
+	neededSet := map[wire.OutPoint]struct{}{outpoint: {}}
+	err := view.fetchUtxosMain(b.utxoCache, neededSet)
 	if err != nil {
 		return nil, err
 	}
 
-	return entry, nil
+	return view.entries[outpoint], nil
 }
@@ -37,10 +37,6 @@ const (
 	// serializedHeightVersion is the block version which changed block
 	// coinbases to start with the serialized block height.
 	serializedHeightVersion = 2
-
-	// baseSubsidy is the starting subsidy amount for mined blocks.  This
-	// value is halved every SubsidyHalvingInterval blocks.
-	baseSubsidy = 50 * ulordutil.SatoshiPerBitcoin
 )
 
 var (
@@ -186,18 +182,11 @@ func isBIP0030Node(node *blockNode) bool {
 // newly generated blocks awards as well as validating the coinbase for blocks
 // has the expected value.
 //
-// The subsidy is halved every SubsidyReductionInterval blocks.  Mathematically
-// this is: baseSubsidy / 2^(height/SubsidyReductionInterval)
-//
-// At the target block generation rate for the main network, this is
-// approximately every 4 years.
+// The subsidy is defined by the subsidy schedule of the chain parameters, which
+// halves it every ReductionInterval blocks.  At the target block generation
+// rate for the main network, this is approximately every 4 years.
 func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
-	if chainParams.SubsidyReductionInterval == 0 {
-		return baseSubsidy
-	}
-
-	// Equivalent to: baseSubsidy / 2^(height/subsidyHalvingInterval)
-	return baseSubsidy >> uint(height/chainParams.SubsidyReductionInterval)
+	return chainParams.Subsidy.BlockSubsidy(height)
 }
 
 // CheckTransactionSanity performs some preliminary checks on a transaction to
@@ -298,13 +287,13 @@ func CheckTransactionSanity(tx *ulordutil.Tx) error {
 }
 
 // checkProofOfWork ensures the block header bits which indicate the target
-// difficulty is in min/max range and that the block hash is less than the
-// target difficulty as claimed.
+// difficulty is in min/max range and that the proof-of-work hash of the network
+// is less than the target difficulty as claimed.
 //
 // The flags modify the behavior of this function as follows:
-//  - BFNoPoWCheck: The check to ensure the block hash is less than the target
-//    difficulty is not performed.
-func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags BehaviorFlags) error {
+//  - BFNoPoWCheck: The check to ensure the proof-of-work hash is less than the
+//    target difficulty is not performed.
+func checkProofOfWork(header *wire.BlockHeader, chainParams *chaincfg.Params, flags BehaviorFlags) error {
 	// The target difficulty must be larger than zero.
 	target := CompactToBig(header.Bits)
 	if target.Sign() <= 0 {
@@ -314,6 +303,7 @@ func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags Behavio
 	}
 
 	// The target difficulty must be less than the maximum allowed.
+	powLimit := chainParams.PowLimit
 	if target.Cmp(powLimit) > 0 {
 		str := fmt.Sprintf("block target difficulty of %064x is "+
 			"higher than max of %064x", target, powLimit)
@@ -323,8 +313,10 @@ func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags Behavio
 	// The block hash must be less than the claimed target unless the flag
 	// to avoid proof of work checks is set.
 	if flags&BFNoPoWCheck != BFNoPoWCheck {
-		// The block hash must be less than the claimed target.
-		hash := header.BlockHash()
+		// The proof-of-work hash of the network, which is the block
+		// hash unless the network registered another one, must be less
+		// than the claimed target.
+		hash := chainParams.PowHash(header)
 		hashNum := HashToBig(&hash)
 		if hashNum.Cmp(target) > 0 {
 			str := fmt.Sprintf("block hash of %064x is higher than "+
@@ -336,11 +328,30 @@ func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int, flags Behavio
 	return nil
 }
 
+// blockHashPowParams returns chain parameters with the passed proof-of-work
+// limit for the functions which predate per-network proof-of-work hashes.  No
+// hash is registered for their network, so the proof-of-work hash of blocks is
+// their block hash.
+func blockHashPowParams(powLimit *big.Int) *chaincfg.Params {
+	return &chaincfg.Params{PowLimit: powLimit}
+}
+
 // CheckProofOfWork ensures the block header bits which indicate the target
 // difficulty is in min/max range and that the block hash is less than the
 // target difficulty as claimed.
+//
+// Deprecated: Use CheckProofOfWorkParams, which checks the proof-of-work hash
+// registered for the network rather than the block hash.
 func CheckProofOfWork(block *ulordutil.Block, powLimit *big.Int) error {
-	return checkProofOfWork(&block.MsgBlock().Header, powLimit, BFNone)
+	return checkProofOfWork(&block.MsgBlock().Header,
+		blockHashPowParams(powLimit), BFNone)
+}
+
+// CheckProofOfWorkParams ensures the block header bits which indicate the
+// target difficulty is in min/max range and that the proof-of-work hash of the
+// network is less than the target difficulty as claimed.
+func CheckProofOfWorkParams(block *ulordutil.Block, chainParams *chaincfg.Params) error {
+	return checkProofOfWork(&block.MsgBlock().Header, chainParams, BFNone)
 }
 
 // CountSigOps returns the number of signature operations for all transaction
@@ -427,11 +438,11 @@ func CountP2SHSigOps(tx *ulordutil.Tx, isCoinBaseTx bool, utxoView *UtxoViewpoin
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkProofOfWork.
-func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
+func checkBlockHeaderSanity(header *wire.BlockHeader, chainParams *chaincfg.Params, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	// Ensure the proof of work bits in the block header is in min/max range
 	// and the block hash is less than the target value described by the
 	// bits.
-	err := checkProofOfWork(header, powLimit, flags)
+	err := checkProofOfWork(header, chainParams, flags)
 	if err != nil {
 		return err
 	}
@@ -464,10 +475,10 @@ func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSou
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkBlockHeaderSanity.
-func checkBlockSanity(block *ulordutil.Block, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
+func checkBlockSanity(block *ulordutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	msgBlock := block.MsgBlock()
 	header := &msgBlock.Header
-	err := checkBlockHeaderSanity(header, powLimit, timeSource, flags)
+	err := checkBlockHeaderSanity(header, chainParams, timeSource, flags)
 	if err != nil {
 		return err
 	}
@@ -571,8 +582,20 @@ func checkBlockSanity(block *ulordutil.Block, powLimit *big.Int, timeSource Medi
 
 // CheckBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
+// The proof of work is checked against the block hash.
+//
+// Deprecated: Use CheckBlockSanityParams, which checks the proof-of-work hash
+// registered for the network rather than the block hash.
 func CheckBlockSanity(block *ulordutil.Block, powLimit *big.Int, timeSource MedianTimeSource) error {
-	return checkBlockSanity(block, powLimit, timeSource, BFNone)
+	return checkBlockSanity(block, blockHashPowParams(powLimit), timeSource,
+		BFNone)
+}
+
+// CheckBlockSanityParams performs some preliminary checks on a block of the
+// network defined by the passed parameters to ensure it is sane before
+// continuing with block processing.  These checks are context free.
+func CheckBlockSanityParams(block *ulordutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource) error {
+	return checkBlockSanity(block, chainParams, timeSource, BFNone)
 }
 
 // ExtractCoinbaseHeight attempts to extract the height of the block from the
@@ -733,85 +756,98 @@ func (b *BlockChain) checkBlockContext(block *ulordutil.Block, prevNode *blockNo
 
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	if !fastAdd {
-		// Obtain the latest state of the deployed CSV soft-fork in
-		// order to properly guard the new validation behavior based on
-		// the current BIP 9 version bits state.
-		csvState, err := b.deploymentState(prevNode, chaincfg.DeploymentCSV)
-		if err != nil {
-			return err
-		}
+		return b.checkBlockTxnsContext(block, prevNode)
+	}
 
-		// Once the CSV soft-fork is fully active, we'll switch to
-		// using the current median time past of the past block's
-		// timestamps for all lock-time based checks.
-		blockTime := header.Timestamp
-		if csvState == ThresholdActive {
-			blockTime = prevNode.CalcPastMedianTime()
-		}
+	return nil
+}
 
-		// The height of this block is one more than the referenced
-		// previous block.
-		blockHeight := prevNode.height + 1
+// checkBlockTxnsContext performs the validation checks on the transactions of
+// the block which depend on its position within the block chain.  See
+// checkBlockContext for the checks which are performed on the header.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkBlockTxnsContext(block *ulordutil.Block, prevNode *blockNode) error {
+	header := &block.MsgBlock().Header
 
-		// Ensure all transactions in the block are finalized.
-		for _, tx := range block.Transactions() {
-			if !IsFinalizedTransaction(tx, blockHeight,
-				blockTime) {
+	// Obtain the latest state of the deployed CSV soft-fork in
+	// order to properly guard the new validation behavior based on
+	// the current BIP 9 version bits state.
+	csvState, err := b.deploymentState(prevNode, chaincfg.DeploymentCSV)
+	if err != nil {
+		return err
+	}
 
-				str := fmt.Sprintf("block contains unfinalized "+
-					"transaction %v", tx.Hash())
-				return ruleError(ErrUnfinalizedTx, str)
-			}
-		}
+	// Once the CSV soft-fork is fully active, we'll switch to
+	// using the current median time past of the past block's
+	// timestamps for all lock-time based checks.
+	blockTime := header.Timestamp
+	if csvState == ThresholdActive {
+		blockTime = prevNode.CalcPastMedianTime()
+	}
 
-		// Ensure coinbase starts with serialized block heights for
-		// blocks whose version is the serializedHeightVersion or newer
-		// once a majority of the network has upgraded.  This is part of
-		// BIP0034.
-		if ShouldHaveSerializedBlockHeight(header) &&
-			blockHeight >= b.chainParams.BIP0034Height {
+	// The height of this block is one more than the referenced
+	// previous block.
+	blockHeight := prevNode.height + 1
 
-			coinbaseTx := block.Transactions()[0]
-			err := checkSerializedHeight(coinbaseTx, blockHeight)
-			if err != nil {
-				return err
-			}
+	// Ensure all transactions in the block are finalized.
+	for _, tx := range block.Transactions() {
+		if !IsFinalizedTransaction(tx, blockHeight,
+			blockTime) {
+
+			str := fmt.Sprintf("block contains unfinalized "+
+				"transaction %v", tx.Hash())
+			return ruleError(ErrUnfinalizedTx, str)
 		}
+	}
 
-		// Query for the Version Bits state for the segwit soft-fork
-		// deployment. If segwit is active, we'll switch over to
-		// enforcing all the new rules.
-		segwitState, err := b.deploymentState(prevNode,
-			chaincfg.DeploymentSegwit)
+	// Ensure coinbase starts with serialized block heights for
+	// blocks whose version is the serializedHeightVersion or newer
+	// once a majority of the network has upgraded.  This is part of
+	// BIP0034.
+	if ShouldHaveSerializedBlockHeight(header) &&
+		blockHeight >= b.chainParams.BIP0034Height {
+
+		coinbaseTx := block.Transactions()[0]
+		err := checkSerializedHeight(coinbaseTx, blockHeight)
 		if err != nil {
 			return err
 		}
+	}
 
-		// If segwit is active, then we'll need to fully validate the
-		// new witness commitment for adherence to the rules.
-		if segwitState == ThresholdActive {
-			// Validate the witness commitment (if any) within the
-			// block.  This involves asserting that if the coinbase
-			// contains the special commitment output, then this
-			// merkle root matches a computed merkle root of all
-			// the wtxid's of the transactions within the block. In
-			// addition, various other checks against the
-			// coinbase's witness stack.
-			if err := ValidateWitnessCommitment(block); err != nil {
-				return err
-			}
+	// Query for the Version Bits state for the segwit soft-fork
+	// deployment. If segwit is active, we'll switch over to
+	// enforcing all the new rules.
+	segwitState, err := b.deploymentState(prevNode,
+		chaincfg.DeploymentSegwit)
+	if err != nil {
+		return err
+	}
 
-			// Once the witness commitment, witness nonce, and sig
-			// op cost have been validated, we can finally assert
-			// that the block's weight doesn't exceed the current
-			// consensus parameter.
-			blockWeight := GetBlockWeight(block)
-			if blockWeight > MaxBlockWeight {
-				str := fmt.Sprintf("block's weight metric is "+
-					"too high - got %v, max %v",
-					blockWeight, MaxBlockWeight)
-				return ruleError(ErrBlockWeightTooHigh, str)
-			}
+	// If segwit is active, then we'll need to fully validate the
+	// new witness commitment for adherence to the rules.
+	if segwitState == ThresholdActive {
+		// Validate the witness commitment (if any) within the
+		// block.  This involves asserting that if the coinbase
+		// contains the special commitment output, then this
+		// merkle root matches a computed merkle root of all
+		// the wtxid's of the transactions within the block. In
+		// addition, various other checks against the
+		// coinbase's witness stack.
+		if err := ValidateWitnessCommitment(block); err != nil {
+			return err
+		}
+
+		// Once the witness commitment, witness nonce, and sig
+		// op cost have been validated, we can finally assert
+		// that the block's weight doesn't exceed the current
+		// consensus parameter.
+		blockWeight := GetBlockWeight(block)
+		if blockWeight > MaxBlockWeight {
+			str := fmt.Sprintf("block's weight metric is "+
+				"too high - got %v, max %v",
+				blockWeight, MaxBlockWeight)
+			return ruleError(ErrBlockWeightTooHigh, str)
 		}
 	}
 
@@ -840,7 +876,7 @@ func (b *BlockChain) checkBIP0030(node *blockNode, block *ulordutil.Block, view
 			fetchSet[prevOut] = struct{}{}
 		}
 	}
-	err := view.fetchUtxos(b.db, fetchSet)
+	err := view.fetchUtxos(b.utxoCache, fetchSet)
 	if err != nil {
 		return err
 	}
@@ -1036,7 +1072,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *ulordutil.Block,
 	//
 	// These utxo entries are needed for verification of things such as
 	// transaction inputs, counting pay-to-script-hashes, and scripts.
-	err := view.fetchInputUtxos(b.db, block)
+	err := view.fetchInputUtxos(b.utxoCache, block)
 	if err != nil {
 		return err
 	}
@@ -1125,20 +1161,18 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *ulordutil.Block,
 
 	// The total output values of the coinbase transaction must not exceed
 	// the expected subsidy value plus total transaction fees gained from
-	// mining the block.  It is safe to ignore overflow and out of range
-	// errors here because those error conditions would have already been
-	// caught by checkTransactionSanity.
-	var totalSatoshiOut int64
-	for _, txOut := range transactions[0].MsgTx().TxOut {
-		totalSatoshiOut += txOut.Value
+	// mining the block along with the verified payments of superblocks.
+	err = b.checkCoinbaseValue(block, node.height, totalFees)
+	if err != nil {
+		return err
 	}
-	expectedSatoshiOut := CalcBlockSubsidy(node.height, b.chainParams) +
-		totalFees
-	if totalSatoshiOut > expectedSatoshiOut {
-		str := fmt.Sprintf("coinbase transaction for block pays %v "+
-			"which is more than expected value of %v",
-			totalSatoshiOut, expectedSatoshiOut)
-		return ruleError(ErrBadCoinbaseValue, str)
+
+	// Ensure the coinbase transaction makes the founder, masternode, and
+	// superblock payments required by the consensus rules along with any
+	// additional payout checks the chain was configured with.
+	err = b.checkCoinbasePayouts(block, node.height, totalFees)
+	if err != nil {
+		return err
 	}
 
 	// Don't run scripts if this node is before the latest known good
@@ -1260,7 +1294,7 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *ulordutil.Block) error {
 		return ruleError(ErrPrevBlockNotBest, str)
 	}
 
-	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	err := checkBlockSanity(block, b.chainParams, b.timeSource, flags)
 	if err != nil {
 		return err
 	}
@@ -148,8 +148,8 @@ func TestCheckConnectBlockTemplate(t *testing.T) {
 	}
 }
 
-// TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
-// as expected.
+// TestCheckBlockSanity tests the CheckBlockSanity and CheckBlockSanityParams
+// functions to ensure they work as expected.
 func TestCheckBlockSanity(t *testing.T) {
 	powLimit := chaincfg.MainNetParams.PowLimit
 	block := ulordutil.NewBlock(&Block100000)
@@ -158,6 +158,10 @@ func TestCheckBlockSanity(t *testing.T) {
 	if err != nil {
 		t.Errorf("CheckBlockSanity: %v", err)
 	}
+	err = CheckBlockSanityParams(block, &chaincfg.MainNetParams, timeSource)
+	if err != nil {
+		t.Errorf("CheckBlockSanityParams: %v", err)
+	}
 
 	// Ensure a block that has a timestamp with a precision higher than one
 	// second fails.
@@ -167,6 +171,11 @@ func TestCheckBlockSanity(t *testing.T) {
 	if err == nil {
 		t.Errorf("CheckBlockSanity: error is nil when it shouldn't be")
 	}
+	err = CheckBlockSanityParams(block, &chaincfg.MainNetParams, timeSource)
+	if err == nil {
+		t.Errorf("CheckBlockSanityParams: error is nil when it " +
+			"shouldn't be")
+	}
 }
 
 // TestCheckSerializedHeight tests the checkSerializedHeight function with
@@ -158,22 +158,30 @@ func (c deploymentChecker) EndTime() uint64 {
 // RuleChangeActivationThreshold is the number of blocks for which the condition
 // must be true in order to lock in a rule change.
 //
-// This implementation returns the value defined by the chain params the checker
-// is associated with.
+// This implementation returns the value defined by the specific deployment the
+// checker is associated with, or by the chain params when the deployment does
+// not define it.
 //
 // This is part of the thresholdConditionChecker interface implementation.
 func (c deploymentChecker) RuleChangeActivationThreshold() uint32 {
+	if c.deployment.RuleChangeActivationThreshold != 0 {
+		return c.deployment.RuleChangeActivationThreshold
+	}
 	return c.chain.chainParams.RuleChangeActivationThreshold
 }
 
 // MinerConfirmationWindow is the number of blocks in each threshold state
 // retarget window.
 //
-// This implementation returns the value defined by the chain params the checker
-// is associated with.
+// This implementation returns the value defined by the specific deployment the
+// checker is associated with, or by the chain params when the deployment does
+// not define it.
 //
 // This is part of the thresholdConditionChecker interface implementation.
 func (c deploymentChecker) MinerConfirmationWindow() uint32 {
+	if c.deployment.MinerConfirmationWindow != 0 {
+		return c.deployment.MinerConfirmationWindow
+	}
 	return c.chain.chainParams.MinerConfirmationWindow
 }
 
@@ -143,6 +143,38 @@ func ulordMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropBlockStatsIndex {
+		if err := indexers.DropBlockStatsIndex(db, interrupt); err != nil {
+			ulordLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropAddrUtxoIndex {
+		if err := indexers.DropAddrUtxoIndex(db, interrupt); err != nil {
+			ulordLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropSpentIndex {
+		if err := indexers.DropSpentIndex(db, interrupt); err != nil {
+			ulordLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTimestampIndex {
+		if err := indexers.DropTimestampIndex(db, interrupt); err != nil {
+			ulordLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 
 	// Create server and start it.
 	server, err := newServer(cfg.Listeners, db, activeNetParams.Params,
@@ -0,0 +1,14 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+// PowHashFunc computes the proof-of-work hash of a serialized block header,
+// which is compared against the target difficulty of the block.  Networks
+// register the function they use with chaincfg.RegisterPowHash.
+type PowHashFunc func(header []byte) Hash
+
+// Ensure DoubleHashH, the proof-of-work hash of networks which do not register
+// their own, is a PowHashFunc.
+var _ PowHashFunc = DoubleHashH
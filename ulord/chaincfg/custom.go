@@ -0,0 +1,575 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// defaultBaseParams is the name of the network whose parameters custom
+// networks inherit unless they name another one.
+const defaultBaseParams = "regtest"
+
+// defaultNets are the default networks custom networks may be based on.
+var defaultNets = []*Params{&MainNetParams, &TestNet3Params,
+	&RegressionNetParams, &SimNetParams}
+
+// jsonDNSSeed is the JSON representation of a DNSSeed.
+type jsonDNSSeed struct {
+	Host         string `json:"host"`
+	HasFiltering bool   `json:"hasfiltering"`
+}
+
+// jsonFounderReward is the JSON representation of a FounderReward.
+type jsonFounderReward struct {
+	StartHeight int32  `json:"startheight"`
+	EndHeight   int32  `json:"endheight"`
+	PkScript    string `json:"pkscript"`
+	Percent     int64  `json:"percent"`
+}
+
+// jsonCheckpoint is the JSON representation of a Checkpoint.
+type jsonCheckpoint struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// jsonUtxoSnapshot is the JSON representation of a UtxoSnapshot.
+type jsonUtxoSnapshot struct {
+	Height     int32  `json:"height"`
+	Hash       string `json:"hash"`
+	Commitment string `json:"commitment"`
+}
+
+// jsonDeployment is the JSON representation of a ConsensusDeployment.
+type jsonDeployment struct {
+	BitNumber                     uint8  `json:"bitnumber"`
+	StartTime                     uint64 `json:"starttime"`
+	ExpireTime                    uint64 `json:"expiretime"`
+	MinerConfirmationWindow       uint32 `json:"minerconfirmationwindow,omitempty"`
+	RuleChangeActivationThreshold uint32 `json:"rulechangeactivationthreshold,omitempty"`
+}
+
+// jsonParams is the JSON representation of Params.  Byte strings, hashes, and
+// the proof-of-work limit are hex-encoded and durations use the format of
+// time.ParseDuration.  The deployments are keyed by their names.
+type jsonParams struct {
+	Base string `json:"base,omitempty"`
+
+	Name        string        `json:"name"`
+	Net         uint32        `json:"net"`
+	DefaultPort string        `json:"defaultport"`
+	DNSSeeds    []jsonDNSSeed `json:"dnsseeds"`
+
+	ProtocolVersion    uint32 `json:"protocolversion,omitempty"`
+	MinProtocolVersion uint32 `json:"minprotocolversion,omitempty"`
+
+	GenesisBlock string `json:"genesisblock"`
+	GenesisHash  string `json:"genesishash,omitempty"`
+	PowLimit     string `json:"powlimit"`
+	PowLimitBits uint32 `json:"powlimitbits"`
+
+	BIP0034Height            int32               `json:"bip0034height"`
+	BIP0065Height            int32               `json:"bip0065height"`
+	BIP0066Height            int32               `json:"bip0066height"`
+	CoinbaseMaturity         uint16              `json:"coinbasematurity"`
+	BaseSubsidy              int64               `json:"basesubsidy"`
+	SubsidyReductionInterval int32               `json:"subsidyreductioninterval"`
+	FounderRewards           []jsonFounderReward `json:"founderrewards"`
+	MasternodePaymentHeight  int32               `json:"masternodepaymentheight"`
+	MasternodePaymentPercent int64               `json:"masternodepaymentpercent"`
+	MasternodeCollateral     int64               `json:"masternodecollateral"`
+	SuperblockStartHeight    int32               `json:"superblockstartheight"`
+	SuperblockCycle          int32               `json:"superblockcycle"`
+	SuperblockBudgetPercent  int64               `json:"superblockbudgetpercent"`
+
+	TargetTimespan           string `json:"targettimespan"`
+	TargetTimePerBlock       string `json:"targettimeperblock"`
+	RetargetAdjustmentFactor int64  `json:"retargetadjustmentfactor"`
+	ReduceMinDifficulty      bool   `json:"reducemindifficulty"`
+	MinDiffReductionTime     string `json:"mindiffreductiontime"`
+	GenerateSupported        bool   `json:"generatesupported"`
+
+	Checkpoints       []jsonCheckpoint   `json:"checkpoints"`
+	CheckpointPubKeys []string           `json:"checkpointpubkeys"`
+	UtxoSnapshots     []jsonUtxoSnapshot `json:"utxosnapshots"`
+
+	RuleChangeActivationThreshold uint32                    `json:"rulechangeactivationthreshold"`
+	MinerConfirmationWindow       uint32                    `json:"minerconfirmationwindow"`
+	Deployments                   map[string]jsonDeployment `json:"deployments"`
+
+	RelayNonStdTxs bool `json:"relaynonstdtxs"`
+
+	Bech32HRPSegwit         string `json:"bech32hrpsegwit"`
+	PubKeyHashAddrID        byte   `json:"pubkeyhashaddrid"`
+	ScriptHashAddrID        byte   `json:"scripthashaddrid"`
+	PrivateKeyID            byte   `json:"privatekeyid"`
+	WitnessPubKeyHashAddrID byte   `json:"witnesspubkeyhashaddrid"`
+	WitnessScriptHashAddrID byte   `json:"witnessscripthashaddrid"`
+	HDPrivateKeyID          string `json:"hdprivatekeyid"`
+	HDPublicKeyID           string `json:"hdpublickeyid"`
+	HDCoinType              uint32 `json:"hdcointype"`
+}
+
+// newJSONParams returns the JSON representation of the passed parameters.
+func newJSONParams(params *Params) (*jsonParams, error) {
+	var genesis bytes.Buffer
+	if params.GenesisBlock != nil {
+		if err := params.GenesisBlock.Serialize(&genesis); err != nil {
+			return nil, err
+		}
+	}
+	p := &jsonParams{
+		Name:                          params.Name,
+		Net:                           uint32(params.Net),
+		DefaultPort:                   params.DefaultPort,
+		ProtocolVersion:               params.ProtocolVersion,
+		MinProtocolVersion:            params.MinProtocolVersion,
+		GenesisBlock:                  hex.EncodeToString(genesis.Bytes()),
+		PowLimitBits:                  params.PowLimitBits,
+		BIP0034Height:                 params.BIP0034Height,
+		BIP0065Height:                 params.BIP0065Height,
+		BIP0066Height:                 params.BIP0066Height,
+		CoinbaseMaturity:              params.CoinbaseMaturity,
+		BaseSubsidy:                   params.Subsidy.BaseSubsidy,
+		SubsidyReductionInterval:      params.Subsidy.ReductionInterval,
+		MasternodePaymentHeight:       params.Subsidy.MasternodePaymentHeight,
+		MasternodePaymentPercent:      params.Subsidy.MasternodePaymentPercent,
+		MasternodeCollateral:          params.MasternodeCollateral,
+		SuperblockStartHeight:         params.Subsidy.SuperblockStartHeight,
+		SuperblockCycle:               params.Subsidy.SuperblockCycle,
+		SuperblockBudgetPercent:       params.Subsidy.SuperblockBudgetPercent,
+		TargetTimespan:                params.TargetTimespan.String(),
+		TargetTimePerBlock:            params.TargetTimePerBlock.String(),
+		RetargetAdjustmentFactor:      params.RetargetAdjustmentFactor,
+		ReduceMinDifficulty:           params.ReduceMinDifficulty,
+		MinDiffReductionTime:          params.MinDiffReductionTime.String(),
+		GenerateSupported:             params.GenerateSupported,
+		RuleChangeActivationThreshold: params.RuleChangeActivationThreshold,
+		MinerConfirmationWindow:       params.MinerConfirmationWindow,
+		Deployments:                   make(map[string]jsonDeployment),
+		RelayNonStdTxs:                params.RelayNonStdTxs,
+		Bech32HRPSegwit:               params.Bech32HRPSegwit,
+		PubKeyHashAddrID:              params.PubKeyHashAddrID,
+		ScriptHashAddrID:              params.ScriptHashAddrID,
+		PrivateKeyID:                  params.PrivateKeyID,
+		WitnessPubKeyHashAddrID:       params.WitnessPubKeyHashAddrID,
+		WitnessScriptHashAddrID:       params.WitnessScriptHashAddrID,
+		HDPrivateKeyID:                hex.EncodeToString(params.HDPrivateKeyID[:]),
+		HDPublicKeyID:                 hex.EncodeToString(params.HDPublicKeyID[:]),
+		HDCoinType:                    params.HDCoinType,
+	}
+	if params.GenesisHash != nil {
+		p.GenesisHash = params.GenesisHash.String()
+	}
+	if params.PowLimit != nil {
+		p.PowLimit = params.PowLimit.Text(16)
+	}
+	for _, seed := range params.DNSSeeds {
+		p.DNSSeeds = append(p.DNSSeeds, jsonDNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+	for _, reward := range params.Subsidy.FounderRewards {
+		p.FounderRewards = append(p.FounderRewards, jsonFounderReward{
+			StartHeight: reward.StartHeight,
+			EndHeight:   reward.EndHeight,
+			PkScript:    hex.EncodeToString(reward.PkScript),
+			Percent:     reward.Percent,
+		})
+	}
+	for _, checkpoint := range params.Checkpoints {
+		p.Checkpoints = append(p.Checkpoints, jsonCheckpoint{
+			Height: checkpoint.Height,
+			Hash:   checkpoint.Hash.String(),
+		})
+	}
+	for _, pubKey := range params.CheckpointPubKeys {
+		p.CheckpointPubKeys = append(p.CheckpointPubKeys,
+			hex.EncodeToString(pubKey))
+	}
+	for _, snapshot := range params.UtxoSnapshots {
+		p.UtxoSnapshots = append(p.UtxoSnapshots, jsonUtxoSnapshot{
+			Height:     snapshot.Height,
+			Hash:       snapshot.Hash.String(),
+			Commitment: snapshot.Commitment.String(),
+		})
+	}
+	for id, deployment := range params.Deployments {
+		p.Deployments[deploymentNames[id]] = jsonDeployment{
+			BitNumber:                     deployment.BitNumber,
+			StartTime:                     deployment.StartTime,
+			ExpireTime:                    deployment.ExpireTime,
+			MinerConfirmationWindow:       deployment.MinerConfirmationWindow,
+			RuleChangeActivationThreshold: deployment.RuleChangeActivationThreshold,
+		}
+	}
+	return p, nil
+}
+
+// decodeHash decodes the passed hash of the named field.
+func decodeHash(field, hashStr string) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	return hash, nil
+}
+
+// decodeHex decodes the passed hex-encoded bytes of the named field.
+func decodeHex(field, hexStr string) ([]byte, error) {
+	b, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	return b, nil
+}
+
+// decodeDuration decodes the passed duration of the named field.
+func decodeDuration(field, durationStr string) (time.Duration, error) {
+	d, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	return d, nil
+}
+
+// params returns the parameters the JSON representation describes.
+func (p *jsonParams) params() (*Params, error) {
+	params := &Params{
+		Name:                          p.Name,
+		Net:                           wire.BitcoinNet(p.Net),
+		DefaultPort:                   p.DefaultPort,
+		ProtocolVersion:               p.ProtocolVersion,
+		MinProtocolVersion:            p.MinProtocolVersion,
+		PowLimitBits:                  p.PowLimitBits,
+		BIP0034Height:                 p.BIP0034Height,
+		BIP0065Height:                 p.BIP0065Height,
+		BIP0066Height:                 p.BIP0066Height,
+		CoinbaseMaturity:              p.CoinbaseMaturity,
+		MasternodeCollateral:          p.MasternodeCollateral,
+		RetargetAdjustmentFactor:      p.RetargetAdjustmentFactor,
+		ReduceMinDifficulty:           p.ReduceMinDifficulty,
+		GenerateSupported:             p.GenerateSupported,
+		RuleChangeActivationThreshold: p.RuleChangeActivationThreshold,
+		MinerConfirmationWindow:       p.MinerConfirmationWindow,
+		RelayNonStdTxs:                p.RelayNonStdTxs,
+		Bech32HRPSegwit:               p.Bech32HRPSegwit,
+		PubKeyHashAddrID:              p.PubKeyHashAddrID,
+		ScriptHashAddrID:              p.ScriptHashAddrID,
+		PrivateKeyID:                  p.PrivateKeyID,
+		WitnessPubKeyHashAddrID:       p.WitnessPubKeyHashAddrID,
+		WitnessScriptHashAddrID:       p.WitnessScriptHashAddrID,
+		HDCoinType:                    p.HDCoinType,
+	}
+	params.Subsidy = SubsidySchedule{
+		BaseSubsidy:              p.BaseSubsidy,
+		ReductionInterval:        p.SubsidyReductionInterval,
+		MasternodePaymentHeight:  p.MasternodePaymentHeight,
+		MasternodePaymentPercent: p.MasternodePaymentPercent,
+		SuperblockStartHeight:    p.SuperblockStartHeight,
+		SuperblockCycle:          p.SuperblockCycle,
+		SuperblockBudgetPercent:  p.SuperblockBudgetPercent,
+	}
+
+	genesis, err := decodeHex("genesisblock", p.GenesisBlock)
+	if err != nil {
+		return nil, err
+	}
+	params.GenesisBlock = new(wire.MsgBlock)
+	if err := params.GenesisBlock.Deserialize(bytes.NewReader(genesis)); err != nil {
+		return nil, fmt.Errorf("invalid genesisblock: %v", err)
+	}
+	if p.GenesisHash != "" {
+		params.GenesisHash, err = decodeHash("genesishash", p.GenesisHash)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		hash := params.GenesisBlock.BlockHash()
+		params.GenesisHash = &hash
+	}
+
+	powLimit, ok := new(big.Int).SetString(p.PowLimit, 16)
+	if !ok || powLimit.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid powlimit %q", p.PowLimit)
+	}
+	params.PowLimit = powLimit
+
+	params.TargetTimespan, err = decodeDuration("targettimespan",
+		p.TargetTimespan)
+	if err != nil {
+		return nil, err
+	}
+	params.TargetTimePerBlock, err = decodeDuration("targettimeperblock",
+		p.TargetTimePerBlock)
+	if err != nil {
+		return nil, err
+	}
+	params.MinDiffReductionTime, err = decodeDuration("mindiffreductiontime",
+		p.MinDiffReductionTime)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seed := range p.DNSSeeds {
+		params.DNSSeeds = append(params.DNSSeeds, DNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+	for _, reward := range p.FounderRewards {
+		pkScript, err := decodeHex("founder reward pkscript",
+			reward.PkScript)
+		if err != nil {
+			return nil, err
+		}
+		subsidy := &params.Subsidy
+		subsidy.FounderRewards = append(subsidy.FounderRewards, FounderReward{
+			StartHeight: reward.StartHeight,
+			EndHeight:   reward.EndHeight,
+			PkScript:    pkScript,
+			Percent:     reward.Percent,
+		})
+	}
+	for _, checkpoint := range p.Checkpoints {
+		hash, err := decodeHash("checkpoint hash", checkpoint.Hash)
+		if err != nil {
+			return nil, err
+		}
+		params.Checkpoints = append(params.Checkpoints, Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   hash,
+		})
+	}
+	for _, pubKeyStr := range p.CheckpointPubKeys {
+		pubKey, err := decodeHex("checkpoint public key", pubKeyStr)
+		if err != nil {
+			return nil, err
+		}
+		params.CheckpointPubKeys = append(params.CheckpointPubKeys, pubKey)
+	}
+	for _, snapshot := range p.UtxoSnapshots {
+		hash, err := decodeHash("utxo snapshot hash", snapshot.Hash)
+		if err != nil {
+			return nil, err
+		}
+		commitment, err := decodeHash("utxo snapshot commitment",
+			snapshot.Commitment)
+		if err != nil {
+			return nil, err
+		}
+		params.UtxoSnapshots = append(params.UtxoSnapshots, UtxoSnapshot{
+			Height:     snapshot.Height,
+			Hash:       hash,
+			Commitment: commitment,
+		})
+	}
+
+	for name, deployment := range p.Deployments {
+		id := -1
+		for i, deploymentName := range deploymentNames {
+			if name == deploymentName {
+				id = i
+				break
+			}
+		}
+		if id < 0 {
+			return nil, fmt.Errorf("unknown deployment %q", name)
+		}
+		params.Deployments[id] = ConsensusDeployment{
+			BitNumber:                     deployment.BitNumber,
+			StartTime:                     deployment.StartTime,
+			ExpireTime:                    deployment.ExpireTime,
+			MinerConfirmationWindow:       deployment.MinerConfirmationWindow,
+			RuleChangeActivationThreshold: deployment.RuleChangeActivationThreshold,
+		}
+	}
+
+	for _, id := range []struct {
+		field string
+		hex   string
+		dst   *[4]byte
+	}{
+		{"hdprivatekeyid", p.HDPrivateKeyID, &params.HDPrivateKeyID},
+		{"hdpublickeyid", p.HDPublicKeyID, &params.HDPublicKeyID},
+	} {
+		b, err := decodeHex(id.field, id.hex)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != len(id.dst) {
+			return nil, fmt.Errorf("invalid %s: must be %d bytes",
+				id.field, len(id.dst))
+		}
+		copy(id.dst[:], b)
+	}
+
+	setDeprecatedSubsidyFields(params)
+	return params, nil
+}
+
+// EncodeParams writes the JSON representation of the passed parameters, which
+// DecodeParams reads back, to w.
+func EncodeParams(w io.Writer, params *Params) error {
+	p, err := newJSONParams(params)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// DecodeParams reads the parameters of a custom network from their JSON
+// representation.
+//
+// The parameters start out as a copy of the parameters of the default network
+// named by the "base" field, which defaults to "regtest", and every other field
+// present in the JSON replaces the corresponding parameter.  Lists such as the
+// DNS seeds and checkpoints are replaced as a whole, while the deployments
+// replace the deployments of the base network with the same name.  The genesis
+// hash is computed from the genesis block when the genesis block is replaced
+// without also specifying its hash.
+//
+// The returned parameters are not registered.  See RegisterParams.
+func DecodeParams(r io.Reader) (*Params, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var header struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid network parameters: %v", err)
+	}
+	if header.Base == "" {
+		header.Base = defaultBaseParams
+	}
+	var base *Params
+	for _, params := range defaultNets {
+		if params.Name == header.Base {
+			base = params
+			break
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("unknown base network %q", header.Base)
+	}
+
+	p, err := newJSONParams(base)
+	if err != nil {
+		return nil, err
+	}
+	baseGenesis := p.GenesisBlock
+	p.GenesisHash = ""
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid network parameters: %v", err)
+	}
+
+	// Keep the hash of the genesis block of the base network, which is not
+	// necessarily the hash of its header, unless it was replaced.
+	if p.GenesisHash == "" && p.GenesisBlock == baseGenesis {
+		p.GenesisHash = base.GenesisHash.String()
+	}
+	return p.params()
+}
+
+// LoadParams reads the parameters of a custom network from the JSON file at the
+// passed path.  See DecodeParams for the format of the file.
+func LoadParams(path string) (*Params, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	params, err := DecodeParams(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return params, nil
+}
+
+// checkParams returns an error when the passed parameters of a custom network
+// are inconsistent in a way which would make the chain unusable.
+func checkParams(params *Params) error {
+	switch {
+	case params.Name == "":
+		return fmt.Errorf("network has no name")
+	case params.GenesisBlock == nil || params.GenesisHash == nil:
+		return fmt.Errorf("network has no genesis block")
+	case params.PowLimit == nil || params.PowLimit.Sign() <= 0:
+		return fmt.Errorf("proof-of-work limit must be positive")
+	case params.Subsidy.BaseSubsidy <= 0:
+		return fmt.Errorf("base subsidy must be positive")
+	case params.Subsidy.ReductionInterval < 0:
+		return fmt.Errorf("subsidy reduction interval must not be negative")
+	case params.TargetTimePerBlock <= 0:
+		return fmt.Errorf("target time per block must be positive")
+	case params.TargetTimespan < params.TargetTimePerBlock:
+		return fmt.Errorf("target timespan must be at least the target " +
+			"time per block")
+	case params.RetargetAdjustmentFactor <= 0:
+		return fmt.Errorf("retarget adjustment factor must be positive")
+	case params.MinerConfirmationWindow == 0:
+		return fmt.Errorf("miner confirmation window must be positive")
+	case params.RuleChangeActivationThreshold > params.MinerConfirmationWindow:
+		return fmt.Errorf("rule change activation threshold exceeds the " +
+			"miner confirmation window")
+	case params.Subsidy.SuperblockCycle < 0:
+		return fmt.Errorf("superblock cycle must not be negative")
+	case params.MasternodeCollateral < 0:
+		return fmt.Errorf("masternode collateral must not be negative")
+	}
+
+	// The shares of the block subsidy paid to the founders and masternodes
+	// must leave a non-negative share to the miner at every height.
+	subsidy := &params.Subsidy
+	percents := []int64{subsidy.MasternodePaymentPercent,
+		subsidy.SuperblockBudgetPercent}
+	for _, reward := range subsidy.FounderRewards {
+		percents = append(percents, reward.Percent)
+	}
+	for _, percent := range percents {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("subsidy share of %d%% is out of range",
+				percent)
+		}
+	}
+	for _, reward := range subsidy.FounderRewards {
+		total := reward.Percent + subsidy.MasternodePaymentPercent
+		if total > 100 {
+			return fmt.Errorf("founder reward and masternode payment "+
+				"shares add up to %d%%", total)
+		}
+	}
+	return nil
+}
+
+// RegisterParams checks the passed parameters of a fully custom network, such
+// as ones read with LoadParams, and registers them like Register.  An error is
+// returned when the parameters are inconsistent and ErrDuplicateNet when the
+// network is already registered.
+func RegisterParams(params *Params) error {
+	if err := checkParams(params); err != nil {
+		return fmt.Errorf("invalid %q network parameters: %v",
+			params.Name, err)
+	}
+	return Register(params)
+}
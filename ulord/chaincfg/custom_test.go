@@ -0,0 +1,163 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEncodeDecodeParams ensures the default networks survive a round trip
+// through their JSON representation.
+func TestEncodeDecodeParams(t *testing.T) {
+	for _, params := range defaultNets {
+		var buf bytes.Buffer
+		if err := EncodeParams(&buf, params); err != nil {
+			t.Fatalf("EncodeParams(%s): unexpected error: %v",
+				params.Name, err)
+		}
+		decoded, err := DecodeParams(&buf)
+		if err != nil {
+			t.Fatalf("DecodeParams(%s): unexpected error: %v",
+				params.Name, err)
+		}
+
+		// Empty lists are decoded as nil.
+		want := *params
+		if len(want.DNSSeeds) == 0 {
+			want.DNSSeeds = nil
+		}
+		if !reflect.DeepEqual(decoded, &want) {
+			t.Fatalf("DecodeParams(%s): got %+v, want %+v",
+				params.Name, decoded, &want)
+		}
+	}
+}
+
+// TestDecodeParamsBase ensures custom networks inherit the parameters they do
+// not specify from their base network.
+func TestDecodeParamsBase(t *testing.T) {
+	params, err := DecodeParams(strings.NewReader(`{
+		"base": "testnet3",
+		"name": "privnet",
+		"net": 1234,
+		"pubkeyhashaddrid": 50,
+		"masternodecollateral": 1000000000000,
+		"targettimeperblock": "30s",
+		"dnsseeds": [{"host": "seed.example.com", "hasfiltering": true}],
+		"deployments": {"csv": {"bitnumber": 1, "starttime": 0,
+			"expiretime": 100}}
+	}`))
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error: %v", err)
+	}
+
+	want := TestNet3Params
+	want.Name = "privnet"
+	want.Net = 1234
+	want.PubKeyHashAddrID = 50
+	want.MasternodeCollateral = 1000000000000
+	want.TargetTimePerBlock = 30 * time.Second
+	want.DNSSeeds = []DNSSeed{{Host: "seed.example.com", HasFiltering: true}}
+	want.Deployments[DeploymentCSV] = ConsensusDeployment{
+		BitNumber:  1,
+		ExpireTime: 100,
+	}
+	if !reflect.DeepEqual(params, &want) {
+		t.Fatalf("DecodeParams: got %+v, want %+v", params, &want)
+	}
+
+	// Replacing the genesis block without its hash must compute the hash.
+	genesis := *RegressionNetParams.GenesisBlock
+	genesis.Header.Nonce++
+	var buf bytes.Buffer
+	custom := RegressionNetParams
+	custom.GenesisBlock = &genesis
+	if err := EncodeParams(&buf, &custom); err != nil {
+		t.Fatalf("EncodeParams: unexpected error: %v", err)
+	}
+	json := strings.Replace(buf.String(), `"genesishash"`, `"unused"`, 1)
+	params, err = DecodeParams(strings.NewReader(json))
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error: %v", err)
+	}
+	if hash := genesis.BlockHash(); *params.GenesisHash != hash {
+		t.Fatalf("DecodeParams: got genesis hash %v, want %v",
+			params.GenesisHash, hash)
+	}
+}
+
+// TestDecodeParamsErrors ensures invalid JSON representations of network
+// parameters are rejected.
+func TestDecodeParamsErrors(t *testing.T) {
+	tests := []string{
+		`{`,
+		`{"base": "unknown"}`,
+		`{"genesisblock": "zz"}`,
+		`{"genesisblock": "00"}`,
+		`{"genesishash": "zz"}`,
+		`{"powlimit": "-1"}`,
+		`{"targettimespan": "1 day"}`,
+		`{"deployments": {"unknown": {}}}`,
+		`{"hdpublickeyid": "0102"}`,
+		`{"founderrewards": [{"pkscript": "z"}]}`,
+	}
+	for _, test := range tests {
+		if _, err := DecodeParams(strings.NewReader(test)); err == nil {
+			t.Fatalf("DecodeParams(%s): unexpected success", test)
+		}
+	}
+}
+
+// TestRegisterParams ensures inconsistent custom networks are rejected and
+// valid ones are registered.
+func TestRegisterParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Params)
+	}{
+		{"no name", func(p *Params) { p.Name = "" }},
+		{"no pow limit", func(p *Params) { p.PowLimit = nil }},
+		{"no subsidy", func(p *Params) { p.Subsidy.BaseSubsidy = 0 }},
+		{"negative interval", func(p *Params) { p.Subsidy.ReductionInterval = -1 }},
+		{"no target time", func(p *Params) { p.TargetTimePerBlock = 0 }},
+		{"short timespan", func(p *Params) {
+			p.TargetTimespan = p.TargetTimePerBlock - 1
+		}},
+		{"no adjustment", func(p *Params) { p.RetargetAdjustmentFactor = 0 }},
+		{"no window", func(p *Params) { p.MinerConfirmationWindow = 0 }},
+		{"high threshold", func(p *Params) {
+			p.RuleChangeActivationThreshold = p.MinerConfirmationWindow + 1
+		}},
+		{"negative collateral", func(p *Params) { p.MasternodeCollateral = -1 }},
+		{"masternode share", func(p *Params) { p.Subsidy.MasternodePaymentPercent = 101 }},
+		{"total share", func(p *Params) {
+			p.Subsidy.MasternodePaymentPercent = 60
+			p.Subsidy.FounderRewards = []FounderReward{{Percent: 50}}
+		}},
+	}
+	for _, test := range tests {
+		params := RegressionNetParams
+		params.Name = "customnet"
+		params.Net = 0xfeedbeef
+		test.modify(&params)
+		if err := RegisterParams(&params); err == nil {
+			t.Fatalf("RegisterParams(%s): unexpected success", test.name)
+		}
+	}
+
+	params := RegressionNetParams
+	params.Name = "customnet"
+	params.Net = 0xfeedbeef
+	if err := RegisterParams(&params); err != nil {
+		t.Fatalf("RegisterParams: unexpected error: %v", err)
+	}
+	if err := RegisterParams(&params); err != ErrDuplicateNet {
+		t.Fatalf("RegisterParams: got %v, want %v", err, ErrDuplicateNet)
+	}
+}
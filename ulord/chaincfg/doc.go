@@ -58,4 +58,16 @@
 // non-standard network.  As a general rule of thumb, all network parameters
 // should be unique to the network, but parameter collisions can still occur
 // (unfortunately, this is the case with regtest and testnet3 sharing magics).
+//
+// Such parameters may also be read at runtime with LoadParams or DecodeParams
+// from a JSON file which only lists the parameters that differ from one of the
+// default networks, and registered with RegisterParams, so private networks do
+// not require recompiling applications.
+//
+// Networks whose proof of work is not the double SHA-256 block hash, such as
+// Ulord's CryptoHello, provide their hash with RegisterPowHash.  It is consulted
+// through Params.PowHash when blocks are validated and mined.  This package only
+// provides the registry: it does not implement CryptoHello, and none of the
+// default networks register a hash, so they use the block hash until an
+// implementation is registered for them.
 package chaincfg
@@ -0,0 +1,36 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"io"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// MessageVersion returns the protocol version messages of the network are
+// encoded with when no version was negotiated with a peer, which is
+// ProtocolVersion when it is set and wire.ProtocolVersion otherwise.
+func (p *Params) MessageVersion() uint32 {
+	if p.ProtocolVersion != 0 {
+		return p.ProtocolVersion
+	}
+	return wire.ProtocolVersion
+}
+
+// WriteMessage writes the passed message to w with the magic bytes of the
+// network, encoded with the protocol version returned by MessageVersion.
+// Unlike wire.WriteMessage, it allows messages of several networks to be
+// written in one process without passing their magic and version around.
+func (p *Params) WriteMessage(w io.Writer, msg wire.Message) error {
+	return wire.WriteMessage(w, msg, p.MessageVersion(), p.Net)
+}
+
+// ReadMessage reads the next message of the network from r, decoded with the
+// protocol version returned by MessageVersion.  Messages with the magic bytes
+// of other networks are rejected like they are by wire.ReadMessage.
+func (p *Params) ReadMessage(r io.Reader) (wire.Message, []byte, error) {
+	return wire.ReadMessage(r, p.MessageVersion(), p.Net)
+}
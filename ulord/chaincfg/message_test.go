@@ -0,0 +1,49 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// TestParamsMessages ensures messages are encoded with the magic bytes and
+// protocol version of the network they are written for.
+func TestParamsMessages(t *testing.T) {
+	if v := SimNetParams.MessageVersion(); v != wire.ProtocolVersion {
+		t.Fatalf("MessageVersion: got %d, want %d", v, wire.ProtocolVersion)
+	}
+
+	// Use a network with its own magic and an older protocol version next
+	// to simnet in the same process.
+	bridged := SimNetParams
+	bridged.Net = SimNetParams.Net + 1
+	bridged.ProtocolVersion = wire.SendHeadersVersion
+	if v := bridged.MessageVersion(); v != wire.SendHeadersVersion {
+		t.Fatalf("MessageVersion: got %d, want %d", v,
+			wire.SendHeadersVersion)
+	}
+
+	var buf bytes.Buffer
+	if err := bridged.WriteMessage(&buf, wire.NewMsgPing(42)); err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	msg, _, err := bridged.ReadMessage(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if ping, ok := msg.(*wire.MsgPing); !ok || ping.Nonce != 42 {
+		t.Fatalf("ReadMessage: got %v, want ping with nonce 42", msg)
+	}
+
+	if _, _, err := SimNetParams.ReadMessage(bytes.NewReader(encoded)); err == nil {
+		t.Fatal("ReadMessage: expected error for message of another " +
+			"network")
+	}
+}
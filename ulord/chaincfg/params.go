@@ -40,6 +40,25 @@ var (
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
 )
 
+// baseSubsidy is the subsidy of the blocks before the first reduction on the
+// default networks.
+const baseSubsidy = 50 * 1e8
+
+// FounderReward defines a share of the block subsidy which the coinbase
+// transactions of the blocks in a range of heights must pay to a fixed script.
+type FounderReward struct {
+	// StartHeight and EndHeight are the first and last heights of the
+	// range.  An EndHeight of zero means the range never ends.
+	StartHeight int32
+	EndHeight   int32
+
+	// PkScript is the script the reward must be paid to.
+	PkScript []byte
+
+	// Percent is the share of the block subsidy paid to PkScript.
+	Percent int64
+}
+
 // Checkpoint identifies a known good point in the block chain.  Using
 // checkpoints allows a few optimizations for old blocks during initial download
 // and also prevents forks from old blocks.
@@ -52,6 +71,14 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// UtxoSnapshot identifies a utxo set snapshot which is trusted to bootstrap a
+// node from.
+type UtxoSnapshot struct {
+	Height     int32
+	Hash       *chainhash.Hash
+	Commitment *chainhash.Hash
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -76,6 +103,16 @@ type ConsensusDeployment struct {
 	// ExpireTime is the median block time after which the attempted
 	// deployment expires.
 	ExpireTime uint64
+
+	// MinerConfirmationWindow is the number of blocks in each period in
+	// which the votes of the miners for the deployment are counted.  Zero
+	// uses the MinerConfirmationWindow of the chain parameters.
+	MinerConfirmationWindow uint32
+
+	// RuleChangeActivationThreshold is the number of blocks in a period
+	// which must vote for the deployment to lock it in.  Zero uses the
+	// RuleChangeActivationThreshold of the chain parameters.
+	RuleChangeActivationThreshold uint32
 }
 
 // Constants that define the deployment offset in the deployments field of the
@@ -103,6 +140,23 @@ const (
 	DefinedDeployments
 )
 
+// deploymentNames maps the deployment IDs to the names used to report them,
+// such as by the getblockchaininfo RPC.
+var deploymentNames = [DefinedDeployments]string{
+	DeploymentTestDummy: "dummy",
+	DeploymentCSV:       "csv",
+	DeploymentSegwit:    "segwit",
+}
+
+// DeploymentName returns the name of the deployment with the passed ID or an
+// empty string when the ID is not defined.
+func DeploymentName(deploymentID uint32) string {
+	if deploymentID >= DefinedDeployments {
+		return ""
+	}
+	return deploymentNames[deploymentID]
+}
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -113,6 +167,16 @@ type Params struct {
 	// Net defines the magic bytes used to identify the network.
 	Net wire.BitcoinNet
 
+	// ProtocolVersion is the highest protocol version peers of the network
+	// advertise and negotiate.  The highest version supported by the peer
+	// package is used when it is zero.
+	ProtocolVersion uint32
+
+	// MinProtocolVersion is the lowest protocol version of remote peers
+	// which is accepted on the network.  The lowest version supported by
+	// the peer package is used when it is zero.
+	MinProtocolVersion uint32
+
 	// DefaultPort defines the default peer-to-peer port for the network.
 	DefaultPort string
 
@@ -144,10 +208,40 @@ type Params struct {
 	// coins (coinbase transactions) can be spent.
 	CoinbaseMaturity uint16
 
+	// Subsidy defines the block subsidy and how it is shared between the
+	// miners, the founders, the masternodes, and the superblock budget.
+	Subsidy SubsidySchedule
+
 	// SubsidyReductionInterval is the interval of blocks before the subsidy
 	// is reduced.
+	//
+	// Deprecated: Use Subsidy.ReductionInterval.  This field mirrors it on
+	// the networks defined by this package and by DecodeParams, however
+	// it is not consulted when blocks are validated or created.
 	SubsidyReductionInterval int32
 
+	// FounderRewards, MasternodePaymentHeight, MasternodePaymentPercent,
+	// SuperblockStartHeight, SuperblockCycle, and SuperblockBudgetPercent
+	// are the shares of the block subsidy paid to the founders, the
+	// masternodes, and the superblock budget.
+	//
+	// Deprecated: Use the fields of the same names of Subsidy.  These
+	// fields mirror them on the networks defined by this package and by
+	// DecodeParams, however they are not consulted when blocks are
+	// validated or created.
+	FounderRewards           []FounderReward
+	MasternodePaymentHeight  int32
+	MasternodePaymentPercent int64
+	SuperblockStartHeight    int32
+	SuperblockCycle          int32
+	SuperblockBudgetPercent  int64
+
+	// MasternodeCollateral is the amount in satoshi of the output which
+	// must back each masternode.  It is left to the masternode list, which
+	// is maintained outside of the block chain, to verify it and is zero
+	// when the network does not define it.
+	MasternodeCollateral int64
+
 	// TargetTimespan is the desired amount of time that should elapse
 	// before the block difficulty requirement is examined to determine how
 	// it should be changed in order to maintain the desired block
@@ -181,6 +275,14 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// CheckpointPubKeys are the serialized public keys trusted to sign
+	// files of additional checkpoints.
+	CheckpointPubKeys [][]byte
+
+	// UtxoSnapshots are the utxo set snapshots whose commitments are known
+	// to be correct.
+	UtxoSnapshots []UtxoSnapshot
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -233,14 +335,18 @@ var MainNetParams = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock:             &genesisBlock,
-	GenesisHash:              &genesisHash,
-	PowLimit:                 mainPowLimit,
-	PowLimitBits:             0x1d00ffff,
-	BIP0034Height:            227931, // 000000000000024b89b42a942fe0d9fea3bb44ab7bd1b19115dd6a759c0808b8
-	BIP0065Height:            388381, // 000000000000000004c2b624ed5d7756c508d90fd0da2c7c679febfa6c4735f0
-	BIP0066Height:            363725, // 00000000000000000379eaa19dce8c9b722d46ae6a57c2f1a988119488b50931
-	CoinbaseMaturity:         100,
+	GenesisBlock:     &genesisBlock,
+	GenesisHash:      &genesisHash,
+	PowLimit:         mainPowLimit,
+	PowLimitBits:     0x1d00ffff,
+	BIP0034Height:    227931, // 000000000000024b89b42a942fe0d9fea3bb44ab7bd1b19115dd6a759c0808b8
+	BIP0065Height:    388381, // 000000000000000004c2b624ed5d7756c508d90fd0da2c7c679febfa6c4735f0
+	BIP0066Height:    363725, // 00000000000000000379eaa19dce8c9b722d46ae6a57c2f1a988119488b50931
+	CoinbaseMaturity: 100,
+	Subsidy: SubsidySchedule{
+		BaseSubsidy:       baseSubsidy,
+		ReductionInterval: 210000,
+	},
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -312,14 +418,18 @@ var RegressionNetParams = Params{
 	DNSSeeds:    []DNSSeed{},
 
 	// Chain parameters
-	GenesisBlock:             &regTestGenesisBlock,
-	GenesisHash:              &regTestGenesisHash,
-	PowLimit:                 regressionPowLimit,
-	PowLimitBits:             0x207fffff,
-	CoinbaseMaturity:         100,
-	BIP0034Height:            100000000, // Not active - Permit ver 1 blocks
-	BIP0065Height:            1351,      // Used by regression tests
-	BIP0066Height:            1251,      // Used by regression tests
+	GenesisBlock:     &regTestGenesisBlock,
+	GenesisHash:      &regTestGenesisHash,
+	PowLimit:         regressionPowLimit,
+	PowLimitBits:     0x207fffff,
+	CoinbaseMaturity: 100,
+	BIP0034Height:    100000000, // Not active - Permit ver 1 blocks
+	BIP0065Height:    1351,      // Used by regression tests
+	BIP0066Height:    1251,      // Used by regression tests
+	Subsidy: SubsidySchedule{
+		BaseSubsidy:       baseSubsidy,
+		ReductionInterval: 150,
+	},
 	SubsidyReductionInterval: 150,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -391,14 +501,18 @@ var TestNet3Params = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock:             &testNet3GenesisBlock,
-	GenesisHash:              &testNet3GenesisHash,
-	PowLimit:                 testNet3PowLimit,
-	PowLimitBits:             0x1d00ffff,
-	BIP0034Height:            21111,  // 0000000023b3a96d3484e5abb3755c413e7d41500f8e2a5c3f0dd01299cd8ef8
-	BIP0065Height:            581885, // 00000000007f6655f22f98e72ed80d8b06dc761d5da09df0fa1dc4be4f861eb6
-	BIP0066Height:            330776, // 000000002104c8c45e99a8853285a3b592602a3ccde2b832481da85e9e4ba182
-	CoinbaseMaturity:         100,
+	GenesisBlock:     &testNet3GenesisBlock,
+	GenesisHash:      &testNet3GenesisHash,
+	PowLimit:         testNet3PowLimit,
+	PowLimitBits:     0x1d00ffff,
+	BIP0034Height:    21111,  // 0000000023b3a96d3484e5abb3755c413e7d41500f8e2a5c3f0dd01299cd8ef8
+	BIP0065Height:    581885, // 00000000007f6655f22f98e72ed80d8b06dc761d5da09df0fa1dc4be4f861eb6
+	BIP0066Height:    330776, // 000000002104c8c45e99a8853285a3b592602a3ccde2b832481da85e9e4ba182
+	CoinbaseMaturity: 100,
+	Subsidy: SubsidySchedule{
+		BaseSubsidy:       baseSubsidy,
+		ReductionInterval: 210000,
+	},
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -483,14 +597,18 @@ var SimNetParams = Params{
 	DNSSeeds:    []DNSSeed{}, // NOTE: There must NOT be any seeds.
 
 	// Chain parameters
-	GenesisBlock:             &simNetGenesisBlock,
-	GenesisHash:              &simNetGenesisHash,
-	PowLimit:                 simNetPowLimit,
-	PowLimitBits:             0x207fffff,
-	BIP0034Height:            0, // Always active on simnet
-	BIP0065Height:            0, // Always active on simnet
-	BIP0066Height:            0, // Always active on simnet
-	CoinbaseMaturity:         100,
+	GenesisBlock:     &simNetGenesisBlock,
+	GenesisHash:      &simNetGenesisHash,
+	PowLimit:         simNetPowLimit,
+	PowLimitBits:     0x207fffff,
+	BIP0034Height:    0, // Always active on simnet
+	BIP0065Height:    0, // Always active on simnet
+	BIP0066Height:    0, // Always active on simnet
+	CoinbaseMaturity: 100,
+	Subsidy: SubsidySchedule{
+		BaseSubsidy:       baseSubsidy,
+		ReductionInterval: 210000,
+	},
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
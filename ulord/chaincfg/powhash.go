@@ -0,0 +1,58 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+var (
+	// powHashesMtx protects powHashes.
+	powHashesMtx sync.RWMutex
+
+	// powHashes maps the networks which registered a proof-of-work hash to
+	// it.  They are keyed by the magic bytes of the network so copies of
+	// the parameters, such as the modified copies used by tests, share the
+	// hash of the network.
+	powHashes = make(map[wire.BitcoinNet]chainhash.PowHashFunc)
+)
+
+// RegisterPowHash registers the proof-of-work hash of the network defined by
+// the passed parameters, replacing any hash registered for it before, so
+// implementations such as Ulord's CryptoHello can be provided by packages
+// outside of chaincfg.  Networks without a registered hash, which includes all
+// of the default networks, use the double SHA-256 hash of the block header,
+// which is also its block hash.
+//
+// This function is safe for concurrent access.
+func RegisterPowHash(params *Params, powHash chainhash.PowHashFunc) {
+	powHashesMtx.Lock()
+	powHashes[params.Net] = powHash
+	powHashesMtx.Unlock()
+}
+
+// PowHash returns the proof-of-work hash of the passed block header, which
+// must be less than the target difficulty of the block, using the hash
+// registered for the network with RegisterPowHash.
+//
+// This function is safe for concurrent access.
+func (p *Params) PowHash(header *wire.BlockHeader) chainhash.Hash {
+	powHashesMtx.RLock()
+	powHash := powHashes[p.Net]
+	powHashesMtx.RUnlock()
+	if powHash == nil {
+		return header.BlockHash()
+	}
+
+	// Ignore the error since serializing the header to a buffer can only
+	// fail when running out of memory, which panics.
+	buf := bytes.NewBuffer(make([]byte, 0, wire.MaxBlockHeaderPayload))
+	_ = header.Serialize(buf)
+	return powHash(buf.Bytes())
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// TestPowHash ensures networks use the block hash as their proof-of-work hash
+// unless they registered another one.
+func TestPowHash(t *testing.T) {
+	header := &RegressionNetParams.GenesisBlock.Header
+	params := RegressionNetParams
+	params.Net = 0xfeedface
+	if got, want := params.PowHash(header), header.BlockHash(); got != want {
+		t.Fatalf("PowHash: got %v without registered hash, want %v",
+			got, want)
+	}
+
+	var serialized []byte
+	RegisterPowHash(&params, func(b []byte) chainhash.Hash {
+		serialized = b
+		return chainhash.HashH(b)
+	})
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	want := chainhash.HashH(buf.Bytes())
+	if got := params.PowHash(header); got != want {
+		t.Fatalf("PowHash: got %v, want %v", got, want)
+	}
+	if !bytes.Equal(serialized, buf.Bytes()) {
+		t.Fatalf("PowHash: hashed %x, want serialized header %x",
+			serialized, buf.Bytes())
+	}
+
+	// Other networks must not be affected by the registration.
+	if got := RegressionNetParams.PowHash(header); got != header.BlockHash() {
+		t.Fatalf("PowHash: got %v for regtest, want block hash", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+// FounderPayout is an amount the coinbase transaction of a block must pay to
+// a founder.
+type FounderPayout struct {
+	PkScript []byte
+	Amount   int64
+}
+
+// SubsidySchedule defines the block subsidy of a network and the shares of it
+// which the coinbase transactions must pay to the founders, the masternodes,
+// and the superblock budget.  It is the single model of the subsidy consulted
+// when blocks are validated and created, so tests may alter it on a copy of
+// the chain parameters.
+type SubsidySchedule struct {
+	// BaseSubsidy is the subsidy of the blocks before the first reduction.
+	BaseSubsidy int64
+
+	// ReductionInterval is the interval of blocks before the subsidy is
+	// halved.  The subsidy is never reduced when it is zero.
+	ReductionInterval int32
+
+	// FounderRewards are the shares of the block subsidy the coinbase
+	// transactions must pay to the founders.
+	FounderRewards []FounderReward
+
+	// MasternodePaymentHeight is the height of the first block whose
+	// coinbase transaction must pay MasternodePaymentPercent of the block
+	// subsidy to a masternode.  Masternode payments are not required when
+	// MasternodePaymentPercent is zero.
+	MasternodePaymentHeight  int32
+	MasternodePaymentPercent int64
+
+	// SuperblockStartHeight is the height of the first superblock and
+	// SuperblockCycle is the number of blocks between superblocks.  The
+	// coinbase transaction of a superblock may pay out a budget of
+	// SuperblockBudgetPercent of the subsidy of each block of the cycle on
+	// top of its own subsidy.  There are no superblocks when
+	// SuperblockCycle is zero.
+	SuperblockStartHeight   int32
+	SuperblockCycle         int32
+	SuperblockBudgetPercent int64
+}
+
+// BlockSubsidy returns the subsidy of the block at the given height, which is
+// BaseSubsidy halved every ReductionInterval blocks.  Mathematically this is:
+// BaseSubsidy / 2^(height/ReductionInterval)
+func (s *SubsidySchedule) BlockSubsidy(height int32) int64 {
+	if s.ReductionInterval == 0 {
+		return s.BaseSubsidy
+	}
+	return s.BaseSubsidy >> uint(height/s.ReductionInterval)
+}
+
+// FounderPayouts returns the founder rewards the coinbase transaction of the
+// block at the given height must pay.
+func (s *SubsidySchedule) FounderPayouts(height int32) []FounderPayout {
+	var payouts []FounderPayout
+	subsidy := s.BlockSubsidy(height)
+	for i := range s.FounderRewards {
+		reward := &s.FounderRewards[i]
+		if height < reward.StartHeight || (reward.EndHeight != 0 &&
+			height > reward.EndHeight) {
+
+			continue
+		}
+		payouts = append(payouts, FounderPayout{
+			PkScript: reward.PkScript,
+			Amount:   subsidy * reward.Percent / 100,
+		})
+	}
+	return payouts
+}
+
+// MasternodePayment returns the share of the subsidy the coinbase transaction
+// of the block at the given height must pay to a masternode.  It is zero when
+// the block does not require a masternode payment.
+func (s *SubsidySchedule) MasternodePayment(height int32) int64 {
+	if s.MasternodePaymentPercent == 0 || height < s.MasternodePaymentHeight {
+		return 0
+	}
+	return s.BlockSubsidy(height) * s.MasternodePaymentPercent / 100
+}
+
+// IsSuperblock returns whether the block at the given height is a superblock
+// which may pay out the budget approved by the governance system.
+func (s *SubsidySchedule) IsSuperblock(height int32) bool {
+	if s.SuperblockCycle <= 0 || height < s.SuperblockStartHeight {
+		return false
+	}
+	return (height-s.SuperblockStartHeight)%s.SuperblockCycle == 0
+}
+
+// SuperblockBudget returns the amount the coinbase transaction of the block at
+// the given height may pay out on top of the block subsidy and fees.  It is the
+// budget share of the subsidy of each block of the superblock cycle ending at a
+// superblock and zero for all other blocks.
+func (s *SubsidySchedule) SuperblockBudget(height int32) int64 {
+	if !s.IsSuperblock(height) {
+		return 0
+	}
+
+	var budget int64
+	for h := height - s.SuperblockCycle + 1; h <= height; h++ {
+		budget += s.BlockSubsidy(h) * s.SuperblockBudgetPercent / 100
+	}
+	return budget
+}
+
+// setDeprecatedSubsidyFields sets the deprecated fields of the passed
+// parameters which mirror their subsidy schedule.
+func setDeprecatedSubsidyFields(params *Params) {
+	subsidy := &params.Subsidy
+	params.SubsidyReductionInterval = subsidy.ReductionInterval
+	params.FounderRewards = subsidy.FounderRewards
+	params.MasternodePaymentHeight = subsidy.MasternodePaymentHeight
+	params.MasternodePaymentPercent = subsidy.MasternodePaymentPercent
+	params.SuperblockStartHeight = subsidy.SuperblockStartHeight
+	params.SuperblockCycle = subsidy.SuperblockCycle
+	params.SuperblockBudgetPercent = subsidy.SuperblockBudgetPercent
+}
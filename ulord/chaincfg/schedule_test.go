@@ -0,0 +1,121 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSubsidySchedule ensures the subsidy schedule halves the subsidy and
+// computes the founder and masternode shares of it.
+func TestSubsidySchedule(t *testing.T) {
+	founderScript := []byte{0x51}
+	schedule := SubsidySchedule{
+		BaseSubsidy:       1000,
+		ReductionInterval: 100,
+		FounderRewards: []FounderReward{{
+			StartHeight: 50,
+			EndHeight:   149,
+			PkScript:    founderScript,
+			Percent:     10,
+		}},
+		MasternodePaymentHeight:  120,
+		MasternodePaymentPercent: 40,
+	}
+
+	tests := []struct {
+		height     int32
+		subsidy    int64
+		founder    []FounderPayout
+		masternode int64
+	}{
+		{height: 0, subsidy: 1000},
+		{height: 50, subsidy: 1000, founder: []FounderPayout{
+			{PkScript: founderScript, Amount: 100}}},
+		{height: 100, subsidy: 500, founder: []FounderPayout{
+			{PkScript: founderScript, Amount: 50}}},
+		{height: 120, subsidy: 500, founder: []FounderPayout{
+			{PkScript: founderScript, Amount: 50}}, masternode: 200},
+		{height: 150, subsidy: 500, masternode: 200},
+		{height: 6400, subsidy: 0},
+	}
+	for _, test := range tests {
+		if got := schedule.BlockSubsidy(test.height); got != test.subsidy {
+			t.Errorf("BlockSubsidy(%d): got %d, want %d", test.height,
+				got, test.subsidy)
+		}
+		got := schedule.FounderPayouts(test.height)
+		if !reflect.DeepEqual(got, test.founder) {
+			t.Errorf("FounderPayouts(%d): got %v, want %v",
+				test.height, got, test.founder)
+		}
+		if got := schedule.MasternodePayment(test.height); got != test.masternode {
+			t.Errorf("MasternodePayment(%d): got %d, want %d",
+				test.height, got, test.masternode)
+		}
+	}
+
+	// The subsidy is never reduced without a reduction interval.
+	schedule.ReductionInterval = 0
+	if got := schedule.BlockSubsidy(1000000); got != 1000 {
+		t.Errorf("BlockSubsidy: got %d without reduction, want 1000", got)
+	}
+}
+
+// TestDeprecatedSubsidyFields ensures the deprecated fields of the chain
+// parameters mirror the subsidy schedule of the default networks and of
+// decoded custom networks.
+func TestDeprecatedSubsidyFields(t *testing.T) {
+	custom, err := DecodeParams(strings.NewReader(`{
+		"name": "custom",
+		"base": "regtest",
+		"subsidyreductioninterval": 1000,
+		"founderrewards": [{
+			"startheight": 1,
+			"endheight": 100,
+			"pkscript": "51",
+			"percent": 10
+		}],
+		"masternodepaymentheight": 50,
+		"masternodepaymentpercent": 40,
+		"superblockstartheight": 200,
+		"superblockcycle": 100,
+		"superblockbudgetpercent": 5
+	}`))
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error: %v", err)
+	}
+
+	networks := []*Params{&MainNetParams, &TestNet3Params,
+		&RegressionNetParams, &SimNetParams, custom}
+	for _, params := range networks {
+		var mirrored Params
+		mirrored.Subsidy = params.Subsidy
+		setDeprecatedSubsidyFields(&mirrored)
+		got := []interface{}{params.SubsidyReductionInterval,
+			params.FounderRewards, params.MasternodePaymentHeight,
+			params.MasternodePaymentPercent,
+			params.SuperblockStartHeight, params.SuperblockCycle,
+			params.SuperblockBudgetPercent}
+		want := []interface{}{mirrored.SubsidyReductionInterval,
+			mirrored.FounderRewards, mirrored.MasternodePaymentHeight,
+			mirrored.MasternodePaymentPercent,
+			mirrored.SuperblockStartHeight, mirrored.SuperblockCycle,
+			mirrored.SuperblockBudgetPercent}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: deprecated subsidy fields %v do not "+
+				"mirror the subsidy schedule %v", params.Name,
+				got, want)
+		}
+	}
+	if custom.SubsidyReductionInterval != 1000 ||
+		custom.MasternodePaymentPercent != 40 {
+
+		t.Errorf("custom: deprecated subsidy fields not set: %+v",
+			custom.Subsidy)
+	}
+}
@@ -263,12 +263,14 @@ func (bi *blockImporter) statusHandler(resultsChan chan *importResults) {
 		}
 		close(bi.quit)
 
-	// The import finished normally.
+	// The import finished normally, so write the changes to the utxo set
+	// held in memory to the database.
 	case <-bi.doneChan:
+		err := bi.chain.FlushUtxoCache(blockchain.FlushRequired)
 		resultsChan <- &importResults{
 			blocksProcessed: bi.blocksProcessed,
 			blocksImported:  bi.blocksImported,
-			err:             nil,
+			err:             err,
 		}
 	}
 }
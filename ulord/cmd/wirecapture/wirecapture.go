@@ -0,0 +1,302 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// wirecapture connects to a reference node, completes the version handshake,
+// and records the raw payloads of the messages the node sends in the format of
+// the wire conformance suite in wire/testdata/conformance.
+//
+// The payloads are read straight from the connection and are never decoded and
+// encoded again by the wire package, so they capture the encoding of the node
+// even where it differs from the one of the wire package.  Every vector records
+// the user agent and protocol version of the node, its address, and the time of
+// the capture in its source field.
+//
+// Usage:
+//
+//	wirecapture -c 203.0.113.1:9888 -r getaddr -r getsporks -o captured.json
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// config defines the configuration options for wirecapture.
+type config struct {
+	Connect        string        `short:"c" long:"connect" description:"Address of the reference node to capture from" required:"true"`
+	TestNet3       bool          `long:"testnet" description:"Use the test network"`
+	RegressionTest bool          `long:"regtest" description:"Use the regression test network"`
+	SimNet         bool          `long:"simnet" description:"Use the simulation test network"`
+	Requests       []string      `short:"r" long:"request" description:"Message to send after the handshake as command[:hexpayload], such as getaddr, mempool, or getsporks"`
+	Commands       []string      `long:"command" description:"Only capture messages with this command; all commands are captured when none is given"`
+	PerCommand     int           `short:"n" long:"percommand" description:"Maximum number of messages captured per command"`
+	Timeout        time.Duration `short:"t" long:"timeout" description:"Time to capture messages for after the handshake"`
+	Out            string        `short:"o" long:"out" description:"File to write the captured vectors to instead of stdout"`
+}
+
+// vector is a captured message payload in the format of the conformance suite.
+type vector struct {
+	Name     string `json:"name"`
+	Command  string `json:"command"`
+	Pver     uint32 `json:"pver"`
+	Encoding string `json:"encoding"`
+	Payload  string `json:"payload"`
+	Source   string `json:"source"`
+}
+
+// frame is a raw message read from the connection.
+type frame struct {
+	command string
+	payload []byte
+}
+
+// readFrame reads the next message of the passed network from r without
+// decoding its payload.
+func readFrame(r io.Reader, net wire.BitcoinNet) (*frame, error) {
+	var hdr [wire.MessageHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if magic := wire.BitcoinNet(binary.LittleEndian.Uint32(hdr[0:4])); magic != net {
+		return nil, fmt.Errorf("message from other network %v", magic)
+	}
+	command := string(bytes.TrimRight(hdr[4:4+wire.CommandSize], "\x00"))
+	length := binary.LittleEndian.Uint32(hdr[16:20])
+	if length > wire.MaxMessagePayload {
+		return nil, fmt.Errorf("%s message payload of %d bytes is too "+
+			"large", command, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	checksum := chainhash.DoubleHashB(payload)[0:4]
+	if !bytes.Equal(checksum, hdr[20:24]) {
+		return nil, fmt.Errorf("%s message has an invalid checksum",
+			command)
+	}
+	return &frame{command: command, payload: payload}, nil
+}
+
+// writeFrame writes a message of the passed network with the passed command
+// and raw payload to w.
+func writeFrame(w io.Writer, net wire.BitcoinNet, command string, payload []byte) error {
+	if len(command) > wire.CommandSize {
+		return fmt.Errorf("command %q is too long", command)
+	}
+	var hdr [wire.MessageHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(net))
+	copy(hdr[4:4+wire.CommandSize], command)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(payload)))
+	copy(hdr[20:24], chainhash.DoubleHashB(payload)[0:4])
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// parseRequest parses a request of the form command[:hexpayload].
+func parseRequest(request string) (string, []byte, error) {
+	parts := strings.SplitN(request, ":", 2)
+	if len(parts) == 1 {
+		return parts[0], nil, nil
+	}
+	payload, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid payload of request %q: %v",
+			request, err)
+	}
+	return parts[0], payload, nil
+}
+
+// capture connects to the reference node and returns the vectors of the
+// messages it sends.
+func capture(cfg *config, params *chaincfg.Params) ([]vector, error) {
+	conn, err := net.DialTimeout("tcp", cfg.Connect, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Announce the protocol version of the network so the node sends the
+	// messages of the newest version it supports.
+	us := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	them := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		them = wire.NewNetAddressIPPort(addr.IP, uint16(addr.Port), 0)
+	}
+	ver := wire.NewMsgVersion(us, them, uint64(rand.Int63()), 0)
+	ver.ProtocolVersion = int32(params.MessageVersion())
+	ver.AddUserAgent("wirecapture", "0.1.0")
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	if err := params.WriteMessage(conn, ver); err != nil {
+		return nil, err
+	}
+
+	// The version of the node is read without the wire package as well,
+	// since it is one of the captured messages.  Only the protocol version
+	// and user agent are decoded to describe the source of the vectors.
+	var remote *frame
+	for remote == nil {
+		f, err := readFrame(conn, params.Net)
+		if err != nil {
+			return nil, err
+		}
+		if f.command == wire.CmdVersion {
+			remote = f
+		}
+	}
+	remoteVer := new(wire.MsgVersion)
+	err = remoteVer.BtcDecode(bytes.NewBuffer(remote.payload),
+		params.MessageVersion(), wire.BaseEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the version of the "+
+			"node: %v", err)
+	}
+	pver := params.MessageVersion()
+	if uint32(remoteVer.ProtocolVersion) < pver {
+		pver = uint32(remoteVer.ProtocolVersion)
+	}
+	source := fmt.Sprintf("captured from %s (protocol %d) at %s on %s",
+		remoteVer.UserAgent, remoteVer.ProtocolVersion, cfg.Connect,
+		time.Now().UTC().Format("2006-01-02"))
+
+	if err := params.WriteMessage(conn, wire.NewMsgVerAck()); err != nil {
+		return nil, err
+	}
+	for _, request := range cfg.Requests {
+		command, payload, err := parseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFrame(conn, params.Net, command, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, command := range cfg.Commands {
+		wanted[command] = true
+	}
+	counts := make(map[string]int)
+	var vectors []vector
+	record := func(f *frame, pver uint32) {
+		if len(wanted) != 0 && !wanted[f.command] {
+			return
+		}
+		if counts[f.command] >= cfg.PerCommand {
+			return
+		}
+		counts[f.command]++
+		name := f.command
+		if counts[f.command] > 1 {
+			name += "-" + strconv.Itoa(counts[f.command])
+		}
+		vectors = append(vectors, vector{
+			Name:     name,
+			Command:  f.command,
+			Pver:     pver,
+			Encoding: "base",
+			Payload:  hex.EncodeToString(f.payload),
+			Source:   source,
+		})
+	}
+	record(remote, uint32(remoteVer.ProtocolVersion))
+
+	conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	for {
+		f, err := readFrame(conn, params.Net)
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				return vectors, nil
+			}
+			return vectors, err
+		}
+		record(f, pver)
+
+		// Answer pings so the node keeps the connection open.
+		if f.command == wire.CmdPing && len(f.payload) == 8 {
+			nonce := binary.LittleEndian.Uint64(f.payload)
+			err := params.WriteMessage(conn, wire.NewMsgPong(nonce))
+			if err != nil {
+				return vectors, err
+			}
+		}
+	}
+}
+
+func main() {
+	cfg := config{
+		PerCommand: 1,
+		Timeout:    time.Minute,
+	}
+	parser := flags.NewParser(&cfg, flags.Default)
+	if _, err := parser.Parse(); err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		os.Exit(1)
+	}
+
+	params := &chaincfg.MainNetParams
+	numNets := 0
+	if cfg.TestNet3 {
+		numNets++
+		params = &chaincfg.TestNet3Params
+	}
+	if cfg.RegressionTest {
+		numNets++
+		params = &chaincfg.RegressionNetParams
+	}
+	if cfg.SimNet {
+		numNets++
+		params = &chaincfg.SimNetParams
+	}
+	if numNets > 1 {
+		fmt.Fprintln(os.Stderr, "The testnet, regtest, and simnet "+
+			"params can't be used together -- choose one of the three")
+		os.Exit(1)
+	}
+
+	vectors, err := capture(&cfg, params)
+	if err != nil && len(vectors) == 0 {
+		fmt.Fprintf(os.Stderr, "Unable to capture messages: %v\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Capture ended early: %v\n", err)
+	}
+
+	data, err := json.MarshalIndent(vectors, "", "\t")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to encode vectors: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+	if cfg.Out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := ioutil.WriteFile(cfg.Out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write vectors: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"bufio"
 	"crypto/rand"
 	"encoding/base64"
-	"errors"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
@@ -27,9 +27,11 @@ import (
 	"github.com/ulordsuite/ulord/database"
 	_ "github.com/ulordsuite/ulord/database/ffldb"
 	"github.com/ulordsuite/ulord/mempool"
+	"github.com/ulordsuite/ulord/netsync"
 	"github.com/ulordsuite/ulord/peer"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/zmqpub"
 	"github.com/ulordsuite/ulordutil"
-	"github.com/ulordsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
 )
 
@@ -58,6 +60,9 @@ const (
 	blockMaxWeightMin            = 4000
 	blockMaxWeightMax            = blockchain.MaxBlockWeight - 4000
 	defaultGenerate              = false
+	defaultStratumPort           = "3333"
+	defaultStratumDifficulty     = 1
+	defaultStratumMinDifficulty  = 0.001
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
 	defaultSigCacheMaxSize       = 100000
@@ -102,22 +107,39 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	MaxPeerUploadRate    int64         `long:"maxpeeruploadrate" description:"Maximum rate in KB/s at which data is sent to each peer -- 0 to disable"`
+	MaxPeerDownloadRate  int64         `long:"maxpeerdownloadrate" description:"Maximum rate in KB/s at which data is received from each peer -- 0 to disable"`
+	MaxUploadRate        int64         `long:"maxuploadrate" description:"Maximum rate in KB/s at which data is sent to all peers -- 0 to disable"`
+	MaxDownloadRate      int64         `long:"maxdownloadrate" description:"Maximum rate in KB/s at which data is received from all peers -- 0 to disable"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
+	DisconnectThreshold  uint32        `long:"disconnectthreshold" description:"Maximum allowed ban score before disconnecting misbehaving peers without banning them -- 0 to disable"`
+	BanPenalties         []string      `long:"banpenalty" description:"Override the penalty of an offense of misbehaving peers.  Format: '<offense>:<persistent points>:<decaying points>[:disconnect]' -- may be specified multiple times"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	TrustedPeers         []string      `long:"trustedpeer" description:"Add an IP network or IP of peers whose transactions bypass the relay policy and which are preferred for syncing (eg. 192.168.1.0/24 or ::1)"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
+	RPCProfiles          []string      `long:"rpcprofile" description:"Define an RPC permission profile as <name>:<base profile>[:<+method|-method>,...] which allows the methods of the base profile (admin, limited, readonly or a previously defined profile) plus or minus the listed methods"`
+	RPCAuthUsers         []string      `long:"rpcauthuser" default-mask:"-" description:"Add an RPC user with the given permission profile as <user>:<profile>:<password>"`
 	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	REST                 bool          `long:"rest" description:"Serve the unauthenticated read-only REST interface for blocks, headers and transactions on the RPC listeners"`
+	GRPCListeners        []string      `long:"grpclisten" description:"Add an interface/port to serve the gRPC service on (default port: 8335, testnet: 18335) -- NOTE: The gRPC service is disabled unless a listener is specified and requires the RPC server"`
+	ZMQPubHashBlock      string        `long:"zmqpubhashblock" description:"Publish the hashes of connected blocks on the specified ZeroMQ endpoint (eg. tcp://127.0.0.1:28332)"`
+	ZMQPubHashTx         string        `long:"zmqpubhashtx" description:"Publish the hashes of new and connected transactions on the specified ZeroMQ endpoint"`
+	ZMQPubRawBlock       string        `long:"zmqpubrawblock" description:"Publish connected blocks on the specified ZeroMQ endpoint"`
+	ZMQPubRawTx          string        `long:"zmqpubrawtx" description:"Publish new and connected transactions on the specified ZeroMQ endpoint"`
+	ZMQPubHashTxLock     string        `long:"zmqpubhashtxlock" description:"Publish the hashes of transactions locked by InstantSend on the specified ZeroMQ endpoint"`
+	ZMQPubRawTxLock      string        `long:"zmqpubrawtxlock" description:"Publish transactions locked by InstantSend on the specified ZeroMQ endpoint"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
-	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass, rpclimituser/rpclimitpass or rpcauthuser is specified"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	DisableDNSSeed       bool          `long:"nodnsseed" description:"Disable DNS seeding for peers"`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
@@ -128,24 +150,46 @@ type config struct {
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
-	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by using distinct proxy credentials for each peer."`
+	OnlyOnion            bool          `long:"onlyonion" description:"Only connect to tor hidden services -- Requires --proxy or --onion"`
+	ASMap                string        `long:"asmap" description:"File mapping IP addresses to autonomous systems, which spreads outbound peers across autonomous systems instead of /16 networks"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	NetParams            string        `long:"netparams" description:"Use the custom network whose parameters are defined by the given JSON file"`
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
+	CheckpointFile       string        `long:"checkpointfile" description:"Load additional checkpoints from a file signed by a trusted key"`
+	CheckpointPubKeys    []string      `long:"checkpointpubkey" description:"Add a hex-encoded public key trusted to sign checkpoint files in addition to the ones of the active network -- may be specified multiple times"`
+	MaxReorgDepth        int32         `long:"maxreorgdepth" description:"Reject reorganizations of the main chain which disconnect more than this many blocks -- 0 to allow any depth"`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	NATPMP               bool          `long:"natpmp" description:"Use NAT-PMP to map our listening port outside of NAT when UPnP is disabled or unavailable"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxOrphanTxsPerPeer  int           `long:"maxorphantxperpeer" description:"Max number of orphan transactions from a single peer to keep in memory"`
+	RejectReplacement    bool          `long:"rejectreplacement" description:"Reject transactions that attempt to replace existing transactions within the mempool through the Replace-By-Fee (RBF) signaling policy."`
+	MaxReplEvictions     int           `long:"maxreplevictions" description:"Max number of mempool transactions a single Replace-By-Fee (RBF) transaction may evict, including their descendants"`
+	LimitAncestorCount   int           `long:"limitancestorcount" description:"Do not accept transactions if they and their unconfirmed ancestors in the mempool exceed this many transactions"`
+	LimitAncestorSize    int           `long:"limitancestorsize" description:"Do not accept transactions if they and their unconfirmed ancestors in the mempool exceed this total virtual size in kilobytes"`
+	LimitDescendantCount int           `long:"limitdescendantcount" description:"Do not accept transactions if any of their unconfirmed ancestors in the mempool would have more than this many descendants, including itself"`
+	LimitDescendantSize  int           `long:"limitdescendantsize" description:"Do not accept transactions if any of their unconfirmed ancestors in the mempool would have descendants exceeding this total virtual size in kilobytes, including itself"`
+	MempoolExpiry        time.Duration `long:"mempoolexpiry" description:"Evict transactions, along with their descendants, which have been in the mempool for longer than this duration"`
+	MaxMempool           int           `long:"maxmempool" description:"Keep the total virtual size of the transactions in the mempool below this many megabytes by evicting those paying the lowest fee rates"`
+	NoPersistMempool     bool          `long:"nopersistmempool" description:"Do not save the mempool on shutdown and load it on startup"`
+	MempoolAddrIndex     bool          `long:"mempooladdrindex" description:"Index the transactions in the mempool by the addresses they pay to and spend from, so websocket clients registering addresses are notified of pending payments to them"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
-	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate or stratumlisten options are set"`
+	StratumListeners     []string      `long:"stratumlisten" description:"Add an interface/port to listen for stratum mining connections (default port: 3333)"`
+	StratumDifficulty    float64       `long:"stratumdiff" description:"Initial share difficulty of stratum clients"`
+	StratumMinDifficulty float64       `long:"stratummindiff" description:"Minimum share difficulty stratum clients are adjusted to"`
+	StratumMaxDifficulty float64       `long:"stratummaxdiff" description:"Maximum share difficulty stratum clients are adjusted to -- 0 for no limit"`
 	BlockMinSize         uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
 	BlockMaxSize         uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
@@ -156,20 +200,36 @@ type config struct {
 	NoCFilters           bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
 	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	UtxoCacheMaxSizeMiB  int           `long:"utxocachemaxsize" description:"The maximum size in MiB of the cache of unspent transaction outputs held in memory before they are written to the database"`
+	Prune                uint64        `long:"prune" description:"Delete the data of old blocks to keep the block data under the target size in MiB while keeping their headers and the utxo set -- 0 to keep all blocks, otherwise at least 1536"`
+	LoadUtxoSnapshot     string        `long:"loadutxosnapshot" description:"Bootstrap the empty chain from the utxo set snapshot in the given file and validate the blocks before it in the background -- disables committed filtering (CF) support until they are validated"`
+	SnapshotCommitment   string        `long:"utxosnapshotcommitment" description:"Trust the utxo set snapshot loaded with --loadutxosnapshot when it has the given commitment in addition to those known for the network"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	BlockStatsIndex      bool          `long:"blockstatsindex" description:"Maintain an index of the statistics of the transactions of each block which backs the getblockstats RPC"`
+	DropBlockStatsIndex  bool          `long:"dropblockstatsindex" description:"Deletes the block stats index from the database on start up and then exits."`
+	AddrUtxoIndex        bool          `long:"addrutxoindex" description:"Maintain an index of the history, unspent outputs, and balances of addresses which makes the getaddressbalance, getaddressutxos, and getaddressdeltas RPCs available"`
+	DropAddrUtxoIndex    bool          `long:"dropaddrutxoindex" description:"Deletes the address utxo index from the database on start up and then exits."`
+	SpentIndex           bool          `long:"spentindex" description:"Maintain an index of the inputs which spend each output which makes the getspentinfo RPC available"`
+	DropSpentIndex       bool          `long:"dropspentindex" description:"Deletes the spent index from the database on start up and then exits."`
+	TimestampIndex       bool          `long:"timestampindex" description:"Maintain an index of the timestamps of the blocks which makes the getblockhashes RPC available"`
+	DropTimestampIndex   bool          `long:"droptimestampindex" description:"Deletes the timestamp index from the database on start up and then exits."`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	lookup               func(string) ([]net.IP, error)
-	oniondial            func(string, string, time.Duration) (net.Conn, error)
-	dial                 func(string, string, time.Duration) (net.Conn, error)
+	dialer               *connmgr.Dialer
 	addCheckpoints       []chaincfg.Checkpoint
+	banPenalties         map[netsync.Offense]netsync.Penalty
+	checkpointPubKeys    []*ulordec.PublicKey
+	snapshotCommitment   *chainhash.Hash
 	miningAddrs          []ulordutil.Address
+	rpcUsers             []*rpcUser
 	minRelayTxFee        ulordutil.Amount
 	whitelists           []*net.IPNet
+	trustedPeers         []*net.IPNet
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -321,6 +381,25 @@ func normalizeAddresses(addrs []string, defaultPort string) []string {
 	return removeDuplicateAddresses(addrs)
 }
 
+// zmqEndpoints returns the ZeroMQ endpoints of the topics which are configured
+// to be published.
+func (c *config) zmqEndpoints() map[string]string {
+	endpoints := make(map[string]string)
+	for topic, endpoint := range map[string]string{
+		zmqpub.TopicHashBlock:  c.ZMQPubHashBlock,
+		zmqpub.TopicHashTx:     c.ZMQPubHashTx,
+		zmqpub.TopicRawBlock:   c.ZMQPubRawBlock,
+		zmqpub.TopicRawTx:      c.ZMQPubRawTx,
+		zmqpub.TopicHashTxLock: c.ZMQPubHashTxLock,
+		zmqpub.TopicRawTxLock:  c.ZMQPubRawTxLock,
+	} {
+		if endpoint != "" {
+			endpoints[topic] = endpoint
+		}
+	}
+	return endpoints
+}
+
 // newCheckpointFromStr parses checkpoints in the '<height>:<hash>' format.
 func newCheckpointFromStr(checkpoint string) (chaincfg.Checkpoint, error) {
 	parts := strings.Split(checkpoint, ":")
@@ -369,6 +448,95 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// parseBanPenalties checks the ban penalty strings for valid syntax
+// ('<offense>:<persistent>:<transient>[:disconnect]') and parses them to the
+// penalties of the offenses.
+func parseBanPenalties(penaltyStrings []string) (map[netsync.Offense]netsync.Penalty, error) {
+	if len(penaltyStrings) == 0 {
+		return nil, nil
+	}
+	penalties := make(map[netsync.Offense]netsync.Penalty, len(penaltyStrings))
+	for _, penaltyString := range penaltyStrings {
+		parts := strings.Split(penaltyString, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("unable to parse ban penalty %q -- "+
+				"use the syntax <offense>:<persistent>:<transient>"+
+				"[:disconnect]", penaltyString)
+		}
+		offense, err := netsync.ParseOffense(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		persistent, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse persistent "+
+				"points of ban penalty %q: %v", penaltyString, err)
+		}
+		transient, err := strconv.ParseUint(parts[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse decaying "+
+				"points of ban penalty %q: %v", penaltyString, err)
+		}
+		if len(parts) == 4 && parts[3] != "disconnect" {
+			return nil, fmt.Errorf("unable to parse ban penalty %q "+
+				"-- the optional last field must be 'disconnect'",
+				penaltyString)
+		}
+		penalties[offense] = netsync.Penalty{
+			Persistent: uint32(persistent),
+			Transient:  uint32(transient),
+			Disconnect: len(parts) == 4,
+		}
+	}
+	return penalties, nil
+}
+
+// parseCheckpointPubKeys parses the hex-encoded public keys trusted to sign
+// checkpoint files.
+func parseCheckpointPubKeys(pubKeyStrings []string) ([]*ulordec.PublicKey, error) {
+	if len(pubKeyStrings) == 0 {
+		return nil, nil
+	}
+	pubKeys := make([]*ulordec.PublicKey, len(pubKeyStrings))
+	for i, pubKeyString := range pubKeyStrings {
+		serialized, err := hex.DecodeString(pubKeyString)
+		if err != nil {
+			return nil, fmt.Errorf("malformed public key %q: %v",
+				pubKeyString, err)
+		}
+		pubKeys[i], err = ulordec.ParsePubKey(serialized, ulordec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %v",
+				pubKeyString, err)
+		}
+	}
+	return pubKeys, nil
+}
+
+// parseIPNet parses the passed IP network in CIDR notation or IP address.  An
+// IP address is treated as a network which only includes that address.
+func parseIPNet(addr string) (*net.IPNet, error) {
+	_, ipnet, err := net.ParseCIDR(addr)
+	if err == nil {
+		return ipnet, nil
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP network or address '%s'", addr)
+	}
+	var bits int
+	if ip.To4() == nil {
+		// IPv6
+		bits = 128
+	} else {
+		bits = 32
+	}
+	return &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(bits, bits),
+	}, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -425,8 +593,19 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxOrphanTxsPerPeer:  mempool.DefaultMaxOrphanTxsPerTag,
+		MaxReplEvictions:     mempool.DefaultMaxReplacementEvictions,
+		LimitAncestorCount:   mempool.DefaultMaxAncestorCount,
+		LimitAncestorSize:    mempool.DefaultMaxAncestorSize / 1000,
+		LimitDescendantCount: mempool.DefaultMaxDescendantCount,
+		LimitDescendantSize:  mempool.DefaultMaxDescendantSize / 1000,
+		MempoolExpiry:        mempool.DefaultTxExpiry,
+		MaxMempool:           mempool.DefaultMaxPoolSize / 1000000,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		UtxoCacheMaxSizeMiB:  blockchain.DefaultUtxoCacheMaxSize / (1024 * 1024),
 		Generate:             defaultGenerate,
+		StratumDifficulty:    defaultStratumDifficulty,
+		StratumMinDifficulty: defaultStratumMinDifficulty,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
 	}
@@ -546,9 +725,24 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.NetParams != "" {
+		numNets++
+		chainParams, err := chaincfg.LoadParams(
+			cleanAndExpandPath(cfg.NetParams))
+		if err == nil {
+			err = chaincfg.RegisterParams(chainParams)
+		}
+		if err != nil {
+			err := fmt.Errorf("%s: unable to load network params: %v",
+				funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = newCustomNetParams(chainParams)
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, segnet, and simnet params " +
-			"can't be used together -- choose one of the four"
+		str := "%s: The testnet, regtest, segnet, simnet, and netparams " +
+			"params can't be used together -- choose one of the five"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -640,36 +834,49 @@ func loadConfig() (*config, []string, error) {
 
 	// Validate any given whitelisted IP addresses and networks.
 	if len(cfg.Whitelists) > 0 {
-		var ip net.IP
 		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
 
 		for _, addr := range cfg.Whitelists {
-			_, ipnet, err := net.ParseCIDR(addr)
+			ipnet, err := parseIPNet(addr)
 			if err != nil {
-				ip = net.ParseIP(addr)
-				if ip == nil {
-					str := "%s: The whitelist value of '%s' is invalid"
-					err = fmt.Errorf(str, funcName, addr)
-					fmt.Fprintln(os.Stderr, err)
-					fmt.Fprintln(os.Stderr, usageMessage)
-					return nil, nil, err
-				}
-				var bits int
-				if ip.To4() == nil {
-					// IPv6
-					bits = 128
-				} else {
-					bits = 32
-				}
-				ipnet = &net.IPNet{
-					IP:   ip,
-					Mask: net.CIDRMask(bits, bits),
-				}
+				str := "%s: The whitelist value of '%s' is invalid"
+				err = fmt.Errorf(str, funcName, addr)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
 			}
 			cfg.whitelists = append(cfg.whitelists, ipnet)
 		}
 	}
 
+	// The bandwidth limits may not be negative.
+	if cfg.MaxPeerUploadRate < 0 || cfg.MaxPeerDownloadRate < 0 ||
+		cfg.MaxUploadRate < 0 || cfg.MaxDownloadRate < 0 {
+
+		str := "%s: the bandwidth limits may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate any given trusted peer IP addresses and networks.
+	if len(cfg.TrustedPeers) > 0 {
+		cfg.trustedPeers = make([]*net.IPNet, 0, len(cfg.TrustedPeers))
+
+		for _, addr := range cfg.TrustedPeers {
+			ipnet, err := parseIPNet(addr)
+			if err != nil {
+				str := "%s: The trustedpeer value of '%s' is invalid"
+				err = fmt.Errorf(str, funcName, addr)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.trustedPeers = append(cfg.trustedPeers, ipnet)
+		}
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -680,8 +887,9 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// --proxy or --connect without --listen disables listening.
-	if (cfg.Proxy != "" || len(cfg.ConnectPeers) > 0) &&
+	// --proxy, --onlyonion, or --connect without --listen disables
+	// listening.
+	if (cfg.Proxy != "" || cfg.OnlyOnion || len(cfg.ConnectPeers) > 0) &&
 		len(cfg.Listeners) == 0 {
 		cfg.DisableListen = true
 	}
@@ -700,6 +908,39 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Parse the RPC permission profiles and the users they apply to.
+	rpcProfiles, err := parseRPCProfiles(cfg.RPCProfiles)
+	if err == nil {
+		cfg.rpcUsers, err = parseRPCUsers(cfg.RPCAuthUsers, rpcProfiles)
+	}
+	if err != nil {
+		str := "%s: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Check to make sure all RPC users have distinct usernames.
+	rpcUserNames := map[string]struct{}{}
+	if cfg.RPCUser != "" {
+		rpcUserNames[cfg.RPCUser] = struct{}{}
+	}
+	if cfg.RPCLimitUser != "" {
+		rpcUserNames[cfg.RPCLimitUser] = struct{}{}
+	}
+	for _, user := range cfg.rpcUsers {
+		if _, ok := rpcUserNames[user.name]; ok {
+			str := "%s: the RPC username %q is specified more " +
+				"than once"
+			err := fmt.Errorf(str, funcName, user.name)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		rpcUserNames[user.name] = struct{}{}
+	}
+
 	// Check to make sure limited and admin users don't have the same username
 	if cfg.RPCUser == cfg.RPCLimitUser && cfg.RPCUser != "" {
 		str := "%s: --rpcuser and --rpclimituser must not specify the " +
@@ -722,7 +963,8 @@ func loadConfig() (*config, []string, error) {
 
 	// The RPC server is disabled if no username or password is provided.
 	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
+		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") &&
+		len(cfg.rpcUsers) == 0 {
 		cfg.DisableRPC = true
 	}
 
@@ -788,6 +1030,50 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Limit the max replacement evictions to a sane value.
+	if cfg.MaxReplEvictions < 1 {
+		str := "%s: The maxreplevictions option may not be less than 1 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxReplEvictions)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Limit the unconfirmed transaction chain limits to sane values.
+	chainLimits := []struct {
+		name  string
+		value int
+	}{
+		{"limitancestorcount", cfg.LimitAncestorCount},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", cfg.LimitDescendantCount},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+		{"maxmempool", cfg.MaxMempool},
+		{"maxorphantxperpeer", cfg.MaxOrphanTxsPerPeer},
+		{"utxocachemaxsize", cfg.UtxoCacheMaxSizeMiB},
+	}
+	for _, limit := range chainLimits {
+		if limit.value < 1 {
+			str := "%s: The %s option may not be less than 1 " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, limit.name, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Don't allow transactions to expire before they were even relayed.
+	if cfg.MempoolExpiry < time.Minute {
+		str := "%s: The mempoolexpiry option may not be less than 1m " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max orphan count to a sane vlue.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -852,6 +1138,102 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --blockstatsindex and --dropblockstatsindex do not mix.
+	if cfg.BlockStatsIndex && cfg.DropBlockStatsIndex {
+		err := fmt.Errorf("%s: the --blockstatsindex and "+
+			"--dropblockstatsindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --addrutxoindex and --dropaddrutxoindex do not mix.
+	if cfg.AddrUtxoIndex && cfg.DropAddrUtxoIndex {
+		err := fmt.Errorf("%s: the --addrutxoindex and "+
+			"--dropaddrutxoindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --spentindex and --dropspentindex do not mix.
+	if cfg.SpentIndex && cfg.DropSpentIndex {
+		err := fmt.Errorf("%s: the --spentindex and --dropspentindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --timestampindex and --droptimestampindex do not mix.
+	if cfg.TimestampIndex && cfg.DropTimestampIndex {
+		err := fmt.Errorf("%s: the --timestampindex and "+
+			"--droptimestampindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Pruning requires a target which leaves room for the recent blocks.
+	minPruneTarget := uint64(blockchain.MinPruneTargetSize / (1024 * 1024))
+	if cfg.Prune != 0 && cfg.Prune < minPruneTarget {
+		str := "%s: the prune target must be at least %d MiB -- " +
+			"parsed [%d]"
+		err := fmt.Errorf(str, funcName, minPruneTarget, cfg.Prune)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune and the optional indexes other than the committed filter
+	// index do not mix since the indexes need the data of all blocks.
+	if cfg.Prune != 0 && (cfg.TxIndex || cfg.AddrIndex ||
+		cfg.BlockStatsIndex || cfg.AddrUtxoIndex || cfg.SpentIndex ||
+		cfg.TimestampIndex) {
+
+		err := fmt.Errorf("%s: the --prune option may not be activated "+
+			"at the same time as the --txindex, --addrindex, "+
+			"--blockstatsindex, --addrutxoindex, --spentindex, or "+
+			"--timestampindex options", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Loading a utxo snapshot and the optional indexes do not mix since
+	// the indexes need the data of the blocks before the snapshot.
+	if cfg.LoadUtxoSnapshot != "" {
+		if cfg.TxIndex || cfg.AddrIndex || cfg.BlockStatsIndex ||
+			cfg.AddrUtxoIndex || cfg.SpentIndex || cfg.TimestampIndex {
+
+			err := fmt.Errorf("%s: the --loadutxosnapshot option may "+
+				"not be activated at the same time as the "+
+				"--txindex, --addrindex, --blockstatsindex, "+
+				"--addrutxoindex, --spentindex, or --timestampindex "+
+				"options", funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.LoadUtxoSnapshot = cleanAndExpandPath(cfg.LoadUtxoSnapshot)
+		cfg.NoCFilters = true
+	}
+	if cfg.SnapshotCommitment != "" {
+		cfg.snapshotCommitment, err = chainhash.NewHashFromStr(
+			cfg.SnapshotCommitment)
+		if err != nil {
+			str := "%s: invalid utxo snapshot commitment: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// --addrindex and --droptxindex do not mix.
 	if cfg.AddrIndex && cfg.DropTxIndex {
 		err := fmt.Errorf("%s: the --addrindex and --droptxindex "+
@@ -896,6 +1278,33 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure there is at least one mining address and the share
+	// difficulties are sane when the stratum server is enabled.
+	if len(cfg.StratumListeners) > 0 {
+		if len(cfg.MiningAddrs) == 0 {
+			str := "%s: the stratumlisten option is set, but there " +
+				"are no mining addresses specified"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.StratumMinDifficulty <= 0 ||
+			cfg.StratumDifficulty < cfg.StratumMinDifficulty ||
+			(cfg.StratumMaxDifficulty != 0 &&
+				cfg.StratumDifficulty > cfg.StratumMaxDifficulty) {
+
+			str := "%s: the stratumdiff option must be between the " +
+				"positive stratummindiff and stratummaxdiff " +
+				"options -- parsed [%g, %g, %g]"
+			err := fmt.Errorf(str, funcName, cfg.StratumMinDifficulty,
+				cfg.StratumDifficulty, cfg.StratumMaxDifficulty)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -906,15 +1315,39 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
-	// Only allow TLS to be disabled if the RPC is bound to localhost
-	// addresses.
+	// Add default port to all gRPC listener addresses if needed and remove
+	// duplicate addresses.
+	cfg.GRPCListeners = normalizeAddresses(cfg.GRPCListeners,
+		activeNetParams.grpcPort)
+
+	// The gRPC service is served by the RPC server.
+	if cfg.DisableRPC && len(cfg.GRPCListeners) != 0 {
+		str := "%s: the --grpclisten option requires the RPC server " +
+			"which is disabled"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Add default port to all stratum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.StratumListeners = normalizeAddresses(cfg.StratumListeners,
+		defaultStratumPort)
+
+	// Only allow TLS to be disabled if the RPC and gRPC services are bound
+	// to localhost addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
 		allowedTLSListeners := map[string]struct{}{
 			"localhost": {},
 			"127.0.0.1": {},
 			"::1":       {},
 		}
-		for _, addr := range cfg.RPCListeners {
+		listeners := make([]string, 0, len(cfg.RPCListeners)+
+			len(cfg.GRPCListeners))
+		listeners = append(listeners, cfg.RPCListeners...)
+		listeners = append(listeners, cfg.GRPCListeners...)
+		for _, addr := range listeners {
 			host, _, err := net.SplitHostPort(addr)
 			if err != nil {
 				str := "%s: RPC listen interface '%s' is " +
@@ -962,6 +1395,53 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The maximum reorganize depth can't be negative.
+	if cfg.MaxReorgDepth < 0 {
+		str := "%s: The maxreorgdepth option may not be negative -- " +
+			"parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxReorgDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Check the ban penalties for syntax errors.
+	cfg.banPenalties, err = parseBanPenalties(cfg.BanPenalties)
+	if err != nil {
+		str := "%s: Error parsing ban penalties: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Parse the keys trusted to sign checkpoint files.
+	cfg.checkpointPubKeys, err = parseCheckpointPubKeys(cfg.CheckpointPubKeys)
+	if err != nil {
+		str := "%s: Error parsing checkpoint public keys: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.CheckpointFile != "" {
+		cfg.CheckpointFile = cleanAndExpandPath(cfg.CheckpointFile)
+	}
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+
+	// Ensure the ZeroMQ endpoints are valid.
+	for topic, endpoint := range cfg.zmqEndpoints() {
+		if _, err := zmqpub.ParseEndpoint(endpoint); err != nil {
+			str := "%s: Invalid zmqpub%s endpoint: %v"
+			err := fmt.Errorf(str, funcName, topic, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
@@ -972,13 +1452,32 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// Setup dial and DNS resolution (lookup) functions depending on the
-	// specified options.  The default is to use the standard
-	// net.DialTimeout function as well as the system DNS resolver.  When a
-	// proxy is specified, the dial function is set to the proxy specific
-	// dial function and the lookup is set to use tor (unless --noonion is
-	// specified in which case the system DNS resolver is used).
-	cfg.dial = net.DialTimeout
+	// --onlyonion requires a proxy to reach tor hidden services through and
+	// does not mix with --noonion.
+	if cfg.OnlyOnion && (cfg.NoOnion ||
+		(cfg.Proxy == "" && cfg.OnionProxy == "")) {
+
+		str := "%s: the --onlyonion option requires either proxy or " +
+			"onionproxy to be set and may not be combined with --noonion"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Setup the dialer and DNS resolution (lookup) function depending on
+	// the specified options.  The default is to dial connections directly
+	// and to use the system DNS resolver.  When a proxy is specified, all
+	// connections are dialed through it and the lookup is set to use tor
+	// (unless --noonion is specified in which case the system DNS resolver
+	// is used).  When an onion-specific proxy is specified, connections to
+	// .onion addresses are dialed through it instead, which allows their
+	// traffic to be routed through a different proxy than normal traffic.
+	cfg.dialer = &connmgr.Dialer{
+		NoOnion:   cfg.NoOnion,
+		OnlyOnion: cfg.OnlyOnion,
+		Timeout:   defaultConnectTimeout,
+	}
 	cfg.lookup = net.LookupIP
 	if cfg.Proxy != "" {
 		_, _, err := net.SplitHostPort(cfg.Proxy)
@@ -993,22 +1492,18 @@ func loadConfig() (*config, []string, error) {
 		// Tor isolation flag means proxy credentials will be overridden
 		// unless there is also an onion proxy configured in which case
 		// that one will be overridden.
-		torIsolation := false
-		if cfg.TorIsolation && cfg.OnionProxy == "" &&
-			(cfg.ProxyUser != "" || cfg.ProxyPass != "") {
-
-			torIsolation = true
+		torIsolation := cfg.TorIsolation && cfg.OnionProxy == ""
+		if torIsolation && (cfg.ProxyUser != "" || cfg.ProxyPass != "") {
 			fmt.Fprintln(os.Stderr, "Tor isolation set -- "+
 				"overriding specified proxy user credentials")
 		}
 
-		proxy := &socks.Proxy{
-			Addr:         cfg.Proxy,
-			Username:     cfg.ProxyUser,
-			Password:     cfg.ProxyPass,
-			TorIsolation: torIsolation,
+		cfg.dialer.Proxy = &connmgr.Proxy{
+			Addr:            cfg.Proxy,
+			Username:        cfg.ProxyUser,
+			Password:        cfg.ProxyPass,
+			StreamIsolation: torIsolation,
 		}
-		cfg.dial = proxy.DialTimeout
 
 		// Treat the proxy as tor and perform DNS resolution through it
 		// unless the --noonion flag is set or there is an
@@ -1019,13 +1514,6 @@ func loadConfig() (*config, []string, error) {
 			}
 		}
 	}
-
-	// Setup onion address dial function depending on the specified options.
-	// The default is to use the same dial function selected above.  However,
-	// when an onion-specific proxy is specified, the onion address dial
-	// function is set to use the onion-specific proxy while leaving the
-	// normal dial function as selected above.  This allows .onion address
-	// traffic to be routed through a different proxy than normal traffic.
 	if cfg.OnionProxy != "" {
 		_, _, err := net.SplitHostPort(cfg.OnionProxy)
 		if err != nil {
@@ -1045,14 +1533,11 @@ func loadConfig() (*config, []string, error) {
 				"credentials ")
 		}
 
-		cfg.oniondial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
-			proxy := &socks.Proxy{
-				Addr:         cfg.OnionProxy,
-				Username:     cfg.OnionProxyUser,
-				Password:     cfg.OnionProxyPass,
-				TorIsolation: cfg.TorIsolation,
-			}
-			return proxy.DialTimeout(network, addr, timeout)
+		cfg.dialer.OnionProxy = &connmgr.Proxy{
+			Addr:            cfg.OnionProxy,
+			Username:        cfg.OnionProxyUser,
+			Password:        cfg.OnionProxyPass,
+			StreamIsolation: cfg.TorIsolation,
 		}
 
 		// When configured in bridge mode (both --onion and --proxy are
@@ -1064,16 +1549,6 @@ func loadConfig() (*config, []string, error) {
 				return connmgr.TorLookupIP(host, cfg.OnionProxy)
 			}
 		}
-	} else {
-		cfg.oniondial = cfg.dial
-	}
-
-	// Specifying --noonion means the onion address dial function results in
-	// an error.
-	if cfg.NoOnion {
-		cfg.oniondial = func(a, b string, t time.Duration) (net.Conn, error) {
-			return nil, errors.New("tor has been disabled")
-		}
 	}
 
 	// Warn about missing config file only after all other configuration is
@@ -1159,11 +1634,7 @@ func createDefaultConfigFile(destinationPath string) error {
 // one was specified, but will otherwise use the normal dial function (which
 // could itself use a proxy or not).
 func ulordDial(addr net.Addr) (net.Conn, error) {
-	if strings.Contains(addr.String(), ".onion:") {
-		return cfg.oniondial(addr.Network(), addr.String(),
-			defaultConnectTimeout)
-	}
-	return cfg.dial(addr.Network(), addr.String(), defaultConnectTimeout)
+	return cfg.dialer.Dial(addr)
 }
 
 // ulordLookup resolves the IP of the given host using the correct DNS lookup
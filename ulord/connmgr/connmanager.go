@@ -18,10 +18,19 @@ import (
 // be delayed by the configured retry duration.
 const maxFailedAttempts = 25
 
+// maxGroupAttempts is the maximum number of addresses requested for a new
+// connection when the network groups of the addresses returned are already
+// used by other outbound connections.
+const maxGroupAttempts = 10
+
 var (
 	//ErrDialNil is used to indicate that Dial cannot be nil in the configuration.
 	ErrDialNil = errors.New("Config: Dial cannot be nil")
 
+	// ErrGroupInUse is used to indicate that no address outside of the
+	// network groups of the existing outbound connections was found.
+	ErrGroupInUse = errors.New("no address in an unused network group")
+
 	// maxRetryDuration is the max duration of time retrying of a persistent
 	// connection is allowed to grow to.  This is necessary since the retry
 	// logic uses a backoff mechanism which increases the interval base times
@@ -61,10 +70,19 @@ type ConnReq struct {
 	Addr      net.Addr
 	Permanent bool
 
+	// Feeler marks a short-lived connection made to test whether an address
+	// is reachable.  Feeler connections don't count toward the target
+	// number of outbound connections and are never retried.
+	Feeler bool
+
 	conn       net.Conn
 	state      ConnState
 	stateMtx   sync.RWMutex
 	retryCount uint32
+
+	// group is the network group claimed by the request.  It is only
+	// accessed by the connection handler.
+	group string
 }
 
 // updateState updates the state of the connection request.
@@ -142,6 +160,24 @@ type Config struct {
 
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
+
+	// GroupKey returns the network group of an address, such as its /16
+	// network or autonomous system.  When set, automatic connections are
+	// only made to addresses in network groups no other automatic
+	// connection, pending or established, is using, which makes it harder
+	// for an attacker controlling a single network to occupy all outbound
+	// connections.
+	GroupKey func(net.Addr) string
+
+	// FeelerInterval is the interval at which feeler connections are made
+	// once the target number of outbound connections is reached.  Feeler
+	// connections test whether addresses are reachable so the caller can
+	// keep its set of addresses known to work fresh.  Feeler connections
+	// are disabled when zero or when GetFeelerAddress is nil.
+	FeelerInterval time.Duration
+
+	// GetFeelerAddress returns an address to make a feeler connection to.
+	GetFeelerAddress func() (net.Addr, error)
 }
 
 // registerPending is used to register a pending connection attempt. By
@@ -171,6 +207,15 @@ type handleFailed struct {
 	err error
 }
 
+// claimGroup is used to claim the network group of an address for a pending
+// connection request.  The reply is false when another automatic connection
+// request already claimed the group.
+type claimGroup struct {
+	c     *ConnReq
+	group string
+	reply chan bool
+}
+
 // ConnManager provides a manager to handle network connections.
 type ConnManager struct {
 	// The following variables must only be used atomically.
@@ -234,11 +279,58 @@ func (cm *ConnManager) connHandler() {
 
 		// conns represents the set of all actively connected peers.
 		conns = make(map[uint64]*ConnReq, cm.cfg.TargetOutbound)
+
+		// groups maps the network groups claimed by automatic
+		// connection requests to the ids of the requests.
+		groups = make(map[string]uint64)
 	)
 
+	// releaseGroup releases the network group claimed by the passed
+	// connection request, if any.
+	releaseGroup := func(c *ConnReq) {
+		if c.group == "" {
+			return
+		}
+		if groups[c.group] == c.id {
+			delete(groups, c.group)
+		}
+		c.group = ""
+	}
+
+	// Make feeler connections periodically when enabled.
+	var feelerTicks <-chan time.Time
+	if cm.cfg.FeelerInterval > 0 && cm.cfg.GetFeelerAddress != nil {
+		ticker := time.NewTicker(cm.cfg.FeelerInterval)
+		defer ticker.Stop()
+		feelerTicks = ticker.C
+	}
+
 out:
 	for {
 		select {
+		case <-feelerTicks:
+			// Only make a feeler connection when the target number
+			// of outbound connections is reached and no other one
+			// is in progress.
+			var outbound uint32
+			feelerActive := false
+			for _, c := range conns {
+				if c.Feeler {
+					feelerActive = true
+					continue
+				}
+				outbound++
+			}
+			for _, c := range pending {
+				if c.Feeler {
+					feelerActive = true
+				}
+			}
+			if feelerActive || outbound < cm.cfg.TargetOutbound {
+				continue
+			}
+			go cm.newFeelerReq()
+
 		case req := <-cm.requests:
 			switch msg := req.(type) {
 
@@ -248,6 +340,23 @@ out:
 				pending[msg.c.id] = connReq
 				close(msg.done)
 
+			case claimGroup:
+				id, ok := groups[msg.group]
+				if ok && id != msg.c.id {
+					msg.reply <- false
+					continue
+				}
+				if _, ok := pending[msg.c.id]; !ok {
+					// The request was canceled, so there is
+					// no need to hold on to the group.
+					msg.reply <- true
+					continue
+				}
+				releaseGroup(msg.c)
+				groups[msg.group] = msg.c.id
+				msg.c.group = msg.group
+				msg.reply <- true
+
 			case handleConnected:
 				connReq := msg.c
 
@@ -290,6 +399,7 @@ out:
 					connReq.updateState(ConnCanceled)
 					log.Debugf("Canceling: %v", connReq)
 					delete(pending, msg.id)
+					releaseGroup(connReq)
 					continue
 
 				}
@@ -308,10 +418,18 @@ out:
 					go cm.cfg.OnDisconnection(connReq)
 				}
 
+				// Automatic connection requests are replaced by
+				// new ones for other addresses, so the network
+				// group can be used by them.
+				if !connReq.Permanent {
+					releaseGroup(connReq)
+				}
+
 				// All internal state has been cleaned up, if
-				// this connection is being removed, we will
-				// make no further attempts with this request.
-				if !msg.retry {
+				// this connection is being removed, or it is a
+				// feeler connection, we will make no further
+				// attempts with this request.
+				if !msg.retry || connReq.Feeler {
 					connReq.updateState(ConnDisconnected)
 					continue
 				}
@@ -322,7 +440,7 @@ out:
 				// re added to the pending map, so that
 				// subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
+				if outboundCount(conns) < cm.cfg.TargetOutbound ||
 					connReq.Permanent {
 
 					connReq.updateState(ConnPending)
@@ -344,6 +462,15 @@ out:
 				connReq.updateState(ConnFailing)
 				log.Debugf("Failed to connect to %v: %v",
 					connReq, msg.err)
+				if !connReq.Permanent {
+					releaseGroup(connReq)
+				}
+
+				// Failed feeler connections are not replaced.
+				if connReq.Feeler {
+					delete(pending, connReq.id)
+					continue
+				}
 				cm.handleFailedConn(connReq)
 			}
 
@@ -356,6 +483,41 @@ out:
 	log.Trace("Connection handler done")
 }
 
+// outboundCount returns the number of the passed connections which count
+// toward the target number of outbound connections.
+func outboundCount(conns map[uint64]*ConnReq) uint32 {
+	var count uint32
+	for _, c := range conns {
+		if !c.Feeler {
+			count++
+		}
+	}
+	return count
+}
+
+// claimGroup claims the network group of the passed address for the passed
+// connection request.  It returns false when the group is already claimed by
+// another connection request.  Groups are not used when the configuration has
+// no GroupKey function.
+func (cm *ConnManager) claimGroup(c *ConnReq, addr net.Addr) bool {
+	if cm.cfg.GroupKey == nil {
+		return true
+	}
+
+	reply := make(chan bool, 1)
+	select {
+	case cm.requests <- claimGroup{c, cm.cfg.GroupKey(addr), reply}:
+	case <-cm.quit:
+		return false
+	}
+	select {
+	case ok := <-reply:
+		return ok
+	case <-cm.quit:
+		return false
+	}
+}
+
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
@@ -388,7 +550,22 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	addr, err := cm.cfg.GetNewAddress()
+	// Request addresses until one in a network group which is not used by
+	// other outbound connections is found.
+	var addr net.Addr
+	var err error
+	for attempt := 0; attempt < maxGroupAttempts; attempt++ {
+		addr, err = cm.cfg.GetNewAddress()
+		if err != nil {
+			break
+		}
+		if cm.claimGroup(c, addr) {
+			break
+		}
+		log.Debugf("Skipping %v in a network group which is already "+
+			"in use", addr)
+		addr, err = nil, ErrGroupInUse
+	}
 	if err != nil {
 		select {
 		case cm.requests <- handleFailed{c, err}:
@@ -402,6 +579,26 @@ func (cm *ConnManager) NewConnReq() {
 	cm.Connect(c)
 }
 
+// newFeelerReq creates a new feeler connection request and connects to the
+// corresponding address.
+func (cm *ConnManager) newFeelerReq() {
+	if atomic.LoadInt32(&cm.stop) != 0 {
+		return
+	}
+
+	addr, err := cm.cfg.GetFeelerAddress()
+	if err != nil {
+		log.Debugf("Unable to get feeler address: %v", err)
+		return
+	}
+
+	log.Debugf("Making feeler connection to %v", addr)
+	cm.Connect(&ConnReq{
+		Addr:   addr,
+		Feeler: true,
+	})
+}
+
 // Connect assigns an id and dials a connection to the address of the
 // connection request.
 func (cm *ConnManager) Connect(c *ConnReq) {
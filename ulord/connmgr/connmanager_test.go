@@ -216,6 +216,132 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestGroupDiversity tests that automatic connections are only made to
+// addresses in distinct network groups.
+func TestGroupDiversity(t *testing.T) {
+	// Generate addresses in three /16 networks with two addresses each.
+	var next uint32
+	groupKey := func(addr net.Addr) string {
+		ip := addr.(*net.TCPAddr).IP.To4()
+		return fmt.Sprintf("%d.%d", ip[0], ip[1])
+	}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound: 3,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			i := atomic.AddUint32(&next, 1) - 1
+			return &net.TCPAddr{
+				IP:   net.IPv4(10, byte(i/2%3), 0, byte(i%2+1)),
+				Port: 18555,
+			}, nil
+		},
+		GroupKey: groupKey,
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	groups := make(map[string]*ConnReq)
+	for i := 0; i < 3; i++ {
+		c := <-connected
+		group := groupKey(c.Addr)
+		if other, ok := groups[group]; ok {
+			t.Fatalf("group diversity: got %v and %v in group %s",
+				other.Addr, c.Addr, group)
+		}
+		groups[group] = c
+	}
+
+	// The group of a disconnected peer is released so it can be used by
+	// the replacement connection.
+	c := groups["10.0"]
+	cmgr.Disconnect(c.ID())
+	select {
+	case c := <-connected:
+		if group := groupKey(c.Addr); group != "10.0" {
+			t.Fatalf("group diversity: got replacement in group %s, "+
+				"want 10.0", group)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("group diversity: timeout waiting for replacement")
+	}
+}
+
+// TestFeelerConnections tests that feeler connections are made once the
+// target number of outbound connections is reached, one at a time, and are
+// never retried.
+func TestFeelerConnections(t *testing.T) {
+	feelerAddr := &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.2"),
+		Port: 18555,
+	}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound: 1,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		FeelerInterval: time.Millisecond,
+		GetFeelerAddress: func() (net.Addr, error) {
+			return feelerAddr, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	if c := <-connected; c.Feeler {
+		t.Fatalf("feeler: got feeler connection before the target " +
+			"was reached")
+	}
+	feeler := <-connected
+	if !feeler.Feeler || feeler.Addr != feelerAddr {
+		t.Fatalf("feeler: got connection to %v (feeler %v), want "+
+			"feeler connection to %v", feeler.Addr, feeler.Feeler,
+			feelerAddr)
+	}
+
+	// Only a single feeler connection is made at a time.
+	select {
+	case c := <-connected:
+		t.Fatalf("feeler: got unexpected connection to %v", c.Addr)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Disconnected feeler connections are not retried, but are replaced
+	// by new feeler connections.
+	cmgr.Disconnect(feeler.ID())
+	select {
+	case c := <-connected:
+		if !c.Feeler || c.ID() == feeler.ID() {
+			t.Fatalf("feeler: got connection to %v (feeler %v, id "+
+				"%d), want new feeler connection", c.Addr,
+				c.Feeler, c.ID())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("feeler: timeout waiting for feeler connection")
+	}
+	if state := feeler.State(); state != ConnDisconnected {
+		t.Fatalf("feeler: got state %v, want %v", state,
+			ConnDisconnected)
+	}
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -10,5 +10,10 @@ Connection Manager Overview
 Connection Manager handles all the general connection concerns such as
 maintaining a set number of outbound connections, sourcing peers, banning,
 limiting max connections, tor lookup, etc.
+
+When configured with a GroupKey function, automatic outbound connections are
+only made to addresses in distinct network groups, so an attacker controlling a
+single network can't occupy all of them.  Feeler connections may also be made
+periodically to test whether addresses are reachable.
 */
 package connmgr
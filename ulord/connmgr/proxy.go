@@ -0,0 +1,155 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/go-socks/socks"
+)
+
+var (
+	// ErrOnionDisabled is returned when dialing a tor hidden service while
+	// connecting to them is disabled.
+	ErrOnionDisabled = errors.New("tor has been disabled")
+
+	// ErrOnlyOnion is returned when dialing an address which is not a tor
+	// hidden service while only connecting to them is allowed.
+	ErrOnlyOnion = errors.New("only tor hidden services may be dialed")
+)
+
+// IsOnionHost returns whether the passed host is the address of a tor hidden
+// service, which consists of the base32 encoding of either a 10 byte (version
+// 2) or a 35 byte (version 3) service identifier followed by ".onion".
+func IsOnionHost(host string) bool {
+	if !strings.HasSuffix(host, ".onion") {
+		return false
+	}
+	id := strings.ToUpper(strings.TrimSuffix(host, ".onion"))
+	if len(id) != 16 && len(id) != 56 {
+		return false
+	}
+	_, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(id)
+	return err == nil
+}
+
+// Proxy describes a SOCKS5 proxy, such as tor, connections are dialed through.
+type Proxy struct {
+	// Addr is the host and port of the proxy.
+	Addr string
+
+	// Username and Password are the credentials used to authenticate with
+	// the proxy, if any.
+	Username string
+	Password string
+
+	// StreamIsolation makes connections to different peers authenticate
+	// with distinct credentials derived from the address of the peer,
+	// which causes tor to route them over separate circuits.  The
+	// configured credentials, if any, are ignored.
+	StreamIsolation bool
+
+	secretOnce sync.Once
+	secret     [32]byte
+}
+
+// isolationCredentials returns the credentials connections to the passed
+// address authenticate with when stream isolation is enabled.  They are derived
+// from a random secret so other parties can't link them to the address, and
+// are stable so reconnections to the same peer share its circuit.
+func (p *Proxy) isolationCredentials(addr string) (string, string) {
+	p.secretOnce.Do(func() {
+		if _, err := rand.Read(p.secret[:]); err != nil {
+			log.Warnf("Unable to generate stream isolation secret: %v",
+				err)
+		}
+	})
+	mac := hmac.New(sha256.New, p.secret[:])
+	mac.Write([]byte(addr))
+	sum := mac.Sum(nil)
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:])
+}
+
+// DialTimeout connects to the passed address on the named network through the
+// proxy.
+func (p *Proxy) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	proxy := &socks.Proxy{
+		Addr:     p.Addr,
+		Username: p.Username,
+		Password: p.Password,
+	}
+	if p.StreamIsolation {
+		proxy.Username, proxy.Password = p.isolationCredentials(addr)
+	}
+	return proxy.DialTimeout(network, addr, timeout)
+}
+
+// Dialer dials the addresses of peers directly or through SOCKS5 proxies
+// depending on whether they are tor hidden services.
+type Dialer struct {
+	// Proxy is the proxy all connections are dialed through.  Connections
+	// are dialed directly when it is nil.
+	Proxy *Proxy
+
+	// OnionProxy is the proxy connections to tor hidden services are
+	// dialed through.  Proxy is used when it is nil.
+	OnionProxy *Proxy
+
+	// NoOnion disables connecting to tor hidden services.
+	NoOnion bool
+
+	// OnlyOnion disables connecting to addresses which are not tor hidden
+	// services.
+	OnlyOnion bool
+
+	// Timeout is the maximum amount of time a dial waits for a connection
+	// to complete.
+	Timeout time.Duration
+}
+
+// DialTimeout connects to the passed address on the named network using the
+// proxy which is appropriate for the address.
+func (d *Dialer) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := d.Proxy
+	if IsOnionHost(host) {
+		if d.NoOnion {
+			return nil, ErrOnionDisabled
+		}
+		if d.OnionProxy != nil {
+			proxy = d.OnionProxy
+		}
+		if proxy == nil {
+			return nil, errors.New("tor hidden services require a proxy")
+		}
+		network = "tcp"
+	} else if d.OnlyOnion {
+		return nil, ErrOnlyOnion
+	}
+
+	if proxy == nil {
+		return net.DialTimeout(network, addr, timeout)
+	}
+	return proxy.DialTimeout(network, addr, timeout)
+}
+
+// Dial connects to the passed address.  It is suitable for use as the Dial
+// function of the connection manager.
+func (d *Dialer) Dial(addr net.Addr) (net.Conn, error) {
+	return d.DialTimeout(addr.Network(), addr.String(), d.Timeout)
+}
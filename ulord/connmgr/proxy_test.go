@@ -0,0 +1,145 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestIsOnionHost ensures the addresses of tor hidden services are recognized.
+func TestIsOnionHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"3g2upl4pq6kufc4m.onion", true},
+		{"vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion", true},
+		{"3G2UPL4PQ6KUFC4M.onion", true},
+		{"3g2upl4pq6kufc4.onion", false},
+		{"3g2upl4pq6kufc41.onion", false},
+		{".onion", false},
+		{"3g2upl4pq6kufc4m.onion.com", false},
+		{"example.com", false},
+		{"127.0.0.1", false},
+	}
+	for _, test := range tests {
+		if got := IsOnionHost(test.host); got != test.want {
+			t.Errorf("IsOnionHost(%q): got %v, want %v", test.host, got,
+				test.want)
+		}
+	}
+}
+
+// socksUsernames runs a SOCKS5 server which records the usernames clients
+// authenticate with and fails their requests afterwards.
+func socksUsernames(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	usernames := make(chan string, 10)
+	go func() {
+		defer l.Close()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				// Select username and password authentication.
+				var greeting [2]byte
+				if _, err := io.ReadFull(conn, greeting[:]); err != nil {
+					return
+				}
+				methods := make([]byte, greeting[1])
+				if _, err := io.ReadFull(conn, methods); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 2})
+
+				var header [2]byte
+				if _, err := io.ReadFull(conn, header[:]); err != nil {
+					return
+				}
+				username := make([]byte, header[1])
+				if _, err := io.ReadFull(conn, username); err != nil {
+					return
+				}
+				usernames <- string(username)
+				conn.Write([]byte{1, 1})
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), usernames
+}
+
+// TestDialer ensures the dialer routes connections through the appropriate
+// proxy and isolates the streams to different peers.
+func TestDialer(t *testing.T) {
+	const onionAddr = "3g2upl4pq6kufc4m.onion:9888"
+	proxyAddr, proxyUsernames := socksUsernames(t)
+	onionProxyAddr, onionUsernames := socksUsernames(t)
+	recvUsername := func(usernames <-chan string) string {
+		select {
+		case username := <-usernames:
+			return username
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for proxy authentication")
+		}
+		return ""
+	}
+
+	d := &Dialer{
+		Proxy: &Proxy{
+			Addr:            proxyAddr,
+			StreamIsolation: true,
+		},
+		OnionProxy: &Proxy{
+			Addr:     onionProxyAddr,
+			Username: "user",
+			Password: "pass",
+		},
+		Timeout: time.Second,
+	}
+
+	// Connections to tor hidden services use the onion proxy.
+	d.DialTimeout("tcp", onionAddr, time.Second)
+	if username := recvUsername(onionUsernames); username != "user" {
+		t.Fatalf("got username %q, want %q", username, "user")
+	}
+
+	// Connections to the same peer share credentials while connections to
+	// different peers use distinct ones.
+	d.DialTimeout("tcp", "10.0.0.1:9888", time.Second)
+	first := recvUsername(proxyUsernames)
+	d.DialTimeout("tcp", "10.0.0.1:9888", time.Second)
+	second := recvUsername(proxyUsernames)
+	d.DialTimeout("tcp", "10.0.0.2:9888", time.Second)
+	third := recvUsername(proxyUsernames)
+	if first != second {
+		t.Fatalf("got usernames %q and %q for the same peer", first,
+			second)
+	}
+	if first == third {
+		t.Fatalf("got username %q for different peers", first)
+	}
+
+	// Tor hidden services can be disabled or be the only addresses which
+	// can be dialed.
+	d.NoOnion = true
+	if _, err := d.DialTimeout("tcp", onionAddr, time.Second); err != ErrOnionDisabled {
+		t.Fatalf("DialTimeout: got error %v, want %v", err,
+			ErrOnionDisabled)
+	}
+	d.NoOnion, d.OnlyOnion = false, true
+	if _, err := d.DialTimeout("tcp", "10.0.0.1:9888", time.Second); err != ErrOnlyOnion {
+		t.Fatalf("DialTimeout: got error %v, want %v", err, ErrOnlyOnion)
+	}
+}
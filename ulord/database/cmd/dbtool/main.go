@@ -89,6 +89,17 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("migrate",
+		"Migrate the block database to another database backend",
+		"Copy the block database to a new database which stores the "+
+			"metadata with the backend given by --todbtype.  The "+
+			"block files are hard linked when possible and the "+
+			"source database is left intact.", &migrateCfg)
+	parser.AddCommand("restore",
+		"Restore the block database from a backup",
+		"Restore the block database from a backup written by the "+
+			"backupchainstate RPC.  The block database must not "+
+			"exist yet.", &restoreCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
@@ -0,0 +1,54 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ulordsuite/ulord/database/ffldb"
+)
+
+// migrateCmd defines the configuration options for the migrate command.
+type migrateCmd struct {
+	ToDbType string `long:"todbtype" description:"Database backend to migrate the block database to"`
+}
+
+var (
+	// migrateCfg defines the configuration options for the command.
+	migrateCfg = migrateCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *migrateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if !validDbType(cmd.ToDbType) {
+		str := "The specified target database type [%v] is invalid " +
+			"-- supported types %v"
+		return fmt.Errorf(str, cmd.ToDbType, knownDbTypes)
+	}
+	if cmd.ToDbType == cfg.DbType {
+		return fmt.Errorf("The block database already uses the %v "+
+			"backend", cfg.DbType)
+	}
+
+	// The database names are based on the database types.
+	srcPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	dstPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+
+		cmd.ToDbType)
+
+	log.Infof("Migrating block database from '%s' to '%s'", srcPath,
+		dstPath)
+	if err := ffldb.Migrate(cfg.DbType, srcPath, cmd.ToDbType, dstPath); err != nil {
+		return err
+	}
+	log.Infof("Migration complete -- start ulord with --dbtype=%s to use "+
+		"the migrated database", cmd.ToDbType)
+	return nil
+}
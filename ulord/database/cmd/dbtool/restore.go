@@ -0,0 +1,62 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ulordsuite/ulord/database/ffldb"
+)
+
+// restoreCmd defines the configuration options for the restore command.
+type restoreCmd struct {
+	InFile string `short:"i" long:"infile" description:"File containing a backup written by the backupchainstate RPC"`
+}
+
+var (
+	// restoreCfg defines the configuration options for the command.
+	restoreCfg = restoreCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *restoreCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if cmd.InFile == "" {
+		return errors.New("The backup file must be specified with " +
+			"--infile")
+	}
+	fi, err := os.Open(cmd.InFile)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	// The database name is based on the database type.
+	dbName := blockDbNamePrefix + "_" + cfg.DbType
+	dbPath := filepath.Join(cfg.DataDir, dbName)
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		return err
+	}
+
+	log.Infof("Restoring block database from '%s' to '%s'", cmd.InFile,
+		dbPath)
+	network, err := ffldb.Restore(cfg.DbType, dbPath, fi)
+	if err != nil {
+		return err
+	}
+	if network != activeNetParams.Net {
+		os.RemoveAll(dbPath)
+		return fmt.Errorf("The backup is for network %v rather than %v",
+			network, activeNetParams.Net)
+	}
+	return nil
+}
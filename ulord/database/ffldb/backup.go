@@ -0,0 +1,509 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ulordsuite/goleveldb/leveldb/util"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// backupVersion is the current version of the backup format.
+	backupVersion = 1
+
+	// backupChunkSize is the number of bytes of a block file which are read
+	// under the lock for the file at a time while it is backed up.
+	backupChunkSize = 1 << 20 // 1 MiB
+)
+
+// backupMagic identifies the data written by Backup.
+var backupMagic = [8]byte{'f', 'f', 'l', 'd', 'b', 'b', 'a', 'k'}
+
+// The backup format written by Backup is:
+//
+//   <magic><version><network><metadata entries><file count><block files>
+//   <checksum>
+//
+//   Field           Type      Size
+//   magic           [8]byte   8
+//   version         uint32    4
+//   network         uint32    4
+//   metadata entry  key len   variable (uvarint)
+//                   key       variable
+//                   value len variable (uvarint)
+//                   value     variable
+//   end of entries  0         1 (uvarint)
+//   file count      uint32    4
+//   block file      file num  4
+//                   size      4
+//                   data      variable
+//   checksum        uint32    4
+//
+// The checksum is the Castagnoli CRC-32 of all of the previous data.  All
+// fixed size integers are little endian.
+
+// backupWriter writes the fields of a backup while accumulating the checksum
+// of everything written.
+type backupWriter struct {
+	w       *bufio.Writer
+	hasher  hash.Hash32
+	scratch [binary.MaxVarintLen64]byte
+}
+
+// Write writes the passed bytes and adds them to the checksum.
+func (bw *backupWriter) Write(b []byte) (int, error) {
+	_, _ = bw.hasher.Write(b)
+	return bw.w.Write(b)
+}
+
+// writeUint32 writes the passed value as a little endian uint32.
+func (bw *backupWriter) writeUint32(v uint32) error {
+	byteOrder.PutUint32(bw.scratch[:4], v)
+	_, err := bw.Write(bw.scratch[:4])
+	return err
+}
+
+// writeVarBytes writes the passed bytes prefixed with their length.
+func (bw *backupWriter) writeVarBytes(b []byte) error {
+	n := binary.PutUvarint(bw.scratch[:], uint64(len(b)))
+	if _, err := bw.Write(bw.scratch[:n]); err != nil {
+		return err
+	}
+	_, err := bw.Write(b)
+	return err
+}
+
+// Backup writes a consistent copy of the database as of the time it is called
+// to the passed writer while the database remains usable.  The copy is taken
+// from the same snapshot a read-only transaction uses, so blocks and metadata
+// committed while the backup is written are not part of it.  Block files which
+// are pruned while the backup is written cause it to fail.
+//
+// The written data can be restored to a new database with Restore.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Backup(w io.Writer) error {
+	return db.View(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		bw := &backupWriter{
+			w:      bufio.NewWriterSize(w, backupChunkSize),
+			hasher: crc32.New(castagnoli),
+		}
+
+		// The write cursor of the snapshot determines which block files
+		// and how much of the current one belong to the backup.
+		writeRow := tx.snapshot.Get(bucketizedKey(metadataBucketID,
+			writeLocKeyName))
+		if writeRow == nil {
+			str := "write cursor does not exist"
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+		curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+		if err != nil {
+			return err
+		}
+
+		if _, err := bw.Write(backupMagic[:]); err != nil {
+			return backupWriteErr(err)
+		}
+		if err := bw.writeUint32(backupVersion); err != nil {
+			return backupWriteErr(err)
+		}
+		if err := bw.writeUint32(uint32(db.store.network)); err != nil {
+			return backupWriteErr(err)
+		}
+
+		// Write every metadata key of the snapshot followed by an empty
+		// key to mark the end of the entries.
+		log.Infof("Backing up metadata")
+		iter := tx.snapshot.NewIterator(&util.Range{})
+		for ok := iter.First(); ok; ok = iter.Next() {
+			if err := bw.writeVarBytes(iter.Key()); err != nil {
+				iter.Release()
+				return backupWriteErr(err)
+			}
+			if err := bw.writeVarBytes(iter.Value()); err != nil {
+				iter.Release()
+				return backupWriteErr(err)
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return convertErr("failed to read metadata", err)
+		}
+		if err := bw.writeVarBytes(nil); err != nil {
+			return backupWriteErr(err)
+		}
+
+		// Files which were pruned do not exist and are skipped.
+		var fileNums []uint32
+		var fileSizes []uint32
+		for fileNum := uint32(0); fileNum <= curFileNum; fileNum++ {
+			fi, err := os.Stat(blockFilePath(db.store.basePath, fileNum))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				str := fmt.Sprintf("failed to stat block file %d: %v",
+					fileNum, err)
+				return makeDbErr(database.ErrDriverSpecific, str, err)
+			}
+			size := uint32(fi.Size())
+			if fileNum == curFileNum {
+				size = curOffset
+			}
+			fileNums = append(fileNums, fileNum)
+			fileSizes = append(fileSizes, size)
+		}
+		if err := bw.writeUint32(uint32(len(fileNums))); err != nil {
+			return backupWriteErr(err)
+		}
+		log.Infof("Backing up %d block files", len(fileNums))
+		for i, fileNum := range fileNums {
+			err := db.backupBlockFile(bw, fileNum, fileSizes[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := bw.writeUint32(bw.hasher.Sum32()); err != nil {
+			return backupWriteErr(err)
+		}
+		if err := bw.w.Flush(); err != nil {
+			return backupWriteErr(err)
+		}
+		log.Infof("Backup complete")
+		return nil
+	})
+}
+
+// backupBlockFile writes the first size bytes of the passed block file to the
+// backup.  The data is read in chunks so the lock for the file is not held
+// while the backup is written.
+func (db *db) backupBlockFile(bw *backupWriter, fileNum, size uint32) error {
+	if err := bw.writeUint32(fileNum); err != nil {
+		return backupWriteErr(err)
+	}
+	if err := bw.writeUint32(size); err != nil {
+		return backupWriteErr(err)
+	}
+
+	buf := make([]byte, backupChunkSize)
+	for offset := uint32(0); offset < size; {
+		chunk := buf
+		if remaining := size - offset; remaining < uint32(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+
+		blockFile, err := db.store.blockFile(fileNum)
+		if err != nil {
+			return err
+		}
+		_, err = blockFile.file.ReadAt(chunk, int64(offset))
+		blockFile.RUnlock()
+		if err != nil {
+			str := fmt.Sprintf("failed to read block file %d, offset "+
+				"%d: %v", fileNum, offset, err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+
+		if _, err := bw.Write(chunk); err != nil {
+			return backupWriteErr(err)
+		}
+		offset += uint32(len(chunk))
+	}
+	return nil
+}
+
+// backupWriteErr returns a database error for a failure to write a backup.
+func backupWriteErr(err error) error {
+	str := fmt.Sprintf("failed to write backup: %v", err)
+	return makeDbErr(database.ErrDriverSpecific, str, err)
+}
+
+// backupReader reads the fields of a backup while accumulating the checksum of
+// everything read.
+type backupReader struct {
+	r       *bufio.Reader
+	hasher  hash.Hash32
+	scratch [4]byte
+}
+
+// Read reads into the passed bytes and adds the read data to the checksum.
+func (br *backupReader) Read(b []byte) (int, error) {
+	n, err := br.r.Read(b)
+	_, _ = br.hasher.Write(b[:n])
+	return n, err
+}
+
+// ReadByte reads a single byte and adds it to the checksum.  It allows the
+// lengths of the metadata entries to be read with binary.ReadUvarint.
+func (br *backupReader) ReadByte() (byte, error) {
+	b, err := br.r.ReadByte()
+	if err == nil {
+		_, _ = br.hasher.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readUint32 reads a little endian uint32.
+func (br *backupReader) readUint32() (uint32, error) {
+	if _, err := io.ReadFull(br, br.scratch[:]); err != nil {
+		return 0, err
+	}
+	return byteOrder.Uint32(br.scratch[:]), nil
+}
+
+// readVarBytes reads bytes prefixed with their length.
+func (br *backupReader) readVarBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBackupEntrySize {
+		return nil, fmt.Errorf("metadata entry of %d bytes exceeds "+
+			"the maximum of %d bytes", n, maxBackupEntrySize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// maxBackupEntrySize is the maximum size of a metadata key or value which is
+// accepted when restoring a backup.  It protects against allocating huge
+// amounts of memory for corrupted backups.
+const maxBackupEntrySize = 1 << 28 // 256 MiB
+
+// Restore creates a new database at dbPath, whose metadata is stored with the
+// backend of the dbType driver, from the backup read from the passed reader.
+// The backup may have been written by a database using any backend.  The
+// database path must not exist yet and is removed again when the restore
+// fails.
+//
+// The returned network is the one of the backed up database, which must be
+// passed when opening the restored database.
+func Restore(dbType, dbPath string, r io.Reader) (wire.BitcoinNet, error) {
+	backend, ok := kvBackends[dbType]
+	if !ok {
+		str := fmt.Sprintf("driver %q is not registered", dbType)
+		return 0, makeDbErr(database.ErrDbUnknownType, str, nil)
+	}
+	if fileExists(dbPath) {
+		str := fmt.Sprintf("database %q already exists", dbPath)
+		return 0, makeDbErr(database.ErrDbExists, str, nil)
+	}
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		str := fmt.Sprintf("failed to create %q: %v", dbPath, err)
+		return 0, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	network, err := restore(backend, dbPath, r)
+	if err != nil {
+		_ = os.RemoveAll(dbPath)
+		return 0, err
+	}
+	log.Infof("Restore to %q complete", dbPath)
+	return network, nil
+}
+
+// restore restores the backup read from the passed reader to the new database
+// at dbPath.  The caller is responsible for removing the database on failure.
+func restore(backend *kvBackend, dbPath string, r io.Reader) (wire.BitcoinNet, error) {
+	br := &backupReader{
+		r:      bufio.NewReaderSize(r, backupChunkSize),
+		hasher: crc32.New(castagnoli),
+	}
+	corruptErr := func(err error) error {
+		str := fmt.Sprintf("failed to read backup: %v", err)
+		return makeDbErr(database.ErrCorruption, str, err)
+	}
+
+	var magic [len(backupMagic)]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return 0, corruptErr(err)
+	}
+	if magic != backupMagic {
+		str := "data is not a database backup"
+		return 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	version, err := br.readUint32()
+	if err != nil {
+		return 0, corruptErr(err)
+	}
+	if version != backupVersion {
+		str := fmt.Sprintf("unsupported backup version %d", version)
+		return 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	network, err := br.readUint32()
+	if err != nil {
+		return 0, corruptErr(err)
+	}
+
+	kv, err := backend.open(filepath.Join(dbPath, metadataDbName), true)
+	if err != nil {
+		return 0, err
+	}
+	defer kv.Close()
+
+	// Restore the metadata entries in batches until the empty key which
+	// marks their end.
+	log.Infof("Restoring metadata")
+	for done := false; !done; {
+		var readErr error
+		err := kv.Update(func(w kvWriter) error {
+			for n := 0; n < migrateBatchSize; n++ {
+				key, err := br.readVarBytes()
+				if err != nil {
+					readErr = err
+					return err
+				}
+				if len(key) == 0 {
+					done = true
+					return nil
+				}
+				value, err := br.readVarBytes()
+				if err != nil {
+					readErr = err
+					return err
+				}
+				if err := w.Put(key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if readErr != nil {
+			return 0, corruptErr(readErr)
+		}
+		if err != nil {
+			return 0, convertErr("failed to write metadata", err)
+		}
+	}
+
+	numFiles, err := br.readUint32()
+	if err != nil {
+		return 0, corruptErr(err)
+	}
+	log.Infof("Restoring %d block files", numFiles)
+	for i := uint32(0); i < numFiles; i++ {
+		fileNum, err := br.readUint32()
+		if err != nil {
+			return 0, corruptErr(err)
+		}
+		size, err := br.readUint32()
+		if err != nil {
+			return 0, corruptErr(err)
+		}
+		if err := restoreBlockFile(br, dbPath, fileNum, size); err != nil {
+			return 0, err
+		}
+	}
+
+	// The checksum covers everything before it, so it is calculated before
+	// reading it.
+	wantChecksum := br.hasher.Sum32()
+	checksum, err := br.readUint32()
+	if err != nil {
+		return 0, corruptErr(err)
+	}
+	if checksum != wantChecksum {
+		str := fmt.Sprintf("backup checksum does not match - got %x, "+
+			"want %x", wantChecksum, checksum)
+		return 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	if err := kv.Close(); err != nil {
+		return 0, convertErr("failed to close restored metadata", err)
+	}
+	return wire.BitcoinNet(network), nil
+}
+
+// restoreBlockFile writes the next size bytes of the backup to the passed
+// block file of the database at dbPath.
+func restoreBlockFile(br *backupReader, dbPath string, fileNum, size uint32) error {
+	filePath := blockFilePath(dbPath, fileNum)
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0666)
+	if err != nil {
+		str := fmt.Sprintf("failed to create block file %d: %v", fileNum,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	_, err = io.CopyN(file, br, int64(size))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		file.Close()
+		str := fmt.Sprintf("failed to restore block file %d: %v",
+			fileNum, err)
+		return makeDbErr(database.ErrCorruption, str, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		str := fmt.Sprintf("failed to sync block file %d: %v", fileNum,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return file.Close()
+}
+
+// VerifyIntegrity reads every block referenced by the block index and ensures
+// the checksum and network stored along with it in the block files match.  The
+// blocks are checked against the snapshot a read-only transaction uses, so the
+// database remains usable while they are verified.
+//
+// An error with the ErrCorruption code is returned for the first block which
+// fails the checks.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) VerifyIntegrity() error {
+	return db.View(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+
+		log.Infof("Verifying the integrity of the block files")
+		var numBlocks int
+		err := tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+			var hash chainhash.Hash
+			copy(hash[:], k)
+			if len(v) < blockLocSize {
+				str := fmt.Sprintf("block index entry for block "+
+					"%s is too short", hash)
+				return makeDbErr(database.ErrCorruption, str, nil)
+			}
+
+			// The read checks the checksum and network of the block.
+			loc := deserializeBlockLoc(v)
+			if _, err := db.store.readBlock(&hash, loc); err != nil {
+				return err
+			}
+
+			numBlocks++
+			if numBlocks%10000 == 0 {
+				log.Infof("Verified %d blocks", numBlocks)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Verified the integrity of %d blocks", numBlocks)
+		return nil
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/database/ffldb"
+)
+
+// TestBackupRestore ensures a backup holds the state of the database at the
+// time it was taken, restores to a database which passes the integrity check,
+// and corruption of either the backup or the block files is detected.
+func TestBackupRestore(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: unexpected error: %v", err)
+	}
+	stored, extra := blocks[:20], blocks[20]
+
+	srcPath := filepath.Join(os.TempDir(), "ffldb-backupsrc")
+	dstPath := filepath.Join(os.TempDir(), "ffldb-backupdst")
+	_ = os.RemoveAll(srcPath)
+	_ = os.RemoveAll(dstPath)
+	defer os.RemoveAll(srcPath)
+	defer os.RemoveAll(dstPath)
+
+	// Store the blocks with a small maximum file size so they span multiple
+	// files along with a metadata value.
+	db, err := database.Create(dbType, srcPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	var backup bytes.Buffer
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		err = db.Update(func(tx database.Tx) error {
+			for _, block := range stored {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return tx.Metadata().Put([]byte("key"), []byte("value"))
+		})
+		if err != nil {
+			return
+		}
+		if err = db.VerifyIntegrity(); err != nil {
+			return
+		}
+		if err = db.Backup(&backup); err != nil {
+			return
+		}
+
+		// Blocks stored after the backup must not be part of it.
+		err = db.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(extra)
+		})
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	network, err := ffldb.Restore(dbType, dstPath,
+		bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore: unexpected error: %v", err)
+	}
+	if network != blockDataNet {
+		t.Fatalf("Restore: got network %v, want %v", network,
+			blockDataNet)
+	}
+	db, err = database.Open(dbType, dstPath, network)
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	err = db.View(func(tx database.Tx) error {
+		if got := tx.Metadata().Get([]byte("key")); string(got) != "value" {
+			t.Errorf("Get: got %q, want %q", got, "value")
+		}
+		for _, block := range stored {
+			want, _ := block.Bytes()
+			got, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("FetchBlock: block %v mismatch",
+					block.Hash())
+			}
+		}
+		has, err := tx.HasBlock(extra.Hash())
+		if err != nil {
+			return err
+		}
+		if has {
+			t.Error("HasBlock: block stored after the backup was " +
+				"restored")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	if err := db.VerifyIntegrity(); err != nil {
+		t.Fatalf("VerifyIntegrity: unexpected error: %v", err)
+	}
+	db.Close()
+
+	// Corrupt the data of the first block and ensure the integrity check
+	// detects it.
+	filePath := filepath.Join(dstPath, "000000000.fdb")
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	fileData[100] ^= 0xff
+	if err := os.WriteFile(filePath, fileData, 0666); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	db, err = database.Open(dbType, dstPath, network)
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	err = db.VerifyIntegrity()
+	db.Close()
+	if dbErr, ok := err.(database.Error); !ok ||
+		dbErr.ErrorCode != database.ErrCorruption {
+
+		t.Fatalf("VerifyIntegrity: got error %v, want %v", err,
+			database.ErrCorruption)
+	}
+
+	// Restoring a corrupted or truncated backup or to an existing database
+	// must fail without leaving a database behind.
+	corrupted := append([]byte{}, backup.Bytes()...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	restorePath := dstPath + "2"
+	defer os.RemoveAll(restorePath)
+	tests := []struct {
+		name   string
+		dbPath string
+		data   []byte
+		code   database.ErrorCode
+	}{
+		{"corrupted", restorePath, corrupted, database.ErrCorruption},
+		{"truncated", restorePath, backup.Bytes()[:backup.Len()-1],
+			database.ErrCorruption},
+		{"not a backup", restorePath, []byte("garbage"),
+			database.ErrCorruption},
+		{"existing", dstPath, backup.Bytes(), database.ErrDbExists},
+	}
+	for _, test := range tests {
+		_, err := ffldb.Restore(dbType, test.dbPath,
+			bytes.NewReader(test.data))
+		if dbErr, ok := err.(database.Error); !ok || dbErr.ErrorCode != test.code {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.code)
+		}
+		if test.dbPath == restorePath {
+			if _, err := os.Stat(restorePath); !os.IsNotExist(err) {
+				t.Errorf("%s: restored database was not removed",
+					test.name)
+			}
+		}
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
@@ -295,6 +298,31 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	return blockFile, nil
 }
 
+// closeFile closes the passed flat file number if it is open for reads and
+// removes it from the open files.  It is used before deleting block files which
+// are no longer needed.
+func (s *blockStore) closeFile(fileNum uint32) {
+	s.obfMutex.Lock()
+	defer s.obfMutex.Unlock()
+
+	blockFile, ok := s.openBlockFiles[fileNum]
+	if !ok {
+		return
+	}
+
+	// Close the file under the write lock for the file in case any readers
+	// are currently reading from it so it's not closed out from under them.
+	blockFile.Lock()
+	_ = blockFile.file.Close()
+	blockFile.Unlock()
+
+	s.lruMutex.Lock()
+	s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+	delete(s.fileNumToLRUElem, fileNum)
+	s.lruMutex.Unlock()
+	delete(s.openBlockFiles, fileNum)
+}
+
 // deleteFile removes the block file for the passed flat file number.  The file
 // must already be closed and it is the responsibility of the caller to do any
 // other state cleanup necessary.
@@ -439,22 +467,29 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		wc.Unlock()
 	}
 
-	// All writes are done under the write lock for the file to ensure any
-	// readers are finished and blocked first.
-	wc.curFile.Lock()
-	defer wc.curFile.Unlock()
-
 	// Open the current file if needed.  This will typically only be the
 	// case when moving to the next file to write to or on initial database
 	// load.  However, it might also be the case if rollbacks happened after
-	// file writes started during a transaction commit.
+	// file writes started during a transaction commit.  The file handle is
+	// only replaced under the write lock for the file to ensure any readers
+	// are finished and blocked first.
+	wc.curFile.Lock()
 	if wc.curFile.file == nil {
 		file, err := s.openWriteFileFunc(wc.curFileNum)
 		if err != nil {
+			wc.curFile.Unlock()
 			return blockLocation{}, err
 		}
 		wc.curFile.file = file
 	}
+	wc.curFile.Unlock()
+
+	// The block is appended under the read lock for the file so readers of
+	// the blocks already in it are not blocked while it is written.  This is
+	// safe since the appended region is past the end of every block which
+	// is visible to readers, and there is only a single writer at a time.
+	wc.curFile.RLock()
+	defer wc.curFile.RUnlock()
 
 	// Bitcoin network.
 	origOffset := wc.curOffset
@@ -720,15 +755,9 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 func scanBlockFiles(dbPath string) (int, uint32) {
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
-		filePath := blockFilePath(dbPath, uint32(i))
-		st, err := os.Stat(filePath)
-		if err != nil {
-			break
-		}
-		lastFile = i
-
-		fileLen = uint32(st.Size())
+	if files := blockFileInfos(dbPath); len(files) > 0 {
+		lastFile = int(files[len(files)-1].fileNum)
+		fileLen = uint32(files[len(files)-1].size)
 	}
 
 	log.Tracef("Scan found latest block file #%d with length %d", lastFile,
@@ -736,6 +765,40 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 	return lastFile, fileLen
 }
 
+// blockFileInfo describes a flat file which houses blocks.
+type blockFileInfo struct {
+	fileNum uint32
+	size    int64
+}
+
+// blockFileInfos returns the flat files which house blocks in the passed
+// database path ordered by their file number.  The file numbers are not
+// necessarily contiguous since the oldest files are deleted when the blocks are
+// pruned.
+func blockFileInfos(dbPath string) []blockFileInfo {
+	paths, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	files := make([]blockFileInfo, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".fdb")
+		fileNum, err := strconv.ParseUint(name, 10, 32)
+		if err != nil || blockFilePath(dbPath, uint32(fileNum)) != path {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, blockFileInfo{
+			fileNum: uint32(fileNum),
+			size:    st.Size(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].fileNum < files[j].fileNum
+	})
+	return files
+}
+
 // newBlockStore returns a new block store with the current block file number
 // and offset set and all fields initialized.
 func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
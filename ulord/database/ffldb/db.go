@@ -22,9 +22,7 @@ import (
 	"github.com/ulordsuite/goleveldb/leveldb"
 	"github.com/ulordsuite/goleveldb/leveldb/comparer"
 	ldberrors "github.com/ulordsuite/goleveldb/leveldb/errors"
-	"github.com/ulordsuite/goleveldb/leveldb/filter"
 	"github.com/ulordsuite/goleveldb/leveldb/iterator"
-	"github.com/ulordsuite/goleveldb/leveldb/opt"
 	"github.com/ulordsuite/goleveldb/leveldb/util"
 )
 
@@ -74,6 +72,10 @@ var (
 	// writeLocKeyName is the key used to store the current write file
 	// location.
 	writeLocKeyName = []byte("ffldb-writeloc")
+
+	// beenPrunedKeyName is the key used to record that blocks have been
+	// pruned from the database.
+	beenPrunedKeyName = []byte("ffldb-beenpruned")
 )
 
 // Common error strings.
@@ -970,6 +972,10 @@ type transaction struct {
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
 
+	// Flat block files that need to be deleted on commit since all of the
+	// blocks they house have been pruned.
+	pendingFileDeletions []uint32
+
 	// Active iterators that need to be notified when the pending keys have
 	// been updated so the cursors can properly handle updates to the
 	// transaction state.
@@ -1583,6 +1589,97 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks deletes the oldest flat block files until the total size of the
+// block files is no more than the passed target size in bytes.  The file which
+// is currently being written to is never deleted, so the target might not be
+// reached.  It returns the hashes of the blocks which were removed.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(targetSize uint64) ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Choose the oldest files to delete until the target size is met
+	// while leaving the current write file alone.
+	wc := tx.db.store.writeCursor
+	wc.RLock()
+	curFileNum := wc.curFileNum
+	wc.RUnlock()
+	files := blockFileInfos(tx.db.store.basePath)
+	var totalSize uint64
+	for _, file := range files {
+		totalSize += uint64(file.size)
+	}
+	pruneFiles := make(map[uint32]struct{})
+	for _, file := range files {
+		if totalSize <= targetSize || file.fileNum >= curFileNum {
+			break
+		}
+		pruneFiles[file.fileNum] = struct{}{}
+		totalSize -= uint64(file.size)
+	}
+	if len(pruneFiles) == 0 {
+		return nil, nil
+	}
+
+	// Remove the blocks housed in the chosen files from the block index.
+	var pruned []chainhash.Hash
+	cursor := tx.blockIdxBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		location := deserializeBlockLoc(cursor.Value())
+		if _, ok := pruneFiles[location.blockFileNum]; !ok {
+			continue
+		}
+		var hash chainhash.Hash
+		copy(hash[:], cursor.Key())
+		pruned = append(pruned, hash)
+	}
+	for i := range pruned {
+		if err := tx.blockIdxBucket.Delete(pruned[i][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record that the database has been pruned and delete the files once
+	// the transaction is committed.
+	if err := tx.metaBucket.Put(beenPrunedKeyName, []byte{1}); err != nil {
+		return nil, convertErr("failed to store pruned flag", err)
+	}
+	for fileNum := range pruneFiles {
+		tx.pendingFileDeletions = append(tx.pendingFileDeletions, fileNum)
+	}
+
+	return pruned, nil
+}
+
+// BeenPruned returns whether or not blocks have ever been pruned from the
+// database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) BeenPruned() (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	return tx.metaBucket.Get(beenPrunedKeyName) != nil, nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1597,6 +1694,9 @@ func (tx *transaction) close() {
 	tx.pendingKeys = nil
 	tx.pendingRemove = nil
 
+	// Clear pending block files that would have been deleted on commit.
+	tx.pendingFileDeletions = nil
+
 	// Release the snapshot.
 	if tx.snapshot != nil {
 		tx.snapshot.Release()
@@ -1666,7 +1766,27 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Delete the block files which house pruned blocks.  The cache is
+	// flushed first so the removal of the blocks from the block index is
+	// persisted before their data is gone.
+	if len(tx.pendingFileDeletions) == 0 {
+		return nil
+	}
+	if err := tx.db.cache.flush(); err != nil {
+		return err
+	}
+	for _, fileNum := range tx.pendingFileDeletions {
+		tx.db.store.closeFile(fileNum)
+		if err := tx.db.store.deleteFileFunc(fileNum); err != nil {
+			log.Warnf("Failed to delete pruned block file number "+
+				"%d: %v", fileNum, err)
+		}
+	}
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
@@ -1731,7 +1851,8 @@ type db struct {
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
 	store     *blockStore  // Handles read/writing blocks to flat files.
-	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
+	cache     *dbCache     // Cache layer which wraps underlying metadata store.
+	dbType    string       // Type of the driver of the metadata store backend.
 }
 
 // Enforce db implements the database.DB interface.
@@ -1742,7 +1863,7 @@ var _ database.DB = (*db)(nil)
 //
 // This function is part of the database.DB interface implementation.
 func (db *db) Type() string {
-	return dbType
+	return db.dbType
 }
 
 // begin is the implementation function for the Begin database method.  See its
@@ -1919,7 +2040,7 @@ func (db *db) Close() error {
 	// cache and clear all state without the individual locks.
 
 	// Close the database cache which will flush any existing entries to
-	// disk and close the underlying leveldb database.  Any error is saved
+	// disk and close the underlying metadata store.  Any error is saved
 	// and returned at the end after the remaining cleanup since the
 	// database will be marked closed even if this fails given there is no
 	// good way for the caller to recover from a failure here anyways.
@@ -1953,25 +2074,35 @@ func fileExists(name string) bool {
 
 // initDB creates the initial buckets and values used by the package.  This is
 // mainly in a separate function for testing purposes.
-func initDB(ldb *leveldb.DB) error {
-	// The starting block file write cursor location is file num 0, offset
-	// 0.
-	batch := new(leveldb.Batch)
-	batch.Put(bucketizedKey(metadataBucketID, writeLocKeyName),
-		serializeWriteRow(0, 0))
-
-	// Create block index bucket and set the current bucket id.
-	//
-	// NOTE: Since buckets are virtualized through the use of prefixes,
-	// there is no need to store the bucket index data for the metadata
-	// bucket in the database.  However, the first bucket ID to use does
-	// need to account for it to ensure there are no key collisions.
-	batch.Put(bucketIndexKey(metadataBucketID, blockIdxBucketName),
-		blockIdxBucketID[:])
-	batch.Put(curBucketIDKeyName, blockIdxBucketID[:])
-
-	// Write everything as a single batch.
-	if err := ldb.Write(batch, nil); err != nil {
+func initDB(kv kvStore) error {
+	// Write everything as a single atomic update.
+	err := kv.Update(func(w kvWriter) error {
+		// The starting block file write cursor location is file num 0,
+		// offset 0.
+		err := w.Put(bucketizedKey(metadataBucketID, writeLocKeyName),
+			serializeWriteRow(0, 0))
+		if err != nil {
+			return err
+		}
+
+		// Create block index bucket and set the current bucket id.
+		//
+		// NOTE: Since buckets are virtualized through the use of
+		// prefixes, there is no need to store the bucket index data for
+		// the metadata bucket in the database.  However, the first
+		// bucket ID to use does need to account for it to ensure there
+		// are no key collisions.
+		err = w.Put(bucketIndexKey(metadataBucketID, blockIdxBucketName),
+			blockIdxBucketID[:])
+		if err != nil {
+			return err
+		}
+		return w.Put(curBucketIDKeyName, blockIdxBucketID[:])
+	})
+	if err != nil {
+		if dbErr, ok := err.(database.Error); ok {
+			return dbErr
+		}
 		str := fmt.Sprintf("failed to initialize metadata database: %v",
 			err)
 		return convertErr(str, err)
@@ -1980,9 +2111,10 @@ func initDB(ldb *leveldb.DB) error {
 	return nil
 }
 
-// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
-// is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, error) {
+// openDB opens the database at the provided path with its metadata stored with
+// the passed backend.  database.ErrDbDoesNotExist is returned if the database
+// doesn't exist and the create flag is not set.
+func openDB(backend *kvBackend, dbPath string, network wire.BitcoinNet, create bool) (database.DB, error) {
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
@@ -1993,32 +2125,25 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 
 	// Ensure the full path to the database exists.
 	if !dbExists {
-		// The error can be ignored here since the call to
-		// leveldb.OpenFile will fail if the directory couldn't be
-		// created.
+		// The error can be ignored here since opening the metadata
+		// store will fail if the directory couldn't be created.
 		_ = os.MkdirAll(dbPath, 0700)
 	}
 
-	// Open the metadata database (will create it if needed).
-	opts := opt.Options{
-		ErrorIfExist: create,
-		Strict:       opt.DefaultStrict,
-		Compression:  opt.NoCompression,
-		Filter:       filter.NewBloomFilter(10),
-	}
-	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
+	// Open the metadata store (will create it if needed).
+	kv, err := backend.open(metadataDbPath, create)
 	if err != nil {
-		return nil, convertErr(err.Error(), err)
+		return nil, err
 	}
 
 	// Create the block store which includes scanning the existing flat
 	// block files to find what the current write cursor position is
 	// according to the data that is actually on disk.  Also create the
-	// database cache which wraps the underlying leveldb database to provide
+	// database cache which wraps the underlying metadata store to provide
 	// write caching.
 	store := newBlockStore(dbPath, network)
-	cache := newDbCache(ldb, store, defaultCacheSize, defaultFlushSecs)
-	pdb := &db{store: store, cache: cache}
+	cache := newDbCache(kv, store, defaultCacheSize, defaultFlushSecs)
+	pdb := &db{store: store, cache: cache, dbType: backend.dbType}
 
 	// Perform any reconciliation needed between the block and metadata as
 	// well as database initialization, if needed.
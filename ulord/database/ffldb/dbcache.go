@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/ulordsuite/ulord/database/internal/treap"
-	"github.com/ulordsuite/goleveldb/leveldb"
 	"github.com/ulordsuite/goleveldb/leveldb/iterator"
 	"github.com/ulordsuite/goleveldb/leveldb/util"
 )
@@ -285,7 +284,7 @@ func (iter *dbCacheIterator) Error() error {
 // dbCacheSnapshot defines a snapshot of the database cache and underlying
 // database at a particular point in time.
 type dbCacheSnapshot struct {
-	dbSnapshot    *leveldb.Snapshot
+	dbSnapshot    kvSnapshot
 	pendingKeys   *treap.Immutable
 	pendingRemove *treap.Immutable
 }
@@ -301,7 +300,7 @@ func (snap *dbCacheSnapshot) Has(key []byte) bool {
 	}
 
 	// Consult the database.
-	hasKey, _ := snap.dbSnapshot.Has(key, nil)
+	hasKey, _ := snap.dbSnapshot.Has(key)
 	return hasKey
 }
 
@@ -317,7 +316,7 @@ func (snap *dbCacheSnapshot) Get(key []byte) []byte {
 	}
 
 	// Consult the database.
-	value, err := snap.dbSnapshot.Get(key, nil)
+	value, err := snap.dbSnapshot.Get(key)
 	if err != nil {
 		return nil
 	}
@@ -340,7 +339,7 @@ func (snap *dbCacheSnapshot) Release() {
 // can be nil if the functionality is not desired.
 func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 	return &dbCacheIterator{
-		dbIter:        snap.dbSnapshot.NewIterator(slice, nil),
+		dbIter:        snap.dbSnapshot.NewIterator(slice),
 		cacheIter:     newLdbCacheIter(snap, slice),
 		cacheSnapshot: snap,
 	}
@@ -354,8 +353,8 @@ func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 // can commit transactions at will without incurring large performance hits due
 // to frequent disk syncs.
 type dbCache struct {
-	// ldb is the underlying leveldb DB for metadata.
-	ldb *leveldb.DB
+	// kv is the underlying key/value store for metadata.
+	kv kvStore
 
 	// store is used to sync blocks to flat files.
 	store *blockStore
@@ -396,49 +395,30 @@ type dbCache struct {
 //
 // The snapshot must be released after use by calling Release.
 func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
-	dbSnapshot, err := c.ldb.GetSnapshot()
-	if err != nil {
-		str := "failed to open transaction"
-		return nil, convertErr(str, err)
-	}
-
 	// Since the cached keys to be added and removed use an immutable treap,
 	// a snapshot is simply obtaining the root of the tree under the lock
 	// which is used to atomically swap the root.
+	//
+	// The snapshot of the underlying store is obtained under the same lock
+	// since a flush only clears the cache after the cached keys have been
+	// committed to the store.  Otherwise a snapshot of the store from before
+	// a flush could be paired with the cleared cache, hiding the flushed
+	// keys from the transaction.
 	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	dbSnapshot, err := c.kv.Snapshot()
+	if err != nil {
+		str := "failed to open transaction"
+		return nil, convertErr(str, err)
+	}
 	cacheSnapshot := &dbCacheSnapshot{
 		dbSnapshot:    dbSnapshot,
 		pendingKeys:   c.cachedKeys,
 		pendingRemove: c.cachedRemove,
 	}
-	c.cacheLock.RUnlock()
 	return cacheSnapshot, nil
 }
 
-// updateDB invokes the passed function in the context of a managed leveldb
-// transaction.  Any errors returned from the user-supplied function will cause
-// the transaction to be rolled back and are returned from this function.
-// Otherwise, the transaction is committed when the user-supplied function
-// returns a nil error.
-func (c *dbCache) updateDB(fn func(ldbTx *leveldb.Transaction) error) error {
-	// Start a leveldb transaction.
-	ldbTx, err := c.ldb.OpenTransaction()
-	if err != nil {
-		return convertErr("failed to open ldb transaction", err)
-	}
-
-	if err := fn(ldbTx); err != nil {
-		ldbTx.Discard()
-		return err
-	}
-
-	// Commit the leveldb transaction and convert any errors as needed.
-	if err := ldbTx.Commit(); err != nil {
-		return convertErr("failed to commit leveldb transaction", err)
-	}
-	return nil
-}
-
 // TreapForEacher is an interface which allows iteration of a treap in ascending
 // order using a user-supplied callback for each key/value pair.  It mainly
 // exists so both mutable and immutable treaps can be atomically committed to
@@ -450,13 +430,13 @@ type TreapForEacher interface {
 // commitTreaps atomically commits all of the passed pending add/update/remove
 // updates to the underlying database.
 func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error {
-	// Perform all leveldb updates using an atomic transaction.
-	return c.updateDB(func(ldbTx *leveldb.Transaction) error {
+	// Perform all updates using an atomic transaction.
+	return c.kv.Update(func(w kvWriter) error {
 		var innerErr error
 		pendingKeys.ForEach(func(k, v []byte) bool {
-			if dbErr := ldbTx.Put(k, v, nil); dbErr != nil {
+			if dbErr := w.Put(k, v); dbErr != nil {
 				str := fmt.Sprintf("failed to put key %q to "+
-					"ldb transaction", k)
+					"metadata transaction", k)
 				innerErr = convertErr(str, dbErr)
 				return false
 			}
@@ -467,9 +447,9 @@ func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error
 		}
 
 		pendingRemove.ForEach(func(k, v []byte) bool {
-			if dbErr := ldbTx.Delete(k, nil); dbErr != nil {
+			if dbErr := w.Delete(k); dbErr != nil {
 				str := fmt.Sprintf("failed to delete "+
-					"key %q from ldb transaction",
+					"key %q from metadata transaction",
 					k)
 				innerErr = convertErr(str, dbErr)
 				return false
@@ -509,7 +489,7 @@ func (c *dbCache) flush() error {
 		return nil
 	}
 
-	// Perform all leveldb updates using an atomic transaction.
+	// Perform all metadata updates using an atomic transaction.
 	if err := c.commitTreaps(cachedKeys, cachedRemove); err != nil {
 		return err
 	}
@@ -572,7 +552,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 			return err
 		}
 
-		// Perform all leveldb updates using an atomic transaction.
+		// Perform all metadata updates using an atomic transaction.
 		err := c.commitTreaps(tx.pendingKeys, tx.pendingRemove)
 		if err != nil {
 			return err
@@ -621,7 +601,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 }
 
 // Close cleanly shuts down the database cache by syncing all data and closing
-// the underlying leveldb database.
+// the underlying metadata store.
 //
 // This function MUST be called with the database write lock held.
 func (c *dbCache) Close() error {
@@ -630,13 +610,13 @@ func (c *dbCache) Close() error {
 		// Even if there is an error while flushing, attempt to close
 		// the underlying database.  The error is ignored since it would
 		// mask the flush error.
-		_ = c.ldb.Close()
+		_ = c.kv.Close()
 		return err
 	}
 
-	// Close the underlying leveldb database.
-	if err := c.ldb.Close(); err != nil {
-		str := "failed to close underlying leveldb database"
+	// Close the underlying metadata store.
+	if err := c.kv.Close(); err != nil {
+		str := "failed to close underlying metadata store"
 		return convertErr(str, err)
 	}
 
@@ -644,12 +624,12 @@ func (c *dbCache) Close() error {
 }
 
 // newDbCache returns a new database cache instance backed by the provided
-// leveldb instance.  The cache will be flushed to leveldb when the max size
+// metadata store.  The cache will be flushed to the store when the max size
 // exceeds the provided value or it has been longer than the provided interval
 // since the last flush.
-func newDbCache(ldb *leveldb.DB, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
+func newDbCache(kv kvStore, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
 	return &dbCache{
-		ldb:           ldb,
+		kv:            kv,
 		store:         store,
 		maxSize:       maxSize,
 		flushInterval: time.Second * time.Duration(flushIntervalSecs),
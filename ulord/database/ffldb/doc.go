@@ -25,5 +25,34 @@ database path as a string and the block network:
 	if err != nil {
 		// Handle error
 	}
+
+Metadata Backends
+
+The metadata store is abstracted so backends other than leveldb may be used,
+each of which is provided as a separate database type.  Building with the
+pebble build tag adds the "ffpebble" database type which stores the metadata in
+pebble.  Its compactions are less prone to stalling writes during the initial
+block download.
+
+The block files are identical for all backends.  An existing database is
+converted to another backend with the Migrate function, which copies the
+metadata and hard links the block files when possible:
+
+	err := ffldb.Migrate("ffldb", "path/to/database", "ffpebble",
+		"path/to/newdatabase")
+	if err != nil {
+		// Handle error
+	}
+
+Backups
+
+The Backup method of the database writes a consistent copy of it while it
+remains in use.  Restore creates a new database from such a backup with any of
+the backends:
+
+	network, err := ffldb.Restore("ffldb", "path/to/database", backupFile)
+	if err != nil {
+		// Handle error
+	}
 */
 package ffldb
@@ -14,12 +14,8 @@ import (
 
 var log = btclog.Disabled
 
-const (
-	dbType = "ffldb"
-)
-
 // parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, error) {
+func parseArgs(dbType, funcName string, args ...interface{}) (string, wire.BitcoinNet, error) {
 	if len(args) != 2 {
 		return "", 0, fmt.Errorf("invalid arguments to %s.%s -- "+
 			"expected database path and block network", dbType,
@@ -41,44 +37,50 @@ func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, e
 	return dbPath, network, nil
 }
 
-// openDBDriver is the callback provided during driver registration that opens
-// an existing database for use.
-func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Open", args...)
-	if err != nil {
-		return nil, err
-	}
-
-	return openDB(dbPath, network, false)
-}
-
-// createDBDriver is the callback provided during driver registration that
-// creates, initializes, and opens a database for use.
-func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Create", args...)
-	if err != nil {
-		return nil, err
-	}
-
-	return openDB(dbPath, network, true)
-}
-
 // useLogger is the callback provided during driver registration that sets the
 // current logger to the provided one.
 func useLogger(logger btclog.Logger) {
 	log = logger
 }
 
-func init() {
+// registerBackend registers the database driver which stores the metadata
+// with the passed backend.
+func registerBackend(backend *kvBackend) {
+	kvBackends[backend.dbType] = backend
+
+	// openDBDriver is the callback provided during driver registration
+	// that opens an existing database for use.
+	openDBDriver := func(args ...interface{}) (database.DB, error) {
+		dbPath, network, err := parseArgs(backend.dbType, "Open",
+			args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDB(backend, dbPath, network, false)
+	}
+
+	// createDBDriver is the callback provided during driver registration
+	// that creates, initializes, and opens a database for use.
+	createDBDriver := func(args ...interface{}) (database.DB, error) {
+		dbPath, network, err := parseArgs(backend.dbType, "Create",
+			args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDB(backend, dbPath, network, true)
+	}
+
 	// Register the driver.
 	driver := database.Driver{
-		DbType:    dbType,
+		DbType:    backend.dbType,
 		Create:    createDBDriver,
 		Open:      openDBDriver,
 		UseLogger: useLogger,
 	}
 	if err := database.RegisterDriver(driver); err != nil {
 		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
-			dbType, err))
+			backend.dbType, err))
 	}
 }
@@ -0,0 +1,72 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"github.com/ulordsuite/goleveldb/leveldb/iterator"
+	"github.com/ulordsuite/goleveldb/leveldb/util"
+)
+
+// kvStore is the key/value store which persists the metadata of the database.
+// Blocks are stored in flat files regardless of the store, so the backends only
+// differ in how the metadata is persisted.
+//
+// Iterators implement the leveldb iterator.Iterator interface since the cursors
+// merge them with the iterators over the cached and pending keys.
+type kvStore interface {
+	// Snapshot returns a read-only view of the store at the current point
+	// in time.  The snapshot must be released after use.
+	Snapshot() (kvSnapshot, error)
+
+	// Update invokes the passed function to stage writes which are
+	// atomically applied to the store when it returns a nil error and
+	// discarded otherwise.
+	Update(fn func(w kvWriter) error) error
+
+	// Close syncs and closes the store.
+	Close() error
+}
+
+// kvWriter stages the writes of an update to a kvStore.
+type kvWriter interface {
+	// Put sets the value of the passed key.
+	Put(key, value []byte) error
+
+	// Delete removes the passed key.
+	Delete(key []byte) error
+}
+
+// kvSnapshot is a read-only view of a kvStore at a particular point in time.
+type kvSnapshot interface {
+	// Has returns whether or not the passed key exists.
+	Has(key []byte) (bool, error)
+
+	// Get returns the value of the passed key or nil when the key does not
+	// exist.
+	Get(key []byte) ([]byte, error)
+
+	// NewIterator returns an iterator over the keys in the passed range,
+	// which may be nil to iterate over all keys.  The start key of the
+	// range is inclusive and the limit key is exclusive.
+	NewIterator(slice *util.Range) iterator.Iterator
+
+	// Release releases the snapshot.
+	Release()
+}
+
+// kvBackend describes a backend of the metadata store.  Each backend is
+// registered as a separate database driver whose type is the one of the
+// backend.
+type kvBackend struct {
+	dbType string
+
+	// open opens the store at the passed path.  The store is created when
+	// the create flag is set, in which case it is an error if it already
+	// exists.
+	open func(path string, create bool) (kvStore, error)
+}
+
+// kvBackends maps the database types of the registered backends to them.
+var kvBackends = make(map[string]*kvBackend)
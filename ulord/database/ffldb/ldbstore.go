@@ -0,0 +1,143 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"github.com/ulordsuite/goleveldb/leveldb"
+	"github.com/ulordsuite/goleveldb/leveldb/filter"
+	"github.com/ulordsuite/goleveldb/leveldb/iterator"
+	"github.com/ulordsuite/goleveldb/leveldb/opt"
+	"github.com/ulordsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// ldbDbType is the database type of the driver which stores the
+	// metadata in leveldb.
+	ldbDbType = "ffldb"
+)
+
+// ldbStore is a kvStore backed by leveldb.
+type ldbStore struct {
+	ldb *leveldb.DB
+}
+
+// Enforce ldbStore implements the kvStore interface.
+var _ kvStore = (*ldbStore)(nil)
+
+// Snapshot returns a read-only view of the store at the current point in time.
+//
+// This is part of the kvStore interface implementation.
+func (s *ldbStore) Snapshot() (kvSnapshot, error) {
+	snap, err := s.ldb.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return ldbSnapshot{snap}, nil
+}
+
+// Update invokes the passed function in the context of a managed leveldb
+// transaction which is committed when the function returns a nil error.
+//
+// This is part of the kvStore interface implementation.
+func (s *ldbStore) Update(fn func(w kvWriter) error) error {
+	ldbTx, err := s.ldb.OpenTransaction()
+	if err != nil {
+		return convertErr("failed to open ldb transaction", err)
+	}
+
+	if err := fn(ldbWriter{ldbTx}); err != nil {
+		ldbTx.Discard()
+		return err
+	}
+
+	// Commit the leveldb transaction and convert any errors as needed.
+	if err := ldbTx.Commit(); err != nil {
+		return convertErr("failed to commit leveldb transaction", err)
+	}
+	return nil
+}
+
+// Close closes the underlying leveldb database.
+//
+// This is part of the kvStore interface implementation.
+func (s *ldbStore) Close() error {
+	return s.ldb.Close()
+}
+
+// ldbWriter stages the writes of an update in a leveldb transaction.
+type ldbWriter struct {
+	ldbTx *leveldb.Transaction
+}
+
+// Put sets the value of the passed key.
+//
+// This is part of the kvWriter interface implementation.
+func (w ldbWriter) Put(key, value []byte) error {
+	return w.ldbTx.Put(key, value, nil)
+}
+
+// Delete removes the passed key.
+//
+// This is part of the kvWriter interface implementation.
+func (w ldbWriter) Delete(key []byte) error {
+	return w.ldbTx.Delete(key, nil)
+}
+
+// ldbSnapshot is a kvSnapshot backed by a leveldb snapshot.
+type ldbSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// Has returns whether or not the passed key exists.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s ldbSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(key, nil)
+}
+
+// Get returns the value of the passed key or nil when the key does not exist.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s ldbSnapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.snap.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+// NewIterator returns an iterator over the keys in the passed range.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s ldbSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	return s.snap.NewIterator(slice, nil)
+}
+
+// Release releases the snapshot.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s ldbSnapshot) Release() {
+	s.snap.Release()
+}
+
+// openLdbStore opens the leveldb database at the passed path, creating it when
+// the create flag is set.
+func openLdbStore(path string, create bool) (kvStore, error) {
+	opts := opt.Options{
+		ErrorIfExist: create,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+	ldb, err := leveldb.OpenFile(path, &opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	return &ldbStore{ldb: ldb}, nil
+}
+
+func init() {
+	registerBackend(&kvBackend{dbType: ldbDbType, open: openLdbStore})
+}
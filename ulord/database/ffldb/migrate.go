@@ -0,0 +1,175 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ulordsuite/ulord/database"
+)
+
+// migrateBatchSize is the number of metadata keys copied in a single update of
+// the destination store during a migration.
+const migrateBatchSize = 10000
+
+// Migrate copies the database at srcPath, whose metadata is stored with the
+// backend of the srcType driver, to a new database at dstPath whose metadata is
+// stored with the backend of the dstType driver.  Neither database may be open
+// while it is migrated.
+//
+// The flat block files are the same for all backends.  Completed files are
+// hard linked into the new database when possible since they are never written
+// again, while the current write file is copied since blocks are appended to
+// it.  The source database is left intact.
+func Migrate(srcType, srcPath, dstType, dstPath string) error {
+	srcBackend, ok := kvBackends[srcType]
+	if !ok {
+		str := fmt.Sprintf("driver %q is not registered", srcType)
+		return makeDbErr(database.ErrDbUnknownType, str, nil)
+	}
+	dstBackend, ok := kvBackends[dstType]
+	if !ok {
+		str := fmt.Sprintf("driver %q is not registered", dstType)
+		return makeDbErr(database.ErrDbUnknownType, str, nil)
+	}
+
+	srcMetadataPath := filepath.Join(srcPath, metadataDbName)
+	if !fileExists(srcMetadataPath) {
+		str := fmt.Sprintf("database %q does not exist", srcMetadataPath)
+		return makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+	dstMetadataPath := filepath.Join(dstPath, metadataDbName)
+	if fileExists(dstMetadataPath) {
+		str := fmt.Sprintf("database %q already exists", dstMetadataPath)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+	if err := os.MkdirAll(dstPath, 0700); err != nil {
+		str := fmt.Sprintf("failed to create %q: %v", dstPath, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	src, err := srcBackend.open(srcMetadataPath, false)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := dstBackend.open(dstMetadataPath, true)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	log.Infof("Migrating metadata from %s to %s", srcType, dstType)
+	curFileNum, err := migrateMetadata(src, dst)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Migrating %d block files", curFileNum+1)
+	for fileNum := uint32(0); fileNum <= curFileNum; fileNum++ {
+		srcFile := blockFilePath(srcPath, fileNum)
+		dstFile := blockFilePath(dstPath, fileNum)
+
+		// Files which were pruned do not exist.
+		if !fileExists(srcFile) {
+			continue
+		}
+		if fileNum < curFileNum && os.Link(srcFile, dstFile) == nil {
+			continue
+		}
+		if err := copyFile(srcFile, dstFile); err != nil {
+			str := fmt.Sprintf("failed to copy block file %q: %v",
+				srcFile, err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+
+	if err := dst.Close(); err != nil {
+		return convertErr("failed to close migrated metadata", err)
+	}
+	log.Infof("Migration to %q complete", dstPath)
+	return nil
+}
+
+// migrateMetadata copies all keys of the source store to the destination store
+// and returns the number of the current block file according to the write
+// cursor.
+func migrateMetadata(src, dst kvStore) (uint32, error) {
+	snap, err := src.Snapshot()
+	if err != nil {
+		return 0, convertErr("failed to open metadata snapshot", err)
+	}
+	defer snap.Release()
+
+	writeRow, err := snap.Get(bucketizedKey(metadataBucketID,
+		writeLocKeyName))
+	if err != nil {
+		return 0, convertErr("failed to read write cursor", err)
+	}
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, _, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return 0, err
+	}
+
+	iter := snap.NewIterator(nil)
+	defer iter.Release()
+	var numKeys int
+	for more := iter.First(); more; {
+		// The keys and values of the iterator are only valid until it
+		// is moved, so they are copied.
+		err := dst.Update(func(w kvWriter) error {
+			for n := 0; more && n < migrateBatchSize; n++ {
+				err := w.Put(copySlice(iter.Key()),
+					copySlice(iter.Value()))
+				if err != nil {
+					return err
+				}
+				numKeys++
+				more = iter.Next()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, convertErr("failed to write metadata", err)
+		}
+		log.Debugf("Migrated %d metadata keys", numKeys)
+	}
+	if err := iter.Error(); err != nil {
+		return 0, convertErr("failed to read metadata", err)
+	}
+
+	return curFileNum, nil
+}
+
+// copyFile copies the file at the passed source path to the destination path.
+func copyFile(srcPath, dstPath string) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return err
+	}
+	if err := dstFile.Sync(); err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/database/ffldb"
+)
+
+// TestMigrate ensures a database is copied along with its blocks spread over
+// several files and the migrated database is independent of the source.
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: unexpected error: %v", err)
+	}
+	stored, extra := blocks[:20], blocks[20]
+
+	srcPath := filepath.Join(os.TempDir(), "ffldb-migratesrc")
+	dstPath := filepath.Join(os.TempDir(), "ffldb-migratedst")
+	_ = os.RemoveAll(srcPath)
+	_ = os.RemoveAll(dstPath)
+	defer os.RemoveAll(srcPath)
+	defer os.RemoveAll(dstPath)
+
+	// Store the blocks with a small maximum file size so they span multiple
+	// files along with a metadata value.
+	db, err := database.Create(dbType, srcPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		err = db.Update(func(tx database.Tx) error {
+			for _, block := range stored {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return tx.Metadata().Put([]byte("key"), []byte("value"))
+		})
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	db.Close()
+
+	if err := ffldb.Migrate(dbType, srcPath, dbType, dstPath); err != nil {
+		t.Fatalf("Migrate: unexpected error: %v", err)
+	}
+
+	// The migrated database must hold the blocks and metadata.  Storing a
+	// block in it must not change the source database.
+	db, err = database.Open(dbType, dstPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open migrated database: %v", err)
+	}
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		err = db.Update(func(tx database.Tx) error {
+			if got := tx.Metadata().Get([]byte("key")); string(got) != "value" {
+				t.Errorf("Get: got %q, want %q", got, "value")
+			}
+			for _, block := range stored {
+				want, _ := block.Bytes()
+				got, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("FetchBlock: block %v mismatch",
+						block.Hash())
+				}
+			}
+			return tx.StoreBlock(extra)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	db.Close()
+
+	db, err = database.Open(dbType, srcPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to reopen source database: %v", err)
+	}
+	err = db.View(func(tx database.Tx) error {
+		has, err := tx.HasBlock(extra.Hash())
+		if err != nil {
+			return err
+		}
+		if has {
+			t.Error("HasBlock: block stored in the migrated database " +
+				"exists in the source database")
+		}
+		_, err = tx.FetchBlock(stored[len(stored)-1].Hash())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	db.Close()
+
+	// Migrating to an existing database or with an unknown backend must
+	// fail.
+	tests := []struct {
+		srcType string
+		dstType string
+		dstPath string
+		code    database.ErrorCode
+	}{
+		{dbType, dbType, dstPath, database.ErrDbExists},
+		{"unknown", dbType, dstPath + "2", database.ErrDbUnknownType},
+		{dbType, "unknown", dstPath + "2", database.ErrDbUnknownType},
+	}
+	for _, test := range tests {
+		err := ffldb.Migrate(test.srcType, srcPath, test.dstType,
+			test.dstPath)
+		if dbErr, ok := err.(database.Error); !ok || dbErr.ErrorCode != test.code {
+			t.Errorf("Migrate(%s, %s): got error %v, want %v",
+				test.srcType, test.dstType, err, test.code)
+		}
+	}
+}
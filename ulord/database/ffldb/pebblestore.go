@@ -0,0 +1,290 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build pebble
+// +build pebble
+
+package ffldb
+
+import (
+	"github.com/cockroachdb/pebble"
+	"github.com/ulordsuite/goleveldb/leveldb/iterator"
+	"github.com/ulordsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// pebbleDbType is the database type of the driver which stores the
+	// metadata in pebble.  It is only available when built with the pebble
+	// build tag.
+	pebbleDbType = "ffpebble"
+)
+
+// pebbleStore is a kvStore backed by pebble, a log-structured merge tree
+// written in Go whose compactions are less prone to stall writes than the
+// ones of leveldb.
+type pebbleStore struct {
+	pdb *pebble.DB
+}
+
+// Enforce pebbleStore implements the kvStore interface.
+var _ kvStore = (*pebbleStore)(nil)
+
+// Snapshot returns a read-only view of the store at the current point in time.
+//
+// This is part of the kvStore interface implementation.
+func (s *pebbleStore) Snapshot() (kvSnapshot, error) {
+	return pebbleSnapshot{s.pdb.NewSnapshot()}, nil
+}
+
+// Update invokes the passed function to stage writes in a pebble batch which
+// is committed and synced when the function returns a nil error.
+//
+// This is part of the kvStore interface implementation.
+func (s *pebbleStore) Update(fn func(w kvWriter) error) error {
+	batch := s.pdb.NewBatch()
+	defer batch.Close()
+
+	if err := fn(pebbleWriter{batch}); err != nil {
+		return err
+	}
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return convertErr("failed to commit pebble batch", err)
+	}
+	return nil
+}
+
+// Close closes the underlying pebble database.
+//
+// This is part of the kvStore interface implementation.
+func (s *pebbleStore) Close() error {
+	return s.pdb.Close()
+}
+
+// pebbleWriter stages the writes of an update in a pebble batch.
+type pebbleWriter struct {
+	batch *pebble.Batch
+}
+
+// Put sets the value of the passed key.
+//
+// This is part of the kvWriter interface implementation.
+func (w pebbleWriter) Put(key, value []byte) error {
+	return w.batch.Set(key, value, nil)
+}
+
+// Delete removes the passed key.
+//
+// This is part of the kvWriter interface implementation.
+func (w pebbleWriter) Delete(key []byte) error {
+	return w.batch.Delete(key, nil)
+}
+
+// pebbleSnapshot is a kvSnapshot backed by a pebble snapshot.
+type pebbleSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+// Has returns whether or not the passed key exists.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s pebbleSnapshot) Has(key []byte) (bool, error) {
+	value, err := s.Get(key)
+	return value != nil, err
+}
+
+// Get returns the value of the passed key or nil when the key does not exist.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s pebbleSnapshot) Get(key []byte) ([]byte, error) {
+	value, closer, err := s.snap.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The value is only valid until the closer is closed.
+	value = append([]byte{}, value...)
+	return value, closer.Close()
+}
+
+// NewIterator returns an iterator over the keys in the passed range.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s pebbleSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	var opts pebble.IterOptions
+	if slice != nil {
+		opts.LowerBound = slice.Start
+		opts.UpperBound = slice.Limit
+	}
+	iter, err := s.snap.NewIter(&opts)
+	if err != nil {
+		return iterator.NewEmptyIterator(err)
+	}
+	return &pebbleIterator{iter: iter}
+}
+
+// Release releases the snapshot.
+//
+// This is part of the kvSnapshot interface implementation.
+func (s pebbleSnapshot) Release() {
+	s.snap.Close()
+}
+
+// pebbleIterator wraps a pebble iterator to provide the semantics of the
+// leveldb iterator.Iterator interface.
+type pebbleIterator struct {
+	iter       *pebble.Iterator
+	positioned bool
+	released   bool
+	err        error
+	releaser   util.Releaser
+}
+
+// Enforce pebbleIterator implements the leveldb iterator.Iterator interface.
+var _ iterator.Iterator = (*pebbleIterator)(nil)
+
+// First moves the iterator to the first key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) First() bool {
+	if it.released {
+		return false
+	}
+	it.positioned = true
+	return it.iter.First()
+}
+
+// Last moves the iterator to the last key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Last() bool {
+	if it.released {
+		return false
+	}
+	it.positioned = true
+	return it.iter.Last()
+}
+
+// Seek moves the iterator to the first key/value pair whose key is greater
+// than or equal to the given key.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Seek(key []byte) bool {
+	if it.released {
+		return false
+	}
+	it.positioned = true
+	return it.iter.SeekGE(key)
+}
+
+// Next moves the iterator to the next key/value pair.  An iterator which is not
+// positioned yet moves to the first pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Next() bool {
+	if !it.positioned {
+		return it.First()
+	}
+	if it.released || !it.iter.Valid() {
+		return false
+	}
+	return it.iter.Next()
+}
+
+// Prev moves the iterator to the previous key/value pair.  An iterator which is
+// not positioned yet moves to the last pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Prev() bool {
+	if !it.positioned {
+		return it.Last()
+	}
+	if it.released || !it.iter.Valid() {
+		return false
+	}
+	return it.iter.Prev()
+}
+
+// Valid returns whether the iterator is positioned at a key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Valid() bool {
+	return !it.released && it.positioned && it.iter.Valid()
+}
+
+// Key returns the key of the current key/value pair or nil if done.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.iter.Key()
+}
+
+// Value returns the value of the current key/value pair or nil if done.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Value() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.iter.Value()
+}
+
+// Error returns any accumulated error.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.released {
+		return nil
+	}
+	return it.iter.Error()
+}
+
+// Release closes the underlying pebble iterator.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) Release() {
+	if it.released {
+		return
+	}
+	it.released = true
+	it.err = it.iter.Close()
+	if it.releaser != nil {
+		it.releaser.Release()
+		it.releaser = nil
+	}
+}
+
+// SetReleaser sets the releaser which is called when the iterator is
+// released.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (it *pebbleIterator) SetReleaser(releaser util.Releaser) {
+	it.releaser = releaser
+}
+
+// openPebbleStore opens the pebble database at the passed path, creating it
+// when the create flag is set.
+func openPebbleStore(path string, create bool) (kvStore, error) {
+	opts := &pebble.Options{
+		ErrorIfExists:    create,
+		ErrorIfNotExists: !create,
+	}
+	pdb, err := pebble.Open(path, opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	return &pebbleStore{pdb: pdb}, nil
+}
+
+func init() {
+	registerBackend(&kvBackend{dbType: pebbleDbType, open: openPebbleStore})
+}
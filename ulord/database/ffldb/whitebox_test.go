@@ -16,8 +16,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/database"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
@@ -179,7 +181,7 @@ func TestCornerCases(t *testing.T) {
 	// directory is needed.
 	testName := "openDB: fail due to file at target location"
 	wantErrCode := database.ErrDriverSpecific
-	idb, err := openDB(dbPath, blockDataNet, true)
+	idb, err := openDB(kvBackends[ldbDbType], dbPath, blockDataNet, true)
 	if !checkDbError(t, testName, err, wantErrCode) {
 		if err == nil {
 			idb.Close()
@@ -191,7 +193,7 @@ func TestCornerCases(t *testing.T) {
 	// Remove the file and create the database to run tests against.  It
 	// should be successful this time.
 	_ = os.RemoveAll(dbPath)
-	idb, err = openDB(dbPath, blockDataNet, true)
+	idb, err = openDB(kvBackends[ldbDbType], dbPath, blockDataNet, true)
 	if err != nil {
 		t.Errorf("openDB: unexpected error: %v", err)
 		return
@@ -215,14 +217,14 @@ func TestCornerCases(t *testing.T) {
 	_ = os.RemoveAll(filePath)
 
 	// Close the underlying leveldb database out from under the database.
-	ldb := idb.(*db).cache.ldb
-	ldb.Close()
+	kv := idb.(*db).cache.kv
+	kv.Close()
 
 	// Ensure initilization errors in the underlying database work as
 	// expected.
 	testName = "initDB: reinitialization"
 	wantErrCode = database.ErrDbNotOpen
-	err = initDB(ldb)
+	err = initDB(kv)
 	if !checkDbError(t, testName, err, wantErrCode) {
 		return
 	}
@@ -605,9 +607,9 @@ func TestFailureScenarios(t *testing.T) {
 	// Create a new database to run tests against.
 	dbPath := filepath.Join(os.TempDir(), "ffldb-failurescenarios")
 	_ = os.RemoveAll(dbPath)
-	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	idb, err := database.Create(ldbDbType, dbPath, blockDataNet)
 	if err != nil {
-		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		t.Errorf("Failed to create test database (%s) %v", ldbDbType, err)
 		return
 	}
 	defer os.RemoveAll(dbPath)
@@ -704,3 +706,249 @@ func TestFailureScenarios(t *testing.T) {
 	// Test various corruption scenarios.
 	testCorruption(tc)
 }
+
+// TestPruneBlocks ensures pruning deletes the oldest block files until the
+// target size is met, removes the blocks they house from the block index, and
+// the database can be reopened with the gap in the block files.
+func TestPruneBlocks(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "ffldb-pruneblocks")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", ldbDbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	store := idb.(*db).store
+	store.maxBlockFileSize = 1024 // 1KiB
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		idb.Close()
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		idb.Close()
+		t.Fatalf("StoreBlock: Unexpected error: %v", err)
+	}
+
+	// Prune the blocks down to a few files.
+	const targetSize = 4096
+	var pruned []chainhash.Hash
+	err = idb.Update(func(tx database.Tx) error {
+		var err error
+		pruned, err = tx.PruneBlocks(targetSize)
+		return err
+	})
+	if err != nil {
+		idb.Close()
+		t.Fatalf("PruneBlocks: Unexpected error: %v", err)
+	}
+	idb.Close()
+
+	// Ensure the remaining files meet the target, the oldest blocks were
+	// pruned, and the newest blocks remain after reopening the database.
+	var totalSize int64
+	for _, file := range blockFileInfos(dbPath) {
+		totalSize += file.size
+	}
+	if totalSize > targetSize {
+		t.Fatalf("block files use %d bytes after pruning, target %d",
+			totalSize, targetSize)
+	}
+	if len(pruned) == 0 || len(pruned) >= len(blocks) {
+		t.Fatalf("unexpected number of pruned blocks %d", len(pruned))
+	}
+	prunedSet := make(map[chainhash.Hash]struct{}, len(pruned))
+	for i := range pruned {
+		prunedSet[pruned[i]] = struct{}{}
+	}
+	for _, block := range blocks[:len(pruned)] {
+		if _, ok := prunedSet[*block.Hash()]; !ok {
+			t.Fatalf("block %v was not pruned", block.Hash())
+		}
+	}
+	idb, err = database.Open(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", ldbDbType, err)
+	}
+	defer idb.Close()
+	err = idb.View(func(tx database.Tx) error {
+		beenPruned, err := tx.BeenPruned()
+		if err != nil {
+			return err
+		}
+		if !beenPruned {
+			return fmt.Errorf("database not marked as pruned")
+		}
+		for i, block := range blocks {
+			_, err := tx.FetchBlock(block.Hash())
+			if i < len(pruned) {
+				if !checkDbError(t, "FetchBlock", err,
+					database.ErrBlockNotFound) {
+					return errSubTestFail
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: Unexpected error: %v", err)
+	}
+
+	// Ensure blocks are still appended to the latest block file.
+	wc := idb.(*db).store.writeCursor
+	lastFile, _ := scanBlockFiles(dbPath)
+	if wc.curFileNum != uint32(lastFile) {
+		t.Fatalf("write cursor file: got %d, want %d", wc.curFileNum,
+			lastFile)
+	}
+}
+
+// TestConcurrentReaders ensures read-only transactions proceed while a write
+// transaction is active and keep seeing the state of the database as of the
+// time they were started, even when the commit flushes the cache.
+func TestConcurrentReaders(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "ffldb-concurrentreaders")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", ldbDbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer idb.Close()
+
+	// Force every commit to flush the cache to the metadata store.
+	idb.(*db).cache.maxSize = 0
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	first, rest := blocks[0], blocks[1:]
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(first)
+	})
+	if err != nil {
+		t.Fatalf("StoreBlock: Unexpected error: %v", err)
+	}
+
+	// checkState ensures the passed transaction sees the first block and
+	// whether or not it sees the rest of the blocks and the metadata key.
+	key := []byte("concurrentkey")
+	checkState := func(tx database.Tx, wantRest bool) error {
+		if _, err := tx.FetchBlock(first.Hash()); err != nil {
+			return err
+		}
+		for _, block := range rest {
+			has, err := tx.HasBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if has != wantRest {
+				return fmt.Errorf("HasBlock(%v): got %v, want %v",
+					block.Hash(), has, wantRest)
+			}
+		}
+		if got := tx.Metadata().Get(key) != nil; got != wantRest {
+			return fmt.Errorf("Get: got existence %v, want %v", got,
+				wantRest)
+		}
+		return nil
+	}
+
+	// Start a read transaction which remains open across the write.
+	roTx, err := idb.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin: Unexpected error: %v", err)
+	}
+	defer roTx.Rollback()
+
+	// Stage the rest of the blocks in a write transaction and hold it open
+	// until the readers below finished.
+	staged := make(chan struct{})
+	proceed := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- idb.Update(func(tx database.Tx) error {
+			for _, block := range rest {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			if err := tx.Metadata().Put(key, []byte("value")); err != nil {
+				return err
+			}
+			close(staged)
+			<-proceed
+			return nil
+		})
+	}()
+	<-staged
+
+	// Readers must not wait for the write transaction.
+	viewErr := make(chan error, 1)
+	go func() {
+		viewErr <- idb.View(func(tx database.Tx) error {
+			return checkState(tx, false)
+		})
+	}()
+	select {
+	case err := <-viewErr:
+		if err != nil {
+			t.Fatalf("View: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("View: blocked by the active write transaction")
+	}
+
+	// Read the first block concurrently with the commit which appends the
+	// rest of the blocks to the same file.
+	readErr := make(chan error, 4)
+	for i := 0; i < cap(readErr); i++ {
+		go func() {
+			var err error
+			for j := 0; j < 50 && err == nil; j++ {
+				err = idb.View(func(tx database.Tx) error {
+					_, err := tx.FetchBlock(first.Hash())
+					return err
+				})
+			}
+			readErr <- err
+		}()
+	}
+	close(proceed)
+	if err := <-writeErr; err != nil {
+		t.Fatalf("Update: Unexpected error: %v", err)
+	}
+	for i := 0; i < cap(readErr); i++ {
+		if err := <-readErr; err != nil {
+			t.Fatalf("FetchBlock: Unexpected error: %v", err)
+		}
+	}
+
+	// The transaction started before the commit must still see the old
+	// state while new transactions see the committed one.
+	if err := checkState(roTx, false); err != nil {
+		t.Fatalf("open read transaction: %v", err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		return checkState(tx, true)
+	})
+	if err != nil {
+		t.Fatalf("View after commit: %v", err)
+	}
+}
@@ -8,6 +8,8 @@
 package database
 
 import (
+	"io"
+
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulordutil"
 )
@@ -389,6 +391,29 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks deletes the oldest stored blocks until the total size of
+	// the block storage is no more than the passed target size in bytes and
+	// returns the hashes of the deleted blocks.  Implementations store
+	// blocks in groups, so the target is approximate and blocks which were
+	// stored most recently are never deleted.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	//
+	// NOTE: The deleted blocks are no longer reported by HasBlock and the
+	// fetch functions return ErrBlockNotFound for them.
+	PruneBlocks(targetSize uint64) ([]chainhash.Hash, error)
+
+	// BeenPruned returns whether or not blocks have ever been deleted from
+	// the database by PruneBlocks.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	BeenPruned() (bool, error)
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
@@ -435,6 +460,11 @@ type DB interface {
 	// transaction can be started at a time.  The call will block when
 	// starting a read-write transaction when one is already open.
 	//
+	// Read-only transactions do not wait for an open read-write
+	// transaction.  Every transaction operates on a snapshot of the
+	// database as of the time it was started, so changes committed after
+	// that are not visible to it.
+	//
 	// NOTE: The transaction must be closed by calling Rollback or Commit on
 	// it when it is no longer needed.  Failure to do so can result in
 	// unclaimed memory and/or inablity to close the database due to locks
@@ -459,6 +489,23 @@ type DB interface {
 	// user-supplied function will result in a panic.
 	Update(fn func(tx Tx) error) error
 
+	// Backup writes a consistent copy of the database as of the time it
+	// is called to the passed writer.  The database remains usable while
+	// the backup is written, and changes committed in the meantime are not
+	// part of it.  The format of the backup is specific to the database
+	// implementation.
+	Backup(w io.Writer) error
+
+	// VerifyIntegrity reads all stored blocks and ensures they match the
+	// metadata which references them.  The database remains usable while
+	// the blocks are verified.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrCorruption if a stored block is corrupt
+	//   - ErrDbNotOpen if the database is not open
+	VerifyIntegrity() error
+
 	// Close cleanly shuts down the database and syncs all data.  It will
 	// block until all database transactions have been finalized (rolled
 	// back or committed).
@@ -34,13 +34,31 @@ Application Options:
       --listen=             Add an interface/port to listen for connections
                             (default all interfaces port: 8333, testnet: 18333)
       --maxpeers=           Max number of inbound and outbound peers (125)
+      --maxpeeruploadrate=  Maximum rate in KB/s at which data is sent to each
+                            peer -- 0 to disable
+      --maxpeerdownloadrate= Maximum rate in KB/s at which data is received from
+                            each peer -- 0 to disable
+      --maxuploadrate=      Maximum rate in KB/s at which data is sent to all
+                            peers -- 0 to disable
+      --maxdownloadrate=    Maximum rate in KB/s at which data is received from
+                            all peers -- 0 to disable
       --nobanning           Disable banning of misbehaving peers
       --banduration=        How long to ban misbehaving peers.  Valid time units
                             are {s, m, h}.  Minimum 1 second (24h0m0s)
       --banthreshold=       Maximum allowed ban score before disconnecting and
                             banning misbehaving peers.
+      --disconnectthreshold= Maximum allowed ban score before disconnecting
+                            misbehaving peers without banning them -- 0 to
+                            disable
+      --banpenalty=         Override the penalty of an offense of misbehaving
+                            peers.  Format: '<offense>:<persistent
+                            points>:<decaying points>[:disconnect]' -- may be
+                            specified multiple times
       --whitelist=          Add an IP network or IP that will not be banned.
                             (eg. 192.168.1.0/24 or ::1)
+      --trustedpeer=        Add an IP network or IP of peers whose transactions
+                            bypass the relay policy and which are preferred for
+                            syncing (eg. 192.168.1.0/24 or ::1)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
@@ -71,14 +89,29 @@ Application Options:
       --onionuser=          Username for onion proxy server
       --onionpass=          Password for onion proxy server
       --noonion             Disable connecting to tor hidden services
-      --torisolation        Enable Tor stream isolation by randomizing user
-                            credentials for each connection.
+      --torisolation        Enable Tor stream isolation by using distinct proxy
+                            credentials for each peer.
+      --onlyonion           Only connect to tor hidden services -- Requires
+                            --proxy or --onion
+      --asmap=              File mapping IP addresses to autonomous systems,
+                            which spreads outbound peers across autonomous
+                            systems instead of /16 networks
       --testnet             Use the test network
       --regtest             Use the regression test network
       --simnet              Use the simulation test network
+      --netparams=          Use the custom network whose parameters are defined
+                            by the given JSON file
       --addcheckpoint=      Add a custom checkpoint.  Format: '<height>:<hash>'
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
+      --checkpointfile=     Load additional checkpoints from a file signed by
+                            a trusted key
+      --checkpointpubkey=   Add a hex-encoded public key trusted to sign
+                            checkpoint files in addition to the ones of the
+                            active network -- may be specified multiple times
+      --maxreorgdepth=      Reject reorganizations of the main chain which
+                            disconnect more than this many blocks -- 0 to
+                            allow any depth
       --uacomment=          Comment to add to the user agent --
                             See BIP 14 for more information.
       --dbtype=             Database backend to use for the Block Chain (ffldb)
@@ -91,6 +124,8 @@ Application Options:
                             the log level for individual subsystems -- Use show
                             to list available subsystems (info)
       --upnp                Use UPnP to map our listening port outside of NAT
+      --natpmp              Use NAT-PMP to map our listening port outside of NAT
+                            when UPnP is disabled or unavailable
       --minrelaytxfee=      The minimum transaction fee in BTC/kB to be
                             considered a non-zero fee.
       --limitfreerelay=     Limit relay of transactions with no transaction fee
@@ -100,6 +135,40 @@ Application Options:
                             high priority for relaying
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --maxorphantxperpeer= Max number of orphan transactions from a single
+                            peer to keep in memory (25)
+      --rejectreplacement   Reject transactions that attempt to replace existing
+                            transactions within the mempool through the
+                            Replace-By-Fee (RBF) signaling policy.
+      --maxreplevictions=   Max number of mempool transactions a single
+                            Replace-By-Fee (RBF) transaction may evict,
+                            including their descendants (100)
+      --limitancestorcount= Do not accept transactions if they and their
+                            unconfirmed ancestors in the mempool exceed this
+                            many transactions (25)
+      --limitancestorsize=  Do not accept transactions if they and their
+                            unconfirmed ancestors in the mempool exceed this
+                            total virtual size in kilobytes (101)
+      --limitdescendantcount= Do not accept transactions if any of their
+                            unconfirmed ancestors in the mempool would have
+                            more than this many descendants, including itself
+                            (25)
+      --limitdescendantsize= Do not accept transactions if any of their
+                            unconfirmed ancestors in the mempool would have
+                            descendants exceeding this total virtual size in
+                            kilobytes, including itself (101)
+      --mempoolexpiry=      Evict transactions, along with their descendants,
+                            which have been in the mempool for longer than this
+                            duration (336h0m0s)
+      --maxmempool=         Keep the total virtual size of the transactions in
+                            the mempool below this many megabytes by evicting
+                            those paying the lowest fee rates (300)
+      --nopersistmempool    Do not save the mempool on shutdown and load it on
+                            startup
+      --mempooladdrindex    Index the transactions in the mempool by the
+                            addresses they pay to and spend from, so websocket
+                            clients registering addresses are notified of
+                            pending payments to them
       --generate            Generate (mine) bitcoins using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
@@ -115,6 +184,18 @@ Application Options:
       --nocfilters          Disable committed filtering (CF) support.
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
+      --utxocachemaxsize=   The maximum size in MiB of the cache of unspent
+                            transaction outputs held in memory before they are
+                            written to the database (250)
+      --prune=              Delete the data of old blocks to keep the block data
+                            under the target size in MiB while keeping their
+                            headers and the utxo set -- 0 to keep all blocks,
+                            otherwise at least 1536
+      --loadutxosnapshot=   Load a utxo set snapshot written by the dumptxoutset
+                            RPC into an empty database and validate the history
+                            of the chain in the background
+      --utxosnapshotcommitment= The commitment of the snapshot to load when it
+                            isn't listed in the chain parameters
       --blocksonly          Do not accept transactions from remote peers.
       --relaynonstd         Relay non-standard transactions regardless of the
                             default settings for the active network.
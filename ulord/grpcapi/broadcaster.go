@@ -0,0 +1,81 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberQueueSize is the number of notifications which are queued for a
+// stream before it is considered too slow and closed.
+const subscriberQueueSize = 1000
+
+// Broadcaster relays notifications to the streams subscribed to them.
+type Broadcaster struct {
+	mtx         sync.Mutex
+	subscribers map[chan Message]struct{}
+}
+
+// NewBroadcaster returns a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan Message]struct{})}
+}
+
+// Broadcast queues the passed notification to be sent to all subscribers.
+// Subscribers whose queue is full are dropped so a slow client does not block
+// the caller.
+//
+// This function is safe for concurrent access.
+func (b *Broadcaster) Broadcast(msg Message) {
+	b.mtx.Lock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	b.mtx.Unlock()
+}
+
+// Relay sends the notifications broadcast after it is called with the passed
+// function until the context is done.  It is meant to be called by stream
+// handlers.
+//
+// This function is safe for concurrent access.
+func (b *Broadcaster) Relay(ctx context.Context, send func(Message) error) error {
+	ch := make(chan Message, subscriberQueueSize)
+	b.mtx.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mtx.Unlock()
+
+	defer func() {
+		b.mtx.Lock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+		b.mtx.Unlock()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return Errorf(ResourceExhausted, "stream closed "+
+					"since notifications were not received "+
+					"fast enough")
+			}
+			if err := send(msg); err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
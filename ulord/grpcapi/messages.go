@@ -0,0 +1,367 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+// This file holds the messages defined in ulord.proto.  The field numbers
+// used when encoding them must match the definitions.
+
+// skipField is used to decode messages without fields.  All fields are
+// unknown and therefore ignored.
+func skipField(f *field) error {
+	return nil
+}
+
+// GetBestBlockRequest is the request of the GetBestBlock method.
+type GetBestBlockRequest struct{}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBestBlockRequest) Marshal() []byte {
+	return nil
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBestBlockRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, skipField)
+}
+
+// GetBestBlockResponse holds the hash and height of the best block of the main
+// chain.
+type GetBestBlockResponse struct {
+	// Hash is the hash of the block in the byte order it is displayed in.
+	Hash   string
+	Height int32
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBestBlockResponse) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Hash)
+	e.int32Field(2, m.Height)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBestBlockResponse) Unmarshal(b []byte) error {
+	*m = GetBestBlockResponse{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Hash, err = f.string()
+		case 2:
+			m.Height, err = f.int32()
+		}
+		return err
+	})
+}
+
+// GetBlockRequest is the request of the GetBlock method.
+type GetBlockRequest struct {
+	Hash string
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBlockRequest) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Hash)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBlockRequest) Unmarshal(b []byte) error {
+	*m = GetBlockRequest{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Hash, err = f.string()
+		}
+		return err
+	})
+}
+
+// GetBlockResponse holds a serialized block.
+type GetBlockResponse struct {
+	Block []byte
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBlockResponse) Marshal() []byte {
+	var e encoder
+	e.bytesField(1, m.Block)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBlockResponse) Unmarshal(b []byte) error {
+	*m = GetBlockResponse{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Block, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// GetBlockChainInfoRequest is the request of the GetBlockChainInfo method.
+type GetBlockChainInfoRequest struct{}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBlockChainInfoRequest) Marshal() []byte {
+	return nil
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBlockChainInfoRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, skipField)
+}
+
+// GetBlockChainInfoResponse describes the state of the block chain.
+type GetBlockChainInfoResponse struct {
+	// Chain is the name of the network.
+	Chain         string
+	Blocks        int32
+	Headers       int32
+	BestBlockHash string
+	Difficulty    float64
+	MedianTime    int64
+	Pruned        bool
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetBlockChainInfoResponse) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Chain)
+	e.int32Field(2, m.Blocks)
+	e.int32Field(3, m.Headers)
+	e.stringField(4, m.BestBlockHash)
+	e.doubleField(5, m.Difficulty)
+	e.int64Field(6, m.MedianTime)
+	e.boolField(7, m.Pruned)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetBlockChainInfoResponse) Unmarshal(b []byte) error {
+	*m = GetBlockChainInfoResponse{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Chain, err = f.string()
+		case 2:
+			m.Blocks, err = f.int32()
+		case 3:
+			m.Headers, err = f.int32()
+		case 4:
+			m.BestBlockHash, err = f.string()
+		case 5:
+			m.Difficulty, err = f.double()
+		case 6:
+			m.MedianTime, err = f.int64()
+		case 7:
+			m.Pruned, err = f.bool()
+		}
+		return err
+	})
+}
+
+// GetTransactionRequest is the request of the GetTransaction method.
+type GetTransactionRequest struct {
+	Txid string
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetTransactionRequest) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Txid)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetTransactionRequest) Unmarshal(b []byte) error {
+	*m = GetTransactionRequest{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Txid, err = f.string()
+		}
+		return err
+	})
+}
+
+// GetTransactionResponse holds a serialized transaction.
+type GetTransactionResponse struct {
+	Transaction []byte
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *GetTransactionResponse) Marshal() []byte {
+	var e encoder
+	e.bytesField(1, m.Transaction)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *GetTransactionResponse) Unmarshal(b []byte) error {
+	*m = GetTransactionResponse{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Transaction, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// SendTransactionRequest holds a serialized transaction to add to the memory
+// pool and relay to the network.
+type SendTransactionRequest struct {
+	Transaction   []byte
+	AllowHighFees bool
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *SendTransactionRequest) Marshal() []byte {
+	var e encoder
+	e.bytesField(1, m.Transaction)
+	e.boolField(2, m.AllowHighFees)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *SendTransactionRequest) Unmarshal(b []byte) error {
+	*m = SendTransactionRequest{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Transaction, err = f.bytes()
+		case 2:
+			m.AllowHighFees, err = f.bool()
+		}
+		return err
+	})
+}
+
+// SendTransactionResponse holds the hash of the sent transaction.
+type SendTransactionResponse struct {
+	Txid string
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *SendTransactionResponse) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Txid)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *SendTransactionResponse) Unmarshal(b []byte) error {
+	*m = SendTransactionResponse{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Txid, err = f.string()
+		}
+		return err
+	})
+}
+
+// SubscribeBlocksRequest is the request of the SubscribeBlocks method.
+type SubscribeBlocksRequest struct{}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *SubscribeBlocksRequest) Marshal() []byte {
+	return nil
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *SubscribeBlocksRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, skipField)
+}
+
+// BlockNotification is streamed to block subscribers when a block is connected
+// to or disconnected from the main chain.
+type BlockNotification struct {
+	Hash   string
+	Height int32
+
+	// Time is the timestamp of the block header in seconds since the epoch.
+	Time int64
+
+	// Connected is false when the block was disconnected.
+	Connected bool
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *BlockNotification) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Hash)
+	e.int32Field(2, m.Height)
+	e.int64Field(3, m.Time)
+	e.boolField(4, m.Connected)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *BlockNotification) Unmarshal(b []byte) error {
+	*m = BlockNotification{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Hash, err = f.string()
+		case 2:
+			m.Height, err = f.int32()
+		case 3:
+			m.Time, err = f.int64()
+		case 4:
+			m.Connected, err = f.bool()
+		}
+		return err
+	})
+}
+
+// SubscribeTransactionsRequest is the request of the SubscribeTransactions
+// method.
+type SubscribeTransactionsRequest struct{}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *SubscribeTransactionsRequest) Marshal() []byte {
+	return nil
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *SubscribeTransactionsRequest) Unmarshal(b []byte) error {
+	return decodeFields(b, skipField)
+}
+
+// TransactionNotification is streamed to transaction subscribers when a
+// transaction is accepted to the memory pool.
+type TransactionNotification struct {
+	Txid string
+
+	// Transaction is the serialized transaction.
+	Transaction []byte
+}
+
+// Marshal returns the protocol buffers encoding of the message.
+func (m *TransactionNotification) Marshal() []byte {
+	var e encoder
+	e.stringField(1, m.Txid)
+	e.bytesField(2, m.Transaction)
+	return e
+}
+
+// Unmarshal decodes the protocol buffers encoding of the message.
+func (m *TransactionNotification) Unmarshal(b []byte) error {
+	*m = TransactionNotification{}
+	return decodeFields(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Txid, err = f.string()
+		case 2:
+			m.Transaction, err = f.bytes()
+		}
+		return err
+	})
+}
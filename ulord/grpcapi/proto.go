@@ -0,0 +1,230 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// wireType is the protocol buffers wire type of an encoded field.
+type wireType uint8
+
+// These constants define the wire types of the protocol buffers encoding.
+// The deprecated group wire types are not supported.
+const (
+	wireVarint  wireType = 0
+	wireFixed64 wireType = 1
+	wireBytes   wireType = 2
+	wireFixed32 wireType = 5
+)
+
+// errTruncated is returned when an encoded message ends in the middle of a
+// field.
+var errTruncated = errors.New("truncated protobuf message")
+
+// Message is a protocol buffers message which is sent or received by the
+// service.
+type Message interface {
+	// Marshal returns the protocol buffers encoding of the message.
+	Marshal() []byte
+
+	// Unmarshal decodes the protocol buffers encoding of the message.
+	// Unknown fields are ignored.
+	Unmarshal(b []byte) error
+}
+
+// encoder appends the fields of a message to its protocol buffers encoding.
+// Fields holding the default value of their type are omitted as specified by
+// proto3.
+type encoder []byte
+
+// key appends the key of a field.
+func (e *encoder) key(num int, typ wireType) {
+	*e = binary.AppendUvarint(*e, uint64(num)<<3|uint64(typ))
+}
+
+// uint64Field appends a varint encoded field.
+func (e *encoder) uint64Field(num int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.key(num, wireVarint)
+	*e = binary.AppendUvarint(*e, v)
+}
+
+// int64Field appends an int64 field.
+func (e *encoder) int64Field(num int, v int64) {
+	e.uint64Field(num, uint64(v))
+}
+
+// int32Field appends an int32 field.  Negative values are sign extended to
+// 64 bits.
+func (e *encoder) int32Field(num int, v int32) {
+	e.uint64Field(num, uint64(int64(v)))
+}
+
+// boolField appends a bool field.
+func (e *encoder) boolField(num int, v bool) {
+	if v {
+		e.uint64Field(num, 1)
+	}
+}
+
+// doubleField appends a double field.
+func (e *encoder) doubleField(num int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.key(num, wireFixed64)
+	*e = binary.LittleEndian.AppendUint64(*e, math.Float64bits(v))
+}
+
+// bytesField appends a bytes field.
+func (e *encoder) bytesField(num int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	e.key(num, wireBytes)
+	*e = binary.AppendUvarint(*e, uint64(len(v)))
+	*e = append(*e, v...)
+}
+
+// stringField appends a string field.
+func (e *encoder) stringField(num int, v string) {
+	if v == "" {
+		return
+	}
+	e.key(num, wireBytes)
+	*e = binary.AppendUvarint(*e, uint64(len(v)))
+	*e = append(*e, v...)
+}
+
+// field is a field decoded from the protocol buffers encoding of a message.
+type field struct {
+	num int
+	typ wireType
+
+	// value holds the value of varint and fixed size fields while data
+	// holds the content of length-delimited fields.
+	value uint64
+	data  []byte
+}
+
+// checkType returns an error when the field does not have the passed wire
+// type.
+func (f *field) checkType(typ wireType) error {
+	if f.typ != typ {
+		return fmt.Errorf("field %d has wire type %d, want %d", f.num,
+			f.typ, typ)
+	}
+	return nil
+}
+
+// decodeFields decodes the fields of a protocol buffers message and calls the
+// passed function with each of them.
+func decodeFields(b []byte, fn func(f *field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+
+		f := field{num: int(key >> 3), typ: wireType(key & 7)}
+		if f.num <= 0 || key>>3 > math.MaxInt32 {
+			return fmt.Errorf("invalid field number %d", key>>3)
+		}
+		switch f.typ {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return errTruncated
+			}
+			f.data = b[n : n+int(length)]
+			b = b[n+int(length):]
+
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d",
+				f.typ, f.num)
+		}
+
+		if err := fn(&f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bytes returns the content of a bytes field.  The returned slice is a copy
+// so it may be retained after the message is decoded.
+func (f *field) bytes() ([]byte, error) {
+	if err := f.checkType(wireBytes); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// string returns the value of a string field.
+func (f *field) string() (string, error) {
+	if err := f.checkType(wireBytes); err != nil {
+		return "", err
+	}
+	return string(f.data), nil
+}
+
+// int64 returns the value of an int64 field.
+func (f *field) int64() (int64, error) {
+	if err := f.checkType(wireVarint); err != nil {
+		return 0, err
+	}
+	return int64(f.value), nil
+}
+
+// int32 returns the value of an int32 field.  Values out of range are
+// truncated as specified by the protocol buffers language guide.
+func (f *field) int32() (int32, error) {
+	v, err := f.int64()
+	return int32(v), err
+}
+
+// bool returns the value of a bool field.
+func (f *field) bool() (bool, error) {
+	if err := f.checkType(wireVarint); err != nil {
+		return false, err
+	}
+	return f.value != 0, nil
+}
+
+// double returns the value of a double field.
+func (f *field) double() (float64, error) {
+	if err := f.checkType(wireFixed64); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(f.value), nil
+}
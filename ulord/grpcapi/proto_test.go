@@ -0,0 +1,101 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestMessageEncoding ensures messages are encoded as specified by the
+// protocol buffers encoding and decoded back.
+func TestMessageEncoding(t *testing.T) {
+	tests := []struct {
+		msg     Message
+		decoded Message
+		encoded []byte
+	}{
+		{
+			msg:     &GetBestBlockResponse{Hash: "ab", Height: 300},
+			decoded: &GetBestBlockResponse{},
+			encoded: []byte{0x0a, 0x02, 'a', 'b', 0x10, 0xac, 0x02},
+		},
+		{
+			// Negative int32 values are sign extended.
+			msg:     &BlockNotification{Height: -1, Connected: true},
+			decoded: &BlockNotification{},
+			encoded: []byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0x01, 0x20, 0x01},
+		},
+		{
+			msg:     &GetBlockChainInfoResponse{Difficulty: 1},
+			decoded: &GetBlockChainInfoResponse{},
+			encoded: []byte{0x29, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f},
+		},
+		{
+			// Fields with default values are omitted.
+			msg:     &SendTransactionRequest{},
+			decoded: &SendTransactionRequest{},
+			encoded: nil,
+		},
+		{
+			msg: &TransactionNotification{Txid: "cd",
+				Transaction: []byte{1, 2}},
+			decoded: &TransactionNotification{},
+			encoded: []byte{0x0a, 0x02, 'c', 'd', 0x12, 0x02, 1, 2},
+		},
+	}
+
+	for i, test := range tests {
+		encoded := test.msg.Marshal()
+		if !bytes.Equal(encoded, test.encoded) {
+			t.Errorf("test %d: got encoding %x, want %x", i, encoded,
+				test.encoded)
+			continue
+		}
+		if err := test.decoded.Unmarshal(encoded); err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.decoded, test.msg) {
+			t.Errorf("test %d: decoded %+v, want %+v", i,
+				test.decoded, test.msg)
+		}
+	}
+}
+
+// TestMessageDecoding ensures unknown fields are skipped and invalid encodings
+// are rejected.
+func TestMessageDecoding(t *testing.T) {
+	// Unknown varint, fixed32, fixed64 and bytes fields precede the hash.
+	encoded := []byte{0x78, 0x05, 0x7d, 1, 2, 3, 4, 0x79, 1, 2, 3, 4, 5,
+		6, 7, 8, 0x72, 0x01, 0x00, 0x0a, 0x01, 'a'}
+	var req GetBlockRequest
+	if err := req.Unmarshal(encoded); err != nil {
+		t.Fatalf("Unmarshal: unexpected error: %v", err)
+	}
+	if req.Hash != "a" {
+		t.Fatalf("Unmarshal: got hash %q, want %q", req.Hash, "a")
+	}
+
+	invalid := [][]byte{
+		// Truncated length-delimited field.
+		{0x0a, 0x02, 'a'},
+		// Truncated varint.
+		{0x10, 0xff},
+		// Hash encoded as varint.
+		{0x08, 0x01},
+		// Group wire type.
+		{0x0b},
+		// Field number 0.
+		{0x02, 0x00},
+	}
+	for _, b := range invalid {
+		if err := req.Unmarshal(b); err == nil {
+			t.Errorf("Unmarshal: did not fail with %x", b)
+		}
+	}
+}
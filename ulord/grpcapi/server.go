@@ -0,0 +1,264 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ServiceName is the fully qualified name of the service defined in
+// ulord.proto.  The paths of the requests of its methods are in the
+// '/<ServiceName>/<method>' format.
+const ServiceName = "ulordrpc.Ulord"
+
+const (
+	// frameHeaderLen is the length of the header preceding each message
+	// sent over a stream, which holds the compressed flag and the length
+	// of the message.
+	frameHeaderLen = 5
+
+	// maxRequestSize is the maximum size of a request message.  It matches
+	// the default of the gRPC implementations.
+	maxRequestSize = 4 * 1024 * 1024
+)
+
+// Code is a gRPC status code returned to clients in the grpc-status trailer.
+type Code uint32
+
+// These constants define the gRPC status codes.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+// Error is an error returned to a client with its status code.  Errors of
+// other types returned by handlers are returned with the Internal code.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Errorf returns an error with the passed code and formatted message.
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// UnaryHandler handles the request of a method which returns a single
+// response.
+type UnaryHandler func(r *http.Request, req Message) (Message, error)
+
+// StreamHandler handles the request of a method which streams its responses.
+// The responses are sent with the passed function and the stream ends when the
+// handler returns.
+type StreamHandler func(r *http.Request, req Message, send func(Message) error) error
+
+// method describes how the requests of a method are handled.  Exactly one of
+// the handlers is set.
+type method struct {
+	newRequest func() Message
+	unary      UnaryHandler
+	stream     StreamHandler
+}
+
+// Server serves the methods of the service over HTTP/2 connections.  It is an
+// http.Handler so it may be used with a http.Server configured for HTTP/2.
+//
+// Compressed messages and client streaming are not supported.
+type Server struct {
+	authorize func(r *http.Request, method string) error
+	methods   map[string]*method
+}
+
+// NewServer returns a server without methods.  The passed function, when not
+// nil, is called with each request and the name of its method before the
+// method is invoked and the request is rejected with the error it returns.
+func NewServer(authorize func(r *http.Request, method string) error) *Server {
+	return &Server{
+		authorize: authorize,
+		methods:   make(map[string]*method),
+	}
+}
+
+// HandleUnary registers the handler of the method with the passed name whose
+// requests are created with newRequest.
+func (s *Server) HandleUnary(name string, newRequest func() Message, handler UnaryHandler) {
+	s.methods[name] = &method{newRequest: newRequest, unary: handler}
+}
+
+// HandleStream registers the handler of the streaming method with the passed
+// name whose requests are created with newRequest.
+func (s *Server) HandleStream(name string, newRequest func() Message, handler StreamHandler) {
+	s.methods[name] = &method{newRequest: newRequest, stream: handler}
+}
+
+// isGRPCContentType returns whether the passed content type is the one of gRPC
+// requests encoded with protocol buffers.
+func isGRPCContentType(contentType string) bool {
+	if contentType == "application/grpc" {
+		return true
+	}
+	return strings.HasPrefix(contentType, "application/grpc;") ||
+		strings.HasPrefix(contentType, "application/grpc+proto")
+}
+
+// ServeHTTP satisfies the http.Handler interface and serves a gRPC request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "505 gRPC requires HTTP/2.",
+			http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method Not Allowed.",
+			http.StatusMethodNotAllowed)
+		return
+	}
+	if !isGRPCContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "415 Unsupported Media Type.",
+			http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// The status of the call is always sent in the trailers since the
+	// headers are written before the method is invoked.
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	err := s.serve(w, r)
+
+	code, message := OK, ""
+	if err != nil {
+		code, message = Internal, err.Error()
+		if e, ok := err.(*Error); ok {
+			code, message = e.Code, e.Message
+		}
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status",
+		strconv.FormatUint(uint64(code), 10))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message",
+			encodeStatusMessage(message))
+	}
+}
+
+// serve reads the request message, invokes the handler of the requested
+// method and writes its responses.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	name := strings.TrimPrefix(r.URL.Path, "/"+ServiceName+"/")
+	m, ok := s.methods[name]
+	if !ok || name == r.URL.Path {
+		return Errorf(Unimplemented, "unknown method %s", r.URL.Path)
+	}
+	if s.authorize != nil {
+		if err := s.authorize(r, name); err != nil {
+			return err
+		}
+	}
+
+	payload, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	req := m.newRequest()
+	if err := req.Unmarshal(payload); err != nil {
+		return Errorf(InvalidArgument, "invalid request: %v", err)
+	}
+
+	if m.unary != nil {
+		resp, err := m.unary(r, req)
+		if err != nil {
+			return err
+		}
+		return writeMessage(w, resp)
+	}
+
+	// Flush the headers so the client knows the stream is established
+	// before the first response is sent.
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+	return m.stream(r, req, func(msg Message) error {
+		if err := writeMessage(w, msg); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+}
+
+// readMessage reads a length-prefixed message.
+func readMessage(r io.Reader) ([]byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, Errorf(InvalidArgument, "failed to read request "+
+			"header: %v", err)
+	}
+	if header[0] != 0 {
+		return nil, Errorf(Unimplemented, "compressed requests are "+
+			"not supported")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxRequestSize {
+		return nil, Errorf(ResourceExhausted, "request of %d bytes "+
+			"exceeds the maximum of %d bytes", length, maxRequestSize)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, Errorf(InvalidArgument, "failed to read request: %v",
+			err)
+	}
+	return payload, nil
+}
+
+// writeMessage writes a length-prefixed message.
+func writeMessage(w io.Writer, msg Message) error {
+	payload := msg.Marshal()
+	frame := make([]byte, frameHeaderLen, frameHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// encodeStatusMessage percent-encodes the passed message for the grpc-message
+// trailer as required by the gRPC protocol.
+func encodeStatusMessage(message string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
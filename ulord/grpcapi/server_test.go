@@ -0,0 +1,195 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server serving unencrypted HTTP/2 requests along
+// with a client to send them.
+func newTestServer(t *testing.T, s *Server) (*httptest.Server, *http.Client) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+
+	ts := httptest.NewUnstartedServer(s)
+	ts.Config.Protocols = &protocols
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	client := &http.Client{
+		Transport: &http.Transport{Protocols: &protocols},
+		Timeout:   10 * time.Second,
+	}
+	return ts, client
+}
+
+// call invokes the passed method and returns the response.
+func call(t *testing.T, ts *httptest.Server, client *http.Client, method string, req Message) *http.Response {
+	var body bytes.Buffer
+	if err := writeMessage(&body, req); err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	url := ts.URL + "/" + ServiceName + "/" + method
+	httpReq, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		t.Fatalf("NewRequest: unexpected error: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("Authorization", "secret")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", method, err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Fatalf("%s: unexpected response %s %s", method, resp.Proto,
+			resp.Status)
+	}
+	return resp
+}
+
+// readResponse reads a response message and returns it along with the status
+// in the trailers, which are only read once no message remains.
+func readResponse(t *testing.T, resp *http.Response, msg Message) (bool, string, string) {
+	payload, err := readMessage(resp.Body)
+	if err == nil {
+		if err := msg.Unmarshal(payload); err != nil {
+			t.Fatalf("Unmarshal: unexpected error: %v", err)
+		}
+		return true, "", ""
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	resp.Body.Close()
+	return false, resp.Trailer.Get("Grpc-Status"),
+		resp.Trailer.Get("Grpc-Message")
+}
+
+// TestServer ensures requests are authorized and dispatched to the handlers
+// of their methods, whose responses and errors are returned with the status
+// codes.
+func TestServer(t *testing.T) {
+	blocks := NewBroadcaster()
+	s := NewServer(func(r *http.Request, method string) error {
+		if r.Header.Get("Authorization") != "secret" {
+			return Errorf(Unauthenticated, "authentication failure")
+		}
+		if method == "SendTransaction" {
+			return Errorf(PermissionDenied, "not authorized")
+		}
+		return nil
+	})
+	s.HandleUnary("GetBlock", func() Message { return new(GetBlockRequest) },
+		func(r *http.Request, req Message) (Message, error) {
+			hash := req.(*GetBlockRequest).Hash
+			switch hash {
+			case "missing":
+				return nil, Errorf(NotFound, "Block not found: %s",
+					hash)
+			case "broken":
+				return nil, errors.New("100% broken")
+			}
+			return &GetBlockResponse{Block: []byte(hash)}, nil
+		})
+	s.HandleUnary("SendTransaction",
+		func() Message { return new(SendTransactionRequest) },
+		func(r *http.Request, req Message) (Message, error) {
+			return &SendTransactionResponse{}, nil
+		})
+	s.HandleStream("SubscribeBlocks",
+		func() Message { return new(SubscribeBlocksRequest) },
+		func(r *http.Request, req Message, send func(Message) error) error {
+			return blocks.Relay(r.Context(), send)
+		})
+	ts, client := newTestServer(t, s)
+
+	resp := call(t, ts, client, "GetBlock", &GetBlockRequest{Hash: "ab"})
+	var block GetBlockResponse
+	if ok, _, _ := readResponse(t, resp, &block); !ok ||
+		string(block.Block) != "ab" {
+
+		t.Fatalf("GetBlock: unexpected response %+v", block)
+	}
+	if ok, status, _ := readResponse(t, resp, &block); ok || status != "0" {
+		t.Fatalf("GetBlock: got status %q, want 0", status)
+	}
+
+	tests := []struct {
+		method  string
+		req     Message
+		status  string
+		message string
+	}{
+		{"GetBlock", &GetBlockRequest{Hash: "missing"}, "5",
+			"Block not found: missing"},
+		{"GetBlock", &GetBlockRequest{Hash: "broken"}, "13",
+			"100%25 broken"},
+		{"SendTransaction", &SendTransactionRequest{}, "7",
+			"not authorized"},
+		{"GetFoo", &GetBlockRequest{}, "12",
+			"unknown method /ulordrpc.Ulord/GetFoo"},
+	}
+	for _, test := range tests {
+		resp := call(t, ts, client, test.method, test.req)
+		ok, status, message := readResponse(t, resp, &block)
+		if ok || status != test.status || message != test.message {
+			t.Errorf("%s: got status %q (%q), want %q (%q)",
+				test.method, status, message, test.status,
+				test.message)
+		}
+	}
+
+	// Notifications broadcast once the stream is subscribed are received
+	// in order.
+	resp = call(t, ts, client, "SubscribeBlocks", &SubscribeBlocksRequest{})
+	for i := 0; ; i++ {
+		blocks.mtx.Lock()
+		n := len(blocks.subscribers)
+		blocks.mtx.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 500 {
+			t.Fatal("stream did not subscribe to notifications")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for height := int32(1); height <= 2; height++ {
+		blocks.Broadcast(&BlockNotification{Height: height})
+	}
+	for height := int32(1); height <= 2; height++ {
+		var n BlockNotification
+		if ok, _, _ := readResponse(t, resp, &n); !ok || n.Height != height {
+			t.Fatalf("SubscribeBlocks: got %+v, want height %d", n,
+				height)
+		}
+	}
+	resp.Body.Close()
+}
+
+// TestServerRejectsHTTP1 ensures requests which do not use HTTP/2 are
+// rejected.
+func TestServerRejectsHTTP1(t *testing.T) {
+	ts := httptest.NewServer(NewServer(nil))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/"+ServiceName+"/GetBlock",
+		"application/grpc", bytes.NewReader(make([]byte, frameHeaderLen)))
+	if err != nil {
+		t.Fatalf("Post: unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Fatalf("got status %s, want %d", resp.Status,
+			http.StatusHTTPVersionNotSupported)
+	}
+}
@@ -19,11 +19,14 @@ import (
 	"github.com/ulordsuite/ulordutil"
 )
 
-// solveBlock attempts to find a nonce which makes the passed block header hash
-// to a value less than the target difficulty. When a successful solution is
-// found true is returned and the nonce field of the passed header is updated
-// with the solution. False is returned if no solution exists.
-func solveBlock(header *wire.BlockHeader, targetDifficulty *big.Int) bool {
+// solveBlock attempts to find a nonce which makes the proof-of-work hash of the
+// passed block header on the passed network a value less than the target
+// difficulty. When a successful solution is found true is returned and the
+// nonce field of the passed header is updated with the solution. False is
+// returned if no solution exists.
+func solveBlock(header *wire.BlockHeader, net *chaincfg.Params,
+	targetDifficulty *big.Int) bool {
+
 	// sbResult is used by the solver goroutines to send results.
 	type sbResult struct {
 		found bool
@@ -43,7 +46,7 @@ func solveBlock(header *wire.BlockHeader, targetDifficulty *big.Int) bool {
 				return
 			default:
 				hdr.Nonce = i
-				hash := hdr.BlockHash()
+				hash := net.PowHash(&hdr)
 				if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
 					select {
 					case results <- sbResult{true, i}:
@@ -136,6 +139,17 @@ func CreateBlock(prevBlock *ulordutil.Block, inclusionTxs []*ulordutil.Tx,
 	blockVersion int32, blockTime time.Time, miningAddr ulordutil.Address,
 	mineTo []wire.TxOut, net *chaincfg.Params) (*ulordutil.Block, error) {
 
+	return createBlock(prevBlock, inclusionTxs, blockVersion, blockTime,
+		miningAddr, mineTo, 0, net)
+}
+
+// createBlock creates a new block like CreateBlock whose coinbase script
+// contains the passed extra nonce.
+func createBlock(prevBlock *ulordutil.Block, inclusionTxs []*ulordutil.Tx,
+	blockVersion int32, blockTime time.Time, miningAddr ulordutil.Address,
+	mineTo []wire.TxOut, extraNonce uint64,
+	net *chaincfg.Params) (*ulordutil.Block, error) {
+
 	var (
 		prevHash      *chainhash.Hash
 		blockHeight   int32
@@ -165,7 +179,6 @@ func CreateBlock(prevBlock *ulordutil.Block, inclusionTxs []*ulordutil.Tx,
 		ts = prevBlockTime.Add(time.Second)
 	}
 
-	extraNonce := uint64(0)
 	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce)
 	if err != nil {
 		return nil, err
@@ -196,7 +209,7 @@ func CreateBlock(prevBlock *ulordutil.Block, inclusionTxs []*ulordutil.Tx,
 		}
 	}
 
-	found := solveBlock(&block.Header, net.PowLimit)
+	found := solveBlock(&block.Header, net, net.PowLimit)
 	if !found {
 		return nil, errors.New("Unable to solve block")
 	}
@@ -0,0 +1,175 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// ChainGenerator generates a chain of blocks building from the genesis block of
+// a network entirely in process, without a running node.  Each block pays its
+// subsidy to a mining address and has a valid proof of work at the minimum
+// difficulty of the network, so the generator is only practical for networks
+// with a trivial proof-of-work limit such as simnet and regtest.
+//
+// The generated blocks and their headers can be fed directly to peers or to a
+// blockchain instance in order to produce canned chains for tests.  A
+// generator and its branches are not safe for concurrent access.
+type ChainGenerator struct {
+	net          *chaincfg.Params
+	miningAddr   ulordutil.Address
+	blockVersion int32
+
+	// blocks are the generated blocks, where the block at height h is at
+	// index h-1 since the genesis block is not included.
+	blocks []*ulordutil.Block
+
+	// extraNonce is included in the coinbase scripts of the blocks this
+	// generator creates so that the blocks of different branches differ.
+	// numBranches is the number of generators sharing the original chain.
+	extraNonce  uint64
+	numBranches *uint64
+}
+
+// NewChainGenerator returns a generator of a chain of the passed network whose
+// blocks pay their subsidy to the passed address.
+func NewChainGenerator(net *chaincfg.Params, miningAddr ulordutil.Address) (*ChainGenerator, error) {
+	if miningAddr == nil {
+		return nil, errors.New("a mining address is required")
+	}
+	if !miningAddr.IsForNet(net) {
+		return nil, fmt.Errorf("mining address %v is not for %s",
+			miningAddr, net.Name)
+	}
+
+	return &ChainGenerator{
+		net:          net,
+		miningAddr:   miningAddr,
+		blockVersion: BlockVersion,
+		numBranches:  new(uint64),
+	}, nil
+}
+
+// SetBlockVersion sets the version of the blocks generated afterwards.
+func (g *ChainGenerator) SetBlockVersion(version int32) {
+	g.blockVersion = version
+}
+
+// Height returns the height of the tip of the generated chain, which is zero
+// when no blocks have been generated yet.
+func (g *ChainGenerator) Height() int32 {
+	return int32(len(g.blocks))
+}
+
+// TipHash returns the hash of the tip of the generated chain, which is the hash
+// of the genesis block when no blocks have been generated yet.
+func (g *ChainGenerator) TipHash() *chainhash.Hash {
+	if len(g.blocks) == 0 {
+		return g.net.GenesisHash
+	}
+	return g.blocks[len(g.blocks)-1].Hash()
+}
+
+// NextBlock generates a block extending the tip of the chain which includes the
+// passed transactions after its coinbase transaction.  The timestamp of the
+// block is one second after the one of the tip.
+func (g *ChainGenerator) NextBlock(txns []*ulordutil.Tx) (*ulordutil.Block, error) {
+	var tip *ulordutil.Block
+	if len(g.blocks) != 0 {
+		tip = g.blocks[len(g.blocks)-1]
+	}
+
+	block, err := createBlock(tip, txns, g.blockVersion, time.Time{},
+		g.miningAddr, nil, g.extraNonce, g.net)
+	if err != nil {
+		return nil, err
+	}
+	g.blocks = append(g.blocks, block)
+	return block, nil
+}
+
+// Generate generates the passed number of blocks without any transactions
+// other than their coinbase transactions and returns them.
+func (g *ChainGenerator) Generate(numBlocks uint32) ([]*ulordutil.Block, error) {
+	blocks := make([]*ulordutil.Block, 0, numBlocks)
+	for i := uint32(0); i < numBlocks; i++ {
+		block, err := g.NextBlock(nil)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// Branch returns a new generator whose chain is the one of g up to the passed
+// height, so blocks generated from it fork from the chain of g at that height.
+// Passing zero forks from the genesis block.  The blocks generated by each
+// branch differ from the ones of all other branches at the same height.
+func (g *ChainGenerator) Branch(height int32) (*ChainGenerator, error) {
+	if height < 0 || height > g.Height() {
+		return nil, fmt.Errorf("height %d is not in the generated chain "+
+			"of height %d", height, g.Height())
+	}
+
+	*g.numBranches++
+	branch := *g
+	branch.blocks = append([]*ulordutil.Block(nil), g.blocks[:height]...)
+	branch.extraNonce = *g.numBranches
+	return &branch, nil
+}
+
+// BlockByHeight returns the generated block at the passed height.
+func (g *ChainGenerator) BlockByHeight(height int32) (*ulordutil.Block, error) {
+	if height < 1 || height > g.Height() {
+		return nil, fmt.Errorf("no generated block at height %d", height)
+	}
+	return g.blocks[height-1], nil
+}
+
+// Blocks returns all generated blocks in order of increasing height.
+func (g *ChainGenerator) Blocks() []*ulordutil.Block {
+	return append([]*ulordutil.Block(nil), g.blocks...)
+}
+
+// Headers returns the headers of the generated blocks from the passed height
+// through the tip of the chain in order of increasing height.
+func (g *ChainGenerator) Headers(startHeight int32) []wire.BlockHeader {
+	if startHeight < 1 {
+		startHeight = 1
+	}
+	if startHeight > g.Height() {
+		return nil
+	}
+
+	headers := make([]wire.BlockHeader, 0, g.Height()-startHeight+1)
+	for _, block := range g.blocks[startHeight-1:] {
+		headers = append(headers, block.MsgBlock().Header)
+	}
+	return headers
+}
+
+// HeadersMsg returns a headers message with the generated headers starting
+// with the one at the passed height, limited to the maximum number of headers
+// a message may contain.
+func (g *ChainGenerator) HeadersMsg(startHeight int32) *wire.MsgHeaders {
+	headers := g.Headers(startHeight)
+	if len(headers) > wire.MaxBlockHeadersPerMsg {
+		headers = headers[:wire.MaxBlockHeadersPerMsg]
+	}
+
+	msg := wire.NewMsgHeaders()
+	for i := range headers {
+		msg.AddBlockHeader(&headers[i])
+	}
+	return msg
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestChainGenerator ensures the chain generator produces linked blocks with
+// valid proofs of work and forks from the expected heights.
+func TestChainGenerator(t *testing.T) {
+	net := &chaincfg.SimNetParams
+	addr, err := ulordutil.NewAddressPubKeyHash(make([]byte, 20), net)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+
+	if _, err := NewChainGenerator(net, nil); err == nil {
+		t.Fatal("NewChainGenerator: expected error for nil address")
+	}
+	if _, err := NewChainGenerator(&chaincfg.RegressionNetParams, addr); err == nil {
+		t.Fatal("NewChainGenerator: expected error for address of " +
+			"another network")
+	}
+
+	g, err := NewChainGenerator(net, addr)
+	if err != nil {
+		t.Fatalf("NewChainGenerator: unexpected error: %v", err)
+	}
+	if *g.TipHash() != *net.GenesisHash {
+		t.Fatalf("TipHash: got %v, want genesis hash", g.TipHash())
+	}
+
+	const numBlocks = 3
+	blocks, err := g.Generate(numBlocks)
+	if err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+	if g.Height() != numBlocks {
+		t.Fatalf("Height: got %d, want %d", g.Height(), numBlocks)
+	}
+
+	prevHash := net.GenesisHash
+	for i, block := range blocks {
+		height := int32(i + 1)
+		if block.Height() != height {
+			t.Errorf("block %d: got height %d", height, block.Height())
+		}
+		header := &block.MsgBlock().Header
+		if header.PrevBlock != *prevHash {
+			t.Errorf("block %d: got previous block %v, want %v",
+				height, header.PrevBlock, prevHash)
+		}
+		if err := blockchain.CheckProofOfWorkParams(block, net); err != nil {
+			t.Errorf("block %d: invalid proof of work: %v", height,
+				err)
+		}
+		prevHash = block.Hash()
+	}
+	if *g.TipHash() != *prevHash {
+		t.Fatalf("TipHash: got %v, want %v", g.TipHash(), prevHash)
+	}
+
+	msg := g.HeadersMsg(2)
+	if len(msg.Headers) != numBlocks-1 {
+		t.Fatalf("HeadersMsg: got %d headers, want %d",
+			len(msg.Headers), numBlocks-1)
+	}
+	if msg.Headers[0].BlockHash() != *blocks[1].Hash() {
+		t.Fatalf("HeadersMsg: got first header %v, want %v",
+			msg.Headers[0].BlockHash(), blocks[1].Hash())
+	}
+	if headers := g.Headers(numBlocks + 1); len(headers) != 0 {
+		t.Fatalf("Headers: got %d headers past the tip", len(headers))
+	}
+
+	// A branch should share the blocks up to the fork height and then
+	// diverge without affecting the original chain.
+	branch, err := g.Branch(1)
+	if err != nil {
+		t.Fatalf("Branch: unexpected error: %v", err)
+	}
+	fork, err := branch.NextBlock(nil)
+	if err != nil {
+		t.Fatalf("NextBlock: unexpected error: %v", err)
+	}
+	if fork.MsgBlock().Header.PrevBlock != *blocks[0].Hash() {
+		t.Fatalf("branch block does not extend the fork point")
+	}
+	if *fork.Hash() == *blocks[1].Hash() {
+		t.Fatalf("branch block is identical to the original block")
+	}
+	if g.Height() != numBlocks {
+		t.Fatalf("branching modified the original chain")
+	}
+	if _, err := g.Branch(numBlocks + 1); err == nil {
+		t.Fatal("Branch: expected error for height past the tip")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// leakCheckTimeout is the time torn down harnesses are given to release
+	// their ports and to stop their goroutines before they are reported as
+	// leaked.
+	leakCheckTimeout = 10 * time.Second
+
+	// leakCheckInterval is the interval between checks for leaks while
+	// waiting for them to be released.
+	leakCheckInterval = 50 * time.Millisecond
+)
+
+// harnessPkgPrefixes identify the functions which start the goroutines of the
+// harnesses, either directly or through their RPC clients.
+var harnessPkgPrefixes = []string{
+	"github.com/ulordsuite/ulord/integration/rpctest.",
+	"github.com/ulordsuite/ulord/rpcclient.",
+}
+
+// LeakError is returned by TearDownAll when torn down harnesses left processes,
+// ports, directories, or goroutines behind.
+type LeakError struct {
+	// Leaks describes each of the leaked resources.
+	Leaks []string
+}
+
+// Error satisfies the error interface and lists the leaked resources.
+func (e *LeakError) Error() string {
+	return fmt.Sprintf("test harnesses leaked %d resources:\n\t%s",
+		len(e.Leaks), strings.Join(e.Leaks, "\n\t"))
+}
+
+// checkLeaks verifies that the passed torn down harnesses released all their
+// resources: the ulord process has exited, its ports are free, its directories
+// were removed, and no goroutines of any harness remain.  It returns a
+// *LeakError describing the offenders otherwise.
+//
+// NOTE: This must only be called once all active harnesses are torn down since
+// the goroutines of any remaining harness are reported as leaked.
+func checkLeaks(harnesses []*Harness) error {
+	var leaks []string
+	for _, h := range harnesses {
+		leaks = append(leaks, harnessLeaks(h)...)
+	}
+	leaks = append(leaks, goroutineLeaks()...)
+	if len(leaks) == 0 {
+		return nil
+	}
+	return &LeakError{Leaks: leaks}
+}
+
+// harnessLeaks returns a description of the process, ports, and directories
+// the passed torn down harness leaked.
+func harnessLeaks(h *Harness) []string {
+	var leaks []string
+	name := h.node.config.String()
+
+	cmd := h.node.cmd
+	if cmd.Process != nil && cmd.ProcessState == nil {
+		leaks = append(leaks, fmt.Sprintf("%s: ulord process %d did "+
+			"not exit", name, cmd.Process.Pid))
+	}
+
+	for _, addr := range []string{h.node.config.listen, h.node.config.rpcListen} {
+		if err := waitPortReleased(addr); err != nil {
+			leaks = append(leaks, fmt.Sprintf("%s: port %s was not "+
+				"released: %v", name, addr, err))
+		}
+	}
+
+	if _, err := os.Stat(h.testNodeDir); !os.IsNotExist(err) {
+		leaks = append(leaks, fmt.Sprintf("%s: directory %s was not "+
+			"removed", name, h.testNodeDir))
+	}
+
+	return leaks
+}
+
+// waitPortReleased waits up to leakCheckTimeout for the passed address to be
+// free to listen on.  It returns the error of the last attempt to listen on it
+// when it is not.
+func waitPortReleased(addr string) error {
+	deadline := time.Now().Add(leakCheckTimeout)
+	for {
+		l, err := net.Listen("tcp", addr)
+		if err == nil {
+			return l.Close()
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(leakCheckInterval)
+	}
+}
+
+// goroutineLeaks waits up to leakCheckTimeout for the goroutines of the
+// harnesses to exit and returns the stack traces of the ones which did not.
+func goroutineLeaks() []string {
+	deadline := time.Now().Add(leakCheckTimeout)
+	for {
+		leaks := harnessGoroutines()
+		if len(leaks) == 0 || time.Now().After(deadline) {
+			return leaks
+		}
+		time.Sleep(leakCheckInterval)
+	}
+}
+
+// harnessGoroutines returns the stack traces of all goroutines other than the
+// calling one which were started by the harnesses.
+func harnessGoroutines() []string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// The stack traces are separated by blank lines and the first one is
+	// the trace of the calling goroutine.
+	var leaks []string
+	stacks := bytes.Split(buf, []byte("\n\n"))
+	for _, stack := range stacks[1:] {
+		if startedByHarness(stack) {
+			leaks = append(leaks, string(stack))
+		}
+	}
+	return leaks
+}
+
+// startedByHarness returns whether the goroutine with the passed stack trace
+// was started by a function of the harnesses.
+func startedByHarness(stack []byte) bool {
+	const createdBy = "created by "
+	i := bytes.Index(stack, []byte(createdBy))
+	if i < 0 {
+		return false
+	}
+	creator := stack[i+len(createdBy):]
+	for _, prefix := range harnessPkgPrefixes {
+		if bytes.HasPrefix(creator, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"testing"
+)
+
+// TestStartedByHarness ensures goroutines are attributed to the harnesses by
+// the function which created them.
+func TestStartedByHarness(t *testing.T) {
+	tests := []struct {
+		name  string
+		stack string
+		want  bool
+	}{
+		{
+			name: "memwallet goroutine",
+			stack: "goroutine 20 [chan receive]:\n" +
+				"github.com/ulordsuite/ulord/integration/rpctest.(*memWallet).chainSyncer(0xc000)\n" +
+				"\t/go/src/memwallet.go:329 +0x4b\n" +
+				"created by github.com/ulordsuite/ulord/integration/rpctest.(*memWallet).Start in goroutine 1\n" +
+				"\t/go/src/memwallet.go:250 +0x56",
+			want: true,
+		},
+		{
+			name: "rpc client goroutine",
+			stack: "goroutine 21 [select]:\n" +
+				"github.com/ulordsuite/ulord/rpcclient.(*Client).wsOutHandler(0xc000)\n" +
+				"\t/go/src/infrastructure.go:500 +0x4b\n" +
+				"created by github.com/ulordsuite/ulord/rpcclient.(*Client).start in goroutine 1\n" +
+				"\t/go/src/infrastructure.go:900 +0x56",
+			want: true,
+		},
+		{
+			name: "test goroutine running harness code",
+			stack: "goroutine 6 [chan receive]:\n" +
+				"github.com/ulordsuite/ulord/integration/rpctest.TestHarness(0xc000)\n" +
+				"\t/go/src/rpc_harness_test.go:680 +0x4b\n" +
+				"created by testing.(*T).Run in goroutine 1\n" +
+				"\t/usr/lib/go/src/testing/testing.go:1648 +0x3ad",
+			want: false,
+		},
+		{
+			name: "main goroutine",
+			stack: "goroutine 1 [chan receive]:\n" +
+				"github.com/ulordsuite/ulord/integration/rpctest.TestMain(0xc000)\n" +
+				"\t/go/src/rpc_harness_test.go:640 +0x4b",
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		got := startedByHarness([]byte(test.stack))
+		if got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -14,10 +14,12 @@ import (
 	"github.com/ulordsuite/ulord/ulordec"
 	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/mempool"
 	"github.com/ulordsuite/ulord/rpcclient"
 	"github.com/ulordsuite/ulord/txscript"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/coinselect"
 	"github.com/ulordsuite/ulordutil/hdkeychain"
 )
 
@@ -33,6 +35,42 @@ var (
 	}
 )
 
+// AddressType identifies the kind of output script the addresses generated by
+// the memWallet pay to.
+type AddressType uint8
+
+const (
+	// AddrP2PKH is a pay-to-pubkey-hash address.
+	AddrP2PKH AddressType = iota
+
+	// AddrP2SH is a pay-to-script-hash address whose redeem script is a
+	// pay-to-pubkey-hash script.
+	AddrP2SH
+
+	// AddrP2WPKH is a pay-to-witness-pubkey-hash address.  Outputs paying
+	// to it can only be spent once segwit is active.
+	AddrP2WPKH
+)
+
+// AllAddressTypes is the list of all address types the memWallet can generate.
+var AllAddressTypes = []AddressType{AddrP2PKH, AddrP2SH, AddrP2WPKH}
+
+// addressTypeStrings is a map of address types back to their constant names
+// for pretty printing.
+var addressTypeStrings = map[AddressType]string{
+	AddrP2PKH:  "AddrP2PKH",
+	AddrP2SH:   "AddrP2SH",
+	AddrP2WPKH: "AddrP2WPKH",
+}
+
+// String returns the AddressType in human-readable form.
+func (t AddressType) String() string {
+	if s, ok := addressTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown AddressType (%d)", uint8(t))
+}
+
 // utxo represents an unspent output spendable by the memWallet. The maturity
 // height of the transaction is recorded in order to properly observe the
 // maturity period of direct coinbase outputs.
@@ -40,6 +78,7 @@ type utxo struct {
 	pkScript       []byte
 	value          ulordutil.Amount
 	keyIndex       uint32
+	addrType       AddressType
 	maturityHeight int32
 	isLocked       bool
 }
@@ -50,6 +89,58 @@ func (u *utxo) isMature(height int32) bool {
 	return height >= u.maturityHeight
 }
 
+const (
+	// p2pkhInputSize is the largest number of bytes an input spending a
+	// p2pkh output adds to a transaction: the outpoint, sequence and
+	// sigScript length followed by OP_DATA_73 <sig> OP_DATA_33 <pubkey>.
+	p2pkhInputSize = 32 + 4 + 4 + 1 + 1 + 73 + 1 + 33
+
+	// p2shInputSize is the largest number of bytes an input spending a
+	// p2sh output of the memWallet adds to a transaction: the same as a
+	// p2pkh input followed by OP_DATA_25 <p2pkh redeem script>.
+	p2shInputSize = p2pkhInputSize + 1 + 25
+
+	// p2wpkhInputSize is the largest number of bytes an input spending a
+	// p2wpkh output adds to a transaction: the outpoint, sequence and
+	// empty sigScript followed by the witness item count and
+	// OP_DATA_73 <sig> OP_DATA_33 <pubkey>.  The witness is counted at
+	// its full size since fees are calculated from the serialized size.
+	p2wpkhInputSize = 32 + 4 + 4 + 1 + 1 + 1 + 73 + 1 + 33
+
+	// p2pkhOutputSize is the number of bytes a p2pkh output adds to a
+	// transaction: the value, the pkScript length and the pkScript.
+	p2pkhOutputSize = 8 + 1 + 25
+)
+
+// selectableUtxo pairs a utxo with its outpoint so it can be passed to the
+// coin selection algorithms of the coinselect package.
+type selectableUtxo struct {
+	outPoint wire.OutPoint
+	*utxo
+}
+
+// Value returns the value of the utxo.
+//
+// This is part of the coinselect.Utxo interface.
+func (u *selectableUtxo) Value() ulordutil.Amount {
+	return u.value
+}
+
+// SpendSize returns the number of bytes spending the utxo adds to a
+// transaction.
+//
+// This is part of the coinselect.Utxo interface.
+func (u *selectableUtxo) SpendSize() int {
+	switch u.addrType {
+	case AddrP2SH:
+		return p2shInputSize
+	case AddrP2WPKH:
+		return p2wpkhInputSize
+	default:
+		return p2pkhInputSize
+	}
+}
+
 // chainUpdate encapsulates an update to the current main chain. This struct is
 // used to sync up the memWallet each time a new block is connected to the main
 // chain.
@@ -88,6 +179,14 @@ type memWallet struct {
 	// are indexed by their keypath from the hdRoot.
 	addrs map[uint32]ulordutil.Address
 
+	// addrTypes are the types of the addresses generated by newAddress in
+	// turn, and nextAddrType is the index of the type of the next one.
+	addrTypes    []AddressType
+	nextAddrType int
+
+	// harnessID is the ID of the harness the wallet belongs to.
+	harnessID uint32
+
 	// utxos is the set of utxos spendable by the wallet.
 	utxos map[wire.OutPoint]*utxo
 
@@ -101,6 +200,8 @@ type memWallet struct {
 	chainUpdateSignal chan struct{}
 	chainMtx          sync.Mutex
 
+	quit chan struct{}
+
 	net *chaincfg.Params
 
 	rpc *rpcclient.Client
@@ -133,7 +234,7 @@ func newMemWallet(net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
 	if err != nil {
 		return nil, err
 	}
-	coinbaseAddr, err := keyToAddr(coinbaseKey, net)
+	coinbaseAddr, err := keyToAddr(coinbaseKey, AddrP2PKH, net)
 	if err != nil {
 		return nil, err
 	}
@@ -150,8 +251,11 @@ func newMemWallet(net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
 		hdIndex:           1,
 		hdRoot:            hdRoot,
 		addrs:             addrs,
+		addrTypes:         []AddressType{AddrP2PKH},
+		harnessID:         harnessID,
 		utxos:             make(map[wire.OutPoint]*utxo),
 		chainUpdateSignal: make(chan struct{}),
+		quit:              make(chan struct{}),
 		reorgJournal:      make(map[int32]*undoEntry),
 	}, nil
 }
@@ -161,6 +265,12 @@ func (m *memWallet) Start() {
 	go m.chainSyncer()
 }
 
+// Stop stops all goroutines of the wallet.  Chain updates queued afterwards are
+// no longer processed.
+func (m *memWallet) Stop() {
+	close(m.quit)
+}
+
 // SyncedHeight returns the height the wallet is known to be synced to.
 //
 // This function is safe for concurrent access.
@@ -191,7 +301,10 @@ func (m *memWallet) IngestBlock(height int32, header *wire.BlockHeader, filtered
 	// available. We do this in a new goroutine in order to avoid blocking
 	// the main loop of the rpc client.
 	go func() {
-		m.chainUpdateSignal <- struct{}{}
+		select {
+		case m.chainUpdateSignal <- struct{}{}:
+		case <-m.quit:
+		}
 	}()
 }
 
@@ -226,7 +339,13 @@ func (m *memWallet) ingestBlock(update *chainUpdate) {
 func (m *memWallet) chainSyncer() {
 	var update *chainUpdate
 
-	for range m.chainUpdateSignal {
+	for {
+		select {
+		case <-m.chainUpdateSignal:
+		case <-m.quit:
+			return
+		}
+
 		// A new update is available, so pop the new chain update from
 		// the front of the update queue.
 		m.chainMtx.Lock()
@@ -273,6 +392,7 @@ func (m *memWallet) evalOutputs(outputs []*wire.TxOut, txHash *chainhash.Hash,
 			m.utxos[op] = &utxo{
 				value:          ulordutil.Amount(output.Value),
 				keyIndex:       keyIndex,
+				addrType:       addressTypeOf(addr),
 				maturityHeight: maturityHeight,
 				pkScript:       pkScript,
 			}
@@ -311,7 +431,10 @@ func (m *memWallet) UnwindBlock(height int32, header *wire.BlockHeader) {
 	// available. We do this in a new goroutine in order to avoid blocking
 	// the main loop of the rpc client.
 	go func() {
-		m.chainUpdateSignal <- struct{}{}
+		select {
+		case m.chainUpdateSignal <- struct{}{}:
+		case <-m.quit:
+		}
 	}()
 }
 
@@ -331,11 +454,37 @@ func (m *memWallet) unwindBlock(update *chainUpdate) {
 	delete(m.reorgJournal, update.blockHeight)
 }
 
+// SetAddressTypes sets the types of the addresses generated by the wallet.
+// When perAddress is true, the addresses generated by NewAddress and the change
+// addresses of created transactions cycle through the passed types.
+// Otherwise, all addresses are of a single type picked from the passed types
+// by the ID of the harness, so a suite creating a harness per test run
+// exercises each of them in turn.  Addresses of type AddrP2PKH are generated
+// when no types are passed.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) SetAddressTypes(types []AddressType, perAddress bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	switch {
+	case len(types) == 0:
+		types = []AddressType{AddrP2PKH}
+	case !perAddress:
+		types = []AddressType{types[m.harnessID%uint32(len(types))]}
+	}
+	m.addrTypes = append([]AddressType(nil), types...)
+	m.nextAddrType = 0
+}
+
 // newAddress returns a new address from the wallet's hd key chain.  It also
 // loads the address into the RPC client's transaction filter to ensure any
 // transactions that involve it are delivered via the notifications.
+//
+// NOTE: The memWallet's mutex must be held when this function is called.
 func (m *memWallet) newAddress() (ulordutil.Address, error) {
 	index := m.hdIndex
+	addrType := m.addrTypes[m.nextAddrType]
 
 	childKey, err := m.hdRoot.Child(index)
 	if err != nil {
@@ -346,7 +495,7 @@ func (m *memWallet) newAddress() (ulordutil.Address, error) {
 		return nil, err
 	}
 
-	addr, err := keyToAddr(privKey, m.net)
+	addr, err := keyToAddr(privKey, addrType, m.net)
 	if err != nil {
 		return nil, err
 	}
@@ -359,6 +508,7 @@ func (m *memWallet) newAddress() (ulordutil.Address, error) {
 	m.addrs[index] = addr
 
 	m.hdIndex++
+	m.nextAddrType = (m.nextAddrType + 1) % len(m.addrTypes)
 
 	return addr, nil
 }
@@ -383,67 +533,73 @@ func (m *memWallet) NewAddress() (ulordutil.Address, error) {
 func (m *memWallet) fundTx(tx *wire.MsgTx, amt ulordutil.Amount,
 	feeRate ulordutil.Amount, change bool) error {
 
-	const (
-		// spendSize is the largest number of bytes of a sigScript
-		// which spends a p2pkh output: OP_DATA_73 <sig> OP_DATA_33 <pubkey>
-		spendSize = 1 + 73 + 1 + 33
-	)
-
-	var (
-		amtSelected ulordutil.Amount
-		txSize      int
-	)
-
+	// Gather the outputs which may currently be spent, skipping any which
+	// are still immature or locked.
+	var utxos []coinselect.Utxo
 	for outPoint, utxo := range m.utxos {
-		// Skip any outputs that are still currently immature or are
-		// currently locked.
 		if !utxo.isMature(m.currentHeight) || utxo.isLocked {
 			continue
 		}
+		utxos = append(utxos, &selectableUtxo{outPoint, utxo})
+	}
 
-		amtSelected += utxo.value
+	// Change below the dust threshold would make the transaction
+	// non-standard, so it is added to the fee instead.  The threshold of a
+	// pay-to-pubkey-hash script is used since it is the largest of the
+	// supported address types.
+	dustAddr, err := ulordutil.NewAddressPubKeyHash(make([]byte, 20), m.net)
+	if err != nil {
+		return err
+	}
+	dustScript, err := txscript.PayToAddrScript(dustAddr)
+	if err != nil {
+		return err
+	}
+	minChange := ulordutil.DustThreshold(dustScript,
+		mempool.DefaultMinRelayTxFee)
+
+	// Select the coins to spend, accounting for the size of the inputs,
+	// the outputs already on the transaction and a change output.  The
+	// change is assumed to pay to the largest output script of the
+	// supported address types.
+	selection, err := coinselect.LargestFirst(utxos, &coinselect.Params{
+		TargetValue:     amt,
+		FeeRate:         feeRate,
+		BaseSize:        tx.SerializeSize(),
+		ChangeSize:      p2pkhOutputSize,
+		ChangeSpendSize: p2shInputSize,
+		MinChange:       minChange,
+		NoChange:        !change,
+	})
+	if err != nil {
+		return fmt.Errorf("not enough funds for coin selection")
+	}
 
-		// Add the selected output to the transaction, updating the
-		// current tx size while accounting for the size of the future
-		// sigScript.
+	for _, u := range selection.Inputs {
+		outPoint := u.(*selectableUtxo).outPoint
 		tx.AddTxIn(wire.NewTxIn(&outPoint, nil, nil))
-		txSize = tx.SerializeSize() + spendSize*len(tx.TxIn)
-
-		// Calculate the fee required for the txn at this point
-		// observing the specified fee rate. If we don't have enough
-		// coins from he current amount selected to pay the fee, then
-		// continue to grab more coins.
-		reqFee := ulordutil.Amount(txSize * int(feeRate))
-		if amtSelected-reqFee < amt {
-			continue
-		}
+	}
 
-		// If we have any change left over and we should create a change
-		// output, then add an additional output to the transaction
-		// reserved for it.
-		changeVal := amtSelected - amt - reqFee
-		if changeVal > 0 && change {
-			addr, err := m.newAddress()
-			if err != nil {
-				return err
-			}
-			pkScript, err := txscript.PayToAddrScript(addr)
-			if err != nil {
-				return err
-			}
-			changeOutput := &wire.TxOut{
-				Value:    int64(changeVal),
-				PkScript: pkScript,
-			}
-			tx.AddTxOut(changeOutput)
+	// If we have any change left over and we should create a change
+	// output, then add an additional output to the transaction reserved
+	// for it.
+	if selection.Change > 0 {
+		addr, err := m.newAddress()
+		if err != nil {
+			return err
 		}
-
-		return nil
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return err
+		}
+		changeOutput := &wire.TxOut{
+			Value:    int64(selection.Change),
+			PkScript: pkScript,
+		}
+		tx.AddTxOut(changeOutput)
 	}
 
-	// If we've reached this point, then coin selection failed due to an
-	// insufficient amount of coins.
-	return fmt.Errorf("not enough funds for coin selection")
+	return nil
 }
 
 // SendOutputs creates, then sends a transaction paying to the specified output
@@ -505,6 +661,7 @@ func (m *memWallet) CreateTransaction(outputs []*wire.TxOut,
 	// Along the way record all outputs being spent in order to avoid a
 	// potential double spend.
 	spentOutputs := make([]*utxo, 0, len(tx.TxIn))
+	var sigHashes *txscript.TxSigHashes
 	for i, txIn := range tx.TxIn {
 		outPoint := txIn.PreviousOutPoint
 		utxo := m.utxos[outPoint]
@@ -519,13 +676,44 @@ func (m *memWallet) CreateTransaction(outputs []*wire.TxOut,
 			return nil, err
 		}
 
-		sigScript, err := txscript.SignatureScript(tx, i, utxo.pkScript,
-			txscript.SigHashAll, privKey, true)
-		if err != nil {
-			return nil, err
-		}
+		switch utxo.addrType {
+		case AddrP2SH:
+			redeemScript, err := p2pkhScript(privKey, m.net)
+			if err != nil {
+				return nil, err
+			}
+			sigScript, err := txscript.SignatureScript(tx, i,
+				redeemScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, err
+			}
+			sigScript, err = txscript.NewScriptBuilder().
+				AddOps(sigScript).AddData(redeemScript).Script()
+			if err != nil {
+				return nil, err
+			}
+			txIn.SignatureScript = sigScript
+
+		case AddrP2WPKH:
+			if sigHashes == nil {
+				sigHashes = txscript.NewTxSigHashes(tx)
+			}
+			witness, err := txscript.WitnessSignature(tx, sigHashes, i,
+				int64(utxo.value), utxo.pkScript, txscript.SigHashAll,
+				privKey, true)
+			if err != nil {
+				return nil, err
+			}
+			txIn.Witness = witness
 
-		txIn.SignatureScript = sigScript
+		default:
+			sigScript, err := txscript.SignatureScript(tx, i,
+				utxo.pkScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, err
+			}
+			txIn.SignatureScript = sigScript
+		}
 
 		spentOutputs = append(spentOutputs, utxo)
 	}
@@ -580,12 +768,51 @@ func (m *memWallet) ConfirmedBalance() ulordutil.Amount {
 	return balance
 }
 
-// keyToAddr maps the passed private to corresponding p2pkh address.
-func keyToAddr(key *ulordec.PrivateKey, net *chaincfg.Params) (ulordutil.Address, error) {
-	serializedKey := key.PubKey().SerializeCompressed()
-	pubKeyAddr, err := ulordutil.NewAddressPubKey(serializedKey, net)
+// keyToAddr maps the passed private key to the corresponding address of the
+// passed type.
+func keyToAddr(key *ulordec.PrivateKey, addrType AddressType,
+	net *chaincfg.Params) (ulordutil.Address, error) {
+
+	switch addrType {
+	case AddrP2SH:
+		redeemScript, err := p2pkhScript(key, net)
+		if err != nil {
+			return nil, err
+		}
+		return ulordutil.NewAddressScriptHash(redeemScript, net)
+
+	case AddrP2WPKH:
+		pubKeyHash := ulordutil.Hash160(key.PubKey().SerializeCompressed())
+		return ulordutil.NewAddressWitnessPubKeyHash(pubKeyHash, net)
+
+	default:
+		serializedKey := key.PubKey().SerializeCompressed()
+		pubKeyAddr, err := ulordutil.NewAddressPubKey(serializedKey, net)
+		if err != nil {
+			return nil, err
+		}
+		return pubKeyAddr.AddressPubKeyHash(), nil
+	}
+}
+
+// p2pkhScript returns the p2pkh script paying to the passed private key, which
+// is the redeem script of its p2sh address.
+func p2pkhScript(key *ulordec.PrivateKey, net *chaincfg.Params) ([]byte, error) {
+	addr, err := keyToAddr(key, AddrP2PKH, net)
 	if err != nil {
 		return nil, err
 	}
-	return pubKeyAddr.AddressPubKeyHash(), nil
+	return txscript.PayToAddrScript(addr)
+}
+
+// addressTypeOf returns the type of the passed address of the wallet.
+func addressTypeOf(addr ulordutil.Address) AddressType {
+	switch addr.(type) {
+	case *ulordutil.AddressScriptHash:
+		return AddrP2SH
+	case *ulordutil.AddressWitnessPubKeyHash:
+		return AddrP2WPKH
+	default:
+		return AddrP2PKH
+	}
 }
@@ -18,6 +18,10 @@ import (
 	"github.com/ulordsuite/ulordutil"
 )
 
+// processExitTimeout is the time a ulord process is given to exit after being
+// interrupted before it is killed.
+const processExitTimeout = 30 * time.Second
+
 // nodeConfig contains all the args, and data required to launch a ulord process
 // and connect the rpc client to it.
 type nodeConfig struct {
@@ -225,18 +229,36 @@ func (n *node) start() error {
 
 // stop interrupts the running ulord process process, and waits until it exits
 // properly. On windows, interrupt is not supported, so a kill signal is used
-// instead
+// instead.  The process is killed when it does not exit within
+// processExitTimeout of being interrupted.
 func (n *node) stop() error {
 	if n.cmd == nil || n.cmd.Process == nil {
 		// return if not properly initialized
 		// or error starting the process
 		return nil
 	}
-	defer n.cmd.Wait()
+
+	var err error
 	if runtime.GOOS == "windows" {
-		return n.cmd.Process.Signal(os.Kill)
+		err = n.cmd.Process.Signal(os.Kill)
+	} else {
+		err = n.cmd.Process.Signal(os.Interrupt)
 	}
-	return n.cmd.Process.Signal(os.Interrupt)
+
+	exited := make(chan struct{})
+	go func() {
+		n.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(processExitTimeout):
+		log.Printf("ulord process %d did not exit after %v, killing it",
+			n.cmd.Process.Pid, processExitTimeout)
+		n.cmd.Process.Kill()
+		<-exited
+	}
+	return err
 }
 
 // cleanup cleanups process and args files. The file housing the pid of the
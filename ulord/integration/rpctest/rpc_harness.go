@@ -91,10 +91,26 @@ type Harness struct {
 	sync.Mutex
 }
 
+// writeNetParams writes the passed parameters of a custom network to a file in
+// the passed directory and returns its path.
+func writeNetParams(dir string, activeNet *chaincfg.Params) (string, error) {
+	path := filepath.Join(dir, "netparams.json")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := chaincfg.EncodeParams(f, activeNet); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
 // New creates and initializes new instance of the rpc test harness.
 // Optionally, websocket handlers and a specified configuration may be passed.
 // In the case that a nil config is passed, a default configuration will be
-// used.
+// used.  The parameters of networks other than the default ones are passed to
+// the node with --netparams.
 //
 // NOTE: This function is safe for concurrent access.
 func New(activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers,
@@ -103,6 +119,17 @@ func New(activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers,
 	harnessStateMtx.Lock()
 	defer harnessStateMtx.Unlock()
 
+	testDir, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	harnessID := strconv.Itoa(numTestInstances)
+	nodeTestData, err := ioutil.TempDir(testDir, "harness-"+harnessID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add a flag for the appropriate network type based on the provided
 	// chain params.
 	switch activeNet.Net {
@@ -115,19 +142,18 @@ func New(activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers,
 	case wire.SimNet:
 		extraArgs = append(extraArgs, "--simnet")
 	default:
-		return nil, fmt.Errorf("rpctest.New must be called with one " +
-			"of the supported chain networks")
-	}
-
-	testDir, err := baseDir()
-	if err != nil {
-		return nil, err
-	}
-
-	harnessID := strconv.Itoa(numTestInstances)
-	nodeTestData, err := ioutil.TempDir(testDir, "harness-"+harnessID)
-	if err != nil {
-		return nil, err
+		// Pass the parameters of any other network to the node in a
+		// file and register them so the addresses of the network can
+		// also be decoded by the harness.
+		netParamsFile, err := writeNetParams(nodeTestData, activeNet)
+		if err != nil {
+			return nil, err
+		}
+		extraArgs = append(extraArgs, "--netparams="+netParamsFile)
+		err = chaincfg.RegisterParams(activeNet)
+		if err != nil && err != chaincfg.ErrDuplicateNet {
+			return nil, err
+		}
 	}
 
 	certFile := filepath.Join(nodeTestData, "rpc.cert")
@@ -274,7 +300,9 @@ func (h *Harness) SetUp(createTestChain bool, numMatureOutputs uint32) error {
 func (h *Harness) tearDown() error {
 	if h.Node != nil {
 		h.Node.Shutdown()
+		h.Node.WaitForShutdown()
 	}
+	h.wallet.Stop()
 
 	if err := h.node.shutdown(); err != nil {
 		return err
@@ -337,6 +365,19 @@ func (h *Harness) NewAddress() (ulordutil.Address, error) {
 	return h.wallet.NewAddress()
 }
 
+// SetAddressTypes sets the types of the addresses generated by the Harness'
+// internal wallet, including the change addresses of the transactions it
+// creates.  When perAddress is true, the generated addresses cycle through the
+// passed types.  Otherwise, all addresses are of a single type picked from the
+// passed types by the number of the harness, so a suite creating a harness per
+// test run exercises each of them in turn.  Passing no types restores the
+// default of generating p2pkh addresses only.
+//
+// This function is safe for concurrent access.
+func (h *Harness) SetAddressTypes(types []AddressType, perAddress bool) {
+	h.wallet.SetAddressTypes(types, perAddress)
+}
+
 // ConfirmedBalance returns the confirmed balance of the Harness' internal
 // wallet.
 //
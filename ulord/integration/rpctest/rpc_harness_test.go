@@ -546,6 +546,64 @@ func testMemWalletLockedOutputs(r *Harness, t *testing.T) {
 	}
 }
 
+func testMemWalletAddressTypes(r *Harness, t *testing.T) {
+	// Restore the default address type for the remaining tests.
+	defer r.SetAddressTypes(nil, true)
+
+	newAddressType := func() AddressType {
+		addr, err := r.NewAddress()
+		if err != nil {
+			t.Fatalf("unable to generate new address: %v", err)
+		}
+		return addressTypeOf(addr)
+	}
+
+	// Addresses generated per address should cycle through all the types.
+	r.SetAddressTypes(AllAddressTypes, true)
+	for i := 0; i < 2*len(AllAddressTypes); i++ {
+		want := AllAddressTypes[i%len(AllAddressTypes)]
+		if got := newAddressType(); got != want {
+			t.Fatalf("address #%d has wrong type - got %v, want %v",
+				i, got, want)
+		}
+	}
+
+	// Addresses generated per run should all be of the type picked by the
+	// number of the harness.
+	r.SetAddressTypes(AllAddressTypes, false)
+	want := AllAddressTypes[r.nodeNum%len(AllAddressTypes)]
+	for i := 0; i < 2; i++ {
+		if got := newAddressType(); got != want {
+			t.Fatalf("address #%d has wrong type - got %v, want %v",
+				i, got, want)
+		}
+	}
+
+	// Outputs paying to a p2sh address should be spendable by the wallet,
+	// including the ones of the change of the spending transaction.
+	r.SetAddressTypes([]AddressType{AddrP2SH}, true)
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	output := wire.NewTxOut(5*ulordutil.SatoshiPerBitcoin, pkScript)
+	if _, err := r.SendOutputs([]*wire.TxOut{output}, 10); err != nil {
+		t.Fatalf("unable to send to p2sh address: %v", err)
+	}
+	if _, err := r.Node.Generate(1); err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+	output = wire.NewTxOut(int64(r.ConfirmedBalance())-
+		ulordutil.SatoshiPerBitcoin, pkScript)
+	if _, err := r.SendOutputs([]*wire.TxOut{output}, 10); err != nil {
+		t.Fatalf("unable to spend p2sh outputs: %v", err)
+	}
+}
+
 var harnessTestCases = []HarnessTestCase{
 	testSendOutputs,
 	testConnectNode,
@@ -556,6 +614,7 @@ var harnessTestCases = []HarnessTestCase{
 	testGenerateAndSubmitBlockWithCustomCoinbaseOutputs,
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testMemWalletAddressTypes,
 }
 
 var mainHarness *Harness
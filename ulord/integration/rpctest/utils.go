@@ -134,18 +134,22 @@ func ConnectNode(from *Harness, to *Harness) error {
 	return nil
 }
 
-// TearDownAll tears down all active test harnesses.
+// TearDownAll tears down all active test harnesses.  It then verifies that
+// the harnesses did not leak any resources and returns a *LeakError describing
+// the offenders when they did.
 func TearDownAll() error {
 	harnessStateMtx.Lock()
 	defer harnessStateMtx.Unlock()
 
+	harnesses := make([]*Harness, 0, len(testInstances))
 	for _, harness := range testInstances {
 		if err := harness.tearDown(); err != nil {
 			return err
 		}
+		harnesses = append(harnesses, harness)
 	}
 
-	return nil
+	return checkLeaks(harnesses)
 }
 
 // ActiveHarnesses returns a slice of all currently active test harnesses. A
@@ -18,9 +18,11 @@ import (
 	"github.com/ulordsuite/ulord/mempool"
 	"github.com/ulordsuite/ulord/mining"
 	"github.com/ulordsuite/ulord/mining/cpuminer"
+	"github.com/ulordsuite/ulord/mining/stratum"
 	"github.com/ulordsuite/ulord/netsync"
 	"github.com/ulordsuite/ulord/peer"
 	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/zmqpub"
 
 	"github.com/ulordsuite/btclog"
 	"github.com/jrick/logrotate/rotator"
@@ -80,10 +82,12 @@ func init() {
 	indexers.UseLogger(indxLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
+	stratum.UseLogger(minrLog)
 	peer.UseLogger(peerLog)
 	txscript.UseLogger(scrpLog)
 	netsync.UseLogger(syncLog)
 	mempool.UseLogger(txmpLog)
+	zmqpub.UseLogger(rpcsLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -0,0 +1,212 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// AcceptanceCheck identifies a check transactions go through before they are
+// accepted into the pool.
+type AcceptanceCheck int
+
+// These constants define the checks in the order they are performed.  Checks
+// which do not apply to a transaction, such as the standardness checks when
+// non-standard transactions are accepted, are not performed.
+const (
+	// CheckWitness ensures transactions with witness data are only
+	// accepted once segwit is active.
+	CheckWitness AcceptanceCheck = iota
+
+	// CheckDuplicate rejects transactions already in the pool.
+	CheckDuplicate
+
+	// CheckSanity performs the context free consensus checks.
+	CheckSanity
+
+	// CheckCoinbase rejects standalone coinbase transactions.
+	CheckCoinbase
+
+	// CheckStandard ensures the transaction is standard.
+	CheckStandard
+
+	// CheckPoolDoubleSpend detects double spends of outputs already spent
+	// by transactions in the pool.
+	CheckPoolDoubleSpend
+
+	// CheckTxLocks rejects spends of outputs locked to another transaction
+	// by a masternode quorum for InstantSend.
+	CheckTxLocks
+
+	// CheckInputsAvailable fetches the outputs spent by the transaction
+	// and determines whether it is an orphan.
+	CheckInputsAvailable
+
+	// CheckSequenceLocks ensures the relative lock times of the inputs
+	// are met by the next block.
+	CheckSequenceLocks
+
+	// CheckInputs performs the consensus checks of the inputs and
+	// calculates the fee.
+	CheckInputs
+
+	// CheckInputsStandard ensures the spent outputs are standard.
+	CheckInputsStandard
+
+	// CheckSigOpCost limits the signature operation cost.
+	CheckSigOpCost
+
+	// CheckFees ensures the transaction pays the minimum fees or has
+	// enough priority and applies the free transaction rate limiter.
+	CheckFees
+
+	// CheckPackageLimits limits the chains of unconfirmed transactions.
+	CheckPackageLimits
+
+	// CheckReplacement validates replacements of transactions in the pool.
+	CheckReplacement
+
+	// CheckScripts verifies the signatures of the inputs.  It is performed
+	// without holding the mempool lock.
+	CheckScripts
+
+	// CheckRevalidation repeats the checks other than CheckScripts when
+	// the pool or the chain changed while the scripts were verified.
+	CheckRevalidation
+
+	// CheckPoolSize adds the transaction to the pool and ensures it is not
+	// evicted to keep the pool within its maximum size.
+	CheckPoolSize
+
+	// CheckOrphan adds an orphan transaction to the orphan pool.
+	CheckOrphan
+)
+
+// Map of AcceptanceCheck values back to their constant names for pretty
+// printing.
+var acceptanceCheckStrings = map[AcceptanceCheck]string{
+	CheckWitness:         "CheckWitness",
+	CheckDuplicate:       "CheckDuplicate",
+	CheckSanity:          "CheckSanity",
+	CheckCoinbase:        "CheckCoinbase",
+	CheckStandard:        "CheckStandard",
+	CheckPoolDoubleSpend: "CheckPoolDoubleSpend",
+	CheckTxLocks:         "CheckTxLocks",
+	CheckInputsAvailable: "CheckInputsAvailable",
+	CheckSequenceLocks:   "CheckSequenceLocks",
+	CheckInputs:          "CheckInputs",
+	CheckInputsStandard:  "CheckInputsStandard",
+	CheckSigOpCost:       "CheckSigOpCost",
+	CheckFees:            "CheckFees",
+	CheckPackageLimits:   "CheckPackageLimits",
+	CheckReplacement:     "CheckReplacement",
+	CheckScripts:         "CheckScripts",
+	CheckRevalidation:    "CheckRevalidation",
+	CheckPoolSize:        "CheckPoolSize",
+	CheckOrphan:          "CheckOrphan",
+}
+
+// String returns the AcceptanceCheck as a human-readable name.
+func (c AcceptanceCheck) String() string {
+	if s := acceptanceCheckStrings[c]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown AcceptanceCheck (%d)", int(c))
+}
+
+// CheckResult describes the outcome of a single acceptance check.
+type CheckResult struct {
+	// Check is the check performed.
+	Check AcceptanceCheck
+
+	// Duration is how long the check took.
+	Duration time.Duration
+
+	// Err is the reason the transaction failed the check or nil when it
+	// passed.
+	Err error
+}
+
+// AcceptanceResult describes the outcome of processing a transaction with
+// ProcessTransactionResult.
+type AcceptanceResult struct {
+	// Tx is the processed transaction.
+	Tx *ulordutil.Tx
+
+	// Checks holds the checks performed in order.  Checking stops at the
+	// first failed check, so only the last check can have failed.
+	Checks []CheckResult
+
+	// MissingParents holds the parents of the transaction which are
+	// unknown when it is an orphan.
+	MissingParents []*chainhash.Hash
+
+	// Orphan is whether the transaction was added to the orphan pool.
+	Orphan bool
+
+	// Accepted holds the transactions added to the pool, which are the
+	// processed transaction followed by the orphans accepted because of
+	// it.
+	Accepted []*TxDesc
+
+	// Err is the reason the transaction was rejected or nil.
+	Err error
+
+	// Duration is how long processing the transaction took, including the
+	// time spent waiting for the mempool lock and processing orphans.
+	Duration time.Duration
+}
+
+// AcceptanceHook is implemented by types which want to observe the outcome of
+// every transaction processed by the pool, such as to log or export acceptance
+// metrics.
+type AcceptanceHook interface {
+	// TransactionProcessed is called with the result of processing a
+	// transaction with ProcessTransaction or ProcessTransactionResult.  It
+	// is called without the mempool lock held and the result must be
+	// treated as read only.
+	TransactionProcessed(result *AcceptanceResult)
+}
+
+// checkRecorder records the outcome and timing of the acceptance checks of a
+// transaction.  A nil recorder records nothing so the checks of transactions
+// which aren't instrumented, such as orphans and transactions loaded from
+// disk, cost nothing.
+type checkRecorder struct {
+	checks  []CheckResult
+	current AcceptanceCheck
+	started time.Time
+	active  bool
+}
+
+// begin ends the current check as passed and starts timing the passed check.
+func (r *checkRecorder) begin(check AcceptanceCheck) {
+	if r == nil {
+		return
+	}
+
+	r.end(nil)
+	r.current = check
+	r.started = time.Now()
+	r.active = true
+}
+
+// end ends the current check, if any, with the passed error.
+func (r *checkRecorder) end(err error) {
+	if r == nil || !r.active {
+		return
+	}
+
+	r.checks = append(r.checks, CheckResult{
+		Check:    r.current,
+		Duration: time.Since(r.started),
+		Err:      err,
+	})
+	r.active = false
+}
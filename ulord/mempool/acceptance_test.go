@@ -0,0 +1,197 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// recordingHook is an AcceptanceHook which keeps all results it is passed.
+type recordingHook struct {
+	results []*AcceptanceResult
+}
+
+// TransactionProcessed keeps the passed result.
+//
+// This is part of the AcceptanceHook interface.
+func (h *recordingHook) TransactionProcessed(result *AcceptanceResult) {
+	h.results = append(h.results, result)
+}
+
+// TestProcessTransactionResult ensures the acceptance result reports the
+// checks performed on transactions and is passed to the acceptance hook.
+func TestProcessTransactionResult(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	hook := &recordingHook{}
+	harness.txPool.cfg.AcceptanceHook = hook
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+
+	// checkResult ensures the passed result ended with the passed check
+	// and that only that check failed when an error is expected.
+	checkResult := func(result *AcceptanceResult, lastCheck AcceptanceCheck,
+		wantErr bool) {
+
+		t.Helper()
+		if (result.Err != nil) != wantErr {
+			t.Fatalf("unexpected error: %v", result.Err)
+		}
+		if len(result.Checks) == 0 {
+			t.Fatal("no checks were reported")
+		}
+		last := result.Checks[len(result.Checks)-1]
+		if last.Check != lastCheck {
+			t.Fatalf("last check is %v, want %v", last.Check,
+				lastCheck)
+		}
+		if last.Err != result.Err {
+			t.Fatalf("last check error is %v, want %v", last.Err,
+				result.Err)
+		}
+		for _, check := range result.Checks[:len(result.Checks)-1] {
+			if check.Err != nil {
+				t.Fatalf("check %v failed: %v", check.Check,
+					check.Err)
+			}
+		}
+	}
+
+	// The second transaction of the chain is an orphan.
+	result := harness.txPool.ProcessTransactionResult(chainedTxns[1], true,
+		false, 0)
+	checkResult(result, CheckOrphan, false)
+	if !result.Orphan || len(result.MissingParents) != 1 ||
+		len(result.Accepted) != 0 {
+
+		t.Fatalf("orphan was not reported as such: %+v", result)
+	}
+
+	// Accepting its parent accepts both transactions after running every
+	// check that applies to them.
+	result = harness.txPool.ProcessTransactionResult(chainedTxns[0], false,
+		false, 0)
+	checkResult(result, CheckPoolSize, false)
+	if result.Orphan || len(result.Accepted) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	wantChecks := []AcceptanceCheck{CheckDuplicate, CheckSanity,
+		CheckCoinbase, CheckStandard, CheckPoolDoubleSpend,
+		CheckInputsAvailable, CheckSequenceLocks, CheckInputs,
+		CheckInputsStandard, CheckSigOpCost, CheckFees,
+		CheckPackageLimits, CheckScripts, CheckPoolSize}
+	if len(result.Checks) != len(wantChecks) {
+		t.Fatalf("got %d checks, want %d", len(result.Checks),
+			len(wantChecks))
+	}
+	for i, check := range result.Checks {
+		if check.Check != wantChecks[i] {
+			t.Fatalf("check %d is %v, want %v", i, check.Check,
+				wantChecks[i])
+		}
+	}
+
+	// Duplicates fail the duplicate check, and ProcessTransaction also
+	// passes its result to the hook.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[0], false,
+		false, 0)
+	if err == nil {
+		t.Fatal("ProcessTransaction: accepted duplicate transaction")
+	}
+	if len(hook.results) != 3 {
+		t.Fatalf("hook was called %d times, want 3", len(hook.results))
+	}
+	checkResult(hook.results[2], CheckDuplicate, true)
+	if hook.results[2].Err != err {
+		t.Fatalf("hook was passed error %v, want %v",
+			hook.results[2].Err, err)
+	}
+}
+
+// TestCheckAcceptance ensures CheckAcceptance reports whether transactions
+// would be accepted without modifying the pool.
+func TestCheckAcceptance(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Create a replaceable transaction and a child spending it.
+	const fee = ulordutil.Amount(1000)
+	tx, err := harness.CreateSignedTxWithFee(outputs[:1], 1, fee,
+		MaxRBFSequence)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(tx, 0),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// An acceptable transaction is reported with its fee and size but is
+	// not added to the pool.
+	info, err := harness.txPool.CheckAcceptance(tx)
+	if err != nil {
+		t.Fatalf("CheckAcceptance: unexpected error: %v", err)
+	}
+	if info.Fee != int64(fee) ||
+		info.VirtualSize != GetTxVirtualSize(tx) ||
+		len(info.Replaces) != 0 {
+
+		t.Fatalf("CheckAcceptance: unexpected info %+v", info)
+	}
+	testPoolMembership(tc, tx, false, false)
+
+	// Orphans are rejected and not added to the orphan pool.
+	if _, err := harness.txPool.CheckAcceptance(child); err == nil {
+		t.Fatal("CheckAcceptance: accepted orphan")
+	}
+	testPoolMembership(tc, child, false, false)
+
+	// Transactions already in the pool are rejected.
+	_, err = harness.txPool.ProcessTransaction(tx, false,
+		false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	if _, err := harness.txPool.CheckAcceptance(tx); err == nil {
+		t.Fatal("CheckAcceptance: accepted duplicate transaction")
+	}
+
+	// Replacements report the transactions they would replace, which
+	// remain in the pool.
+	replacement, err := harness.CreateSignedTxWithFee(outputs[:1], 1,
+		fee*10, MaxRBFSequence)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	info, err = harness.txPool.CheckAcceptance(replacement)
+	if err != nil {
+		t.Fatalf("CheckAcceptance: unexpected error: %v", err)
+	}
+	if len(info.Replaces) != 1 ||
+		!info.Replaces[0].IsEqual(tx.Hash()) {
+
+		t.Fatalf("CheckAcceptance: unexpected replaced transactions %v",
+			info.Replaces)
+	}
+	testPoolMembership(tc, tx, false, true)
+	testPoolMembership(tc, replacement, false, false)
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// poolAddrIndex indexes the transactions in the pool by the addresses of the
+// outputs they create and of the outputs they spend, along with the outpoints
+// of the outputs paying to each address.  It allows queries for the pending
+// transactions of an address without scanning the entire pool.
+//
+// Like the unconfirmed part of the address index of the indexers package, pay
+// to pubkey outputs are indexed under their pay to pubkey hash address so both
+// kinds of payments to a key are found by its address.
+type poolAddrIndex struct {
+	params *chaincfg.Params
+
+	// txns maps address keys to the transactions of the pool paying to or
+	// spending from the address.
+	txns map[string]map[chainhash.Hash]struct{}
+
+	// outputs maps address keys to the outputs of transactions of the pool
+	// paying to the address.
+	outputs map[string]map[wire.OutPoint]struct{}
+
+	// txAddrs maps the transactions of the pool to the address keys they
+	// are indexed under so they are removed without looking up the outputs
+	// they spend again.
+	txAddrs map[chainhash.Hash]map[string]struct{}
+}
+
+// newPoolAddrIndex returns an empty pool address index for addresses of the
+// passed network.
+func newPoolAddrIndex(params *chaincfg.Params) *poolAddrIndex {
+	return &poolAddrIndex{
+		params:  params,
+		txns:    make(map[string]map[chainhash.Hash]struct{}),
+		outputs: make(map[string]map[wire.OutPoint]struct{}),
+		txAddrs: make(map[chainhash.Hash]map[string]struct{}),
+	}
+}
+
+// poolAddrKey returns the key the passed address is indexed under.
+func poolAddrKey(addr ulordutil.Address) string {
+	if pkAddr, ok := addr.(*ulordutil.AddressPubKey); ok {
+		return pkAddr.AddressPubKeyHash().EncodeAddress()
+	}
+	return addr.EncodeAddress()
+}
+
+// scriptAddrKeys returns the keys of the addresses the passed public key
+// script pays to.  Scripts which do not pay to any address have no keys.
+func (idx *poolAddrIndex) scriptAddrKeys(pkScript []byte) []string {
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, idx.params)
+	keys := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		keys = append(keys, poolAddrKey(addr))
+	}
+	return keys
+}
+
+// addTx indexes the passed transaction under the addresses of its outputs and
+// of the outputs it spends, which are looked up in the passed utxo view.
+func (idx *poolAddrIndex) addTx(tx *ulordutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	hash := *tx.Hash()
+	keys := make(map[string]struct{})
+	index := func(key string) {
+		keys[key] = struct{}{}
+		txns, ok := idx.txns[key]
+		if !ok {
+			txns = make(map[chainhash.Hash]struct{})
+			idx.txns[key] = txns
+		}
+		txns[hash] = struct{}{}
+	}
+
+	// The existence checks are elided since the transaction has already
+	// been validated and thus all inputs are known to exist.
+	for _, txIn := range tx.MsgTx().TxIn {
+		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil {
+			continue
+		}
+		for _, key := range idx.scriptAddrKeys(entry.PkScript()) {
+			index(key)
+		}
+	}
+
+	for i, txOut := range tx.MsgTx().TxOut {
+		op := wire.OutPoint{Hash: hash, Index: uint32(i)}
+		for _, key := range idx.scriptAddrKeys(txOut.PkScript) {
+			index(key)
+			outputs, ok := idx.outputs[key]
+			if !ok {
+				outputs = make(map[wire.OutPoint]struct{})
+				idx.outputs[key] = outputs
+			}
+			outputs[op] = struct{}{}
+		}
+	}
+
+	if len(keys) != 0 {
+		idx.txAddrs[hash] = keys
+	}
+}
+
+// removeTx removes the passed transaction from the index.
+func (idx *poolAddrIndex) removeTx(tx *ulordutil.Tx) {
+	hash := *tx.Hash()
+	for key := range idx.txAddrs[hash] {
+		delete(idx.txns[key], hash)
+		if len(idx.txns[key]) == 0 {
+			delete(idx.txns, key)
+		}
+
+		outputs := idx.outputs[key]
+		for i := range tx.MsgTx().TxOut {
+			delete(outputs, wire.OutPoint{Hash: hash, Index: uint32(i)})
+		}
+		if len(outputs) == 0 {
+			delete(idx.outputs, key)
+		}
+	}
+	delete(idx.txAddrs, hash)
+}
+
+// txHashes returns the hashes of the transactions paying to or spending from
+// the passed address.
+func (idx *poolAddrIndex) txHashes(addr ulordutil.Address) []chainhash.Hash {
+	txns := idx.txns[poolAddrKey(addr)]
+	hashes := make([]chainhash.Hash, 0, len(txns))
+	for hash := range txns {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// outPoints returns the outputs paying to the passed address sorted by their
+// transaction hashes and output indexes.
+func (idx *poolAddrIndex) outPoints(addr ulordutil.Address) []wire.OutPoint {
+	outputs := idx.outputs[poolAddrKey(addr)]
+	ops := make([]wire.OutPoint, 0, len(outputs))
+	for op := range outputs {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if c := bytes.Compare(ops[i].Hash[:], ops[j].Hash[:]); c != 0 {
+			return c < 0
+		}
+		return ops[i].Index < ops[j].Index
+	})
+	return ops
+}
+
+// HasAddrIndex returns whether the pool maintains its address index, which is
+// enabled by Config.IndexAddrs.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) HasAddrIndex() bool {
+	return mp.addrIndex != nil
+}
+
+// TxDescsForAddress returns the descriptors of the transactions in the pool
+// paying to or spending from the passed address, ordered by the time they were
+// added to the pool.  It returns nil when the address index of the pool is not
+// enabled.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TxDescsForAddress(addr ulordutil.Address) []*TxDesc {
+	if mp.addrIndex == nil {
+		return nil
+	}
+
+	mp.mtx.RLock()
+	hashes := mp.addrIndex.txHashes(addr)
+	descs := make([]*TxDesc, 0, len(hashes))
+	for i := range hashes {
+		descs = append(descs, mp.pool.Get(hashes[i]))
+	}
+	mp.mtx.RUnlock()
+
+	sort.Slice(descs, func(i, j int) bool {
+		if !descs[i].Added.Equal(descs[j].Added) {
+			return descs[i].Added.Before(descs[j].Added)
+		}
+		return bytes.Compare(descs[i].Tx.Hash()[:],
+			descs[j].Tx.Hash()[:]) < 0
+	})
+	return descs
+}
+
+// OutPointsForAddress returns the outputs of transactions in the pool paying
+// to the passed address, which are the pending payments to the address,
+// sorted by their transaction hashes and output indexes.  Outputs which are
+// already spent by other transactions in the pool are included.  It returns nil
+// when the address index of the pool is not enabled.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) OutPointsForAddress(addr ulordutil.Address) []wire.OutPoint {
+	if mp.addrIndex == nil {
+		return nil
+	}
+
+	mp.mtx.RLock()
+	ops := mp.addrIndex.outPoints(addr)
+	mp.mtx.RUnlock()
+	return ops
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestAddrIndex ensures the address index of the pool tracks the transactions
+// and outputs of addresses as transactions are added and removed.
+func TestAddrIndex(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	if harness.txPool.HasAddrIndex() ||
+		harness.txPool.TxDescsForAddress(harness.payAddr) != nil {
+
+		t.Fatal("address index enabled without IndexAddrs")
+	}
+	harness.txPool.addrIndex = newPoolAddrIndex(harness.chainParams)
+
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+
+	// Pay to pubkey addresses are indexed like their pay to pubkey hash
+	// addresses, so both find the transactions paying to the key.
+	pkAddr, err := ulordutil.NewAddressPubKey(
+		harness.signKey.PubKey().SerializeCompressed(),
+		harness.chainParams)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	for _, addr := range []ulordutil.Address{harness.payAddr, pkAddr} {
+		descs := harness.txPool.TxDescsForAddress(addr)
+		if len(descs) != len(chainedTxns) {
+			t.Fatalf("TxDescsForAddress: got %d transactions, want %d",
+				len(descs), len(chainedTxns))
+		}
+		for _, tx := range chainedTxns {
+			found := false
+			for _, txD := range descs {
+				found = found || txD.Tx.Hash().IsEqual(tx.Hash())
+			}
+			if !found {
+				t.Fatalf("TxDescsForAddress: transaction %v "+
+					"not found", tx.Hash())
+			}
+		}
+
+		ops := harness.txPool.OutPointsForAddress(addr)
+		if len(ops) != len(chainedTxns) {
+			t.Fatalf("OutPointsForAddress: got %d outputs, want %d",
+				len(ops), len(chainedTxns))
+		}
+		if bytes.Compare(ops[0].Hash[:], ops[1].Hash[:]) >= 0 {
+			t.Fatalf("OutPointsForAddress: unsorted outputs %v", ops)
+		}
+	}
+
+	otherAddr, err := ulordutil.NewAddressPubKeyHash(make([]byte, 20),
+		harness.chainParams)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	if got := harness.txPool.TxDescsForAddress(otherAddr); len(got) != 0 {
+		t.Fatalf("TxDescsForAddress: got %d transactions for an "+
+			"unrelated address", len(got))
+	}
+
+	// Removing the last transaction of the chain leaves the output of the
+	// first one, which it spent, as the only pending payment.
+	harness.txPool.RemoveTransaction(chainedTxns[1], false)
+	ops := harness.txPool.OutPointsForAddress(harness.payAddr)
+	want := wire.OutPoint{Hash: *chainedTxns[0].Hash()}
+	if len(ops) != 1 || ops[0] != want {
+		t.Fatalf("OutPointsForAddress: got %v, want [%v]", ops, want)
+	}
+
+	// Ensure no entries are left behind once the pool is empty.
+	harness.txPool.RemoveTransaction(chainedTxns[0], false)
+	idx := harness.txPool.addrIndex
+	if len(idx.txns) != 0 || len(idx.outputs) != 0 || len(idx.txAddrs) != 0 {
+		t.Fatalf("address index not empty after removing all "+
+			"transactions: %d addresses, %d output addresses, %d "+
+			"transactions", len(idx.txns), len(idx.outputs),
+			len(idx.txAddrs))
+	}
+}
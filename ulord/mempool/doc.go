@@ -40,14 +40,30 @@ be an exhaustive list.
    - Reject non-fully-spent duplicate transactions
    - Reject coinbase transactions
    - Reject double spends (both from the chain and other transactions in pool)
+   - Opt-in replacement of pool transactions which signal replaceability
+     (BIP0125) by transactions paying higher fees
    - Reject invalid transactions according to the network consensus rules
    - Full script execution and validation with signature cache support
    - Individual transaction query support
+   - Optional address index answering queries for the transactions and
+     pending payments of an address without scanning the pool
  - Orphan transaction support (transactions that spend from unknown outputs)
    - Configurable limits (see transaction acceptance policy)
    - Automatic addition of orphan transactions that are no longer orphans as new
      transactions are added to the pool
    - Individual orphan transaction query support
+   - Per-peer orphan quotas which evict the oldest orphans of the peer with the
+     most orphans first
+   - Orphan pool statistics
+ - Detailed acceptance results reporting the outcome and timing of every check
+   along with an optional hook to log or export acceptance metrics
+ - InstantSend lock awareness which marks transactions locked by masternode
+   quorums, rejects conflicting spends of locked inputs and prevents locked
+   transactions from being replaced
+ - Dry-run acceptance checks which report whether a transaction would be
+   accepted along with its fee without modifying the pool
+ - Concurrent validation which verifies the scripts of transactions without
+   holding the pool lock and lock-free lookups of transactions in the pool
  - Configurable transaction acceptance policy
    - Option to accept or reject standard transactions
    - Option to accept or reject transactions based on priority calculations
@@ -55,7 +71,12 @@ be an exhaustive list.
    - Non-zero fee threshold
    - Max signature operations per transaction
    - Max orphan transaction size
-   - Max number of orphan transactions allowed
+   - Max number of orphan transactions allowed in total and per peer
+   - Option to reject replacement transactions and max number of transactions
+     a replacement may evict
+   - Max pool size with eviction of the transactions paying the lowest fee
+     rates and a minimum fee rate which rises while the pool is full
+   - Expiry of transactions which stay in the pool for too long
  - Additional metadata tracking for each transaction
    - Timestamp when the transaction was added to the pool
    - Most recent block height when the transaction was added to the pool
@@ -63,6 +84,8 @@ be an exhaustive list.
    - The starting priority for the transaction
  - Manual control of transaction removal
    - Recursive removal of all dependent transactions
+ - Saving the pool to a file and loading it back with all transactions
+   validated again against the current chain
 
 Errors
 
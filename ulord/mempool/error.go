@@ -5,10 +5,46 @@
 package mempool
 
 import (
+	"strings"
+	"unicode"
+
 	"github.com/ulordsuite/ulord/blockchain"
 	"github.com/ulordsuite/ulord/wire"
 )
 
+// These constants define the reject reasons of transactions rejected by the
+// memory pool.  Transactions rejected due to a blockchain.RuleError carry the
+// reason returned by ChainRejectReason instead.
+const (
+	RejectReasonAlreadyKnown           wire.RejectReason = "txn-already-known"
+	RejectReasonAlreadyExists          wire.RejectReason = "txn-already-exists"
+	RejectReasonMempoolConflict        wire.RejectReason = "txn-mempool-conflict"
+	RejectReasonTxLocked               wire.RejectReason = "txn-locked"
+	RejectReasonMissingInputs          wire.RejectReason = "missing-inputs"
+	RejectReasonOrphanTooLarge         wire.RejectReason = "orphan-too-large"
+	RejectReasonCoinbase               wire.RejectReason = "coinbase"
+	RejectReasonNoWitnessYet           wire.RejectReason = "no-witness-yet"
+	RejectReasonNonBIP68Final          wire.RejectReason = "non-bip68-final"
+	RejectReasonNonFinal               wire.RejectReason = "non-final"
+	RejectReasonVersion                wire.RejectReason = "version"
+	RejectReasonTxSize                 wire.RejectReason = "tx-size"
+	RejectReasonScriptSigSize          wire.RejectReason = "scriptsig-size"
+	RejectReasonScriptSigNotPushOnly   wire.RejectReason = "scriptsig-not-pushonly"
+	RejectReasonScriptPubKey           wire.RejectReason = "scriptpubkey"
+	RejectReasonMultiOpReturn          wire.RejectReason = "multi-op-return"
+	RejectReasonNonstandardInputs      wire.RejectReason = "bad-txns-nonstandard-inputs"
+	RejectReasonTooManySigOps          wire.RejectReason = "bad-txns-too-many-sigops"
+	RejectReasonTooLongMempoolChain    wire.RejectReason = "too-long-mempool-chain"
+	RejectReasonTooManyReplacements    wire.RejectReason = "too-many-replacements"
+	RejectReasonReplacementConflict    wire.RejectReason = "replacement-spends-conflict"
+	RejectReasonReplacementUnconfirmed wire.RejectReason = "replacement-adds-unconfirmed"
+	RejectReasonMinRelayFee            wire.RejectReason = "min-relay-fee-not-met"
+	RejectReasonMempoolMinFee          wire.RejectReason = "mempool-min-fee-not-met"
+	RejectReasonInsufficientPriority   wire.RejectReason = "insufficient-priority"
+	RejectReasonRateLimited            wire.RejectReason = "rate-limited-free-tx"
+	RejectReasonMempoolFull            wire.RejectReason = "mempool-full"
+)
+
 // RuleError identifies a rule violation.  It is used to indicate that
 // processing of a transaction failed due to one of the many validation
 // rules.  The caller can use type assertions to determine if a failure was
@@ -33,8 +69,9 @@ func (e RuleError) Error() string {
 // specifically due to a rule violation and access the ErrorCode field to
 // ascertain the specific reason for the rule violation.
 type TxRuleError struct {
-	RejectCode  wire.RejectCode // The code to send with reject messages
-	Description string          // Human readable description of the issue
+	RejectCode  wire.RejectCode   // The code to send with reject messages
+	Reason      wire.RejectReason // The precise reason of the reject code
+	Description string            // Human readable description of the issue
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -44,9 +81,9 @@ func (e TxRuleError) Error() string {
 
 // txRuleError creates an underlying TxRuleError with the given a set of
 // arguments and returns a RuleError that encapsulates it.
-func txRuleError(c wire.RejectCode, desc string) RuleError {
+func txRuleError(c wire.RejectCode, reason wire.RejectReason, desc string) RuleError {
 	return RuleError{
-		Err: TxRuleError{RejectCode: c, Description: desc},
+		Err: TxRuleError{RejectCode: c, Reason: reason, Description: desc},
 	}
 }
 
@@ -58,6 +95,57 @@ func chainRuleError(chainErr blockchain.RuleError) RuleError {
 	}
 }
 
+// ChainRejectReason returns the reject reason of blocks and transactions
+// rejected due to a blockchain.RuleError with the passed error code.  It is
+// the name of the error code without its Err prefix in lowercase words joined
+// by dashes, so ErrBadMerkleRoot maps to "bad-merkle-root", and
+// wire.RejectReasonInvalid for unknown error codes.
+func ChainRejectReason(code blockchain.ErrorCode) wire.RejectReason {
+	name := code.String()
+	if !strings.HasPrefix(name, "Err") {
+		return wire.RejectReasonInvalid
+	}
+	name = name[len("Err"):]
+
+	var reason []rune
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				reason = append(reason, '-')
+			}
+			r = unicode.ToLower(r)
+		}
+		reason = append(reason, r)
+	}
+	return wire.RejectReason(reason)
+}
+
+// extractRejectReason attempts to return a relevant reject code and reason for
+// a given error by examining the error for known types.  It will return true if
+// they were successfully extracted.
+func extractRejectReason(err error) (wire.RejectCode, wire.RejectReason, bool) {
+	code, found := extractRejectCode(err)
+	if !found {
+		return code, code.Reason(), false
+	}
+
+	// Pull the underlying error out of a RuleError.
+	if rerr, ok := err.(RuleError); ok {
+		err = rerr.Err
+	}
+
+	switch err := err.(type) {
+	case blockchain.RuleError:
+		return code, ChainRejectReason(err.ErrorCode), true
+
+	case TxRuleError:
+		if err.Reason != "" {
+			return code, err.Reason, true
+		}
+	}
+	return code, code.Reason(), true
+}
+
 // extractRejectCode attempts to return a relevant reject code for a given error
 // by examining the error for known types.  It will return true if a code
 // was successfully extracted.
@@ -131,3 +219,13 @@ func ErrToRejectErr(err error) (wire.RejectCode, string) {
 	// text.
 	return wire.RejectInvalid, "rejected: " + err.Error()
 }
+
+// ErrToRejectReason examines the underlying type of the error like
+// ErrToRejectErr and additionally returns the machine-readable reject reason
+// of the error.  The returned code, reason and detail are appropriate to be
+// sent in a wire.MsgReject message created by wire.NewMsgRejectReason.
+func ErrToRejectReason(err error) (wire.RejectCode, wire.RejectReason, string) {
+	code, detail := ErrToRejectErr(err)
+	_, reason, _ := extractRejectReason(err)
+	return code, reason, detail
+}
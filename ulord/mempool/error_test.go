@@ -0,0 +1,107 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// TestErrToRejectReason ensures errors are converted to the expected reject
+// codes, reasons and details.
+func TestErrToRejectReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   wire.RejectCode
+		reason wire.RejectReason
+		detail string
+	}{{
+		name: "tx rule error",
+		err: txRuleError(wire.RejectInsufficientFee,
+			RejectReasonMinRelayFee, "fee too low"),
+		code:   wire.RejectInsufficientFee,
+		reason: RejectReasonMinRelayFee,
+		detail: "fee too low",
+	}, {
+		name: "tx rule error without reason",
+		err: RuleError{Err: TxRuleError{
+			RejectCode:  wire.RejectDust,
+			Description: "dust output",
+		}},
+		code:   wire.RejectDust,
+		reason: wire.RejectReasonDust,
+		detail: "dust output",
+	}, {
+		name: "chain rule error",
+		err: chainRuleError(blockchain.RuleError{
+			ErrorCode:   blockchain.ErrBadMerkleRoot,
+			Description: "bad merkle root",
+		}),
+		code:   wire.RejectInvalid,
+		reason: "bad-merkle-root",
+		detail: "bad merkle root",
+	}, {
+		name: "unwrapped chain rule error",
+		err: blockchain.RuleError{
+			ErrorCode:   blockchain.ErrDuplicateBlock,
+			Description: "already have block",
+		},
+		code:   wire.RejectDuplicate,
+		reason: "duplicate-block",
+		detail: "already have block",
+	}, {
+		name:   "other error",
+		err:    errors.New("disk full"),
+		code:   wire.RejectInvalid,
+		reason: wire.RejectReasonInvalid,
+		detail: "rejected: disk full",
+	}, {
+		name:   "nil error",
+		code:   wire.RejectInvalid,
+		reason: wire.RejectReasonInvalid,
+		detail: "rejected",
+	}}
+
+	for _, test := range tests {
+		code, reason, detail := ErrToRejectReason(test.err)
+		if code != test.code || reason != test.reason ||
+			detail != test.detail {
+
+			t.Errorf("%s: got (%v, %q, %q), want (%v, %q, %q)",
+				test.name, code, reason, detail, test.code,
+				test.reason, test.detail)
+		}
+	}
+}
+
+// TestChainRejectReason ensures the reject reasons of blockchain error codes
+// are derived from their names.
+func TestChainRejectReason(t *testing.T) {
+	tests := []struct {
+		in   blockchain.ErrorCode
+		want wire.RejectReason
+	}{
+		{blockchain.ErrDuplicateBlock, "duplicate-block"},
+		{blockchain.ErrBadMerkleRoot, "bad-merkle-root"},
+		{blockchain.ErrBadMasternodePayment, "bad-masternode-payment"},
+		{blockchain.ErrorCode(0xffff), wire.RejectReasonInvalid},
+	}
+
+	for i, test := range tests {
+		got := ChainRejectReason(test.in)
+		if got != test.want {
+			t.Errorf("ChainRejectReason #%d (%v): got %q, want %q",
+				i, test.in, got, test.want)
+		}
+		if !got.IsValid() {
+			t.Errorf("ChainRejectReason #%d (%v): invalid reason %q",
+				i, test.in, got)
+		}
+	}
+}
@@ -178,6 +178,13 @@ type FeeEstimator struct {
 	// Transactions that have been removed from the bins. This allows us to
 	// revert in case of an orphaned block.
 	dropped []*registeredBlock
+
+	// The decaying fee rate bucket statistics over a short and a long
+	// history of blocks used for smart fee estimates.  Unlike the bins,
+	// they are not reverted by Rollback since their decay limits the
+	// effect of an orphaned block.
+	shortStats *feeBucketStats
+	longStats  *feeBucketStats
 }
 
 // NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
@@ -192,6 +199,8 @@ func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
 		maxReplacements:     estimateFeeMaxReplacements,
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		shortStats:          newFeeBucketStats(shortHorizonDecay),
+		longStats:           newFeeBucketStats(longHorizonDecay),
 	}
 }
 
@@ -237,6 +246,10 @@ func (ef *FeeEstimator) RegisterBlock(block *ulordutil.Block) error {
 	ef.lastKnownHeight = height
 	ef.numBlocksRegistered++
 
+	// Age the bucket statistics before recording the new block.
+	ef.shortStats.decayAll()
+	ef.longStats.decayAll()
+
 	// Randomly order txs in block.
 	transactions := make(map[*ulordutil.Tx]struct{})
 	for _, t := range block.Transactions() {
@@ -273,6 +286,10 @@ func (ef *FeeEstimator) RegisterBlock(block *ulordutil.Block) error {
 			return errors.New("Transaction has already been mined")
 		}
 
+		// Record how quickly the tx confirmed for smart estimates.
+		ef.shortStats.record(o.feeRate, height-o.observed)
+		ef.longStats.record(o.feeRate, height-o.observed)
+
 		// This shouldn't happen but check just in case to avoid
 		// an out-of-bounds array index later.
 		if blocksToConfirm >= estimateFeeDepth {
@@ -308,6 +325,10 @@ func (ef *FeeEstimator) RegisterBlock(block *ulordutil.Block) error {
 	// Go through the mempool for txs that have been in too long.
 	for hash, o := range ef.observed {
 		if o.mined == mining.UnminedHeight && height-o.observed >= estimateFeeDepth {
+			// Record the tx as not confirmed in time for smart
+			// estimates.
+			ef.shortStats.record(o.feeRate, estimateFeeDepth+1)
+			ef.longStats.record(o.feeRate, estimateFeeDepth+1)
 			delete(ef.observed, hash)
 		}
 	}
@@ -334,6 +355,27 @@ func (ef *FeeEstimator) LastKnownHeight() int32 {
 	return ef.lastKnownHeight
 }
 
+// Rebase discards the transactions which are waiting to be mined and the
+// history needed to roll back blocks, and continues from the passed height.
+// It allows the fee statistics of a FeeEstimator restored from a state which
+// is not at the current height of the chain, for example after an unclean
+// shutdown, to be kept.
+func (ef *FeeEstimator) Rebase(height int32) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	// The mined transactions must be kept since they are referenced by
+	// the bins.
+	for hash, o := range ef.observed {
+		if o.mined == mining.UnminedHeight {
+			delete(ef.observed, hash)
+		}
+	}
+	ef.dropped = ef.dropped[:0]
+	ef.lastKnownHeight = height
+	ef.cached = nil
+}
+
 // Rollback unregisters a recently registered block from the FeeEstimator.
 // This can be used to reverse the effect of an orphaned block on the fee
 // estimator. The maximum number of rollbacks allowed is given by
@@ -578,7 +620,7 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
 // start fee estimation over.
-const estimateFeeSaveVersion = 1
+const estimateFeeSaveVersion = 2
 
 func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
 	var lenTransactions uint32
@@ -671,6 +713,10 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 		registered.serialize(w, observed)
 	}
 
+	// Bucket statistics.
+	ef.shortStats.serialize(w)
+	ef.longStats.serialize(w)
+
 	// Commit the tx and return.
 	return FeeEstimatorState(w.Bytes())
 }
@@ -745,5 +791,15 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		}
 	}
 
+	// Read bucket statistics.
+	ef.shortStats, err = deserializeFeeBucketStats(r)
+	if err != nil {
+		return nil, err
+	}
+	ef.longStats, err = deserializeFeeBucketStats(r)
+	if err != nil {
+		return nil, err
+	}
+
 	return ef, nil
 }
@@ -26,6 +26,8 @@ func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstim
 		maxReplacements:     int32(maxReplacements),
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		shortStats:          newFeeBucketStats(shortHorizonDecay),
+		longStats:           newFeeBucketStats(longHorizonDecay),
 	}
 }
 
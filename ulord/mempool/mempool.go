@@ -8,6 +8,7 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -38,8 +39,92 @@ const (
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
+
+	// DefaultMaxOrphanTxsPerTag is the default maximum number of orphans
+	// with the same tag, which is typically the peer that relayed them,
+	// that can be queued.
+	DefaultMaxOrphanTxsPerTag = 25
+
+	// MaxRBFSequence is the maximum sequence number an input can use to
+	// signal that the transaction spending it can be replaced using the
+	// opt-in Replace-By-Fee policy defined by BIP0125.
+	MaxRBFSequence = 0xfffffffd
+
+	// DefaultMaxReplacementEvictions is the default maximum number of
+	// transactions, including their descendants, a single replacement
+	// transaction may evict from the pool.
+	DefaultMaxReplacementEvictions = 100
+
+	// DefaultMaxAncestorCount is the default maximum number of
+	// transactions, including itself, a transaction and its unconfirmed
+	// ancestors in the pool may consist of.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum total virtual size of
+	// a transaction and its unconfirmed ancestors in the pool.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// transactions, including itself, a transaction in the pool and its
+	// descendants may consist of.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum total virtual size
+	// of a transaction in the pool and its descendants.
+	DefaultMaxDescendantSize = 101000
+
+	// DefaultTxExpiry is the default maximum amount of time a transaction
+	// is allowed to stay in the pool before it expires and is evicted.
+	DefaultTxExpiry = time.Hour * 336
+
+	// DefaultMaxPoolSize is the default maximum total virtual size of all
+	// transactions in the pool.
+	DefaultMaxPoolSize = 300 * 1000 * 1000
+
+	// txExpireScanInterval is the minimum amount of time in between scans
+	// of the pool to evict expired transactions.
+	txExpireScanInterval = time.Minute * 10
+
+	// rollingFeeHalfLife is the amount of time it takes the minimum fee
+	// rate raised by evicting transactions to keep the pool within its
+	// maximum size to decay by half.  It decays faster while the pool is
+	// mostly empty.
+	rollingFeeHalfLife = time.Hour * 12
 )
 
+// EvictionReason describes why a transaction was evicted from the pool.
+type EvictionReason int
+
+// These constants define the reasons a transaction can be evicted from the
+// pool for.  Transactions evicted because they are replaced per BIP0125 are
+// reported separately through Config.TxReplaced.
+const (
+	// EvictionExpired indicates the transaction, or one of its ancestors,
+	// stayed in the pool for longer than the expiry set by policy.
+	EvictionExpired EvictionReason = iota
+
+	// EvictionSizeLimit indicates the transaction, or one of its
+	// ancestors, paid the lowest fee rate in the pool when it exceeded its
+	// maximum size.
+	EvictionSizeLimit
+)
+
+// evictionReasonStrings is a map of eviction reasons back to their constant
+// names for pretty printing.
+var evictionReasonStrings = map[EvictionReason]string{
+	EvictionExpired:   "EvictionExpired",
+	EvictionSizeLimit: "EvictionSizeLimit",
+}
+
+// String returns the EvictionReason in human-readable form.
+func (r EvictionReason) String() string {
+	if s, ok := evictionReasonStrings[r]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown EvictionReason (%d)", int(r))
+}
+
 // Tag represents an identifier to use for tagging orphan transactions.  The
 // caller may choose any scheme it desires, however it is common to use peer IDs
 // so that orphans can be identified by which peer first relayed them.
@@ -63,6 +148,15 @@ type Config struct {
 	// the current best chain.
 	BestHeight func() int32
 
+	// BestHash defines the function to use to access the hash of the best
+	// block of the current best chain.  It allows detecting a
+	// reorganization which replaces the best block with another one at the
+	// same height while a transaction is validated.
+	//
+	// This field can be nil, in which case only changes of the height of
+	// the best chain are detected.
+	BestHash func() chainhash.Hash
+
 	// MedianTimePast defines the function to use in order to access the
 	// median time past calculated from the point-of-view of the current
 	// chain tip within the best chain.
@@ -90,9 +184,51 @@ type Config struct {
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
 
+	// IndexAddrs defines whether the pool maintains an index of its
+	// transactions by the addresses they pay to and spend from, which
+	// answers TxDescsForAddress and OutPointsForAddress queries without
+	// scanning the pool.  Unlike AddrIndex, it does not require the
+	// address index of the chain to be enabled.
+	IndexAddrs bool
+
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// TxReplaced defines an optional function to call when transactions
+	// are evicted from the pool because a transaction replacing them per
+	// BIP0125 was accepted.  The evicted transactions include the
+	// descendants of the replaced transactions.  It is called with the
+	// mempool lock held, so it must not call back into the pool.
+	TxReplaced func(evicted []*ulordutil.Tx, replacement *ulordutil.Tx)
+
+	// TxEvicted defines an optional function to call for every transaction
+	// evicted from the pool because it expired or to keep the pool within
+	// its maximum size, along with the reason.  It is called with the
+	// mempool lock held, so it must not call back into the pool.
+	TxEvicted func(tx *ulordutil.Tx, reason EvictionReason)
+
+	// TxLocks defines an optional source of the transaction locks
+	// established by masternode quorums for InstantSend.  Transactions
+	// spending outputs locked to another transaction are rejected.
+	TxLocks TxLockSource
+
+	// TxLocked defines an optional function to call for every transaction
+	// in the pool which MarkTxLocked marks as locked.  Transactions which
+	// are already locked when they are added to the pool are not passed,
+	// since their descriptors are marked as locked instead.  It is called
+	// without the mempool lock held.
+	TxLocked func(tx *ulordutil.Tx)
+
+	// AcceptanceHook defines an optional hook which is passed the result of
+	// processing every transaction with ProcessTransaction and
+	// ProcessTransactionResult.
+	AcceptanceHook AcceptanceHook
+
+	// PersistFile is the path of the file the pool contents are written to
+	// by SaveToFile and read back from by LoadFromFile so that pending
+	// transactions survive a restart.  An empty path disables persistence.
+	PersistFile string
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -120,6 +256,13 @@ type Policy struct {
 	// that can be queued.
 	MaxOrphanTxs int
 
+	// MaxOrphanTxsPerTag is the maximum number of orphan transactions with
+	// the same tag that can be queued.  Since tags typically identify the
+	// peer which relayed an orphan, it prevents a single peer from taking
+	// up all orphan slots.  DefaultMaxOrphanTxsPerTag is used when it is
+	// not positive.
+	MaxOrphanTxsPerTag int
+
 	// MaxOrphanTxSize is the maximum size allowed for orphan transactions.
 	// This helps prevent memory exhaustion attacks from sending a lot of
 	// of big orphans.
@@ -133,6 +276,49 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in BTC/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee ulordutil.Amount
+
+	// RejectReplacement defines whether to reject transactions which
+	// double spend transactions in the pool even when those signal
+	// replaceability per BIP0125.
+	RejectReplacement bool
+
+	// MaxReplacementEvictions is the maximum number of transactions,
+	// including their descendants, a single replacement transaction may
+	// evict from the pool.  DefaultMaxReplacementEvictions is used when it
+	// is not positive.
+	MaxReplacementEvictions int
+
+	// MaxAncestorCount and MaxAncestorSize are the maximum number and total
+	// virtual size of the transactions formed by a transaction and its
+	// unconfirmed ancestors in the pool.  DefaultMaxAncestorCount and
+	// DefaultMaxAncestorSize are used when they are not positive.
+	MaxAncestorCount int
+	MaxAncestorSize  int64
+
+	// MaxDescendantCount and MaxDescendantSize are the maximum number and
+	// total virtual size of the transactions formed by a transaction in
+	// the pool and its descendants.  DefaultMaxDescendantCount and
+	// DefaultMaxDescendantSize are used when they are not positive.
+	MaxDescendantCount int
+	MaxDescendantSize  int64
+
+	// TxExpiry is the maximum amount of time a transaction is allowed to
+	// stay in the pool.  Expired transactions are evicted along with their
+	// descendants.  DefaultTxExpiry is used when it is not positive.
+	TxExpiry time.Duration
+
+	// MaxPoolSize is the maximum total virtual size of all transactions in
+	// the pool.  Once it is exceeded, the transactions with the lowest fee
+	// rates are evicted along with their descendants and the minimum fee
+	// rate required to enter the pool is raised above theirs.
+	// DefaultMaxPoolSize is used when it is not positive.
+	MaxPoolSize int64
+
+	// Standardness holds the standardness rules transactions must satisfy
+	// unless AcceptNonStd is set, although its script flags are always
+	// used to execute scripts.  txscript.DefaultPolicy is used when it is
+	// nil.
+	Standardness *txscript.Policy
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -143,6 +329,24 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// AncestorCount, AncestorSize and AncestorFees are the number, total
+	// virtual size and total fees of the transaction and all of its
+	// unconfirmed ancestors in the pool.
+	AncestorCount int
+	AncestorSize  int64
+	AncestorFees  int64
+
+	// DescendantCount, DescendantSize and DescendantFees are the number,
+	// total virtual size and total fees of the transaction and all of its
+	// descendants in the pool.
+	DescendantCount int
+	DescendantSize  int64
+	DescendantFees  int64
+
+	// Locked is whether the transaction has been locked by a masternode
+	// quorum for InstantSend.
+	Locked bool
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -152,6 +356,34 @@ type orphanTx struct {
 	tx         *ulordutil.Tx
 	tag        Tag
 	expiration time.Time
+
+	// seq is the order the orphan was added to the orphan pool in.  It is
+	// used to resolve orphans in the order they were received and to find
+	// the oldest orphans to evict.
+	seq uint64
+}
+
+// OrphanStats houses statistics about the orphan pool.
+type OrphanStats struct {
+	// Count is the number of orphans currently in the pool and CountByTag
+	// the number of them by tag.
+	Count      int
+	CountByTag map[Tag]int
+
+	// Added is the total number of orphans added to the pool.
+	Added uint64
+
+	// Resolved is the total number of orphans which were accepted into
+	// the main pool once their missing parents became available.
+	Resolved uint64
+
+	// Expired is the total number of orphans removed because they stayed
+	// in the pool for too long, including the orphans redeeming them.
+	Expired uint64
+
+	// Evicted is the total number of orphans evicted to make room for new
+	// ones because either the pool or the quota of their tag was full.
+	Evicted uint64
 }
 
 // TxPool is used as a source of transactions that need to be mined into blocks
@@ -163,10 +395,14 @@ type TxPool struct {
 
 	mtx           sync.RWMutex
 	cfg           Config
-	pool          map[chainhash.Hash]*TxDesc
+	pool          *poolMap
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*ulordutil.Tx
+	orphansByTag  map[Tag]map[chainhash.Hash]*orphanTx
+	orphanSeq     uint64
+	orphanStats   OrphanStats
 	outpoints     map[wire.OutPoint]*ulordutil.Tx
+	addrIndex     *poolAddrIndex // nil unless Config.IndexAddrs is set
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
@@ -175,6 +411,29 @@ type TxPool struct {
 	// the scan will only run when an orphan is added to the pool as opposed
 	// to on an unconditional timer.
 	nextExpireScan time.Time
+
+	// nextTxExpireScan is the time after which the pool will be scanned in
+	// order to evict expired transactions.  Like nextExpireScan, the scan
+	// only runs when a transaction is added to the pool.
+	nextTxExpireScan time.Time
+
+	// poolSize is the total virtual size of all transactions in the pool.
+	poolSize int64
+
+	// rollingMinFee is the minimum fee rate in satoshi/kB raised by
+	// evicting transactions to keep the pool within its maximum size.  It
+	// decays over time starting from lastRollingFeeUpdate.
+	rollingMinFee        float64
+	lastRollingFeeUpdate time.Time
+
+	// generation is incremented whenever a transaction is added to or
+	// removed from the main pool.  It allows detecting whether the pool
+	// changed while a transaction was validated without holding the lock.
+	generation uint64
+
+	// trustedTags houses the tags of trusted sources, such as trusted
+	// peers, whose transactions bypass the standardness and fee policy.
+	trustedTags map[Tag]struct{}
 }
 
 // Ensure the TxPool type implements the mining.TxSource interface.
@@ -219,6 +478,11 @@ func (mp *TxPool) removeOrphan(tx *ulordutil.Tx, removeRedeemers bool) {
 
 	// Remove the transaction from the orphan pool.
 	delete(mp.orphans, *txHash)
+	tagOrphans := mp.orphansByTag[otx.tag]
+	delete(tagOrphans, *txHash)
+	if len(tagOrphans) == 0 {
+		delete(mp.orphansByTag, otx.tag)
+	}
 }
 
 // RemoveOrphan removes the passed orphan transaction from the orphan pool and
@@ -238,21 +502,59 @@ func (mp *TxPool) RemoveOrphan(tx *ulordutil.Tx) {
 func (mp *TxPool) RemoveOrphansByTag(tag Tag) uint64 {
 	var numEvicted uint64
 	mp.mtx.Lock()
-	for _, otx := range mp.orphans {
-		if otx.tag == tag {
-			mp.removeOrphan(otx.tx, true)
-			numEvicted++
-		}
+	for _, otx := range mp.orphansByTag[tag] {
+		mp.removeOrphan(otx.tx, true)
+		numEvicted++
 	}
 	mp.mtx.Unlock()
 	return numEvicted
 }
 
-// limitNumOrphans limits the number of orphan transactions by evicting a random
-// orphan if adding a new one would cause it to overflow the max allowed.
+// OrphanStats returns statistics about the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) OrphanStats() OrphanStats {
+	mp.mtx.RLock()
+	stats := mp.orphanStats
+	stats.Count = len(mp.orphans)
+	stats.CountByTag = make(map[Tag]int, len(mp.orphansByTag))
+	for tag, tagOrphans := range mp.orphansByTag {
+		stats.CountByTag[tag] = len(tagOrphans)
+	}
+	mp.mtx.RUnlock()
+
+	return stats
+}
+
+// evictOldestOrphan evicts the orphan which was added to the orphan pool first
+// out of the passed orphans.  Orphans redeeming it are not evicted since it is
+// quite possible it might be needed again shortly.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) limitNumOrphans() error {
+func (mp *TxPool) evictOldestOrphan(orphans map[chainhash.Hash]*orphanTx) {
+	var oldest *orphanTx
+	for _, otx := range orphans {
+		if oldest == nil || otx.seq < oldest.seq {
+			oldest = otx
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	mp.removeOrphan(oldest.tx, false)
+	mp.orphanStats.Evicted++
+}
+
+// limitNumOrphans limits the number of orphan transactions by evicting an
+// orphan if adding a new one with the passed tag would cause either the orphans
+// with that tag or the whole orphan pool to overflow the max allowed.  The
+// oldest orphan with the same tag is evicted in the first case and the oldest
+// orphan of the tag with the most orphans in the second, so a single peer can
+// only push out its own orphans.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitNumOrphans(tag Tag) error {
 	// Scan through the orphan pool and remove any expired orphans when it's
 	// time.  This is done for efficiency so the scan only happens
 	// periodically instead of on every orphan added to the pool.
@@ -272,6 +574,7 @@ func (mp *TxPool) limitNumOrphans() error {
 		mp.nextExpireScan = now.Add(orphanExpireScanInterval)
 
 		numOrphans := len(mp.orphans)
+		mp.orphanStats.Expired += uint64(origNumOrphans - numOrphans)
 		if numExpired := origNumOrphans - numOrphans; numExpired > 0 {
 			log.Debugf("Expired %d %s (remaining: %d)", numExpired,
 				pickNoun(numExpired, "orphan", "orphans"),
@@ -279,24 +582,30 @@ func (mp *TxPool) limitNumOrphans() error {
 		}
 	}
 
+	// Make room within the quota of the tag first.  That also keeps the
+	// pool within its limit since the number of orphans doesn't change.
+	maxPerTag := mp.cfg.Policy.MaxOrphanTxsPerTag
+	if maxPerTag <= 0 {
+		maxPerTag = DefaultMaxOrphanTxsPerTag
+	}
+	if tagOrphans := mp.orphansByTag[tag]; len(tagOrphans) >= maxPerTag {
+		mp.evictOldestOrphan(tagOrphans)
+		return nil
+	}
+
 	// Nothing to do if adding another orphan will not cause the pool to
 	// exceed the limit.
 	if len(mp.orphans)+1 <= mp.cfg.Policy.MaxOrphanTxs {
 		return nil
 	}
 
-	// Remove a random entry from the map.  For most compilers, Go's
-	// range statement iterates starting at a random item although
-	// that is not 100% guaranteed by the spec.  The iteration order
-	// is not important here because an adversary would have to be
-	// able to pull off preimage attacks on the hashing function in
-	// order to target eviction of specific entries anyways.
-	for _, otx := range mp.orphans {
-		// Don't remove redeemers in the case of a random eviction since
-		// it is quite possible it might be needed again shortly.
-		mp.removeOrphan(otx.tx, false)
-		break
+	var largest map[chainhash.Hash]*orphanTx
+	for _, tagOrphans := range mp.orphansByTag {
+		if len(tagOrphans) > len(largest) {
+			largest = tagOrphans
+		}
 	}
+	mp.evictOldestOrphan(largest)
 
 	return nil
 }
@@ -311,15 +620,23 @@ func (mp *TxPool) addOrphan(tx *ulordutil.Tx, tag Tag) {
 	}
 
 	// Limit the number orphan transactions to prevent memory exhaustion.
-	// This will periodically remove any expired orphans and evict a random
+	// This will periodically remove any expired orphans and evict an
 	// orphan if space is still needed.
-	mp.limitNumOrphans()
+	mp.limitNumOrphans(tag)
 
-	mp.orphans[*tx.Hash()] = &orphanTx{
+	mp.orphanSeq++
+	otx := &orphanTx{
 		tx:         tx,
 		tag:        tag,
 		expiration: time.Now().Add(orphanTTL),
+		seq:        mp.orphanSeq,
 	}
+	mp.orphans[*tx.Hash()] = otx
+	if _, exists := mp.orphansByTag[tag]; !exists {
+		mp.orphansByTag[tag] = make(map[chainhash.Hash]*orphanTx)
+	}
+	mp.orphansByTag[tag][*tx.Hash()] = otx
+	mp.orphanStats.Added++
 	for _, txIn := range tx.MsgTx().TxIn {
 		if _, exists := mp.orphansByPrev[txIn.PreviousOutPoint]; !exists {
 			mp.orphansByPrev[txIn.PreviousOutPoint] =
@@ -351,7 +668,8 @@ func (mp *TxPool) maybeAddOrphan(tx *ulordutil.Tx, tag Tag) error {
 		str := fmt.Sprintf("orphan transaction size of %d bytes is "+
 			"larger than max allowed size of %d bytes",
 			serializedLen, mp.cfg.Policy.MaxOrphanTxSize)
-		return txRuleError(wire.RejectNonstandard, str)
+		return txRuleError(wire.RejectNonstandard,
+			RejectReasonOrphanTooLarge, str)
 	}
 
 	// Add the orphan if the none of the above disqualified it.
@@ -381,24 +699,16 @@ func (mp *TxPool) removeOrphanDoubleSpends(tx *ulordutil.Tx) {
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) isTransactionInPool(hash *chainhash.Hash) bool {
-	if _, exists := mp.pool[*hash]; exists {
-		return true
-	}
-
-	return false
+	return mp.pool.Get(*hash) != nil
 }
 
 // IsTransactionInPool returns whether or not the passed transaction already
 // exists in the main pool.
 //
-// This function is safe for concurrent access.
+// This function is safe for concurrent access.  It does not acquire the
+// mempool lock, so it does not wait for transactions being validated.
 func (mp *TxPool) IsTransactionInPool(hash *chainhash.Hash) bool {
-	// Protect concurrent access.
-	mp.mtx.RLock()
-	inPool := mp.isTransactionInPool(hash)
-	mp.mtx.RUnlock()
-
-	return inPool
+	return mp.isTransactionInPool(hash)
 }
 
 // isOrphanInPool returns whether or not the passed transaction already exists
@@ -464,18 +774,41 @@ func (mp *TxPool) removeTransaction(tx *ulordutil.Tx, removeRedeemers bool) {
 	}
 
 	// Remove the transaction if needed.
-	if txDesc, exists := mp.pool[*txHash]; exists {
+	if txDesc := mp.pool.Get(*txHash); txDesc != nil {
+		// Remove the transaction from the package statistics of its
+		// ancestors and any descendants which remain in the pool.
+		ancestors := make(map[chainhash.Hash]*TxDesc)
+		mp.txAncestors(tx, ancestors)
+		for _, ancestor := range ancestors {
+			ancestor.DescendantCount--
+			ancestor.DescendantSize -= txDesc.size()
+			ancestor.DescendantFees -= txDesc.Fee
+		}
+		descendants := make(map[chainhash.Hash]*ulordutil.Tx)
+		mp.txDescendants(tx, descendants)
+		for hash := range descendants {
+			descendant := mp.pool.Get(hash)
+			descendant.AncestorCount--
+			descendant.AncestorSize -= txDesc.size()
+			descendant.AncestorFees -= txDesc.Fee
+		}
+		mp.poolSize -= txDesc.size()
+
 		// Remove unconfirmed address index entries associated with the
 		// transaction if enabled.
 		if mp.cfg.AddrIndex != nil {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
+		if mp.addrIndex != nil {
+			mp.addrIndex.removeTx(txDesc.Tx)
+		}
 
 		// Mark the referenced outpoints as unspent by the pool.
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
-		delete(mp.pool, *txHash)
+		mp.pool.Delete(*txHash)
+		mp.generation++
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 	}
 }
@@ -530,9 +863,32 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *ulordut
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
+		Locked:           mp.lockedBySource(tx),
+	}
+
+	// Account for the transaction in the package statistics of itself and
+	// its ancestors.  A new transaction has no descendants in the pool.
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(tx, ancestors)
+	txD.AncestorCount = 1
+	txD.AncestorSize = txD.size()
+	txD.AncestorFees = fee
+	for _, ancestor := range ancestors {
+		txD.AncestorCount++
+		txD.AncestorSize += ancestor.size()
+		txD.AncestorFees += ancestor.Fee
+
+		ancestor.DescendantCount++
+		ancestor.DescendantSize += txD.size()
+		ancestor.DescendantFees += fee
 	}
+	txD.DescendantCount = 1
+	txD.DescendantSize = txD.size()
+	txD.DescendantFees = fee
 
-	mp.pool[*tx.Hash()] = txD
+	mp.pool.Set(txD)
+	mp.generation++
+	mp.poolSize += txD.size()
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
@@ -543,6 +899,9 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *ulordut
 	if mp.cfg.AddrIndex != nil {
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
+	if mp.addrIndex != nil {
+		mp.addrIndex.addTx(tx, utxoView)
+	}
 
 	// Record this tx for fee estimation if enabled.
 	if mp.cfg.FeeEstimator != nil {
@@ -554,23 +913,454 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *ulordut
 
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
-// Note it does not check for double spends against transactions already in the
+// Spending them is allowed when every transaction spending them signals
+// replaceability per BIP0125 and replacements are not rejected by policy, in
+// which case true is returned to indicate the transaction is a potential
+// replacement which must be further validated with validateReplacement.  Note
+// it does not check for double spends against transactions already in the
 // main chain.
 //
 // This function MUST be called with the mempool lock held (for reads).
-func (mp *TxPool) checkPoolDoubleSpend(tx *ulordutil.Tx) error {
+func (mp *TxPool) checkPoolDoubleSpend(tx *ulordutil.Tx) (bool, error) {
+	var isReplacement bool
 	for _, txIn := range tx.MsgTx().TxIn {
-		if txR, exists := mp.outpoints[txIn.PreviousOutPoint]; exists {
+		txR, exists := mp.outpoints[txIn.PreviousOutPoint]
+		if !exists {
+			continue
+		}
+
+		if mp.cfg.Policy.RejectReplacement ||
+			!mp.signalsReplacement(txR, nil) {
+
 			str := fmt.Sprintf("output %v already spent by "+
 				"transaction %v in the memory pool",
 				txIn.PreviousOutPoint, txR.Hash())
-			return txRuleError(wire.RejectDuplicate, str)
+			return false, txRuleError(wire.RejectDuplicate,
+				RejectReasonMempoolConflict, str)
+		}
+		isReplacement = true
+	}
+
+	return isReplacement, nil
+}
+
+// signalsReplacement returns whether the passed transaction signals that it
+// can be replaced per BIP0125, either explicitly through the sequence number
+// of one of its inputs or by inheriting replaceability from an unconfirmed
+// ancestor in the pool.  The cache tracks the ancestors already found not to
+// signal replaceability and may be nil.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) signalsReplacement(tx *ulordutil.Tx,
+	cache map[chainhash.Hash]struct{}) bool {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		if txIn.Sequence <= MaxRBFSequence {
+			return true
+		}
+	}
+
+	if cache == nil {
+		cache = make(map[chainhash.Hash]struct{})
+	}
+	cache[*tx.Hash()] = struct{}{}
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, visited := cache[parentHash]; visited {
+			continue
+		}
+		parent := mp.pool.Get(parentHash)
+		if parent == nil {
+			continue
+		}
+		if mp.signalsReplacement(parent.Tx, cache) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// size returns the virtual size of the transaction described by the entry.
+func (txD *TxDesc) size() int64 {
+	return GetTxVirtualSize(txD.Tx)
+}
+
+// txAncestors adds all transactions in the pool whose outputs the passed
+// transaction spends, directly or indirectly, to the ancestors map.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txAncestors(tx *ulordutil.Tx,
+	ancestors map[chainhash.Hash]*TxDesc) {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, seen := ancestors[parentHash]; seen {
+			continue
+		}
+		parent := mp.pool.Get(parentHash)
+		if parent == nil {
+			continue
+		}
+		ancestors[parentHash] = parent
+		mp.txAncestors(parent.Tx, ancestors)
+	}
+}
+
+// checkPackageLimits ensures adding the passed transaction with the given
+// virtual size to the pool would not create a chain of unconfirmed
+// transactions exceeding the ancestor and descendant limits of the policy.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *ulordutil.Tx, txSize int64) error {
+	policy := &mp.cfg.Policy
+	maxAncestorCount := policy.MaxAncestorCount
+	if maxAncestorCount <= 0 {
+		maxAncestorCount = DefaultMaxAncestorCount
+	}
+	maxAncestorSize := policy.MaxAncestorSize
+	if maxAncestorSize <= 0 {
+		maxAncestorSize = DefaultMaxAncestorSize
+	}
+	maxDescendantCount := policy.MaxDescendantCount
+	if maxDescendantCount <= 0 {
+		maxDescendantCount = DefaultMaxDescendantCount
+	}
+	maxDescendantSize := policy.MaxDescendantSize
+	if maxDescendantSize <= 0 {
+		maxDescendantSize = DefaultMaxDescendantSize
+	}
+
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(tx, ancestors)
+	ancestorCount := len(ancestors) + 1
+	ancestorSize := txSize
+	for _, ancestor := range ancestors {
+		ancestorSize += ancestor.size()
+	}
+	if ancestorCount > maxAncestorCount {
+		str := fmt.Sprintf("transaction %v has too many unconfirmed "+
+			"ancestors: max is %v, has %v", tx.Hash(),
+			maxAncestorCount, ancestorCount)
+		return txRuleError(wire.RejectNonstandard,
+			RejectReasonTooLongMempoolChain, str)
+	}
+	if ancestorSize > maxAncestorSize {
+		str := fmt.Sprintf("transaction %v and its unconfirmed "+
+			"ancestors are too large: max is %v, has %v", tx.Hash(),
+			maxAncestorSize, ancestorSize)
+		return txRuleError(wire.RejectNonstandard,
+			RejectReasonTooLongMempoolChain, str)
+	}
+
+	for hash, ancestor := range ancestors {
+		if ancestor.DescendantCount+1 > maxDescendantCount {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"descendant count limit of ancestor %v: max is "+
+				"%v", tx.Hash(), hash, maxDescendantCount)
+			return txRuleError(wire.RejectNonstandard,
+				RejectReasonTooLongMempoolChain, str)
+		}
+		if ancestor.DescendantSize+txSize > maxDescendantSize {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"descendant size limit of ancestor %v: max is "+
+				"%v", tx.Hash(), hash, maxDescendantSize)
+			return txRuleError(wire.RejectNonstandard,
+				RejectReasonTooLongMempoolChain, str)
 		}
 	}
 
 	return nil
 }
 
+// txDescendants adds all transactions in the pool which spend outputs of the
+// passed transaction, directly or indirectly, to the descendants map.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txDescendants(tx *ulordutil.Tx,
+	descendants map[chainhash.Hash]*ulordutil.Tx) {
+
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		txR, exists := mp.outpoints[prevOut]
+		if !exists {
+			continue
+		}
+		if _, seen := descendants[*txR.Hash()]; seen {
+			continue
+		}
+		descendants[*txR.Hash()] = txR
+		mp.txDescendants(txR, descendants)
+	}
+}
+
+// validateReplacement checks whether the passed transaction, which double
+// spends transactions in the pool that signal replaceability, satisfies the
+// replacement rules of BIP0125 given its fee.  On success, the transactions it
+// would evict, which are the transactions it conflicts with along with all of
+// their descendants, are returned.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) validateReplacement(tx *ulordutil.Tx,
+	txFee int64) (map[chainhash.Hash]*ulordutil.Tx, error) {
+
+	txHash := tx.Hash()
+
+	// Gather the transactions the replacement directly conflicts with
+	// along with the parents they spend from the pool.
+	conflicts := make(map[chainhash.Hash]*ulordutil.Tx)
+	for _, txIn := range tx.MsgTx().TxIn {
+		if txR, exists := mp.outpoints[txIn.PreviousOutPoint]; exists {
+			conflicts[*txR.Hash()] = txR
+		}
+	}
+	conflictParents := make(map[chainhash.Hash]struct{})
+	for _, conflict := range conflicts {
+		for _, txIn := range conflict.MsgTx().TxIn {
+			parentHash := txIn.PreviousOutPoint.Hash
+			if mp.pool.Get(parentHash) != nil {
+				conflictParents[parentHash] = struct{}{}
+			}
+		}
+	}
+
+	// Limit the number of transactions a single replacement may evict to
+	// bound the work it causes and prevent it from being used to flush
+	// large parts of the pool.
+	evicted := make(map[chainhash.Hash]*ulordutil.Tx)
+	for hash, conflict := range conflicts {
+		evicted[hash] = conflict
+		mp.txDescendants(conflict, evicted)
+	}
+	for hash := range evicted {
+		if mp.pool.Get(hash).Locked {
+			str := fmt.Sprintf("replacement transaction %v evicts "+
+				"transaction %v which is locked", txHash, hash)
+			return nil, txRuleError(wire.RejectDuplicate,
+				RejectReasonTxLocked, str)
+		}
+	}
+	maxEvictions := mp.cfg.Policy.MaxReplacementEvictions
+	if maxEvictions <= 0 {
+		maxEvictions = DefaultMaxReplacementEvictions
+	}
+	if len(evicted) > maxEvictions {
+		str := fmt.Sprintf("replacement transaction %v evicts more "+
+			"transactions than permitted: max is %v, evicts %v",
+			txHash, maxEvictions, len(evicted))
+		return nil, txRuleError(wire.RejectNonstandard,
+			RejectReasonTooManyReplacements, str)
+	}
+
+	// The replacement may not spend outputs of the transactions it evicts
+	// and may only include unconfirmed inputs which were already spent
+	// from by one of the transactions it conflicts with.
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, exists := evicted[parentHash]; exists {
+			str := fmt.Sprintf("replacement transaction %v spends "+
+				"transaction %v which it replaces", txHash,
+				parentHash)
+			return nil, txRuleError(wire.RejectInvalid,
+				RejectReasonReplacementConflict, str)
+		}
+		if mp.pool.Get(parentHash) == nil {
+			continue
+		}
+		if _, exists := conflictParents[parentHash]; !exists {
+			str := fmt.Sprintf("replacement transaction %v spends "+
+				"new unconfirmed input %v", txHash,
+				txIn.PreviousOutPoint)
+			return nil, txRuleError(wire.RejectNonstandard,
+				RejectReasonReplacementUnconfirmed, str)
+		}
+	}
+
+	// The replacement must pay a higher fee rate than each of the
+	// transactions it directly conflicts with so that miners prefer it.
+	txSize := GetTxVirtualSize(tx)
+	txFeePerKB := txFee * 1000 / txSize
+	for hash := range conflicts {
+		conflictFeePerKB := mp.pool.Get(hash).FeePerKB
+		if txFeePerKB <= conflictFeePerKB {
+			str := fmt.Sprintf("replacement transaction %v has an "+
+				"insufficient fee rate: needs more than %v, has %v",
+				txHash, conflictFeePerKB, txFeePerKB)
+			return nil, txRuleError(wire.RejectInsufficientFee,
+				wire.RejectReasonInsufficientFee, str)
+		}
+	}
+
+	// The replacement must pay at least the total fees of all of the
+	// transactions it evicts, and the additional fee must pay for its own
+	// relay at the minimum relay fee rate.
+	var evictedFees int64
+	for hash := range evicted {
+		evictedFees += mp.pool.Get(hash).Fee
+	}
+	if txFee < evictedFees {
+		str := fmt.Sprintf("replacement transaction %v has an "+
+			"insufficient absolute fee: needs %v, has %v", txHash,
+			evictedFees, txFee)
+		return nil, txRuleError(wire.RejectInsufficientFee,
+			wire.RejectReasonInsufficientFee, str)
+	}
+	minFee := calcMinRequiredTxRelayFee(txSize,
+		mp.cfg.Policy.MinRelayTxFee)
+	if txFee-evictedFees < minFee {
+		str := fmt.Sprintf("replacement transaction %v has an "+
+			"insufficient fee delta: needs %v, has %v", txHash,
+			minFee, txFee-evictedFees)
+		return nil, txRuleError(wire.RejectInsufficientFee,
+			wire.RejectReasonInsufficientFee, str)
+	}
+
+	return evicted, nil
+}
+
+// evictTransaction removes the passed transaction along with all of its
+// descendants from the pool and reports each of them as evicted for the
+// passed reason.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) evictTransaction(tx *ulordutil.Tx, reason EvictionReason) {
+	evicted := make(map[chainhash.Hash]*ulordutil.Tx)
+	mp.txDescendants(tx, evicted)
+	evicted[*tx.Hash()] = tx
+	mp.removeTransaction(tx, true)
+
+	log.Debugf("Evicted transaction %v and %d descendants (%v)", tx.Hash(),
+		len(evicted)-1, reason)
+
+	if mp.cfg.TxEvicted != nil {
+		for _, evictedTx := range evicted {
+			mp.cfg.TxEvicted(evictedTx, reason)
+		}
+	}
+}
+
+// expireTransactions evicts all transactions which have been in the pool for
+// longer than the expiry set by policy, along with their descendants.  The
+// pool is only scanned once per txExpireScanInterval.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) expireTransactions(now time.Time) {
+	if now.Before(mp.nextTxExpireScan) {
+		return
+	}
+	mp.nextTxExpireScan = now.Add(txExpireScanInterval)
+
+	expiry := mp.cfg.Policy.TxExpiry
+	if expiry <= 0 {
+		expiry = DefaultTxExpiry
+	}
+	cutoff := now.Add(-expiry)
+	for _, txD := range mp.pool.Descs() {
+		// Descendants of expired transactions which are evicted
+		// along with them are skipped.
+		if txD.Added.Before(cutoff) && mp.isTransactionInPool(txD.Tx.Hash()) {
+			mp.evictTransaction(txD.Tx, EvictionExpired)
+		}
+	}
+}
+
+// limitPoolSize evicts the transactions whose packages with their descendants
+// pay the lowest fee rates until the pool is within its maximum size.  The
+// rolling minimum fee rate is raised above the rate of every evicted package
+// by the minimum relay fee so the space they free up is not immediately taken
+// by transactions paying no more than they did.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitPoolSize() {
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPoolSize
+	}
+	for mp.poolSize > maxSize {
+		var worst *TxDesc
+		var worstFeePerKB int64
+		for _, txD := range mp.pool.Descs() {
+			feePerKB := txD.DescendantFees * 1000 / txD.DescendantSize
+			if worst == nil || feePerKB < worstFeePerKB {
+				worst = txD
+				worstFeePerKB = feePerKB
+			}
+		}
+
+		// Apply any pending decay before raising the rolling minimum
+		// fee rate.
+		mp.minFeeRate()
+		newMinFee := float64(worstFeePerKB +
+			int64(mp.cfg.Policy.MinRelayTxFee))
+		if newMinFee > mp.rollingMinFee {
+			mp.rollingMinFee = newMinFee
+			mp.lastRollingFeeUpdate = time.Now()
+			log.Debugf("Raised mempool minimum fee rate to %v "+
+				"satoshi/kB", int64(newMinFee))
+		}
+
+		mp.evictTransaction(worst.Tx, EvictionSizeLimit)
+	}
+}
+
+// minFeeRate returns the minimum fee rate in satoshi/kB a new transaction must
+// pay to be accepted into the pool.  It is the greater of the minimum relay
+// fee and the rolling minimum fee rate raised by limitPoolSize, which decays
+// with a half-life of rollingFeeHalfLife, or a quarter or half of it while the
+// pool is less than a quarter or half full, respectively.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) minFeeRate() ulordutil.Amount {
+	minRelayTxFee := mp.cfg.Policy.MinRelayTxFee
+	if mp.rollingMinFee == 0 {
+		return minRelayTxFee
+	}
+
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPoolSize
+	}
+	halfLife := rollingFeeHalfLife
+	switch {
+	case mp.poolSize < maxSize/4:
+		halfLife /= 4
+	case mp.poolSize < maxSize/2:
+		halfLife /= 2
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(mp.lastRollingFeeUpdate)
+	mp.rollingMinFee /= math.Pow(2, float64(elapsed)/float64(halfLife))
+	mp.lastRollingFeeUpdate = now
+
+	// Stop requiring more than the minimum relay fee once the rolling
+	// minimum has decayed well below it.
+	if mp.rollingMinFee < float64(minRelayTxFee)/2 {
+		mp.rollingMinFee = 0
+		return minRelayTxFee
+	}
+	rollingMinFee := ulordutil.Amount(mp.rollingMinFee)
+	if rollingMinFee > minRelayTxFee {
+		return rollingMinFee
+	}
+	return minRelayTxFee
+}
+
+// MinFeeRate returns the minimum fee rate in satoshi/kB a new transaction must
+// currently pay to be accepted into the pool.  It only exceeds the minimum
+// relay fee set by policy while transactions are being evicted to keep the
+// pool within its maximum size.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeRate() ulordutil.Amount {
+	mp.mtx.Lock()
+	minFeeRate := mp.minFeeRate()
+	mp.mtx.Unlock()
+
+	return minFeeRate
+}
+
 // CheckSpend checks whether the passed outpoint is already spent by a
 // transaction in the mempool. If that's the case the spending transaction will
 // be returned, if not nil will be returned.
@@ -602,7 +1392,7 @@ func (mp *TxPool) fetchInputUtxos(tx *ulordutil.Tx) (*blockchain.UtxoViewpoint,
 			continue
 		}
 
-		if poolTxDesc, exists := mp.pool[prevOut.Hash]; exists {
+		if poolTxDesc := mp.pool.Get(prevOut.Hash); poolTxDesc != nil {
 			// AddTxOut ignores out of range index values, so it is
 			// safe to call without bounds checking here.
 			utxoView.AddTxOut(poolTxDesc.Tx, prevOut.Index,
@@ -619,30 +1409,60 @@ func (mp *TxPool) fetchInputUtxos(tx *ulordutil.Tx) (*blockchain.UtxoViewpoint,
 //
 // This function is safe for concurrent access.
 func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*ulordutil.Tx, error) {
-	// Protect concurrent access.
-	mp.mtx.RLock()
-	txDesc, exists := mp.pool[*txHash]
-	mp.mtx.RUnlock()
-
-	if exists {
+	// The pool map protects concurrent access itself.
+	if txDesc := mp.pool.Get(*txHash); txDesc != nil {
 		return txDesc.Tx, nil
 	}
 
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
-// maybeAcceptTransaction is the internal function which implements the public
-// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// validationStale returns whether the passed result of validateTransaction may
+// no longer hold because transactions were added to or removed from the pool
+// since the pool had the passed generation, or the best chain changed.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) validationStale(v *txValidation, generation uint64) bool {
+	if mp.generation != generation || mp.cfg.BestHeight() != v.bestHeight {
+		return true
+	}
+	return mp.cfg.BestHash != nil && mp.cfg.BestHash() != v.bestHash
+}
+
+// txValidation houses the details of a transaction found acceptable by
+// validateTransaction which are needed to add it to the pool.
+type txValidation struct {
+	utxoView   *blockchain.UtxoViewpoint
+	bestHeight int32
+	bestHash   chainhash.Hash
+	fee        int64
+	size       int64
+
+	// evicted holds the transactions the transaction replaces.
+	evicted map[chainhash.Hash]*ulordutil.Tx
+}
+
+// validateTransaction runs all checks maybeAcceptTransaction performs before
+// adding a transaction to the pool, except for verifying its scripts, without
+// modifying the pool.  When the transaction is an orphan, its missing parents
+// are returned instead.  Note the free transaction rate limiter is updated
+// when rateLimit is set.
+//
+// Transactions from trusted sources bypass the standardness and fee policy,
+// but not the consensus rules.
+//
+// The checks performed are recorded by the passed recorder, which may be nil.
+// The caller must end the last check with the returned error.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) validateTransaction(tx *ulordutil.Tx, isNew, rateLimit, rejectDupOrphans, trusted bool, checks *checkRecorder) ([]*chainhash.Hash, *txValidation, error) {
 	txHash := tx.Hash()
 
 	// If a transaction has iwtness data, and segwit isn't active yet, If
 	// segwit isn't active yet, then we won't accept it into the mempool as
 	// it can't be mined yet.
 	if tx.MsgTx().HasWitness() {
+		checks.begin(CheckWitness)
 		segwitActive, err := mp.cfg.IsDeploymentActive(chaincfg.DeploymentSegwit)
 		if err != nil {
 			return nil, nil, err
@@ -651,7 +1471,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 		if !segwitActive {
 			str := fmt.Sprintf("transaction %v has witness data, "+
 				"but segwit isn't active yet", txHash)
-			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+			return nil, nil, txRuleError(wire.RejectNonstandard,
+				RejectReasonNoWitnessYet, str)
 		}
 	}
 
@@ -659,16 +1480,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// applies to orphan transactions as well when the reject duplicate
 	// orphans flag is set.  This check is intended to be a quick check to
 	// weed out duplicates.
+	checks.begin(CheckDuplicate)
 	if mp.isTransactionInPool(txHash) || (rejectDupOrphans &&
 		mp.isOrphanInPool(txHash)) {
 
 		str := fmt.Sprintf("already have transaction %v", txHash)
-		return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, nil, txRuleError(wire.RejectDuplicate,
+			RejectReasonAlreadyKnown, str)
 	}
 
 	// Perform preliminary sanity checks on the transaction.  This makes
 	// use of blockchain which contains the invariant rules for what
 	// transactions are allowed into blocks.
+	checks.begin(CheckSanity)
 	err := blockchain.CheckTransactionSanity(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
@@ -678,10 +1502,12 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	}
 
 	// A standalone transaction must not be a coinbase transaction.
+	checks.begin(CheckCoinbase)
 	if blockchain.IsCoinBase(tx) {
 		str := fmt.Sprintf("transaction %v is an individual coinbase",
 			txHash)
-		return nil, nil, txRuleError(wire.RejectInvalid, str)
+		return nil, nil, txRuleError(wire.RejectInvalid,
+			RejectReasonCoinbase, str)
 	}
 
 	// Get the current height of the main chain.  A standalone transaction
@@ -689,26 +1515,32 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// one more than the current height.
 	bestHeight := mp.cfg.BestHeight()
 	nextBlockHeight := bestHeight + 1
+	var bestHash chainhash.Hash
+	if mp.cfg.BestHash != nil {
+		bestHash = mp.cfg.BestHash()
+	}
 
 	medianTimePast := mp.cfg.MedianTimePast()
 
 	// Don't allow non-standard transactions if the network parameters
 	// forbid their acceptance.
-	if !mp.cfg.Policy.AcceptNonStd {
+	if !mp.cfg.Policy.AcceptNonStd && !trusted {
+		checks.begin(CheckStandard)
 		err = checkTransactionStandard(tx, nextBlockHeight,
 			medianTimePast, mp.cfg.Policy.MinRelayTxFee,
-			mp.cfg.Policy.MaxTxVersion)
+			mp.cfg.Policy.MaxTxVersion, mp.cfg.Policy.Standardness)
 		if err != nil {
-			// Attempt to extract a reject code from the error so
-			// it can be retained.  When not possible, fall back to
-			// a non standard error.
-			rejectCode, found := extractRejectCode(err)
+			// Attempt to extract a reject code and reason from the
+			// error so they can be retained.  When not possible,
+			// fall back to a non standard error.
+			rejectCode, reason, found := extractRejectReason(err)
 			if !found {
 				rejectCode = wire.RejectNonstandard
+				reason = wire.RejectReasonNonstandard
 			}
 			str := fmt.Sprintf("transaction %v is not standard: %v",
 				txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, nil, txRuleError(rejectCode, reason, str)
 		}
 	}
 
@@ -720,15 +1552,26 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// at this point.  There is a more in-depth check that happens later
 	// after fetching the referenced transaction inputs from the main chain
 	// which examines the actual spend data and prevents double spends.
-	err = mp.checkPoolDoubleSpend(tx)
+	checks.begin(CheckPoolDoubleSpend)
+	isReplacement, err := mp.checkPoolDoubleSpend(tx)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Don't allow the transaction to spend outputs locked to another
+	// transaction by a masternode quorum.
+	if mp.cfg.TxLocks != nil {
+		checks.begin(CheckTxLocks)
+		if err := mp.checkTxLocks(tx); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Fetch all of the unspent transaction outputs referenced by the inputs
 	// to this transaction.  This function also attempts to fetch the
 	// transaction itself to be used for detecting a duplicate transaction
 	// without needing to do a separate lookup.
+	checks.begin(CheckInputsAvailable)
 	utxoView, err := mp.fetchInputUtxos(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
@@ -745,6 +1588,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 		entry := utxoView.LookupEntry(prevOut)
 		if entry != nil && !entry.IsSpent() {
 			return nil, nil, txRuleError(wire.RejectDuplicate,
+				RejectReasonAlreadyExists,
 				"transaction already exists")
 		}
 		utxoView.RemoveEntry(prevOut)
@@ -772,6 +1616,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// Don't allow the transaction into the mempool unless its sequence
 	// lock is active, meaning that it'll be allowed into the next block
 	// with respect to its defined relative lock times.
+	checks.begin(CheckSequenceLocks)
 	sequenceLock, err := mp.cfg.CalcSequenceLock(tx, utxoView)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
@@ -782,6 +1627,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	if !blockchain.SequenceLockActive(sequenceLock, nextBlockHeight,
 		medianTimePast) {
 		return nil, nil, txRuleError(wire.RejectNonstandard,
+			RejectReasonNonBIP68Final,
 			"transaction's sequence locks on inputs not met")
 	}
 
@@ -789,6 +1635,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// rules in blockchain for what transactions are allowed into blocks.
 	// Also returns the fees associated with the transaction which will be
 	// used later.
+	checks.begin(CheckInputs)
 	txFee, err := blockchain.CheckTransactionInputs(tx, nextBlockHeight,
 		utxoView, mp.cfg.ChainParams)
 	if err != nil {
@@ -800,19 +1647,22 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 
 	// Don't allow transactions with non-standard inputs if the network
 	// parameters forbid their acceptance.
-	if !mp.cfg.Policy.AcceptNonStd {
-		err := checkInputsStandard(tx, utxoView)
+	if !mp.cfg.Policy.AcceptNonStd && !trusted {
+		checks.begin(CheckInputsStandard)
+		err := checkInputsStandard(tx, utxoView,
+			mp.cfg.Policy.Standardness)
 		if err != nil {
-			// Attempt to extract a reject code from the error so
-			// it can be retained.  When not possible, fall back to
-			// a non standard error.
-			rejectCode, found := extractRejectCode(err)
+			// Attempt to extract a reject code and reason from the
+			// error so they can be retained.  When not possible,
+			// fall back to a non standard error.
+			rejectCode, reason, found := extractRejectReason(err)
 			if !found {
 				rejectCode = wire.RejectNonstandard
+				reason = RejectReasonNonstandardInputs
 			}
 			str := fmt.Sprintf("transaction %v has a non-standard "+
 				"input: %v", txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, nil, txRuleError(rejectCode, reason, str)
 		}
 	}
 
@@ -826,6 +1676,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// maximum allowed signature operations per transaction is less than
 	// the maximum allowed signature operations per block.
 	// TODO(roasbeef): last bool should be conditional on segwit activation
+	checks.begin(CheckSigOpCost)
 	sigOpCost, err := blockchain.GetSigOpCost(tx, false, utxoView, true, true)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
@@ -836,7 +1687,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	if sigOpCost > mp.cfg.Policy.MaxSigOpCostPerTx {
 		str := fmt.Sprintf("transaction %v sigop cost is too high: %d > %d",
 			txHash, sigOpCost, mp.cfg.Policy.MaxSigOpCostPerTx)
-		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		return nil, nil, txRuleError(wire.RejectNonstandard,
+			RejectReasonTooManySigOps, str)
 	}
 
 	// Don't allow transactions with fees too low to get into a mined block.
@@ -850,14 +1702,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 	// which is more desirable.  Therefore, as long as the size of the
 	// transaction does not exceeed 1000 less than the reserved space for
 	// high-priority transactions, don't require a fee for it.
+	checks.begin(CheckFees)
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
+	if trusted {
+		minFee = 0
+	}
 	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee,
 			minFee)
-		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee,
+			RejectReasonMinRelayFee, str)
 	}
 
 	// Require that free transactions have sufficient priority to be mined
@@ -871,7 +1728,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 			str := fmt.Sprintf("transaction %v has insufficient "+
 				"priority (%g <= %g)", txHash,
 				currentPriority, mining.MinHighPriority)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee,
+				RejectReasonInsufficientPriority, str)
 		}
 	}
 
@@ -889,7 +1747,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 		if mp.pennyTotal >= mp.cfg.Policy.FreeTxRelayLimit*10*1000 {
 			str := fmt.Sprintf("transaction %v has been rejected "+
 				"by the rate limiter due to low fees", txHash)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee,
+				RejectReasonRateLimited, str)
 		}
 		oldTotal := mp.pennyTotal
 
@@ -899,25 +1758,152 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 			mp.cfg.Policy.FreeTxRelayLimit*10*1000)
 	}
 
-	// Verify crypto signatures for each input and reject the transaction if
-	// any don't verify.
-	err = blockchain.ValidateTransactionScripts(tx, utxoView,
-		txscript.StandardVerifyFlags, mp.cfg.SigCache,
+	// Require new transactions to pay the minimum fee rate raised by
+	// evicting transactions when the pool is full.  Unlike the minimum
+	// relay fee, priority does not exempt transactions from it.
+	if minFeeRate := mp.minFeeRate(); isNew && !trusted &&
+		minFeeRate > mp.cfg.Policy.MinRelayTxFee {
+
+		poolMinFee := calcMinRequiredTxRelayFee(serializedSize,
+			minFeeRate)
+		if txFee < poolMinFee {
+			str := fmt.Sprintf("transaction %v has %d fees which is "+
+				"under the mempool minimum fee of %d", txHash,
+				txFee, poolMinFee)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee,
+				RejectReasonMempoolMinFee, str)
+		}
+	}
+
+	// Don't allow transactions which would create overly long or large
+	// chains of unconfirmed transactions since they are expensive to track
+	// and make it harder to build block templates.
+	checks.begin(CheckPackageLimits)
+	err = mp.checkPackageLimits(tx, serializedSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure a transaction replacing transactions in the pool satisfies
+	// the replacement rules and determine which transactions it evicts.
+	var evicted map[chainhash.Hash]*ulordutil.Tx
+	if isReplacement {
+		checks.begin(CheckReplacement)
+		evicted, err = mp.validateReplacement(tx, txFee)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return nil, &txValidation{
+		utxoView:   utxoView,
+		bestHeight: bestHeight,
+		bestHash:   bestHash,
+		fee:        txFee,
+		size:       serializedSize,
+		evicted:    evicted,
+	}, nil
+}
+
+// validateScripts verifies the signatures of all inputs of the passed
+// transaction against the outputs they spend in the passed view.  The inputs
+// are verified in parallel by the script validation worker pool of the
+// blockchain package.
+//
+// This function does not access the pool, so it is safe to call without the
+// mempool lock held.
+func (mp *TxPool) validateScripts(tx *ulordutil.Tx, utxoView *blockchain.UtxoViewpoint) error {
+	err := blockchain.ValidateTransactionScripts(tx, utxoView,
+		mp.cfg.Policy.Standardness.VerifyFlags, mp.cfg.SigCache,
 		mp.cfg.HashCache)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return chainRuleError(cerr)
 		}
+		return err
+	}
+
+	return nil
+}
+
+// maybeAcceptTransaction is the internal function which implements the public
+// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
+// more details.
+//
+// The checks performed are recorded by the passed recorder, which may be nil.
+// The caller must end the last check with the returned error.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rejectDupOrphans, trusted bool, checks *checkRecorder) ([]*chainhash.Hash, *TxDesc, error) {
+	missingParents, v, err := mp.validateTransaction(tx, isNew, rateLimit,
+		rejectDupOrphans, trusted, checks)
+	if err != nil || len(missingParents) > 0 {
+		return missingParents, nil, err
+	}
+
+	// Verify crypto signatures for each input and reject the transaction if
+	// any don't verify.
+	checks.begin(CheckScripts)
+	if err := mp.validateScripts(tx, v.utxoView); err != nil {
+		return nil, nil, err
+	}
+
+	txD, err := mp.addValidatedTransaction(tx, v, checks)
+	if err != nil {
 		return nil, nil, err
 	}
+	return nil, txD, nil
+}
+
+// addValidatedTransaction adds the passed transaction, which must have been
+// fully validated against the current state of the pool and the chain, to the
+// pool.  The transactions it replaces are removed and the pool is kept within
+// its maximum size afterwards, which evicts the transaction again when it pays
+// the lowest fee rate in the pool, in which case an error is returned.
+//
+// The checks performed are recorded by the passed recorder, which may be nil.
+// The caller must end the last check with the returned error.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addValidatedTransaction(tx *ulordutil.Tx, v *txValidation, checks *checkRecorder) (*TxDesc, error) {
+	txHash := tx.Hash()
+
+	// Remove the transactions being replaced before adding the
+	// replacement so the outpoints they spend are released.
+	checks.begin(CheckPoolSize)
+	evictedTxns := make([]*ulordutil.Tx, 0, len(v.evicted))
+	for _, evictedTx := range v.evicted {
+		mp.removeTransaction(evictedTx, false)
+		evictedTxns = append(evictedTxns, evictedTx)
+	}
 
 	// Add to transaction pool.
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
+	txD := mp.addTransaction(v.utxoView, tx, v.bestHeight, v.fee)
+
+	if len(evictedTxns) > 0 {
+		log.Debugf("Replaced %d transactions with %v", len(evictedTxns),
+			txHash)
+		if mp.cfg.TxReplaced != nil {
+			mp.cfg.TxReplaced(evictedTxns, tx)
+		}
+	}
+
+	// Evict expired transactions and keep the pool within its maximum
+	// size now that it grew.  The new transaction is evicted too when it
+	// pays the lowest fee rate in the pool.
+	mp.expireTransactions(time.Now())
+	mp.limitPoolSize()
+	if !mp.isTransactionInPool(txHash) {
+		str := fmt.Sprintf("transaction %v was evicted because the "+
+			"mempool is full", txHash)
+		return nil, txRuleError(wire.RejectInsufficientFee,
+			RejectReasonMempoolFull, str)
+	}
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
-		len(mp.pool))
+		mp.pool.Len())
 
-	return nil, txD, nil
+	return txD, nil
 }
 
 // MaybeAcceptTransaction is the main workhorse for handling insertion of new
@@ -934,12 +1920,71 @@ func (mp *TxPool) maybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit, rej
 func (mp *TxPool) MaybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit,
+		true, false, nil)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
 }
 
+// AcceptanceInfo describes a transaction found acceptable by CheckAcceptance.
+type AcceptanceInfo struct {
+	// Fee is the fee the transaction pays in satoshi.
+	Fee int64
+
+	// VirtualSize is the virtual size of the transaction.
+	VirtualSize int64
+
+	// Replaces holds the hashes of the transactions in the pool the
+	// transaction would replace, including their descendants.
+	Replaces []*chainhash.Hash
+}
+
+// CheckAcceptance runs the passed transaction through all of the validation
+// and policy checks ProcessTransaction performs without adding it to the pool
+// or otherwise modifying the pool.  It returns the error the transaction would
+// be rejected with, or its fee information when it would be accepted.
+// Transactions which spend outputs of unknown transactions are rejected as
+// orphans.  Since the transaction is not added, it is not checked whether it
+// would be evicted right away to keep the pool within its maximum size.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) CheckAcceptance(tx *ulordutil.Tx) (*AcceptanceInfo, error) {
+	// Protect concurrent access.  The write lock is needed since the
+	// minimum fee rate of the pool decays when it is checked.
+	mp.mtx.Lock()
+	missingParents, v, err := mp.validateTransaction(tx, true, false, true,
+		false, nil)
+	mp.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(missingParents) > 0 {
+		str := fmt.Sprintf("orphan transaction %v references "+
+			"outputs of unknown or fully-spent "+
+			"transaction %v", tx.Hash(), missingParents[0])
+		return nil, txRuleError(wire.RejectDuplicate,
+			RejectReasonMissingInputs, str)
+	}
+
+	// The scripts don't depend on the pool, so they are verified without
+	// holding the lock.
+	if err := mp.validateScripts(tx, v.utxoView); err != nil {
+		return nil, err
+	}
+
+	info := &AcceptanceInfo{
+		Fee:         v.fee,
+		VirtualSize: v.size,
+		Replaces:    make([]*chainhash.Hash, 0, len(v.evicted)),
+	}
+	for hash := range v.evicted {
+		hashCopy := hash
+		info.Replaces = append(info.Replaces, &hashCopy)
+	}
+	return info, nil
+}
+
 // processOrphans is the internal function which implements the public
 // ProcessOrphans.  See the comment for ProcessOrphans for more details.
 //
@@ -947,6 +1992,11 @@ func (mp *TxPool) MaybeAcceptTransaction(tx *ulordutil.Tx, isNew, rateLimit bool
 func (mp *TxPool) processOrphans(acceptedTx *ulordutil.Tx) []*TxDesc {
 	var acceptedTxns []*TxDesc
 
+	// Track the outputs spent by the orphans accepted so far.  Orphans
+	// double spending them can't be accepted anymore and are removed
+	// below.
+	spent := make(map[wire.OutPoint]struct{})
+
 	// Start with processing at least the passed transaction.
 	processList := list.New()
 	processList.PushBack(acceptedTx)
@@ -955,60 +2005,63 @@ func (mp *TxPool) processOrphans(acceptedTx *ulordutil.Tx) []*TxDesc {
 		firstElement := processList.Remove(processList.Front())
 		processItem := firstElement.(*ulordutil.Tx)
 
-		prevOut := wire.OutPoint{Hash: *processItem.Hash()}
-		for txOutIdx := range processItem.MsgTx().TxOut {
-			// Look up all orphans that redeem the output that is
-			// now available.  This will typically only be one, but
-			// it could be multiple if the orphan pool contains
-			// double spends.  While it may seem odd that the orphan
-			// pool would allow this since there can only possibly
-			// ultimately be a single redeemer, it's important to
-			// track it this way to prevent malicious actors from
-			// being able to purposely constructing orphans that
-			// would otherwise make outputs unspendable.
-			//
-			// Skip to the next available output if there are none.
-			prevOut.Index = uint32(txOutIdx)
-			orphans, exists := mp.orphansByPrev[prevOut]
-			if !exists {
+		// Look up all orphans that redeem outputs that are now
+		// available.  There could be multiple redeemers of an output
+		// if the orphan pool contains double spends.  While it may seem
+		// odd that the orphan pool would allow this since there can
+		// only possibly ultimately be a single redeemer, it's
+		// important to track it this way to prevent malicious actors
+		// from being able to purposely constructing orphans that would
+		// otherwise make outputs unspendable.  The orphans are tried in
+		// the order they were received, so the first one received wins.
+		for _, otx := range mp.orphanRedeemers(processItem) {
+			// Skip orphans removed while processing earlier ones
+			// and those which are now double spends.
+			tx := otx.tx
+			if _, exists := mp.orphans[*tx.Hash()]; !exists {
 				continue
 			}
-
-			// Potentially accept an orphan into the tx pool.
-			for _, tx := range orphans {
-				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
-				if err != nil {
-					// The orphan is now invalid, so there
-					// is no way any other orphans which
-					// redeem any of its outputs can be
-					// accepted.  Remove them.
-					mp.removeOrphan(tx, true)
+			var isDoubleSpend bool
+			for _, txIn := range tx.MsgTx().TxIn {
+				if _, ok := spent[txIn.PreviousOutPoint]; ok {
+					isDoubleSpend = true
 					break
 				}
+			}
+			if isDoubleSpend {
+				continue
+			}
 
-				// Transaction is still an orphan.  Try the next
-				// orphan which redeems this output.
-				if len(missing) > 0 {
-					continue
-				}
+			// Potentially accept the orphan into the tx pool.
+			missing, txD, err := mp.maybeAcceptTransaction(tx, true,
+				true, false, mp.isTagTrusted(otx.tag), nil)
+			if err != nil {
+				// The orphan is now invalid, so there is no
+				// way any other orphans which redeem any of its
+				// outputs can be accepted.  Remove them.
+				mp.removeOrphan(tx, true)
+				continue
+			}
+
+			// Transaction is still an orphan.  Try the next
+			// orphan.
+			if len(missing) > 0 {
+				continue
+			}
 
-				// Transaction was accepted into the main pool.
-				//
-				// Add it to the list of accepted transactions
-				// that are no longer orphans, remove it from
-				// the orphan pool, and add it to the list of
-				// transactions to process so any orphans that
-				// depend on it are handled too.
-				acceptedTxns = append(acceptedTxns, txD)
-				mp.removeOrphan(tx, false)
-				processList.PushBack(tx)
-
-				// Only one transaction for this outpoint can be
-				// accepted, so the rest are now double spends
-				// and are removed later.
-				break
+			// Transaction was accepted into the main pool.
+			//
+			// Add it to the list of accepted transactions that are
+			// no longer orphans, remove it from the orphan pool,
+			// and add it to the list of transactions to process so
+			// any orphans that depend on it are handled too.
+			acceptedTxns = append(acceptedTxns, txD)
+			mp.removeOrphan(tx, false)
+			mp.orphanStats.Resolved++
+			for _, txIn := range tx.MsgTx().TxIn {
+				spent[txIn.PreviousOutPoint] = struct{}{}
 			}
+			processList.PushBack(tx)
 		}
 	}
 
@@ -1023,6 +2076,33 @@ func (mp *TxPool) processOrphans(acceptedTx *ulordutil.Tx) []*TxDesc {
 	return acceptedTxns
 }
 
+// orphanRedeemers returns the orphans which redeem any of the outputs of the
+// passed transaction in the order they were added to the orphan pool.  Each
+// orphan is only returned once, even when it redeems several outputs, so it is
+// only tried once when the transaction becomes available.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) orphanRedeemers(tx *ulordutil.Tx) []*orphanTx {
+	var redeemers []*orphanTx
+	seen := make(map[chainhash.Hash]struct{})
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for txOutIdx := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(txOutIdx)
+		for hash := range mp.orphansByPrev[prevOut] {
+			if _, ok := seen[hash]; ok {
+				continue
+			}
+			seen[hash] = struct{}{}
+			redeemers = append(redeemers, mp.orphans[hash])
+		}
+	}
+
+	sort.Slice(redeemers, func(i, j int) bool {
+		return redeemers[i].seq < redeemers[j].seq
+	})
+	return redeemers
+}
+
 // ProcessOrphans determines if there are any orphans which depend on the passed
 // transaction hash (it is possible that they are no longer orphans) and
 // potentially accepts them to the memory pool.  It repeats the process for the
@@ -1041,32 +2121,70 @@ func (mp *TxPool) ProcessOrphans(acceptedTx *ulordutil.Tx) []*TxDesc {
 	return acceptedTxns
 }
 
-// ProcessTransaction is the main workhorse for handling insertion of new
-// free-standing transactions into the memory pool.  It includes functionality
-// such as rejecting duplicate transactions, ensuring transactions follow all
-// rules, orphan transaction handling, and insertion into the memory pool.
+// processTransaction is the internal function which implements the public
+// ProcessTransaction and ProcessTransactionResult.  It returns the
+// transactions added to the pool and, when the transaction is an orphan, its
+// missing parents.  The checks performed are recorded by the passed recorder,
+// which may be nil.
 //
-// It returns a slice of transactions added to the mempool.  When the
-// error is nil, the list will include the passed transaction itself along
-// with any additional orphan transaactions that were added as a result of
-// the passed one being accepted.
+// The scripts of the transaction are verified without holding the mempool lock
+// so bursts of transactions arriving concurrently are verified in parallel
+// rather than one after another.  The other checks are repeated once the lock
+// is reacquired when the pool or the chain changed in the meantime.  That is
+// sufficient since the outputs an input spends are identified by the hash of
+// the transaction creating them, so the scripts they are verified against
+// can't change.
 //
-// This function is safe for concurrent access.
-func (mp *TxPool) ProcessTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bool, tag Tag) ([]*TxDesc, error) {
-	log.Tracef("Processing transaction %v", tx.Hash())
-
-	// Protect concurrent access.
-	mp.mtx.Lock()
-	defer mp.mtx.Unlock()
-
+// This function MUST NOT be called with the mempool lock held.
+func (mp *TxPool) processTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bool, tag Tag, checks *checkRecorder) ([]*TxDesc, []*chainhash.Hash, error) {
 	// Potentially accept the transaction to the memory pool.
-	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+	mp.mtx.Lock()
+	trusted := mp.isTagTrusted(tag)
+	missingParents, v, err := mp.validateTransaction(tx, true, rateLimit,
+		true, trusted, checks)
 	if err != nil {
-		return nil, err
+		mp.mtx.Unlock()
+		checks.end(err)
+		return nil, nil, err
 	}
+	if len(missingParents) == 0 {
+		generation := mp.generation
+		mp.mtx.Unlock()
+
+		// Verify crypto signatures for each input and reject the
+		// transaction if any don't verify.
+		checks.begin(CheckScripts)
+		if err := mp.validateScripts(tx, v.utxoView); err != nil {
+			checks.end(err)
+			return nil, nil, err
+		}
+
+		// Repeat the other checks when transactions were added to or
+		// removed from the pool or the best chain changed while the
+		// scripts were verified.  The rate limiter already accounted
+		// for the transaction.
+		mp.mtx.Lock()
+		if mp.validationStale(v, generation) {
+
+			checks.begin(CheckRevalidation)
+			missingParents, v, err = mp.validateTransaction(tx,
+				true, false, true, trusted, nil)
+			if err != nil {
+				mp.mtx.Unlock()
+				checks.end(err)
+				return nil, nil, err
+			}
+		}
+	}
+	defer mp.mtx.Unlock()
 
 	if len(missingParents) == 0 {
+		txD, err := mp.addValidatedTransaction(tx, v, checks)
+		checks.end(err)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		// Accept any orphan transactions that depend on this
 		// transaction (they may no longer be orphans if all inputs
 		// are now available) and repeat for those accepted
@@ -1079,8 +2197,9 @@ func (mp *TxPool) ProcessTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bo
 		acceptedTxs[0] = txD
 		copy(acceptedTxs[1:], newTxs)
 
-		return acceptedTxs, nil
+		return acceptedTxs, nil, nil
 	}
+	checks.end(nil)
 
 	// The transaction is an orphan (has inputs missing).  Reject
 	// it if the flag to allow orphans is not set.
@@ -1097,12 +2216,73 @@ func (mp *TxPool) ProcessTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bo
 		str := fmt.Sprintf("orphan transaction %v references "+
 			"outputs of unknown or fully-spent "+
 			"transaction %v", tx.Hash(), missingParents[0])
-		return nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, missingParents, txRuleError(wire.RejectDuplicate,
+			RejectReasonMissingInputs, str)
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
+	checks.begin(CheckOrphan)
 	err = mp.maybeAddOrphan(tx, tag)
-	return nil, err
+	checks.end(err)
+	return nil, missingParents, err
+}
+
+// ProcessTransaction is the main workhorse for handling insertion of new
+// free-standing transactions into the memory pool.  It includes functionality
+// such as rejecting duplicate transactions, ensuring transactions follow all
+// rules, orphan transaction handling, and insertion into the memory pool.
+//
+// It returns a slice of transactions added to the mempool.  When the
+// error is nil, the list will include the passed transaction itself along
+// with any additional orphan transaactions that were added as a result of
+// the passed one being accepted.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bool, tag Tag) ([]*TxDesc, error) {
+	// Only pay for recording the checks when someone is interested in
+	// them.
+	if mp.cfg.AcceptanceHook != nil {
+		result := mp.ProcessTransactionResult(tx, allowOrphan,
+			rateLimit, tag)
+		return result.Accepted, result.Err
+	}
+
+	log.Tracef("Processing transaction %v", tx.Hash())
+
+	acceptedTxs, _, err := mp.processTransaction(tx, allowOrphan,
+		rateLimit, tag, nil)
+	return acceptedTxs, err
+}
+
+// ProcessTransactionResult processes the passed transaction exactly like
+// ProcessTransaction, but returns a detailed result which reports the outcome
+// and timing of every check the transaction went through.  The result is also
+// passed to the acceptance hook when one is configured.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessTransactionResult(tx *ulordutil.Tx, allowOrphan, rateLimit bool, tag Tag) *AcceptanceResult {
+	log.Tracef("Processing transaction %v", tx.Hash())
+
+	start := time.Now()
+	var checks checkRecorder
+
+	acceptedTxs, missingParents, err := mp.processTransaction(tx,
+		allowOrphan, rateLimit, tag, &checks)
+
+	result := &AcceptanceResult{
+		Tx:             tx,
+		Checks:         checks.checks,
+		MissingParents: missingParents,
+		Orphan:         err == nil && len(missingParents) > 0,
+		Accepted:       acceptedTxs,
+		Err:            err,
+		Duration:       time.Since(start),
+	}
+	if mp.cfg.AcceptanceHook != nil {
+		mp.cfg.AcceptanceHook.TransactionProcessed(result)
+	}
+
+	return result
 }
 
 // Count returns the number of transactions in the main pool.  It does not
@@ -1110,11 +2290,7 @@ func (mp *TxPool) ProcessTransaction(tx *ulordutil.Tx, allowOrphan, rateLimit bo
 //
 // This function is safe for concurrent access.
 func (mp *TxPool) Count() int {
-	mp.mtx.RLock()
-	count := len(mp.pool)
-	mp.mtx.RUnlock()
-
-	return count
+	return mp.pool.Len()
 }
 
 // TxHashes returns a slice of hashes for all of the transactions in the memory
@@ -1123,14 +2299,14 @@ func (mp *TxPool) Count() int {
 // This function is safe for concurrent access.
 func (mp *TxPool) TxHashes() []*chainhash.Hash {
 	mp.mtx.RLock()
-	hashes := make([]*chainhash.Hash, len(mp.pool))
-	i := 0
-	for hash := range mp.pool {
-		hashCopy := hash
+	descs := mp.pool.Descs()
+	mp.mtx.RUnlock()
+
+	hashes := make([]*chainhash.Hash, len(descs))
+	for i, desc := range descs {
+		hashCopy := *desc.Tx.Hash()
 		hashes[i] = &hashCopy
-		i++
 	}
-	mp.mtx.RUnlock()
 
 	return hashes
 }
@@ -1141,29 +2317,48 @@ func (mp *TxPool) TxHashes() []*chainhash.Hash {
 // This function is safe for concurrent access.
 func (mp *TxPool) TxDescs() []*TxDesc {
 	mp.mtx.RLock()
-	descs := make([]*TxDesc, len(mp.pool))
-	i := 0
-	for _, desc := range mp.pool {
-		descs[i] = desc
-		i++
-	}
+	descs := mp.pool.Descs()
 	mp.mtx.RUnlock()
 
 	return descs
 }
 
 // MiningDescs returns a slice of mining descriptors for all the transactions
-// in the pool.
+// in the pool.  The package fee rate of each descriptor accounts for the fees
+// paid by the descendants of the transaction in the pool.
 //
 // This is part of the mining.TxSource interface implementation and is safe for
 // concurrent access as required by the interface contract.
 func (mp *TxPool) MiningDescs() []*mining.TxDesc {
 	mp.mtx.RLock()
-	descs := make([]*mining.TxDesc, len(mp.pool))
-	i := 0
-	for _, desc := range mp.pool {
-		descs[i] = &desc.TxDesc
-		i++
+
+	// Copy the descriptors so their package fee rates can be set without
+	// modifying the pool entries.
+	poolDescs := mp.pool.Descs()
+	descs := make([]*mining.TxDesc, 0, len(poolDescs))
+	byHash := make(map[chainhash.Hash]*mining.TxDesc, len(poolDescs))
+	for _, txD := range poolDescs {
+		desc := txD.TxDesc
+		desc.PackageFeePerKB = desc.FeePerKB
+		descs = append(descs, &desc)
+		byHash[*txD.Tx.Hash()] = &desc
+	}
+
+	// Raise the package fee rate of every ancestor of a transaction to
+	// the fee rate of the package formed by the transaction and its
+	// ancestors when it is higher so that children pay for their parents.
+	for _, txD := range poolDescs {
+		if txD.AncestorCount <= 1 {
+			continue
+		}
+		feePerKB := txD.AncestorFees * 1000 / txD.AncestorSize
+		ancestors := make(map[chainhash.Hash]*TxDesc)
+		mp.txAncestors(txD.Tx, ancestors)
+		for hash := range ancestors {
+			if desc := byHash[hash]; desc.PackageFeePerKB < feePerKB {
+				desc.PackageFeePerKB = feePerKB
+			}
+		}
 	}
 	mp.mtx.RUnlock()
 
@@ -1178,11 +2373,12 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*ulordjson.GetRawMempoolVerbose
 	mp.mtx.RLock()
 	defer mp.mtx.RUnlock()
 
+	descs := mp.pool.Descs()
 	result := make(map[string]*ulordjson.GetRawMempoolVerboseResult,
-		len(mp.pool))
+		len(descs))
 	bestHeight := mp.cfg.BestHeight()
 
-	for _, desc := range mp.pool {
+	for _, desc := range descs {
 		// Calculate the current priority based on the inputs to
 		// the transaction.  Use zero if one or more of the
 		// input transactions can't be found for some reason.
@@ -1203,6 +2399,7 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*ulordjson.GetRawMempoolVerbose
 			StartingPriority: desc.StartingPriority,
 			CurrentPriority:  currentPriority,
 			Depends:          make([]string, 0),
+			InstantLock:      desc.Locked,
 		}
 		for _, txIn := range tx.MsgTx().TxIn {
 			hash := &txIn.PreviousOutPoint.Hash
@@ -1218,6 +2415,31 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*ulordjson.GetRawMempoolVerbose
 	return result
 }
 
+// isTagTrusted returns whether transactions with the passed tag come from a
+// trusted source.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) isTagTrusted(tag Tag) bool {
+	_, ok := mp.trustedTags[tag]
+	return ok
+}
+
+// SetTagTrusted sets whether transactions with the passed tag, such as those
+// relayed by the peer with the ID, come from a trusted source.  Transactions
+// from trusted sources bypass the standardness and fee policy, but not the
+// consensus rules.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetTagTrusted(tag Tag, trusted bool) {
+	mp.mtx.Lock()
+	if trusted {
+		mp.trustedTags[tag] = struct{}{}
+	} else {
+		delete(mp.trustedTags, tag)
+	}
+	mp.mtx.Unlock()
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1229,12 +2451,24 @@ func (mp *TxPool) LastUpdated() time.Time {
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
+	poolCfg := *cfg
+	if poolCfg.Policy.Standardness == nil {
+		poolCfg.Policy.Standardness = txscript.DefaultPolicy()
+	}
+	var addrIndex *poolAddrIndex
+	if poolCfg.IndexAddrs {
+		addrIndex = newPoolAddrIndex(poolCfg.ChainParams)
+	}
 	return &TxPool{
-		cfg:            *cfg,
-		pool:           make(map[chainhash.Hash]*TxDesc),
-		orphans:        make(map[chainhash.Hash]*orphanTx),
-		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*ulordutil.Tx),
-		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
-		outpoints:      make(map[wire.OutPoint]*ulordutil.Tx),
+		cfg:              poolCfg,
+		addrIndex:        addrIndex,
+		pool:             newPoolMap(),
+		orphans:          make(map[chainhash.Hash]*orphanTx),
+		orphansByPrev:    make(map[wire.OutPoint]map[chainhash.Hash]*ulordutil.Tx),
+		orphansByTag:     make(map[Tag]map[chainhash.Hash]*orphanTx),
+		nextExpireScan:   time.Now().Add(orphanExpireScanInterval),
+		nextTxExpireScan: time.Now().Add(txExpireScanInterval),
+		outpoints:        make(map[wire.OutPoint]*ulordutil.Tx),
+		trustedTags:      make(map[Tag]struct{}),
 	}
 }
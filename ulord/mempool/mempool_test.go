@@ -28,6 +28,7 @@ type fakeChain struct {
 	sync.RWMutex
 	utxos          *blockchain.UtxoViewpoint
 	currentHeight  int32
+	bestHash       chainhash.Hash
 	medianTimePast time.Time
 }
 
@@ -78,6 +79,23 @@ func (s *fakeChain) SetHeight(height int32) {
 	s.Unlock()
 }
 
+// BestHash returns the hash of the best block associated with the fake chain
+// instance.
+func (s *fakeChain) BestHash() chainhash.Hash {
+	s.RLock()
+	hash := s.bestHash
+	s.RUnlock()
+	return hash
+}
+
+// SetBestHash sets the hash of the best block associated with the fake chain
+// instance.
+func (s *fakeChain) SetBestHash(hash chainhash.Hash) {
+	s.Lock()
+	s.bestHash = hash
+	s.Unlock()
+}
+
 // MedianTimePast returns the current median time past associated with the fake
 // chain instance.
 func (s *fakeChain) MedianTimePast() time.Time {
@@ -188,12 +206,22 @@ func (p *poolHarness) CreateCoinbaseTx(blockHeight int32, numOutputs uint32) (*u
 // total input amount.  All outputs will be to the payment script associated
 // with the harness and all inputs are assumed to do the same.
 func (p *poolHarness) CreateSignedTx(inputs []spendableOutput, numOutputs uint32) (*ulordutil.Tx, error) {
-	// Calculate the total input amount and split it amongst the requested
-	// number of outputs.
+	return p.CreateSignedTxWithFee(inputs, numOutputs, 0,
+		wire.MaxTxInSequenceNum)
+}
+
+// CreateSignedTxWithFee creates a new signed transaction in the same manner as
+// CreateSignedTx, except the outputs are reduced by the provided fee and all
+// inputs use the provided sequence number, which allows the transaction to
+// signal replaceability per BIP0125.
+func (p *poolHarness) CreateSignedTxWithFee(inputs []spendableOutput, numOutputs uint32, fee ulordutil.Amount, sequence uint32) (*ulordutil.Tx, error) {
+	// Calculate the total input amount less the fee and split it amongst
+	// the requested number of outputs.
 	var totalInput ulordutil.Amount
 	for _, input := range inputs {
 		totalInput += input.amount
 	}
+	totalInput -= fee
 	amountPerOutput := int64(totalInput) / int64(numOutputs)
 	remainder := int64(totalInput) - amountPerOutput*int64(numOutputs)
 
@@ -202,7 +230,7 @@ func (p *poolHarness) CreateSignedTx(inputs []spendableOutput, numOutputs uint32
 		tx.AddTxIn(&wire.TxIn{
 			PreviousOutPoint: input.outPoint,
 			SignatureScript:  nil,
-			Sequence:         wire.MaxTxInSequenceNum,
+			Sequence:         sequence,
 		})
 	}
 	for i := uint32(0); i < numOutputs; i++ {
@@ -320,6 +348,7 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 			ChainParams:      chainParams,
 			FetchUtxoView:    chain.FetchUtxoView,
 			BestHeight:       chain.BestHeight,
+			BestHash:         chain.BestHash,
 			MedianTimePast:   chain.MedianTimePast,
 			CalcSequenceLock: chain.CalcSequenceLock,
 			SigCache:         nil,
@@ -570,6 +599,128 @@ func TestOrphanEviction(t *testing.T) {
 	}
 }
 
+// TestOrphanQuotas ensures a tag can't hold more orphans than its quota and
+// that the oldest orphans of the tag with the most orphans are evicted when the
+// orphan pool is full.
+func TestOrphanQuotas(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.MaxOrphanTxs = 5
+	harness.txPool.cfg.Policy.MaxOrphanTxsPerTag = 3
+	tc := &testContext{t, harness}
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 8)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	addOrphans := func(txns []*ulordutil.Tx, tag Tag) {
+		t.Helper()
+		for _, tx := range txns {
+			_, err := harness.txPool.ProcessTransaction(tx, true,
+				false, tag)
+			if err != nil {
+				t.Fatalf("ProcessTransaction: failed to accept valid "+
+					"orphan %v", err)
+			}
+		}
+	}
+
+	// Adding more orphans than the quota of a tag evicts its oldest one
+	// even though the orphan pool is not full.
+	addOrphans(chainedTxns[1:5], 1)
+	testPoolMembership(tc, chainedTxns[1], false, false)
+	for _, tx := range chainedTxns[2:5] {
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	// Once the orphan pool is full, orphans with another tag evict the
+	// oldest orphan of the tag with the most orphans.
+	addOrphans(chainedTxns[5:8], 2)
+	testPoolMembership(tc, chainedTxns[2], false, false)
+	for _, tx := range chainedTxns[3:8] {
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	stats := harness.txPool.OrphanStats()
+	if stats.Count != 5 || stats.CountByTag[1] != 2 ||
+		stats.CountByTag[2] != 3 || stats.Added != 7 ||
+		stats.Evicted != 2 || stats.Resolved != 0 {
+
+		t.Fatalf("unexpected orphan stats %+v", stats)
+	}
+
+	// Removing the orphans of a tag only removes those orphans and the
+	// orphans redeeming them.
+	harness.txPool.RemoveOrphansByTag(2)
+	stats = harness.txPool.OrphanStats()
+	if stats.Count != 2 || len(stats.CountByTag) != 1 ||
+		stats.CountByTag[1] != 2 {
+
+		t.Fatalf("unexpected orphan stats %+v", stats)
+	}
+}
+
+// TestOrphanResolutionOrder ensures orphans are resolved in the order they
+// were received when their parent becomes available, so the first of several
+// orphans double spending each other is accepted regardless of its tag.
+func TestOrphanResolutionOrder(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	parent, err := harness.CreateSignedTx(outputs[0:1], 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// Create orphans spending the same output of the parent and add them
+	// with descending tags.
+	var orphans []*ulordutil.Tx
+	for i := uint32(1); i <= 3; i++ {
+		orphan, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, i)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		_, err = harness.txPool.ProcessTransaction(orphan, true, false,
+			Tag(4-i))
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"orphan %v", err)
+		}
+		orphans = append(orphans, orphan)
+	}
+
+	acceptedTxns, err := harness.txPool.ProcessTransaction(parent, false,
+		false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid tx %v", err)
+	}
+	if len(acceptedTxns) != 2 || acceptedTxns[1].Tx != orphans[0] {
+		t.Fatalf("ProcessTransaction: did not accept the parent and "+
+			"the first orphan -- got %d transactions",
+			len(acceptedTxns))
+	}
+	testPoolMembership(tc, orphans[0], false, true)
+	for _, tx := range orphans[1:] {
+		testPoolMembership(tc, tx, false, false)
+	}
+
+	stats := harness.txPool.OrphanStats()
+	if stats.Count != 0 || stats.Added != 3 || stats.Resolved != 1 {
+		t.Fatalf("unexpected orphan stats %+v", stats)
+	}
+}
+
 // TestBasicOrphanRemoval ensure that orphan removal works as expected when an
 // orphan that doesn't exist is removed  both when there is another orphan that
 // redeems it and when there is not.
@@ -866,3 +1017,684 @@ func TestCheckSpend(t *testing.T) {
 		t.Fatalf("Unexpeced spend found in pool: %v", spend)
 	}
 }
+
+// TestReplaceByFee ensures transactions replacing transactions in the pool
+// per BIP0125 are accepted or rejected according to the replacement rules and
+// that the replaced transactions and their descendants are evicted.
+func TestReplaceByFee(t *testing.T) {
+	t.Parallel()
+
+	const (
+		noRBF = wire.MaxTxInSequenceNum
+		rbf   = MaxRBFSequence
+	)
+
+	// mustTx creates a signed transaction or fails the test.
+	mustTx := func(h *poolHarness, inputs []spendableOutput, numOutputs uint32,
+		fee ulordutil.Amount, sequence uint32) *ulordutil.Tx {
+
+		tx, err := h.CreateSignedTxWithFee(inputs, numOutputs, fee,
+			sequence)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+
+	// mustAccept adds the transactions to the pool or fails the test.
+	mustAccept := func(h *poolHarness, txns ...*ulordutil.Tx) {
+		for _, tx := range txns {
+			_, err := h.txPool.ProcessTransaction(tx, false, false, 0)
+			if err != nil {
+				t.Fatalf("unable to accept transaction: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+
+		// setup adds the transactions to replace to the pool and
+		// returns the replacement along with the transactions it is
+		// expected to evict.  The outputs are confirmed and unspent.
+		setup func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx)
+
+		// rejectCode is the expected reject code when the replacement
+		// is expected to be rejected.
+		rejected   bool
+		rejectCode wire.RejectCode
+	}{
+		{
+			name: "original does not signal replaceability",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				orig := mustTx(h, outs[:1], 1, 1000, noRBF)
+				mustAccept(h, orig)
+				return mustTx(h, outs[:1], 1, 5000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectDuplicate,
+		},
+		{
+			name: "replacement rejected by policy",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				h.txPool.cfg.Policy.RejectReplacement = true
+				orig := mustTx(h, outs[:1], 1, 1000, rbf)
+				mustAccept(h, orig)
+				return mustTx(h, outs[:1], 1, 5000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectDuplicate,
+		},
+		{
+			name: "original and descendants replaced",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				orig := mustTx(h, outs[:1], 2, 1000, rbf)
+				child := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(orig, 0),
+				}, 1, 1000, noRBF)
+				mustAccept(h, orig, child)
+				repl := mustTx(h, outs[:1], 1, 5000, noRBF)
+				return repl, []*ulordutil.Tx{orig, child}
+			},
+		},
+		{
+			name: "replaceability inherited from unconfirmed parent",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				parent := mustTx(h, outs[:1], 2, 1000, rbf)
+				child := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}, 1, 1000, noRBF)
+				mustAccept(h, parent, child)
+				repl := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}, 1, 5000, noRBF)
+				return repl, []*ulordutil.Tx{child}
+			},
+		},
+		{
+			name: "insufficient fee rate",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				orig := mustTx(h, outs[:1], 1, 5000, rbf)
+				mustAccept(h, orig)
+				return mustTx(h, outs[:1], 1, 5000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectInsufficientFee,
+		},
+		{
+			name: "insufficient absolute fee",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				// The replacement pays a higher fee rate than the
+				// original, but not the fees of its descendant.
+				orig := mustTx(h, outs[:1], 2, 1000, rbf)
+				child := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(orig, 0),
+				}, 1, 20000, noRBF)
+				mustAccept(h, orig, child)
+				return mustTx(h, outs[:1], 1, 5000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectInsufficientFee,
+		},
+		{
+			name: "insufficient fee delta",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				// The replacement pays a higher fee and fee rate,
+				// but not enough more to pay for its own relay.
+				orig := mustTx(h, outs[:1], 1, 1000, rbf)
+				mustAccept(h, orig)
+				return mustTx(h, outs[:1], 1, 1001, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectInsufficientFee,
+		},
+		{
+			name: "too many evictions",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				h.txPool.cfg.Policy.MaxReplacementEvictions = 2
+				orig := mustTx(h, outs[:1], 1, 1000, rbf)
+				child := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(orig, 0),
+				}, 1, 1000, noRBF)
+				grandchild := mustTx(h, []spendableOutput{
+					txOutToSpendableOut(child, 0),
+				}, 1, 1000, noRBF)
+				mustAccept(h, orig, child, grandchild)
+				return mustTx(h, outs[:1], 1, 50000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectNonstandard,
+		},
+		{
+			name: "new unconfirmed input",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				orig := mustTx(h, outs[:1], 1, 1000, rbf)
+				other := mustTx(h, outs[1:2], 1, 1000, noRBF)
+				mustAccept(h, orig, other)
+				return mustTx(h, []spendableOutput{
+					outs[0], txOutToSpendableOut(other, 0),
+				}, 1, 50000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectNonstandard,
+		},
+		{
+			name: "replacement spends replaced transaction",
+			setup: func(h *poolHarness, outs []spendableOutput) (*ulordutil.Tx, []*ulordutil.Tx) {
+				orig := mustTx(h, outs[:2], 2, 1000, rbf)
+				mustAccept(h, orig)
+				return mustTx(h, []spendableOutput{
+					outs[0], txOutToSpendableOut(orig, 0),
+				}, 1, 50000, noRBF), nil
+			},
+			rejected:   true,
+			rejectCode: wire.RejectInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("unable to create test pool: %v", err)
+		}
+
+		// Split the spendable output into several confirmed outputs.
+		funding := mustTx(harness, outputs, 5, 0, noRBF)
+		harness.chain.utxos.AddTxOuts(funding, harness.chain.BestHeight())
+		outs := make([]spendableOutput, 0, 5)
+		for i := uint32(0); i < 5; i++ {
+			outs = append(outs, txOutToSpendableOut(funding, i))
+		}
+
+		var gotEvicted []*ulordutil.Tx
+		harness.txPool.cfg.TxReplaced = func(evicted []*ulordutil.Tx,
+			replacement *ulordutil.Tx) {
+
+			gotEvicted = evicted
+		}
+
+		replacement, wantEvicted := test.setup(harness, outs)
+		_, err = harness.txPool.ProcessTransaction(replacement, false,
+			false, 0)
+		tc := &testContext{t, harness}
+		if test.rejected {
+			code, ok := extractRejectCode(err)
+			if !ok || code != test.rejectCode {
+				t.Errorf("%s: unexpected error - got %v, want "+
+					"reject code %v", test.name, err,
+					test.rejectCode)
+			}
+			testPoolMembership(tc, replacement, false, false)
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to accept replacement: %v",
+				test.name, err)
+			continue
+		}
+
+		testPoolMembership(tc, replacement, false, true)
+		for _, tx := range wantEvicted {
+			testPoolMembership(tc, tx, false, false)
+		}
+		if len(gotEvicted) != len(wantEvicted) {
+			t.Errorf("%s: got %d evicted transactions, want %d",
+				test.name, len(gotEvicted), len(wantEvicted))
+		}
+	}
+}
+
+// TestPackageTracking ensures the ancestor and descendant statistics of pool
+// entries are maintained as transactions are added and removed, that chains of
+// unconfirmed transactions are limited, and that the package fee rates exposed
+// for mining allow children to pay for their parents.
+func TestPackageTracking(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Create a chain of transactions where the parent pays no fee and each
+	// following transaction pays a higher fee than the one before.
+	var chain []*ulordutil.Tx
+	spendable := outputs[0]
+	for i, fee := range []ulordutil.Amount{0, 1000, 100000} {
+		tx, err := harness.CreateSignedTxWithFee(
+			[]spendableOutput{spendable}, 2, fee,
+			wire.MaxTxInSequenceNum)
+		if err != nil {
+			t.Fatalf("unable to create transaction #%d: %v", i, err)
+		}
+		_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction #%d: %v", i, err)
+		}
+		chain = append(chain, tx)
+		spendable = txOutToSpendableOut(tx, 0)
+	}
+
+	// sumStats returns the number, total virtual size and total fees of
+	// the passed transactions.
+	sumStats := func(txns []*ulordutil.Tx) (int, int64, int64) {
+		var size, fees int64
+		for _, tx := range txns {
+			txD := harness.txPool.pool.Get(*tx.Hash())
+			size += GetTxVirtualSize(tx)
+			fees += txD.Fee
+		}
+		return len(txns), size, fees
+	}
+
+	// checkStats ensures the package statistics of the pool entry for the
+	// passed transaction match those of the passed ancestors and
+	// descendants, which include the transaction itself.
+	checkStats := func(tx *ulordutil.Tx, ancestors, descendants []*ulordutil.Tx) {
+		t.Helper()
+		txD := harness.txPool.pool.Get(*tx.Hash())
+		count, size, fees := sumStats(ancestors)
+		if txD.AncestorCount != count || txD.AncestorSize != size ||
+			txD.AncestorFees != fees {
+			t.Errorf("tx %v: got ancestor stats (%d, %d, %d), want "+
+				"(%d, %d, %d)", tx.Hash(), txD.AncestorCount,
+				txD.AncestorSize, txD.AncestorFees, count, size,
+				fees)
+		}
+		count, size, fees = sumStats(descendants)
+		if txD.DescendantCount != count || txD.DescendantSize != size ||
+			txD.DescendantFees != fees {
+			t.Errorf("tx %v: got descendant stats (%d, %d, %d), "+
+				"want (%d, %d, %d)", tx.Hash(),
+				txD.DescendantCount, txD.DescendantSize,
+				txD.DescendantFees, count, size, fees)
+		}
+	}
+	checkStats(chain[0], chain[:1], chain)
+	checkStats(chain[1], chain[:2], chain[1:])
+	checkStats(chain[2], chain, chain[2:])
+
+	// The parent pays no fee, but is selected by the fee rate of the
+	// package formed by the final transaction and its ancestors.
+	packageFees := harness.txPool.pool.Get(*chain[2].Hash()).AncestorFees
+	packageSize := harness.txPool.pool.Get(*chain[2].Hash()).AncestorSize
+	wantPackageFeePerKB := packageFees * 1000 / packageSize
+	for _, desc := range harness.txPool.MiningDescs() {
+		want := desc.FeePerKB
+		if *desc.Tx.Hash() != *chain[2].Hash() {
+			want = wantPackageFeePerKB
+		}
+		if desc.PackageFeePerKB != want {
+			t.Errorf("tx %v: got package fee rate %d, want %d",
+				desc.Tx.Hash(), desc.PackageFeePerKB, want)
+		}
+	}
+
+	// Transactions exceeding the ancestor or descendant count limits are
+	// rejected.
+	harness.txPool.cfg.Policy.MaxAncestorCount = 3
+	tx, err := harness.CreateSignedTxWithFee(
+		[]spendableOutput{txOutToSpendableOut(chain[2], 0)}, 1, 1000,
+		wire.MaxTxInSequenceNum)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	code, reason, _ := extractRejectReason(err)
+	if code != wire.RejectNonstandard ||
+		reason != RejectReasonTooLongMempoolChain {
+
+		t.Fatalf("ProcessTransaction: unexpected error - got %v, want "+
+			"reject code %v with reason %q", err,
+			wire.RejectNonstandard, RejectReasonTooLongMempoolChain)
+	}
+	testPoolMembership(tc, tx, false, false)
+
+	harness.txPool.cfg.Policy.MaxDescendantCount = 3
+	tx, err = harness.CreateSignedTxWithFee(
+		[]spendableOutput{txOutToSpendableOut(chain[0], 1)}, 1, 1000,
+		wire.MaxTxInSequenceNum)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	code, reason, _ = extractRejectReason(err)
+	if code != wire.RejectNonstandard ||
+		reason != RejectReasonTooLongMempoolChain {
+
+		t.Fatalf("ProcessTransaction: unexpected error - got %v, want "+
+			"reject code %v with reason %q", err,
+			wire.RejectNonstandard, RejectReasonTooLongMempoolChain)
+	}
+	testPoolMembership(tc, tx, false, false)
+
+	// Removing the parent without its redeemers, as happens when it is
+	// mined, removes it from the statistics of its descendants.
+	harness.txPool.RemoveTransaction(chain[0], false)
+	checkStats(chain[1], chain[1:2], chain[1:])
+	checkStats(chain[2], chain[1:], chain[2:])
+
+	// Removing the final transaction removes it from the statistics of its
+	// ancestors.
+	harness.txPool.RemoveTransaction(chain[2], false)
+	checkStats(chain[1], chain[1:2], chain[1:2])
+}
+
+// TestPoolSizeLimit ensures the transactions paying the lowest fee rates are
+// evicted once the pool exceeds its maximum size and that the minimum fee rate
+// required to enter the pool is raised above theirs.
+func TestPoolSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	evicted := make(map[chainhash.Hash]EvictionReason)
+	harness.txPool.cfg.TxEvicted = func(tx *ulordutil.Tx, reason EvictionReason) {
+		evicted[*tx.Hash()] = reason
+	}
+
+	// Create a parent paying no fee and two children paying a high and a
+	// low fee, then limit the pool to its current size.  A few bytes of
+	// slack allow for the signatures of the children differing in size.
+	parent, err := harness.CreateSignedTx(outputs, 4)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(parent, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	children := make([]*ulordutil.Tx, 0, 4)
+	for i, fee := range []ulordutil.Amount{50000, 1000, 20000, 1000} {
+		tx, err := harness.CreateSignedTxWithFee([]spendableOutput{
+			txOutToSpendableOut(parent, uint32(i)),
+		}, 1, fee, wire.MaxTxInSequenceNum)
+		if err != nil {
+			t.Fatalf("unable to create transaction #%d: %v", i, err)
+		}
+		children = append(children, tx)
+	}
+	for _, tx := range children[:2] {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	harness.txPool.cfg.Policy.MaxPoolSize = harness.txPool.poolSize + 2
+	if got := harness.txPool.MinFeeRate(); got != harness.txPool.cfg.Policy.MinRelayTxFee {
+		t.Fatalf("MinFeeRate: got %v, want the minimum relay fee", got)
+	}
+
+	// Adding the third child exceeds the maximum size, so the low fee
+	// child, which pays the lowest fee rate in the pool, is evicted.
+	_, err = harness.txPool.ProcessTransaction(children[2], false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(tc, children[2], false, true)
+	testPoolMembership(tc, children[1], false, false)
+	if len(evicted) != 1 || evicted[*children[1].Hash()] != EvictionSizeLimit {
+		t.Fatalf("unexpected evicted transactions %v", evicted)
+	}
+	if harness.txPool.poolSize > harness.txPool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("pool size %d exceeds the maximum of %d",
+			harness.txPool.poolSize,
+			harness.txPool.cfg.Policy.MaxPoolSize)
+	}
+
+	// The minimum fee rate is raised above the rate of the evicted
+	// transaction, so another transaction paying the same fee is rejected.
+	evictedFeePerKB := 1000 * 1000 / GetTxVirtualSize(children[1])
+	minFeeRate := harness.txPool.MinFeeRate()
+	if int64(minFeeRate) <= evictedFeePerKB {
+		t.Fatalf("MinFeeRate: got %v, want more than %v", minFeeRate,
+			evictedFeePerKB)
+	}
+	_, err = harness.txPool.ProcessTransaction(children[3], false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error - got %v, want "+
+			"reject code %v", err, wire.RejectInsufficientFee)
+	}
+	testPoolMembership(tc, children[3], false, false)
+
+	// The raised minimum fee rate decays back to the minimum relay fee
+	// over time.
+	harness.txPool.mtx.Lock()
+	harness.txPool.lastRollingFeeUpdate = time.Now().Add(-10 * rollingFeeHalfLife)
+	harness.txPool.mtx.Unlock()
+	if got := harness.txPool.MinFeeRate(); got != harness.txPool.cfg.Policy.MinRelayTxFee {
+		t.Fatalf("MinFeeRate: got %v after decay, want the minimum "+
+			"relay fee", got)
+	}
+}
+
+// TestTxExpiry ensures transactions which stay in the pool for longer than the
+// expiry set by policy are evicted along with their descendants.
+func TestTxExpiry(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	evicted := make(map[chainhash.Hash]EvictionReason)
+	harness.txPool.cfg.TxEvicted = func(tx *ulordutil.Tx, reason EvictionReason) {
+		evicted[*tx.Hash()] = reason
+	}
+
+	// Create a parent with two outputs and a chain of two transactions off
+	// of the first one.
+	parent, err := harness.CreateSignedTx(outputs, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(txOutToSpendableOut(parent, 0), 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range append([]*ulordutil.Tx{parent}, chainedTxns...) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+
+	// Make the first chained transaction appear to have been added just
+	// over the expiry ago and force the next transaction added to scan
+	// for expired transactions.
+	harness.txPool.cfg.Policy.TxExpiry = time.Hour
+	harness.txPool.pool.Get(*chainedTxns[0].Hash()).Added =
+		time.Now().Add(-time.Hour - time.Minute)
+	harness.txPool.nextTxExpireScan = time.Time{}
+
+	tx, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 1),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	testPoolMembership(tc, parent, false, true)
+	testPoolMembership(tc, tx, false, true)
+	for _, chainedTx := range chainedTxns {
+		testPoolMembership(tc, chainedTx, false, false)
+		if evicted[*chainedTx.Hash()] != EvictionExpired {
+			t.Fatalf("transaction %v was not reported as expired",
+				chainedTx.Hash())
+		}
+	}
+	if len(evicted) != len(chainedTxns) {
+		t.Fatalf("unexpected evicted transactions %v", evicted)
+	}
+}
+
+// TestConcurrentProcessTransaction ensures transactions processed concurrently,
+// which have their scripts verified without holding the mempool lock, can't
+// double spend each other.
+func TestConcurrentProcessTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	const numOutputs = 20
+	base, err := harness.CreateSignedTx(outputs, numOutputs)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(base, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// Create two conflicting transactions spending each output of the
+	// base transaction and submit all of them at once.
+	var txns []*ulordutil.Tx
+	for i := uint32(0); i < numOutputs; i++ {
+		for numTxOuts := uint32(1); numTxOuts <= 2; numTxOuts++ {
+			tx, err := harness.CreateSignedTx([]spendableOutput{
+				txOutToSpendableOut(base, i),
+			}, numTxOuts)
+			if err != nil {
+				t.Fatalf("unable to create transaction: %v", err)
+			}
+			txns = append(txns, tx)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, tx := range txns {
+		wg.Add(1)
+		go func(tx *ulordutil.Tx) {
+			defer wg.Done()
+			harness.txPool.ProcessTransaction(tx, false, false, 0)
+		}(tx)
+	}
+	wg.Wait()
+
+	// Exactly one transaction of each conflicting pair must have been
+	// accepted.
+	for i := 0; i < len(txns); i += 2 {
+		first := harness.txPool.IsTransactionInPool(txns[i].Hash())
+		second := harness.txPool.IsTransactionInPool(txns[i+1].Hash())
+		if first == second {
+			t.Fatalf("conflicting transactions %d and %d: in pool "+
+				"%v and %v", i, i+1, first, second)
+		}
+	}
+	if count := harness.txPool.Count(); count != numOutputs+1 {
+		t.Fatalf("pool holds %d transactions, want %d", count,
+			numOutputs+1)
+	}
+}
+
+// TestTrustedTag ensures transactions with a trusted tag bypass the fee policy
+// while transactions with other tags are still subject to it.
+func TestTrustedTag(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Disallow free transactions so the rate limiter rejects transactions
+	// which don't pay the minimum relay fee.
+	harness.txPool.cfg.Policy.FreeTxRelayLimit = 0
+	tx, err := harness.CreateSignedTx(outputs[:1], 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	const untrustedTag, trustedTag = Tag(1), Tag(2)
+	harness.txPool.SetTagTrusted(trustedTag, true)
+	_, err = harness.txPool.ProcessTransaction(tx, false, true, untrustedTag)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error - got %v, want "+
+			"reject code %v", err, wire.RejectInsufficientFee)
+	}
+	testPoolMembership(tc, tx, false, false)
+
+	_, err = harness.txPool.ProcessTransaction(tx, false, true, trustedTag)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(tc, tx, false, true)
+
+	// Transactions with tags which are no longer trusted are subject to
+	// the fee policy again.
+	harness.txPool.SetTagTrusted(trustedTag, false)
+	tx2, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(tx, 0),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx2, false, true, trustedTag)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error - got %v, want "+
+			"reject code %v", err, wire.RejectInsufficientFee)
+	}
+}
+
+// TestValidationStale ensures the checks of a transaction validated before its
+// scripts were verified without the mempool lock are repeated when the pool or
+// the best chain changed in the meantime, including a reorganization replacing
+// the best block with another one at the same height.
+func TestValidationStale(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tx, err := harness.CreateSignedTx(outputs[0:1], 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	pool := harness.txPool
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+	_, v, err := pool.validateTransaction(tx, true, false, true, false, nil)
+	if err != nil {
+		t.Fatalf("validateTransaction: unexpected error: %v", err)
+	}
+	generation := pool.generation
+
+	if pool.validationStale(v, generation) {
+		t.Fatal("validation stale without changes")
+	}
+
+	harness.chain.SetBestHash(chainhash.Hash{0x01})
+	if !pool.validationStale(v, generation) {
+		t.Fatal("validation not stale after the best block was replaced " +
+			"at the same height")
+	}
+	harness.chain.SetBestHash(chainhash.Hash{})
+
+	height := harness.chain.BestHeight()
+	harness.chain.SetHeight(height + 1)
+	if !pool.validationStale(v, generation) {
+		t.Fatal("validation not stale after a block was connected")
+	}
+	harness.chain.SetHeight(height)
+
+	pool.generation++
+	if !pool.validationStale(v, generation) {
+		t.Fatal("validation not stale after the pool changed")
+	}
+}
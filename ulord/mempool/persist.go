@@ -0,0 +1,191 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// persistVersion is the version of the format written by Save.
+	persistVersion = 1
+
+	// maxPersistedTxs is the maximum number of transactions Load will
+	// accept from a saved pool.  It only guards against allocating memory
+	// for a corrupted count.
+	maxPersistedTxs = 1000000
+)
+
+// Save writes all transactions currently in the pool along with the time
+// each was added to w.  Transactions are written after all of their
+// in-pool ancestors so the pool can be rebuilt by accepting them in order.
+// Orphans are not saved.
+//
+// The format is a big endian uint32 version and uint32 count followed by,
+// for each transaction, the big endian int64 unix time it was added and its
+// serialization including witness data.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Save(w io.Writer) error {
+	mp.mtx.RLock()
+	descs := mp.pool.Descs()
+	mp.mtx.RUnlock()
+
+	// A transaction always has more in-pool ancestors than any of its
+	// parents, so ordering by ancestor count writes parents first.
+	sort.Slice(descs, func(i, j int) bool {
+		if descs[i].AncestorCount != descs[j].AncestorCount {
+			return descs[i].AncestorCount < descs[j].AncestorCount
+		}
+		return descs[i].Added.Before(descs[j].Added)
+	})
+
+	bw := bufio.NewWriter(w)
+	err := binary.Write(bw, binary.BigEndian, uint32(persistVersion))
+	if err != nil {
+		return err
+	}
+	err = binary.Write(bw, binary.BigEndian, uint32(len(descs)))
+	if err != nil {
+		return err
+	}
+	for _, txD := range descs {
+		err := binary.Write(bw, binary.BigEndian, txD.Added.Unix())
+		if err != nil {
+			return err
+		}
+		if err := txD.Tx.MsgTx().Serialize(bw); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Load reads transactions written by Save from r and submits each of them
+// to the pool.  Since the chain may have moved on since the pool was saved,
+// every transaction is fully validated against the current best chain and
+// those which are no longer valid, such as ones which have been mined or
+// double spent, or whose parents were rejected, are skipped.  Accepted
+// transactions keep the time they were originally added to the pool.
+//
+// It returns the number of transactions accepted.  An error is only returned
+// when r can't be read or isn't in the expected format, in which case the
+// transactions accepted before the error remain in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+
+	var version, count uint32
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return 0, err
+	}
+	if version != persistVersion {
+		return 0, fmt.Errorf("unsupported mempool file version %d",
+			version)
+	}
+	if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+		return 0, err
+	}
+	if count > maxPersistedTxs {
+		return 0, fmt.Errorf("mempool file holds too many transactions "+
+			"[count %d, max %d]", count, maxPersistedTxs)
+	}
+
+	var accepted int
+	for i := uint32(0); i < count; i++ {
+		var added int64
+		if err := binary.Read(br, binary.BigEndian, &added); err != nil {
+			return accepted, err
+		}
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(br); err != nil {
+			return accepted, err
+		}
+		tx := ulordutil.NewTx(&msgTx)
+
+		mp.mtx.Lock()
+		missingParents, txD, err := mp.maybeAcceptTransaction(tx, false,
+			false, true, false, nil)
+		if err == nil && len(missingParents) == 0 {
+			txD.Added = time.Unix(added, 0)
+			accepted++
+		}
+		mp.mtx.Unlock()
+
+		switch {
+		case err != nil:
+			log.Debugf("Skipping saved transaction %v: %v", tx.Hash(),
+				err)
+		case len(missingParents) > 0:
+			log.Debugf("Skipping saved transaction %v: missing "+
+				"parents", tx.Hash())
+		}
+	}
+
+	return accepted, nil
+}
+
+// SaveToFile saves the pool with Save to the file set by Config.PersistFile.
+// The file is replaced atomically so an interrupted save never leaves a
+// truncated file behind.  It does nothing when no file is configured.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SaveToFile() error {
+	path := mp.cfg.PersistFile
+	if path == "" {
+		return nil
+	}
+
+	tmpPath := path + ".new"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := mp.Save(f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// LoadFromFile loads the pool with Load from the file set by
+// Config.PersistFile and returns the number of transactions accepted.  It
+// does nothing when no file is configured or the file does not exist.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) LoadFromFile() (int, error) {
+	path := mp.cfg.PersistFile
+	if path == "" {
+		return 0, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return mp.Load(f)
+}
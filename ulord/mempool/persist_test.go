@@ -0,0 +1,204 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestPersistMempool ensures the pool contents can be saved and loaded back
+// into a new pool, and that loaded transactions are validated against the
+// current state of the chain.
+func TestPersistMempool(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	// Create a transaction with two outputs, a chain of transactions off
+	// of the first one and a single transaction off of the second one.
+	base, err := harness.CreateSignedTx(spendableOuts, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(txOutToSpendableOut(base, 0), 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	other, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(base, 1),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// Add the transactions to the pool and make every transaction appear
+	// to have been added before its parents to ensure saving does not rely
+	// on the time transactions were added to order them.
+	txns := append([]*ulordutil.Tx{base}, chainedTxns...)
+	txns = append(txns, other)
+	for _, tx := range txns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	added := time.Unix(time.Now().Unix()-3600, 0)
+	for i := len(txns) - 1; i >= 0; i-- {
+		txD := harness.txPool.pool.Get(*txns[i].Hash())
+		txD.Added = added.Add(-time.Duration(i) * time.Second)
+	}
+
+	var buf bytes.Buffer
+	if err := harness.txPool.Save(&buf); err != nil {
+		t.Fatalf("Save: unexpected error: %v", err)
+	}
+	saved := buf.Bytes()
+
+	// Loading into an empty pool must restore all transactions along with
+	// the time they were added.
+	pool := New(&harness.txPool.cfg)
+	numLoaded, err := pool.Load(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if numLoaded != len(txns) {
+		t.Fatalf("Load: loaded %d transactions, want %d", numLoaded,
+			len(txns))
+	}
+	for i, tx := range txns {
+		txD := pool.pool.Get(*tx.Hash())
+		if txD == nil {
+			t.Fatalf("transaction %d was not loaded", i)
+		}
+		want := added.Add(-time.Duration(i) * time.Second)
+		if !txD.Added.Equal(want) {
+			t.Fatalf("transaction %d: added time %v, want %v", i,
+				txD.Added, want)
+		}
+	}
+	lastDesc := pool.pool.Get(*chainedTxns[2].Hash())
+	if lastDesc.AncestorCount != 4 {
+		t.Fatalf("ancestor count of last chained transaction is %d, "+
+			"want 4", lastDesc.AncestorCount)
+	}
+
+	// Mine the base transaction and double spend its second output in the
+	// chain.  Loading again must then skip the mined transaction and the
+	// one which is no longer valid while still accepting the chain built
+	// on the mined transaction.
+	harness.chain.Lock()
+	harness.chain.utxos.LookupEntry(spendableOuts[0].outPoint).Spend()
+	harness.chain.utxos.AddTxOuts(base, harness.chain.currentHeight+1)
+	harness.chain.utxos.LookupEntry(txOutToSpendableOut(base, 1).outPoint).Spend()
+	harness.chain.Unlock()
+
+	pool = New(&harness.txPool.cfg)
+	numLoaded, err = pool.Load(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if numLoaded != len(chainedTxns) {
+		t.Fatalf("Load: loaded %d transactions, want %d", numLoaded,
+			len(chainedTxns))
+	}
+	for _, tx := range chainedTxns {
+		if !pool.IsTransactionInPool(tx.Hash()) {
+			t.Fatalf("transaction %v was not loaded", tx.Hash())
+		}
+	}
+	if pool.IsTransactionInPool(base.Hash()) ||
+		pool.IsTransactionInPool(other.Hash()) {
+
+		t.Fatal("invalid saved transaction was loaded")
+	}
+
+	// An unknown version and a truncated file must be rejected.
+	badVersion := append([]byte(nil), saved...)
+	binary.BigEndian.PutUint32(badVersion, persistVersion+1)
+	if _, err := New(&harness.txPool.cfg).Load(bytes.NewReader(badVersion)); err == nil {
+		t.Fatal("Load: did not reject unknown version")
+	}
+	// The last chained transaction is saved last since it has the most
+	// ancestors, so all other valid transactions are loaded before the
+	// truncated one is reached.
+	truncated := saved[:len(saved)-1]
+	numLoaded, err = New(&harness.txPool.cfg).Load(bytes.NewReader(truncated))
+	if err == nil {
+		t.Fatal("Load: did not reject truncated data")
+	}
+	if numLoaded != len(chainedTxns)-1 {
+		t.Fatalf("Load: loaded %d transactions before truncated data, "+
+			"want %d", numLoaded, len(chainedTxns)-1)
+	}
+}
+
+// TestPersistMempoolFile ensures the pool is saved to and loaded from the
+// configured file and that a missing file or no file is not an error.
+func TestPersistMempoolFile(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tx, err := harness.CreateSignedTx(spendableOuts, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// Saving and loading without a configured file does nothing.
+	if err := harness.txPool.SaveToFile(); err != nil {
+		t.Fatalf("SaveToFile: unexpected error: %v", err)
+	}
+	if n, err := harness.txPool.LoadFromFile(); n != 0 || err != nil {
+		t.Fatalf("LoadFromFile: got (%d, %v), want (0, nil)", n, err)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := harness.txPool.cfg
+	cfg.PersistFile = filepath.Join(tmpDir, "mempool.dat")
+
+	// A missing file is not an error.
+	if n, err := New(&cfg).LoadFromFile(); n != 0 || err != nil {
+		t.Fatalf("LoadFromFile: got (%d, %v), want (0, nil)", n, err)
+	}
+
+	harness.txPool.cfg.PersistFile = cfg.PersistFile
+	if err := harness.txPool.SaveToFile(); err != nil {
+		t.Fatalf("SaveToFile: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(cfg.PersistFile + ".new"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file was left behind: %v", err)
+	}
+
+	pool := New(&cfg)
+	if n, err := pool.LoadFromFile(); n != 1 || err != nil {
+		t.Fatalf("LoadFromFile: got (%d, %v), want (1, nil)", n, err)
+	}
+	if !pool.IsTransactionInPool(tx.Hash()) {
+		t.Fatal("saved transaction was not loaded")
+	}
+}
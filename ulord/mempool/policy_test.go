@@ -41,13 +41,13 @@ func TestCalcMinRequiredTxRelayFee(t *testing.T) {
 		},
 		{
 			"max standard tx size with default minimum relay fee",
-			maxStandardTxWeight / 4,
+			txscript.DefaultMaxStandardTxWeight / 4,
 			DefaultMinRelayTxFee,
 			100000,
 		},
 		{
 			"max standard tx size with max satoshi relay fee",
-			maxStandardTxWeight / 4,
+			txscript.DefaultMaxStandardTxWeight / 4,
 			ulordutil.MaxSatoshi,
 			ulordutil.MaxSatoshi,
 		},
@@ -193,7 +193,8 @@ func TestCheckPkScriptStandard(t *testing.T) {
 			continue
 		}
 		scriptClass := txscript.GetScriptClass(script)
-		got := checkPkScriptStandard(script, scriptClass)
+		got := checkPkScriptStandard(script, scriptClass,
+			txscript.DefaultPolicy())
 		if (test.isStandard && got != nil) ||
 			(!test.isStandard && got == nil) {
 
@@ -306,10 +307,24 @@ func TestCheckTransactionStandard(t *testing.T) {
 		PkScript: dummyPkScript,
 	}
 
+	// A policy which relaxes the default standardness rules.
+	relaxedPolicy := txscript.DefaultPolicy()
+	relaxedPolicy.RequirePushOnly = false
+	relaxedPolicy.MaxDataCarrierSize = 160
+	relaxedPolicy.MaxNullDataOutputs = 2
+	relaxedPolicy.DustRelayFee = 1
+
+	// A policy which tightens the default standardness rules.
+	strictPolicy := txscript.DefaultPolicy()
+	strictPolicy.MaxSigScriptSize = 64
+	strictPolicy.MaxDataCarrierSize = 0
+	strictPolicy.DustRelayFee = 1000000
+
 	tests := []struct {
 		name       string
 		tx         wire.MsgTx
 		height     int32
+		policy     *txscript.Policy // default policy when nil
 		isStandard bool
 		code       wire.RejectCode
 	}{
@@ -360,7 +375,7 @@ func TestCheckTransactionStandard(t *testing.T) {
 				TxOut: []*wire.TxOut{{
 					Value: 0,
 					PkScript: bytes.Repeat([]byte{0x00},
-						(maxStandardTxWeight/4)+1),
+						(txscript.DefaultMaxStandardTxWeight/4)+1),
 				}},
 				LockTime: 0,
 			},
@@ -375,7 +390,7 @@ func TestCheckTransactionStandard(t *testing.T) {
 				TxIn: []*wire.TxIn{{
 					PreviousOutPoint: dummyPrevOut,
 					SignatureScript: bytes.Repeat([]byte{0x00},
-						maxStandardSigScriptSize+1),
+						txscript.DefaultMaxStandardSigScriptSize+1),
 					Sequence: wire.MaxTxInSequenceNum,
 				}},
 				TxOut:    []*wire.TxOut{&dummyTxOut},
@@ -464,13 +479,123 @@ func TestCheckTransactionStandard(t *testing.T) {
 			height:     300000,
 			isStandard: true,
 		},
+		{
+			name: "Relaxed policy signature script that does more " +
+				"than push data",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: dummyPrevOut,
+					SignatureScript: []byte{
+						txscript.OP_CHECKSIGVERIFY},
+					Sequence: wire.MaxTxInSequenceNum,
+				}},
+				TxOut:    []*wire.TxOut{&dummyTxOut},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     relaxedPolicy,
+			isStandard: true,
+		},
+		{
+			name: "Relaxed policy two large nulldata outputs",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{{
+					Value: 0,
+					PkScript: append([]byte{txscript.OP_RETURN,
+						txscript.OP_PUSHDATA1, 160},
+						make([]byte, 160)...),
+				}, {
+					Value:    0,
+					PkScript: []byte{txscript.OP_RETURN},
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     relaxedPolicy,
+			isStandard: true,
+		},
+		{
+			name: "Relaxed policy small output",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{{
+					Value:    100,
+					PkScript: dummyPkScript,
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     relaxedPolicy,
+			isStandard: true,
+		},
+		{
+			name: "Strict policy signature script size is too large",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&dummyTxOut},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     strictPolicy,
+			isStandard: false,
+			code:       wire.RejectNonstandard,
+		},
+		{
+			name: "Strict policy nulldata output with data",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: dummyPrevOut,
+					Sequence:         wire.MaxTxInSequenceNum,
+				}},
+				TxOut: []*wire.TxOut{{
+					Value:    0,
+					PkScript: []byte{txscript.OP_RETURN, 0x01, 0x01},
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     strictPolicy,
+			isStandard: false,
+			code:       wire.RejectNonstandard,
+		},
+		{
+			name: "Strict policy dust output",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: dummyPrevOut,
+					Sequence:         wire.MaxTxInSequenceNum,
+				}},
+				TxOut: []*wire.TxOut{{
+					Value:    100000,
+					PkScript: dummyPkScript,
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     strictPolicy,
+			isStandard: false,
+			code:       wire.RejectDust,
+		},
 	}
 
 	pastMedianTime := time.Now()
 	for _, test := range tests {
+		policy := test.policy
+		if policy == nil {
+			policy = txscript.DefaultPolicy()
+		}
+
 		// Ensure standardness is as expected.
 		err := checkTransactionStandard(ulordutil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1)
+			test.height, pastMedianTime, DefaultMinRelayTxFee, 1,
+			policy)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"sync"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// poolShards is the number of shards the transactions in the main pool are
+// split into.  It must divide 256 since the first byte of the transaction hash
+// selects the shard.
+const poolShards = 32
+
+// poolShard is a single shard of a poolMap along with the lock protecting it.
+type poolShard struct {
+	mtx   sync.RWMutex
+	descs map[chainhash.Hash]*TxDesc
+}
+
+// poolMap holds the descriptors of the transactions in the main pool split
+// into shards which are each protected by their own lock.  This allows the
+// frequent lookups of single transactions, such as those done for every
+// inventory vector announced by peers, to be done without acquiring the
+// mempool lock so they don't stall behind transactions being validated.
+//
+// The pool as a whole is still protected by the mempool lock, so entries must
+// only be added and removed with the mempool lock held for writes.
+type poolMap struct {
+	shards [poolShards]poolShard
+}
+
+// newPoolMap returns a new empty poolMap.
+func newPoolMap() *poolMap {
+	m := &poolMap{}
+	for i := range m.shards {
+		m.shards[i].descs = make(map[chainhash.Hash]*TxDesc)
+	}
+	return m
+}
+
+// shard returns the shard holding the transaction with the passed hash.
+func (m *poolMap) shard(hash *chainhash.Hash) *poolShard {
+	return &m.shards[int(hash[0])%poolShards]
+}
+
+// Get returns the descriptor of the transaction with the passed hash or nil
+// when it is not in the pool.
+//
+// This function is safe for concurrent access.
+func (m *poolMap) Get(hash chainhash.Hash) *TxDesc {
+	shard := m.shard(&hash)
+	shard.mtx.RLock()
+	txD := shard.descs[hash]
+	shard.mtx.RUnlock()
+
+	return txD
+}
+
+// Set adds the passed descriptor, replacing any descriptor of the same
+// transaction.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (m *poolMap) Set(txD *TxDesc) {
+	hash := txD.Tx.Hash()
+	shard := m.shard(hash)
+	shard.mtx.Lock()
+	shard.descs[*hash] = txD
+	shard.mtx.Unlock()
+}
+
+// Delete removes the descriptor of the transaction with the passed hash.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (m *poolMap) Delete(hash chainhash.Hash) {
+	shard := m.shard(&hash)
+	shard.mtx.Lock()
+	delete(shard.descs, hash)
+	shard.mtx.Unlock()
+}
+
+// Len returns the number of transactions in the pool.
+//
+// This function is safe for concurrent access, but the count is only
+// consistent with other pool state when the mempool lock is held.
+func (m *poolMap) Len() int {
+	var n int
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mtx.RLock()
+		n += len(shard.descs)
+		shard.mtx.RUnlock()
+	}
+	return n
+}
+
+// Descs returns the descriptors of all transactions in the pool.  The slice is
+// a snapshot, so the pool may be modified while iterating over it.
+//
+// This function MUST be called with the mempool lock held (for reads) for the
+// snapshot to be consistent.
+func (m *poolMap) Descs() []*TxDesc {
+	descs := make([]*TxDesc, 0, m.Len())
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mtx.RLock()
+		for _, txD := range shard.descs {
+			descs = append(descs, txD)
+		}
+		shard.mtx.RUnlock()
+	}
+	return descs
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// feeBucketMin and feeBucketMax are the lowest and highest fee rates,
+	// in satoshi/byte, tracked by separate buckets in the statistics used
+	// for smart fee estimates.  Lower and higher fee rates are counted in
+	// the first and last bucket respectively.
+	feeBucketMin = 1.0
+	feeBucketMax = 10000.0
+
+	// feeBucketSpacing is the factor between the upper bounds of
+	// consecutive fee rate buckets.
+	feeBucketSpacing = 1.1
+
+	// shortHorizonDecay and longHorizonDecay are the factors the bucket
+	// statistics of the short and long horizon are multiplied by for each
+	// block, which gives them half-lives of about 18 and 144 blocks.
+	shortHorizonDecay = 0.962
+	longHorizonDecay = 0.9952
+
+	// sufficientFeeTxs is the average number of transactions per block a
+	// range of buckets must have seen for its success rate to be trusted.
+	sufficientFeeTxs = 0.1
+)
+
+// These constants define the confidence levels used by EstimateSmartFee.  A
+// confidence level is the fraction of the transactions paying a fee rate
+// which must have confirmed within a target for the fee rate to be estimated
+// for that target.
+const (
+	// ConfidenceHalfTarget is required for half the requested target.
+	ConfidenceHalfTarget = 0.60
+
+	// ConfidenceTarget is required for the requested target.
+	ConfidenceTarget = 0.85
+
+	// ConfidenceDoubleTarget is required for twice the requested target
+	// by conservative estimates.
+	ConfidenceDoubleTarget = 0.95
+)
+
+// EstimateMode selects how a smart fee estimate trades off the fee paid
+// against the certainty of the transaction confirming in time.
+type EstimateMode int
+
+const (
+	// EstimateConservative bases estimates on both a short and a long
+	// history of blocks and also requires a high confidence that the
+	// transaction confirms within twice the target.  It potentially
+	// returns a higher fee rate than EstimateEconomical.
+	EstimateConservative EstimateMode = iota
+
+	// EstimateEconomical bases estimates on a short history of blocks only
+	// so they respond quickly to falling fees.
+	EstimateEconomical
+)
+
+// estimateModeStrings is a map of estimate modes back to their constant names
+// for pretty printing.
+var estimateModeStrings = map[EstimateMode]string{
+	EstimateConservative: "EstimateConservative",
+	EstimateEconomical:   "EstimateEconomical",
+}
+
+// String returns the EstimateMode in human-readable form.
+func (m EstimateMode) String() string {
+	if s, ok := estimateModeStrings[m]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown EstimateMode (%d)", int(m))
+}
+
+// SmartFeeEstimate is a fee rate estimate returned by EstimateSmartFee.
+type SmartFeeEstimate struct {
+	// FeeRate is the estimated fee rate.
+	FeeRate BtcPerKilobyte
+
+	// Blocks is the number of blocks the estimate is for.  It is higher
+	// than the requested target when there was not enough data to give an
+	// estimate for the target.
+	Blocks uint32
+}
+
+// feeBucketBounds holds the upper bounds, in satoshi/byte, of the fee rate
+// buckets.  The last bucket has no upper bound.
+var feeBucketBounds = func() []float64 {
+	var bounds []float64
+	for bound := feeBucketMin; bound < feeBucketMax; bound *= feeBucketSpacing {
+		bounds = append(bounds, bound)
+	}
+	return append(bounds, feeBucketMax)
+}()
+
+// feeBucketIndex returns the index of the bucket the passed fee rate falls in.
+func feeBucketIndex(feeRate SatoshiPerByte) int {
+	return sort.SearchFloat64s(feeBucketBounds, float64(feeRate))
+}
+
+// feeBucketStats holds exponentially decaying statistics of how quickly
+// transactions paying the fee rates of each bucket confirmed.
+type feeBucketStats struct {
+	decay float64
+
+	// txCount and feeSum are the number and total fee rate of the
+	// transactions in each bucket which either confirmed or were not
+	// confirmed within estimateFeeDepth blocks.
+	txCount []float64
+	feeSum  []float64
+
+	// confirmed holds for each target, the number of transactions in each
+	// bucket which confirmed within that many blocks.
+	confirmed [estimateFeeDepth][]float64
+}
+
+// newFeeBucketStats returns empty bucket statistics with the passed decay.
+func newFeeBucketStats(decay float64) *feeBucketStats {
+	numBuckets := len(feeBucketBounds) + 1
+	stats := &feeBucketStats{
+		decay:   decay,
+		txCount: make([]float64, numBuckets),
+		feeSum:  make([]float64, numBuckets),
+	}
+	for i := range stats.confirmed {
+		stats.confirmed[i] = make([]float64, numBuckets)
+	}
+	return stats
+}
+
+// decayAll applies the decay of one block to all statistics.
+func (s *feeBucketStats) decayAll() {
+	for i := range s.txCount {
+		s.txCount[i] *= s.decay
+		s.feeSum[i] *= s.decay
+	}
+	for _, confirmed := range s.confirmed {
+		for i := range confirmed {
+			confirmed[i] *= s.decay
+		}
+	}
+}
+
+// record adds a transaction paying the passed fee rate which confirmed after
+// the passed number of blocks.  Transactions which were not confirmed within
+// estimateFeeDepth blocks are recorded with a higher number of blocks.
+func (s *feeBucketStats) record(feeRate SatoshiPerByte, blocksToConfirm int32) {
+	bucket := feeBucketIndex(feeRate)
+	s.txCount[bucket]++
+	s.feeSum[bucket] += float64(feeRate)
+	for target := blocksToConfirm; target <= estimateFeeDepth; target++ {
+		if target > 0 {
+			s.confirmed[target-1][bucket]++
+		}
+	}
+}
+
+// estimate returns the average fee rate of the cheapest range of buckets in
+// which at least the passed fraction of the transactions confirmed within
+// target blocks.  Buckets are grouped into ranges, starting from the highest
+// fee rates, until each range has seen enough transactions to be trusted.
+// False is returned when not even the range with the highest fee rates
+// passes.
+func (s *feeBucketStats) estimate(target int, confidence float64) (SatoshiPerByte, bool) {
+	sufficient := sufficientFeeTxs / (1 - s.decay)
+	confirmed := s.confirmed[target-1]
+
+	var numConfirmed, numTxs float64
+	rangeHigh, passLow, passHigh := len(s.txCount)-1, -1, -1
+	for bucket := len(s.txCount) - 1; bucket >= 0; bucket-- {
+		numConfirmed += confirmed[bucket]
+		numTxs += s.txCount[bucket]
+		if numTxs < sufficient {
+			continue
+		}
+		if numConfirmed/numTxs < confidence {
+			break
+		}
+		passLow, passHigh = bucket, rangeHigh
+		numConfirmed, numTxs = 0, 0
+		rangeHigh = bucket - 1
+	}
+	if passLow < 0 {
+		return 0, false
+	}
+
+	var feeSum, txCount float64
+	for bucket := passLow; bucket <= passHigh; bucket++ {
+		feeSum += s.feeSum[bucket]
+		txCount += s.txCount[bucket]
+	}
+	return SatoshiPerByte(feeSum / txCount), true
+}
+
+// serialize writes the statistics to w.
+func (s *feeBucketStats) serialize(w io.Writer) {
+	binary.Write(w, binary.BigEndian, s.decay)
+	binary.Write(w, binary.BigEndian, uint32(len(s.txCount)))
+	binary.Write(w, binary.BigEndian, s.txCount)
+	binary.Write(w, binary.BigEndian, s.feeSum)
+	for _, confirmed := range s.confirmed {
+		binary.Write(w, binary.BigEndian, confirmed)
+	}
+}
+
+// deserializeFeeBucketStats reads statistics written by serialize from r.
+func deserializeFeeBucketStats(r io.Reader) (*feeBucketStats, error) {
+	var decay float64
+	var numBuckets uint32
+	binary.Read(r, binary.BigEndian, &decay)
+	err := binary.Read(r, binary.BigEndian, &numBuckets)
+	if err != nil {
+		return nil, err
+	}
+	if int(numBuckets) != len(feeBucketBounds)+1 {
+		return nil, fmt.Errorf("Incorrect number of fee buckets: "+
+			"expected %d found %d", len(feeBucketBounds)+1, numBuckets)
+	}
+
+	s := newFeeBucketStats(decay)
+	binary.Read(r, binary.BigEndian, s.txCount)
+	binary.Read(r, binary.BigEndian, s.feeSum)
+	for _, confirmed := range s.confirmed {
+		err := binary.Read(r, binary.BigEndian, confirmed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// smartEstimate returns the fee rate estimate for the passed target in the
+// passed mode.  It is the highest of the fee rates found for half the target
+// at ConfidenceHalfTarget and for the target at ConfidenceTarget and, for
+// conservative estimates, additionally for the target at ConfidenceTarget in
+// the long horizon and for twice the target at ConfidenceDoubleTarget.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) smartEstimate(target int, mode EstimateMode) (SatoshiPerByte, bool) {
+	// The estimate for the target itself is required, while those used
+	// to make the estimate safer are only taken into account when there
+	// is enough data for them.
+	feeRate, ok := ef.shortStats.estimate(target, ConfidenceTarget)
+	if mode == EstimateConservative {
+		longRate, longOk := ef.longStats.estimate(target,
+			ConfidenceTarget)
+		if !ok || (longOk && longRate > feeRate) {
+			feeRate, ok = longRate, longOk
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+
+	halfTarget := target / 2
+	if halfTarget < 1 {
+		halfTarget = 1
+	}
+	rate, ok := ef.shortStats.estimate(halfTarget, ConfidenceHalfTarget)
+	if ok && rate > feeRate {
+		feeRate = rate
+	}
+	if mode == EstimateConservative {
+		doubleTarget := target * 2
+		if doubleTarget > estimateFeeDepth {
+			doubleTarget = estimateFeeDepth
+		}
+		rate, ok := ef.longStats.estimate(doubleTarget,
+			ConfidenceDoubleTarget)
+		if ok && rate > feeRate {
+			feeRate = rate
+		}
+	}
+
+	return feeRate, true
+}
+
+// EstimateSmartFee estimates the fee rate a transaction must pay to confirm
+// within the passed number of blocks.  Unlike EstimateFee, the estimate is
+// based on how often transactions paying each range of fee rates confirmed
+// in time, which must be at least the confidence levels defined by the
+// Confidence constants, and on the mode.  When there is not enough data to
+// give an estimate for the target, the estimate for the closest higher
+// target there is enough data for is returned.
+func (ef *FeeEstimator) EstimateSmartFee(numBlocks uint32, mode EstimateMode) (*SmartFeeEstimate, error) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if ef.numBlocksRegistered < ef.minRegisteredBlocks {
+		return nil, errors.New("not enough blocks have been observed")
+	}
+
+	if numBlocks == 0 {
+		return nil, errors.New("cannot confirm transaction in zero blocks")
+	}
+
+	if numBlocks > estimateFeeDepth {
+		return nil, fmt.Errorf(
+			"can only estimate fees for up to %d blocks from now",
+			estimateFeeDepth)
+	}
+
+	for target := int(numBlocks); target <= estimateFeeDepth; target++ {
+		feeRate, ok := ef.smartEstimate(target, mode)
+		if ok {
+			return &SmartFeeEstimate{
+				FeeRate: feeRate.ToBtcPerKb(),
+				Blocks:  uint32(target),
+			}, nil
+		}
+	}
+
+	return nil, errors.New("insufficient data to estimate fee")
+}
+
+// EstimateRawFee estimates the fee rate a transaction must pay to confirm
+// within the passed number of blocks with the passed confidence, which is the
+// fraction of the transactions paying the fee rate which confirmed in time.
+// Economical estimates are based on a short history of blocks and
+// conservative estimates on a long one.
+func (ef *FeeEstimator) EstimateRawFee(numBlocks uint32, confidence float64, mode EstimateMode) (BtcPerKilobyte, error) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if numBlocks == 0 || numBlocks > estimateFeeDepth {
+		return -1, fmt.Errorf("number of blocks must be between 1 "+
+			"and %d", estimateFeeDepth)
+	}
+
+	if confidence <= 0 || confidence > 1 {
+		return -1, errors.New("confidence must be greater than 0 and " +
+			"at most 1")
+	}
+
+	stats := ef.longStats
+	if mode == EstimateEconomical {
+		stats = ef.shortStats
+	}
+	feeRate, ok := stats.estimate(int(numBlocks), confidence)
+	if !ok {
+		return -1, errors.New("insufficient data to estimate fee")
+	}
+
+	return feeRate.ToBtcPerKb(), nil
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ulordsuite/ulord/mining"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// sameFeeRate returns whether the passed fee rates are equal apart from
+// floating point rounding.
+func sameFeeRate(a, b BtcPerKilobyte) bool {
+	return math.Abs(float64(a-b)) < 1e-12
+}
+
+// TestEstimateSmartFee ensures smart fee estimates are based on how quickly
+// transactions paying each fee rate confirmed and that the bucket statistics
+// survive saving and restoring the fee estimator.
+func TestEstimateSmartFee(t *testing.T) {
+	t.Parallel()
+
+	ef := NewFeeEstimator(DefaultEstimateFeeMaxRollback, 0)
+	ef.lastKnownHeight = 0
+
+	// Every block, observe transactions paying 100 satoshi/byte which
+	// confirm in the next block, ones paying 10 satoshi/byte which confirm
+	// three blocks later and ones paying 1 satoshi/byte which never
+	// confirm.  The test transactions are 10 bytes in size.
+	type pendingTx struct {
+		tx        *ulordutil.Tx
+		confirmAt int32
+	}
+	var pending []pendingTx
+	var version int32
+	var height int32
+	for block := 0; block < 60; block++ {
+		for _, tx := range []struct {
+			fee   int64
+			delay int32
+		}{{1000, 1}, {1000, 1}, {100, 3}, {100, 3}, {10, math.MaxInt16}} {
+			version++
+			txD := &TxDesc{
+				TxDesc: mining.TxDesc{
+					Tx: ulordutil.NewTx(&wire.MsgTx{
+						Version: version,
+					}),
+					Height: height,
+					Fee:    tx.fee,
+				},
+			}
+			ef.ObserveTransaction(txD)
+			pending = append(pending, pendingTx{txD.Tx, height + tx.delay})
+		}
+
+		height++
+		var mined []*wire.MsgTx
+		remaining := pending[:0]
+		for _, p := range pending {
+			if p.confirmAt == height {
+				mined = append(mined, p.tx.MsgTx())
+				continue
+			}
+			remaining = append(remaining, p)
+		}
+		pending = remaining
+
+		block := ulordutil.NewBlock(&wire.MsgBlock{Transactions: mined})
+		block.SetHeight(height)
+		if err := ef.RegisterBlock(block); err != nil {
+			t.Fatalf("RegisterBlock: unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		numBlocks uint32
+		mode      EstimateMode
+		want      SatoshiPerByte
+	}{
+		{1, EstimateEconomical, 100},
+		{1, EstimateConservative, 100},
+		{3, EstimateEconomical, 100},
+		{6, EstimateEconomical, 10},
+		{6, EstimateConservative, 10},
+		{25, EstimateConservative, 10},
+	}
+	checkEstimates := func(ef *FeeEstimator) {
+		t.Helper()
+		for _, test := range tests {
+			estimate, err := ef.EstimateSmartFee(test.numBlocks,
+				test.mode)
+			if err != nil {
+				t.Fatalf("EstimateSmartFee(%d, %v): unexpected "+
+					"error: %v", test.numBlocks, test.mode, err)
+			}
+			if !sameFeeRate(estimate.FeeRate, test.want.ToBtcPerKb()) ||
+				estimate.Blocks != test.numBlocks {
+
+				t.Fatalf("EstimateSmartFee(%d, %v): got %v in %d "+
+					"blocks, want %v in %d blocks",
+					test.numBlocks, test.mode, estimate.FeeRate,
+					estimate.Blocks, test.want.ToBtcPerKb(),
+					test.numBlocks)
+			}
+		}
+	}
+	checkEstimates(ef)
+
+	// Raw estimates use the passed confidence in the horizon selected by
+	// the mode.
+	rawTests := []struct {
+		numBlocks  uint32
+		confidence float64
+		mode       EstimateMode
+		want       SatoshiPerByte
+	}{
+		{1, 0.3, EstimateEconomical, 100},
+		{3, 0.99, EstimateEconomical, 10},
+		{3, 0.99, EstimateConservative, 10},
+	}
+	for _, test := range rawTests {
+		feeRate, err := ef.EstimateRawFee(test.numBlocks,
+			test.confidence, test.mode)
+		if err != nil || !sameFeeRate(feeRate, test.want.ToBtcPerKb()) {
+			t.Fatalf("EstimateRawFee(%d, %v, %v): got (%v, %v), "+
+				"want %v", test.numBlocks, test.confidence,
+				test.mode, feeRate, err, test.want.ToBtcPerKb())
+		}
+	}
+	if _, err := ef.EstimateRawFee(1, 1.5, EstimateEconomical); err == nil {
+		t.Fatal("EstimateRawFee: did not reject invalid confidence")
+	}
+
+	// Invalid targets are rejected.
+	for _, numBlocks := range []uint32{0, estimateFeeDepth + 1} {
+		_, err := ef.EstimateSmartFee(numBlocks, EstimateEconomical)
+		if err == nil {
+			t.Fatalf("EstimateSmartFee(%d): did not return an error",
+				numBlocks)
+		}
+	}
+
+	// The estimates survive saving and restoring the fee estimator and
+	// rebasing it to a later height.
+	restored, err := RestoreFeeEstimator(ef.Save())
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
+	}
+	checkEstimates(restored)
+	restored.Rebase(height + 10)
+	if restored.LastKnownHeight() != height+10 {
+		t.Fatalf("LastKnownHeight: got %d, want %d",
+			restored.LastKnownHeight(), height+10)
+	}
+	for _, o := range restored.observed {
+		if o.mined == mining.UnminedHeight {
+			t.Fatalf("unmined transaction %v was kept by Rebase",
+				o.hash)
+		}
+	}
+	checkEstimates(restored)
+
+	// A new fee estimator has no data to give estimates from.
+	ef = NewFeeEstimator(DefaultEstimateFeeMaxRollback, 0)
+	if _, err := ef.EstimateSmartFee(1, EstimateEconomical); err == nil {
+		t.Fatal("EstimateSmartFee: did not return an error without data")
+	}
+}
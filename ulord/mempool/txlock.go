@@ -0,0 +1,100 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TxLockSource provides the transaction locks established by masternode
+// quorums for InstantSend.  Once a quorum locks a transaction, the outputs it
+// spends may not be spent by any other transaction.
+type TxLockSource interface {
+	// LockedSpender returns the hash of the transaction the passed
+	// outpoint is locked to or nil when the outpoint is not locked.
+	LockedSpender(outpoint wire.OutPoint) *chainhash.Hash
+}
+
+// lockedBySource returns whether all outputs spent by the passed transaction
+// are locked to it by the configured lock source.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) lockedBySource(tx *ulordutil.Tx) bool {
+	if mp.cfg.TxLocks == nil {
+		return false
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		spender := mp.cfg.TxLocks.LockedSpender(txIn.PreviousOutPoint)
+		if spender == nil || !spender.IsEqual(tx.Hash()) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTxLocks ensures the passed transaction does not spend any outputs which
+// are locked to another transaction by the configured lock source.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkTxLocks(tx *ulordutil.Tx) error {
+	if mp.cfg.TxLocks == nil {
+		return nil
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		spender := mp.cfg.TxLocks.LockedSpender(txIn.PreviousOutPoint)
+		if spender != nil && !spender.IsEqual(tx.Hash()) {
+			str := fmt.Sprintf("transaction %v spends output %v "+
+				"which is locked to transaction %v", tx.Hash(),
+				txIn.PreviousOutPoint, spender)
+			return txRuleError(wire.RejectDuplicate,
+				RejectReasonTxLocked, str)
+		}
+	}
+	return nil
+}
+
+// MarkTxLocked marks the transaction with the passed hash as locked by a
+// masternode quorum.  Locked transactions can't be replaced.  It returns
+// whether the transaction is in the pool.  Transactions added to the pool
+// after being locked are marked automatically when the pool is configured
+// with a lock source.  The TxLocked callback of the pool is invoked when the
+// transaction was not locked before.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MarkTxLocked(hash *chainhash.Hash) bool {
+	mp.mtx.Lock()
+	txD := mp.pool.Get(*hash)
+	exists := txD != nil
+	var newlyLocked *ulordutil.Tx
+	if exists && !txD.Locked {
+		txD.Locked = true
+		newlyLocked = txD.Tx
+	}
+	mp.mtx.Unlock()
+
+	if newlyLocked != nil && mp.cfg.TxLocked != nil {
+		mp.cfg.TxLocked(newlyLocked)
+	}
+	return exists
+}
+
+// IsTxLocked returns whether the transaction with the passed hash is in the
+// pool and locked by a masternode quorum.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) IsTxLocked(hash *chainhash.Hash) bool {
+	mp.mtx.RLock()
+	txD := mp.pool.Get(*hash)
+	locked := txD != nil && txD.Locked
+	mp.mtx.RUnlock()
+
+	return locked
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// fakeTxLocks is a TxLockSource which locks outpoints to the transactions
+// stored in the map.
+type fakeTxLocks map[wire.OutPoint]chainhash.Hash
+
+// LockedSpender returns the transaction the outpoint is locked to, if any.
+//
+// This is part of the TxLockSource interface.
+func (l fakeTxLocks) LockedSpender(outpoint wire.OutPoint) *chainhash.Hash {
+	if hash, ok := l[outpoint]; ok {
+		return &hash
+	}
+	return nil
+}
+
+// TestTxLocks ensures the pool marks transactions locked by masternode quorums,
+// notifies the TxLocked callback of transactions locked after they entered the
+// pool, rejects spends of outputs locked to other transactions and refuses to
+// replace locked transactions.
+func TestTxLocks(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	locks := make(fakeTxLocks)
+	harness.txPool.cfg.TxLocks = locks
+	var notified []*ulordutil.Tx
+	harness.txPool.cfg.TxLocked = func(tx *ulordutil.Tx) {
+		notified = append(notified, tx)
+	}
+
+	// Create a transaction with two outputs to spend from.
+	base, err := harness.CreateSignedTx(outputs, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(base, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	outs := []spendableOutput{
+		txOutToSpendableOut(base, 0),
+		txOutToSpendableOut(base, 1),
+	}
+
+	mustTx := func(inputs []spendableOutput, fee ulordutil.Amount) *ulordutil.Tx {
+		t.Helper()
+		tx, err := harness.CreateSignedTxWithFee(inputs, 1, fee,
+			MaxRBFSequence)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	mustReject := func(tx *ulordutil.Tx) {
+		t.Helper()
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err == nil {
+			t.Fatalf("ProcessTransaction: accepted transaction %v",
+				tx.Hash())
+		}
+		code, reason, _ := extractRejectReason(err)
+		if code != wire.RejectDuplicate || reason != RejectReasonTxLocked {
+			t.Fatalf("ProcessTransaction: got reject code %v with "+
+				"reason %q, want %v with reason %q", code, reason,
+				wire.RejectDuplicate, RejectReasonTxLocked)
+		}
+	}
+
+	// A transaction spending an output locked to another transaction is
+	// rejected, while the locked transaction is accepted and marked as
+	// locked.
+	locked := mustTx(outs[:1], 1000)
+	locks[outs[0].outPoint] = *locked.Hash()
+	mustReject(mustTx(outs[:1], 5000))
+	_, err = harness.txPool.ProcessTransaction(locked, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	if !harness.txPool.IsTxLocked(locked.Hash()) {
+		t.Fatal("transaction locked by the lock source is not locked")
+	}
+	entry := harness.txPool.RawMempoolVerbose()[locked.Hash().String()]
+	if entry == nil || !entry.InstantLock {
+		t.Fatalf("verbose mempool entry is not reported locked: %+v",
+			entry)
+	}
+	if len(notified) != 0 {
+		t.Fatalf("TxLocked called for %d transactions locked on "+
+			"acceptance", len(notified))
+	}
+
+	// A transaction marked as locked after entering the pool can't be
+	// replaced.
+	tx := mustTx(outs[1:2], 1000)
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	if harness.txPool.IsTxLocked(tx.Hash()) {
+		t.Fatal("unlocked transaction is locked")
+	}
+	if !harness.txPool.MarkTxLocked(tx.Hash()) {
+		t.Fatal("MarkTxLocked: transaction is not in the pool")
+	}
+	if !harness.txPool.IsTxLocked(tx.Hash()) {
+		t.Fatal("transaction marked as locked is not locked")
+	}
+	if len(notified) != 1 || notified[0] != tx {
+		t.Fatalf("TxLocked called with %v, want %v", notified, tx)
+	}
+
+	// Marking the transaction as locked again does not notify the
+	// callback again.
+	if !harness.txPool.MarkTxLocked(tx.Hash()) {
+		t.Fatal("MarkTxLocked: transaction is not in the pool")
+	}
+	if len(notified) != 1 {
+		t.Fatalf("TxLocked called %d times", len(notified))
+	}
+	mustReject(mustTx(outs[1:2], 5000))
+	testPoolMembership(&testContext{t, harness}, tx, false, true)
+
+	// Transactions which aren't in the pool can't be marked as locked.
+	if harness.txPool.MarkTxLocked(&chainhash.Hash{}) {
+		t.Fatal("MarkTxLocked: unknown transaction is in the pool")
+	}
+	if len(notified) != 1 {
+		t.Fatalf("TxLocked called %d times", len(notified))
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+const (
+	// DefaultUnbroadcastInterval is the default time a locally submitted
+	// transaction waits for its first rebroadcast.  The time between
+	// later rebroadcasts doubles with every attempt.
+	DefaultUnbroadcastInterval = 5 * time.Minute
+
+	// DefaultMaxUnbroadcastInterval is the default maximum time between
+	// two rebroadcasts of a locally submitted transaction.
+	DefaultMaxUnbroadcastInterval = 2 * time.Hour
+)
+
+// UnbroadcastTx describes a locally submitted transaction which has not been
+// seen announced by peers yet.
+type UnbroadcastTx struct {
+	// Desc is the transaction descriptor of the pool at the time the
+	// transaction was submitted.
+	Desc *TxDesc
+
+	// Added is the time the transaction was submitted.
+	Added time.Time
+
+	// Attempts is the number of times the transaction was rebroadcast.
+	Attempts int
+
+	// NextAttempt is the time the transaction is rebroadcast next.
+	NextAttempt time.Time
+}
+
+// UnbroadcastSet tracks locally submitted transactions until they are seen
+// announced by peers and schedules their rebroadcasts with an exponential
+// backoff, so transactions submitted while their first relay does not reach
+// the network are not silently lost.
+//
+// The set does not relay transactions itself.  Its owner periodically
+// rebroadcasts the transactions returned by Due and removes transactions once
+// peers announce or request them, or when they leave the pool.
+//
+// All functions are safe for concurrent access.
+type UnbroadcastSet struct {
+	mtx         sync.Mutex
+	interval    time.Duration
+	maxInterval time.Duration
+	txns        map[chainhash.Hash]*UnbroadcastTx
+}
+
+// NewUnbroadcastSet returns an empty set of unbroadcast transactions which
+// are first rebroadcast after the passed interval and then with doubling
+// intervals up to the passed maximum interval.
+func NewUnbroadcastSet(interval, maxInterval time.Duration) *UnbroadcastSet {
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	return &UnbroadcastSet{
+		interval:    interval,
+		maxInterval: maxInterval,
+		txns:        make(map[chainhash.Hash]*UnbroadcastTx),
+	}
+}
+
+// backoff returns the time to wait for the next rebroadcast of a transaction
+// which was rebroadcast the passed number of times.
+func (u *UnbroadcastSet) backoff(attempts int) time.Duration {
+	d := u.interval
+	for i := 0; i < attempts && d < u.maxInterval; i++ {
+		d *= 2
+	}
+	if d > u.maxInterval {
+		d = u.maxInterval
+	}
+	return d
+}
+
+// addedBefore returns whether transaction a was added to the set before b,
+// ordering transactions added at the same time by their hashes.
+func addedBefore(a, b *UnbroadcastTx) bool {
+	if !a.Added.Equal(b.Added) {
+		return a.Added.Before(b.Added)
+	}
+	return bytes.Compare(a.Desc.Tx.Hash()[:], b.Desc.Tx.Hash()[:]) < 0
+}
+
+// Add adds the transaction of the passed descriptor to the set as submitted at
+// the passed time.  Transactions which are already in the set keep their
+// rebroadcast schedule.
+func (u *UnbroadcastSet) Add(txD *TxDesc, now time.Time) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	hash := *txD.Tx.Hash()
+	if _, ok := u.txns[hash]; ok {
+		return
+	}
+	u.txns[hash] = &UnbroadcastTx{
+		Desc:        txD,
+		Added:       now,
+		NextAttempt: now.Add(u.backoff(0)),
+	}
+}
+
+// Remove removes the passed transaction from the set and returns whether it
+// was in it.
+func (u *UnbroadcastSet) Remove(hash *chainhash.Hash) bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if _, ok := u.txns[*hash]; !ok {
+		return false
+	}
+	delete(u.txns, *hash)
+	return true
+}
+
+// Contains returns whether the passed transaction is in the set.
+func (u *UnbroadcastSet) Contains(hash *chainhash.Hash) bool {
+	u.mtx.Lock()
+	_, ok := u.txns[*hash]
+	u.mtx.Unlock()
+	return ok
+}
+
+// Count returns the number of transactions in the set.
+func (u *UnbroadcastSet) Count() int {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return len(u.txns)
+}
+
+// Txns returns the transactions of the set ordered by the time they were
+// added.
+func (u *UnbroadcastSet) Txns() []UnbroadcastTx {
+	u.mtx.Lock()
+	txns := make([]UnbroadcastTx, 0, len(u.txns))
+	for _, tx := range u.txns {
+		txns = append(txns, *tx)
+	}
+	u.mtx.Unlock()
+
+	sort.Slice(txns, func(i, j int) bool {
+		return addedBefore(&txns[i], &txns[j])
+	})
+	return txns
+}
+
+// Due returns the descriptors of the transactions whose rebroadcast is due at
+// the passed time ordered by the time they were added, and schedules their
+// next rebroadcast after twice the previous interval, up to the maximum
+// interval of the set.
+func (u *UnbroadcastSet) Due(now time.Time) []*TxDesc {
+	u.mtx.Lock()
+	var due []*UnbroadcastTx
+	for _, tx := range u.txns {
+		if now.Before(tx.NextAttempt) {
+			continue
+		}
+		tx.Attempts++
+		tx.NextAttempt = now.Add(u.backoff(tx.Attempts))
+		due = append(due, tx)
+	}
+	u.mtx.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return addedBefore(due[i], due[j])
+	})
+	descs := make([]*TxDesc, 0, len(due))
+	for _, tx := range due {
+		descs = append(descs, tx.Desc)
+	}
+	return descs
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/mining"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestUnbroadcastSet ensures locally submitted transactions are rebroadcast
+// with an exponential backoff until they are removed.
+func TestUnbroadcastSet(t *testing.T) {
+	newTxDesc := func(lockTime uint32) *TxDesc {
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.LockTime = lockTime
+		return &TxDesc{TxDesc: mining.TxDesc{Tx: ulordutil.NewTx(msgTx)}}
+	}
+	dueHashes := func(set *UnbroadcastSet, now time.Time) []string {
+		var hashes []string
+		for _, txD := range set.Due(now) {
+			hashes = append(hashes, txD.Tx.Hash().String())
+		}
+		return hashes
+	}
+
+	set := NewUnbroadcastSet(time.Minute, 3*time.Minute)
+	start := time.Unix(1538000000, 0)
+	tx1, tx2 := newTxDesc(1), newTxDesc(2)
+	set.Add(tx1, start)
+	set.Add(tx2, start.Add(time.Second))
+	set.Add(tx1, start.Add(time.Minute))
+	if set.Count() != 2 {
+		t.Fatalf("Count: got %d, want 2", set.Count())
+	}
+	tx1Hash, tx2Hash := tx1.Tx.Hash().String(), tx2.Tx.Hash().String()
+
+	// Each step advances the clock and lists the transactions due at that
+	// time.  Intervals double from one minute up to the maximum of three.
+	tests := []struct {
+		after time.Duration
+		want  []string
+	}{
+		{30 * time.Second, nil},
+		{time.Minute, []string{tx1Hash}},
+		{time.Minute + time.Second, []string{tx2Hash}},
+		{2*time.Minute + 30*time.Second, nil},
+		{3 * time.Minute, []string{tx1Hash}},
+		{3*time.Minute + time.Second, []string{tx2Hash}},
+		{6 * time.Minute, []string{tx1Hash}},
+		{6*time.Minute + time.Second, []string{tx2Hash}},
+		{9 * time.Minute, []string{tx1Hash}},
+		{9*time.Minute + time.Second, []string{tx2Hash}},
+	}
+	for i, test := range tests {
+		got := dueHashes(set, start.Add(test.after))
+		if len(got) != len(test.want) {
+			t.Fatalf("Due #%d: got %v, want %v", i, got, test.want)
+		}
+		for j := range got {
+			if got[j] != test.want[j] {
+				t.Fatalf("Due #%d: got %v, want %v", i, got,
+					test.want)
+			}
+		}
+	}
+
+	txns := set.Txns()
+	if len(txns) != 2 || txns[0].Desc != tx1 || txns[0].Attempts != 4 {
+		t.Fatalf("Txns: unexpected transactions %+v", txns)
+	}
+
+	if !set.Remove(tx1.Tx.Hash()) || set.Remove(tx1.Tx.Hash()) {
+		t.Fatal("Remove: transaction removed more than once")
+	}
+	if set.Contains(tx1.Tx.Hash()) || !set.Contains(tx2.Tx.Hash()) {
+		t.Fatal("Contains: unexpected membership after Remove")
+	}
+	got := dueHashes(set, start.Add(time.Hour))
+	if len(got) != 1 || got[0] != tx2Hash {
+		t.Fatalf("Due: got %v after Remove, want [%v]", got, tx2Hash)
+	}
+}
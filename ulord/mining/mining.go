@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/ulordsuite/ulord/blockchain"
@@ -51,6 +52,17 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// PackageFeePerKB is the fee rate in Satoshi per 1000 bytes the
+	// transaction is selected by when extending a block template.  New
+	// block templates compute the fee rates of the ancestor packages of
+	// the transactions themselves instead.  It is the higher of
+	// FeePerKB and the best fee rate of the packages formed by each of its
+	// descendants in the source pool along with all of their unconfirmed
+	// ancestors, so a child paying a high fee raises the fee rate of its
+	// parents (child-pays-for-parent).  Zero indicates the source pool
+	// does not track packages, in which case FeePerKB is used.
+	PackageFeePerKB int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -81,11 +93,35 @@ type txPrioItem struct {
 	priority float64
 	feePerKB int64
 
+	// weight is the weight of the transaction.
+	weight int64
+
 	// dependsOn holds a map of transaction hashes which this one depends
 	// on.  It will only be set when the transaction references other
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// parents and children are the items whose transactions this one
+	// depends on and which depend on this one respectively.  numAncestors
+	// is the total number of items this one depends on, directly or
+	// indirectly.
+	parents      []*txPrioItem
+	children     []*txPrioItem
+	numAncestors int
+
+	// included and failed indicate whether the transaction has been
+	// included in the block or is unable to be included.  version is used
+	// to invalidate the stale package queue entries of the item.
+	included bool
+	failed   bool
+	version  int
+}
+
+// vsize returns the virtual size of the transaction.
+func (item *txPrioItem) vsize() int64 {
+	return (item.weight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -246,11 +282,13 @@ func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, e
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
 // based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// is nil, the coinbase transaction will instead be redeemable by anyone.  The
+// passed payments are added after the output paying the address, which is
+// reduced by the part of the subsidy used to pay them.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr ulordutil.Address) (*ulordutil.Tx, error) {
+func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr ulordutil.Address, payments []*wire.TxOut, fromSubsidy int64) (*ulordutil.Tx, error) {
 	// Create the script to pay to the provided payment address if one was
 	// specified.  Otherwise create a script that allows the coinbase to be
 	// redeemable by anyone.
@@ -280,9 +318,13 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		Sequence:        wire.MaxTxInSequenceNum,
 	})
 	tx.AddTxOut(&wire.TxOut{
-		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, params),
+		Value: blockchain.CalcBlockSubsidy(nextBlockHeight, params) -
+			fromSubsidy,
 		PkScript: pkScript,
 	})
+	for _, payment := range payments {
+		tx.AddTxOut(payment)
+	}
 	return ulordutil.NewTx(tx), nil
 }
 
@@ -301,17 +343,64 @@ func spendTransaction(utxoView *blockchain.UtxoViewpoint, tx *ulordutil.Tx, heig
 	return nil
 }
 
-// logSkippedDeps logs any dependencies which are also skipped as a result of
-// skipping a transaction while generating a block template at the trace level.
-func logSkippedDeps(tx *ulordutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
-	if deps == nil {
-		return
+// witnessCommitmentWeight returns the additional weight the passed coinbase
+// transaction takes once a witness commitment is added to it.  A model
+// coinbase transaction with a witness commitment is used to accurately
+// account for the weight of the witness nonce and the commitment output.
+func witnessCommitmentWeight(coinbaseTx *ulordutil.Tx) uint32 {
+	coinbaseCopy := ulordutil.NewTx(coinbaseTx.MsgTx().Copy())
+	coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
+		bytes.Repeat([]byte("a"), blockchain.CoinbaseWitnessDataLen),
 	}
+	coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
+		PkScript: bytes.Repeat([]byte("a"),
+			blockchain.CoinbaseWitnessPkScriptLength),
+	})
+
+	weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
+		blockchain.GetTransactionWeight(coinbaseTx)
+	return uint32(weightDiff)
+}
 
-	for _, item := range deps {
-		log.Tracef("Skipping tx %s since it depends on %s\n",
-			item.tx.Hash(), tx.Hash())
+// addWitnessCommitment adds a commitment to the witness data of the passed
+// block transactions as an OP_RETURN output to the coinbase transaction, which
+// must be the first of the transactions, and returns the commitment.
+func addWitnessCommitment(coinbaseTx *ulordutil.Tx, blockTxns []*ulordutil.Tx) []byte {
+	// The witness of the coinbase transaction MUST be exactly 32-bytes
+	// of all zeroes.
+	var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
+	coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
+
+	// Next, obtain the merkle root of a tree which consists of the
+	// wtxid of all transactions in the block. The coinbase
+	// transaction will have a special wtxid of all zeroes.
+	witnessMerkleTree := blockchain.BuildMerkleTreeStore(blockTxns, true)
+	witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
+
+	// The preimage to the witness commitment is:
+	// witnessRoot || coinbaseWitness
+	var witnessPreimage [64]byte
+	copy(witnessPreimage[:32], witnessMerkleRoot[:])
+	copy(witnessPreimage[32:], witnessNonce[:])
+
+	// The witness commitment itself is the double-sha256 of the
+	// witness preimage generated above. With the commitment
+	// generated, the witness script for the output is: OP_RETURN
+	// OP_DATA_36 {0xaa21a9ed || witnessCommitment}. The leading
+	// prefix is referred to as the "witness magic bytes".
+	witnessCommitment := chainhash.DoubleHashB(witnessPreimage[:])
+	witnessScript := append(blockchain.WitnessMagicBytes, witnessCommitment...)
+
+	// Finally, create the OP_RETURN carrying witness commitment
+	// output as an additional output within the coinbase.
+	commitmentOutput := &wire.TxOut{
+		Value:    0,
+		PkScript: witnessScript,
 	}
+	coinbaseTx.MsgTx().TxOut = append(coinbaseTx.MsgTx().TxOut,
+		commitmentOutput)
+
+	return witnessCommitment
 }
 
 // MinimumMedianTime returns the minimum allowed timestamp for a block building
@@ -353,6 +442,7 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+	payments    CoinbasePayments
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -367,6 +457,11 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 	sigCache *txscript.SigCache,
 	hashCache *txscript.HashCache) *BlkTmplGenerator {
 
+	payments := policy.CoinbasePayments
+	if payments == nil {
+		payments = NewConsensusPayments(params, nil)
+	}
+
 	return &BlkTmplGenerator{
 		policy:      policy,
 		chainParams: params,
@@ -375,7 +470,18 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 		timeSource:  timeSource,
 		sigCache:    sigCache,
 		hashCache:   hashCache,
+		payments:    payments,
+	}
+}
+
+// verifyFlags returns the script verification flags of the standardness
+// policy which are used to validate the scripts of the transactions included
+// in block templates.
+func (g *BlkTmplGenerator) verifyFlags() txscript.ScriptFlags {
+	if g.policy.Standardness != nil {
+		return g.policy.Standardness.VerifyFlags
 	}
+	return txscript.StandardVerifyFlags
 }
 
 // NewBlockTemplate returns a new block template that is ready to be solved
@@ -385,34 +491,37 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // functionality is useful since there are cases such as the getblocktemplate
 // RPC where external mining software is responsible for creating their own
 // coinbase which will replace the one generated for the block template.  Thus
-// the need to have configured address can be avoided.
+// the need to have configured address can be avoided.  The coinbase also makes
+// the payments provided by the CoinbasePayments of the policy, such as founder
+// rewards and masternode payments, after the output paying the address.
 //
 // The transactions selected and included are prioritized according to several
 // factors.  First, each transaction has a priority calculated based on its
 // value, age of inputs, and size.  Transactions which consist of larger
-// amounts, older inputs, and small sizes have the highest priority.  Second, a
-// fee per kilobyte is calculated for each transaction.  Transactions with a
-// higher fee per kilobyte are preferred.  Finally, the block generation related
-// policy settings are all taken into account.
+// amounts, older inputs, and small sizes have the highest priority.  Second,
+// each transaction forms a package with all of the transactions in the source
+// pool it depends on which have not been included yet, and a fee per kilobyte
+// is calculated for the package as a whole.  Packages with a higher fee per
+// kilobyte are preferred, so transactions whose descendants pay for them are
+// preferred as well (child-pays-for-parent).  Finally, the block generation
+// related policy settings are all taken into account.
 //
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
-// prioritizes based on the priority (then fee per kilobyte) or the fee per
-// kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
-// which spend outputs from other transactions in the source pool are added to a
-// dependency map so they can be added to the priority queue once the
-// transactions they depend on have been included.
+// When the BlockPrioritySize policy setting allots space for high-priority
+// transactions, that area is filled first with the transactions that have the
+// highest priority, where transactions which spend outputs from other
+// transactions in the source pool only become eligible once the transactions
+// they depend on have been included.  The high-priority area ends once it has
+// been filled, or the priority falls below what is considered high-priority.
 //
-// Once the high-priority area (if configured) has been filled with
-// transactions, or the priority falls below what is considered high-priority,
-// the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// The rest of the block is filled with the package with the highest fee per
+// kilobyte which is included as a whole, ordered so each transaction comes
+// after the ones it depends on, after which the packages of its descendants
+// are updated to no longer account for the included transactions.
 //
-// When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
-// transaction will be skipped unless the BlockMinSize policy setting is
-// nonzero, in which case the block will be filled with the low-fee/free
-// transactions until the block size reaches that minimum size.
+// When the package fees per kilobyte drop below the TxMinFreeFee policy
+// setting, the remaining packages will be skipped unless the BlockMinSize
+// policy setting is nonzero, in which case the block will be filled with the
+// low-fee/free packages until the block size reaches that minimum size.
 //
 // Any transactions which would cause the block to exceed the BlockMaxSize
 // policy setting, exceed the maximum allowed signature operations per block, or
@@ -430,7 +539,7 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |                                   |   |
 //  |                                   |   |
 //  |                                   |   |--- policy.BlockMaxSize
-//  |  Transactions prioritized by fee  |   |
+//  |  Packages prioritized by fee      |   |
 //  |  until <= policy.TxMinFreeFee     |   |
 //  |                                   |   |
 //  |                                   |   |
@@ -458,56 +567,69 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress ulordutil.Address) (*Bl
 	if err != nil {
 		return nil, err
 	}
+	payments, fromSubsidy, err := g.payments.CoinbasePayments(
+		nextBlockHeight, &best.Hash)
+	if err != nil {
+		return nil, err
+	}
 	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
-		nextBlockHeight, payToAddress)
+		nextBlockHeight, payToAddress, payments, fromSubsidy)
 	if err != nil {
 		return nil, err
 	}
 	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
 
-	// Get the current source transactions and create a priority queue to
-	// hold the transactions which are ready for inclusion into a block
-	// along with some priority related and fee metadata.  Reserve the same
-	// number of items that are available for the priority queue.  Also,
-	// choose the initial sort order for the priority queue based on whether
-	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := g.txSource.MiningDescs()
-	sortedByFee := g.policy.BlockPrioritySize == 0
-	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
-
-	// Create a slice to hold the transactions to be included in the
-	// generated block with reserved space.  Also create a utxo view to
-	// house all of the input transactions so multiple lookups can be
-	// avoided.
-	blockTxns := make([]*ulordutil.Tx, 0, len(sourceTxns))
-	blockTxns = append(blockTxns, coinbaseTx)
-	blockUtxos := blockchain.NewUtxoViewpoint()
+	// Query the version bits state to see if segwit has been activated, if
+	// so then this means that we'll include any transactions with witness
+	// data in the mempool, and also add the witness commitment as an
+	// OP_RETURN output in the coinbase transaction.
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
-
-	// Create slices to hold the fees and number of signature operations
-	// for each of the selected transactions and add an entry for the
-	// coinbase.  This allows the code below to simply append details about
-	// a transaction as it is selected for inclusion in the final block.
-	// However, since the total fees aren't known yet, use a dummy value for
-	// the coinbase fee which will be updated later.
-	txFees := make([]int64, 0, len(sourceTxns))
-	txSigOpCosts := make([]int64, 0, len(sourceTxns))
-	txFees = append(txFees, -1) // Updated once known
-	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	// Create a builder which houses the transactions to be included in the
+	// generated block along with their fees and signature operation costs
+	// starting with the coinbase.  Since the total fees aren't known yet, a
+	// dummy value is used for the coinbase fee which will be updated later.
+	// The builder also houses a utxo view of all of the input transactions
+	// so multiple lookups can be avoided.
+	//
+	// The starting block weight is the weight of the block header plus the
+	// max possible transaction count size, plus the weight of the coinbase
+	// transaction.
+	sourceTxns := g.txSource.MiningDescs()
+	b := &blockBuilder{
+		g:            g,
+		height:       nextBlockHeight,
+		segwitActive: segwitActive,
+		coinbaseTx:   coinbaseTx,
+		blockTxns:    make([]*ulordutil.Tx, 0, len(sourceTxns)),
+		blockUtxos:   blockchain.NewUtxoViewpoint(),
+		blockWeight: uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
+			blockchain.GetTransactionWeight(coinbaseTx)),
+		blockSigOpCost: coinbaseSigOpCost,
+		txFees:         make([]int64, 0, len(sourceTxns)),
+		txSigOpCosts:   make([]int64, 0, len(sourceTxns)),
+	}
+	b.blockTxns = append(b.blockTxns, coinbaseTx)
+	b.txFees = append(b.txFees, -1) // Updated once known
+	b.txSigOpCosts = append(b.txSigOpCosts, coinbaseSigOpCost)
 
 	log.Debugf("Considering %d transactions for inclusion to new block",
 		len(sourceTxns))
 
+	// Create an item for every transaction which is a candidate for
+	// inclusion in the block along with the transactions in the source
+	// pool it depends on.
+	items := make(map[chainhash.Hash]*txPrioItem, len(sourceTxns))
 mempoolLoop:
 	for _, txDesc := range sourceTxns {
 		// A block can't have more than one coinbase or contain
-		// non-finalized transactions.
+		// non-finalized transactions.  Also, if segregated witness has
+		// not been activated yet, then we shouldn't include any witness
+		// transactions in the block.
 		tx := txDesc.Tx
 		if blockchain.IsCoinBase(tx) {
 			log.Tracef("Skipping coinbase tx %s", tx.Hash())
@@ -519,6 +641,10 @@ mempoolLoop:
 			log.Tracef("Skipping non-finalized tx %s", tx.Hash())
 			continue
 		}
+		if !segwitActive && tx.HasWitness() {
+			log.Tracef("Skipping witness tx %s", tx.Hash())
+			continue
+		}
 
 		// Fetch all of the utxos referenced by the this transaction.
 		// NOTE: This intentionally does not fetch inputs from the
@@ -535,7 +661,12 @@ mempoolLoop:
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
 		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
+		prioItem := &txPrioItem{
+			tx:       tx,
+			fee:      txDesc.Fee,
+			feePerKB: txDesc.FeePerKB,
+			weight:   blockchain.GetTransactionWeight(tx),
+		}
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
 			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
@@ -551,21 +682,11 @@ mempoolLoop:
 				// The transaction is referencing another
 				// transaction in the source pool, so setup an
 				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
 				if prioItem.dependsOn == nil {
 					prioItem.dependsOn = make(
 						map[chainhash.Hash]struct{})
 				}
 				prioItem.dependsOn[*originHash] = struct{}{}
-
-				// Skip the check below. We already know the
-				// referenced transaction is available.
-				continue
 			}
 		}
 
@@ -574,221 +695,140 @@ mempoolLoop:
 		// formula is: sum(inputValue * inputAge) / adjustedTxSize
 		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
 			nextBlockHeight)
-
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
-		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+		items[*tx.Hash()] = prioItem
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
 		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
-	}
-
-	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
-
-	// The starting block size is the size of the block header plus the max
-	// possible transaction count size, plus the size of the coinbase
-	// transaction.
-	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
-		blockchain.GetTransactionWeight(coinbaseTx))
-	blockSigOpCost := coinbaseSigOpCost
-	totalFees := int64(0)
-
-	// Query the version bits state to see if segwit has been activated, if
-	// so then this means that we'll include any transactions with witness
-	// data in the mempool, and also add the witness commitment as an
-	// OP_RETURN output in the coinbase transaction.
-	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
-	if err != nil {
-		return nil, err
+		mergeUtxoView(b.blockUtxos, utxos)
 	}
-	segwitActive := segwitState == blockchain.ThresholdActive
-
-	witnessIncluded := false
-
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		tx := prioItem.tx
-
-		switch {
-		// If segregated witness has not been activated yet, then we
-		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
-			continue
-
-		// Otherwise, Keep track of if we've included a transaction
-		// with witness data or not. If so, then we'll need to include
-		// the witness commitment as the last output in the coinbase
-		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
-			// If we're about to include a transaction bearing
-			// witness data, then we'll also need to include a
-			// witness commitment in the coinbase transaction.
-			// Therefore, we account for the additional weight
-			// within the block with a model coinbase tx with a
-			// witness commitment.
-			coinbaseCopy := ulordutil.NewTx(coinbaseTx.MsgTx().Copy())
-			coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
-				bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessDataLen),
+	linkTxPrioItems(items)
+
+	// Fill the high-priority area, if configured, with the transactions
+	// which have the highest priority.  Transactions are only ready for
+	// inclusion once all of the transactions they depend on have been
+	// included.
+	if g.policy.BlockPrioritySize > 0 {
+		priorityQueue := newTxPriorityQueue(len(items), false)
+		for _, item := range items {
+			if !item.failed && len(item.parents) == 0 {
+				heap.Push(priorityQueue, item)
 			}
-			coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
-				PkScript: bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessPkScriptLength),
-			})
-
-			// In order to accurately account for the weight
-			// addition due to this coinbase transaction, we'll add
-			// the difference of the transaction before and after
-			// the addition of the commitment to the block weight.
-			weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
-				blockchain.GetTransactionWeight(coinbaseTx)
-
-			blockWeight += uint32(weightDiff)
-
-			witnessIncluded = true
 		}
+		for priorityQueue.Len() > 0 {
+			item := heap.Pop(priorityQueue).(*txPrioItem)
+			if item.priority <= MinHighPriority ||
+				!b.fits([]*txPrioItem{item},
+					g.policy.BlockPrioritySize) {
+
+				log.Tracef("Leaving high-priority area at tx "+
+					"%s with priority %.2f and block weight "+
+					"%d", item.tx.Hash(), item.priority,
+					b.blockWeight)
+				break
+			}
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
-
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+			if !b.addTx(item) {
+				markTxPrioItemFailed(item)
+				continue
+			}
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+			// Add transactions which depend on this one (and also
+			// do not have any other pending dependencies) to the
+			// priority queue.
+		childLoop:
+			for _, child := range item.children {
+				for _, parent := range child.parents {
+					if !parent.included {
+						continue childLoop
+					}
+				}
+				if !child.failed {
+					heap.Push(priorityQueue, child)
+				}
+			}
 		}
+	}
 
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
+	// Select the remaining transactions by the fee per kilobyte of the
+	// packages they form with all of their ancestors which have not been
+	// included yet so children pay for their parents.  The best package is
+	// included as a whole, after which the packages of the descendants of
+	// its transactions are updated since they no longer contain them.
+	packageQueue := make(txPackageQueue, 0, len(items))
+	for _, item := range items {
+		if !item.failed && !item.included {
+			packageQueue.pushPackage(item)
+		}
+	}
+	for packageQueue.Len() > 0 {
+		entry := heap.Pop(&packageQueue).(*txPackageEntry)
+		item := entry.item
+		if item.failed || item.included || entry.version != item.version {
 			continue
 		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
+		pkg, _, _ := txPackage(item)
+		if pkg == nil {
+			markTxPrioItemFailed(item)
 			continue
 		}
 
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
+		// Skip free packages once the block is larger than the minimum
+		// block weight.  Since packages are sorted by their fee per
+		// kilobyte, all of the remaining ones are free as well.
+		if entry.feePerKB < int64(g.policy.TxMinFreeFee) &&
+			b.blockWeight >= g.policy.BlockMinWeight {
 
-			log.Tracef("Skipping tx %s with feePerKB %d "+
-				"< TxMinFreeFee %d and block weight %d >= "+
-				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
+			log.Tracef("Skipping remaining packages with feePerKB "+
+				"%d < TxMinFreeFee %d and block weight %d >= "+
+				"minBlockWeight %d", entry.feePerKB,
+				g.policy.TxMinFreeFee, b.blockWeight,
 				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
+			break
+		}
+
+		// Skip the package when it doesn't fit.  Its ancestors may
+		// still be included on their own.
+		if !b.fits(pkg, g.policy.BlockMaxWeight) {
+			log.Tracef("Skipping tx %s because its package would "+
+				"exceed the max block weight", item.tx.Hash())
+			markTxPrioItemFailed(item)
 			continue
 		}
 
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
-			prioItem.priority <= MinHighPriority) {
-
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
-				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
-				prioItem.priority, MinHighPriority)
-
-			sortedByFee = true
-			priorityQueue.SetLessFunc(txPQByFee)
-
-			// Put the transaction back into the priority queue and
-			// skip it so it is re-priortized by fees if it won't
-			// fit into the high-priority section or the priority
-			// is too low.  Otherwise this transaction will be the
-			// final one in the high-priority section, so just fall
-			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
-				prioItem.priority < MinHighPriority {
-
-				heap.Push(priorityQueue, prioItem)
-				continue
+		// Include the transactions of the package.  A transaction that
+		// fails to be added causes the rest of the package to fail as
+		// well since they depend on it.
+		for _, pkgItem := range pkg {
+			if !b.addTx(pkgItem) {
+				markTxPrioItemFailed(pkgItem)
+				break
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		// Update the packages of the pending descendants of the
+		// transactions which were included.
+		descendants := make(map[*txPrioItem]struct{})
+		for _, pkgItem := range pkg {
+			if pkgItem.included {
+				txPrioItemDescendants(pkgItem, descendants)
+			}
 		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
-
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+		for descendant := range descendants {
+			if !descendant.failed && !descendant.included {
+				packageQueue.pushPackage(descendant)
 			}
 		}
 	}
 
+	blockTxns := b.blockTxns
+	blockWeight := b.blockWeight
+	blockSigOpCost := b.blockSigOpCost
+	totalFees := b.totalFees
+	txFees := b.txFees
+	txSigOpCosts := b.txSigOpCosts
+	witnessIncluded := b.witnessIncluded
+
 	// Now that the actual transactions have been selected, update the
 	// block weight for the real transaction count and coinbase value with
 	// the total fees accordingly.
@@ -803,40 +843,7 @@ mempoolLoop:
 	// OP_RETURN output within the coinbase transaction.
 	var witnessCommitment []byte
 	if witnessIncluded {
-		// The witness of the coinbase transaction MUST be exactly 32-bytes
-		// of all zeroes.
-		var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
-		coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
-
-		// Next, obtain the merkle root of a tree which consists of the
-		// wtxid of all transactions in the block. The coinbase
-		// transaction will have a special wtxid of all zeroes.
-		witnessMerkleTree := blockchain.BuildMerkleTreeStore(blockTxns,
-			true)
-		witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
-
-		// The preimage to the witness commitment is:
-		// witnessRoot || coinbaseWitness
-		var witnessPreimage [64]byte
-		copy(witnessPreimage[:32], witnessMerkleRoot[:])
-		copy(witnessPreimage[32:], witnessNonce[:])
-
-		// The witness commitment itself is the double-sha256 of the
-		// witness preimage generated above. With the commitment
-		// generated, the witness script for the output is: OP_RETURN
-		// OP_DATA_36 {0xaa21a9ed || witnessCommitment}. The leading
-		// prefix is referred to as the "witness magic bytes".
-		witnessCommitment = chainhash.DoubleHashB(witnessPreimage[:])
-		witnessScript := append(blockchain.WitnessMagicBytes, witnessCommitment...)
-
-		// Finally, create the OP_RETURN carrying witness commitment
-		// output as an additional output within the coinbase.
-		commitmentOutput := &wire.TxOut{
-			Value:    0,
-			PkScript: witnessScript,
-		}
-		coinbaseTx.MsgTx().TxOut = append(coinbaseTx.MsgTx().TxOut,
-			commitmentOutput)
+		witnessCommitment = addWitnessCommitment(coinbaseTx, blockTxns)
 	}
 
 	// Calculate the required difficulty for the block.  The timestamp
@@ -895,6 +902,267 @@ mempoolLoop:
 	}, nil
 }
 
+// ExtendBlockTemplate appends the transactions which were added to the source
+// pool after the passed block template was generated to it without selecting
+// all of the transactions of the template again.  This allows templates to be
+// kept up to date with new transactions far more cheaply than generating a new
+// one with NewBlockTemplate.
+//
+// Only transactions paying at least the TxMinFreeFee policy setting whose
+// inputs are either confirmed or created by transactions in the template are
+// considered, in order of their fee per kilobyte, and the maximum block weight
+// and signature operation cost are enforced.  Transactions which conflict
+// with ones already in the template are skipped.  The coinbase value, witness
+// commitment, and merkle root of the template are updated accordingly and the
+// number of added transactions is returned.
+//
+// An error is returned and the template is left unmodified when it does not
+// build on the current best chain, in which case a new template must be
+// generated instead.
+//
+// The passed template must not be accessed concurrently.
+func (g *BlkTmplGenerator) ExtendBlockTemplate(template *BlockTemplate) (int, error) {
+	best := g.chain.BestSnapshot()
+	msgBlock := template.Block
+	if msgBlock.Header.PrevBlock != best.Hash {
+		return 0, fmt.Errorf("block template builds on %v instead of "+
+			"the current best block %v", msgBlock.Header.PrevBlock,
+			best.Hash)
+	}
+	nextBlockHeight := template.Height
+
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return 0, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
+
+	// Create a utxo view which houses the outputs of the transactions
+	// already in the template so new transactions are able to spend them.
+	// Also keep track of the outputs they spend in order to skip any new
+	// transactions which conflict with them.
+	blockTxns := make([]*ulordutil.Tx, 0, len(msgBlock.Transactions))
+	inTemplate := make(map[chainhash.Hash]struct{},
+		len(msgBlock.Transactions))
+	spent := make(map[wire.OutPoint]struct{})
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	for i, msgTx := range msgBlock.Transactions {
+		tx := ulordutil.NewTx(msgTx)
+		blockTxns = append(blockTxns, tx)
+		inTemplate[*tx.Hash()] = struct{}{}
+		if i == 0 {
+			continue
+		}
+		for _, txIn := range msgTx.TxIn {
+			spent[txIn.PreviousOutPoint] = struct{}{}
+		}
+		blockUtxos.AddTxOuts(tx, nextBlockHeight)
+	}
+	numTemplateTxns := len(blockTxns)
+
+	// The current weight of the template is padded with the max possible
+	// growth of the transaction count.
+	blockWeight := uint32(blockchain.GetBlockWeight(ulordutil.NewBlock(
+		msgBlock)) + wire.MaxVarIntPayload*blockchain.WitnessScaleFactor)
+	var blockSigOpCost int64
+	for _, sigOpCost := range template.SigOpCosts {
+		blockSigOpCost += sigOpCost
+	}
+	coinbaseTx := ulordutil.NewTx(msgBlock.Transactions[0].Copy())
+	witnessIncluded := template.WitnessCommitment != nil
+
+	// Gather the transactions which are not in the template yet and sort
+	// them by their fee per kilobyte, preferring the package fee rate when
+	// available.
+	feePerKB := func(txDesc *TxDesc) int64 {
+		if txDesc.PackageFeePerKB > txDesc.FeePerKB {
+			return txDesc.PackageFeePerKB
+		}
+		return txDesc.FeePerKB
+	}
+	var candidates []*TxDesc
+	for _, txDesc := range g.txSource.MiningDescs() {
+		if _, ok := inTemplate[*txDesc.Tx.Hash()]; ok {
+			continue
+		}
+		if feePerKB(txDesc) < int64(g.policy.TxMinFreeFee) {
+			continue
+		}
+		candidates = append(candidates, txDesc)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return feePerKB(candidates[i]) > feePerKB(candidates[j])
+	})
+
+	// Add the candidates until none of the remaining ones are able to be
+	// added.  Multiple passes are made since a candidate may spend the
+	// outputs of another one which is added after it.  Candidates which
+	// can never be added are removed by setting them to nil.
+	var txFees, txSigOpCosts []int64
+	totalFees := int64(0)
+	for {
+		numAdded := len(blockTxns)
+
+	candidateLoop:
+		for i, txDesc := range candidates {
+			if txDesc == nil {
+				continue
+			}
+			tx := txDesc.Tx
+			if blockchain.IsCoinBase(tx) || (!segwitActive &&
+				tx.HasWitness()) || !blockchain.IsFinalizedTransaction(
+				tx, nextBlockHeight, g.timeSource.AdjustedTime()) {
+
+				candidates[i] = nil
+				continue
+			}
+
+			// Ensure all of the outputs the transaction spends are
+			// either confirmed or created by transactions in the
+			// template and are not spent by any of them.
+			utxos, err := g.chain.FetchUtxoView(tx)
+			if err != nil {
+				log.Warnf("Unable to fetch utxo view for tx "+
+					"%s: %v", tx.Hash(), err)
+				candidates[i] = nil
+				continue
+			}
+			for _, txIn := range tx.MsgTx().TxIn {
+				prevOut := txIn.PreviousOutPoint
+				if _, ok := spent[prevOut]; ok {
+					log.Tracef("Skipping tx %s because it "+
+						"conflicts with the block "+
+						"template", tx.Hash())
+					candidates[i] = nil
+					continue candidateLoop
+				}
+				if blockUtxos.LookupEntry(prevOut) != nil {
+					continue
+				}
+				entry := utxos.LookupEntry(prevOut)
+				if entry == nil || entry.IsSpent() {
+					// The output might be created by a
+					// candidate added later on.
+					continue candidateLoop
+				}
+			}
+			mergeUtxoView(blockUtxos, utxos)
+
+			// Enforce the maximum block weight while accounting for
+			// the witness commitment which has to be added to the
+			// coinbase for the first transaction with witness data.
+			txWeight := uint32(blockchain.GetTransactionWeight(tx))
+			if !witnessIncluded && tx.HasWitness() {
+				txWeight += witnessCommitmentWeight(coinbaseTx)
+			}
+			blockPlusTxWeight := blockWeight + txWeight
+			if blockPlusTxWeight < blockWeight ||
+				blockPlusTxWeight >= g.policy.BlockMaxWeight {
+
+				candidates[i] = nil
+				continue
+			}
+
+			// Enforce the maximum signature operation cost per
+			// block.
+			sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+				blockUtxos, true, segwitActive)
+			if err != nil || blockSigOpCost+int64(sigOpCost) >
+				blockchain.MaxBlockSigOpsCost {
+
+				candidates[i] = nil
+				continue
+			}
+
+			// Ensure the transaction inputs pass all of the
+			// necessary preconditions before adding it.
+			_, err = blockchain.CheckTransactionInputs(tx,
+				nextBlockHeight, blockUtxos, g.chainParams)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"CheckTransactionInputs: %v", tx.Hash(),
+					err)
+				candidates[i] = nil
+				continue
+			}
+			err = blockchain.ValidateTransactionScripts(tx,
+				blockUtxos, g.verifyFlags(), g.sigCache,
+				g.hashCache)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"ValidateTransactionScripts: %v",
+					tx.Hash(), err)
+				candidates[i] = nil
+				continue
+			}
+
+			spendTransaction(blockUtxos, tx, nextBlockHeight)
+			for _, txIn := range tx.MsgTx().TxIn {
+				spent[txIn.PreviousOutPoint] = struct{}{}
+			}
+			blockTxns = append(blockTxns, tx)
+			blockWeight = blockPlusTxWeight
+			blockSigOpCost += int64(sigOpCost)
+			totalFees += txDesc.Fee
+			txFees = append(txFees, txDesc.Fee)
+			txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+			if tx.HasWitness() {
+				witnessIncluded = true
+			}
+			candidates[i] = nil
+		}
+
+		if len(blockTxns) == numAdded {
+			break
+		}
+	}
+	numAdded := len(blockTxns) - numTemplateTxns
+	if numAdded == 0 {
+		return 0, nil
+	}
+
+	// Update the coinbase value with the additional fees and replace the
+	// witness commitment, which is always the final coinbase output, when
+	// the template has one.
+	coinbase := coinbaseTx.MsgTx()
+	coinbase.TxOut[0].Value += totalFees
+	if template.WitnessCommitment != nil {
+		coinbase.TxOut = coinbase.TxOut[:len(coinbase.TxOut)-1]
+	}
+	blockTxns[0] = coinbaseTx
+	var witnessCommitment []byte
+	if witnessIncluded {
+		witnessCommitment = addWitnessCommitment(coinbaseTx, blockTxns)
+	}
+
+	// Create the extended block and perform a full check on it against
+	// the chain consensus rules before updating the template.
+	merkles := blockchain.BuildMerkleTreeStore(blockTxns, false)
+	extended := &wire.MsgBlock{Header: msgBlock.Header}
+	extended.Header.MerkleRoot = *merkles[len(merkles)-1]
+	for _, tx := range blockTxns {
+		if err := extended.AddTransaction(tx.MsgTx()); err != nil {
+			return 0, err
+		}
+	}
+	block := ulordutil.NewBlock(extended)
+	block.SetHeight(nextBlockHeight)
+	if err := g.chain.CheckConnectBlockTemplate(block); err != nil {
+		return 0, err
+	}
+
+	template.Block = extended
+	template.Fees[0] -= totalFees
+	template.Fees = append(template.Fees, txFees...)
+	template.SigOpCosts = append(template.SigOpCosts, txSigOpCosts...)
+	template.WitnessCommitment = witnessCommitment
+
+	log.Debugf("Extended block template with %d transactions (%d in "+
+		"fees, %d weight)", numAdded, totalFees, blockWeight)
+
+	return numAdded, nil
+}
+
 // UpdateBlockTime updates the timestamp in the header of the passed block to
 // the current time while taking into account the median time of the last
 // several blocks to ensure the new time is after that time per the chain
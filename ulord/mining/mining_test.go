@@ -5,10 +5,16 @@
 package mining
 
 import (
+	"bytes"
 	"container/heap"
 	"math/rand"
 	"testing"
 
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
 )
 
@@ -108,3 +114,49 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestAddWitnessCommitment ensures the witness commitment added to a coinbase
+// transaction commits to the witness data of the block transactions and that
+// the weight reserved for it matches the actual weight.
+func TestAddWitnessCommitment(t *testing.T) {
+	coinbaseScript, err := standardCoinbaseScript(100, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: unexpected error: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(&chaincfg.MainNetParams,
+		coinbaseScript, 100, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: unexpected error: %v", err)
+	}
+
+	witnessTx := wire.NewMsgTx(wire.TxVersion)
+	txIn := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil,
+		wire.TxWitness{{0x01}, {0x02, 0x03}})
+	witnessTx.AddTxIn(txIn)
+	witnessTx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+
+	weight := witnessCommitmentWeight(coinbaseTx)
+	oldWeight := blockchain.GetTransactionWeight(coinbaseTx)
+	blockTxns := []*ulordutil.Tx{coinbaseTx, ulordutil.NewTx(witnessTx)}
+	commitment := addWitnessCommitment(coinbaseTx, blockTxns)
+	newWeight := blockchain.GetTransactionWeight(coinbaseTx)
+	if int64(weight) != newWeight-oldWeight {
+		t.Fatalf("witnessCommitmentWeight: got %d, want %d", weight,
+			newWeight-oldWeight)
+	}
+
+	extracted, ok := blockchain.ExtractWitnessCommitment(coinbaseTx)
+	if !ok || !bytes.Equal(extracted, commitment) {
+		t.Fatalf("addWitnessCommitment: unexpected commitment %x, "+
+			"want %x", extracted, commitment)
+	}
+
+	var msgBlock wire.MsgBlock
+	for _, tx := range blockTxns {
+		msgBlock.AddTransaction(tx.MsgTx())
+	}
+	err = blockchain.ValidateWitnessCommitment(ulordutil.NewBlock(&msgBlock))
+	if err != nil {
+		t.Fatalf("ValidateWitnessCommitment: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// linkTxPrioItems links each of the passed items with the items whose
+// transactions it depends on and the items whose transactions depend on it.
+// Items depending on a transaction which is not one of the passed items can
+// never be included in a block, so they are marked as failed along with all of
+// their descendants.
+func linkTxPrioItems(items map[chainhash.Hash]*txPrioItem) {
+	for _, item := range items {
+		for hash := range item.dependsOn {
+			parent, ok := items[hash]
+			if !ok {
+				item.failed = true
+				continue
+			}
+			item.parents = append(item.parents, parent)
+			parent.children = append(parent.children, item)
+		}
+	}
+	for _, item := range items {
+		if item.failed {
+			markTxPrioItemFailed(item)
+		}
+	}
+
+	// Count the ancestors of every item so the transactions of a package
+	// can be ordered such that each one comes after those it depends on.
+	for _, item := range items {
+		ancestors := make(map[*txPrioItem]struct{})
+		txPrioItemAncestors(item, ancestors, false)
+		item.numAncestors = len(ancestors)
+	}
+}
+
+// markTxPrioItemFailed marks the passed item and all of its descendants as
+// failed since none of them are able to be included in a block.  The skipped
+// descendants are logged at the trace level.
+func markTxPrioItemFailed(item *txPrioItem) {
+	item.failed = true
+	for _, child := range item.children {
+		if !child.failed {
+			log.Tracef("Skipping tx %s since it depends on %s",
+				child.tx.Hash(), item.tx.Hash())
+			markTxPrioItemFailed(child)
+		}
+	}
+}
+
+// txPrioItemAncestors adds the ancestors of the passed item to the provided
+// set.  Ancestors that have already been included in the block are skipped
+// when onlyPending is set.
+func txPrioItemAncestors(item *txPrioItem, ancestors map[*txPrioItem]struct{}, onlyPending bool) {
+	for _, parent := range item.parents {
+		if onlyPending && parent.included {
+			continue
+		}
+		if _, ok := ancestors[parent]; ok {
+			continue
+		}
+		ancestors[parent] = struct{}{}
+		txPrioItemAncestors(parent, ancestors, onlyPending)
+	}
+}
+
+// txPrioItemDescendants adds the descendants of the passed item to the
+// provided set.
+func txPrioItemDescendants(item *txPrioItem, descendants map[*txPrioItem]struct{}) {
+	for _, child := range item.children {
+		if _, ok := descendants[child]; ok {
+			continue
+		}
+		descendants[child] = struct{}{}
+		txPrioItemDescendants(child, descendants)
+	}
+}
+
+// txPackage returns the transactions of the package formed by the passed item
+// and all of its ancestors which have not been included in the block yet,
+// ordered such that each transaction comes after those it depends on, along
+// with the total fee and virtual size of the package.  Nil is returned when one
+// of the ancestors has failed to be included.
+func txPackage(item *txPrioItem) ([]*txPrioItem, int64, int64) {
+	ancestors := make(map[*txPrioItem]struct{})
+	txPrioItemAncestors(item, ancestors, true)
+
+	pkg := make([]*txPrioItem, 0, len(ancestors)+1)
+	fee, vsize := item.fee, item.vsize()
+	for ancestor := range ancestors {
+		if ancestor.failed {
+			return nil, 0, 0
+		}
+		pkg = append(pkg, ancestor)
+		fee += ancestor.fee
+		vsize += ancestor.vsize()
+	}
+	sort.Slice(pkg, func(i, j int) bool {
+		return pkg[i].numAncestors < pkg[j].numAncestors
+	})
+	pkg = append(pkg, item)
+
+	return pkg, fee, vsize
+}
+
+// txPackageEntry is an entry of a txPackageQueue which houses the fee per
+// kilobyte of the package formed by an item and its ancestors when the entry
+// was created.  Entries are invalidated by updating the version of the item.
+type txPackageEntry struct {
+	item     *txPrioItem
+	feePerKB int64
+	version  int
+}
+
+// txPackageQueue implements a priority queue of package entries which pops the
+// entry with the highest package fee per kilobyte first.  It implements the
+// heap.Interface.
+type txPackageQueue []*txPackageEntry
+
+// Len returns the number of entries in the queue.  It is part of the
+// heap.Interface implementation.
+func (pq txPackageQueue) Len() int {
+	return len(pq)
+}
+
+// Less returns whether the entry with index i should sort before the entry
+// with index j.  It is part of the heap.Interface implementation.
+func (pq txPackageQueue) Less(i, j int) bool {
+	if pq[i].feePerKB == pq[j].feePerKB {
+		return pq[i].item.priority > pq[j].item.priority
+	}
+	return pq[i].feePerKB > pq[j].feePerKB
+}
+
+// Swap swaps the entries at the passed indices.  It is part of the
+// heap.Interface implementation.
+func (pq txPackageQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+}
+
+// Push pushes the passed entry onto the queue.  It is part of the
+// heap.Interface implementation.
+func (pq *txPackageQueue) Push(x interface{}) {
+	*pq = append(*pq, x.(*txPackageEntry))
+}
+
+// Pop removes the entry with the highest package fee per kilobyte from the
+// queue and returns it.  It is part of the heap.Interface implementation.
+func (pq *txPackageQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*pq = old[0 : n-1]
+	return entry
+}
+
+// pushPackage pushes a new entry for the package formed by the passed item and
+// its pending ancestors onto the queue, invalidating any previous entries for
+// the item.
+func (pq *txPackageQueue) pushPackage(item *txPrioItem) {
+	_, fee, vsize := txPackage(item)
+	if vsize == 0 {
+		return
+	}
+	item.version++
+	heap.Push(pq, &txPackageEntry{
+		item:     item,
+		feePerKB: fee * 1000 / vsize,
+		version:  item.version,
+	})
+}
+
+// blockBuilder houses the state of a block template while transactions are
+// being selected for it.
+type blockBuilder struct {
+	g               *BlkTmplGenerator
+	height          int32
+	segwitActive    bool
+	coinbaseTx      *ulordutil.Tx
+	blockTxns       []*ulordutil.Tx
+	blockUtxos      *blockchain.UtxoViewpoint
+	blockWeight     uint32
+	blockSigOpCost  int64
+	totalFees       int64
+	txFees          []int64
+	txSigOpCosts    []int64
+	witnessIncluded bool
+}
+
+// txWeight returns the weight the transaction of the passed item adds to the
+// block, including the weight of the witness commitment which has to be added
+// to the coinbase for the first transaction with witness data.
+func (b *blockBuilder) txWeight(item *txPrioItem, witnessIncluded bool) uint32 {
+	weight := uint32(item.weight)
+	if !witnessIncluded && item.tx.HasWitness() {
+		weight += witnessCommitmentWeight(b.coinbaseTx)
+	}
+	return weight
+}
+
+// fits returns whether or not the transactions of the passed items all fit in
+// the block within the passed maximum weight.
+func (b *blockBuilder) fits(items []*txPrioItem, maxWeight uint32) bool {
+	weight := b.blockWeight
+	witnessIncluded := b.witnessIncluded
+	for _, item := range items {
+		newWeight := weight + b.txWeight(item, witnessIncluded)
+		if newWeight < weight || newWeight >= maxWeight {
+			return false
+		}
+		weight = newWeight
+		witnessIncluded = witnessIncluded || item.tx.HasWitness()
+	}
+	return true
+}
+
+// addTx adds the transaction of the passed item to the block when it does not
+// exceed the maximum block weight and signature operation cost and passes all
+// of the necessary preconditions.  It returns whether or not the transaction
+// was added.
+func (b *blockBuilder) addTx(item *txPrioItem) bool {
+	g := b.g
+	tx := item.tx
+
+	// Enforce maximum block weight.  Also check for overflow.
+	blockPlusTxWeight := b.blockWeight + b.txWeight(item, b.witnessIncluded)
+	if blockPlusTxWeight < b.blockWeight ||
+		blockPlusTxWeight >= g.policy.BlockMaxWeight {
+
+		log.Tracef("Skipping tx %s because it would exceed "+
+			"the max block weight", tx.Hash())
+		return false
+	}
+
+	// Enforce maximum signature operation cost per block.  Also check for
+	// overflow.
+	sigOpCost, err := blockchain.GetSigOpCost(tx, false, b.blockUtxos, true,
+		b.segwitActive)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in "+
+			"GetSigOpCost: %v", tx.Hash(), err)
+		return false
+	}
+	if b.blockSigOpCost+int64(sigOpCost) < b.blockSigOpCost ||
+		b.blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+		log.Tracef("Skipping tx %s because it would "+
+			"exceed the maximum sigops per block", tx.Hash())
+		return false
+	}
+
+	// Ensure the transaction inputs pass all of the necessary
+	// preconditions before allowing it to be added to the block.
+	_, err = blockchain.CheckTransactionInputs(tx, b.height, b.blockUtxos,
+		g.chainParams)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in "+
+			"CheckTransactionInputs: %v", tx.Hash(), err)
+		return false
+	}
+	err = blockchain.ValidateTransactionScripts(tx, b.blockUtxos,
+		g.verifyFlags(), g.sigCache, g.hashCache)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in "+
+			"ValidateTransactionScripts: %v", tx.Hash(), err)
+		return false
+	}
+
+	// Spend the transaction inputs in the block utxo view and add an entry
+	// for it to ensure any transactions which reference this one have it
+	// available as an input and can ensure they aren't double spending.
+	spendTransaction(b.blockUtxos, tx, b.height)
+
+	// Add the transaction to the block, increment counters, and save the
+	// fees and signature operation counts to the block template.
+	b.blockTxns = append(b.blockTxns, tx)
+	b.blockWeight = blockPlusTxWeight
+	b.blockSigOpCost += int64(sigOpCost)
+	b.totalFees += item.fee
+	b.txFees = append(b.txFees, item.fee)
+	b.txSigOpCosts = append(b.txSigOpCosts, int64(sigOpCost))
+	b.witnessIncluded = b.witnessIncluded || tx.HasWitness()
+	item.included = true
+
+	log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)", tx.Hash(),
+		item.priority, item.feePerKB)
+
+	return true
+}
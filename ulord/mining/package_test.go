@@ -0,0 +1,124 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"container/heap"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// newTestPrioItem returns an item for a transaction spending the first output
+// of each of the passed parents, or a confirmed output when there are none.
+func newTestPrioItem(fee, weight int64, parents ...*txPrioItem) *txPrioItem {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	item := &txPrioItem{fee: fee, weight: weight}
+	if len(parents) == 0 {
+		var hash chainhash.Hash
+		hash[0] = byte(fee)
+		hash[1] = byte(weight)
+		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, 0), nil, nil))
+	}
+	for _, parent := range parents {
+		hash := parent.tx.Hash()
+		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+		if item.dependsOn == nil {
+			item.dependsOn = make(map[chainhash.Hash]struct{})
+		}
+		item.dependsOn[*hash] = struct{}{}
+	}
+	msgTx.AddTxOut(wire.NewTxOut(fee, nil))
+	item.tx = ulordutil.NewTx(msgTx)
+	return item
+}
+
+// TestTxPackages ensures packages are formed from the pending ancestors of a
+// transaction in dependency order and that the package queue selects children
+// which pay for their parents first.
+func TestTxPackages(t *testing.T) {
+	// The parent pays a low fee which is made up for by its child, while
+	// the unrelated transaction pays an average fee.  The orphan depends
+	// on a transaction which is not a candidate.
+	parent := newTestPrioItem(100, 4000)
+	child := newTestPrioItem(5000, 4000, parent)
+	grandchild := newTestPrioItem(10, 4000, child)
+	unrelated := newTestPrioItem(1500, 4000)
+	missing := newTestPrioItem(1, 400)
+	orphan := newTestPrioItem(9000, 400, missing)
+	orphanChild := newTestPrioItem(9000, 400, orphan)
+
+	items := make(map[chainhash.Hash]*txPrioItem)
+	for _, item := range []*txPrioItem{parent, child, grandchild,
+		unrelated, orphan, orphanChild} {
+
+		items[*item.tx.Hash()] = item
+	}
+	linkTxPrioItems(items)
+
+	if !orphan.failed || !orphanChild.failed {
+		t.Fatal("transactions depending on a missing transaction are " +
+			"not marked as failed")
+	}
+	if grandchild.numAncestors != 2 {
+		t.Fatalf("unexpected number of ancestors: got %d, want 2",
+			grandchild.numAncestors)
+	}
+
+	pkg, fee, vsize := txPackage(grandchild)
+	if len(pkg) != 3 || pkg[0] != parent || pkg[1] != child ||
+		pkg[2] != grandchild {
+
+		t.Fatalf("unexpected package order: %v", pkg)
+	}
+	if fee != 5110 || vsize != 3000 {
+		t.Fatalf("unexpected package fee and size: got %d and %d, "+
+			"want 5110 and 3000", fee, vsize)
+	}
+	if pkg, _, _ := txPackage(orphanChild); pkg != nil {
+		t.Fatal("package with a failed ancestor was formed")
+	}
+
+	// The package of the child has the highest fee rate, so it must be
+	// selected first even though the fee rate of the parent is lowest.
+	var queue txPackageQueue
+	for _, item := range items {
+		if !item.failed {
+			queue.pushPackage(item)
+		}
+	}
+	entry := heap.Pop(&queue).(*txPackageEntry)
+	if entry.item != child {
+		t.Fatalf("unexpected first package: got %v, want %v",
+			entry.item.tx.Hash(), child.tx.Hash())
+	}
+
+	// Once the parent and child are included, the package of the
+	// grandchild only consists of itself and stale entries are skipped by
+	// their version.
+	parent.included = true
+	child.included = true
+	staleVersion := grandchild.version
+	queue.pushPackage(grandchild)
+	if grandchild.version == staleVersion {
+		t.Fatal("package entry version was not updated")
+	}
+	pkg, fee, _ = txPackage(grandchild)
+	if len(pkg) != 1 || fee != 10 {
+		t.Fatalf("unexpected package after including ancestors: %d "+
+			"transactions with fee %d", len(pkg), fee)
+	}
+	entry = heap.Pop(&queue).(*txPackageEntry)
+	if entry.item != grandchild || entry.version == grandchild.version {
+		t.Fatal("stale package entry was not popped first")
+	}
+	entry = heap.Pop(&queue).(*txPackageEntry)
+	if entry.item != unrelated {
+		t.Fatalf("unexpected next package: got %v, want %v",
+			entry.item.tx.Hash(), unrelated.tx.Hash())
+	}
+}
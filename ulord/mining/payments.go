@@ -0,0 +1,109 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// CoinbasePayments provides the outputs the coinbase transaction of a block
+// template must contain in addition to the output paying the miner.
+type CoinbasePayments interface {
+	// CoinbasePayments returns the outputs the coinbase transaction of the
+	// block at the given height, which extends the block with the given
+	// hash, must contain along with the amount of the block subsidy which
+	// is used to pay them.  The output paying the miner is reduced by that
+	// amount.
+	CoinbasePayments(height int32, prevHash *chainhash.Hash) ([]*wire.TxOut, int64, error)
+}
+
+// ConsensusPayments provides the founder rewards, masternode payments and
+// superblock payments the consensus rules require coinbase transactions to
+// make.  It implements the CoinbasePayments interface.
+type ConsensusPayments struct {
+	chainParams *chaincfg.Params
+	payees      blockchain.PayeeSource
+}
+
+// Ensure ConsensusPayments implements the CoinbasePayments interface.
+var _ CoinbasePayments = (*ConsensusPayments)(nil)
+
+// NewConsensusPayments returns the coinbase payments required by the passed
+// chain parameters with the masternode and superblock payees chosen by the
+// provided payee source.  The payee source may be nil for chains which don't
+// require masternode payments, in which case no superblock payments are made
+// either.
+func NewConsensusPayments(params *chaincfg.Params, payees blockchain.PayeeSource) *ConsensusPayments {
+	return &ConsensusPayments{
+		chainParams: params,
+		payees:      payees,
+	}
+}
+
+// CoinbasePayments returns the outputs the coinbase transaction of the block at
+// the given height must contain.
+//
+// Founder rewards and the masternode payment are shares of the block subsidy.
+// An error is returned when the block must make a masternode payment and there
+// is no payee source or it does not know the payee, since the share must not
+// be paid to the miner instead.  Superblock payments are paid from the budget
+// of the superblock cycle on top of the block subsidy.
+//
+// This is part of the CoinbasePayments interface implementation.
+func (p *ConsensusPayments) CoinbasePayments(height int32, prevHash *chainhash.Hash) ([]*wire.TxOut, int64, error) {
+	schedule := &p.chainParams.Subsidy
+	subsidy := schedule.BlockSubsidy(height)
+
+	var payments []*wire.TxOut
+	var fromSubsidy int64
+	for _, payout := range schedule.FounderPayouts(height) {
+		payments = append(payments, wire.NewTxOut(payout.Amount,
+			payout.PkScript))
+		fromSubsidy += payout.Amount
+	}
+
+	if amount := schedule.MasternodePayment(height); amount > 0 {
+		if p.payees == nil {
+			return nil, 0, fmt.Errorf("block at height %d must pay "+
+				"a masternode but there is no payee source",
+				height)
+		}
+		payee := p.payees.MasternodePayee(height, prevHash)
+		if payee == nil {
+			return nil, 0, fmt.Errorf("the masternode payee of the "+
+				"block at height %d is not known", height)
+		}
+		payments = append(payments, wire.NewTxOut(amount, payee))
+		fromSubsidy += amount
+	}
+
+	if fromSubsidy > subsidy {
+		return nil, 0, fmt.Errorf("coinbase payments of %d for block "+
+			"at height %d exceed the block subsidy of %d",
+			fromSubsidy, height, subsidy)
+	}
+
+	if p.payees != nil && schedule.IsSuperblock(height) {
+		var total int64
+		for _, payment := range p.payees.SuperblockPayments(height) {
+			total += payment.Value
+			payments = append(payments, wire.NewTxOut(payment.Value,
+				payment.PkScript))
+		}
+		budget := schedule.SuperblockBudget(height)
+		if total > budget {
+			return nil, 0, fmt.Errorf("payments of superblock at "+
+				"height %d total %d which is more than the "+
+				"budget of %d", height, total, budget)
+		}
+	}
+
+	return payments, fromSubsidy, nil
+}
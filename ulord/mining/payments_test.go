@@ -0,0 +1,181 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// testPayees is a blockchain.PayeeSource returning fixed payees.
+type testPayees struct {
+	masternode []byte
+	superblock []*wire.TxOut
+}
+
+func (p *testPayees) MasternodePayee(int32, *chainhash.Hash) []byte {
+	return p.masternode
+}
+
+func (p *testPayees) SuperblockPayments(int32) []*wire.TxOut {
+	return p.superblock
+}
+
+// TestConsensusPayments ensures the founder rewards, masternode payments and
+// superblock payments are allocated as required by the consensus rules.
+func TestConsensusPayments(t *testing.T) {
+	founderScript := []byte{0x51}
+	masternodeScript := []byte{0x52}
+	proposalScript := []byte{0x53}
+
+	params := chaincfg.RegressionNetParams
+	params.Subsidy.FounderRewards = []chaincfg.FounderReward{
+		{StartHeight: 10, EndHeight: 19, PkScript: founderScript,
+			Percent: 10},
+	}
+	params.Subsidy.MasternodePaymentHeight = 15
+	params.Subsidy.MasternodePaymentPercent = 50
+	params.Subsidy.SuperblockStartHeight = 20
+	params.Subsidy.SuperblockCycle = 10
+	params.Subsidy.SuperblockBudgetPercent = 10
+	subsidy := blockchain.CalcBlockSubsidy(0, &params)
+	budget := blockchain.CalcSuperblockBudget(20, &params)
+
+	type payment struct {
+		value    int64
+		pkScript []byte
+	}
+	tests := []struct {
+		name        string
+		height      int32
+		payees      blockchain.PayeeSource
+		payments    []payment
+		fromSubsidy int64
+		err         bool
+	}{{
+		name:   "no payments",
+		height: 5,
+		payees: &testPayees{masternode: masternodeScript},
+	}, {
+		name:        "founder reward",
+		height:      10,
+		payees:      &testPayees{masternode: masternodeScript},
+		payments:    []payment{{subsidy / 10, founderScript}},
+		fromSubsidy: subsidy / 10,
+	}, {
+		name:   "founder reward and masternode",
+		height: 15,
+		payees: &testPayees{masternode: masternodeScript},
+		payments: []payment{
+			{subsidy / 10, founderScript},
+			{subsidy / 2, masternodeScript},
+		},
+		fromSubsidy: subsidy/10 + subsidy/2,
+	}, {
+		name:   "unknown masternode payee",
+		height: 15,
+		payees: &testPayees{},
+		err:    true,
+	}, {
+		name:        "no payee source",
+		height:      10,
+		payees:      nil,
+		payments:    []payment{{subsidy / 10, founderScript}},
+		fromSubsidy: subsidy / 10,
+	}, {
+		name:   "no payee source with masternode payment",
+		height: 25,
+		payees: nil,
+		err:    true,
+	}, {
+		name:   "superblock",
+		height: 20,
+		payees: &testPayees{
+			masternode: masternodeScript,
+			superblock: []*wire.TxOut{
+				wire.NewTxOut(budget, proposalScript),
+			},
+		},
+		payments: []payment{
+			{subsidy / 2, masternodeScript},
+			{budget, proposalScript},
+		},
+		fromSubsidy: subsidy / 2,
+	}, {
+		name:   "superblock over budget",
+		height: 20,
+		payees: &testPayees{
+			masternode: masternodeScript,
+			superblock: []*wire.TxOut{
+				wire.NewTxOut(budget+1, proposalScript),
+			},
+		},
+		err: true,
+	}}
+
+	for _, test := range tests {
+		payments, fromSubsidy, err := NewConsensusPayments(&params,
+			test.payees).CoinbasePayments(test.height,
+			&chainhash.Hash{})
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: did not receive expected error",
+					test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if fromSubsidy != test.fromSubsidy {
+			t.Errorf("%s: got %d from subsidy, want %d", test.name,
+				fromSubsidy, test.fromSubsidy)
+		}
+		if len(payments) != len(test.payments) {
+			t.Errorf("%s: got %d payments, want %d", test.name,
+				len(payments), len(test.payments))
+			continue
+		}
+		for i, want := range test.payments {
+			if payments[i].Value != want.value ||
+				!bytes.Equal(payments[i].PkScript, want.pkScript) {
+
+				t.Errorf("%s: payment %d: got %d to %x, want %d "+
+					"to %x", test.name, i, payments[i].Value,
+					payments[i].PkScript, want.value,
+					want.pkScript)
+			}
+		}
+	}
+}
+
+// TestCreateCoinbasePayments ensures the coinbase transaction pays the miner
+// first with its share of the subsidy reduced by the payments which follow.
+func TestCreateCoinbasePayments(t *testing.T) {
+	coinbaseScript, err := standardCoinbaseScript(100, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: unexpected error: %v", err)
+	}
+	payments := []*wire.TxOut{wire.NewTxOut(1000, []byte{0x51})}
+	coinbaseTx, err := createCoinbaseTx(&chaincfg.MainNetParams,
+		coinbaseScript, 100, nil, payments, 1000)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: unexpected error: %v", err)
+	}
+
+	txOuts := coinbaseTx.MsgTx().TxOut
+	subsidy := blockchain.CalcBlockSubsidy(100, &chaincfg.MainNetParams)
+	if len(txOuts) != 2 || txOuts[0].Value != subsidy-1000 ||
+		txOuts[1] != payments[0] {
+
+		t.Fatalf("unexpected coinbase outputs: %v", txOuts)
+	}
+}
@@ -6,6 +6,7 @@ package mining
 
 import (
 	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/txscript"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
 )
@@ -45,6 +46,18 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee ulordutil.Amount
+
+	// Standardness is the script standardness policy whose verification
+	// flags are used to validate transaction scripts when generating a
+	// block template.  The default policy is used when it is nil.
+	Standardness *txscript.Policy
+
+	// CoinbasePayments provides the outputs the coinbase transactions of
+	// block templates must contain in addition to the output paying the
+	// miner.  The consensus payments of the chain parameters without a
+	// payee source are used when it is nil, so templates can't be created
+	// for blocks which must pay a masternode.
+	CoinbasePayments CoinbasePayments
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
@@ -0,0 +1,292 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/mining"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// extraNonce1Size is the size in bytes of the extra nonce which is
+	// assigned to each client by the server.
+	extraNonce1Size = 4
+
+	// extraNonce2Size is the size in bytes of the extra nonce which is
+	// chosen by the miners of a client.
+	extraNonce2Size = 4
+
+	// extraNonceSize is the total size in bytes of the extra nonce which is
+	// placed in the coinbase script of a job.
+	extraNonceSize = extraNonce1Size + extraNonce2Size
+
+	// maxTimeOffset is the maximum number of seconds the timestamp of a
+	// share is allowed to be ahead of the current time.
+	maxTimeOffset = 2 * 60 * 60
+)
+
+var (
+	// diff1Target is the target which corresponds to a share difficulty
+	// of 1.  It is the same value used by stratum miners to compute the
+	// target of the difficulty set by the server.
+	diff1Target = new(big.Int).Lsh(big.NewInt(0xffff), 208)
+)
+
+// job houses a block template converted into the work that is sent to the
+// miners of a client with a mining.notify message.  The coinbase transaction
+// is split around the extra nonce so miners are able to build unique coinbase
+// transactions, and thus merkle roots, without any further communication.
+type job struct {
+	id           string
+	height       int32
+	block        *wire.MsgBlock
+	coinbase1    []byte
+	coinbase2    []byte
+	merkleBranch []chainhash.Hash
+	target       *big.Int
+	created      time.Time
+	cleanJobs    bool
+
+	mtx    sync.Mutex
+	shares map[string]struct{}
+}
+
+// coinbaseScript returns the coinbase script for a block at the passed height
+// along with the offset of the extra nonce in it.  The extra nonce is pushed
+// as fixed size data directly after the block height as required by BIP0034.
+func coinbaseScript(height int32) ([]byte, int, error) {
+	heightScript, err := txscript.NewScriptBuilder().
+		AddInt64(int64(height)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	script, err := txscript.NewScriptBuilder().AddOps(heightScript).
+		AddData(make([]byte, extraNonceSize)).
+		AddData([]byte(mining.CoinbaseFlags)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(script) > blockchain.MaxCoinbaseScriptLen {
+		return nil, 0, fmt.Errorf("coinbase script length of %d is "+
+			"out of range (min: %d, max: %d)", len(script),
+			blockchain.MinCoinbaseScriptLen,
+			blockchain.MaxCoinbaseScriptLen)
+	}
+
+	// Skip the opcode which pushes the extra nonce.
+	return script, len(heightScript) + 1, nil
+}
+
+// merkleBranch returns the hashes which are required to compute the merkle
+// root of a block from the hash of its coinbase transaction.  The passed
+// hashes are those of the transactions which follow the coinbase.
+func merkleBranch(hashes []chainhash.Hash) []chainhash.Hash {
+	var branch []chainhash.Hash
+	level := hashes
+	for len(level) > 0 {
+		branch = append(branch, level[0])
+
+		// The coinbase side of the tree is always the first entry of
+		// the next level, so only the remaining pairs are hashed.  The
+		// last hash is paired with itself when there is an odd number
+		// of them.
+		rest := level[1:]
+		next := make([]chainhash.Hash, 0, (len(rest)+1)/2)
+		for i := 0; i < len(rest); i += 2 {
+			right := &rest[i]
+			if i+1 < len(rest) {
+				right = &rest[i+1]
+			}
+			next = append(next, *blockchain.HashMerkleBranches(&rest[i],
+				right))
+		}
+		level = next
+	}
+	return branch
+}
+
+// newJob converts the passed block template into a job with the provided id.
+// The template must pay to a valid address.
+func newJob(id string, template *mining.BlockTemplate, cleanJobs bool) (*job, error) {
+	if !template.ValidPayAddress {
+		return nil, errors.New("block template does not pay to a " +
+			"valid address")
+	}
+
+	// Replace the coinbase script of the template with one which provides
+	// room for the extra nonce.
+	msgBlock := &wire.MsgBlock{
+		Header:       template.Block.Header,
+		Transactions: make([]*wire.MsgTx, len(template.Block.Transactions)),
+	}
+	copy(msgBlock.Transactions, template.Block.Transactions)
+	coinbaseTx := msgBlock.Transactions[0].Copy()
+	script, offset, err := coinbaseScript(template.Height)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx.TxIn[0].SignatureScript = script
+	msgBlock.Transactions[0] = coinbaseTx
+
+	// The coinbase is split at the extra nonce.  Its offset in the
+	// serialized transaction is made up of the version, the input count,
+	// the previous outpoint and the script length.
+	var buf bytes.Buffer
+	buf.Grow(coinbaseTx.SerializeSizeStripped())
+	if err := coinbaseTx.SerializeNoWitness(&buf); err != nil {
+		return nil, err
+	}
+	offset += 4 + wire.VarIntSerializeSize(uint64(len(coinbaseTx.TxIn))) +
+		chainhash.HashSize + 4 +
+		wire.VarIntSerializeSize(uint64(len(script)))
+	serialized := buf.Bytes()
+
+	hashes := make([]chainhash.Hash, 0, len(msgBlock.Transactions)-1)
+	for _, tx := range msgBlock.Transactions[1:] {
+		hashes = append(hashes, tx.TxHash())
+	}
+
+	return &job{
+		id:           id,
+		height:       template.Height,
+		block:        msgBlock,
+		coinbase1:    serialized[:offset],
+		coinbase2:    serialized[offset+extraNonceSize:],
+		merkleBranch: merkleBranch(hashes),
+		target:       blockchain.CompactToBig(msgBlock.Header.Bits),
+		created:      time.Now(),
+		cleanJobs:    cleanJobs,
+		shares:       make(map[string]struct{}),
+	}, nil
+}
+
+// wordSwap returns a copy of the passed hash with the byte order of each
+// 32-bit word reversed which is the encoding stratum uses for the previous
+// block hash.
+func wordSwap(hash *chainhash.Hash) []byte {
+	swapped := make([]byte, chainhash.HashSize)
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		binary.BigEndian.PutUint32(swapped[i:],
+			binary.LittleEndian.Uint32(hash[i:]))
+	}
+	return swapped
+}
+
+// uint32Hex returns the big-endian hex encoding of the passed value.
+func uint32Hex(v uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return hex.EncodeToString(b[:])
+}
+
+// notifyParams returns the parameters of the mining.notify message for the
+// job.
+func (j *job) notifyParams() []interface{} {
+	branch := make([]string, 0, len(j.merkleBranch))
+	for i := range j.merkleBranch {
+		branch = append(branch, hex.EncodeToString(j.merkleBranch[i][:]))
+	}
+	header := &j.block.Header
+	return []interface{}{
+		j.id,
+		hex.EncodeToString(wordSwap(&header.PrevBlock)),
+		hex.EncodeToString(j.coinbase1),
+		hex.EncodeToString(j.coinbase2),
+		branch,
+		uint32Hex(uint32(header.Version)),
+		uint32Hex(header.Bits),
+		uint32Hex(uint32(header.Timestamp.Unix())),
+		j.cleanJobs,
+	}
+}
+
+// coinbase returns the coinbase transaction of the job with the passed extra
+// nonce.  The witness of the coinbase in the block template is retained so the
+// witness commitment remains valid.
+func (j *job) coinbase(extraNonce []byte) (*wire.MsgTx, error) {
+	if len(extraNonce) != extraNonceSize {
+		return nil, fmt.Errorf("extra nonce is %d bytes instead of %d",
+			len(extraNonce), extraNonceSize)
+	}
+
+	serialized := make([]byte, 0, len(j.coinbase1)+extraNonceSize+
+		len(j.coinbase2))
+	serialized = append(serialized, j.coinbase1...)
+	serialized = append(serialized, extraNonce...)
+	serialized = append(serialized, j.coinbase2...)
+
+	var coinbaseTx wire.MsgTx
+	err := coinbaseTx.DeserializeNoWitness(bytes.NewReader(serialized))
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx.TxIn[0].Witness = j.block.Transactions[0].TxIn[0].Witness
+	return &coinbaseTx, nil
+}
+
+// merkleRoot returns the merkle root of the job for a coinbase transaction with
+// the passed hash.
+func (j *job) merkleRoot(coinbaseHash *chainhash.Hash) chainhash.Hash {
+	root := *coinbaseHash
+	for i := range j.merkleBranch {
+		root = *blockchain.HashMerkleBranches(&root, &j.merkleBranch[i])
+	}
+	return root
+}
+
+// solve returns the block of the job with the passed extra nonce, timestamp
+// and nonce.
+func (j *job) solve(extraNonce []byte, timestamp, nonce uint32) (*wire.MsgBlock, error) {
+	coinbaseTx, err := j.coinbase(extraNonce)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseHash := coinbaseTx.TxHash()
+
+	msgBlock := &wire.MsgBlock{
+		Header:       j.block.Header,
+		Transactions: make([]*wire.MsgTx, len(j.block.Transactions)),
+	}
+	copy(msgBlock.Transactions, j.block.Transactions)
+	msgBlock.Transactions[0] = coinbaseTx
+	msgBlock.Header.MerkleRoot = j.merkleRoot(&coinbaseHash)
+	msgBlock.Header.Timestamp = time.Unix(int64(timestamp), 0)
+	msgBlock.Header.Nonce = nonce
+	return msgBlock, nil
+}
+
+// addShare records the share with the passed key and returns false when it has
+// already been submitted.
+func (j *job) addShare(key string) bool {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	if _, ok := j.shares[key]; ok {
+		return false
+	}
+	j.shares[key] = struct{}{}
+	return true
+}
+
+// difficultyTarget returns the target a share has to meet for the passed
+// difficulty.
+func difficultyTarget(difficulty float64) *big.Int {
+	target := new(big.Float).SetInt(diff1Target)
+	target.Quo(target, big.NewFloat(difficulty))
+	result, _ := target.Int(nil)
+	return result
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/mining"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// newTestTemplate returns a block template at the passed height with the
+// provided number of transactions following the coinbase.
+func newTestTemplate(height int32, numTxns int) *mining.BlockTemplate {
+	coinbaseTx := wire.NewMsgTx(wire.TxVersion)
+	coinbaseTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x01, 0x02},
+		Sequence:        wire.MaxTxInSequenceNum,
+		Witness:         wire.TxWitness{make([]byte, 32)},
+	})
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   4,
+			PrevBlock: chainhash.Hash{0x01, 0x02, 0x03, 0x04},
+			Timestamp: time.Unix(1500000000, 0),
+			Bits:      0x1d00ffff,
+		},
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	}
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{
+			byte(i)}, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		msgBlock.Transactions = append(msgBlock.Transactions, tx)
+	}
+
+	return &mining.BlockTemplate{
+		Block:           msgBlock,
+		Height:          height,
+		ValidPayAddress: true,
+	}
+}
+
+// TestMerkleBranch ensures the merkle branch of a job along with the hash of
+// the coinbase results in the merkle root of the block for various numbers of
+// transactions.
+func TestMerkleBranch(t *testing.T) {
+	for numTxns := 0; numTxns < 12; numTxns++ {
+		template := newTestTemplate(1000, numTxns)
+		j, err := newJob("1", template, true)
+		if err != nil {
+			t.Fatalf("newJob: unexpected error: %v", err)
+		}
+
+		block := ulordutil.NewBlock(j.block)
+		merkles := blockchain.BuildMerkleTreeStore(block.Transactions(),
+			false)
+		coinbaseHash := j.block.Transactions[0].TxHash()
+		root := j.merkleRoot(&coinbaseHash)
+		if !root.IsEqual(merkles[len(merkles)-1]) {
+			t.Errorf("merkle root for %d transactions: got %v, "+
+				"want %v", numTxns, root, merkles[len(merkles)-1])
+		}
+	}
+}
+
+// TestJobSolve ensures the coinbase of a job is split around the extra nonce
+// and that solved blocks are assembled with the passed extra nonce, timestamp
+// and nonce.
+func TestJobSolve(t *testing.T) {
+	template := newTestTemplate(1000, 3)
+	j, err := newJob("1", template, true)
+	if err != nil {
+		t.Fatalf("newJob: unexpected error: %v", err)
+	}
+
+	extraNonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	msgBlock, err := j.solve(extraNonce, 1500000100, 12345)
+	if err != nil {
+		t.Fatalf("solve: unexpected error: %v", err)
+	}
+
+	// The coinbase script must start with the block height followed by
+	// the extra nonce and the witness must be retained.
+	coinbaseTx := msgBlock.Transactions[0]
+	script := coinbaseTx.TxIn[0].SignatureScript
+	want := []byte{0x02, 0xe8, 0x03, 0x08}
+	want = append(want, extraNonce...)
+	if !bytes.HasPrefix(script, want) {
+		t.Fatalf("unexpected coinbase script: got %x, want prefix %x",
+			script, want)
+	}
+	if len(coinbaseTx.TxIn[0].Witness) != 1 {
+		t.Fatal("coinbase witness was not retained")
+	}
+	if coinbaseTx.TxOut[0].Value != 5000000000 {
+		t.Fatalf("unexpected coinbase output value: %d",
+			coinbaseTx.TxOut[0].Value)
+	}
+
+	// The template must not be modified.
+	if !bytes.Equal(template.Block.Transactions[0].TxIn[0].SignatureScript,
+		[]byte{0x01, 0x02}) {
+
+		t.Fatal("coinbase of the block template was modified")
+	}
+
+	block := ulordutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	if !msgBlock.Header.MerkleRoot.IsEqual(merkles[len(merkles)-1]) {
+		t.Fatalf("unexpected merkle root: got %v, want %v",
+			msgBlock.Header.MerkleRoot, merkles[len(merkles)-1])
+	}
+	if msgBlock.Header.Timestamp.Unix() != 1500000100 ||
+		msgBlock.Header.Nonce != 12345 {
+
+		t.Fatalf("unexpected timestamp and nonce: %v and %d",
+			msgBlock.Header.Timestamp, msgBlock.Header.Nonce)
+	}
+
+	if _, err := j.solve(extraNonce[:4], 1500000100, 0); err == nil {
+		t.Fatal("solve: did not fail with a short extra nonce")
+	}
+}
+
+// TestNotifyParams ensures the mining.notify parameters of a job are encoded
+// as expected by stratum miners.
+func TestNotifyParams(t *testing.T) {
+	j, err := newJob("1a", newTestTemplate(1000, 1), false)
+	if err != nil {
+		t.Fatalf("newJob: unexpected error: %v", err)
+	}
+
+	params := j.notifyParams()
+	if len(params) != 9 {
+		t.Fatalf("unexpected number of parameters: %d", len(params))
+	}
+	wantPrevHash := "04030201" + strings.Repeat("00", 28)
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"job id", params[0], "1a"},
+		{"previous block", params[1], wantPrevHash},
+		{"version", params[5], "00000004"},
+		{"bits", params[6], "1d00ffff"},
+		{"time", params[7], "59682f00"},
+		{"clean jobs", params[8], false},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, test.got,
+				test.want)
+		}
+	}
+	if branch := params[4].([]string); len(branch) != 1 {
+		t.Errorf("unexpected merkle branch length: %d", len(branch))
+	}
+}
+
+// TestHandleSubmit ensures shares are checked against the worker, job,
+// duplicates and the difficulty of the client.
+func TestHandleSubmit(t *testing.T) {
+	j, err := newJob("1", newTestTemplate(1000, 2), true)
+	if err != nil {
+		t.Fatalf("newJob: unexpected error: %v", err)
+	}
+	s := New(&Config{ChainParams: &chaincfg.RegressionNetParams})
+	s.jobs[j.id] = j
+	c := &client{
+		s:           s,
+		extraNonce1: []byte{0x00, 0x00, 0x00, 0x01},
+		workers:     map[string]struct{}{"worker": {}},
+		vd:          newVarDiff(1e-12, 0, 0, time.Now()),
+	}
+
+	params := func(args ...string) []json.RawMessage {
+		raw := make([]json.RawMessage, 0, len(args))
+		for _, arg := range args {
+			b, _ := json.Marshal(arg)
+			raw = append(raw, b)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unauthorized worker",
+			[]string{"other", "1", "00000000", "59682f00", "00000000"},
+			errCodeUnauthorized},
+		{"unknown job",
+			[]string{"worker", "2", "00000000", "59682f00", "00000000"},
+			errCodeJobNotFound},
+		{"short extra nonce",
+			[]string{"worker", "1", "0000", "59682f00", "00000000"},
+			errCodeOther},
+		{"time before template",
+			[]string{"worker", "1", "00000000", "59682eff", "00000000"},
+			errCodeOther},
+		{"valid share",
+			[]string{"worker", "1", "00000000", "59682f00", "00000000"},
+			0},
+		{"duplicate share",
+			[]string{"worker", "1", "00000000", "59682f00", "00000000"},
+			errCodeDuplicateShare},
+	}
+	for _, test := range tests {
+		result, rerr := c.handleSubmit(params(test.args...))
+		if test.code == 0 {
+			if rerr != nil || result != true {
+				t.Errorf("%s: unexpected result %v and error %v",
+					test.name, result, rerr)
+			}
+			continue
+		}
+		if rerr == nil || rerr.Code != test.code {
+			t.Errorf("%s: got error %v, want code %d", test.name,
+				rerr, test.code)
+		}
+	}
+	if c.vd.shares != 1 {
+		t.Errorf("unexpected number of recorded shares: %d", c.vd.shares)
+	}
+
+	// A share which does not meet the difficulty of the client must be
+	// rejected.
+	c.vd.difficulty = 1e12
+	_, rerr := c.handleSubmit(params("worker", "1", "00000001", "59682f00",
+		"00000000"))
+	if rerr == nil || rerr.Code != errCodeLowDifficulty {
+		t.Errorf("low difficulty share: got error %v, want code %d",
+			rerr, errCodeLowDifficulty)
+	}
+}
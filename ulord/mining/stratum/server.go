@@ -0,0 +1,741 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/mining"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// jobRefreshInterval is the minimum amount of time between new jobs for
+	// the same previous block.  New jobs are only created in that case when
+	// the transactions available for the block template have changed.
+	jobRefreshInterval = 30 * time.Second
+
+	// jobUpdateInterval is the interval at which the server checks whether
+	// a new job has to be sent to the clients and adjusts their
+	// difficulties.
+	jobUpdateInterval = time.Second
+
+	// maxJobs is the maximum number of jobs for the same previous block
+	// the server keeps track of for share submissions.
+	maxJobs = 16
+
+	// clientIdleTimeout is the amount of time after which a client which
+	// has not sent any messages is disconnected.
+	clientIdleTimeout = 10 * time.Minute
+
+	// clientWriteTimeout is the amount of time after which writing a
+	// message to a client times out.
+	clientWriteTimeout = 30 * time.Second
+
+	// maxMessageSize is the maximum size in bytes of a single message sent
+	// by a client.
+	maxMessageSize = 16 * 1024
+)
+
+// Error codes returned to clients as defined by the stratum protocol.
+const (
+	errCodeOther          = 20
+	errCodeJobNotFound    = 21
+	errCodeDuplicateShare = 22
+	errCodeLowDifficulty  = 23
+	errCodeUnauthorized   = 24
+	errCodeNotSubscribed  = 25
+)
+
+// Config is a descriptor containing the stratum server configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the server is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// BlockTemplateGenerator identifies the instance to use in order to
+	// generate the block templates jobs are created from.
+	BlockTemplateGenerator *mining.BlkTmplGenerator
+
+	// MiningAddrs is a list of payment addresses to use for the generated
+	// blocks.  Each block template will randomly select one of them.  At
+	// least one address is required.
+	MiningAddrs []ulordutil.Address
+
+	// ProcessBlock defines the function to call with any solved blocks.
+	// It typically must run the provided block through the same set of
+	// rules and handling as any other block coming from the network.
+	ProcessBlock func(*ulordutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// IsCurrent defines the function to use to obtain whether or not the
+	// block chain is current.  This is used by the server to avoid handing
+	// out work while the chain is still syncing.
+	IsCurrent func() bool
+
+	// Listeners defines a slice of listeners for which the server will
+	// accept stratum connections.
+	Listeners []net.Listener
+
+	// Difficulty is the initial share difficulty of new clients.
+	Difficulty float64
+
+	// MinDifficulty and MaxDifficulty bound the share difficulty of a
+	// client when it is adjusted to its hash rate.  A bound of zero
+	// disables it.
+	MinDifficulty float64
+	MaxDifficulty float64
+}
+
+// stratumError describes an error which is returned to a client.  It is
+// encoded as an array of the error code, message and traceback as expected by
+// stratum miners.
+type stratumError struct {
+	Code    int
+	Message string
+}
+
+// MarshalJSON encodes the error in the stratum format.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Code, e.Message, nil})
+}
+
+// request is a message sent by a client.
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response is the reply to a request of a client.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// notification is a message sent to a client without a request.
+type notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// Server provides a stratum server which hands out work created from block
+// templates to miners, validates the shares they submit against their
+// individual difficulty and submits the solved blocks.
+type Server struct {
+	started         int32
+	shutdown        int32
+	cfg             Config
+	wg              sync.WaitGroup
+	quit            chan struct{}
+	submitBlockLock sync.Mutex
+
+	mtx             sync.Mutex
+	clients         map[*client]struct{}
+	jobs            map[string]*job
+	jobOrder        []string
+	currentJob      *job
+	nextJobID       uint64
+	nextExtraNonce1 uint32
+}
+
+// client houses the state of a stratum connection.
+type client struct {
+	s           *Server
+	conn        net.Conn
+	addr        string
+	extraNonce1 []byte
+	sendMtx     sync.Mutex
+
+	mtx            sync.Mutex
+	subscribed     bool
+	workers        map[string]struct{}
+	vd             *varDiff
+	prevDifficulty float64
+}
+
+// New returns a new instance of a stratum server for the provided
+// configuration.  Use Start to begin accepting connections and Stop to shut it
+// down.
+func New(cfg *Config) *Server {
+	// Start with a random extra nonce so clients receive different work
+	// across restarts.
+	extraNonce1, err := wire.RandomUint64()
+	if err != nil {
+		log.Warnf("Unable to generate random extra nonce for stratum "+
+			"clients: %v", err)
+	}
+
+	return &Server{
+		cfg:             *cfg,
+		quit:            make(chan struct{}),
+		clients:         make(map[*client]struct{}),
+		jobs:            make(map[string]*job),
+		nextExtraNonce1: uint32(extraNonce1),
+	}
+}
+
+// Start begins accepting stratum connections on the configured listeners and
+// handing out work to the clients.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+	s.wg.Add(1)
+	go s.jobHandler()
+}
+
+// Stop gracefully stops the server by closing the listeners and all client
+// connections.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Infof("Stratum server is already in the process of " +
+			"shutting down")
+		return
+	}
+
+	close(s.quit)
+	for _, listener := range s.cfg.Listeners {
+		listener.Close()
+	}
+	s.mtx.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mtx.Unlock()
+	s.wg.Wait()
+	log.Infof("Stratum server shutdown complete")
+}
+
+// listenHandler accepts stratum connections on the passed listener until the
+// server is shut down.
+//
+// This function MUST be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	defer s.wg.Done()
+
+	log.Infof("Stratum server listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.shutdown) != 0 {
+				return
+			}
+			if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+				time.Sleep(time.Second)
+				continue
+			}
+			log.Errorf("Can't accept stratum connections on %s: %v",
+				listener.Addr(), err)
+			return
+		}
+
+		s.mtx.Lock()
+		if atomic.LoadInt32(&s.shutdown) != 0 {
+			s.mtx.Unlock()
+			conn.Close()
+			return
+		}
+		var extraNonce1 [extraNonce1Size]byte
+		binary.BigEndian.PutUint32(extraNonce1[:], s.nextExtraNonce1)
+		s.nextExtraNonce1++
+		c := &client{
+			s:           s,
+			conn:        conn,
+			addr:        conn.RemoteAddr().String(),
+			extraNonce1: extraNonce1[:],
+			workers:     make(map[string]struct{}),
+			vd: newVarDiff(s.cfg.Difficulty, s.cfg.MinDifficulty,
+				s.cfg.MaxDifficulty, time.Now()),
+		}
+		s.clients[c] = struct{}{}
+		s.wg.Add(1)
+		s.mtx.Unlock()
+
+		log.Debugf("New stratum client %s", c.addr)
+		go c.inHandler()
+	}
+}
+
+// removeClient closes the connection of the passed client and stops sending
+// it any work.
+func (s *Server) removeClient(c *client) {
+	s.mtx.Lock()
+	delete(s.clients, c)
+	s.mtx.Unlock()
+	c.conn.Close()
+	log.Debugf("Stratum client %s disconnected", c.addr)
+}
+
+// jobHandler periodically creates new jobs when there is a new best block or
+// the available transactions have changed, and adjusts the difficulty of the
+// clients.
+//
+// This function MUST be run as a goroutine.
+func (s *Server) jobHandler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(jobUpdateInterval)
+	defer ticker.Stop()
+	s.updateJob()
+	for {
+		select {
+		case <-ticker.C:
+			s.updateJob()
+			s.retargetClients()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// updateJob creates a new job and sends it to all subscribed clients when the
+// best block has changed or the available transactions have changed and the
+// current job has been handed out long enough.
+func (s *Server) updateJob() {
+	if s.cfg.IsCurrent != nil && !s.cfg.IsCurrent() {
+		return
+	}
+
+	g := s.cfg.BlockTemplateGenerator
+	best := g.BestSnapshot()
+	s.mtx.Lock()
+	current := s.currentJob
+	s.mtx.Unlock()
+
+	cleanJobs := current == nil ||
+		!current.block.Header.PrevBlock.IsEqual(&best.Hash)
+	if !cleanJobs && (time.Since(current.created) < jobRefreshInterval ||
+		!g.TxSource().LastUpdated().After(current.created)) {
+
+		return
+	}
+
+	// Choose a payment address at random.
+	rand.Seed(time.Now().UnixNano())
+	payToAddr := s.cfg.MiningAddrs[rand.Intn(len(s.cfg.MiningAddrs))]
+	template, err := g.NewBlockTemplate(payToAddr)
+	if err != nil {
+		log.Errorf("Failed to create new block template for stratum "+
+			"job: %v", err)
+		return
+	}
+
+	s.mtx.Lock()
+	s.nextJobID++
+	j, err := newJob(strconv.FormatUint(s.nextJobID, 16), template,
+		cleanJobs)
+	if err != nil {
+		s.mtx.Unlock()
+		log.Errorf("Failed to create stratum job: %v", err)
+		return
+	}
+
+	// Jobs for a previous block are stale, so they are removed along with
+	// the oldest jobs beyond the maximum.
+	if cleanJobs {
+		s.jobs = make(map[string]*job)
+		s.jobOrder = s.jobOrder[:0]
+	}
+	s.jobs[j.id] = j
+	s.jobOrder = append(s.jobOrder, j.id)
+	if len(s.jobOrder) > maxJobs {
+		delete(s.jobs, s.jobOrder[0])
+		s.jobOrder = s.jobOrder[1:]
+	}
+	s.currentJob = j
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mtx.Unlock()
+
+	log.Debugf("New stratum job %s for block height %d with %d "+
+		"transactions", j.id, j.height, len(j.block.Transactions))
+	for _, c := range clients {
+		c.notify(j, cleanJobs)
+	}
+}
+
+// retargetClients adjusts the difficulty of all clients which are due for a
+// retarget.
+func (s *Server) retargetClients() {
+	s.mtx.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mtx.Unlock()
+
+	now := time.Now()
+	for _, c := range clients {
+		c.retarget(now)
+	}
+}
+
+// lookupJob returns the job with the passed id or nil if the job is unknown
+// or stale.
+func (s *Server) lookupJob(id string) *job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.jobs[id]
+}
+
+// submitBlock submits the passed block to network after ensuring it passes all
+// of the consensus validation rules.
+func (s *Server) submitBlock(block *ulordutil.Block, worker string) bool {
+	s.submitBlockLock.Lock()
+	defer s.submitBlockLock.Unlock()
+
+	// Ensure the block is not stale since a new block could have shown up
+	// while the share was being submitted.
+	msgBlock := block.MsgBlock()
+	best := s.cfg.BlockTemplateGenerator.BestSnapshot()
+	if !msgBlock.Header.PrevBlock.IsEqual(&best.Hash) {
+		log.Debugf("Block submitted via stratum worker %s with "+
+			"previous block %s is stale", worker,
+			msgBlock.Header.PrevBlock)
+		return false
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so log that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unexpected error while processing "+
+				"block submitted via stratum: %v", err)
+			return false
+		}
+
+		log.Debugf("Block submitted via stratum rejected: %v", err)
+		return false
+	}
+	if isOrphan {
+		log.Debugf("Block submitted via stratum is an orphan")
+		return false
+	}
+
+	// The block was accepted.
+	coinbaseTx := msgBlock.Transactions[0].TxOut[0]
+	log.Infof("Block submitted via stratum worker %s accepted (hash %s, "+
+		"amount %v)", worker, block.Hash(),
+		ulordutil.Amount(coinbaseTx.Value))
+	return true
+}
+
+// inHandler reads and handles the messages of the client until it
+// disconnects or the server is shut down.
+//
+// This function MUST be run as a goroutine.
+func (c *client) inHandler() {
+	defer c.s.wg.Done()
+	defer c.s.removeClient(c)
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxMessageSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(clientIdleTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				log.Debugf("Failed to read from stratum client "+
+					"%s: %v", c.addr, err)
+			}
+			return
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debugf("Malformed message from stratum client "+
+				"%s: %v", c.addr, err)
+			return
+		}
+
+		// Responses to requests of the server are ignored.
+		if req.Method == "" {
+			continue
+		}
+		if !c.handleRequest(&req) {
+			return
+		}
+	}
+}
+
+// handleRequest handles the passed request and sends the response.  It
+// returns false when the client has to be disconnected.
+func (c *client) handleRequest(req *request) bool {
+	var result interface{}
+	var rerr *stratumError
+	switch req.Method {
+	case "mining.subscribe":
+		result, rerr = c.handleSubscribe()
+
+	case "mining.authorize":
+		result, rerr = c.handleAuthorize(req.Params)
+
+	case "mining.submit":
+		result, rerr = c.handleSubmit(req.Params)
+
+	case "mining.extranonce.subscribe":
+		// The extra nonce of a client never changes, so there is
+		// nothing to subscribe to.
+		result = true
+
+	default:
+		rerr = &stratumError{errCodeOther, "unknown method " +
+			req.Method}
+	}
+
+	id := req.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	if !c.send(&response{ID: id, Result: result, Error: rerr}) {
+		return false
+	}
+
+	// Newly subscribed clients receive their difficulty and the current
+	// job right away.
+	if req.Method == "mining.subscribe" && rerr == nil {
+		c.mtx.Lock()
+		difficulty := c.vd.difficulty
+		c.mtx.Unlock()
+		c.send(&notification{
+			Method: "mining.set_difficulty",
+			Params: []interface{}{difficulty},
+		})
+
+		c.s.mtx.Lock()
+		current := c.s.currentJob
+		c.s.mtx.Unlock()
+		if current != nil {
+			c.notify(current, true)
+		}
+	}
+	return true
+}
+
+// handleSubscribe handles the mining.subscribe request which assigns the
+// extra nonce of the client.
+func (c *client) handleSubscribe() (interface{}, *stratumError) {
+	c.mtx.Lock()
+	c.subscribed = true
+	c.mtx.Unlock()
+
+	id := hex.EncodeToString(c.extraNonce1)
+	return []interface{}{
+		[]interface{}{
+			[]interface{}{"mining.set_difficulty", id},
+			[]interface{}{"mining.notify", id},
+		},
+		id,
+		extraNonce2Size,
+	}, nil
+}
+
+// handleAuthorize handles the mining.authorize request.  Solved blocks always
+// pay to the mining addresses of the server, so any worker is authorized.
+func (c *client) handleAuthorize(params []json.RawMessage) (interface{}, *stratumError) {
+	var worker string
+	if len(params) < 1 || json.Unmarshal(params[0], &worker) != nil {
+		return nil, &stratumError{errCodeOther, "invalid parameters"}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !c.subscribed {
+		return nil, &stratumError{errCodeNotSubscribed, "not subscribed"}
+	}
+	c.workers[worker] = struct{}{}
+	log.Infof("Stratum client %s authorized worker %s", c.addr, worker)
+	return true, nil
+}
+
+// parseUint32Hex parses the big-endian hex encoding of a 32-bit value.
+func parseUint32Hex(s string) (uint32, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 4 {
+		return 0, fmt.Errorf("value is %d bytes instead of 4", len(b))
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// handleSubmit handles the mining.submit request by checking the share against
+// the difficulty of the client and submitting the block when it also meets the
+// target of the network.
+func (c *client) handleSubmit(params []json.RawMessage) (interface{}, *stratumError) {
+	var args [5]string
+	if len(params) < len(args) {
+		return nil, &stratumError{errCodeOther, "invalid parameters"}
+	}
+	for i := range args {
+		if err := json.Unmarshal(params[i], &args[i]); err != nil {
+			return nil, &stratumError{errCodeOther,
+				"invalid parameters"}
+		}
+	}
+	worker, jobID := args[0], args[1]
+
+	c.mtx.Lock()
+	_, authorized := c.workers[worker]
+	difficulty := c.vd.difficulty
+	if c.prevDifficulty != 0 && c.prevDifficulty < difficulty {
+		difficulty = c.prevDifficulty
+	}
+	c.mtx.Unlock()
+	if !authorized {
+		return nil, &stratumError{errCodeUnauthorized,
+			"unauthorized worker"}
+	}
+
+	j := c.s.lookupJob(jobID)
+	if j == nil {
+		return nil, &stratumError{errCodeJobNotFound, "job not found"}
+	}
+
+	extraNonce2, err := hex.DecodeString(args[2])
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		return nil, &stratumError{errCodeOther, "invalid extranonce2"}
+	}
+	timestamp, err := parseUint32Hex(args[3])
+	if err != nil {
+		return nil, &stratumError{errCodeOther, "invalid ntime"}
+	}
+	minTimestamp := j.block.Header.Timestamp.Unix()
+	maxTimestamp := time.Now().Unix() + maxTimeOffset
+	if int64(timestamp) < minTimestamp || int64(timestamp) > maxTimestamp {
+		return nil, &stratumError{errCodeOther, "ntime out of range"}
+	}
+	nonce, err := parseUint32Hex(args[4])
+	if err != nil {
+		return nil, &stratumError{errCodeOther, "invalid nonce"}
+	}
+
+	extraNonce := make([]byte, 0, extraNonceSize)
+	extraNonce = append(extraNonce, c.extraNonce1...)
+	extraNonce = append(extraNonce, extraNonce2...)
+	if !j.addShare(hex.EncodeToString(extraNonce) + args[3] + args[4]) {
+		return nil, &stratumError{errCodeDuplicateShare,
+			"duplicate share"}
+	}
+
+	msgBlock, err := j.solve(extraNonce, timestamp, nonce)
+	if err != nil {
+		return nil, &stratumError{errCodeOther, err.Error()}
+	}
+	powHash := c.s.cfg.ChainParams.PowHash(&msgBlock.Header)
+	hashNum := blockchain.HashToBig(&powHash)
+
+	// A share which solves the block is always accepted, even when the
+	// network target is easier than the target of the client.
+	if hashNum.Cmp(j.target) <= 0 {
+		c.s.submitBlock(ulordutil.NewBlock(msgBlock), worker)
+	} else if hashNum.Cmp(difficultyTarget(difficulty)) > 0 {
+		return nil, &stratumError{errCodeLowDifficulty,
+			"low difficulty share"}
+	}
+
+	c.mtx.Lock()
+	c.vd.addShare()
+	c.mtx.Unlock()
+	log.Tracef("Accepted share %s from stratum worker %s",
+		msgBlock.Header.BlockHash(), worker)
+	return true, nil
+}
+
+// notify sends the passed job to the client when it is subscribed.  The
+// clients are told to abandon their previous work when cleanJobs is set.
+func (c *client) notify(j *job, cleanJobs bool) {
+	c.mtx.Lock()
+	subscribed := c.subscribed
+	c.prevDifficulty = 0
+	c.mtx.Unlock()
+	if !subscribed {
+		return
+	}
+
+	params := j.notifyParams()
+	params[len(params)-1] = cleanJobs
+	c.send(&notification{Method: "mining.notify", Params: params})
+}
+
+// retarget adjusts the difficulty of the client when it is due for a retarget
+// and sends the new difficulty to it.  Shares for the work the client already
+// received are accepted at the previous difficulty until it receives a new
+// job.
+func (c *client) retarget(now time.Time) {
+	c.mtx.Lock()
+	oldDifficulty := c.vd.difficulty
+	difficulty, changed := c.vd.retarget(now)
+	if changed && c.prevDifficulty == 0 {
+		c.prevDifficulty = oldDifficulty
+	}
+	subscribed := c.subscribed
+	c.mtx.Unlock()
+	if !changed || !subscribed {
+		return
+	}
+
+	log.Debugf("Changing difficulty of stratum client %s from %g to %g",
+		c.addr, oldDifficulty, difficulty)
+	c.send(&notification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{difficulty},
+	})
+}
+
+// send writes the passed message to the client.  It returns false and closes
+// the connection when the message could not be written.
+func (c *client) send(msg interface{}) bool {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("Failed to marshal stratum message: %v", err)
+		return false
+	}
+	b = append(b, '\n')
+
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	if _, err := c.conn.Write(b); err != nil {
+		log.Debugf("Failed to write to stratum client %s: %v", c.addr,
+			err)
+		c.conn.Close()
+		return false
+	}
+	return true
+}
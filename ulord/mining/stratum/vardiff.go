@@ -0,0 +1,105 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// targetShareTime is the average time between the shares of a client
+	// the difficulty is adjusted for.
+	targetShareTime = 10 * time.Second
+
+	// retargetTime is the time between adjustments of the difficulty of a
+	// client.
+	retargetTime = 90 * time.Second
+
+	// maxRetargetFactor is the maximum factor the difficulty of a client
+	// is adjusted by in a single retarget.
+	maxRetargetFactor = 4
+
+	// minRetargetChange is the minimum relative change of the difficulty
+	// of a client required for a retarget to take effect.  This prevents
+	// sending new difficulties to clients for insignificant variations of
+	// their hash rate.
+	minRetargetChange = 0.1
+)
+
+// varDiff tracks the shares submitted by a client in order to adjust its
+// difficulty such that shares are submitted at the target rate.
+type varDiff struct {
+	minDifficulty   float64
+	maxDifficulty   float64
+	targetShareTime time.Duration
+	retargetTime    time.Duration
+
+	difficulty   float64
+	lastRetarget time.Time
+	shares       int
+}
+
+// newVarDiff returns a varDiff for the passed initial difficulty which keeps
+// the difficulty within the provided bounds.  A bound of zero disables it.
+func newVarDiff(difficulty, minDifficulty, maxDifficulty float64, now time.Time) *varDiff {
+	v := &varDiff{
+		minDifficulty:   minDifficulty,
+		maxDifficulty:   maxDifficulty,
+		targetShareTime: targetShareTime,
+		retargetTime:    retargetTime,
+		lastRetarget:    now,
+	}
+	v.difficulty = v.clamp(difficulty)
+	return v
+}
+
+// clamp returns the passed difficulty limited to the configured bounds.
+func (v *varDiff) clamp(difficulty float64) float64 {
+	if v.minDifficulty > 0 && difficulty < v.minDifficulty {
+		difficulty = v.minDifficulty
+	}
+	if v.maxDifficulty > 0 && difficulty > v.maxDifficulty {
+		difficulty = v.maxDifficulty
+	}
+	return difficulty
+}
+
+// addShare records a share submitted at the passed time.
+func (v *varDiff) addShare() {
+	v.shares++
+}
+
+// retarget adjusts the difficulty once the retarget time has elapsed since
+// the last retarget based on the average time between the shares submitted
+// since then.  It returns the new difficulty and whether or not it changed.
+//
+// Clients which did not submit any shares have their difficulty lowered by
+// the maximum factor.
+func (v *varDiff) retarget(now time.Time) (float64, bool) {
+	elapsed := now.Sub(v.lastRetarget)
+	if v.retargetTime <= 0 || v.targetShareTime <= 0 ||
+		elapsed < v.retargetTime {
+
+		return v.difficulty, false
+	}
+
+	factor := 1.0 / maxRetargetFactor
+	if v.shares > 0 {
+		shareTime := elapsed.Seconds() / float64(v.shares)
+		factor = v.targetShareTime.Seconds() / shareTime
+		factor = math.Max(factor, 1.0/maxRetargetFactor)
+		factor = math.Min(factor, maxRetargetFactor)
+	}
+	v.lastRetarget = now
+	v.shares = 0
+
+	difficulty := v.clamp(v.difficulty * factor)
+	if math.Abs(difficulty-v.difficulty) < v.difficulty*minRetargetChange {
+		return v.difficulty, false
+	}
+	v.difficulty = difficulty
+	return difficulty, true
+}
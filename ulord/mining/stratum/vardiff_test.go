@@ -0,0 +1,70 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+// TestVarDiff ensures the difficulty of a client is adjusted towards the
+// target share rate within the configured bounds.
+func TestVarDiff(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	tests := []struct {
+		name       string
+		difficulty float64
+		min        float64
+		max        float64
+		shares     int
+		elapsed    time.Duration
+		want       float64
+		changed    bool
+	}{
+		{"before retarget time", 8, 0, 0, 100, retargetTime / 2, 8, false},
+		{"on target", 8, 0, 0, 9, retargetTime, 8, false},
+		{"twice as fast", 8, 0, 0, 18, retargetTime, 16, true},
+		{"half as fast", 8, 0, 0, 9, 2 * retargetTime, 4, true},
+		{"limited increase", 8, 0, 0, 1000, retargetTime, 32, true},
+		{"no shares", 8, 0, 0, 0, retargetTime, 2, true},
+		{"maximum", 8, 0, 12, 18, retargetTime, 12, true},
+		{"minimum", 8, 4, 0, 0, retargetTime, 4, true},
+		{"at minimum", 4, 4, 0, 0, retargetTime, 4, false},
+		{"insignificant change", 8, 0, 0, 19, 2 * retargetTime, 8, false},
+	}
+
+	for _, test := range tests {
+		v := newVarDiff(test.difficulty, test.min, test.max, start)
+		for i := 0; i < test.shares; i++ {
+			v.addShare()
+		}
+		got, changed := v.retarget(start.Add(test.elapsed))
+		if got != test.want || changed != test.changed {
+			t.Errorf("%s: got difficulty %v (changed %v), want %v "+
+				"(changed %v)", test.name, got, changed, test.want,
+				test.changed)
+		}
+	}
+}
+
+// TestDifficultyTarget ensures share difficulties are converted to the targets
+// used by stratum miners.
+func TestDifficultyTarget(t *testing.T) {
+	if difficultyTarget(1).Cmp(diff1Target) != 0 {
+		t.Fatalf("unexpected target for difficulty 1: %x",
+			difficultyTarget(1))
+	}
+	want := new(big.Int).Lsh(diff1Target, 1)
+	if difficultyTarget(0.5).Cmp(want) != 0 {
+		t.Fatalf("unexpected target for difficulty 0.5: got %x, want %x",
+			difficultyTarget(0.5), want)
+	}
+	want = new(big.Int).Rsh(diff1Target, 4)
+	if difficultyTarget(16).Cmp(want) != 0 {
+		t.Fatalf("unexpected target for difficulty 16: got %x, want %x",
+			difficultyTarget(16), want)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// natpmpPort is the port NAT-PMP gateways listen for requests on.
+	natpmpPort = 5351
+
+	// natpmpAttempts is the number of times a NAT-PMP request is sent
+	// before giving up.  The timeout starts at natpmpInitialTimeout and
+	// doubles after each attempt as described by RFC 6886.
+	natpmpAttempts       = 4
+	natpmpInitialTimeout = 250 * time.Millisecond
+)
+
+// The NAT-PMP opcodes.  The opcodes of responses have the high bit set.
+const (
+	natpmpOpExternalAddress = 0
+	natpmpOpMapUDP          = 1
+	natpmpOpMapTCP          = 2
+	natpmpOpResponse        = 128
+)
+
+// natpmpResultErrors describes the non-zero result codes of NAT-PMP responses.
+var natpmpResultErrors = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// natpmpNAT implements the NAT interface using the NAT Port Mapping Protocol
+// as described by RFC 6886.
+type natpmpNAT struct {
+	gateway *net.UDPAddr
+}
+
+// DiscoverNATPMP looks up the default gateway and returns a NAT for it when it
+// supports NAT-PMP.
+func DiscoverNATPMP() (NAT, error) {
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	nat := &natpmpNAT{gateway: &net.UDPAddr{IP: gateway, Port: natpmpPort}}
+
+	// Ensure the gateway answers NAT-PMP requests.
+	if _, err := nat.GetExternalAddress(); err != nil {
+		return nil, fmt.Errorf("NAT-PMP discovery failed: %v", err)
+	}
+	return nat, nil
+}
+
+// defaultGateway returns the IPv4 address of the default gateway.  The routing
+// table is used when it is available, otherwise the gateway is assumed to be
+// the first address of the /24 network of the local address used to reach the
+// internet, which is the case for most home routers.
+func defaultGateway() (net.IP, error) {
+	if gateway, err := routeTableGateway("/proc/net/route"); err == nil {
+		return gateway, nil
+	}
+
+	// The address is not contacted since no packets are sent over a UDP
+	// socket until it is written to.
+	conn, err := net.Dial("udp4", "8.8.8.8:53")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if local == nil {
+		return nil, errors.New("no local IPv4 address")
+	}
+	return net.IPv4(local[0], local[1], local[2], 1), nil
+}
+
+// routeTableGateway returns the gateway of the default route in the passed
+// routing table in the format of /proc/net/route on Linux.
+func routeTableGateway(path string) (net.IP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The fields are the interface, destination, and gateway
+		// followed by others.  Addresses are hex encoded in host byte
+		// order, which is little endian on all supported platforms.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		gateway, err := hex.DecodeString(fields[2])
+		if err != nil || len(gateway) != 4 {
+			continue
+		}
+		return net.IPv4(gateway[3], gateway[2], gateway[1], gateway[0]), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route")
+}
+
+// request sends the passed NAT-PMP request to the gateway and returns the
+// response once one of the passed size with the matching opcode is received.
+func (n *natpmpNAT) request(msg []byte, responseSize int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	response := make([]byte, 16)
+	timeout := natpmpInitialTimeout
+	for attempt := 0; attempt < natpmpAttempts; attempt++ {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		timeout *= 2
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			size, err := conn.Read(response)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+
+			// Ignore unrelated packets.
+			if size < responseSize || response[0] != 0 ||
+				response[1] != msg[1]|natpmpOpResponse {
+
+				continue
+			}
+			result := binary.BigEndian.Uint16(response[2:4])
+			if result != 0 {
+				desc, ok := natpmpResultErrors[result]
+				if !ok {
+					desc = fmt.Sprintf("result code %d", result)
+				}
+				return nil, fmt.Errorf("NAT-PMP request failed: %s",
+					desc)
+			}
+			return response[:responseSize], nil
+		}
+	}
+	return nil, errors.New("NAT-PMP gateway did not respond")
+}
+
+// GetExternalAddress implements the NAT interface by requesting the external
+// address from the NAT-PMP gateway.
+func (n *natpmpNAT) GetExternalAddress() (net.IP, error) {
+	response, err := n.request([]byte{0, natpmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(response[8], response[9], response[10], response[11]), nil
+}
+
+// AddPortMapping implements the NAT interface by requesting a mapping of the
+// passed external port to the passed internal port for timeout seconds from the
+// NAT-PMP gateway.  The gateway may map a different external port, which is
+// returned.
+func (n *natpmpNAT) AddPortMapping(protocol string, externalPort, internalPort int, description string, timeout int) (int, error) {
+	op := byte(natpmpOpMapTCP)
+	if protocol == "udp" {
+		op = natpmpOpMapUDP
+	}
+	msg := make([]byte, 12)
+	msg[1] = op
+	binary.BigEndian.PutUint16(msg[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(msg[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(msg[8:12], uint32(timeout))
+	response, err := n.request(msg, 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(response[10:12])), nil
+}
+
+// DeletePortMapping implements the NAT interface by requesting the removal of
+// the mapping of the passed internal port from the NAT-PMP gateway.
+func (n *natpmpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	// Mappings are removed by requesting a mapping with a lifetime and
+	// external port of zero.
+	_, err := n.AddPortMapping(protocol, 0, internalPort, "", 0)
+	return err
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeNATPMPGateway runs a NAT-PMP gateway which maps every requested port to
+// the external port following it and returns its address along with a channel
+// the requests it received are sent to.
+func fakeNATPMPGateway(t *testing.T) (*net.UDPAddr, <-chan []byte) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	requests := make(chan []byte, 10)
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 64)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte(nil), buf[:n]...)
+			requests <- req
+
+			var resp []byte
+			switch req[1] {
+			case natpmpOpExternalAddress:
+				resp = make([]byte, 12)
+				copy(resp[8:], []byte{203, 0, 113, 7})
+			case natpmpOpMapTCP:
+				resp = make([]byte, 16)
+				copy(resp[8:10], req[4:6])
+				port := binary.BigEndian.Uint16(req[6:8])
+				if port != 0 {
+					port++
+				}
+				binary.BigEndian.PutUint16(resp[10:12], port)
+				copy(resp[12:16], req[8:12])
+			default:
+				// Unsupported opcode.
+				resp = make([]byte, 8)
+				resp[3] = 5
+			}
+			resp[1] = req[1] | natpmpOpResponse
+			conn.WriteToUDP(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr), requests
+}
+
+// TestNATPMP ensures the NAT-PMP client sends well formed requests and parses
+// the responses of the gateway.
+func TestNATPMP(t *testing.T) {
+	gateway, requests := fakeNATPMPGateway(t)
+	nat := &natpmpNAT{gateway: gateway}
+
+	ip, err := nat.GetExternalAddress()
+	if err != nil {
+		t.Fatalf("GetExternalAddress: unexpected error: %v", err)
+	}
+	if want := net.IPv4(203, 0, 113, 7); !ip.Equal(want) {
+		t.Fatalf("GetExternalAddress: got %v, want %v", ip, want)
+	}
+	<-requests
+
+	port, err := nat.AddPortMapping("tcp", 9888, 9889, "", 1200)
+	if err != nil {
+		t.Fatalf("AddPortMapping: unexpected error: %v", err)
+	}
+	if port != 9889 {
+		t.Fatalf("AddPortMapping: got port %d, want %d", port, 9889)
+	}
+	req := <-requests
+	if len(req) != 12 || binary.BigEndian.Uint16(req[4:6]) != 9889 ||
+		binary.BigEndian.Uint16(req[6:8]) != 9888 ||
+		binary.BigEndian.Uint32(req[8:12]) != 1200 {
+
+		t.Fatalf("AddPortMapping: unexpected request %x", req)
+	}
+
+	// Mappings are deleted with a zero lifetime and external port.
+	if err := nat.DeletePortMapping("tcp", 9889, 9889); err != nil {
+		t.Fatalf("DeletePortMapping: unexpected error: %v", err)
+	}
+	req = <-requests
+	if binary.BigEndian.Uint16(req[6:8]) != 0 ||
+		binary.BigEndian.Uint32(req[8:12]) != 0 {
+
+		t.Fatalf("DeletePortMapping: unexpected request %x", req)
+	}
+
+	// Result codes are returned as errors.
+	if _, err := nat.request([]byte{0, 3}, 8); err == nil {
+		t.Fatal("request: unexpected success for unsupported opcode")
+	}
+}
+
+// TestRouteTableGateway ensures the default gateway is parsed from routing
+// tables in the format of /proc/net/route.
+func TestRouteTableGateway(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routetable")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "route")
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t0\t00000000\n"
+	if err := ioutil.WriteFile(path, []byte(table), 0600); err != nil {
+		t.Fatalf("unable to write route table: %v", err)
+	}
+	gateway, err := routeTableGateway(path)
+	if err != nil {
+		t.Fatalf("routeTableGateway: unexpected error: %v", err)
+	}
+	if want := net.IPv4(192, 168, 0, 1); !gateway.Equal(want) {
+		t.Fatalf("routeTableGateway: got %v, want %v", gateway, want)
+	}
+}
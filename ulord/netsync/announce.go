@@ -0,0 +1,96 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// maxAnnouncedBlocks is the maximum number of blocks which are requested from
+// a peer at a time in response to headers announcing them.
+const maxAnnouncedBlocks = 16
+
+// handleHeadersAnnouncement handles the headers a peer sent to announce new
+// blocks as described by BIP0130 after it was asked to do so with a
+// sendheaders message.  The announced blocks which are not known yet are
+// requested from the peer once the chain is current.  When the headers don't
+// connect to a known block, the headers which connect them to the main chain
+// are requested instead.
+func (sm *SyncManager) handleHeadersAnnouncement(peer *peerpkg.Peer, state *peerSyncState, headers []*wire.BlockHeader) {
+	if len(headers) == 0 {
+		return
+	}
+
+	// Ensure the headers connect to each other.
+	hashes := make([]*chainhash.Hash, 0, len(headers))
+	for i, header := range headers {
+		hash := header.BlockHash()
+		if i > 0 && !header.PrevBlock.IsEqual(hashes[i-1]) {
+			reason := fmt.Sprintf("announced block header %v does "+
+				"not connect to the previous header", hash)
+			sm.peerNotifier.PeerMisbehaved(peer,
+				OffenseUnconnectedHeaders, reason)
+			return
+		}
+		hashes = append(hashes, &hash)
+		peer.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, &hash))
+	}
+	lastHash := hashes[len(hashes)-1]
+	peer.UpdateLastAnnouncedBlock(lastHash)
+
+	// Ignore announcements until the chain is current like inventory
+	// announcements, since the blocks are downloaded from the sync peer.
+	if !sm.current() {
+		return
+	}
+
+	// Ask for the headers which connect the announced blocks to the main
+	// chain when the parent of the first one is unknown.
+	haveParent, err := sm.chain.HaveBlock(&headers[0].PrevBlock)
+	if err != nil {
+		log.Warnf("Unable to check for block %v: %v",
+			headers[0].PrevBlock, err)
+		return
+	}
+	if !haveParent {
+		sm.addOrphanHeader(headers[len(headers)-1], peer)
+		if !state.requestedOrphanHeaders {
+			sm.requestOrphanHeaders(peer, state)
+		}
+		return
+	}
+
+	// Update the height of the peer when it announced a known block.
+	if height, err := sm.chain.BlockHeightByHash(lastHash); err == nil {
+		peer.UpdateLastBlockHeight(height)
+	}
+
+	// Request the first blocks which are not known yet.  The headers of
+	// the following ones are tracked so they are requested once their
+	// parents connect.
+	var missing []*chainhash.Hash
+	for i, hash := range hashes {
+		haveBlock, err := sm.chain.HaveBlock(hash)
+		if err != nil {
+			log.Warnf("Unable to check for block %v: %v", hash, err)
+			return
+		}
+		if haveBlock {
+			continue
+		}
+		missing = append(missing, hash)
+		if len(missing) == maxAnnouncedBlocks {
+			for _, header := range headers[i+1:] {
+				sm.addOrphanHeader(header, peer)
+			}
+			break
+		}
+	}
+	sm.requestBlocks(peer, state, missing)
+}
@@ -5,7 +5,10 @@
 package netsync
 
 import (
+	"net"
+
 	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/blockchain/indexers"
 	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/mempool"
@@ -25,6 +28,28 @@ type PeerNotifier interface {
 	RelayInventory(invVect *wire.InvVect, data interface{})
 
 	TransactionConfirmed(tx *ulordutil.Tx)
+
+	// PeerMisbehaved penalizes the peer for the passed offense, which may
+	// disconnect or ban it.  It must not block.
+	PeerMisbehaved(peer *peer.Peer, offense Offense, reason string)
+
+	// RelayMasternodeMessage relays the passed masternode message to all
+	// peers which support masternode messages except the peer it was
+	// received from.
+	RelayMasternodeMessage(msg wire.Message, source *peer.Peer)
+}
+
+// MasternodeNotifier exposes methods to hand the masternode messages received
+// from peers to the masternode manager once they passed the sanity checks of
+// the SyncManager.  Each method returns whether the message is new and valid
+// and must be relayed to other peers, or an error when the message is invalid,
+// in which case the peer it was received from is penalized.
+type MasternodeNotifier interface {
+	ProcessMNBroadcast(msg *wire.MsgMNBroadcast, source *peer.Peer) (bool, error)
+
+	ProcessMNPing(msg *wire.MsgMNPing, source *peer.Peer) (bool, error)
+
+	ProcessTxLockVote(msg *wire.MsgTxLockVote, source *peer.Peer) (bool, error)
 }
 
 // Config is a configuration struct used to initialize a new SyncManager.
@@ -38,4 +63,18 @@ type Config struct {
 	MaxPeers           int
 
 	FeeEstimator *mempool.FeeEstimator
+
+	// MasternodeNotifier is the masternode manager masternode messages
+	// are handed to.  Masternode messages are dropped without being
+	// relayed when it is nil since they can't be verified.
+	MasternodeNotifier MasternodeNotifier
+
+	// IndexManager is the manager of the enabled indexes, which is used by
+	// reindex jobs.  It is nil when no indexes are enabled.
+	IndexManager *indexers.Manager
+
+	// TrustedPeers are the networks of peers whose transactions bypass the
+	// relay policy, but not the consensus rules, and which are preferred
+	// when choosing the peer to sync from.
+	TrustedPeers []*net.IPNet
 }
@@ -6,12 +6,14 @@ package netsync
 
 import (
 	"container/list"
+	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/blockchain/indexers"
 	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/database"
@@ -38,6 +40,11 @@ const (
 	// maxRequestedTxns is the maximum number of requested transactions
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
+
+	// maxSnapshotBlocksInFlight is the maximum number of blocks before the
+	// utxo snapshot the chain was bootstrapped from which are requested
+	// from a peer at a time.
+	maxSnapshotBlocksInFlight = 16
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -136,6 +143,16 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+
+	// orphanHeaders is the number of orphan headers tracked for the peer
+	// and requestedOrphanHeaders indicates the headers connecting them to
+	// the main chain were requested from it.
+	orphanHeaders          int
+	requestedOrphanHeaders bool
+
+	// trusted indicates the peer is included in the networks of trusted
+	// peers, so its transactions bypass the relay policy.
+	trusted bool
 }
 
 // SyncManager is used to communicate block related messages with peers. The
@@ -161,6 +178,7 @@ type SyncManager struct {
 	requestedBlocks map[chainhash.Hash]struct{}
 	syncPeer        *peerpkg.Peer
 	peerStates      map[*peerpkg.Peer]*peerSyncState
+	orphanHeaders   map[chainhash.Hash]*orphanHeader
 
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
@@ -170,6 +188,21 @@ type SyncManager struct {
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
+
+	// An optional masternode manager and the masternode messages which
+	// were already processed.  The map should only be accessed from the
+	// blockHandler thread.
+	masternodeNotifier MasternodeNotifier
+	seenMasternodeMsgs map[chainhash.Hash]struct{}
+
+	// An optional index manager and the reindex job which is running, if
+	// any.  The job should only be accessed from the blockHandler thread.
+	indexManager *indexers.Manager
+	reindexJob   *ReindexJob
+
+	// The networks of trusted peers.  They should only be accessed from
+	// the blockHandler thread.
+	trustedPeers []*net.IPNet
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
@@ -259,8 +292,13 @@ func (sm *SyncManager) startSync() {
 		}
 
 		// TODO(davec): Use a better algorithm to choose the best peer.
-		// For now, just pick the first available candidate.
-		bestPeer = peer
+		// For now, just pick the first available candidate, preferring
+		// trusted peers.
+		if bestPeer == nil || (state.trusted &&
+			!sm.peerStates[bestPeer].trusted) {
+
+			bestPeer = peer
+		}
 	}
 
 	// Start syncing from the best peer if one was selected.
@@ -370,11 +408,18 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 	}
+	sm.setPeerTrusted(peer, sm.peerStates[peer], sm.isTrustedPeer(peer))
 
 	// Start syncing by choosing the best candidate if needed.
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
 	}
+
+	// Download the blocks before the utxo snapshot the chain was
+	// bootstrapped from, if any, from full nodes.
+	if isSyncCandidate {
+		sm.fetchSnapshotBlocks(peer)
+	}
 }
 
 // handleDonePeerMsg deals with peers that have signalled they are done.  It
@@ -407,6 +452,12 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 		delete(sm.requestedBlocks, blockHash)
 	}
 
+	// Stop tracking the orphan headers the peer announced.
+	sm.removePeerOrphanHeaders(peer)
+
+	// Stop trusting the transactions tagged with the ID of the peer.
+	sm.setPeerTrusted(peer, state, false)
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
 	// sync peer.  Also, reset the headers-first state if in headers-first
 	// mode so
@@ -480,8 +531,9 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 
 		// Convert the error into an appropriate reject message and
 		// send it.
-		code, reason := mempool.ErrToRejectErr(err)
-		peer.PushRejectMsg(wire.CmdTx, code, reason, txHash, false)
+		code, reason, detail := mempool.ErrToRejectReason(err)
+		peer.PushRejectMsg(wire.CmdTx, code,
+			wire.FormatRejectReason(reason, detail), txHash, false)
 		return
 	}
 
@@ -518,7 +570,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		return
 	}
 
-	// If we didn't ask for this block then the peer is misbehaving.
+	// If we didn't ask for this block while syncing then the peer is
+	// misbehaving.  Once the chain is current, unsolicited blocks which
+	// extend a known block are processed since peers may push newly found
+	// blocks.  The headers of unsolicited blocks whose parents are unknown
+	// are tracked instead while the headers connecting them to the main
+	// chain are requested.
 	blockHash := bmsg.block.Hash()
 	if _, exists = state.requestedBlocks[*blockHash]; !exists {
 		// The regression test intentionally sends some blocks twice
@@ -527,10 +584,24 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		// mode in this case so the chain code is actually fed the
 		// duplicate blocks.
 		if sm.chainParams != &chaincfg.RegressionNetParams {
-			log.Warnf("Got unrequested block %v from %s -- "+
-				"disconnecting", blockHash, peer.Addr())
-			peer.Disconnect()
-			return
+			if !sm.current() {
+				reason := fmt.Sprintf("unrequested block %v",
+					blockHash)
+				sm.peerNotifier.PeerMisbehaved(peer,
+					OffenseUnrequestedBlock, reason)
+				return
+			}
+			prevHash := &bmsg.block.MsgBlock().Header.PrevBlock
+			haveParent, err := sm.chain.HaveBlock(prevHash)
+			if err != nil {
+				log.Warnf("Unable to check for block %v: %v",
+					prevHash, err)
+				return
+			}
+			if !haveParent {
+				sm.trackOrphanBlock(bmsg.block, peer, state)
+				return
+			}
 		}
 	}
 
@@ -564,6 +635,19 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	delete(state.requestedBlocks, *blockHash)
 	delete(sm.requestedBlocks, *blockHash)
 
+	// Blocks before the utxo snapshot the chain was bootstrapped from are
+	// already part of the main chain, so they are validated separately.
+	if sm.chain.IsSnapshotBlock(blockHash) {
+		sm.handleSnapshotBlockMsg(bmsg)
+		return
+	}
+
+	// Orphan blocks which are far ahead of the main chain are not kept.
+	if sm.isDistantOrphan(bmsg.block) {
+		sm.trackOrphanBlock(bmsg.block, peer, state)
+		return
+	}
+
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
 	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
@@ -586,8 +670,9 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Convert the error into an appropriate reject message and
 		// send it.
-		code, reason := mempool.ErrToRejectErr(err)
-		peer.PushRejectMsg(wire.CmdBlock, code, reason, blockHash, false)
+		code, reason, detail := mempool.ErrToRejectReason(err)
+		peer.PushRejectMsg(wire.CmdBlock, code,
+			wire.FormatRejectReason(reason, detail), blockHash, false)
 		return
 	}
 
@@ -646,6 +731,11 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Clear the rejected transactions.
 		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+
+		// Request the announced blocks which extend the block and the
+		// new tip of the main chain.
+		sm.requestOrphanChildren(blockHash)
+		sm.requestOrphanChildren(&best.Hash)
 	}
 
 	// Update the block height for this peer. But only send a message to
@@ -660,6 +750,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
+	if state.syncCandidate {
+		sm.fetchSnapshotBlocks(peer)
+	}
+
 	// Nothing more to do if we aren't in headers-first mode.
 	if !sm.headersFirstMode {
 		return
@@ -712,6 +806,60 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	}
 }
 
+// handleSnapshotBlockMsg handles block messages for blocks before the utxo
+// snapshot the chain was bootstrapped from.
+func (sm *SyncManager) handleSnapshotBlockMsg(bmsg *blockMsg) {
+	peer := bmsg.peer
+	blockHash := bmsg.block.Hash()
+	err := sm.chain.ProcessSnapshotBlock(bmsg.block)
+	if err != nil {
+		if _, ok := err.(blockchain.RuleError); ok {
+			log.Infof("Rejected block %v before the utxo snapshot "+
+				"from %s: %v", blockHash, peer, err)
+			code, reason, detail := mempool.ErrToRejectReason(err)
+			peer.PushRejectMsg(wire.CmdBlock, code,
+				wire.FormatRejectReason(reason, detail), blockHash,
+				false)
+		} else {
+			log.Errorf("Failed to process block %v before the utxo "+
+				"snapshot: %v", blockHash, err)
+		}
+		return
+	}
+
+	sm.fetchSnapshotBlocks(peer)
+}
+
+// fetchSnapshotBlocks requests the next blocks before the utxo snapshot the
+// chain was bootstrapped from which haven't been validated yet from the passed
+// peer, unless it is still busy with earlier requests.
+func (sm *SyncManager) fetchSnapshotBlocks(peer *peerpkg.Peer) {
+	state, exists := sm.peerStates[peer]
+	if !exists || len(state.requestedBlocks) >= minInFlightBlocks {
+		return
+	}
+
+	hashes := sm.chain.SnapshotBlocksNeeded(maxSnapshotBlocksInFlight)
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+	for i := range hashes {
+		hash := &hashes[i]
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
+		}
+
+		sm.requestedBlocks[*hash] = struct{}{}
+		state.requestedBlocks[*hash] = struct{}{}
+		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
+		if peer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
+		}
+		gdmsg.AddInvVect(iv)
+	}
+	if len(gdmsg.InvList) > 0 {
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
 // fetchHeaderBlocks creates and sends a request to the syncPeer for the next
 // list of blocks to be downloaded based on the current list of headers.
 func (sm *SyncManager) fetchHeaderBlocks() {
@@ -770,7 +918,7 @@ func (sm *SyncManager) fetchHeaderBlocks() {
 // requested when performing a headers-first sync.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	peer := hmsg.peer
-	_, exists := sm.peerStates[peer]
+	state, exists := sm.peerStates[peer]
 	if !exists {
 		log.Warnf("Received headers message from unknown peer %s", peer)
 		return
@@ -779,10 +927,23 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	// The remote peer is misbehaving if we didn't request headers.
 	msg := hmsg.headers
 	numHeaders := len(msg.Headers)
-	if !sm.headersFirstMode {
-		log.Warnf("Got %d unrequested headers from %s -- "+
-			"disconnecting", numHeaders, peer.Addr())
-		peer.Disconnect()
+	if state.requestedOrphanHeaders && (!sm.headersFirstMode ||
+		peer != sm.syncPeer) {
+
+		sm.handleOrphanHeaders(peer, state, msg.Headers)
+		return
+	}
+	if !sm.headersFirstMode || peer != sm.syncPeer {
+		// Peers which were asked to announce new blocks with headers
+		// messages send them unrequested.
+		if peer.ProtocolVersion() < wire.SendHeadersVersion {
+			reason := fmt.Sprintf("%d unrequested headers",
+				numHeaders)
+			sm.peerNotifier.PeerMisbehaved(peer,
+				OffenseUnrequestedHeaders, reason)
+			return
+		}
+		sm.handleHeadersAnnouncement(peer, state, msg.Headers)
 		return
 	}
 
@@ -794,10 +955,8 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	// Process all of the received headers ensuring each one connects to the
 	// previous and that checkpoints match.
 	receivedCheckpoint := false
-	var finalHash *chainhash.Hash
 	for _, blockHeader := range msg.Headers {
 		blockHash := blockHeader.BlockHash()
-		finalHash = &blockHash
 
 		// Ensure there is a previous header to compare against.
 		prevNodeEl := sm.headerList.Back()
@@ -819,10 +978,10 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 				sm.startHeader = e
 			}
 		} else {
-			log.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", peer.Addr())
-			peer.Disconnect()
+			reason := fmt.Sprintf("block header %v does not "+
+				"properly connect to the chain", blockHash)
+			sm.peerNotifier.PeerMisbehaved(peer,
+				OffenseUnconnectedHeaders, reason)
 			return
 		}
 
@@ -834,13 +993,13 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 					"header against checkpoint at height "+
 					"%d/hash %s", node.height, node.hash)
 			} else {
-				log.Warnf("Block header at height %d/hash "+
-					"%s from peer %s does NOT match "+
-					"expected checkpoint hash of %s -- "+
-					"disconnecting", node.height,
-					node.hash, peer.Addr(),
+				reason := fmt.Sprintf("block header at "+
+					"height %d/hash %s does NOT match "+
+					"expected checkpoint hash of %s",
+					node.height, node.hash,
 					sm.nextCheckpoint.Hash)
-				peer.Disconnect()
+				sm.peerNotifier.PeerMisbehaved(peer,
+					OffenseCheckpointMismatch, reason)
 				return
 			}
 			break
@@ -865,8 +1024,11 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	// This header is not a checkpoint, so request the next batch of
 	// headers starting from the latest known header and ending with the
 	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+	next := msg.NextGetHeaders(sm.nextCheckpoint.Hash)
+	if next == nil {
+		return
+	}
+	err := peer.PushGetHeadersMsg(next.BlockLocatorHashes, &next.HashStop)
 	if err != nil {
 		log.Warnf("Failed to send getheaders message to "+
 			"peer %s: %v", peer.Addr(), err)
@@ -1172,6 +1334,9 @@ out:
 			case *headersMsg:
 				sm.handleHeadersMsg(msg)
 
+			case *masternodeMsg:
+				sm.handleMasternodeMsg(msg)
+
 			case *donePeerMsg:
 				sm.handleDonePeerMsg(msg.peer)
 
@@ -1204,6 +1369,20 @@ out:
 				// Wait until the sender unpauses the manager.
 				<-msg.unpause
 
+			case reindexMsg:
+				sm.handleReindexMsg(msg)
+
+			case reindexStepMsg:
+				sm.handleReindexStepMsg(msg)
+
+			case setTrustedPeerMsg:
+				sm.handleSetTrustedPeerMsg(msg)
+
+			case getTrustedPeersMsg:
+				trustedPeers := make([]*net.IPNet, len(sm.trustedPeers))
+				copy(trustedPeers, sm.trustedPeers)
+				msg.reply <- trustedPeers
+
 			default:
 				log.Warnf("Invalid message type in block "+
 					"handler: %T", msg)
@@ -1214,6 +1393,10 @@ out:
 		}
 	}
 
+	if sm.reindexJob != nil {
+		sm.finishReindexJob(sm.reindexJob, ErrReindexCanceled)
+	}
+
 	sm.wg.Done()
 	log.Trace("Block handler done")
 }
@@ -1449,11 +1632,17 @@ func New(config *Config) (*SyncManager, error) {
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
+		orphanHeaders:   make(map[chainhash.Hash]*orphanHeader),
 		progressLogger:  newBlockProgressLogger("Processed", log),
 		msgChan:         make(chan interface{}, config.MaxPeers*3),
 		headerList:      list.New(),
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
+
+		masternodeNotifier: config.MasternodeNotifier,
+		seenMasternodeMsgs: make(map[chainhash.Hash]struct{}),
+		indexManager:       config.IndexManager,
+		trustedPeers:       config.TrustedPeers,
 	}
 
 	best := sm.chain.BestSnapshot()
@@ -0,0 +1,189 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// maxSeenMasternodeMsgs is the maximum number of masternode messages
+	// which are remembered to avoid processing and relaying them again.
+	maxSeenMasternodeMsgs = 10000
+
+	// maxMasternodeSigTimeOffset is the maximum amount of time the
+	// signature time of a masternode message may be ahead of the current
+	// time.
+	maxMasternodeSigTimeOffset = time.Hour
+)
+
+// masternodeMsg packages a masternode message and the peer it came from
+// together so the block handler has access to that information.
+type masternodeMsg struct {
+	msg  wire.Message
+	peer *peerpkg.Peer
+}
+
+// masternodeMsgHash returns the hash identifying a masternode message, which
+// is the double sha256 of its serialization.
+func masternodeMsgHash(msg wire.Message) (chainhash.Hash, error) {
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, wire.MasternodeVersion, wire.BaseEncoding)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return chainhash.DoubleHashH(buf.Bytes()), nil
+}
+
+// checkMasternodeSigTime ensures the signature time of a masternode message is
+// not too far in the future.
+func checkMasternodeSigTime(sigTime int64) error {
+	maxTime := time.Now().Add(maxMasternodeSigTimeOffset)
+	if time.Unix(sigTime, 0).After(maxTime) {
+		return fmt.Errorf("signature time %v is too far in the future",
+			time.Unix(sigTime, 0))
+	}
+	return nil
+}
+
+// checkMNPing performs the checks of a masternode ping which don't depend on
+// the masternode list.
+func checkMNPing(msg *wire.MsgMNPing) error {
+	if len(msg.Signature) == 0 {
+		return errors.New("masternode ping is not signed")
+	}
+	return checkMasternodeSigTime(msg.SigTime)
+}
+
+// checkMasternodeMsg performs the checks of a masternode message which don't
+// depend on the masternode list.  The signatures are verified by the
+// masternode manager.
+func checkMasternodeMsg(msg wire.Message) error {
+	switch msg := msg.(type) {
+	case *wire.MsgMNBroadcast:
+		if len(msg.Signature) == 0 {
+			return errors.New("masternode broadcast is not signed")
+		}
+		pubKeys := [][]byte{msg.CollateralPubKey, msg.MasternodePubKey}
+		for _, pubKey := range pubKeys {
+			_, err := ulordec.ParsePubKey(pubKey, ulordec.S256())
+			if err != nil {
+				return fmt.Errorf("masternode broadcast has an "+
+					"invalid public key: %v", err)
+			}
+		}
+		if msg.LastPing.Outpoint != msg.Outpoint {
+			return errors.New("masternode broadcast includes a ping " +
+				"of another masternode")
+		}
+		if err := checkMasternodeSigTime(msg.SigTime); err != nil {
+			return err
+		}
+		return checkMNPing(&msg.LastPing)
+
+	case *wire.MsgMNPing:
+		return checkMNPing(msg)
+
+	case *wire.MsgTxLockVote:
+		if len(msg.Signature) == 0 {
+			return errors.New("transaction lock vote is not signed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected masternode message %T", msg)
+}
+
+// handleMasternodeMsg handles masternode broadcasts, pings, and transaction lock
+// votes from all peers.  Messages which pass the sanity checks and were not
+// seen before are handed to the masternode manager and relayed to the other
+// peers when the masternode manager accepts them.
+func (sm *SyncManager) handleMasternodeMsg(mmsg *masternodeMsg) {
+	peer := mmsg.peer
+	if _, exists := sm.peerStates[peer]; !exists {
+		log.Warnf("Received %s message from unknown peer %s",
+			mmsg.msg.Command(), peer)
+		return
+	}
+
+	// Masternode messages can't be verified before the chain is current
+	// and without a masternode manager.
+	if sm.masternodeNotifier == nil || !sm.current() {
+		return
+	}
+
+	if err := checkMasternodeMsg(mmsg.msg); err != nil {
+		reason := fmt.Sprintf("invalid %s message: %v",
+			mmsg.msg.Command(), err)
+		sm.peerNotifier.PeerMisbehaved(peer,
+			OffenseInvalidMasternodeMsg, reason)
+		return
+	}
+
+	// Ignore messages which were already processed.
+	hash, err := masternodeMsgHash(mmsg.msg)
+	if err != nil {
+		log.Errorf("Unable to hash %s message from %s: %v",
+			mmsg.msg.Command(), peer, err)
+		return
+	}
+	if _, exists := sm.seenMasternodeMsgs[hash]; exists {
+		return
+	}
+
+	// Pings must refer to a block of the main chain.  They are not
+	// remembered when they don't, since the block may not have been
+	// received yet.
+	if ping, ok := mmsg.msg.(*wire.MsgMNPing); ok {
+		if !sm.chain.MainChainHasBlock(&ping.BlockHash) {
+			log.Debugf("Ignoring masternode ping from %s for unknown "+
+				"block %v", peer, ping.BlockHash)
+			return
+		}
+	}
+
+	sm.limitMap(sm.seenMasternodeMsgs, maxSeenMasternodeMsgs)
+	sm.seenMasternodeMsgs[hash] = struct{}{}
+
+	var relay bool
+	switch msg := mmsg.msg.(type) {
+	case *wire.MsgMNBroadcast:
+		relay, err = sm.masternodeNotifier.ProcessMNBroadcast(msg, peer)
+	case *wire.MsgMNPing:
+		relay, err = sm.masternodeNotifier.ProcessMNPing(msg, peer)
+	case *wire.MsgTxLockVote:
+		relay, err = sm.masternodeNotifier.ProcessTxLockVote(msg, peer)
+	}
+	if err != nil {
+		reason := fmt.Sprintf("rejected %s message: %v",
+			mmsg.msg.Command(), err)
+		sm.peerNotifier.PeerMisbehaved(peer,
+			OffenseInvalidMasternodeMsg, reason)
+		return
+	}
+	if relay {
+		sm.peerNotifier.RelayMasternodeMessage(mmsg.msg, peer)
+	}
+}
+
+// QueueMasternodeMsg adds the passed masternode broadcast, ping, or transaction
+// lock vote message and peer to the block handling queue.
+func (sm *SyncManager) QueueMasternodeMsg(msg wire.Message, peer *peerpkg.Peer) {
+	// No channel handling here because peers do not need to block on
+	// masternode messages.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		return
+	}
+
+	sm.msgChan <- &masternodeMsg{msg: msg, peer: peer}
+}
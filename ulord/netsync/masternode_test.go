@@ -0,0 +1,94 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// TestCheckMasternodeMsg ensures the sanity checks of masternode messages
+// reject malformed messages.
+func TestCheckMasternodeMsg(t *testing.T) {
+	key, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pubKey := key.PubKey().SerializeCompressed()
+	now := time.Now().Unix()
+	outpoint := wire.OutPoint{Index: 1}
+
+	newPing := func() wire.MsgMNPing {
+		return wire.MsgMNPing{
+			Outpoint:  outpoint,
+			SigTime:   now,
+			Signature: []byte{0x01},
+		}
+	}
+	newBroadcast := func() *wire.MsgMNBroadcast {
+		return &wire.MsgMNBroadcast{
+			Outpoint:         outpoint,
+			CollateralPubKey: pubKey,
+			MasternodePubKey: pubKey,
+			Signature:        []byte{0x01},
+			SigTime:          now,
+			LastPing:         newPing(),
+		}
+	}
+
+	unsignedPing := newPing()
+	unsignedPing.Signature = nil
+	futurePing := newPing()
+	futurePing.SigTime = now + int64(2*maxMasternodeSigTimeOffset/time.Second)
+	badKey := newBroadcast()
+	badKey.MasternodePubKey = []byte{0x02}
+	otherPing := newBroadcast()
+	otherPing.LastPing.Outpoint.Index = 2
+	unsignedLastPing := newBroadcast()
+	unsignedLastPing.LastPing.Signature = nil
+	ping := newPing()
+
+	tests := []struct {
+		name  string
+		msg   wire.Message
+		valid bool
+	}{
+		{"valid broadcast", newBroadcast(), true},
+		{"broadcast with invalid key", badKey, false},
+		{"broadcast with ping of other masternode", otherPing, false},
+		{"broadcast with unsigned ping", unsignedLastPing, false},
+		{"valid ping", &ping, true},
+		{"unsigned ping", &unsignedPing, false},
+		{"ping from the future", &futurePing, false},
+		{"valid vote", &wire.MsgTxLockVote{Signature: []byte{0x01}}, true},
+		{"unsigned vote", &wire.MsgTxLockVote{}, false},
+		{"unexpected message", &wire.MsgVerAck{}, false},
+	}
+	for _, test := range tests {
+		err := checkMasternodeMsg(test.msg)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+
+	// Messages which differ must not share a hash.
+	hash1, err := masternodeMsgHash(&ping)
+	if err != nil {
+		t.Fatalf("masternodeMsgHash: %v", err)
+	}
+	hash2, err := masternodeMsgHash(&futurePing)
+	if err != nil {
+		t.Fatalf("masternodeMsgHash: %v", err)
+	}
+	if hash1 == hash2 {
+		t.Fatal("masternodeMsgHash: different pings share a hash")
+	}
+}
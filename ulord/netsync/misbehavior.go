@@ -0,0 +1,307 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/connmgr"
+)
+
+// maxMisbehaviorRecords is the maximum number of the most recent offenses
+// which are remembered for each peer.
+const maxMisbehaviorRecords = 10
+
+// Offense identifies a kind of misbehavior of a peer which is penalized
+// according to a MisbehaviorPolicy.
+type Offense uint8
+
+// These constants define the offenses peers are penalized for.
+const (
+	// OffenseUnrequestedBlock indicates a peer sent a block which was not
+	// requested.
+	OffenseUnrequestedBlock Offense = iota
+
+	// OffenseUnrequestedHeaders indicates a peer sent headers which were
+	// not requested.
+	OffenseUnrequestedHeaders
+
+	// OffenseUnconnectedHeaders indicates a peer sent a header which does
+	// not connect to the previous header.
+	OffenseUnconnectedHeaders
+
+	// OffenseCheckpointMismatch indicates a peer sent a header which does
+	// not match the checkpoint at its height.
+	OffenseCheckpointMismatch
+
+	// OffenseMempoolRequest indicates a peer requested the contents of the
+	// memory pool.  It is penalized to prevent flooding.
+	OffenseMempoolRequest
+
+	// OffenseGetDataRequest indicates a peer requested data.  The penalty
+	// is the share of the size of the request of the maximum inventory
+	// size to prevent flooding.
+	OffenseGetDataRequest
+
+	// OffenseUnsupportedRequest indicates a peer made a request for a
+	// service which is not enabled.
+	OffenseUnsupportedRequest
+
+	// OffenseProtocolViolation indicates a peer made a request for a
+	// service which is not enabled although its protocol version requires
+	// it to know better.
+	OffenseProtocolViolation
+
+	// OffenseInvalidFeeFilter indicates a peer sent a fee filter with an
+	// invalid amount.
+	OffenseInvalidFeeFilter
+
+	// OffenseFilterNotLoaded indicates a peer changed its bloom filter
+	// without loading one first.
+	OffenseFilterNotLoaded
+
+	// OffenseUnwantedTx indicates a peer announced transactions although
+	// transaction relay was disabled.
+	OffenseUnwantedTx
+
+	// OffenseEmptyAddr indicates a peer sent an addr message without any
+	// addresses.
+	OffenseEmptyAddr
+
+	// OffenseInvalidMasternodeMsg indicates a peer sent a masternode
+	// broadcast, ping, or transaction lock vote which is invalid.
+	OffenseInvalidMasternodeMsg
+
+	// numOffenses is the number of offenses.  It MUST be the last entry.
+	numOffenses
+)
+
+// offenseStrings is a map of offenses back to their names which are used in
+// configuration options and RPC results.
+var offenseStrings = map[Offense]string{
+	OffenseUnrequestedBlock:     "unrequestedblock",
+	OffenseUnrequestedHeaders:   "unrequestedheaders",
+	OffenseUnconnectedHeaders:   "unconnectedheaders",
+	OffenseCheckpointMismatch:   "checkpointmismatch",
+	OffenseMempoolRequest:       "mempool",
+	OffenseGetDataRequest:       "getdata",
+	OffenseUnsupportedRequest:   "unsupportedrequest",
+	OffenseProtocolViolation:    "protocolviolation",
+	OffenseInvalidFeeFilter:     "invalidfeefilter",
+	OffenseFilterNotLoaded:      "filternotloaded",
+	OffenseUnwantedTx:           "unwantedtx",
+	OffenseEmptyAddr:            "emptyaddr",
+	OffenseInvalidMasternodeMsg: "invalidmasternodemsg",
+}
+
+// String returns the Offense in human-readable form.
+func (o Offense) String() string {
+	if s, ok := offenseStrings[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Offense (%d)", int(o))
+}
+
+// ParseOffense returns the offense with the passed name.
+func ParseOffense(name string) (Offense, error) {
+	for o := Offense(0); o < numOffenses; o++ {
+		if offenseStrings[o] == name {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown offense %q", name)
+}
+
+// Penalty describes how a peer is penalized for an offense.
+type Penalty struct {
+	// Persistent is the number of points added to the ban score of the
+	// peer which do not decay.
+	Persistent uint32
+
+	// Transient is the number of points added to the ban score of the peer
+	// which decay to half of their value every connmgr.Halflife seconds.
+	Transient uint32
+
+	// Disconnect specifies whether the peer is disconnected regardless of
+	// its ban score.
+	Disconnect bool
+}
+
+// MisbehaviorPolicy defines the penalties of offenses and the actions taken
+// against peers based on their ban score.
+type MisbehaviorPolicy struct {
+	// Penalties are the penalties of the offenses.  Offenses without a
+	// penalty are recorded but not penalized.
+	Penalties map[Offense]Penalty
+
+	// DisconnectThreshold is the ban score above which a peer is
+	// disconnected without being banned.  It can be zero to only
+	// disconnect peers when they are banned or when the penalty of an
+	// offense requires it.
+	DisconnectThreshold uint32
+
+	// BanThreshold is the ban score above which a peer is banned and
+	// disconnected.
+	BanThreshold uint32
+
+	// BanDuration is how long a peer is banned.
+	BanDuration time.Duration
+
+	// DisableBanning disables the ban scores of peers.  Offenses are still
+	// recorded and peers are still disconnected when the penalty of an
+	// offense requires it.
+	DisableBanning bool
+}
+
+// DefaultMisbehaviorPolicy returns the default policy which bans peers with a
+// ban score above 100 for a day.
+func DefaultMisbehaviorPolicy() *MisbehaviorPolicy {
+	return &MisbehaviorPolicy{
+		Penalties: map[Offense]Penalty{
+			OffenseUnrequestedBlock:     {Disconnect: true},
+			OffenseUnrequestedHeaders:   {Disconnect: true},
+			OffenseUnconnectedHeaders:   {Disconnect: true},
+			OffenseCheckpointMismatch:   {Disconnect: true},
+			OffenseMempoolRequest:       {Transient: 33},
+			OffenseGetDataRequest:       {Transient: 99},
+			OffenseUnsupportedRequest:   {Disconnect: true},
+			OffenseProtocolViolation:    {Persistent: 100, Disconnect: true},
+			OffenseInvalidFeeFilter:     {Disconnect: true},
+			OffenseFilterNotLoaded:      {Disconnect: true},
+			OffenseUnwantedTx:           {Disconnect: true},
+			OffenseEmptyAddr:            {Disconnect: true},
+			OffenseInvalidMasternodeMsg: {Persistent: 20},
+		},
+		BanThreshold: 100,
+		BanDuration:  time.Hour * 24,
+	}
+}
+
+// MisbehaviorAction is the action to take against a peer after an offense.
+type MisbehaviorAction uint8
+
+// These constants define the actions taken against misbehaving peers.
+const (
+	// MisbehaviorNone indicates the peer remains connected.
+	MisbehaviorNone MisbehaviorAction = iota
+
+	// MisbehaviorDisconnect indicates the peer must be disconnected.
+	MisbehaviorDisconnect
+
+	// MisbehaviorBan indicates the peer must be banned and disconnected.
+	MisbehaviorBan
+)
+
+// misbehaviorActionStrings is a map of actions back to their names which are
+// used in RPC results.
+var misbehaviorActionStrings = map[MisbehaviorAction]string{
+	MisbehaviorNone:       "none",
+	MisbehaviorDisconnect: "disconnect",
+	MisbehaviorBan:        "ban",
+}
+
+// String returns the MisbehaviorAction in human-readable form.
+func (a MisbehaviorAction) String() string {
+	if s, ok := misbehaviorActionStrings[a]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown MisbehaviorAction (%d)", int(a))
+}
+
+// MisbehaviorRecord describes an offense committed by a peer.
+type MisbehaviorRecord struct {
+	Offense Offense
+	Reason  string
+	Time    time.Time
+
+	// Score is the ban score of the peer after the offense.
+	Score uint32
+
+	// Action is the action taken against the peer for the offense.
+	Action MisbehaviorAction
+}
+
+// PeerMisbehavior tracks the ban score and the most recent offenses of a peer
+// according to a policy.
+//
+// This type is safe for concurrent access.
+type PeerMisbehavior struct {
+	policy *MisbehaviorPolicy
+	score  connmgr.DynamicBanScore
+
+	mtx     sync.Mutex
+	records []MisbehaviorRecord
+}
+
+// NewPeerMisbehavior returns a tracker of the misbehavior of a peer which
+// penalizes offenses according to the passed policy.
+func NewPeerMisbehavior(policy *MisbehaviorPolicy) *PeerMisbehavior {
+	return &PeerMisbehavior{policy: policy}
+}
+
+// Score returns the current ban score of the peer.
+func (m *PeerMisbehavior) Score() uint32 {
+	return m.score.Int()
+}
+
+// Records returns the most recent offenses of the peer, oldest first.
+func (m *PeerMisbehavior) Records() []MisbehaviorRecord {
+	m.mtx.Lock()
+	records := make([]MisbehaviorRecord, len(m.records))
+	copy(records, m.records)
+	m.mtx.Unlock()
+	return records
+}
+
+// Misbehaved penalizes the peer for the passed offense and returns the record
+// of the offense, which includes the action to take against the peer.
+func (m *PeerMisbehavior) Misbehaved(offense Offense, reason string) MisbehaviorRecord {
+	return m.MisbehavedFraction(offense, 1, 1, reason)
+}
+
+// MisbehavedFraction penalizes the peer with the fraction num/denom of the
+// penalty of the passed offense and returns the record of the offense, which
+// includes the action to take against the peer.  This is used for offenses
+// whose severity depends on the size of a request.
+func (m *PeerMisbehavior) MisbehavedFraction(offense Offense, num, denom uint32, reason string) MisbehaviorRecord {
+	penalty := m.policy.Penalties[offense]
+	record := MisbehaviorRecord{
+		Offense: offense,
+		Reason:  reason,
+		Time:    time.Now(),
+	}
+	if m.policy.DisableBanning {
+		record.Score = m.score.Int()
+	} else {
+		persistent := uint32(uint64(penalty.Persistent) * uint64(num) /
+			uint64(denom))
+		transient := uint32(uint64(penalty.Transient) * uint64(num) /
+			uint64(denom))
+		if persistent == 0 && transient == 0 {
+			record.Score = m.score.Int()
+		} else {
+			record.Score = m.score.Increase(persistent, transient)
+		}
+	}
+
+	threshold := m.policy.DisconnectThreshold
+	switch {
+	case !m.policy.DisableBanning && record.Score > m.policy.BanThreshold:
+		record.Action = MisbehaviorBan
+	case penalty.Disconnect || (threshold > 0 && record.Score > threshold):
+		record.Action = MisbehaviorDisconnect
+	}
+
+	m.mtx.Lock()
+	if len(m.records) == maxMisbehaviorRecords {
+		copy(m.records, m.records[1:])
+		m.records = m.records[:len(m.records)-1]
+	}
+	m.records = append(m.records, record)
+	m.mtx.Unlock()
+	return record
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+)
+
+// TestPeerMisbehavior ensures offenses are penalized and recorded according to
+// the misbehavior policy.
+func TestPeerMisbehavior(t *testing.T) {
+	policy := DefaultMisbehaviorPolicy()
+	policy.DisconnectThreshold = 50
+	m := NewPeerMisbehavior(policy)
+
+	tests := []struct {
+		offense   Offense
+		num       uint32
+		denom     uint32
+		wantScore uint32
+		action    MisbehaviorAction
+	}{
+		// Offenses which only disconnect don't increase the score.
+		{OffenseFilterNotLoaded, 1, 1, 0, MisbehaviorDisconnect},
+		// A getdata request of a third of the maximum size.
+		{OffenseGetDataRequest, 1, 3, 33, MisbehaviorNone},
+		{OffenseMempoolRequest, 1, 1, 66, MisbehaviorDisconnect},
+		{OffenseProtocolViolation, 1, 1, 166, MisbehaviorBan},
+	}
+	for i, test := range tests {
+		record := m.MisbehavedFraction(test.offense, test.num, test.denom,
+			test.offense.String())
+		if record.Score != test.wantScore || record.Action != test.action {
+			t.Fatalf("test %d: got score %d and action %v, want %d "+
+				"and %v", i, record.Score, record.Action,
+				test.wantScore, test.action)
+		}
+	}
+	if score := m.Score(); score != 166 {
+		t.Fatalf("Score: got %d, want 166", score)
+	}
+
+	// The most recent offenses are recorded, oldest first.
+	for i := 0; i < maxMisbehaviorRecords; i++ {
+		m.Misbehaved(OffenseEmptyAddr, "emptyaddr")
+	}
+	records := m.Records()
+	if len(records) != maxMisbehaviorRecords {
+		t.Fatalf("Records: got %d records, want %d", len(records),
+			maxMisbehaviorRecords)
+	}
+	for _, record := range records {
+		if record.Offense != OffenseEmptyAddr {
+			t.Fatalf("Records: got unexpected offense %v",
+				record.Offense)
+		}
+	}
+
+	// Offenses are still recorded and disconnect peers without changing
+	// the score when banning is disabled.
+	policy.DisableBanning = true
+	m = NewPeerMisbehavior(policy)
+	record := m.Misbehaved(OffenseProtocolViolation, "filterload")
+	if record.Score != 0 || record.Action != MisbehaviorDisconnect {
+		t.Fatalf("got score %d and action %v with banning disabled",
+			record.Score, record.Action)
+	}
+	if len(m.Records()) != 1 {
+		t.Fatal("offense not recorded with banning disabled")
+	}
+}
+
+// TestParseOffense ensures the names of all offenses can be parsed.
+func TestParseOffense(t *testing.T) {
+	for o := Offense(0); o < numOffenses; o++ {
+		parsed, err := ParseOffense(o.String())
+		if err != nil || parsed != o {
+			t.Fatalf("ParseOffense(%q): got %v, %v", o.String(),
+				parsed, err)
+		}
+	}
+	if _, err := ParseOffense("unknown"); err == nil {
+		t.Fatal("ParseOffense: expected error for unknown offense")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// maxOrphanHeaders is the maximum number of headers of blocks whose
+	// parents are unknown which are tracked.  The oldest header is evicted
+	// when the limit is reached.
+	maxOrphanHeaders = 500
+
+	// maxOrphanHeadersPerPeer is the maximum number of orphan headers
+	// which are tracked for a single peer.
+	maxOrphanHeadersPerPeer = 32
+
+	// orphanHeaderTTL is how long an orphan header is tracked before it
+	// expires.
+	orphanHeaderTTL = 10 * time.Minute
+
+	// maxOrphanBlockDistance is the maximum number of blocks the height of
+	// an orphan block may be ahead of the main chain for the block to be
+	// kept by the chain until its parents arrive.  Only the headers of
+	// orphan blocks which are further ahead are tracked, so peers can't
+	// make the node hold on to blocks it can't connect for a long time.
+	maxOrphanBlockDistance = 288
+
+	// maxOrphanRecoveryBlocks is the maximum number of blocks which are
+	// requested from a peer at a time once the headers connecting an
+	// orphan to the chain were received.
+	maxOrphanRecoveryBlocks = 16
+)
+
+// orphanHeader is the header of an announced block whose parent is unknown
+// along with the peer which announced it.
+type orphanHeader struct {
+	header     wire.BlockHeader
+	peer       *peerpkg.Peer
+	expiration time.Time
+}
+
+// removeOrphanHeader stops tracking the orphan header with the passed hash.
+func (sm *SyncManager) removeOrphanHeader(hash *chainhash.Hash) {
+	orphan, exists := sm.orphanHeaders[*hash]
+	if !exists {
+		return
+	}
+	delete(sm.orphanHeaders, *hash)
+	if state, exists := sm.peerStates[orphan.peer]; exists {
+		state.orphanHeaders--
+	}
+}
+
+// addOrphanHeader tracks the header of a block whose parent is unknown which
+// was announced by the passed peer.  Expired headers are removed, and the
+// oldest header is evicted when the limit is reached.  The header is ignored
+// when the peer already announced too many orphans.
+func (sm *SyncManager) addOrphanHeader(header *wire.BlockHeader, peer *peerpkg.Peer) {
+	hash := header.BlockHash()
+	if _, exists := sm.orphanHeaders[hash]; exists {
+		return
+	}
+
+	now := time.Now()
+	var oldest *orphanHeader
+	var oldestHash chainhash.Hash
+	for orphanHash, orphan := range sm.orphanHeaders {
+		if now.After(orphan.expiration) {
+			sm.removeOrphanHeader(&orphanHash)
+			continue
+		}
+		if oldest == nil || orphan.expiration.Before(oldest.expiration) {
+			oldest = orphan
+			oldestHash = orphanHash
+		}
+	}
+
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		return
+	}
+	if state.orphanHeaders >= maxOrphanHeadersPerPeer {
+		log.Debugf("Ignoring orphan header %v from %s which announced "+
+			"too many orphans", hash, peer)
+		return
+	}
+	if len(sm.orphanHeaders) >= maxOrphanHeaders && oldest != nil {
+		sm.removeOrphanHeader(&oldestHash)
+	}
+
+	sm.orphanHeaders[hash] = &orphanHeader{
+		header:     *header,
+		peer:       peer,
+		expiration: now.Add(orphanHeaderTTL),
+	}
+	state.orphanHeaders++
+}
+
+// removePeerOrphanHeaders stops tracking the orphan headers announced by the
+// passed peer.
+func (sm *SyncManager) removePeerOrphanHeaders(peer *peerpkg.Peer) {
+	for hash, orphan := range sm.orphanHeaders {
+		if orphan.peer == peer {
+			sm.removeOrphanHeader(&hash)
+		}
+	}
+}
+
+// requestOrphanHeaders asks the passed peer for the headers which connect the
+// blocks it announced to the main chain.
+func (sm *SyncManager) requestOrphanHeaders(peer *peerpkg.Peer, state *peerSyncState) {
+	locator, err := sm.chain.LatestBlockLocator()
+	if err != nil {
+		log.Warnf("Failed to get block locator for the latest block: "+
+			"%v", err)
+		return
+	}
+	if err := peer.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+		log.Warnf("Failed to send getheaders message to peer %s: %v",
+			peer, err)
+		return
+	}
+	state.requestedOrphanHeaders = true
+}
+
+// isDistantOrphan returns whether the parent of the passed block is unknown
+// and its height is too far ahead of the main chain for the block to be kept
+// by the chain until its parents arrive.
+func (sm *SyncManager) isDistantOrphan(block *ulordutil.Block) bool {
+	header := &block.MsgBlock().Header
+	if haveParent, err := sm.chain.HaveBlock(&header.PrevBlock); err != nil ||
+		haveParent {
+
+		return false
+	}
+	if !blockchain.ShouldHaveSerializedBlockHeight(header) {
+		return false
+	}
+	height, err := blockchain.ExtractCoinbaseHeight(block.Transactions()[0])
+	if err != nil {
+		return false
+	}
+	return height > sm.chain.BestSnapshot().Height+maxOrphanBlockDistance
+}
+
+// trackOrphanBlock tracks the header of the passed block whose parent is
+// unknown instead of handing the block to the chain, and asks the peer for the
+// headers which connect it to the main chain.
+func (sm *SyncManager) trackOrphanBlock(block *ulordutil.Block, peer *peerpkg.Peer, state *peerSyncState) {
+	log.Debugf("Tracking the header of orphan block %v from %s",
+		block.Hash(), peer)
+	sm.addOrphanHeader(&block.MsgBlock().Header, peer)
+	sm.requestOrphanHeaders(peer, state)
+}
+
+// requestBlocks requests the blocks with the passed hashes which are neither
+// known nor already requested from the passed peer.
+func (sm *SyncManager) requestBlocks(peer *peerpkg.Peer, state *peerSyncState, hashes []*chainhash.Hash) {
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+	for _, hash := range hashes {
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
+		}
+		if haveBlock, err := sm.chain.HaveBlock(hash); err != nil ||
+			haveBlock {
+
+			continue
+		}
+
+		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
+		if peer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
+		}
+		sm.requestedBlocks[*hash] = struct{}{}
+		sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
+		state.requestedBlocks[*hash] = struct{}{}
+		gdmsg.AddInvVect(iv)
+	}
+	if len(gdmsg.InvList) > 0 {
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleOrphanHeaders handles the headers a peer sent in response to a request
+// for the headers which connect its orphan blocks to the main chain.  The
+// first missing blocks are requested from the peer.
+func (sm *SyncManager) handleOrphanHeaders(peer *peerpkg.Peer, state *peerSyncState, headers []*wire.BlockHeader) {
+	state.requestedOrphanHeaders = false
+	if len(headers) == 0 {
+		return
+	}
+
+	// Ensure the headers connect to a known block and to each other.
+	haveParent, err := sm.chain.HaveBlock(&headers[0].PrevBlock)
+	if err != nil {
+		log.Warnf("Unable to check for block %v: %v",
+			headers[0].PrevBlock, err)
+		return
+	}
+	if !haveParent {
+		reason := fmt.Sprintf("block header %v does not connect to "+
+			"a known block", headers[0].BlockHash())
+		sm.peerNotifier.PeerMisbehaved(peer, OffenseUnconnectedHeaders,
+			reason)
+		return
+	}
+	hashes := make([]*chainhash.Hash, 0, len(headers))
+	for i, header := range headers {
+		hash := header.BlockHash()
+		if i > 0 && !header.PrevBlock.IsEqual(hashes[i-1]) {
+			reason := fmt.Sprintf("block header %v does not "+
+				"properly connect to the previous header", hash)
+			sm.peerNotifier.PeerMisbehaved(peer,
+				OffenseUnconnectedHeaders, reason)
+			return
+		}
+		hashes = append(hashes, &hash)
+		sm.removeOrphanHeader(&hash)
+	}
+
+	// Request the first missing blocks.  The following ones are requested
+	// once they connect.
+	var missing []*chainhash.Hash
+	for _, hash := range hashes {
+		haveBlock, err := sm.chain.HaveBlock(hash)
+		if err != nil {
+			log.Warnf("Unable to check for block %v: %v", hash, err)
+			return
+		}
+		if haveBlock {
+			continue
+		}
+		missing = append(missing, hash)
+		if len(missing) == maxOrphanRecoveryBlocks {
+			break
+		}
+	}
+	sm.requestBlocks(peer, state, missing)
+}
+
+// requestOrphanChildren requests the blocks whose orphan headers extend the
+// block with the passed hash from the peers which announced them.
+func (sm *SyncManager) requestOrphanChildren(hash *chainhash.Hash) {
+	for childHash, orphan := range sm.orphanHeaders {
+		if !orphan.header.PrevBlock.IsEqual(hash) {
+			continue
+		}
+		childHash := childHash
+		if state, exists := sm.peerStates[orphan.peer]; exists {
+			sm.requestBlocks(orphan.peer, state,
+				[]*chainhash.Hash{&childHash})
+		}
+		sm.removeOrphanHeader(&childHash)
+	}
+}
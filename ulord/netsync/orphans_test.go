@@ -0,0 +1,95 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// TestOrphanHeaders ensures the orphan headers are bounded per peer and in
+// total and are removed when they expire or their peer disconnects.
+func TestOrphanHeaders(t *testing.T) {
+	DisableLog()
+	sm := &SyncManager{
+		peerStates:    make(map[*peerpkg.Peer]*peerSyncState),
+		orphanHeaders: make(map[chainhash.Hash]*orphanHeader),
+	}
+	newPeer := func() *peerpkg.Peer {
+		peer := peerpkg.NewInboundPeer(&peerpkg.Config{})
+		sm.peerStates[peer] = &peerSyncState{}
+		return peer
+	}
+	var nonce uint32
+	newHeader := func() *wire.BlockHeader {
+		nonce++
+		return &wire.BlockHeader{Nonce: nonce}
+	}
+
+	// Peers can't announce more than their share of orphans.
+	peer1 := newPeer()
+	for i := 0; i < maxOrphanHeadersPerPeer+1; i++ {
+		sm.addOrphanHeader(newHeader(), peer1)
+	}
+	if len(sm.orphanHeaders) != maxOrphanHeadersPerPeer {
+		t.Fatalf("got %d orphan headers, want %d",
+			len(sm.orphanHeaders), maxOrphanHeadersPerPeer)
+	}
+	if n := sm.peerStates[peer1].orphanHeaders; n != maxOrphanHeadersPerPeer {
+		t.Fatalf("got %d orphan headers for peer, want %d", n,
+			maxOrphanHeadersPerPeer)
+	}
+
+	// The oldest header is evicted once the limit is reached.
+	first := newHeader()
+	sm.addOrphanHeader(first, newPeer())
+	sm.orphanHeaders[first.BlockHash()].expiration = time.Now().Add(time.Minute)
+	for len(sm.orphanHeaders) < maxOrphanHeaders {
+		peer := newPeer()
+		for i := 0; i < maxOrphanHeadersPerPeer &&
+			len(sm.orphanHeaders) < maxOrphanHeaders; i++ {
+
+			sm.addOrphanHeader(newHeader(), peer)
+		}
+	}
+	last := newHeader()
+	sm.addOrphanHeader(last, newPeer())
+	if len(sm.orphanHeaders) != maxOrphanHeaders {
+		t.Fatalf("got %d orphan headers, want %d",
+			len(sm.orphanHeaders), maxOrphanHeaders)
+	}
+	if _, exists := sm.orphanHeaders[first.BlockHash()]; exists {
+		t.Fatal("oldest orphan header was not evicted")
+	}
+	if _, exists := sm.orphanHeaders[last.BlockHash()]; !exists {
+		t.Fatal("newest orphan header was not added")
+	}
+
+	// Expired headers are removed.
+	for _, orphan := range sm.orphanHeaders {
+		if orphan.peer != peer1 {
+			orphan.expiration = time.Now().Add(-time.Second)
+		}
+	}
+	sm.addOrphanHeader(newHeader(), newPeer())
+	if len(sm.orphanHeaders) != maxOrphanHeadersPerPeer+1 {
+		t.Fatalf("got %d orphan headers after expiration, want %d",
+			len(sm.orphanHeaders), maxOrphanHeadersPerPeer+1)
+	}
+
+	// The headers of disconnected peers are removed.
+	sm.removePeerOrphanHeaders(peer1)
+	if len(sm.orphanHeaders) != 1 {
+		t.Fatalf("got %d orphan headers after removing peer, want 1",
+			len(sm.orphanHeaders))
+	}
+	if n := sm.peerStates[peer1].orphanHeaders; n != 0 {
+		t.Fatalf("got %d orphan headers for removed peer, want 0", n)
+	}
+}
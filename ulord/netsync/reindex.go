@@ -0,0 +1,212 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/blockchain/indexers"
+)
+
+const (
+	// reindexBatchSize is the maximum number of blocks a reindex job
+	// processes before the block handler handles other messages.
+	reindexBatchSize = 100
+
+	// reindexLogInterval is the minimum amount of time between the
+	// progress messages logged by a reindex job.
+	reindexLogInterval = 10 * time.Second
+)
+
+// ErrReindexCanceled is the error of reindex jobs which were canceled before
+// they finished.
+var ErrReindexCanceled = errors.New("reindex job canceled")
+
+// ReindexProgress describes the progress of a reindex job.
+type ReindexProgress struct {
+	// Height is the lowest height of the tips of the indexes which are
+	// being reindexed.
+	Height int32
+
+	// BestHeight is the height of the main chain.
+	BestHeight int32
+}
+
+// ReindexJob is a job which processes the blocks of the main chain starting at
+// a given height again through some of the indexes.  The blocks are processed
+// in batches by the block handler in between the other messages it handles, so
+// the node keeps running.
+type ReindexJob struct {
+	indexes     []indexers.Indexer
+	startHeight int32
+	progress    func(ReindexProgress)
+	lastLog     time.Time
+
+	cancelOnce sync.Once
+	quit       chan struct{}
+	done       chan struct{}
+	err        error
+}
+
+// Cancel stops the job.  The indexes which did not catch up with the main chain
+// yet are not updated until another reindex job catches them up or the node is
+// restarted.
+func (j *ReindexJob) Cancel() {
+	j.cancelOnce.Do(func() {
+		close(j.quit)
+	})
+}
+
+// Done returns a channel which is closed when the job finished, failed, or was
+// canceled.
+func (j *ReindexJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Err returns the reason the job stopped.  It is nil when the job finished and
+// must only be called after the channel returned by Done is closed.
+func (j *ReindexJob) Err() error {
+	return j.err
+}
+
+// reindexMsg is a message type to be sent across the message channel for
+// starting a reindex job.
+type reindexMsg struct {
+	job   *ReindexJob
+	reply chan error
+}
+
+// reindexStepMsg is a message type to be sent across the message channel for
+// processing the next batch of blocks of a reindex job.
+type reindexStepMsg struct {
+	job *ReindexJob
+}
+
+// queueReindexStep queues the next batch of blocks of the passed job.  The
+// message is sent from a separate goroutine since it is invoked from the block
+// handler.
+func (sm *SyncManager) queueReindexStep(job *ReindexJob) {
+	go func() {
+		select {
+		case sm.msgChan <- reindexStepMsg{job: job}:
+		case <-sm.quit:
+		}
+	}()
+}
+
+// finishReindexJob stops the passed job with the passed error.
+func (sm *SyncManager) finishReindexJob(job *ReindexJob, err error) {
+	if err != nil && err != ErrReindexCanceled {
+		log.Errorf("Reindex job failed: %v", err)
+	}
+	job.err = err
+	close(job.done)
+	if sm.reindexJob == job {
+		sm.reindexJob = nil
+	}
+}
+
+// handleReindexMsg starts the reindex job of the passed message unless another
+// job is running.
+func (sm *SyncManager) handleReindexMsg(msg reindexMsg) {
+	if sm.reindexJob != nil {
+		msg.reply <- errors.New("a reindex job is already running")
+		return
+	}
+	err := sm.indexManager.Reindex(msg.job.indexes, msg.job.startHeight)
+	if err != nil {
+		msg.reply <- err
+		return
+	}
+
+	sm.reindexJob = msg.job
+	sm.queueReindexStep(msg.job)
+	msg.reply <- nil
+}
+
+// handleReindexStepMsg processes the next batch of blocks of a reindex job and
+// queues the following one until the indexes caught up with the main chain.
+func (sm *SyncManager) handleReindexStepMsg(msg reindexStepMsg) {
+	job := msg.job
+	select {
+	case <-job.quit:
+		sm.finishReindexJob(job, ErrReindexCanceled)
+		return
+	default:
+	}
+
+	height, done, err := sm.indexManager.ReindexStep(reindexBatchSize)
+	if err != nil {
+		sm.finishReindexJob(job, err)
+		return
+	}
+
+	best := sm.chain.BestSnapshot()
+	progress := ReindexProgress{Height: height, BestHeight: best.Height}
+	if job.progress != nil {
+		job.progress(progress)
+	}
+	if done {
+		sm.finishReindexJob(job, nil)
+		return
+	}
+	if time.Since(job.lastLog) >= reindexLogInterval {
+		log.Infof("Reindexing at height %d of %d", progress.Height,
+			progress.BestHeight)
+		job.lastLog = time.Now()
+	}
+	sm.queueReindexStep(job)
+}
+
+// Reindex starts a job which processes the blocks of the main chain starting at
+// the passed height again through the indexes with the passed names, or all
+// enabled indexes when none are passed.  The progress callback, if any, is
+// invoked from the block handler after each batch of blocks and must not block
+// or call into the sync manager.  Only one job can run at a time.
+func (sm *SyncManager) Reindex(startHeight int32, indexNames []string,
+	progress func(ReindexProgress)) (*ReindexJob, error) {
+
+	if sm.indexManager == nil {
+		return nil, errors.New("no indexes are enabled")
+	}
+
+	indexes := sm.indexManager.Indexes()
+	if len(indexNames) != 0 {
+		selected := make([]indexers.Indexer, 0, len(indexNames))
+	nextName:
+		for _, name := range indexNames {
+			for _, indexer := range indexes {
+				if indexer.Name() == name {
+					selected = append(selected, indexer)
+					continue nextName
+				}
+			}
+			return nil, fmt.Errorf("the %s is not enabled", name)
+		}
+		indexes = selected
+	}
+
+	job := &ReindexJob{
+		indexes:     indexes,
+		startHeight: startHeight,
+		progress:    progress,
+		lastLog:     time.Now(),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	reply := make(chan error, 1)
+	select {
+	case sm.msgChan <- reindexMsg{job: job, reply: reply}:
+	case <-sm.quit:
+		return nil, errors.New("the sync manager is shutting down")
+	}
+	if err := <-reply; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
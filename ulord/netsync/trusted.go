@@ -0,0 +1,123 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"net"
+
+	"github.com/ulordsuite/ulord/mempool"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+)
+
+// setTrustedPeerMsg is a message type to be sent across the message channel
+// for adding or removing a network of trusted peers.
+type setTrustedPeerMsg struct {
+	ipNet   *net.IPNet
+	trusted bool
+	reply   chan error
+}
+
+// getTrustedPeersMsg is a message type to be sent across the message channel
+// for retrieving the networks of trusted peers.
+type getTrustedPeersMsg struct {
+	reply chan []*net.IPNet
+}
+
+// sameIPNet returns whether both networks are the same.
+func sameIPNet(a, b *net.IPNet) bool {
+	return a.IP.Equal(b.IP) && a.Mask.String() == b.Mask.String()
+}
+
+// isTrustedPeer returns whether the address of the passed peer is included in
+// the networks of trusted peers.
+func (sm *SyncManager) isTrustedPeer(peer *peerpkg.Peer) bool {
+	if len(sm.trustedPeers) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(peer.Addr())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range sm.trustedPeers {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// setPeerTrusted updates whether the passed peer is trusted.  The transactions
+// of trusted peers bypass the relay policy of the mempool.
+func (sm *SyncManager) setPeerTrusted(peer *peerpkg.Peer, state *peerSyncState, trusted bool) {
+	if state.trusted == trusted {
+		return
+	}
+	state.trusted = trusted
+	sm.txMemPool.SetTagTrusted(mempool.Tag(peer.ID()), trusted)
+	if trusted {
+		log.Infof("Trusting peer %s", peer)
+	} else {
+		log.Infof("No longer trusting peer %s", peer)
+	}
+}
+
+// handleSetTrustedPeerMsg adds or removes the network of the passed message to
+// or from the networks of trusted peers and updates the connected peers.
+func (sm *SyncManager) handleSetTrustedPeerMsg(msg setTrustedPeerMsg) {
+	index := -1
+	for i, ipNet := range sm.trustedPeers {
+		if sameIPNet(ipNet, msg.ipNet) {
+			index = i
+			break
+		}
+	}
+	switch {
+	case msg.trusted && index != -1:
+		msg.reply <- errors.New("the network is already trusted")
+		return
+	case msg.trusted:
+		sm.trustedPeers = append(sm.trustedPeers, msg.ipNet)
+	case index == -1:
+		msg.reply <- errors.New("the network is not trusted")
+		return
+	default:
+		sm.trustedPeers = append(sm.trustedPeers[:index],
+			sm.trustedPeers[index+1:]...)
+	}
+
+	for peer, state := range sm.peerStates {
+		sm.setPeerTrusted(peer, state, sm.isTrustedPeer(peer))
+	}
+	msg.reply <- nil
+}
+
+// SetTrustedPeer adds or removes the passed network of trusted peers.  The
+// transactions of trusted peers bypass the relay policy, but not the consensus
+// rules, and trusted peers are preferred when choosing the peer to sync from.
+func (sm *SyncManager) SetTrustedPeer(ipNet *net.IPNet, trusted bool) error {
+	reply := make(chan error, 1)
+	select {
+	case sm.msgChan <- setTrustedPeerMsg{ipNet: ipNet, trusted: trusted, reply: reply}:
+	case <-sm.quit:
+		return errors.New("the sync manager is shutting down")
+	}
+	return <-reply
+}
+
+// TrustedPeers returns the networks of trusted peers.
+func (sm *SyncManager) TrustedPeers() []*net.IPNet {
+	reply := make(chan []*net.IPNet, 1)
+	select {
+	case sm.msgChan <- getTrustedPeersMsg{reply: reply}:
+	case <-sm.quit:
+		return nil
+	}
+	return <-reply
+}
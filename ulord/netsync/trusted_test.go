@@ -0,0 +1,78 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ulordsuite/ulord/mempool"
+	peerpkg "github.com/ulordsuite/ulord/peer"
+)
+
+// TestSetTrustedPeer ensures connected peers are trusted and no longer trusted
+// as the networks of trusted peers are added and removed.
+func TestSetTrustedPeer(t *testing.T) {
+	DisableLog()
+	sm := &SyncManager{
+		txMemPool:  mempool.New(&mempool.Config{}),
+		peerStates: make(map[*peerpkg.Peer]*peerSyncState),
+	}
+	newPeer := func(addr string) *peerpkg.Peer {
+		peer, err := peerpkg.NewOutboundPeer(&peerpkg.Config{}, addr)
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected error: %v", err)
+		}
+		sm.peerStates[peer] = &peerSyncState{}
+		return peer
+	}
+	peer1 := newPeer("10.0.0.1:9888")
+	peer2 := newPeer("192.168.1.1:9888")
+
+	setTrustedPeer := func(network string, trusted bool) error {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			t.Fatalf("ParseCIDR: unexpected error: %v", err)
+		}
+		reply := make(chan error, 1)
+		sm.handleSetTrustedPeerMsg(setTrustedPeerMsg{
+			ipNet:   ipNet,
+			trusted: trusted,
+			reply:   reply,
+		})
+		return <-reply
+	}
+	checkTrusted := func(want1, want2 bool) {
+		t.Helper()
+		if got := sm.peerStates[peer1].trusted; got != want1 {
+			t.Fatalf("peer 1 trusted: got %v, want %v", got, want1)
+		}
+		if got := sm.peerStates[peer2].trusted; got != want2 {
+			t.Fatalf("peer 2 trusted: got %v, want %v", got, want2)
+		}
+	}
+
+	if err := setTrustedPeer("10.0.0.0/8", true); err != nil {
+		t.Fatalf("SetTrustedPeer: unexpected error: %v", err)
+	}
+	checkTrusted(true, false)
+	if err := setTrustedPeer("10.0.0.0/8", true); err == nil {
+		t.Fatal("SetTrustedPeer: expected error for trusted network")
+	}
+	if err := setTrustedPeer("192.168.1.1/32", true); err != nil {
+		t.Fatalf("SetTrustedPeer: unexpected error: %v", err)
+	}
+	checkTrusted(true, true)
+	if err := setTrustedPeer("10.0.0.0/8", false); err != nil {
+		t.Fatalf("SetTrustedPeer: unexpected error: %v", err)
+	}
+	checkTrusted(false, true)
+	if err := setTrustedPeer("10.0.0.0/8", false); err == nil {
+		t.Fatal("SetTrustedPeer: expected error for untrusted network")
+	}
+	if len(sm.trustedPeers) != 1 {
+		t.Fatalf("got %d trusted networks, want 1", len(sm.trustedPeers))
+	}
+}
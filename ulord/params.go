@@ -17,7 +17,8 @@ var activeNetParams = &mainNetParams
 // network and test networks.
 type params struct {
 	*chaincfg.Params
-	rpcPort string
+	rpcPort  string
+	grpcPort string
 }
 
 // mainNetParams contains parameters specific to the main network
@@ -27,8 +28,9 @@ type params struct {
 // it does not handle on to ulord.  This approach allows the wallet process
 // to emulate the full reference implementation RPC API.
 var mainNetParams = params{
-	Params:  &chaincfg.MainNetParams,
-	rpcPort: "8334",
+	Params:   &chaincfg.MainNetParams,
+	rpcPort:  "8334",
+	grpcPort: "8335",
 }
 
 // regressionNetParams contains parameters specific to the regression test
@@ -36,23 +38,37 @@ var mainNetParams = params{
 // than the reference implementation - see the mainNetParams comment for
 // details.
 var regressionNetParams = params{
-	Params:  &chaincfg.RegressionNetParams,
-	rpcPort: "18334",
+	Params:   &chaincfg.RegressionNetParams,
+	rpcPort:  "18334",
+	grpcPort: "18335",
 }
 
 // testNet3Params contains parameters specific to the test network (version 3)
 // (wire.TestNet3).  NOTE: The RPC port is intentionally different than the
 // reference implementation - see the mainNetParams comment for details.
 var testNet3Params = params{
-	Params:  &chaincfg.TestNet3Params,
-	rpcPort: "18334",
+	Params:   &chaincfg.TestNet3Params,
+	rpcPort:  "18334",
+	grpcPort: "18335",
 }
 
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
-	Params:  &chaincfg.SimNetParams,
-	rpcPort: "18556",
+	Params:   &chaincfg.SimNetParams,
+	rpcPort:  "18556",
+	grpcPort: "18557",
+}
+
+// newCustomNetParams returns the parameters specific to a custom network
+// loaded with --netparams.  Custom networks use the RPC ports of the test
+// networks.
+func newCustomNetParams(chainParams *chaincfg.Params) *params {
+	return &params{
+		Params:   chainParams,
+		rpcPort:  "18334",
+		grpcPort: "18335",
+	}
 }
 
 // netName returns the name used when referring to a bitcoin network.  At the
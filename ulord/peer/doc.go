@@ -92,6 +92,17 @@ It is often useful to use closures which encapsulate state when specifying the
 callback handlers.  This provides a clean method for accessing that state when
 callbacks are invoked.
 
+Handshake Extensions
+
+Subsystems which need to perform additional handshakes with peers, such as
+authenticating masternodes, can do so by specifying HandshakeExtensions in the
+Config struct.  Each extension is started once the verack message is received
+from a peer advertising the services it requires, is passed the messages
+received from the peer until it reports completion, and fails when it does not
+complete within its timeout.  A failed extension disconnects the peer unless it
+is optional.  The OnHandshakeComplete callback is invoked once all extensions
+completed.
+
 Queuing Messages and Inventory
 
 The QueueMessage function provides the fundamental means to send messages to the
@@ -16,3 +16,11 @@ package peer
 func TstAllowSelfConns() {
 	allowSelfConns = true
 }
+
+// TstSetSendHeadersPreferred sets whether the peer sent a sendheaders message
+// without it having to be received.
+func (p *Peer) TstSetSendHeadersPreferred(preferred bool) {
+	p.flagsMtx.Lock()
+	p.sendHeadersPreferred = preferred
+	p.flagsMtx.Unlock()
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// ErrHandshakeTimeout is the error a handshake extension fails with when it
+// does not complete within its timeout.
+var ErrHandshakeTimeout = errors.New("handshake extension timed out")
+
+// HandshakeExtension describes a sub-handshake which is performed with peers
+// once the version and verack messages were exchanged, such as a challenge
+// authenticating a masternode.  It allows higher layers to extend the handshake
+// without changes to the peer package.
+//
+// Extensions run concurrently with each other and with the regular processing
+// of messages, so they don't hold up other messages.  The OnHandshakeComplete
+// listener is invoked once all of them completed.
+type HandshakeExtension struct {
+	// Name identifies the extension in log messages.
+	Name string
+
+	// Services are the service flags the remote peer must advertise for the
+	// extension to be performed with it.  Extensions without services are
+	// performed with all peers.
+	Services wire.ServiceFlag
+
+	// Timeout is the maximum duration the extension may take to complete
+	// after the verack message was received.  Defaults to the timeout of
+	// the version negotiation when zero.
+	Timeout time.Duration
+
+	// Optional specifies that a failure of the extension is logged instead
+	// of disconnecting the peer.
+	Optional bool
+
+	// Start is invoked when the extension begins and typically queues the
+	// messages which initiate the sub-handshake.  It may be nil when the
+	// remote peer initiates it.  Returning an error fails the extension.
+	Start func(p *Peer) error
+
+	// HandleMessage is invoked with each message received from the peer
+	// until the extension completes and returns whether it completed.  The
+	// messages are processed as usual as well.  Returning an error fails
+	// the extension.  The extension completes as soon as it started when
+	// HandleMessage is nil.
+	HandleMessage func(p *Peer, msg wire.Message) (bool, error)
+}
+
+// pendingExtension tracks a handshake extension which did not complete yet.
+type pendingExtension struct {
+	ext   *HandshakeExtension
+	timer *time.Timer
+}
+
+// HandshakeComplete returns whether the version and verack messages were
+// exchanged with the peer and all handshake extensions performed with it
+// completed.
+//
+// This function is safe for concurrent access.
+func (p *Peer) HandshakeComplete() bool {
+	p.extMtx.Lock()
+	complete := p.handshakeComplete
+	p.extMtx.Unlock()
+	return complete
+}
+
+// startHandshakeExtensions begins the handshake extensions which apply to the
+// peer.  It is invoked from the input handler once the verack message was
+// received.
+func (p *Peer) startHandshakeExtensions() {
+	services := p.Services()
+	p.extMtx.Lock()
+	for _, ext := range p.cfg.HandshakeExtensions {
+		if services&ext.Services != ext.Services {
+			continue
+		}
+		timeout := ext.Timeout
+		if timeout <= 0 {
+			timeout = negotiateTimeout
+		}
+		pe := &pendingExtension{ext: ext}
+		pe.timer = time.AfterFunc(timeout, func() {
+			p.failHandshakeExtension(pe, ErrHandshakeTimeout)
+		})
+		p.pendingExts = append(p.pendingExts, pe)
+	}
+	pending := make([]*pendingExtension, len(p.pendingExts))
+	copy(pending, p.pendingExts)
+	p.extMtx.Unlock()
+
+	for _, pe := range pending {
+		log.Debugf("Starting handshake extension %s with peer %s",
+			pe.ext.Name, p)
+		if pe.ext.Start != nil {
+			if err := pe.ext.Start(p); err != nil {
+				p.failHandshakeExtension(pe, err)
+				continue
+			}
+		}
+		if pe.ext.HandleMessage == nil {
+			p.completeHandshakeExtension(pe)
+		}
+	}
+	p.maybeCompleteHandshake()
+}
+
+// handleHandshakeMsg passes the passed message received from the peer to the
+// handshake extensions which did not complete yet.  It is invoked from the
+// input handler.
+func (p *Peer) handleHandshakeMsg(msg wire.Message) {
+	p.extMtx.Lock()
+	pending := make([]*pendingExtension, len(p.pendingExts))
+	copy(pending, p.pendingExts)
+	p.extMtx.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	for _, pe := range pending {
+		done, err := pe.ext.HandleMessage(p, msg)
+		if err != nil {
+			p.failHandshakeExtension(pe, err)
+			continue
+		}
+		if done {
+			p.completeHandshakeExtension(pe)
+		}
+	}
+	p.maybeCompleteHandshake()
+}
+
+// removePendingExtension removes the passed handshake extension from the ones
+// which did not complete yet and returns whether it was pending.
+func (p *Peer) removePendingExtension(pe *pendingExtension) bool {
+	p.extMtx.Lock()
+	defer p.extMtx.Unlock()
+
+	for i, other := range p.pendingExts {
+		if other == pe {
+			pe.timer.Stop()
+			p.pendingExts = append(p.pendingExts[:i],
+				p.pendingExts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// completeHandshakeExtension marks the passed handshake extension completed.
+func (p *Peer) completeHandshakeExtension(pe *pendingExtension) {
+	if p.removePendingExtension(pe) {
+		log.Debugf("Completed handshake extension %s with peer %s",
+			pe.ext.Name, p)
+	}
+}
+
+// failHandshakeExtension handles the failure of the passed handshake extension
+// by disconnecting the peer unless the extension is optional.
+func (p *Peer) failHandshakeExtension(pe *pendingExtension, err error) {
+	if !p.removePendingExtension(pe) {
+		return
+	}
+
+	if pe.ext.Optional {
+		log.Debugf("Optional handshake extension %s with peer %s "+
+			"failed: %v", pe.ext.Name, p, err)
+		p.maybeCompleteHandshake()
+		return
+	}
+
+	log.Infof("Handshake extension %s with peer %s failed: %v -- "+
+		"disconnecting", pe.ext.Name, p, err)
+	p.Disconnect()
+}
+
+// maybeCompleteHandshake marks the handshake completed and notifies the
+// listener once no handshake extension is pending anymore.
+func (p *Peer) maybeCompleteHandshake() {
+	if atomic.LoadInt32(&p.disconnect) != 0 {
+		return
+	}
+
+	p.extMtx.Lock()
+	if p.handshakeComplete || len(p.pendingExts) != 0 {
+		p.extMtx.Unlock()
+		return
+	}
+	p.handshakeComplete = true
+	p.extMtx.Unlock()
+
+	if p.cfg.Listeners.OnHandshakeComplete != nil {
+		p.cfg.Listeners.OnHandshakeComplete(p)
+	}
+}
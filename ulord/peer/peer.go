@@ -106,6 +106,13 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 bitcoin
+	// message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -194,6 +201,12 @@ type MessageListeners struct {
 	// OnVerAck is invoked when a peer receives a verack bitcoin message.
 	OnVerAck func(p *Peer, msg *wire.MsgVerAck)
 
+	// OnHandshakeComplete is invoked once the verack message was received
+	// and all handshake extensions performed with the peer completed.  It
+	// may be invoked from a goroutine other than the input handler when
+	// an optional extension timed out.
+	OnHandshakeComplete func(p *Peer)
+
 	// OnReject is invoked when a peer receives a reject bitcoin message.
 	OnReject func(p *Peer, msg *wire.MsgReject)
 
@@ -201,6 +214,19 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnMNBroadcast is invoked when a peer receives a Ulord mnb message.
+	OnMNBroadcast func(p *Peer, msg *wire.MsgMNBroadcast)
+
+	// OnMNPing is invoked when a peer receives a Ulord mnp message.
+	OnMNPing func(p *Peer, msg *wire.MsgMNPing)
+
+	// OnTxLockVote is invoked when a peer receives a Ulord txlvote
+	// message.
+	OnTxLockVote func(p *Peer, msg *wire.MsgTxLockVote)
+
+	// OnSpork is invoked when a peer receives a Ulord spork message.
+	OnSpork func(p *Peer, msg *wire.MsgSpork)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -260,8 +286,8 @@ type Config struct {
 	Services wire.ServiceFlag
 
 	// ProtocolVersion specifies the maximum protocol version to use and
-	// advertise.  This field can be omitted in which case
-	// peer.MaxProtocolVersion will be used.
+	// advertise.  This field can be omitted in which case the version
+	// returned by MaxVersion for the chain parameters will be used.
 	ProtocolVersion uint32
 
 	// DisableRelayTx specifies if the remote peer should be informed to
@@ -275,6 +301,30 @@ type Config struct {
 	// TrickleInterval is the duration of the ticker which trickles down the
 	// inventory to a peer.
 	TrickleInterval time.Duration
+
+	// MessageHook specifies an optional callback which is invoked with the
+	// command and size of every message read from or written to the peer.
+	// It may be used for bandwidth accounting and misbehavior scoring.
+	// The callback is invoked from the peer's read and write goroutines,
+	// so it must be safe for concurrent access and must not block.
+	MessageHook wire.MessageHook
+
+	// MaxUploadRate and MaxDownloadRate are the maximum number of bytes
+	// per second which are sent to and received from the peer.  Zero
+	// disables the respective limit.
+	MaxUploadRate   int64
+	MaxDownloadRate int64
+
+	// UploadLimiter and DownloadLimiter optionally limit the aggregate
+	// rate at which bytes are sent to and received from all peers sharing
+	// them in addition to the limits of the peer.
+	UploadLimiter   *RateLimiter
+	DownloadLimiter *RateLimiter
+
+	// HandshakeExtensions are the sub-handshakes performed with the peer
+	// after the verack message was received when it advertises the
+	// services they require.
+	HandshakeExtensions []*HandshakeExtension
 }
 
 // minUint32 is a helper function to return the minimum of two uint32s.
@@ -379,6 +429,18 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+
+	// BytesSentPerMsg and BytesRecvPerMsg are the number of bytes sent
+	// and received by message command.
+	BytesSentPerMsg map[string]uint64
+	BytesRecvPerMsg map[string]uint64
+
+	// PingMicrosP50, PingMicrosP90, and PingMicrosP99 are percentiles of
+	// the recent ping times in microseconds, or zero when no ping
+	// returned yet.
+	PingMicrosP50 int64
+	PingMicrosP90 int64
+	PingMicrosP99 int64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -445,6 +507,7 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	sendAddrV2           bool   // peer sent a sendaddrv2 message
 	verAckReceived       bool
 	witnessEnabled       bool
 
@@ -469,6 +532,21 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	pingSamples        []int64   // Recent ping times in microseconds.
+	nextPingSample     int       // Index of the oldest ping sample.
+	bytesSentPerMsg    map[string]uint64
+	bytesRecvPerMsg    map[string]uint64
+
+	// uploadLimiter and downloadLimiter limit the rates at which bytes are
+	// sent to and received from the peer.  They are nil when disabled.
+	uploadLimiter   *RateLimiter
+	downloadLimiter *RateLimiter
+
+	// The handshake extensions which did not complete yet and whether the
+	// handshake completed are protected by the extMtx mutex.
+	extMtx            sync.Mutex
+	pendingExts       []*pendingExtension
+	handshakeComplete bool
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
@@ -553,6 +631,12 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+
+		BytesSentPerMsg: copyMsgBytes(p.bytesSentPerMsg),
+		BytesRecvPerMsg: copyMsgBytes(p.bytesRecvPerMsg),
+		PingMicrosP50:   pingPercentile(p.pingSamples, 50),
+		PingMicrosP90:   pingPercentile(p.pingSamples, 90),
+		PingMicrosP99:   pingPercentile(p.pingSamples, 99),
 	}
 
 	p.statsMtx.RUnlock()
@@ -796,6 +880,53 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// WantsAddrV2 returns if the peer signaled support for addrv2 messages, which
+// must be sent to it instead of addr messages, as described by BIP0155.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	sendAddrV2 := p.sendAddrV2
+	p.flagsMtx.Unlock()
+
+	return sendAddrV2
+}
+
+// AnnounceBlockHeader announces the block with the passed header to the peer
+// with a headers message as described by BIP0130.  The block is only announced
+// when the peer sent a sendheaders message and is known to have the parent of
+// the block, since the peer can't connect the header otherwise.  It returns
+// whether the block was announced, so the caller can fall back to announcing
+// it with an inventory vector instead.
+//
+// This function is safe for concurrent access.
+func (p *Peer) AnnounceBlockHeader(header *wire.BlockHeader) bool {
+	if !p.WantsHeaders() {
+		return false
+	}
+	parentIV := wire.NewInvVect(wire.InvTypeBlock, &header.PrevBlock)
+	lastAnnounced := p.LastAnnouncedBlock()
+	if !p.knownInventory.Exists(parentIV) && (lastAnnounced == nil ||
+		!lastAnnounced.IsEqual(&header.PrevBlock)) {
+
+		return false
+	}
+
+	blockHash := header.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	if p.knownInventory.Exists(iv) {
+		return true
+	}
+	msg := wire.NewMsgHeaders()
+	if err := msg.AddBlockHeader(header); err != nil {
+		log.Errorf("Failed to add block header: %v", err)
+		return false
+	}
+	p.AddKnownInventory(iv)
+	p.QueueMessage(msg, nil)
+	return true
+}
+
 // IsWitnessEnabled returns true if the peer has signalled that it supports
 // segregated witness.
 //
@@ -844,6 +975,39 @@ func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, er
 	return msg.AddrList, nil
 }
 
+// PushAddrV2Msg sends an addrv2 message to the connected peer using the
+// provided addresses.  Like PushAddrMsg, a random subset of the addresses is
+// sent when there are more than the maximum allowed, and the addresses which
+// were actually sent are returned.  It should only be used for peers which
+// want addrv2 messages as reported by WantsAddrV2.
+func (p *Peer) PushAddrV2Msg(addresses []*wire.NetAddressV2) ([]*wire.NetAddressV2, error) {
+	addressCount := len(addresses)
+
+	// Nothing to send.
+	if addressCount == 0 {
+		return nil, nil
+	}
+
+	msg := wire.NewMsgAddrV2()
+	msg.AddrList = make([]*wire.NetAddressV2, addressCount)
+	copy(msg.AddrList, addresses)
+
+	// Randomize the addresses sent if there are more than the maximum allowed.
+	if addressCount > wire.MaxAddrPerMsg {
+		// Shuffle the address list.
+		for i := 0; i < wire.MaxAddrPerMsg; i++ {
+			j := i + rand.Intn(addressCount-i)
+			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+		}
+
+		// Truncate it to the maximum size.
+		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+	}
+
+	p.QueueMessage(msg, nil)
+	return msg.AddrList, nil
+}
+
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
 // and stop hash.  It will ignore back-to-back duplicate requests.
 //
@@ -914,13 +1078,9 @@ func (p *Peer) PushGetHeadersMsg(locator blockchain.BlockLocator, stopHash *chai
 	}
 
 	// Construct the getheaders request and queue it to be sent.
-	msg := wire.NewMsgGetHeaders()
-	msg.HashStop = *stopHash
-	for _, hash := range locator {
-		err := msg.AddBlockLocatorHash(hash)
-		if err != nil {
-			return err
-		}
+	msg, err := wire.NewMsgGetHeadersFromLocator(locator, stopHash)
+	if err != nil {
+		return err
 	}
 	p.QueueMessage(msg, nil)
 
@@ -999,6 +1159,7 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
 			p.lastPingNonce = 0
+			p.addPingSample(p.lastPingMicros)
 		}
 		p.statsMtx.Unlock()
 	}
@@ -1006,12 +1167,20 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	n, msg, buf, err := wire.ReadMessageWithHookN(p.conn,
+		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding,
+		p.cfg.MessageHook)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	if msg != nil {
+		p.addMsgBytes(p.bytesRecvPerMsg, msg.Command(), n)
+	}
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
+
+	// Delay reading the next message as required by the download limits.
+	p.downloadLimiter.Wait(n, p.quit)
+	p.cfg.DownloadLimiter.Wait(n, p.quit)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1069,12 +1238,18 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	n, err := wire.WriteMessageWithHookN(p.conn, msg,
+		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc,
+		p.cfg.MessageHook)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.addMsgBytes(p.bytesSentPerMsg, msg.Command(), n)
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
+
+	// Delay writing the next message as required by the upload limits.
+	p.uploadLimiter.Wait(n, p.quit)
+	p.cfg.UploadLimiter.Wait(n, p.quit)
 	return err
 }
 
@@ -1367,6 +1542,13 @@ out:
 		atomic.StoreInt64(&p.lastRecv, time.Now().Unix())
 		p.stallControl <- stallControlMsg{sccReceiveMessage, rmsg}
 
+		// Pass the message to the handshake extensions which did not
+		// complete yet.  No read lock is necessary because
+		// verAckReceived is not written to in any other goroutine.
+		if p.verAckReceived {
+			p.handleHandshakeMsg(rmsg)
+		}
+
 		// Handle each supported message type.
 		p.stallControl <- stallControlMsg{sccHandlerStart, rmsg}
 		switch msg := rmsg.(type) {
@@ -1391,6 +1573,7 @@ out:
 			if p.cfg.Listeners.OnVerAck != nil {
 				p.cfg.Listeners.OnVerAck(p, msg)
 			}
+			p.startHandshakeExtensions()
 
 		case *wire.MsgGetAddr:
 			if p.cfg.Listeners.OnGetAddr != nil {
@@ -1402,6 +1585,24 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// Support for addrv2 messages must be signaled before
+			// the verack message as described by BIP0155, so the
+			// message is ignored afterwards.
+			p.flagsMtx.Lock()
+			if !p.verAckReceived {
+				p.sendAddrV2 = true
+			}
+			p.flagsMtx.Unlock()
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1528,6 +1729,26 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgMNBroadcast:
+			if p.cfg.Listeners.OnMNBroadcast != nil {
+				p.cfg.Listeners.OnMNBroadcast(p, msg)
+			}
+
+		case *wire.MsgMNPing:
+			if p.cfg.Listeners.OnMNPing != nil {
+				p.cfg.Listeners.OnMNPing(p, msg)
+			}
+
+		case *wire.MsgTxLockVote:
+			if p.cfg.Listeners.OnTxLockVote != nil {
+				p.cfg.Listeners.OnTxLockVote(p, msg)
+			}
+
+		case *wire.MsgSpork:
+			if p.cfg.Listeners.OnSpork != nil {
+				p.cfg.Listeners.OnSpork(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1959,12 +2180,13 @@ func (p *Peer) readRemoteVersionMsg() error {
 	// NOTE: If minAcceptableProtocolVersion is raised to be higher than
 	// wire.RejectVersion, this should send a reject packet before
 	// disconnecting.
-	if uint32(msg.ProtocolVersion) < MinAcceptableProtocolVersion {
+	minVersion := MinAcceptableVersion(p.cfg.ChainParams)
+	if uint32(msg.ProtocolVersion) < minVersion {
 		// Send a reject message indicating the protocol version is
 		// obsolete and wait for the message to be sent before
 		// disconnecting.
 		reason := fmt.Sprintf("protocol version must be %d or greater",
-			MinAcceptableProtocolVersion)
+			minVersion)
 		rejectMsg := wire.NewMsgReject(msg.Command(), wire.RejectObsolete,
 			reason)
 		_ = p.writeMessage(rejectMsg, wire.LatestEncoding)
@@ -2102,6 +2324,10 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Signal support for addrv2 messages, which must happen before the
+	// verack message as described by BIP0155.
+	p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+
 	// Send our verack message now that the IO processing machinery has started.
 	p.QueueMessage(wire.NewMsgVerAck(), nil)
 	return nil
@@ -2149,22 +2375,43 @@ func (p *Peer) WaitForDisconnect() {
 	<-p.quit
 }
 
+// MaxVersion returns the maximum protocol version peers of the network with the
+// passed parameters use and advertise, which is the ProtocolVersion of the
+// parameters when it is set and MaxProtocolVersion otherwise.
+func MaxVersion(params *chaincfg.Params) uint32 {
+	if params.ProtocolVersion != 0 {
+		return params.ProtocolVersion
+	}
+	return MaxProtocolVersion
+}
+
+// MinAcceptableVersion returns the lowest protocol version of remote peers
+// accepted on the network with the passed parameters, which is the
+// MinProtocolVersion of the parameters when it is set and
+// MinAcceptableProtocolVersion otherwise.
+func MinAcceptableVersion(params *chaincfg.Params) uint32 {
+	if params.MinProtocolVersion != 0 {
+		return params.MinProtocolVersion
+	}
+	return MinAcceptableProtocolVersion
+}
+
 // newPeerBase returns a new base bitcoin peer based on the inbound flag.  This
 // is used by the NewInboundPeer and NewOutboundPeer functions to perform base
 // setup needed by both types of peers.
 func newPeerBase(origCfg *Config, inbound bool) *Peer {
-	// Default to the max supported protocol version if not specified by the
-	// caller.
-	cfg := *origCfg // Copy to avoid mutating caller.
-	if cfg.ProtocolVersion == 0 {
-		cfg.ProtocolVersion = MaxProtocolVersion
-	}
-
 	// Set the chain parameters to testnet if the caller did not specify any.
+	cfg := *origCfg // Copy to avoid mutating caller.
 	if cfg.ChainParams == nil {
 		cfg.ChainParams = &chaincfg.TestNet3Params
 	}
 
+	// Default to the max supported protocol version of the network if not
+	// specified by the caller.
+	if cfg.ProtocolVersion == 0 {
+		cfg.ProtocolVersion = MaxVersion(cfg.ChainParams)
+	}
+
 	// Set the trickle interval if a non-positive value is specified.
 	if cfg.TrickleInterval <= 0 {
 		cfg.TrickleInterval = DefaultTrickleInterval
@@ -2186,6 +2433,14 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		cfg:             cfg, // Copy so caller can't mutate.
 		services:        cfg.Services,
 		protocolVersion: cfg.ProtocolVersion,
+		bytesSentPerMsg: make(map[string]uint64),
+		bytesRecvPerMsg: make(map[string]uint64),
+	}
+	if cfg.MaxUploadRate > 0 {
+		p.uploadLimiter = NewRateLimiter(cfg.MaxUploadRate)
+	}
+	if cfg.MaxDownloadRate > 0 {
+		p.downloadLimiter = NewRateLimiter(cfg.MaxDownloadRate)
 	}
 	return &p
 }
@@ -193,6 +193,12 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		return
 	}
 
+	// Peers signal support for addrv2 messages during the handshake.
+	if !p.WantsAddrV2() {
+		t.Errorf("testPeer: peer does not want addrv2 messages")
+		return
+	}
+
 	stats := p.StatsSnapshot()
 
 	if p.ID() != stats.ID {
@@ -260,8 +266,8 @@ func TestPeerConnection(t *testing.T) {
 		wantLastPingNonce:   uint64(0),
 		wantLastPingMicros:  int64(0),
 		wantTimeOffset:      int64(0),
-		wantBytesSent:       167, // 143 version + 24 verack
-		wantBytesReceived:   167,
+		wantBytesSent:       191, // 143 version + 24 sendaddrv2 + 24 verack
+		wantBytesReceived:   191,
 		wantWitnessEnabled:  false,
 	}
 	wantStats2 := peerStats{
@@ -275,8 +281,8 @@ func TestPeerConnection(t *testing.T) {
 		wantLastPingNonce:   uint64(0),
 		wantLastPingMicros:  int64(0),
 		wantTimeOffset:      int64(0),
-		wantBytesSent:       167, // 143 version + 24 verack
-		wantBytesReceived:   167,
+		wantBytesSent:       191, // 143 version + 24 sendaddrv2 + 24 verack
+		wantBytesReceived:   191,
 		wantWitnessEnabled:  true,
 	}
 
@@ -432,6 +438,24 @@ func TestPeerListeners(t *testing.T) {
 			OnMerkleBlock: func(p *peer.Peer, msg *wire.MsgMerkleBlock) {
 				ok <- msg
 			},
+			OnMNBroadcast: func(p *peer.Peer, msg *wire.MsgMNBroadcast) {
+				ok <- msg
+			},
+			OnMNPing: func(p *peer.Peer, msg *wire.MsgMNPing) {
+				ok <- msg
+			},
+			OnTxLockVote: func(p *peer.Peer, msg *wire.MsgTxLockVote) {
+				ok <- msg
+			},
+			OnSpork: func(p *peer.Peer, msg *wire.MsgSpork) {
+				ok <- msg
+			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				ok <- msg
+			},
+			OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
+				ok <- msg
+			},
 			OnVersion: func(p *peer.Peer, msg *wire.MsgVersion) *wire.MsgReject {
 				ok <- msg
 				return nil
@@ -594,6 +618,32 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnMNBroadcast",
+			wire.NewMsgMNBroadcast(&wire.OutPoint{}, &wire.NetAddress{},
+				[]byte{0x02}, []byte{0x03}),
+		},
+		{
+			"OnMNPing",
+			wire.NewMsgMNPing(&wire.OutPoint{}, &chainhash.Hash{}, 0, nil),
+		},
+		{
+			"OnTxLockVote",
+			wire.NewMsgTxLockVote(&chainhash.Hash{}, &wire.OutPoint{},
+				&wire.OutPoint{}, nil),
+		},
+		{
+			"OnSpork",
+			wire.NewMsgSpork(10001, 1, 0, nil),
+		},
+		{
+			"OnAddrV2",
+			wire.NewMsgAddrV2(),
+		},
+		{
+			"OnSendAddrV2",
+			wire.NewMsgSendAddrV2(),
+		},
 	}
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
@@ -858,6 +908,82 @@ func TestUnsupportedVersionPeer(t *testing.T) {
 	}
 }
 
+// TestNetworkProtocolVersions ensures peers use the protocol versions of the
+// network they are configured for and disconnect remote peers older than its
+// minimum version.
+func TestNetworkProtocolVersions(t *testing.T) {
+	params := chaincfg.SimNetParams
+	params.ProtocolVersion = wire.FeeFilterVersion
+	params.MinProtocolVersion = wire.FeeFilterVersion
+	peerCfg := &peer.Config{
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &params,
+		TrickleInterval:  time.Second * 10,
+	}
+
+	localNA := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.1"),
+		uint16(18555), wire.SFNodeNetwork)
+	remoteNA := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.2"),
+		uint16(18555), wire.SFNodeNetwork)
+	localConn, remoteConn := pipe(
+		&conn{laddr: "10.0.0.1:18555", raddr: "10.0.0.2:18555"},
+		&conn{laddr: "10.0.0.2:18555", raddr: "10.0.0.1:18555"},
+	)
+
+	p, err := peer.NewOutboundPeer(peerCfg, "10.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err - %v\n", err)
+	}
+	if p.ProtocolVersion() != wire.FeeFilterVersion {
+		t.Fatalf("ProtocolVersion: got %d, want %d",
+			p.ProtocolVersion(), wire.FeeFilterVersion)
+	}
+	p.AssociateConnection(localConn)
+
+	// The version message must be sent with the magic of the network.
+	msg, _, err := params.ReadMessage(remoteConn)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected err - %v\n", err)
+	}
+	version, ok := msg.(*wire.MsgVersion)
+	if !ok {
+		t.Fatalf("Expected version message, got [%s]", msg.Command())
+	}
+	if uint32(version.ProtocolVersion) != wire.FeeFilterVersion {
+		t.Fatalf("Advertised protocol version %d, want %d",
+			version.ProtocolVersion, wire.FeeFilterVersion)
+	}
+
+	// A remote peer below the minimum version of the network must be
+	// disconnected even though the peer package accepts its version.
+	go func() {
+		for {
+			if _, _, err := params.ReadMessage(remoteConn); err != nil {
+				return
+			}
+		}
+	}()
+	oldVersionMsg := wire.NewMsgVersion(remoteNA, localNA, 0, 0)
+	oldVersionMsg.ProtocolVersion = int32(wire.SendHeadersVersion)
+	err = wire.WriteMessage(remoteConn.Writer, oldVersionMsg,
+		wire.SendHeadersVersion, params.Net)
+	if err != nil {
+		t.Fatalf("wire.WriteMessage: unexpected err - %v\n", err)
+	}
+
+	disconnected := make(chan struct{})
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("Peer did not disconnect the outdated peer")
+	}
+}
+
 // TestDuplicateVersionMsg ensures that receiving a version message after one
 // has already been received results in the peer being disconnected.
 func TestDuplicateVersionMsg(t *testing.T) {
@@ -921,3 +1047,144 @@ func init() {
 	// Allow self connection when running the tests.
 	peer.TstAllowSelfConns()
 }
+
+// TestAnnounceBlockHeader ensures blocks are only announced with headers to
+// peers which prefer headers and are known to have the parent block.
+func TestAnnounceBlockHeader(t *testing.T) {
+	p, err := peer.NewOutboundPeer(&peer.Config{}, "10.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected error: %v", err)
+	}
+	parent := wire.BlockHeader{Nonce: 1}
+	parentHash := parent.BlockHash()
+	header := wire.BlockHeader{PrevBlock: parentHash, Nonce: 2}
+	headerHash := header.BlockHash()
+
+	if p.AnnounceBlockHeader(&header) {
+		t.Fatal("AnnounceBlockHeader: announced block to peer which " +
+			"does not prefer headers")
+	}
+	p.TstSetSendHeadersPreferred(true)
+	if p.AnnounceBlockHeader(&header) {
+		t.Fatal("AnnounceBlockHeader: announced block to peer which " +
+			"does not know the parent")
+	}
+
+	p.UpdateLastAnnouncedBlock(&parentHash)
+	if !p.AnnounceBlockHeader(&header) {
+		t.Fatal("AnnounceBlockHeader: did not announce block to peer " +
+			"which announced the parent")
+	}
+
+	// The announced block becomes known to the peer, so its children can
+	// be announced with headers as well.
+	child := wire.BlockHeader{PrevBlock: headerHash, Nonce: 3}
+	p.UpdateLastAnnouncedBlock(&chainhash.Hash{})
+	if !p.AnnounceBlockHeader(&child) {
+		t.Fatal("AnnounceBlockHeader: did not announce block to peer " +
+			"which knows the parent")
+	}
+}
+
+// TestHandshakeExtensions ensures handshake extensions are performed with
+// peers advertising the required services and that failures of required
+// extensions disconnect the peer.
+func TestHandshakeExtensions(t *testing.T) {
+	// connectPeers connects an inbound peer using the passed extensions to
+	// an outbound peer advertising bloom filter support.
+	connectPeers := func(exts []*peer.HandshakeExtension, complete chan<- struct{}) *peer.Peer {
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:8333"},
+			&conn{raddr: "10.0.0.2:8333"},
+		)
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners: peer.MessageListeners{
+				OnHandshakeComplete: func(p *peer.Peer) {
+					complete <- struct{}{}
+				},
+			},
+			ChainParams:         &chaincfg.MainNetParams,
+			TrickleInterval:     time.Second * 10,
+			HandshakeExtensions: exts,
+		})
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			ChainParams:     &chaincfg.MainNetParams,
+			Services:        wire.SFNodeBloom,
+			TrickleInterval: time.Second * 10,
+		}, "10.0.0.1:8333")
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		return inPeer
+	}
+
+	// The challenge extension pings the peer and completes once the
+	// matching pong is received, the witness extension requires services
+	// the peer does not advertise, and the optional extension never
+	// completes.
+	const nonce = 7
+	exts := []*peer.HandshakeExtension{{
+		Name:     "challenge",
+		Services: wire.SFNodeBloom,
+		Start: func(p *peer.Peer) error {
+			p.QueueMessage(wire.NewMsgPing(nonce), nil)
+			return nil
+		},
+		HandleMessage: func(p *peer.Peer, msg wire.Message) (bool, error) {
+			pong, ok := msg.(*wire.MsgPong)
+			return ok && pong.Nonce == nonce, nil
+		},
+	}, {
+		Name:     "witness",
+		Services: wire.SFNodeWitness,
+		Timeout:  time.Millisecond,
+		HandleMessage: func(p *peer.Peer, msg wire.Message) (bool, error) {
+			return false, nil
+		},
+	}, {
+		Name:     "optional",
+		Timeout:  time.Millisecond * 10,
+		Optional: true,
+		HandleMessage: func(p *peer.Peer, msg wire.Message) (bool, error) {
+			return false, nil
+		},
+	}}
+	complete := make(chan struct{}, 1)
+	inPeer := connectPeers(exts, complete)
+	select {
+	case <-complete:
+	case <-time.After(time.Second):
+		t.Fatal("handshake extensions: timeout waiting for completion")
+	}
+	if !inPeer.HandshakeComplete() || !inPeer.Connected() {
+		t.Fatalf("handshake extensions: got complete %v, connected %v",
+			inPeer.HandshakeComplete(), inPeer.Connected())
+	}
+	inPeer.Disconnect()
+
+	// A failing required extension disconnects the peer.
+	exts = []*peer.HandshakeExtension{{
+		Name: "failing",
+		Start: func(p *peer.Peer) error {
+			return errors.New("challenge failed")
+		},
+	}}
+	inPeer = connectPeers(exts, complete)
+	disconnected := make(chan struct{})
+	go func() {
+		inPeer.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("handshake extensions: peer not disconnected after " +
+			"failed extension")
+	}
+	if inPeer.HandshakeComplete() {
+		t.Fatal("handshake extensions: handshake completed after " +
+			"failed extension")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate at which bytes are transferred.  A single limiter
+// may be shared by multiple peers to limit their aggregate rate.
+//
+// Bytes which exceed the allowance are borrowed from the future, so a transfer
+// larger than the burst size is never blocked indefinitely.  Instead, the
+// transfers which follow it wait until the debt is paid off.
+type RateLimiter struct {
+	mtx       sync.Mutex
+	rate      float64 // bytes per second
+	burst     float64
+	allowance float64
+	last      time.Time
+}
+
+// NewRateLimiter returns a rate limiter which allows the passed number of bytes
+// per second on average, as well as bursts of up to a second worth of bytes.  A
+// rate of zero disables the limiter.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{
+		rate:      float64(bytesPerSecond),
+		burst:     float64(bytesPerSecond),
+		allowance: float64(bytesPerSecond),
+		last:      time.Now(),
+	}
+}
+
+// Rate returns the number of bytes per second allowed by the limiter, which is
+// zero when it is disabled.
+func (r *RateLimiter) Rate() int64 {
+	return int64(r.rate)
+}
+
+// reserve takes the passed number of bytes from the allowance and returns how
+// long the caller must wait before the allowance is no longer in debt.
+func (r *RateLimiter) reserve(n int, now time.Time) time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.allowance += now.Sub(r.last).Seconds() * r.rate
+	if r.allowance > r.burst {
+		r.allowance = r.burst
+	}
+	r.last = now
+	r.allowance -= float64(n)
+	if r.allowance >= 0 {
+		return 0
+	}
+	return time.Duration(-r.allowance / r.rate * float64(time.Second))
+}
+
+// Wait blocks until the passed number of bytes may be transferred without
+// exceeding the rate of the limiter, or until the quit channel is closed.  A
+// nil limiter never blocks.
+//
+// This function is safe for concurrent access.
+func (r *RateLimiter) Wait(n int, quit <-chan struct{}) {
+	if r == nil || r.rate <= 0 || n <= 0 {
+		return
+	}
+	delay := r.reserve(n, time.Now())
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	select {
+	case <-timer.C:
+	case <-quit:
+		timer.Stop()
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRateLimiter ensures the rate limiter allows bursts of up to its rate and
+// delays the transfers which follow until the debt is paid off.
+func TestRateLimiter(t *testing.T) {
+	r := NewRateLimiter(1000)
+	now := r.last
+
+	// A burst of up to a second worth of bytes is allowed.
+	if delay := r.reserve(1000, now); delay != 0 {
+		t.Fatalf("reserve: got delay %v, want 0", delay)
+	}
+
+	// Exceeding the allowance borrows from the future.
+	if delay := r.reserve(500, now); delay != 500*time.Millisecond {
+		t.Fatalf("reserve: got delay %v, want %v", delay,
+			500*time.Millisecond)
+	}
+
+	// The allowance is replenished over time.
+	now = now.Add(time.Second)
+	if delay := r.reserve(500, now); delay != 0 {
+		t.Fatalf("reserve: got delay %v, want 0", delay)
+	}
+
+	// The allowance never exceeds the burst size.
+	now = now.Add(time.Hour)
+	if delay := r.reserve(2000, now); delay != time.Second {
+		t.Fatalf("reserve: got delay %v, want %v", delay, time.Second)
+	}
+
+	// Waiting is cut short once the quit channel is closed.
+	quit := make(chan struct{})
+	close(quit)
+	start := time.Now()
+	r.Wait(10000, quit)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Wait: returned after %v despite quit", elapsed)
+	}
+
+	// Nil and disabled limiters never block.
+	var nilLimiter *RateLimiter
+	nilLimiter.Wait(1<<20, nil)
+	NewRateLimiter(0).Wait(1<<20, nil)
+}
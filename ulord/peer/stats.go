@@ -0,0 +1,61 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import "sort"
+
+// maxPingSamples is the maximum number of recent ping times which are kept to
+// calculate the ping time percentiles.
+const maxPingSamples = 100
+
+// addMsgBytes adds the passed number of bytes to the passed per message
+// statistics for the passed command.
+//
+// This function is safe for concurrent access.
+func (p *Peer) addMsgBytes(perMsg map[string]uint64, command string, n int) {
+	p.statsMtx.Lock()
+	perMsg[command] += uint64(n)
+	p.statsMtx.Unlock()
+}
+
+// addPingSample records the passed ping time, replacing the oldest one once
+// the maximum number of samples is reached.
+//
+// This function MUST be called with the stats lock held (for writes).
+func (p *Peer) addPingSample(micros int64) {
+	if len(p.pingSamples) < maxPingSamples {
+		p.pingSamples = append(p.pingSamples, micros)
+		return
+	}
+	p.pingSamples[p.nextPingSample] = micros
+	p.nextPingSample = (p.nextPingSample + 1) % maxPingSamples
+}
+
+// pingPercentile returns the passed percentile of the passed ping times using
+// the nearest-rank method, or zero when there are none.
+func pingPercentile(samples []int64, percentile int) int64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]int64, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	rank := (percentile*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// copyMsgBytes returns a copy of the passed per message statistics.
+func copyMsgBytes(perMsg map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(perMsg))
+	for command, n := range perMsg {
+		c[command] = n
+	}
+	return c
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import "testing"
+
+// TestPingPercentile ensures ping time percentiles are calculated with the
+// nearest-rank method.
+func TestPingPercentile(t *testing.T) {
+	samples := make([]int64, 0, 100)
+	for i := int64(100); i > 0; i-- {
+		samples = append(samples, i*10)
+	}
+
+	tests := []struct {
+		samples    []int64
+		percentile int
+		want       int64
+	}{
+		{nil, 50, 0},
+		{[]int64{42}, 50, 42},
+		{[]int64{42}, 99, 42},
+		{[]int64{30, 10, 20}, 50, 20},
+		{[]int64{30, 10, 20}, 99, 30},
+		{samples, 50, 500},
+		{samples, 90, 900},
+		{samples, 99, 990},
+	}
+	for i, test := range tests {
+		got := pingPercentile(test.samples, test.percentile)
+		if got != test.want {
+			t.Errorf("pingPercentile #%d: got %d, want %d", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestAddPingSample ensures only the most recent ping times are kept.
+func TestAddPingSample(t *testing.T) {
+	p := newPeerBase(&Config{}, false)
+	for i := int64(0); i < maxPingSamples+10; i++ {
+		p.addPingSample(i)
+	}
+	if len(p.pingSamples) != maxPingSamples {
+		t.Fatalf("got %d samples, want %d", len(p.pingSamples),
+			maxPingSamples)
+	}
+	for _, sample := range p.pingSamples {
+		if sample < 10 {
+			t.Fatalf("sample %d was not replaced", sample)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
 
 	"github.com/ulordsuite/ulord/blockchain"
@@ -49,7 +50,15 @@ func (p *rpcPeer) IsTxRelayDisabled() bool {
 // This function is safe for concurrent access and is part of the rpcserverPeer
 // interface implementation.
 func (p *rpcPeer) BanScore() uint32 {
-	return (*serverPeer)(p).banScore.Int()
+	return (*serverPeer)(p).misbehavior.Score()
+}
+
+// Misbehavior returns the most recent offenses of the peer, oldest first.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) Misbehavior() []netsync.MisbehaviorRecord {
+	return (*serverPeer)(p).misbehavior.Records()
 }
 
 // FeeFilter returns the requested current minimum fee rate for which
@@ -164,6 +173,31 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// NetTotalsPerMsg returns the sums of all bytes received and sent across the
+// network for all peers by message command.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) NetTotalsPerMsg() (map[string]uint64, map[string]uint64) {
+	return cm.server.NetTotalsPerMsg()
+}
+
+// BandwidthLimits returns the maximum number of bytes per second sent to and
+// received from all peers, which are zero when unlimited.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BandwidthLimits() (int64, int64) {
+	var upload, download int64
+	if cm.server.uploadLimiter != nil {
+		upload = cm.server.uploadLimiter.Rate()
+	}
+	if cm.server.downloadLimiter != nil {
+		download = cm.server.downloadLimiter.Rate()
+	}
+	return upload, download
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -207,14 +241,23 @@ func (cm *rpcConnManager) BroadcastMessage(msg wire.Message) {
 	cm.server.BroadcastMessage(msg)
 }
 
-// AddRebroadcastInventory adds the provided inventory to the list of
-// inventories to be rebroadcast at random intervals until they show up in a
-// block.
+// AddUnbroadcastTransaction adds the provided transaction to the transactions
+// which are rebroadcast with an increasing backoff until a peer announces or
+// requests them.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) AddUnbroadcastTransaction(txD *mempool.TxDesc) {
+	cm.server.AddUnbroadcastTransaction(txD)
+}
+
+// UnbroadcastTransactions returns the transactions submitted through the RPC
+// server which have not been announced or requested by any peer yet.
 //
 // This function is safe for concurrent access and is part of the
 // rpcserverConnManager interface implementation.
-func (cm *rpcConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interface{}) {
-	cm.server.AddRebroadcastInventory(iv, data)
+func (cm *rpcConnManager) UnbroadcastTransactions() []mempool.UnbroadcastTx {
+	return cm.server.UnbroadcastTransactions()
 }
 
 // RelayTransactions generates and relays inventory vectors for all of the
@@ -270,10 +313,29 @@ func (b *rpcSyncMgr) SyncPeerID() int32 {
 
 // LocateBlocks returns the hashes of the blocks after the first known block in
 // the provided locators until the provided stop hash or the current tip is
-// reached, up to a max of wire.MaxBlockHeadersPerMsg hashes.
+// reached, up to a max of wire.DefaultBlockHeadersPerMsg hashes.
 //
 // This function is safe for concurrent access and is part of the
 // rpcserverSyncManager interface implementation.
 func (b *rpcSyncMgr) LocateHeaders(locators []*chainhash.Hash, hashStop *chainhash.Hash) []wire.BlockHeader {
 	return b.server.chain.LocateHeaders(locators, hashStop)
 }
+
+// Reindex starts a job which processes the blocks of the main chain starting
+// at the provided height again through the indexes with the provided names, or
+// all enabled indexes when none are provided.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) Reindex(startHeight int32, indexNames []string) (*netsync.ReindexJob, error) {
+	return b.syncMgr.Reindex(startHeight, indexNames, nil)
+}
+
+// SetTrustedPeer adds or removes the provided network of trusted peers, whose
+// transactions bypass the relay policy.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) SetTrustedPeer(ipNet *net.IPNet, trusted bool) error {
+	return b.syncMgr.SetTrustedPeer(ipNet, trusted)
+}
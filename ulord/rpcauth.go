@@ -0,0 +1,312 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ulordsuite/ulord/ulordjson"
+)
+
+// These constants define the names of the built-in RPC permission profiles.
+const (
+	// rpcProfileAdmin allows all methods.  It is the profile of the user
+	// set with --rpcuser.
+	rpcProfileAdmin = "admin"
+
+	// rpcProfileLimited allows the methods in rpcLimited.  It is the
+	// profile of the user set with --rpclimituser.
+	rpcProfileLimited = "limited"
+
+	// rpcProfileReadOnly allows the methods in rpcReadOnly.
+	rpcProfileReadOnly = "readonly"
+)
+
+// Commands that neither change the state of the server nor broadcast data to
+// the network, which are available to users with the readonly profile.
+var rpcReadOnly = map[string]struct{}{
+	// Websockets commands
+	"notifyblocks":              {},
+	"notifynewtransactions":     {},
+	"session":                   {},
+	"stopnotifyblocks":          {},
+	"stopnotifynewtransactions": {},
+
+	// Websockets AND HTTP/S commands
+	"help": {},
+
+	// HTTP/S-only commands
+	"createrawtransaction":  {},
+	"decoderawtransaction":  {},
+	"decodescript":          {},
+	"deriveaddresses":       {},
+	"estimatefee":           {},
+	"estimatesmartfee":      {},
+	"getaddednodeinfo":      {},
+	"getaddressbalance":     {},
+	"getaddressdeltas":      {},
+	"getaddressutxos":       {},
+	"getbestblock":          {},
+	"getbestblockhash":      {},
+	"getblock":              {},
+	"getblockchaininfo":     {},
+	"getblockcount":         {},
+	"getblockhash":          {},
+	"getblockhashes":        {},
+	"getblockheader":        {},
+	"getblockstats":         {},
+	"getcfilter":            {},
+	"getcfilterheader":      {},
+	"getchaintips":          {},
+	"getconnectioncount":    {},
+	"getcurrentnet":         {},
+	"getdescriptorinfo":     {},
+	"getdifficulty":         {},
+	"getgenerate":           {},
+	"gethashespersec":       {},
+	"getheaders":            {},
+	"getinfo":               {},
+	"getmempoolinfo":        {},
+	"getmininginfo":         {},
+	"getnettotals":          {},
+	"getnetworkhashps":      {},
+	"getpeerinfo":           {},
+	"getrawmempool":         {},
+	"getrawtransaction":     {},
+	"getspentinfo":          {},
+	"gettxout":              {},
+	"searchrawtransactions": {},
+	"testmempoolaccept":     {},
+	"uptime":                {},
+	"validateaddress":       {},
+	"verifymessage":         {},
+	"version":               {},
+}
+
+// rpcProfile describes the RPC methods a user is allowed to call.
+type rpcProfile struct {
+	name string
+
+	// all indicates every method which is not denied is allowed.
+	// Otherwise only the methods in allow are.
+	all   bool
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// allows returns whether the profile permits calling the passed method.
+func (p *rpcProfile) allows(method string) bool {
+	if _, ok := p.deny[method]; ok {
+		return false
+	}
+	if p.all {
+		return true
+	}
+	_, ok := p.allow[method]
+	return ok
+}
+
+// rpcBuiltinProfiles maps the names of the built-in profiles to the profiles.
+var rpcBuiltinProfiles = map[string]*rpcProfile{
+	rpcProfileAdmin:    {name: rpcProfileAdmin, all: true},
+	rpcProfileLimited:  {name: rpcProfileLimited, allow: rpcLimited},
+	rpcProfileReadOnly: {name: rpcProfileReadOnly, allow: rpcReadOnly},
+}
+
+// rpcUser is a user allowed to connect to the RPC server.
+type rpcUser struct {
+	name    string
+	profile *rpcProfile
+
+	// authsha is the hash of the HTTP basic authorization header of the
+	// user.
+	authsha [sha256.Size]byte
+}
+
+// newRPCUser returns an RPC user with the passed credentials and profile.
+func newRPCUser(name, pass string, profile *rpcProfile) *rpcUser {
+	login := name + ":" + pass
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	return &rpcUser{
+		name:    name,
+		profile: profile,
+		authsha: sha256.Sum256([]byte(auth)),
+	}
+}
+
+// isKnownRPCMethod returns whether the passed method is handled by the RPC
+// server.
+func isKnownRPCMethod(method string) bool {
+	if _, ok := rpcHandlers[method]; ok {
+		return true
+	}
+	_, ok := wsHandlers[method]
+	return ok
+}
+
+// parseRPCProfiles parses the profiles defined with the --rpcprofile option in
+// the '<name>:<base profile>[:<+method|-method>,...]' format.  Each profile
+// permits the methods of its base profile, which may be a built-in profile or
+// a profile defined before it, plus the methods prefixed with + and minus the
+// ones prefixed with -.  The returned map includes the built-in profiles.
+func parseRPCProfiles(defs []string) (map[string]*rpcProfile, error) {
+	profiles := make(map[string]*rpcProfile, len(rpcBuiltinProfiles)+
+		len(defs))
+	for name, profile := range rpcBuiltinProfiles {
+		profiles[name] = profile
+	}
+
+	for _, def := range defs {
+		parts := strings.Split(def, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("unable to parse RPC profile %q "+
+				"-- use the syntax <name>:<base profile>"+
+				"[:<+method|-method>,...]", def)
+		}
+		name := parts[0]
+		if _, ok := profiles[name]; ok {
+			return nil, fmt.Errorf("RPC profile %q is already "+
+				"defined", name)
+		}
+		base, ok := profiles[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown base profile %q of RPC "+
+				"profile %q", parts[1], name)
+		}
+
+		profile := &rpcProfile{
+			name:  name,
+			all:   base.all,
+			allow: make(map[string]struct{}, len(base.allow)),
+			deny:  make(map[string]struct{}, len(base.deny)),
+		}
+		for method := range base.allow {
+			profile.allow[method] = struct{}{}
+		}
+		for method := range base.deny {
+			profile.deny[method] = struct{}{}
+		}
+
+		if len(parts) == 3 {
+			for _, rule := range strings.Split(parts[2], ",") {
+				if len(rule) < 2 || (rule[0] != '+' && rule[0] != '-') {
+					return nil, fmt.Errorf("invalid rule %q "+
+						"of RPC profile %q -- use +method "+
+						"or -method", rule, name)
+				}
+				method := rule[1:]
+				if !isKnownRPCMethod(method) {
+					return nil, fmt.Errorf("unknown method "+
+						"%q in RPC profile %q", method,
+						name)
+				}
+				if rule[0] == '+' {
+					delete(profile.deny, method)
+					profile.allow[method] = struct{}{}
+				} else {
+					delete(profile.allow, method)
+					profile.deny[method] = struct{}{}
+				}
+			}
+		}
+
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// parseRPCUsers parses the users added with the --rpcauthuser option in the
+// '<user>:<profile>:<password>' format.  The password may contain colons.
+func parseRPCUsers(defs []string, profiles map[string]*rpcProfile) ([]*rpcUser, error) {
+	users := make([]*rpcUser, 0, len(defs))
+	for _, def := range defs {
+		parts := strings.SplitN(def, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("unable to parse RPC user %q -- "+
+				"use the syntax <user>:<profile>:<password>",
+				parts[0])
+		}
+		profile, ok := profiles[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q of RPC user "+
+				"%q", parts[1], parts[0])
+		}
+		users = append(users, newRPCUser(parts[0], parts[2], profile))
+	}
+	return users, nil
+}
+
+// authenticate returns the user with the passed HTTP basic authorization
+// header or nil when there is no such user.
+//
+// This check is time-constant.
+func (s *rpcServer) authenticate(auth string) *rpcUser {
+	authsha := sha256.Sum256([]byte(auth))
+
+	var match *rpcUser
+	for _, user := range s.users {
+		cmp := subtle.ConstantTimeCompare(authsha[:], user.authsha[:])
+		if cmp == 1 && match == nil {
+			match = user
+		}
+	}
+	return match
+}
+
+// rpcNotAuthorizedError returns the error returned to users whose profile does
+// not allow calling a method.
+func rpcNotAuthorizedError(profile *rpcProfile) *ulordjson.RPCError {
+	return &ulordjson.RPCError{
+		Code: ulordjson.ErrRPCInvalidParams.Code,
+		Message: fmt.Sprintf("%s user not authorized for this method",
+			profile.name),
+	}
+}
+
+// sortedMethods returns the methods of the passed set in sorted order.
+func sortedMethods(methods map[string]struct{}) []string {
+	sorted := make([]string, 0, len(methods))
+	for method := range methods {
+		sorted = append(sorted, method)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// handleRPCUserInfo implements the rpcuserinfo command.
+func handleRPCUserInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.RPCUserInfoCmd)
+
+	results := make([]ulordjson.RPCUserInfoResult, 0, len(s.users))
+	for _, user := range s.users {
+		if c.User != nil && *c.User != user.name {
+			continue
+		}
+		result := ulordjson.RPCUserInfoResult{
+			User:       user.name,
+			Profile:    user.profile.name,
+			AllMethods: user.profile.all,
+			Denied:     sortedMethods(user.profile.deny),
+		}
+		if !user.profile.all {
+			result.Allowed = sortedMethods(user.profile.allow)
+		}
+		results = append(results, result)
+	}
+
+	if c.User != nil && len(results) == 0 {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: "Unknown RPC user: " + *c.User,
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/ulordjson"
+)
+
+// TestParseRPCProfiles ensures RPC permission profiles are derived from their
+// base profiles with the methods allowed and denied by their rules.
+func TestParseRPCProfiles(t *testing.T) {
+	profiles, err := parseRPCProfiles([]string{
+		"monitor:readonly:-getpeerinfo,+getblocktemplate",
+		"operator:admin:-stop,-addnode",
+		"restricted:operator:-generate,+addnode",
+		"plain:limited",
+	})
+	if err != nil {
+		t.Fatalf("parseRPCProfiles: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		profile string
+		method  string
+		allowed bool
+	}{
+		{"monitor", "getblockcount", true},
+		{"monitor", "getpeerinfo", false},
+		{"monitor", "getblocktemplate", true},
+		{"monitor", "sendrawtransaction", false},
+		{"operator", "generate", true},
+		{"operator", "stop", false},
+		{"operator", "addnode", false},
+		{"restricted", "generate", false},
+		{"restricted", "addnode", true},
+		{"restricted", "stop", false},
+		{"plain", "sendrawtransaction", true},
+		{"plain", "generate", false},
+		{"readonly", "sendrawtransaction", false},
+		{"readonly", "getblock", true},
+		{"admin", "stop", true},
+	}
+	for _, test := range tests {
+		got := profiles[test.profile].allows(test.method)
+		if got != test.allowed {
+			t.Errorf("%s profile allows %s: got %v, want %v",
+				test.profile, test.method, got, test.allowed)
+		}
+	}
+
+	// Deriving profiles must not modify the built-in profiles.
+	if !rpcBuiltinProfiles[rpcProfileReadOnly].allows("getpeerinfo") ||
+		rpcBuiltinProfiles[rpcProfileReadOnly].allows("getblocktemplate") {
+
+		t.Fatal("readonly profile was modified")
+	}
+
+	invalid := [][]string{
+		{"monitor"},
+		{":readonly"},
+		{"monitor:unknown"},
+		{"monitor:readonly:getblock"},
+		{"monitor:readonly:+getfoo"},
+		{"monitor:readonly", "monitor:limited"},
+		{"admin:readonly"},
+		{"monitor:readonly:+getblock:-stop"},
+	}
+	for _, defs := range invalid {
+		if _, err := parseRPCProfiles(defs); err == nil {
+			t.Errorf("parseRPCProfiles: did not fail with %v", defs)
+		}
+	}
+}
+
+// TestParseRPCUsers ensures RPC users are parsed along with their profiles and
+// passwords which may contain colons.
+func TestParseRPCUsers(t *testing.T) {
+	users, err := parseRPCUsers([]string{
+		"monitor:readonly:secret",
+		"pool:limited:pass:with:colons",
+	}, rpcBuiltinProfiles)
+	if err != nil {
+		t.Fatalf("parseRPCUsers: unexpected error: %v", err)
+	}
+	want := []*rpcUser{
+		newRPCUser("monitor", "secret",
+			rpcBuiltinProfiles[rpcProfileReadOnly]),
+		newRPCUser("pool", "pass:with:colons",
+			rpcBuiltinProfiles[rpcProfileLimited]),
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Fatalf("parseRPCUsers: got %+v, want %+v", users, want)
+	}
+
+	invalid := []string{
+		"monitor",
+		"monitor:readonly",
+		"monitor:readonly:",
+		":readonly:secret",
+		"monitor:unknown:secret",
+	}
+	for _, def := range invalid {
+		_, err := parseRPCUsers([]string{def}, rpcBuiltinProfiles)
+		if err == nil {
+			t.Errorf("parseRPCUsers: did not fail with %q", def)
+		}
+	}
+}
+
+// TestRPCUserInfo ensures users are authenticated with their credentials and
+// listed along with their profiles by the rpcuserinfo command.
+func TestRPCUserInfo(t *testing.T) {
+	profiles, err := parseRPCProfiles([]string{"monitor:readonly:-getpeerinfo"})
+	if err != nil {
+		t.Fatalf("parseRPCProfiles: unexpected error: %v", err)
+	}
+	s := &rpcServer{users: []*rpcUser{
+		newRPCUser("admin", "secret", rpcBuiltinProfiles[rpcProfileAdmin]),
+		newRPCUser("monitor", "secret", profiles["monitor"]),
+	}}
+
+	basicAuth := func(login string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	}
+	if user := s.authenticate(basicAuth("monitor:secret")); user != s.users[1] {
+		t.Fatalf("authenticate: got user %v, want monitor", user)
+	}
+	if user := s.authenticate(basicAuth("monitor:wrong")); user != nil {
+		t.Fatalf("authenticate: got user %v for a wrong password", user)
+	}
+
+	result, err := handleRPCUserInfo(s, ulordjson.NewRPCUserInfoCmd(
+		ulordjson.String("monitor")), nil)
+	if err != nil {
+		t.Fatalf("handleRPCUserInfo: unexpected error: %v", err)
+	}
+	infos := result.([]ulordjson.RPCUserInfoResult)
+	if len(infos) != 1 || infos[0].User != "monitor" ||
+		infos[0].Profile != "monitor" || infos[0].AllMethods ||
+		len(infos[0].Allowed) != len(rpcReadOnly)-1 ||
+		!reflect.DeepEqual(infos[0].Denied, []string{"getpeerinfo"}) {
+
+		t.Fatalf("handleRPCUserInfo: unexpected result %+v", infos)
+	}
+
+	result, err = handleRPCUserInfo(s, ulordjson.NewRPCUserInfoCmd(nil), nil)
+	if err != nil {
+		t.Fatalf("handleRPCUserInfo: unexpected error: %v", err)
+	}
+	infos = result.([]ulordjson.RPCUserInfoResult)
+	if len(infos) != 2 || !infos[0].AllMethods || infos[0].Allowed != nil {
+		t.Fatalf("handleRPCUserInfo: unexpected result %+v", infos)
+	}
+
+	_, err = handleRPCUserInfo(s, ulordjson.NewRPCUserInfoCmd(
+		ulordjson.String("unknown")), nil)
+	if err == nil {
+		t.Fatal("handleRPCUserInfo: did not fail for an unknown user")
+	}
+}
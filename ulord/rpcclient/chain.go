@@ -13,6 +13,7 @@ import (
 	"github.com/ulordsuite/ulord/ulordjson"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
 )
 
 // FutureGetBestBlockHashResult is a future promise to deliver the result of a
@@ -72,7 +73,7 @@ func (r FutureGetBlockResult) Receive() (*wire.MsgBlock, error) {
 	}
 
 	// Decode the serialized block hex to raw bytes.
-	serializedBlock, err := hex.DecodeString(blockHex)
+	serializedBlock, err := ulordutil.DecodeInto(nil, blockHex)
 	if err != nil {
 		return nil, err
 	}
@@ -594,6 +595,45 @@ func (c *Client) EstimateFee(numBlocks int64) (float64, error) {
 	return c.EstimateFeeAsync(numBlocks).Receive()
 }
 
+// FutureEstimateSmartFeeResult is a future promise to deliver the result of a
+// EstimateSmartFeeAsync RPC invocation (or an applicable error).
+type FutureEstimateSmartFeeResult chan *response
+
+// Receive waits for the response promised by the future and returns the fee
+// estimate provided by the server.
+func (r FutureEstimateSmartFeeResult) Receive() (*ulordjson.EstimateSmartFeeResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an estimatesmartfee result object.
+	var estimate ulordjson.EstimateSmartFeeResult
+	err = json.Unmarshal(res, &estimate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &estimate, nil
+}
+
+// EstimateSmartFeeAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See EstimateSmartFee for the blocking version and more details.
+func (c *Client) EstimateSmartFeeAsync(confTarget int64, mode *ulordjson.EstimateSmartFeeMode) FutureEstimateSmartFeeResult {
+	cmd := ulordjson.NewEstimateSmartFeeCmd(confTarget, mode)
+	return c.sendCmd(cmd)
+}
+
+// EstimateSmartFee provides an estimated fee rate in bitcoins per kilobyte
+// for a transaction to be mined within confTarget blocks.  The mode may be nil
+// to use the default conservative mode.
+func (c *Client) EstimateSmartFee(confTarget int64, mode *ulordjson.EstimateSmartFeeMode) (*ulordjson.EstimateSmartFeeResult, error) {
+	return c.EstimateSmartFeeAsync(confTarget, mode).Receive()
+}
+
 // FutureVerifyChainResult is a future promise to deliver the result of a
 // VerifyChainAsync, VerifyChainLevelAsyncRPC, or VerifyChainBlocksAsync
 // invocation (or an applicable error).
@@ -732,6 +772,151 @@ func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
 
+// FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
+// GetTxOutSetInfoAsync RPC invocation (or an applicable error).
+type FutureGetTxOutSetInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of the unspent transaction output set.
+func (r FutureGetTxOutSetInfoResult) Receive() (*ulordjson.GetTxOutSetInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var txOutSetInfo ulordjson.GetTxOutSetInfoResult
+	if err := json.Unmarshal(res, &txOutSetInfo); err != nil {
+		return nil, err
+	}
+	return &txOutSetInfo, nil
+}
+
+// GetTxOutSetInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetTxOutSetInfo for the blocking version and more details.
+func (c *Client) GetTxOutSetInfoAsync() FutureGetTxOutSetInfoResult {
+	cmd := ulordjson.NewGetTxOutSetInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetTxOutSetInfo returns statistics about the unspent transaction output set
+// such as the number of unspent outputs and the total amount they hold.
+func (c *Client) GetTxOutSetInfo() (*ulordjson.GetTxOutSetInfoResult, error) {
+	return c.GetTxOutSetInfoAsync().Receive()
+}
+
+// FutureGetBlockStatsResult is a future promise to deliver the result of a
+// GetBlockStatsAsync RPC invocation (or an applicable error).
+type FutureGetBlockStatsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of the requested block.
+func (r FutureGetBlockStatsResult) Receive() (*ulordjson.GetBlockStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockStats ulordjson.GetBlockStatsResult
+	if err := json.Unmarshal(res, &blockStats); err != nil {
+		return nil, err
+	}
+	return &blockStats, nil
+}
+
+// GetBlockStatsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetBlockStats for the blocking version and more details.
+func (c *Client) GetBlockStatsAsync(hashOrHeight ulordjson.HashOrHeight, stats *[]string) FutureGetBlockStatsResult {
+	cmd := ulordjson.NewGetBlockStatsCmd(hashOrHeight, stats)
+	return c.sendCmd(cmd)
+}
+
+// GetBlockStats returns statistics about the fees and transactions of the block
+// with the passed hash or height.  Only the passed statistics are returned
+// when stats is not nil.
+func (c *Client) GetBlockStats(hashOrHeight ulordjson.HashOrHeight, stats *[]string) (*ulordjson.GetBlockStatsResult, error) {
+	return c.GetBlockStatsAsync(hashOrHeight, stats).Receive()
+}
+
+// FutureGetChainTxStatsResult is a future promise to deliver the result of a
+// GetChainTxStatsAsync RPC invocation (or an applicable error).
+type FutureGetChainTxStatsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// transaction statistics of the requested window of blocks.
+func (r FutureGetChainTxStatsResult) Receive() (*ulordjson.GetChainTxStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var chainTxStats ulordjson.GetChainTxStatsResult
+	if err := json.Unmarshal(res, &chainTxStats); err != nil {
+		return nil, err
+	}
+	return &chainTxStats, nil
+}
+
+// GetChainTxStatsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetChainTxStats for the blocking version and more details.
+func (c *Client) GetChainTxStatsAsync() FutureGetChainTxStatsResult {
+	cmd := ulordjson.NewGetChainTxStatsCmd(nil, nil)
+	return c.sendCmd(cmd)
+}
+
+// GetChainTxStats returns statistics about the total number and rate of
+// transactions in the chain over the default window ending at the best block.
+//
+// See GetChainTxStatsNBlocks and GetChainTxStatsNBlocksBlockHash to override
+// the window.
+func (c *Client) GetChainTxStats() (*ulordjson.GetChainTxStatsResult, error) {
+	return c.GetChainTxStatsAsync().Receive()
+}
+
+// GetChainTxStatsNBlocksAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetChainTxStatsNBlocks for the blocking version and more details.
+func (c *Client) GetChainTxStatsNBlocksAsync(nBlocks int32) FutureGetChainTxStatsResult {
+	cmd := ulordjson.NewGetChainTxStatsCmd(&nBlocks, nil)
+	return c.sendCmd(cmd)
+}
+
+// GetChainTxStatsNBlocks returns statistics about the total number and rate of
+// transactions in the chain over the passed number of blocks ending at the best
+// block.
+func (c *Client) GetChainTxStatsNBlocks(nBlocks int32) (*ulordjson.GetChainTxStatsResult, error) {
+	return c.GetChainTxStatsNBlocksAsync(nBlocks).Receive()
+}
+
+// GetChainTxStatsNBlocksBlockHashAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetChainTxStatsNBlocksBlockHash for the blocking version and more
+// details.
+func (c *Client) GetChainTxStatsNBlocksBlockHashAsync(nBlocks int32, blockHash *chainhash.Hash) FutureGetChainTxStatsResult {
+	hash := blockHash.String()
+	cmd := ulordjson.NewGetChainTxStatsCmd(&nBlocks, &hash)
+	return c.sendCmd(cmd)
+}
+
+// GetChainTxStatsNBlocksBlockHash returns statistics about the total number and
+// rate of transactions in the chain over the passed number of blocks ending at
+// the block with the passed hash.
+func (c *Client) GetChainTxStatsNBlocksBlockHash(nBlocks int32, blockHash *chainhash.Hash) (*ulordjson.GetChainTxStatsResult, error) {
+	return c.GetChainTxStatsNBlocksBlockHashAsync(nBlocks, blockHash).Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -819,6 +1004,39 @@ func (c *Client) InvalidateBlock(blockHash *chainhash.Hash) error {
 	return c.InvalidateBlockAsync(blockHash).Receive()
 }
 
+// FutureReconsiderBlockResult is a future promise to deliver the result of a
+// ReconsiderBlockAsync RPC invocation (or an applicable error).
+type FutureReconsiderBlockResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the block could not be reconsidered.
+func (r FutureReconsiderBlockResult) Receive() error {
+	_, err := receiveFuture(r)
+
+	return err
+}
+
+// ReconsiderBlockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ReconsiderBlock for the blocking version and more details.
+func (c *Client) ReconsiderBlockAsync(blockHash *chainhash.Hash) FutureReconsiderBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := ulordjson.NewReconsiderBlockCmd(hash)
+	return c.sendCmd(cmd)
+}
+
+// ReconsiderBlock removes the invalid marks set by InvalidateBlock from a
+// specific block.
+func (c *Client) ReconsiderBlock(blockHash *chainhash.Hash) error {
+	return c.ReconsiderBlockAsync(blockHash).Receive()
+}
+
 // FutureGetCFilterResult is a future promise to deliver the result of a
 // GetCFilterAsync RPC invocation (or an applicable error).
 type FutureGetCFilterResult chan *response
@@ -138,7 +138,9 @@ reconnect by default as previously described.
 
 The second category of errors typically indicates a programmer error and as such
 the type can vary, but usually will be best handled by simply showing/logging
-it.
+it.  One exception is the *WrongNetworkError returned by the methods sending
+funds, such as SendToAddress and SendMany, when an address is not for the
+network of the RPC server reported by the Params method.
 
 The third category of errors, that is errors returned by the server, can be
 detected by type asserting the error in a *ulordjson.RPCError.  For example, to
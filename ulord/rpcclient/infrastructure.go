@@ -23,6 +23,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/ulordjson"
 	"github.com/ulordsuite/go-socks/socks"
 	"github.com/ulordsuite/websocket"
@@ -115,7 +116,23 @@ type jsonRequest struct {
 // result of the invocation at some future time.  Invoking the Receive method on
 // the returned future will block until the result is available if it's not
 // already.
+//
+// A client operating a wallet of a server with multiple loaded wallets is
+// returned by the Wallet method.  It shares the connection of the client it was
+// returned from.
 type Client struct {
+	*clientConn
+
+	// wallet is the name of the wallet of the server requests are routed to.
+	// Requests are handled by the default wallet of the server when it is
+	// empty.
+	wallet string
+}
+
+// clientConn houses the connection to the RPC server and the state of the
+// requests sent over it, which are shared by a client and all clients returned
+// by its Wallet method.
+type clientConn struct {
 	id uint64 // atomic, so must stay 64-bit aligned
 
 	// config holds the connection configuration assoiated with this client.
@@ -139,6 +156,12 @@ type Client struct {
 	// reconnect to the RPC server.
 	retryCount int64
 
+	// params holds the parameters of the network of the RPC server once
+	// they were queried.  It is protected by paramsMtx, which is held for
+	// the duration of the query.
+	paramsMtx sync.Mutex
+	params    *chaincfg.Params
+
 	// Track command and their response channels by ID.
 	requestLock sync.Mutex
 	requestMap  map[uint64]*list.Element
@@ -148,6 +171,7 @@ type Client struct {
 	ntfnHandlers  *NotificationHandlers
 	ntfnStateLock sync.Mutex
 	ntfnState     *notificationState
+	reorgs        reorgTracker
 
 	// Networking infrastructure.
 	sendChan        chan []byte
@@ -809,9 +833,12 @@ func (c *Client) sendPost(jReq *jsonRequest) {
 	if !c.config.DisableTLS {
 		protocol = "https"
 	}
-	url := protocol + "://" + c.config.Host
+	rpcURL := protocol + "://" + c.config.Host
+	if c.wallet != "" {
+		rpcURL += "/wallet/" + url.PathEscape(c.wallet)
+	}
 	bodyReader := bytes.NewReader(jReq.marshalledJSON)
-	httpReq, err := http.NewRequest("POST", url, bodyReader)
+	httpReq, err := http.NewRequest("POST", rpcURL, bodyReader)
 	if err != nil {
 		jReq.responseChan <- &response{result: nil, err: err}
 		return
@@ -877,6 +904,10 @@ func (c *Client) sendCmd(cmd interface{}) chan *response {
 	if err != nil {
 		return newFutureError(err)
 	}
+	marshalledJSON, err = c.routeToWallet(id, marshalledJSON)
+	if err != nil {
+		return newFutureError(err)
+	}
 
 	// Generate the request and send it along with a channel to respond on.
 	responseChan := make(chan *response, 1)
@@ -892,6 +923,24 @@ func (c *Client) sendCmd(cmd interface{}) chan *response {
 	return responseChan
 }
 
+// routeToWallet returns the passed marshalled request with the wallet of the
+// client set when the client operates a named wallet over a websocket
+// connection.  The request is returned unmodified otherwise since requests sent
+// in HTTP POST mode are routed by the URL path instead.
+func (c *Client) routeToWallet(id uint64, marshalledJSON []byte) ([]byte, error) {
+	if c.wallet == "" || c.config.HTTPPostMode {
+		return marshalledJSON, nil
+	}
+
+	var request ulordjson.Request
+	if err := json.Unmarshal(marshalledJSON, &request); err != nil {
+		return nil, err
+	}
+	request.ID = id
+	request.Wallet = c.wallet
+	return json.Marshal(&request)
+}
+
 // sendCmdAndWait sends the passed command to the associated server, waits
 // for the reply, and returns the result from it.  It will return the error
 // field in the reply if there is one.
@@ -901,6 +950,25 @@ func (c *Client) sendCmdAndWait(cmd interface{}) (interface{}, error) {
 	return receiveFuture(c.sendCmd(cmd))
 }
 
+// Wallet returns a client which routes its requests to the wallet with the
+// passed name on a server with multiple loaded wallets.  The name is appended to
+// the URL path as /wallet/<name> in HTTP POST mode and is included in each
+// request otherwise.  Passing an empty name returns a client whose requests are
+// handled by the default wallet of the server.
+//
+// The returned client shares the connection, notification handlers, and request
+// IDs of c, so any number of wallets may be operated over one connection.
+// Shutting down or disconnecting either client affects both of them.
+func (c *Client) Wallet(name string) *Client {
+	return &Client{clientConn: c.clientConn, wallet: name}
+}
+
+// WalletName returns the name of the wallet the requests of the client are
+// routed to, which is empty for the default wallet of the server.
+func (c *Client) WalletName() string {
+	return c.wallet
+}
+
 // Disconnected returns whether or not the server is disconnected.  If a
 // websocket client was created but never connected, this also returns false.
 func (c *Client) Disconnected() bool {
@@ -1039,6 +1107,16 @@ func (c *Client) start() {
 		}()
 		go c.wsInHandler()
 		go c.wsOutHandler()
+
+		// Query the network of the server now that the connection is
+		// established.  A failed query is retried by the first call
+		// which needs the network.
+		go func() {
+			if _, err := c.detectParams(); err != nil {
+				log.Debugf("Unable to detect the network of RPC "+
+					"server %s: %v", c.config.Host, err)
+			}
+		}()
 	}
 }
 
@@ -1247,7 +1325,7 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		}
 	}
 
-	client := &Client{
+	client := &Client{clientConn: &clientConn{
 		config:          config,
 		wsConn:          wsConn,
 		httpClient:      httpClient,
@@ -1260,7 +1338,7 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		connEstablished: connEstablished,
 		disconnect:      make(chan struct{}),
 		shutdown:        make(chan struct{}),
-	}
+	}}
 
 	if start {
 		log.Infof("Established connection to RPC server %s",
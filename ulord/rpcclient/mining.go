@@ -5,7 +5,6 @@
 package rpcclient
 
 import (
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 
@@ -402,7 +401,7 @@ func (c *Client) SubmitBlockAsync(block *ulordutil.Block, options *ulordjson.Sub
 			return newFutureError(err)
 		}
 
-		blockHex = hex.EncodeToString(blockBytes)
+		blockHex = ulordutil.EncodeToStringBuf(blockBytes)
 	}
 
 	cmd := ulordjson.NewSubmitBlockCmd(blockHex, options)
@@ -121,6 +121,22 @@ type NotificationHandlers struct {
 	// OnBlockDisconnected: it receives the block's height and header.
 	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
 
+	// OnReorg is invoked when a reorganization of the longest (best) chain
+	// is reconstructed from the filtered block connected and disconnected
+	// notifications.  It will only be invoked if a preceding call to
+	// NotifyBlocks has been made to register for the notifications and the
+	// function is non-nil.
+	//
+	// The detached blocks are ordered from the old tip down to the block
+	// after the common ancestor, and the attached blocks from the block
+	// after the common ancestor up to the new tip.  The reorg is reported
+	// once the new chain reaches the height of the old one, so the attached
+	// blocks may include blocks connected after the reorg when the new
+	// chain was shorter.  OnFilteredBlockConnected and
+	// OnFilteredBlockDisconnected are still invoked for each of the blocks.
+	OnReorg func(oldTip, newTip, commonAncestor *BlockStamp,
+		detachedBlocks, attachedBlocks []*wire.BlockHeader)
+
 	// OnRecvTx is invoked when a transaction that receives funds to a
 	// registered address is received into the memory pool and also
 	// connected to the longest (best) chain.  It will only be invoked if a
@@ -206,6 +222,17 @@ type NotificationHandlers struct {
 	OnUnknownNotification func(method string, params []json.RawMessage)
 }
 
+// handleReorg delivers the passed reorg reconstructed from the block
+// notifications to the OnReorg handler.  Nothing is delivered when it is nil.
+func (c *Client) handleReorg(r *reorg) {
+	if r == nil {
+		return
+	}
+
+	c.ntfnHandlers.OnReorg(r.oldTip, r.newTip, r.commonAncestor,
+		r.detached, r.attached)
+}
+
 // handleNotification examines the passed notification type, performs
 // conversions to get the raw notification types into higher level types and
 // delivers the notification to the appropriate On<X> handler registered with
@@ -239,7 +266,8 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 	case ulordjson.FilteredBlockConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnFilteredBlockConnected == nil {
+		if c.ntfnHandlers.OnFilteredBlockConnected == nil &&
+			c.ntfnHandlers.OnReorg == nil {
 			return
 		}
 
@@ -251,8 +279,14 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnFilteredBlockConnected(blockHeight,
-			blockHeader, transactions)
+		if c.ntfnHandlers.OnFilteredBlockConnected != nil {
+			c.ntfnHandlers.OnFilteredBlockConnected(blockHeight,
+				blockHeader, transactions)
+		}
+		if c.ntfnHandlers.OnReorg != nil {
+			r := c.reorgs.blockConnected(blockHeight, blockHeader)
+			c.handleReorg(r)
+		}
 
 	// OnBlockDisconnected
 	case ulordjson.BlockDisconnectedNtfnMethod:
@@ -275,7 +309,8 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 	case ulordjson.FilteredBlockDisconnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnFilteredBlockDisconnected == nil {
+		if c.ntfnHandlers.OnFilteredBlockDisconnected == nil &&
+			c.ntfnHandlers.OnReorg == nil {
 			return
 		}
 
@@ -287,8 +322,14 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnFilteredBlockDisconnected(blockHeight,
-			blockHeader)
+		if c.ntfnHandlers.OnFilteredBlockDisconnected != nil {
+			c.ntfnHandlers.OnFilteredBlockDisconnected(blockHeight,
+				blockHeader)
+		}
+		if c.ntfnHandlers.OnReorg != nil {
+			r := c.reorgs.blockDisconnected(blockHeight, blockHeader)
+			c.handleReorg(r)
+		}
 
 	// OnRecvTx
 	case ulordjson.RecvTxNtfnMethod:
@@ -1342,7 +1383,8 @@ func (c *Client) LoadTxFilterAsync(reload bool, addresses []ulordutil.Address,
 		}
 	}
 
-	cmd := ulordjson.NewLoadTxFilterCmd(reload, addrStrs, outPointObjects)
+	cmd := ulordjson.NewLoadTxFilterCmd(reload, addrStrs, outPointObjects,
+		nil, nil)
 	return c.sendCmd(cmd)
 }
 
@@ -1355,3 +1397,49 @@ func (c *Client) LoadTxFilterAsync(reload bool, addresses []ulordutil.Address,
 func (c *Client) LoadTxFilter(reload bool, addresses []ulordutil.Address, outPoints []wire.OutPoint) error {
 	return c.LoadTxFilterAsync(reload, addresses, outPoints).Receive()
 }
+
+// LoadTxFilterScriptsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See LoadTxFilterScripts for the blocking version and more details.
+//
+// NOTE: This is a ulord extension and requires a websocket connection.
+func (c *Client) LoadTxFilterScriptsAsync(reload bool,
+	addresses []ulordutil.Address, outPoints []wire.OutPoint,
+	scripts [][]byte, minAmount ulordutil.Amount) FutureLoadTxFilterResult {
+
+	addrStrs := make([]string, len(addresses))
+	for i, a := range addresses {
+		addrStrs[i] = a.EncodeAddress()
+	}
+	outPointObjects := make([]ulordjson.OutPoint, len(outPoints))
+	for i := range outPoints {
+		outPointObjects[i] = ulordjson.OutPoint{
+			Hash:  outPoints[i].Hash.String(),
+			Index: outPoints[i].Index,
+		}
+	}
+	scriptStrs := make([]string, len(scripts))
+	for i, script := range scripts {
+		scriptStrs[i] = hex.EncodeToString(script)
+	}
+
+	cmd := ulordjson.NewLoadTxFilterCmd(reload, addrStrs, outPointObjects,
+		&scriptStrs, ulordjson.Float64(minAmount.ToBTC()))
+	return c.sendCmd(cmd)
+}
+
+// LoadTxFilterScripts is like LoadTxFilter except the filter also matches
+// outputs paying to the passed output scripts, which may be nonstandard, and
+// outputs worth less than the passed minimum amount never match the filter.
+// Passing a minimum amount of zero matches outputs of any amount.
+//
+// NOTE: This is a ulord extension and requires a websocket connection.
+func (c *Client) LoadTxFilterScripts(reload bool, addresses []ulordutil.Address,
+	outPoints []wire.OutPoint, scripts [][]byte,
+	minAmount ulordutil.Amount) error {
+
+	return c.LoadTxFilterScriptsAsync(reload, addresses, outPoints, scripts,
+		minAmount).Receive()
+}
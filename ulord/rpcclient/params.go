@@ -0,0 +1,114 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// WrongNetworkError describes an address passed to a method sending funds to
+// it which is not for the network of the RPC server.  It is returned before the
+// request is sent to the server.
+type WrongNetworkError struct {
+	// Address is the address which is not for the network of the server.
+	Address ulordutil.Address
+
+	// Net is the network of the server.
+	Net *chaincfg.Params
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *WrongNetworkError) Error() string {
+	return fmt.Sprintf("address %s is not for the %s network of the RPC "+
+		"server", e.Address.EncodeAddress(), e.Net.Name)
+}
+
+// chainParams returns the parameters of the network with the passed name as
+// reported in the chain field of the getblockchaininfo result.  Both the names
+// of the networks of this suite and the short names reported by Bitcoin Core
+// style servers are recognized.
+func chainParams(chain string) (*chaincfg.Params, error) {
+	switch chain {
+	case chaincfg.MainNetParams.Name, "main":
+		return &chaincfg.MainNetParams, nil
+	case chaincfg.TestNet3Params.Name, "test":
+		return &chaincfg.TestNet3Params, nil
+	case chaincfg.RegressionNetParams.Name:
+		return &chaincfg.RegressionNetParams, nil
+	case chaincfg.SimNetParams.Name:
+		return &chaincfg.SimNetParams, nil
+	default:
+		return nil, fmt.Errorf("unknown chain %q", chain)
+	}
+}
+
+// detectParams queries the network of the RPC server with getblockchaininfo
+// unless it is already known and returns its parameters.  Errors are not
+// cached so that the detection is retried by the next call.
+//
+// This function is safe for concurrent access.
+func (c *Client) detectParams() (*chaincfg.Params, error) {
+	c.paramsMtx.Lock()
+	defer c.paramsMtx.Unlock()
+
+	if c.params != nil {
+		return c.params, nil
+	}
+
+	info, err := c.GetBlockChainInfo()
+	if err != nil {
+		return nil, err
+	}
+	params, err := chainParams(info.Chain)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("RPC server %s is on %s", c.config.Host, params.Name)
+	c.params = params
+	return params, nil
+}
+
+// Params returns the parameters of the network of the RPC server.  Websocket
+// clients query the network when their connection is established, while HTTP
+// POST clients query it with the first call which needs it.  The error of the
+// query is returned when the network could not be determined.
+//
+// This function is safe for concurrent access.
+func (c *Client) Params() (*chaincfg.Params, error) {
+	return c.detectParams()
+}
+
+// checkAddrNets returns a *WrongNetworkError for the first of the passed
+// addresses which is not for the network of the RPC server, or the error of
+// detecting the network when it is not known yet.
+func (c *Client) checkAddrNets(addrs ...ulordutil.Address) error {
+	params, err := c.Params()
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !addr.IsForNet(params) {
+			return &WrongNetworkError{Address: addr, Net: params}
+		}
+	}
+	return nil
+}
+
+// checkAmountNets is like checkAddrNets for the addresses of the passed
+// amounts, which are checked in the order of their encodings.
+func (c *Client) checkAmountNets(amounts map[ulordutil.Address]ulordutil.Amount) error {
+	addrs := make([]ulordutil.Address, 0, len(amounts))
+	for addr := range amounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].EncodeAddress() < addrs[j].EncodeAddress()
+	})
+	return c.checkAddrNets(addrs...)
+}
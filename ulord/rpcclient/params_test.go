@@ -0,0 +1,185 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestChainParams ensures the chain names reported by getblockchaininfo map to
+// the parameters of their networks and unknown names are rejected.
+func TestChainParams(t *testing.T) {
+	tests := []struct {
+		chain  string
+		params *chaincfg.Params
+	}{
+		{"mainnet", &chaincfg.MainNetParams},
+		{"main", &chaincfg.MainNetParams},
+		{"testnet3", &chaincfg.TestNet3Params},
+		{"test", &chaincfg.TestNet3Params},
+		{"regtest", &chaincfg.RegressionNetParams},
+		{"simnet", &chaincfg.SimNetParams},
+		{"signet", nil},
+		{"MAINNET", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		params, err := chainParams(test.chain)
+		if test.params == nil {
+			if err == nil {
+				t.Errorf("chainParams(%q): got %s, want error",
+					test.chain, params.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("chainParams(%q): unexpected error: %v",
+				test.chain, err)
+			continue
+		}
+		if params != test.params {
+			t.Errorf("chainParams(%q): got %s, want %s", test.chain,
+				params.Name, test.params.Name)
+		}
+	}
+}
+
+// testRPCServer is a JSON-RPC server on the regression test network which
+// records the methods of the requests it receives.
+type testRPCServer struct {
+	*httptest.Server
+
+	mtx     sync.Mutex
+	methods []string
+}
+
+// newTestRPCServer returns a started testRPCServer.
+func newTestRPCServer(t *testing.T) *testRPCServer {
+	s := &testRPCServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unable to read request: %v", err)
+			return
+		}
+		var req struct {
+			Method string          `json:"method"`
+			ID     json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("unable to decode request %s: %v", body, err)
+			return
+		}
+		s.mtx.Lock()
+		s.methods = append(s.methods, req.Method)
+		s.mtx.Unlock()
+
+		var result interface{}
+		switch req.Method {
+		case "getblockchaininfo":
+			result = map[string]interface{}{"chain": "regtest"}
+		case "sendtoaddress", "sendmany":
+			result = strings.Repeat("00", 32)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": result,
+			"error":  nil,
+			"id":     req.ID,
+		})
+	}))
+	return s
+}
+
+// Methods returns the methods of the requests received so far.
+func (s *testRPCServer) Methods() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.methods...)
+}
+
+// TestSendWrongNetwork ensures sending to an address of another network than
+// the one of the RPC server fails with a WrongNetworkError without sending the
+// request, while addresses of the network of the server are sent.
+func TestSendWrongNetwork(t *testing.T) {
+	server := newTestRPCServer(t)
+	defer server.Close()
+
+	client, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	defer client.Shutdown()
+
+	hash := make([]byte, 20)
+	mainAddr, err := ulordutil.NewAddressPubKeyHash(hash,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	regtestAddr, err := ulordutil.NewAddressPubKeyHash(hash,
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	checkWrongNetwork := func(method string, err error) {
+		t.Helper()
+		wrongNet, ok := err.(*WrongNetworkError)
+		if !ok {
+			t.Fatalf("%s: got error %v, want WrongNetworkError",
+				method, err)
+		}
+		if wrongNet.Address != mainAddr ||
+			wrongNet.Net != &chaincfg.RegressionNetParams {
+
+			t.Fatalf("%s: got error for address %v on %s", method,
+				wrongNet.Address, wrongNet.Net.Name)
+		}
+	}
+
+	_, err = client.SendToAddress(mainAddr, 1000)
+	checkWrongNetwork("SendToAddress", err)
+	_, err = client.SendMany("", map[ulordutil.Address]ulordutil.Amount{
+		regtestAddr: 1000,
+		mainAddr:    1000,
+	})
+	checkWrongNetwork("SendMany", err)
+
+	// Only the network of the server was queried, once.
+	methods := server.Methods()
+	if len(methods) != 1 || methods[0] != "getblockchaininfo" {
+		t.Fatalf("server received %v, want only getblockchaininfo",
+			methods)
+	}
+	if params, err := client.Params(); err != nil ||
+		params != &chaincfg.RegressionNetParams {
+
+		t.Fatalf("Params: got %v (err %v), want regtest", params, err)
+	}
+
+	if _, err := client.SendToAddress(regtestAddr, 1000); err != nil {
+		t.Fatalf("SendToAddress: unexpected error: %v", err)
+	}
+	methods = server.Methods()
+	if len(methods) != 2 || methods[1] != "sendtoaddress" {
+		t.Fatalf("server received %v, want sendtoaddress last",
+			methods)
+	}
+}
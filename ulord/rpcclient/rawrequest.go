@@ -49,6 +49,9 @@ func (c *Client) RawRequestAsync(method string, params []json.RawMessage) Future
 		Method:  method,
 		Params:  params,
 	}
+	if !c.config.HTTPPostMode {
+		rawRequest.Wallet = c.wallet
+	}
 	marshalledJSON, err := json.Marshal(rawRequest)
 	if err != nil {
 		return newFutureError(err)
@@ -78,7 +78,7 @@ func (r FutureGetRawTransactionResult) Receive() (*ulordutil.Tx, error) {
 	}
 
 	// Decode the serialized transaction hex to raw bytes.
-	serializedTx, err := hex.DecodeString(txHex)
+	serializedTx, err := ulordutil.DecodeInto(nil, txHex)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +293,7 @@ func (c *Client) SendRawTransactionAsync(tx *wire.MsgTx, allowHighFees bool) Fut
 		if err := tx.Serialize(buf); err != nil {
 			return newFutureError(err)
 		}
-		txHex = hex.EncodeToString(buf.Bytes())
+		txHex = ulordutil.EncodeToStringBuf(buf.Bytes())
 	}
 
 	cmd := ulordjson.NewSendRawTransactionCmd(txHex, &allowHighFees)
@@ -306,6 +306,54 @@ func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainh
 	return c.SendRawTransactionAsync(tx, allowHighFees).Receive()
 }
 
+// FutureTestMempoolAcceptResult is a future promise to deliver the result
+// of a TestMempoolAcceptAsync RPC invocation (or an applicable error).
+type FutureTestMempoolAcceptResult chan *response
+
+// Receive waits for the response promised by the future and returns whether
+// each of the transactions would be accepted into the memory pool.
+func (r FutureTestMempoolAcceptResult) Receive() ([]ulordjson.TestMempoolAcceptResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of testmempoolaccept result objects.
+	var results []ulordjson.TestMempoolAcceptResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// TestMempoolAcceptAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See TestMempoolAccept for the blocking version and more details.
+func (c *Client) TestMempoolAcceptAsync(txns []*wire.MsgTx) FutureTestMempoolAcceptResult {
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := ulordjson.NewTestMempoolAcceptCmd(rawTxns)
+	return c.sendCmd(cmd)
+}
+
+// TestMempoolAccept returns whether the server would accept each of the passed
+// transactions into its memory pool without adding them to it.
+func (c *Client) TestMempoolAccept(txns []*wire.MsgTx) ([]ulordjson.TestMempoolAcceptResult, error) {
+	return c.TestMempoolAcceptAsync(txns).Receive()
+}
+
 // FutureSignRawTransactionResult is a future promise to deliver the result
 // of one of the SignRawTransactionAsync family of RPC invocations (or an
 // applicable error).
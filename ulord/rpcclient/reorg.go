@@ -0,0 +1,125 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// BlockStamp identifies a block of the best chain by its hash and height.
+type BlockStamp struct {
+	Hash   chainhash.Hash
+	Height int32
+}
+
+// reorg describes a chain reorganization reconstructed by a reorgTracker.
+type reorg struct {
+	oldTip         *BlockStamp
+	newTip         *BlockStamp
+	commonAncestor *BlockStamp
+	detached       []*wire.BlockHeader
+	attached       []*wire.BlockHeader
+}
+
+// reorgTracker reconstructs chain reorganizations from the filtered block
+// connected and disconnected notifications sent by the server, which always
+// sends the notifications for all blocks detached by a reorg before the ones
+// for the blocks it attaches.
+//
+// The tracker is only accessed by the goroutine delivering notifications, so
+// it is not protected by a mutex.
+type reorgTracker struct {
+	// detached holds the headers of the blocks detached by a pending reorg
+	// in the order they were detached, so the first one is the header of
+	// the old tip of the chain.
+	detached     []*wire.BlockHeader
+	oldTipHeight int32
+
+	// attached holds the headers of the blocks attached by a pending reorg
+	// in the order they were attached.
+	attached          []*wire.BlockHeader
+	attachedTipHeight int32
+}
+
+// blockDisconnected records the passed block as detached from the best chain.
+// It returns the pending reorg when its attached blocks are being detached
+// again, since the new chain of such a reorg never reached the height of the
+// old one, and nil otherwise.
+func (t *reorgTracker) blockDisconnected(height int32, header *wire.BlockHeader) *reorg {
+	var completed *reorg
+	if len(t.attached) != 0 {
+		completed = t.complete()
+	}
+
+	if len(t.detached) == 0 {
+		t.oldTipHeight = height
+	}
+	t.detached = append(t.detached, header)
+	return completed
+}
+
+// blockConnected records the passed block as attached to the best chain.  It
+// returns the pending reorg once the new chain reaches the height of the old
+// one, and nil otherwise.
+func (t *reorgTracker) blockConnected(height int32, header *wire.BlockHeader) *reorg {
+	// Blocks extending the best chain outside of a reorg need no
+	// reconciliation.
+	if len(t.detached) == 0 {
+		return nil
+	}
+
+	// The first block attached by a reorg must build on the common
+	// ancestor, which is the parent of the last block detached.  Give up
+	// on the reorg otherwise since notifications were missed, such as
+	// while the client was reconnecting.
+	if len(t.attached) == 0 &&
+		header.PrevBlock != t.detached[len(t.detached)-1].PrevBlock {
+
+		log.Warnf("Block %v at height %d does not build on the common "+
+			"ancestor of the %d blocks detached before it",
+			header.BlockHash(), height, len(t.detached))
+		t.reset()
+		return nil
+	}
+
+	t.attached = append(t.attached, header)
+	t.attachedTipHeight = height
+	if height < t.oldTipHeight {
+		return nil
+	}
+	return t.complete()
+}
+
+// complete returns the pending reorg and resets the tracker for the next one.
+func (t *reorgTracker) complete() *reorg {
+	lastDetached := t.detached[len(t.detached)-1]
+	r := &reorg{
+		detached: t.detached,
+		attached: t.attached,
+	}
+	r.oldTip = &BlockStamp{
+		Hash:   t.detached[0].BlockHash(),
+		Height: t.oldTipHeight,
+	}
+	r.newTip = &BlockStamp{
+		Hash:   t.attached[len(t.attached)-1].BlockHash(),
+		Height: t.attachedTipHeight,
+	}
+	r.commonAncestor = &BlockStamp{
+		Hash:   lastDetached.PrevBlock,
+		Height: t.oldTipHeight - int32(len(t.detached)),
+	}
+	t.reset()
+	return r
+}
+
+// reset discards the pending reorg.
+func (t *reorgTracker) reset() {
+	t.detached = nil
+	t.oldTipHeight = 0
+	t.attached = nil
+	t.attachedTipHeight = 0
+}
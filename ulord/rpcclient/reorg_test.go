@@ -0,0 +1,259 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordjson"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// reorgTestChain holds the headers of a main chain a0 to a5 and of a side chain
+// b4 to b6 forking from it after a3, keyed by name.
+type reorgTestChain map[string]*wire.BlockHeader
+
+// newReorgTestChain returns the headers of the test chains.
+func newReorgTestChain() reorgTestChain {
+	chain := make(reorgTestChain)
+	extend := func(prefix string, prev *wire.BlockHeader, from, to int) {
+		for height := from; height <= to; height++ {
+			header := &wire.BlockHeader{
+				Version:   1,
+				Timestamp: time.Unix(1500000000+int64(height), 0),
+				Nonce:     uint32(len(chain)),
+			}
+			if prev != nil {
+				header.PrevBlock = prev.BlockHash()
+			}
+			chain[prefix+string('0'+rune(height))] = header
+			prev = header
+		}
+	}
+	extend("a", nil, 0, 5)
+	extend("b", chain["a3"], 4, 6)
+	return chain
+}
+
+// height returns the height of the block with the passed name.
+func (reorgTestChain) height(name string) int32 {
+	return int32(name[1] - '0')
+}
+
+// stamp returns the block stamp of the block with the passed name.
+func (c reorgTestChain) stamp(name string) *BlockStamp {
+	return &BlockStamp{Hash: c[name].BlockHash(), Height: c.height(name)}
+}
+
+// headers returns the headers of the blocks with the passed names.
+func (c reorgTestChain) headers(names ...string) []*wire.BlockHeader {
+	headers := make([]*wire.BlockHeader, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, c[name])
+	}
+	return headers
+}
+
+// notification returns the notification of the passed method for the block
+// with the passed name.
+func (c reorgTestChain) notification(t *testing.T, method, name string) *rawNotification {
+	header := c[name]
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		t.Fatalf("unable to serialize header: %v", err)
+	}
+	hexHeader := hex.EncodeToString(buf.Bytes())
+
+	var params []interface{}
+	switch method {
+	case ulordjson.FilteredBlockConnectedNtfnMethod:
+		params = []interface{}{c.height(name), hexHeader, []string{}}
+	case ulordjson.FilteredBlockDisconnectedNtfnMethod:
+		params = []interface{}{c.height(name), hexHeader}
+	default:
+		params = []interface{}{header.BlockHash().String(),
+			c.height(name), header.Timestamp.Unix()}
+	}
+	ntfn := &rawNotification{Method: method}
+	for _, param := range params {
+		marshalled, err := json.Marshal(param)
+		if err != nil {
+			t.Fatalf("unable to marshal parameter: %v", err)
+		}
+		ntfn.Params = append(ntfn.Params, marshalled)
+	}
+	return ntfn
+}
+
+// testReorg is a reorg reported to the OnReorg handler.
+type testReorg struct {
+	oldTip, newTip, commonAncestor *BlockStamp
+	detached, attached             []*wire.BlockHeader
+}
+
+// TestReorgTracker ensures chain reorganizations are reconstructed from the
+// filtered block connected and disconnected notifications.
+func TestReorgTracker(t *testing.T) {
+	chain := newReorgTestChain()
+	const (
+		connected       = ulordjson.FilteredBlockConnectedNtfnMethod
+		disconnected    = ulordjson.FilteredBlockDisconnectedNtfnMethod
+		oldConnected    = ulordjson.BlockConnectedNtfnMethod
+		oldDisconnected = ulordjson.BlockDisconnectedNtfnMethod
+	)
+	type event struct {
+		method string
+		block  string
+	}
+	simpleReorg := testReorg{
+		oldTip:         chain.stamp("a5"),
+		newTip:         chain.stamp("b5"),
+		commonAncestor: chain.stamp("a3"),
+		detached:       chain.headers("a5", "a4"),
+		attached:       chain.headers("b4", "b5"),
+	}
+
+	tests := []struct {
+		name   string
+		events []event
+		reorgs []testReorg
+	}{{
+		name: "blocks connected without reorg",
+		events: []event{
+			{connected, "a4"},
+			{connected, "a5"},
+		},
+	}, {
+		name: "simple reorg",
+		events: []event{
+			{disconnected, "a5"},
+			{disconnected, "a4"},
+			{connected, "b4"},
+			{connected, "b5"},
+			{connected, "b6"},
+		},
+		reorgs: []testReorg{simpleReorg},
+	}, {
+		// The reorg is only reported once the new chain reaches the
+		// height of the old one.
+		name: "shorter new chain",
+		events: []event{
+			{disconnected, "a5"},
+			{disconnected, "a4"},
+			{connected, "b4"},
+		},
+	}, {
+		// The blocks attached by a reorg whose new chain is shorter
+		// are detached again by the next reorg.
+		name: "second detach before the attach completes",
+		events: []event{
+			{disconnected, "a5"},
+			{disconnected, "a4"},
+			{connected, "b4"},
+			{disconnected, "b4"},
+			{connected, "a4"},
+			{connected, "a5"},
+		},
+		reorgs: []testReorg{{
+			oldTip:         chain.stamp("a5"),
+			newTip:         chain.stamp("b4"),
+			commonAncestor: chain.stamp("a3"),
+			detached:       chain.headers("a5", "a4"),
+			attached:       chain.headers("b4"),
+		}, {
+			oldTip:         chain.stamp("b4"),
+			newTip:         chain.stamp("a4"),
+			commonAncestor: chain.stamp("a3"),
+			detached:       chain.headers("b4"),
+			attached:       chain.headers("a4"),
+		}},
+	}, {
+		// The notification of b4 was missed, so the reorg can't be
+		// reconstructed and the tracker starts over.
+		name: "first attached block not on the common ancestor",
+		events: []event{
+			{disconnected, "a5"},
+			{disconnected, "a4"},
+			{connected, "b5"},
+			{connected, "b6"},
+			{disconnected, "b6"},
+			{connected, "b6"},
+		},
+		reorgs: []testReorg{{
+			oldTip:         chain.stamp("b6"),
+			newTip:         chain.stamp("b6"),
+			commonAncestor: chain.stamp("b5"),
+			detached:       chain.headers("b6"),
+			attached:       chain.headers("b6"),
+		}},
+	}, {
+		name: "interleaved non-filtered notifications",
+		events: []event{
+			{oldDisconnected, "a5"},
+			{disconnected, "a5"},
+			{oldDisconnected, "a4"},
+			{disconnected, "a4"},
+			{oldConnected, "b4"},
+			{connected, "b4"},
+			{oldConnected, "b5"},
+			{connected, "b5"},
+		},
+		reorgs: []testReorg{simpleReorg},
+	}}
+
+	for _, test := range tests {
+		var reorgs []testReorg
+		var oldNtfns int
+		client := &Client{clientConn: &clientConn{
+			ntfnHandlers: &NotificationHandlers{
+				OnBlockConnected: func(*chainhash.Hash, int32, time.Time) {
+					oldNtfns++
+				},
+				OnBlockDisconnected: func(*chainhash.Hash, int32, time.Time) {
+					oldNtfns++
+				},
+				OnReorg: func(oldTip, newTip, commonAncestor *BlockStamp,
+					detached, attached []*wire.BlockHeader) {
+
+					reorgs = append(reorgs, testReorg{oldTip,
+						newTip, commonAncestor, detached,
+						attached})
+				},
+			},
+		}}
+
+		var wantOldNtfns int
+		for _, event := range test.events {
+			if event.method == oldConnected ||
+				event.method == oldDisconnected {
+
+				wantOldNtfns++
+			}
+			ntfn := chain.notification(t, event.method, event.block)
+			client.handleNotification(ntfn)
+		}
+		if oldNtfns != wantOldNtfns {
+			t.Errorf("%s: got %d non-filtered notifications, want %d",
+				test.name, oldNtfns, wantOldNtfns)
+		}
+		if len(reorgs) != len(test.reorgs) {
+			t.Errorf("%s: got %d reorgs, want %d", test.name,
+				len(reorgs), len(test.reorgs))
+			continue
+		}
+		for i, got := range reorgs {
+			if !reflect.DeepEqual(got, test.reorgs[i]) {
+				t.Errorf("%s: reorg %d: got %+v, want %+v",
+					test.name, i, got, test.reorgs[i])
+			}
+		}
+	}
+}
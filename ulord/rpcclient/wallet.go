@@ -470,6 +470,9 @@ func (r FutureSendToAddressResult) Receive() (*chainhash.Hash, error) {
 //
 // See SendToAddress for the blocking version and more details.
 func (c *Client) SendToAddressAsync(address ulordutil.Address, amount ulordutil.Amount) FutureSendToAddressResult {
+	if err := c.checkAddrNets(address); err != nil {
+		return newFutureError(err)
+	}
 	addr := address.EncodeAddress()
 	cmd := ulordjson.NewSendToAddressCmd(addr, amount.ToBTC(), nil, nil)
 	return c.sendCmd(cmd)
@@ -496,6 +499,9 @@ func (c *Client) SendToAddressCommentAsync(address ulordutil.Address,
 	amount ulordutil.Amount, comment,
 	commentTo string) FutureSendToAddressResult {
 
+	if err := c.checkAddrNets(address); err != nil {
+		return newFutureError(err)
+	}
 	addr := address.EncodeAddress()
 	cmd := ulordjson.NewSendToAddressCmd(addr, amount.ToBTC(), &comment,
 		&commentTo)
@@ -549,6 +555,9 @@ func (r FutureSendFromResult) Receive() (*chainhash.Hash, error) {
 //
 // See SendFrom for the blocking version and more details.
 func (c *Client) SendFromAsync(fromAccount string, toAddress ulordutil.Address, amount ulordutil.Amount) FutureSendFromResult {
+	if err := c.checkAddrNets(toAddress); err != nil {
+		return newFutureError(err)
+	}
 	addr := toAddress.EncodeAddress()
 	cmd := ulordjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(), nil,
 		nil, nil)
@@ -573,6 +582,9 @@ func (c *Client) SendFrom(fromAccount string, toAddress ulordutil.Address, amoun
 //
 // See SendFromMinConf for the blocking version and more details.
 func (c *Client) SendFromMinConfAsync(fromAccount string, toAddress ulordutil.Address, amount ulordutil.Amount, minConfirms int) FutureSendFromResult {
+	if err := c.checkAddrNets(toAddress); err != nil {
+		return newFutureError(err)
+	}
 	addr := toAddress.EncodeAddress()
 	cmd := ulordjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(),
 		&minConfirms, nil, nil)
@@ -602,6 +614,9 @@ func (c *Client) SendFromCommentAsync(fromAccount string,
 	toAddress ulordutil.Address, amount ulordutil.Amount, minConfirms int,
 	comment, commentTo string) FutureSendFromResult {
 
+	if err := c.checkAddrNets(toAddress); err != nil {
+		return newFutureError(err)
+	}
 	addr := toAddress.EncodeAddress()
 	cmd := ulordjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(),
 		&minConfirms, &comment, &commentTo)
@@ -657,6 +672,9 @@ func (r FutureSendManyResult) Receive() (*chainhash.Hash, error) {
 //
 // See SendMany for the blocking version and more details.
 func (c *Client) SendManyAsync(fromAccount string, amounts map[ulordutil.Address]ulordutil.Amount) FutureSendManyResult {
+	if err := c.checkAmountNets(amounts); err != nil {
+		return newFutureError(err)
+	}
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
 		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
@@ -686,6 +704,9 @@ func (c *Client) SendManyMinConfAsync(fromAccount string,
 	amounts map[ulordutil.Address]ulordutil.Amount,
 	minConfirms int) FutureSendManyResult {
 
+	if err := c.checkAmountNets(amounts); err != nil {
+		return newFutureError(err)
+	}
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
 		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
@@ -720,6 +741,9 @@ func (c *Client) SendManyCommentAsync(fromAccount string,
 	amounts map[ulordutil.Address]ulordutil.Amount, minConfirms int,
 	comment string) FutureSendManyResult {
 
+	if err := c.checkAmountNets(amounts); err != nil {
+		return newFutureError(err)
+	}
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
 		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
@@ -1896,11 +1920,59 @@ func (c *Client) WalletLock() error {
 // decryption key which is then stored in memory for the specified timeout
 // (in seconds).
 func (c *Client) WalletPassphrase(passphrase string, timeoutSecs int64) error {
-	cmd := ulordjson.NewWalletPassphraseCmd(passphrase, timeoutSecs)
+	cmd := ulordjson.NewWalletPassphraseCmd(passphrase, timeoutSecs, nil)
 	_, err := c.sendCmdAndWait(cmd)
 	return err
 }
 
+// WalletPassphraseStakingOnly unlocks the wallet for the specified timeout (in
+// seconds) like WalletPassphrase, except that the unlocked wallet may only be
+// used for staking and not to send funds.
+func (c *Client) WalletPassphraseStakingOnly(passphrase string, timeoutSecs int64) error {
+	cmd := ulordjson.NewWalletPassphraseCmd(passphrase, timeoutSecs,
+		ulordjson.Bool(true))
+	_, err := c.sendCmdAndWait(cmd)
+	return err
+}
+
+// FutureGetWalletLockStateResult is a future promise to deliver the result of
+// a GetWalletLockStateAsync RPC invocation (or an applicable error).
+type FutureGetWalletLockStateResult chan *response
+
+// Receive waits for the response promised by the future and returns the lock
+// state of the wallet.
+func (r FutureGetWalletLockStateResult) Receive() (*ulordjson.GetWalletLockStateResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getwalletlockstate result object.
+	var lockState ulordjson.GetWalletLockStateResult
+	err = json.Unmarshal(res, &lockState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockState, nil
+}
+
+// GetWalletLockStateAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetWalletLockState for the blocking version and more details.
+func (c *Client) GetWalletLockStateAsync() FutureGetWalletLockStateResult {
+	cmd := ulordjson.NewGetWalletLockStateCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetWalletLockState returns whether the wallet is locked and, when it is
+// unlocked, until when and whether it is unlocked for staking only.
+func (c *Client) GetWalletLockState() (*ulordjson.GetWalletLockStateResult, error) {
+	return c.GetWalletLockStateAsync().Receive()
+}
+
 // FutureWalletPassphraseChangeResult is a future promise to deliver the result
 // of a WalletPassphraseChangeAsync RPC invocation (or an applicable error).
 type FutureWalletPassphraseChangeResult chan *response
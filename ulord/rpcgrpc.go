@@ -0,0 +1,236 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ulordsuite/ulord/grpcapi"
+	"github.com/ulordsuite/ulord/ulordjson"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// grpcMethods maps the methods of the gRPC service to the RPC methods whose
+// handlers serve them.  Users may only call a gRPC method when their profile
+// allows the matching RPC method.
+var grpcMethods = map[string]string{
+	"GetBestBlock":          "getbestblock",
+	"GetBlock":              "getblock",
+	"GetBlockChainInfo":     "getblockchaininfo",
+	"GetTransaction":        "getrawtransaction",
+	"SendTransaction":       "sendrawtransaction",
+	"SubscribeBlocks":       "notifyblocks",
+	"SubscribeTransactions": "notifynewtransactions",
+}
+
+// grpcErrorFromRPC converts an error returned by an RPC handler into the
+// matching gRPC error.
+func grpcErrorFromRPC(err error) error {
+	rpcErr, ok := err.(*ulordjson.RPCError)
+	if !ok {
+		return err
+	}
+	switch rpcErr.Code {
+	// The block not found and no transaction info errors share the same
+	// code.
+	case ulordjson.ErrRPCBlockNotFound, ulordjson.ErrRPCMisc:
+		return grpcapi.Errorf(grpcapi.NotFound, "%s", rpcErr.Message)
+
+	// Invalid hex strings and transactions which can't be deserialized or
+	// are rejected share the same code.
+	case ulordjson.ErrRPCDecodeHexString, ulordjson.ErrRPCInvalidParameter:
+		return grpcapi.Errorf(grpcapi.InvalidArgument, "%s",
+			rpcErr.Message)
+	}
+	return grpcapi.Errorf(grpcapi.Internal, "%s", rpcErr.Message)
+}
+
+// grpcAuthorize authenticates gRPC requests with the same credentials as RPC
+// requests and checks the profile of the user allows the requested method.
+func (s *rpcServer) grpcAuthorize(r *http.Request, method string) error {
+	_, profile, err := s.checkAuth(r, true)
+	if err != nil {
+		return grpcapi.Errorf(grpcapi.Unauthenticated,
+			"authentication failure")
+	}
+	if !profile.allows(grpcMethods[method]) {
+		return grpcapi.Errorf(grpcapi.PermissionDenied,
+			"%s user not authorized for this method", profile.name)
+	}
+	return nil
+}
+
+// newGRPCService returns the gRPC service whose methods are served by the RPC
+// handlers.
+func (s *rpcServer) newGRPCService() *grpcapi.Server {
+	service := grpcapi.NewServer(s.grpcAuthorize)
+
+	service.HandleUnary("GetBestBlock",
+		func() grpcapi.Message { return new(grpcapi.GetBestBlockRequest) },
+		func(r *http.Request, req grpcapi.Message) (grpcapi.Message, error) {
+			result, err := handleGetBestBlock(s, &ulordjson.GetBestBlockCmd{}, nil)
+			if err != nil {
+				return nil, grpcErrorFromRPC(err)
+			}
+			best := result.(*ulordjson.GetBestBlockResult)
+			return &grpcapi.GetBestBlockResponse{
+				Hash:   best.Hash,
+				Height: best.Height,
+			}, nil
+		})
+
+	service.HandleUnary("GetBlock",
+		func() grpcapi.Message { return new(grpcapi.GetBlockRequest) },
+		func(r *http.Request, req grpcapi.Message) (grpcapi.Message, error) {
+			result, err := handleGetBlock(s, &ulordjson.GetBlockCmd{
+				Hash:    req.(*grpcapi.GetBlockRequest).Hash,
+				Verbose: ulordjson.Bool(false),
+			}, nil)
+			if err != nil {
+				return nil, grpcErrorFromRPC(err)
+			}
+			block, err := hex.DecodeString(result.(string))
+			if err != nil {
+				return nil, err
+			}
+			return &grpcapi.GetBlockResponse{Block: block}, nil
+		})
+
+	service.HandleUnary("GetBlockChainInfo",
+		func() grpcapi.Message { return new(grpcapi.GetBlockChainInfoRequest) },
+		func(r *http.Request, req grpcapi.Message) (grpcapi.Message, error) {
+			result, err := handleGetBlockChainInfo(s,
+				&ulordjson.GetBlockChainInfoCmd{}, nil)
+			if err != nil {
+				return nil, grpcErrorFromRPC(err)
+			}
+			info := result.(*ulordjson.GetBlockChainInfoResult)
+			return &grpcapi.GetBlockChainInfoResponse{
+				Chain:         info.Chain,
+				Blocks:        info.Blocks,
+				Headers:       info.Headers,
+				BestBlockHash: info.BestBlockHash,
+				Difficulty:    info.Difficulty,
+				MedianTime:    info.MedianTime,
+				Pruned:        info.Pruned,
+			}, nil
+		})
+
+	service.HandleUnary("GetTransaction",
+		func() grpcapi.Message { return new(grpcapi.GetTransactionRequest) },
+		func(r *http.Request, req grpcapi.Message) (grpcapi.Message, error) {
+			result, err := handleGetRawTransaction(s,
+				&ulordjson.GetRawTransactionCmd{
+					Txid:    req.(*grpcapi.GetTransactionRequest).Txid,
+					Verbose: ulordjson.Int(0),
+				}, nil)
+			if err != nil {
+				return nil, grpcErrorFromRPC(err)
+			}
+			tx, err := hex.DecodeString(result.(string))
+			if err != nil {
+				return nil, err
+			}
+			return &grpcapi.GetTransactionResponse{Transaction: tx}, nil
+		})
+
+	service.HandleUnary("SendTransaction",
+		func() grpcapi.Message { return new(grpcapi.SendTransactionRequest) },
+		func(r *http.Request, req grpcapi.Message) (grpcapi.Message, error) {
+			c := req.(*grpcapi.SendTransactionRequest)
+			result, err := handleSendRawTransaction(s,
+				&ulordjson.SendRawTransactionCmd{
+					HexTx:         hex.EncodeToString(c.Transaction),
+					AllowHighFees: ulordjson.Bool(c.AllowHighFees),
+				}, nil)
+			if err != nil {
+				return nil, grpcErrorFromRPC(err)
+			}
+			return &grpcapi.SendTransactionResponse{
+				Txid: result.(string),
+			}, nil
+		})
+
+	service.HandleStream("SubscribeBlocks",
+		func() grpcapi.Message { return new(grpcapi.SubscribeBlocksRequest) },
+		func(r *http.Request, req grpcapi.Message, send func(grpcapi.Message) error) error {
+			return s.grpcBlocks.Relay(r.Context(), send)
+		})
+
+	service.HandleStream("SubscribeTransactions",
+		func() grpcapi.Message { return new(grpcapi.SubscribeTransactionsRequest) },
+		func(r *http.Request, req grpcapi.Message, send func(grpcapi.Message) error) error {
+			return s.grpcTxs.Relay(r.Context(), send)
+		})
+
+	return service
+}
+
+// startGRPC starts serving the gRPC service on the gRPC listeners.  HTTP/2 is
+// negotiated over TLS unless TLS is disabled, in which case clients connect
+// with HTTP/2 prior knowledge.
+func (s *rpcServer) startGRPC() {
+	var protocols http.Protocols
+	if cfg.DisableTLS {
+		protocols.SetUnencryptedHTTP2(true)
+	} else {
+		protocols.SetHTTP2(true)
+	}
+	s.grpcServer = &http.Server{
+		Handler:   s.newGRPCService(),
+		Protocols: &protocols,
+
+		// Timeout connections which don't send the headers of their
+		// requests within the allowed timeframe.
+		ReadHeaderTimeout: time.Second * rpcAuthTimeoutSeconds,
+	}
+
+	for _, listener := range s.cfg.GRPCListeners {
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			rpcsLog.Infof("gRPC server listening on %s", listener.Addr())
+			s.grpcServer.Serve(listener)
+			rpcsLog.Tracef("gRPC listener done for %s", listener.Addr())
+			s.wg.Done()
+		}(listener)
+	}
+}
+
+// grpcNotifyBlock streams the passed block, which was connected to or
+// disconnected from the main chain, to the gRPC block subscribers.
+func (s *rpcServer) grpcNotifyBlock(block *ulordutil.Block, connected bool) {
+	if s.grpcBlocks == nil {
+		return
+	}
+	s.grpcBlocks.Broadcast(&grpcapi.BlockNotification{
+		Hash:      block.Hash().String(),
+		Height:    block.Height(),
+		Time:      block.MsgBlock().Header.Timestamp.Unix(),
+		Connected: connected,
+	})
+}
+
+// grpcNotifyTx streams the passed transaction, which was accepted to the
+// memory pool, to the gRPC transaction subscribers.
+func (s *rpcServer) grpcNotifyTx(tx *ulordutil.Tx) {
+	if s.grpcTxs == nil {
+		return
+	}
+	var buf bytes.Buffer
+	buf.Grow(tx.MsgTx().SerializeSize())
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		rpcsLog.Errorf("Failed to serialize transaction %v: %v",
+			tx.Hash(), err)
+		return
+	}
+	s.grpcTxs.Broadcast(&grpcapi.TransactionNotification{
+		Txid:        tx.Hash().String(),
+		Transaction: buf.Bytes(),
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ulordsuite/btclog"
+	"github.com/ulordsuite/ulord/grpcapi"
+)
+
+// TestGRPCAuthorize ensures gRPC requests are authenticated with the RPC
+// credentials and only allowed when the profile of the user allows the RPC
+// method matching the gRPC method.
+func TestGRPCAuthorize(t *testing.T) {
+	for method, rpcMethod := range grpcMethods {
+		if !isKnownRPCMethod(rpcMethod) {
+			t.Errorf("gRPC method %s maps to unknown RPC method %s",
+				method, rpcMethod)
+		}
+	}
+
+	// Authentication failures are logged, which requires the log rotator
+	// that is only initialized by the main function.
+	defer func(logger btclog.Logger) { rpcsLog = logger }(rpcsLog)
+	rpcsLog = btclog.Disabled
+
+	s := &rpcServer{users: []*rpcUser{
+		newRPCUser("admin", "secret", rpcBuiltinProfiles[rpcProfileAdmin]),
+		newRPCUser("monitor", "secret",
+			rpcBuiltinProfiles[rpcProfileReadOnly]),
+	}}
+
+	tests := []struct {
+		user   string
+		pass   string
+		method string
+		code   grpcapi.Code
+	}{
+		{"admin", "secret", "SendTransaction", grpcapi.OK},
+		{"monitor", "secret", "GetBlock", grpcapi.OK},
+		{"monitor", "secret", "SubscribeBlocks", grpcapi.OK},
+		{"monitor", "secret", "SendTransaction", grpcapi.PermissionDenied},
+		{"monitor", "wrong", "GetBlock", grpcapi.Unauthenticated},
+		{"", "", "GetBlock", grpcapi.Unauthenticated},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest(http.MethodPost, "/", nil)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error: %v", err)
+		}
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.pass)
+		}
+
+		code := grpcapi.OK
+		if err := s.grpcAuthorize(r, test.method); err != nil {
+			code = err.(*grpcapi.Error).Code
+		}
+		if code != test.code {
+			t.Errorf("%s calling %s: got code %d, want %d", test.user,
+				test.method, code, test.code)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordjson"
+)
+
+const (
+	// restMaxHeaders is the maximum number of headers which are returned
+	// for a single headers request.
+	restMaxHeaders = 2000
+
+	// restImmutableCacheControl is the Cache-Control header value of
+	// responses whose content never changes, such as serialized blocks.
+	restImmutableCacheControl = "public, max-age=31536000, immutable"
+
+	// restMutableCacheControl is the Cache-Control header value of
+	// responses which depend on the state of the chain.
+	restMutableCacheControl = "no-cache"
+)
+
+// restFormat identifies the output format of a REST response.
+type restFormat int
+
+// These constants define the output formats of the REST interface.
+const (
+	restFormatBinary restFormat = iota
+	restFormatHex
+	restFormatJSON
+)
+
+// restFormats maps the file extensions of REST paths to output formats.
+var restFormats = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restContentTypes maps output formats to their content types.  They are also
+// used to negotiate the output format from the Accept header of requests
+// without a file extension.
+var restContentTypes = map[restFormat]string{
+	restFormatBinary: "application/octet-stream",
+	restFormatHex:    "text/plain",
+	restFormatJSON:   "application/json",
+}
+
+// restError describes an error which is returned to a REST client as a plain
+// text message with the HTTP status code.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// restResponse houses the content of a successful REST response.
+type restResponse struct {
+	// raw is the serialized data returned for the binary and hex formats.
+	raw []byte
+
+	// result is the value returned for the JSON format.
+	result interface{}
+
+	// immutable indicates the content for the requested path never changes
+	// so it can be cached indefinitely.
+	immutable bool
+}
+
+// negotiateRESTFormat returns the output format selected by the Accept header
+// of the passed request, which defaults to JSON.
+func negotiateRESTFormat(r *http.Request) restFormat {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		for format, contentType := range restContentTypes {
+			if mediaType == contentType {
+				return format
+			}
+		}
+	}
+	return restFormatJSON
+}
+
+// parseRESTParam splits the final parameter of a REST path from its file
+// extension and returns it along with the requested output format.  The
+// format is negotiated from the Accept header when there is no extension.
+func parseRESTParam(r *http.Request, param string) (string, restFormat, error) {
+	ext := path.Ext(param)
+	if ext == "" {
+		return param, negotiateRESTFormat(r), nil
+	}
+	format, ok := restFormats[ext[1:]]
+	if !ok {
+		return "", 0, &restError{http.StatusNotFound, fmt.Sprintf(
+			"output format %q not found (available: bin, hex, json)",
+			ext[1:])}
+	}
+	return strings.TrimSuffix(param, ext), format, nil
+}
+
+// restErrorFromRPC converts an error returned by an RPC handler into the
+// matching REST error.
+func restErrorFromRPC(err error) *restError {
+	rpcErr, ok := err.(*ulordjson.RPCError)
+	if !ok {
+		return &restError{http.StatusInternalServerError, err.Error()}
+	}
+	switch rpcErr.Code {
+	// The block not found and no transaction info errors share the same
+	// code.
+	case ulordjson.ErrRPCBlockNotFound, ulordjson.ErrRPCMisc:
+		return &restError{http.StatusNotFound, rpcErr.Message}
+
+	case ulordjson.ErrRPCDecodeHexString, ulordjson.ErrRPCInvalidParameter:
+		return &restError{http.StatusBadRequest, rpcErr.Message}
+	}
+	return &restError{http.StatusInternalServerError, rpcErr.Message}
+}
+
+// restHexResult returns the response for an RPC handler result which is a
+// hex-encoded string.
+func restHexResult(result interface{}, immutable bool) (*restResponse, error) {
+	raw, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return nil, err
+	}
+	return &restResponse{raw: raw, immutable: immutable}, nil
+}
+
+// restBlock returns the block with the passed hash.  The transactions are only
+// listed by their hashes in the JSON format when txDetails is not set.
+func (s *rpcServer) restBlock(hashStr string, format restFormat, txDetails bool) (*restResponse, error) {
+	if format != restFormatJSON {
+		result, err := handleGetBlock(s, &ulordjson.GetBlockCmd{
+			Hash:    hashStr,
+			Verbose: ulordjson.Bool(false),
+		}, nil)
+		if err != nil {
+			return nil, restErrorFromRPC(err)
+		}
+		return restHexResult(result, true)
+	}
+
+	result, err := handleGetBlock(s, &ulordjson.GetBlockCmd{
+		Hash:      hashStr,
+		Verbose:   ulordjson.Bool(true),
+		VerboseTx: ulordjson.Bool(txDetails),
+	}, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return &restResponse{result: result}, nil
+}
+
+// restHeaders returns up to count headers of the main chain starting with the
+// header with the passed hash.  Only the header itself is returned when it is
+// not part of the main chain.
+func (s *rpcServer) restHeaders(countStr, hashStr string, format restFormat) (*restResponse, error) {
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 || count > restMaxHeaders {
+		return nil, &restError{http.StatusBadRequest, fmt.Sprintf(
+			"header count out of range: %s (must be between 1 "+
+				"and %d)", countStr, restMaxHeaders)}
+	}
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, &restError{http.StatusBadRequest,
+			"invalid hash: " + hashStr}
+	}
+
+	chain := s.cfg.Chain
+	hashes := []chainhash.Hash{*hash}
+	if height, err := chain.BlockHeightByHash(hash); err == nil {
+		hashes, err = chain.HeightRange(height, height+int32(count))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var raw bytes.Buffer
+	results := make([]interface{}, 0, len(hashes))
+	for i := range hashes {
+		if format != restFormatJSON {
+			header, err := chain.HeaderByHash(&hashes[i])
+			if err != nil {
+				return nil, &restError{http.StatusNotFound,
+					"Block not found"}
+			}
+			if err := header.Serialize(&raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		result, err := handleGetBlockHeader(s, &ulordjson.GetBlockHeaderCmd{
+			Hash:    hashes[i].String(),
+			Verbose: ulordjson.Bool(true),
+		}, nil)
+		if err != nil {
+			return nil, restErrorFromRPC(err)
+		}
+		results = append(results, result)
+	}
+
+	if format != restFormatJSON {
+		return &restResponse{raw: raw.Bytes()}, nil
+	}
+	return &restResponse{result: results}, nil
+}
+
+// restTx returns the transaction with the passed hash from the memory pool or
+// the transaction index.
+func (s *rpcServer) restTx(hashStr string, format restFormat) (*restResponse, error) {
+	verbose := 0
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	result, err := handleGetRawTransaction(s,
+		&ulordjson.GetRawTransactionCmd{
+			Txid:    hashStr,
+			Verbose: &verbose,
+		}, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	if format == restFormatJSON {
+		return &restResponse{result: result}, nil
+	}
+
+	// Transactions in the memory pool may still be replaced by one with
+	// a different witness, so only confirmed ones are immutable.
+	hash, _ := chainhash.NewHashFromStr(hashStr)
+	return restHexResult(result, !s.cfg.TxMemPool.HaveTransaction(hash))
+}
+
+// restChainInfo returns the state of the block chain as returned by the
+// getblockchaininfo RPC.
+func (s *rpcServer) restChainInfo(format restFormat) (*restResponse, error) {
+	if format != restFormatJSON {
+		return nil, &restError{http.StatusNotFound,
+			"output format not found (available: json)"}
+	}
+	result, err := handleGetBlockChainInfo(s, nil, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return &restResponse{result: result}, nil
+}
+
+// restRoute returns the response for the passed REST path, which is relative
+// to the /rest/ prefix, along with its output format.
+func (s *rpcServer) restRoute(r *http.Request, restPath string) (*restResponse, restFormat, error) {
+	parts := strings.Split(restPath, "/")
+	last, format, err := parseRESTParam(r, parts[len(parts)-1])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var resp *restResponse
+	switch {
+	case len(parts) == 2 && parts[0] == "block":
+		resp, err = s.restBlock(last, format, true)
+
+	case len(parts) == 3 && parts[0] == "block" &&
+		parts[1] == "notxdetails":
+
+		resp, err = s.restBlock(last, format, false)
+
+	case len(parts) == 3 && parts[0] == "headers":
+		resp, err = s.restHeaders(parts[1], last, format)
+
+	case len(parts) == 2 && parts[0] == "tx":
+		resp, err = s.restTx(last, format)
+
+	case len(parts) == 1 && last == "chaininfo":
+		resp, err = s.restChainInfo(format)
+
+	default:
+		err = &restError{http.StatusNotFound, "unknown REST path"}
+	}
+	return resp, format, err
+}
+
+// restHandler serves the read-only REST interface.  It does not require
+// authentication since it only provides public block chain data.
+func (s *rpcServer) restHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Method not allowed.",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Limit the number of connections to max allowed.
+	if s.limitConnections(w, r.RemoteAddr) {
+		return
+	}
+
+	// Keep track of the number of connected clients.
+	s.incrementClients()
+	defer s.decrementClients()
+
+	restPath := strings.TrimPrefix(r.URL.Path, "/rest/")
+	resp, format, err := s.restRoute(r, restPath)
+	if err != nil {
+		rerr, ok := err.(*restError)
+		if !ok {
+			rerr = &restError{http.StatusInternalServerError,
+				err.Error()}
+		}
+		rpcsLog.Debugf("REST request %s failed: %v", r.URL.Path, err)
+		http.Error(w, rerr.message, rerr.status)
+		return
+	}
+
+	var body []byte
+	switch format {
+	case restFormatBinary:
+		body = resp.raw
+	case restFormatHex:
+		body = []byte(hex.EncodeToString(resp.raw) + "\n")
+	default:
+		body, err = json.Marshal(resp.result)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal REST reply: %v", err)
+			http.Error(w, "500 Internal server error.",
+				http.StatusInternalServerError)
+			return
+		}
+		body = append(body, '\n')
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", restContentTypes[format])
+	header.Set("Vary", "Accept")
+	if !resp.immutable {
+		header.Set("Cache-Control", restMutableCacheControl)
+	} else {
+		// The content of immutable responses only depends on the
+		// requested path and format.
+		etag := strconv.Quote(fmt.Sprintf("%s-%d", restPath, format))
+		header.Set("Cache-Control", restImmutableCacheControl)
+		header.Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
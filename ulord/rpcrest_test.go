@@ -0,0 +1,110 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ulordsuite/ulord/ulordjson"
+)
+
+// TestParseRESTParam ensures the output format of REST requests is selected by
+// the file extension or negotiated from the Accept header.
+func TestParseRESTParam(t *testing.T) {
+	tests := []struct {
+		param  string
+		accept string
+		want   string
+		format restFormat
+		status int
+	}{
+		{param: "abcd.bin", want: "abcd", format: restFormatBinary},
+		{param: "abcd.hex", want: "abcd", format: restFormatHex},
+		{param: "abcd.json", want: "abcd", format: restFormatJSON},
+		{param: "abcd.json", accept: "text/plain", want: "abcd",
+			format: restFormatJSON},
+		{param: "abcd", want: "abcd", format: restFormatJSON},
+		{param: "abcd", accept: "application/octet-stream", want: "abcd",
+			format: restFormatBinary},
+		{param: "abcd", accept: "text/html, text/plain; q=0.9",
+			want: "abcd", format: restFormatHex},
+		{param: "abcd", accept: "text/html", want: "abcd",
+			format: restFormatJSON},
+		{param: "abcd.xml", status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/rest/block/"+test.param,
+			nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		got, format, err := parseRESTParam(r, test.param)
+		if test.status != 0 {
+			rerr, ok := err.(*restError)
+			if !ok || rerr.status != test.status {
+				t.Errorf("%s: got error %v, want status %d",
+					test.param, err, test.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.param, err)
+			continue
+		}
+		if got != test.want || format != test.format {
+			t.Errorf("%s (accept %q): got %q with format %d, want "+
+				"%q with format %d", test.param, test.accept, got,
+				format, test.want, test.format)
+		}
+	}
+}
+
+// TestRESTErrors ensures invalid REST requests and errors returned by the RPC
+// handlers are reported with the expected HTTP status codes.
+func TestRESTErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"block not found", &ulordjson.RPCError{
+			Code: ulordjson.ErrRPCBlockNotFound,
+		}, http.StatusNotFound},
+		{"no tx info", &ulordjson.RPCError{
+			Code: ulordjson.ErrRPCNoTxInfo,
+		}, http.StatusNotFound},
+		{"invalid hash", rpcDecodeHexError("zz"), http.StatusBadRequest},
+		{"internal", ulordjson.NewRPCError(ulordjson.ErrRPCInternal.Code,
+			"failure"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		if rerr := restErrorFromRPC(test.err); rerr.status != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name,
+				rerr.status, test.status)
+		}
+	}
+
+	// Requests which are rejected before accessing the chain.
+	s := &rpcServer{}
+	paths := []string{
+		"unknown/abcd.json",
+		"block/a/b/abcd.json",
+		"headers/0/abcd.json",
+		"headers/2001/abcd.json",
+		"headers/x/abcd.json",
+		"chaininfo.bin",
+	}
+	for _, path := range paths {
+		r := httptest.NewRequest(http.MethodGet, "/rest/"+path, nil)
+		_, _, err := s.restRoute(r, path)
+		if _, ok := err.(*restError); !ok {
+			t.Errorf("%s: did not receive expected REST error, got %v",
+				path, err)
+		}
+	}
+}
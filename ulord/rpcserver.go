@@ -7,9 +7,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -21,6 +18,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,16 +27,18 @@ import (
 
 	"github.com/ulordsuite/ulord/blockchain"
 	"github.com/ulordsuite/ulord/blockchain/indexers"
-	"github.com/ulordsuite/ulord/ulordec"
 	"github.com/ulordsuite/ulord/ulordjson"
 	"github.com/ulordsuite/ulord/chaincfg"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/database"
+	"github.com/ulordsuite/ulord/grpcapi"
 	"github.com/ulordsuite/ulord/mempool"
 	"github.com/ulordsuite/ulord/mining"
 	"github.com/ulordsuite/ulord/mining/cpuminer"
+	"github.com/ulordsuite/ulord/netsync"
 	"github.com/ulordsuite/ulord/peer"
 	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/txscript/descriptor"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
 	"github.com/ulordsuite/websocket"
@@ -75,6 +75,10 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxDescriptorRange is the maximum number of addresses the
+	// deriveaddresses RPC derives in a single call.
+	maxDescriptorRange = 10000
 )
 
 var (
@@ -100,7 +104,7 @@ var (
 	// block template generated by the getblocktemplate RPC.    It is
 	// declared here to avoid the overhead of creating the slice on every
 	// invocation for constant data.
-	gbtCapabilities = []string{"proposal"}
+	gbtCapabilities = []string{"proposal", "longpoll", "workid"}
 )
 
 // Errors
@@ -127,26 +131,38 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
+	"addcheckpoint":         handleAddCheckpoint,
 	"addnode":               handleAddNode,
+	"backupchainstate":      handleBackupChainState,
 	"createrawtransaction":  handleCreateRawTransaction,
 	"debuglevel":            handleDebugLevel,
 	"decoderawtransaction":  handleDecodeRawTransaction,
 	"decodescript":          handleDecodeScript,
+	"deriveaddresses":       handleDeriveAddresses,
+	"dumptxoutset":          handleDumpTxOutSet,
 	"estimatefee":           handleEstimateFee,
+	"estimatesmartfee":      handleEstimateSmartFee,
 	"generate":              handleGenerate,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
+	"getaddressbalance":     handleGetAddressBalance,
+	"getaddressdeltas":      handleGetAddressDeltas,
+	"getaddressutxos":       handleGetAddressUtxos,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
 	"getblock":              handleGetBlock,
 	"getblockchaininfo":     handleGetBlockChainInfo,
 	"getblockcount":         handleGetBlockCount,
 	"getblockhash":          handleGetBlockHash,
+	"getblockhashes":        handleGetBlockHashes,
 	"getblockheader":        handleGetBlockHeader,
+	"getblockstats":         handleGetBlockStats,
 	"getblocktemplate":      handleGetBlockTemplate,
 	"getcfilter":            handleGetCFilter,
 	"getcfilterheader":      handleGetCFilterHeader,
+	"getchaintips":          handleGetChainTips,
 	"getconnectioncount":    handleGetConnectionCount,
 	"getcurrentnet":         handleGetCurrentNet,
+	"getdescriptorinfo":     handleGetDescriptorInfo,
 	"getdifficulty":         handleGetDifficulty,
 	"getgenerate":           handleGetGenerate,
 	"gethashespersec":       handleGetHashesPerSec,
@@ -159,15 +175,22 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getpeerinfo":           handleGetPeerInfo,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
+	"getspentinfo":          handleGetSpentInfo,
 	"gettxout":              handleGetTxOut,
 	"help":                  handleHelp,
+	"invalidateblock":       handleInvalidateBlock,
 	"node":                  handleNode,
 	"ping":                  handlePing,
+	"reconsiderblock":       handleReconsiderBlock,
+	"reindex":               handleReindex,
+	"rpcuserinfo":           handleRPCUserInfo,
 	"searchrawtransactions": handleSearchRawTransactions,
 	"sendrawtransaction":    handleSendRawTransaction,
 	"setgenerate":           handleSetGenerate,
+	"settrustedpeer":        handleSetTrustedPeer,
 	"stop":                  handleStop,
 	"submitblock":           handleSubmitBlock,
+	"testmempoolaccept":     handleTestMempoolAccept,
 	"uptime":                handleUptime,
 	"validateaddress":       handleValidateAddress,
 	"verifychain":           handleVerifyChain,
@@ -198,6 +221,7 @@ var rpcAskWallet = map[string]struct{}{
 	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
+	"getwalletlockstate":     {},
 	"importprivkey":          {},
 	"importwallet":           {},
 	"keypoolrefill":          {},
@@ -225,14 +249,15 @@ var rpcAskWallet = map[string]struct{}{
 
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
-	"estimatepriority": {},
-	"getchaintips":     {},
-	"getmempoolentry":  {},
-	"getnetworkinfo":   {},
-	"getwork":          {},
-	"invalidateblock":  {},
-	"preciousblock":    {},
-	"reconsiderblock":  {},
+	"estimatepriority":      {},
+	"getchaintxstats":       {},
+	"getmempoolancestors":   {},
+	"getmempooldescendants": {},
+	"getmempoolentry":       {},
+	"getnetworkinfo":        {},
+	"getwork":               {},
+	"preciousblock":         {},
+	"prioritisetransaction": {},
 }
 
 // Commands that are available to a limited user
@@ -254,16 +279,25 @@ var rpcLimited = map[string]struct{}{
 	"createrawtransaction":  {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
+	"deriveaddresses":       {},
 	"estimatefee":           {},
+	"estimatesmartfee":      {},
+	"getaddressbalance":     {},
+	"getaddressdeltas":      {},
+	"getaddressutxos":       {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
 	"getblockhash":          {},
+	"getblockhashes":        {},
 	"getblockheader":        {},
+	"getblockstats":         {},
 	"getcfilter":            {},
 	"getcfilterheader":      {},
+	"getchaintips":          {},
 	"getcurrentnet":         {},
+	"getdescriptorinfo":     {},
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
@@ -271,10 +305,12 @@ var rpcLimited = map[string]struct{}{
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
+	"getspentinfo":          {},
 	"gettxout":              {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
@@ -328,13 +364,72 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *ulordjson.RPCError {
 // getblocktemplate.
 type gbtWorkState struct {
 	sync.Mutex
-	lastTxUpdate  time.Time
-	lastGenerated time.Time
-	prevHash      *chainhash.Hash
-	minTimestamp  time.Time
-	template      *mining.BlockTemplate
-	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
-	timeSource    blockchain.MedianTimeSource
+	lastTxUpdate    time.Time
+	lastTxExtend    time.Time
+	lastGenerated   time.Time
+	lastRegenerated time.Time
+	prevHash        *chainhash.Hash
+	minTimestamp    time.Time
+	template        *mining.BlockTemplate
+	notifyMap       map[chainhash.Hash]map[int64]chan struct{}
+	notifyTimer     *time.Timer
+	timeSource      blockchain.MedianTimeSource
+
+	// txResults and txIndex cache the template result transactions and
+	// the indices of all transactions of the current block template so
+	// only transactions added by extending the template need to be
+	// converted.
+	txResults []ulordjson.GetBlockTemplateResultTx
+	txIndex   map[chainhash.Hash]int64
+}
+
+// gbtClientCaps houses the capabilities a caller of getblocktemplate reported
+// which determine the contents of the returned block templates.
+type gbtClientCaps struct {
+	// useCoinbaseValue indicates the caller creates its own coinbase and
+	// only needs the coinbase value as opposed to a full coinbase
+	// transaction.
+	useCoinbaseValue bool
+
+	// longPoll indicates the caller supports long polling for block
+	// template updates.
+	longPoll bool
+
+	// workID indicates the caller supports work IDs.
+	workID bool
+}
+
+// parseGBTClientCaps returns the capabilities reported by the caller of
+// getblocktemplate.  Callers which don't report any capabilities default to
+// only being provided a coinbase value along with the long poll ID.
+func parseGBTClientCaps(request *ulordjson.TemplateRequest) gbtClientCaps {
+	caps := gbtClientCaps{useCoinbaseValue: true, longPoll: true}
+	if request == nil || len(request.Capabilities) == 0 {
+		return caps
+	}
+
+	var hasCoinbaseValue, hasCoinbaseTxn bool
+	caps.longPoll = false
+	for _, capability := range request.Capabilities {
+		switch capability {
+		case "coinbasetxn":
+			hasCoinbaseTxn = true
+		case "coinbasevalue":
+			hasCoinbaseValue = true
+		case "longpoll":
+			caps.longPoll = true
+		case "workid":
+			caps.workID = true
+		}
+	}
+
+	// Restrict the result to either a coinbase value or a coinbase
+	// transaction object depending on the request.
+	if hasCoinbaseTxn && !hasCoinbaseValue {
+		caps.useCoinbaseValue = false
+	}
+
+	return caps
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
@@ -359,6 +454,31 @@ func handleAskWallet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (
 	return nil, ErrRPCNoWallet
 }
 
+// handleAddCheckpoint implements the addcheckpoint command.
+func handleAddCheckpoint(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.AddCheckpointCmd)
+	if cfg.DisableCheckpoints {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Checkpoints are disabled by --nocheckpoints",
+		}
+	}
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+
+	checkpoint := chaincfg.Checkpoint{Height: c.Height, Hash: hash}
+	err = s.cfg.Chain.AddCheckpoints([]chaincfg.Checkpoint{checkpoint})
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	return nil, nil
+}
+
 // handleAddNode handles addnode commands.
 func handleAddNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*ulordjson.AddNodeCmd)
@@ -782,7 +902,7 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	if len(hexStr)%2 != 0 {
 		hexStr = "0" + hexStr
 	}
-	serializedTx, err := hex.DecodeString(hexStr)
+	serializedTx, err := ulordutil.DecodeInto(nil, hexStr)
 	if err != nil {
 		return nil, rpcDecodeHexError(hexStr)
 	}
@@ -854,6 +974,192 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleDeriveAddresses implements the deriveaddresses command.
+func handleDeriveAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.DeriveAddressesCmd)
+
+	// Unlike getdescriptorinfo, the checksum is required since the derived
+	// addresses are likely to receive funds.
+	if !strings.Contains(c.Descriptor, "#") {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+			Message: "Missing checksum",
+		}
+	}
+	desc, err := descriptor.Parse(c.Descriptor, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid descriptor: " + err.Error(),
+		}
+	}
+
+	// Determine the range of child indexes to derive.  Descriptors which
+	// are not ranged only describe a single script.
+	begin, end := 0, 0
+	switch {
+	case desc.IsRange() && c.Range == nil:
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: "Range must be specified for a ranged descriptor",
+		}
+	case !desc.IsRange() && c.Range != nil:
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: "Range should not be specified for an un-ranged descriptor",
+		}
+	case c.Range != nil:
+		r := *c.Range
+		switch len(r) {
+		case 1:
+			end = r[0]
+		case 2:
+			begin, end = r[0], r[1]
+		default:
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidParameter,
+				Message: "Range must be a number or a pair of numbers",
+			}
+		}
+	}
+	if begin < 0 || end < begin {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: "Range must be non-negative and ascending",
+		}
+	}
+	if end-begin >= maxDescriptorRange {
+		return nil, &ulordjson.RPCError{
+			Code: ulordjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Range is too large, at most %d "+
+				"addresses may be derived", maxDescriptorRange),
+		}
+	}
+
+	addresses := make([]string, 0, end-begin+1)
+	for index := begin; index <= end; index++ {
+		addr, err := desc.Address(uint32(index))
+		if err == descriptor.ErrNoAddress {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+				Message: "Descriptor does not have a corresponding address",
+			}
+		}
+		if err != nil {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+				Message: "Unable to derive address: " + err.Error(),
+			}
+		}
+		addresses = append(addresses, addr.EncodeAddress())
+	}
+	return addresses, nil
+}
+
+// handleBackupChainState handles backupchainstate commands.
+func handleBackupChainState(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.BackupChainStateCmd)
+
+	// Relative paths are relative to the data directory.  The backup is
+	// written to a temporary file first so an interrupted backup doesn't
+	// leave a partial backup behind.
+	path := c.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.DataDir, path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("%s already exists", path),
+		}
+	}
+	tmpPath := path + ".incomplete"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	err = s.cfg.DB.Backup(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	var size int64
+	if err == nil {
+		var fi os.FileInfo
+		fi, err = f.Stat()
+		if fi != nil {
+			size = fi.Size()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		context := "Failed to write the backup"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &ulordjson.BackupChainStateResult{
+		Path: path,
+		Size: size,
+	}, nil
+}
+
+// handleDumpTxOutSet handles dumptxoutset commands.
+func handleDumpTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.DumpTxOutSetCmd)
+
+	// Relative paths are relative to the data directory.  The snapshot is
+	// written to a temporary file first so an interrupted dump doesn't
+	// leave a partial snapshot behind.
+	path := c.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.DataDir, path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("%s already exists", path),
+		}
+	}
+	tmpPath := path + ".incomplete"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	info, err := s.cfg.Chain.WriteUtxoSnapshot(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		context := "Failed to write the utxo snapshot"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &ulordjson.DumpTxOutSetResult{
+		CoinsWritten: info.NumUtxos,
+		BaseHash:     info.Hash.String(),
+		BaseHeight:   info.Height,
+		Path:         path,
+		TxOutSetHash: info.Commitment.String(),
+	}, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*ulordjson.EstimateFeeCmd)
@@ -876,6 +1182,50 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget <= 0 {
+		return nil, errors.New("Parameter ConfTarget must be positive")
+	}
+
+	mode := mempool.EstimateConservative
+	if c.EstimateMode != nil {
+		switch *c.EstimateMode {
+		case ulordjson.EstimateModeUnset, ulordjson.EstimateModeConservative:
+		case ulordjson.EstimateModeEconomical:
+			mode = mempool.EstimateEconomical
+		default:
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidParameter,
+				Message: "invalid estimate mode",
+			}
+		}
+	}
+
+	// Errors estimating the fee are reported in the result rather than
+	// failing the request.
+	estimate, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		uint32(c.ConfTarget), mode)
+	if err != nil {
+		return &ulordjson.EstimateSmartFeeResult{
+			Errors: []string{err.Error()},
+			Blocks: c.ConfTarget,
+		}, nil
+	}
+
+	feeRate := float64(estimate.FeeRate)
+	return &ulordjson.EstimateSmartFeeResult{
+		FeeRate: &feeRate,
+		Blocks:  int64(estimate.Blocks),
+	}, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
@@ -1020,6 +1370,146 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// addrUtxoIndexAddress returns the address utxo index along with the decoded
+// passed address.  An error is returned when the index is not enabled or the
+// address is invalid.
+func addrUtxoIndexAddress(s *rpcServer, address string) (*indexers.AddrUtxoIndex, ulordutil.Address, error) {
+	// Respond with an error if the address utxo index is not enabled.
+	addrUtxoIndex := s.cfg.AddrUtxoIndex
+	if addrUtxoIndex == nil {
+		return nil, nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Address utxo index must be enabled (--addrutxoindex)",
+		}
+	}
+
+	addr, err := ulordutil.DecodeAddress(address, s.cfg.ChainParams)
+	if err != nil {
+		return nil, nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	return addrUtxoIndex, addr, nil
+}
+
+// addrUtxoIndexPage returns the number of entries to skip and the number of
+// entries requested from the passed optional parameters of the address utxo
+// index commands.  It follows the searchrawtransactions command by defaulting
+// to 100 entries, treating a negative count as 1, and treating a negative skip
+// as 0.
+func addrUtxoIndexPage(skip, count *int) (uint32, uint32) {
+	numRequested := 100
+	if count != nil {
+		numRequested = *count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+
+	var numToSkip int
+	if skip != nil {
+		numToSkip = *skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+	return uint32(numToSkip), uint32(numRequested)
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.GetAddressBalanceCmd)
+	addrUtxoIndex, addr, err := addrUtxoIndexAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := addrUtxoIndex.Balance(addr)
+	if err != nil {
+		context := "Failed to load address balance"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return &ulordjson.GetAddressBalanceResult{
+		Balance:  balance.Balance,
+		Received: balance.Received,
+	}, nil
+}
+
+// handleGetAddressDeltas implements the getaddressdeltas command.
+func handleGetAddressDeltas(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.GetAddressDeltasCmd)
+	addrUtxoIndex, addr, err := addrUtxoIndexAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	numToSkip, numRequested := addrUtxoIndexPage(c.Skip, c.Count)
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+	deltas, err := addrUtxoIndex.Deltas(addr, numToSkip, numRequested,
+		reverse)
+	if err != nil {
+		context := "Failed to load address deltas"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]ulordjson.GetAddressDeltasResult, 0, len(deltas))
+	for i := range deltas {
+		delta := &deltas[i]
+		results = append(results, ulordjson.GetAddressDeltasResult{
+			Address:    c.Address,
+			TxID:       delta.Hash.String(),
+			Height:     delta.Height,
+			BlockIndex: delta.TxIndex,
+			Index:      delta.Index,
+			Spending:   delta.Input,
+			Satoshis:   delta.Amount,
+		})
+	}
+	return results, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.GetAddressUtxosCmd)
+	addrUtxoIndex, addr, err := addrUtxoIndexAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// All outputs pay to the same address, so they share the script.
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		context := "Failed to generate pay-to-address script"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	numToSkip, numRequested := addrUtxoIndexPage(c.Skip, c.Count)
+	utxos, err := addrUtxoIndex.Utxos(addr, numToSkip, numRequested)
+	if err != nil {
+		context := "Failed to load address utxos"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	script := hex.EncodeToString(pkScript)
+	results := make([]ulordjson.GetAddressUtxosResult, 0, len(utxos))
+	for i := range utxos {
+		utxo := &utxos[i]
+		results = append(results, ulordjson.GetAddressUtxosResult{
+			Address:     c.Address,
+			TxID:        utxo.OutPoint.Hash.String(),
+			OutputIndex: utxo.OutPoint.Index,
+			Script:      script,
+			Satoshis:    utxo.Amount,
+			Height:      utxo.Height,
+		})
+	}
+	return results, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1059,6 +1549,22 @@ func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
 	return diff
 }
 
+// rpcBlockNotFoundError returns the error for a block which could not be loaded
+// from the database.  Blocks which are known, but whose data has been pruned,
+// are reported as such rather than as unknown blocks.
+func rpcBlockNotFoundError(chain *blockchain.BlockChain, hash *chainhash.Hash) *ulordjson.RPCError {
+	if chain.IsBlockPruned(hash) {
+		return &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Block not available (pruned data)",
+		}
+	}
+	return &ulordjson.RPCError{
+		Code:    ulordjson.ErrRPCBlockNotFound,
+		Message: "Block not found",
+	}
+}
+
 // handleGetBlock implements the getblock command.
 func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*ulordjson.GetBlockCmd)
@@ -1075,16 +1581,13 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		return err
 	})
 	if err != nil {
-		return nil, &ulordjson.RPCError{
-			Code:    ulordjson.ErrRPCBlockNotFound,
-			Message: "Block not found",
-		}
+		return nil, rpcBlockNotFoundError(s.cfg.Chain, hash)
 	}
 
 	// When the verbose flag isn't set, simply return the serialized block
 	// as a hex-encoded string.
 	if c.Verbose != nil && !*c.Verbose {
-		return hex.EncodeToString(blkBytes), nil
+		return ulordutil.EncodeToStringBuf(blkBytes), nil
 	}
 
 	// The verbose flag is set, so generate the JSON object and return it.
@@ -1196,9 +1699,12 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		BestBlockHash: chainSnapshot.Hash.String(),
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
-		Pruned:        false,
+		Pruned:        chain.IsPruned(),
 		Bip9SoftForks: make(map[string]*ulordjson.Bip9SoftForkDescription),
 	}
+	if chainInfo.Pruned {
+		chainInfo.PruneHeight = chain.PruneHeight()
+	}
 
 	// Next, populate the response with information describing the current
 	// status of soft-forks deployed via the super-majority block
@@ -1239,18 +1745,8 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	for deployment, deploymentDetails := range params.Deployments {
 		// Map the integer deployment ID into a human readable
 		// fork-name.
-		var forkName string
-		switch deployment {
-		case chaincfg.DeploymentTestDummy:
-			forkName = "dummy"
-
-		case chaincfg.DeploymentCSV:
-			forkName = "csv"
-
-		case chaincfg.DeploymentSegwit:
-			forkName = "segwit"
-
-		default:
+		forkName := chaincfg.DeploymentName(uint32(deployment))
+		if forkName == "" {
 			return nil, &ulordjson.RPCError{
 				Code: ulordjson.ErrRPCInternal.Code,
 				Message: fmt.Sprintf("Unknown deployment %v "+
@@ -1259,8 +1755,10 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		}
 
 		// Query the chain for the current status of the deployment as
-		// identified by its deployment ID.
-		deploymentStatus, err := chain.ThresholdState(uint32(deployment))
+		// identified by its deployment ID along with the height it
+		// was reached at.
+		deploymentStatus, since, err := chain.ThresholdStateSince(
+			uint32(deployment))
 		if err != nil {
 			context := "Failed to obtain deployment status"
 			return nil, internalRPCError(err.Error(), context)
@@ -1285,6 +1783,7 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 			Bit:       deploymentDetails.BitNumber,
 			StartTime: int64(deploymentDetails.StartTime),
 			Timeout:   int64(deploymentDetails.ExpireTime),
+			Since:     since,
 		}
 	}
 
@@ -1311,6 +1810,31 @@ func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return hash.String(), nil
 }
 
+// handleGetBlockHashes implements the getblockhashes command.
+func handleGetBlockHashes(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the timestamp index is not enabled.
+	timestampIndex := s.cfg.TimestampIndex
+	if timestampIndex == nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Timestamp index must be enabled (--timestampindex)",
+		}
+	}
+
+	c := cmd.(*ulordjson.GetBlockHashesCmd)
+	hashes, err := timestampIndex.BlockHashes(c.High, c.Low)
+	if err != nil {
+		context := "Failed to load block hashes"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	hashStrings := make([]string, 0, len(hashes))
+	for i := range hashes {
+		hashStrings = append(hashStrings, hashes[i].String())
+	}
+	return hashStrings, nil
+}
+
 // handleGetBlockHeader implements the getblockheader command.
 func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*ulordjson.GetBlockHeaderCmd)
@@ -1376,7 +1900,136 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
 		Difficulty:    getDifficultyRatio(blockHeader.Bits, params),
 	}
-	return blockHeaderReply, nil
+	return blockHeaderReply, nil
+}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.GetBlockStatsCmd)
+
+	// Look up the block by either its height or its hash.  Only blocks in
+	// the main chain have stats.
+	var hash *chainhash.Hash
+	hashOrHeight := string(c.HashOrHeight)
+	if height, err := strconv.ParseInt(hashOrHeight, 10, 32); err == nil {
+		hash, err = s.cfg.Chain.BlockHashByHeight(int32(height))
+		if err != nil {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCOutOfRange,
+				Message: "Block number out of range",
+			}
+		}
+	} else {
+		hash, err = chainhash.NewHashFromStr(hashOrHeight)
+		if err != nil {
+			return nil, rpcDecodeHexError(hashOrHeight)
+		}
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCBlockNotFound,
+				Message: "Block is not in the main chain",
+			}
+		}
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(hash)
+	if err != nil {
+		context := "Failed to obtain block height"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	header, err := s.cfg.Chain.HeaderByHash(hash)
+	if err != nil {
+		context := "Failed to obtain block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Use the stats from the block stats index when it is enabled and
+	// otherwise compute them from the block and its spend journal.
+	var stats *indexers.BlockStats
+	if s.cfg.BlockStatsIndex != nil {
+		stats, err = s.cfg.BlockStatsIndex.BlockStats(hash)
+		if err != nil {
+			context := "Failed to load block stats"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+	if stats == nil {
+		block, err := s.cfg.Chain.BlockByHash(hash)
+		if err != nil {
+			return nil, rpcBlockNotFoundError(s.cfg.Chain, hash)
+		}
+		stxos, err := s.cfg.Chain.FetchSpendJournal(block)
+		if err != nil {
+			context := "Failed to load spend journal"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		stats, err = indexers.CalcBlockStats(block, stxos)
+		if err != nil {
+			context := "Failed to compute block stats"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+
+	result := &ulordjson.GetBlockStatsResult{
+		Hash:               hash.String(),
+		FeeRatePercentiles: stats.FeeRatePercentiles[:],
+		Height:             height,
+		Ins:                stats.Ins,
+		MaxFee:             stats.MaxFee,
+		MaxFeeRate:         stats.MaxFeeRate,
+		MaxTxSize:          stats.MaxTxSize,
+		MedianFee:          stats.MedianFee,
+		MedianTxSize:       stats.MedianTxSize,
+		MinFee:             stats.MinFee,
+		MinFeeRate:         stats.MinFeeRate,
+		MinTxSize:          stats.MinTxSize,
+		Outs:               stats.Outs,
+		Subsidy:            blockchain.CalcBlockSubsidy(height, s.cfg.ChainParams),
+		SegWitTotalSize:    stats.SwTotalSize,
+		SegWitTotalWeight:  stats.SwTotalWeight,
+		SegWitTxs:          stats.SwTxs,
+		Time:               header.Timestamp.Unix(),
+		TotalOut:           stats.TotalOut,
+		TotalSize:          stats.TotalSize,
+		TotalWeight:        stats.TotalWeight,
+		TotalFee:           stats.TotalFee,
+		Txs:                stats.Txs,
+		UtxoIncrease:       stats.UtxoIncrease,
+		UtxoSizeIncrease:   stats.UtxoSizeInc,
+	}
+	if numTxns := stats.Txs - 1; numTxns > 0 {
+		result.AverageFee = stats.TotalFee / numTxns
+		result.AverageTxSize = stats.TotalSize / numTxns
+	}
+	if vsize := stats.TotalWeight / blockchain.WitnessScaleFactor; vsize > 0 {
+		result.AverageFeeRate = stats.TotalFee / vsize
+	}
+	if c.Stats == nil || len(*c.Stats) == 0 {
+		return result, nil
+	}
+
+	// Only return the requested stats.
+	marshalled, err := json.Marshal(result)
+	if err != nil {
+		context := "Failed to marshal block stats"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	var allStats map[string]json.RawMessage
+	if err := json.Unmarshal(marshalled, &allStats); err != nil {
+		context := "Failed to unmarshal block stats"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	selected := make(map[string]json.RawMessage, len(*c.Stats))
+	for _, name := range *c.Stats {
+		stat, ok := allStats[name]
+		if !ok {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid selected statistic %s", name),
+			}
+		}
+		selected[name] = stat
+	}
+	return selected, nil
 }
 
 // encodeTemplateID encodes the passed details into an ID that can be used to
@@ -1483,10 +2136,26 @@ func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
 			return
 		}
 
-		if time.Now().After(state.lastGenerated.Add(time.Second *
-			gbtRegenerateSeconds)) {
-
+		regenerateTime := state.lastRegenerated.Add(time.Second *
+			gbtRegenerateSeconds)
+		if time.Now().After(regenerateTime) {
 			state.notifyLongPollers(state.prevHash, lastUpdated)
+			return
+		}
+
+		// Schedule the notification for when a new block template is
+		// allowed to be generated so long poll clients are not left
+		// waiting until yet another transaction arrives.
+		if state.notifyTimer == nil {
+			state.notifyTimer = time.AfterFunc(time.Until(
+				regenerateTime), func() {
+
+				state.Lock()
+				state.notifyTimer = nil
+				state.Unlock()
+
+				state.NotifyMempoolTx(lastUpdated)
+			})
 		}
 	}()
 }
@@ -1549,7 +2218,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	if template == nil || state.prevHash == nil ||
 		!state.prevHash.IsEqual(latestHash) ||
 		(state.lastTxUpdate != lastTxUpdate &&
-			time.Now().After(state.lastGenerated.Add(time.Second*
+			time.Now().After(state.lastRegenerated.Add(time.Second*
 				gbtRegenerateSeconds))) {
 
 		// Reset the previous best hash the block template was generated
@@ -1590,9 +2259,13 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// generated until needed.
 		state.template = template
 		state.lastGenerated = time.Now()
+		state.lastRegenerated = state.lastGenerated
 		state.lastTxUpdate = lastTxUpdate
+		state.lastTxExtend = lastTxUpdate
 		state.prevHash = latestHash
 		state.minTimestamp = minTimestamp
+		state.txResults = nil
+		state.txIndex = nil
 
 		rpcsLog.Debugf("Generated block template (timestamp %v, "+
 			"target %s, merkle root %s)",
@@ -1609,6 +2282,26 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// trigger a new block template to be generated.  So, update the
 		// existing block template.
 
+		// Extend the block template with any transactions that were
+		// added to the memory pool since it was last updated rather
+		// than waiting for a new one to be generated.  A failure to do
+		// so is not fatal since the existing template is still valid.
+		if state.lastTxExtend != lastTxUpdate {
+			numAdded, err := generator.ExtendBlockTemplate(template)
+			if err != nil {
+				rpcsLog.Debugf("Unable to extend block template: %v",
+					err)
+			}
+			state.lastTxExtend = lastTxUpdate
+			if numAdded > 0 {
+				// Update the time the template was generated so
+				// it is identified by a new long poll ID.
+				state.lastGenerated = time.Now()
+				state.notifyLongPollers(latestHash,
+					lastTxUpdate)
+			}
+		}
+
 		// When the caller requires a full coinbase as opposed to only
 		// the pertinent details needed to create their own coinbase,
 		// add a payment address to the output of the coinbase of the
@@ -1654,45 +2347,29 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	return nil
 }
 
-// blockTemplateResult returns the current block template associated with the
-// state as a ulordjson.GetBlockTemplateResult that is ready to be encoded to JSON
-// and returned to the caller.
+// templateTxResults returns the template result transactions for all but the
+// coinbase transaction of the current block template.  The results are cached
+// so only the transactions which were added to the template since the previous
+// invocation are converted.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*ulordjson.GetBlockTemplateResult, error) {
-	// Ensure the timestamps are still in valid range for the template.
-	// This should really only ever happen if the local clock is changed
-	// after the template is generated, but it's important to avoid serving
-	// invalid block templates.
+func (state *gbtWorkState) templateTxResults() ([]ulordjson.GetBlockTemplateResultTx, error) {
 	template := state.template
 	msgBlock := template.Block
-	header := &msgBlock.Header
-	adjustedTime := state.timeSource.AdjustedTime()
-	maxTime := adjustedTime.Add(time.Second * blockchain.MaxTimeOffsetSeconds)
-	if header.Timestamp.After(maxTime) {
-		return nil, &ulordjson.RPCError{
-			Code: ulordjson.ErrRPCOutOfRange,
-			Message: fmt.Sprintf("The template time is after the "+
-				"maximum allowed time for a block - template "+
-				"time %v, maximum time %v", adjustedTime,
-				maxTime),
-		}
+	numTx := len(msgBlock.Transactions)
+	if state.txIndex == nil {
+		state.txResults = make([]ulordjson.GetBlockTemplateResultTx, 0,
+			numTx-1)
+		state.txIndex = make(map[chainhash.Hash]int64, numTx)
 	}
 
 	// Convert each transaction in the block template to a template result
 	// transaction.  The result does not include the coinbase, so notice
 	// the adjustments to the various lengths and indices.
-	numTx := len(msgBlock.Transactions)
-	transactions := make([]ulordjson.GetBlockTemplateResultTx, 0, numTx-1)
-	txIndex := make(map[chainhash.Hash]int64, numTx)
-	for i, tx := range msgBlock.Transactions {
+	for i := len(state.txResults) + 1; i < numTx; i++ {
+		tx := msgBlock.Transactions[i]
 		txHash := tx.TxHash()
-		txIndex[txHash] = int64(i)
-
-		// Skip the coinbase transaction.
-		if i == 0 {
-			continue
-		}
+		state.txIndex[txHash] = int64(i)
 
 		// Create an array of 1-based indices to transactions that come
 		// before this one in the transactions list which this one
@@ -1702,7 +2379,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		// when multiple inputs reference the same transaction.
 		dependsMap := make(map[int64]struct{})
 		for _, txIn := range tx.TxIn {
-			if idx, ok := txIndex[txIn.PreviousOutPoint.Hash]; ok {
+			if idx, ok := state.txIndex[txIn.PreviousOutPoint.Hash]; ok {
 				dependsMap[idx] = struct{}{}
 			}
 		}
@@ -1727,7 +2404,41 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 			SigOps:  template.SigOpCosts[i],
 			Weight:  blockchain.GetTransactionWeight(bTx),
 		}
-		transactions = append(transactions, resultTx)
+		state.txResults = append(state.txResults, resultTx)
+	}
+
+	return state.txResults, nil
+}
+
+// blockTemplateResult returns the current block template associated with the
+// state as a ulordjson.GetBlockTemplateResult that is ready to be encoded to JSON
+// and returned to the caller.  The contents depend on the capabilities reported
+// by the caller.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) blockTemplateResult(caps gbtClientCaps, submitOld *bool) (*ulordjson.GetBlockTemplateResult, error) {
+	// Ensure the timestamps are still in valid range for the template.
+	// This should really only ever happen if the local clock is changed
+	// after the template is generated, but it's important to avoid serving
+	// invalid block templates.
+	template := state.template
+	msgBlock := template.Block
+	header := &msgBlock.Header
+	adjustedTime := state.timeSource.AdjustedTime()
+	maxTime := adjustedTime.Add(time.Second * blockchain.MaxTimeOffsetSeconds)
+	if header.Timestamp.After(maxTime) {
+		return nil, &ulordjson.RPCError{
+			Code: ulordjson.ErrRPCOutOfRange,
+			Message: fmt.Sprintf("The template time is after the "+
+				"maximum allowed time for a block - template "+
+				"time %v, maximum time %v", adjustedTime,
+				maxTime),
+		}
+	}
+
+	transactions, err := state.templateTxResults()
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate the block template reply.  Note that following mutations are
@@ -1746,7 +2457,6 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		SizeLimit:    wire.MaxBlockPayload,
 		Transactions: transactions,
 		Version:      header.Version,
-		LongPollID:   templateID,
 		SubmitOld:    submitOld,
 		Target:       targetDifficulty,
 		MinTime:      state.minTimestamp.Unix(),
@@ -1755,13 +2465,20 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		NonceRange:   gbtNonceRange,
 		Capabilities: gbtCapabilities,
 	}
+	if caps.longPoll {
+		reply.LongPollID = templateID
+	}
+	if caps.workID {
+		reply.WorkID = templateID
+	}
+
 	// If the generated block template includes transactions with witness
 	// data, then include the witness commitment in the GBT result.
 	if template.WitnessCommitment != nil {
 		reply.DefaultWitnessCommitment = hex.EncodeToString(template.WitnessCommitment)
 	}
 
-	if useCoinbaseValue {
+	if caps.useCoinbaseValue {
 		reply.CoinbaseAux = gbtCoinbaseAux
 		reply.CoinbaseValue = &msgBlock.Transactions[0].TxOut[0].Value
 	} else {
@@ -1809,14 +2526,14 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 // has passed without finding a solution.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, caps gbtClientCaps, closeChan <-chan struct{}) (interface{}, error) {
 	state := s.gbtWorkState
 	state.Lock()
 	// The state unlock is intentionally not deferred here since it needs to
 	// be manually unlocked before waiting for a notification about block
 	// template changes.
 
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	if err := state.updateBlockTemplate(s, caps.useCoinbaseValue); err != nil {
 		state.Unlock()
 		return nil, err
 	}
@@ -1825,7 +2542,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// the caller is invalid.
 	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
 	if err != nil {
-		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+		result, err := state.blockTemplateResult(caps, nil)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1846,7 +2563,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		// old block template depending on whether or not a solution has
 		// already been found and added to the block chain.
 		submitOld := prevHash.IsEqual(prevTemplateHash)
-		result, err := state.blockTemplateResult(useCoinbaseValue,
+		result, err := state.blockTemplateResult(caps,
 			&submitOld)
 		if err != nil {
 			state.Unlock()
@@ -1879,7 +2596,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	state.Lock()
 	defer state.Unlock()
 
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	if err := state.updateBlockTemplate(s, caps.useCoinbaseValue); err != nil {
 		return nil, err
 	}
 
@@ -1887,7 +2604,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// block template depending on whether or not a solution has already
 	// been found and added to the block chain.
 	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
-	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+	result, err := state.blockTemplateResult(caps, &submitOld)
 	if err != nil {
 		return nil, err
 	}
@@ -1900,32 +2617,15 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 // handles both long poll requests as specified by BIP 0022 as well as regular
 // requests.  In addition, it detects the capabilities reported by the caller
 // in regards to whether or not it supports creating its own coinbase (the
-// coinbasetxn and coinbasevalue capabilities) and modifies the returned block
-// template accordingly.
+// coinbasetxn and coinbasevalue capabilities), long polling, and work IDs and
+// modifies the returned block template accordingly.
 func handleGetBlockTemplateRequest(s *rpcServer, request *ulordjson.TemplateRequest, closeChan <-chan struct{}) (interface{}, error) {
-	// Extract the relevant passed capabilities and restrict the result to
-	// either a coinbase value or a coinbase transaction object depending on
-	// the request.  Default to only providing a coinbase value.
-	useCoinbaseValue := true
-	if request != nil {
-		var hasCoinbaseValue, hasCoinbaseTxn bool
-		for _, capability := range request.Capabilities {
-			switch capability {
-			case "coinbasetxn":
-				hasCoinbaseTxn = true
-			case "coinbasevalue":
-				hasCoinbaseValue = true
-			}
-		}
-
-		if hasCoinbaseTxn && !hasCoinbaseValue {
-			useCoinbaseValue = false
-		}
-	}
+	// Extract the relevant passed capabilities.
+	caps := parseGBTClientCaps(request)
 
 	// When a coinbase transaction has been requested, respond with an error
 	// if there are no addresses to pay the created block template to.
-	if !useCoinbaseValue && len(cfg.miningAddrs) == 0 {
+	if !caps.useCoinbaseValue && len(cfg.miningAddrs) == 0 {
 		return nil, &ulordjson.RPCError{
 			Code: ulordjson.ErrRPCInternal.Code,
 			Message: "A coinbase transaction has been requested, " +
@@ -1960,8 +2660,9 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *ulordjson.TemplateRequ
 	// client to be notified when block template referenced by the ID should
 	// be replaced with a new one.
 	if request != nil && request.LongPollID != "" {
+		caps.longPoll = true
 		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeChan)
+			caps, closeChan)
 	}
 
 	// Protect concurrent access when updating block templates.
@@ -1975,10 +2676,10 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *ulordjson.TemplateRequ
 	// seconds since the last template was generated.  Otherwise, the
 	// timestamp for the existing block template is updated (and possibly
 	// the difficulty on testnet per the consesus rules).
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	if err := state.updateBlockTemplate(s, caps.useCoinbaseValue); err != nil {
 		return nil, err
 	}
-	return state.blockTemplateResult(useCoinbaseValue, nil)
+	return state.blockTemplateResult(caps, nil)
 }
 
 // chainErrToGBTErrString converts an error returned from btcchain to a string
@@ -2120,10 +2821,30 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *ulordjson.TemplateReq
 	}
 	block := ulordutil.NewBlock(&msgBlock)
 
-	// Ensure the block is building from the expected previous block.
+	// There is no need to validate blocks which are already known.
+	haveBlock, err := s.cfg.Chain.HaveBlock(block.Hash())
+	if err != nil {
+		context := "Failed to check for block proposal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if haveBlock {
+		return "duplicate", nil
+	}
+
+	// Ensure the block is building from the expected previous block.  The
+	// validity of blocks which build from another known block can't be
+	// determined since they are only checked against the best chain.
 	expectedPrevHash := s.cfg.Chain.BestSnapshot().Hash
 	prevHash := &block.MsgBlock().Header.PrevBlock
 	if !expectedPrevHash.IsEqual(prevHash) {
+		havePrev, err := s.cfg.Chain.HaveBlock(prevHash)
+		if err != nil {
+			context := "Failed to check for block proposal parent"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if havePrev {
+			return "inconclusive-not-best-prevblk", nil
+		}
 		return "bad-prevblk", nil
 	}
 
@@ -2231,6 +2952,21 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hash.String(), nil
 }
 
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	tips := s.cfg.Chain.ChainTips()
+	results := make([]ulordjson.GetChainTipsResult, 0, len(tips))
+	for _, tip := range tips {
+		results = append(results, ulordjson.GetChainTipsResult{
+			Height:    tip.Height,
+			Hash:      tip.Hash.String(),
+			BranchLen: tip.BranchLen,
+			Status:    ulordjson.ChainTipStatus(tip.Status.String()),
+		})
+	}
+	return results, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2241,6 +2977,34 @@ func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return s.cfg.ChainParams.Net, nil
 }
 
+// handleGetDescriptorInfo implements the getdescriptorinfo command.
+func handleGetDescriptorInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.GetDescriptorInfoCmd)
+
+	desc, err := descriptor.Parse(c.Descriptor, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid descriptor: " + err.Error(),
+		}
+	}
+
+	// The checksum is the one of the passed descriptor, which may include
+	// private keys, rather than the one of the returned descriptor.
+	checksum, err := descriptor.Checksum(strings.SplitN(c.Descriptor, "#", 2)[0])
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to compute checksum")
+	}
+
+	return &ulordjson.GetDescriptorInfoResult{
+		Descriptor:     desc.String(),
+		Checksum:       checksum,
+		IsRange:        desc.IsRange(),
+		IsSolvable:     desc.IsSolvable(),
+		HasPrivateKeys: desc.HasPrivateKeys(),
+	}, nil
+}
+
 // handleGetDifficulty implements the getdifficulty command.
 func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
@@ -2326,9 +3090,28 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	unbroadcast := s.cfg.ConnMgr.UnbroadcastTransactions()
+	unbroadcastTxs := make([]string, 0, len(unbroadcast))
+	for _, tx := range unbroadcast {
+		unbroadcastTxs = append(unbroadcastTxs, tx.Desc.Tx.Hash().String())
+	}
+
+	maxMempool := int64(cfg.MaxMempool) * 1000000
+	if maxMempool <= 0 {
+		maxMempool = mempool.DefaultMaxPoolSize
+	}
+
+	// The saved pool is loaded while the server is created, so it has
+	// always been loaded by the time the RPC server is running.
 	ret := &ulordjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:             int64(len(mempoolTxns)),
+		Bytes:            numBytes,
+		MaxMempool:       maxMempool,
+		MempoolMinFee:    s.cfg.TxMemPool.MinFeeRate().ToBTC(),
+		MinRelayTxFee:    cfg.minRelayTxFee.ToBTC(),
+		UnbroadcastCount: int64(len(unbroadcastTxs)),
+		UnbroadcastTxs:   unbroadcastTxs,
+		Loaded:           true,
 	}
 
 	return ret, nil
@@ -2373,10 +3156,16 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.cfg.ConnMgr.NetTotals()
+	bytesRecvPerMsg, bytesSentPerMsg := s.cfg.ConnMgr.NetTotalsPerMsg()
+	uploadLimit, downloadLimit := s.cfg.ConnMgr.BandwidthLimits()
 	reply := &ulordjson.GetNetTotalsResult{
-		TotalBytesRecv: totalBytesRecv,
-		TotalBytesSent: totalBytesSent,
-		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		TotalBytesRecv:  totalBytesRecv,
+		TotalBytesSent:  totalBytesSent,
+		TimeMillis:      time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		BytesRecvPerMsg: bytesRecvPerMsg,
+		BytesSentPerMsg: bytesSentPerMsg,
+		UploadLimit:     uploadLimit,
+		DownloadLimit:   downloadLimit,
 	}
 	return reply, nil
 }
@@ -2503,12 +3292,28 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+
+			PingP50:         float64(statsSnap.PingMicrosP50),
+			PingP90:         float64(statsSnap.PingMicrosP90),
+			PingP99:         float64(statsSnap.PingMicrosP99),
+			BytesSentPerMsg: statsSnap.BytesSentPerMsg,
+			BytesRecvPerMsg: statsSnap.BytesRecvPerMsg,
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
 			// We actually want microseconds.
 			info.PingWait = wait / 1000
 		}
+		for _, record := range p.Misbehavior() {
+			info.Misbehavior = append(info.Misbehavior,
+				ulordjson.PeerMisbehaviorResult{
+					Offense:  record.Offense.String(),
+					Reason:   record.Reason,
+					Time:     record.Time.Unix(),
+					BanScore: int32(record.Score),
+					Action:   record.Action.String(),
+				})
+		}
 		infos = append(infos, info)
 	}
 	return infos, nil
@@ -2590,7 +3395,7 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 		// transaction as a hex-encoded string.  This is done here to
 		// avoid deserializing it only to reserialize it again later.
 		if !verbose {
-			return hex.EncodeToString(txBytes), nil
+			return ulordutil.EncodeToStringBuf(txBytes), nil
 		}
 
 		// Grab the block height.
@@ -2653,6 +3458,43 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the spent index is not enabled.
+	spentIndex := s.cfg.SpentIndex
+	if spentIndex == nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*ulordjson.GetSpentInfoCmd)
+	txHash, err := chainhash.NewHashFromStr(c.OutPoint.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.OutPoint.Txid)
+	}
+
+	outPoint := wire.OutPoint{Hash: *txHash, Index: c.OutPoint.Index}
+	info, err := spentIndex.SpentInfo(&outPoint)
+	if err != nil {
+		context := "Failed to load spent info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if info == nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidAddressOrKey,
+			Message: "Unable to get spent info",
+		}
+	}
+
+	return &ulordjson.GetSpentInfoResult{
+		Txid:   info.Hash.String(),
+		Index:  info.Index,
+		Height: info.Height,
+	}, nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*ulordjson.GetTxOutCmd)
@@ -2798,6 +3640,68 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// chainManipulationError returns the error for a failed attempt to invalidate
+// or reconsider the block with the passed hash.
+func chainManipulationError(err error, hash *chainhash.Hash, context string) error {
+	if _, ok := err.(blockchain.UnknownBlockError); ok {
+		return &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCBlockNotFound,
+			Message: fmt.Sprintf("Block not found: %v", hash),
+		}
+	}
+	return internalRPCError(err.Error(), context)
+}
+
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.InvalidateBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.cfg.Chain.InvalidateBlock(hash)
+	if err != nil {
+		return nil, chainManipulationError(err, hash,
+			"Failed to invalidate block")
+	}
+	return nil, nil
+}
+
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.ReconsiderBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.cfg.Chain.ReconsiderBlock(hash)
+	if err != nil {
+		return nil, chainManipulationError(err, hash,
+			"Failed to reconsider block")
+	}
+	return nil, nil
+}
+
+// handleReindex implements the reindex command.
+func handleReindex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.ReindexCmd)
+	var indexNames []string
+	if c.Indexes != nil {
+		indexNames = *c.Indexes
+	}
+
+	_, err := s.cfg.SyncMgr.Reindex(c.StartHeight, indexNames)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCMisc,
+			Message: "Failed to start reindex job: " + err.Error(),
+		}
+	}
+	return nil, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3192,7 +4096,7 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		// transaction is already in serialized form.
 		rtx := &addressTxns[i]
 		if rtx.txBytes != nil {
-			hexTxns[i] = hex.EncodeToString(rtx.txBytes)
+			hexTxns[i] = ulordutil.EncodeToStringBuf(rtx.txBytes)
 			continue
 		}
 
@@ -3304,7 +4208,7 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	if len(hexStr)%2 != 0 {
 		hexStr = "0" + hexStr
 	}
-	serializedTx, err := hex.DecodeString(hexStr)
+	serializedTx, err := ulordutil.DecodeInto(nil, hexStr)
 	if err != nil {
 		return nil, rpcDecodeHexError(hexStr)
 	}
@@ -3365,10 +4269,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	s.NotifyNewTransactions(acceptedTxs)
 
 	// Keep track of all the sendrawtransaction request txns so that they
-	// can be rebroadcast if they don't make their way into a block.
-	txD := acceptedTxs[0]
-	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
-	s.cfg.ConnMgr.AddRebroadcastInventory(iv, txD)
+	// can be rebroadcast if they don't reach the network.
+	s.cfg.ConnMgr.AddUnbroadcastTransaction(acceptedTxs[0])
 
 	return tx.Hash().String(), nil
 }
@@ -3409,6 +4311,40 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSetTrustedPeer handles settrustedpeer commands.
+func handleSetTrustedPeer(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.SetTrustedPeerCmd)
+
+	ipNet, err := parseIPNet(c.Addr)
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	switch c.SubCmd {
+	case "add":
+		err = s.cfg.SyncMgr.SetTrustedPeer(ipNet, true)
+	case "remove":
+		err = s.cfg.SyncMgr.SetTrustedPeer(ipNet, false)
+	default:
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for settrustedpeer",
+		}
+	}
+
+	if err != nil {
+		return nil, &ulordjson.RPCError{
+			Code:    ulordjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleStop implements the stop command.
 func handleStop(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	select {
@@ -3427,7 +4363,7 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	if len(hexStr)%2 != 0 {
 		hexStr = "0" + c.HexBlock
 	}
-	serializedBlock, err := hex.DecodeString(hexStr)
+	serializedBlock, err := ulordutil.DecodeInto(nil, hexStr)
 	if err != nil {
 		return nil, rpcDecodeHexError(hexStr)
 	}
@@ -3451,6 +4387,59 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*ulordjson.TestMempoolAcceptCmd)
+
+	// Deserialize all transactions before checking any of them.
+	txns := make([]*ulordutil.Tx, 0, len(c.RawTxns))
+	for _, hexStr := range c.RawTxns {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, ulordutil.NewTx(&msgTx))
+	}
+
+	// Check each transaction on its own against the current pool.  Since
+	// none of them are added to the pool, a transaction spending another
+	// one of them is reported as an orphan.
+	results := make([]ulordjson.TestMempoolAcceptResult, 0, len(txns))
+	for _, tx := range txns {
+		result := ulordjson.TestMempoolAcceptResult{
+			Txid: tx.Hash().String(),
+		}
+		info, err := s.cfg.TxMemPool.CheckAcceptance(tx)
+		if err != nil {
+			if _, ok := err.(mempool.RuleError); !ok {
+				rpcsLog.Errorf("Failed to check transaction %v: %v",
+					tx.Hash(), err)
+			}
+			result.RejectReason = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Allowed = true
+		result.Vsize = info.VirtualSize
+		result.Fee = ulordutil.Amount(info.Fee).ToBTC()
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3479,9 +4468,23 @@ func verifyChain(s *rpcServer, level, depth int32) error {
 	if finishHeight < 0 {
 		finishHeight = 0
 	}
+
+	// The data of blocks below the prune height is no longer available.
+	if pruneHeight := s.cfg.Chain.PruneHeight(); finishHeight < pruneHeight-1 {
+		finishHeight = pruneHeight - 1
+	}
 	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
 		best.Height-finishHeight, level)
 
+	// Level 4 checks the integrity of all stored blocks regardless of the
+	// depth.
+	if level >= 4 {
+		if err := s.cfg.DB.VerifyIntegrity(); err != nil {
+			rpcsLog.Errorf("Verify found corrupt block data: %v", err)
+			return err
+		}
+	}
+
 	for height := best.Height; height > finishHeight; height-- {
 		// Level 0 just looks up the block.
 		block, err := s.cfg.Chain.BlockByHeight(height)
@@ -3493,8 +4496,8 @@ func verifyChain(s *rpcServer, level, depth int32) error {
 
 		// Level 1 does basic chain sanity checks.
 		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				s.cfg.ChainParams.PowLimit, s.cfg.TimeSource)
+			err := blockchain.CheckBlockSanityParams(block,
+				s.cfg.ChainParams, s.cfg.TimeSource)
 			if err != nil {
 				rpcsLog.Errorf("Verify is unable to validate "+
 					"block at hash %v height %d: %v",
@@ -3546,45 +4549,17 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 		}
 	}
 
-	// Decode base64 signature.
-	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	// Validate the signature against the address.  Signatures that can't
+	// be recovered mirror Bitcoin Core behavior and are treated as invalid
+	// rather than as errors.
+	verified, err := ulordutil.VerifyMessage(addr, c.Signature, c.Message)
 	if err != nil {
 		return nil, &ulordjson.RPCError{
 			Code:    ulordjson.ErrRPCParse.Code,
-			Message: "Malformed base64 encoding: " + err.Error(),
+			Message: "Malformed base64 encoding of signature",
 		}
 	}
-
-	// Validate the signature - this just shows that it was valid at all.
-	// we will compare it with the key next.
-	var buf bytes.Buffer
-	wire.WriteVarString(&buf, 0, "Bitcoin Signed Message:\n")
-	wire.WriteVarString(&buf, 0, c.Message)
-	expectedMessageHash := chainhash.DoubleHashB(buf.Bytes())
-	pk, wasCompressed, err := ulordec.RecoverCompact(ulordec.S256(), sig,
-		expectedMessageHash)
-	if err != nil {
-		// Mirror Bitcoin Core behavior, which treats error in
-		// RecoverCompact as invalid signature.
-		return false, nil
-	}
-
-	// Reconstruct the pubkey hash.
-	var serializedPK []byte
-	if wasCompressed {
-		serializedPK = pk.SerializeCompressed()
-	} else {
-		serializedPK = pk.SerializeUncompressed()
-	}
-	address, err := ulordutil.NewAddressPubKey(serializedPK, params)
-	if err != nil {
-		// Again mirror Bitcoin Core behavior, which treats error in public key
-		// reconstruction as invalid signature.
-		return false, nil
-	}
-
-	// Return boolean if addresses match.
-	return address.EncodeAddress() == c.Address, nil
+	return verified, nil
 }
 
 // handleVersion implements the version command.
@@ -3607,9 +4582,9 @@ type rpcServer struct {
 	started                int32
 	shutdown               int32
 	cfg                    rpcserverConfig
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	users                  []*rpcUser
 	ntfnMgr                *wsNotificationManager
+	chainSub               *blockchain.Subscription
 	numClients             int32
 	statusLines            map[int]string
 	statusLock             sync.RWMutex
@@ -3618,6 +4593,13 @@ type rpcServer struct {
 	helpCacher             *helpCacher
 	requestProcessShutdown chan struct{}
 	quit                   chan int
+
+	// grpcServer serves the gRPC service while grpcBlocks and grpcTxs
+	// relay notifications to its subscribers.  They are only set when
+	// gRPC listeners are configured.
+	grpcServer *http.Server
+	grpcBlocks *grpcapi.Broadcaster
+	grpcTxs    *grpcapi.Broadcaster
 }
 
 // httpStatusLine returns a response Status-Line (RFC 2616 Section 6.1)
@@ -3689,6 +4671,13 @@ func (s *rpcServer) Stop() error {
 			return err
 		}
 	}
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Close(); err != nil {
+			rpcsLog.Errorf("Problem shutting down gRPC: %v", err)
+			return err
+		}
+	}
+	s.chainSub.Unsubscribe()
 	s.ntfnMgr.Shutdown()
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
@@ -3711,6 +4700,7 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	for _, txD := range txns {
 		// Notify websocket clients about mempool transactions.
 		s.ntfnMgr.NotifyMempoolTx(txD.Tx, true)
+		s.grpcNotifyTx(txD.Tx)
 
 		// Potentially notify any getblocktemplate long poll clients
 		// about stale block templates due to the new transaction.
@@ -3759,40 +4749,29 @@ func (s *rpcServer) decrementClients() {
 //
 // This check is time-constant.
 //
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
+// The bool return value signifies auth success (true if successful) and the
+// profile return value specifies the methods the user is allowed to call.  The
+// profile is always nil if authentication did not succeed.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, *rpcProfile, error) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
 		if require {
 			rpcsLog.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return false, nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return false, nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	user := s.authenticate(authhdr[0])
+	if user != nil {
+		return true, user.profile, nil
 	}
 
-	// Request's auth doesn't match either user
+	// Request's auth doesn't match any user
 	rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, errors.New("auth failure")
+	return false, nil, errors.New("auth failure")
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
@@ -3878,7 +4857,7 @@ func createMarshalledReply(id, result interface{}, replyErr error) ([]byte, erro
 }
 
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, profile *rpcProfile) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
@@ -3966,14 +4945,10 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 			}
 		}()
 
-		// Check if the user is limited and set error if method unauthorized
-		if !isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr = &ulordjson.RPCError{
-					Code:    ulordjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-			}
+		// Check if the profile of the user allows the method and set
+		// error if method unauthorized
+		if !profile.allows(request.Method) {
+			jsonErr = rpcNotAuthorizedError(profile)
 		}
 
 		if jsonErr == nil {
@@ -4045,19 +5020,24 @@ func (s *rpcServer) Start() {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
-		_, isAdmin, err := s.checkAuth(r, true)
+		_, profile, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, profile)
 	})
 
+	// Read-only REST endpoint.
+	if cfg.REST {
+		rpcServeMux.HandleFunc("/rest/", s.restHandler)
+	}
+
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
+		authenticated, profile, err := s.checkAuth(r, false)
 		if err != nil {
 			jsonAuthFail(w)
 			return
@@ -4074,7 +5054,7 @@ func (s *rpcServer) Start() {
 			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
 			return
 		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
+		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, profile)
 	})
 
 	for _, listener := range s.cfg.Listeners {
@@ -4087,6 +5067,10 @@ func (s *rpcServer) Start() {
 		}(listener)
 	}
 
+	if len(s.cfg.GRPCListeners) != 0 {
+		s.startGRPC()
+	}
+
 	s.ntfnMgr.Start()
 }
 
@@ -4130,6 +5114,10 @@ type rpcserverPeer interface {
 	// the peer is to being banned.
 	BanScore() uint32
 
+	// Misbehavior returns the most recent offenses of the peer, oldest
+	// first.
+	Misbehavior() []netsync.MisbehaviorRecord
+
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
@@ -4175,6 +5163,14 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// NetTotalsPerMsg returns the sums of all bytes received and sent
+	// across the network for all peers by message command.
+	NetTotalsPerMsg() (map[string]uint64, map[string]uint64)
+
+	// BandwidthLimits returns the maximum number of bytes per second sent
+	// to and received from all peers, which are zero when unlimited.
+	BandwidthLimits() (int64, int64)
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
@@ -4186,10 +5182,15 @@ type rpcserverConnManager interface {
 	// connected peers.
 	BroadcastMessage(msg wire.Message)
 
-	// AddRebroadcastInventory adds the provided inventory to the list of
-	// inventories to be rebroadcast at random intervals until they show up
-	// in a block.
-	AddRebroadcastInventory(iv *wire.InvVect, data interface{})
+	// AddUnbroadcastTransaction adds the provided transaction to the
+	// transactions which are rebroadcast with an increasing backoff until
+	// a peer announces or requests them.
+	AddUnbroadcastTransaction(txD *mempool.TxDesc)
+
+	// UnbroadcastTransactions returns the transactions submitted through
+	// the RPC server which have not been announced or requested by any
+	// peer yet.
+	UnbroadcastTransactions() []mempool.UnbroadcastTx
 
 	// RelayTransactions generates and relays inventory vectors for all of
 	// the passed transactions to all connected peers.
@@ -4218,9 +5219,18 @@ type rpcserverSyncManager interface {
 
 	// LocateHeaders returns the headers of the blocks after the first known
 	// block in the provided locators until the provided stop hash or the
-	// current tip is reached, up to a max of wire.MaxBlockHeadersPerMsg
+	// current tip is reached, up to a max of wire.DefaultBlockHeadersPerMsg
 	// hashes.
 	LocateHeaders(locators []*chainhash.Hash, hashStop *chainhash.Hash) []wire.BlockHeader
+
+	// Reindex starts a job which processes the blocks of the main chain
+	// starting at the provided height again through the indexes with the
+	// provided names, or all enabled indexes when none are provided.
+	Reindex(startHeight int32, indexNames []string) (*netsync.ReindexJob, error)
+
+	// SetTrustedPeer adds or removes the provided network of trusted peers,
+	// whose transactions bypass the relay policy.
+	SetTrustedPeer(ipNet *net.IPNet, trusted bool) error
 }
 
 // rpcserverConfig is a descriptor containing the RPC server configuration.
@@ -4231,6 +5241,10 @@ type rpcserverConfig struct {
 	// is stopped.
 	Listeners []net.Listener
 
+	// GRPCListeners defines a slice of listeners the gRPC service is
+	// served on.  They are owned by the RPC server like the RPC listeners.
+	GRPCListeners []net.Listener
+
 	// StartupTime is the unix timestamp for when the server that is hosting
 	// the RPC server started.
 	StartupTime int64
@@ -4264,9 +5278,13 @@ type rpcserverConfig struct {
 
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
+	TxIndex         *indexers.TxIndex
+	AddrIndex       *indexers.AddrIndex
+	CfIndex         *indexers.CfIndex
+	BlockStatsIndex *indexers.BlockStatsIndex
+	AddrUtxoIndex   *indexers.AddrUtxoIndex
+	SpentIndex      *indexers.SpentIndex
+	TimestampIndex  *indexers.TimestampIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
@@ -4284,17 +5302,22 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 		quit: make(chan int),
 	}
 	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
+		rpc.users = append(rpc.users, newRPCUser(cfg.RPCUser,
+			cfg.RPCPass, rpcBuiltinProfiles[rpcProfileAdmin]))
 	}
 	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+		rpc.users = append(rpc.users, newRPCUser(cfg.RPCLimitUser,
+			cfg.RPCLimitPass, rpcBuiltinProfiles[rpcProfileLimited]))
+	}
+	rpc.users = append(rpc.users, cfg.rpcUsers...)
+	if len(config.GRPCListeners) != 0 {
+		rpc.grpcBlocks = grpcapi.NewBroadcaster()
+		rpc.grpcTxs = grpcapi.NewBroadcaster()
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
-	rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)
+	rpc.chainSub = rpc.cfg.Chain.SubscribeBuffered(
+		rpc.handleBlockchainNotification, blockchain.NTBlockAccepted,
+		blockchain.NTBlockConnected, blockchain.NTBlockDisconnected)
 
 	return &rpc, nil
 }
@@ -4324,6 +5347,7 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients of incoming block.
 		s.ntfnMgr.NotifyBlockConnected(block)
+		s.grpcNotifyBlock(block, true)
 
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*ulordutil.Block)
@@ -4334,6 +5358,7 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+		s.grpcNotifyBlock(block, false)
 	}
 }
 
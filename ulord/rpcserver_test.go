@@ -0,0 +1,64 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/ulordjson"
+)
+
+// TestParseGBTClientCaps ensures the capabilities reported by callers of
+// getblocktemplate are negotiated as expected.
+func TestParseGBTClientCaps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		request *ulordjson.TemplateRequest
+		want    gbtClientCaps
+	}{
+		{
+			name:    "no request",
+			request: nil,
+			want:    gbtClientCaps{useCoinbaseValue: true, longPoll: true},
+		},
+		{
+			name:    "no capabilities",
+			request: &ulordjson.TemplateRequest{},
+			want:    gbtClientCaps{useCoinbaseValue: true, longPoll: true},
+		},
+		{
+			name: "coinbase transaction",
+			request: &ulordjson.TemplateRequest{
+				Capabilities: []string{"coinbasetxn", "longpoll"},
+			},
+			want: gbtClientCaps{longPoll: true},
+		},
+		{
+			name: "coinbase transaction and value",
+			request: &ulordjson.TemplateRequest{
+				Capabilities: []string{"coinbasetxn",
+					"coinbasevalue"},
+			},
+			want: gbtClientCaps{useCoinbaseValue: true},
+		},
+		{
+			name: "work id without long polling",
+			request: &ulordjson.TemplateRequest{
+				Capabilities: []string{"coinbasevalue", "workid"},
+			},
+			want: gbtClientCaps{useCoinbaseValue: true, workID: true},
+		},
+	}
+
+	for _, test := range tests {
+		got := parseGBTClientCaps(test.request)
+		if got != test.want {
+			t.Errorf("%s: unexpected capabilities: got %+v, want %+v",
+				test.name, got, test.want)
+		}
+	}
+}
@@ -29,11 +29,25 @@ var helpDescsEnUS = map[string]string{
 	"debuglevel--result0":    "The string 'Done.'",
 	"debuglevel--result1":    "The list of subsystems",
 
+	// AddCheckpointCmd help.
+	"addcheckpoint--synopsis": "Adds a checkpoint which blocks in the main chain must match.  It fails when the block in the main chain at the height has a different hash, which must be invalidated first.",
+	"addcheckpoint-height":    "The height of the checkpoint",
+	"addcheckpoint-hash":      "The hash of the block at the height",
+
 	// AddNodeCmd help.
 	"addnode--synopsis": "Attempts to add or remove a persistent peer.",
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// BackupChainStateCmd help.
+	"backupchainstate--synopsis": "Writes a consistent backup of the block database to a file while the node keeps running.\n" +
+		"The backup can be restored with the restore command of dbtool.",
+	"backupchainstate-path": "The path of the file, relative to the data directory unless it is absolute, which must not exist yet",
+
+	// BackupChainStateResult help.
+	"backupchainstateresult-path": "The absolute path of the backup file",
+	"backupchainstateresult-size": "The size of the backup in bytes",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -115,6 +129,23 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DeriveAddressesCmd help.
+	"deriveaddresses--synopsis":  "Derives the addresses of the output scripts described by a descriptor, which must include its checksum.",
+	"deriveaddresses-descriptor": "The descriptor followed by its checksum",
+	"deriveaddresses-range":      "The end or the [begin,end] pair of the child indexes to derive the addresses of ranged descriptors at",
+	"deriveaddresses--result0":   "The derived addresses",
+
+	// DumpTxOutSetCmd help.
+	"dumptxoutset--synopsis": "Writes a snapshot of the utxo set at the best block to a file which can be loaded with --loadutxosnapshot to bootstrap a new node.",
+	"dumptxoutset-path":      "The path of the file, relative to the data directory unless it is absolute, which must not exist yet",
+
+	// DumpTxOutSetResult help.
+	"dumptxoutsetresult-coins_written": "The number of unspent transaction outputs in the snapshot",
+	"dumptxoutsetresult-base_hash":     "The hash of the block the snapshot was taken at",
+	"dumptxoutsetresult-base_height":   "The height of the block the snapshot was taken at",
+	"dumptxoutsetresult-path":          "The absolute path of the snapshot file",
+	"dumptxoutsetresult-txoutset_hash": "The commitment of the snapshot which nodes loading it must trust",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
@@ -124,6 +155,23 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee rate in bitcoins per kilobyte " +
+		"required for a transaction to be mined within a certain number of " +
+		"blocks, based on how quickly transactions paying each fee rate were mined.",
+	"estimatesmartfee-conftarget": "The maximum number of blocks which can be " +
+		"generated before the transaction is mined (1 to 25)",
+	"estimatesmartfee-estimatemode": "The estimate mode: ECONOMICAL responds " +
+		"quickly to falling fees while CONSERVATIVE, the default, uses a longer " +
+		"history and requires more certainty (UNSET, ECONOMICAL or CONSERVATIVE)",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "Estimated fee rate in bitcoins per kilobyte, " +
+		"omitted when no estimate is available",
+	"estimatesmartfeeresult-errors": "Errors encountered while estimating the fee rate",
+	"estimatesmartfeeresult-blocks": "The number of blocks the estimate is for, which " +
+		"is higher than the requested target when there was not enough data for it",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
@@ -147,6 +195,47 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the balance of the passed address and the total amount it received in transactions confirmed in the main chain.\n" +
+		"Usage of this RPC requires the optional --addrutxoindex flag to be activated.",
+	"getaddressbalance-address": "The address to return the balance of",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":  "The sum of the unspent outputs paying to the address in satoshi",
+	"getaddressbalanceresult-received": "The sum of all outputs which paid to the address in satoshi",
+
+	// GetAddressDeltasCmd help.
+	"getaddressdeltas--synopsis": "Returns the outputs paying to the passed address and the inputs spending them in transactions confirmed in the main chain, in the order they appear in the chain.\n" +
+		"Usage of this RPC requires the optional --addrutxoindex flag to be activated.",
+	"getaddressdeltas-address": "The address to return the deltas of",
+	"getaddressdeltas-skip":    "The number of leading deltas to leave out of the response",
+	"getaddressdeltas-count":   "The maximum number of deltas to return",
+	"getaddressdeltas-reverse": "Specifies that the deltas should be returned in reverse chronological order",
+
+	// GetAddressDeltasResult help.
+	"getaddressdeltasresult-address":    "The address",
+	"getaddressdeltasresult-txid":       "The hash of the transaction",
+	"getaddressdeltasresult-height":     "The height of the block containing the transaction",
+	"getaddressdeltasresult-blockindex": "The position of the transaction in the block",
+	"getaddressdeltasresult-index":      "The position of the input or output in the transaction",
+	"getaddressdeltasresult-spending":   "Whether the delta is an input spending an output paying to the address",
+	"getaddressdeltasresult-satoshis":   "The amount credited to the address in satoshi, which is negative for inputs",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs paying to the passed address in transactions confirmed in the main chain, ordered by their outpoints.\n" +
+		"Usage of this RPC requires the optional --addrutxoindex flag to be activated.",
+	"getaddressutxos-address": "The address to return the unspent outputs of",
+	"getaddressutxos-skip":    "The number of leading unspent outputs to leave out of the response",
+	"getaddressutxos-count":   "The maximum number of unspent outputs to return",
+
+	// GetAddressUtxosResult help.
+	"getaddressutxosresult-address":     "The address",
+	"getaddressutxosresult-txid":        "The hash of the transaction containing the output",
+	"getaddressutxosresult-outputIndex": "The index of the output in the transaction",
+	"getaddressutxosresult-script":      "The hex-encoded public key script of the output",
+	"getaddressutxosresult-satoshis":    "The amount of the output in satoshi",
+	"getaddressutxosresult-height":      "The height of the block containing the transaction",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -252,6 +341,13 @@ var helpDescsEnUS = map[string]string{
 	"getblockhash-index":     "The block height",
 	"getblockhash--result0":  "The block hash",
 
+	// GetBlockHashesCmd help.
+	"getblockhashes--synopsis": "Returns the hashes of the blocks in the main chain with a timestamp which is at least low and less than high, ordered by timestamp.\n" +
+		"Usage of this RPC requires the optional --timestampindex flag to be activated.",
+	"getblockhashes-high":     "The timestamp in seconds since 1 Jan 1970 GMT before which the blocks are returned",
+	"getblockhashes-low":      "The timestamp in seconds since 1 Jan 1970 GMT from which the blocks are returned",
+	"getblockhashes--result0": "The hashes of the blocks",
+
 	// GetBlockHeaderCmd help.
 	"getblockheader--synopsis":   "Returns information about a block header given its hash.",
 	"getblockheader-hash":        "The hash of the block",
@@ -274,9 +370,44 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis":    "Returns statistics about the transactions of a block in the main chain.  The statistics are read from the block stats index when it is enabled with --blockstatsindex and computed from the block otherwise.",
+	"getblockstats-hashorheight": "The hash or the height of the block",
+	"getblockstats-stats":        "The names of the statistics to return instead of all of them",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":              "The average fee of the transactions excluding the coinbase in satoshi",
+	"getblockstatsresult-avgfeerate":          "The average fee rate in satoshi per virtual byte",
+	"getblockstatsresult-avgtxsize":           "The average size of the transactions excluding the coinbase",
+	"getblockstatsresult-blockhash":           "The hash of the block",
+	"getblockstatsresult-feerate_percentiles": "The fee rates at the 10th, 25th, 50th, 75th, and 90th percentiles of the weight of the transactions in satoshi per virtual byte",
+	"getblockstatsresult-height":              "The height of the block",
+	"getblockstatsresult-ins":                 "The number of inputs excluding the coinbase",
+	"getblockstatsresult-maxfee":              "The highest fee in the block in satoshi",
+	"getblockstatsresult-maxfeerate":          "The highest fee rate in the block in satoshi per virtual byte",
+	"getblockstatsresult-maxtxsize":           "The size of the largest transaction excluding the coinbase",
+	"getblockstatsresult-medianfee":           "The median fee in the block in satoshi",
+	"getblockstatsresult-mediantxsize":        "The median size of the transactions excluding the coinbase",
+	"getblockstatsresult-minfee":              "The lowest fee in the block in satoshi",
+	"getblockstatsresult-minfeerate":          "The lowest fee rate in the block in satoshi per virtual byte",
+	"getblockstatsresult-mintxsize":           "The size of the smallest transaction excluding the coinbase",
+	"getblockstatsresult-outs":                "The number of outputs",
+	"getblockstatsresult-subsidy":             "The block subsidy in satoshi",
+	"getblockstatsresult-swtotal_size":        "The total size of the transactions with witness data",
+	"getblockstatsresult-swtotal_weight":      "The total weight of the transactions with witness data",
+	"getblockstatsresult-swtxs":               "The number of transactions with witness data",
+	"getblockstatsresult-time":                "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-total_out":           "The total amount of the outputs excluding the coinbase in satoshi",
+	"getblockstatsresult-total_size":          "The total size of the transactions excluding the coinbase",
+	"getblockstatsresult-total_weight":        "The total weight of the transactions excluding the coinbase",
+	"getblockstatsresult-totalfee":            "The total fee in satoshi",
+	"getblockstatsresult-txs":                 "The number of transactions including the coinbase",
+	"getblockstatsresult-utxo_increase":       "The change in the number of unspent transaction outputs",
+	"getblockstatsresult-utxo_size_inc":       "The change in the serialized size of the unspent transaction outputs",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
-	"templaterequest-capabilities": "List of capabilities",
+	"templaterequest-capabilities": "List of client capabilities such as 'coinbasetxn', 'coinbasevalue', 'longpoll', and 'workid'",
 	"templaterequest-longpollid":   "The long poll ID of a job to monitor for expiration; required and valid only for long poll requests ",
 	"templaterequest-sigoplimit":   "Number of signature operations allowed in blocks (this parameter is ignored)",
 	"templaterequest-sizelimit":    "Number of bytes allowed in blocks (this parameter is ignored)",
@@ -308,8 +439,8 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresult-coinbaseaux":                "Data that should be included in the coinbase signature script",
 	"getblocktemplateresult-coinbasetxn":                "Information about the coinbase transaction",
 	"getblocktemplateresult-coinbasevalue":              "Total amount available for the coinbase in Satoshi",
-	"getblocktemplateresult-workid":                     "This value must be returned with result if provided (not provided)",
-	"getblocktemplateresult-longpollid":                 "Identifier for long poll request which allows monitoring for expiration",
+	"getblocktemplateresult-workid":                     "This value must be returned with result if provided (only provided when the client reports the 'workid' capability)",
+	"getblocktemplateresult-longpollid":                 "Identifier for long poll request which allows monitoring for expiration (omitted when the client reports capabilities without 'longpoll')",
 	"getblocktemplateresult-longpolluri":                "An alternate URI to use for long poll requests if provided (not provided)",
 	"getblocktemplateresult-submitold":                  "Not applicable",
 	"getblocktemplateresult-target":                     "Hex-encoded big-endian number which valid results must be less than",
@@ -318,7 +449,7 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresult-mintime":                    "Minimum allowed time",
 	"getblocktemplateresult-mutable":                    "List of mutations the server explicitly allows",
 	"getblocktemplateresult-noncerange":                 "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
-	"getblocktemplateresult-capabilities":               "List of server capabilities including 'proposal' to indicate support for block proposals",
+	"getblocktemplateresult-capabilities":               "List of server capabilities including 'proposal' to indicate support for block proposals, 'longpoll', and 'workid'",
 	"getblocktemplateresult-reject-reason":              "Reason the proposal was invalid as-is (only applies to proposal responses)",
 	"getblocktemplateresult-default_witness_commitment": "The witness commitment itself. Will be populated if the block has witness data",
 	"getblocktemplateresult-weightlimit":                "The current limit on the max allowed weight of a block",
@@ -344,6 +475,15 @@ var helpDescsEnUS = map[string]string{
 	"getcfilterheader-hash":       "The hash of the block",
 	"getcfilterheader--result0":   "The block's gcs filter header",
 
+	// GetChainTipsCmd help.
+	"getchaintips--synopsis": "Returns the tips of all known branches of the block tree, including the main chain, ordered by decreasing height.",
+
+	// GetChainTipsResult help.
+	"getchaintipsresult-height":    "The height of the tip",
+	"getchaintipsresult-hash":      "The hash of the tip",
+	"getchaintipsresult-branchlen": "The number of blocks between the tip and the main chain, zero for the main chain",
+	"getchaintipsresult-status":    "The state of the branch (active, valid-fork, valid-headers, headers-only, or invalid)",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -352,6 +492,17 @@ var helpDescsEnUS = map[string]string{
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifer",
 
+	// GetDescriptorInfoCmd help.
+	"getdescriptorinfo--synopsis":  "Analyzes a descriptor and returns it in canonical form with private keys replaced by public keys.",
+	"getdescriptorinfo-descriptor": "The descriptor, optionally followed by its checksum",
+
+	// GetDescriptorInfoResult help.
+	"getdescriptorinforesult-descriptor":     "The descriptor in canonical form followed by its checksum",
+	"getdescriptorinforesult-checksum":       "The checksum of the passed descriptor",
+	"getdescriptorinforesult-isrange":        "Whether the descriptor describes scripts derived at a range of child indexes",
+	"getdescriptorinforesult-issolvable":     "Whether the descriptor contains the information needed to spend its scripts",
+	"getdescriptorinforesult-hasprivatekeys": "Whether the descriptor contains private keys",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
@@ -407,8 +558,14 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":            "Size in bytes of the mempool",
+	"getmempoolinforesult-size":             "Number of transactions in the mempool",
+	"getmempoolinforesult-maxmempool":       "Maximum total virtual size of the transactions in the mempool in bytes",
+	"getmempoolinforesult-mempoolminfee":    "Minimum fee rate in BTC/kB a transaction must pay to be accepted into the mempool",
+	"getmempoolinforesult-minrelaytxfee":    "Minimum fee rate in BTC/kB for a transaction to be relayed",
+	"getmempoolinforesult-unbroadcastcount": "Number of transactions submitted through RPC which have not been announced or requested by any peer yet",
+	"getmempoolinforesult-unbroadcasttxs":   "Hashes of the transactions submitted through RPC which have not been announced or requested by any peer yet, in submission order",
+	"getmempoolinforesult-loaded":           "Whether the mempool saved on the last shutdown has been loaded",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -437,32 +594,61 @@ var helpDescsEnUS = map[string]string{
 	"getnettotals--synopsis": "Returns a JSON object containing network traffic statistics.",
 
 	// GetNetTotalsResult help.
-	"getnettotalsresult-totalbytesrecv": "Total bytes received",
-	"getnettotalsresult-totalbytessent": "Total bytes sent",
-	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-totalbytesrecv":           "Total bytes received",
+	"getnettotalsresult-totalbytessent":           "Total bytes sent",
+	"getnettotalsresult-timemillis":               "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-bytesrecv_per_msg":        "Total bytes received by message command",
+	"getnettotalsresult-bytesrecv_per_msg--key":   "command",
+	"getnettotalsresult-bytesrecv_per_msg--value": "n",
+	"getnettotalsresult-bytesrecv_per_msg--desc":  "Total bytes received for the message command",
+	"getnettotalsresult-bytessent_per_msg":        "Total bytes sent by message command",
+	"getnettotalsresult-bytessent_per_msg--key":   "command",
+	"getnettotalsresult-bytessent_per_msg--value": "n",
+	"getnettotalsresult-bytessent_per_msg--desc":  "Total bytes sent for the message command",
+	"getnettotalsresult-uploadlimit":              "Maximum number of bytes per second sent to all peers, 0 when unlimited",
+	"getnettotalsresult-downloadlimit":            "Maximum number of bytes per second received from all peers, 0 when unlimited",
 
 	// GetPeerInfoResult help.
-	"getpeerinforesult-id":             "A unique node ID",
-	"getpeerinforesult-addr":           "The ip address and port of the peer",
-	"getpeerinforesult-addrlocal":      "Local address",
-	"getpeerinforesult-services":       "Services bitmask which represents the services supported by the peer",
-	"getpeerinforesult-relaytxes":      "Peer has requested transactions be relayed to it",
-	"getpeerinforesult-lastsend":       "Time the last message was received in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-lastrecv":       "Time the last message was sent in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-bytessent":      "Total bytes sent",
-	"getpeerinforesult-bytesrecv":      "Total bytes received",
-	"getpeerinforesult-conntime":       "Time the connection was made in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-timeoffset":     "The time offset of the peer",
-	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
-	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
-	"getpeerinforesult-version":        "The protocol version of the peer",
-	"getpeerinforesult-subver":         "The user agent of the peer",
-	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
-	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
-	"getpeerinforesult-currentheight":  "The current height of the peer",
-	"getpeerinforesult-banscore":       "The ban score",
-	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
-	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-id":                       "A unique node ID",
+	"getpeerinforesult-addr":                     "The ip address and port of the peer",
+	"getpeerinforesult-addrlocal":                "Local address",
+	"getpeerinforesult-services":                 "Services bitmask which represents the services supported by the peer",
+	"getpeerinforesult-relaytxes":                "Peer has requested transactions be relayed to it",
+	"getpeerinforesult-lastsend":                 "Time the last message was received in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-lastrecv":                 "Time the last message was sent in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-bytessent":                "Total bytes sent",
+	"getpeerinforesult-bytesrecv":                "Total bytes received",
+	"getpeerinforesult-conntime":                 "Time the connection was made in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-timeoffset":               "The time offset of the peer",
+	"getpeerinforesult-pingtime":                 "Number of microseconds the last ping took",
+	"getpeerinforesult-pingwait":                 "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-version":                  "The protocol version of the peer",
+	"getpeerinforesult-subver":                   "The user agent of the peer",
+	"getpeerinforesult-inbound":                  "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-startingheight":           "The latest block height the peer knew about when the connection was established",
+	"getpeerinforesult-currentheight":            "The current height of the peer",
+	"getpeerinforesult-banscore":                 "The ban score",
+	"getpeerinforesult-feefilter":                "The requested minimum fee a transaction must have to be announced to the peer",
+	"getpeerinforesult-syncnode":                 "Whether or not the peer is the sync peer",
+	"getpeerinforesult-pingp50":                  "Median of the recent ping times in microseconds",
+	"getpeerinforesult-pingp90":                  "90th percentile of the recent ping times in microseconds",
+	"getpeerinforesult-pingp99":                  "99th percentile of the recent ping times in microseconds",
+	"getpeerinforesult-bytessent_per_msg":        "Total bytes sent to the peer by message command",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "n",
+	"getpeerinforesult-bytessent_per_msg--desc":  "Total bytes sent for the message command",
+	"getpeerinforesult-bytesrecv_per_msg":        "Total bytes received from the peer by message command",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "n",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "Total bytes received for the message command",
+	"getpeerinforesult-misbehavior":              "The most recent offenses of the peer, oldest first",
+
+	// PeerMisbehaviorResult help.
+	"peermisbehaviorresult-offense":  "The kind of offense",
+	"peermisbehaviorresult-reason":   "A description of the offense",
+	"peermisbehaviorresult-time":     "Time of the offense in seconds since 1 Jan 1970 GMT",
+	"peermisbehaviorresult-banscore": "The ban score after the offense",
+	"peermisbehaviorresult-action":   "The action taken against the peer (none, disconnect, or ban)",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -476,6 +662,7 @@ var helpDescsEnUS = map[string]string{
 	"getrawmempoolverboseresult-currentpriority":  "Current priority",
 	"getrawmempoolverboseresult-depends":          "Unconfirmed transactions used as inputs for this transaction",
 	"getrawmempoolverboseresult-vsize":            "The virtual size of a transaction",
+	"getrawmempoolverboseresult-instantlock":      "Whether the transaction has been locked by a masternode quorum for InstantSend",
 
 	// GetRawMempoolCmd help.
 	"getrawmempool--synopsis":   "Returns information about all of the transactions currently in the memory pool.",
@@ -492,6 +679,20 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// SpentInfoOutPoint help.
+	"spentinfooutpoint-txid":  "The hash of the transaction of the output",
+	"spentinfooutpoint-index": "The index of the output in the transaction",
+
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the input which spends the passed output in the main chain.\n" +
+		"Usage of this RPC requires the optional --spentindex flag to be activated.",
+	"getspentinfo-outpoint": "The output to return the spending input of",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the input in the spending transaction",
+	"getspentinforesult-height": "The height of the block containing the spending transaction",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -514,10 +715,34 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Permanently marks a block and all of its descendants as invalid and reorganizes the chain to the best remaining valid chain.",
+	"invalidateblock-blockhash": "The hash of the block to invalidate",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes the invalid marks set by invalidateblock from a block, its ancestors, and its descendants and reorganizes the chain to the best chain.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
+	// ReindexCmd help.
+	"reindex--synopsis":   "Starts a job which processes the blocks of the main chain starting at a height again through the enabled indexes.  The indexes are not updated with new blocks until they caught up with the main chain again.",
+	"reindex-startheight": "The height of the first block to process again",
+	"reindex-indexes":     "The names of the indexes to rebuild (\"transaction index\", \"address index\", \"committed filter index\", or \"block stats index\"), all enabled indexes when omitted",
+
+	// RPCUserInfoCmd help.
+	"rpcuserinfo--synopsis": "Returns the RPC users along with the permission profiles which specify the methods they are allowed to call.",
+	"rpcuserinfo-user":      "Only return the user with this name",
+
+	// RPCUserInfoResult help.
+	"rpcuserinforesult-user":       "The name of the user",
+	"rpcuserinforesult-profile":    "The name of the permission profile of the user",
+	"rpcuserinforesult-allmethods": "Whether the user is allowed to call all methods which are not denied",
+	"rpcuserinforesult-allowed":    "The methods the user is allowed to call unless allmethods is set",
+	"rpcuserinforesult-denied":     "The methods the user is not allowed to call",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -546,6 +771,11 @@ var helpDescsEnUS = map[string]string{
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetTrustedPeerCmd help.
+	"settrustedpeer--synopsis": "Adds or removes a network of trusted peers.  Transactions relayed by trusted peers bypass the relay policy, but not the consensus rules, and trusted peers are preferred when choosing the peer to sync from.",
+	"settrustedpeer-addr":      "IP network in CIDR notation or IP address of the peers to operate on",
+	"settrustedpeer-subcmd":    "'add' to trust the peers or 'remove' to no longer trust them",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown ulord.",
 	"stop--result0":  "The string 'ulord stopping.'",
@@ -561,6 +791,18 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Returns whether the serialized, hex-encoded transactions would be accepted " +
+		"into the memory pool without adding them to it.  Each transaction is checked on its own against the current pool.",
+	"testmempoolaccept-rawtxns": "Serialized, hex-encoded signed transactions",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-allowed":       "Whether the transaction would be accepted into the memory pool",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected (only when allowed is false)",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction (only when allowed is true)",
+	"testmempoolacceptresult-fee":           "The fee paid by the transaction in bitcoins (only when allowed is true)",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
@@ -574,7 +816,8 @@ var helpDescsEnUS = map[string]string{
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
 		"For ulord this is:\n" +
 		"checklevel=0 - Look up each block and ensure it can be loaded from the database.\n" +
-		"checklevel=1 - Perform basic context-free sanity checks on each block.",
+		"checklevel=1 - Perform basic context-free sanity checks on each block.\n" +
+		"checklevel=4 - Additionally verify the checksums of all stored blocks, regardless of checkdepth.",
 	"verifychain-checklevel": "How thorough the block verification is",
 	"verifychain-checkdepth": "The number of blocks to check",
 	"verifychain--result0":   "Whether or not the chain verified",
@@ -631,6 +874,8 @@ var helpDescsEnUS = map[string]string{
 	"loadtxfilter-reload":    "Load a new filter instead of adding data to an existing one",
 	"loadtxfilter-addresses": "Array of addresses to add to the transaction filter",
 	"loadtxfilter-outpoints": "Array of outpoints to add to the transaction filter",
+	"loadtxfilter-scripts":   "Array of hex-encoded output scripts, which may be nonstandard, to add to the transaction filter",
+	"loadtxfilter-minamount": "Minimum value in ULD of outputs matching the transaction filter, spends of watched outpoints match regardless of their value (unchanged when omitted unless the filter is reloaded)",
 
 	// Rescan help.
 	"rescan--synopsis": "Rescan block chain for transactions to addresses.\n" +
@@ -674,26 +919,38 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
+	"addcheckpoint":         nil,
 	"addnode":               nil,
+	"backupchainstate":      {(*ulordjson.BackupChainStateResult)(nil)},
 	"createrawtransaction":  {(*string)(nil)},
 	"debuglevel":            {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":  {(*ulordjson.TxRawDecodeResult)(nil)},
 	"decodescript":          {(*ulordjson.DecodeScriptResult)(nil)},
+	"deriveaddresses":       {(*[]string)(nil)},
+	"dumptxoutset":          {(*ulordjson.DumpTxOutSetResult)(nil)},
 	"estimatefee":           {(*float64)(nil)},
+	"estimatesmartfee":      {(*ulordjson.EstimateSmartFeeResult)(nil)},
 	"generate":              {(*[]string)(nil)},
 	"getaddednodeinfo":      {(*[]string)(nil), (*[]ulordjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":     {(*ulordjson.GetAddressBalanceResult)(nil)},
+	"getaddressdeltas":      {(*[]ulordjson.GetAddressDeltasResult)(nil)},
+	"getaddressutxos":       {(*[]ulordjson.GetAddressUtxosResult)(nil)},
 	"getbestblock":          {(*ulordjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":      {(*string)(nil)},
 	"getblock":              {(*string)(nil), (*ulordjson.GetBlockVerboseResult)(nil)},
 	"getblockcount":         {(*int64)(nil)},
 	"getblockhash":          {(*string)(nil)},
+	"getblockhashes":        {(*[]string)(nil)},
 	"getblockheader":        {(*string)(nil), (*ulordjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockstats":         {(*ulordjson.GetBlockStatsResult)(nil)},
 	"getblocktemplate":      {(*ulordjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getblockchaininfo":     {(*ulordjson.GetBlockChainInfoResult)(nil)},
 	"getcfilter":            {(*string)(nil)},
 	"getcfilterheader":      {(*string)(nil)},
+	"getchaintips":          {(*[]ulordjson.GetChainTipsResult)(nil)},
 	"getconnectioncount":    {(*int32)(nil)},
 	"getcurrentnet":         {(*uint32)(nil)},
+	"getdescriptorinfo":     {(*ulordjson.GetDescriptorInfoResult)(nil)},
 	"getdifficulty":         {(*float64)(nil)},
 	"getgenerate":           {(*bool)(nil)},
 	"gethashespersec":       {(*float64)(nil)},
@@ -706,15 +963,22 @@ var rpcResultTypes = map[string][]interface{}{
 	"getpeerinfo":           {(*[]ulordjson.GetPeerInfoResult)(nil)},
 	"getrawmempool":         {(*[]string)(nil), (*ulordjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":     {(*string)(nil), (*ulordjson.TxRawResult)(nil)},
+	"getspentinfo":          {(*ulordjson.GetSpentInfoResult)(nil)},
 	"gettxout":              {(*ulordjson.GetTxOutResult)(nil)},
 	"node":                  nil,
 	"help":                  {(*string)(nil), (*string)(nil)},
+	"invalidateblock":       nil,
 	"ping":                  nil,
+	"reconsiderblock":       nil,
+	"reindex":               nil,
+	"rpcuserinfo":           {(*[]ulordjson.RPCUserInfoResult)(nil)},
 	"searchrawtransactions": {(*string)(nil), (*[]ulordjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":    {(*string)(nil)},
 	"setgenerate":           nil,
+	"settrustedpeer":        nil,
 	"stop":                  {(*string)(nil)},
 	"submitblock":           {nil, (*string)(nil)},
+	"testmempoolaccept":     {(*[]ulordjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                {(*int64)(nil)},
 	"validateaddress":       {(*ulordjson.ValidateAddressChainResult)(nil)},
 	"verifychain":           {(*bool)(nil)},
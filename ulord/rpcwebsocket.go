@@ -8,8 +8,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -85,7 +83,7 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	authenticated bool, profile *rpcProfile) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -104,7 +102,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, profile)
 	if err != nil {
 		rpcsLog.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -267,6 +265,14 @@ type wsClientFilter struct {
 
 	// Outpoints of unspent outputs.
 	unspent map[wire.OutPoint]struct{}
+
+	// Output scripts which are matched exactly, which allows matching
+	// nonstandard and non-address outputs.
+	scripts map[string]struct{}
+
+	// minAmount is the minimum value of outputs which match the filter.
+	// Spends of watched outpoints match regardless of their value.
+	minAmount int64
 }
 
 // newWSClientFilter creates a new, empty wsClientFilter struct to be used
@@ -281,6 +287,7 @@ func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint, par
 		uncompressedPubKeys: map[[65]byte]struct{}{},
 		otherAddresses:      map[string]struct{}{},
 		unspent:             make(map[wire.OutPoint]struct{}, len(unspentOutPoints)),
+		scripts:             map[string]struct{}{},
 	}
 
 	for _, s := range addresses {
@@ -445,6 +452,29 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 	delete(f.unspent, *op)
 }
 
+// addScript adds an output script to the wsClientFilter.
+func (f *wsClientFilter) addScript(script []byte) {
+	f.scripts[string(script)] = struct{}{}
+}
+
+// matchOutput returns true if the passed output pays to a script or one of the
+// passed addresses of the output script which have been added to the
+// wsClientFilter and is worth at least the minimum amount of the filter.
+func (f *wsClientFilter) matchOutput(output *wire.TxOut, addrs []ulordutil.Address) bool {
+	if output.Value < f.minAmount {
+		return false
+	}
+	if _, ok := f.scripts[string(output.PkScript)]; ok {
+		return true
+	}
+	for _, a := range addrs {
+		if f.existsAddress(a) {
+			return true
+		}
+	}
+	return false
+}
+
 // Notification types
 type notificationBlockConnected ulordutil.Block
 type notificationBlockDisconnected ulordutil.Block
@@ -574,7 +604,8 @@ out:
 				m.removeSpentRequest(watchedOutPoints, n.wsc, n.op)
 
 			case *notificationRegisterAddr:
-				m.addAddrRequests(watchedAddrs, n.wsc, n.addrs)
+				m.addAddrRequests(watchedOutPoints, watchedAddrs,
+					n.wsc, n.addrs)
 
 			case *notificationUnregisterAddr:
 				m.removeAddrRequest(watchedAddrs, n.wsc, n.addr)
@@ -656,13 +687,10 @@ func (m *wsNotificationManager) subscribedClients(tx *ulordutil.Tx,
 	}
 
 	for i, output := range msgTx.TxOut {
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		// Nonstandard or non-address outputs are only matched by
+		// their scripts.
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
 			output.PkScript, m.server.cfg.ChainParams)
-		if err != nil {
-			// Clients are not able to subscribe to
-			// nonstandard or non-address outputs.
-			continue
-		}
 		for quitChan, wsc := range clients {
 			wsc.Lock()
 			filter := wsc.filterData
@@ -671,15 +699,13 @@ func (m *wsNotificationManager) subscribedClients(tx *ulordutil.Tx,
 				continue
 			}
 			filter.mu.Lock()
-			for _, a := range addrs {
-				if filter.existsAddress(a) {
-					subscribed[quitChan] = struct{}{}
-					op := wire.OutPoint{
-						Hash:  *tx.Hash(),
-						Index: uint32(i),
-					}
-					filter.addUnspentOutPoint(&op)
+			if filter.matchOutput(output, addrs) {
+				subscribed[quitChan] = struct{}{}
+				op := wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(i),
 				}
+				filter.addUnspentOutPoint(&op)
 			}
 			filter.mu.Unlock()
 		}
@@ -1123,9 +1149,11 @@ func (m *wsNotificationManager) RegisterTxOutAddressRequests(wsc *wsClient, addr
 
 // addAddrRequests adds the websocket client wsc to the address to client set
 // addrMap so wsc will be notified for any mempool or block transaction outputs
-// spending to any of the addresses in addrs.
-func (*wsNotificationManager) addAddrRequests(addrMap map[string]map[chan struct{}]*wsClient,
-	wsc *wsClient, addrs []string) {
+// spending to any of the addresses in addrs.  When the mempool indexes its
+// transactions by address, wsc is notified of the mempool transactions already
+// paying to the addresses right away.
+func (m *wsNotificationManager) addAddrRequests(opMap map[wire.OutPoint]map[chan struct{}]*wsClient,
+	addrMap map[string]map[chan struct{}]*wsClient, wsc *wsClient, addrs []string) {
 
 	for _, addr := range addrs {
 		// Track the request in the client as well so it can be quickly be
@@ -1141,6 +1169,43 @@ func (*wsNotificationManager) addAddrRequests(addrMap map[string]map[chan struct
 		}
 		cmap[wsc.quit] = wsc
 	}
+
+	// Check if any transactions paying to these addresses already exist in
+	// the mempool, if so send the notifications immediately.  Only the
+	// requesting client is notified since the others were notified when
+	// the transactions were accepted.
+	txMemPool := m.server.cfg.TxMemPool
+	if !txMemPool.HasAddrIndex() {
+		return
+	}
+	params := m.server.cfg.ChainParams
+	clientAddrs := make(map[string]map[chan struct{}]*wsClient, len(addrs))
+	var payments []chainhash.Hash
+	seen := make(map[chainhash.Hash]struct{})
+	for _, addrStr := range addrs {
+		addr, err := ulordutil.DecodeAddress(addrStr, params)
+		if err != nil {
+			continue
+		}
+		clientAddrs[addrStr] = map[chan struct{}]*wsClient{wsc.quit: wsc}
+		for _, op := range txMemPool.OutPointsForAddress(addr) {
+			if _, ok := seen[op.Hash]; !ok {
+				seen[op.Hash] = struct{}{}
+				payments = append(payments, op.Hash)
+			}
+		}
+	}
+
+	for i := range payments {
+		tx, err := txMemPool.FetchTransaction(&payments[i])
+		if err != nil {
+			// The transaction left the pool in the meantime.
+			continue
+		}
+		rpcsLog.Debugf("Found existing mempool payment to a watched "+
+			"address: %v", tx.Hash())
+		m.notifyForTxOuts(opMap, clientAddrs, tx, nil)
+	}
 }
 
 // UnregisterTxOutAddressRequest removes a request from the passed websocket
@@ -1265,9 +1330,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// profile specifies the RPC calls the client is allowed to make.  It
+	// is nil until the client is authenticated.
+	profile *rpcProfile
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1405,15 +1470,13 @@ out:
 			// Check credentials.
 			login := authCmd.Username + ":" + authCmd.Passphrase
 			auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-			authSha := sha256.Sum256([]byte(auth))
-			cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-			limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-			if cmp != 1 && limitcmp != 1 {
+			user := c.server.authenticate(auth)
+			if user == nil {
 				rpcsLog.Warnf("Auth failure.")
 				break out
 			}
 			c.authenticated = true
-			c.isAdmin = cmp == 1
+			c.profile = user.profile
 
 			// Marshal and send response.
 			reply, err := createMarshalledReply(cmd.id, nil, nil)
@@ -1426,24 +1489,19 @@ out:
 			continue
 		}
 
-		// Check if the client is using limited RPC credentials and
-		// error when not authorized to call this RPC.
-		if !c.isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr := &ulordjson.RPCError{
-					Code:    ulordjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-				// Marshal and send response.
-				reply, err := createMarshalledReply(request.ID, nil, jsonErr)
-				if err != nil {
-					rpcsLog.Errorf("Failed to marshal parse failure "+
-						"reply: %v", err)
-					continue
-				}
-				c.SendMessage(reply, nil)
+		// Check if the profile of the client allows this RPC and
+		// error when not authorized to call it.
+		if !c.profile.allows(request.Method) {
+			jsonErr := rpcNotAuthorizedError(c.profile)
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.ID, nil, jsonErr)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal parse failure "+
+					"reply: %v", err)
 				continue
 			}
+			c.SendMessage(reply, nil)
+			continue
 		}
 
 		// Asynchronously handle the request.  A semaphore is used to
@@ -1710,7 +1768,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, error) {
+	remoteAddr string, authenticated bool, profile *rpcProfile) (*wsClient, error) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -1721,7 +1779,7 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 		conn:              conn,
 		addr:              remoteAddr,
 		authenticated:     authenticated,
-		isAdmin:           isAdmin,
+		profile:           profile,
 		sessionID:         sessionID,
 		server:            server,
 		addrRequests:      make(map[string]struct{}),
@@ -1803,16 +1861,37 @@ func handleLoadTxFilter(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		}
 	}
 
+	var scripts [][]byte
+	if cmd.Scripts != nil {
+		scripts = make([][]byte, len(*cmd.Scripts))
+		for i, scriptHex := range *cmd.Scripts {
+			script, err := hex.DecodeString(scriptHex)
+			if err != nil {
+				return nil, rpcDecodeHexError(scriptHex)
+			}
+			scripts[i] = script
+		}
+	}
+
+	var minAmount ulordutil.Amount
+	if cmd.MinAmount != nil {
+		var err error
+		minAmount, err = ulordutil.NewAmount(*cmd.MinAmount)
+		if err != nil || minAmount < 0 {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCInvalidParameter,
+				Message: "Invalid minimum amount",
+			}
+		}
+	}
+
 	params := wsc.server.cfg.ChainParams
 
 	wsc.Lock()
 	if cmd.Reload || wsc.filterData == nil {
 		wsc.filterData = newWSClientFilter(cmd.Addresses, outPoints,
 			params)
-		wsc.Unlock()
 	} else {
-		wsc.Unlock()
-
 		wsc.filterData.mu.Lock()
 		for _, a := range cmd.Addresses {
 			wsc.filterData.addAddressStr(a, params)
@@ -1822,6 +1901,19 @@ func handleLoadTxFilter(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		}
 		wsc.filterData.mu.Unlock()
 	}
+	filter := wsc.filterData
+	wsc.Unlock()
+
+	// The minimum amount is only changed when it is passed, so data can be
+	// added to a filter without resetting it.
+	filter.mu.Lock()
+	for _, script := range scripts {
+		filter.addScript(script)
+	}
+	if cmd.MinAmount != nil {
+		filter.minAmount = int64(minAmount)
+	}
+	filter.mu.Unlock()
 
 	return nil, nil
 }
@@ -2172,28 +2264,23 @@ func rescanBlockFilter(filter *wsClientFilter, block *ulordutil.Block, params *c
 
 		// Scan outputs.
 		for i, output := range msgTx.TxOut {
-			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
 				output.PkScript, params)
-			if err != nil {
+			if !filter.matchOutput(output, addrs) {
 				continue
 			}
-			for _, a := range addrs {
-				if !filter.existsAddress(a) {
-					continue
-				}
 
-				op := wire.OutPoint{
-					Hash:  *tx.Hash(),
-					Index: uint32(i),
-				}
-				filter.addUnspentOutPoint(&op)
+			op := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(i),
+			}
+			filter.addUnspentOutPoint(&op)
 
-				if !added {
-					transactions = append(
-						transactions,
-						txHexString(msgTx))
-					added = true
-				}
+			if !added {
+				transactions = append(
+					transactions,
+					txHexString(msgTx))
+				added = true
 			}
 		}
 	}
@@ -2242,6 +2329,12 @@ func handleRescanBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	var lastBlockHash *chainhash.Hash
 	for i := range blockHashes {
 		block, err := bc.BlockByHash(blockHashes[i])
+		if _, ok := err.(blockchain.BlockPrunedError); ok {
+			return nil, &ulordjson.RPCError{
+				Code:    ulordjson.ErrRPCMisc,
+				Message: "Block not available (pruned data)",
+			}
+		}
 		if err != nil {
 			return nil, &ulordjson.RPCError{
 				Code:    ulordjson.ErrRPCBlockNotFound,
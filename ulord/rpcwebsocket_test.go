@@ -0,0 +1,85 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestRescanBlockFilter ensures transactions are matched by the addresses,
+// scripts and outpoints of a websocket client filter and outputs worth less
+// than its minimum amount are ignored.
+func TestRescanBlockFilter(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	addr, err := ulordutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	nonStdScript := []byte{txscript.OP_TRUE, txscript.OP_DROP}
+	watched := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+
+	newTx := func(prevOut wire.OutPoint, outputs ...*wire.TxOut) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+		for _, output := range outputs {
+			tx.AddTxOut(output)
+		}
+		return tx
+	}
+	other := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 0}
+	coinbase := newTx(wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		wire.NewTxOut(5000, nil))
+	smallToAddr := newTx(other, wire.NewTxOut(999, addrScript))
+	toAddr := newTx(other, wire.NewTxOut(1000, addrScript))
+	toScript := newTx(other, wire.NewTxOut(2000, nonStdScript))
+	spendWatched := newTx(watched, wire.NewTxOut(1, nil))
+	unrelated := newTx(other, wire.NewTxOut(5000, []byte{txscript.OP_TRUE}))
+
+	block := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, smallToAddr, toAddr,
+			toScript, spendWatched, unrelated},
+	})
+
+	filter := newWSClientFilter([]string{addr.EncodeAddress()},
+		[]wire.OutPoint{watched}, params)
+	filter.addScript(nonStdScript)
+	filter.minAmount = 1000
+
+	got := rescanBlockFilter(filter, block, params)
+	want := []string{txHexString(toAddr), txHexString(toScript),
+		txHexString(spendWatched)}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transaction %d: got %s, want %s", i, got[i],
+				want[i])
+		}
+	}
+
+	// The matching outputs must be watched for spends while outputs worth
+	// less than the minimum amount must not.
+	for _, tx := range []*wire.MsgTx{toAddr, toScript} {
+		op := wire.OutPoint{Hash: tx.TxHash(), Index: 0}
+		if !filter.existsUnspentOutPoint(&op) {
+			t.Errorf("output %v is not watched", op)
+		}
+	}
+	op := wire.OutPoint{Hash: smallToAddr.TxHash(), Index: 0}
+	if filter.existsUnspentOutPoint(&op) {
+		t.Errorf("output %v below the minimum amount is watched", op)
+	}
+}
@@ -12,8 +12,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -32,10 +35,13 @@ import (
 	"github.com/ulordsuite/ulord/mempool"
 	"github.com/ulordsuite/ulord/mining"
 	"github.com/ulordsuite/ulord/mining/cpuminer"
+	"github.com/ulordsuite/ulord/mining/stratum"
 	"github.com/ulordsuite/ulord/netsync"
 	"github.com/ulordsuite/ulord/peer"
 	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/ulordec"
 	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulord/zmqpub"
 	"github.com/ulordsuite/ulordutil"
 	"github.com/ulordsuite/ulordutil/bloom"
 )
@@ -57,6 +63,43 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// unbroadcastCheckInterval is the interval at which transactions
+	// submitted through the RPC server are checked for being due for a
+	// rebroadcast.
+	unbroadcastCheckInterval = time.Minute
+
+	// feeFilterCheckInterval is the interval at which the minimum fee rate
+	// of the transaction pool is checked for changes which are announced
+	// to peers with feefilter messages.
+	feeFilterCheckInterval = time.Minute
+
+	// feeFilterUpdateInterval is the minimum time between two feefilter
+	// messages sent to a peer, so a fluctuating minimum fee rate does not
+	// flood peers with messages.
+	feeFilterUpdateInterval = 5 * time.Minute
+
+	// natLeaseDuration is the lifetime of the port mapping requested from
+	// the NAT gateway, which is renewed every natRenewInterval.
+	natLeaseDuration = time.Minute * 20
+	natRenewInterval = time.Minute * 15
+
+	// natRetryInterval is the time to wait before retrying a failed port
+	// mapping with a random external port.
+	natRetryInterval = time.Minute
+
+	// natMinExternalPort is the lowest random external port requested
+	// from the NAT gateway.
+	natMinExternalPort = 1024
+
+	// feelerConnectionInterval is the interval at which short-lived
+	// connections are made to addresses which were never connected to once
+	// the target number of outbound peers is reached.
+	feelerConnectionInterval = time.Minute * 2
+
+	// mempoolFileName is the name of the file in the data directory the
+	// mempool is saved to on shutdown.
+	mempoolFileName = "mempool.dat"
 )
 
 var (
@@ -121,15 +164,15 @@ var _ net.Addr = simpleAddr{}
 type broadcastMsg struct {
 	message      wire.Message
 	excludePeers []*serverPeer
-}
 
-// broadcastInventoryAdd is a type used to declare that the InvVect it contains
-// needs to be added to the rebroadcast map
-type broadcastInventoryAdd relayMsg
+	// origin is the peer the message was received from, if any.  It is
+	// excluded from the broadcast.
+	origin *peer.Peer
 
-// broadcastInventoryDel is a type used to declare that the InvVect it contains
-// needs to be removed from the rebroadcast map
-type broadcastInventoryDel *wire.InvVect
+	// minProtocolVersion is the protocol version peers must have
+	// negotiated to receive the message.
+	minProtocolVersion uint32
+}
 
 // relayMsg packages an inventory vector along with the newly discovered
 // inventory so the relay has access to that information.
@@ -205,38 +248,62 @@ type server struct {
 	shutdownSched int32
 	startupTime   int64
 
-	chainParams          *chaincfg.Params
-	addrManager          *addrmgr.AddrManager
-	connManager          *connmgr.ConnManager
-	sigCache             *txscript.SigCache
-	hashCache            *txscript.HashCache
-	rpcServer            *rpcServer
-	syncManager          *netsync.SyncManager
-	chain                *blockchain.BlockChain
-	txMemPool            *mempool.TxPool
-	cpuMiner             *cpuminer.CPUMiner
-	modifyRebroadcastInv chan interface{}
-	newPeers             chan *serverPeer
-	donePeers            chan *serverPeer
-	banPeers             chan *serverPeer
-	query                chan interface{}
-	relayInv             chan relayMsg
-	broadcast            chan broadcastMsg
-	peerHeightsUpdate    chan updatePeerHeightsMsg
-	wg                   sync.WaitGroup
-	quit                 chan struct{}
-	nat                  NAT
-	db                   database.DB
-	timeSource           blockchain.MedianTimeSource
-	services             wire.ServiceFlag
+	chainParams       *chaincfg.Params
+	addrManager       *addrmgr.AddrManager
+	connManager       *connmgr.ConnManager
+	sigCache          *txscript.SigCache
+	hashCache         *txscript.HashCache
+	rpcServer         *rpcServer
+	syncManager       *netsync.SyncManager
+	chain             *blockchain.BlockChain
+	txMemPool         *mempool.TxPool
+	cpuMiner          *cpuminer.CPUMiner
+	stratumServer     *stratum.Server
+	zmqPublisher      *zmqpub.Publisher
+	unbroadcast       *mempool.UnbroadcastSet
+	newPeers          chan *serverPeer
+	donePeers         chan *serverPeer
+	banPeers          chan *serverPeer
+	query             chan interface{}
+	relayInv          chan relayMsg
+	broadcast         chan broadcastMsg
+	peerHeightsUpdate chan updatePeerHeightsMsg
+	wg                sync.WaitGroup
+	quit              chan struct{}
+	nat               NAT
+	natListenPort     uint16
+	db                database.DB
+	timeSource        blockchain.MedianTimeSource
+	services          wire.ServiceFlag
+	misbehaviorPolicy *netsync.MisbehaviorPolicy
+
+	// uploadLimiter and downloadLimiter limit the aggregate rates at which
+	// data is sent to and received from all peers.  They are nil when the
+	// limits are disabled.
+	uploadLimiter   *peer.RateLimiter
+	downloadLimiter *peer.RateLimiter
+
+	// asMap groups outbound peers by autonomous system instead of by /16
+	// network.  It is nil when no asmap is configured.
+	asMap *addrmgr.ASMap
+
+	// The number of bytes sent to and received from all peers by message
+	// command, which are protected by the msgBytesMtx mutex.
+	msgBytesMtx     sync.Mutex
+	bytesSentPerMsg map[string]uint64
+	bytesRecvPerMsg map[string]uint64
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
+	txIndex         *indexers.TxIndex
+	addrIndex       *indexers.AddrIndex
+	cfIndex         *indexers.CfIndex
+	blockStatsIndex *indexers.BlockStatsIndex
+	addrUtxoIndex   *indexers.AddrUtxoIndex
+	spentIndex      *indexers.SpentIndex
+	timestampIndex  *indexers.TimestampIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
@@ -266,8 +333,16 @@ type serverPeer struct {
 	isWhitelisted  bool
 	filter         *bloom.Filter
 	knownAddresses map[string]struct{}
-	banScore       connmgr.DynamicBanScore
+	misbehavior    *netsync.PeerMisbehavior
 	quit           chan struct{}
+
+	// feeFilterMtx protects the fee rate of the last feefilter message
+	// sent to the peer and the time it was sent, which is zero until the
+	// version negotiation completed.
+	feeFilterMtx  sync.Mutex
+	sentFeeFilter int64
+	feeFilterSent time.Time
+
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
@@ -281,6 +356,7 @@ func newServerPeer(s *server, isPersistent bool) *serverPeer {
 		persistent:     isPersistent,
 		filter:         bloom.LoadFilter(nil),
 		knownAddresses: make(map[string]struct{}),
+		misbehavior:    netsync.NewPeerMisbehavior(s.misbehaviorPolicy),
 		quit:           make(chan struct{}),
 		txProcessed:    make(chan struct{}, 1),
 		blockProcessed: make(chan struct{}, 1),
@@ -308,6 +384,21 @@ func (sp *serverPeer) addressKnown(na *wire.NetAddress) bool {
 	return exists
 }
 
+// addKnownAddressesV2 adds the given addrv2 addresses to the set of known
+// addresses to the peer to prevent sending duplicate addresses.
+func (sp *serverPeer) addKnownAddressesV2(addresses []*wire.NetAddressV2) {
+	for _, na := range addresses {
+		sp.knownAddresses[addrmgr.NetAddressKeyV2(na)] = struct{}{}
+	}
+}
+
+// addressKnownV2 true if the given addrv2 address is already known to the
+// peer.
+func (sp *serverPeer) addressKnownV2(na *wire.NetAddressV2) bool {
+	_, exists := sp.knownAddresses[addrmgr.NetAddressKeyV2(na)]
+	return exists
+}
+
 // setDisableRelayTx toggles relaying of transactions for the given peer.
 // It is safe for concurrent access.
 func (sp *serverPeer) setDisableRelayTx(disable bool) {
@@ -346,42 +437,64 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 	sp.addKnownAddresses(known)
 }
 
-// addBanScore increases the persistent and decaying ban score fields by the
-// values passed as parameters. If the resulting score exceeds half of the ban
-// threshold, a warning is logged including the reason provided. Further, if
-// the score is above the ban threshold, the peer will be banned and
-// disconnected.
-func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
-	// No warning is logged and no score is calculated if banning is disabled.
-	if cfg.DisableBanning {
-		return
+// pushAddrV2Msg sends an addrv2 message to the connected peer using the
+// provided addresses.
+func (sp *serverPeer) pushAddrV2Msg(addresses []*wire.NetAddressV2) {
+	// Filter addresses already known to the peer.
+	addrs := make([]*wire.NetAddressV2, 0, len(addresses))
+	for _, addr := range addresses {
+		if !sp.addressKnownV2(addr) {
+			addrs = append(addrs, addr)
+		}
 	}
-	if sp.isWhitelisted {
-		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
+	known, err := sp.PushAddrV2Msg(addrs)
+	if err != nil {
+		peerLog.Errorf("Can't push address message to %s: %v", sp.Peer, err)
+		sp.Disconnect()
 		return
 	}
+	sp.addKnownAddressesV2(known)
+}
+
+// misbehaving penalizes the peer for the passed offense according to the
+// misbehavior policy of the server.  A warning including the reason is logged
+// once the ban score exceeds half of the ban threshold, and the peer is
+// disconnected or banned as required by the policy.  Whitelisted peers are
+// never penalized, but they are still disconnected when the penalty of the
+// offense requires it.
+func (sp *serverPeer) misbehaving(offense netsync.Offense, reason string) {
+	sp.misbehavingFraction(offense, 1, 1, reason)
+}
 
-	warnThreshold := cfg.BanThreshold >> 1
-	if transient == 0 && persistent == 0 {
-		// The score is not being increased, but a warning message is still
-		// logged if the score is above the warn threshold.
-		score := sp.banScore.Int()
-		if score > warnThreshold {
-			peerLog.Warnf("Misbehaving peer %s: %s -- ban score is %d, "+
-				"it was not increased this time", sp, reason, score)
+// misbehavingFraction penalizes the peer with the fraction num/denom of the
+// penalty of the passed offense.  See misbehaving for details.
+func (sp *serverPeer) misbehavingFraction(offense netsync.Offense, num, denom uint32, reason string) {
+	policy := sp.server.misbehaviorPolicy
+	if sp.isWhitelisted {
+		peerLog.Debugf("Misbehaving whitelisted peer %s: %s (%s)", sp,
+			reason, offense)
+		if policy.Penalties[offense].Disconnect {
+			sp.Disconnect()
 		}
 		return
 	}
-	score := sp.banScore.Increase(persistent, transient)
-	if score > warnThreshold {
-		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
-			sp, reason, score)
-		if score > cfg.BanThreshold {
-			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
-				sp)
-			sp.server.BanPeer(sp)
-			sp.Disconnect()
-		}
+
+	record := sp.misbehavior.MisbehavedFraction(offense, num, denom, reason)
+	if !policy.DisableBanning && record.Score > policy.BanThreshold>>1 {
+		peerLog.Warnf("Misbehaving peer %s: %s (%s) -- ban score is %d",
+			sp, reason, offense, record.Score)
+	}
+	switch record.Action {
+	case netsync.MisbehaviorBan:
+		peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
+			sp)
+		sp.server.BanPeer(sp)
+		sp.Disconnect()
+
+	case netsync.MisbehaviorDisconnect:
+		peerLog.Infof("Misbehaving peer %s: %s (%s) -- disconnecting",
+			sp, reason, offense)
+		sp.Disconnect()
 	}
 }
 
@@ -414,7 +527,8 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 
 	// Ignore peers that have a protcol version that is too old.  The peer
 	// negotiation logic will disconnect it after this callback returns.
-	if msg.ProtocolVersion < int32(peer.MinAcceptableProtocolVersion) {
+	minVersion := peer.MinAcceptableVersion(sp.server.chainParams)
+	if msg.ProtocolVersion < int32(minVersion) {
 		return nil
 	}
 
@@ -430,6 +544,17 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 		return wire.NewMsgReject(msg.Command(), wire.RejectNonstandard, reason)
 	}
 
+	// Feeler connections only test whether the address is reachable, so
+	// mark the address as a known good one and disconnect.
+	if sp.connReq != nil && sp.connReq.Feeler {
+		if !cfg.SimNet {
+			addrManager.Good(remoteAddr)
+		}
+		srvrLog.Debugf("Disconnecting feeler connection %s", sp)
+		sp.Disconnect()
+		return nil
+	}
+
 	// Update the address manager and request known addresses from the
 	// remote peer for outbound connections.  This is skipped when running
 	// on the simulation test network since it is only intended to connect
@@ -502,9 +627,8 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// Only allow mempool requests if the server has bloom filtering
 	// enabled.
 	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
-		peerLog.Debugf("peer %v sent mempool request with bloom "+
-			"filtering disabled -- disconnecting", sp)
-		sp.Disconnect()
+		sp.misbehaving(netsync.OffenseUnsupportedRequest,
+			"mempool request with bloom filtering disabled")
 		return
 	}
 
@@ -512,7 +636,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
 	// half of its value.
-	sp.addBanScore(0, 33, "mempool")
+	sp.misbehaving(netsync.OffenseMempoolRequest, "mempool")
 
 	// Generate inventory message with the available transactions in the
 	// transaction memory pool.  Limit it to the max allowed inventory
@@ -600,6 +724,8 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
+	sp.server.txAnnounced(msg.InvList)
+
 	if !cfg.BlocksOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
@@ -613,9 +739,9 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
 				"blocksonly enabled", invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
-				peerLog.Infof("Peer %v is announcing "+
-					"transactions -- disconnecting", sp)
-				sp.Disconnect()
+				sp.misbehaving(netsync.OffenseUnwantedTx,
+					"announcing transactions with "+
+						"blocksonly enabled")
 				return
 			}
 			continue
@@ -652,7 +778,8 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 	// bursts of small requests are not penalized as that would potentially ban
 	// peers performing IBD.
 	// This incremental score decays each minute to half of its value.
-	sp.addBanScore(0, uint32(length)*99/wire.MaxInvPerMsg, "getdata")
+	sp.misbehavingFraction(netsync.OffenseGetDataRequest, uint32(length),
+		wire.MaxInvPerMsg, "getdata")
 
 	// We wait on this wait channel periodically to prevent queuing
 	// far more data than we can send in a reasonable time, wasting memory.
@@ -767,7 +894,7 @@ func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
 
 	// Find the most recent known block in the best chain based on the block
 	// locator and fetch all of the headers after it until either
-	// wire.MaxBlockHeadersPerMsg have been fetched or the provided stop
+	// wire.DefaultBlockHeadersPerMsg have been fetched or the provided stop
 	// hash is encountered.
 	//
 	// Use the block after the genesis block if no other blocks in the
@@ -800,7 +927,7 @@ func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
 		break
 
 	default:
-		peerLog.Debug("Filter request for unknown filter: %v",
+		peerLog.Debugf("Filter request for unknown filter: %v",
 			msg.FilterType)
 		return
 	}
@@ -856,7 +983,7 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 		break
 
 	default:
-		peerLog.Debug("Filter request for unknown headers for "+
+		peerLog.Debugf("Filter request for unknown headers for "+
 			"filter: %v", msg.FilterType)
 		return
 	}
@@ -877,6 +1004,7 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 	)
 	if err != nil {
 		peerLog.Debugf("Invalid getcfheaders request: %v", err)
+		return
 	}
 
 	// This is possible if StartHeight is one greater that the height of
@@ -973,7 +1101,7 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 		break
 
 	default:
-		peerLog.Debug("Filter request for unknown checkpoints for "+
+		peerLog.Debugf("Filter request for unknown checkpoints for "+
 			"filter: %v", msg.FilterType)
 		return
 	}
@@ -1116,35 +1244,100 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 // it will be banned since it is intentionally violating the protocol.
 func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
-		// Ban the peer if the protocol version is high enough that the
-		// peer is knowingly violating the protocol and banning is
-		// enabled.
-		//
-		// NOTE: Even though the addBanScore function already examines
-		// whether or not banning is enabled, it is checked here as well
-		// to ensure the violation is logged and the peer is
-		// disconnected regardless.
-		if sp.ProtocolVersion() >= wire.BIP0111Version &&
-			!cfg.DisableBanning {
-
-			// Disconnect the peer regardless of whether it was
-			// banned.
-			sp.addBanScore(100, 0, cmd)
-			sp.Disconnect()
+		// The peer is knowingly violating the protocol when its
+		// protocol version is high enough.
+		reason := fmt.Sprintf("unsupported %s request", cmd)
+		if sp.ProtocolVersion() >= wire.BIP0111Version {
+			sp.misbehaving(netsync.OffenseProtocolViolation, reason)
 			return false
 		}
 
-		// Disconnect the peer regardless of protocol version or banning
-		// state.
-		peerLog.Debugf("%s sent an unsupported %s request -- "+
-			"disconnecting", sp, cmd)
-		sp.Disconnect()
+		sp.misbehaving(netsync.OffenseUnsupportedRequest, reason)
 		return false
 	}
 
 	return true
 }
 
+// OnVerAck is invoked when a peer receives a verack bitcoin message and is
+// used to send messages which must only be sent once the remote peer has
+// completed the version negotiation, such as the local fee filter.
+func (sp *serverPeer) OnVerAck(_ *peer.Peer, _ *wire.MsgVerAck) {
+	sp.pushFeeFilterMsg(true)
+	sp.pushSendHeadersMsg()
+}
+
+// pushSendHeadersMsg sends a sendheaders message to the connected peer
+// requesting that new blocks are announced with headers messages instead of
+// inventory vectors as described by BIP0130, which saves a round trip.
+// Nothing is sent to peers with a protocol version that does not support the
+// message.
+func (sp *serverPeer) pushSendHeadersMsg() {
+	if sp.ProtocolVersion() < wire.SendHeadersVersion {
+		return
+	}
+	sp.QueueMessage(wire.NewMsgSendHeaders(), nil)
+}
+
+// feeFilterRate returns the fee rate in satoshi/kB peers are requested not to
+// announce transactions below with feefilter messages.  It is the minimum fee
+// rate of the transaction pool, which rises above the minimum relay fee while
+// the pool is full, since transactions paying less would be rejected anyway.
+// When running in blocks only mode, the maximum possible fee rate is returned
+// so no transactions are announced at all.
+func (s *server) feeFilterRate() int64 {
+	if cfg.BlocksOnly {
+		return ulordutil.MaxSatoshi
+	}
+	return int64(s.txMemPool.MinFeeRate())
+}
+
+// feeFilterDue returns whether a feefilter message requesting the passed fee
+// rate is due to be sent to the peer at the passed time, and records it as sent
+// when it is.  The initial message, which is sent once the version negotiation
+// completed, is due unless the rate is zero.  Later messages are due when the
+// rate changed, but no sooner than feeFilterUpdateInterval after the previous
+// one.
+func (sp *serverPeer) feeFilterDue(rate int64, now time.Time, initial bool) bool {
+	sp.feeFilterMtx.Lock()
+	defer sp.feeFilterMtx.Unlock()
+
+	if initial {
+		sp.sentFeeFilter = rate
+		sp.feeFilterSent = now
+		return rate > 0
+	}
+
+	// Updates are not sent before the initial message.
+	if sp.feeFilterSent.IsZero() || rate == sp.sentFeeFilter ||
+		now.Sub(sp.feeFilterSent) < feeFilterUpdateInterval {
+
+		return false
+	}
+	sp.sentFeeFilter = rate
+	sp.feeFilterSent = now
+	return true
+}
+
+// pushFeeFilterMsg sends a feefilter message to the connected peer requesting
+// that no transactions with a fee rate below the one returned by feeFilterRate
+// are announced when it is due as described by feeFilterDue.  The initial
+// message is sent once the version negotiation completed and later ones when
+// the minimum fee rate of the transaction pool changes.  Nothing is sent to
+// peers with a protocol version that does not support the message.
+func (sp *serverPeer) pushFeeFilterMsg(initial bool) {
+	if sp.ProtocolVersion() < wire.FeeFilterVersion {
+		return
+	}
+
+	rate := sp.server.feeFilterRate()
+	if !sp.feeFilterDue(rate, time.Now(), initial) {
+		return
+	}
+
+	sp.QueueMessage(wire.NewMsgFeeFilter(rate), nil)
+}
+
 // OnFeeFilter is invoked when a peer receives a feefilter bitcoin message and
 // is used by remote peers to request that no transactions which have a fee rate
 // lower than provided value are inventoried to them.  The peer will be
@@ -1152,15 +1345,33 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 func (sp *serverPeer) OnFeeFilter(_ *peer.Peer, msg *wire.MsgFeeFilter) {
 	// Check that the passed minimum fee is a valid amount.
 	if msg.MinFee < 0 || msg.MinFee > ulordutil.MaxSatoshi {
-		peerLog.Debugf("Peer %v sent an invalid feefilter '%v' -- "+
-			"disconnecting", sp, ulordutil.Amount(msg.MinFee))
-		sp.Disconnect()
+		reason := fmt.Sprintf("invalid feefilter '%v'",
+			ulordutil.Amount(msg.MinFee))
+		sp.misbehaving(netsync.OffenseInvalidFeeFilter, reason)
 		return
 	}
 
 	atomic.StoreInt64(&sp.feeFilter, msg.MinFee)
 }
 
+// OnMNBroadcast is invoked when a peer receives an mnb message.  The
+// announcement is validated and relayed by the sync manager.
+func (sp *serverPeer) OnMNBroadcast(_ *peer.Peer, msg *wire.MsgMNBroadcast) {
+	sp.server.syncManager.QueueMasternodeMsg(msg, sp.Peer)
+}
+
+// OnMNPing is invoked when a peer receives an mnp message.  The ping is
+// validated and relayed by the sync manager.
+func (sp *serverPeer) OnMNPing(_ *peer.Peer, msg *wire.MsgMNPing) {
+	sp.server.syncManager.QueueMasternodeMsg(msg, sp.Peer)
+}
+
+// OnTxLockVote is invoked when a peer receives a txlvote message.  The vote is
+// validated and relayed by the sync manager.
+func (sp *serverPeer) OnTxLockVote(_ *peer.Peer, msg *wire.MsgTxLockVote) {
+	sp.server.syncManager.QueueMasternodeMsg(msg, sp.Peer)
+}
+
 // OnFilterAdd is invoked when a peer receives a filteradd bitcoin
 // message and is used by remote peers to add data to an already loaded bloom
 // filter.  The peer will be disconnected if a filter is not loaded when this
@@ -1173,9 +1384,8 @@ func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 	}
 
 	if !sp.filter.IsLoaded() {
-		peerLog.Debugf("%s sent a filteradd request with no filter "+
-			"loaded -- disconnecting", sp)
-		sp.Disconnect()
+		sp.misbehaving(netsync.OffenseFilterNotLoaded,
+			"filteradd request with no filter loaded")
 		return
 	}
 
@@ -1194,9 +1404,8 @@ func (sp *serverPeer) OnFilterClear(_ *peer.Peer, msg *wire.MsgFilterClear) {
 	}
 
 	if !sp.filter.IsLoaded() {
-		peerLog.Debugf("%s sent a filterclear request with no "+
-			"filter loaded -- disconnecting", sp)
-		sp.Disconnect()
+		sp.misbehaving(netsync.OffenseFilterNotLoaded,
+			"filterclear request with no filter loaded")
 		return
 	}
 
@@ -1249,11 +1458,14 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 	}
 	sp.sentAddrs = true
 
-	// Get the current known addresses from the address manager.
-	addrCache := sp.server.addrManager.AddressCache()
-
-	// Push the addresses.
-	sp.pushAddrMsg(addrCache)
+	// Push the current known addresses from the address manager.  Peers
+	// which support addrv2 messages are also sent the addresses which
+	// can't be represented in addr messages, such as Tor v3 addresses.
+	if sp.WantsAddrV2() {
+		sp.pushAddrV2Msg(sp.server.addrManager.AddressCacheV2())
+		return
+	}
+	sp.pushAddrMsg(sp.server.addrManager.AddressCache())
 }
 
 // OnAddr is invoked when a peer receives an addr bitcoin message and is
@@ -1274,9 +1486,9 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 
 	// A message that has no addresses is invalid.
 	if len(msg.AddrList) == 0 {
-		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
-			msg.Command(), sp.Peer)
-		sp.Disconnect()
+		reason := fmt.Sprintf("command [%s] does not contain any "+
+			"addresses", msg.Command())
+		sp.misbehaving(netsync.OffenseEmptyAddr, reason)
 		return
 	}
 
@@ -1306,16 +1518,62 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
 }
 
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to notify the server about advertised addresses like OnAddr, including
+// the addresses of networks which addr messages can't represent.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	// Ignore addresses when running on the simulation test network.  See
+	// OnAddr for details.
+	if cfg.SimNet {
+		return
+	}
+
+	// A message that has no addresses is invalid.
+	if len(msg.AddrList) == 0 {
+		reason := fmt.Sprintf("command [%s] does not contain any "+
+			"addresses", msg.Command())
+		sp.misbehaving(netsync.OffenseEmptyAddr, reason)
+		return
+	}
+
+	for _, na := range msg.AddrList {
+		// Don't add more address if we're disconnecting.
+		if !sp.Connected() {
+			return
+		}
+
+		// Set the timestamp to 5 days ago if it's more than 24 hours
+		// in the future so this address is one of the first to be
+		// removed when space is needed.
+		now := time.Now()
+		if na.Timestamp.After(now.Add(time.Minute * 10)) {
+			na.Timestamp = now.Add(-1 * time.Hour * 24 * 5)
+		}
+
+		// Add address to known addresses for this peer.
+		sp.addKnownAddressesV2([]*wire.NetAddressV2{na})
+	}
+
+	// Add addresses to server address manager.
+	sp.server.addrManager.AddAddressesV2(msg.AddrList, sp.NA())
+}
+
 // OnRead is invoked when a peer receives a message and it is used to update
 // the bytes received by the server.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	if msg != nil {
+		sp.server.addMsgBytes(sp.server.bytesRecvPerMsg, msg.Command(),
+			bytesRead)
+	}
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
 // the bytes sent by the server.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	sp.server.addMsgBytes(sp.server.bytesSentPerMsg, msg.Command(),
+		bytesWritten)
 }
 
 // randomUint16Number returns a random uint16 in a specified input range.  Note
@@ -1336,26 +1594,37 @@ func randomUint16Number(max uint16) uint16 {
 	}
 }
 
-// AddRebroadcastInventory adds 'iv' to the list of inventories to be
-// rebroadcasted at random intervals until they show up in a block.
-func (s *server) AddRebroadcastInventory(iv *wire.InvVect, data interface{}) {
-	// Ignore if shutting down.
-	if atomic.LoadInt32(&s.shutdown) != 0 {
-		return
-	}
+// AddUnbroadcastTransaction adds the transaction of the passed descriptor to
+// the transactions which are rebroadcast until a peer announces or requests
+// them, or they leave the mempool.
+func (s *server) AddUnbroadcastTransaction(txD *mempool.TxDesc) {
+	s.unbroadcast.Add(txD, time.Now())
+}
 
-	s.modifyRebroadcastInv <- broadcastInventoryAdd{invVect: iv, data: data}
+// UnbroadcastTransactions returns the transactions submitted through the RPC
+// server which have not been announced or requested by any peer yet, ordered
+// by the time they were submitted.
+func (s *server) UnbroadcastTransactions() []mempool.UnbroadcastTx {
+	return s.unbroadcast.Txns()
 }
 
-// RemoveRebroadcastInventory removes 'iv' from the list of items to be
-// rebroadcasted if present.
-func (s *server) RemoveRebroadcastInventory(iv *wire.InvVect) {
-	// Ignore if shutting down.
-	if atomic.LoadInt32(&s.shutdown) != 0 {
+// txAnnounced removes the transactions of the passed inventory vectors, which
+// were announced by a peer, from the transactions to rebroadcast since they
+// have reached the network.
+func (s *server) txAnnounced(invList []*wire.InvVect) {
+	if s.unbroadcast.Count() == 0 {
 		return
 	}
 
-	s.modifyRebroadcastInv <- broadcastInventoryDel(iv)
+	for _, iv := range invList {
+		if iv.Type != wire.InvTypeTx && iv.Type != wire.InvTypeWitnessTx {
+			continue
+		}
+		if s.unbroadcast.Remove(&iv.Hash) {
+			srvrLog.Debugf("Transaction %v reached the network",
+				iv.Hash)
+		}
+	}
 }
 
 // relayTransactions generates and relays inventory vectors for all of the
@@ -1381,18 +1650,57 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	if s.rpcServer != nil {
 		s.rpcServer.NotifyNewTransactions(txns)
 	}
+
+	// Publish the newly accepted transactions to ZeroMQ subscribers along
+	// with the ones which are locked by InstantSend.  Transactions locked
+	// after they were accepted are published by txLocked.
+	if s.zmqPublisher != nil {
+		for _, txD := range txns {
+			s.zmqPublisher.NotifyNewTransaction(txD.Tx)
+			if txD.Locked {
+				s.zmqPublisher.NotifyTxLocked(txD.Tx)
+			}
+		}
+	}
 }
 
 // Transaction has one confirmation on the main chain. Now we can mark it as no
 // longer needing rebroadcasting.
 func (s *server) TransactionConfirmed(tx *ulordutil.Tx) {
-	// Rebroadcasting is only necessary when the RPC server is active.
-	if s.rpcServer == nil {
-		return
+	s.unbroadcast.Remove(tx.Hash())
+}
+
+// txReplaced is invoked by the mempool when transactions are evicted by a
+// transaction replacing them per BIP0125.  The evicted transactions will never
+// confirm, so they no longer need rebroadcasting.
+func (s *server) txReplaced(evicted []*ulordutil.Tx, replacement *ulordutil.Tx) {
+	srvrLog.Debugf("Transaction %v replaced %d transactions in the mempool",
+		replacement.Hash(), len(evicted))
+
+	for _, tx := range evicted {
+		s.unbroadcast.Remove(tx.Hash())
 	}
+}
 
-	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
-	s.RemoveRebroadcastInventory(iv)
+// txEvicted is called by the mempool for every transaction it evicts because
+// the transaction expired or to keep the pool within its maximum size.  The
+// evicted transaction is removed from the rebroadcast inventory.
+func (s *server) txEvicted(tx *ulordutil.Tx, reason mempool.EvictionReason) {
+	srvrLog.Debugf("Transaction %v evicted from the mempool (%v)",
+		tx.Hash(), reason)
+
+	s.unbroadcast.Remove(tx.Hash())
+}
+
+// txLocked is called by the mempool for every transaction in the pool which is
+// locked by a masternode quorum after it was accepted.  The locked transaction
+// is published to ZeroMQ subscribers.
+func (s *server) txLocked(tx *ulordutil.Tx) {
+	srvrLog.Debugf("Transaction %v in the mempool locked", tx.Hash())
+
+	if s.zmqPublisher != nil {
+		s.zmqPublisher.NotifyTxLocked(tx)
+	}
 }
 
 // pushTxMsg sends a tx message for the provided transaction hash to the
@@ -1421,6 +1729,12 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 
 	sp.QueueMessageWithEncoding(tx.MsgTx(), doneChan, encoding)
 
+	// A peer requesting the transaction has received it, so it no longer
+	// needs rebroadcasting.
+	if s.unbroadcast.Remove(hash) {
+		srvrLog.Debugf("Transaction %v reached the network", hash)
+	}
+
 	return nil
 }
 
@@ -1629,7 +1943,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.asMap.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1653,7 +1967,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.asMap.GroupKey(sp.NA())]--
 		}
 		if !sp.Inbound() && sp.connReq != nil {
 			s.connManager.Disconnect(sp.connReq.ID())
@@ -1686,9 +2000,15 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		return
 	}
 	direction := directionString(sp.Inbound())
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	banDuration := s.misbehaviorPolicy.BanDuration
+	reason := "unknown reason"
+	if records := sp.misbehavior.Records(); len(records) > 0 {
+		last := records[len(records)-1]
+		reason = fmt.Sprintf("%s (%s)", last.Reason, last.Offense)
+	}
+	srvrLog.Infof("Banned peer %s (%s) for %v: %s", host, direction,
+		banDuration, reason)
+	state.banned[host] = time.Now().Add(banDuration)
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
@@ -1700,8 +2020,8 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 		}
 
 		// If the inventory is a block and the peer prefers headers,
-		// generate and send a headers message instead of an inventory
-		// message.
+		// announce it with a headers message instead of an inventory
+		// message when the peer is able to connect the header.
 		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
 			blockHeader, ok := msg.data.(wire.BlockHeader)
 			if !ok {
@@ -1709,14 +2029,9 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 					" is not a block header")
 				return
 			}
-			msgHeaders := wire.NewMsgHeaders()
-			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
-				peerLog.Errorf("Failed to add block"+
-					" header: %v", err)
+			if sp.AnnounceBlockHeader(&blockHeader) {
 				return
 			}
-			sp.QueueMessage(msgHeaders, nil)
-			return
 		}
 
 		if msg.invVect.Type == wire.InvTypeTx {
@@ -1770,6 +2085,10 @@ func (s *server) handleBroadcastMsg(state *peerState, bmsg *broadcastMsg) {
 				return
 			}
 		}
+		if sp.Peer == bmsg.origin ||
+			sp.ProtocolVersion() < bmsg.minProtocolVersion {
+			return
+		}
 
 		sp.QueueMessage(bmsg.message, nil)
 	})
@@ -1803,6 +2122,11 @@ type connectNodeMsg struct {
 	reply     chan error
 }
 
+type getPeerMsg struct {
+	peer  *peer.Peer
+	reply chan *serverPeer
+}
+
 type removeNodeMsg struct {
 	cmp   func(*serverPeer) bool
 	reply chan error
@@ -1821,6 +2145,15 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		})
 		msg.reply <- nconnected
 
+	case getPeerMsg:
+		var found *serverPeer
+		state.forAllPeers(func(sp *serverPeer) {
+			if sp.Peer == msg.peer {
+				found = sp
+			}
+		})
+		msg.reply <- found
+
 	case getPeersMsg:
 		peers := make([]*serverPeer, 0, state.Count())
 		state.forAllPeers(func(sp *serverPeer) {
@@ -1865,7 +2198,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.asMap.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1901,7 +2234,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.asMap.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1909,7 +2242,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.asMap.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -1949,6 +2282,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 	return &peer.Config{
 		Listeners: peer.MessageListeners{
 			OnVersion:      sp.OnVersion,
+			OnVerAck:       sp.OnVerAck,
 			OnMemPool:      sp.OnMemPool,
 			OnTx:           sp.OnTx,
 			OnBlock:        sp.OnBlock,
@@ -1961,11 +2295,15 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnGetCFHeaders: sp.OnGetCFHeaders,
 			OnGetCFCheckpt: sp.OnGetCFCheckpt,
 			OnFeeFilter:    sp.OnFeeFilter,
+			OnMNBroadcast:  sp.OnMNBroadcast,
+			OnMNPing:       sp.OnMNPing,
+			OnTxLockVote:   sp.OnTxLockVote,
 			OnFilterAdd:    sp.OnFilterAdd,
 			OnFilterClear:  sp.OnFilterClear,
 			OnFilterLoad:   sp.OnFilterLoad,
 			OnGetAddr:      sp.OnGetAddr,
 			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
 			OnRead:         sp.OnRead,
 			OnWrite:        sp.OnWrite,
 
@@ -1984,8 +2322,12 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
 		DisableRelayTx:    cfg.BlocksOnly,
-		ProtocolVersion:   peer.MaxProtocolVersion,
+		ProtocolVersion:   peer.MaxVersion(sp.server.chainParams),
 		TrickleInterval:   cfg.TrickleInterval,
+		MaxUploadRate:     cfg.MaxPeerUploadRate * 1000,
+		MaxDownloadRate:   cfg.MaxPeerDownloadRate * 1000,
+		UploadLimiter:     sp.server.uploadLimiter,
+		DownloadLimiter:   sp.server.downloadLimiter,
 	}
 }
 
@@ -2123,6 +2465,12 @@ out:
 	s.syncManager.Stop()
 	s.addrManager.Stop()
 
+	// Write the changes to the utxo set held in memory to the database now
+	// that blocks are no longer processed.
+	if err := s.chain.FlushUtxoCache(blockchain.FlushRequired); err != nil {
+		srvrLog.Errorf("Unable to flush the utxo cache: %v", err)
+	}
+
 	// Drain channels before exiting so nothing is left waiting around
 	// to send.
 cleanup:
@@ -2152,6 +2500,39 @@ func (s *server) BanPeer(sp *serverPeer) {
 	s.banPeers <- sp
 }
 
+// PeerMisbehaved penalizes the passed peer for an offense detected by the sync
+// manager according to the misbehavior policy of the server.  The peer is
+// looked up and penalized asynchronously so the caller is never blocked by the
+// peer handler.  It is part of the netsync.PeerNotifier interface.
+func (s *server) PeerMisbehaved(p *peer.Peer, offense netsync.Offense, reason string) {
+	go func() {
+		reply := make(chan *serverPeer, 1)
+		select {
+		case s.query <- getPeerMsg{peer: p, reply: reply}:
+		case <-s.quit:
+			return
+		}
+		select {
+		case sp := <-reply:
+			if sp != nil {
+				sp.misbehaving(offense, reason)
+			}
+		case <-s.quit:
+		}
+	}()
+}
+
+// RelayMasternodeMessage relays the passed masternode message to all connected
+// peers which support masternode messages except the peer it was received
+// from.  It is part of the netsync.PeerNotifier interface.
+func (s *server) RelayMasternodeMessage(msg wire.Message, source *peer.Peer) {
+	s.broadcast <- broadcastMsg{
+		message:            msg,
+		origin:             source,
+		minProtocolVersion: wire.MasternodeVersion,
+	}
+}
+
 // RelayInventory relays the passed inventory vector to all connected peers
 // that are not already known to have it.
 func (s *server) RelayInventory(invVect *wire.InvVect, data interface{}) {
@@ -2203,6 +2584,31 @@ func (s *server) NetTotals() (uint64, uint64) {
 		atomic.LoadUint64(&s.bytesSent)
 }
 
+// addMsgBytes adds the passed number of bytes to the passed per message totals
+// for the passed command.  It is safe for concurrent access.
+func (s *server) addMsgBytes(perMsg map[string]uint64, command string, n int) {
+	s.msgBytesMtx.Lock()
+	perMsg[command] += uint64(n)
+	s.msgBytesMtx.Unlock()
+}
+
+// NetTotalsPerMsg returns the sums of all bytes received and sent across the
+// network for all peers by message command.  It is safe for concurrent access.
+func (s *server) NetTotalsPerMsg() (map[string]uint64, map[string]uint64) {
+	s.msgBytesMtx.Lock()
+	defer s.msgBytesMtx.Unlock()
+
+	recv := make(map[string]uint64, len(s.bytesRecvPerMsg))
+	for command, n := range s.bytesRecvPerMsg {
+		recv[command] = n
+	}
+	sent := make(map[string]uint64, len(s.bytesSentPerMsg))
+	for command, n := range s.bytesSentPerMsg {
+		sent[command] = n
+	}
+	return recv, sent
+}
+
 // UpdatePeerHeights updates the heights of all peers who have have announced
 // the latest connected main chain block, or a recognized orphan. These height
 // updates allow us to dynamically refresh peer heights, ensuring sync peer
@@ -2215,61 +2621,69 @@ func (s *server) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight i
 	}
 }
 
-// rebroadcastHandler keeps track of user submitted inventories that we have
-// sent out but have not yet made it into a block. We periodically rebroadcast
-// them in case our peers restarted or otherwise lost track of them.
+// rebroadcastHandler periodically rebroadcasts the transactions submitted
+// through the RPC server which have not been announced or requested by any peer
+// yet, in case our peers restarted or otherwise lost track of them.  The time
+// between the rebroadcasts of a transaction doubles with every attempt.
 func (s *server) rebroadcastHandler() {
-	// Wait 5 min before first tx rebroadcast.
-	timer := time.NewTimer(5 * time.Minute)
-	pendingInvs := make(map[wire.InvVect]interface{})
+	ticker := time.NewTicker(unbroadcastCheckInterval)
+	defer ticker.Stop()
 
 out:
 	for {
 		select {
-		case riv := <-s.modifyRebroadcastInv:
-			switch msg := riv.(type) {
-			// Incoming InvVects are added to our map of RPC txs.
-			case broadcastInventoryAdd:
-				pendingInvs[*msg.invVect] = msg.data
-
-			// When an InvVect has been added to a block, we can
-			// now remove it, if it was present.
-			case broadcastInventoryDel:
-				if _, ok := pendingInvs[*msg]; ok {
-					delete(pendingInvs, *msg)
+		case <-ticker.C:
+			for _, txD := range s.unbroadcast.Due(time.Now()) {
+				// Transactions which left the pool without
+				// being mined, evicted or replaced will never
+				// be relayed, so stop tracking them.
+				hash := txD.Tx.Hash()
+				if !s.txMemPool.IsTransactionInPool(hash) {
+					s.unbroadcast.Remove(hash)
+					continue
 				}
-			}
 
-		case <-timer.C:
-			// Any inventory we have has not made it into a block
-			// yet. We periodically resubmit them until they have.
-			for iv, data := range pendingInvs {
-				ivCopy := iv
-				s.RelayInventory(&ivCopy, data)
+				srvrLog.Debugf("Rebroadcasting transaction %v",
+					hash)
+				iv := wire.NewInvVect(wire.InvTypeTx, hash)
+				s.RelayInventory(iv, txD)
 			}
 
-			// Process at a random time up to 30mins (in seconds)
-			// in the future.
-			timer.Reset(time.Second *
-				time.Duration(randomUint16Number(1800)))
-
 		case <-s.quit:
 			break out
 		}
 	}
 
-	timer.Stop()
+	s.wg.Done()
+}
 
-	// Drain channels before exiting so nothing is left waiting around
-	// to send.
-cleanup:
+// feeFilterHandler periodically sends feefilter messages with the current
+// minimum fee rate of the transaction pool to the connected peers whose last
+// feefilter message requested a different fee rate.  It must be run as a
+// goroutine.
+func (s *server) feeFilterHandler() {
+	ticker := time.NewTicker(feeFilterCheckInterval)
+	defer ticker.Stop()
+
+out:
 	for {
 		select {
-		case <-s.modifyRebroadcastInv:
-		default:
-			break cleanup
+		case <-ticker.C:
+			replyChan := make(chan []*serverPeer)
+			select {
+			case s.query <- getPeersMsg{reply: replyChan}:
+			case <-s.quit:
+				break out
+			}
+			for _, sp := range <-replyChan {
+				sp.pushFeeFilterMsg(false)
+			}
+
+		case <-s.quit:
+			break out
 		}
 	}
+
 	s.wg.Done()
 }
 
@@ -2290,16 +2704,21 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Start the fee filter handler, which announces changes of the minimum
+	// fee rate of the transaction pool to peers.
+	s.wg.Add(1)
+	go s.feeFilterHandler()
+
 	if s.nat != nil {
 		s.wg.Add(1)
-		go s.upnpUpdateThread()
+		go s.natUpdateThread()
 	}
 
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
 		// Start the rebroadcastHandler, which ensures user tx received by
-		// the RPC server are rebroadcast until being included in a block.
+		// the RPC server are rebroadcast until they reach the network.
 		go s.rebroadcastHandler()
 
 		s.rpcServer.Start()
@@ -2309,6 +2728,16 @@ func (s *server) Start() {
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
+
+	// Start the ZeroMQ publisher if it is enabled.
+	if s.zmqPublisher != nil {
+		s.zmqPublisher.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2325,6 +2754,16 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Stop the stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Stop()
+	}
+
+	// Stop the ZeroMQ publisher if it is enabled.
+	if s.zmqPublisher != nil {
+		s.zmqPublisher.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2338,6 +2777,11 @@ func (s *server) Stop() error {
 		return nil
 	})
 
+	// Save the mempool so its transactions can be restored on startup.
+	if err := s.txMemPool.SaveToFile(); err != nil {
+		srvrLog.Errorf("Unable to save mempool: %v", err)
+	}
+
 	// Signal the remaining goroutines to quit.
 	close(s.quit)
 	return nil
@@ -2432,44 +2876,80 @@ func parseListeners(addrs []string) ([]net.Addr, error) {
 	return netAddrs, nil
 }
 
-func (s *server) upnpUpdateThread() {
+// randomExternalPort returns a random port outside of the range of well-known
+// ports to request from the NAT gateway when the listen port is unavailable.
+func randomExternalPort() int {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return natMinExternalPort
+	}
+	return natMinExternalPort + int(binary.LittleEndian.Uint16(b[:]))%
+		(65536-natMinExternalPort)
+}
+
+// natUpdateThread maps the listen port of the server on the NAT gateway and
+// advertises the external address it is reachable at.  The mapping is renewed
+// before its lease expires and the external address is looked up again each
+// time, so a changed address replaces the advertised one.  The mapping is
+// removed on shutdown.  It must be run as a goroutine.
+func (s *server) natUpdateThread() {
 	// Go off immediately to prevent code duplication, thereafter we renew
-	// lease every 15 minutes.
-	timer := time.NewTimer(0 * time.Second)
-	lport, _ := strconv.ParseInt(activeNetParams.DefaultPort, 10, 16)
-	first := true
+	// the lease periodically.
+	timer := time.NewTimer(0)
+	lport := int(s.natListenPort)
+	eport := lport
+	mapped := false
+	var advertised *wire.NetAddress
 out:
 	for {
 		select {
 		case <-timer.C:
-			// TODO: pick external port  more cleverly
-			// TODO: know which ports we are listening to on an external net.
-			// TODO: if specific listen port doesn't work then ask for wildcard
-			// listen port?
-			// XXX this assumes timeout is in seconds.
-			listenPort, err := s.nat.AddPortMapping("tcp", int(lport), int(lport),
-				"ulord listen port", 20*60)
+			timer.Reset(natRenewInterval)
+			port, err := s.nat.AddPortMapping("tcp", eport, lport,
+				"ulord listen port", int(natLeaseDuration.Seconds()))
 			if err != nil {
-				srvrLog.Warnf("can't add UPnP port mapping: %v", err)
-			}
-			if first && err == nil {
-				// TODO: look this up periodically to see if upnp domain changed
-				// and so did ip.
-				externalip, err := s.nat.GetExternalAddress()
-				if err != nil {
-					srvrLog.Warnf("UPnP can't get external address: %v", err)
-					continue out
-				}
-				na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
-					s.services)
-				err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
-				if err != nil {
-					// XXX DeletePortMapping?
+				srvrLog.Warnf("Can't add NAT port mapping: %v", err)
+
+				// The external port may already be mapped to
+				// another host, so retry with a random one
+				// shortly unless it was mapped before.
+				if !mapped {
+					eport = randomExternalPort()
+					timer.Reset(natRetryInterval)
 				}
-				srvrLog.Warnf("Successfully bound via UPnP to %s", addrmgr.NetAddressKey(na))
-				first = false
+				continue
+			}
+			mapped = true
+			eport = port
+
+			externalIP, err := s.nat.GetExternalAddress()
+			if err != nil {
+				srvrLog.Warnf("Can't get external address from NAT: %v",
+					err)
+				continue
 			}
-			timer.Reset(time.Minute * 15)
+			na := wire.NewNetAddressIPPort(externalIP, uint16(eport),
+				s.services)
+			key := addrmgr.NetAddressKey(na)
+			if advertised != nil &&
+				addrmgr.NetAddressKey(advertised) == key {
+
+				continue
+			}
+			if advertised != nil {
+				s.addrManager.RemoveLocalAddress(advertised)
+				advertised = nil
+			}
+			err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
+			if err != nil {
+				srvrLog.Warnf("Not advertising external address %s: %v",
+					key, err)
+				continue
+			}
+			advertised = na
+			srvrLog.Infof("Mapped listen port %d via NAT to %s", lport,
+				key)
+
 		case <-s.quit:
 			break out
 		}
@@ -2477,19 +2957,23 @@ out:
 
 	timer.Stop()
 
-	if err := s.nat.DeletePortMapping("tcp", int(lport), int(lport)); err != nil {
-		srvrLog.Warnf("unable to remove UPnP port mapping: %v", err)
-	} else {
-		srvrLog.Debugf("successfully disestablished UPnP port mapping")
+	if mapped {
+		err := s.nat.DeletePortMapping("tcp", eport, lport)
+		if err != nil {
+			srvrLog.Warnf("Unable to remove NAT port mapping: %v", err)
+		} else {
+			srvrLog.Debugf("Successfully removed NAT port mapping")
+		}
 	}
 
 	s.wg.Done()
 }
 
-// setupRPCListeners returns a slice of listeners that are configured for use
-// with the RPC server depending on the configuration settings for listen
-// addresses and TLS.
-func setupRPCListeners() ([]net.Listener, error) {
+// setupRPCListeners returns a slice of listeners on the passed addresses that
+// are configured for use with the RPC server depending on the configuration
+// settings for TLS.  The passed protocols are offered to clients during the TLS
+// handshake.
+func setupRPCListeners(listenAddrs []string, nextProtos ...string) ([]net.Listener, error) {
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
 	if !cfg.DisableTLS {
@@ -2509,6 +2993,7 @@ func setupRPCListeners() ([]net.Listener, error) {
 		tlsConfig := tls.Config{
 			Certificates: []tls.Certificate{keypair},
 			MinVersion:   tls.VersionTLS12,
+			NextProtos:   nextProtos,
 		}
 
 		// Change the standard net.Listen function to the tls one.
@@ -2517,7 +3002,7 @@ func setupRPCListeners() ([]net.Listener, error) {
 		}
 	}
 
-	netAddrs, err := parseListeners(cfg.RPCListeners)
+	netAddrs, err := parseListeners(listenAddrs)
 	if err != nil {
 		return nil, err
 	}
@@ -2535,10 +3020,87 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// setupStratumListeners returns a slice of listeners that are configured for
+// use with the stratum server depending on the configuration settings for
+// listen addresses.
+func setupStratumListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.StratumListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			minrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// loadUtxoSnapshot bootstraps the passed chain from the utxo snapshot file
+// specified by the --loadutxosnapshot option.  The snapshot is ignored when the
+// chain already contains blocks.
+func loadUtxoSnapshot(chain *blockchain.BlockChain) error {
+	if best := chain.BestSnapshot(); best.Height != 0 {
+		srvrLog.Infof("Not loading the utxo snapshot since the chain "+
+			"already contains blocks up to height %d", best.Height)
+		return nil
+	}
+
+	f, err := os.Open(cfg.LoadUtxoSnapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := chain.LoadUtxoSnapshot(f, cfg.snapshotCommitment)
+	if err != nil {
+		return fmt.Errorf("unable to load the utxo snapshot %s: %v",
+			cfg.LoadUtxoSnapshot, err)
+	}
+	srvrLog.Infof("Bootstrapped the chain from the utxo snapshot at "+
+		"block %v (height %d)", info.Hash, info.Height)
+	return nil
+}
+
+// checkCoinbasePayees returns an error when the coinbase transactions of the
+// passed network must pay masternodes or the payments of superblocks.  Their
+// payees are chosen by the masternode list and the governance system, which
+// the server does not provide a payee source for, so it could neither build
+// block templates nor validate blocks of such networks.
+func checkCoinbasePayees(chainParams *chaincfg.Params) error {
+	subsidy := &chainParams.Subsidy
+	if subsidy.MasternodePaymentPercent > 0 {
+		return fmt.Errorf("the %s network requires masternode payments "+
+			"of %d%% of the block subsidy from height %d, which are "+
+			"not supported since there is no source of masternode "+
+			"payees", chainParams.Name,
+			subsidy.MasternodePaymentPercent,
+			subsidy.MasternodePaymentHeight)
+	}
+	if subsidy.SuperblockCycle > 0 && subsidy.SuperblockBudgetPercent > 0 {
+		return fmt.Errorf("the %s network requires superblocks every %d "+
+			"blocks from height %d, which are not supported since "+
+			"there is no source of superblock payments",
+			chainParams.Name, subsidy.SuperblockCycle,
+			subsidy.SuperblockStartHeight)
+	}
+	return nil
+}
+
 // newServer returns a new ulord server configured to listen on addr for the
 // bitcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
 func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Params, interrupt <-chan struct{}) (*server, error) {
+	if err := checkCoinbasePayees(chainParams); err != nil {
+		return nil, err
+	}
+
 	services := defaultServices
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
@@ -2546,6 +3108,10 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.Prune != 0 {
+		// Pruned nodes can't serve the full block chain.
+		services &^= wire.SFNodeNetwork
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, ulordLookup)
 
@@ -2563,24 +3129,45 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	}
 
 	s := server{
-		chainParams:          chainParams,
-		addrManager:          amgr,
-		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
-		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
-		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
-		query:                make(chan interface{}),
-		relayInv:             make(chan relayMsg, cfg.MaxPeers),
-		broadcast:            make(chan broadcastMsg, cfg.MaxPeers),
-		quit:                 make(chan struct{}),
-		modifyRebroadcastInv: make(chan interface{}),
-		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
-		nat:                  nat,
-		db:                   db,
-		timeSource:           blockchain.NewMedianTime(),
-		services:             services,
-		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
-		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
-		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
+		chainParams: chainParams,
+		addrManager: amgr,
+		newPeers:    make(chan *serverPeer, cfg.MaxPeers),
+		donePeers:   make(chan *serverPeer, cfg.MaxPeers),
+		banPeers:    make(chan *serverPeer, cfg.MaxPeers),
+		query:       make(chan interface{}),
+		relayInv:    make(chan relayMsg, cfg.MaxPeers),
+		broadcast:   make(chan broadcastMsg, cfg.MaxPeers),
+		quit:        make(chan struct{}),
+		unbroadcast: mempool.NewUnbroadcastSet(
+			mempool.DefaultUnbroadcastInterval,
+			mempool.DefaultMaxUnbroadcastInterval),
+		peerHeightsUpdate: make(chan updatePeerHeightsMsg),
+		nat:               nat,
+		natListenPort:     listenPort(listeners),
+		db:                db,
+		timeSource:        blockchain.NewMedianTime(),
+		services:          services,
+		sigCache:          txscript.NewSigCache(cfg.SigCacheMaxSize),
+		hashCache:         txscript.NewHashCache(cfg.SigCacheMaxSize),
+		cfCheckptCaches:   make(map[wire.FilterType][]cfHeaderKV),
+		misbehaviorPolicy: newMisbehaviorPolicy(),
+		bytesSentPerMsg:   make(map[string]uint64),
+		bytesRecvPerMsg:   make(map[string]uint64),
+	}
+	if cfg.MaxUploadRate > 0 {
+		s.uploadLimiter = peer.NewRateLimiter(cfg.MaxUploadRate * 1000)
+	}
+	if cfg.MaxDownloadRate > 0 {
+		s.downloadLimiter = peer.NewRateLimiter(cfg.MaxDownloadRate * 1000)
+	}
+	if cfg.ASMap != "" {
+		asMap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load asmap: %v", err)
+		}
+		s.asMap = asMap
+		srvrLog.Infof("Grouping outbound peers by autonomous system "+
+			"using asmap %s", cfg.ASMap)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2614,45 +3201,87 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
 		indexes = append(indexes, s.cfIndex)
 	}
+	if cfg.BlockStatsIndex {
+		indxLog.Info("Block stats index is enabled")
+		s.blockStatsIndex = indexers.NewBlockStatsIndex(db)
+		indexes = append(indexes, s.blockStatsIndex)
+	}
+	if cfg.AddrUtxoIndex {
+		indxLog.Info("Address utxo index is enabled")
+		s.addrUtxoIndex = indexers.NewAddrUtxoIndex(db, chainParams)
+		indexes = append(indexes, s.addrUtxoIndex)
+	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent index is enabled")
+		s.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.spentIndex)
+	}
+	if cfg.TimestampIndex {
+		indxLog.Info("Timestamp index is enabled")
+		s.timestampIndex = indexers.NewTimestampIndex(db)
+		indexes = append(indexes, s.timestampIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
+	var reindexManager *indexers.Manager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		reindexManager = indexers.NewManager(db, indexes)
+		indexManager = reindexManager
 	}
 
 	// Merge given checkpoints with the default ones unless they are disabled.
 	var checkpoints []chaincfg.Checkpoint
 	if !cfg.DisableCheckpoints {
 		checkpoints = mergeCheckpoints(s.chainParams.Checkpoints, cfg.addCheckpoints)
+		if cfg.CheckpointFile != "" {
+			signed, err := loadCheckpointFile(cfg.CheckpointFile,
+				s.chainParams, cfg.checkpointPubKeys)
+			if err != nil {
+				return nil, err
+			}
+			checkpoints = mergeCheckpoints(checkpoints, signed)
+		}
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:               s.db,
+		Interrupt:        interrupt,
+		ChainParams:      s.chainParams,
+		Checkpoints:      checkpoints,
+		TimeSource:       s.timeSource,
+		SigCache:         s.sigCache,
+		IndexManager:     indexManager,
+		HashCache:        s.hashCache,
+		UtxoCacheMaxSize: uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		Prune:            cfg.Prune * 1024 * 1024,
+		MaxReorgDepth:    cfg.MaxReorgDepth,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Bootstrap the chain from a utxo snapshot when requested.  Nodes can't
+	// serve the full block chain until the blocks before it are validated.
+	if cfg.LoadUtxoSnapshot != "" {
+		if err := loadUtxoSnapshot(s.chain); err != nil {
+			return nil, err
+		}
+	}
+	if s.chain.UtxoSnapshot() != nil {
+		s.services &^= wire.SFNodeNetwork
+	}
+
 	// Search for a FeeEstimator state in the database. If none can be found
-	// or if it cannot be loaded, create a new one.
-	db.Update(func(tx database.Tx) error {
+	// or if it cannot be loaded, create a new one.  The state is left in
+	// the database so its fee statistics are still available after an
+	// unclean shutdown, in which case it is behind the chain.
+	db.View(func(tx database.Tx) error {
 		metadata := tx.Metadata()
 		feeEstimationData := metadata.Get(mempool.EstimateFeeDatabaseKey)
 		if feeEstimationData != nil {
-			// delete it from the database so that we don't try to restore the
-			// same thing again somehow.
-			metadata.Delete(mempool.EstimateFeeDatabaseKey)
-
 			// If there is an error, log it and make a new fee estimator.
 			var err error
 			s.feeEstimator, err = mempool.RestoreFeeEstimator(feeEstimationData)
@@ -2665,28 +3294,47 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		return nil
 	})
 
-	// If no feeEstimator has been found, or if the one that has been found
-	// is behind somehow, create a new one and start over.
-	if s.feeEstimator == nil || s.feeEstimator.LastKnownHeight() != s.chain.BestSnapshot().Height {
+	// If no feeEstimator has been found, create a new one.  If the one that
+	// has been found is not at the current height somehow, keep its fee
+	// statistics but stop tracking the transactions it was waiting for.
+	bestHeight := s.chain.BestSnapshot().Height
+	if s.feeEstimator == nil {
 		s.feeEstimator = mempool.NewFeeEstimator(
 			mempool.DefaultEstimateFeeMaxRollback,
 			mempool.DefaultEstimateFeeMinRegisteredBlocks)
+	} else if s.feeEstimator.LastKnownHeight() != bestHeight {
+		s.feeEstimator.Rebase(bestHeight)
 	}
 
+	// The same standardness policy is shared by the memory pool and the
+	// block template generator so both agree on which scripts are valid.
+	standardness := txscript.DefaultPolicy()
+
 	txC := mempool.Config{
 		Policy: mempool.Policy{
-			DisableRelayPriority: cfg.NoRelayPriority,
-			AcceptNonStd:         cfg.RelayNonStd,
-			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
-			MaxOrphanTxs:         cfg.MaxOrphanTxs,
-			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
-			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
-			MinRelayTxFee:        cfg.minRelayTxFee,
-			MaxTxVersion:         2,
+			DisableRelayPriority:    cfg.NoRelayPriority,
+			AcceptNonStd:            cfg.RelayNonStd,
+			FreeTxRelayLimit:        cfg.FreeTxRelayLimit,
+			MaxOrphanTxs:            cfg.MaxOrphanTxs,
+			MaxOrphanTxsPerTag:      cfg.MaxOrphanTxsPerPeer,
+			MaxOrphanTxSize:         defaultMaxOrphanTxSize,
+			MaxSigOpCostPerTx:       blockchain.MaxBlockSigOpsCost / 4,
+			MinRelayTxFee:           cfg.minRelayTxFee,
+			MaxTxVersion:            2,
+			RejectReplacement:       cfg.RejectReplacement,
+			MaxReplacementEvictions: cfg.MaxReplEvictions,
+			MaxAncestorCount:        cfg.LimitAncestorCount,
+			MaxAncestorSize:         int64(cfg.LimitAncestorSize) * 1000,
+			MaxDescendantCount:      cfg.LimitDescendantCount,
+			MaxDescendantSize:       int64(cfg.LimitDescendantSize) * 1000,
+			TxExpiry:                cfg.MempoolExpiry,
+			MaxPoolSize:             int64(cfg.MaxMempool) * 1000000,
+			Standardness:            standardness,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
 		BestHeight:     func() int32 { return s.chain.BestSnapshot().Height },
+		BestHash:       func() chainhash.Hash { return s.chain.BestSnapshot().Hash },
 		MedianTimePast: func() time.Time { return s.chain.BestSnapshot().MedianTime },
 		CalcSequenceLock: func(tx *ulordutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
 			return s.chain.CalcSequenceLock(tx, view, true)
@@ -2695,10 +3343,26 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		SigCache:           s.sigCache,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
+		IndexAddrs:         cfg.MempoolAddrIndex,
 		FeeEstimator:       s.feeEstimator,
+		TxReplaced:         s.txReplaced,
+		TxEvicted:          s.txEvicted,
+		TxLocked:           s.txLocked,
+	}
+	if !cfg.NoPersistMempool {
+		txC.PersistFile = filepath.Join(cfg.DataDir, mempoolFileName)
 	}
 	s.txMemPool = mempool.New(&txC)
 
+	// Restore the transactions saved on the last shutdown.  They are
+	// validated again since the chain may have changed in the meantime.
+	numLoaded, err := s.txMemPool.LoadFromFile()
+	if err != nil {
+		srvrLog.Warnf("Unable to load saved mempool: %v", err)
+	} else if numLoaded > 0 {
+		srvrLog.Infof("Loaded %d saved mempool transactions", numLoaded)
+	}
+
 	s.syncManager, err = netsync.New(&netsync.Config{
 		PeerNotifier:       &s,
 		Chain:              s.chain,
@@ -2707,6 +3371,8 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
 		FeeEstimator:       s.feeEstimator,
+		IndexManager:       reindexManager,
+		TrustedPeers:       cfg.trustedPeers,
 	})
 	if err != nil {
 		return nil, err
@@ -2724,6 +3390,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		Standardness:      standardness,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
@@ -2737,6 +3404,39 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		IsCurrent:              s.syncManager.IsCurrent,
 	})
 
+	if len(cfg.StratumListeners) > 0 {
+		stratumListeners, err := setupStratumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(stratumListeners) == 0 {
+			return nil, errors.New("MINR: No valid stratum listen " +
+				"address")
+		}
+
+		s.stratumServer = stratum.New(&stratum.Config{
+			ChainParams:            chainParams,
+			BlockTemplateGenerator: blockTemplateGenerator,
+			MiningAddrs:            cfg.miningAddrs,
+			ProcessBlock:           s.syncManager.ProcessBlock,
+			IsCurrent:              s.syncManager.IsCurrent,
+			Listeners:              stratumListeners,
+			Difficulty:             cfg.StratumDifficulty,
+			MinDifficulty:          cfg.StratumMinDifficulty,
+			MaxDifficulty:          cfg.StratumMaxDifficulty,
+		})
+	}
+
+	if endpoints := cfg.zmqEndpoints(); len(endpoints) > 0 {
+		s.zmqPublisher, err = zmqpub.New(&zmqpub.Config{
+			Chain:     s.chain,
+			Endpoints: endpoints,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation network is always
 	// in connect-only mode since it is only intended to connect to
@@ -2758,11 +3458,27 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				// Addresses which can't be represented as a
+				// wire.NetAddress, such as Tor v3 addresses,
+				// can't be connected to yet.
+				na := addr.NetAddress()
+				if na == nil {
+					continue
+				}
+
+				key := s.asMap.GroupKey(na)
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// Only tor hidden services can be connected to
+				// when running tor-only.
+				if cfg.OnlyOnion &&
+					!addrmgr.IsOnionCatTor(na) {
+
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2770,12 +3486,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				}
 
 				// allow nondefault ports after 50 failed tries.
-				if tries < 50 && fmt.Sprintf("%d", addr.NetAddress().Port) !=
+				if tries < 50 && fmt.Sprintf("%d", na.Port) !=
 					activeNetParams.DefaultPort {
 					continue
 				}
 
-				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				addrString := addrmgr.NetAddressKey(na)
 				return addrStringToNetAddr(addrString)
 			}
 
@@ -2783,6 +3499,39 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// Make feeler connections to addresses which were never connected to
+	// whenever new addresses are connected to automatically, so addresses
+	// which turn out to be reachable are moved to the tried table.
+	var feelerAddressFunc func() (net.Addr, error)
+	var feelerInterval time.Duration
+	if newAddressFunc != nil {
+		feelerInterval = feelerConnectionInterval
+		feelerAddressFunc = func() (net.Addr, error) {
+			for tries := 0; tries < 100; tries++ {
+				addr := s.addrManager.GetUntriedAddress()
+				if addr == nil {
+					break
+				}
+
+				na := addr.NetAddress()
+				if na == nil {
+					continue
+				}
+				if cfg.OnlyOnion && !addrmgr.IsOnionCatTor(na) {
+					continue
+				}
+				if time.Since(addr.LastAttempt()) < 10*time.Minute {
+					continue
+				}
+
+				addrString := addrmgr.NetAddressKey(na)
+				return addrStringToNetAddr(addrString)
+			}
+
+			return nil, errors.New("no valid feeler address")
+		}
+	}
+
 	// Create a connection manager.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
@@ -2796,6 +3545,24 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		Dial:           ulordDial,
 		OnConnection:   s.outboundPeerConnected,
 		GetNewAddress:  newAddressFunc,
+		GroupKey: func(addr net.Addr) string {
+			host, portStr, err := net.SplitHostPort(addr.String())
+			if err != nil {
+				return addr.String()
+			}
+			port, err := strconv.ParseUint(portStr, 10, 16)
+			if err != nil {
+				return addr.String()
+			}
+			na, err := s.addrManager.HostToNetAddress(host,
+				uint16(port), 0)
+			if err != nil {
+				return addr.String()
+			}
+			return s.asMap.GroupKey(na)
+		},
+		FeelerInterval:   feelerInterval,
+		GetFeelerAddress: feelerAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2822,7 +3589,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if !cfg.DisableRPC {
 		// Setup listeners for the configured RPC listen addresses and
 		// TLS settings.
-		rpcListeners, err := setupRPCListeners()
+		rpcListeners, err := setupRPCListeners(cfg.RPCListeners)
 		if err != nil {
 			return nil, err
 		}
@@ -2830,22 +3597,42 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			return nil, errors.New("RPCS: No valid listen address")
 		}
 
+		// The gRPC service requires HTTP/2 which is negotiated during
+		// the TLS handshake.
+		var grpcListeners []net.Listener
+		if len(cfg.GRPCListeners) != 0 {
+			grpcListeners, err = setupRPCListeners(
+				cfg.GRPCListeners, "h2")
+			if err != nil {
+				return nil, err
+			}
+			if len(grpcListeners) == 0 {
+				return nil, errors.New("gRPC: No valid listen " +
+					"address")
+			}
+		}
+
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:       rpcListeners,
+			GRPCListeners:   grpcListeners,
+			StartupTime:     s.startupTime,
+			ConnMgr:         &rpcConnManager{&s},
+			SyncMgr:         &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:      s.timeSource,
+			Chain:           s.chain,
+			ChainParams:     chainParams,
+			DB:              db,
+			TxMemPool:       s.txMemPool,
+			Generator:       blockTemplateGenerator,
+			CPUMiner:        s.cpuMiner,
+			TxIndex:         s.txIndex,
+			AddrIndex:       s.addrIndex,
+			CfIndex:         s.cfIndex,
+			FeeEstimator:    s.feeEstimator,
+			BlockStatsIndex: s.blockStatsIndex,
+			AddrUtxoIndex:   s.addrUtxoIndex,
+			SpentIndex:      s.spentIndex,
+			TimestampIndex:  s.timestampIndex,
 		})
 		if err != nil {
 			return nil, err
@@ -2863,7 +3650,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 
 // initListeners initializes the configured net listeners and adds any bound
 // addresses to the address manager. Returns the listeners and a NAT interface,
-// which is non-nil if UPnP is in use.
+// which is non-nil if UPnP or NAT-PMP is in use.
 func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wire.ServiceFlag) ([]net.Listener, NAT, error) {
 	// Listen for TCP connections at the configured addresses
 	netAddrs, err := parseListeners(listenAddrs)
@@ -2925,6 +3712,13 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 			}
 			// nil nat here is fine, just means no upnp on network.
 		}
+		if nat == nil && cfg.NATPMP {
+			var err error
+			nat, err = DiscoverNATPMP()
+			if err != nil {
+				srvrLog.Warnf("Can't discover NAT-PMP: %v", err)
+			}
+		}
 
 		// Add bound addresses to address manager to be advertised to peers.
 		for _, listener := range listeners {
@@ -2939,6 +3733,19 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 	return listeners, nat, nil
 }
 
+// listenPort returns the port of the first of the passed listeners which listens
+// on a TCP port, which may have been chosen dynamically when listening on port
+// zero.  The default port of the network is returned when there is none.
+func listenPort(listeners []net.Listener) uint16 {
+	for _, listener := range listeners {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+			return uint16(addr.Port)
+		}
+	}
+	port, _ := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	return uint16(port)
+}
+
 // addrStringToNetAddr takes an address in the form of 'host:port' and returns
 // a net.Addr which maps to the original address with any host names resolved
 // to IP addresses.  It also handles tor addresses properly by returning a
@@ -2966,7 +3773,7 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 	// address instead.
 	if strings.HasSuffix(host, ".onion") {
 		if cfg.NoOnion {
-			return nil, errors.New("tor has been disabled")
+			return nil, connmgr.ErrOnionDisabled
 		}
 
 		return &onionAddr{addr: addr}, nil
@@ -3133,3 +3940,50 @@ func mergeCheckpoints(defaultCheckpoints, additional []chaincfg.Checkpoint) []ch
 	sort.Sort(checkpointSorter(checkpoints))
 	return checkpoints
 }
+
+// newMisbehaviorPolicy returns the policy used to penalize misbehaving peers
+// which is the default policy adjusted by the configuration.
+func newMisbehaviorPolicy() *netsync.MisbehaviorPolicy {
+	policy := netsync.DefaultMisbehaviorPolicy()
+	for offense, penalty := range cfg.banPenalties {
+		policy.Penalties[offense] = penalty
+	}
+	policy.DisconnectThreshold = cfg.DisconnectThreshold
+	policy.BanThreshold = cfg.BanThreshold
+	policy.BanDuration = cfg.BanDuration
+	policy.DisableBanning = cfg.DisableBanning
+	return policy
+}
+
+// loadCheckpointFile returns the checkpoints of the file at the given path once
+// its signature is verified against the public keys trusted by the network
+// parameters and the passed additional keys.
+func loadCheckpointFile(path string, chainParams *chaincfg.Params, extraKeys []*ulordec.PublicKey) ([]chaincfg.Checkpoint, error) {
+	keys := make([]*ulordec.PublicKey, 0, len(chainParams.CheckpointPubKeys)+
+		len(extraKeys))
+	for _, serialized := range chainParams.CheckpointPubKeys {
+		key, err := ulordec.ParsePubKey(serialized, ulordec.S256())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	keys = append(keys, extraKeys...)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys are trusted to sign "+
+			"checkpoint file %s -- use --checkpointpubkey", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := blockchain.ParseSignedCheckpoints(chainParams.Net,
+		data, keys)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load checkpoint file %s: %v",
+			path, err)
+	}
+	srvrLog.Infof("Loaded %d checkpoints from %s", len(checkpoints), path)
+	return checkpoints, nil
+}
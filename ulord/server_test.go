@@ -0,0 +1,158 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/mempool"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestFeeFilterRate ensures feefilter messages request the minimum fee rate of
+// the transaction pool rather than the configured minimum relay fee, and the
+// maximum fee rate in blocks only mode.
+func TestFeeFilterRate(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config{minRelayTxFee: 1000}
+
+	s := &server{txMemPool: mempool.New(&mempool.Config{
+		Policy: mempool.Policy{MinRelayTxFee: 5000},
+	})}
+	if rate := s.feeFilterRate(); rate != 5000 {
+		t.Errorf("got fee filter rate %d, want 5000", rate)
+	}
+
+	cfg.BlocksOnly = true
+	if rate := s.feeFilterRate(); rate != ulordutil.MaxSatoshi {
+		t.Errorf("blocks only: got fee filter rate %d, want %d", rate,
+			int64(ulordutil.MaxSatoshi))
+	}
+}
+
+// TestFeeFilterDue ensures the initial feefilter message is sent once the
+// version negotiation completed and later ones only when the fee rate changed,
+// no sooner than feeFilterUpdateInterval after the previous message.
+func TestFeeFilterDue(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	tests := []struct {
+		name    string
+		rate    int64
+		elapsed time.Duration
+		initial bool
+		due     bool
+	}{{
+		name:    "update before version negotiation",
+		rate:    1000,
+		elapsed: 0,
+		due:     false,
+	}, {
+		name:    "initial message",
+		rate:    1000,
+		elapsed: 0,
+		initial: true,
+		due:     true,
+	}, {
+		name:    "rate unchanged",
+		rate:    1000,
+		elapsed: feeFilterUpdateInterval,
+		due:     false,
+	}, {
+		name:    "rate changed too soon",
+		rate:    2000,
+		elapsed: feeFilterUpdateInterval - time.Second,
+		due:     false,
+	}, {
+		name:    "rate changed",
+		rate:    2000,
+		elapsed: feeFilterUpdateInterval,
+		due:     true,
+	}, {
+		name:    "rate changed again too soon",
+		rate:    1500,
+		elapsed: feeFilterUpdateInterval + time.Second,
+		due:     false,
+	}, {
+		name:    "rate changed again",
+		rate:    1500,
+		elapsed: 2 * feeFilterUpdateInterval,
+		due:     true,
+	}}
+
+	sp := newServerPeer(&server{}, false)
+	for _, test := range tests {
+		now := start.Add(test.elapsed)
+		due := sp.feeFilterDue(test.rate, now, test.initial)
+		if due != test.due {
+			t.Errorf("%s: got due %v, want %v", test.name, due,
+				test.due)
+		}
+	}
+
+	// The initial message is not sent without a fee rate, but updates are
+	// once the rate rises.
+	sp = newServerPeer(&server{}, false)
+	if sp.feeFilterDue(0, start, true) {
+		t.Errorf("initial message without fee rate: got due true, " +
+			"want false")
+	}
+	if !sp.feeFilterDue(1000, start.Add(feeFilterUpdateInterval), false) {
+		t.Errorf("update after initial message without fee rate: " +
+			"got due false, want true")
+	}
+}
+
+// TestCheckCoinbasePayees ensures the server refuses networks whose coinbase
+// transactions must pay masternodes or superblock payments, since it has no
+// source of their payees.
+func TestCheckCoinbasePayees(t *testing.T) {
+	withMasternodes := chaincfg.RegressionNetParams
+	withMasternodes.Subsidy.MasternodePaymentHeight = 100
+	withMasternodes.Subsidy.MasternodePaymentPercent = 50
+
+	withSuperblocks := chaincfg.RegressionNetParams
+	withSuperblocks.Subsidy.SuperblockStartHeight = 100
+	withSuperblocks.Subsidy.SuperblockCycle = 50
+	withSuperblocks.Subsidy.SuperblockBudgetPercent = 10
+
+	withoutBudget := chaincfg.RegressionNetParams
+	withoutBudget.Subsidy.SuperblockStartHeight = 100
+	withoutBudget.Subsidy.SuperblockCycle = 50
+
+	withFounders := chaincfg.RegressionNetParams
+	withFounders.Subsidy.FounderRewards = []chaincfg.FounderReward{{
+		StartHeight: 1,
+		EndHeight:   100,
+		PkScript:    []byte{0x51},
+		Percent:     20,
+	}}
+
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+		valid  bool
+	}{
+		{"mainnet", &chaincfg.MainNetParams, true},
+		{"testnet", &chaincfg.TestNet3Params, true},
+		{"regtest", &chaincfg.RegressionNetParams, true},
+		{"simnet", &chaincfg.SimNetParams, true},
+		{"founder rewards", &withFounders, true},
+		{"superblocks without budget", &withoutBudget, true},
+		{"masternode payments", &withMasternodes, false},
+		{"superblocks", &withSuperblocks, false},
+	}
+	for _, test := range tests {
+		err := checkCoinbasePayees(test.params)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: network with payees accepted", test.name)
+		}
+	}
+}
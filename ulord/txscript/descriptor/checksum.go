@@ -0,0 +1,123 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// inputCharset lists the characters descriptors may contain.  They are
+	// grouped into classes of 32 characters such that the most common
+	// characters only differ in their position within a class, which
+	// allows the checksum to detect more errors in them.
+	inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// checksumCharset is the charset the checksum is encoded with, which
+	// is the same as the one of bech32.
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// checksumLen is the number of characters of a checksum.
+	checksumLen = 8
+)
+
+// polyMod updates the passed checksum state with the passed 5-bit value.  The
+// checksum is a BCH code over GF(32) which detects up to four errors in
+// descriptors of up to 501 characters.
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// Checksum returns the checksum of the passed descriptor, which must not
+// include a checksum itself.  An error is returned when the descriptor contains
+// characters outside of the descriptor charset.
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in descriptor",
+				desc[i])
+		}
+
+		// Each character contributes its position within its class, and
+		// the classes of groups of three characters are combined into a
+		// single value.
+		c = polyMod(c, pos&31)
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			c = polyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+	for i := 0; i < checksumLen; i++ {
+		c = polyMod(c, 0)
+	}
+	c ^= 1
+
+	var checksum [checksumLen]byte
+	for i := range checksum {
+		checksum[i] = checksumCharset[(c>>uint(5*(7-i)))&31]
+	}
+	return string(checksum[:]), nil
+}
+
+// AddChecksum returns the passed descriptor followed by its checksum.
+func AddChecksum(desc string) (string, error) {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// splitChecksum splits the passed descriptor into the descriptor itself and
+// the checksum following it, which is empty when there is none.  An error is
+// returned when the checksum is malformed or does not match.
+func splitChecksum(desc string) (string, string, error) {
+	i := strings.IndexByte(desc, '#')
+	if i == -1 {
+		return desc, "", nil
+	}
+	desc, checksum := desc[:i], desc[i+1:]
+	if len(checksum) != checksumLen {
+		return "", "", fmt.Errorf("expected %d character checksum, "+
+			"not %d characters", checksumLen, len(checksum))
+	}
+	expected, err := Checksum(desc)
+	if err != nil {
+		return "", "", err
+	}
+	if checksum != expected {
+		return "", "", fmt.Errorf("provided checksum %q does not match "+
+			"computed checksum %q", checksum, expected)
+	}
+	return desc, checksum, nil
+}
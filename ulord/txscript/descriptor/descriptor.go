@@ -0,0 +1,366 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// maxMultiSigKeys is the maximum number of keys of multi and
+	// sortedmulti expressions.
+	maxMultiSigKeys = 16
+
+	// maxBareMultiSigKeys is the maximum number of keys of multi and
+	// sortedmulti expressions which are not nested in sh or wsh, since
+	// larger bare multisig outputs are not standard.
+	maxBareMultiSigKeys = 3
+)
+
+// ErrNoAddress is returned by Address for descriptors whose scripts have no
+// address, such as pk and bare multi descriptors.
+var ErrNoAddress = errors.New("descriptor has no address")
+
+// context describes where a script expression appears, which determines the
+// expressions and keys it may contain.
+type context int
+
+const (
+	contextTop context = iota
+	contextP2SH
+	contextP2WSH
+)
+
+// Descriptor is a parsed output script descriptor.  It describes a single
+// output script or, when it contains keys ending in a wildcard, a range of
+// output scripts derived at child indexes.
+type Descriptor struct {
+	name      string
+	keys      []*key
+	threshold int
+	sub       *Descriptor
+	addr      ulordutil.Address
+	rawScript []byte
+	net       *chaincfg.Params
+}
+
+// Parse parses the passed descriptor for the passed network.  The checksum
+// following the descriptor is optional, but it must be valid when present.
+func Parse(desc string, net *chaincfg.Params) (*Descriptor, error) {
+	desc, _, err := splitChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	return parseScript(desc, contextTop, net)
+}
+
+// parseScript parses the passed script expression, which appears in the
+// passed context.
+func parseScript(expr string, ctx context, net *chaincfg.Params) (*Descriptor, error) {
+	open := strings.IndexByte(expr, '(')
+	if open == -1 || !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("invalid script expression %q", expr)
+	}
+	name, arg := expr[:open], expr[open+1:len(expr)-1]
+	d := &Descriptor{name: name, net: net}
+
+	var err error
+	switch name {
+	case "pk", "pkh":
+		var k *key
+		k, err = parseKey(arg, ctx == contextP2WSH, net)
+		d.keys = []*key{k}
+
+	case "wpkh":
+		if ctx == contextP2WSH {
+			return nil, errors.New("wpkh is not allowed in wsh")
+		}
+		var k *key
+		k, err = parseKey(arg, true, net)
+		d.keys = []*key{k}
+
+	case "sh":
+		if ctx != contextTop {
+			return nil, errors.New("sh is only allowed at the top level")
+		}
+		d.sub, err = parseScript(arg, contextP2SH, net)
+
+	case "wsh":
+		if ctx == contextP2WSH {
+			return nil, errors.New("wsh is not allowed in wsh")
+		}
+		d.sub, err = parseScript(arg, contextP2WSH, net)
+
+	case "multi", "sortedmulti":
+		err = d.parseMultiSig(arg, ctx)
+
+	case "addr":
+		if ctx != contextTop {
+			return nil, errors.New("addr is only allowed at the top level")
+		}
+		d.addr, err = ulordutil.DecodeAddress(arg, net)
+		if err == nil && !d.addr.IsForNet(net) {
+			err = fmt.Errorf("address %q is not for %s", arg, net.Name)
+		}
+
+	case "raw":
+		if ctx != contextTop {
+			return nil, errors.New("raw is only allowed at the top level")
+		}
+		d.rawScript, err = hex.DecodeString(arg)
+
+	default:
+		return nil, fmt.Errorf("unknown script expression %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// parseMultiSig parses the arguments of a multi or sortedmulti expression,
+// which appears in the passed context.
+func (d *Descriptor) parseMultiSig(arg string, ctx context) error {
+	args := strings.Split(arg, ",")
+	threshold, err := strconv.Atoi(args[0])
+	if err != nil || strings.TrimLeft(args[0], "0123456789") != "" {
+		return fmt.Errorf("invalid multisig threshold %q", args[0])
+	}
+	numKeys := len(args) - 1
+	switch {
+	case numKeys < 1 || numKeys > maxMultiSigKeys:
+		return fmt.Errorf("multisig requires 1 to %d keys, not %d",
+			maxMultiSigKeys, numKeys)
+	case ctx == contextTop && numKeys > maxBareMultiSigKeys:
+		return fmt.Errorf("bare multisig allows at most %d keys, not %d",
+			maxBareMultiSigKeys, numKeys)
+	case threshold < 1 || threshold > numKeys:
+		return fmt.Errorf("multisig threshold %d is not between 1 and "+
+			"the number of keys %d", threshold, numKeys)
+	}
+
+	// The script is the threshold, the pushed keys, the number of keys,
+	// and OP_CHECKMULTISIG.
+	scriptLen := 3
+	for _, expr := range args[1:] {
+		k, err := parseKey(expr, ctx == contextP2WSH, d.net)
+		if err != nil {
+			return err
+		}
+		d.keys = append(d.keys, k)
+		if k.compressed {
+			scriptLen += 1 + 33
+		} else {
+			scriptLen += 1 + 65
+		}
+	}
+	if ctx == contextP2SH && scriptLen > txscript.MaxScriptElementSize {
+		return fmt.Errorf("redeem script of %d bytes exceeds the maximum "+
+			"of %d bytes", scriptLen, txscript.MaxScriptElementSize)
+	}
+	d.threshold = threshold
+	return nil
+}
+
+// IsRange returns whether the descriptor describes a range of output scripts
+// derived at child indexes.
+func (d *Descriptor) IsRange() bool {
+	for _, k := range d.keys {
+		if k.isRange() {
+			return true
+		}
+	}
+	return d.sub != nil && d.sub.IsRange()
+}
+
+// IsSolvable returns whether the descriptor contains all information needed to
+// spend its output scripts, given the private keys.  This is the case for all
+// descriptors other than addr and raw.
+func (d *Descriptor) IsSolvable() bool {
+	if d.sub != nil {
+		return d.sub.IsSolvable()
+	}
+	return d.name != "addr" && d.name != "raw"
+}
+
+// HasPrivateKeys returns whether any key of the descriptor was given as a
+// private key.
+func (d *Descriptor) HasPrivateKeys() bool {
+	for _, k := range d.keys {
+		if k.isPrivate() {
+			return true
+		}
+	}
+	return d.sub != nil && d.sub.HasPrivateKeys()
+}
+
+// expression returns the descriptor without checksum.
+func (d *Descriptor) expression() string {
+	switch d.name {
+	case "sh", "wsh":
+		return d.name + "(" + d.sub.expression() + ")"
+	case "multi", "sortedmulti":
+		args := []string{strconv.Itoa(d.threshold)}
+		for _, k := range d.keys {
+			args = append(args, k.String())
+		}
+		return d.name + "(" + strings.Join(args, ",") + ")"
+	case "addr":
+		return "addr(" + d.addr.EncodeAddress() + ")"
+	case "raw":
+		return "raw(" + hex.EncodeToString(d.rawScript) + ")"
+	}
+	return d.name + "(" + d.keys[0].String() + ")"
+}
+
+// String returns the descriptor followed by its checksum.  Private keys are
+// replaced by their public keys.
+func (d *Descriptor) String() string {
+	// All characters of expressions are part of the descriptor charset,
+	// so computing the checksum can't fail.
+	expr := d.expression()
+	checksum, _ := Checksum(expr)
+	return expr + "#" + checksum
+}
+
+// Scripts returns the output script described by the descriptor at the passed
+// child index along with the redeem script and witness script it commits to.
+// The redeem script is nil unless the descriptor is a sh descriptor and the
+// witness script is nil unless it is or nests a wsh descriptor.  The index is
+// ignored unless the descriptor is ranged.
+func (d *Descriptor) Scripts(index uint32) ([]byte, []byte, []byte, error) {
+	switch d.name {
+	case "sh":
+		redeemScript, _, witnessScript, err := d.sub.Scripts(index)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		addr, err := ulordutil.NewAddressScriptHash(redeemScript, d.net)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		return pkScript, redeemScript, witnessScript, err
+
+	case "wsh":
+		witnessScript, _, _, err := d.sub.Scripts(index)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hash := sha256.Sum256(witnessScript)
+		addr, err := ulordutil.NewAddressWitnessScriptHash(hash[:], d.net)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		return pkScript, nil, witnessScript, err
+	}
+
+	pkScript, err := d.script(index)
+	return pkScript, nil, nil, err
+}
+
+// script returns the output script of descriptors which don't nest scripts at
+// the passed child index.
+func (d *Descriptor) script(index uint32) ([]byte, error) {
+	switch d.name {
+	case "multi", "sortedmulti":
+		pubKeys := make([][]byte, 0, len(d.keys))
+		for _, k := range d.keys {
+			pubKey, err := k.serialize(index)
+			if err != nil {
+				return nil, err
+			}
+			pubKeys = append(pubKeys, pubKey)
+		}
+		if d.name == "sortedmulti" {
+			sort.Slice(pubKeys, func(i, j int) bool {
+				return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+			})
+		}
+		addrs := make([]*ulordutil.AddressPubKey, 0, len(pubKeys))
+		for _, pubKey := range pubKeys {
+			addr, err := ulordutil.NewAddressPubKey(pubKey, d.net)
+			if err != nil {
+				return nil, err
+			}
+			addrs = append(addrs, addr)
+		}
+		return txscript.MultiSigScript(addrs, d.threshold)
+
+	case "addr":
+		return txscript.PayToAddrScript(d.addr)
+
+	case "raw":
+		script := make([]byte, len(d.rawScript))
+		copy(script, d.rawScript)
+		return script, nil
+	}
+
+	pubKey, err := d.keys[0].serialize(index)
+	if err != nil {
+		return nil, err
+	}
+	var addr ulordutil.Address
+	switch d.name {
+	case "pk":
+		return txscript.NewScriptBuilder().AddData(pubKey).
+			AddOp(txscript.OP_CHECKSIG).Script()
+	case "pkh":
+		addr, err = ulordutil.NewAddressPubKeyHash(
+			ulordutil.Hash160(pubKey), d.net)
+	default:
+		addr, err = ulordutil.NewAddressWitnessPubKeyHash(
+			ulordutil.Hash160(pubKey), d.net)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// Script returns the output script described by the descriptor at the passed
+// child index, which is ignored unless the descriptor is ranged.
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	pkScript, _, _, err := d.Scripts(index)
+	return pkScript, err
+}
+
+// Address returns the address of the output script described by the
+// descriptor at the passed child index, which is ignored unless the descriptor
+// is ranged.  ErrNoAddress is returned when the output script has no address.
+func (d *Descriptor) Address(index uint32) (ulordutil.Address, error) {
+	if d.name == "addr" {
+		return d.addr, nil
+	}
+	pkScript, err := d.Script(index)
+	if err != nil {
+		return nil, err
+	}
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, d.net)
+	if err != nil {
+		return nil, err
+	}
+	switch class {
+	case txscript.PubKeyHashTy, txscript.ScriptHashTy,
+		txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy:
+
+		if len(addrs) == 1 {
+			return addrs[0], nil
+		}
+	}
+	return nil, ErrNoAddress
+}
@@ -0,0 +1,377 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/hdkeychain"
+)
+
+const (
+	// Compressed and uncompressed private keys of the same secret along
+	// with their public keys.
+	testWIF             = "L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1"
+	testWIFUncompressed = "5KYZdUEo39z3FPrtuX2QbbwGnNP5zTd7yyr2SC1j299sBCnWjss"
+	testPubKey          = "03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	testPubKeyUncomp    = "04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea75abea235"
+
+	// The master key of the first BIP32 test vector.
+	testXPrv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	testXPub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+)
+
+// TestChecksum ensures descriptor checksums are computed and verified.
+func TestChecksum(t *testing.T) {
+	desc := "sh(multi(2,[00000000/111'/222]xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc,xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L/0))"
+	checksum, err := Checksum(desc)
+	if err != nil {
+		t.Fatalf("Checksum: unexpected error: %v", err)
+	}
+	if checksum != "ggrsrxfy" {
+		t.Fatalf("Checksum: got %q, want %q", checksum, "ggrsrxfy")
+	}
+
+	if _, err := Checksum("pk(\x00)"); err == nil {
+		t.Errorf("Checksum: unexpected success for invalid character")
+	}
+
+	tests := []struct {
+		desc  string
+		valid bool
+	}{
+		{desc, true},
+		{desc + "#ggrsrxfy", true},
+		{desc + "#ggrsrxfx", false},
+		{desc + "#ggrsrxf", false},
+		{desc + "#", false},
+		{desc + "#ggrsrxfy#ggrsrxfy", false},
+	}
+	for _, test := range tests {
+		_, _, err := splitChecksum(test.desc)
+		if (err == nil) != test.valid {
+			t.Errorf("splitChecksum(%q): got error %v, want valid %v",
+				test.desc, err, test.valid)
+		}
+	}
+}
+
+// TestParse ensures descriptors are parsed, derive the expected scripts, and
+// are formatted with public keys.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		desc   string
+		str    string
+		script string
+		isPriv bool
+	}{
+		{
+			desc:   "pk(" + testWIF + ")",
+			str:    "pk(" + testPubKey + ")",
+			script: "21" + testPubKey + "ac",
+			isPriv: true,
+		},
+		{
+			desc:   "pk(" + testPubKey + ")",
+			str:    "pk(" + testPubKey + ")",
+			script: "21" + testPubKey + "ac",
+		},
+		{
+			desc:   "pkh([deadbeef/1/2'/3/4']" + testWIF + ")",
+			str:    "pkh([deadbeef/1/2'/3/4']" + testPubKey + ")",
+			script: "76a9149a1c78a507689f6f54b847ad1cef1e614ee23f1e88ac",
+			isPriv: true,
+		},
+		{
+			desc:   "pkh(" + testWIFUncompressed + ")",
+			str:    "pkh(" + testPubKeyUncomp + ")",
+			script: "76a914b5bd079c4d57cc7fc28ecf8213a6b791625b818388ac",
+			isPriv: true,
+		},
+		{
+			desc:   "wpkh(" + testPubKey + ")",
+			str:    "wpkh(" + testPubKey + ")",
+			script: "00149a1c78a507689f6f54b847ad1cef1e614ee23f1e",
+		},
+		{
+			desc:   "sh(wpkh(" + testPubKey + "))",
+			str:    "sh(wpkh(" + testPubKey + "))",
+			script: "a91484ab21b1b2fd065d4504ff693d832434b6108d7b87",
+		},
+		{
+			desc:   "wsh(pkh(" + testPubKey + "))",
+			str:    "wsh(pkh(" + testPubKey + "))",
+			script: "0020338e023079b91c58571b20e602d7805fb808c22473cbc391a41b1bd3a192e75b",
+		},
+		{
+			desc: "multi(1," + testWIF + "," + testWIFUncompressed + ")",
+			str:  "multi(1," + testPubKey + "," + testPubKeyUncomp + ")",
+			script: "5121" + testPubKey + "41" + testPubKeyUncomp +
+				"52ae",
+			isPriv: true,
+		},
+		{
+			desc: "sortedmulti(1," + testPubKeyUncomp + "," +
+				testPubKey + ")",
+			str: "sortedmulti(1," + testPubKeyUncomp + "," +
+				testPubKey + ")",
+			script: "5121" + testPubKey + "41" + testPubKeyUncomp +
+				"52ae",
+		},
+		{
+			desc:   "pk(" + testXPrv + ")",
+			str:    "pk(" + testXPub + ")",
+			script: "2103" + "39a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2" + "ac",
+			isPriv: true,
+		},
+		{
+			desc:   "raw(6a0100)",
+			str:    "raw(6a0100)",
+			script: "6a0100",
+		},
+	}
+
+	for _, test := range tests {
+		d, err := Parse(test.desc, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.desc, err)
+			continue
+		}
+		wantStr, _ := AddChecksum(test.str)
+		if str := d.String(); str != wantStr {
+			t.Errorf("String(%q): got %q, want %q", test.desc, str,
+				wantStr)
+		}
+		if d.IsRange() {
+			t.Errorf("IsRange(%q): unexpected range", test.desc)
+		}
+		if d.HasPrivateKeys() != test.isPriv {
+			t.Errorf("HasPrivateKeys(%q): got %v, want %v", test.desc,
+				d.HasPrivateKeys(), test.isPriv)
+		}
+		script, err := d.Script(0)
+		if err != nil {
+			t.Errorf("Script(%q): unexpected error: %v", test.desc, err)
+			continue
+		}
+		if hex.EncodeToString(script) != test.script {
+			t.Errorf("Script(%q): got %x, want %s", test.desc, script,
+				test.script)
+		}
+
+		// The formatted descriptor parses to the same scripts.
+		d2, err := Parse(d.String(), &chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", d.String(), err)
+			continue
+		}
+		script2, err := d2.Script(0)
+		if err != nil || !bytes.Equal(script, script2) {
+			t.Errorf("Script(%q): got %x, want %x", d.String(),
+				script2, script)
+		}
+	}
+}
+
+// TestParseInvalid ensures invalid descriptors are rejected.
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"pk()",
+		"pk(" + testPubKey,
+		"foo(" + testPubKey + ")",
+		"pk(" + testPubKey[:64] + ")",
+		"wpkh(" + testPubKeyUncomp + ")",
+		"wsh(pk(" + testWIFUncompressed + "))",
+		"sh(sh(pk(" + testPubKey + ")))",
+		"wsh(wsh(pk(" + testPubKey + ")))",
+		"wsh(wpkh(" + testPubKey + "))",
+		"sh(addr(" + testPubKey + "))",
+		"sh(raw(6a))",
+		"raw(6a0)",
+		"multi(0," + testPubKey + ")",
+		"multi(2," + testPubKey + ")",
+		"multi(+1," + testPubKey + ")",
+		"multi(1," + strings.Repeat(testPubKey+",", 3) + testPubKey + ")",
+		"sh(multi(1," + strings.Repeat(testPubKey+",", 15) + testPubKey + "))",
+		"wsh(multi(1," + strings.Repeat(testPubKey+",", 16) + testPubKey + "))",
+		"pkh([deadbee]" + testPubKey + ")",
+		"pkh([deadbeef/x]" + testPubKey + ")",
+		"pkh([deadbeef" + testPubKey + ")",
+		"pkh(" + testPubKey + "/0)",
+		"pkh(" + testXPub + "/1'/*)",
+		"pkh(" + testXPub + "/*')",
+		"pkh(" + testXPub + "/1/x)",
+		"pk(" + testWIF + ")#aaaaaaaa",
+	}
+	for _, desc := range tests {
+		if _, err := Parse(desc, &chaincfg.MainNetParams); err == nil {
+			t.Errorf("Parse(%q): unexpected success", desc)
+		}
+	}
+
+	// Keys of other networks are rejected.
+	if _, err := Parse("pk("+testXPub+")", &chaincfg.TestNet3Params); err == nil {
+		t.Errorf("Parse: unexpected success for key of other network")
+	}
+}
+
+// TestParseRange ensures ranged descriptors derive the keys at the requested
+// child indexes.
+func TestParseRange(t *testing.T) {
+	master, err := hdkeychain.NewKeyFromString(testXPrv)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		desc string
+		path func(index uint32) hdkeychain.DerivationPath
+	}{
+		{
+			desc: "wpkh(" + testXPub + "/1/2/*)",
+			path: func(index uint32) hdkeychain.DerivationPath {
+				return hdkeychain.DerivationPath{1, 2, index}
+			},
+		},
+		{
+			desc: "wpkh([d34db33f/44'/0'/0']" + testXPrv + "/0'/*')",
+			path: func(index uint32) hdkeychain.DerivationPath {
+				return hdkeychain.DerivationPath{
+					hdkeychain.HardenedKeyStart,
+					hdkeychain.HardenedKeyStart + index,
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.desc, err)
+			continue
+		}
+		if !d.IsRange() {
+			t.Errorf("IsRange(%q): unexpected non-range", test.desc)
+		}
+		for index := uint32(0); index < 3; index++ {
+			child, err := master.Derive(test.path(index))
+			if err != nil {
+				t.Fatalf("Derive: unexpected error: %v", err)
+			}
+			pubKey, _ := child.ECPubKey()
+			want, _ := ulordutil.NewAddressWitnessPubKeyHash(
+				ulordutil.Hash160(pubKey.SerializeCompressed()),
+				&chaincfg.MainNetParams)
+			addr, err := d.Address(index)
+			if err != nil {
+				t.Errorf("Address(%q, %d): unexpected error: %v",
+					test.desc, index, err)
+				continue
+			}
+			if addr.EncodeAddress() != want.EncodeAddress() {
+				t.Errorf("Address(%q, %d): got %s, want %s",
+					test.desc, index, addr, want)
+			}
+		}
+		if _, err := d.Address(hdkeychain.HardenedKeyStart); err == nil {
+			t.Errorf("Address(%q): unexpected success for hardened "+
+				"index", test.desc)
+		}
+	}
+
+	// Descriptors without an address are rejected.
+	d, err := Parse("pk("+testPubKey+")", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if _, err := d.Address(0); err != ErrNoAddress {
+		t.Errorf("Address: got error %v, want %v", err, ErrNoAddress)
+	}
+}
+
+// TestInfer ensures descriptors are inferred from output scripts using the
+// known keys and scripts.
+func TestInfer(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	pubKey, _ := hex.DecodeString(testPubKey)
+	redeemDesc, err := Parse("wsh(multi(1,"+testPubKey+","+testXPub+"))", net)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	redeemScript, _ := redeemDesc.Script(0)
+	_, _, witnessScript, _ := redeemDesc.Scripts(0)
+
+	p := &Provider{
+		PubKey: func(hash []byte) ([]byte, bool) {
+			if bytes.Equal(hash, ulordutil.Hash160(pubKey)) {
+				return pubKey, true
+			}
+			return nil, false
+		},
+		Script: func(hash []byte) ([]byte, bool) {
+			if bytes.Equal(hash, ulordutil.Hash160(redeemScript)) {
+				return redeemScript, true
+			}
+			if len(hash) == 32 {
+				return witnessScript, true
+			}
+			return nil, false
+		},
+	}
+
+	tests := []struct {
+		desc     string
+		provider *Provider
+		want     string
+		solvable bool
+	}{
+		{"pk(" + testPubKey + ")", nil, "pk(" + testPubKey + ")", true},
+		{"pkh(" + testPubKey + ")", p, "pkh(" + testPubKey + ")", true},
+		{"wpkh(" + testPubKey + ")", p, "wpkh(" + testPubKey + ")", true},
+		{"sh(wpkh(" + testPubKey + "))", p, "", false},
+		{
+			"sh(wsh(multi(1," + testPubKey + "," + testXPub + ")))", p,
+			"", true,
+		},
+		{"multi(1," + testPubKey + ")", nil, "multi(1," + testPubKey + ")", true},
+		{"raw(6a0100)", p, "raw(6a0100)", false},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc, net)
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", test.desc, err)
+		}
+		script, _ := d.Script(0)
+		inferred := Infer(script, net, test.provider)
+		if inferred.IsSolvable() != test.solvable {
+			t.Errorf("Infer(%q): got solvable %v, want %v", test.desc,
+				inferred.IsSolvable(), test.solvable)
+		}
+		if test.want != "" {
+			want, _ := AddChecksum(test.want)
+			if inferred.String() != want {
+				t.Errorf("Infer(%q): got %q, want %q", test.desc,
+					inferred.String(), want)
+			}
+		}
+		inferredScript, err := inferred.Script(0)
+		if err != nil || !bytes.Equal(inferredScript, script) {
+			t.Errorf("Infer(%q): got script %x, want %x", test.desc,
+				inferredScript, script)
+		}
+	}
+
+	// Unknown hashes fall back to an addr descriptor.
+	d, _ := Parse("pkh("+testPubKey+")", net)
+	script, _ := d.Script(0)
+	if inferred := Infer(script, net, nil); inferred.name != "addr" {
+		t.Errorf("Infer: got %q, want addr descriptor", inferred)
+	}
+}
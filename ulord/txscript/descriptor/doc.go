@@ -0,0 +1,41 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package descriptor implements output script descriptors, a language for
+describing collections of output scripts.
+
+Descriptor Overview
+
+A descriptor such as "wpkh(02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9)"
+describes how an output script is built from keys and which scripts it nests.
+The following script expressions are supported:
+
+	pk(KEY)              pay-to-pubkey
+	pkh(KEY)             pay-to-pubkey-hash
+	wpkh(KEY)            pay-to-witness-pubkey-hash
+	sh(SCRIPT)           pay-to-script-hash of SCRIPT
+	wsh(SCRIPT)          pay-to-witness-script-hash of SCRIPT
+	multi(k,KEY,...)     k-of-n bare multisig
+	sortedmulti(k,...)   k-of-n multisig with lexicographically sorted keys
+	addr(ADDRESS)        the output script of an address
+	raw(HEX)             a literal output script
+
+A KEY is a hex encoded public key, a WIF encoded private key, or an extended
+public or private key optionally followed by a derivation path.  Extended keys
+whose path ends in "/*" or "/*'" describe a range of keys and, in turn, a range
+of scripts, which are derived at a child index.  Any key may be preceded by key
+origin information of the form "[fingerprint/path]".
+
+Descriptors may be followed by an eight character checksum separated by "#",
+which protects against typing errors.  It is verified by Parse when present.
+
+Inference
+
+Infer performs the reverse operation and returns the most descriptive
+descriptor for an output script, using the keys and scripts known to the
+caller to describe the hashes the script commits to.  Scripts which can't be
+described otherwise are returned as addr or raw descriptors.
+*/
+package descriptor
@@ -0,0 +1,178 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// Provider supplies the public keys and scripts known to the caller, which
+// Infer uses to describe the hashes output scripts commit to.  Either function
+// may be nil.
+type Provider struct {
+	// PubKey returns the serialized public key with the passed hash160.
+	PubKey func(hash []byte) ([]byte, bool)
+
+	// Script returns the script with the passed hash, which is a hash160
+	// for pay-to-script-hash outputs and a sha256 for
+	// pay-to-witness-script-hash outputs.
+	Script func(hash []byte) ([]byte, bool)
+}
+
+// pubKey returns the public key with the passed hash160 as a key usable in the
+// passed context.
+func (p *Provider) pubKey(hash []byte, ctx context) *key {
+	if p == nil || p.PubKey == nil {
+		return nil
+	}
+	serialized, ok := p.PubKey(hash)
+	if !ok || !bytes.Equal(ulordutil.Hash160(serialized), hash) {
+		return nil
+	}
+	k, err := newKey(serialized)
+	if err != nil || (ctx == contextP2WSH && !k.compressed) {
+		return nil
+	}
+	return k
+}
+
+// script returns the script with the passed hash.
+func (p *Provider) script(hash []byte) []byte {
+	if p == nil || p.Script == nil {
+		return nil
+	}
+	script, ok := p.Script(hash)
+	if !ok {
+		return nil
+	}
+	switch len(hash) {
+	case 20:
+		if bytes.Equal(ulordutil.Hash160(script), hash) {
+			return script
+		}
+	case 32:
+		if h := sha256.Sum256(script); bytes.Equal(h[:], hash) {
+			return script
+		}
+	}
+	return nil
+}
+
+// Infer returns a descriptor for the passed output script.  Hashes of public
+// keys and scripts are described by the ones returned by the provider, which
+// may be nil.  An addr descriptor is returned for output scripts with an
+// address which can't be described otherwise, and a raw descriptor for all
+// other output scripts.
+func Infer(pkScript []byte, net *chaincfg.Params, p *Provider) *Descriptor {
+	if d := inferScript(pkScript, contextTop, net, p); d != nil {
+		return d
+	}
+
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, net)
+	if err == nil && len(addrs) == 1 {
+		switch class {
+		case txscript.PubKeyHashTy, txscript.ScriptHashTy,
+			txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy:
+
+			return &Descriptor{name: "addr", addr: addrs[0], net: net}
+		}
+	}
+
+	script := make([]byte, len(pkScript))
+	copy(script, pkScript)
+	return &Descriptor{name: "raw", rawScript: script, net: net}
+}
+
+// inferScript returns a solvable descriptor for the passed script, which
+// appears in the passed context, or nil when there is none.
+func inferScript(script []byte, ctx context, net *chaincfg.Params, p *Provider) *Descriptor {
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		return nil
+	}
+
+	d := &Descriptor{net: net}
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyTy:
+		k, err := newKey(pushes[0])
+		if err != nil || (ctx == contextP2WSH && !k.compressed) {
+			return nil
+		}
+		d.name, d.keys = "pk", []*key{k}
+
+	case txscript.PubKeyHashTy:
+		k := p.pubKey(pushes[0], ctx)
+		if k == nil {
+			return nil
+		}
+		d.name, d.keys = "pkh", []*key{k}
+
+	case txscript.WitnessV0PubKeyHashTy:
+		if ctx == contextP2WSH {
+			return nil
+		}
+		k := p.pubKey(pushes[1], contextP2WSH)
+		if k == nil {
+			return nil
+		}
+		d.name, d.keys = "wpkh", []*key{k}
+
+	case txscript.ScriptHashTy:
+		if ctx != contextTop {
+			return nil
+		}
+		redeemScript := p.script(pushes[0])
+		if redeemScript == nil {
+			return nil
+		}
+		d.name, d.sub = "sh", inferScript(redeemScript, contextP2SH, net, p)
+		if d.sub == nil {
+			return nil
+		}
+
+	case txscript.WitnessV0ScriptHashTy:
+		if ctx == contextP2WSH {
+			return nil
+		}
+		witnessScript := p.script(pushes[1])
+		if witnessScript == nil {
+			return nil
+		}
+		d.name = "wsh"
+		d.sub = inferScript(witnessScript, contextP2WSH, net, p)
+		if d.sub == nil {
+			return nil
+		}
+
+	case txscript.MultiSigTy:
+		numKeys, threshold, err := txscript.CalcMultiSigStats(script)
+		if err != nil || numKeys != len(pushes) || numKeys < 1 ||
+			numKeys > maxMultiSigKeys || threshold < 1 ||
+			threshold > numKeys {
+
+			return nil
+		}
+		if ctx == contextTop && numKeys > maxBareMultiSigKeys {
+			return nil
+		}
+		for _, serialized := range pushes {
+			k, err := newKey(serialized)
+			if err != nil || (ctx == contextP2WSH && !k.compressed) {
+				return nil
+			}
+			d.keys = append(d.keys, k)
+		}
+		d.name, d.threshold = "multi", threshold
+
+	default:
+		return nil
+	}
+	return d
+}
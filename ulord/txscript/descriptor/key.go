@@ -0,0 +1,259 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/hdkeychain"
+)
+
+// wildcard describes whether the derivation path of an extended key ends in a
+// wildcard and, if so, whether children are derived hardened.
+type wildcard int
+
+const (
+	wildcardNone wildcard = iota
+	wildcardUnhardened
+	wildcardHardened
+)
+
+// key is a key expression of a descriptor.  It either describes a single
+// public key, possibly given by its private key, or the children of an extended
+// key when it ends in a wildcard.
+type key struct {
+	// fingerprint and originPath hold the key origin information, which
+	// is only informational.  The fingerprint is nil when there is none.
+	fingerprint []byte
+	originPath  hdkeychain.DerivationPath
+
+	// pubKey holds the key when it is neither an extended key nor derived
+	// from a wildcard.  wif holds the private key when it was given as one.
+	pubKey     *ulordec.PublicKey
+	compressed bool
+	wif        *ulordutil.WIF
+
+	// extKey and path hold the extended key and the path following it, and
+	// derived is extKey with the path applied.
+	extKey   *hdkeychain.ExtendedKey
+	path     hdkeychain.DerivationPath
+	wildcard wildcard
+	derived  *hdkeychain.ExtendedKey
+}
+
+// newKey returns a key for the passed serialized public key.  Hybrid public
+// keys are rejected since they are not standard.
+func newKey(serialized []byte) (*key, error) {
+	if len(serialized) == 0 || (serialized[0] != 0x02 &&
+		serialized[0] != 0x03 && serialized[0] != 0x04) {
+
+		return nil, errors.New("unsupported public key format")
+	}
+	pubKey, err := ulordec.ParsePubKey(serialized, ulordec.S256())
+	if err != nil {
+		return nil, err
+	}
+	return &key{pubKey: pubKey, compressed: serialized[0] != 0x04}, nil
+}
+
+// parseKey parses the passed key expression for the passed network.  Only
+// compressed public keys are accepted when witness is set.
+func parseKey(expr string, witness bool, net *chaincfg.Params) (*key, error) {
+	var fingerprint []byte
+	var originPath hdkeychain.DerivationPath
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end == -1 {
+			return nil, fmt.Errorf("key origin of %q is not closed", expr)
+		}
+		origin := strings.SplitN(expr[1:end], "/", 2)
+		if len(origin[0]) != 8 {
+			return nil, fmt.Errorf("fingerprint %q is not 4 bytes",
+				origin[0])
+		}
+		var err error
+		fingerprint, err = hex.DecodeString(origin[0])
+		if err != nil {
+			return nil, fmt.Errorf("fingerprint %q is not hex",
+				origin[0])
+		}
+		if len(origin) == 2 {
+			originPath, err = hdkeychain.ParsePath("m/" + origin[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key origin path "+
+					"%q", origin[1])
+			}
+		}
+		expr = expr[end+1:]
+	}
+
+	k, err := parseKeyMaterial(expr, net)
+	if err != nil {
+		return nil, err
+	}
+	if witness && !k.compressed {
+		return nil, errors.New("uncompressed keys are not allowed " +
+			"in witness scripts")
+	}
+	k.fingerprint = fingerprint
+	k.originPath = originPath
+	return k, nil
+}
+
+// parseKeyMaterial parses the passed key expression without key origin
+// information.
+func parseKeyMaterial(expr string, net *chaincfg.Params) (*key, error) {
+	elems := strings.Split(expr, "/")
+	if len(elems) == 1 {
+		if serialized, err := hex.DecodeString(expr); err == nil {
+			if len(serialized) != ulordec.PubKeyBytesLenCompressed &&
+				len(serialized) != ulordec.PubKeyBytesLenUncompressed {
+
+				return nil, fmt.Errorf("public key %q has invalid "+
+					"length", expr)
+			}
+			return newKey(serialized)
+		}
+		if wif, err := ulordutil.DecodeWIF(expr); err == nil {
+			if !wif.IsForNet(net) {
+				return nil, fmt.Errorf("private key %q is not for "+
+					"%s", expr, net.Name)
+			}
+			return &key{
+				pubKey:     wif.PrivKey.PubKey(),
+				compressed: wif.CompressPubKey,
+				wif:        wif,
+			}, nil
+		}
+	}
+
+	extKey, err := hdkeychain.NewKeyFromString(elems[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q", elems[0])
+	}
+	if !extKey.IsForNet(net) {
+		return nil, fmt.Errorf("extended key %q is not for %s",
+			elems[0], net.Name)
+	}
+	k := &key{compressed: true, extKey: extKey}
+
+	elems = elems[1:]
+	if len(elems) > 0 {
+		switch elems[len(elems)-1] {
+		case "*":
+			k.wildcard = wildcardUnhardened
+		case "*'", "*h", "*H":
+			k.wildcard = wildcardHardened
+		}
+		if k.wildcard != wildcardNone {
+			elems = elems[:len(elems)-1]
+		}
+	}
+	if len(elems) > 0 {
+		k.path, err = hdkeychain.ParsePath("m/" + strings.Join(elems, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q",
+				strings.Join(elems, "/"))
+		}
+	}
+
+	// The path preceding the wildcard is derived once up front, which also
+	// rejects hardened derivation from extended public keys early.
+	k.derived, err = extKey.Derive(k.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive %q: %v", expr, err)
+	}
+	if k.wildcard == wildcardHardened && !k.derived.IsPrivate() {
+		return nil, errors.New("hardened wildcards require an extended " +
+			"private key")
+	}
+	if k.wildcard == wildcardNone {
+		k.pubKey, err = k.derived.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// isRange returns whether the key describes a range of keys.
+func (k *key) isRange() bool {
+	return k.wildcard != wildcardNone
+}
+
+// isPrivate returns whether the key was given as a private key.
+func (k *key) isPrivate() bool {
+	return k.wif != nil || (k.extKey != nil && k.extKey.IsPrivate())
+}
+
+// serialize returns the serialized public key described by the key at the
+// passed child index, which is ignored unless the key ends in a wildcard.
+func (k *key) serialize(index uint32) ([]byte, error) {
+	if k.wildcard == wildcardNone {
+		if k.compressed {
+			return k.pubKey.SerializeCompressed(), nil
+		}
+		return k.pubKey.SerializeUncompressed(), nil
+	}
+
+	if index >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("index %d is out of range", index)
+	}
+	if k.wildcard == wildcardHardened {
+		index += hdkeychain.HardenedKeyStart
+	}
+	child, err := k.derived.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+// pathString returns the passed derivation path without the leading "m".
+func pathString(path hdkeychain.DerivationPath) string {
+	return strings.TrimPrefix(path.String(), "m")
+}
+
+// String returns the key expression with private keys replaced by their public
+// keys.
+func (k *key) String() string {
+	var buf bytes.Buffer
+	if k.fingerprint != nil {
+		fmt.Fprintf(&buf, "[%x%s]", k.fingerprint, pathString(k.originPath))
+	}
+
+	if k.extKey == nil {
+		pubKey, _ := k.serialize(0)
+		buf.WriteString(hex.EncodeToString(pubKey))
+		return buf.String()
+	}
+
+	// Neutering only fails for keys of unregistered networks, which are
+	// rejected when parsing.
+	extKey, err := k.extKey.Neuter()
+	if err != nil {
+		extKey = k.extKey
+	}
+	buf.WriteString(extKey.String())
+	buf.WriteString(pathString(k.path))
+	switch k.wildcard {
+	case wildcardUnhardened:
+		buf.WriteString("/*")
+	case wildcardHardened:
+		buf.WriteString("/*'")
+	}
+	return buf.String()
+}
@@ -38,5 +38,12 @@ ErrorCode field of the type asserted txscript.Error while still providing rich
 error messages with contextual information.  A convenience function named
 IsErrorCode is also provided to allow callers to easily check for a specific
 error code.  See ErrorCode in the package documentation for a full list.
+
+Debugging
+
+Calling EnableTracing on an Engine makes it record every executed opcode along
+with snapshots of the stacks, which is returned by Trace, and makes its errors
+describe the opcode executed last.  Scripts may also be executed a few opcodes
+at a time with StepN while examining the state of the engine with Inspect.
 */
 package txscript
@@ -136,6 +136,7 @@ type Engine struct {
 	witnessVersion  int
 	witnessProgram  []byte
 	inputAmount     int64
+	trace           *Trace // nil unless tracing is enabled
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -446,12 +447,17 @@ func (vm *Engine) Step() (done bool, err error) {
 		return true, err
 	}
 	opcode := &vm.scripts[vm.scriptIdx][vm.scriptOff]
+	scriptIdx, scriptOff := vm.scriptIdx, vm.scriptOff
+	executed := vm.isBranchExecuting()
 	vm.scriptOff++
 
 	// Execute the opcode while taking into account several things such as
 	// disabled opcodes, illegal opcodes, maximum allowed operations per
 	// script, maximum script element sizes, and conditionals.
 	err = vm.executeOpcode(opcode)
+	if vm.trace != nil {
+		vm.recordStep(scriptIdx, scriptOff, opcode, executed, err)
+	}
 	if err != nil {
 		return true, err
 	}
@@ -462,7 +468,11 @@ func (vm *Engine) Step() (done bool, err error) {
 	if combinedStackSize > MaxStackSize {
 		str := fmt.Sprintf("combined stack size %d > max allowed %d",
 			combinedStackSize, MaxStackSize)
-		return false, scriptError(ErrStackOverflow, str)
+		err = scriptError(ErrStackOverflow, str)
+		if vm.trace != nil {
+			vm.trace.Steps[len(vm.trace.Steps)-1].Err = err
+		}
+		return false, err
 	}
 
 	// Prepare for next instruction.
@@ -540,7 +550,7 @@ func (vm *Engine) Execute() (err error) {
 
 		done, err = vm.Step()
 		if err != nil {
-			return err
+			return vm.traceError(err)
 		}
 		log.Tracef("%v", newLogClosure(func() string {
 			var dstr, astr string
@@ -557,7 +567,7 @@ func (vm *Engine) Execute() (err error) {
 		}))
 	}
 
-	return vm.CheckErrorCondition(true)
+	return vm.traceError(vm.CheckErrorCondition(true))
 }
 
 // subScript returns the script since the last OP_CODESEPARATOR.
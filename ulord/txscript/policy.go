@@ -0,0 +1,119 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"github.com/ulordsuite/ulordutil"
+)
+
+const (
+	// DefaultMaxStandardTxWeight is the default maximum weight of standard
+	// transactions.
+	DefaultMaxStandardTxWeight = 400000
+
+	// DefaultMaxStandardSigScriptSize is the default maximum size of
+	// standard signature scripts.  It allows for a 15-of-15 CHECKMULTISIG
+	// pay-to-script-hash with compressed keys.
+	//
+	// The form of the overall script is: OP_0 <15 signatures> OP_PUSHDATA2
+	// <2 bytes len> [OP_15 <15 pubkeys> OP_15 OP_CHECKMULTISIG]
+	//
+	// For the p2sh script portion, each of the 15 compressed pubkeys are
+	// 33 bytes (plus one for the OP_DATA_33 opcode), and the thus it totals
+	// to (15*34)+3 = 513 bytes.  Next, each of the 15 signatures is a max
+	// of 73 bytes (plus one for the OP_DATA_73 opcode).  Also, there is one
+	// extra byte for the initial extra OP_0 push and 3 bytes for the
+	// OP_PUSHDATA2 needed to specify the 513 bytes for the script push.
+	// That brings the total to 1+(15*74)+3+513 = 1627.  This value also
+	// adds a few extra bytes to provide a little buffer.
+	// (1 + 15*74 + 3) + (15*34 + 3) + 23 = 1650
+	DefaultMaxStandardSigScriptSize = 1650
+
+	// DefaultMaxStandardP2SHSigOps is the default maximum number of
+	// signature operations of standard pay-to-script-hash redeem scripts.
+	DefaultMaxStandardP2SHSigOps = 15
+
+	// DefaultMaxStandardMultiSigKeys is the default maximum number of
+	// public keys of standard bare multi-signature output scripts.
+	DefaultMaxStandardMultiSigKeys = 3
+)
+
+// Policy describes the standardness rules which transactions must satisfy, in
+// addition to the consensus rules, to be accepted into the memory pool and
+// included in block templates.  Unlike the consensus rules, they may be tuned
+// freely since violating them only affects relay and mining.
+//
+// The zero value is not usable.  DefaultPolicy returns the rules used by
+// default, which may then be modified.
+type Policy struct {
+	// VerifyFlags are the script flags transaction scripts are executed
+	// with, which enforce additional rules such as clean stacks and low S
+	// signatures.
+	VerifyFlags ScriptFlags
+
+	// MaxTxWeight is the maximum weight of a transaction.
+	MaxTxWeight int64
+
+	// MaxSigScriptSize is the maximum size of each signature script of a
+	// transaction.
+	MaxSigScriptSize int
+
+	// RequirePushOnly specifies that signature scripts may only contain
+	// opcodes which push data.
+	RequirePushOnly bool
+
+	// MaxP2SHSigOps is the maximum number of signature operations of the
+	// redeem script of each pay-to-script-hash input.
+	MaxP2SHSigOps int
+
+	// MaxMultiSigKeys is the maximum number of public keys of bare
+	// multi-signature output scripts.
+	MaxMultiSigKeys int
+
+	// MaxDataCarrierSize is the maximum number of bytes pushed by null
+	// data output scripts, and MaxNullDataOutputs the maximum number of
+	// null data outputs of a transaction.
+	MaxDataCarrierSize int
+	MaxNullDataOutputs int
+
+	// DustRelayFee is the fee rate in satoshi per 1000 bytes used to
+	// determine whether outputs are dust, which is the case when spending
+	// them costs more than a third of their value at this rate.  The
+	// minimum relay fee is used when it is zero.
+	DustRelayFee ulordutil.Amount
+}
+
+// DefaultPolicy returns the standardness rules used by default.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		VerifyFlags:        StandardVerifyFlags,
+		MaxTxWeight:        DefaultMaxStandardTxWeight,
+		MaxSigScriptSize:   DefaultMaxStandardSigScriptSize,
+		RequirePushOnly:    true,
+		MaxP2SHSigOps:      DefaultMaxStandardP2SHSigOps,
+		MaxMultiSigKeys:    DefaultMaxStandardMultiSigKeys,
+		MaxDataCarrierSize: MaxDataCarrierSize,
+		MaxNullDataOutputs: 1,
+	}
+}
+
+// ScriptClass returns the class of the passed script like GetScriptClass
+// except that null data scripts are classified according to the maximum data
+// carrier size of the policy.
+func (p *Policy) ScriptClass(script []byte) ScriptClass {
+	pops, err := parseScript(script)
+	if err != nil {
+		return NonStandardTy
+	}
+	if isNullDataSize(pops, p.MaxDataCarrierSize) {
+		return NullDataTy
+	}
+	class := typeOfScript(pops)
+	if class == NullDataTy {
+		// The script pushes more data than the policy allows.
+		return NonStandardTy
+	}
+	return class
+}
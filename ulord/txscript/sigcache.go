@@ -5,21 +5,51 @@
 package txscript
 
 import (
+	"math/big"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ulordsuite/ulord/ulordec"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
 
-// sigCacheEntry represents an entry in the SigCache. Entries within the
-// SigCache are keyed according to the sigHash of the signature. In the
-// scenario of a cache-hit (according to the sigHash), an additional comparison
-// of the signature, and public key will be executed in order to ensure a complete
-// match. In the occasion that two sigHashes collide, the newer sigHash will
-// simply overwrite the existing entry.
-type sigCacheEntry struct {
-	sig    *ulordec.Signature
-	pubKey *ulordec.PublicKey
+// sigCacheShards is the number of independently locked shards the entries of
+// the SigCache are spread over.  Sharding keeps the script validation workers
+// from contending on a single lock while connecting blocks.
+const sigCacheShards = 16
+
+// sigCacheKey identifies an entry in the SigCache.  It is the hash of the
+// sigHash, the signature and the public key, so signatures over the same
+// sigHash under different keys, or different signatures over the same sigHash,
+// are distinct entries rather than overwriting each other.
+type sigCacheKey chainhash.Hash
+
+// newSigCacheKey returns the key of the entry for a signature over 'sigHash'
+// under public key 'pubKey'.
+func newSigCacheKey(sigHash *chainhash.Hash, sig *ulordec.Signature, pubKey *ulordec.PublicKey) sigCacheKey {
+	var buf [chainhash.HashSize + 64 + ulordec.PubKeyBytesLenCompressed]byte
+	copy(buf[:chainhash.HashSize], sigHash[:])
+	putScalar(buf[chainhash.HashSize:chainhash.HashSize+32], sig.R)
+	putScalar(buf[chainhash.HashSize+32:chainhash.HashSize+64], sig.S)
+	copy(buf[chainhash.HashSize+64:], pubKey.SerializeCompressed())
+	return sigCacheKey(chainhash.HashH(buf[:]))
+}
+
+// putScalar writes the passed signature scalar to the 32 byte buffer as a
+// big-endian number padded with leading zeros.  Scalars which do not fit, which
+// can't be produced by a valid signature, are truncated to their low bytes.
+func putScalar(buf []byte, n *big.Int) {
+	b := n.Bytes()
+	if len(b) > len(buf) {
+		b = b[len(b)-len(buf):]
+	}
+	copy(buf[len(buf)-len(b):], b)
+}
+
+// sigCacheShard is a portion of the entries of the SigCache with its own lock.
+type sigCacheShard struct {
+	sync.RWMutex
+	validSigs map[sigCacheKey]struct{}
 }
 
 // SigCache implements an ECDSA signature verification cache with a randomized
@@ -32,9 +62,13 @@ type sigCacheEntry struct {
 // Secondly, usage of the SigCache introduces a signature verification
 // optimization which speeds up the validation of transactions within a block,
 // if they've already been seen and verified within the mempool.
+//
+// Entries are keyed by the (sigHash, signature, public key) triplet and spread
+// over several shards which are locked independently, so concurrent script
+// validation only contends when it touches the same shard.
 type SigCache struct {
-	sync.RWMutex
-	validSigs  map[chainhash.Hash]sigCacheEntry
+	numEntries int64 // atomic
+	shards     [sigCacheShards]sigCacheShard
 	maxEntries uint
 }
 
@@ -44,23 +78,41 @@ type SigCache struct {
 // to make room for new entries that would cause the number of entries in the
 // cache to exceed the max.
 func NewSigCache(maxEntries uint) *SigCache {
-	return &SigCache{
-		validSigs:  make(map[chainhash.Hash]sigCacheEntry, maxEntries),
-		maxEntries: maxEntries,
+	s := &SigCache{maxEntries: maxEntries}
+	for i := range s.shards {
+		s.shards[i].validSigs = make(map[sigCacheKey]struct{},
+			maxEntries/sigCacheShards)
 	}
+	return s
+}
+
+// shard returns the shard holding the entry with the passed key.
+func (s *SigCache) shard(key *sigCacheKey) *sigCacheShard {
+	return &s.shards[key[0]%sigCacheShards]
+}
+
+// len returns the number of entries in the SigCache.
+func (s *SigCache) len() int {
+	return int(atomic.LoadInt64(&s.numEntries))
 }
 
 // Exists returns true if an existing entry of 'sig' over 'sigHash' for public
 // key 'pubKey' is found within the SigCache. Otherwise, false is returned.
 //
 // NOTE: This function is safe for concurrent access. Readers won't be blocked
-// unless there exists a writer, adding an entry to the SigCache.
+// unless there exists a writer adding an entry to the same shard.
 func (s *SigCache) Exists(sigHash chainhash.Hash, sig *ulordec.Signature, pubKey *ulordec.PublicKey) bool {
-	s.RLock()
-	entry, ok := s.validSigs[sigHash]
-	s.RUnlock()
+	if s.maxEntries <= 0 {
+		return false
+	}
+
+	key := newSigCacheKey(&sigHash, sig, pubKey)
+	shard := s.shard(&key)
+	shard.RLock()
+	_, ok := shard.validSigs[key]
+	shard.RUnlock()
 
-	return ok && entry.pubKey.IsEqual(pubKey) && entry.sig.IsEqual(sig)
+	return ok
 }
 
 // Add adds an entry for a signature over 'sigHash' under public key 'pubKey'
@@ -68,19 +120,44 @@ func (s *SigCache) Exists(sigHash chainhash.Hash, sig *ulordec.Signature, pubKey
 // existing entry is randomly chosen to be evicted in order to make space for
 // the new entry.
 //
-// NOTE: This function is safe for concurrent access. Writers will block
-// simultaneous readers until function execution has concluded.
+// NOTE: This function is safe for concurrent access. Writers only block
+// readers and writers of the same shard.  Concurrent additions to a full
+// cache may briefly exceed the max number of entries by the number of
+// concurrent writers.
 func (s *SigCache) Add(sigHash chainhash.Hash, sig *ulordec.Signature, pubKey *ulordec.PublicKey) {
-	s.Lock()
-	defer s.Unlock()
-
 	if s.maxEntries <= 0 {
 		return
 	}
 
+	key := newSigCacheKey(&sigHash, sig, pubKey)
+	shard := s.shard(&key)
+	shard.RLock()
+	_, ok := shard.validSigs[key]
+	shard.RUnlock()
+	if ok {
+		return
+	}
+
 	// If adding this new entry will put us over the max number of allowed
 	// entries, then evict an entry.
-	if uint(len(s.validSigs)+1) > s.maxEntries {
+	if uint(s.len()+1) > s.maxEntries {
+		s.evict(int(key[0] % sigCacheShards))
+	}
+
+	shard.Lock()
+	if _, ok := shard.validSigs[key]; !ok {
+		shard.validSigs[key] = struct{}{}
+		atomic.AddInt64(&s.numEntries, 1)
+	}
+	shard.Unlock()
+}
+
+// evict removes a random entry from the SigCache, preferring the shard with the
+// passed index and falling back to the following shards when it is empty.
+func (s *SigCache) evict(first int) {
+	for i := 0; i < sigCacheShards; i++ {
+		shard := &s.shards[(first+i)%sigCacheShards]
+		shard.Lock()
 		// Remove a random entry from the map. Relying on the random
 		// starting point of Go's map iteration. It's worth noting that
 		// the random iteration starting point is not 100% guaranteed
@@ -90,10 +167,12 @@ func (s *SigCache) Add(sigHash chainhash.Hash, sig *ulordec.Signature, pubKey *u
 		// would need to be able to execute preimage attacks on the
 		// hashing function in order to start eviction at a specific
 		// entry.
-		for sigEntry := range s.validSigs {
-			delete(s.validSigs, sigEntry)
-			break
+		for key := range shard.validSigs {
+			delete(shard.validSigs, key)
+			atomic.AddInt64(&s.numEntries, -1)
+			shard.Unlock()
+			return
 		}
+		shard.Unlock()
 	}
-	s.validSigs[sigHash] = sigCacheEntry{sig, pubKey}
 }
@@ -82,9 +82,9 @@ func TestSigCacheAddEvictEntry(t *testing.T) {
 	}
 
 	// The sigcache should now have sigCacheSize entries within it.
-	if uint(len(sigCache.validSigs)) != sigCacheSize {
+	if uint(sigCache.len()) != sigCacheSize {
 		t.Fatalf("sigcache should now have %v entries, instead it has %v",
-			sigCacheSize, len(sigCache.validSigs))
+			sigCacheSize, sigCache.len())
 	}
 
 	// Add a new entry, this should cause eviction of a randomly chosen
@@ -96,9 +96,9 @@ func TestSigCacheAddEvictEntry(t *testing.T) {
 	sigCache.Add(*msgNew, sigNew, keyNew)
 
 	// The sigcache should still have sigCache entries.
-	if uint(len(sigCache.validSigs)) != sigCacheSize {
+	if uint(sigCache.len()) != sigCacheSize {
 		t.Fatalf("sigcache should now have %v entries, instead it has %v",
-			sigCacheSize, len(sigCache.validSigs))
+			sigCacheSize, sigCache.len())
 	}
 
 	// The entry added above should be found within the sigcache.
@@ -133,8 +133,53 @@ func TestSigCacheAddMaxEntriesZeroOrNegative(t *testing.T) {
 	}
 
 	// There shouldn't be any entries in the sigCache.
-	if len(sigCache.validSigs) != 0 {
+	if sigCache.len() != 0 {
 		t.Errorf("%v items found in sigcache, no items should have"+
-			"been added", len(sigCache.validSigs))
+			"been added", sigCache.len())
+	}
+}
+
+// TestSigCacheSameSigHash ensures signatures over the same sigHash under
+// different public keys are cached as distinct entries rather than replacing
+// each other.
+func TestSigCacheSameSigHash(t *testing.T) {
+	sigCache := NewSigCache(10)
+
+	msg, sig1, key1, err := genRandomSig()
+	if err != nil {
+		t.Fatalf("unable to generate random signature test data")
+	}
+	privKey2, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("unable to generate private key: %v", err)
+	}
+	sig2, err := privKey2.Sign(msg[:])
+	if err != nil {
+		t.Fatalf("unable to sign message: %v", err)
+	}
+	key2 := privKey2.PubKey()
+
+	sigCache.Add(*msg, sig1, key1)
+	sigCache.Add(*msg, sig2, key2)
+	if sigCache.len() != 2 {
+		t.Fatalf("sigcache should have 2 entries, instead it has %v",
+			sigCache.len())
+	}
+	if !sigCache.Exists(*msg, sig1, key1) ||
+		!sigCache.Exists(*msg, sig2, key2) {
+		t.Fatalf("previously added item not found in signature cache")
+	}
+
+	// Mixing the signature of one entry with the key of the other must not
+	// be found.
+	if sigCache.Exists(*msg, sig1, key2) || sigCache.Exists(*msg, sig2, key1) {
+		t.Fatalf("mismatched signature and key found in signature cache")
+	}
+
+	// Adding an existing entry again must not add another entry.
+	sigCache.Add(*msg, sig1, key1)
+	if sigCache.len() != 2 {
+		t.Fatalf("sigcache should have 2 entries, instead it has %v",
+			sigCache.len())
 	}
 }
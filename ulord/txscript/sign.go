@@ -5,6 +5,8 @@
 package txscript
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 
@@ -462,3 +464,188 @@ func SignTxOutput(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 		addresses, nrequired, sigScript, previousScript)
 	return mergedScript, nil
 }
+
+// signWitnessScript creates the witness stack items, excluding the witness
+// script itself, which satisfy the passed witness script of a p2wsh output
+// using signatures observing the transaction digest algorithm defined within
+// BIP0143.  Multisig scripts which can't be fully signed with the available
+// keys are signed partially.
+func signWitnessScript(chainParams *chaincfg.Params, tx *wire.MsgTx,
+	sigHashes *TxSigHashes, idx int, amt int64, witnessScript []byte,
+	hashType SigHashType, kdb KeyDB) ([][]byte, error) {
+
+	class, addresses, nRequired, err := ExtractPkScriptAddrs(witnessScript,
+		chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	switch class {
+	case PubKeyTy:
+		key, _, err := kdb.GetKey(addresses[0])
+		if err != nil {
+			return nil, err
+		}
+		sig, err := RawTxInWitnessSignature(tx, sigHashes, idx, amt,
+			witnessScript, hashType, key)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{sig}, nil
+
+	case PubKeyHashTy:
+		key, compressed, err := kdb.GetKey(addresses[0])
+		if err != nil {
+			return nil, err
+		}
+		sig, err := RawTxInWitnessSignature(tx, sigHashes, idx, amt,
+			witnessScript, hashType, key)
+		if err != nil {
+			return nil, err
+		}
+		pk := (*ulordec.PublicKey)(&key.PublicKey)
+		pkData := pk.SerializeUncompressed()
+		if compressed {
+			pkData = pk.SerializeCompressed()
+		}
+		return [][]byte{sig, pkData}, nil
+
+	case MultiSigTy:
+		// The first item is the dummy element consumed by
+		// OP_CHECKMULTISIG, which must be empty under the null dummy
+		// rule.
+		items := [][]byte{nil}
+		for _, addr := range addresses {
+			if len(items)-1 == nRequired {
+				break
+			}
+			key, _, err := kdb.GetKey(addr)
+			if err != nil {
+				continue
+			}
+			sig, err := RawTxInWitnessSignature(tx, sigHashes, idx,
+				amt, witnessScript, hashType, key)
+			if err != nil {
+				continue
+			}
+			items = append(items, sig)
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("can't sign witness script of type %v",
+			class)
+	}
+}
+
+// signWitnessProgram creates the witness which spends the passed version 0
+// witness program.
+func signWitnessProgram(chainParams *chaincfg.Params, tx *wire.MsgTx,
+	sigHashes *TxSigHashes, idx int, amt int64, witnessProgram []byte,
+	hashType SigHashType, kdb KeyDB, sdb ScriptDB) (wire.TxWitness, error) {
+
+	class, addresses, _, err := ExtractPkScriptAddrs(witnessProgram,
+		chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	switch class {
+	case WitnessV0PubKeyHashTy:
+		key, compressed, err := kdb.GetKey(addresses[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// Only compressed public keys are allowed in p2wkh witnesses
+		// by the standardness rules.
+		if !compressed {
+			return nil, errors.New("p2wkh outputs require compressed " +
+				"public keys")
+		}
+		return WitnessSignature(tx, sigHashes, idx, amt, witnessProgram,
+			hashType, key, true)
+
+	case WitnessV0ScriptHashTy:
+		witnessScript, err := sdb.GetScript(addresses[0])
+		if err != nil {
+			return nil, err
+		}
+		scriptHash := sha256.Sum256(witnessScript)
+		if !bytes.Equal(scriptHash[:], addresses[0].ScriptAddress()) {
+			return nil, errors.New("witness script does not match " +
+				"the witness program")
+		}
+
+		items, err := signWitnessScript(chainParams, tx, sigHashes, idx,
+			amt, witnessScript, hashType, kdb)
+		if err != nil {
+			return nil, err
+		}
+		return append(wire.TxWitness(items), witnessScript), nil
+
+	default:
+		return nil, fmt.Errorf("can't sign witness program of type %v",
+			class)
+	}
+}
+
+// SignTxOutputWitness signs output idx of the given tx to resolve the script
+// given in pkScript with a signature type of hashType and returns the
+// signature script and the witness of the input.  Version 0 witness programs,
+// both native and nested in pay-to-script-hash outputs, are signed observing
+// the transaction digest algorithm defined within BIP0143, which commits to
+// the amt of the output being spent.  Other outputs are signed like
+// SignTxOutput does, in which case the witness is nil.
+//
+// Keys and witness scripts are looked up like SignTxOutput does, where the
+// witness scripts of p2wsh outputs are looked up by their p2wsh address.
+func SignTxOutputWitness(chainParams *chaincfg.Params, tx *wire.MsgTx,
+	idx int, amt int64, pkScript []byte, sigHashes *TxSigHashes,
+	hashType SigHashType, kdb KeyDB, sdb ScriptDB) ([]byte, wire.TxWitness,
+	error) {
+
+	if IsWitnessProgram(pkScript) {
+		witness, err := signWitnessProgram(chainParams, tx, sigHashes,
+			idx, amt, pkScript, hashType, kdb, sdb)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, witness, nil
+	}
+
+	// Witness programs nested in pay-to-script-hash outputs are spent by
+	// pushing the witness program as the redeem script.
+	if IsPayToScriptHash(pkScript) {
+		_, addresses, _, err := ExtractPkScriptAddrs(pkScript,
+			chainParams)
+		if err != nil {
+			return nil, nil, err
+		}
+		redeemScript, err := sdb.GetScript(addresses[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if IsWitnessProgram(redeemScript) {
+			witness, err := signWitnessProgram(chainParams, tx,
+				sigHashes, idx, amt, redeemScript, hashType, kdb,
+				sdb)
+			if err != nil {
+				return nil, nil, err
+			}
+			sigScript, err := NewScriptBuilder().AddData(
+				redeemScript).Script()
+			if err != nil {
+				return nil, nil, err
+			}
+			return sigScript, witness, nil
+		}
+	}
+
+	sigScript, err := SignTxOutput(chainParams, tx, idx, pkScript,
+		hashType, kdb, sdb, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sigScript, nil, nil
+}
@@ -5,6 +5,7 @@
 package txscript
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"testing"
@@ -1714,3 +1715,138 @@ nexttest:
 		}
 	}
 }
+
+// TestSignTxOutputWitness ensures native and nested witness programs are
+// signed with valid witnesses and other outputs with valid signature scripts.
+func TestSignTxOutputWitness(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.TestNet3Params
+	mustAddr := func(addr ulordutil.Address, err error) ulordutil.Address {
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		return addr
+	}
+	mustScript := func(script []byte, err error) []byte {
+		if err != nil {
+			t.Fatalf("unable to create script: %v", err)
+		}
+		return script
+	}
+
+	keys := make(map[string]addressToKey)
+	scripts := make(map[string][]byte)
+	var pubKeys []*ulordutil.AddressPubKey
+	var pkhAddrs []ulordutil.Address
+	for i := 0; i < 2; i++ {
+		key, err := ulordec.NewPrivateKey(ulordec.S256())
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		pkData := key.PubKey().SerializeCompressed()
+		pkhAddr := mustAddr(ulordutil.NewAddressPubKeyHash(
+			ulordutil.Hash160(pkData), params))
+		wpkhAddr := mustAddr(ulordutil.NewAddressWitnessPubKeyHash(
+			ulordutil.Hash160(pkData), params))
+		pubKey, err := ulordutil.NewAddressPubKey(pkData, params)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		keys[pkhAddr.EncodeAddress()] = addressToKey{key, true}
+		keys[wpkhAddr.EncodeAddress()] = addressToKey{key, true}
+		pubKeys = append(pubKeys, pubKey)
+		pkhAddrs = append(pkhAddrs, pkhAddr)
+	}
+
+	// witnessScriptHash returns the p2wsh output paying to the passed
+	// witness script and registers the script.
+	witnessScriptHash := func(witnessScript []byte) []byte {
+		hash := sha256.Sum256(witnessScript)
+		addr := mustAddr(ulordutil.NewAddressWitnessScriptHash(hash[:],
+			params))
+		scripts[addr.EncodeAddress()] = witnessScript
+		return mustScript(PayToAddrScript(addr))
+	}
+
+	// scriptHash returns the p2sh output paying to the passed redeem
+	// script and registers the script.
+	scriptHash := func(redeemScript []byte) []byte {
+		addr := mustAddr(ulordutil.NewAddressScriptHash(redeemScript,
+			params))
+		scripts[addr.EncodeAddress()] = redeemScript
+		return mustScript(PayToAddrScript(addr))
+	}
+
+	wpkhAddr := mustAddr(ulordutil.NewAddressWitnessPubKeyHash(
+		pkhAddrs[0].ScriptAddress(), params))
+	p2wpkh := mustScript(PayToAddrScript(wpkhAddr))
+	multiSig := mustScript(MultiSigScript(pubKeys, 2))
+	p2pkh := mustScript(PayToAddrScript(pkhAddrs[1]))
+
+	tests := []struct {
+		name        string
+		pkScript    []byte
+		wantWitness bool
+	}{
+		{"p2wkh", p2wpkh, true},
+		{"p2sh-p2wkh", scriptHash(p2wpkh), true},
+		{"p2wsh multisig", witnessScriptHash(multiSig), true},
+		{"p2wsh p2pkh", witnessScriptHash(p2pkh), true},
+		{"p2sh-p2wsh multisig", scriptHash(witnessScriptHash(multiSig)), true},
+		{"p2pkh", p2pkh, false},
+	}
+
+	const amt = 100000
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{Value: amt - 1000, PkScript: p2pkh}},
+	}
+	for _, test := range tests {
+		sigHashes := NewTxSigHashes(tx)
+		sigScript, witness, err := SignTxOutputWitness(params, tx, 0,
+			amt, test.pkScript, sigHashes, SigHashAll,
+			mkGetKey(keys), mkGetScript(scripts))
+		if err != nil {
+			t.Errorf("%s: unable to sign: %v", test.name, err)
+			continue
+		}
+		if (witness != nil) != test.wantWitness {
+			t.Errorf("%s: got witness %x, want witness %v", test.name,
+				witness, test.wantWitness)
+			continue
+		}
+
+		tx.TxIn[0].SignatureScript = sigScript
+		tx.TxIn[0].Witness = witness
+		vm, err := NewEngine(test.pkScript, tx, 0, StandardVerifyFlags,
+			nil, sigHashes, amt)
+		if err != nil {
+			t.Errorf("%s: unable to create engine: %v", test.name, err)
+			continue
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: invalid signature: %v", test.name, err)
+			continue
+		}
+
+		// Witness signatures commit to the amount being spent.
+		if !test.wantWitness {
+			continue
+		}
+		vm, err = NewEngine(test.pkScript, tx, 0, StandardVerifyFlags,
+			nil, sigHashes, amt+1)
+		if err != nil {
+			t.Errorf("%s: unable to create engine: %v", test.name, err)
+			continue
+		}
+		if err := vm.Execute(); err == nil {
+			t.Errorf("%s: signature valid for a different amount",
+				test.name)
+		}
+	}
+}
@@ -23,10 +23,7 @@ const (
 	// help reduce issues related to transaction malleability as well as
 	// allow pay-to-script hash transactions.  Note these flags are
 	// different than what is required for the consensus rules in that they
-	// are more strict.
-	//
-	// TODO: This definition does not belong here.  It belongs in a policy
-	// package.
+	// are more strict.  They are the default VerifyFlags of Policy.
 	StandardVerifyFlags = ScriptBip16 |
 		ScriptVerifyDERSignatures |
 		ScriptVerifyStrictEncoding |
@@ -141,9 +138,15 @@ func isMultiSig(pops []parsedOpcode) bool {
 // isNullData returns true if the passed script is a null data transaction,
 // false otherwise.
 func isNullData(pops []parsedOpcode) bool {
+	return isNullDataSize(pops, MaxDataCarrierSize)
+}
+
+// isNullDataSize returns true if the passed script is a null data transaction
+// which pushes at most the passed number of bytes, false otherwise.
+func isNullDataSize(pops []parsedOpcode, maxSize int) bool {
 	// A nulldata transaction is either a single OP_RETURN or an
 	// OP_RETURN SMALLDATA (where SMALLDATA is a data push up to
-	// MaxDataCarrierSize bytes).
+	// maxSize bytes).
 	l := len(pops)
 	if l == 1 && pops[0].opcode.value == OP_RETURN {
 		return true
@@ -153,7 +156,7 @@ func isNullData(pops []parsedOpcode) bool {
 		pops[0].opcode.value == OP_RETURN &&
 		(isSmallInt(pops[1].opcode) || pops[1].opcode.value <=
 			OP_PUSHDATA4) &&
-		len(pops[1].data) <= MaxDataCarrierSize
+		len(pops[1].data) <= maxSize
 }
 
 // scriptType returns the type of the script being inspected from the known
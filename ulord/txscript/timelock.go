@@ -0,0 +1,227 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"fmt"
+
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// CheckLockTimeVerifyScript returns a script which requires the spending
+// transaction to have a lock time of at least lockTime before the passed
+// script is evaluated.  The resulting script is of the form:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <script>
+//
+// Lock times below LockTimeThreshold are interpreted as block heights while
+// all others are interpreted as unix timestamps.
+func CheckLockTimeVerifyScript(lockTime uint32, script []byte) ([]byte, error) {
+	return NewScriptBuilder().AddInt64(int64(lockTime)).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP).
+		AddOps(script).Script()
+}
+
+// CheckSequenceVerifyScript returns a script which requires the spending
+// input to have a relative lock time of at least sequence before the passed
+// script is evaluated.  The resulting script is of the form:
+//
+//	<sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <script>
+//
+// The sequence is encoded as described by BIP0068.  An Error with the error
+// code ErrInvalidLockTime will be returned if the sequence has the disable
+// flag set since the relative lock time would never be enforced.
+func CheckSequenceVerifyScript(sequence uint32, script []byte) ([]byte, error) {
+	if err := checkRelativeLockTime(sequence); err != nil {
+		return nil, err
+	}
+
+	return NewScriptBuilder().AddInt64(int64(sequence)).
+		AddOp(OP_CHECKSEQUENCEVERIFY).AddOp(OP_DROP).
+		AddOps(script).Script()
+}
+
+// EscrowScript returns a time-locked escrow script which may be redeemed at
+// any time with signatures from both the payer and the payee, or by the payer
+// alone once the spending transaction has a lock time of at least lockTime.
+// The resulting script is of the form:
+//
+//	OP_IF
+//	  2 <payer> <payee> 2 OP_CHECKMULTISIG
+//	OP_ELSE
+//	  <lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <payer> OP_CHECKSIG
+//	OP_ENDIF
+//
+// See EscrowSigScript and EscrowRefundSigScript for redeeming it.
+func EscrowScript(lockTime uint32, payer, payee *ulordutil.AddressPubKey) ([]byte, error) {
+	return NewScriptBuilder().
+		AddOp(OP_IF).
+		AddOp(OP_2).AddData(payer.ScriptAddress()).
+		AddData(payee.ScriptAddress()).AddOp(OP_2).
+		AddOp(OP_CHECKMULTISIG).
+		AddOp(OP_ELSE).
+		AddInt64(int64(lockTime)).AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).AddData(payer.ScriptAddress()).AddOp(OP_CHECKSIG).
+		AddOp(OP_ENDIF).
+		Script()
+}
+
+// DelayedPaymentScript returns a payment channel style script which pays to
+// the delayed key once the spending input has a relative lock time of at
+// least sequence, or immediately to the revocation key.  The resulting script
+// is of the form:
+//
+//	OP_IF
+//	  <revocation>
+//	OP_ELSE
+//	  <sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <delayed>
+//	OP_ENDIF
+//	OP_CHECKSIG
+//
+// An Error with the error code ErrInvalidLockTime will be returned if the
+// sequence has the disable flag set.  See DelayedPaymentSigScript and
+// RevocationSigScript for redeeming it.
+func DelayedPaymentScript(sequence uint32, delayed, revocation *ulordutil.AddressPubKey) ([]byte, error) {
+	if err := checkRelativeLockTime(sequence); err != nil {
+		return nil, err
+	}
+
+	return NewScriptBuilder().
+		AddOp(OP_IF).
+		AddData(revocation.ScriptAddress()).
+		AddOp(OP_ELSE).
+		AddInt64(int64(sequence)).AddOp(OP_CHECKSEQUENCEVERIFY).
+		AddOp(OP_DROP).AddData(delayed.ScriptAddress()).
+		AddOp(OP_ENDIF).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// branchSigScript returns a signature script which pushes the passed data
+// followed by the branch selector for an OP_IF and, when it is not nil, the
+// redeem script required to spend a pay-to-script-hash output.
+func branchSigScript(data [][]byte, branch bool, redeemScript []byte) ([]byte, error) {
+	builder := NewScriptBuilder()
+	for _, d := range data {
+		builder.AddData(d)
+	}
+	if branch {
+		builder.AddOp(OP_TRUE)
+	} else {
+		builder.AddOp(OP_FALSE)
+	}
+	if redeemScript != nil {
+		builder.AddData(redeemScript)
+	}
+	return builder.Script()
+}
+
+// EscrowSigScript returns a signature script which redeems an EscrowScript
+// with signatures from both the payer and the payee.  When redeemScript is not
+// nil it is pushed last as required to spend a pay-to-script-hash output.
+func EscrowSigScript(payerSig, payeeSig, redeemScript []byte) ([]byte, error) {
+	// The extra OP_0 is consumed by the off-by-one bug in
+	// OP_CHECKMULTISIG.
+	return branchSigScript([][]byte{nil, payerSig, payeeSig}, true,
+		redeemScript)
+}
+
+// EscrowRefundSigScript returns a signature script which redeems an
+// EscrowScript with the payer signature alone once its lock time has been
+// reached.  The spending transaction must be prepared with
+// PrepareLockTimeSpend.  When redeemScript is not nil it is pushed last as
+// required to spend a pay-to-script-hash output.
+func EscrowRefundSigScript(payerSig, redeemScript []byte) ([]byte, error) {
+	return branchSigScript([][]byte{payerSig}, false, redeemScript)
+}
+
+// DelayedPaymentSigScript returns a signature script which redeems a
+// DelayedPaymentScript with the delayed key signature once its relative lock
+// time has been reached.  The spending input must be prepared with
+// PrepareSequenceSpend.  When redeemScript is not nil it is pushed last as
+// required to spend a pay-to-script-hash output.
+func DelayedPaymentSigScript(delayedSig, redeemScript []byte) ([]byte, error) {
+	return branchSigScript([][]byte{delayedSig}, false, redeemScript)
+}
+
+// RevocationSigScript returns a signature script which redeems a
+// DelayedPaymentScript with the revocation key signature.  When redeemScript
+// is not nil it is pushed last as required to spend a pay-to-script-hash
+// output.
+func RevocationSigScript(revocationSig, redeemScript []byte) ([]byte, error) {
+	return branchSigScript([][]byte{revocationSig}, true, redeemScript)
+}
+
+// checkRelativeLockTime returns an error if the passed sequence does not
+// encode a relative lock time.
+func checkRelativeLockTime(sequence uint32) error {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		str := fmt.Sprintf("sequence %#08x has the relative lock "+
+			"time disable flag set", sequence)
+		return scriptError(ErrInvalidLockTime, str)
+	}
+	return nil
+}
+
+// PrepareLockTimeSpend sets the lock time of the passed transaction so that
+// the input at the provided index satisfies an OP_CHECKLOCKTIMEVERIFY for
+// lockTime.  The transaction lock time is only ever raised so that other
+// inputs with lock time requirements remain satisfied, and the sequence of the
+// input is made non-final since a final input disables the lock time.  The
+// signature for the input must be generated after calling this function.
+//
+// An Error with the error code ErrInvalidIndex will be returned for an
+// out-of-bounds index and ErrInvalidLockTime when the transaction already has
+// a lock time of the other kind (block height versus timestamp).
+func PrepareLockTimeSpend(tx *wire.MsgTx, idx int, lockTime uint32) error {
+	if idx < 0 || idx >= len(tx.TxIn) {
+		str := fmt.Sprintf("transaction input index %d is out of "+
+			"bounds for %d inputs", idx, len(tx.TxIn))
+		return scriptError(ErrInvalidIndex, str)
+	}
+
+	if tx.LockTime != 0 && (tx.LockTime < LockTimeThreshold) !=
+		(lockTime < LockTimeThreshold) {
+
+		str := fmt.Sprintf("lock time %d is not of the same kind as "+
+			"the transaction lock time %d", lockTime, tx.LockTime)
+		return scriptError(ErrInvalidLockTime, str)
+	}
+
+	if lockTime > tx.LockTime {
+		tx.LockTime = lockTime
+	}
+	if tx.TxIn[idx].Sequence == wire.MaxTxInSequenceNum {
+		tx.TxIn[idx].Sequence = wire.MaxTxInSequenceNum - 1
+	}
+	return nil
+}
+
+// PrepareSequenceSpend sets the sequence of the input at the provided index
+// of the passed transaction so that it satisfies an OP_CHECKSEQUENCEVERIFY for
+// sequence.  The transaction version is raised to 2 when necessary since
+// relative lock times are only enforced from that version on.  The signature
+// for the input must be generated after calling this function.
+//
+// An Error with the error code ErrInvalidIndex will be returned for an
+// out-of-bounds index and ErrInvalidLockTime when the sequence has the
+// disable flag set.
+func PrepareSequenceSpend(tx *wire.MsgTx, idx int, sequence uint32) error {
+	if idx < 0 || idx >= len(tx.TxIn) {
+		str := fmt.Sprintf("transaction input index %d is out of "+
+			"bounds for %d inputs", idx, len(tx.TxIn))
+		return scriptError(ErrInvalidIndex, str)
+	}
+	if err := checkRelativeLockTime(sequence); err != nil {
+		return err
+	}
+
+	if tx.Version < 2 {
+		tx.Version = 2
+	}
+	tx.TxIn[idx].Sequence = sequence
+	return nil
+}
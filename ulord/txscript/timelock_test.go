@@ -0,0 +1,297 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// newTimeLockKey returns a new private key along with its compressed public
+// key address.
+func newTimeLockKey(t *testing.T) (*ulordec.PrivateKey, *ulordutil.AddressPubKey) {
+	key, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("failed to make private key: %v", err)
+	}
+	addr, err := ulordutil.NewAddressPubKey(key.PubKey().SerializeCompressed(),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("failed to make address: %v", err)
+	}
+	return key, addr
+}
+
+// newTimeLockSpend returns a transaction spending a pay-to-script-hash output
+// of the passed redeem script along with the output script.
+func newTimeLockSpend(t *testing.T, redeemScript []byte) (*wire.MsgTx, []byte) {
+	scriptHash := ulordutil.Hash160(redeemScript)
+	pkScript, err := payToScriptHashScript(scriptHash)
+	if err != nil {
+		t.Fatalf("failed to make pkScript: %v", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil,
+		nil))
+	tx.AddTxOut(wire.NewTxOut(1e8, []byte{OP_TRUE}))
+	return tx, pkScript
+}
+
+// signTimeLockSpend signs the only input of the passed transaction for the
+// redeem script with each of the provided keys.
+func signTimeLockSpend(t *testing.T, tx *wire.MsgTx, redeemScript []byte,
+	keys ...*ulordec.PrivateKey) [][]byte {
+
+	sigs := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		sig, err := RawTxInSignature(tx, 0, redeemScript, SigHashAll,
+			key)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// executeTimeLockSpend executes the scripts of the only input of the passed
+// transaction.
+func executeTimeLockSpend(tx *wire.MsgTx, sigScript, pkScript []byte) error {
+	tx.TxIn[0].SignatureScript = sigScript
+	vm, err := NewEngine(pkScript, tx, 0, StandardVerifyFlags, nil, nil,
+		1e8)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// TestTimeLockScripts ensures the CLTV and CSV prefix scripts are built as
+// expected.
+func TestTimeLockScripts(t *testing.T) {
+	t.Parallel()
+
+	script, err := CheckLockTimeVerifyScript(500000, []byte{OP_TRUE})
+	if err != nil {
+		t.Fatalf("CheckLockTimeVerifyScript: unexpected error: %v", err)
+	}
+	want := mustParseShortForm("500000 CHECKLOCKTIMEVERIFY DROP TRUE")
+	if !bytes.Equal(script, want) {
+		t.Fatalf("CheckLockTimeVerifyScript: got %x, want %x", script,
+			want)
+	}
+
+	script, err = CheckSequenceVerifyScript(144, []byte{OP_TRUE})
+	if err != nil {
+		t.Fatalf("CheckSequenceVerifyScript: unexpected error: %v", err)
+	}
+	want = mustParseShortForm("144 CHECKSEQUENCEVERIFY DROP TRUE")
+	if !bytes.Equal(script, want) {
+		t.Fatalf("CheckSequenceVerifyScript: got %x, want %x", script,
+			want)
+	}
+
+	_, err = CheckSequenceVerifyScript(wire.SequenceLockTimeDisabled|144,
+		[]byte{OP_TRUE})
+	if !IsErrorCode(err, ErrInvalidLockTime) {
+		t.Fatalf("CheckSequenceVerifyScript: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidLockTime)
+	}
+
+	_, delayed := newTimeLockKey(t)
+	_, err = DelayedPaymentScript(wire.SequenceLockTimeDisabled, delayed,
+		delayed)
+	if !IsErrorCode(err, ErrInvalidLockTime) {
+		t.Fatalf("DelayedPaymentScript: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidLockTime)
+	}
+}
+
+// TestEscrowScript ensures an escrow script can be redeemed cooperatively at
+// any time and by the payer alone only once its lock time has been reached.
+func TestEscrowScript(t *testing.T) {
+	t.Parallel()
+
+	const lockTime = 500000
+	payerKey, payer := newTimeLockKey(t)
+	payeeKey, payee := newTimeLockKey(t)
+	redeemScript, err := EscrowScript(lockTime, payer, payee)
+	if err != nil {
+		t.Fatalf("EscrowScript: unexpected error: %v", err)
+	}
+
+	// Both parties may redeem the escrow without any lock time.
+	tx, pkScript := newTimeLockSpend(t, redeemScript)
+	sigs := signTimeLockSpend(t, tx, redeemScript, payerKey, payeeKey)
+	sigScript, err := EscrowSigScript(sigs[0], sigs[1], redeemScript)
+	if err != nil {
+		t.Fatalf("EscrowSigScript: unexpected error: %v", err)
+	}
+	if err := executeTimeLockSpend(tx, sigScript, pkScript); err != nil {
+		t.Fatalf("cooperative spend: unexpected error: %v", err)
+	}
+
+	// The payer may not redeem the escrow alone before the lock time.
+	tx, pkScript = newTimeLockSpend(t, redeemScript)
+	if err := PrepareLockTimeSpend(tx, 0, lockTime-1); err != nil {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: %v", err)
+	}
+	sigs = signTimeLockSpend(t, tx, redeemScript, payerKey)
+	sigScript, err = EscrowRefundSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("EscrowRefundSigScript: unexpected error: %v", err)
+	}
+	err = executeTimeLockSpend(tx, sigScript, pkScript)
+	if !IsErrorCode(err, ErrUnsatisfiedLockTime) {
+		t.Fatalf("early refund: unexpected error: got %v, want %v",
+			err, ErrUnsatisfiedLockTime)
+	}
+
+	// The payer may redeem the escrow alone once the lock time has been
+	// reached.
+	tx, pkScript = newTimeLockSpend(t, redeemScript)
+	if err := PrepareLockTimeSpend(tx, 0, lockTime); err != nil {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: %v", err)
+	}
+	if tx.LockTime != lockTime ||
+		tx.TxIn[0].Sequence == wire.MaxTxInSequenceNum {
+
+		t.Fatalf("PrepareLockTimeSpend: unexpected lock time %d and "+
+			"sequence %d", tx.LockTime, tx.TxIn[0].Sequence)
+	}
+	sigs = signTimeLockSpend(t, tx, redeemScript, payerKey)
+	sigScript, err = EscrowRefundSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("EscrowRefundSigScript: unexpected error: %v", err)
+	}
+	if err := executeTimeLockSpend(tx, sigScript, pkScript); err != nil {
+		t.Fatalf("refund: unexpected error: %v", err)
+	}
+
+	// The payee may not redeem the escrow alone.
+	sigs = signTimeLockSpend(t, tx, redeemScript, payeeKey)
+	sigScript, err = EscrowRefundSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("EscrowRefundSigScript: unexpected error: %v", err)
+	}
+	if err := executeTimeLockSpend(tx, sigScript, pkScript); err == nil {
+		t.Fatal("payee refund: unexpected success")
+	}
+}
+
+// TestDelayedPaymentScript ensures a delayed payment script can be redeemed
+// with the revocation key at any time and with the delayed key only once its
+// relative lock time has been reached.
+func TestDelayedPaymentScript(t *testing.T) {
+	t.Parallel()
+
+	const sequence = 144
+	delayedKey, delayed := newTimeLockKey(t)
+	revocationKey, revocation := newTimeLockKey(t)
+	redeemScript, err := DelayedPaymentScript(sequence, delayed,
+		revocation)
+	if err != nil {
+		t.Fatalf("DelayedPaymentScript: unexpected error: %v", err)
+	}
+
+	// The revocation key may redeem the output at any time.
+	tx, pkScript := newTimeLockSpend(t, redeemScript)
+	sigs := signTimeLockSpend(t, tx, redeemScript, revocationKey)
+	sigScript, err := RevocationSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("RevocationSigScript: unexpected error: %v", err)
+	}
+	if err := executeTimeLockSpend(tx, sigScript, pkScript); err != nil {
+		t.Fatalf("revocation spend: unexpected error: %v", err)
+	}
+
+	// The delayed key may not redeem the output before the relative lock
+	// time.
+	tx, pkScript = newTimeLockSpend(t, redeemScript)
+	if err := PrepareSequenceSpend(tx, 0, sequence-1); err != nil {
+		t.Fatalf("PrepareSequenceSpend: unexpected error: %v", err)
+	}
+	sigs = signTimeLockSpend(t, tx, redeemScript, delayedKey)
+	sigScript, err = DelayedPaymentSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("DelayedPaymentSigScript: unexpected error: %v", err)
+	}
+	err = executeTimeLockSpend(tx, sigScript, pkScript)
+	if !IsErrorCode(err, ErrUnsatisfiedLockTime) {
+		t.Fatalf("early delayed spend: unexpected error: got %v, "+
+			"want %v", err, ErrUnsatisfiedLockTime)
+	}
+
+	// The delayed key may redeem the output once the relative lock time
+	// has been reached.
+	tx, pkScript = newTimeLockSpend(t, redeemScript)
+	if err := PrepareSequenceSpend(tx, 0, sequence); err != nil {
+		t.Fatalf("PrepareSequenceSpend: unexpected error: %v", err)
+	}
+	if tx.Version != 2 || tx.TxIn[0].Sequence != sequence {
+		t.Fatalf("PrepareSequenceSpend: unexpected version %d and "+
+			"sequence %d", tx.Version, tx.TxIn[0].Sequence)
+	}
+	sigs = signTimeLockSpend(t, tx, redeemScript, delayedKey)
+	sigScript, err = DelayedPaymentSigScript(sigs[0], redeemScript)
+	if err != nil {
+		t.Fatalf("DelayedPaymentSigScript: unexpected error: %v", err)
+	}
+	if err := executeTimeLockSpend(tx, sigScript, pkScript); err != nil {
+		t.Fatalf("delayed spend: unexpected error: %v", err)
+	}
+}
+
+// TestPrepareTimeLockSpend ensures the spend preparation helpers reject
+// invalid arguments and only ever raise the transaction lock time.
+func TestPrepareTimeLockSpend(t *testing.T) {
+	t.Parallel()
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil,
+		nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 1), nil,
+		nil))
+
+	err := PrepareLockTimeSpend(tx, 2, 100)
+	if !IsErrorCode(err, ErrInvalidIndex) {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidIndex)
+	}
+	err = PrepareSequenceSpend(tx, -1, 100)
+	if !IsErrorCode(err, ErrInvalidIndex) {
+		t.Fatalf("PrepareSequenceSpend: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidIndex)
+	}
+	err = PrepareSequenceSpend(tx, 0, wire.MaxTxInSequenceNum)
+	if !IsErrorCode(err, ErrInvalidLockTime) {
+		t.Fatalf("PrepareSequenceSpend: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidLockTime)
+	}
+
+	if err := PrepareLockTimeSpend(tx, 0, 200); err != nil {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: %v", err)
+	}
+	if err := PrepareLockTimeSpend(tx, 1, 100); err != nil {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: %v", err)
+	}
+	if tx.LockTime != 200 {
+		t.Fatalf("PrepareLockTimeSpend: unexpected lock time: got %d, "+
+			"want %d", tx.LockTime, 200)
+	}
+
+	err = PrepareLockTimeSpend(tx, 1, LockTimeThreshold)
+	if !IsErrorCode(err, ErrInvalidLockTime) {
+		t.Fatalf("PrepareLockTimeSpend: unexpected error: got %v, "+
+			"want %v", err, ErrInvalidLockTime)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// TraceStep records the execution of a single opcode by an engine with
+// tracing enabled.
+type TraceStep struct {
+	// ScriptIdx and OpcodeIdx identify the executed opcode.  Script index
+	// 0 is the signature script and 1 is the public key script, which may
+	// be followed by a pay-to-script-hash redeem script and a witness
+	// script.
+	ScriptIdx int
+	OpcodeIdx int
+
+	// Opcode is the disassembly of the executed opcode.
+	Opcode string
+
+	// Executed is false when the opcode was skipped since it is in a
+	// conditional branch which is not executing.
+	Executed bool
+
+	// Stack and AltStack are snapshots of the data and alternate stacks
+	// after the opcode was executed, with the top item last.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// NumOps is the number of operations of the current script executed
+	// so far, which is limited to MaxOpsPerScript.
+	NumOps int
+
+	// Err is the error the opcode failed with, if any.
+	Err error
+}
+
+// String returns a human-readable representation of the step.
+func (s *TraceStep) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%02x:%04x: %s", s.ScriptIdx, s.OpcodeIdx, s.Opcode)
+	if !s.Executed {
+		buf.WriteString(" (skipped)")
+	}
+	buf.WriteString(" stack: [")
+	for i, item := range s.Stack {
+		if i != 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(hex.EncodeToString(item))
+	}
+	buf.WriteString("]")
+	if len(s.AltStack) != 0 {
+		fmt.Fprintf(&buf, " altstack: %d items", len(s.AltStack))
+	}
+	if s.Err != nil {
+		fmt.Fprintf(&buf, " error: %v", s.Err)
+	}
+	return buf.String()
+}
+
+// Trace records the execution of scripts by an engine with tracing enabled.
+type Trace struct {
+	// Flags are the script flags the engine executes scripts with.
+	Flags ScriptFlags
+
+	// Steps are the executed opcodes in order.
+	Steps []TraceStep
+}
+
+// String returns a human-readable representation of the trace with one line
+// per executed opcode.
+func (t *Trace) String() string {
+	var buf bytes.Buffer
+	for i := range t.Steps {
+		buf.WriteString(t.Steps[i].String())
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// EngineState describes the state of an engine between steps as returned by
+// Inspect.
+type EngineState struct {
+	// ScriptIdx and OpcodeIdx identify the opcode which is executed next.
+	ScriptIdx int
+	OpcodeIdx int
+
+	// NextOpcode is the disassembly of the opcode which is executed next.
+	// It is empty once all scripts were executed.
+	NextOpcode string
+
+	// Done is true once all scripts were executed.
+	Done bool
+
+	// Stack and AltStack are snapshots of the data and alternate stacks
+	// with the top item last.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// BranchExecuting is false when the next opcode is in a conditional
+	// branch which is not executing.
+	BranchExecuting bool
+
+	// NumOps is the number of operations of the current script executed
+	// so far.
+	NumOps int
+}
+
+// EnableTracing makes the engine record every executed opcode along with
+// snapshots of the stacks in a trace, which is returned by Trace.  Tracing
+// must be enabled before the first step to record all of them.
+//
+// Errors returned by Execute and StepN with tracing enabled also describe the
+// opcode which was executed last, so tracing is useful to diagnose why a script
+// failed.  It slows down execution considerably and is intended for debugging.
+func (vm *Engine) EnableTracing() {
+	if vm.trace == nil {
+		vm.trace = &Trace{Flags: vm.flags}
+	}
+}
+
+// Trace returns the trace recorded by the engine, or nil when tracing is not
+// enabled.
+func (vm *Engine) Trace() *Trace {
+	return vm.trace
+}
+
+// Inspect returns the current state of the engine.
+func (vm *Engine) Inspect() *EngineState {
+	state := &EngineState{
+		ScriptIdx:       vm.scriptIdx,
+		OpcodeIdx:       vm.scriptOff,
+		Stack:           vm.GetStack(),
+		AltStack:        vm.GetAltStack(),
+		BranchExecuting: vm.isBranchExecuting(),
+		NumOps:          vm.numOps,
+	}
+	if err := vm.validPC(); err == nil {
+		pop := &vm.scripts[vm.scriptIdx][vm.scriptOff]
+		state.NextOpcode = pop.print(false)
+	} else {
+		state.Done = true
+	}
+	return state
+}
+
+// StepN executes up to n opcodes by calling Step repeatedly and returns whether
+// all scripts were executed.  It stops early when all scripts were executed or
+// an error occurs.  Unlike Execute, it does not check whether the scripts
+// succeeded once they were executed, which is left to CheckErrorCondition.
+//
+// The result of calling StepN or any other method is undefined if an error is
+// returned.
+func (vm *Engine) StepN(n int) (done bool, err error) {
+	for i := 0; i < n && !done; i++ {
+		done, err = vm.Step()
+		if err != nil {
+			return done, vm.traceError(err)
+		}
+	}
+	return done, nil
+}
+
+// recordStep adds the execution of the passed opcode, which is located at the
+// passed position, to the trace.
+func (vm *Engine) recordStep(scriptIdx, scriptOff int, pop *parsedOpcode, executed bool, err error) {
+	vm.trace.Steps = append(vm.trace.Steps, TraceStep{
+		ScriptIdx: scriptIdx,
+		OpcodeIdx: scriptOff,
+		Opcode:    pop.print(false),
+		Executed:  executed,
+		Stack:     vm.GetStack(),
+		AltStack:  vm.GetAltStack(),
+		NumOps:    vm.numOps,
+		Err:       err,
+	})
+}
+
+// traceError adds the position of the opcode which was executed last to the
+// description of the passed script error when tracing is enabled.
+func (vm *Engine) traceError(err error) error {
+	serr, ok := err.(Error)
+	if !ok || vm.trace == nil || len(vm.trace.Steps) == 0 {
+		return err
+	}
+	last := &vm.trace.Steps[len(vm.trace.Steps)-1]
+	serr.Description = fmt.Sprintf("%s (last executed opcode %02x:%04x: "+
+		"%s)", serr.Description, last.ScriptIdx, last.OpcodeIdx,
+		last.Opcode)
+	return serr
+}
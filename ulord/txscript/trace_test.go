@@ -0,0 +1,163 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// newTraceTestEngine returns an engine for the passed scripts in short form
+// with tracing enabled.
+func newTraceTestEngine(t *testing.T, sigScript, pkScript string) *Engine {
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			SignatureScript: mustParseShortForm(sigScript),
+			Sequence:        wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+	vm, err := NewEngine(mustParseShortForm(pkScript), tx, 0,
+		ScriptVerifySigPushOnly, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	vm.EnableTracing()
+	return vm
+}
+
+// TestTrace ensures the engine records executed opcodes and stack snapshots
+// when tracing is enabled.
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	vm := newTraceTestEngine(t, "1 2", "ADD 0 IF 4 ENDIF 3 EQUAL")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+
+	trace := vm.Trace()
+	if trace.Flags != ScriptVerifySigPushOnly {
+		t.Errorf("Flags: got %v, want %v", trace.Flags,
+			ScriptVerifySigPushOnly)
+	}
+	want := []struct {
+		scriptIdx, opcodeIdx int
+		opcode               string
+		executed             bool
+		depth                int
+	}{
+		{0, 0, "OP_1", true, 1},
+		{0, 1, "OP_2", true, 2},
+		{1, 0, "OP_ADD", true, 1},
+		{1, 1, "OP_0", true, 2},
+		{1, 2, "OP_IF", true, 1},
+		{1, 3, "OP_4", false, 1},
+		{1, 4, "OP_ENDIF", false, 1},
+		{1, 5, "OP_3", true, 2},
+		{1, 6, "OP_EQUAL", true, 1},
+	}
+	if len(trace.Steps) != len(want) {
+		t.Fatalf("Steps: got %d steps, want %d:\n%s", len(trace.Steps),
+			len(want), trace)
+	}
+	for i, w := range want {
+		step := &trace.Steps[i]
+		if step.ScriptIdx != w.scriptIdx || step.OpcodeIdx != w.opcodeIdx ||
+			step.Opcode != w.opcode || step.Executed != w.executed ||
+			len(step.Stack) != w.depth || step.Err != nil {
+
+			t.Errorf("step %d: got %v, want %+v", i, step.String(), w)
+		}
+	}
+	if !bytes.Equal(trace.Steps[2].Stack[0], []byte{3}) {
+		t.Errorf("step 2: got stack %x, want [03]", trace.Steps[2].Stack)
+	}
+
+	// Engines without tracing enabled don't record a trace.
+	tx := &wire.MsgTx{TxIn: []*wire.TxIn{{}}}
+	vm, err := NewEngine(mustParseShortForm("1"), tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+	if vm.Trace() != nil {
+		t.Errorf("Trace: unexpected trace without tracing enabled")
+	}
+}
+
+// TestTraceError ensures errors of engines with tracing enabled describe the
+// opcode which was executed last and are recorded in the trace.
+func TestTraceError(t *testing.T) {
+	t.Parallel()
+
+	vm := newTraceTestEngine(t, "1 2", "ADD 4 EQUAL")
+	err := vm.Execute()
+	if !IsErrorCode(err, ErrEvalFalse) {
+		t.Fatalf("Execute: got error %v, want %v", err, ErrEvalFalse)
+	}
+	if !strings.Contains(err.Error(), "01:0002: OP_EQUAL") {
+		t.Errorf("Execute: error %q does not describe the last opcode",
+			err)
+	}
+
+	vm = newTraceTestEngine(t, "1", "VERIFY RETURN")
+	err = vm.Execute()
+	if !IsErrorCode(err, ErrEarlyReturn) {
+		t.Fatalf("Execute: got error %v, want %v", err, ErrEarlyReturn)
+	}
+	steps := vm.Trace().Steps
+	last := &steps[len(steps)-1]
+	if last.Opcode != "OP_RETURN" || !IsErrorCode(last.Err, ErrEarlyReturn) {
+		t.Errorf("last step: got %v, want failed OP_RETURN", last)
+	}
+}
+
+// TestStepNInspect ensures StepN executes the requested number of opcodes and
+// Inspect describes the state between them.
+func TestStepNInspect(t *testing.T) {
+	t.Parallel()
+
+	vm := newTraceTestEngine(t, "1 2", "ADD 3 EQUAL")
+	state := vm.Inspect()
+	if state.ScriptIdx != 0 || state.OpcodeIdx != 0 ||
+		state.NextOpcode != "OP_1" || state.Done ||
+		len(state.Stack) != 0 || !state.BranchExecuting {
+
+		t.Errorf("Inspect: unexpected initial state %+v", state)
+	}
+
+	done, err := vm.StepN(3)
+	if err != nil || done {
+		t.Fatalf("StepN: got done %v, error %v, want not done", done, err)
+	}
+	state = vm.Inspect()
+	if state.ScriptIdx != 1 || state.OpcodeIdx != 1 ||
+		state.NextOpcode != "OP_3" || len(state.Stack) != 1 ||
+		state.NumOps != 1 {
+
+		t.Errorf("Inspect: unexpected state %+v", state)
+	}
+
+	done, err = vm.StepN(10)
+	if err != nil || !done {
+		t.Fatalf("StepN: got done %v, error %v, want done", done, err)
+	}
+	if state = vm.Inspect(); !state.Done || state.NextOpcode != "" {
+		t.Errorf("Inspect: unexpected final state %+v", state)
+	}
+	if len(vm.Trace().Steps) != 5 {
+		t.Errorf("Trace: got %d steps, want 5", len(vm.Trace().Steps))
+	}
+	if err := vm.CheckErrorCondition(true); err != nil {
+		t.Errorf("CheckErrorCondition: unexpected error: %v", err)
+	}
+}
@@ -9,11 +9,41 @@ package ulordjson
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/ulordsuite/ulord/wire"
 )
 
+// AddCheckpointCmd defines the addcheckpoint JSON-RPC command.
+type AddCheckpointCmd struct {
+	Height int32
+	Hash   string
+}
+
+// NewAddCheckpointCmd returns a new instance which can be used to issue an
+// addcheckpoint JSON-RPC command.
+func NewAddCheckpointCmd(height int32, hash string) *AddCheckpointCmd {
+	return &AddCheckpointCmd{
+		Height: height,
+		Hash:   hash,
+	}
+}
+
+// BackupChainStateCmd defines the backupchainstate JSON-RPC command.
+type BackupChainStateCmd struct {
+	Path string
+}
+
+// NewBackupChainStateCmd returns a new instance which can be used to issue a
+// backupchainstate JSON-RPC command.
+func NewBackupChainStateCmd(path string) *BackupChainStateCmd {
+	return &BackupChainStateCmd{
+		Path: path,
+	}
+}
+
 // AddNodeSubCmd defines the type used in the addnode JSON-RPC command for the
 // sub command field.
 type AddNodeSubCmd string
@@ -100,6 +130,109 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// DescriptorRange is a parameter which specifies the child indexes ranged
+// descriptors are derived at.  It holds either the end of the range, which
+// then begins at zero, or the beginning and end of the range, and is marshalled
+// as a JSON number or array accordingly.
+type DescriptorRange []int
+
+// MarshalJSON provides a custom Marshal method for DescriptorRange.
+func (r DescriptorRange) MarshalJSON() ([]byte, error) {
+	if len(r) == 1 {
+		return json.Marshal(r[0])
+	}
+	return json.Marshal([]int(r))
+}
+
+// UnmarshalJSON provides a custom Unmarshal method for DescriptorRange.
+func (r *DescriptorRange) UnmarshalJSON(data []byte) error {
+	var end int
+	if err := json.Unmarshal(data, &end); err == nil {
+		*r = DescriptorRange{end}
+		return nil
+	}
+
+	var indexes []int
+	if err := json.Unmarshal(data, &indexes); err != nil {
+		return err
+	}
+	if len(indexes) != 2 {
+		return errors.New("range must be a number or an array of " +
+			"two numbers")
+	}
+	*r = DescriptorRange(indexes)
+	return nil
+}
+
+// DeriveAddressesCmd defines the deriveaddresses JSON-RPC command.
+type DeriveAddressesCmd struct {
+	Descriptor string
+	Range      *DescriptorRange
+}
+
+// NewDeriveAddressesCmd returns a new instance which can be used to issue a
+// deriveaddresses JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDeriveAddressesCmd(descriptor string, r *DescriptorRange) *DeriveAddressesCmd {
+	return &DeriveAddressesCmd{
+		Descriptor: descriptor,
+		Range:      r,
+	}
+}
+
+// DumpTxOutSetCmd defines the dumptxoutset JSON-RPC command.
+type DumpTxOutSetCmd struct {
+	Path string
+}
+
+// NewDumpTxOutSetCmd returns a new instance which can be used to issue a
+// dumptxoutset JSON-RPC command.
+func NewDumpTxOutSetCmd(path string) *DumpTxOutSetCmd {
+	return &DumpTxOutSetCmd{
+		Path: path,
+	}
+}
+
+// EstimateSmartFeeMode defines the type used in the estimatesmartfee JSON-RPC
+// command for the estimate mode field.
+type EstimateSmartFeeMode string
+
+const (
+	// EstimateModeUnset indicates the default estimate mode, which is
+	// conservative, should be used.
+	EstimateModeUnset EstimateSmartFeeMode = "UNSET"
+
+	// EstimateModeEconomical indicates the estimate should respond quickly
+	// to falling fees at the risk of a transaction taking longer than the
+	// target to confirm.
+	EstimateModeEconomical EstimateSmartFeeMode = "ECONOMICAL"
+
+	// EstimateModeConservative indicates the estimate should be based on a
+	// longer history of blocks and require more certainty that a
+	// transaction confirms within the target.
+	EstimateModeConservative EstimateSmartFeeMode = "CONSERVATIVE"
+)
+
+// EstimateSmartFeeCmd defines the estimatesmartfee JSON-RPC command.
+type EstimateSmartFeeCmd struct {
+	ConfTarget   int64
+	EstimateMode *EstimateSmartFeeMode `jsonrpcdefault:"\"CONSERVATIVE\""`
+}
+
+// NewEstimateSmartFeeCmd returns a new instance which can be used to issue an
+// estimatesmartfee JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewEstimateSmartFeeCmd(confTarget int64, mode *EstimateSmartFeeMode) *EstimateSmartFeeCmd {
+	return &EstimateSmartFeeCmd{
+		ConfTarget:   confTarget,
+		EstimateMode: mode,
+	}
+}
+
 // GetAddedNodeInfoCmd defines the getaddednodeinfo JSON-RPC command.
 type GetAddedNodeInfoCmd struct {
 	DNS  bool
@@ -118,6 +251,61 @@ func NewGetAddedNodeInfoCmd(dns bool, node *string) *GetAddedNodeInfoCmd {
 	}
 }
 
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Address string
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(address string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Address: address,
+	}
+}
+
+// GetAddressDeltasCmd defines the getaddressdeltas JSON-RPC command.
+type GetAddressDeltasCmd struct {
+	Address string
+	Skip    *int  `jsonrpcdefault:"0"`
+	Count   *int  `jsonrpcdefault:"100"`
+	Reverse *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetAddressDeltasCmd returns a new instance which can be used to issue a
+// getaddressdeltas JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressDeltasCmd(address string, skip, count *int, reverse *bool) *GetAddressDeltasCmd {
+	return &GetAddressDeltasCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+		Reverse: reverse,
+	}
+}
+
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Address string
+	Skip    *int `jsonrpcdefault:"0"`
+	Count   *int `jsonrpcdefault:"100"`
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressUtxosCmd(address string, skip, count *int) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+	}
+}
+
 // GetBestBlockHashCmd defines the getbestblockhash JSON-RPC command.
 type GetBestBlockHashCmd struct{}
 
@@ -193,6 +381,70 @@ func NewGetBlockHeaderCmd(hash string, verbose *bool) *GetBlockHeaderCmd {
 	}
 }
 
+// HashOrHeight is a parameter which identifies a block by either its hash or
+// its height.  It is marshalled as a JSON number when it holds a height and as a
+// JSON string otherwise.
+type HashOrHeight string
+
+// MarshalJSON provides a custom Marshal method for HashOrHeight.
+func (h HashOrHeight) MarshalJSON() ([]byte, error) {
+	if height, err := strconv.ParseInt(string(h), 10, 32); err == nil {
+		return json.Marshal(height)
+	}
+	return json.Marshal(string(h))
+}
+
+// UnmarshalJSON provides a custom Unmarshal method for HashOrHeight.
+func (h *HashOrHeight) UnmarshalJSON(data []byte) error {
+	var height int32
+	if err := json.Unmarshal(data, &height); err == nil {
+		*h = HashOrHeight(strconv.FormatInt(int64(height), 10))
+		return nil
+	}
+
+	var hash string
+	if err := json.Unmarshal(data, &hash); err != nil {
+		return err
+	}
+	*h = HashOrHeight(hash)
+	return nil
+}
+
+// GetBlockHashesCmd defines the getblockhashes JSON-RPC command.
+type GetBlockHashesCmd struct {
+	High uint32
+	Low  uint32
+}
+
+// NewGetBlockHashesCmd returns a new instance which can be used to issue a
+// getblockhashes JSON-RPC command.  The hashes of the blocks with a timestamp
+// which is at least low and less than high are requested.
+func NewGetBlockHashesCmd(high, low uint32) *GetBlockHashesCmd {
+	return &GetBlockHashesCmd{
+		High: high,
+		Low:  low,
+	}
+}
+
+// GetBlockStatsCmd defines the getblockstats JSON-RPC command.
+type GetBlockStatsCmd struct {
+	HashOrHeight HashOrHeight
+	Stats        *[]string
+}
+
+// NewGetBlockStatsCmd returns a new instance which can be used to issue a
+// getblockstats JSON-RPC command.  The block is identified by either its hash
+// or its height.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockStatsCmd(hashOrHeight HashOrHeight, stats *[]string) *GetBlockStatsCmd {
+	return &GetBlockStatsCmd{
+		HashOrHeight: hashOrHeight,
+		Stats:        stats,
+	}
+}
+
 // TemplateRequest is a request object as defined in BIP22
 // (https://en.bitcoin.it/wiki/BIP_0022), it is optionally provided as an
 // pointer argument to GetBlockTemplateCmd.
@@ -321,6 +573,25 @@ func NewGetChainTipsCmd() *GetChainTipsCmd {
 	return &GetChainTipsCmd{}
 }
 
+// GetChainTxStatsCmd defines the getchaintxstats JSON-RPC command.
+type GetChainTxStatsCmd struct {
+	NBlocks   *int32
+	BlockHash *string
+}
+
+// NewGetChainTxStatsCmd returns a new instance which can be used to issue a
+// getchaintxstats JSON-RPC command.  The statistics are calculated over the
+// window of nBlocks blocks ending at the block with the passed hash.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetChainTxStatsCmd(nBlocks *int32, blockHash *string) *GetChainTxStatsCmd {
+	return &GetChainTxStatsCmd{
+		NBlocks:   nBlocks,
+		BlockHash: blockHash,
+	}
+}
+
 // GetConnectionCountCmd defines the getconnectioncount JSON-RPC command.
 type GetConnectionCountCmd struct{}
 
@@ -330,6 +601,19 @@ func NewGetConnectionCountCmd() *GetConnectionCountCmd {
 	return &GetConnectionCountCmd{}
 }
 
+// GetDescriptorInfoCmd defines the getdescriptorinfo JSON-RPC command.
+type GetDescriptorInfoCmd struct {
+	Descriptor string
+}
+
+// NewGetDescriptorInfoCmd returns a new instance which can be used to issue a
+// getdescriptorinfo JSON-RPC command.
+func NewGetDescriptorInfoCmd(descriptor string) *GetDescriptorInfoCmd {
+	return &GetDescriptorInfoCmd{
+		Descriptor: descriptor,
+	}
+}
+
 // GetDifficultyCmd defines the getdifficulty JSON-RPC command.
 type GetDifficultyCmd struct{}
 
@@ -366,6 +650,51 @@ func NewGetInfoCmd() *GetInfoCmd {
 	return &GetInfoCmd{}
 }
 
+// GetMasternodeCountCmd defines the getmasternodecount JSON-RPC command.
+type GetMasternodeCountCmd struct{}
+
+// NewGetMasternodeCountCmd returns a new instance which can be used to issue a
+// getmasternodecount JSON-RPC command.
+func NewGetMasternodeCountCmd() *GetMasternodeCountCmd {
+	return &GetMasternodeCountCmd{}
+}
+
+// GetMempoolAncestorsCmd defines the getmempoolancestors JSON-RPC command.
+type GetMempoolAncestorsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolAncestorsCmd returns a new instance which can be used to issue
+// a getmempoolancestors JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolAncestorsCmd(txHash string, verbose *bool) *GetMempoolAncestorsCmd {
+	return &GetMempoolAncestorsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
+// GetMempoolDescendantsCmd defines the getmempooldescendants JSON-RPC command.
+type GetMempoolDescendantsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolDescendantsCmd returns a new instance which can be used to
+// issue a getmempooldescendants JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolDescendantsCmd(txHash string, verbose *bool) *GetMempoolDescendantsCmd {
+	return &GetMempoolDescendantsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
 // GetMempoolEntryCmd defines the getmempoolentry JSON-RPC command.
 type GetMempoolEntryCmd struct {
 	TxID string
@@ -379,6 +708,23 @@ func NewGetMempoolEntryCmd(txHash string) *GetMempoolEntryCmd {
 	}
 }
 
+// PrioritiseTransactionCmd defines the prioritisetransaction JSON-RPC command.
+type PrioritiseTransactionCmd struct {
+	TxID     string
+	FeeDelta int64
+}
+
+// NewPrioritiseTransactionCmd returns a new instance which can be used to
+// issue a prioritisetransaction JSON-RPC command.  The fee delta, in satoshi,
+// is added to the fee of the transaction when it is selected for a block
+// template, without changing the fee the transaction actually pays.
+func NewPrioritiseTransactionCmd(txHash string, feeDelta int64) *PrioritiseTransactionCmd {
+	return &PrioritiseTransactionCmd{
+		TxID:     txHash,
+		FeeDelta: feeDelta,
+	}
+}
+
 // GetMempoolInfoCmd defines the getmempoolinfo JSON-RPC command.
 type GetMempoolInfoCmd struct{}
 
@@ -479,6 +825,26 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// SpentInfoOutPoint identifies the output whose spending input is requested by
+// the getspentinfo JSON-RPC command.
+type SpentInfoOutPoint struct {
+	Txid  string `json:"txid"`
+	Index uint32 `json:"index"`
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	OutPoint SpentInfoOutPoint
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(outPoint SpentInfoOutPoint) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		OutPoint: outPoint,
+	}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -606,6 +972,40 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ReindexCmd defines the reindex JSON-RPC command.
+type ReindexCmd struct {
+	StartHeight int32
+	Indexes     *[]string
+}
+
+// NewReindexCmd returns a new instance which can be used to issue a reindex
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewReindexCmd(startHeight int32, indexes *[]string) *ReindexCmd {
+	return &ReindexCmd{
+		StartHeight: startHeight,
+		Indexes:     indexes,
+	}
+}
+
+// RPCUserInfoCmd defines the rpcuserinfo JSON-RPC command.
+type RPCUserInfoCmd struct {
+	User *string
+}
+
+// NewRPCUserInfoCmd returns a new instance which can be used to issue a
+// rpcuserinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewRPCUserInfoCmd(user *string) *RPCUserInfoCmd {
+	return &RPCUserInfoCmd{
+		User: user,
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -670,6 +1070,35 @@ func NewSetGenerateCmd(generate bool, genProcLimit *int) *SetGenerateCmd {
 	}
 }
 
+// SetTrustedPeerSubCmd defines the type used in the settrustedpeer JSON-RPC
+// command for the sub command field.
+type SetTrustedPeerSubCmd string
+
+const (
+	// STPAdd indicates the specified IP network or address should be
+	// trusted.
+	STPAdd SetTrustedPeerSubCmd = "add"
+
+	// STPRemove indicates the specified IP network or address should no
+	// longer be trusted.
+	STPRemove SetTrustedPeerSubCmd = "remove"
+)
+
+// SetTrustedPeerCmd defines the settrustedpeer JSON-RPC command.
+type SetTrustedPeerCmd struct {
+	Addr   string
+	SubCmd SetTrustedPeerSubCmd `jsonrpcusage:"\"add|remove\""`
+}
+
+// NewSetTrustedPeerCmd returns a new instance which can be used to issue a
+// settrustedpeer JSON-RPC command.
+func NewSetTrustedPeerCmd(addr string, subCmd SetTrustedPeerSubCmd) *SetTrustedPeerCmd {
+	return &SetTrustedPeerCmd{
+		Addr:   addr,
+		SubCmd: subCmd,
+	}
+}
+
 // StopCmd defines the stop JSON-RPC command.
 type StopCmd struct{}
 
@@ -704,6 +1133,19 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// TestMempoolAcceptCmd defines the testmempoolaccept JSON-RPC command.
+type TestMempoolAcceptCmd struct {
+	RawTxns []string
+}
+
+// NewTestMempoolAcceptCmd returns a new instance which can be used to issue a
+// testmempoolaccept JSON-RPC command.
+func NewTestMempoolAcceptCmd(rawTxns []string) *TestMempoolAcceptCmd {
+	return &TestMempoolAcceptCmd{
+		RawTxns: rawTxns,
+	}
+}
+
 // UptimeCmd defines the uptime JSON-RPC command.
 type UptimeCmd struct{}
 
@@ -777,26 +1219,41 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("addcheckpoint", (*AddCheckpointCmd)(nil), flags)
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("backupchainstate", (*BackupChainStateCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("deriveaddresses", (*DeriveAddressesCmd)(nil), flags)
+	MustRegisterCmd("dumptxoutset", (*DumpTxOutSetCmd)(nil), flags)
+	MustRegisterCmd("estimatesmartfee", (*EstimateSmartFeeCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressdeltas", (*GetAddressDeltasCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getblockhashes", (*GetBlockHashesCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
+	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
 	MustRegisterCmd("getcfilter", (*GetCFilterCmd)(nil), flags)
 	MustRegisterCmd("getcfilterheader", (*GetCFilterHeaderCmd)(nil), flags)
 	MustRegisterCmd("getchaintips", (*GetChainTipsCmd)(nil), flags)
+	MustRegisterCmd("getchaintxstats", (*GetChainTxStatsCmd)(nil), flags)
 	MustRegisterCmd("getconnectioncount", (*GetConnectionCountCmd)(nil), flags)
+	MustRegisterCmd("getdescriptorinfo", (*GetDescriptorInfoCmd)(nil), flags)
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
+	MustRegisterCmd("getmasternodecount", (*GetMasternodeCountCmd)(nil), flags)
+	MustRegisterCmd("getmempoolancestors", (*GetMempoolAncestorsCmd)(nil), flags)
+	MustRegisterCmd("getmempooldescendants", (*GetMempoolDescendantsCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
 	MustRegisterCmd("getmempoolinfo", (*GetMempoolInfoCmd)(nil), flags)
 	MustRegisterCmd("getmininginfo", (*GetMiningInfoCmd)(nil), flags)
@@ -806,6 +1263,7 @@ func init() {
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
@@ -814,12 +1272,17 @@ func init() {
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
+	MustRegisterCmd("prioritisetransaction", (*PrioritiseTransactionCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("reindex", (*ReindexCmd)(nil), flags)
+	MustRegisterCmd("rpcuserinfo", (*RPCUserInfoCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
+	MustRegisterCmd("settrustedpeer", (*SetTrustedPeerCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("testmempoolaccept", (*TestMempoolAcceptCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
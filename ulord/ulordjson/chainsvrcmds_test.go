@@ -30,6 +30,17 @@ func TestChainSvrCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "addcheckpoint",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("addcheckpoint", 1000, "123")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewAddCheckpointCmd(1000, "123")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"addcheckpoint","params":[1000,"123"],"id":1}`,
+			unmarshalled: &ulordjson.AddCheckpointCmd{Height: 1000, Hash: "123"},
+		},
 		{
 			name: "addnode",
 			newCmd: func() (interface{}, error) {
@@ -103,6 +114,104 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &ulordjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "deriveaddresses",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("deriveaddresses", "addr(1Address)")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewDeriveAddressesCmd("addr(1Address)", nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"deriveaddresses","params":["addr(1Address)"],"id":1}`,
+			unmarshalled: &ulordjson.DeriveAddressesCmd{Descriptor: "addr(1Address)"},
+		},
+		{
+			name: "deriveaddresses optional - range end",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("deriveaddresses", "pkh(xpub/*)", "2")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewDeriveAddressesCmd("pkh(xpub/*)",
+					&ulordjson.DescriptorRange{2})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"deriveaddresses","params":["pkh(xpub/*)",2],"id":1}`,
+			unmarshalled: &ulordjson.DeriveAddressesCmd{
+				Descriptor: "pkh(xpub/*)",
+				Range:      &ulordjson.DescriptorRange{2},
+			},
+		},
+		{
+			name: "deriveaddresses optional - range",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("deriveaddresses", "pkh(xpub/*)", "[1,3]")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewDeriveAddressesCmd("pkh(xpub/*)",
+					&ulordjson.DescriptorRange{1, 3})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"deriveaddresses","params":["pkh(xpub/*)",[1,3]],"id":1}`,
+			unmarshalled: &ulordjson.DeriveAddressesCmd{
+				Descriptor: "pkh(xpub/*)",
+				Range:      &ulordjson.DescriptorRange{1, 3},
+			},
+		},
+		{
+			name: "backupchainstate",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("backupchainstate", "chainstate.bak")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewBackupChainStateCmd("chainstate.bak")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"backupchainstate","params":["chainstate.bak"],"id":1}`,
+			unmarshalled: &ulordjson.BackupChainStateCmd{Path: "chainstate.bak"},
+		},
+		{
+			name: "dumptxoutset",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("dumptxoutset", "utxos.dat")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewDumpTxOutSetCmd("utxos.dat")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"dumptxoutset","params":["utxos.dat"],"id":1}`,
+			unmarshalled: &ulordjson.DumpTxOutSetCmd{Path: "utxos.dat"},
+		},
+		{
+			name: "estimatesmartfee",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("estimatesmartfee", 6)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewEstimateSmartFeeCmd(6, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6],"id":1}`,
+			unmarshalled: &ulordjson.EstimateSmartFeeCmd{
+				ConfTarget: 6,
+				EstimateMode: func() *ulordjson.EstimateSmartFeeMode {
+					mode := ulordjson.EstimateModeConservative
+					return &mode
+				}(),
+			},
+		},
+		{
+			name: "estimatesmartfee optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("estimatesmartfee", 6, "ECONOMICAL")
+			},
+			staticCmd: func() interface{} {
+				mode := ulordjson.EstimateModeEconomical
+				return ulordjson.NewEstimateSmartFeeCmd(6, &mode)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6,"ECONOMICAL"],"id":1}`,
+			unmarshalled: &ulordjson.EstimateSmartFeeCmd{
+				ConfTarget: 6,
+				EstimateMode: func() *ulordjson.EstimateSmartFeeMode {
+					mode := ulordjson.EstimateModeEconomical
+					return &mode
+				}(),
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -128,6 +237,65 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: ulordjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getaddressbalance", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetAddressBalanceCmd("1Address")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getaddressbalance","params":["1Address"],"id":1}`,
+			unmarshalled: &ulordjson.GetAddressBalanceCmd{Address: "1Address"},
+		},
+		{
+			name: "getaddressdeltas",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getaddressdeltas", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetAddressDeltasCmd("1Address", nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":["1Address"],"id":1}`,
+			unmarshalled: &ulordjson.GetAddressDeltasCmd{
+				Address: "1Address",
+				Skip:    ulordjson.Int(0),
+				Count:   ulordjson.Int(100),
+				Reverse: ulordjson.Bool(false),
+			},
+		},
+		{
+			name: "getaddressdeltas optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getaddressdeltas", "1Address", 10, 20, true)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetAddressDeltasCmd("1Address",
+					ulordjson.Int(10), ulordjson.Int(20), ulordjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":["1Address",10,20,true],"id":1}`,
+			unmarshalled: &ulordjson.GetAddressDeltasCmd{
+				Address: "1Address",
+				Skip:    ulordjson.Int(10),
+				Count:   ulordjson.Int(20),
+				Reverse: ulordjson.Bool(true),
+			},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getaddressutxos", "1Address", 5)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetAddressUtxosCmd("1Address", ulordjson.Int(5), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address",5],"id":1}`,
+			unmarshalled: &ulordjson.GetAddressUtxosCmd{
+				Address: "1Address",
+				Skip:    ulordjson.Int(5),
+				Count:   ulordjson.Int(100),
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -221,6 +389,20 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockhash","params":[123],"id":1}`,
 			unmarshalled: &ulordjson.GetBlockHashCmd{Index: 123},
 		},
+		{
+			name: "getblockhashes",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getblockhashes", 1231614698, 1231024505)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetBlockHashesCmd(1231614698, 1231024505)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashes","params":[1231614698,1231024505],"id":1}`,
+			unmarshalled: &ulordjson.GetBlockHashesCmd{
+				High: 1231614698,
+				Low:  1231024505,
+			},
+		},
 		{
 			name: "getblockheader",
 			newCmd: func() (interface{}, error) {
@@ -235,6 +417,34 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: ulordjson.Bool(true),
 			},
 		},
+		{
+			name: "getblockstats",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getblockstats", "123")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetBlockStatsCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":[123],"id":1}`,
+			unmarshalled: &ulordjson.GetBlockStatsCmd{
+				HashOrHeight: "123",
+			},
+		},
+		{
+			name: "getblockstats optional - hash and stats",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getblockstats", "000000000000000000000000000000000000000000000000000000000000abcd", `["txs","totalfee"]`)
+			},
+			staticCmd: func() interface{} {
+				stats := []string{"txs", "totalfee"}
+				return ulordjson.NewGetBlockStatsCmd("000000000000000000000000000000000000000000000000000000000000abcd", &stats)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["000000000000000000000000000000000000000000000000000000000000abcd",["txs","totalfee"]],"id":1}`,
+			unmarshalled: &ulordjson.GetBlockStatsCmd{
+				HashOrHeight: "000000000000000000000000000000000000000000000000000000000000abcd",
+				Stats:        &[]string{"txs", "totalfee"},
+			},
+		},
 		{
 			name: "getblocktemplate",
 			newCmd: func() (interface{}, error) {
@@ -361,6 +571,45 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
 			unmarshalled: &ulordjson.GetChainTipsCmd{},
 		},
+		{
+			name: "getchaintxstats",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getchaintxstats")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetChainTxStatsCmd(nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getchaintxstats","params":[],"id":1}`,
+			unmarshalled: &ulordjson.GetChainTxStatsCmd{},
+		},
+		{
+			name: "getchaintxstats optional1",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getchaintxstats", 1000)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetChainTxStatsCmd(ulordjson.Int32(1000), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getchaintxstats","params":[1000],"id":1}`,
+			unmarshalled: &ulordjson.GetChainTxStatsCmd{
+				NBlocks: ulordjson.Int32(1000),
+			},
+		},
+		{
+			name: "getchaintxstats optional2",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getchaintxstats", 1000, "0000afaf")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetChainTxStatsCmd(ulordjson.Int32(1000),
+					ulordjson.String("0000afaf"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getchaintxstats","params":[1000,"0000afaf"],"id":1}`,
+			unmarshalled: &ulordjson.GetChainTxStatsCmd{
+				NBlocks:   ulordjson.Int32(1000),
+				BlockHash: ulordjson.String("0000afaf"),
+			},
+		},
 		{
 			name: "getconnectioncount",
 			newCmd: func() (interface{}, error) {
@@ -372,6 +621,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getconnectioncount","params":[],"id":1}`,
 			unmarshalled: &ulordjson.GetConnectionCountCmd{},
 		},
+		{
+			name: "getdescriptorinfo",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getdescriptorinfo", "raw(00)")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetDescriptorInfoCmd("raw(00)")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdescriptorinfo","params":["raw(00)"],"id":1}`,
+			unmarshalled: &ulordjson.GetDescriptorInfoCmd{Descriptor: "raw(00)"},
+		},
 		{
 			name: "getdifficulty",
 			newCmd: func() (interface{}, error) {
@@ -416,6 +676,75 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}`,
 			unmarshalled: &ulordjson.GetInfoCmd{},
 		},
+		{
+			name: "getmasternodecount",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getmasternodecount")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetMasternodeCountCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getmasternodecount","params":[],"id":1}`,
+			unmarshalled: &ulordjson.GetMasternodeCountCmd{},
+		},
+		{
+			name: "getmempoolancestors",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getmempoolancestors", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetMempoolAncestorsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash"],"id":1}`,
+			unmarshalled: &ulordjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: ulordjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempoolancestors optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getmempoolancestors", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetMempoolAncestorsCmd("txhash",
+					ulordjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash",true],"id":1}`,
+			unmarshalled: &ulordjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: ulordjson.Bool(true),
+			},
+		},
+		{
+			name: "getmempooldescendants",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getmempooldescendants", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetMempoolDescendantsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash"],"id":1}`,
+			unmarshalled: &ulordjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: ulordjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempooldescendants optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getmempooldescendants", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetMempoolDescendantsCmd("txhash",
+					ulordjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash",true],"id":1}`,
+			unmarshalled: &ulordjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: ulordjson.Bool(true),
+			},
+		},
 		{
 			name: "getmempoolentry",
 			newCmd: func() (interface{}, error) {
@@ -580,6 +909,22 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: ulordjson.Int(1),
 			},
 		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getspentinfo", `{"txid":"123","index":1}`)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetSpentInfoCmd(ulordjson.SpentInfoOutPoint{
+					Txid:  "123",
+					Index: 1,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":[{"txid":"123","index":1}],"id":1}`,
+			unmarshalled: &ulordjson.GetSpentInfoCmd{
+				OutPoint: ulordjson.SpentInfoOutPoint{Txid: "123", Index: 1},
+			},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -740,6 +1085,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "prioritisetransaction",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("prioritisetransaction", "txhash", 1000)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewPrioritiseTransactionCmd("txhash", 1000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"prioritisetransaction","params":["txhash",1000],"id":1}`,
+			unmarshalled: &ulordjson.PrioritiseTransactionCmd{
+				TxID:     "txhash",
+				FeeDelta: 1000,
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, error) {
@@ -753,6 +1112,58 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "reindex",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("reindex", 100)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewReindexCmd(100, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reindex","params":[100],"id":1}`,
+			unmarshalled: &ulordjson.ReindexCmd{
+				StartHeight: 100,
+				Indexes:     nil,
+			},
+		},
+		{
+			name: "reindex optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("reindex", 100, []string{"address index"})
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewReindexCmd(100, &[]string{"address index"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reindex","params":[100,["address index"]],"id":1}`,
+			unmarshalled: &ulordjson.ReindexCmd{
+				StartHeight: 100,
+				Indexes:     &[]string{"address index"},
+			},
+		},
+		{
+			name: "rpcuserinfo",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("rpcuserinfo")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewRPCUserInfoCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"rpcuserinfo","params":[],"id":1}`,
+			unmarshalled: &ulordjson.RPCUserInfoCmd{User: nil},
+		},
+		{
+			name: "rpcuserinfo optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("rpcuserinfo", "monitor")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewRPCUserInfoCmd(ulordjson.String("monitor"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rpcuserinfo","params":["monitor"],"id":1}`,
+			unmarshalled: &ulordjson.RPCUserInfoCmd{
+				User: ulordjson.String("monitor"),
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -948,6 +1359,20 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: ulordjson.Int(6),
 			},
 		},
+		{
+			name: "settrustedpeer",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("settrustedpeer", "192.168.1.0/24", "add")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewSetTrustedPeerCmd("192.168.1.0/24", ulordjson.STPAdd)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"settrustedpeer","params":["192.168.1.0/24","add"],"id":1}`,
+			unmarshalled: &ulordjson.SetTrustedPeerCmd{
+				Addr:   "192.168.1.0/24",
+				SubCmd: ulordjson.STPAdd,
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -992,6 +1417,19 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "testmempoolaccept",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("testmempoolaccept", []string{"1122"})
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewTestMempoolAcceptCmd([]string{"1122"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122"]],"id":1}`,
+			unmarshalled: &ulordjson.TestMempoolAcceptCmd{
+				RawTxns: []string{"1122"},
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
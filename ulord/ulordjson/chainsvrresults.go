@@ -24,6 +24,39 @@ type GetBlockHeaderVerboseResult struct {
 	NextHash      string  `json:"nextblockhash,omitempty"`
 }
 
+// GetBlockStatsResult models the data from the getblockstats command.  Fees
+// are in satoshi and fee rates in satoshi per virtual byte.
+type GetBlockStatsResult struct {
+	AverageFee         int64   `json:"avgfee"`
+	AverageFeeRate     int64   `json:"avgfeerate"`
+	AverageTxSize      int64   `json:"avgtxsize"`
+	Hash               string  `json:"blockhash"`
+	FeeRatePercentiles []int64 `json:"feerate_percentiles"`
+	Height             int32   `json:"height"`
+	Ins                int64   `json:"ins"`
+	MaxFee             int64   `json:"maxfee"`
+	MaxFeeRate         int64   `json:"maxfeerate"`
+	MaxTxSize          int64   `json:"maxtxsize"`
+	MedianFee          int64   `json:"medianfee"`
+	MedianTxSize       int64   `json:"mediantxsize"`
+	MinFee             int64   `json:"minfee"`
+	MinFeeRate         int64   `json:"minfeerate"`
+	MinTxSize          int64   `json:"mintxsize"`
+	Outs               int64   `json:"outs"`
+	Subsidy            int64   `json:"subsidy"`
+	SegWitTotalSize    int64   `json:"swtotal_size"`
+	SegWitTotalWeight  int64   `json:"swtotal_weight"`
+	SegWitTxs          int64   `json:"swtxs"`
+	Time               int64   `json:"time"`
+	TotalOut           int64   `json:"total_out"`
+	TotalSize          int64   `json:"total_size"`
+	TotalWeight        int64   `json:"total_weight"`
+	TotalFee           int64   `json:"totalfee"`
+	Txs                int64   `json:"txs"`
+	UtxoIncrease       int64   `json:"utxo_increase"`
+	UtxoSizeIncrease   int64   `json:"utxo_size_inc"`
+}
+
 // GetBlockVerboseResult models the data from the getblock command when the
 // verbose flag is set.  When the verbose flag is not set, getblock returns a
 // hex-encoded string.
@@ -63,6 +96,22 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh,omitempty"`
 }
 
+// BackupChainStateResult models the data returned from the backupchainstate
+// command.
+type BackupChainStateResult struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// DumpTxOutSetResult models the data returned from the dumptxoutset command.
+type DumpTxOutSetResult struct {
+	CoinsWritten uint64 `json:"coins_written"`
+	BaseHash     string `json:"base_hash"`
+	BaseHeight   int32  `json:"base_height"`
+	Path         string `json:"path"`
+	TxOutSetHash string `json:"txoutset_hash"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -77,6 +126,37 @@ type GetAddedNodeInfoResult struct {
 	Addresses *[]GetAddedNodeInfoResultAddr `json:"addresses,omitempty"`
 }
 
+// GetAddressBalanceResult models the data from the getaddressbalance command.
+// The amounts are in satoshi.
+type GetAddressBalanceResult struct {
+	Balance  int64 `json:"balance"`
+	Received int64 `json:"received"`
+}
+
+// GetAddressDeltasResult models the data from the getaddressdeltas command for
+// each output paying to the address and each input spending such an output.
+// The amount in satoshi is negative for inputs.
+type GetAddressDeltasResult struct {
+	Address    string `json:"address"`
+	TxID       string `json:"txid"`
+	Height     int32  `json:"height"`
+	BlockIndex uint32 `json:"blockindex"`
+	Index      uint32 `json:"index"`
+	Spending   bool   `json:"spending"`
+	Satoshis   int64  `json:"satoshis"`
+}
+
+// GetAddressUtxosResult models the data from the getaddressutxos command for
+// each unspent output paying to the address.
+type GetAddressUtxosResult struct {
+	Address     string `json:"address"`
+	TxID        string `json:"txid"`
+	OutputIndex uint32 `json:"outputIndex"`
+	Script      string `json:"script"`
+	Satoshis    int64  `json:"satoshis"`
+	Height      int32  `json:"height"`
+}
+
 // SoftForkDescription describes the current state of a soft-fork which was
 // deployed using a super-majority block signalling.
 type SoftForkDescription struct {
@@ -173,30 +253,146 @@ type GetBlockTemplateResult struct {
 	RejectReasion string   `json:"reject-reason,omitempty"`
 }
 
-// GetMempoolEntryResult models the data returned from the getmempoolentry
+// ChainTipStatus describes the state of the branch of the block tree ending at
+// a chain tip returned by the getchaintips command.
+type ChainTipStatus string
+
+const (
+	// ChainTipStatusActive is the status of the tip of the main chain.
+	ChainTipStatusActive ChainTipStatus = "active"
+
+	// ChainTipStatusValidFork is the status of a side chain whose blocks
+	// have been fully validated.
+	ChainTipStatusValidFork ChainTipStatus = "valid-fork"
+
+	// ChainTipStatusValidHeaders is the status of a side chain whose
+	// blocks are available but have not been fully validated.
+	ChainTipStatusValidHeaders ChainTipStatus = "valid-headers"
+
+	// ChainTipStatusHeadersOnly is the status of a side chain of which only
+	// the headers are known.
+	ChainTipStatusHeadersOnly ChainTipStatus = "headers-only"
+
+	// ChainTipStatusInvalid is the status of a side chain containing a
+	// block which failed validation.
+	ChainTipStatusInvalid ChainTipStatus = "invalid"
+)
+
+// GetChainTipsResult models the data returned from the getchaintips command.
+type GetChainTipsResult struct {
+	Height    int32          `json:"height"`
+	Hash      string         `json:"hash"`
+	BranchLen int32          `json:"branchlen"`
+	Status    ChainTipStatus `json:"status"`
+}
+
+// GetChainTxStatsResult models the data returned from the getchaintxstats
+// command.  The window fields other than the block count and final block hash
+// are omitted when the window is empty.
+type GetChainTxStatsResult struct {
+	Time                 int64   `json:"time"`
+	TxCount              int64   `json:"txcount"`
+	WindowFinalBlockHash string  `json:"window_final_block_hash"`
+	WindowBlockCount     int32   `json:"window_block_count"`
+	WindowTxCount        int64   `json:"window_tx_count,omitempty"`
+	WindowInterval       int64   `json:"window_interval,omitempty"`
+	TxRate               float64 `json:"txrate,omitempty"`
+}
+
+// GetDescriptorInfoResult models the data returned from the getdescriptorinfo
 // command.
+type GetDescriptorInfoResult struct {
+	Descriptor     string `json:"descriptor"`
+	Checksum       string `json:"checksum"`
+	IsRange        bool   `json:"isrange"`
+	IsSolvable     bool   `json:"issolvable"`
+	HasPrivateKeys bool   `json:"hasprivatekeys"`
+}
+
+// MasternodeProtocolCount models the number of masternodes running a protocol
+// version as part of the getmasternodecount command.
+type MasternodeProtocolCount struct {
+	ProtocolVersion uint32 `json:"protocolversion"`
+	Total           int32  `json:"total"`
+	Enabled         int32  `json:"enabled"`
+}
+
+// GetMasternodeCountResult models the data returned from the
+// getmasternodecount command.
+type GetMasternodeCountResult struct {
+	Total      int32                     `json:"total"`
+	Enabled    int32                     `json:"enabled"`
+	ByProtocol []MasternodeProtocolCount `json:"byprotocol"`
+}
+
+// MasternodeScoreResult models the rank and score of a masternode in the
+// quorum of a block.  Masternodes are ranked by decreasing score starting at
+// rank 1.
+type MasternodeScoreResult struct {
+	Rank            int32  `json:"rank"`
+	Score           string `json:"score"`
+	OutPoint        string `json:"outpoint"`
+	Address         string `json:"address"`
+	Payee           string `json:"payee"`
+	ProtocolVersion uint32 `json:"protocolversion"`
+	Enabled         bool   `json:"enabled"`
+}
+
+// MempoolFees models the fees object of the getmempoolentry,
+// getmempoolancestors, and getmempooldescendants results.  All fees are in
+// bitcoins.
+type MempoolFees struct {
+	Base       float64 `json:"base"`
+	Modified   float64 `json:"modified"`
+	Ancestor   float64 `json:"ancestor"`
+	Descendant float64 `json:"descendant"`
+}
+
+// GetMempoolEntryResult models the data returned from the getmempoolentry
+// command.  It is also the type of the values of the objects returned by the
+// getmempoolancestors and getmempooldescendants commands when the verbose flag
+// is set, which are keyed by transaction hash.  When the verbose flag is not
+// set, those commands return an array of transaction hashes.
 type GetMempoolEntryResult struct {
-	Size             int32    `json:"size"`
-	Fee              float64  `json:"fee"`
-	ModifiedFee      float64  `json:"modifiedfee"`
-	Time             int64    `json:"time"`
-	Height           int64    `json:"height"`
-	StartingPriority float64  `json:"startingpriority"`
-	CurrentPriority  float64  `json:"currentpriority"`
-	DescendantCount  int64    `json:"descendantcount"`
-	DescendantSize   int64    `json:"descendantsize"`
-	DescendantFees   float64  `json:"descendantfees"`
-	AncestorCount    int64    `json:"ancestorcount"`
-	AncestorSize     int64    `json:"ancestorsize"`
-	AncestorFees     float64  `json:"ancestorfees"`
-	Depends          []string `json:"depends"`
+	Size             int32       `json:"size"`
+	Vsize            int32       `json:"vsize"`
+	Fee              float64     `json:"fee"`
+	ModifiedFee      float64     `json:"modifiedfee"`
+	Time             int64       `json:"time"`
+	Height           int64       `json:"height"`
+	StartingPriority float64     `json:"startingpriority"`
+	CurrentPriority  float64     `json:"currentpriority"`
+	DescendantCount  int64       `json:"descendantcount"`
+	DescendantSize   int64       `json:"descendantsize"`
+	DescendantFees   float64     `json:"descendantfees"`
+	AncestorCount    int64       `json:"ancestorcount"`
+	AncestorSize     int64       `json:"ancestorsize"`
+	AncestorFees     float64     `json:"ancestorfees"`
+	Fees             MempoolFees `json:"fees"`
+	Depends          []string    `json:"depends"`
+	SpentBy          []string    `json:"spentby"`
+	InstantLock      bool        `json:"instantlock"`
+}
+
+// EstimateSmartFeeResult models the data returned from the estimatesmartfee
+// command.
+type EstimateSmartFeeResult struct {
+	FeeRate *float64 `json:"feerate,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Blocks  int64    `json:"blocks"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size             int64    `json:"size"`
+	Bytes            int64    `json:"bytes"`
+	MaxMempool       int64    `json:"maxmempool"`
+	MempoolMinFee    float64  `json:"mempoolminfee"`
+	MinRelayTxFee    float64  `json:"minrelaytxfee"`
+	UnbroadcastCount int64    `json:"unbroadcastcount"`
+	UnbroadcastTxs   []string `json:"unbroadcasttxs,omitempty"`
+	Loaded           bool     `json:"loaded"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -257,6 +453,24 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+
+	PingP50         float64           `json:"pingp50"`
+	PingP90         float64           `json:"pingp90"`
+	PingP99         float64           `json:"pingp99"`
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+
+	Misbehavior []PeerMisbehaviorResult `json:"misbehavior,omitempty"`
+}
+
+// PeerMisbehaviorResult models an offense committed by a peer as part of the
+// data returned by the getpeerinfo command.
+type PeerMisbehaviorResult struct {
+	Offense  string `json:"offense"`
+	Reason   string `json:"reason"`
+	Time     int64  `json:"time"`
+	BanScore int32  `json:"banscore"`
+	Action   string `json:"action"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -271,6 +485,17 @@ type GetRawMempoolVerboseResult struct {
 	StartingPriority float64  `json:"startingpriority"`
 	CurrentPriority  float64  `json:"currentpriority"`
 	Depends          []string `json:"depends"`
+	InstantLock      bool     `json:"instantlock"`
+}
+
+// RPCUserInfoResult models the data returned for each user from the
+// rpcuserinfo command.
+type RPCUserInfoResult struct {
+	User       string   `json:"user"`
+	Profile    string   `json:"profile"`
+	AllMethods bool     `json:"allmethods"`
+	Allowed    []string `json:"allowed,omitempty"`
+	Denied     []string `json:"denied,omitempty"`
 }
 
 // ScriptPubKeyResult models the scriptPubKey data of a tx script.  It is
@@ -283,6 +508,13 @@ type ScriptPubKeyResult struct {
 	Addresses []string `json:"addresses,omitempty"`
 }
 
+// GetSpentInfoResult models the data from the getspentinfo command.
+type GetSpentInfoResult struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height int32  `json:"height"`
+}
+
 // GetTxOutResult models the data from the gettxout command.
 type GetTxOutResult struct {
 	BestBlock     string             `json:"bestblock"`
@@ -292,11 +524,27 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
+type GetTxOutSetInfoResult struct {
+	Height         int64   `json:"height"`
+	BestBlock      string  `json:"bestblock"`
+	Transactions   int64   `json:"transactions"`
+	TxOuts         int64   `json:"txouts"`
+	BogoSize       int64   `json:"bogosize"`
+	HashSerialized string  `json:"hash_serialized_2"`
+	DiskSize       int64   `json:"disk_size"`
+	TotalAmount    float64 `json:"total_amount"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv  uint64            `json:"totalbytesrecv"`
+	TotalBytesSent  uint64            `json:"totalbytessent"`
+	TimeMillis      int64             `json:"timemillis"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	UploadLimit     int64             `json:"uploadlimit"`
+	DownloadLimit   int64             `json:"downloadlimit"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -533,6 +781,16 @@ type SearchRawTransactionsResult struct {
 	Blocktime     int64        `json:"blocktime,omitempty"`
 }
 
+// TestMempoolAcceptResult models the data returned from the testmempoolaccept
+// command for each transaction.
+type TestMempoolAcceptResult struct {
+	Txid         string  `json:"txid"`
+	Allowed      bool    `json:"allowed"`
+	RejectReason string  `json:"reject-reason,omitempty"`
+	Vsize        int64   `json:"vsize,omitempty"`
+	Fee          float64 `json:"fee,omitempty"`
+}
+
 // TxRawDecodeResult models the data from the decoderawtransaction command.
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
@@ -68,6 +68,93 @@ func TestChainSvrCustomResults(t *testing.T) {
 			},
 			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"prevOut":{"addresses":["addr1"],"value":0},"sequence":4294967295}`,
 		},
+		{
+			name: "getchaintips result",
+			result: &ulordjson.GetChainTipsResult{
+				Height:    4,
+				Hash:      "123",
+				BranchLen: 2,
+				Status:    ulordjson.ChainTipStatusValidFork,
+			},
+			expected: `{"height":4,"hash":"123","branchlen":2,"status":"valid-fork"}`,
+		},
+		{
+			name: "getmasternodecount result",
+			result: &ulordjson.GetMasternodeCountResult{
+				Total:   3,
+				Enabled: 2,
+				ByProtocol: []ulordjson.MasternodeProtocolCount{
+					{ProtocolVersion: 70208, Total: 3, Enabled: 2},
+				},
+			},
+			expected: `{"total":3,"enabled":2,"byprotocol":[{"protocolversion":70208,"total":3,"enabled":2}]}`,
+		},
+		{
+			name: "masternode score result",
+			result: &ulordjson.MasternodeScoreResult{
+				Rank:            1,
+				Score:           "00ff",
+				OutPoint:        "123:0",
+				Address:         "127.0.0.1:9888",
+				Payee:           "addr1",
+				ProtocolVersion: 70208,
+				Enabled:         true,
+			},
+			expected: `{"rank":1,"score":"00ff","outpoint":"123:0","address":"127.0.0.1:9888","payee":"addr1","protocolversion":70208,"enabled":true}`,
+		},
+		{
+			name: "getmempoolinfo result",
+			result: &ulordjson.GetMempoolInfoResult{
+				Size:             157,
+				Bytes:            310768,
+				MaxMempool:       300000000,
+				MempoolMinFee:    0.00001,
+				MinRelayTxFee:    0.00001,
+				UnbroadcastCount: 2,
+				Loaded:           true,
+			},
+			expected: `{"size":157,"bytes":310768,"maxmempool":300000000,"mempoolminfee":0.00001,"minrelaytxfee":0.00001,"unbroadcastcount":2,"loaded":true}`,
+		},
+		{
+			name: "getmempoolinfo result with unbroadcast txs",
+			result: &ulordjson.GetMempoolInfoResult{
+				Size:             1,
+				Bytes:            225,
+				MaxMempool:       300000000,
+				MempoolMinFee:    0.00001,
+				MinRelayTxFee:    0.00001,
+				UnbroadcastCount: 1,
+				UnbroadcastTxs:   []string{"123"},
+				Loaded:           true,
+			},
+			expected: `{"size":1,"bytes":225,"maxmempool":300000000,"mempoolminfee":0.00001,"minrelaytxfee":0.00001,"unbroadcastcount":1,"unbroadcasttxs":["123"],"loaded":true}`,
+		},
+		{
+			name: "getmempoolentry result",
+			result: &ulordjson.GetMempoolEntryResult{
+				Size:            225,
+				Vsize:           225,
+				Fee:             0.0001,
+				ModifiedFee:     0.0002,
+				Time:            1538000000,
+				Height:          100,
+				DescendantCount: 2,
+				DescendantSize:  450,
+				DescendantFees:  0.0003,
+				AncestorCount:   1,
+				AncestorSize:    225,
+				AncestorFees:    0.0002,
+				Fees: ulordjson.MempoolFees{
+					Base:       0.0001,
+					Modified:   0.0002,
+					Ancestor:   0.0002,
+					Descendant: 0.0003,
+				},
+				Depends: []string{},
+				SpentBy: []string{"txhash"},
+			},
+			expected: `{"size":225,"vsize":225,"fee":0.0001,"modifiedfee":0.0002,"time":1538000000,"height":100,"startingpriority":0,"currentpriority":0,"descendantcount":2,"descendantsize":450,"descendantfees":0.0003,"ancestorcount":1,"ancestorsize":225,"ancestorfees":0.0002,"fees":{"base":0.0001,"modified":0.0002,"ancestor":0.0002,"descendant":0.0003},"depends":[],"spentby":["txhash"],"instantlock":false}`,
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -103,7 +103,9 @@ type OutPoint struct {
 }
 
 // LoadTxFilterCmd defines the loadtxfilter request parameters to load or
-// reload a transaction filter.
+// reload a transaction filter.  Scripts are hex-encoded output scripts which
+// are matched exactly, and outputs worth less than MinAmount, in ULD, do not
+// match the filter.
 //
 // NOTE: This is a ulord extension ported from github.com/decred/dcrd/dcrjson
 // and requires a websocket connection.
@@ -111,18 +113,27 @@ type LoadTxFilterCmd struct {
 	Reload    bool
 	Addresses []string
 	OutPoints []OutPoint
+	Scripts   *[]string
+	MinAmount *float64
 }
 
 // NewLoadTxFilterCmd returns a new instance which can be used to issue a
 // loadtxfilter JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: This is a ulord extension ported from github.com/decred/dcrd/dcrjson
 // and requires a websocket connection.
-func NewLoadTxFilterCmd(reload bool, addresses []string, outPoints []OutPoint) *LoadTxFilterCmd {
+func NewLoadTxFilterCmd(reload bool, addresses []string, outPoints []OutPoint,
+	scripts *[]string, minAmount *float64) *LoadTxFilterCmd {
+
 	return &LoadTxFilterCmd{
 		Reload:    reload,
 		Addresses: addresses,
 		OutPoints: outPoints,
+		Scripts:   scripts,
+		MinAmount: minAmount,
 	}
 }
 
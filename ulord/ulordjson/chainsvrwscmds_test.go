@@ -204,7 +204,7 @@ func TestChainSvrWsCmds(t *testing.T) {
 					Hash:  "0000000000000000000000000000000000000000000000000000000000000123",
 					Index: 0,
 				}}
-				return ulordjson.NewLoadTxFilterCmd(false, addrs, ops)
+				return ulordjson.NewLoadTxFilterCmd(false, addrs, ops, nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"loadtxfilter","params":[false,["1Address"],[{"hash":"0000000000000000000000000000000000000000000000000000000000000123","index":0}]],"id":1}`,
 			unmarshalled: &ulordjson.LoadTxFilterCmd{
@@ -213,6 +213,25 @@ func TestChainSvrWsCmds(t *testing.T) {
 				OutPoints: []ulordjson.OutPoint{{Hash: "0000000000000000000000000000000000000000000000000000000000000123", Index: 0}},
 			},
 		},
+		{
+			name: "loadtxfilter optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("loadtxfilter", true, `[]`, `[]`, `["76a914"]`, 0.5)
+			},
+			staticCmd: func() interface{} {
+				scripts := []string{"76a914"}
+				return ulordjson.NewLoadTxFilterCmd(true, []string{},
+					[]ulordjson.OutPoint{}, &scripts, ulordjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"loadtxfilter","params":[true,[],[],["76a914"],0.5],"id":1}`,
+			unmarshalled: &ulordjson.LoadTxFilterCmd{
+				Reload:    true,
+				Addresses: []string{},
+				OutPoints: []ulordjson.OutPoint{},
+				Scripts:   &[]string{"76a914"},
+				MinAmount: ulordjson.Float64(0.5),
+			},
+		},
 		{
 			name: "rescanblocks",
 			newCmd: func() (interface{}, error) {
@@ -66,11 +66,16 @@ func IsValidIDType(id interface{}) bool {
 // statically typed command infrastructure which handles creation of these
 // requests, however this struct it being exported in case the caller wants to
 // construct raw requests for some reason.
+//
+// The Wallet field names the wallet a request is routed to when it is sent to a
+// server with multiple loaded wallets over a websocket connection, since there
+// is no URL path to select it.  It is omitted when empty.
 type Request struct {
 	Jsonrpc string            `json:"jsonrpc"`
 	Method  string            `json:"method"`
 	Params  []json.RawMessage `json:"params"`
 	ID      interface{}       `json:"id"`
+	Wallet  string            `json:"wallet,omitempty"`
 }
 
 // NewRequest returns a new JSON-RPC 1.0 request object given the provided id,
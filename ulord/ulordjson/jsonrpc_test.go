@@ -98,6 +98,51 @@ func TestMarshalResponse(t *testing.T) {
 	}
 }
 
+// TestMarshalRequestWallet ensures the wallet of a request is only marshalled
+// when it is set.
+func TestMarshalRequestWallet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		wallet   string
+		expected string
+	}{
+		{
+			name:     "default wallet",
+			wallet:   "",
+			expected: `{"jsonrpc":"1.0","method":"getbalance","params":[],"id":1}`,
+		},
+		{
+			name:     "named wallet",
+			wallet:   "w1",
+			expected: `{"jsonrpc":"1.0","method":"getbalance","params":[],"id":1,"wallet":"w1"}`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		request, err := ulordjson.NewRequest(1, "getbalance", nil)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		request.Wallet = test.wallet
+		marshalled, err := json.Marshal(request)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		if string(marshalled) != test.expected {
+			t.Errorf("Test #%d (%s) mismatched result - got %s, "+
+				"want %s", i, test.name, marshalled,
+				test.expected)
+		}
+	}
+}
+
 // TestMiscErrors tests a few error conditions not covered elsewhere.
 func TestMiscErrors(t *testing.T) {
 	t.Parallel()
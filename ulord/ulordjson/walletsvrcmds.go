@@ -7,6 +7,11 @@
 
 package ulordjson
 
+import (
+	"encoding/json"
+	"errors"
+)
+
 // AddMultisigAddressCmd defines the addmutisigaddress JSON-RPC command.
 type AddMultisigAddressCmd struct {
 	NRequired int
@@ -250,6 +255,15 @@ func NewGetTransactionCmd(txHash string, includeWatchOnly *bool) *GetTransaction
 	}
 }
 
+// GetWalletLockStateCmd defines the getwalletlockstate JSON-RPC command.
+type GetWalletLockStateCmd struct{}
+
+// NewGetWalletLockStateCmd returns a new instance which can be used to issue a
+// getwalletlockstate JSON-RPC command.
+func NewGetWalletLockStateCmd() *GetWalletLockStateCmd {
+	return &GetWalletLockStateCmd{}
+}
+
 // GetWalletInfoCmd defines the getwalletinfo JSON-RPC command.
 type GetWalletInfoCmd struct{}
 
@@ -259,6 +273,122 @@ func NewGetWalletInfoCmd() *GetWalletInfoCmd {
 	return &GetWalletInfoCmd{}
 }
 
+// ImportMultiScriptPubKey is the output script an importmulti request imports.
+// It is marshalled as the hex-encoded Script when Address is empty and as a
+// JSON object naming the address otherwise.
+type ImportMultiScriptPubKey struct {
+	Script  string
+	Address string
+}
+
+// MarshalJSON provides a custom Marshal method for ImportMultiScriptPubKey.
+func (s ImportMultiScriptPubKey) MarshalJSON() ([]byte, error) {
+	if s.Address != "" {
+		return json.Marshal(struct {
+			Address string `json:"address"`
+		}{s.Address})
+	}
+	return json.Marshal(s.Script)
+}
+
+// UnmarshalJSON provides a custom Unmarshal method for ImportMultiScriptPubKey.
+func (s *ImportMultiScriptPubKey) UnmarshalJSON(data []byte) error {
+	var script string
+	if err := json.Unmarshal(data, &script); err == nil {
+		*s = ImportMultiScriptPubKey{Script: script}
+		return nil
+	}
+
+	var addr struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(data, &addr); err != nil {
+		return err
+	}
+	if addr.Address == "" {
+		return errors.New("scriptPubKey must be a hex-encoded script " +
+			"or an object with an address")
+	}
+	*s = ImportMultiScriptPubKey{Address: addr.Address}
+	return nil
+}
+
+// ImportMultiTimestamp is the creation time of the keys of an importmulti
+// request, which bounds how far back the wallet rescans the chain for them.  It
+// is marshalled as the JSON string "now" when Now is set and as the Time in
+// seconds since the epoch otherwise.
+type ImportMultiTimestamp struct {
+	Now  bool
+	Time int64
+}
+
+// MarshalJSON provides a custom Marshal method for ImportMultiTimestamp.
+func (t ImportMultiTimestamp) MarshalJSON() ([]byte, error) {
+	if t.Now {
+		return json.Marshal("now")
+	}
+	return json.Marshal(t.Time)
+}
+
+// UnmarshalJSON provides a custom Unmarshal method for ImportMultiTimestamp.
+func (t *ImportMultiTimestamp) UnmarshalJSON(data []byte) error {
+	var now string
+	if err := json.Unmarshal(data, &now); err == nil {
+		if now != "now" {
+			return errors.New(`timestamp must be a number or "now"`)
+		}
+		*t = ImportMultiTimestamp{Now: true}
+		return nil
+	}
+
+	var time int64
+	if err := json.Unmarshal(data, &time); err != nil {
+		return err
+	}
+	*t = ImportMultiTimestamp{Time: time}
+	return nil
+}
+
+// ImportMultiRequest describes the keys and scripts one entry of an
+// importmulti command imports.  Either Descriptor or ScriptPubKey must be set.
+type ImportMultiRequest struct {
+	Descriptor    *string                  `json:"desc,omitempty"`
+	ScriptPubKey  *ImportMultiScriptPubKey `json:"scriptPubKey,omitempty"`
+	Timestamp     ImportMultiTimestamp     `json:"timestamp"`
+	RedeemScript  *string                  `json:"redeemscript,omitempty"`
+	WitnessScript *string                  `json:"witnessscript,omitempty"`
+	PubKeys       []string                 `json:"pubkeys,omitempty"`
+	Keys          []string                 `json:"keys,omitempty"`
+	Range         *DescriptorRange         `json:"range,omitempty"`
+	Internal      *bool                    `json:"internal,omitempty"`
+	WatchOnly     *bool                    `json:"watchonly,omitempty"`
+	Label         *string                  `json:"label,omitempty"`
+	KeyPool       *bool                    `json:"keypool,omitempty"`
+}
+
+// ImportMultiOptions are the options of the importmulti command.
+type ImportMultiOptions struct {
+	Rescan bool `json:"rescan"`
+}
+
+// ImportMultiCmd defines the importmulti JSON-RPC command.
+type ImportMultiCmd struct {
+	Requests []ImportMultiRequest
+	Options  *ImportMultiOptions `jsonrpcdefault:"{\"rescan\":true}"`
+}
+
+// NewImportMultiCmd returns a new instance which can be used to issue an
+// importmulti JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewImportMultiCmd(requests []ImportMultiRequest, options *ImportMultiOptions) *ImportMultiCmd {
+	return &ImportMultiCmd{
+		Requests: requests,
+		Options:  options,
+	}
+}
+
 // ImportPrivKeyCmd defines the importprivkey JSON-RPC command.
 type ImportPrivKeyCmd struct {
 	PrivKey string
@@ -625,16 +755,22 @@ func NewWalletLockCmd() *WalletLockCmd {
 
 // WalletPassphraseCmd defines the walletpassphrase JSON-RPC command.
 type WalletPassphraseCmd struct {
-	Passphrase string
-	Timeout    int64
+	Passphrase  string
+	Timeout     int64
+	StakingOnly *bool `jsonrpcdefault:"false"`
 }
 
 // NewWalletPassphraseCmd returns a new instance which can be used to issue a
-// walletpassphrase JSON-RPC command.
-func NewWalletPassphraseCmd(passphrase string, timeout int64) *WalletPassphraseCmd {
+// walletpassphrase JSON-RPC command.  When stakingOnly is true the unlocked
+// wallet may only be used for staking and not to send funds.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewWalletPassphraseCmd(passphrase string, timeout int64, stakingOnly *bool) *WalletPassphraseCmd {
 	return &WalletPassphraseCmd{
-		Passphrase: passphrase,
-		Timeout:    timeout,
+		Passphrase:  passphrase,
+		Timeout:     timeout,
+		StakingOnly: stakingOnly,
 	}
 }
 
@@ -674,6 +810,8 @@ func init() {
 	MustRegisterCmd("getreceivedbyaddress", (*GetReceivedByAddressCmd)(nil), flags)
 	MustRegisterCmd("gettransaction", (*GetTransactionCmd)(nil), flags)
 	MustRegisterCmd("getwalletinfo", (*GetWalletInfoCmd)(nil), flags)
+	MustRegisterCmd("getwalletlockstate", (*GetWalletLockStateCmd)(nil), flags)
+	MustRegisterCmd("importmulti", (*ImportMultiCmd)(nil), flags)
 	MustRegisterCmd("importprivkey", (*ImportPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("keypoolrefill", (*KeyPoolRefillCmd)(nil), flags)
 	MustRegisterCmd("listaccounts", (*ListAccountsCmd)(nil), flags)
@@ -369,6 +369,69 @@ func TestWalletSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getwalletinfo","params":[],"id":1}`,
 			unmarshalled: &ulordjson.GetWalletInfoCmd{},
 		},
+		{
+			name: "getwalletlockstate",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("getwalletlockstate")
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewGetWalletLockStateCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getwalletlockstate","params":[],"id":1}`,
+			unmarshalled: &ulordjson.GetWalletLockStateCmd{},
+		},
+		{
+			name: "importmulti",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("importmulti",
+					`[{"scriptPubKey":"76a914","timestamp":1538000000}]`)
+			},
+			staticCmd: func() interface{} {
+				requests := []ulordjson.ImportMultiRequest{{
+					ScriptPubKey: &ulordjson.ImportMultiScriptPubKey{Script: "76a914"},
+					Timestamp:    ulordjson.ImportMultiTimestamp{Time: 1538000000},
+				}}
+				return ulordjson.NewImportMultiCmd(requests, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importmulti","params":[[{"scriptPubKey":"76a914","timestamp":1538000000}]],"id":1}`,
+			unmarshalled: &ulordjson.ImportMultiCmd{
+				Requests: []ulordjson.ImportMultiRequest{{
+					ScriptPubKey: &ulordjson.ImportMultiScriptPubKey{Script: "76a914"},
+					Timestamp:    ulordjson.ImportMultiTimestamp{Time: 1538000000},
+				}},
+				Options: &ulordjson.ImportMultiOptions{Rescan: true},
+			},
+		},
+		{
+			name: "importmulti optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("importmulti",
+					`[{"scriptPubKey":{"address":"1Addr"},"timestamp":"now","keys":["key"],"label":"l","watchonly":false}]`,
+					`{"rescan":false}`)
+			},
+			staticCmd: func() interface{} {
+				requests := []ulordjson.ImportMultiRequest{{
+					ScriptPubKey: &ulordjson.ImportMultiScriptPubKey{Address: "1Addr"},
+					Timestamp:    ulordjson.ImportMultiTimestamp{Now: true},
+					Keys:         []string{"key"},
+					WatchOnly:    ulordjson.Bool(false),
+					Label:        ulordjson.String("l"),
+				}}
+				options := &ulordjson.ImportMultiOptions{Rescan: false}
+				return ulordjson.NewImportMultiCmd(requests, options)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importmulti","params":[[{"scriptPubKey":{"address":"1Addr"},"timestamp":"now","keys":["key"],"watchonly":false,"label":"l"}],{"rescan":false}],"id":1}`,
+			unmarshalled: &ulordjson.ImportMultiCmd{
+				Requests: []ulordjson.ImportMultiRequest{{
+					ScriptPubKey: &ulordjson.ImportMultiScriptPubKey{Address: "1Addr"},
+					Timestamp:    ulordjson.ImportMultiTimestamp{Now: true},
+					Keys:         []string{"key"},
+					WatchOnly:    ulordjson.Bool(false),
+					Label:        ulordjson.String("l"),
+				}},
+				Options: &ulordjson.ImportMultiOptions{Rescan: false},
+			},
+		},
 		{
 			name: "importprivkey",
 			newCmd: func() (interface{}, error) {
@@ -1182,12 +1245,29 @@ func TestWalletSvrCmds(t *testing.T) {
 				return ulordjson.NewCmd("walletpassphrase", "pass", 60)
 			},
 			staticCmd: func() interface{} {
-				return ulordjson.NewWalletPassphraseCmd("pass", 60)
+				return ulordjson.NewWalletPassphraseCmd("pass", 60, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"walletpassphrase","params":["pass",60],"id":1}`,
 			unmarshalled: &ulordjson.WalletPassphraseCmd{
-				Passphrase: "pass",
-				Timeout:    60,
+				Passphrase:  "pass",
+				Timeout:     60,
+				StakingOnly: ulordjson.Bool(false),
+			},
+		},
+		{
+			name: "walletpassphrase optional",
+			newCmd: func() (interface{}, error) {
+				return ulordjson.NewCmd("walletpassphrase", "pass", 60, true)
+			},
+			staticCmd: func() interface{} {
+				return ulordjson.NewWalletPassphraseCmd("pass", 60,
+					ulordjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"walletpassphrase","params":["pass",60,true],"id":1}`,
+			unmarshalled: &ulordjson.WalletPassphraseCmd{
+				Passphrase:  "pass",
+				Timeout:     60,
+				StakingOnly: ulordjson.Bool(true),
 			},
 		},
 		{
@@ -56,6 +56,23 @@ type InfoWalletResult struct {
 	Errors          string  `json:"errors"`
 }
 
+// GetWalletLockStateResult models the data from the getwalletlockstate
+// command.
+type GetWalletLockStateResult struct {
+	Encrypted     bool  `json:"encrypted"`
+	Locked        bool  `json:"locked"`
+	UnlockedUntil int64 `json:"unlocked_until"`
+	StakingOnly   bool  `json:"staking_only"`
+}
+
+// ImportMultiResult models the result of one request of the importmulti
+// command.
+type ImportMultiResult struct {
+	Success  bool      `json:"success"`
+	Warnings []string  `json:"warnings,omitempty"`
+	Error    *RPCError `json:"error,omitempty"`
+}
+
 // ListTransactionsResult models the data from the listtransactions command.
 type ListTransactionsResult struct {
 	Abandoned         bool     `json:"abandoned"`
@@ -596,11 +596,24 @@ func BenchmarkDecodeMerkleBlock(b *testing.B) {
 // BenchmarkTxHash performs a benchmark on how long it takes to hash a
 // transaction.
 func BenchmarkTxHash(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		genesisCoinbaseTx.TxHash()
 	}
 }
 
+// BenchmarkSerializeNoWitnessInto performs a benchmark on how long it takes to
+// serialize a transaction into a reused buffer.
+func BenchmarkSerializeNoWitnessInto(b *testing.B) {
+	tx := blockOne.Transactions[0]
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = tx.SerializeNoWitnessInto(buf)
+	}
+}
+
 // BenchmarkDoubleHashB performs a benchmark on how long it takes to perform a
 // double hash returning a byte slice.
 func BenchmarkDoubleHashB(b *testing.B) {
@@ -0,0 +1,157 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// BlockDecoder decodes a serialized block from a reader one transaction at a
+// time.  Unlike MsgBlock.BtcDecode, which holds every transaction of the block
+// in memory at once, only the transaction most recently returned by Next is
+// referenced by the decoder, so arbitrarily large blocks can be processed with
+// memory bounded by the size of their largest transaction.
+//
+// The decoder does not verify that the reader is exhausted once all
+// transactions have been returned.
+type BlockDecoder struct {
+	r       io.Reader
+	pver    uint32
+	enc     MessageEncoding
+	header  BlockHeader
+	txCount uint64
+	next    uint64
+	err     error
+}
+
+// NewBlockDecoder reads the block header and transaction count from r and
+// returns a decoder which yields the transactions of the block using the given
+// protocol version and message encoding.  See NewBlockDecoderStorage for
+// decoding blocks stored to disk, such as in a database.
+func NewBlockDecoder(r io.Reader, pver uint32, enc MessageEncoding) (*BlockDecoder, error) {
+	d := BlockDecoder{r: r, pver: pver, enc: enc}
+	err := readBlockHeader(r, pver, &d.header)
+	if err != nil {
+		return nil, err
+	}
+
+	d.txCount, err = ReadVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prevent more transactions than could possibly fit into a block.
+	// Although the transactions are not allocated up front, callers
+	// commonly size their own structures by the count.
+	if d.txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", d.txCount, maxTxPerBlock)
+		return nil, messageErrorCode(ErrMsgTooManyItems,
+			"NewBlockDecoder", str)
+	}
+	err = checkItemCount(r, d.txCount, minTxPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// NewBlockDecoderStorage returns a decoder for a block serialized in the
+// long-term storage format used by MsgBlock.Serialize.
+func NewBlockDecoderStorage(r io.Reader) (*BlockDecoder, error) {
+	return NewBlockDecoder(r, 0, WitnessEncoding)
+}
+
+// Header returns the header of the block being decoded.
+func (d *BlockDecoder) Header() *BlockHeader {
+	return &d.header
+}
+
+// TxCount returns the total number of transactions in the block.
+func (d *BlockDecoder) TxCount() uint64 {
+	return d.txCount
+}
+
+// Remaining returns the number of transactions which have not been decoded
+// yet.
+func (d *BlockDecoder) Remaining() uint64 {
+	return d.txCount - d.next
+}
+
+// Next decodes and returns the next transaction of the block.  It returns
+// io.EOF once all transactions have been returned.  Any error encountered
+// while decoding a transaction is sticky and returned by all subsequent
+// calls.
+func (d *BlockDecoder) Next() (*MsgTx, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.next >= d.txCount {
+		return nil, io.EOF
+	}
+
+	tx := new(MsgTx)
+	if err := tx.BtcDecode(d.r, d.pver, d.enc); err != nil {
+		// A transaction cut short is an unexpected end of the block
+		// rather than its regular end.
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil, err
+	}
+	d.next++
+
+	return tx, nil
+}
+
+// StreamEncode encodes a block with the passed header and number of
+// transactions to w using the given protocol version and message encoding,
+// calling nextTx to obtain each transaction in order as it is needed.  It
+// produces the same encoding as MsgBlock.BtcEncode without requiring all of
+// the transactions to be held in memory at once.
+//
+// An error is returned if nextTx returns an error or a nil transaction before
+// txCount transactions have been encoded.  nextTx is not called again after
+// the final transaction.
+func StreamEncode(w io.Writer, pver uint32, enc MessageEncoding,
+	header *BlockHeader, txCount uint64, nextTx func() (*MsgTx, error)) error {
+
+	if txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", txCount, maxTxPerBlock)
+		return messageErrorCode(ErrMsgTooManyItems, "StreamEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, header)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, txCount)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < txCount; i++ {
+		tx, err := nextTx()
+		if err != nil {
+			return err
+		}
+		if tx == nil {
+			str := fmt.Sprintf("missing transaction %d of %d", i,
+				txCount)
+			return messageError("StreamEncode", str)
+		}
+		err = tx.BtcEncode(w, pver, enc)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockDecoder tests that streaming decoding of a block yields the same
+// header and transactions as decoding the whole block.
+func TestBlockDecoder(t *testing.T) {
+	d, err := NewBlockDecoderStorage(bytes.NewReader(blockOneBytes))
+	if err != nil {
+		t.Fatalf("NewBlockDecoderStorage: %v", err)
+	}
+	if !reflect.DeepEqual(d.Header(), &blockOne.Header) {
+		t.Errorf("Header: mismatched header - got %v, want %v",
+			spew.Sdump(d.Header()), spew.Sdump(&blockOne.Header))
+	}
+	if d.TxCount() != uint64(len(blockOne.Transactions)) {
+		t.Errorf("TxCount: got %d, want %d", d.TxCount(),
+			len(blockOne.Transactions))
+	}
+
+	for i, want := range blockOne.Transactions {
+		tx, err := d.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(tx, want) {
+			t.Errorf("Next #%d: mismatched tx - got %v, want %v", i,
+				spew.Sdump(tx), spew.Sdump(want))
+		}
+	}
+	if d.Remaining() != 0 {
+		t.Errorf("Remaining: got %d, want 0", d.Remaining())
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("Next: wrong error - got %v, want %v", err, io.EOF)
+	}
+}
+
+// TestBlockDecoderErrors performs negative tests against streaming decoding
+// of a block to ensure truncated and malformed blocks are rejected.
+func TestBlockDecoderErrors(t *testing.T) {
+	// Truncations within the header and transaction count are reported by
+	// the constructor.
+	for _, max := range []int{0, 4, 36, 68, 72, 76, 80} {
+		_, err := NewBlockDecoderStorage(bytes.NewReader(blockOneBytes[:max]))
+		if err != io.EOF {
+			t.Errorf("NewBlockDecoderStorage(%d bytes): wrong error - "+
+				"got %v, want %v", max, err, io.EOF)
+		}
+	}
+
+	// Truncations within a transaction are reported by Next and are
+	// sticky.
+	d, err := NewBlockDecoderStorage(bytes.NewReader(blockOneBytes[:100]))
+	if err != nil {
+		t.Fatalf("NewBlockDecoderStorage: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := d.Next(); err != io.ErrUnexpectedEOF {
+			t.Errorf("Next #%d: wrong error - got %v, want %v", i, err,
+				io.ErrUnexpectedEOF)
+		}
+	}
+
+	// Transaction counts larger than could possibly fit into a block are
+	// rejected.
+	overflow := append([]byte{}, blockOneBytes[:80]...)
+	overflow = append(overflow, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff)
+	_, err = NewBlockDecoderStorage(bytes.NewReader(overflow))
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("NewBlockDecoderStorage: wrong error - got %v <%T>, "+
+			"want <%T>", err, err, &MessageError{})
+	}
+}
+
+// TestStreamEncode tests that streaming encoding of a block produces the same
+// bytes as encoding the whole block.
+func TestStreamEncode(t *testing.T) {
+	txns := blockOne.Transactions
+	nextTx := func() (*MsgTx, error) {
+		tx := txns[0]
+		txns = txns[1:]
+		return tx, nil
+	}
+
+	var buf bytes.Buffer
+	err := StreamEncode(&buf, 0, WitnessEncoding, &blockOne.Header,
+		uint64(len(txns)), nextTx)
+	if err != nil {
+		t.Fatalf("StreamEncode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), blockOneBytes) {
+		t.Errorf("StreamEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(blockOneBytes))
+	}
+
+	// A missing transaction must be reported.
+	noTx := func() (*MsgTx, error) { return nil, nil }
+	err = StreamEncode(&buf, 0, WitnessEncoding, &blockOne.Header, 1, noTx)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("StreamEncode: wrong error - got %v <%T>, want <%T>",
+			err, err, &MessageError{})
+	}
+
+	// Errors from the transaction source and the writer are returned.
+	failTx := func() (*MsgTx, error) { return nil, io.ErrClosedPipe }
+	err = StreamEncode(&buf, 0, WitnessEncoding, &blockOne.Header, 1,
+		failTx)
+	if err != io.ErrClosedPipe {
+		t.Errorf("StreamEncode: wrong error - got %v, want %v", err,
+			io.ErrClosedPipe)
+	}
+	txns = blockOne.Transactions
+	w := newFixedWriter(81)
+	err = StreamEncode(w, 0, WitnessEncoding, &blockOne.Header,
+		uint64(len(txns)), nextTx)
+	if err != io.ErrShortWrite {
+		t.Errorf("StreamEncode: wrong error - got %v, want %v", err,
+			io.ErrShortWrite)
+	}
+}
@@ -492,8 +492,10 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0x100000000)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			str := fmt.Sprintf(errNonCanonicalVarInt, rv,
+				discriminant, min)
+			return 0, messageErrorCode(ErrMsgNonCanonicalVarInt,
+				"ReadVarInt", str)
 		}
 
 	case 0xfe:
@@ -507,8 +509,10 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0x10000)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			str := fmt.Sprintf(errNonCanonicalVarInt, rv,
+				discriminant, min)
+			return 0, messageErrorCode(ErrMsgNonCanonicalVarInt,
+				"ReadVarInt", str)
 		}
 
 	case 0xfd:
@@ -522,8 +526,10 @@ func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
 		// encoded using fewer bytes.
 		min := uint64(0xfd)
 		if rv < min {
-			return 0, messageError("ReadVarInt", fmt.Sprintf(
-				errNonCanonicalVarInt, rv, discriminant, min))
+			str := fmt.Sprintf(errNonCanonicalVarInt, rv,
+				discriminant, min)
+			return 0, messageErrorCode(ErrMsgNonCanonicalVarInt,
+				"ReadVarInt", str)
 		}
 
 	default:
@@ -586,6 +592,40 @@ func VarIntSerializeSize(val uint64) int {
 	return 9
 }
 
+// lenReader is implemented by readers which know the number of unread bytes
+// they hold, such as bytes.Buffer and bytes.Reader.  Messages are always
+// decoded from such a reader once their payload has been read.
+type lenReader interface {
+	Len() int
+}
+
+// checkRemaining returns an error when r is known to hold fewer than n bytes.
+// It is called before allocating memory for a field whose length was read
+// from the wire so a small malformed message can not force a large allocation
+// for data it does not contain.  The error is the same one io.ReadFull would
+// return once it ran out of data so the outcome does not depend on the type of
+// the reader.
+func checkRemaining(r io.Reader, n uint64) error {
+	lr, ok := r.(lenReader)
+	if !ok || n <= uint64(lr.Len()) {
+		return nil
+	}
+	if lr.Len() == 0 {
+		return io.EOF
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// checkItemCount returns an error when r is known to hold fewer bytes than
+// count items of at least minSize bytes each require.  Item counts are
+// otherwise only bounded by the per-message maximums, which are far larger
+// than most payloads, so this prevents a small malformed message from forcing
+// the allocation of memory for items it does not contain.  As with
+// checkRemaining, the error is the one reading the items would have returned.
+func checkItemCount(r io.Reader, count, minSize uint64) error {
+	return checkRemaining(r, count*minSize)
+}
+
 // ReadVarString reads a variable length string from r and returns it as a Go
 // string.  A variable length string is encoded as a variable length integer
 // containing the length of the string followed by the bytes that represent the
@@ -604,7 +644,40 @@ func ReadVarString(r io.Reader, pver uint32) (string, error) {
 	if count > MaxMessagePayload {
 		str := fmt.Sprintf("variable length string is too long "+
 			"[count %d, max %d]", count, MaxMessagePayload)
-		return "", messageError("ReadVarString", str)
+		return "", messageErrorCode(ErrMsgFieldTooLong,
+			"ReadVarString", str)
+	}
+	if err := checkRemaining(r, count); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, count)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ReadVarStringMax reads a variable length string from r in the same manner as
+// ReadVarString, but rejects strings longer than the passed maxAllowed
+// parameter before allocating memory for them.  The fieldName parameter is
+// only used for the error message so it provides more context in the error.
+func ReadVarStringMax(r io.Reader, pver uint32, maxAllowed uint32,
+	fieldName string) (string, error) {
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return "", err
+	}
+	if count > uint64(maxAllowed) {
+		str := fmt.Sprintf("%s is longer than the max allowed length "+
+			"[count %d, max %d]", fieldName, count, maxAllowed)
+		return "", messageErrorCode(ErrMsgFieldTooLong,
+			"ReadVarStringMax", str)
+	}
+	if err := checkRemaining(r, count); err != nil {
+		return "", err
 	}
 
 	buf := make([]byte, count)
@@ -648,7 +721,11 @@ func ReadVarBytes(r io.Reader, pver uint32, maxAllowed uint32,
 	if count > uint64(maxAllowed) {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("ReadVarBytes", str)
+		return nil, messageErrorCode(ErrMsgFieldTooLong,
+			"ReadVarBytes", str)
+	}
+	if err := checkRemaining(r, count); err != nil {
+		return nil, err
 	}
 
 	b := make([]byte, count)
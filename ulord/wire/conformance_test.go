@@ -0,0 +1,164 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// conformanceVector is a single message payload of the protocol conformance
+// suite in testdata/conformance.
+type conformanceVector struct {
+	Name     string `json:"name"`
+	Command  string `json:"command"`
+	Pver     uint32 `json:"pver"`
+	Encoding string `json:"encoding"`
+	Payload  string `json:"payload"`
+
+	// Source records where the payload came from.  Payloads captured from
+	// a reference node with cmd/wirecapture start with "captured from"
+	// followed by the user agent and protocol version of the node, its
+	// address, and the date of the capture.
+	Source string `json:"source"`
+}
+
+// capturedSourcePrefix starts the source of every vector captured from a
+// reference node.
+const capturedSourcePrefix = "captured from "
+
+// conformanceCommands is every command which must be covered by at least one
+// conformance vector.  New message types must be added here along with
+// vectors for them.
+var conformanceCommands = []string{
+	CmdVersion, CmdVerAck, CmdGetAddr, CmdAddr, CmdGetBlocks, CmdBlock,
+	CmdInv, CmdGetData, CmdNotFound, CmdTx, CmdPing, CmdPong,
+	CmdGetHeaders, CmdHeaders, CmdAlert, CmdMemPool, CmdFilterAdd,
+	CmdFilterClear, CmdFilterLoad, CmdMerkleBlock, CmdReject,
+	CmdSendHeaders, CmdFeeFilter, CmdGetCFilters, CmdGetCFHeaders,
+	CmdGetCFCheckpt, CmdCFilter, CmdCFHeaders, CmdCFCheckpt,
+	CmdMNBroadcast, CmdMNPing, CmdTxLockVote, CmdSpork, CmdAddrV2,
+	CmdSendAddrV2,
+}
+
+// referenceCommands is every command whose vectors must eventually be captured
+// from the reference ulordd rather than encoded by this package, since a round
+// trip of self-encoded bytes can't detect drift from the encoding of the
+// reference node.
+var referenceCommands = []string{
+	CmdVersion, CmdMNBroadcast, CmdMNPing, CmdTxLockVote, CmdSpork,
+	CmdAddrV2, CmdSendAddrV2,
+}
+
+// loadConformanceVectors reads the conformance vectors from the named file
+// in testdata/conformance.
+func loadConformanceVectors(t *testing.T, name string) []conformanceVector {
+	path := filepath.Join("testdata", "conformance", name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read conformance vectors: %v", err)
+	}
+	var vectors []conformanceVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("unable to parse conformance vectors: %v", err)
+	}
+	return vectors
+}
+
+// TestConformanceVectors ensures every message payload of the conformance
+// suite decodes and encodes back to exactly the same bytes, and that every
+// message type is covered by the suite.
+func TestConformanceVectors(t *testing.T) {
+	vectors := loadConformanceVectors(t, "messages.json")
+
+	covered := make(map[string]bool)
+	captured := make(map[string]bool)
+	t.Logf("Running %d tests", len(vectors))
+	for _, test := range vectors {
+		covered[test.Command] = true
+		if test.Source == "" {
+			t.Errorf("%s: vector does not record its source",
+				test.Name)
+		}
+		if strings.HasPrefix(test.Source, capturedSourcePrefix) {
+			captured[test.Command] = true
+		}
+
+		var enc MessageEncoding
+		switch test.Encoding {
+		case "base":
+			enc = BaseEncoding
+		case "witness":
+			enc = WitnessEncoding
+		default:
+			t.Errorf("%s: unknown encoding %q", test.Name,
+				test.Encoding)
+			continue
+		}
+		payload, err := hex.DecodeString(test.Payload)
+		if err != nil {
+			t.Errorf("%s: invalid payload: %v", test.Name, err)
+			continue
+		}
+
+		msg, err := makeEmptyMessage(test.Command)
+		if err != nil {
+			t.Errorf("%s: %v", test.Name, err)
+			continue
+		}
+		if uint32(len(payload)) > msg.MaxPayloadLength(test.Pver) {
+			t.Errorf("%s: payload of %d bytes exceeds max payload "+
+				"length %d", test.Name, len(payload),
+				msg.MaxPayloadLength(test.Pver))
+		}
+
+		// Decode the payload and ensure all of it was consumed.  A
+		// bytes.Buffer is used since the version message requires one
+		// to detect its optional fields.
+		r := bytes.NewBuffer(payload)
+		if err := msg.BtcDecode(r, test.Pver, enc); err != nil {
+			t.Errorf("%s: BtcDecode: %v", test.Name, err)
+			continue
+		}
+		if r.Len() != 0 {
+			t.Errorf("%s: BtcDecode: %d trailing bytes not consumed",
+				test.Name, r.Len())
+			continue
+		}
+
+		// Encode the decoded message and ensure it is identical to the
+		// original payload.
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, test.Pver, enc); err != nil {
+			t.Errorf("%s: BtcEncode: %v", test.Name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), payload) {
+			t.Errorf("%s: BtcEncode: encoding drift\n got: %x\nwant: %x",
+				test.Name, buf.Bytes(), payload)
+		}
+	}
+
+	for _, cmd := range conformanceCommands {
+		if !covered[cmd] {
+			t.Errorf("no conformance vector for command %q", cmd)
+		}
+	}
+
+	// Report the commands still lacking a capture from the reference node
+	// so the gap stays visible in verbose test output.  See the README of
+	// testdata/conformance for how to capture them.
+	for _, cmd := range referenceCommands {
+		if !captured[cmd] {
+			t.Logf("no conformance vector for command %q captured "+
+				"from the reference node", cmd)
+		}
+	}
+}
@@ -8,6 +8,71 @@ import (
 	"fmt"
 )
 
+// MessageErrorCode identifies a kind of message error.
+type MessageErrorCode int
+
+// These constants are used to identify a specific MessageError.
+const (
+	// ErrMsgInvalid indicates a message which is malformed in a way that is
+	// not covered by one of the more specific error codes.
+	ErrMsgInvalid MessageErrorCode = iota
+
+	// ErrMsgNonCanonicalVarInt indicates a variable length integer which
+	// was not encoded using the minimum possible number of bytes.
+	ErrMsgNonCanonicalVarInt
+
+	// ErrMsgFieldTooLong indicates a variable length string or byte array
+	// which is longer than allowed for its field.
+	ErrMsgFieldTooLong
+
+	// ErrMsgTooManyItems indicates a count of items, such as inventory
+	// vectors, addresses, hashes, or transaction inputs, which is larger
+	// than allowed for the message or than could possibly fit into the
+	// remaining payload.
+	ErrMsgTooManyItems
+
+	// ErrMsgPayloadTooLarge indicates a message payload which is larger
+	// than allowed overall or for its message type.
+	ErrMsgPayloadTooLarge
+
+	// ErrMsgUnsupportedVersion indicates a message or field which is not
+	// valid for the protocol version in use.
+	ErrMsgUnsupportedVersion
+
+	// ErrMsgWrongNetwork indicates a message for another bitcoin network.
+	ErrMsgWrongNetwork
+
+	// ErrMsgUnknownCommand indicates a message with an unknown, malformed,
+	// or overly long command.
+	ErrMsgUnknownCommand
+
+	// ErrMsgBadChecksum indicates a message whose payload does not match
+	// the checksum of its header.
+	ErrMsgBadChecksum
+)
+
+// Map of MessageErrorCode values back to their constant names for pretty
+// printing.
+var messageErrorCodeStrings = map[MessageErrorCode]string{
+	ErrMsgInvalid:            "ErrMsgInvalid",
+	ErrMsgNonCanonicalVarInt: "ErrMsgNonCanonicalVarInt",
+	ErrMsgFieldTooLong:       "ErrMsgFieldTooLong",
+	ErrMsgTooManyItems:       "ErrMsgTooManyItems",
+	ErrMsgPayloadTooLarge:    "ErrMsgPayloadTooLarge",
+	ErrMsgUnsupportedVersion: "ErrMsgUnsupportedVersion",
+	ErrMsgWrongNetwork:       "ErrMsgWrongNetwork",
+	ErrMsgUnknownCommand:     "ErrMsgUnknownCommand",
+	ErrMsgBadChecksum:        "ErrMsgBadChecksum",
+}
+
+// String returns the MessageErrorCode as a human-readable name.
+func (e MessageErrorCode) String() string {
+	if s := messageErrorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown MessageErrorCode (%d)", int(e))
+}
+
 // MessageError describes an issue with a message.
 // An example of some potential issues are messages from the wrong bitcoin
 // network, invalid commands, mismatched checksums, and exceeding max payloads.
@@ -16,8 +81,9 @@ import (
 // differentiate between general io errors such as io.EOF and issues that
 // resulted from malformed messages.
 type MessageError struct {
-	Func        string // Function name
-	Description string // Human readable description of the issue
+	Func        string           // Function name
+	Description string           // Human readable description of the issue
+	ErrorCode   MessageErrorCode // Kind of issue
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -32,3 +98,9 @@ func (e *MessageError) Error() string {
 func messageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
 }
+
+// messageErrorCode creates an error of the given kind for the given function
+// and description.
+func messageErrorCode(c MessageErrorCode, f string, desc string) *MessageError {
+	return &MessageError{Func: f, Description: desc, ErrorCode: c}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build gofuzz
+
+package wire
+
+import (
+	"bytes"
+)
+
+// fuzzProtocolVersions are the protocol versions messages are decoded with by
+// Fuzz.  They cover each version which changed the encoding of a message.
+var fuzzProtocolVersions = []uint32{
+	0,
+	NetAddressTimeVersion,
+	BIP0037Version,
+	SendHeadersVersion,
+	ProtocolVersion,
+}
+
+// Fuzz is the entry point for go-fuzz.  It decodes data as a message framed by
+// a message header for each protocol version in fuzzProtocolVersions and both
+// message encodings.  The invalid message corpus under testdata/fuzz/corpus is
+// a good starting point:
+//
+//   go-fuzz-build github.com/ulordsuite/ulord/wire
+//   go-fuzz -bin=wire-fuzz.zip -workdir=testdata/fuzz
+//
+// It returns 1 when any of the decodings succeeded so go-fuzz gives priority
+// to inputs which produce valid messages.
+func Fuzz(data []byte) int {
+	score := 0
+	for _, pver := range fuzzProtocolVersions {
+		for _, enc := range []MessageEncoding{BaseEncoding, WitnessEncoding} {
+			r := bytes.NewReader(data)
+			_, _, _, err := ReadMessageWithEncodingN(r, pver, MainNet, enc)
+			if err == nil {
+				score = 1
+			}
+		}
+	}
+	return score
+}
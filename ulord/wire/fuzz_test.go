@@ -0,0 +1,132 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// maxCorpusAlloc is the maximum number of bytes decoding any message of the
+// invalid message corpus may allocate.  The messages claim counts and lengths
+// which would require tens of megabytes if they were trusted.
+const maxCorpusAlloc = 1 << 20
+
+// TestInvalidMessageCorpus ensures every message of the invalid message
+// corpus used to seed the fuzzer is rejected with the expected error and
+// without allocating memory for data the message does not contain.
+func TestInvalidMessageCorpus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error            // Expected io error
+		code MessageErrorCode // Expected code when err is nil
+	}{
+		{"addr-count", io.ErrUnexpectedEOF, 0},
+		{"addr-count-max", nil, ErrMsgTooManyItems},
+		{"addrv2-address-length", nil, ErrMsgFieldTooLong},
+		{"bad-checksum", nil, ErrMsgBadChecksum},
+		{"block-tx-count", io.ErrUnexpectedEOF, 0},
+		{"block-tx-count-max", nil, ErrMsgTooManyItems},
+		{"filterload-filter-length", nil, ErrMsgFieldTooLong},
+		{"getdata-count", io.ErrUnexpectedEOF, 0},
+		{"getheaders-locator-count-max", nil, ErrMsgTooManyItems},
+		{"headers-count", io.ErrUnexpectedEOF, 0},
+		{"headers-count-max", nil, ErrMsgTooManyItems},
+		{"inv-count", io.ErrUnexpectedEOF, 0},
+		{"inv-count-max", nil, ErrMsgTooManyItems},
+		{"inv-noncanonical-count", nil, ErrMsgNonCanonicalVarInt},
+		{"merkleblock-flags-length", nil, ErrMsgFieldTooLong},
+		{"payload-too-large", nil, ErrMsgPayloadTooLarge},
+		{"reject-command-length", nil, ErrMsgFieldTooLong},
+		{"reject-reason-length", io.ErrUnexpectedEOF, 0},
+		{"tx-input-count", io.ErrUnexpectedEOF, 0},
+		{"tx-output-count", io.ErrUnexpectedEOF, 0},
+		{"tx-script-length", io.ErrUnexpectedEOF, 0},
+		{"tx-witness-count", io.ErrUnexpectedEOF, 0},
+		{"tx-witness-count-max", nil, ErrMsgTooManyItems},
+		{"tx-witness-item-length", nil, ErrMsgFieldTooLong},
+		{"unknown-command", nil, ErrMsgUnknownCommand},
+		{"version-user-agent-length", nil, ErrMsgFieldTooLong},
+		{"wrong-network", nil, ErrMsgWrongNetwork},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		path := filepath.Join("testdata", "fuzz", "corpus", test.name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: unable to read corpus: %v", test.name, err)
+			continue
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		r := bytes.NewReader(data)
+		_, _, _, err = ReadMessageWithEncodingN(r, ProtocolVersion,
+			MainNet, WitnessEncoding)
+		runtime.ReadMemStats(&after)
+
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > maxCorpusAlloc {
+			t.Errorf("%s: allocated %d bytes, max %d", test.name,
+				alloc, maxCorpusAlloc)
+		}
+
+		if test.err != nil {
+			if err != test.err {
+				t.Errorf("%s: wrong error - got %v, want %v",
+					test.name, err, test.err)
+			}
+			continue
+		}
+		msgErr, ok := err.(*MessageError)
+		if !ok {
+			t.Errorf("%s: wrong error - got %v <%T>, want <%T>",
+				test.name, err, err, &MessageError{})
+			continue
+		}
+		if msgErr.ErrorCode != test.code {
+			t.Errorf("%s: wrong error code - got %v, want %v",
+				test.name, msgErr.ErrorCode, test.code)
+		}
+	}
+}
+
+// TestMessageErrorCodeStringer tests the stringized output for the
+// MessageErrorCode type.
+func TestMessageErrorCodeStringer(t *testing.T) {
+	tests := []struct {
+		in   MessageErrorCode
+		want string
+	}{
+		{ErrMsgInvalid, "ErrMsgInvalid"},
+		{ErrMsgNonCanonicalVarInt, "ErrMsgNonCanonicalVarInt"},
+		{ErrMsgFieldTooLong, "ErrMsgFieldTooLong"},
+		{ErrMsgTooManyItems, "ErrMsgTooManyItems"},
+		{ErrMsgPayloadTooLarge, "ErrMsgPayloadTooLarge"},
+		{ErrMsgUnsupportedVersion, "ErrMsgUnsupportedVersion"},
+		{ErrMsgWrongNetwork, "ErrMsgWrongNetwork"},
+		{ErrMsgUnknownCommand, "ErrMsgUnknownCommand"},
+		{ErrMsgBadChecksum, "ErrMsgBadChecksum"},
+		{0xffff, "Unknown MessageErrorCode (65535)"},
+	}
+
+	// Detect additional error codes that don't have the stringer added.
+	if len(tests)-1 != len(messageErrorCodeStrings) {
+		t.Errorf("It appears an error code was added without adding " +
+			"an associated stringer test")
+	}
+
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+		}
+	}
+}
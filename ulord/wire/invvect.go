@@ -38,6 +38,26 @@ const (
 	InvTypeFilteredWitnessBlock InvType = InvTypeFilteredBlock | InvWitnessFlag
 )
 
+// These constants define the inventory vector types of the Ulord specific
+// objects relayed between peers since protocol version MasternodeVersion.
+// Their values match the ones used by the reference implementation.
+const (
+	// InvTypeTxLockRequest identifies a transaction requested to be sent
+	// instantly by locking its inputs.
+	InvTypeTxLockRequest InvType = 4
+
+	// InvTypeTxLockVote identifies a masternode vote for locking an input
+	// of a transaction, which is relayed in a MsgTxLockVote.
+	InvTypeTxLockVote InvType = 5
+
+	// InvTypeSpork identifies a spork, which is relayed in a MsgSpork.
+	InvTypeSpork InvType = 6
+
+	// InvTypeMasternodeBroadcast identifies a masternode announcement,
+	// which is relayed in a MsgMNBroadcast.
+	InvTypeMasternodeBroadcast InvType = 14
+)
+
 // Map of service flags back to their constant names for pretty printing.
 var ivStrings = map[InvType]string{
 	InvTypeError:                "ERROR",
@@ -47,6 +67,10 @@ var ivStrings = map[InvType]string{
 	InvTypeWitnessBlock:         "MSG_WITNESS_BLOCK",
 	InvTypeWitnessTx:            "MSG_WITNESS_TX",
 	InvTypeFilteredWitnessBlock: "MSG_FILTERED_WITNESS_BLOCK",
+	InvTypeTxLockRequest:        "MSG_TXLOCK_REQUEST",
+	InvTypeTxLockVote:           "MSG_TXLOCK_VOTE",
+	InvTypeSpork:                "MSG_SPORK",
+	InvTypeMasternodeBroadcast:  "MSG_MASTERNODE_ANNOUNCE",
 }
 
 // String returns the InvType in human-readable form.
@@ -58,6 +82,27 @@ func (invtype InvType) String() string {
 	return fmt.Sprintf("Unknown InvType (%d)", uint32(invtype))
 }
 
+// IsKnown returns whether the InvType is one of the inventory vector types
+// defined by this package.  Inventory vectors of unknown types are still
+// decoded so that peers using newer types can be served, but they should not
+// be relayed or requested.
+func (invtype InvType) IsKnown() bool {
+	_, ok := ivStrings[invtype]
+	return ok
+}
+
+// IsUlord returns whether the InvType identifies one of the Ulord specific
+// objects relayed between peers, which must only be advertised to peers of
+// at least protocol version MasternodeVersion.
+func (invtype InvType) IsUlord() bool {
+	switch invtype {
+	case InvTypeTxLockRequest, InvTypeTxLockVote, InvTypeSpork,
+		InvTypeMasternodeBroadcast:
+		return true
+	}
+	return false
+}
+
 // InvVect defines a bitcoin inventory vector which is used to describe data,
 // as specified by the Type field, that a peer wants, has, or does not have to
 // another peer.
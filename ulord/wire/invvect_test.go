@@ -22,6 +22,10 @@ func TestInvTypeStringer(t *testing.T) {
 		{InvTypeError, "ERROR"},
 		{InvTypeTx, "MSG_TX"},
 		{InvTypeBlock, "MSG_BLOCK"},
+		{InvTypeTxLockRequest, "MSG_TXLOCK_REQUEST"},
+		{InvTypeTxLockVote, "MSG_TXLOCK_VOTE"},
+		{InvTypeSpork, "MSG_SPORK"},
+		{InvTypeMasternodeBroadcast, "MSG_MASTERNODE_ANNOUNCE"},
 		{0xffffffff, "Unknown InvType (4294967295)"},
 	}
 
@@ -37,6 +41,36 @@ func TestInvTypeStringer(t *testing.T) {
 
 }
 
+// TestInvTypeValidation tests the classification of inventory vector types.
+func TestInvTypeValidation(t *testing.T) {
+	tests := []struct {
+		in    InvType
+		known bool
+		ulord bool
+	}{
+		{InvTypeTx, true, false},
+		{InvTypeWitnessBlock, true, false},
+		{InvTypeTxLockRequest, true, true},
+		{InvTypeTxLockVote, true, true},
+		{InvTypeSpork, true, true},
+		{InvTypeMasternodeBroadcast, true, true},
+		{7, false, false},
+		{InvTypeSpork | InvWitnessFlag, false, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if known := test.in.IsKnown(); known != test.known {
+			t.Errorf("IsKnown #%d (%v) got: %v want: %v", i, test.in,
+				known, test.known)
+		}
+		if ulord := test.in.IsUlord(); ulord != test.ulord {
+			t.Errorf("IsUlord #%d (%v) got: %v want: %v", i, test.in,
+				ulord, test.ulord)
+		}
+	}
+}
+
 // TestInvVect tests the InvVect API.
 func TestInvVect(t *testing.T) {
 	ivType := InvTypeBlock
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 	"unicode/utf8"
 
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
@@ -57,6 +58,12 @@ const (
 	CmdCFilter      = "cfilter"
 	CmdCFHeaders    = "cfheaders"
 	CmdCFCheckpt    = "cfcheckpt"
+	CmdMNBroadcast  = "mnb"
+	CmdMNPing       = "mnp"
+	CmdTxLockVote   = "txlvote"
+	CmdSpork        = "spork"
+	CmdAddrV2       = "addrv2"
+	CmdSendAddrV2   = "sendaddrv2"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -180,6 +187,24 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdCFCheckpt:
 		msg = &MsgCFCheckpt{}
 
+	case CmdMNBroadcast:
+		msg = &MsgMNBroadcast{}
+
+	case CmdMNPing:
+		msg = &MsgMNPing{}
+
+	case CmdTxLockVote:
+		msg = &MsgTxLockVote{}
+
+	case CmdSpork:
+		msg = &MsgSpork{}
+
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -263,6 +288,35 @@ func WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) erro
 func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	btcnet BitcoinNet, encoding MessageEncoding) (int, error) {
 
+	return WriteMessageWithHookN(w, msg, pver, btcnet, encoding, nil)
+}
+
+// WriteMessageWithHookN writes a bitcoin Message to w in the same manner as
+// WriteMessageWithEncodingN, additionally invoking the passed hook, if not
+// nil, with the details of the written message.
+func WriteMessageWithHookN(w io.Writer, msg Message, pver uint32,
+	btcnet BitcoinNet, encoding MessageEncoding, hook MessageHook) (int, error) {
+
+	n, hdr, err := writeMessage(w, msg, pver, btcnet, encoding)
+	if hook != nil && hdr != nil {
+		hook(&MessageEvent{
+			Direction:  MessageWritten,
+			Command:    hdr.command,
+			PayloadLen: hdr.length,
+			Bytes:      n,
+			Time:       time.Now(),
+			Err:        err,
+		})
+	}
+	return n, err
+}
+
+// writeMessage writes a bitcoin Message to w and returns the number of bytes
+// written along with the header of the message.  The returned header is nil
+// when the message was rejected before any bytes were written.
+func writeMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet,
+	encoding MessageEncoding) (int, *messageHeader, error) {
+
 	totalBytes := 0
 
 	// Enforce max command size.
@@ -271,7 +325,8 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	if len(cmd) > CommandSize {
 		str := fmt.Sprintf("command [%s] is too long [max %v]",
 			cmd, CommandSize)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, nil, messageErrorCode(ErrMsgUnknownCommand,
+			"WriteMessage", str)
 	}
 	copy(command[:], []byte(cmd))
 
@@ -279,7 +334,7 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	var bw bytes.Buffer
 	err := msg.BtcEncode(&bw, pver, encoding)
 	if err != nil {
-		return totalBytes, err
+		return totalBytes, nil, err
 	}
 	payload := bw.Bytes()
 	lenp := len(payload)
@@ -289,7 +344,8 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload is %d bytes",
 			lenp, MaxMessagePayload)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, nil, messageErrorCode(ErrMsgPayloadTooLarge,
+			"WriteMessage", str)
 	}
 
 	// Enforce maximum message payload based on the message type.
@@ -298,7 +354,8 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload size for "+
 			"messages of type [%s] is %d.", lenp, cmd, mpl)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, nil, messageErrorCode(ErrMsgPayloadTooLarge,
+			"WriteMessage", str)
 	}
 
 	// Create header for the message.
@@ -318,13 +375,13 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	n, err := w.Write(hw.Bytes())
 	totalBytes += n
 	if err != nil {
-		return totalBytes, err
+		return totalBytes, &hdr, err
 	}
 
 	// Write payload.
 	n, err = w.Write(payload)
 	totalBytes += n
-	return totalBytes, err
+	return totalBytes, &hdr, err
 }
 
 // ReadMessageWithEncodingN reads, validates, and parses the next bitcoin Message
@@ -336,11 +393,44 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 	enc MessageEncoding) (int, Message, []byte, error) {
 
+	return ReadMessageWithHookN(r, pver, btcnet, enc, nil)
+}
+
+// ReadMessageWithHookN reads, validates, and parses the next bitcoin Message
+// from r in the same manner as ReadMessageWithEncodingN, additionally invoking
+// the passed hook, if not nil, with the details of the message once its header
+// has been read.  The hook is also invoked when the message is rejected after
+// reading the header, such as for messages from other networks or with
+// unknown commands.
+func ReadMessageWithHookN(r io.Reader, pver uint32, btcnet BitcoinNet,
+	enc MessageEncoding, hook MessageHook) (int, Message, []byte, error) {
+
+	n, hdr, msg, payload, err := readMessage(r, pver, btcnet, enc)
+	if hook != nil && hdr != nil {
+		hook(&MessageEvent{
+			Direction:  MessageRead,
+			Command:    hdr.command,
+			PayloadLen: hdr.length,
+			Bytes:      n,
+			Time:       time.Now(),
+			Err:        err,
+		})
+	}
+	return n, msg, payload, err
+}
+
+// readMessage reads, validates, and parses the next bitcoin Message from r.
+// It returns the number of bytes read and the message header in addition to
+// the parsed Message and its raw payload.  The returned header is nil when it
+// could not be read.
+func readMessage(r io.Reader, pver uint32, btcnet BitcoinNet,
+	enc MessageEncoding) (int, *messageHeader, Message, []byte, error) {
+
 	totalBytes := 0
 	n, hdr, err := readMessageHeader(r)
 	totalBytes += n
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, nil, nil, nil, err
 	}
 
 	// Enforce maximum message payload.
@@ -348,7 +438,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
 			"bytes.", hdr.length, MaxMessagePayload)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr, nil, nil, messageErrorCode(ErrMsgPayloadTooLarge,
+			"ReadMessage", str)
 
 	}
 
@@ -356,7 +447,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 	if hdr.magic != btcnet {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("message from other network [%v]", hdr.magic)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr, nil, nil, messageErrorCode(ErrMsgWrongNetwork,
+			"ReadMessage", str)
 	}
 
 	// Check for malformed commands.
@@ -364,15 +456,16 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 	if !utf8.ValidString(command) {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("invalid command %v", []byte(command))
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr, nil, nil, messageErrorCode(ErrMsgUnknownCommand,
+			"ReadMessage", str)
 	}
 
 	// Create struct of appropriate message type based on the command.
 	msg, err := makeEmptyMessage(command)
 	if err != nil {
 		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageError("ReadMessage",
-			err.Error())
+		return totalBytes, hdr, nil, nil, messageErrorCode(
+			ErrMsgUnknownCommand, "ReadMessage", err.Error())
 	}
 
 	// Check for maximum length based on the message type as a malicious client
@@ -384,7 +477,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v.", hdr.length, command, mpl)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr, nil, nil, messageErrorCode(ErrMsgPayloadTooLarge,
+			"ReadMessage", str)
 	}
 
 	// Read payload.
@@ -392,7 +486,7 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 	n, err = io.ReadFull(r, payload)
 	totalBytes += n
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, hdr, nil, nil, err
 	}
 
 	// Test checksum.
@@ -401,7 +495,8 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 		str := fmt.Sprintf("payload checksum failed - header "+
 			"indicates %v, but actual checksum is %v.",
 			hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr, nil, nil, messageErrorCode(ErrMsgBadChecksum,
+			"ReadMessage", str)
 	}
 
 	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
@@ -409,10 +504,10 @@ func ReadMessageWithEncodingN(r io.Reader, pver uint32, btcnet BitcoinNet,
 	pr := bytes.NewBuffer(payload)
 	err = msg.BtcDecode(pr, pver, enc)
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, hdr, nil, nil, err
 	}
 
-	return totalBytes, msg, payload, nil
+	return totalBytes, hdr, msg, payload, nil
 }
 
 // ReadMessageN reads, validates, and parses the next bitcoin Message from r for
@@ -76,6 +76,8 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgAddrV2 := NewMsgAddrV2()
+	msgSendAddrV2 := NewMsgSendAddrV2()
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -111,6 +113,12 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{baseMNBroadcast, baseMNBroadcast, pver, MainNet, 185},
+		{baseMNPing, baseMNPing, pver, MainNet, 111},
+		{baseTxLockVote, baseTxLockVote, pver, MainNet, 131},
+		{baseSpork, baseSpork, pver, MainNet, 48},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -32,7 +32,8 @@ func (msg *MsgAddr) AddAddress(na *NetAddress) error {
 	if len(msg.AddrList)+1 > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses in message [max %v]",
 			MaxAddrPerMsg)
-		return messageError("MsgAddr.AddAddress", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgAddr.AddAddress", str)
 	}
 
 	msg.AddrList = append(msg.AddrList, na)
@@ -67,7 +68,12 @@ func (msg *MsgAddr) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) err
 	if count > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses for message "+
 			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgAddr.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, uint64(maxNetAddressPayload(pver)))
+	if err != nil {
+		return err
 	}
 
 	addrList := make([]NetAddress, count)
@@ -92,13 +98,15 @@ func (msg *MsgAddr) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) err
 	if pver < MultipleAddressVersion && count > 1 {
 		str := fmt.Sprintf("too many addresses for message of "+
 			"protocol version %v [count %v, max 1]", pver, count)
-		return messageError("MsgAddr.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgAddr.BtcEncode", str)
 
 	}
 	if count > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses for message "+
 			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgAddr.BtcEncode", str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
@@ -0,0 +1,284 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2 tests the MsgAddrV2 API.
+func TestAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "addrv2"
+	msg := NewMsgAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Num addresses (varInt) + max allowed addresses.
+	wantPayload := uint32(531009)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure NetAddresses are added properly.
+	na, err := NewNetAddressV2(NetIDTorV3, seqBytes(32), 9888, SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: unexpected error %v", err)
+	}
+	err = msg.AddAddress(na)
+	if err != nil {
+		t.Errorf("AddAddress: %v", err)
+	}
+	if msg.AddrList[0] != na {
+		t.Errorf("AddAddress: wrong address added - got %v, want %v",
+			spew.Sprint(msg.AddrList[0]), spew.Sprint(na))
+	}
+
+	// Ensure the address list is cleared properly.
+	msg.ClearAddresses()
+	if len(msg.AddrList) != 0 {
+		t.Errorf("ClearAddresses: address list is not empty - "+
+			"got %v [%v], want %v", len(msg.AddrList),
+			spew.Sprint(msg.AddrList[0]), 0)
+	}
+
+	// Ensure adding more than the max allowed addresses per message
+	// returns error.
+	for i := 0; i < MaxAddrPerMsg+1; i++ {
+		err = msg.AddAddress(na)
+	}
+	if err == nil {
+		t.Errorf("AddAddress: expected error on too many addresses " +
+			"not received")
+	}
+	err = msg.AddAddresses(na)
+	if err == nil {
+		t.Errorf("AddAddresses: expected error on too many addresses " +
+			"not received")
+	}
+}
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode.
+func TestAddrV2Wire(t *testing.T) {
+	ts := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	ipv4 := &NetAddressV2{
+		Timestamp: ts,
+		Services:  SFNodeNetwork,
+		NetID:     NetIDIPv4,
+		Addr:      []byte{127, 0, 0, 1},
+		Port:      9888,
+	}
+	unknown := &NetAddressV2{
+		Timestamp: ts,
+		Services:  SFNodeNetwork | SFNodeBloom,
+		NetID:     NetworkID(42),
+		Addr:      []byte{0xab, 0xcd, 0xef},
+		Port:      1,
+	}
+
+	// Empty address message.
+	noAddr := NewMsgAddrV2()
+	noAddrEncoded := []byte{
+		0x00, // Varint for number of addresses
+	}
+
+	// Address message with multiple addresses, including one of an
+	// unknown network.
+	multiAddr := NewMsgAddrV2()
+	multiAddr.AddAddresses(ipv4, unknown)
+	multiAddrEncoded := []byte{
+		0x02,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                         // Services (varInt)
+		0x01,                         // Network id IPv4
+		0x04, 0x7f, 0x00, 0x00, 0x01, // Address
+		0x26, 0xa0, // Port 9888 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x05,                   // Services (varInt)
+		0x2a,                   // Unknown network id
+		0x03, 0xab, 0xcd, 0xef, // Address
+		0x00, 0x01, // Port 1 in big-endian
+	}
+
+	tests := []struct {
+		in  *MsgAddrV2 // Message to encode
+		out *MsgAddrV2 // Expected decoded message
+		buf []byte     // Wire encoding
+	}{
+		{noAddr, noAddr, noAddrEncoded},
+		{multiAddr, multiAddr, multiAddrEncoded},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgAddrV2
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if len(msg.AddrList) != len(test.out.AddrList) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+		for j, na := range msg.AddrList {
+			if !reflect.DeepEqual(na, test.out.AddrList[j]) {
+				t.Errorf("BtcDecode #%d address #%d\n got: %s "+
+					"want: %s", i, j, spew.Sdump(na),
+					spew.Sdump(test.out.AddrList[j]))
+			}
+		}
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against wire encode and decode
+// of MsgAddrV2 to confirm error paths work correctly.
+func TestAddrV2WireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	ts := time.Unix(0x495fab29, 0)
+	baseAddr := NewMsgAddrV2()
+	baseAddr.AddAddress(&NetAddressV2{
+		Timestamp: ts,
+		Services:  SFNodeNetwork,
+		NetID:     NetIDIPv4,
+		Addr:      []byte{127, 0, 0, 1},
+		Port:      9888,
+	})
+	baseAddrEncoded := []byte{
+		0x01, 0x29, 0xab, 0x5f, 0x49, 0x01, 0x01, 0x04,
+		0x7f, 0x00, 0x00, 0x01, 0x26, 0xa0,
+	}
+
+	// Message that forces an error by having more than the max allowed
+	// addresses.
+	maxAddr := NewMsgAddrV2()
+	for i := 0; i < MaxAddrPerMsg; i++ {
+		maxAddr.AddAddress(baseAddr.AddrList[0])
+	}
+	maxAddr.AddrList = append(maxAddr.AddrList, baseAddr.AddrList[0])
+	maxAddrEncoded := []byte{
+		0xfd, 0xe9, 0x03, // Varint for number of addresses (1001)
+	}
+
+	// Message with an address of the wrong size for its network.
+	badSize := NewMsgAddrV2()
+	badSize.AddAddress(&NetAddressV2{
+		Timestamp: ts,
+		NetID:     NetIDIPv4,
+		Addr:      []byte{127, 0, 0},
+	})
+	badSizeEncoded := []byte{
+		0x01, 0x29, 0xab, 0x5f, 0x49, 0x00, 0x01, 0x03,
+		0x7f, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	// Message with an address larger than the max allowed size.
+	bigAddrEncoded := []byte{
+		0x01, 0x29, 0xab, 0x5f, 0x49, 0x00, 0x2a, 0xfd,
+		0x01, 0x02,
+	}
+
+	tests := []struct {
+		in       *MsgAddrV2 // Value to encode
+		buf      []byte     // Wire encoding
+		max      int        // Max size of fixed buffer to induce errors
+		writeErr error      // Expected write error
+		readErr  error      // Expected read error
+	}{
+		// Force error in addresses count
+		{baseAddr, baseAddrEncoded, 0, io.ErrShortWrite, io.EOF},
+		// Force error in timestamp.
+		{baseAddr, baseAddrEncoded, 1, io.ErrShortWrite, io.EOF},
+		// Force error in services.
+		{baseAddr, baseAddrEncoded, 5, io.ErrShortWrite, io.EOF},
+		// Force error in network id.
+		{baseAddr, baseAddrEncoded, 6, io.ErrShortWrite, io.EOF},
+		// Force error in address.
+		{baseAddr, baseAddrEncoded, 7, io.ErrShortWrite, io.EOF},
+		// Force error in port.
+		{baseAddr, baseAddrEncoded, 12, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max addresses.
+		{maxAddr, maxAddrEncoded, 3, wireErr, wireErr},
+		// Force error with an address of the wrong size.
+		{badSize, badSizeEncoded, len(badSizeEncoded), wireErr, wireErr},
+		// Force error with an address larger than the max size.
+		{NewMsgAddrV2(), bigAddrEncoded, len(bigAddrEncoded), nil,
+			wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgAddrV2
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
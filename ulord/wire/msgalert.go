@@ -159,7 +159,8 @@ func (alert *Alert) Serialize(w io.Writer, pver uint32) error {
 	if count > maxCountSetCancel {
 		str := fmt.Sprintf("too many cancel alert IDs for alert "+
 			"[count %v, max %v]", count, maxCountSetCancel)
-		return messageError("Alert.Serialize", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"Alert.Serialize", str)
 	}
 	err = WriteVarInt(w, pver, uint64(count))
 	if err != nil {
@@ -181,7 +182,8 @@ func (alert *Alert) Serialize(w io.Writer, pver uint32) error {
 	if count > maxCountSetSubVer {
 		str := fmt.Sprintf("too many sub versions for alert "+
 			"[count %v, max %v]", count, maxCountSetSubVer)
-		return messageError("Alert.Serialize", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"Alert.Serialize", str)
 	}
 	err = WriteVarInt(w, pver, uint64(count))
 	if err != nil {
@@ -228,7 +230,8 @@ func (alert *Alert) Deserialize(r io.Reader, pver uint32) error {
 	if count > maxCountSetCancel {
 		str := fmt.Sprintf("too many cancel alert IDs for alert "+
 			"[count %v, max %v]", count, maxCountSetCancel)
-		return messageError("Alert.Deserialize", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"Alert.Deserialize", str)
 	}
 	alert.SetCancel = make([]int32, count)
 	for i := 0; i < int(count); i++ {
@@ -252,7 +255,8 @@ func (alert *Alert) Deserialize(r io.Reader, pver uint32) error {
 	if count > maxCountSetSubVer {
 		str := fmt.Sprintf("too many sub versions for alert "+
 			"[count %v, max %v]", count, maxCountSetSubVer)
-		return messageError("Alert.Deserialize", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"Alert.Deserialize", str)
 	}
 	alert.SetSubVer = make([]string, count)
 	for i := 0; i < int(count); i++ {
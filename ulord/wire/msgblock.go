@@ -78,7 +78,12 @@ func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er
 	if txCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return messageError("MsgBlock.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgBlock.BtcDecode", str)
+	}
+	err = checkItemCount(r, txCount, minTxPayload)
+	if err != nil {
+		return err
 	}
 
 	msg.Transactions = make([]*MsgTx, 0, txCount)
@@ -148,7 +153,12 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 	if txCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return nil, messageError("MsgBlock.DeserializeTxLoc", str)
+		return nil, messageErrorCode(ErrMsgTooManyItems,
+			"MsgBlock.DeserializeTxLoc", str)
+	}
+	err = checkItemCount(r, txCount, minTxPayload)
+	if err != nil {
+		return nil, err
 	}
 
 	// Deserialize each transaction while keeping track of its location
@@ -251,6 +261,16 @@ func (msg *MsgBlock) SerializeSizeStripped() int {
 	return n
 }
 
+// Weight returns the weight of the block as defined by BIP0141.  See
+// MsgTx.Weight for details.
+func (msg *MsgBlock) Weight() int64 {
+	baseSize := msg.SerializeSizeStripped()
+	totalSize := msg.SerializeSize()
+
+	// (baseSize * 3) + totalSize
+	return int64(baseSize*(witnessScaleFactor-1) + totalSize)
+}
+
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgBlock) Command() string {
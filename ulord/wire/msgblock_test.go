@@ -482,6 +482,26 @@ func TestBlockSerializeSize(t *testing.T) {
 	}
 }
 
+// TestBlockWeight tests the weight of blocks without witness data, which is
+// four times their serialized size.
+func TestBlockWeight(t *testing.T) {
+	noTxBlock := NewMsgBlock(&blockOne.Header)
+	tests := []struct {
+		in     *MsgBlock // Block to weigh
+		weight int64     // Expected weight
+	}{
+		{noTxBlock, 81 * 4},
+		{&blockOne, int64(len(blockOneBytes)) * 4},
+	}
+
+	for i, test := range tests {
+		if weight := test.in.Weight(); weight != test.weight {
+			t.Errorf("MsgBlock.Weight: #%d got: %d, want: %d", i,
+				weight, test.weight)
+		}
+	}
+}
+
 // blockOne is the first block in the mainnet block chain.
 var blockOne = MsgBlock{
 	Header: BlockHeader{
@@ -74,6 +74,10 @@ func (msg *MsgCFCheckpt) BtcDecode(r io.Reader, pver uint32, _ MessageEncoding)
 	if count > maxCFHeadersLen {
 		return ErrInsaneCFHeaderCount
 	}
+	err = checkItemCount(r, count, chainhash.HashSize)
+	if err != nil {
+		return err
+	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
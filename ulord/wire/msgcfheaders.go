@@ -37,8 +37,9 @@ type MsgCFHeaders struct {
 func (msg *MsgCFHeaders) AddCFHash(hash *chainhash.Hash) error {
 	if len(msg.FilterHashes)+1 > MaxCFHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers in message [max %v]",
-			MaxBlockHeadersPerMsg)
-		return messageError("MsgCFHeaders.AddCFHash", str)
+			MaxCFHeadersPerMsg)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgCFHeaders.AddCFHash", str)
 	}
 
 	msg.FilterHashes = append(msg.FilterHashes, hash)
@@ -76,8 +77,13 @@ func (msg *MsgCFHeaders) BtcDecode(r io.Reader, pver uint32, _ MessageEncoding)
 	if count > MaxCFHeadersPerMsg {
 		str := fmt.Sprintf("too many committed filter headers for "+
 			"message [count %v, max %v]", count,
-			MaxBlockHeadersPerMsg)
-		return messageError("MsgCFHeaders.BtcDecode", str)
+			MaxCFHeadersPerMsg)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgCFHeaders.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, chainhash.HashSize)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -121,8 +127,9 @@ func (msg *MsgCFHeaders) BtcEncode(w io.Writer, pver uint32, _ MessageEncoding)
 	if count > MaxCFHeadersPerMsg {
 		str := fmt.Sprintf("too many committed filter headers for "+
 			"message [count %v, max %v]", count,
-			MaxBlockHeadersPerMsg)
-		return messageError("MsgCFHeaders.BtcEncode", str)
+			MaxCFHeadersPerMsg)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgCFHeaders.BtcEncode", str)
 	}
 
 	err = WriteVarInt(w, pver, uint64(count))
@@ -62,7 +62,8 @@ func (msg *MsgCFilter) BtcEncode(w io.Writer, pver uint32, _ MessageEncoding) er
 	if size > MaxCFilterDataSize {
 		str := fmt.Sprintf("cfilter size too large for message "+
 			"[size %v, max %v]", size, MaxCFilterDataSize)
-		return messageError("MsgCFilter.BtcEncode", str)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgCFilter.BtcEncode", str)
 	}
 
 	err := writeElement(w, msg.FilterType)
@@ -25,7 +25,8 @@ func (msg *MsgFeeFilter) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding
 	if pver < FeeFilterVersion {
 		str := fmt.Sprintf("feefilter message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFeeFilter.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFeeFilter.BtcDecode", str)
 	}
 
 	return readElement(r, &msg.MinFee)
@@ -37,7 +38,8 @@ func (msg *MsgFeeFilter) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding
 	if pver < FeeFilterVersion {
 		str := fmt.Sprintf("feefilter message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFeeFilter.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFeeFilter.BtcEncode", str)
 	}
 
 	return writeElement(w, msg.MinFee)
@@ -31,7 +31,8 @@ func (msg *MsgFilterAdd) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filteradd message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterAdd.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterAdd.BtcDecode", str)
 	}
 
 	var err error
@@ -46,14 +47,16 @@ func (msg *MsgFilterAdd) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filteradd message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterAdd.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterAdd.BtcEncode", str)
 	}
 
 	size := len(msg.Data)
 	if size > MaxFilterAddDataSize {
 		str := fmt.Sprintf("filteradd size too large for message "+
 			"[size %v, max %v]", size, MaxFilterAddDataSize)
-		return messageError("MsgFilterAdd.BtcEncode", str)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgFilterAdd.BtcEncode", str)
 	}
 
 	return WriteVarBytes(w, pver, msg.Data)
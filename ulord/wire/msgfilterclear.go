@@ -22,7 +22,8 @@ func (msg *MsgFilterClear) BtcDecode(r io.Reader, pver uint32, enc MessageEncodi
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filterclear message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterClear.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterClear.BtcDecode", str)
 	}
 
 	return nil
@@ -34,7 +35,8 @@ func (msg *MsgFilterClear) BtcEncode(w io.Writer, pver uint32, enc MessageEncodi
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filterclear message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterClear.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterClear.BtcEncode", str)
 	}
 
 	return nil
@@ -55,7 +55,8 @@ func (msg *MsgFilterLoad) BtcDecode(r io.Reader, pver uint32, enc MessageEncodin
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filterload message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterLoad.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterLoad.BtcDecode", str)
 	}
 
 	var err error
@@ -73,7 +74,8 @@ func (msg *MsgFilterLoad) BtcDecode(r io.Reader, pver uint32, enc MessageEncodin
 	if msg.HashFuncs > MaxFilterLoadHashFuncs {
 		str := fmt.Sprintf("too many filter hash functions for message "+
 			"[count %v, max %v]", msg.HashFuncs, MaxFilterLoadHashFuncs)
-		return messageError("MsgFilterLoad.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgFilterLoad.BtcDecode", str)
 	}
 
 	return nil
@@ -85,20 +87,23 @@ func (msg *MsgFilterLoad) BtcEncode(w io.Writer, pver uint32, enc MessageEncodin
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("filterload message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgFilterLoad.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgFilterLoad.BtcEncode", str)
 	}
 
 	size := len(msg.Filter)
 	if size > MaxFilterLoadFilterSize {
 		str := fmt.Sprintf("filterload filter size too large for message "+
 			"[size %v, max %v]", size, MaxFilterLoadFilterSize)
-		return messageError("MsgFilterLoad.BtcEncode", str)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgFilterLoad.BtcEncode", str)
 	}
 
 	if msg.HashFuncs > MaxFilterLoadHashFuncs {
 		str := fmt.Sprintf("too many filter hash functions for message "+
 			"[count %v, max %v]", msg.HashFuncs, MaxFilterLoadHashFuncs)
-		return messageError("MsgFilterLoad.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgFilterLoad.BtcEncode", str)
 	}
 
 	err := WriteVarBytes(w, pver, msg.Filter)
@@ -41,7 +41,8 @@ func (msg *MsgGetBlocks) AddBlockLocatorHash(hash *chainhash.Hash) error {
 	if len(msg.BlockLocatorHashes)+1 > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message [max %v]",
 			MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.AddBlockLocatorHash", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetBlocks.AddBlockLocatorHash", str)
 	}
 
 	msg.BlockLocatorHashes = append(msg.BlockLocatorHashes, hash)
@@ -64,7 +65,12 @@ func (msg *MsgGetBlocks) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetBlocks.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, chainhash.HashSize)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -90,7 +96,8 @@ func (msg *MsgGetBlocks) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetBlocks.BtcEncode", str)
 	}
 
 	err := writeElement(w, msg.ProtocolVersion)
@@ -28,7 +28,8 @@ func (msg *MsgGetData) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgGetData.AddInvVect", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetData.AddInvVect", str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -46,7 +47,12 @@ func (msg *MsgGetData) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding)
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgGetData.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetData.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, maxInvVectPayload)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -72,7 +78,8 @@ func (msg *MsgGetData) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding)
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgGetData.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetData.BtcEncode", str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
@@ -15,8 +15,9 @@ import (
 // getheaders message.  It is used to request a list of block headers for
 // blocks starting after the last known hash in the slice of block locator
 // hashes.  The list is returned via a headers message (MsgHeaders) and is
-// limited by a specific hash to stop at or the maximum number of block headers
-// per message, which is currently 2000.
+// limited by a specific hash to stop at or the number of block headers served
+// per message, which is DefaultBlockHeadersPerMsg.  MsgHeaders.NextGetHeaders
+// builds the request for the following batch of headers.
 //
 // Set the HashStop field to the hash at which to stop and use
 // AddBlockLocatorHash to build up the list of block locator hashes.
@@ -38,7 +39,8 @@ func (msg *MsgGetHeaders) AddBlockLocatorHash(hash *chainhash.Hash) error {
 	if len(msg.BlockLocatorHashes)+1 > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message [max %v]",
 			MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.AddBlockLocatorHash", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetHeaders.AddBlockLocatorHash", str)
 	}
 
 	msg.BlockLocatorHashes = append(msg.BlockLocatorHashes, hash)
@@ -61,7 +63,12 @@ func (msg *MsgGetHeaders) BtcDecode(r io.Reader, pver uint32, enc MessageEncodin
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetHeaders.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, chainhash.HashSize)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -88,7 +95,8 @@ func (msg *MsgGetHeaders) BtcEncode(w io.Writer, pver uint32, enc MessageEncodin
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgGetHeaders.BtcEncode", str)
 	}
 
 	err := writeElement(w, msg.ProtocolVersion)
@@ -126,6 +134,22 @@ func (msg *MsgGetHeaders) MaxPayloadLength(pver uint32) uint32 {
 		chainhash.HashSize) + chainhash.HashSize
 }
 
+// NewMsgGetHeadersFromLocator returns a new bitcoin getheaders message which
+// requests the headers after the first known hash in the passed block locator
+// up to the provided stop hash.  An error is returned when the locator has
+// more than MaxBlockLocatorsPerMsg hashes.
+func NewMsgGetHeadersFromLocator(locator []*chainhash.Hash, hashStop *chainhash.Hash) (*MsgGetHeaders, error) {
+	msg := NewMsgGetHeaders()
+	msg.HashStop = *hashStop
+	for _, hash := range locator {
+		err := msg.AddBlockLocatorHash(hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
 // NewMsgGetHeaders returns a new bitcoin getheaders message that conforms to
 // the Message interface.  See MsgGetHeaders for details.
 func NewMsgGetHeaders() *MsgGetHeaders {
@@ -67,6 +67,36 @@ func TestGetHeaders(t *testing.T) {
 	}
 }
 
+// TestNewMsgGetHeadersFromLocator tests creating a getheaders message from a
+// block locator.
+func TestNewMsgGetHeadersFromLocator(t *testing.T) {
+	hashStop := chainhash.Hash{0x01}
+	locator := []*chainhash.Hash{&chainhash.Hash{0x02}, &mainNetGenesisHash}
+	msg, err := NewMsgGetHeadersFromLocator(locator, &hashStop)
+	if err != nil {
+		t.Fatalf("NewMsgGetHeadersFromLocator: %v", err)
+	}
+	if !reflect.DeepEqual(msg.BlockLocatorHashes, locator) {
+		t.Errorf("NewMsgGetHeadersFromLocator: wrong locator - got %v, "+
+			"want %v", msg.BlockLocatorHashes, locator)
+	}
+	if msg.HashStop != hashStop {
+		t.Errorf("NewMsgGetHeadersFromLocator: wrong stop hash - got "+
+			"%v, want %v", msg.HashStop, hashStop)
+	}
+
+	// Ensure locators with too many hashes are rejected.
+	locator = make([]*chainhash.Hash, MaxBlockLocatorsPerMsg+1)
+	for i := range locator {
+		locator[i] = &mainNetGenesisHash
+	}
+	_, err = NewMsgGetHeadersFromLocator(locator, &hashStop)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("NewMsgGetHeadersFromLocator: wrong error - got %v "+
+			"<%T>, want <%T>", err, err, &MessageError{})
+	}
+}
+
 // TestGetHeadersWire tests the MsgGetHeaders wire encode and decode for various
 // numbers of block locator hashes and protocol versions.
 func TestGetHeadersWire(t *testing.T) {
@@ -7,17 +7,29 @@ package wire
 import (
 	"fmt"
 	"io"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
 
-// MaxBlockHeadersPerMsg is the maximum number of block headers that can be in
-// a single bitcoin headers message.
-const MaxBlockHeadersPerMsg = 2000
+const (
+	// DefaultBlockHeadersPerMsg is the number of block headers served in
+	// response to a single getheaders message.  Peers running the
+	// reference implementation reject headers messages with more headers
+	// than this, so it must not be raised without a protocol change.
+	DefaultBlockHeadersPerMsg = 2000
+
+	// MaxBlockHeadersPerMsg is the maximum number of block headers that
+	// can be in a single bitcoin headers message.  It is larger than
+	// DefaultBlockHeadersPerMsg so that peers which serve bigger batches
+	// during headers-first sync are not rejected.
+	MaxBlockHeadersPerMsg = 8000
+)
 
 // MsgHeaders implements the Message interface and represents a bitcoin headers
 // message.  It is used to deliver block header information in response
 // to a getheaders message (MsgGetHeaders).  The maximum number of block headers
-// per message is currently 2000.  See MsgGetHeaders for details on requesting
-// the headers.
+// per message is MaxBlockHeadersPerMsg.  See MsgGetHeaders for details on
+// requesting the headers.
 type MsgHeaders struct {
 	Headers []*BlockHeader
 }
@@ -27,13 +39,39 @@ func (msg *MsgHeaders) AddBlockHeader(bh *BlockHeader) error {
 	if len(msg.Headers)+1 > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers in message [max %v]",
 			MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.AddBlockHeader", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgHeaders.AddBlockHeader", str)
 	}
 
 	msg.Headers = append(msg.Headers, bh)
 	return nil
 }
 
+// NextGetHeaders returns the getheaders message which requests the headers
+// following the final header of the receiver up to the provided stop hash, or
+// nil when the receiver is the last page of headers.  The receiver is the last
+// page when it is empty, when its final header is the stop hash, or when the
+// stop hash is the zero hash (meaning as many headers as possible) and it holds
+// fewer than DefaultBlockHeadersPerMsg headers.
+func (msg *MsgHeaders) NextGetHeaders(hashStop *chainhash.Hash) *MsgGetHeaders {
+	count := len(msg.Headers)
+	if count == 0 {
+		return nil
+	}
+	finalHash := msg.Headers[count-1].BlockHash()
+	if finalHash.IsEqual(hashStop) {
+		return nil
+	}
+	if *hashStop == (chainhash.Hash{}) && count < DefaultBlockHeadersPerMsg {
+		return nil
+	}
+
+	next := NewMsgGetHeaders()
+	next.HashStop = *hashStop
+	next.AddBlockLocatorHash(&finalHash)
+	return next
+}
+
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgHeaders) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
@@ -46,7 +84,12 @@ func (msg *MsgHeaders) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding)
 	if count > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers for message "+
 			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgHeaders.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, MaxBlockHeaderPayload+1)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of headers to deserialize into in order to
@@ -85,7 +128,8 @@ func (msg *MsgHeaders) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding)
 	if count > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers for message "+
 			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgHeaders.BtcEncode", str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
@@ -130,7 +174,21 @@ func (msg *MsgHeaders) MaxPayloadLength(pver uint32) uint32 {
 // NewMsgHeaders returns a new bitcoin headers message that conforms to the
 // Message interface.  See MsgHeaders for details.
 func NewMsgHeaders() *MsgHeaders {
+	return NewMsgHeadersCap(DefaultBlockHeadersPerMsg)
+}
+
+// NewMsgHeadersCap returns a new bitcoin headers message that conforms to the
+// Message interface with room for the given number of headers before its
+// header slice needs to grow.  The capacity is limited to
+// MaxBlockHeadersPerMsg.  See MsgHeaders for details.
+func NewMsgHeadersCap(capacity int) *MsgHeaders {
+	if capacity > MaxBlockHeadersPerMsg {
+		capacity = MaxBlockHeadersPerMsg
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
 	return &MsgHeaders{
-		Headers: make([]*BlockHeader, 0, MaxBlockHeadersPerMsg),
+		Headers: make([]*BlockHeader, 0, capacity),
 	}
 }
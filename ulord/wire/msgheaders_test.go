@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
 
 // TestHeaders tests the MsgHeaders API.
@@ -28,7 +29,7 @@ func TestHeaders(t *testing.T) {
 	// Ensure max payload is expected value for latest protocol version.
 	// Num headers (varInt) + max allowed headers (header length + 1 byte
 	// for the number of transactions which is always 0).
-	wantPayload := uint32(162009)
+	wantPayload := uint32(648009)
 	maxPayload := msg.MaxPayloadLength(pver)
 	if maxPayload != wantPayload {
 		t.Errorf("MaxPayloadLength: wrong max payload length for "+
@@ -57,6 +58,83 @@ func TestHeaders(t *testing.T) {
 	}
 }
 
+// TestHeadersNextGetHeaders tests building the getheaders message which
+// requests the page of headers following a headers message.
+func TestHeadersNextGetHeaders(t *testing.T) {
+	bh := &blockOne.Header
+	bhHash := bh.BlockHash()
+	stopHash := chainhash.Hash{0x01}
+
+	page := func(count int) *MsgHeaders {
+		msg := NewMsgHeaders()
+		for i := 0; i < count; i++ {
+			msg.AddBlockHeader(bh)
+		}
+		return msg
+	}
+
+	tests := []struct {
+		name     string
+		msg      *MsgHeaders // Headers message to page from
+		hashStop chainhash.Hash
+		wantNext bool // Whether a further page is expected
+	}{
+		{"empty", page(0), stopHash, false},
+		{"reached stop", page(3), bhHash, false},
+		{"partial page to stop hash", page(3), stopHash, true},
+		{"partial page to tip", page(3), chainhash.Hash{}, false},
+		{"full page to tip", page(DefaultBlockHeadersPerMsg),
+			chainhash.Hash{}, true},
+	}
+
+	for _, test := range tests {
+		next := test.msg.NextGetHeaders(&test.hashStop)
+		if (next != nil) != test.wantNext {
+			t.Errorf("%s: got next %v, want next %v", test.name,
+				next != nil, test.wantNext)
+			continue
+		}
+		if next == nil {
+			continue
+		}
+		if next.HashStop != test.hashStop {
+			t.Errorf("%s: wrong stop hash - got %v, want %v",
+				test.name, next.HashStop, test.hashStop)
+		}
+		if len(next.BlockLocatorHashes) != 1 ||
+			*next.BlockLocatorHashes[0] != bhHash {
+			t.Errorf("%s: wrong block locator - got %v, want [%v]",
+				test.name, next.BlockLocatorHashes, bhHash)
+		}
+	}
+}
+
+// TestNewMsgHeadersCap tests that the capacity of new headers messages is
+// limited to the maximum number of headers per message.
+func TestNewMsgHeadersCap(t *testing.T) {
+	tests := []struct {
+		in   int // Requested capacity
+		want int // Expected capacity
+	}{
+		{-1, 0},
+		{0, 0},
+		{100, 100},
+		{MaxBlockHeadersPerMsg + 1, MaxBlockHeadersPerMsg},
+	}
+
+	for i, test := range tests {
+		got := cap(NewMsgHeadersCap(test.in).Headers)
+		if got != test.want {
+			t.Errorf("NewMsgHeadersCap #%d: got %d, want %d", i, got,
+				test.want)
+		}
+	}
+	if got := cap(NewMsgHeaders().Headers); got != DefaultBlockHeadersPerMsg {
+		t.Errorf("NewMsgHeaders: got capacity %d, want %d", got,
+			DefaultBlockHeadersPerMsg)
+	}
+}
+
 // TestHeadersWire tests the MsgHeaders wire encode and decode for various
 // numbers of headers and protocol versions.
 func TestHeadersWire(t *testing.T) {
@@ -264,7 +342,7 @@ func TestHeadersWireErrors(t *testing.T) {
 	}
 	maxHeaders.Headers = append(maxHeaders.Headers, bh)
 	maxHeadersEncoded := []byte{
-		0xfd, 0xd1, 0x07, // Varint for number of addresses (2001)7D1
+		0xfd, 0x41, 0x1f, // Varint for number of headers (8001)
 	}
 
 	// Intentionally invalid block header that has a transaction count used
@@ -36,7 +36,8 @@ func (msg *MsgInv) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgInv.AddInvVect", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgInv.AddInvVect", str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -54,7 +55,12 @@ func (msg *MsgInv) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) erro
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgInv.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgInv.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, maxInvVectPayload)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -80,7 +86,8 @@ func (msg *MsgInv) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) erro
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgInv.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgInv.BtcEncode", str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
@@ -23,7 +23,8 @@ func (msg *MsgMemPool) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding)
 	if pver < BIP0035Version {
 		str := fmt.Sprintf("mempool message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgMemPool.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMemPool.BtcDecode", str)
 	}
 
 	return nil
@@ -35,7 +36,8 @@ func (msg *MsgMemPool) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding)
 	if pver < BIP0035Version {
 		str := fmt.Sprintf("mempool message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgMemPool.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMemPool.BtcEncode", str)
 	}
 
 	return nil
@@ -33,7 +33,8 @@ func (msg *MsgMerkleBlock) AddTxHash(hash *chainhash.Hash) error {
 	if len(msg.Hashes)+1 > maxTxPerBlock {
 		str := fmt.Sprintf("too many tx hashes for message [max %v]",
 			maxTxPerBlock)
-		return messageError("MsgMerkleBlock.AddTxHash", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgMerkleBlock.AddTxHash", str)
 	}
 
 	msg.Hashes = append(msg.Hashes, hash)
@@ -46,7 +47,8 @@ func (msg *MsgMerkleBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncodi
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("merkleblock message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMerkleBlock.BtcDecode", str)
 	}
 
 	err := readBlockHeader(r, pver, &msg.Header)
@@ -67,7 +69,12 @@ func (msg *MsgMerkleBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncodi
 	if count > maxTxPerBlock {
 		str := fmt.Sprintf("too many transaction hashes for message "+
 			"[count %v, max %v]", count, maxTxPerBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgMerkleBlock.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, chainhash.HashSize)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -94,7 +101,8 @@ func (msg *MsgMerkleBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncodi
 	if pver < BIP0037Version {
 		str := fmt.Sprintf("merkleblock message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgMerkleBlock.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMerkleBlock.BtcEncode", str)
 	}
 
 	// Read num transaction hashes and limit to max.
@@ -102,13 +110,15 @@ func (msg *MsgMerkleBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncodi
 	if numHashes > maxTxPerBlock {
 		str := fmt.Sprintf("too many transaction hashes for message "+
 			"[count %v, max %v]", numHashes, maxTxPerBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgMerkleBlock.BtcDecode", str)
 	}
 	numFlagBytes := len(msg.Flags)
 	if numFlagBytes > maxFlagsPerMerkleBlock {
 		str := fmt.Sprintf("too many flag bytes for message [count %v, "+
 			"max %v]", numFlagBytes, maxFlagsPerMerkleBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgMerkleBlock.BtcDecode", str)
 	}
 
 	err := writeBlockHeader(w, pver, &msg.Header)
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// MaxMasternodePubKeySize is the maximum number of bytes of the public keys
+// carried by the masternode broadcast message, which is the size of an
+// uncompressed public key.
+const MaxMasternodePubKeySize = 65
+
+// MsgMNBroadcast implements the Message interface and represents a Ulord mnb
+// message.  It announces a masternode to the network, binding its collateral
+// output and network address to the key it signs pings and votes with.
+//
+// This message was not added until protocol version MasternodeVersion.
+type MsgMNBroadcast struct {
+	// Outpoint is the collateral output identifying the masternode.
+	Outpoint OutPoint
+
+	// Addr is the network address the masternode is reachable at.  Only
+	// the IP and port are encoded.
+	Addr NetAddress
+
+	// CollateralPubKey is the serialized public key controlling the
+	// collateral output and MasternodePubKey is the serialized public key
+	// the masternode signs its pings and votes with.
+	CollateralPubKey []byte
+	MasternodePubKey []byte
+
+	// Signature is the signature of the broadcast by the collateral key.
+	Signature []byte
+
+	// SigTime is the unix time the broadcast was signed at.
+	SigTime int64
+
+	// ProtocolVersion is the protocol version the masternode runs.
+	ProtocolVersion int32
+
+	// LastPing is the most recent ping of the masternode.
+	LastPing MsgMNPing
+}
+
+// readServiceAddr reads the IP and port of a network address, which are
+// encoded without the services and timestamp of a NetAddress.
+func readServiceAddr(r io.Reader, na *NetAddress) error {
+	var ip [16]byte
+	if _, err := io.ReadFull(r, ip[:]); err != nil {
+		return err
+	}
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddress{IP: net.IP(ip[:]), Port: port}
+	return nil
+}
+
+// writeServiceAddr writes the IP and port of a network address.
+func writeServiceAddr(w io.Writer, na *NetAddress) error {
+	// Ensure to always write 16 bytes even if the ip is nil.
+	var ip [16]byte
+	if na.IP != nil {
+		copy(ip[:], na.IP.To16())
+	}
+	if _, err := w.Write(ip[:]); err != nil {
+		return err
+	}
+	return binary.Write(w, bigEndian, na.Port)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgMNBroadcast) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("mnb message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMNBroadcast.BtcDecode", str)
+	}
+
+	err := readOutPoint(r, pver, 0, &msg.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = readServiceAddr(r, &msg.Addr)
+	if err != nil {
+		return err
+	}
+	msg.CollateralPubKey, err = ReadVarBytes(r, pver,
+		MaxMasternodePubKeySize, "mnb collateral public key")
+	if err != nil {
+		return err
+	}
+	msg.MasternodePubKey, err = ReadVarBytes(r, pver,
+		MaxMasternodePubKeySize, "mnb masternode public key")
+	if err != nil {
+		return err
+	}
+	msg.Signature, err = ReadVarBytes(r, pver, MaxMasternodeSigSize,
+		"mnb signature")
+	if err != nil {
+		return err
+	}
+	err = readElements(r, &msg.SigTime, &msg.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	return readMNPing(r, pver, &msg.LastPing)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgMNBroadcast) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("mnb message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMNBroadcast.BtcEncode", str)
+	}
+
+	for _, pubKey := range [][]byte{msg.CollateralPubKey, msg.MasternodePubKey} {
+		if len(pubKey) > MaxMasternodePubKeySize {
+			str := fmt.Sprintf("mnb public key too large for "+
+				"message [size %v, max %v]", len(pubKey),
+				MaxMasternodePubKeySize)
+			return messageErrorCode(ErrMsgFieldTooLong,
+				"MsgMNBroadcast.BtcEncode", str)
+		}
+	}
+	if len(msg.Signature) > MaxMasternodeSigSize {
+		str := fmt.Sprintf("mnb signature too large for message "+
+			"[size %v, max %v]", len(msg.Signature),
+			MaxMasternodeSigSize)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgMNBroadcast.BtcEncode", str)
+	}
+
+	err := writeOutPoint(w, pver, 0, &msg.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = writeServiceAddr(w, &msg.Addr)
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, msg.CollateralPubKey)
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, msg.MasternodePubKey)
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, msg.Signature)
+	if err != nil {
+		return err
+	}
+	err = writeElements(w, msg.SigTime, msg.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	return writeMNPing(w, pver, &msg.LastPing)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgMNBroadcast) Command() string {
+	return CmdMNBroadcast
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgMNBroadcast) MaxPayloadLength(pver uint32) uint32 {
+	// Collateral outpoint (hash + 4 bytes index) + 16 bytes IP + 2 bytes
+	// port + 2 public keys (varInt + key) + num signature bytes (varInt)
+	// + signature + 8 bytes signature time + 4 bytes protocol version +
+	// last ping.
+	return chainhash.HashSize + 4 + 18 +
+		2*(uint32(VarIntSerializeSize(MaxMasternodePubKeySize))+
+			MaxMasternodePubKeySize) +
+		uint32(VarIntSerializeSize(MaxMasternodeSigSize)) +
+		MaxMasternodeSigSize + 12 + maxMNPingPayload
+}
+
+// NewMsgMNBroadcast returns a new Ulord mnb message that conforms to the
+// Message interface.  See MsgMNBroadcast for details.
+func NewMsgMNBroadcast(outpoint *OutPoint, addr *NetAddress, collateralPubKey, masternodePubKey []byte) *MsgMNBroadcast {
+	return &MsgMNBroadcast{
+		Outpoint:         *outpoint,
+		Addr:             NetAddress{IP: addr.IP, Port: addr.Port},
+		CollateralPubKey: collateralPubKey,
+		MasternodePubKey: masternodePubKey,
+		ProtocolVersion:  int32(ProtocolVersion),
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// TestMNBroadcast tests the MsgMNBroadcast API against the latest protocol version.
+func TestMNBroadcast(t *testing.T) {
+	pver := ProtocolVersion
+	msg := NewMsgMNBroadcast(&OutPoint{}, &NetAddress{}, nil, nil)
+
+	// Ensure the command is expected value.
+	wantCmd := "mnb"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgMNBroadcast: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(429)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure a message with the largest allowed fields fits in the max
+	// payload.
+	msg.CollateralPubKey = make([]byte, MaxMasternodePubKeySize)
+	msg.MasternodePubKey = make([]byte, MaxMasternodePubKeySize)
+	msg.Signature = make([]byte, MaxMasternodeSigSize)
+	msg.LastPing.Signature = make([]byte, MaxMasternodeSigSize)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if uint32(buf.Len()) != maxPayload {
+		t.Errorf("BtcEncode: largest message is %d bytes, want %d",
+			buf.Len(), maxPayload)
+	}
+}
+
+// TestMNBroadcastWire tests the MsgMNBroadcast wire encode and decode for various
+// protocol versions.
+func TestMNBroadcastWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgMNBroadcast // Message to encode
+		out  *MsgMNBroadcast // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{baseMNBroadcast, baseMNBroadcast, baseMNBroadcastEncoded, ProtocolVersion},
+
+		// Protocol version MasternodeVersion.
+		{baseMNBroadcast, baseMNBroadcast, baseMNBroadcastEncoded, MasternodeVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgMNBroadcast
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestMNBroadcastWireErrors performs negative tests against wire encode and
+// decode of MsgMNBroadcast to confirm error paths work correctly.
+func TestMNBroadcastWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoMasternode := MasternodeVersion - 1
+	wireErr := &MessageError{}
+
+	// Public key larger than the max allowed size.
+	bigKey := *baseMNBroadcast
+	bigKey.MasternodePubKey = make([]byte, MaxMasternodePubKeySize+1)
+	bigKeyEncoded := append([]byte{}, baseMNBroadcastEncoded[:57]...)
+	bigKeyEncoded = append(bigKeyEncoded, MaxMasternodePubKeySize+1)
+
+	// Signature larger than the max allowed size.
+	bigSig := *baseMNBroadcast
+	bigSig.Signature = make([]byte, MaxMasternodeSigSize+1)
+	bigSigEncoded := append([]byte{}, baseMNBroadcastEncoded[:60]...)
+	bigSigEncoded = append(bigSigEncoded, MaxMasternodeSigSize+1)
+
+	tests := []struct {
+		in       *MsgMNBroadcast // Value to encode
+		buf      []byte          // Wire encoding
+		pver     uint32          // Protocol version for wire encoding
+		max      int             // Max size of fixed buffer to induce errors
+		writeErr error           // Expected write error
+		readErr  error           // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in outpoint.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in IP.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 36, io.ErrShortWrite, io.EOF},
+		// Force error in port.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 52, io.ErrShortWrite, io.EOF},
+		// Force error in collateral public key.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 54, io.ErrShortWrite, io.EOF},
+		// Force error in masternode public key.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 57, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 60, io.ErrShortWrite, io.EOF},
+		// Force error in signature time.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 62, io.ErrShortWrite, io.EOF},
+		// Force error in protocol version.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 70, io.ErrShortWrite, io.EOF},
+		// Force error in last ping.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pver, 74, io.ErrShortWrite, io.EOF},
+		// Force error with public key too large.
+		{&bigKey, bigKeyEncoded, pver, len(bigKeyEncoded), wireErr, wireErr},
+		// Force error with signature too large.
+		{&bigSig, bigSigEncoded, pver, len(bigSigEncoded), wireErr, wireErr},
+		// Force error due to unsupported protocol version.
+		{baseMNBroadcast, baseMNBroadcastEncoded, pverNoMasternode, len(baseMNBroadcastEncoded), wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgMNBroadcast
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
+
+// baseMNBroadcast is used in the various tests as a baseline
+// MsgMNBroadcast.
+var baseMNBroadcast = &MsgMNBroadcast{
+	Outpoint:         OutPoint{Hash: chainhash.Hash{0x07}, Index: 1},
+	Addr:             NetAddress{IP: net.ParseIP("127.0.0.1"), Port: 9888},
+	CollateralPubKey: []byte{0x02, 0x11},
+	MasternodePubKey: []byte{0x03, 0x22},
+	Signature:        []byte{0xdd},
+	SigTime:          0x5a000001,
+	ProtocolVersion:  70013,
+	LastPing:         *baseMNPing,
+}
+
+// baseMNBroadcastEncoded is the wire encoded bytes for baseMNBroadcast.
+var baseMNBroadcastEncoded = []byte{
+	0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff,
+	0x7f, 0x00, 0x00, 0x01, 0x26, 0xa0, 0x02, 0x02,
+	0x11, 0x02, 0x03, 0x22, 0x01, 0xdd, 0x01, 0x00,
+	0x00, 0x5a, 0x00, 0x00, 0x00, 0x00, 0x7d, 0x11,
+	0x01, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x5a, 0x00, 0x00, 0x00, 0x00, 0x01, 0xcc,
+	0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x02, 0x00,
+	0x00,
+}
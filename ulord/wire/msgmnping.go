@@ -0,0 +1,142 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// maxMNPingPayload is the maximum number of bytes of an encoded masternode
+// ping.  It is the collateral outpoint (hash + 4 bytes index) + block hash +
+// 8 bytes signature time + num signature bytes (varInt) + signature + 1 byte
+// sentinel state + 4 bytes sentinel version + 4 bytes daemon version.
+const maxMNPingPayload = chainhash.HashSize + 4 + chainhash.HashSize + 8 +
+	1 + MaxMasternodeSigSize + 1 + 4 + 4
+
+// MsgMNPing implements the Message interface and represents a Ulord mnp
+// message.  Masternodes periodically broadcast a signed ping referencing a
+// recent block to prove they are still online.  The most recent ping of a
+// masternode is also embedded in its MsgMNBroadcast.
+//
+// This message was not added until protocol version MasternodeVersion.
+type MsgMNPing struct {
+	// Outpoint is the collateral output identifying the masternode.
+	Outpoint OutPoint
+
+	// BlockHash is the hash of a recent block, which proves the ping was
+	// not created before it.
+	BlockHash chainhash.Hash
+
+	// SigTime is the unix time the ping was signed at.
+	SigTime int64
+
+	// Signature is the signature of the ping by the masternode key.
+	Signature []byte
+
+	// SentinelCurrent and SentinelVersion report the state of the
+	// sentinel run alongside the masternode.
+	SentinelCurrent bool
+	SentinelVersion uint32
+
+	// DaemonVersion is the client version of the masternode.
+	DaemonVersion uint32
+}
+
+// readMNPing reads an encoded masternode ping from r into ping.
+func readMNPing(r io.Reader, pver uint32, ping *MsgMNPing) error {
+	err := readOutPoint(r, pver, 0, &ping.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = readElements(r, &ping.BlockHash, &ping.SigTime)
+	if err != nil {
+		return err
+	}
+	ping.Signature, err = ReadVarBytes(r, pver, MaxMasternodeSigSize,
+		"masternode ping signature")
+	if err != nil {
+		return err
+	}
+	return readElements(r, &ping.SentinelCurrent, &ping.SentinelVersion,
+		&ping.DaemonVersion)
+}
+
+// writeMNPing encodes ping to w.
+func writeMNPing(w io.Writer, pver uint32, ping *MsgMNPing) error {
+	if len(ping.Signature) > MaxMasternodeSigSize {
+		str := fmt.Sprintf("masternode ping signature too large for "+
+			"message [size %v, max %v]", len(ping.Signature),
+			MaxMasternodeSigSize)
+		return messageErrorCode(ErrMsgFieldTooLong, "writeMNPing", str)
+	}
+
+	err := writeOutPoint(w, pver, 0, &ping.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = writeElements(w, &ping.BlockHash, ping.SigTime)
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, ping.Signature)
+	if err != nil {
+		return err
+	}
+	return writeElements(w, ping.SentinelCurrent, ping.SentinelVersion,
+		ping.DaemonVersion)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgMNPing) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("mnp message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMNPing.BtcDecode", str)
+	}
+
+	return readMNPing(r, pver, msg)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgMNPing) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("mnp message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgMNPing.BtcEncode", str)
+	}
+
+	return writeMNPing(w, pver, msg)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgMNPing) Command() string {
+	return CmdMNPing
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgMNPing) MaxPayloadLength(pver uint32) uint32 {
+	return maxMNPingPayload
+}
+
+// NewMsgMNPing returns a new Ulord mnp message that conforms to the Message
+// interface using the passed parameters and defaults for the remaining
+// fields.  See MsgMNPing for details.
+func NewMsgMNPing(outpoint *OutPoint, blockHash *chainhash.Hash, sigTime int64, signature []byte) *MsgMNPing {
+	return &MsgMNPing{
+		Outpoint:  *outpoint,
+		BlockHash: *blockHash,
+		SigTime:   sigTime,
+		Signature: signature,
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// TestMNPing tests the MsgMNPing API against the latest protocol version.
+func TestMNPing(t *testing.T) {
+	pver := ProtocolVersion
+	msg := NewMsgMNPing(&OutPoint{}, &chainhash.Hash{}, 0, nil)
+
+	// Ensure the command is expected value.
+	wantCmd := "mnp"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgMNPing: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(158)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure a message with the largest allowed fields fits in the max
+	// payload.
+	msg.Signature = make([]byte, MaxMasternodeSigSize)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if uint32(buf.Len()) != maxPayload {
+		t.Errorf("BtcEncode: largest message is %d bytes, want %d",
+			buf.Len(), maxPayload)
+	}
+}
+
+// TestMNPingWire tests the MsgMNPing wire encode and decode for various
+// protocol versions.
+func TestMNPingWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgMNPing // Message to encode
+		out  *MsgMNPing // Expected decoded message
+		buf  []byte     // Wire encoding
+		pver uint32     // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{baseMNPing, baseMNPing, baseMNPingEncoded, ProtocolVersion},
+
+		// Protocol version MasternodeVersion.
+		{baseMNPing, baseMNPing, baseMNPingEncoded, MasternodeVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgMNPing
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestMNPingWireErrors performs negative tests against wire encode and
+// decode of MsgMNPing to confirm error paths work correctly.
+func TestMNPingWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoMasternode := MasternodeVersion - 1
+	wireErr := &MessageError{}
+
+	// Signature larger than the max allowed size.
+	bigSig := *baseMNPing
+	bigSig.Signature = make([]byte, MaxMasternodeSigSize+1)
+	bigSigEncoded := append([]byte{}, baseMNPingEncoded[:76]...)
+	bigSigEncoded = append(bigSigEncoded, MaxMasternodeSigSize+1)
+
+	tests := []struct {
+		in       *MsgMNPing // Value to encode
+		buf      []byte     // Wire encoding
+		pver     uint32     // Protocol version for wire encoding
+		max      int        // Max size of fixed buffer to induce errors
+		writeErr error      // Expected write error
+		readErr  error      // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in outpoint.
+		{baseMNPing, baseMNPingEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in block hash.
+		{baseMNPing, baseMNPingEncoded, pver, 36, io.ErrShortWrite, io.EOF},
+		// Force error in signature time.
+		{baseMNPing, baseMNPingEncoded, pver, 68, io.ErrShortWrite, io.EOF},
+		// Force error in signature length.
+		{baseMNPing, baseMNPingEncoded, pver, 76, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseMNPing, baseMNPingEncoded, pver, 77, io.ErrShortWrite, io.EOF},
+		// Force error in sentinel state.
+		{baseMNPing, baseMNPingEncoded, pver, 78, io.ErrShortWrite, io.EOF},
+		// Force error in sentinel version.
+		{baseMNPing, baseMNPingEncoded, pver, 79, io.ErrShortWrite, io.EOF},
+		// Force error in daemon version.
+		{baseMNPing, baseMNPingEncoded, pver, 83, io.ErrShortWrite, io.EOF},
+		// Force error with signature too large.
+		{&bigSig, bigSigEncoded, pver, len(bigSigEncoded), wireErr, wireErr},
+		// Force error due to unsupported protocol version.
+		{baseMNPing, baseMNPingEncoded, pverNoMasternode, len(baseMNPingEncoded), wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgMNPing
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
+
+// baseMNPing is used in the various tests as a baseline MsgMNPing.
+var baseMNPing = &MsgMNPing{
+	Outpoint:        OutPoint{Hash: chainhash.Hash{0x05}, Index: 0},
+	BlockHash:       chainhash.Hash{0x06},
+	SigTime:         0x5a000000,
+	Signature:       []byte{0xcc},
+	SentinelCurrent: true,
+	SentinelVersion: 0x010000,
+	DaemonVersion:   0x0201,
+}
+
+// baseMNPingEncoded is the wire encoded bytes for baseMNPing.
+var baseMNPingEncoded = []byte{
+	0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x5a,
+	0x00, 0x00, 0x00, 0x00, 0x01, 0xcc, 0x01, 0x00,
+	0x00, 0x01, 0x00, 0x01, 0x02, 0x00, 0x00,
+}
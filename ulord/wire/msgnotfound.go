@@ -25,7 +25,8 @@ func (msg *MsgNotFound) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgNotFound.AddInvVect", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgNotFound.AddInvVect", str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -43,7 +44,12 @@ func (msg *MsgNotFound) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding)
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgNotFound.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgNotFound.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, maxInvVectPayload)
+	if err != nil {
+		return err
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -69,7 +75,8 @@ func (msg *MsgNotFound) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding)
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgNotFound.BtcEncode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgNotFound.BtcEncode", str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
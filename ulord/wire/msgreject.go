@@ -7,6 +7,7 @@ package wire
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
@@ -48,6 +49,87 @@ func (code RejectCode) String() string {
 	return fmt.Sprintf("Unknown RejectCode (%d)", uint8(code))
 }
 
+// RejectReason is a machine-readable cause of a rejection which is more
+// precise than the reject code, such as "insufficient-fee" or
+// "bad-merkle-root".  Reasons consist of lowercase letters, digits and dashes
+// and are sent as the leading token of the reason string of a reject message
+// so that peers which do not know about them still see a readable message.
+type RejectReason string
+
+// These constants define the generic reject reasons of the reject codes.
+// They are used when no more precise reason is known.
+const (
+	RejectReasonMalformed       RejectReason = "malformed"
+	RejectReasonInvalid         RejectReason = "invalid"
+	RejectReasonObsolete        RejectReason = "obsolete"
+	RejectReasonDuplicate       RejectReason = "duplicate"
+	RejectReasonNonstandard     RejectReason = "non-standard"
+	RejectReasonDust            RejectReason = "dust"
+	RejectReasonInsufficientFee RejectReason = "insufficient-fee"
+	RejectReasonCheckpoint      RejectReason = "checkpoint"
+)
+
+// Map of reject codes to their generic reject reasons.
+var rejectCodeReasons = map[RejectCode]RejectReason{
+	RejectMalformed:       RejectReasonMalformed,
+	RejectInvalid:         RejectReasonInvalid,
+	RejectObsolete:        RejectReasonObsolete,
+	RejectDuplicate:       RejectReasonDuplicate,
+	RejectNonstandard:     RejectReasonNonstandard,
+	RejectDust:            RejectReasonDust,
+	RejectInsufficientFee: RejectReasonInsufficientFee,
+	RejectCheckpoint:      RejectReasonCheckpoint,
+}
+
+// Reason returns the generic reject reason of the RejectCode, which is
+// RejectReasonInvalid for unknown codes.
+func (code RejectCode) Reason() RejectReason {
+	if r, ok := rejectCodeReasons[code]; ok {
+		return r
+	}
+	return RejectReasonInvalid
+}
+
+// IsValid returns whether the reject reason is a non-empty token of lowercase
+// letters, digits and dashes.
+func (r RejectReason) IsValid() bool {
+	if r == "" {
+		return false
+	}
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatRejectReason returns the reason string of a reject message carrying
+// the passed machine-readable reason and human-readable detail, which is the
+// reason followed by a colon and the detail, or just the reason when the
+// detail is empty.
+func FormatRejectReason(reason RejectReason, detail string) string {
+	if detail == "" {
+		return string(reason)
+	}
+	return string(reason) + ": " + detail
+}
+
+// parseRejectReason splits a reason string formatted by FormatRejectReason
+// into its machine-readable reason and detail.  Strings sent by peers which
+// do not start with a valid reject reason are returned as the detail.
+func parseRejectReason(s string) (RejectReason, string) {
+	token, detail := s, ""
+	if i := strings.Index(s, ": "); i >= 0 {
+		token, detail = s[:i], s[i+2:]
+	}
+	if reason := RejectReason(token); reason.IsValid() {
+		return reason, detail
+	}
+	return "", s
+}
+
 // MsgReject implements the Message interface and represents a bitcoin reject
 // message.
 //
@@ -77,11 +159,13 @@ func (msg *MsgReject) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) e
 	if pver < RejectVersion {
 		str := fmt.Sprintf("reject message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgReject.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgReject.BtcDecode", str)
 	}
 
-	// Command that was rejected.
-	cmd, err := ReadVarString(r, pver)
+	// Command that was rejected.  Commands are never longer than the
+	// fixed size of the command field of the message header.
+	cmd, err := ReadVarStringMax(r, pver, CommandSize, "rejected command")
 	if err != nil {
 		return err
 	}
@@ -119,7 +203,8 @@ func (msg *MsgReject) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) e
 	if pver < RejectVersion {
 		str := fmt.Sprintf("reject message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgReject.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgReject.BtcEncode", str)
 	}
 
 	// Command that was rejected.
@@ -184,3 +269,26 @@ func NewMsgReject(command string, code RejectCode, reason string) *MsgReject {
 		Reason: reason,
 	}
 }
+
+// NewMsgRejectReason returns a new bitcoin reject message whose reason string
+// carries the passed machine-readable reason followed by the human-readable
+// detail.  See FormatRejectReason for details.
+func NewMsgRejectReason(command string, code RejectCode, reason RejectReason, detail string) *MsgReject {
+	return NewMsgReject(command, code, FormatRejectReason(reason, detail))
+}
+
+// RejectReason returns the machine-readable reason the reason string of the
+// message starts with, or an empty reason when it does not start with one,
+// such as for messages from peers that do not send reject reasons.
+func (msg *MsgReject) RejectReason() RejectReason {
+	reason, _ := parseRejectReason(msg.Reason)
+	return reason
+}
+
+// Detail returns the human-readable part of the reason string of the message
+// following its machine-readable reason.  The whole reason string is returned
+// when it does not start with a reject reason.
+func (msg *MsgReject) Detail() string {
+	_, detail := parseRejectReason(msg.Reason)
+	return detail
+}
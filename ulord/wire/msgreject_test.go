@@ -387,3 +387,65 @@ func TestRejectWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestRejectReason tests reject messages carrying machine-readable reject
+// reasons and the parsing of reason strings of peers that do not send them.
+func TestRejectReason(t *testing.T) {
+	if got := RejectInsufficientFee.Reason(); got != RejectReasonInsufficientFee {
+		t.Errorf("Reason: got %q, want %q", got, RejectReasonInsufficientFee)
+	}
+	if got := RejectCode(0xff).Reason(); got != RejectReasonInvalid {
+		t.Errorf("Reason: got %q for unknown code, want %q", got,
+			RejectReasonInvalid)
+	}
+
+	msg := NewMsgRejectReason(CmdTx, RejectInsufficientFee,
+		"min-relay-fee-not-met", "transaction has 0 fees")
+	if msg.Reason != "min-relay-fee-not-met: transaction has 0 fees" {
+		t.Errorf("NewMsgRejectReason: wrong reason string %q", msg.Reason)
+	}
+
+	tests := []struct {
+		in     string
+		reason RejectReason
+		detail string
+	}{
+		{"bad-merkle-root: block merkle root is invalid",
+			"bad-merkle-root", "block merkle root is invalid"},
+		{"duplicate", "duplicate", ""},
+		{"dupe block", "", "dupe block"},
+		{"Invalid: reason: with colons", "", "Invalid: reason: with colons"},
+		{"", "", ""},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		msg := NewMsgReject(CmdBlock, RejectInvalid, test.in)
+		if got := msg.RejectReason(); got != test.reason {
+			t.Errorf("RejectReason #%d: got %q, want %q", i, got,
+				test.reason)
+		}
+		if got := msg.Detail(); got != test.detail {
+			t.Errorf("Detail #%d: got %q, want %q", i, got,
+				test.detail)
+		}
+	}
+
+	// Ensure the reason survives a round trip over the wire.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, ProtocolVersion, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+	var decoded MsgReject
+	err = decoded.BtcDecode(&buf, ProtocolVersion, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode: %v", err)
+	}
+	if decoded.RejectReason() != "min-relay-fee-not-met" ||
+		decoded.Detail() != "transaction has 0 fees" {
+
+		t.Errorf("BtcDecode: got reason %q and detail %q",
+			decoded.RejectReason(), decoded.Detail())
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSendAddrV2 tests the MsgSendAddrV2 API.
+func TestSendAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "sendaddrv2"
+	msg := NewMsgSendAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != 0 {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want 0", maxPayload)
+	}
+
+	// The message has no payload.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Errorf("BtcEncode: unexpected error %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("BtcEncode: got %d bytes, want 0", buf.Len())
+	}
+	if err := msg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Errorf("BtcDecode: unexpected error %v", err)
+	}
+}
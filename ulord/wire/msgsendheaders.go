@@ -23,7 +23,8 @@ func (msg *MsgSendHeaders) BtcDecode(r io.Reader, pver uint32, enc MessageEncodi
 	if pver < SendHeadersVersion {
 		str := fmt.Sprintf("sendheaders message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgSendHeaders.BtcDecode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgSendHeaders.BtcDecode", str)
 	}
 
 	return nil
@@ -35,7 +36,8 @@ func (msg *MsgSendHeaders) BtcEncode(w io.Writer, pver uint32, enc MessageEncodi
 	if pver < SendHeadersVersion {
 		str := fmt.Sprintf("sendheaders message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgSendHeaders.BtcEncode", str)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgSendHeaders.BtcEncode", str)
 	}
 
 	return nil
@@ -0,0 +1,100 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxMasternodeSigSize is the maximum number of bytes of the signatures
+// carried by the masternode, transaction lock vote, and spork messages.  It is
+// large enough for both compact and DER encoded signatures.
+const MaxMasternodeSigSize = 72
+
+// MsgSpork implements the Message interface and represents a Ulord spork
+// message.  Sporks are network-wide feature switches signed by the spork key
+// of the network, and are relayed to all peers so new features can be enabled
+// or disabled without a new release.
+//
+// This message was not added until protocol version MasternodeVersion.
+type MsgSpork struct {
+	SporkID    int32
+	Value      int64
+	TimeSigned int64
+	Signature  []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSpork) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("spork message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgSpork.BtcDecode", str)
+	}
+
+	err := readElements(r, &msg.SporkID, &msg.Value, &msg.TimeSigned)
+	if err != nil {
+		return err
+	}
+
+	msg.Signature, err = ReadVarBytes(r, pver, MaxMasternodeSigSize,
+		"spork signature")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSpork) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("spork message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgSpork.BtcEncode", str)
+	}
+
+	if len(msg.Signature) > MaxMasternodeSigSize {
+		str := fmt.Sprintf("spork signature too large for message "+
+			"[size %v, max %v]", len(msg.Signature),
+			MaxMasternodeSigSize)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgSpork.BtcEncode", str)
+	}
+
+	err := writeElements(w, msg.SporkID, msg.Value, msg.TimeSigned)
+	if err != nil {
+		return err
+	}
+
+	return WriteVarBytes(w, pver, msg.Signature)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSpork) Command() string {
+	return CmdSpork
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSpork) MaxPayloadLength(pver uint32) uint32 {
+	// 4 bytes spork id + 8 bytes value + 8 bytes time signed +
+	// num signature bytes (varInt) + signature.
+	return 20 + uint32(VarIntSerializeSize(MaxMasternodeSigSize)) +
+		MaxMasternodeSigSize
+}
+
+// NewMsgSpork returns a new Ulord spork message that conforms to the Message
+// interface.  See MsgSpork for details.
+func NewMsgSpork(sporkID int32, value int64, timeSigned int64, signature []byte) *MsgSpork {
+	return &MsgSpork{
+		SporkID:    sporkID,
+		Value:      value,
+		TimeSigned: timeSigned,
+		Signature:  signature,
+	}
+}
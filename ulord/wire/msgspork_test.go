@@ -0,0 +1,186 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSpork tests the MsgSpork API against the latest protocol version.
+func TestSpork(t *testing.T) {
+	pver := ProtocolVersion
+	msg := NewMsgSpork(10001, 1, 0x5a000000, nil)
+
+	// Ensure the command is expected value.
+	wantCmd := "spork"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSpork: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(93)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure a message with the largest allowed fields fits in the max
+	// payload.
+	msg.Signature = make([]byte, MaxMasternodeSigSize)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if uint32(buf.Len()) != maxPayload {
+		t.Errorf("BtcEncode: largest message is %d bytes, want %d",
+			buf.Len(), maxPayload)
+	}
+}
+
+// TestSporkWire tests the MsgSpork wire encode and decode for various
+// protocol versions.
+func TestSporkWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgSpork // Message to encode
+		out  *MsgSpork // Expected decoded message
+		buf  []byte    // Wire encoding
+		pver uint32    // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{baseSpork, baseSpork, baseSporkEncoded, ProtocolVersion},
+
+		// Protocol version MasternodeVersion.
+		{baseSpork, baseSpork, baseSporkEncoded, MasternodeVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgSpork
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestSporkWireErrors performs negative tests against wire encode and
+// decode of MsgSpork to confirm error paths work correctly.
+func TestSporkWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoMasternode := MasternodeVersion - 1
+	wireErr := &MessageError{}
+
+	// Signature larger than the max allowed size.
+	bigSig := *baseSpork
+	bigSig.Signature = make([]byte, MaxMasternodeSigSize+1)
+	bigSigEncoded := append([]byte{}, baseSporkEncoded[:20]...)
+	bigSigEncoded = append(bigSigEncoded, MaxMasternodeSigSize+1)
+
+	tests := []struct {
+		in       *MsgSpork // Value to encode
+		buf      []byte    // Wire encoding
+		pver     uint32    // Protocol version for wire encoding
+		max      int       // Max size of fixed buffer to induce errors
+		writeErr error     // Expected write error
+		readErr  error     // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in spork id.
+		{baseSpork, baseSporkEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in value.
+		{baseSpork, baseSporkEncoded, pver, 4, io.ErrShortWrite, io.EOF},
+		// Force error in time signed.
+		{baseSpork, baseSporkEncoded, pver, 12, io.ErrShortWrite, io.EOF},
+		// Force error in signature length.
+		{baseSpork, baseSporkEncoded, pver, 20, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseSpork, baseSporkEncoded, pver, 21, io.ErrShortWrite, io.EOF},
+		// Force error with signature too large.
+		{&bigSig, bigSigEncoded, pver, len(bigSigEncoded), wireErr, wireErr},
+		// Force error due to unsupported protocol version.
+		{baseSpork, baseSporkEncoded, pverNoMasternode, len(baseSporkEncoded), wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgSpork
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
+
+// baseSpork is used in the various tests as a baseline MsgSpork.
+var baseSpork = NewMsgSpork(10001, 1, 0x5a000000, []byte{0x01, 0x02, 0x03})
+
+// baseSporkEncoded is the wire encoded bytes for baseSpork.
+var baseSporkEncoded = []byte{
+	0x11, 0x27, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x5a,
+	0x00, 0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03,
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"sync"
+	"time"
+)
+
+// MessageDirection identifies whether a message was read or written.
+type MessageDirection uint8
+
+const (
+	// MessageRead indicates a message read by ReadMessageWithHookN.
+	MessageRead MessageDirection = iota
+
+	// MessageWritten indicates a message written by WriteMessageWithHookN.
+	MessageWritten
+)
+
+// String returns the MessageDirection in human-readable form.
+func (d MessageDirection) String() string {
+	if d == MessageWritten {
+		return "written"
+	}
+	return "read"
+}
+
+// MessageEvent describes a single message which was read or written along
+// with the details of its header, so callers can account for bandwidth and
+// misbehavior without parsing the header again.
+type MessageEvent struct {
+	// Direction is whether the message was read or written.
+	Direction MessageDirection
+
+	// Command is the command of the message header.  It is set even when
+	// the command is unknown or malformed.
+	Command string
+
+	// PayloadLen is the payload length of the message header.  For reads
+	// it is the length the remote peer claimed, which may differ from the
+	// number of bytes actually transferred when an error occurred.
+	PayloadLen uint32
+
+	// Bytes is the total number of bytes transferred, including the
+	// header.
+	Bytes int
+
+	// Time is when the transfer of the message completed.
+	Time time.Time
+
+	// Err is the error which occurred while reading or writing the
+	// message, if any.
+	Err error
+}
+
+// MessageHook is a callback invoked with the details of each message read or
+// written.  It is not invoked when a message header could not be read, nor for
+// messages which were rejected before any of their bytes were written.  The
+// hook is called synchronously and must not block.
+type MessageHook func(ev *MessageEvent)
+
+// MessageCount holds the number of messages and bytes transferred for a
+// command.
+type MessageCount struct {
+	Messages uint64
+	Bytes    uint64
+}
+
+// MessageCounter accumulates per-command message and byte counts for both
+// directions.  Its Hook method may be passed as a MessageHook.  It is safe for
+// concurrent access.
+type MessageCounter struct {
+	mtx     sync.Mutex
+	read    map[string]MessageCount
+	written map[string]MessageCount
+}
+
+// NewMessageCounter returns a new empty MessageCounter.
+func NewMessageCounter() *MessageCounter {
+	return &MessageCounter{
+		read:    make(map[string]MessageCount),
+		written: make(map[string]MessageCount),
+	}
+}
+
+// Hook adds the message described by ev to the counts.  This satisfies the
+// MessageHook type.
+func (c *MessageCounter) Hook(ev *MessageEvent) {
+	c.mtx.Lock()
+	counts := c.read
+	if ev.Direction == MessageWritten {
+		counts = c.written
+	}
+	count := counts[ev.Command]
+	count.Messages++
+	count.Bytes += uint64(ev.Bytes)
+	counts[ev.Command] = count
+	c.mtx.Unlock()
+}
+
+// Counts returns a copy of the per-command counts for the given direction.
+func (c *MessageCounter) Counts(dir MessageDirection) map[string]MessageCount {
+	c.mtx.Lock()
+	counts := c.read
+	if dir == MessageWritten {
+		counts = c.written
+	}
+	snapshot := make(map[string]MessageCount, len(counts))
+	for cmd, count := range counts {
+		snapshot[cmd] = count
+	}
+	c.mtx.Unlock()
+	return snapshot
+}
+
+// Reset clears all counts.
+func (c *MessageCounter) Reset() {
+	c.mtx.Lock()
+	c.read = make(map[string]MessageCount)
+	c.written = make(map[string]MessageCount)
+	c.mtx.Unlock()
+}
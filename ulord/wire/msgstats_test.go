@@ -0,0 +1,116 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestMessageHook tests that the message hook is invoked with the details of
+// messages which are read and written, including rejected ones.
+func TestMessageHook(t *testing.T) {
+	pver := ProtocolVersion
+	var events []MessageEvent
+	hook := func(ev *MessageEvent) {
+		events = append(events, *ev)
+	}
+
+	// Write a ping and read it back.
+	var buf bytes.Buffer
+	n, err := WriteMessageWithHookN(&buf, NewMsgPing(123), pver, MainNet,
+		BaseEncoding, hook)
+	if err != nil {
+		t.Fatalf("WriteMessageWithHookN: %v", err)
+	}
+	_, _, _, err = ReadMessageWithHookN(&buf, pver, MainNet, BaseEncoding,
+		hook)
+	if err != nil {
+		t.Fatalf("ReadMessageWithHookN: %v", err)
+	}
+
+	// Messages from other networks are rejected after the header was
+	// read.
+	WriteMessageN(&buf, NewMsgPing(123), pver, TestNet)
+	_, _, _, err = ReadMessageWithHookN(&buf, pver, MainNet, BaseEncoding,
+		hook)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("ReadMessageWithHookN: wrong error - got %v <%T>, "+
+			"want <%T>", err, err, &MessageError{})
+	}
+
+	// Failures to read the header and messages which were rejected before
+	// being written are not reported.
+	ReadMessageWithHookN(&buf, pver, MainNet, BaseEncoding, hook)
+	WriteMessageWithHookN(&buf, &fakeMessage{command: "toolongcommand"},
+		pver, MainNet, BaseEncoding, hook)
+
+	// Failures to write the message are reported.
+	w := newFixedWriter(MessageHeaderSize)
+	_, err = WriteMessageWithHookN(w, NewMsgPing(123), pver, MainNet,
+		BaseEncoding, hook)
+	if err != io.ErrShortWrite {
+		t.Fatalf("WriteMessageWithHookN: wrong error - got %v, want %v",
+			err, io.ErrShortWrite)
+	}
+
+	want := []MessageEvent{
+		{Direction: MessageWritten, Command: CmdPing, PayloadLen: 8, Bytes: n},
+		{Direction: MessageRead, Command: CmdPing, PayloadLen: 8, Bytes: n},
+		{Direction: MessageRead, Command: CmdPing, PayloadLen: 8,
+			Bytes: MessageHeaderSize},
+		{Direction: MessageWritten, Command: CmdPing, PayloadLen: 8,
+			Bytes: MessageHeaderSize, Err: io.ErrShortWrite},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i := range events {
+		if events[i].Time.IsZero() {
+			t.Errorf("event #%d: time not set", i)
+		}
+		events[i].Time = want[i].Time
+		if i == 2 {
+			// Only the type of the error is relevant.
+			events[i].Err = nil
+		}
+		if !reflect.DeepEqual(events[i], want[i]) {
+			t.Errorf("event #%d: got %+v, want %+v", i, events[i],
+				want[i])
+		}
+	}
+}
+
+// TestMessageCounter tests that the message counter accumulates counts per
+// command and direction.
+func TestMessageCounter(t *testing.T) {
+	c := NewMessageCounter()
+	c.Hook(&MessageEvent{Direction: MessageRead, Command: CmdPing, Bytes: 32})
+	c.Hook(&MessageEvent{Direction: MessageRead, Command: CmdPing, Bytes: 32})
+	c.Hook(&MessageEvent{Direction: MessageRead, Command: CmdInv, Bytes: 61})
+	c.Hook(&MessageEvent{Direction: MessageWritten, Command: CmdPong,
+		Bytes: 32})
+
+	wantRead := map[string]MessageCount{
+		CmdPing: {Messages: 2, Bytes: 64},
+		CmdInv:  {Messages: 1, Bytes: 61},
+	}
+	wantWritten := map[string]MessageCount{
+		CmdPong: {Messages: 1, Bytes: 32},
+	}
+	if got := c.Counts(MessageRead); !reflect.DeepEqual(got, wantRead) {
+		t.Errorf("Counts(read): got %v, want %v", got, wantRead)
+	}
+	if got := c.Counts(MessageWritten); !reflect.DeepEqual(got, wantWritten) {
+		t.Errorf("Counts(written): got %v, want %v", got, wantWritten)
+	}
+
+	c.Reset()
+	if got := c.Counts(MessageRead); len(got) != 0 {
+		t.Errorf("Counts(read): got %v after reset", got)
+	}
+}
@@ -5,10 +5,10 @@
 package wire
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"strconv"
+	"sync"
 
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 )
@@ -94,6 +94,12 @@ const (
 	// 6,400,000 bytes.
 	freeListMaxItems = 12500
 
+	// witnessScaleFactor is the factor by which the size of non-witness
+	// data is scaled when calculating weights as defined by BIP0141.  It
+	// mirrors blockchain.WitnessScaleFactor, which can not be referenced
+	// from this package.
+	witnessScaleFactor = 4
+
 	// maxWitnessItemsPerInput is the maximum number of witness items to
 	// be read for the witness data for a single TxIn. This number is
 	// derived using a possble lower bound for the encoding of a witness
@@ -309,9 +315,7 @@ func (msg *MsgTx) TxHash() chainhash.Hash {
 	// Ignore the error returns since the only way the encode could fail
 	// is being out of memory or due to nil pointers, both of which would
 	// cause a run-time panic.
-	buf := bytes.NewBuffer(make([]byte, 0, msg.SerializeSizeStripped()))
-	_ = msg.SerializeNoWitness(buf)
-	return chainhash.DoubleHashH(buf.Bytes())
+	return pooledDoubleHash(msg, BaseEncoding)
 }
 
 // WitnessHash generates the hash of the transaction serialized according to
@@ -321,14 +325,51 @@ func (msg *MsgTx) TxHash() chainhash.Hash {
 // is the same as its txid.
 func (msg *MsgTx) WitnessHash() chainhash.Hash {
 	if msg.HasWitness() {
-		buf := bytes.NewBuffer(make([]byte, 0, msg.SerializeSize()))
-		_ = msg.Serialize(buf)
-		return chainhash.DoubleHashH(buf.Bytes())
+		return pooledDoubleHash(msg, WitnessEncoding)
 	}
 
 	return msg.TxHash()
 }
 
+// appendWriter is an io.Writer which appends everything written to it to a
+// byte slice.  Unlike bytes.Buffer, it allows the caller to keep ownership of
+// the underlying slice so it can be reused between serializations.
+type appendWriter struct {
+	b []byte
+}
+
+// Write appends p to the underlying slice.  It never returns an error.
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// hashWriterPool holds writers used to serialize transactions for hashing so
+// their buffers can be reused rather than allocated for every hash.
+var hashWriterPool = sync.Pool{
+	New: func() interface{} {
+		return &appendWriter{b: make([]byte, 0, 512)}
+	},
+}
+
+// maxPooledHashBuffer is the largest buffer returned to hashWriterPool.
+// Buffers grown beyond it by unusually large transactions are discarded
+// instead so the pool does not pin large amounts of memory.
+const maxPooledHashBuffer = 1 << 16
+
+// pooledDoubleHash returns the double sha256 of the transaction serialized
+// with the given encoding using a buffer from hashWriterPool.
+func pooledDoubleHash(msg *MsgTx, enc MessageEncoding) chainhash.Hash {
+	w := hashWriterPool.Get().(*appendWriter)
+	w.b = w.b[:0]
+	_ = msg.BtcEncode(w, 0, enc)
+	hash := chainhash.DoubleHashH(w.b)
+	if cap(w.b) <= maxPooledHashBuffer {
+		hashWriterPool.Put(w)
+	}
+	return hash
+}
+
 // Copy creates a deep copy of a transaction so that the original does not get
 // modified when the copy is manipulated.
 func (msg *MsgTx) Copy() *MsgTx {
@@ -451,7 +492,12 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 		str := fmt.Sprintf("too many input transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxInPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgTx.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, minTxInPayload)
+	if err != nil {
+		return err
 	}
 
 	// returnScriptBuffers is a closure that returns any script buffers that
@@ -514,7 +560,13 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 		str := fmt.Sprintf("too many output transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxOutPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return messageErrorCode(ErrMsgTooManyItems,
+			"MsgTx.BtcDecode", str)
+	}
+	err = checkItemCount(r, count, MinTxOutPayload)
+	if err != nil {
+		returnScriptBuffers()
+		return err
 	}
 
 	// Deserialize the outputs.
@@ -553,7 +605,13 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 				str := fmt.Sprintf("too many witness items to fit "+
 					"into max message size [count %d, max %d]",
 					witCount, maxWitnessItemsPerInput)
-				return messageError("MsgTx.BtcDecode", str)
+				return messageErrorCode(ErrMsgTooManyItems,
+					"MsgTx.BtcDecode", str)
+			}
+			err = checkItemCount(r, witCount, 1)
+			if err != nil {
+				returnScriptBuffers()
+				return err
 			}
 
 			// Then for witCount number of stack items, each item
@@ -570,6 +628,15 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 				totalScriptSize += uint64(len(txin.Witness[j]))
 			}
 		}
+
+		// The witness serialization must only be used when at least
+		// one input has witness data so every transaction has a
+		// single valid encoding.
+		if !msg.HasWitness() {
+			returnScriptBuffers()
+			return messageError("MsgTx.BtcDecode", "witness tx "+
+				"but no input has witness data")
+		}
 	}
 
 	msg.LockTime, err = binarySerializer.Uint32(r, littleEndian)
@@ -784,6 +851,32 @@ func (msg *MsgTx) SerializeNoWitness(w io.Writer) error {
 	return msg.BtcEncode(w, 0, BaseEncoding)
 }
 
+// SerializeNoWitnessInto encodes the transaction in the same format as
+// SerializeNoWitness, appending it to buf[:0] and returning the resulting
+// slice.  Callers serializing many transactions can pass the returned slice
+// back in to reuse its storage instead of allocating a new buffer per call.
+func (msg *MsgTx) SerializeNoWitnessInto(buf []byte) []byte {
+	if size := msg.SerializeSizeStripped(); cap(buf) < size {
+		buf = make([]byte, 0, size)
+	}
+
+	// Borrow a pooled writer to wrap the caller's buffer since converting
+	// a local writer to an io.Writer would allocate it on the heap.  The
+	// writer's own buffer is put back into the pool afterwards.
+	w := hashWriterPool.Get().(*appendWriter)
+	pooled := w.b
+	w.b = buf[:0]
+
+	// Ignore the error returns since the only way the encode could fail is
+	// being out of memory or due to nil pointers, both of which would
+	// cause a run-time panic.
+	_ = msg.BtcEncode(w, 0, BaseEncoding)
+	buf = w.b
+	w.b = pooled
+	hashWriterPool.Put(w)
+	return buf
+}
+
 // baseSize returns the serialized size of the transaction without accounting
 // for any witness data.
 func (msg *MsgTx) baseSize() int {
@@ -828,6 +921,25 @@ func (msg *MsgTx) SerializeSizeStripped() int {
 	return msg.baseSize()
 }
 
+// Weight returns the weight of the transaction as defined by BIP0141.  It is
+// the size of the transaction without any witness data scaled by the witness
+// scale factor of 4, plus the size of the witness data, so witness data is
+// discounted compared to the rest of the transaction.
+func (msg *MsgTx) Weight() int64 {
+	baseSize := msg.SerializeSizeStripped()
+	totalSize := msg.SerializeSize()
+
+	// (baseSize * 3) + totalSize
+	return int64(baseSize*(witnessScaleFactor-1) + totalSize)
+}
+
+// VirtualSize returns the virtual size of the transaction, which is its weight
+// divided by the witness scale factor of 4 rounded up.  It is the size used
+// for fee rate calculations of transactions with witness data.
+func (msg *MsgTx) VirtualSize() int64 {
+	return (msg.Weight() + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgTx) Command() string {
@@ -938,7 +1050,11 @@ func readScript(r io.Reader, pver uint32, maxAllowed uint32, fieldName string) (
 	if count > uint64(maxAllowed) {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("readScript", str)
+		return nil, messageErrorCode(ErrMsgFieldTooLong,
+			"readScript", str)
+	}
+	if err := checkRemaining(r, count); err != nil {
+		return nil, err
 	}
 
 	b := scriptPool.Borrow(count)
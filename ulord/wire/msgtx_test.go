@@ -778,6 +778,141 @@ func TestTxWitnessSize(t *testing.T) {
 	}
 }
 
+// TestTxWeight performs tests to ensure the weight and virtual size of various
+// transactions, both with and without witness data, are accurate.
+func TestTxWeight(t *testing.T) {
+	// Empty tx message.
+	noTx := NewMsgTx(1)
+
+	tests := []struct {
+		in     *MsgTx // Tx to calculate the weight of
+		weight int64  // Expected weight
+		vsize  int64  // Expected virtual size
+	}{
+		// No inputs or outputs.
+		{noTx, 40, 10},
+
+		// Transaction with an input and an output.
+		{multiTx, 840, 210},
+
+		// Transaction with an input which includes witness data, and
+		// one output.  The virtual size is rounded up.
+		{multiWitnessTx, 436, 109},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if weight := test.in.Weight(); weight != test.weight {
+			t.Errorf("MsgTx.Weight: #%d got: %d, want: %d", i, weight,
+				test.weight)
+		}
+		if vsize := test.in.VirtualSize(); vsize != test.vsize {
+			t.Errorf("MsgTx.VirtualSize: #%d got: %d, want: %d", i,
+				vsize, test.vsize)
+		}
+	}
+}
+
+// TestTxWitnessRoundTrip ensures transactions with witness data round trip
+// through both serializations and that the hashes commit to the expected
+// bytes.
+func TestTxWitnessRoundTrip(t *testing.T) {
+	tx := multiWitnessTx.Copy()
+	tx.AddTxIn(&TxIn{
+		PreviousOutPoint: OutPoint{Index: 1},
+		SignatureScript:  []byte{},
+		Witness:          TxWitness{},
+		Sequence:         0xfffffffe,
+	})
+	tx.TxIn[0].Witness = append(tx.TxIn[0].Witness, []byte{})
+
+	// Decoded scripts are never nil.
+	tx.TxIn[0].SignatureScript = []byte{}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	serialized := buf.Bytes()
+	if len(serialized) != tx.SerializeSize() {
+		t.Errorf("Serialize: got %d bytes, want %d", len(serialized),
+			tx.SerializeSize())
+	}
+	if !bytes.Equal(serialized[4:6], []byte{0x00, 0x01}) {
+		t.Errorf("Serialize: missing marker and flag - got %x",
+			serialized[4:6])
+	}
+
+	var decoded MsgTx
+	if err := decoded.Deserialize(bytes.NewReader(serialized)); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, tx) {
+		t.Errorf("Deserialize: mismatched tx - got %v, want %v",
+			spew.Sdump(&decoded), spew.Sdump(tx))
+	}
+
+	var strippedBuf bytes.Buffer
+	if err := tx.SerializeNoWitness(&strippedBuf); err != nil {
+		t.Fatalf("SerializeNoWitness: %v", err)
+	}
+	stripped := strippedBuf.Bytes()
+	if len(stripped) != tx.SerializeSizeStripped() {
+		t.Errorf("SerializeNoWitness: got %d bytes, want %d",
+			len(stripped), tx.SerializeSizeStripped())
+	}
+	var decodedStripped MsgTx
+	err := decodedStripped.Deserialize(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if decodedStripped.HasWitness() {
+		t.Error("Deserialize: stripped tx has witness data")
+	}
+
+	// The txid excludes the witness data while the witness hash commits
+	// to the full serialization.
+	if got, want := tx.TxHash(), chainhash.DoubleHashH(stripped); got != want {
+		t.Errorf("TxHash: got %v, want %v", got, want)
+	}
+	if got, want := decodedStripped.TxHash(), tx.TxHash(); got != want {
+		t.Errorf("TxHash: stripped tx got %v, want %v", got, want)
+	}
+	if got, want := tx.WitnessHash(), chainhash.DoubleHashH(serialized); got != want {
+		t.Errorf("WitnessHash: got %v, want %v", got, want)
+	}
+	if got, want := decodedStripped.WitnessHash(), tx.TxHash(); got != want {
+		t.Errorf("WitnessHash: stripped tx got %v, want %v", got, want)
+	}
+}
+
+// TestTxSuperfluousWitness ensures the witness serialization is rejected when
+// none of the inputs has witness data.
+func TestTxSuperfluousWitness(t *testing.T) {
+	tx := multiWitnessTx.Copy()
+	tx.TxIn[0].Witness = nil
+
+	// Serialize the transaction without witness data and insert the
+	// marker and flag bytes along with an empty witness stack.
+	var buf bytes.Buffer
+	if err := tx.SerializeNoWitness(&buf); err != nil {
+		t.Fatalf("SerializeNoWitness: %v", err)
+	}
+	stripped := buf.Bytes()
+	serialized := append([]byte{}, stripped[:4]...)
+	serialized = append(serialized, 0x00, 0x01)
+	serialized = append(serialized, stripped[4:len(stripped)-4]...)
+	serialized = append(serialized, 0x00)
+	serialized = append(serialized, stripped[len(stripped)-4:]...)
+
+	var decoded MsgTx
+	err := decoded.Deserialize(bytes.NewReader(serialized))
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("Deserialize: wrong error - got %v <%T>, want <%T>",
+			err, err, &MessageError{})
+	}
+}
+
 // multiTx is a MsgTx with an input and output and used in various tests.
 var multiTx = &MsgTx{
 	Version: 1,
@@ -1019,3 +1154,50 @@ var multiWitnessTxEncodedNonZeroFlag = []byte{
 // multiTxPkScriptLocs is the location information for the public key scripts
 // located in multiWitnessTx.
 var multiWitnessTxPkScriptLocs = []int{58}
+
+// TestTxSerializeNoWitnessInto tests that serializing into a caller provided
+// buffer matches SerializeNoWitness and reuses the buffer when it is large
+// enough.
+func TestTxSerializeNoWitnessInto(t *testing.T) {
+	tests := []*MsgTx{multiTx, multiWitnessTx}
+	for i, tx := range tests {
+		var want bytes.Buffer
+		if err := tx.SerializeNoWitness(&want); err != nil {
+			t.Fatalf("SerializeNoWitness #%d: %v", i, err)
+		}
+
+		// A nil buffer is allocated.
+		got := tx.SerializeNoWitnessInto(nil)
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("SerializeNoWitnessInto #%d: got %x, want %x", i,
+				got, want.Bytes())
+			continue
+		}
+
+		// A large enough buffer is reused after its contents are
+		// discarded.
+		buf := make([]byte, 3, 1024)
+		got = tx.SerializeNoWitnessInto(buf)
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("SerializeNoWitnessInto #%d: got %x, want %x", i,
+				got, want.Bytes())
+			continue
+		}
+		if &got[0] != &buf[0] {
+			t.Errorf("SerializeNoWitnessInto #%d: buffer not reused", i)
+		}
+
+		// The pooled hashes match hashing the serialized bytes.
+		if txHash := tx.TxHash(); txHash != chainhash.DoubleHashH(got) {
+			t.Errorf("TxHash #%d: got %v, want %v", i, txHash,
+				chainhash.DoubleHashH(got))
+		}
+		var witness bytes.Buffer
+		tx.Serialize(&witness)
+		wantHash := chainhash.DoubleHashH(witness.Bytes())
+		if witnessHash := tx.WitnessHash(); witnessHash != wantHash {
+			t.Errorf("WitnessHash #%d: got %v, want %v", i,
+				witnessHash, wantHash)
+		}
+	}
+}
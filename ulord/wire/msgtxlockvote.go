@@ -0,0 +1,114 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// MsgTxLockVote implements the Message interface and represents a Ulord
+// txlvote message.  It is sent by a masternode to vote for locking an input of
+// a transaction requested to be sent instantly, so conflicting transactions
+// are rejected once enough masternodes voted for the lock.
+//
+// This message was not added until protocol version MasternodeVersion.
+type MsgTxLockVote struct {
+	TxHash             chainhash.Hash
+	Outpoint           OutPoint
+	MasternodeOutpoint OutPoint
+	Signature          []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgTxLockVote) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("txlvote message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgTxLockVote.BtcDecode", str)
+	}
+
+	err := readElement(r, &msg.TxHash)
+	if err != nil {
+		return err
+	}
+	err = readOutPoint(r, pver, 0, &msg.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = readOutPoint(r, pver, 0, &msg.MasternodeOutpoint)
+	if err != nil {
+		return err
+	}
+
+	msg.Signature, err = ReadVarBytes(r, pver, MaxMasternodeSigSize,
+		"txlvote signature")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgTxLockVote) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < MasternodeVersion {
+		str := fmt.Sprintf("txlvote message invalid for protocol "+
+			"version %d", pver)
+		return messageErrorCode(ErrMsgUnsupportedVersion,
+			"MsgTxLockVote.BtcEncode", str)
+	}
+
+	if len(msg.Signature) > MaxMasternodeSigSize {
+		str := fmt.Sprintf("txlvote signature too large for message "+
+			"[size %v, max %v]", len(msg.Signature),
+			MaxMasternodeSigSize)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"MsgTxLockVote.BtcEncode", str)
+	}
+
+	err := writeElement(w, &msg.TxHash)
+	if err != nil {
+		return err
+	}
+	err = writeOutPoint(w, pver, 0, &msg.Outpoint)
+	if err != nil {
+		return err
+	}
+	err = writeOutPoint(w, pver, 0, &msg.MasternodeOutpoint)
+	if err != nil {
+		return err
+	}
+
+	return WriteVarBytes(w, pver, msg.Signature)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgTxLockVote) Command() string {
+	return CmdTxLockVote
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgTxLockVote) MaxPayloadLength(pver uint32) uint32 {
+	// Transaction hash + 2 outpoints (hash + 4 bytes index) +
+	// num signature bytes (varInt) + signature.
+	return chainhash.HashSize + 2*(chainhash.HashSize+4) +
+		uint32(VarIntSerializeSize(MaxMasternodeSigSize)) +
+		MaxMasternodeSigSize
+}
+
+// NewMsgTxLockVote returns a new Ulord txlvote message that conforms to the
+// Message interface.  See MsgTxLockVote for details.
+func NewMsgTxLockVote(txHash *chainhash.Hash, outpoint, masternodeOutpoint *OutPoint, signature []byte) *MsgTxLockVote {
+	return &MsgTxLockVote{
+		TxHash:             *txHash,
+		Outpoint:           *outpoint,
+		MasternodeOutpoint: *masternodeOutpoint,
+		Signature:          signature,
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+)
+
+// TestTxLockVote tests the MsgTxLockVote API against the latest protocol version.
+func TestTxLockVote(t *testing.T) {
+	pver := ProtocolVersion
+	msg := NewMsgTxLockVote(&chainhash.Hash{}, &OutPoint{}, &OutPoint{}, nil)
+
+	// Ensure the command is expected value.
+	wantCmd := "txlvote"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgTxLockVote: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(177)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure a message with the largest allowed fields fits in the max
+	// payload.
+	msg.Signature = make([]byte, MaxMasternodeSigSize)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if uint32(buf.Len()) != maxPayload {
+		t.Errorf("BtcEncode: largest message is %d bytes, want %d",
+			buf.Len(), maxPayload)
+	}
+}
+
+// TestTxLockVoteWire tests the MsgTxLockVote wire encode and decode for various
+// protocol versions.
+func TestTxLockVoteWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgTxLockVote // Message to encode
+		out  *MsgTxLockVote // Expected decoded message
+		buf  []byte         // Wire encoding
+		pver uint32         // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{baseTxLockVote, baseTxLockVote, baseTxLockVoteEncoded, ProtocolVersion},
+
+		// Protocol version MasternodeVersion.
+		{baseTxLockVote, baseTxLockVote, baseTxLockVoteEncoded, MasternodeVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgTxLockVote
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestTxLockVoteWireErrors performs negative tests against wire encode and
+// decode of MsgTxLockVote to confirm error paths work correctly.
+func TestTxLockVoteWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoMasternode := MasternodeVersion - 1
+	wireErr := &MessageError{}
+
+	// Signature larger than the max allowed size.
+	bigSig := *baseTxLockVote
+	bigSig.Signature = make([]byte, MaxMasternodeSigSize+1)
+	bigSigEncoded := append([]byte{}, baseTxLockVoteEncoded[:104]...)
+	bigSigEncoded = append(bigSigEncoded, MaxMasternodeSigSize+1)
+
+	tests := []struct {
+		in       *MsgTxLockVote // Value to encode
+		buf      []byte         // Wire encoding
+		pver     uint32         // Protocol version for wire encoding
+		max      int            // Max size of fixed buffer to induce errors
+		writeErr error          // Expected write error
+		readErr  error          // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in transaction hash.
+		{baseTxLockVote, baseTxLockVoteEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in outpoint.
+		{baseTxLockVote, baseTxLockVoteEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in masternode outpoint.
+		{baseTxLockVote, baseTxLockVoteEncoded, pver, 68, io.ErrShortWrite, io.EOF},
+		// Force error in signature length.
+		{baseTxLockVote, baseTxLockVoteEncoded, pver, 104, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseTxLockVote, baseTxLockVoteEncoded, pver, 105, io.ErrShortWrite, io.EOF},
+		// Force error with signature too large.
+		{&bigSig, bigSigEncoded, pver, len(bigSigEncoded), wireErr, wireErr},
+		// Force error due to unsupported protocol version.
+		{baseTxLockVote, baseTxLockVoteEncoded, pverNoMasternode, len(baseTxLockVoteEncoded), wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgTxLockVote
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
+
+// baseTxLockVote is used in the various tests as a baseline MsgTxLockVote.
+var baseTxLockVote = NewMsgTxLockVote(&chainhash.Hash{0x01, 0x02},
+	&OutPoint{Hash: chainhash.Hash{0x03}, Index: 1},
+	&OutPoint{Hash: chainhash.Hash{0x04}, Index: 2}, []byte{0xaa, 0xbb})
+
+// baseTxLockVoteEncoded is the wire encoded bytes for baseTxLockVote.
+var baseTxLockVoteEncoded = []byte{
+	0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+	0x02, 0xaa, 0xbb,
+}
@@ -110,11 +110,8 @@ func (msg *MsgVersion) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding)
 		}
 	}
 	if buf.Len() > 0 {
-		userAgent, err := ReadVarString(buf, pver)
-		if err != nil {
-			return err
-		}
-		err = validateUserAgent(userAgent)
+		userAgent, err := ReadVarStringMax(buf, pver, MaxUserAgentLen,
+			"user agent")
 		if err != nil {
 			return err
 		}
@@ -243,7 +240,7 @@ func validateUserAgent(userAgent string) error {
 	if len(userAgent) > MaxUserAgentLen {
 		str := fmt.Sprintf("user agent too long [len %v, max %v]",
 			len(userAgent), MaxUserAgentLen)
-		return messageError("MsgVersion", str)
+		return messageErrorCode(ErrMsgFieldTooLong, "MsgVersion", str)
 	}
 	return nil
 }
@@ -0,0 +1,310 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// MaxNetAddressV2Size is the maximum number of bytes of an address in a
+// NetAddressV2 as defined by BIP0155, regardless of its network.
+const MaxNetAddressV2Size = 512
+
+// NetworkID identifies the network of an address in a NetAddressV2 as defined
+// by BIP0155.
+type NetworkID uint8
+
+// These constants define the networks defined by BIP0155.
+const (
+	NetIDIPv4  NetworkID = 1
+	NetIDIPv6  NetworkID = 2
+	NetIDTorV2 NetworkID = 3
+	NetIDTorV3 NetworkID = 4
+	NetIDI2P   NetworkID = 5
+	NetIDCJDNS NetworkID = 6
+)
+
+// Map of network ids back to their constant names for pretty printing.
+var netIDStrings = map[NetworkID]string{
+	NetIDIPv4:  "IPv4",
+	NetIDIPv6:  "IPv6",
+	NetIDTorV2: "TorV2",
+	NetIDTorV3: "TorV3",
+	NetIDI2P:   "I2P",
+	NetIDCJDNS: "CJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := netIDStrings[id]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+// addrSize returns the number of bytes of the addresses of the network and
+// whether the network is known.
+func (id NetworkID) addrSize() (int, bool) {
+	switch id {
+	case NetIDIPv4:
+		return net.IPv4len, true
+	case NetIDIPv6, NetIDCJDNS:
+		return net.IPv6len, true
+	case NetIDTorV2:
+		return 10, true
+	case NetIDTorV3, NetIDI2P:
+		return 32, true
+	}
+	return 0, false
+}
+
+var (
+	// onionCatPrefix is the IPv6 prefix Tor v2 addresses are encoded with
+	// in the legacy address format.
+	onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+	// onionEncoding is the encoding of the names of Tor and I2P addresses.
+	onionEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// NetAddressV2 defines information about a peer on the network in the format
+// of the addrv2 message defined by BIP0155.  Unlike NetAddress, it can
+// represent addresses of networks other than IP such as Tor v3, I2P and
+// CJDNS.  Addresses of unknown networks are preserved as opaque bytes so they
+// can be relayed.
+type NetAddressV2 struct {
+	// Last time the address was seen.  This is, unfortunately, encoded as
+	// a uint32 on the wire and therefore is limited to 2106.
+	Timestamp time.Time
+
+	// Bitfield which identifies the services supported by the address.
+	Services ServiceFlag
+
+	// NetID is the network of the address.
+	NetID NetworkID
+
+	// Addr is the address in the encoding of its network.
+	Addr []byte
+
+	// Port the peer is using.  This is encoded in big endian on the wire
+	// which differs from most everything else.
+	Port uint16
+}
+
+// NewNetAddressV2 returns a new NetAddressV2 using the provided network,
+// address, port, and supported services with defaults for the remaining
+// fields.  An error is returned when the address does not have the size
+// required by a known network.
+func NewNetAddressV2(netID NetworkID, addr []byte, port uint16, services ServiceFlag) (*NetAddressV2, error) {
+	if err := checkNetAddressV2(netID, addr); err != nil {
+		return nil, err
+	}
+
+	// Limit the timestamp to one second precision since the protocol
+	// doesn't support better.
+	return &NetAddressV2{
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Services:  services,
+		NetID:     netID,
+		Addr:      addr,
+		Port:      port,
+	}, nil
+}
+
+// NetAddressV2FromLegacy converts a NetAddress to a NetAddressV2.  IPv6
+// addresses in the OnionCat range are converted to Tor v2 addresses.
+func NetAddressV2FromLegacy(na *NetAddress) *NetAddressV2 {
+	na2 := &NetAddressV2{
+		Timestamp: na.Timestamp,
+		Services:  na.Services,
+		Port:      na.Port,
+	}
+
+	ip := na.IP.To16()
+	switch {
+	case na.IP.To4() != nil:
+		na2.NetID = NetIDIPv4
+		na2.Addr = []byte(na.IP.To4())
+	case bytes.HasPrefix(ip, onionCatPrefix):
+		na2.NetID = NetIDTorV2
+		na2.Addr = append([]byte{}, ip[6:]...)
+	default:
+		na2.NetID = NetIDIPv6
+		na2.Addr = make([]byte, net.IPv6len)
+		copy(na2.Addr, ip)
+	}
+	return na2
+}
+
+// ToLegacy converts the address to a NetAddress.  Only IPv4, IPv6 and Tor v2
+// addresses can be represented by a NetAddress, so false is returned for the
+// addresses of all other networks.
+func (na *NetAddressV2) ToLegacy() (*NetAddress, bool) {
+	size, _ := na.NetID.addrSize()
+	if len(na.Addr) != size {
+		return nil, false
+	}
+
+	var ip net.IP
+	switch na.NetID {
+	case NetIDIPv4:
+		ip = net.IPv4(na.Addr[0], na.Addr[1], na.Addr[2], na.Addr[3])
+	case NetIDIPv6:
+		ip = append(net.IP{}, na.Addr...)
+	case NetIDTorV2:
+		ip = append(append(net.IP{}, onionCatPrefix...), na.Addr...)
+	default:
+		return nil, false
+	}
+
+	return &NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  na.Services,
+		IP:        ip,
+		Port:      na.Port,
+	}, true
+}
+
+// HostString returns the host of the address in the textual form of its
+// network, such as a dotted IPv4 address or a Tor .onion name.  Addresses of
+// unknown networks are returned in hex.
+func (na *NetAddressV2) HostString() string {
+	size, known := na.NetID.addrSize()
+	if !known || len(na.Addr) != size {
+		return fmt.Sprintf("%x", na.Addr)
+	}
+
+	switch na.NetID {
+	case NetIDTorV2:
+		return strings.ToLower(onionEncoding.EncodeToString(na.Addr)) +
+			".onion"
+
+	case NetIDTorV3:
+		// Tor v3 names encode the public key followed by a checksum
+		// and the version, which is 3.
+		const version = 0x03
+		h := sha3.New256()
+		h.Write([]byte(".onion checksum"))
+		h.Write(na.Addr)
+		h.Write([]byte{version})
+		checksum := h.Sum(nil)[:2]
+
+		name := make([]byte, 0, len(na.Addr)+3)
+		name = append(name, na.Addr...)
+		name = append(name, checksum...)
+		name = append(name, version)
+		return strings.ToLower(onionEncoding.EncodeToString(name)) +
+			".onion"
+
+	case NetIDI2P:
+		return strings.ToLower(onionEncoding.EncodeToString(na.Addr)) +
+			".b32.i2p"
+	}
+
+	// IPv4, IPv6 and CJDNS addresses are all IP addresses.
+	return net.IP(na.Addr).String()
+}
+
+// String returns the address in host:port form.
+func (na *NetAddressV2) String() string {
+	return net.JoinHostPort(na.HostString(), fmt.Sprint(na.Port))
+}
+
+// checkNetAddressV2 returns an error when the address does not have the size
+// required by its network.  Addresses of unknown networks are only limited by
+// the maximum size of all addresses.
+func checkNetAddressV2(netID NetworkID, addr []byte) error {
+	if len(addr) > MaxNetAddressV2Size {
+		str := fmt.Sprintf("address too large [size %d, max %d]",
+			len(addr), MaxNetAddressV2Size)
+		return messageErrorCode(ErrMsgFieldTooLong,
+			"checkNetAddressV2", str)
+	}
+	size, known := netID.addrSize()
+	if known && len(addr) != size {
+		str := fmt.Sprintf("invalid %v address size [size %d, want %d]",
+			netID, len(addr), size)
+		return messageError("checkNetAddressV2", str)
+	}
+	return nil
+}
+
+// maxNetAddressV2Payload is the maximum number of bytes of an encoded
+// NetAddressV2.  It is 4 bytes timestamp + services (varInt) + 1 byte network
+// id + address (varInt + max address) + 2 bytes port.
+const maxNetAddressV2Payload = 4 + MaxVarIntPayload + 1 + 3 +
+	MaxNetAddressV2Size + 2
+
+// minNetAddressV2Payload is the minimum number of bytes of an encoded
+// NetAddressV2.  It is 4 bytes timestamp + 1 byte services + 1 byte network
+// id + 1 byte address length + 2 bytes port.
+const minNetAddressV2Payload = 4 + 1 + 1 + 1 + 2
+
+// readNetAddressV2 reads an encoded NetAddressV2 from r.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	na.Services = ServiceFlag(services)
+	netID, err := binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+	na.NetID = NetworkID(netID)
+	na.Addr, err = ReadVarBytes(r, pver, MaxNetAddressV2Size,
+		"addrv2 address")
+	if err != nil {
+		return err
+	}
+	if err := checkNetAddressV2(na.NetID, na.Addr); err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	na.Port, err = binarySerializer.Uint16(r, bigEndian)
+	return err
+}
+
+// writeNetAddressV2 serializes a NetAddressV2 to w.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
+	if err := checkNetAddressV2(na.NetID, na.Addr); err != nil {
+		return err
+	}
+
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint8(w, uint8(na.NetID))
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, na.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
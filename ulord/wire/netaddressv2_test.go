@@ -0,0 +1,134 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// seqBytes returns n bytes counting up from zero.
+func seqBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// TestNetAddressV2HostString ensures addresses of every network are formatted
+// in the textual form of their network.
+func TestNetAddressV2HostString(t *testing.T) {
+	torV3Key, _ := hex.DecodeString("79bcc625184b05194975c28b66b66b04" +
+		"69f7f6556fb1ac3189a79b40dda32f1f")
+
+	tests := []struct {
+		netID NetworkID
+		addr  []byte
+		port  uint16
+		want  string
+	}{
+		{NetIDIPv4, []byte{127, 0, 0, 1}, 9888, "127.0.0.1:9888"},
+		{NetIDIPv6, net.ParseIP("2001:db8::1"), 9888,
+			"[2001:db8::1]:9888"},
+		{NetIDTorV2, seqBytes(10), 9888, "aaaqeayeaudaocaj.onion:9888"},
+		{NetIDTorV3, torV3Key, 9888, "pg6mmjiyjmcrsslvykfwnntlaru7p5svn" +
+			"6y2ymmju6nubxndf4pscryd.onion:9888"},
+		{NetIDI2P, seqBytes(32), 0, "aaaqeayeaudaocajbifqydiob4ibceqtcqk" +
+			"rmfyydenbwha5dypq.b32.i2p:0"},
+		{NetIDCJDNS, net.ParseIP("fc00::1"), 9888, "[fc00::1]:9888"},
+		{NetworkID(42), []byte{0xab, 0xcd}, 1, "abcd:1"},
+	}
+
+	for i, test := range tests {
+		na, err := NewNetAddressV2(test.netID, test.addr, test.port, 0)
+		if err != nil {
+			t.Errorf("NewNetAddressV2 #%d (%v): unexpected error %v",
+				i, test.netID, err)
+			continue
+		}
+		if got := na.String(); got != test.want {
+			t.Errorf("String #%d (%v): got %s, want %s", i,
+				test.netID, got, test.want)
+		}
+	}
+
+	// Addresses with the wrong size for their network must be rejected.
+	_, err := NewNetAddressV2(NetIDTorV3, seqBytes(10), 0, 0)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("NewNetAddressV2: wrong error - got %v, want "+
+			"<*MessageError>", err)
+	}
+}
+
+// TestNetAddressV2Legacy ensures addresses are converted to and from the
+// legacy NetAddress when possible.
+func TestNetAddressV2Legacy(t *testing.T) {
+	ts := time.Unix(0x495fab29, 0)
+	onionCat := append(append(net.IP{}, onionCatPrefix...), seqBytes(10)...)
+
+	tests := []struct {
+		legacy *NetAddress
+		netID  NetworkID
+		addr   []byte
+	}{
+		{NewNetAddressTimestamp(ts, SFNodeNetwork,
+			net.ParseIP("192.168.0.1"), 9888), NetIDIPv4,
+			[]byte{192, 168, 0, 1}},
+		{NewNetAddressTimestamp(ts, SFNodeNetwork,
+			net.ParseIP("2001:db8::1"), 9888), NetIDIPv6,
+			[]byte(net.ParseIP("2001:db8::1"))},
+		{NewNetAddressTimestamp(ts, SFNodeNetwork, onionCat, 9888),
+			NetIDTorV2, seqBytes(10)},
+	}
+
+	for i, test := range tests {
+		na := NetAddressV2FromLegacy(test.legacy)
+		if na.NetID != test.netID || !bytes.Equal(na.Addr, test.addr) {
+			t.Errorf("NetAddressV2FromLegacy #%d: got %v %x, want "+
+				"%v %x", i, na.NetID, na.Addr, test.netID,
+				test.addr)
+			continue
+		}
+		if !na.Timestamp.Equal(ts) || na.Services != SFNodeNetwork ||
+			na.Port != 9888 {
+			t.Errorf("NetAddressV2FromLegacy #%d: mismatched fields "+
+				"%v", i, spew.Sdump(na))
+			continue
+		}
+
+		legacy, ok := na.ToLegacy()
+		if !ok {
+			t.Errorf("ToLegacy #%d: unexpected failure", i)
+			continue
+		}
+		if !legacy.IP.Equal(test.legacy.IP) ||
+			!reflect.DeepEqual(legacy.Timestamp, test.legacy.Timestamp) ||
+			legacy.Services != test.legacy.Services ||
+			legacy.Port != test.legacy.Port {
+			t.Errorf("ToLegacy #%d: got %v, want %v", i,
+				spew.Sdump(legacy), spew.Sdump(test.legacy))
+		}
+	}
+
+	// Networks other than IP and Tor v2 can't be converted.
+	for _, netID := range []NetworkID{NetIDTorV3, NetIDI2P, NetIDCJDNS} {
+		size, _ := netID.addrSize()
+		na, err := NewNetAddressV2(netID, make([]byte, size), 0, 0)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2 (%v): unexpected error %v",
+				netID, err)
+		}
+		if _, ok := na.ToLegacy(); ok {
+			t.Errorf("ToLegacy (%v): unexpected success", netID)
+		}
+	}
+}
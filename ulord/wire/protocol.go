@@ -51,6 +51,11 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
+
+	// MasternodeVersion is the protocol version which added the Ulord
+	// specific masternode broadcast and ping, transaction lock vote, and
+	// spork messages.
+	MasternodeVersion uint32 = 70013
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -0,0 +1,299 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/ulordsuite/ulord/blockchain"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// These constants define the topics published by the publisher.
+const (
+	// TopicHashBlock publishes the hash of each block connected to the
+	// main chain.
+	TopicHashBlock = "hashblock"
+
+	// TopicHashTx publishes the hash of each transaction accepted to the
+	// memory pool or included in a block connected to the main chain.
+	TopicHashTx = "hashtx"
+
+	// TopicRawBlock publishes each serialized block connected to the main
+	// chain.
+	TopicRawBlock = "rawblock"
+
+	// TopicRawTx publishes each serialized transaction accepted to the
+	// memory pool or included in a block connected to the main chain.
+	TopicRawTx = "rawtx"
+
+	// TopicHashTxLock publishes the hash of each transaction locked by
+	// InstantSend.
+	TopicHashTxLock = "hashtxlock"
+
+	// TopicRawTxLock publishes each serialized transaction locked by
+	// InstantSend.
+	TopicRawTxLock = "rawtxlock"
+)
+
+// Topics lists all topics which can be published.
+var Topics = []string{
+	TopicHashBlock,
+	TopicHashTx,
+	TopicRawBlock,
+	TopicRawTx,
+	TopicHashTxLock,
+	TopicRawTxLock,
+}
+
+// message is a message published on a topic.
+type message struct {
+	topic    string
+	sequence uint32
+	frames   []byte
+}
+
+// Config is a descriptor containing the publisher configuration.
+type Config struct {
+	// Chain is the chain whose connected blocks are published.
+	Chain *blockchain.BlockChain
+
+	// Endpoints maps the topics to publish to the endpoints they are
+	// published on, such as tcp://127.0.0.1:28332.  Topics sharing an
+	// endpoint are published on the same socket.
+	Endpoints map[string]string
+}
+
+// Publisher publishes blocks and transactions to ZeroMQ subscribers.
+//
+// Each message consists of three frames: the topic, the body and the sequence
+// number of the message as a 4-byte little-endian integer.  Sequence numbers
+// are counted per topic, so subscribers are able to detect lost messages.
+type Publisher struct {
+	cfg      Config
+	sockets  []*pubSocket
+	topics   map[string]*pubSocket
+	chainSub *blockchain.Subscription
+	wg       sync.WaitGroup
+
+	mtx       sync.Mutex
+	sequences map[string]uint32
+}
+
+// ParseEndpoint returns the address to listen on for the passed ZeroMQ
+// endpoint.  Only TCP endpoints are supported and a host of * listens on all
+// interfaces.
+func ParseEndpoint(endpoint string) (string, error) {
+	const scheme = "tcp://"
+	if !strings.HasPrefix(endpoint, scheme) {
+		return "", fmt.Errorf("unsupported endpoint %q: only %s "+
+			"endpoints are supported", endpoint, scheme)
+	}
+	host, port, err := net.SplitHostPort(endpoint[len(scheme):])
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+	if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// New returns a publisher listening on the endpoints of the passed
+// configuration.  Use Start to begin publishing.
+func New(cfg *Config) (*Publisher, error) {
+	p := &Publisher{
+		cfg:       *cfg,
+		topics:    make(map[string]*pubSocket),
+		sequences: make(map[string]uint32),
+	}
+
+	sockets := make(map[string]*pubSocket)
+	for _, topic := range Topics {
+		endpoint, ok := cfg.Endpoints[topic]
+		if !ok {
+			continue
+		}
+		addr, err := ParseEndpoint(endpoint)
+		if err != nil {
+			p.closeSockets()
+			return nil, err
+		}
+		socket, ok := sockets[addr]
+		if !ok {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				p.closeSockets()
+				return nil, err
+			}
+			socket = newPubSocket(listener)
+			sockets[addr] = socket
+			p.sockets = append(p.sockets, socket)
+		}
+		p.topics[topic] = socket
+	}
+	for topic := range cfg.Endpoints {
+		if _, ok := p.topics[topic]; !ok {
+			p.closeSockets()
+			return nil, fmt.Errorf("unknown topic %q", topic)
+		}
+	}
+
+	return p, nil
+}
+
+// closeSockets closes all sockets of the publisher.
+func (p *Publisher) closeSockets() {
+	for _, socket := range p.sockets {
+		socket.close()
+	}
+}
+
+// Start begins accepting subscribers and publishing connected blocks.
+func (p *Publisher) Start() {
+	for _, socket := range p.sockets {
+		log.Infof("ZMQ publisher listening on %s",
+			socket.listener.Addr())
+		p.wg.Add(1)
+		go socket.acceptHandler(&p.wg)
+	}
+	for _, topic := range Topics {
+		if socket, ok := p.topics[topic]; ok {
+			log.Debugf("Publishing %s on %s", topic,
+				socket.listener.Addr())
+		}
+	}
+
+	if p.cfg.Chain != nil {
+		p.chainSub = p.cfg.Chain.SubscribeBuffered(
+			p.handleBlockchainNotification,
+			blockchain.NTBlockConnected)
+	}
+}
+
+// Stop disconnects all subscribers and waits for the publisher to shut down.
+func (p *Publisher) Stop() {
+	if p.chainSub != nil {
+		p.chainSub.Unsubscribe()
+	}
+	p.closeSockets()
+	p.wg.Wait()
+	log.Infof("ZMQ publisher stopped")
+}
+
+// publishing returns whether any of the passed topics is published.
+func (p *Publisher) publishing(topics ...string) bool {
+	for _, topic := range topics {
+		if _, ok := p.topics[topic]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// publish sends the passed body on a topic with the next sequence number of
+// the topic.  Nothing is sent when the topic is not published.
+func (p *Publisher) publish(topic string, body []byte) {
+	socket, ok := p.topics[topic]
+	if !ok {
+		return
+	}
+
+	// The sequence number is assigned and the message is queued while
+	// holding the lock, so subscribers always receive the messages of a
+	// topic in order.
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	sequence := p.sequences[topic]
+	p.sequences[topic]++
+
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], sequence)
+	socket.send(&message{
+		topic:    topic,
+		sequence: sequence,
+		frames:   encodeMessage([]byte(topic), body, seq[:]),
+	})
+}
+
+// publishHash publishes the passed hash in the byte order it is displayed in.
+func (p *Publisher) publishHash(topic string, hash *chainhash.Hash) {
+	if _, ok := p.topics[topic]; !ok {
+		return
+	}
+	body := make([]byte, chainhash.HashSize)
+	for i := range hash {
+		body[chainhash.HashSize-1-i] = hash[i]
+	}
+	p.publish(topic, body)
+}
+
+// publishTx publishes the passed transaction on the hash and raw topics.
+func (p *Publisher) publishTx(tx *ulordutil.Tx, hashTopic, rawTopic string) {
+	p.publishHash(hashTopic, tx.Hash())
+	if !p.publishing(rawTopic) {
+		return
+	}
+	var buf bytes.Buffer
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		log.Errorf("Failed to serialize transaction %v: %v", tx.Hash(),
+			err)
+		return
+	}
+	p.publish(rawTopic, buf.Bytes())
+}
+
+// NotifyBlockConnected publishes the passed block connected to the main chain
+// along with its transactions.
+func (p *Publisher) NotifyBlockConnected(block *ulordutil.Block) {
+	p.publishHash(TopicHashBlock, block.Hash())
+	if p.publishing(TopicRawBlock) {
+		raw, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Failed to serialize block %v: %v",
+				block.Hash(), err)
+		} else {
+			p.publish(TopicRawBlock, raw)
+		}
+	}
+
+	if p.publishing(TopicHashTx, TopicRawTx) {
+		for _, tx := range block.Transactions() {
+			p.publishTx(tx, TopicHashTx, TopicRawTx)
+		}
+	}
+}
+
+// NotifyNewTransaction publishes the passed transaction accepted to the memory
+// pool.
+func (p *Publisher) NotifyNewTransaction(tx *ulordutil.Tx) {
+	p.publishTx(tx, TopicHashTx, TopicRawTx)
+}
+
+// NotifyTxLocked publishes the passed transaction locked by InstantSend.
+func (p *Publisher) NotifyTxLocked(tx *ulordutil.Tx) {
+	p.publishTx(tx, TopicHashTxLock, TopicRawTxLock)
+}
+
+// handleBlockchainNotification publishes the blocks connected to the main
+// chain.
+func (p *Publisher) handleBlockchainNotification(notification *blockchain.Notification) {
+	if notification.Type != blockchain.NTBlockConnected {
+		return
+	}
+	block, ok := notification.Data.(*ulordutil.Block)
+	if !ok {
+		log.Warnf("Chain connected notification is not a block.")
+		return
+	}
+	p.NotifyBlockConnected(block)
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestParseEndpoint ensures ZeroMQ endpoints are converted to the addresses
+// to listen on.
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		valid    bool
+	}{
+		{"tcp://127.0.0.1:28332", "127.0.0.1:28332", true},
+		{"tcp://*:28332", ":28332", true},
+		{"tcp://[::1]:28332", "[::1]:28332", true},
+		{"ipc:///tmp/ulord.sock", "", false},
+		{"tcp://127.0.0.1", "", false},
+		{"127.0.0.1:28332", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := ParseEndpoint(test.endpoint)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error %v", test.endpoint, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.endpoint, got,
+				test.want)
+		}
+	}
+}
+
+// testSubscriber is a minimal ZeroMQ subscribe socket used to receive the
+// messages of a publisher.
+type testSubscriber struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialTestSubscriber connects a subscriber to the passed address and performs
+// the handshake.
+func dialTestSubscriber(t *testing.T, addr string) *testSubscriber {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	s := &testSubscriber{conn: conn, r: bufio.NewReader(conn)}
+
+	if _, err := conn.Write(greeting); err != nil {
+		t.Fatalf("failed to send greeting: %v", err)
+	}
+	peerGreeting := make([]byte, greetingLen)
+	if _, err := io.ReadFull(s.r, peerGreeting); err != nil {
+		t.Fatalf("failed to read greeting: %v", err)
+	}
+	if err := checkGreeting(peerGreeting); err != nil {
+		t.Fatalf("invalid greeting: %v", err)
+	}
+
+	ready := []byte{11}
+	ready = append(ready, "Socket-Type"...)
+	ready = append(ready, 0, 0, 0, 3)
+	ready = append(ready, "SUB"...)
+	if _, err := conn.Write(encodeCommand("READY", ready)); err != nil {
+		t.Fatalf("failed to send READY: %v", err)
+	}
+	flags, body, err := readFrame(s.r)
+	if err != nil {
+		t.Fatalf("failed to read READY: %v", err)
+	}
+	name, data, err := parseCommand(body)
+	if flags&flagCommand == 0 || err != nil || name != "READY" {
+		t.Fatalf("unexpected command %q (flags %x, error %v)", name,
+			flags, err)
+	}
+	if !bytes.Contains(data, []byte("PUB")) {
+		t.Fatalf("unexpected READY properties %q", data)
+	}
+	return s
+}
+
+// subscribe subscribes to the topics with the passed prefix.
+func (s *testSubscriber) subscribe(t *testing.T, prefix string) {
+	msg := append([]byte{1}, prefix...)
+	if _, err := s.conn.Write(encodeMessage(msg)); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+}
+
+// receive reads a message and returns its topic, body and sequence number.
+func (s *testSubscriber) receive(t *testing.T) (string, []byte, uint32) {
+	var parts [][]byte
+	for {
+		flags, body, err := readFrame(s.r)
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		parts = append(parts, body)
+		if flags&flagMore == 0 {
+			break
+		}
+	}
+	if len(parts) != 3 || len(parts[2]) != 4 {
+		t.Fatalf("unexpected message parts %x", parts)
+	}
+	return string(parts[0]), parts[1],
+		binary.LittleEndian.Uint32(parts[2])
+}
+
+// waitSubscribed waits until a subscriber of the passed socket subscribed to
+// the topic.
+func waitSubscribed(t *testing.T, socket *pubSocket, topic string) {
+	for i := 0; i < 500; i++ {
+		socket.mtx.Lock()
+		for s := range socket.subscribers {
+			if s.subscribed(topic) {
+				socket.mtx.Unlock()
+				return
+			}
+		}
+		socket.mtx.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no subscriber subscribed to %s", topic)
+}
+
+// TestPublisher ensures subscribers receive the messages of the topics they
+// subscribed to with sequence numbers counted per topic.
+func TestPublisher(t *testing.T) {
+	p, err := New(&Config{
+		Endpoints: map[string]string{
+			TopicHashBlock: "tcp://127.0.0.1:0",
+			TopicHashTx:    "tcp://127.0.0.1:0",
+			TopicRawTx:     "tcp://127.0.0.1:0",
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if len(p.sockets) != 1 {
+		t.Fatalf("topics sharing an endpoint use %d sockets",
+			len(p.sockets))
+	}
+	p.Start()
+	defer p.Stop()
+
+	socket := p.sockets[0]
+	s := dialTestSubscriber(t, socket.listener.Addr().String())
+	s.subscribe(t, "hash")
+	waitSubscribed(t, socket, TopicHashTx)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil,
+		nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	utx := ulordutil.NewTx(tx)
+
+	// Raw transactions must not be received since they were not
+	// subscribed to and transaction locks are not published at all.
+	p.NotifyNewTransaction(utx)
+	p.NotifyTxLocked(utx)
+	p.NotifyNewTransaction(utx)
+
+	// Hashes are published in the byte order they are displayed in.
+	wantBody, _ := hex.DecodeString(utx.Hash().String())
+	for i := uint32(0); i < 2; i++ {
+		topic, body, seq := s.receive(t)
+		if topic != TopicHashTx || !bytes.Equal(body, wantBody) ||
+			seq != i {
+
+			t.Fatalf("unexpected message: topic %s, body %x, "+
+				"sequence %d", topic, body, seq)
+		}
+	}
+
+	// Connected blocks are published along with their transactions.
+	block := ulordutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{tx},
+	})
+	p.NotifyBlockConnected(block)
+	wants := []struct {
+		topic string
+		seq   uint32
+	}{
+		{TopicHashBlock, 0},
+		{TopicHashTx, 2},
+	}
+	for _, want := range wants {
+		topic, _, seq := s.receive(t)
+		if topic != want.topic || seq != want.seq {
+			t.Fatalf("unexpected message: topic %s, sequence %d, "+
+				"want topic %s, sequence %d", topic, seq,
+				want.topic, want.seq)
+		}
+	}
+}
+
+// TestNewPublisherErrors ensures invalid endpoints and unknown topics are
+// rejected.
+func TestNewPublisherErrors(t *testing.T) {
+	tests := []map[string]string{
+		{TopicHashBlock: "ipc:///tmp/ulord.sock"},
+		{"hashfoo": "tcp://127.0.0.1:0"},
+	}
+	for _, endpoints := range tests {
+		if _, err := New(&Config{Endpoints: endpoints}); err == nil {
+			t.Errorf("New: did not fail with endpoints %v", endpoints)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// greetingLen is the length of the greeting exchanged at the start of
+	// a ZMTP 3.0 connection.
+	greetingLen = 64
+
+	// handshakeTimeout is the amount of time a subscriber has to complete
+	// the greeting and handshake.
+	handshakeTimeout = 10 * time.Second
+
+	// writeTimeout is the amount of time after which writing a message to
+	// a subscriber times out.
+	writeTimeout = 30 * time.Second
+
+	// maxFrameSize is the maximum size of a frame sent by a subscriber.
+	// Subscribers only send subscriptions and commands, so anything larger
+	// is not expected.
+	maxFrameSize = 64 * 1024
+
+	// sendQueueSize is the maximum number of messages which are queued for
+	// a subscriber.  Messages are dropped for subscribers which are not
+	// able to keep up, just like the high water mark of ZeroMQ publish
+	// sockets, which subscribers detect by the gap in sequence numbers.
+	sendQueueSize = 1000
+)
+
+// These constants define the bits of the flags of a ZMTP frame.
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// greeting is the ZMTP 3.0 greeting sent to subscribers.  It consists of the
+// signature, the protocol version, the NULL security mechanism and the
+// as-server flag followed by filler.
+var greeting = func() []byte {
+	g := make([]byte, greetingLen)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3
+	g[11] = 0
+	copy(g[12:32], "NULL")
+	return g
+}()
+
+// checkGreeting returns an error if the passed greeting of a peer is not a
+// ZMTP 3.x greeting using the NULL security mechanism.
+func checkGreeting(g []byte) error {
+	if g[0] != 0xff || g[9]&0x01 != 0x01 {
+		return errors.New("invalid ZMTP signature")
+	}
+	if g[10] < 3 {
+		return fmt.Errorf("unsupported ZMTP version %d.%d", g[10], g[11])
+	}
+	mechanism := string(bytes.TrimRight(g[12:32], "\x00"))
+	if mechanism != "NULL" {
+		return fmt.Errorf("unsupported security mechanism %q", mechanism)
+	}
+	return nil
+}
+
+// appendFrame appends the passed frame with the provided flags to the buffer.
+// The long flag is set as needed.
+func appendFrame(buf []byte, flags byte, body []byte) []byte {
+	if len(body) > 255 {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		buf = append(buf, flags|flagLong)
+		buf = append(buf, size[:]...)
+	} else {
+		buf = append(buf, flags, byte(len(body)))
+	}
+	return append(buf, body...)
+}
+
+// encodeMessage returns the frames of a multipart message with the passed
+// parts.
+func encodeMessage(parts ...[]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += 9 + len(part)
+	}
+	buf := make([]byte, 0, size)
+	for i, part := range parts {
+		var flags byte
+		if i < len(parts)-1 {
+			flags = flagMore
+		}
+		buf = appendFrame(buf, flags, part)
+	}
+	return buf
+}
+
+// encodeCommand returns the frame of a command with the passed name and data.
+func encodeCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+	return appendFrame(nil, flagCommand, body)
+}
+
+// readyCommand returns the READY command of a publish socket.
+func readyCommand() []byte {
+	const name, value = "Socket-Type", "PUB"
+	data := make([]byte, 0, 1+len(name)+4+len(value))
+	data = append(data, byte(len(name)))
+	data = append(data, name...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(value)))
+	data = append(data, size[:]...)
+	data = append(data, value...)
+	return encodeCommand("READY", data)
+}
+
+// readFrame reads a frame from the passed reader and returns its flags and
+// body.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return 0, nil, err
+	}
+	flags := header[0]
+	size := uint64(header[1])
+	if flags&flagLong != 0 {
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(header[1:])
+	}
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the "+
+			"maximum of %d bytes", size, maxFrameSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// parseCommand splits the body of a command frame into its name and data.
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return "", nil, errors.New("malformed command")
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:], nil
+}
+
+// subscriber houses the state of a connection of a ZeroMQ subscribe socket.
+type subscriber struct {
+	conn  net.Conn
+	addr  string
+	queue chan *message
+	quit  chan struct{}
+
+	mtx           sync.Mutex
+	subscriptions map[string]int
+}
+
+// subscribed returns whether the subscriber has a subscription which is a
+// prefix of the passed topic.
+func (s *subscriber) subscribed(topic string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for prefix := range s.subscriptions {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe adds or, when subscribe is false, removes a subscription to the
+// topics with the passed prefix.  Subscriptions are counted, so a prefix has
+// to be unsubscribed as often as it was subscribed.
+func (s *subscriber) subscribe(prefix string, subscribe bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if subscribe {
+		s.subscriptions[prefix]++
+		log.Debugf("ZMQ subscriber %s subscribed to %q", s.addr, prefix)
+		return
+	}
+	if s.subscriptions[prefix] <= 1 {
+		delete(s.subscriptions, prefix)
+	} else {
+		s.subscriptions[prefix]--
+	}
+}
+
+// handshake exchanges the greeting and READY commands with the subscriber.
+func (s *subscriber) handshake(r io.Reader) error {
+	s.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer s.conn.SetDeadline(time.Time{})
+
+	if _, err := s.conn.Write(greeting); err != nil {
+		return err
+	}
+	peerGreeting := make([]byte, greetingLen)
+	if _, err := io.ReadFull(r, peerGreeting); err != nil {
+		return err
+	}
+	if err := checkGreeting(peerGreeting); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Write(readyCommand()); err != nil {
+		return err
+	}
+	flags, body, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags&flagCommand == 0 {
+		return errors.New("expected READY command")
+	}
+	name, _, err := parseCommand(body)
+	if err != nil {
+		return err
+	}
+	if name != "READY" {
+		return fmt.Errorf("expected READY command, got %q", name)
+	}
+	return nil
+}
+
+// inHandler reads the subscriptions of the subscriber until it disconnects.
+// ZMTP 3.0 subscriptions are messages starting with 1 for subscribing and 0
+// for unsubscribing followed by the topic prefix.  The SUBSCRIBE and CANCEL
+// commands of ZMTP 3.1 are supported as well.
+func (s *subscriber) inHandler(r io.Reader) error {
+	var more bool
+	for {
+		flags, body, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(body)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "SUBSCRIBE":
+				s.subscribe(string(data), true)
+			case "CANCEL":
+				s.subscribe(string(data), false)
+			}
+			continue
+		}
+
+		// Only the first frame of a message carries a subscription.
+		first := !more
+		more = flags&flagMore != 0
+		if !first || len(body) == 0 {
+			continue
+		}
+		switch body[0] {
+		case 1:
+			s.subscribe(string(body[1:]), true)
+		case 0:
+			s.subscribe(string(body[1:]), false)
+		}
+	}
+}
+
+// outHandler writes the queued messages to the subscriber until it
+// disconnects.
+func (s *subscriber) outHandler() {
+	for {
+		select {
+		case msg := <-s.queue:
+			s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := s.conn.Write(msg.frames); err != nil {
+				log.Debugf("Failed to write to ZMQ subscriber "+
+					"%s: %v", s.addr, err)
+				s.conn.Close()
+				return
+			}
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// pubSocket is a ZeroMQ publish socket which accepts subscribers on a listener
+// and sends them the messages of the topics they subscribed to.  It speaks
+// ZMTP 3.0 with the NULL security mechanism, so any ZeroMQ subscribe socket is
+// able to connect to it.
+type pubSocket struct {
+	listener net.Listener
+
+	mtx         sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// newPubSocket returns a publish socket accepting subscribers on the passed
+// listener.
+func newPubSocket(listener net.Listener) *pubSocket {
+	return &pubSocket{
+		listener:    listener,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// acceptHandler accepts subscribers until the listener is closed.
+//
+// This function MUST be run as a goroutine.
+func (ps *pubSocket) acceptHandler(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		conn, err := ps.listener.Accept()
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+				time.Sleep(time.Second)
+				continue
+			}
+			return
+		}
+
+		wg.Add(1)
+		go ps.handleSubscriber(conn, wg)
+	}
+}
+
+// handleSubscriber performs the handshake with the subscriber on the passed
+// connection and handles its subscriptions until it disconnects.
+//
+// This function MUST be run as a goroutine.
+func (ps *pubSocket) handleSubscriber(conn net.Conn, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	s := &subscriber{
+		conn:          conn,
+		addr:          conn.RemoteAddr().String(),
+		queue:         make(chan *message, sendQueueSize),
+		quit:          make(chan struct{}),
+		subscriptions: make(map[string]int),
+	}
+	r := bufio.NewReader(conn)
+	if err := s.handshake(r); err != nil {
+		log.Debugf("ZMQ handshake with %s failed: %v", s.addr, err)
+		conn.Close()
+		return
+	}
+	log.Debugf("New ZMQ subscriber %s on %s", s.addr, ps.listener.Addr())
+
+	ps.mtx.Lock()
+	ps.subscribers[s] = struct{}{}
+	ps.mtx.Unlock()
+
+	wg.Add(1)
+	go func() {
+		s.outHandler()
+		wg.Done()
+	}()
+
+	err := s.inHandler(r)
+	log.Debugf("ZMQ subscriber %s disconnected: %v", s.addr, err)
+
+	ps.mtx.Lock()
+	delete(ps.subscribers, s)
+	ps.mtx.Unlock()
+	close(s.quit)
+	conn.Close()
+}
+
+// send queues the passed message for all subscribers of its topic.  The
+// message is dropped for subscribers whose queue is full.
+func (ps *pubSocket) send(msg *message) {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	for s := range ps.subscribers {
+		if !s.subscribed(msg.topic) {
+			continue
+		}
+		select {
+		case s.queue <- msg:
+		default:
+			log.Debugf("Dropping %s message %d for slow ZMQ "+
+				"subscriber %s", msg.topic, msg.sequence, s.addr)
+		}
+	}
+}
+
+// close stops accepting subscribers and disconnects all of them.
+func (ps *pubSocket) close() {
+	ps.listener.Close()
+
+	ps.mtx.Lock()
+	for s := range ps.subscribers {
+		s.conn.Close()
+	}
+	ps.mtx.Unlock()
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrbook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/base58"
+)
+
+// Version is the version of the format written by Book.Write.
+const Version = 1
+
+var (
+	// ErrUnsupportedVersion is returned when reading an address book
+	// written in a format version this package does not know about.
+	ErrUnsupportedVersion = errors.New("unsupported address book version")
+
+	// ErrWrongNetwork is returned when reading an address book written for
+	// another network, or when adding an address of another network.
+	ErrWrongNetwork = errors.New("address book is for another network")
+)
+
+// Purpose describes why an address is in an address book.
+type Purpose string
+
+const (
+	// PurposeReceive marks an address of the wallet receiving payments.
+	PurposeReceive Purpose = "receive"
+
+	// PurposeSend marks an address the wallet sends payments to.
+	PurposeSend Purpose = "send"
+)
+
+// Entry is a labeled address of an address book.
+type Entry struct {
+	// Address is the labeled address.
+	Address ulordutil.Address
+
+	// Label is the label of the address, which may be empty.
+	Label string
+
+	// Created is the time the address was added to the address book.  It
+	// is stored with a precision of one second.
+	Created time.Time
+
+	// Purpose describes why the address is in the address book.
+	Purpose Purpose
+
+	// WatchOnly marks addresses the wallet watches without having the keys
+	// to spend from them.
+	WatchOnly bool
+}
+
+// Book is a set of labeled addresses of one network, keyed by their encoded
+// addresses.
+//
+// All functions are safe for concurrent access.
+type Book struct {
+	mtx     sync.RWMutex
+	net     *chaincfg.Params
+	entries map[string]Entry
+}
+
+// New returns an empty address book for addresses of the passed network.
+func New(net *chaincfg.Params) *Book {
+	return &Book{
+		net:     net,
+		entries: make(map[string]Entry),
+	}
+}
+
+// Put adds the passed entry to the address book, replacing any entry of the
+// same address.  ErrWrongNetwork is returned when the address is not for the
+// network of the address book.
+func (b *Book) Put(entry Entry) error {
+	if entry.Address == nil {
+		return errors.New("address book entry without address")
+	}
+	if !entry.Address.IsForNet(b.net) {
+		return ErrWrongNetwork
+	}
+	entry.Created = entry.Created.Truncate(time.Second)
+
+	b.mtx.Lock()
+	b.entries[entry.Address.EncodeAddress()] = entry
+	b.mtx.Unlock()
+	return nil
+}
+
+// Get returns the entry of the passed address and whether it is in the address
+// book.
+func (b *Book) Get(addr ulordutil.Address) (Entry, bool) {
+	b.mtx.RLock()
+	entry, ok := b.entries[addr.EncodeAddress()]
+	b.mtx.RUnlock()
+	return entry, ok
+}
+
+// SetLabel changes the label of the passed address, adding a receiving address
+// created now when it is not in the address book yet.
+func (b *Book) SetLabel(addr ulordutil.Address, label string) error {
+	if !addr.IsForNet(b.net) {
+		return ErrWrongNetwork
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	key := addr.EncodeAddress()
+	entry, ok := b.entries[key]
+	if !ok {
+		entry = Entry{
+			Address: addr,
+			Created: time.Unix(time.Now().Unix(), 0),
+			Purpose: PurposeReceive,
+		}
+	}
+	entry.Label = label
+	b.entries[key] = entry
+	return nil
+}
+
+// Remove removes the passed address from the address book and returns whether
+// it was in it.
+func (b *Book) Remove(addr ulordutil.Address) bool {
+	key := addr.EncodeAddress()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if _, ok := b.entries[key]; !ok {
+		return false
+	}
+	delete(b.entries, key)
+	return true
+}
+
+// Len returns the number of addresses in the address book.
+func (b *Book) Len() int {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return len(b.entries)
+}
+
+// Entries returns all entries of the address book sorted by their encoded
+// addresses.
+func (b *Book) Entries() []Entry {
+	return b.filter(func(Entry) bool { return true })
+}
+
+// ByLabel returns the entries with the passed label sorted by their encoded
+// addresses.
+func (b *Book) ByLabel(label string) []Entry {
+	return b.filter(func(e Entry) bool { return e.Label == label })
+}
+
+// Labels returns the distinct labels of the entries of the address book in
+// sorted order.  Entries with the passed purpose are considered when it is
+// not empty, and all entries otherwise.
+func (b *Book) Labels(purpose Purpose) []string {
+	b.mtx.RLock()
+	seen := make(map[string]struct{})
+	for _, entry := range b.entries {
+		if purpose != "" && entry.Purpose != purpose {
+			continue
+		}
+		seen[entry.Label] = struct{}{}
+	}
+	b.mtx.RUnlock()
+
+	labels := make([]string, 0, len(seen))
+	for label := range seen {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// filter returns the entries matching the passed function sorted by their
+// encoded addresses.
+func (b *Book) filter(match func(Entry) bool) []Entry {
+	b.mtx.RLock()
+	keys := make([]string, 0, len(b.entries))
+	for key, entry := range b.entries {
+		if match(entry) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, b.entries[key])
+	}
+	b.mtx.RUnlock()
+	return entries
+}
+
+// serializedBook is the JSON representation of an address book.
+type serializedBook struct {
+	Version int               `json:"version"`
+	Net     string            `json:"net"`
+	Entries []serializedEntry `json:"entries"`
+}
+
+// serializedEntry is the JSON representation of an address book entry.
+type serializedEntry struct {
+	Address   string  `json:"address"`
+	Label     string  `json:"label"`
+	Created   int64   `json:"created"`
+	Purpose   Purpose `json:"purpose"`
+	WatchOnly bool    `json:"watchonly,omitempty"`
+}
+
+// Write writes the address book to w as a JSON object holding the format
+// version, the name of the network, and the entries sorted by their encoded
+// addresses with their creation times in seconds since the unix epoch.
+func (b *Book) Write(w io.Writer) error {
+	entries := b.Entries()
+	sb := serializedBook{
+		Version: Version,
+		Net:     b.net.Name,
+		Entries: make([]serializedEntry, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		sb.Entries = append(sb.Entries, serializedEntry{
+			Address:   entry.Address.EncodeAddress(),
+			Label:     entry.Label,
+			Created:   entry.Created.Unix(),
+			Purpose:   entry.Purpose,
+			WatchOnly: entry.WatchOnly,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(&sb)
+}
+
+// Read returns the address book written by Write to r.  ErrUnsupportedVersion
+// is returned when it was written in an unknown format version and
+// ErrWrongNetwork when it was written for a network other than the passed one.
+func Read(r io.Reader, net *chaincfg.Params) (*Book, error) {
+	var sb serializedBook
+	if err := json.NewDecoder(r).Decode(&sb); err != nil {
+		return nil, err
+	}
+	if sb.Version != Version {
+		return nil, ErrUnsupportedVersion
+	}
+	if sb.Net != net.Name {
+		return nil, ErrWrongNetwork
+	}
+
+	b := New(net)
+	for _, se := range sb.Entries {
+		addr, err := decodeAddress(se.Address, net)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v",
+				se.Address, err)
+		}
+		err = b.Put(Entry{
+			Address:   addr,
+			Label:     se.Label,
+			Created:   time.Unix(se.Created, 0),
+			Purpose:   se.Purpose,
+			WatchOnly: se.WatchOnly,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v",
+				se.Address, err)
+		}
+	}
+	return b, nil
+}
+
+// decodeAddress decodes the passed encoded address of the passed network.
+// Unlike ulordutil.DecodeAddress, it resolves base58 addresses whose version
+// byte is the pubkey hash ID of one registered network and the script hash ID
+// of another by the IDs of the passed network.
+func decodeAddress(encoded string, net *chaincfg.Params) (ulordutil.Address, error) {
+	addr, err := ulordutil.DecodeAddress(encoded, net)
+	if err != ulordutil.ErrAddressCollision {
+		return addr, err
+	}
+
+	hash160, netID, err := base58.CheckDecode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	switch netID {
+	case net.PubKeyHashAddrID:
+		return ulordutil.NewAddressPubKeyHash(hash160, net)
+	case net.ScriptHashAddrID:
+		return ulordutil.NewAddressScriptHashFromHash(hash160, net)
+	default:
+		return nil, ErrWrongNetwork
+	}
+}
+
+// WriteFile writes the address book with Write to the file at the passed path.
+// The file is replaced atomically by writing to a temporary file in the same
+// directory which is synced to disk and renamed, so an interrupted write never
+// leaves a truncated address book behind.
+func (b *Book) WriteFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	err = b.Write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// ReadFile returns the address book written by WriteFile to the file at the
+// passed path.  An empty address book is returned when the file does not exist.
+func ReadFile(path string, net *chaincfg.Params) (*Book, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return New(net), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f, net)
+}
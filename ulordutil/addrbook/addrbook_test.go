@@ -0,0 +1,179 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrbook_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/addrbook"
+)
+
+// testAddress returns a pay-to-pubkey-hash address of the passed network whose
+// hash is filled with the passed byte.
+func testAddress(t *testing.T, b byte, net *chaincfg.Params) ulordutil.Address {
+	addr, err := ulordutil.NewAddressPubKeyHash(bytes.Repeat([]byte{b}, 20), net)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	return addr
+}
+
+// TestBook ensures entries are added, queried, and removed as expected.
+func TestBook(t *testing.T) {
+	net := &chaincfg.SimNetParams
+	book := addrbook.New(net)
+
+	addr1 := testAddress(t, 1, net)
+	addr2 := testAddress(t, 2, net)
+	created := time.Unix(1538000000, 500)
+	err := book.Put(addrbook.Entry{
+		Address: addr1,
+		Label:   "savings",
+		Created: created,
+		Purpose: addrbook.PurposeReceive,
+	})
+	if err != nil {
+		t.Fatalf("Put: unexpected error: %v", err)
+	}
+	if err := book.SetLabel(addr2, "savings"); err != nil {
+		t.Fatalf("SetLabel: unexpected error: %v", err)
+	}
+
+	entry, ok := book.Get(addr1)
+	if !ok {
+		t.Fatal("Get: address not found")
+	}
+	if !entry.Created.Equal(time.Unix(1538000000, 0)) {
+		t.Fatalf("Get: got creation time %v, want it truncated to "+
+			"seconds", entry.Created)
+	}
+	entry, _ = book.Get(addr2)
+	if entry.Purpose != addrbook.PurposeReceive {
+		t.Fatalf("SetLabel: got purpose %q for new address",
+			entry.Purpose)
+	}
+
+	if got := len(book.ByLabel("savings")); got != 2 {
+		t.Fatalf("ByLabel: got %d entries, want 2", got)
+	}
+	if err := book.SetLabel(addr2, "spending"); err != nil {
+		t.Fatalf("SetLabel: unexpected error: %v", err)
+	}
+	want := []string{"savings", "spending"}
+	if got := book.Labels(""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Labels: got %v, want %v", got, want)
+	}
+	if got := book.Labels(addrbook.PurposeSend); len(got) != 0 {
+		t.Fatalf("Labels: got %v for send purpose", got)
+	}
+
+	err = book.Put(addrbook.Entry{
+		Address: testAddress(t, 3, &chaincfg.MainNetParams),
+	})
+	if err != addrbook.ErrWrongNetwork {
+		t.Fatalf("Put: got error %v, want %v", err,
+			addrbook.ErrWrongNetwork)
+	}
+
+	if !book.Remove(addr1) || book.Remove(addr1) {
+		t.Fatal("Remove: address removed more than once")
+	}
+	if book.Len() != 1 {
+		t.Fatalf("Len: got %d, want 1", book.Len())
+	}
+}
+
+// TestBookFile ensures address books round trip through their files and that
+// books of other versions or networks are rejected.
+func TestBookFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addrbook")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "addrbook.json")
+
+	net := &chaincfg.SimNetParams
+	book, err := addrbook.ReadFile(path, net)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error for missing file: %v", err)
+	}
+	if book.Len() != 0 {
+		t.Fatalf("ReadFile: got %d entries for missing file", book.Len())
+	}
+
+	entries := []addrbook.Entry{{
+		Address: testAddress(t, 1, net),
+		Label:   "savings",
+		Created: time.Unix(1538000000, 0),
+		Purpose: addrbook.PurposeReceive,
+	}, {
+		Address:   testAddress(t, 2, net),
+		Label:     "exchange",
+		Created:   time.Unix(1538000100, 0),
+		Purpose:   addrbook.PurposeSend,
+		WatchOnly: true,
+	}}
+	for _, entry := range entries {
+		if err := book.Put(entry); err != nil {
+			t.Fatalf("Put: unexpected error: %v", err)
+		}
+	}
+
+	// Write the book twice to ensure existing files are replaced.
+	for i := 0; i < 2; i++ {
+		if err := book.WriteFile(path); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read dir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("WriteFile: left %d files behind", len(files))
+	}
+
+	read, err := addrbook.ReadFile(path, net)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	got := read.Entries()
+	if len(got) != len(entries) {
+		t.Fatalf("ReadFile: got %d entries, want %d", len(got),
+			len(entries))
+	}
+	for i, entry := range got {
+		want := entries[i]
+		if entry.Address.EncodeAddress() != want.Address.EncodeAddress() ||
+			entry.Label != want.Label ||
+			!entry.Created.Equal(want.Created) ||
+			entry.Purpose != want.Purpose ||
+			entry.WatchOnly != want.WatchOnly {
+
+			t.Errorf("ReadFile: entry %d: got %+v, want %+v", i,
+				entry, want)
+		}
+	}
+
+	if _, err := addrbook.ReadFile(path, &chaincfg.MainNetParams); err != addrbook.ErrWrongNetwork {
+		t.Fatalf("ReadFile: got error %v, want %v", err,
+			addrbook.ErrWrongNetwork)
+	}
+	_, err = addrbook.Read(strings.NewReader(`{"version":2,"net":"simnet"}`), net)
+	if err != addrbook.ErrUnsupportedVersion {
+		t.Fatalf("Read: got error %v, want %v", err,
+			addrbook.ErrUnsupportedVersion)
+	}
+}
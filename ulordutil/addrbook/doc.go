@@ -0,0 +1,22 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package addrbook provides an address book of labeled addresses and its on-disk
+format.
+
+Each entry of a Book holds an address of the network of the book along with its
+label, the time it was added, its purpose, and whether it is watch-only.  Books
+can be queried by address and by label, which is what the label RPCs of a
+wallet such as setlabel, getaddressesbylabel, and listlabels need.
+
+File Format
+
+Books are stored as a JSON object holding the format version, the name of the
+network, and the entries sorted by address.  Files written by WriteFile are
+replaced atomically, so a crash while saving an address book leaves either the
+old or the new book on disk.  Reading a book written in an unknown version or
+for another network fails with ErrUnsupportedVersion or ErrWrongNetwork.
+*/
+package addrbook
@@ -0,0 +1,142 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+// These constants are the values of the opcodes used by the standard scripts
+// produced by AddressScript.  They are defined here since the txscript
+// package depends on this one.
+const (
+	op0           = 0x00
+	opDup         = 0x76
+	opEqual       = 0x87
+	opEqualVerify = 0x88
+	opHash160     = 0xa9
+	opCheckSig    = 0xac
+)
+
+// AddressType describes the kind of output script an Address pays to.
+type AddressType byte
+
+// These constants define the address types recognized by ClassifyAddress.
+const (
+	// NonStandardAddr is the type of addresses that are not one of the
+	// standard types.
+	NonStandardAddr AddressType = iota
+
+	// PubKeyAddr is the type of pay-to-pubkey (P2PK) addresses.
+	PubKeyAddr
+
+	// PubKeyHashAddr is the type of pay-to-pubkey-hash (P2PKH) addresses.
+	PubKeyHashAddr
+
+	// ScriptHashAddr is the type of pay-to-script-hash (P2SH) addresses.
+	ScriptHashAddr
+
+	// WitnessV0PubKeyHashAddr is the type of version 0
+	// pay-to-witness-pubkey-hash (P2WPKH) addresses.
+	WitnessV0PubKeyHashAddr
+
+	// WitnessV0ScriptHashAddr is the type of version 0
+	// pay-to-witness-script-hash (P2WSH) addresses.
+	WitnessV0ScriptHashAddr
+)
+
+// addressTypeToName houses the human-readable strings which describe each
+// address type.
+var addressTypeToName = []string{
+	NonStandardAddr:         "nonstandard",
+	PubKeyAddr:              "pubkey",
+	PubKeyHashAddr:          "pubkeyhash",
+	ScriptHashAddr:          "scripthash",
+	WitnessV0PubKeyHashAddr: "witness_v0_keyhash",
+	WitnessV0ScriptHashAddr: "witness_v0_scripthash",
+}
+
+// String implements the Stringer interface by returning the name of the
+// address type.  The names match those of the corresponding txscript script
+// classes.
+func (t AddressType) String() string {
+	if int(t) >= len(addressTypeToName) {
+		return "Invalid"
+	}
+	return addressTypeToName[t]
+}
+
+// IsWitness returns whether or not outputs paying to addresses of the type
+// are spent with witness data.
+func (t AddressType) IsWitness() bool {
+	return t == WitnessV0PubKeyHashAddr || t == WitnessV0ScriptHashAddr
+}
+
+// ClassifyAddress returns the type of the passed address.  NonStandardAddr is
+// returned for address implementations outside of this package.
+func ClassifyAddress(addr Address) AddressType {
+	switch addr.(type) {
+	case *AddressPubKey:
+		return PubKeyAddr
+	case *AddressPubKeyHash:
+		return PubKeyHashAddr
+	case *AddressScriptHash:
+		return ScriptHashAddr
+	case *AddressWitnessPubKeyHash:
+		return WitnessV0PubKeyHashAddr
+	case *AddressWitnessScriptHash:
+		return WitnessV0ScriptHashAddr
+	}
+	return NonStandardAddr
+}
+
+// AddressScript returns the canonical public key script paying to the passed
+// address.  The script is identical to the one created by
+// txscript.PayToAddrScript, which allows callers that only need the script
+// bytes to avoid depending on the txscript package.  ErrUnknownAddressType is
+// returned for nonstandard addresses.
+func AddressScript(addr Address) ([]byte, error) {
+	addrType := ClassifyAddress(addr)
+	if addrType == NonStandardAddr {
+		return nil, ErrUnknownAddressType
+	}
+
+	data := addr.ScriptAddress()
+	switch addrType {
+	case PubKeyAddr:
+		// <pubkey> OP_CHECKSIG
+		script := make([]byte, 0, len(data)+2)
+		script = append(script, byte(len(data)))
+		script = append(script, data...)
+		return append(script, opCheckSig), nil
+
+	case PubKeyHashAddr:
+		// OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG
+		script := make([]byte, 0, len(data)+5)
+		script = append(script, opDup, opHash160, byte(len(data)))
+		script = append(script, data...)
+		return append(script, opEqualVerify, opCheckSig), nil
+
+	case ScriptHashAddr:
+		// OP_HASH160 <hash> OP_EQUAL
+		script := make([]byte, 0, len(data)+3)
+		script = append(script, opHash160, byte(len(data)))
+		script = append(script, data...)
+		return append(script, opEqual), nil
+
+	case WitnessV0PubKeyHashAddr, WitnessV0ScriptHashAddr:
+		// OP_0 <program>
+		script := make([]byte, 0, len(data)+2)
+		script = append(script, op0, byte(len(data)))
+		return append(script, data...), nil
+	}
+
+	return nil, ErrUnknownAddressType
+}
+
+// IsMasternodeCollateral returns whether an output of the passed amount paying
+// to the passed address is usable as the collateral of a masternode.  The
+// collateral must pay exactly MasternodeCollateral to a pay-to-pubkey-hash
+// address, since masternodes sign with the key controlling the collateral.
+func IsMasternodeCollateral(addr Address, amount Amount) bool {
+	return ClassifyAddress(addr) == PubKeyHashAddr &&
+		amount == MasternodeCollateral
+}
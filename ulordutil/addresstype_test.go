@@ -0,0 +1,186 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// mustDecodeHex decodes the passed hex string, panicking on failure.  It is
+// only used with hard-coded values.
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// testAddresses returns a mainnet address of every standard address type,
+// keyed by a short name.  The addresses are created from their hashes since
+// the encoding of some mainnet pay-to-script-hash addresses is ambiguous.
+func testAddresses() (map[string]ulordutil.Address, error) {
+	net := &chaincfg.MainNetParams
+	addrs := make(map[string]ulordutil.Address)
+
+	var err error
+	addrs["p2pkh"], err = ulordutil.NewAddressPubKeyHash(
+		mustDecodeHex("e34cce70c86373273efcc54ce7d2a491bb4a0e84"), net)
+	if err != nil {
+		return nil, err
+	}
+	addrs["p2sh"], err = ulordutil.NewAddressScriptHashFromHash(
+		mustDecodeHex("f815b036d9bbbce5e9f2a00abd1bf3dc91e95510"), net)
+	if err != nil {
+		return nil, err
+	}
+	addrs["compressed p2pk"], err = ulordutil.NewAddressPubKey(
+		mustDecodeHex("02192d74d0cb94344c9569c2e77901573d8d7903c3ebec3a9"+
+			"57724895dca52c6b4"), net)
+	if err != nil {
+		return nil, err
+	}
+	addrs["uncompressed p2pk"], err = ulordutil.NewAddressPubKey(
+		mustDecodeHex("0411db93e1dcdb8a016b49840f8c53bc1eb68a382e97b1482"+
+			"ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e160bfa9b8b64f9"+
+			"d4c03f999b8643f656b412a3"), net)
+	if err != nil {
+		return nil, err
+	}
+	addrs["p2wpkh"], err = ulordutil.NewAddressWitnessPubKeyHash(
+		mustDecodeHex("751e76e8199196d454941c45d1b3a323f1433bd6"), net)
+	if err != nil {
+		return nil, err
+	}
+	addrs["p2wsh"], err = ulordutil.NewAddressWitnessScriptHash(
+		mustDecodeHex("1863143c14c5166804bd19203356da136c985678cd4d27a1b"+
+			"8c6329604903262"), net)
+	if err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// TestClassifyAddress ensures addresses are classified as expected and that
+// their canonical scripts match the ones created by txscript.
+func TestClassifyAddress(t *testing.T) {
+	addrs, err := testAddresses()
+	if err != nil {
+		t.Fatalf("testAddresses: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		addrType  ulordutil.AddressType
+		isWitness bool
+	}{
+		{
+			name:     "p2pkh",
+			addrType: ulordutil.PubKeyHashAddr,
+		},
+		{
+			name:     "p2sh",
+			addrType: ulordutil.ScriptHashAddr,
+		},
+		{
+			name:     "compressed p2pk",
+			addrType: ulordutil.PubKeyAddr,
+		},
+		{
+			name:     "uncompressed p2pk",
+			addrType: ulordutil.PubKeyAddr,
+		},
+		{
+			name:      "p2wpkh",
+			addrType:  ulordutil.WitnessV0PubKeyHashAddr,
+			isWitness: true,
+		},
+		{
+			name:      "p2wsh",
+			addrType:  ulordutil.WitnessV0ScriptHashAddr,
+			isWitness: true,
+		},
+	}
+
+	for i, test := range tests {
+		addr := addrs[test.name]
+		addrType := ulordutil.ClassifyAddress(addr)
+		if addrType != test.addrType {
+			t.Errorf("ClassifyAddress #%d (%s): got %v, want %v", i,
+				test.name, addrType, test.addrType)
+			continue
+		}
+		if addrType.IsWitness() != test.isWitness {
+			t.Errorf("IsWitness #%d (%s): got %v, want %v", i,
+				test.name, addrType.IsWitness(), test.isWitness)
+		}
+
+		script, err := ulordutil.AddressScript(addr)
+		if err != nil {
+			t.Errorf("AddressScript #%d (%s): unexpected error: %v",
+				i, test.name, err)
+			continue
+		}
+		want, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Errorf("PayToAddrScript #%d (%s): unexpected error: %v",
+				i, test.name, err)
+			continue
+		}
+		if !bytes.Equal(script, want) {
+			t.Errorf("AddressScript #%d (%s): got %x, want %x", i,
+				test.name, script, want)
+			continue
+		}
+
+		// The type names match the txscript script classes.
+		class := txscript.GetScriptClass(script)
+		if addrType.String() != class.String() {
+			t.Errorf("String #%d (%s): got %v, want %v", i,
+				test.name, addrType, class)
+		}
+	}
+
+	if _, err := ulordutil.AddressScript(nil); err != ulordutil.ErrUnknownAddressType {
+		t.Errorf("AddressScript: unexpected error -- got %v, want %v",
+			err, ulordutil.ErrUnknownAddressType)
+	}
+}
+
+// TestIsMasternodeCollateral ensures only pay-to-pubkey-hash outputs of the
+// exact collateral amount are considered masternode collateral.
+func TestIsMasternodeCollateral(t *testing.T) {
+	addrs, err := testAddresses()
+	if err != nil {
+		t.Fatalf("testAddresses: unexpected error: %v", err)
+	}
+	p2pkh, p2sh := addrs["p2pkh"], addrs["p2sh"]
+
+	tests := []struct {
+		name   string
+		addr   ulordutil.Address
+		amount ulordutil.Amount
+		want   bool
+	}{
+		{"p2pkh exact", p2pkh, ulordutil.MasternodeCollateral, true},
+		{"p2pkh less", p2pkh, ulordutil.MasternodeCollateral - 1, false},
+		{"p2pkh more", p2pkh, ulordutil.MasternodeCollateral + 1, false},
+		{"p2sh exact", p2sh, ulordutil.MasternodeCollateral, false},
+	}
+
+	for i, test := range tests {
+		got := ulordutil.IsMasternodeCollateral(test.addr, test.amount)
+		if got != test.want {
+			t.Errorf("IsMasternodeCollateral #%d (%s): got %v, want %v",
+				i, test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+)
+
+// FullPrecision is the AmountFormatter precision which formats amounts with
+// as many decimal places as needed to represent a single satoshi in the unit
+// of the formatter.
+const FullPrecision = -1
+
+// AmountFormatter formats amounts for reports and tables.  Unlike
+// Amount.Format, amounts are formatted with exact decimal arithmetic, so no
+// precision is lost for large amounts.  Create an AmountFormatter with
+// NewAmountFormatter and adjust the exported fields as needed.
+type AmountFormatter struct {
+	// Unit is the unit amounts are formatted in.
+	Unit AmountUnit
+
+	// Precision is the number of decimal places amounts are rounded to, or
+	// FullPrecision.  Amounts are rounded half away from zero.
+	Precision int
+
+	// TrimZeros removes trailing zeros from the decimal places, along with
+	// the decimal point when no decimal places remain.
+	TrimZeros bool
+
+	// Accounting formats negative amounts in parentheses rather than with
+	// a leading minus sign.
+	Accounting bool
+
+	// ThousandsSeparator is inserted between each group of three digits of
+	// the integral part when it is not empty.
+	ThousandsSeparator string
+
+	// ShowUnit appends the label of the unit.
+	ShowUnit bool
+}
+
+// NewAmountFormatter returns a new AmountFormatter for the passed unit which
+// formats amounts with full precision and without any decoration.
+func NewAmountFormatter(u AmountUnit) *AmountFormatter {
+	return &AmountFormatter{
+		Unit:      u,
+		Precision: FullPrecision,
+	}
+}
+
+// decimal returns the absolute value of the amount as a decimal number in the
+// unit of the formatter, rounded to its precision and with trailing zeros
+// trimmed when requested.  The returned fraction is empty when there are no
+// decimal places.
+func (f *AmountFormatter) decimal(a Amount) (string, string) {
+	// Using an unsigned value avoids overflowing when negating the
+	// smallest amount.
+	abs := uint64(a)
+	if a < 0 {
+		abs = -abs
+	}
+	v := new(big.Int).SetUint64(abs)
+
+	// The scale is the number of decimal places needed to represent a
+	// single satoshi in the unit.
+	scale := int(f.Unit) + 8
+	if scale < 0 {
+		mul := new(big.Int).Exp(big.NewInt(10),
+			big.NewInt(int64(-scale)), nil)
+		v.Mul(v, mul)
+		scale = 0
+	}
+	if f.Precision >= 0 && f.Precision < scale {
+		div := new(big.Int).Exp(big.NewInt(10),
+			big.NewInt(int64(scale-f.Precision)), nil)
+		v.Add(v, new(big.Int).Rsh(div, 1))
+		v.Quo(v, div)
+		scale = f.Precision
+	}
+
+	digits := v.String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	integral := digits[:len(digits)-scale]
+	fraction := digits[len(digits)-scale:]
+	if f.Precision > scale {
+		fraction += strings.Repeat("0", f.Precision-scale)
+	}
+	if f.TrimZeros {
+		fraction = strings.TrimRight(fraction, "0")
+	}
+	return integral, fraction
+}
+
+// Format returns the amount formatted for display according to the settings
+// of the formatter.  Amounts which round to zero are never formatted as
+// negative.
+func (f *AmountFormatter) Format(a Amount) string {
+	integral, fraction := f.decimal(a)
+	negative := a < 0 && strings.Trim(integral+fraction, "0") != ""
+
+	var buf bytes.Buffer
+	switch {
+	case negative && f.Accounting:
+		buf.WriteByte('(')
+	case negative:
+		buf.WriteByte('-')
+	}
+
+	// Group the integral part from the right.
+	for i := 0; i < len(integral); i++ {
+		if i > 0 && f.ThousandsSeparator != "" && (len(integral)-i)%3 == 0 {
+			buf.WriteString(f.ThousandsSeparator)
+		}
+		buf.WriteByte(integral[i])
+	}
+	if fraction != "" {
+		buf.WriteByte('.')
+		buf.WriteString(fraction)
+	}
+
+	if negative && f.Accounting {
+		buf.WriteByte(')')
+	}
+	if f.ShowUnit {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Unit.String())
+	}
+	return buf.String()
+}
+
+// FormatColumn formats each of the amounts and pads them with leading spaces
+// to the same width, so they line up when printed as a column of a table.
+func (f *AmountFormatter) FormatColumn(amounts []Amount) []string {
+	column := make([]string, len(amounts))
+	var width int
+	for i, a := range amounts {
+		column[i] = f.Format(a)
+		if n := len([]rune(column[i])); n > width {
+			width = n
+		}
+	}
+	for i, s := range column {
+		column[i] = strings.Repeat(" ", width-len([]rune(s))) + s
+	}
+	return column
+}
+
+// MachineString returns the amount as a plain decimal number in the unit and
+// precision of the formatter.  Grouping, accounting style parentheses and the
+// unit label are never used, so the result can be parsed as a number by CSV
+// and JSON consumers.
+func (f *AmountFormatter) MachineString(a Amount) string {
+	plain := *f
+	plain.Accounting = false
+	plain.ThousandsSeparator = ""
+	plain.ShowUnit = false
+	return plain.Format(a)
+}
+
+// CSVRecord returns the amounts formatted with MachineString, suitable for
+// writing as a record with an encoding/csv Writer.
+func (f *AmountFormatter) CSVRecord(amounts []Amount) []string {
+	record := make([]string, len(amounts))
+	for i, a := range amounts {
+		record[i] = f.MachineString(a)
+	}
+	return record
+}
+
+// JSONNumber returns the amount formatted with MachineString as a json.Number
+// so it is encoded as a JSON number without losing precision to a float64.
+func (f *AmountFormatter) JSONNumber(a Amount) json.Number {
+	return json.Number(f.MachineString(a))
+}
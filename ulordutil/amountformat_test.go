@@ -0,0 +1,174 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	. "github.com/ulordsuite/ulordutil"
+)
+
+// TestAmountFormatter ensures amounts are formatted exactly with every
+// combination of settings.
+func TestAmountFormatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter AmountFormatter
+		amount    Amount
+		want      string
+	}{
+		{
+			name:      "full precision",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: FullPrecision},
+			amount:    123456789,
+			want:      "1.23456789",
+		},
+		{
+			name:      "max satoshi exact",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: FullPrecision},
+			amount:    MaxSatoshi - 1,
+			want:      "20999999.99999999",
+		},
+		{
+			name:      "min int64",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: FullPrecision},
+			amount:    math.MinInt64,
+			want:      "-92233720368.54775808",
+		},
+		{
+			name:      "rounded half away from zero",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: 2},
+			amount:    -1500000,
+			want:      "-0.02",
+		},
+		{
+			name:      "rounds to negative zero",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: 2},
+			amount:    -1,
+			want:      "0.00",
+		},
+		{
+			name:      "padded precision",
+			formatter: AmountFormatter{Unit: AmountSatoshi, Precision: 2},
+			amount:    5,
+			want:      "5.00",
+		},
+		{
+			name:      "no decimal places",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: 0},
+			amount:    250000000,
+			want:      "3",
+		},
+		{
+			name: "trimmed zeros",
+			formatter: AmountFormatter{Unit: AmountBTC,
+				Precision: FullPrecision, TrimZeros: true},
+			amount: 120000000,
+			want:   "1.2",
+		},
+		{
+			name: "trimmed point",
+			formatter: AmountFormatter{Unit: AmountBTC,
+				Precision: FullPrecision, TrimZeros: true},
+			amount: 300000000,
+			want:   "3",
+		},
+		{
+			name: "accounting",
+			formatter: AmountFormatter{Unit: AmountMilliBTC,
+				Precision: FullPrecision, Accounting: true},
+			amount: -123456,
+			want:   "(1.23456)",
+		},
+		{
+			name: "grouped with unit",
+			formatter: AmountFormatter{Unit: AmountBTC, Precision: 2,
+				ThousandsSeparator: ",", ShowUnit: true},
+			amount: 123456789012345,
+			want:   "1,234,567.89 BTC",
+		},
+		{
+			name: "grouped exact thousands",
+			formatter: AmountFormatter{Unit: AmountSatoshi,
+				Precision: FullPrecision, ThousandsSeparator: ","},
+			amount: -100000,
+			want:   "-100,000",
+		},
+		{
+			name:      "unit smaller than satoshi",
+			formatter: AmountFormatter{Unit: -10, Precision: FullPrecision},
+			amount:    12,
+			want:      "1200",
+		},
+	}
+
+	for i, test := range tests {
+		got := test.formatter.Format(test.amount)
+		if got != test.want {
+			t.Errorf("Format #%d (%s): got %q, want %q", i, test.name,
+				got, test.want)
+		}
+	}
+}
+
+// TestAmountFormatterMachine ensures the machine formats are undecorated and
+// usable with the csv and json packages.
+func TestAmountFormatterMachine(t *testing.T) {
+	f := NewAmountFormatter(AmountBTC)
+	f.Accounting = true
+	f.ThousandsSeparator = ","
+	f.ShowUnit = true
+	f.TrimZeros = true
+
+	amounts := []Amount{-123456789012345, 0, 100}
+	record := f.CSVRecord(amounts)
+	want := []string{"-1234567.89012345", "0", "0.000001"}
+	if !reflect.DeepEqual(record, want) {
+		t.Fatalf("CSVRecord: got %q, want %q", record, want)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	w.Flush()
+	if got := buf.String(); got != "-1234567.89012345,0,0.000001\n" {
+		t.Errorf("csv: got %q", got)
+	}
+
+	encoded, err := json.Marshal(map[string]json.Number{
+		"amount": f.JSONNumber(MaxSatoshi),
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got := string(encoded); got != `{"amount":21000000}` {
+		t.Errorf("JSONNumber: got %s", got)
+	}
+}
+
+// TestAmountFormatterColumn ensures formatted columns are right aligned.
+func TestAmountFormatterColumn(t *testing.T) {
+	f := NewAmountFormatter(AmountMicroBTC)
+	f.Precision = 1
+	f.Accounting = true
+	f.ShowUnit = true
+
+	got := f.FormatColumn([]Amount{100, -123456, 0})
+	want := []string{
+		"     1.0 μBTC",
+		"(1234.6) μBTC",
+		"     0.0 μBTC",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FormatColumn: got %q, want %q", got, want)
+	}
+}
@@ -33,3 +33,17 @@ func BenchmarkBase58Decode(b *testing.B) {
 		base58.Decode(encoded)
 	}
 }
+
+func BenchmarkCheckDecodeBatch(b *testing.B) {
+	b.StopTimer()
+	inputs := make([]string, 10000)
+	for i := range inputs {
+		data := bytes.Repeat([]byte{byte(i)}, 20)
+		inputs[i] = base58.CheckEncode(data, 0)
+	}
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		base58.CheckDecodeBatch(inputs)
+	}
+}
@@ -6,7 +6,10 @@ package base58
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
+	"hash"
+	"sync"
 )
 
 // ErrChecksum indicates that the checksum of a check-encoded string does not verify against
@@ -16,14 +19,33 @@ var ErrChecksum = errors.New("checksum error")
 // ErrInvalidFormat indicates that the check-encoded string has an invalid format.
 var ErrInvalidFormat = errors.New("invalid format: version and/or checksum bytes missing")
 
+// hasherPool is a free list of SHA256 hashers used to calculate checksums, so
+// decoding large numbers of strings doesn't allocate a hasher for each.
+var hasherPool = sync.Pool{New: func() interface{} { return sha256.New() }}
+
 // checksum: first four bytes of sha256^2
 func checksum(input []byte) (cksum [4]byte) {
-	h := sha256.Sum256(input)
-	h2 := sha256.Sum256(h[:])
-	copy(cksum[:], h2[:4])
+	h := hasherPool.Get().(hash.Hash)
+	var sum [sha256.Size]byte
+	h.Reset()
+	h.Write(input)
+	h.Sum(sum[:0])
+	h.Reset()
+	h.Write(sum[:])
+	h.Sum(sum[:0])
+	hasherPool.Put(h)
+
+	copy(cksum[:], sum[:4])
 	return
 }
 
+// verifyChecksum returns whether the last four bytes of decoded are the
+// checksum of the bytes before them.  The comparison is done in constant time.
+func verifyChecksum(decoded []byte) bool {
+	cksum := checksum(decoded[:len(decoded)-4])
+	return subtle.ConstantTimeCompare(cksum[:], decoded[len(decoded)-4:]) == 1
+}
+
 // CheckEncode prepends a version byte and appends a four byte checksum.
 func CheckEncode(input []byte, version byte) string {
 	b := make([]byte, 0, 1+len(input)+4)
@@ -41,9 +63,7 @@ func CheckDecode(input string) (result []byte, version byte, err error) {
 		return nil, 0, ErrInvalidFormat
 	}
 	version = decoded[0]
-	var cksum [4]byte
-	copy(cksum[:], decoded[len(decoded)-4:])
-	if checksum(decoded[:len(decoded)-4]) != cksum {
+	if !verifyChecksum(decoded) {
 		return nil, 0, ErrChecksum
 	}
 	payload := decoded[1 : len(decoded)-4]
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package base58
+
+import (
+	"runtime"
+	"sync"
+)
+
+// batchChunkSize is the number of strings decoded by a single goroutine at a
+// time by CheckDecodeBatch.
+const batchChunkSize = 1024
+
+// CheckDecodeResult is the outcome of decoding a single string passed to
+// CheckDecodeBatch.
+type CheckDecodeResult struct {
+	// Payload is the decoded data without the version byte and checksum.
+	// It is nil when Err is set.
+	Payload []byte
+
+	// Version is the version byte of the decoded data.  It is zero when
+	// Err is set.
+	Version byte
+
+	// Err is ErrInvalidFormat or ErrChecksum when the string could not be
+	// decoded, and nil otherwise.
+	Err error
+}
+
+// checkDecodeInto decodes input like CheckDecode into result.  The payload
+// references the decoded bytes instead of being copied.
+func checkDecodeInto(input string, result *CheckDecodeResult) {
+	decoded := Decode(input)
+	if len(decoded) < 5 {
+		*result = CheckDecodeResult{Err: ErrInvalidFormat}
+		return
+	}
+	if !verifyChecksum(decoded) {
+		*result = CheckDecodeResult{Err: ErrChecksum}
+		return
+	}
+	*result = CheckDecodeResult{
+		Payload: decoded[1 : len(decoded)-4 : len(decoded)-4],
+		Version: decoded[0],
+	}
+}
+
+// CheckDecodeBatch decodes and verifies each of the passed strings that were
+// encoded with CheckEncode, returning a result for each input in the same
+// order.  It is equivalent to calling CheckDecode for every input, however the
+// work is spread over all available processors and the hashers used to verify
+// the checksums are reused, which makes it considerably faster for large
+// numbers of strings such as when validating imported address lists.
+//
+// A failure to decode one of the strings does not affect the others; the
+// reason is reported in the Err field of its result.
+func CheckDecodeBatch(inputs []string) []CheckDecodeResult {
+	results := make([]CheckDecodeResult, len(inputs))
+
+	// Small batches are not worth the overhead of the goroutines.
+	if len(inputs) <= batchChunkSize {
+		for i, input := range inputs {
+			checkDecodeInto(input, &results[i])
+		}
+		return results
+	}
+
+	// Hand out chunks of the inputs to a worker per processor.  Each
+	// worker writes to a disjoint part of the results, so no further
+	// synchronization is needed.
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := start + batchChunkSize
+				if end > len(inputs) {
+					end = len(inputs)
+				}
+				for j := start; j < end; j++ {
+					checkDecodeInto(inputs[j], &results[j])
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(inputs); start += batchChunkSize {
+		chunks <- start
+	}
+	close(chunks)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package base58_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulordutil/base58"
+)
+
+// TestCheckDecodeBatch ensures the batch decoder returns the same results as
+// CheckDecode for valid and invalid inputs, including batches large enough to
+// be decoded concurrently.
+func TestCheckDecodeBatch(t *testing.T) {
+	var inputs []string
+	for _, test := range checkEncodingStringTests {
+		inputs = append(inputs, test.out)
+	}
+	inputs = append(inputs, "3MNQE1Y", "", "1", "0OIl")
+
+	// Repeat the inputs enough times to exceed a single chunk.
+	for len(inputs) < 5000 {
+		inputs = append(inputs, inputs...)
+	}
+
+	for _, batch := range [][]string{inputs[:15], inputs} {
+		results := base58.CheckDecodeBatch(batch)
+		if len(results) != len(batch) {
+			t.Fatalf("CheckDecodeBatch: got %d results, want %d",
+				len(results), len(batch))
+		}
+
+		for i, input := range batch {
+			payload, version, err := base58.CheckDecode(input)
+			result := results[i]
+			if result.Err != err {
+				t.Errorf("CheckDecodeBatch #%d (%q): unexpected "+
+					"error -- got %v, want %v", i, input,
+					result.Err, err)
+				continue
+			}
+			if result.Version != version ||
+				!bytes.Equal(result.Payload, payload) {
+
+				t.Errorf("CheckDecodeBatch #%d (%q): got (%x, %d), "+
+					"want (%x, %d)", i, input, result.Payload,
+					result.Version, payload, version)
+			}
+		}
+	}
+}
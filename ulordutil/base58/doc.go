@@ -25,5 +25,13 @@ byte arrays into human-readable strings along with a version byte that can be
 used to differentiate the same payload.  For Bitcoin addresses, the extra
 version is used to differentiate the network of otherwise identical public keys
 which helps prevent using an address intended for one network on another.
+
+Batch Decoding
+
+CheckDecodeBatch decodes and verifies large numbers of Base58Check encoded
+strings, such as imported address lists, by spreading the work over all
+available processors.  The result of each string, including the reason it
+failed to decode, is reported separately.  Checksums are always compared in
+constant time.
 */
 package base58
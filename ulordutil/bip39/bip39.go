@@ -0,0 +1,204 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip39
+
+// References:
+//   [BIP39]: BIP0039 - Mnemonic code for generating deterministic keys
+//   https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MinEntropyBits is the minimum number of bits of entropy which may be
+	// encoded as a mnemonic.  It results in a 12 word mnemonic.
+	MinEntropyBits = 128
+
+	// MaxEntropyBits is the maximum number of bits of entropy which may be
+	// encoded as a mnemonic.  It results in a 24 word mnemonic.
+	MaxEntropyBits = 256
+
+	// RecommendedEntropyBits is the recommended number of bits of entropy
+	// for newly generated mnemonics.
+	RecommendedEntropyBits = 256
+
+	// SeedLen is the length in bytes of the seed derived from a mnemonic.
+	// It is suitable for use with hdkeychain.NewMaster.
+	SeedLen = 64
+
+	// seedIterations is the number of PBKDF2 rounds used to derive a seed
+	// as defined by [BIP39].
+	seedIterations = 2048
+
+	// bitsPerWord is the number of bits encoded by each mnemonic word.
+	bitsPerWord = 11
+)
+
+var (
+	// ErrInvalidEntropyLength describes an error in which the provided
+	// entropy is not a multiple of 32 bits between MinEntropyBits and
+	// MaxEntropyBits.
+	ErrInvalidEntropyLength = fmt.Errorf("entropy length must be a "+
+		"multiple of 32 bits between %d and %d bits", MinEntropyBits,
+		MaxEntropyBits)
+
+	// ErrInvalidMnemonicLength describes an error in which the provided
+	// mnemonic does not contain 12, 15, 18, 21, or 24 words.
+	ErrInvalidMnemonicLength = errors.New("mnemonic must contain 12, 15, " +
+		"18, 21, or 24 words")
+
+	// ErrChecksumMismatch describes an error in which the checksum encoded
+	// in the final word of a mnemonic does not match its entropy.  This
+	// usually indicates a mistyped or reordered word.
+	ErrChecksumMismatch = errors.New("mnemonic checksum mismatch")
+)
+
+// UnknownWordError describes an error in which a mnemonic contains a word
+// which is not in the word list it is being decoded with.
+type UnknownWordError string
+
+// Error returns the error string and satisfies the error interface.
+func (e UnknownWordError) Error() string {
+	return fmt.Sprintf("unknown mnemonic word %q", string(e))
+}
+
+// checkEntropyBits returns ErrInvalidEntropyLength when the passed number of
+// bits can't be encoded as a mnemonic.
+func checkEntropyBits(bits int) error {
+	if bits < MinEntropyBits || bits > MaxEntropyBits || bits%32 != 0 {
+		return ErrInvalidEntropyLength
+	}
+	return nil
+}
+
+// readBits returns the n bits of data starting at the passed bit offset as an
+// integer.  Bits are numbered from the most significant bit of the first byte.
+func readBits(data []byte, offset, n int) int {
+	var v int
+	for i := offset; i < offset+n; i++ {
+		bit := (data[i/8] >> uint(7-i%8)) & 1
+		v = v<<1 | int(bit)
+	}
+	return v
+}
+
+// writeBits stores the low n bits of v in data starting at the passed bit
+// offset.  It is the inverse of readBits.
+func writeBits(data []byte, offset, n, v int) {
+	for i := 0; i < n; i++ {
+		if v&(1<<uint(n-1-i)) != 0 {
+			pos := offset + i
+			data[pos/8] |= 1 << uint(7-pos%8)
+		}
+	}
+}
+
+// NewEntropy returns the passed number of bits of cryptographically secure
+// random entropy.  The number of bits must be a multiple of 32 between
+// MinEntropyBits and MaxEntropyBits.
+func NewEntropy(bits int) ([]byte, error) {
+	if err := checkEntropyBits(bits); err != nil {
+		return nil, err
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic encodes the passed entropy as a mnemonic sentence using the
+// passed word list.  A checksum of the entropy is appended before encoding so
+// that transcription errors can be detected when the mnemonic is decoded.
+func NewMnemonic(entropy []byte, wl *Wordlist) (string, error) {
+	entBits := len(entropy) * 8
+	if err := checkEntropyBits(entBits); err != nil {
+		return "", err
+	}
+
+	// The checksum is the first ENT/32 bits of the SHA256 of the entropy,
+	// which always fits within the first byte of the hash.
+	csBits := entBits / 32
+	hash := sha256.Sum256(entropy)
+	data := make([]byte, 0, len(entropy)+1)
+	data = append(data, entropy...)
+	data = append(data, hash[0])
+
+	numWords := (entBits + csBits) / bitsPerWord
+	words := make([]string, numWords)
+	for i := range words {
+		words[i] = wl.Word(readBits(data, i*bitsPerWord, bitsPerWord))
+	}
+
+	return strings.Join(words, wl.separator), nil
+}
+
+// EntropyFromMnemonic decodes the passed mnemonic sentence with the passed
+// word list and returns the entropy it encodes.  The checksum is verified and
+// ErrChecksumMismatch is returned if it does not match.  Words may be
+// separated by any white space, and are matched after NFKD normalization.
+func EntropyFromMnemonic(mnemonic string, wl *Wordlist) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, ErrInvalidMnemonicLength
+	}
+
+	totalBits := len(words) * bitsPerWord
+	csBits := totalBits / 33
+	entBits := totalBits - csBits
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		index, ok := wl.Index(word)
+		if !ok {
+			return nil, UnknownWordError(word)
+		}
+		writeBits(data, i*bitsPerWord, bitsPerWord, index)
+	}
+
+	entropy := data[:entBits/8]
+	hash := sha256.Sum256(entropy)
+	if readBits(data, entBits, csBits) != int(hash[0]>>uint(8-csBits)) {
+		return nil, ErrChecksumMismatch
+	}
+
+	return entropy, nil
+}
+
+// ValidateMnemonic returns an error describing why the passed mnemonic is not
+// a valid mnemonic for the passed word list, or nil if it is valid.
+func ValidateMnemonic(mnemonic string, wl *Wordlist) error {
+	_, err := EntropyFromMnemonic(mnemonic, wl)
+	return err
+}
+
+// NewSeed derives a seed from the passed mnemonic and passphrase as defined by
+// [BIP39].  Both are NFKD normalized before use.  The mnemonic is not
+// validated, which allows seeds to be recovered from mnemonics created with
+// word lists this package does not know about; use SeedFromMnemonic to reject
+// mistyped mnemonics.
+func NewSeed(mnemonic, passphrase string) []byte {
+	password := []byte(norm.NFKD.String(mnemonic))
+	salt := []byte("mnemonic" + norm.NFKD.String(passphrase))
+	return pbkdf2.Key(password, salt, seedIterations, SeedLen, sha512.New)
+}
+
+// SeedFromMnemonic validates the passed mnemonic against the passed word list
+// and then derives its seed with the passed passphrase.
+func SeedFromMnemonic(mnemonic, passphrase string, wl *Wordlist) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic, wl); err != nil {
+		return nil, err
+	}
+	return NewSeed(mnemonic, passphrase), nil
+}
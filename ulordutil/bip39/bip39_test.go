@@ -0,0 +1,252 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// TestVectors ensures the English test vectors from [BIP39] encode, decode,
+// and derive the expected seeds with the passphrase "TREZOR".
+func TestVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			entropy:  "808080808080808080808080808080808080808080808080",
+			mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+			seed:     "107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title",
+			seed:     "bc09fca1804f7e69da93c2f2028eb238c227f2e9dda30cd63699232578480a4021b146ad717fbb7e451ce9eb835f43620bf5c514db0f8add49f5d121449d3e87",
+		},
+		{
+			entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			seed:     "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for i, test := range tests {
+		entropy, err := hex.DecodeString(test.entropy)
+		if err != nil {
+			t.Errorf("#%d: unable to decode entropy: %v", i, err)
+			continue
+		}
+
+		mnemonic, err := NewMnemonic(entropy, English)
+		if err != nil {
+			t.Errorf("NewMnemonic #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("NewMnemonic #%d: mismatched mnemonic -- got "+
+				"%q, want %q", i, mnemonic, test.mnemonic)
+			continue
+		}
+
+		decoded, err := EntropyFromMnemonic(test.mnemonic, English)
+		if err != nil {
+			t.Errorf("EntropyFromMnemonic #%d: unexpected error: %v",
+				i, err)
+			continue
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("EntropyFromMnemonic #%d: mismatched entropy "+
+				"-- got %x, want %x", i, decoded, entropy)
+			continue
+		}
+
+		seed, err := SeedFromMnemonic(test.mnemonic, "TREZOR", English)
+		if err != nil {
+			t.Errorf("SeedFromMnemonic #%d: unexpected error: %v",
+				i, err)
+			continue
+		}
+		if hex.EncodeToString(seed) != test.seed {
+			t.Errorf("SeedFromMnemonic #%d: mismatched seed -- got "+
+				"%x, want %s", i, seed, test.seed)
+			continue
+		}
+	}
+}
+
+// TestInvalidMnemonics ensures malformed mnemonics are rejected with the
+// expected errors.
+func TestInvalidMnemonics(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{
+		{
+			name:     "bad checksum",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+			err:      ErrChecksumMismatch,
+		},
+		{
+			name:     "too few words",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon about",
+			err:      ErrInvalidMnemonicLength,
+		},
+		{
+			name:     "not a multiple of three",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			err:      ErrInvalidMnemonicLength,
+		},
+		{
+			name:     "unknown word",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon ulord",
+			err:      UnknownWordError("ulord"),
+		},
+		{
+			name:     "empty",
+			mnemonic: "",
+			err:      ErrInvalidMnemonicLength,
+		},
+	}
+
+	for i, test := range tests {
+		err := ValidateMnemonic(test.mnemonic, English)
+		if err != test.err {
+			t.Errorf("ValidateMnemonic #%d (%s): unexpected error -- "+
+				"got %v, want %v", i, test.name, err, test.err)
+		}
+	}
+}
+
+// TestEntropyLengths ensures every permitted entropy size round trips through
+// each word list and that other sizes are rejected.
+func TestEntropyLengths(t *testing.T) {
+	for bits := 0; bits <= MaxEntropyBits+32; bits += 8 {
+		entropy, err := NewEntropy(bits)
+		wantErr := bits < MinEntropyBits || bits%32 != 0 ||
+			bits > MaxEntropyBits
+		if wantErr {
+			if err != ErrInvalidEntropyLength {
+				t.Errorf("NewEntropy(%d): unexpected error -- got "+
+					"%v, want %v", bits, err,
+					ErrInvalidEntropyLength)
+			}
+			_, err := NewMnemonic(make([]byte, bits/8), English)
+			if err != ErrInvalidEntropyLength {
+				t.Errorf("NewMnemonic(%d bits): unexpected error "+
+					"-- got %v, want %v", bits, err,
+					ErrInvalidEntropyLength)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewEntropy(%d): unexpected error: %v", bits, err)
+			continue
+		}
+
+		for _, wl := range Wordlists {
+			mnemonic, err := NewMnemonic(entropy, wl)
+			if err != nil {
+				t.Errorf("NewMnemonic(%d bits, %s): unexpected "+
+					"error: %v", bits, wl.Name(), err)
+				continue
+			}
+			words := strings.Split(mnemonic, wl.separator)
+			if len(words) != bits*33/32/11 {
+				t.Errorf("NewMnemonic(%d bits, %s): got %d "+
+					"words", bits, wl.Name(), len(words))
+				continue
+			}
+			decoded, err := EntropyFromMnemonic(mnemonic, wl)
+			if err != nil {
+				t.Errorf("EntropyFromMnemonic(%d bits, %s): "+
+					"unexpected error: %v", bits, wl.Name(), err)
+				continue
+			}
+			if !bytes.Equal(decoded, entropy) {
+				t.Errorf("EntropyFromMnemonic(%d bits, %s): "+
+					"mismatched entropy", bits, wl.Name())
+			}
+		}
+	}
+}
+
+// TestNormalization ensures words are recognized in both composed and
+// decomposed form, and that Japanese mnemonics use the ideographic space.
+func TestNormalization(t *testing.T) {
+	// "ábaco" with a precomposed and a combining acute accent.
+	composed := "\u00e1baco"
+	decomposed := "a\u0301baco"
+	for _, word := range []string{composed, decomposed} {
+		if i, ok := Spanish.Index(word); !ok || i != 0 {
+			t.Errorf("Index(%q): got (%d, %v), want (0, true)", word,
+				i, ok)
+		}
+	}
+
+	entropy := make([]byte, 16)
+	mnemonic, err := NewMnemonic(entropy, Japanese)
+	if err != nil {
+		t.Fatalf("NewMnemonic: unexpected error: %v", err)
+	}
+	if strings.Count(mnemonic, "\u3000") != 11 {
+		t.Fatalf("NewMnemonic: Japanese mnemonic not joined with "+
+			"ideographic spaces: %q", mnemonic)
+	}
+
+	// The seed must not depend on which separator was used.
+	spaced := strings.Replace(mnemonic, "\u3000", " ", -1)
+	if !bytes.Equal(NewSeed(mnemonic, ""), NewSeed(spaced, "")) {
+		t.Fatal("NewSeed: seed depends on the word separator")
+	}
+}
+
+// TestWordlistChecksums ensures the embedded word lists are identical to the
+// files published alongside [BIP39].
+func TestWordlistChecksums(t *testing.T) {
+	tests := []struct {
+		words    string
+		checksum string
+	}{
+		{englishWords, "c1dbd296"},
+		{spanishWords, "266e4f3d"},
+		{frenchWords, "3e56b216"},
+		{italianWords, "2fc7d07e"},
+		{czechWords, "d1b5fda0"},
+		{japaneseWords, "acc1419"},
+		{koreanWords, "4ef461eb"},
+		{chineseSimplifiedWords, "e3721bbf"},
+		{chineseTraditionalWords, "3c20b443"},
+	}
+
+	for i, test := range tests {
+		checksum := fmt.Sprintf("%x", crc32.ChecksumIEEE([]byte(test.words)))
+		if checksum != test.checksum {
+			t.Errorf("#%d: mismatched checksum -- got %s, want %s",
+				i, checksum, test.checksum)
+		}
+	}
+}
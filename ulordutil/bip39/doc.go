@@ -0,0 +1,37 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bip39 provides an API for mnemonic sentences used to back up the seeds
+of hierarchical deterministic wallets (BIP0039).
+
+Overview
+
+A mnemonic is a sentence of 12 to 24 words which encodes 128 to 256 bits of
+entropy along with a short checksum.  It is far easier for a person to write
+down and verify than the raw entropy, and the checksum catches most mistyped
+or reordered words.
+
+Creating a Mnemonic
+
+NewEntropy generates random entropy of the requested size, and NewMnemonic
+encodes it with one of the provided word lists.  EntropyFromMnemonic performs
+the reverse operation and ValidateMnemonic checks a mnemonic without returning
+its entropy.
+
+Deriving a Seed
+
+The seed used to create the master node of a wallet is derived from the
+mnemonic and an optional passphrase with NewSeed or SeedFromMnemonic.  The
+resulting 64 byte seed may be passed directly to hdkeychain.NewMaster.  Note
+that every passphrase yields a valid, but different, seed.
+
+Word Lists
+
+The English, Spanish, French, Italian, Czech, Japanese, Korean, and simplified
+and traditional Chinese word lists from BIP0039 are provided.  Words are matched
+after NFKD normalization, so accented words are recognized whether they were
+entered in composed or decomposed form.
+*/
+package bip39
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bip39
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// wordlistLen is the number of words in every BIP0039 word list.  Each word
+// encodes 11 bits.
+const wordlistLen = 2048
+
+// Wordlist is a BIP0039 word list along with the separator used to join its
+// words into a mnemonic sentence.
+type Wordlist struct {
+	name      string
+	separator string
+	words     []string
+	index     map[string]int
+}
+
+// newWordlist returns a Wordlist for the passed newline separated words.  It
+// panics if the list does not contain exactly wordlistLen unique words since
+// that can only happen due to a programming error in the embedded lists.
+func newWordlist(name, separator, words string) *Wordlist {
+	list := strings.Split(strings.TrimSpace(words), "\n")
+	if len(list) != wordlistLen {
+		panic("bip39: wrong number of words in " + name + " word list")
+	}
+
+	// Lookups are performed on the NFKD form of the words so mnemonics are
+	// recognized regardless of how accented characters were entered.
+	index := make(map[string]int, len(list))
+	for i, word := range list {
+		index[norm.NFKD.String(word)] = i
+	}
+	if len(index) != wordlistLen {
+		panic("bip39: duplicate words in " + name + " word list")
+	}
+
+	return &Wordlist{
+		name:      name,
+		separator: separator,
+		words:     list,
+		index:     index,
+	}
+}
+
+// Name returns the name of the word list, such as "english".
+func (wl *Wordlist) Name() string {
+	return wl.name
+}
+
+// Word returns the word at the passed index.  The index must be less than
+// 2048.
+func (wl *Wordlist) Word(i int) string {
+	return wl.words[i]
+}
+
+// Index returns the index of the passed word within the word list and whether
+// or not it was found.
+func (wl *Wordlist) Index(word string) (int, bool) {
+	i, ok := wl.index[norm.NFKD.String(word)]
+	return i, ok
+}
+
+// Wordlists contains every word list provided by this package.
+var Wordlists = []*Wordlist{
+	English,
+	Spanish,
+	French,
+	Italian,
+	Czech,
+	Japanese,
+	Korean,
+	ChineseSimplified,
+	ChineseTraditional,
+}
@@ -31,6 +31,9 @@ func (e OutOfRangeError) Error() string {
 // manipulation of raw blocks.  It also memoizes hashes for the block and its
 // transactions on their first access so subsequent accesses don't have to
 // repeat the relatively expensive hashing operations.
+//
+// Blocks created with NewBlockFromBytesLazy are backed by their serialized
+// bytes and are only deserialized when MsgBlock is first called.
 type Block struct {
 	msgBlock                 *wire.MsgBlock  // Underlying MsgBlock
 	serializedBlock          []byte          // Serialized bytes for the block
@@ -39,11 +42,33 @@ type Block struct {
 	blockHeight              int32           // Height in the main block chain
 	transactions             []*Tx           // Transactions
 	txnsGenerated            bool            // ALL wrapped transactions generated
+	txLocs                   []wire.TxLoc    // Transaction locations of lazy blocks
+	lazy                     bool            // MsgBlock is deserialized from serializedBlock
 }
 
-// MsgBlock returns the underlying wire.MsgBlock for the Block.
+// MsgBlock returns the underlying wire.MsgBlock for the Block.  For lazily
+// parsed blocks, the block is deserialized on the first call, sharing the
+// wire.MsgTx of each wrapped transaction.
 func (b *Block) MsgBlock() *wire.MsgBlock {
 	// Return the cached block.
+	if b.msgBlock != nil || !b.lazy {
+		return b.msgBlock
+	}
+
+	// The layout of lazily parsed blocks is validated when they are
+	// created, so deserializing the header can not fail.
+	msgBlock := wire.MsgBlock{
+		Transactions: make([]*wire.MsgTx, 0, len(b.transactions)),
+	}
+	header := bytes.NewReader(b.serializedBlock[:blockHeaderLen])
+	if err := msgBlock.Header.Deserialize(header); err != nil {
+		panic(fmt.Sprintf("lazily parsed block header failed to "+
+			"deserialize: %v", err))
+	}
+	for _, tx := range b.transactions {
+		msgBlock.Transactions = append(msgBlock.Transactions, tx.MsgTx())
+	}
+	b.msgBlock = &msgBlock
 	return b.msgBlock
 }
 
@@ -79,7 +104,7 @@ func (b *Block) BytesNoWitness() ([]byte, error) {
 
 	// Serialize the MsgBlock.
 	var w bytes.Buffer
-	err := b.msgBlock.SerializeNoWitness(&w)
+	err := b.MsgBlock().SerializeNoWitness(&w)
 	if err != nil {
 		return nil, err
 	}
@@ -99,12 +124,28 @@ func (b *Block) Hash() *chainhash.Hash {
 		return b.blockHash
 	}
 
+	// Hash lazily parsed blocks from their serialized header so they don't
+	// need to be deserialized.
+	var hash chainhash.Hash
+	if b.lazy {
+		hash = chainhash.DoubleHashH(b.serializedBlock[:blockHeaderLen])
+	} else {
+		hash = b.msgBlock.BlockHash()
+	}
+
 	// Cache the block hash and return it.
-	hash := b.msgBlock.BlockHash()
 	b.blockHash = &hash
 	return &hash
 }
 
+// SetHash caches the passed hash as the block hash so it is not calculated on
+// the first call to Hash.  It allows callers that already know the hash, such
+// as indexers loading blocks from a database, to avoid hashing them again.
+// The caller is responsible for the hash being correct.
+func (b *Block) SetHash(hash *chainhash.Hash) {
+	b.blockHash = hash
+}
+
 // Tx returns a wrapped transaction (ulordutil.Tx) for the transaction at the
 // specified index in the Block.  The supplied index is 0 based.  That is to
 // say, the first transaction in the block is txNum 0.  This is nearly
@@ -112,9 +153,20 @@ func (b *Block) Hash() *chainhash.Hash {
 // underlying wire.MsgBlock, however the wrapped transaction has some helpful
 // properties such as caching the hash so subsequent calls are more efficient.
 func (b *Block) Tx(txNum int) (*Tx, error) {
+	// The wrapped transactions of lazily parsed blocks are all created up
+	// front.
+	if b.lazy {
+		if txNum < 0 || txNum >= len(b.transactions) {
+			str := fmt.Sprintf("transaction index %d is out of "+
+				"range - max %d", txNum, len(b.transactions)-1)
+			return nil, OutOfRangeError(str)
+		}
+		return b.transactions[txNum], nil
+	}
+
 	// Ensure the requested transaction is in range.
 	numTx := uint64(len(b.msgBlock.Transactions))
-	if txNum < 0 || uint64(txNum) > numTx {
+	if txNum < 0 || uint64(txNum) >= numTx {
 		str := fmt.Sprintf("transaction index %d is out of range - max %d",
 			txNum, numTx-1)
 		return nil, OutOfRangeError(str)
@@ -191,6 +243,12 @@ func (b *Block) TxHash(txNum int) (*chainhash.Hash, error) {
 // It is used to allow fast indexing into transactions within the raw byte
 // stream.
 func (b *Block) TxLoc() ([]wire.TxLoc, error) {
+	// The transaction locations of lazily parsed blocks are recorded when
+	// they are created.
+	if b.lazy {
+		return b.txLocs, nil
+	}
+
 	rawMsg, err := b.Bytes()
 	if err != nil {
 		return nil, err
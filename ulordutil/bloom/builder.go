@@ -0,0 +1,144 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bloom
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// DefaultFalsePositiveRate is the false positive rate used by a Builder unless
+// another one is set.
+const DefaultFalsePositiveRate = 0.0001
+
+// Builder collects the elements an SPV client is interested in and creates a
+// bloom filter sized for exactly those elements, so callers don't need to know
+// the number of elements up front.  The zero value is not usable; create a
+// Builder with NewBuilder.
+type Builder struct {
+	fprate   float64
+	tweak    uint32
+	flags    wire.BloomUpdateType
+	elements [][]byte
+}
+
+// NewBuilder returns a new Builder using the DefaultFalsePositiveRate, a random
+// tweak, and the wire.BloomUpdateNone update flags.
+func NewBuilder() *Builder {
+	var tweak [4]byte
+	rand.Read(tweak[:])
+	return &Builder{
+		fprate: DefaultFalsePositiveRate,
+		tweak:  binary.LittleEndian.Uint32(tweak[:]),
+		flags:  wire.BloomUpdateNone,
+	}
+}
+
+// SetFalsePositiveRate sets the false positive rate of the filter.  See
+// NewFilter for the valid range.
+func (b *Builder) SetFalsePositiveRate(fprate float64) *Builder {
+	b.fprate = fprate
+	return b
+}
+
+// SetTweak sets the tweak of the filter, replacing the random one.
+func (b *Builder) SetTweak(tweak uint32) *Builder {
+	b.tweak = tweak
+	return b
+}
+
+// SetFlags sets the flags which determine how the remote peer updates the
+// filter when it matches a transaction.
+func (b *Builder) SetFlags(flags wire.BloomUpdateType) *Builder {
+	b.flags = flags
+	return b
+}
+
+// Add adds the passed data to the elements of the filter.
+func (b *Builder) Add(data []byte) *Builder {
+	b.elements = append(b.elements, data)
+	return b
+}
+
+// AddHash adds the passed hash, such as a transaction hash, to the elements of
+// the filter.
+func (b *Builder) AddHash(hash *chainhash.Hash) *Builder {
+	return b.Add(hash[:])
+}
+
+// AddOutPoint adds the passed outpoint to the elements of the filter so
+// transactions spending it match.
+func (b *Builder) AddOutPoint(outpoint *wire.OutPoint) *Builder {
+	buf := make([]byte, chainhash.HashSize+4)
+	copy(buf, outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(buf[chainhash.HashSize:], outpoint.Index)
+	return b.Add(buf)
+}
+
+// AddPubKey adds the passed public key to the elements of the filter so
+// transactions paying to or spending from it match.  Both the serialized
+// public key and its hash are added, which covers pay-to-pubkey,
+// pay-to-pubkey-hash and pay-to-witness-pubkey-hash scripts as well as the
+// signature scripts and witnesses spending them.  The compressed flag selects
+// the serialization of the public key used by the scripts.
+func (b *Builder) AddPubKey(pubKey *ulordec.PublicKey, compressed bool) *Builder {
+	var serialized []byte
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	} else {
+		serialized = pubKey.SerializeUncompressed()
+	}
+	b.Add(serialized)
+	return b.Add(ulordutil.Hash160(serialized))
+}
+
+// AddAddress adds the passed address to the elements of the filter so
+// transactions paying to it match.
+func (b *Builder) AddAddress(addr ulordutil.Address) *Builder {
+	return b.Add(addr.ScriptAddress())
+}
+
+// Build returns a new Filter containing all of the added elements.
+func (b *Builder) Build() *Filter {
+	// A filter for no elements still needs room for one so it is valid.
+	numElements := uint32(len(b.elements))
+	if numElements == 0 {
+		numElements = 1
+	}
+
+	filter := NewFilter(numElements, b.tweak, b.fprate, b.flags)
+	for _, data := range b.elements {
+		filter.add(data)
+	}
+	return filter
+}
+
+// MatchesScript returns true if the bloom filter might contain any of the
+// data pushed by the passed serialized script and false if it definitely does
+// not.  It matches scripts the same way the filter matches the scripts of
+// transactions.
+//
+// This function is safe for concurrent access.
+func (bf *Filter) MatchesScript(script []byte) bool {
+	pushedData, err := txscript.PushedData(script)
+	if err != nil {
+		return false
+	}
+
+	bf.mtx.Lock()
+	defer bf.mtx.Unlock()
+	for _, data := range pushedData {
+		if bf.matches(data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bloom_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+	"github.com/ulordsuite/ulordutil/bloom"
+)
+
+// TestBuilder ensures filters created by a Builder match the added elements
+// and the scripts paying to them, and use the configured parameters.
+func TestBuilder(t *testing.T) {
+	privKey, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pubKey := privKey.PubKey()
+	pkHash := ulordutil.Hash160(pubKey.SerializeCompressed())
+
+	addr, err := ulordutil.NewAddressScriptHashFromHash(
+		bytes.Repeat([]byte{0x11}, 20), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: %v", err)
+	}
+	outpoint := wire.NewOutPoint(&chainhash.Hash{0x22}, 3)
+
+	f := bloom.NewBuilder().
+		SetFalsePositiveRate(0.0001).
+		SetTweak(0xdeadbeef).
+		SetFlags(wire.BloomUpdateP2PubkeyOnly).
+		AddPubKey(pubKey, true).
+		AddAddress(addr).
+		AddOutPoint(outpoint).
+		Build()
+
+	msg := f.MsgFilterLoad()
+	if msg.Tweak != 0xdeadbeef {
+		t.Errorf("MsgFilterLoad: tweak got %x, want %x", msg.Tweak,
+			0xdeadbeef)
+	}
+	if msg.Flags != wire.BloomUpdateP2PubkeyOnly {
+		t.Errorf("MsgFilterLoad: flags got %v, want %v", msg.Flags,
+			wire.BloomUpdateP2PubkeyOnly)
+	}
+
+	if !f.Matches(pubKey.SerializeCompressed()) {
+		t.Error("Matches: public key not matched")
+	}
+	if !f.Matches(pkHash) {
+		t.Error("Matches: public key hash not matched")
+	}
+	if !f.MatchesOutPoint(outpoint) {
+		t.Error("MatchesOutPoint: outpoint not matched")
+	}
+
+	// Scripts paying to the added keys and address must match, while
+	// scripts paying elsewhere must not.
+	p2pkh, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(pkHash).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+	p2sh, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: %v", err)
+	}
+	other, err := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(bytes.Repeat([]byte{0x33}, 20)).
+		AddOp(txscript.OP_EQUAL).Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		script []byte
+		want   bool
+	}{
+		{"p2pkh", p2pkh, true},
+		{"p2sh", p2sh, true},
+		{"other", other, false},
+		{"malformed", []byte{txscript.OP_DATA_20, 0x01}, false},
+	}
+	for i, test := range tests {
+		if got := f.MatchesScript(test.script); got != test.want {
+			t.Errorf("MatchesScript #%d (%s): got %v, want %v", i,
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestBuilderEmpty ensures a Builder without elements still creates a valid
+// filter.
+func TestBuilderEmpty(t *testing.T) {
+	f := bloom.NewBuilder().Build()
+	if !f.IsLoaded() {
+		t.Fatal("Build: filter not loaded")
+	}
+	if len(f.MsgFilterLoad().Filter) == 0 {
+		t.Error("MsgFilterLoad: empty filter")
+	}
+	if f.Matches([]byte{0x01}) {
+		t.Error("Matches: empty filter matched data")
+	}
+}
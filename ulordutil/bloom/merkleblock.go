@@ -5,12 +5,32 @@
 package bloom
 
 import (
+	"errors"
+
 	"github.com/ulordsuite/ulord/blockchain"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
 )
 
+// maxTxPerBlock is the maximum number of transactions that could possibly fit
+// into a block, which bounds the size of the partial merkle trees accepted by
+// ExtractMatches.
+const maxTxPerBlock = (wire.MaxBlockPayload / 10) + 1
+
+var (
+	// ErrMalformedMerkleBlock describes an error where the partial merkle
+	// tree of a merkle block is not well formed, such as when it has too
+	// few or too many hashes or flag bits.
+	ErrMalformedMerkleBlock = errors.New("malformed partial merkle tree")
+
+	// ErrMerkleRootMismatch describes an error where the partial merkle
+	// tree of a merkle block does not commit to the merkle root in the
+	// header of the block.
+	ErrMerkleRootMismatch = errors.New("partial merkle tree does not " +
+		"match the merkle root")
+)
+
 // merkleBlock is used to house intermediate information needed to generate a
 // wire.MsgMerkleBlock according to a filter.
 type merkleBlock struct {
@@ -80,6 +100,30 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 // NewMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
 // transaction index numbers based on the passed block and filter.
 func NewMerkleBlock(block *ulordutil.Block, filter *Filter) (*wire.MsgMerkleBlock, []uint32) {
+	return newMerkleBlock(block, filter.MatchTxAndUpdate)
+}
+
+// NewMerkleBlockWithTxns returns a new *wire.MsgMerkleBlock proving the
+// inclusion of the transactions with the passed hashes in the block, along with
+// an array of their index numbers within the block.  This is the proof returned
+// by the gettxoutproof RPC.  Hashes of transactions that are not in the block
+// are ignored, so callers should compare the number of returned indices with
+// the number of hashes.
+func NewMerkleBlockWithTxns(block *ulordutil.Block, txHashes []*chainhash.Hash) (*wire.MsgMerkleBlock, []uint32) {
+	wanted := make(map[chainhash.Hash]struct{}, len(txHashes))
+	for _, hash := range txHashes {
+		wanted[*hash] = struct{}{}
+	}
+	return newMerkleBlock(block, func(tx *ulordutil.Tx) bool {
+		_, ok := wanted[*tx.Hash()]
+		return ok
+	})
+}
+
+// newMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the
+// matched transaction index numbers for the transactions of the passed block
+// the match function returns true for.
+func newMerkleBlock(block *ulordutil.Block, match func(*ulordutil.Tx) bool) (*wire.MsgMerkleBlock, []uint32) {
 	numTx := uint32(len(block.Transactions()))
 	mBlock := merkleBlock{
 		numTx:       numTx,
@@ -87,10 +131,10 @@ func NewMerkleBlock(block *ulordutil.Block, filter *Filter) (*wire.MsgMerkleBloc
 		matchedBits: make([]byte, 0, numTx),
 	}
 
-	// Find and keep track of any transactions that match the filter.
+	// Find and keep track of any transactions that match.
 	var matchedIndices []uint32
 	for txIndex, tx := range block.Transactions() {
-		if filter.MatchTxAndUpdate(tx) {
+		if match(tx) {
 			mBlock.matchedBits = append(mBlock.matchedBits, 0x01)
 			matchedIndices = append(matchedIndices, uint32(txIndex))
 		} else {
@@ -123,3 +167,118 @@ func NewMerkleBlock(block *ulordutil.Block, filter *Filter) (*wire.MsgMerkleBloc
 	}
 	return &msgMerkleBlock, matchedIndices
 }
+
+// partialMerkleTree is used to house intermediate information needed to
+// extract the matched transactions from a wire.MsgMerkleBlock.
+type partialMerkleTree struct {
+	numTx          uint32
+	hashes         []*chainhash.Hash
+	flags          []byte
+	bitsUsed       uint32
+	hashesUsed     uint32
+	matchedHashes  []*chainhash.Hash
+	matchedIndices []uint32
+}
+
+// calcTreeWidth calculates and returns the the number of nodes (width) or a
+// merkle tree at the given depth-first height.
+func (p *partialMerkleTree) calcTreeWidth(height uint32) uint32 {
+	return (p.numTx + (1 << height) - 1) >> height
+}
+
+// traverseAndExtract walks the partial merkle tree in the same depth-first
+// order it was built in, recording the matched transactions and returning the
+// hash of the sub-tree at the given depth-first height and node position.
+func (p *partialMerkleTree) traverseAndExtract(height, pos uint32) (*chainhash.Hash, error) {
+	if p.bitsUsed >= uint32(len(p.flags))*8 {
+		return nil, ErrMalformedMerkleBlock
+	}
+	isParent := p.flags[p.bitsUsed/8]&(1<<(p.bitsUsed%8)) != 0
+	p.bitsUsed++
+
+	// Leaf nodes and nodes which are not the parent of a matched node
+	// carry their hash.
+	if height == 0 || !isParent {
+		if p.hashesUsed >= uint32(len(p.hashes)) {
+			return nil, ErrMalformedMerkleBlock
+		}
+		hash := p.hashes[p.hashesUsed]
+		p.hashesUsed++
+		if height == 0 && isParent {
+			p.matchedHashes = append(p.matchedHashes, hash)
+			p.matchedIndices = append(p.matchedIndices, pos)
+		}
+		return hash, nil
+	}
+
+	// At this point, the node is an internal node and it is the parent of
+	// of an included leaf node.
+	left, err := p.traverseAndExtract(height-1, pos*2)
+	if err != nil {
+		return nil, err
+	}
+	right := left
+	if pos*2+1 < p.calcTreeWidth(height-1) {
+		right, err = p.traverseAndExtract(height-1, pos*2+1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Identical siblings would allow different transaction lists
+		// to produce the same merkle root (CVE-2012-2459).
+		if right.IsEqual(left) {
+			return nil, ErrMalformedMerkleBlock
+		}
+	}
+	return blockchain.HashMerkleBranches(left, right), nil
+}
+
+// ExtractMatches verifies the partial merkle tree of the passed merkle block
+// against the merkle root of its header, returning the hashes of the matched
+// transactions and their index numbers within the block.  It is used to verify
+// the proofs created by NewMerkleBlock and NewMerkleBlockWithTxns, such as the
+// ones returned by the gettxoutproof RPC.
+//
+// ErrMalformedMerkleBlock is returned when the partial merkle tree is not
+// well formed, and ErrMerkleRootMismatch when it does not commit to the
+// merkle root of the header.
+func ExtractMatches(msg *wire.MsgMerkleBlock) ([]*chainhash.Hash, []uint32, error) {
+	// A block always has a coinbase transaction, and every transaction
+	// takes at least one flag bit and hash.
+	numTx := msg.Transactions
+	if numTx == 0 || numTx > maxTxPerBlock ||
+		uint32(len(msg.Hashes)) > numTx ||
+		len(msg.Flags)*8 < len(msg.Hashes) {
+
+		return nil, nil, ErrMalformedMerkleBlock
+	}
+
+	p := partialMerkleTree{
+		numTx:  numTx,
+		hashes: msg.Hashes,
+		flags:  msg.Flags,
+	}
+
+	// Calculate the number of merkle branches (height) in the tree.
+	height := uint32(0)
+	for p.calcTreeWidth(height) > 1 {
+		height++
+	}
+
+	root, err := p.traverseAndExtract(height, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// All hashes and all flag bytes except for padding must be used.
+	if p.hashesUsed != uint32(len(p.hashes)) ||
+		(p.bitsUsed+7)/8 != uint32(len(p.flags)) {
+
+		return nil, nil, ErrMalformedMerkleBlock
+	}
+
+	if !root.IsEqual(&msg.Header.MerkleRoot) {
+		return nil, nil, ErrMerkleRootMismatch
+	}
+	return p.matchedHashes, p.matchedIndices, nil
+}
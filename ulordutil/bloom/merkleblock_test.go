@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"testing"
 
+	"github.com/ulordsuite/ulord/blockchain"
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
 	"github.com/ulordsuite/ulord/wire"
 	"github.com/ulordsuite/ulordutil"
@@ -72,3 +73,195 @@ func TestMerkleBlock3(t *testing.T) {
 		return
 	}
 }
+
+// merkleTestBlock returns a block with the passed number of distinct
+// transactions and a valid merkle root.
+func merkleTestBlock(numTx int) *ulordutil.Block {
+	msgBlock := wire.MsgBlock{Header: wire.BlockHeader{Version: 1}}
+	for i := 0; i < numTx; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(i)}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		msgBlock.AddTransaction(tx)
+	}
+	block := ulordutil.NewBlock(&msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+// TestMerkleBlockWithTxns ensures proofs for any subset of the transactions of
+// blocks of various sizes survive serialization and verify against the merkle
+// root.
+func TestMerkleBlockWithTxns(t *testing.T) {
+	for numTx := 1; numTx <= 17; numTx++ {
+		block := merkleTestBlock(numTx)
+		txns := block.Transactions()
+
+		// Prove every transaction on its own, the first and last
+		// together, and none at all.
+		subsets := [][]int{{0, numTx - 1}, {}}
+		for i := 0; i < numTx; i++ {
+			subsets = append(subsets, []int{i})
+		}
+
+		for _, subset := range subsets {
+			var txHashes []*chainhash.Hash
+			wantIndices := make(map[uint32]bool)
+			for _, i := range subset {
+				txHashes = append(txHashes, txns[i].Hash())
+				wantIndices[uint32(i)] = true
+			}
+
+			mBlock, indices := bloom.NewMerkleBlockWithTxns(block,
+				txHashes)
+			if len(indices) != len(wantIndices) {
+				t.Errorf("NewMerkleBlockWithTxns (%d txns, %v): got "+
+					"%d indices, want %d", numTx, subset,
+					len(indices), len(wantIndices))
+				continue
+			}
+
+			// Round trip the proof through its wire encoding.
+			var buf bytes.Buffer
+			err := mBlock.BtcEncode(&buf, wire.ProtocolVersion,
+				wire.LatestEncoding)
+			if err != nil {
+				t.Fatalf("BtcEncode: %v", err)
+			}
+			var decoded wire.MsgMerkleBlock
+			err = decoded.BtcDecode(&buf, wire.ProtocolVersion,
+				wire.LatestEncoding)
+			if err != nil {
+				t.Fatalf("BtcDecode: %v", err)
+			}
+
+			hashes, gotIndices, err := bloom.ExtractMatches(&decoded)
+			if err != nil {
+				t.Errorf("ExtractMatches (%d txns, %v): unexpected "+
+					"error: %v", numTx, subset, err)
+				continue
+			}
+			if len(hashes) != len(gotIndices) ||
+				len(gotIndices) != len(wantIndices) {
+
+				t.Errorf("ExtractMatches (%d txns, %v): got %d "+
+					"matches, want %d", numTx, subset,
+					len(gotIndices), len(wantIndices))
+				continue
+			}
+			for i, index := range gotIndices {
+				if !wantIndices[index] ||
+					!hashes[i].IsEqual(txns[index].Hash()) {
+
+					t.Errorf("ExtractMatches (%d txns, %v): "+
+						"unexpected match %d (%v)", numTx,
+						subset, index, hashes[i])
+				}
+			}
+		}
+	}
+}
+
+// TestExtractMatchesErrors ensures tampered proofs are rejected.
+func TestExtractMatchesErrors(t *testing.T) {
+	block := merkleTestBlock(7)
+	proof := func() *wire.MsgMerkleBlock {
+		txHashes := []*chainhash.Hash{block.Transactions()[3].Hash()}
+		mBlock, _ := bloom.NewMerkleBlockWithTxns(block, txHashes)
+		return mBlock
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(*wire.MsgMerkleBlock)
+		err    error
+	}{
+		{
+			name:   "untampered",
+			tamper: func(*wire.MsgMerkleBlock) {},
+		},
+		{
+			name: "wrong merkle root",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Header.MerkleRoot[0] ^= 0x01
+			},
+			err: bloom.ErrMerkleRootMismatch,
+		},
+		{
+			name: "wrong hash",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				hash := *m.Hashes[0]
+				hash[0] ^= 0x01
+				m.Hashes[0] = &hash
+			},
+			err: bloom.ErrMerkleRootMismatch,
+		},
+		{
+			name: "missing hash",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Hashes = m.Hashes[:len(m.Hashes)-1]
+			},
+			err: bloom.ErrMalformedMerkleBlock,
+		},
+		{
+			name: "extra hash",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Hashes = append(m.Hashes, m.Hashes[0])
+			},
+			err: bloom.ErrMalformedMerkleBlock,
+		},
+		{
+			name: "extra flag byte",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Flags = append(m.Flags, 0x00)
+			},
+			err: bloom.ErrMalformedMerkleBlock,
+		},
+		{
+			name: "no transactions",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Transactions = 0
+			},
+			err: bloom.ErrMalformedMerkleBlock,
+		},
+		{
+			name: "too many transactions",
+			tamper: func(m *wire.MsgMerkleBlock) {
+				m.Transactions = ^uint32(0)
+			},
+			err: bloom.ErrMalformedMerkleBlock,
+		},
+	}
+
+	for i, test := range tests {
+		mBlock := proof()
+		test.tamper(mBlock)
+		_, _, err := bloom.ExtractMatches(mBlock)
+		if err != test.err {
+			t.Errorf("ExtractMatches #%d (%s): unexpected error -- "+
+				"got %v, want %v", i, test.name, err, test.err)
+		}
+	}
+}
+
+// TestExtractMatchesDuplicate ensures proofs relying on identical sibling
+// hashes are rejected, since they allow different transaction lists to have
+// the same merkle root (CVE-2012-2459).
+func TestExtractMatchesDuplicate(t *testing.T) {
+	// Duplicating the last transaction of a block with an odd number of
+	// transactions results in the same merkle root.
+	block := merkleTestBlock(3)
+	msgBlock := *block.MsgBlock()
+	msgBlock.Transactions = append(msgBlock.Transactions,
+		msgBlock.Transactions[2])
+	dupBlock := ulordutil.NewBlock(&msgBlock)
+
+	txHashes := []*chainhash.Hash{dupBlock.Transactions()[3].Hash()}
+	mBlock, _ := bloom.NewMerkleBlockWithTxns(dupBlock, txHashes)
+	_, _, err := bloom.ExtractMatches(mBlock)
+	if err != bloom.ErrMalformedMerkleBlock {
+		t.Errorf("ExtractMatches: unexpected error -- got %v, want %v",
+			err, bloom.ErrMalformedMerkleBlock)
+	}
+}
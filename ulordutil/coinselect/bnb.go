@@ -0,0 +1,105 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinselect
+
+import (
+	"sort"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+// bnbMaxTries is the maximum number of nodes of the search tree visited by
+// BranchAndBound before it gives up.
+const bnbMaxTries = 100000
+
+// bnbSearch houses the state of a depth first search for the set of UTXOs
+// closest to a target value.
+type bnbSearch struct {
+	values     []ulordutil.Amount
+	target     ulordutil.Amount
+	upper      ulordutil.Amount
+	tries      int
+	current    []bool
+	best       []bool
+	bestExcess ulordutil.Amount
+}
+
+// search explores both including and excluding the UTXO at index i given the
+// value selected so far and the total value of the UTXOs not decided on yet.
+func (s *bnbSearch) search(i int, selected, remaining ulordutil.Amount) {
+	if s.tries >= bnbMaxTries || selected > s.upper {
+		return
+	}
+	s.tries++
+
+	// Adding more UTXOs can only increase the excess, so a selection in
+	// range ends this branch.
+	if selected >= s.target {
+		excess := selected - s.target
+		if s.best == nil || excess < s.bestExcess {
+			s.best = append(s.best[:0], s.current...)
+			s.bestExcess = excess
+		}
+		return
+	}
+	if i == len(s.values) || selected+remaining < s.target {
+		return
+	}
+
+	remaining -= s.values[i]
+	s.current[i] = true
+	s.search(i+1, selected+s.values[i], remaining)
+	s.current[i] = false
+	s.search(i+1, selected, remaining)
+}
+
+// BranchAndBound searches for a set of UTXOs that funds the transaction without
+// a change output, wasting at most the cost of creating and later spending a
+// change output on the fee.  Of the sets found, the one paying the smallest
+// excess fee is selected.  Avoiding change reduces the fees paid over time and
+// improves privacy.
+//
+// ErrNoExactMatch is returned when no such set is found, in which case callers
+// typically fall back to another algorithm.
+func BranchAndBound(utxos []Utxo, p *Params) (*Selection, error) {
+	pool := spendable(utxos, p)
+	sort.Stable(byEffectiveValue{pool, p})
+
+	var total ulordutil.Amount
+	values := make([]ulordutil.Amount, len(pool))
+	for i, u := range pool {
+		values[i] = p.effectiveValue(u)
+		total += values[i]
+	}
+
+	target := p.TargetValue + p.fee(p.BaseSize)
+	if total < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	s := bnbSearch{
+		values:  values,
+		target:  target,
+		upper:   target + p.fee(p.ChangeSize+p.ChangeSpendSize),
+		current: make([]bool, len(pool)),
+	}
+	s.search(0, 0, total)
+	if s.best == nil {
+		return nil, ErrNoExactMatch
+	}
+
+	var inputs []Utxo
+	for i, selected := range s.best {
+		if selected {
+			inputs = append(inputs, pool[i])
+		}
+	}
+
+	// The excess is less than the cost of change, so it is added to the
+	// fee.
+	noChange := *p
+	noChange.NoChange = true
+	return finalize(inputs, &noChange)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinselect
+
+import (
+	"errors"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+var (
+	// ErrInsufficientFunds describes an error where the passed UTXOs are
+	// not worth enough to pay for the target value and the fee of the
+	// transaction spending them.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrNoExactMatch describes an error where BranchAndBound could not
+	// find a set of UTXOs that funds the transaction without a change
+	// output.
+	ErrNoExactMatch = errors.New("no selection without change found")
+)
+
+// Utxo is a spendable output that may be selected to fund a transaction.
+type Utxo interface {
+	// Value returns the value of the output.
+	Value() ulordutil.Amount
+
+	// SpendSize returns the number of bytes an input spending the output
+	// adds to the serialized transaction, including its signature script
+	// once signed.
+	SpendSize() int
+}
+
+// Params describes the transaction being funded.  Sizes are in bytes and fee
+// rates in satoshi per byte.
+type Params struct {
+	// TargetValue is the total value of the outputs of the transaction,
+	// excluding any change output.
+	TargetValue ulordutil.Amount
+
+	// FeeRate is the fee rate the transaction must pay.
+	FeeRate ulordutil.Amount
+
+	// BaseSize is the serialized size of the transaction without any
+	// inputs or change output.  It includes the single byte input count
+	// used by transactions with fewer than 253 inputs.
+	BaseSize int
+
+	// ChangeSize is the serialized size of the change output.
+	ChangeSize int
+
+	// ChangeSpendSize is the size of an input spending the change output
+	// later on.  BranchAndBound counts the cost of spending the change
+	// as part of the cost of creating it.
+	ChangeSpendSize int
+
+	// MinChange is the smallest change value worth creating an output
+	// for.  Any smaller change is added to the fee instead.
+	MinChange ulordutil.Amount
+
+	// NoChange disables change outputs.  Any value in excess of the
+	// target value and fee is added to the fee.
+	NoChange bool
+}
+
+// fee returns the fee required for the passed number of bytes.
+func (p *Params) fee(size int) ulordutil.Amount {
+	return p.FeeRate * ulordutil.Amount(size)
+}
+
+// effectiveValue returns the value of the passed UTXO minus the fee for
+// spending it.
+func (p *Params) effectiveValue(u Utxo) ulordutil.Amount {
+	return u.Value() - p.fee(u.SpendSize())
+}
+
+// Selection is the result of a successful coin selection.
+type Selection struct {
+	// Inputs are the selected UTXOs.
+	Inputs []Utxo
+
+	// Fee is the fee paid by the transaction, including the fee for the
+	// change output and any change too small to create an output for.
+	Fee ulordutil.Amount
+
+	// Change is the value of the change output, or zero if the
+	// transaction has no change output.
+	Change ulordutil.Amount
+}
+
+// InputValue returns the total value of the selected UTXOs.
+func (s *Selection) InputValue() ulordutil.Amount {
+	var total ulordutil.Amount
+	for _, u := range s.Inputs {
+		total += u.Value()
+	}
+	return total
+}
+
+// finalize decides whether the transaction spending the passed UTXOs has a
+// change output and calculates its fee.  ErrInsufficientFunds is returned when
+// the inputs can't pay for the target value and the fee.
+func finalize(inputs []Utxo, p *Params) (*Selection, error) {
+	size := p.BaseSize
+	var total ulordutil.Amount
+	for _, u := range inputs {
+		size += u.SpendSize()
+		total += u.Value()
+	}
+
+	fee := p.fee(size)
+	excess := total - p.TargetValue - fee
+	if excess < 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	// Only create a change output when the change left after paying for
+	// the output itself is worth it.
+	if !p.NoChange {
+		changeFee := p.fee(p.ChangeSize)
+		change := excess - changeFee
+		if change > 0 && change >= p.MinChange {
+			return &Selection{
+				Inputs: inputs,
+				Fee:    fee + changeFee,
+				Change: change,
+			}, nil
+		}
+	}
+
+	return &Selection{Inputs: inputs, Fee: fee + excess}, nil
+}
+
+// spendable returns the UTXOs which are worth more than the fee for spending
+// them at the fee rate of the passed parameters.
+func spendable(utxos []Utxo, p *Params) []Utxo {
+	pool := make([]Utxo, 0, len(utxos))
+	for _, u := range utxos {
+		if p.effectiveValue(u) > 0 {
+			pool = append(pool, u)
+		}
+	}
+	return pool
+}
+
+// byEffectiveValue sorts UTXOs in descending order of their effective value.
+type byEffectiveValue struct {
+	utxos  []Utxo
+	params *Params
+}
+
+func (s byEffectiveValue) Len() int      { return len(s.utxos) }
+func (s byEffectiveValue) Swap(i, j int) { s.utxos[i], s.utxos[j] = s.utxos[j], s.utxos[i] }
+func (s byEffectiveValue) Less(i, j int) bool {
+	return s.params.effectiveValue(s.utxos[i]) >
+		s.params.effectiveValue(s.utxos[j])
+}
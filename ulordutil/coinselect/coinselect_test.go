@@ -0,0 +1,263 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinselect
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+// testUtxo is a Utxo with a fixed value and spend size.
+type testUtxo struct {
+	value     ulordutil.Amount
+	spendSize int
+}
+
+func (u *testUtxo) Value() ulordutil.Amount { return u.value }
+func (u *testUtxo) SpendSize() int          { return u.spendSize }
+
+// newTestUtxos returns a p2pkh sized UTXO for each of the passed values.
+func newTestUtxos(values ...ulordutil.Amount) []Utxo {
+	utxos := make([]Utxo, 0, len(values))
+	for _, v := range values {
+		utxos = append(utxos, &testUtxo{value: v, spendSize: 148})
+	}
+	return utxos
+}
+
+// testParams returns parameters for a transaction with a single p2pkh output
+// paying target at one satoshi per byte.
+func testParams(target ulordutil.Amount) *Params {
+	return &Params{
+		TargetValue:     target,
+		FeeRate:         1,
+		BaseSize:        10 + 34,
+		ChangeSize:      34,
+		ChangeSpendSize: 148,
+		MinChange:       546,
+	}
+}
+
+// checkSelection ensures the passed selection balances, which is that the
+// inputs pay for exactly the target value, fee, and change, and that the fee
+// is at least the one required by the fee rate.
+func checkSelection(t *testing.T, name string, s *Selection, p *Params) {
+	t.Helper()
+
+	if s.InputValue() != p.TargetValue+s.Fee+s.Change {
+		t.Errorf("%s: inputs %v do not balance target %v, fee %v, and "+
+			"change %v", name, s.InputValue(), p.TargetValue, s.Fee,
+			s.Change)
+	}
+
+	size := p.BaseSize
+	for _, u := range s.Inputs {
+		size += u.SpendSize()
+	}
+	if s.Change != 0 {
+		size += p.ChangeSize
+		if s.Change < p.MinChange {
+			t.Errorf("%s: change %v below minimum %v", name,
+				s.Change, p.MinChange)
+		}
+	}
+	if s.Fee < p.fee(size) {
+		t.Errorf("%s: fee %v below required fee %v", name, s.Fee,
+			p.fee(size))
+	}
+}
+
+// TestLargestFirst ensures the largest UTXOs are selected and that change is
+// only created when it is worth more than the minimum change.
+func TestLargestFirst(t *testing.T) {
+	tests := []struct {
+		name      string
+		utxos     []Utxo
+		target    ulordutil.Amount
+		noChange  bool
+		numInputs int
+		change    ulordutil.Amount
+		err       error
+	}{
+		{
+			name:      "single input with change",
+			utxos:     newTestUtxos(1000, 100000, 5000),
+			target:    50000,
+			numInputs: 1,
+			change:    100000 - 50000 - (44 + 148 + 34),
+		},
+		{
+			name:      "two inputs with change",
+			utxos:     newTestUtxos(1000, 100000, 50000),
+			target:    120000,
+			numInputs: 2,
+			change:    150000 - 120000 - (44 + 2*148 + 34),
+		},
+		{
+			name:      "dust change added to fee",
+			utxos:     newTestUtxos(100000),
+			target:    100000 - 44 - 148 - 100,
+			numInputs: 1,
+		},
+		{
+			name:      "change disabled",
+			utxos:     newTestUtxos(100000),
+			target:    50000,
+			noChange:  true,
+			numInputs: 1,
+		},
+		{
+			name:   "uneconomical utxos skipped",
+			utxos:  newTestUtxos(100, 148, 147),
+			target: 1,
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "insufficient funds",
+			utxos:  newTestUtxos(1000, 2000),
+			target: 3000,
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "no utxos",
+			target: 1,
+			err:    ErrInsufficientFunds,
+		},
+	}
+
+	for i, test := range tests {
+		p := testParams(test.target)
+		p.NoChange = test.noChange
+		s, err := LargestFirst(test.utxos, p)
+		if err != test.err {
+			t.Errorf("LargestFirst #%d (%s): unexpected error -- got "+
+				"%v, want %v", i, test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(s.Inputs) != test.numInputs || s.Change != test.change {
+			t.Errorf("LargestFirst #%d (%s): got %d inputs and change "+
+				"%v, want %d inputs and change %v", i, test.name,
+				len(s.Inputs), s.Change, test.numInputs, test.change)
+			continue
+		}
+		for j := 1; j < len(s.Inputs); j++ {
+			if s.Inputs[j].Value() > s.Inputs[j-1].Value() {
+				t.Errorf("LargestFirst #%d (%s): inputs not in "+
+					"descending order", i, test.name)
+			}
+		}
+		checkSelection(t, test.name, s, p)
+	}
+}
+
+// TestBranchAndBound ensures changeless selections are found when they exist
+// and the one with the smallest excess is chosen.
+func TestBranchAndBound(t *testing.T) {
+	// The effective value of each UTXO is its value minus 148.
+	utxos := newTestUtxos(1148, 2148, 3148, 5148, 10148)
+
+	tests := []struct {
+		name   string
+		target ulordutil.Amount
+		want   []ulordutil.Amount
+		err    error
+	}{
+		{
+			name:   "exact single",
+			target: 5000 - 44,
+			want:   []ulordutil.Amount{5148},
+		},
+		{
+			name:   "exact combination",
+			target: 6000 - 44,
+			want:   []ulordutil.Amount{5148, 1148},
+		},
+		{
+			name:   "smallest excess within cost of change",
+			target: 3900 - 44,
+			want:   []ulordutil.Amount{3148, 1148},
+		},
+		{
+			name:   "no match",
+			target: 20500 - 44,
+			err:    ErrNoExactMatch,
+		},
+		{
+			name:   "insufficient funds",
+			target: 22000,
+			err:    ErrInsufficientFunds,
+		},
+	}
+
+	for i, test := range tests {
+		p := testParams(test.target)
+		s, err := BranchAndBound(utxos, p)
+		if err != test.err {
+			t.Errorf("BranchAndBound #%d (%s): unexpected error -- "+
+				"got %v, want %v", i, test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if s.Change != 0 {
+			t.Errorf("BranchAndBound #%d (%s): unexpected change %v",
+				i, test.name, s.Change)
+		}
+		if len(s.Inputs) != len(test.want) {
+			t.Errorf("BranchAndBound #%d (%s): got %d inputs, want %d",
+				i, test.name, len(s.Inputs), len(test.want))
+			continue
+		}
+		for j, u := range s.Inputs {
+			if u.Value() != test.want[j] {
+				t.Errorf("BranchAndBound #%d (%s): input %d has "+
+					"value %v, want %v", i, test.name, j,
+					u.Value(), test.want[j])
+			}
+		}
+		checkSelection(t, test.name, s, p)
+	}
+}
+
+// TestRandomImprove ensures random selections balance and are deterministic
+// for a given source of randomness.
+func TestRandomImprove(t *testing.T) {
+	var values []ulordutil.Amount
+	for i := 1; i <= 100; i++ {
+		values = append(values, ulordutil.Amount(i*1000))
+	}
+	utxos := newTestUtxos(values...)
+
+	p := testParams(10000)
+	for seed := int64(0); seed < 50; seed++ {
+		s, err := RandomImprove(utxos, p, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("RandomImprove (seed %d): unexpected error: %v",
+				seed, err)
+		}
+		checkSelection(t, "RandomImprove", s, p)
+
+		again, err := RandomImprove(utxos, p, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("RandomImprove (seed %d): unexpected error: %v",
+				seed, err)
+		}
+		if len(again.Inputs) != len(s.Inputs) || again.Fee != s.Fee {
+			t.Errorf("RandomImprove (seed %d): not deterministic", seed)
+		}
+	}
+
+	_, err := RandomImprove(newTestUtxos(1000), testParams(2000), nil)
+	if err != ErrInsufficientFunds {
+		t.Errorf("RandomImprove: unexpected error -- got %v, want %v",
+			err, ErrInsufficientFunds)
+	}
+}
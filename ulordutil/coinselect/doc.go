@@ -0,0 +1,37 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package coinselect provides algorithms for selecting which unspent transaction
+outputs (UTXOs) fund a transaction.
+
+UTXOs are represented by the Utxo interface, which only requires their value
+and the number of bytes spending them adds to a transaction.  This allows both
+wallets and test harnesses to use the algorithms with their own UTXO types.
+
+Fees
+
+All algorithms account for the fee of the transaction being funded, described
+by Params, and compare UTXOs by their effective value, which is their value
+minus the fee for spending them.  UTXOs worth less than the fee for spending
+them are never selected.
+
+Once the inputs are selected, a change output is only created when the change
+left over after paying for the output itself is at least Params.MinChange.
+Otherwise the excess is added to the fee.
+
+Algorithms
+
+LargestFirst selects the UTXOs with the largest effective value first, which
+results in the fewest inputs.
+
+BranchAndBound searches for a set of UTXOs that funds the transaction without a
+change output, allowing at most the cost of creating and later spending the
+change output to be added to the fee.  It returns ErrNoExactMatch when no such
+set is found, in which case another algorithm should be used.
+
+RandomImprove selects random UTXOs, aiming for change of roughly the same value
+as the payment.
+*/
+package coinselect
@@ -0,0 +1,34 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinselect
+
+import (
+	"sort"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+// LargestFirst selects the UTXOs with the largest effective value, which is
+// the value minus the fee for spending them, until the transaction is funded.
+// It minimizes the number of inputs at the expense of consolidating small
+// UTXOs.  ErrInsufficientFunds is returned when all UTXOs together can't fund
+// the transaction.
+func LargestFirst(utxos []Utxo, p *Params) (*Selection, error) {
+	pool := spendable(utxos, p)
+	sort.Stable(byEffectiveValue{pool, p})
+
+	// Track the value and size of the selection so each UTXO added only
+	// costs a constant amount of work.
+	target := p.TargetValue + p.fee(p.BaseSize)
+	var selected ulordutil.Amount
+	for i, u := range pool {
+		selected += p.effectiveValue(u)
+		if selected >= target {
+			return finalize(pool[:i+1:i+1], p)
+		}
+	}
+
+	return nil, ErrInsufficientFunds
+}
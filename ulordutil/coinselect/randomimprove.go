@@ -0,0 +1,64 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinselect
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+// RandomImprove selects random UTXOs until the transaction is funded, and then
+// keeps adding random UTXOs as long as doing so brings the selected value
+// closer to twice the target without exceeding three times the target.  The
+// resulting change is therefore close in value to the payment, which makes it
+// harder to tell the two apart and leaves UTXOs useful for future payments of
+// a similar size.
+//
+// The passed source of randomness is used to shuffle the UTXOs.  When it is
+// nil, a source seeded with the current time is used.  ErrInsufficientFunds is
+// returned when all UTXOs together can't fund the transaction.
+func RandomImprove(utxos []Utxo, p *Params, r *rand.Rand) (*Selection, error) {
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	pool := spendable(utxos, p)
+	order := r.Perm(len(pool))
+	target := p.TargetValue + p.fee(p.BaseSize)
+
+	// Select random UTXOs until the target is reached.
+	var inputs []Utxo
+	var selected ulordutil.Amount
+	var next int
+	for ; next < len(order) && selected < target; next++ {
+		u := pool[order[next]]
+		inputs = append(inputs, u)
+		selected += p.effectiveValue(u)
+	}
+	if selected < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	// Improve the selection with the remaining UTXOs in random order.
+	ideal, max := 2*target, 3*target
+	distance := func(v ulordutil.Amount) ulordutil.Amount {
+		if v > ideal {
+			return v - ideal
+		}
+		return ideal - v
+	}
+	for ; next < len(order); next++ {
+		u := pool[order[next]]
+		improved := selected + p.effectiveValue(u)
+		if improved <= max && distance(improved) < distance(selected) {
+			inputs = append(inputs, u)
+			selected = improved
+		}
+	}
+
+	return finalize(inputs, p)
+}
@@ -13,4 +13,8 @@ const (
 
 	// MaxSatoshi is the maximum transaction amount allowed in satoshi.
 	MaxSatoshi = 21e6 * SatoshiPerBitcoin
+
+	// MasternodeCollateral is the exact amount in satoshi an output must
+	// hold to serve as the collateral of a masternode.
+	MasternodeCollateral = 1e4 * SatoshiPerBitcoin
 )
@@ -319,9 +319,12 @@ func BuildBasicFilter(block *wire.MsgBlock, prevOutScripts [][]byte) (*gcs.Filte
 
 			// In order to allow the filters to later be committed
 			// to within an OP_RETURN output, we ignore all
-			// OP_RETURNs to avoid a circular dependency.
-			if txOut.PkScript[0] == txscript.OP_RETURN &&
-				txscript.IsPushOnlyScript(txOut.PkScript[1:]) {
+			// OP_RETURNs to avoid a circular dependency.  As
+			// required by BIP158, this applies to every script
+			// starting with OP_RETURN rather than only the ones
+			// followed by data pushes, since none of them can be
+			// spent.
+			if txOut.PkScript[0] == txscript.OP_RETURN {
 				continue
 			}
 
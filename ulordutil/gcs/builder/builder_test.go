@@ -280,3 +280,47 @@ func BuilderTest(b *builder.GCSBuilder, hash *chainhash.Hash, p uint8,
 		t.Fatal("Filter size increased with duplicate items")
 	}
 }
+
+// TestBuildBasicFilter ensures basic filters contain the output scripts of a
+// block, except for OP_RETURN outputs, and the passed previous output scripts.
+func TestBuildBasicFilter(t *testing.T) {
+	p2pkh := []byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+		0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14,
+		txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG}
+	nullData := []byte{txscript.OP_RETURN, txscript.OP_DATA_1, 0x01}
+	nonPushReturn := []byte{txscript.OP_RETURN, txscript.OP_CHECKSIG}
+	prevScript := []byte{txscript.OP_TRUE}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxOut(wire.NewTxOut(1, p2pkh))
+	tx.AddTxOut(wire.NewTxOut(0, nullData))
+	tx.AddTxOut(wire.NewTxOut(0, nonPushReturn))
+	tx.AddTxOut(wire.NewTxOut(0, nil))
+	block := wire.MsgBlock{Transactions: []*wire.MsgTx{tx}}
+
+	f, err := builder.BuildBasicFilter(&block, [][]byte{prevScript, nil})
+	if err != nil {
+		t.Fatalf("BuildBasicFilter: %v", err)
+	}
+	if f.N() != 2 {
+		t.Fatalf("BuildBasicFilter: got %d entries, want 2", f.N())
+	}
+
+	blockHash := block.BlockHash()
+	key := builder.DeriveKey(&blockHash)
+	match, err := f.MatchAny(key, [][]byte{p2pkh})
+	if err != nil {
+		t.Fatalf("MatchAny: %v", err)
+	}
+	if !match {
+		t.Fatal("MatchAny: output script not matched")
+	}
+	match, err = f.Match(key, prevScript)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if !match {
+		t.Fatal("Match: previous output script not matched")
+	}
+}
@@ -286,6 +286,12 @@ func (f *Filter) N() uint32 {
 // Match checks whether a []byte value is likely (within collision probability)
 // to be a member of the set represented by the filter.
 func (f *Filter) Match(key [KeySize]byte, data []byte) (bool, error) {
+	// An empty filter can't match anything.  Its modulus is zero, so every
+	// search term would otherwise reduce to the same value.
+	if f.n == 0 {
+		return false, nil
+	}
+
 	// Create a filter bitstream.
 	filterData, err := f.Bytes()
 	if err != nil {
@@ -303,10 +309,11 @@ func (f *Filter) Match(key [KeySize]byte, data []byte) (bool, error) {
 	term := siphash.Sum64(data, &key)
 	term = fastReduction(term, nphi, nplo)
 
-	// Go through the search filter and look for the desired value.
+	// Go through the search filter and look for the desired value.  Only
+	// the N encoded values are read so the padding at the end of the
+	// bitstream is never mistaken for a value.
 	var lastValue uint64
-	for lastValue < term {
-
+	for i := uint32(0); i < f.n; i++ {
 		// Read the difference between previous and new value from
 		// bitstream.
 		value, err := f.readFullUint64(b)
@@ -318,12 +325,13 @@ func (f *Filter) Match(key [KeySize]byte, data []byte) (bool, error) {
 		}
 
 		// Add the previous value to it.
-		value += lastValue
-		if value == term {
+		lastValue += value
+		switch {
+		case lastValue == term:
 			return true, nil
+		case lastValue > term:
+			return false, nil
 		}
-
-		lastValue = value
 	}
 
 	return false, nil
@@ -333,8 +341,9 @@ func (f *Filter) Match(key [KeySize]byte, data []byte) (bool, error) {
 // probability) to be a member of the set represented by the filter faster than
 // calling Match() for each value individually.
 func (f *Filter) MatchAny(key [KeySize]byte, data [][]byte) (bool, error) {
-	// Basic sanity check.
-	if len(data) == 0 {
+	// Basic sanity check.  As with Match, an empty filter can't match
+	// anything.
+	if len(data) == 0 || f.n == 0 {
 		return false, nil
 	}
 
@@ -366,26 +375,19 @@ func (f *Filter) MatchAny(key [KeySize]byte, data [][]byte) (bool, error) {
 
 	// Zip down the filters, comparing values until we either run out of
 	// values to compare in one of the filters or we reach a matching
-	// value.
-	var lastValue1, lastValue2 uint64
-	lastValue2 = values[0]
-	i := 1
-	for lastValue1 != lastValue2 {
-		// Check which filter to advance to make sure we're comparing
-		// the right values.
-		switch {
-		case lastValue1 > lastValue2:
-			// Advance filter created from search terms or return
-			// false if we're at the end because nothing matched.
-			if i < len(values) {
-				lastValue2 = values[i]
-				i++
-			} else {
+	// value.  The first value of the filter is read up front so a search
+	// value of zero is only matched when the filter actually contains it.
+	var filterValue uint64
+	var read uint32
+	i := 0
+	for {
+		// Advance the filter we're searching while it is behind the
+		// current search value, or return false if we're at the end
+		// because nothing matched.
+		if read == 0 || filterValue < values[i] {
+			if read == f.n {
 				return false, nil
 			}
-		case lastValue2 > lastValue1:
-			// Advance filter we're searching or return false if
-			// we're at the end because nothing matched.
 			value, err := f.readFullUint64(b)
 			if err != nil {
 				if err == io.EOF {
@@ -393,13 +395,22 @@ func (f *Filter) MatchAny(key [KeySize]byte, data [][]byte) (bool, error) {
 				}
 				return false, err
 			}
-			lastValue1 += value
+			filterValue += value
+			read++
+			continue
 		}
-	}
 
-	// If we've made it this far, an element matched between filters so we
-	// return true.
-	return true, nil
+		if filterValue == values[i] {
+			return true, nil
+		}
+
+		// Advance the search values past the current filter value, or
+		// return false if we're at the end because nothing matched.
+		i++
+		if i == len(values) {
+			return false, nil
+		}
+	}
 }
 
 // readFullUint64 reads a value represented by the sum of a unary multiple of
@@ -250,3 +250,62 @@ func TestGCSFilterMatchAny(t *testing.T) {
 		t.Fatal("Filter didn't match any when it should have!")
 	}
 }
+
+// TestGCSFilterMatchEmpty ensures an empty filter never matches.
+func TestGCSFilterMatchEmpty(t *testing.T) {
+	empty, err := gcs.BuildGCSFilter(P, M, key, nil)
+	if err != nil {
+		t.Fatalf("Filter build failed: %s", err.Error())
+	}
+	match, err := empty.Match(key, []byte("Nate"))
+	if err != nil {
+		t.Fatalf("Filter match failed: %s", err.Error())
+	}
+	if match {
+		t.Fatal("Empty filter matched")
+	}
+	match, err = empty.MatchAny(key, contents)
+	if err != nil {
+		t.Fatalf("Filter match any failed: %s", err.Error())
+	}
+	if match {
+		t.Fatal("Empty filter matched any")
+	}
+}
+
+// TestGCSFilterMatchConsistency ensures Match and MatchAny agree for every
+// queried value, including ones reducing to the smallest and largest values
+// of a filter with a tiny range where such values are common.
+func TestGCSFilterMatchConsistency(t *testing.T) {
+	const p, m = 2, 4
+	f, err := gcs.BuildGCSFilter(p, m, key, contents)
+	if err != nil {
+		t.Fatalf("Filter build failed: %s", err.Error())
+	}
+
+	queries := append(append([][]byte{}, contents...), contents2...)
+	for i := 0; i < 200; i++ {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		queries = append(queries, buf[:])
+	}
+
+	for i, query := range queries {
+		match, err := f.Match(key, query)
+		if err != nil {
+			t.Fatalf("Filter match failed: %s", err.Error())
+		}
+		if i < len(contents) && !match {
+			t.Fatalf("Filter didn't match %q when it should have!",
+				query)
+		}
+		matchAny, err := f.MatchAny(key, [][]byte{query})
+		if err != nil {
+			t.Fatalf("Filter match any failed: %s", err.Error())
+		}
+		if match != matchAny {
+			t.Fatalf("Match and MatchAny disagree for %x: %v != %v",
+				query, match, matchAny)
+		}
+	}
+}
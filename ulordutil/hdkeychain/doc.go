@@ -46,6 +46,13 @@ the HardenedKeyStart constant + the hardened key number as the index to the
 Child function.  This provides the ability to cascade the keys into a tree and
 hence generate the hierarchical deterministic key chains.
 
+Derivation Paths
+
+Rather than calling Child repeatedly, a descendant key may be derived in one
+step with the Derive function and a DerivationPath.  The ParsePath function
+parses the familiar textual notation such as "m/44'/0'/0'/0/1", and BIP44Path
+builds the BIP0044 path for a given network, account, branch, and index.
+
 Normal vs Hardened Child Extended Keys
 
 A private extended key can be used to derive both hardened and non-hardened
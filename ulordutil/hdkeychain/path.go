@@ -0,0 +1,133 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain
+
+// References:
+//   [BIP44]: BIP0044 - Multi-Account Hierarchy for Deterministic Wallets
+//   https://github.com/bitcoin/bips/blob/master/bip-0044.mediawiki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+)
+
+const (
+	// BIP44Purpose is the purpose level index, without the hardened offset,
+	// used by [BIP44] derivation paths.
+	BIP44Purpose = 44
+
+	// ExternalBranch is the [BIP44] change level index used for addresses
+	// that are handed out to receive payments.
+	ExternalBranch = 0
+
+	// InternalBranch is the [BIP44] change level index used for change
+	// addresses.
+	InternalBranch = 1
+)
+
+// ErrInvalidPath describes an error in which a derivation path string could
+// not be parsed.
+var ErrInvalidPath = errors.New("invalid derivation path")
+
+// DerivationPath is a sequence of child indexes which describes how to reach a
+// descendant extended key from some ancestor, usually the master node.  Indexes
+// at or above HardenedKeyStart denote hardened children.
+type DerivationPath []uint32
+
+// ParsePath parses a derivation path in the textual form described by [BIP32],
+// such as "m/44'/0'/0'/0/1".  Hardened indexes may be marked with an apostrophe,
+// "h", or "H".  The leading "m" is optional, and "m" on its own describes the
+// empty path (the master node itself).
+func ParsePath(path string) (DerivationPath, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, ErrInvalidPath
+	}
+
+	elems := strings.Split(path, "/")
+	if elems[0] == "m" || elems[0] == "M" {
+		elems = elems[1:]
+	}
+
+	result := make(DerivationPath, 0, len(elems))
+	for _, elem := range elems {
+		hardened := false
+		switch {
+		case strings.HasSuffix(elem, "'"), strings.HasSuffix(elem, "h"),
+			strings.HasSuffix(elem, "H"):
+
+			hardened = true
+			elem = elem[:len(elem)-1]
+		}
+
+		// Only plain decimal digits are accepted so that signs and
+		// whitespace are rejected rather than silently tolerated.
+		if elem == "" || strings.TrimLeft(elem, "0123456789") != "" {
+			return nil, ErrInvalidPath
+		}
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, ErrInvalidPath
+		}
+		if hardened {
+			index += HardenedKeyStart
+		}
+		result = append(result, uint32(index))
+	}
+
+	return result, nil
+}
+
+// String returns the path in the textual form accepted by ParsePath, using an
+// apostrophe to denote hardened indexes.
+func (p DerivationPath) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("m")
+	for _, index := range p {
+		if index >= HardenedKeyStart {
+			fmt.Fprintf(&buf, "/%d'", index-HardenedKeyStart)
+			continue
+		}
+		fmt.Fprintf(&buf, "/%d", index)
+	}
+	return buf.String()
+}
+
+// BIP44Path returns the [BIP44] derivation path of the form
+// m/44'/coin_type'/account'/branch/index using the HD coin type registered for
+// the passed network.  The branch is normally ExternalBranch or InternalBranch.
+func BIP44Path(net *chaincfg.Params, account, branch, index uint32) DerivationPath {
+	return DerivationPath{
+		BIP44Purpose + HardenedKeyStart,
+		net.HDCoinType + HardenedKeyStart,
+		account + HardenedKeyStart,
+		branch,
+		index,
+	}
+}
+
+// Derive returns the descendant extended key reached by deriving each index of
+// the passed path in turn, starting from this extended key.  An empty path
+// returns the extended key itself.
+//
+// Any error returned by Child is passed through unaltered, so, for example,
+// ErrDeriveHardFromPublic is returned when the path contains a hardened index
+// and this is a public extended key.
+func (k *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+)
+
+// TestParsePath ensures derivation path strings are parsed as expected and
+// that malformed paths are rejected.
+func TestParsePath(t *testing.T) {
+	hkStart := uint32(HardenedKeyStart)
+
+	tests := []struct {
+		name string
+		path string
+		want DerivationPath
+		err  error
+	}{
+		{
+			name: "master only",
+			path: "m",
+			want: DerivationPath{},
+		},
+		{
+			name: "bip44 with apostrophes",
+			path: "m/44'/0'/0'/0/1",
+			want: DerivationPath{hkStart + 44, hkStart, hkStart, 0, 1},
+		},
+		{
+			name: "hardened with h and H markers",
+			path: "m/0h/1H/2",
+			want: DerivationPath{hkStart, hkStart + 1, 2},
+		},
+		{
+			name: "no leading m",
+			path: "0'/1",
+			want: DerivationPath{hkStart, 1},
+		},
+		{
+			name: "max indexes",
+			path: "m/2147483647'/2147483647",
+			want: DerivationPath{hkStart + 2147483647, 2147483647},
+		},
+		{name: "empty", path: "", err: ErrInvalidPath},
+		{name: "trailing slash", path: "m/0/", err: ErrInvalidPath},
+		{name: "double slash", path: "m//0", err: ErrInvalidPath},
+		{name: "negative", path: "m/-1", err: ErrInvalidPath},
+		{name: "plus sign", path: "m/+1", err: ErrInvalidPath},
+		{name: "not a number", path: "m/x'", err: ErrInvalidPath},
+		{name: "index too large", path: "m/2147483648", err: ErrInvalidPath},
+		{name: "hardened marker only", path: "m/'", err: ErrInvalidPath},
+		{name: "nested m", path: "m/m/0", err: ErrInvalidPath},
+	}
+
+	for i, test := range tests {
+		path, err := ParsePath(test.path)
+		if err != test.err {
+			t.Errorf("ParsePath #%d (%s): unexpected error -- got %v, "+
+				"want %v", i, test.name, err, test.err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(path, test.want) {
+			t.Errorf("ParsePath #%d (%s): mismatched path -- got %v, "+
+				"want %v", i, test.name, []uint32(path),
+				[]uint32(test.want))
+			continue
+		}
+
+		// Ensure the path round trips through its string form.
+		reparsed, err := ParsePath(path.String())
+		if err != nil {
+			t.Errorf("ParsePath #%d (%s): unable to reparse %q: %v",
+				i, test.name, path.String(), err)
+			continue
+		}
+		if !reflect.DeepEqual(reparsed, path) {
+			t.Errorf("ParsePath #%d (%s): round trip mismatch -- "+
+				"got %v, want %v", i, test.name, reparsed, path)
+		}
+	}
+}
+
+// TestBIP44Path ensures BIP44Path uses the coin type of the passed network.
+func TestBIP44Path(t *testing.T) {
+	tests := []struct {
+		net  *chaincfg.Params
+		want string
+	}{
+		{&chaincfg.MainNetParams, "m/44'/0'/3'/1/7"},
+		{&chaincfg.TestNet3Params, "m/44'/1'/3'/1/7"},
+	}
+
+	for i, test := range tests {
+		path := BIP44Path(test.net, 3, InternalBranch, 7)
+		if got := path.String(); got != test.want {
+			t.Errorf("BIP44Path #%d (%s): got %s, want %s", i,
+				test.net.Name, got, test.want)
+		}
+	}
+}
+
+// TestDerive ensures deriving along a parsed path yields the same key as
+// deriving each child individually, and that hardened derivation from a public
+// extended key is rejected.
+func TestDerive(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatalf("DecodeString: unexpected error: %v", err)
+	}
+	master, err := NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMaster: unexpected error: %v", err)
+	}
+
+	// Expected value taken from the [BIP32] test vector 1 chain
+	// m/0H/1/2H/2.
+	path, err := ParsePath("m/0'/1/2'/2")
+	if err != nil {
+		t.Fatalf("ParsePath: unexpected error: %v", err)
+	}
+	child, err := master.Derive(path)
+	if err != nil {
+		t.Fatalf("Derive: unexpected error: %v", err)
+	}
+	want := "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334"
+	if got := child.String(); got != want {
+		t.Fatalf("Derive: mismatched key -- got %s, want %s", got, want)
+	}
+
+	// An empty path returns the key itself.
+	same, err := master.Derive(DerivationPath{})
+	if err != nil {
+		t.Fatalf("Derive: unexpected error: %v", err)
+	}
+	if same != master {
+		t.Fatal("Derive: empty path did not return the same key")
+	}
+
+	// Hardened derivation is not possible from a public extended key.
+	pub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter: unexpected error: %v", err)
+	}
+	if _, err := pub.Derive(path); err != ErrDeriveHardFromPublic {
+		t.Fatalf("Derive: unexpected error -- got %v, want %v", err,
+			ErrDeriveHardFromPublic)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// maxPooledHexBuf is the capacity of the largest buffer EncodeToStringBuf
+// returns to its pool.  It fits the encoding of a block of the maximum size
+// so that buffers are reused for the largest payloads of the RPC server while
+// unusually large ones are left to the garbage collector.
+const maxPooledHexBuf = 2 * wire.MaxBlockPayload
+
+// hexBufPool holds the buffers EncodeToStringBuf encodes into.
+var hexBufPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// EncodeToStringBuf returns the hexadecimal encoding of src like
+// hex.EncodeToString.  The encoding is done in a pooled buffer so that the
+// returned string is the only allocation, instead of allocating both the
+// encoded bytes and the string copied from them.
+func EncodeToStringBuf(src []byte) string {
+	n := hex.EncodedLen(len(src))
+	bufp := hexBufPool.Get().(*[]byte)
+	if cap(*bufp) < n {
+		*bufp = make([]byte, n)
+	}
+	buf := (*bufp)[:n]
+	hex.Encode(buf, src)
+	s := string(buf)
+
+	if cap(buf) <= maxPooledHexBuf {
+		hexBufPool.Put(bufp)
+	}
+	return s
+}
+
+// DecodeInto decodes the hexadecimal string src into dst and returns the
+// decoded bytes, which share the memory of dst when its capacity suffices and
+// are newly allocated otherwise.  Unlike hex.DecodeString, src is not copied to
+// a byte slice first, so decoding a multi-megabyte payload allocates at most
+// the decoded bytes.
+//
+// The errors match the ones of hex.DecodeString: hex.ErrLength is returned for
+// a string of odd length and a hex.InvalidByteError for the first character
+// which is not a hexadecimal digit.
+func DecodeInto(dst []byte, src string) ([]byte, error) {
+	n := hex.DecodedLen(len(src))
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+
+	for i := 0; i < n; i++ {
+		a, ok := fromHexChar(src[2*i])
+		if !ok {
+			return nil, hex.InvalidByteError(src[2*i])
+		}
+		b, ok := fromHexChar(src[2*i+1])
+		if !ok {
+			return nil, hex.InvalidByteError(src[2*i+1])
+		}
+		dst[i] = a<<4 | b
+	}
+
+	// Like the hex package, an invalid character is reported before an odd
+	// length.
+	if len(src)%2 == 1 {
+		if _, ok := fromHexChar(src[len(src)-1]); !ok {
+			return nil, hex.InvalidByteError(src[len(src)-1])
+		}
+		return nil, hex.ErrLength
+	}
+	return dst, nil
+}
+
+// fromHexChar converts a hexadecimal character into its value and returns
+// whether it is one.
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
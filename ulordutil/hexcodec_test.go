@@ -0,0 +1,75 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestHexCodec ensures the pooled hex helpers produce the same results and
+// errors as the hex package.
+func TestHexCodec(t *testing.T) {
+	tests := []string{
+		"",
+		"00",
+		"0123456789abcdef",
+		"ABCDEF",
+		"0",
+		"0g",
+		"g0",
+		"012",
+		"01z",
+	}
+
+	for _, test := range tests {
+		want, wantErr := hex.DecodeString(test)
+		got, err := ulordutil.DecodeInto(nil, test)
+		if err != wantErr {
+			t.Errorf("DecodeInto(%q): got error %v, want %v", test,
+				err, wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("DecodeInto(%q): got %x, want %x", test, got,
+				want)
+		}
+
+		encoded := ulordutil.EncodeToStringBuf(got)
+		if encoded != hex.EncodeToString(got) {
+			t.Errorf("EncodeToStringBuf(%x): got %q", got, encoded)
+		}
+	}
+}
+
+// TestHexCodecAllocs ensures decoding into a large enough buffer does not
+// allocate and that encoding only allocates the returned string.
+func TestHexCodecAllocs(t *testing.T) {
+	data := bytes.Repeat([]byte{0xab}, 1<<16)
+	encoded := hex.EncodeToString(data)
+	dst := make([]byte, len(data))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := ulordutil.DecodeInto(dst, encoded); err != nil {
+			t.Fatalf("DecodeInto: unexpected error: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeInto: got %v allocations, want 0", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(100, func() {
+		ulordutil.EncodeToStringBuf(data)
+	})
+	if allocs > 1 {
+		t.Errorf("EncodeToStringBuf: got %v allocations, want 1", allocs)
+	}
+}
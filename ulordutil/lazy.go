@@ -0,0 +1,360 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// maxLazyPayload is the maximum number of bytes a lazily parsed block or
+	// transaction may span.  Bounding the buffer by the maximum message
+	// size means any input or output count and script length that fits in
+	// it is also accepted by the wire package, so the deferred
+	// deserialization can not fail once the layout has been scanned.
+	maxLazyPayload = wire.MaxMessagePayload
+
+	// maxTxPerBlock mirrors the limit the wire package imposes on the
+	// number of transactions in a block.
+	maxTxPerBlock = (wire.MaxBlockPayload / 10) + 1
+
+	// maxWitnessItemsPerInput and maxWitnessItemSize mirror the limits the
+	// wire package imposes on transaction witnesses.  Unlike the other
+	// limits, they are not implied by the size of the buffer.
+	maxWitnessItemsPerInput = 500000
+	maxWitnessItemSize      = 11000
+
+	// blockHeaderLen is the number of bytes of a serialized block header.
+	blockHeaderLen = 80
+)
+
+var (
+	// blockPool and txPool hold the Block and Tx wrappers given back by
+	// Release so lazily parsed blocks and transactions can reuse them
+	// instead of allocating new ones.
+	blockPool = sync.Pool{New: func() interface{} { return new(Block) }}
+	txPool    = sync.Pool{New: func() interface{} { return new(Tx) }}
+)
+
+// layoutError creates a wire.MessageError for the given function and
+// description, matching the errors returned when deserializing through the
+// wire package.
+func layoutError(f string, desc string) *wire.MessageError {
+	return &wire.MessageError{Func: f, Description: desc}
+}
+
+// skipBytes advances r by n bytes, returning io.ErrUnexpectedEOF when fewer
+// than n bytes remain.
+func skipBytes(r *bytes.Reader, n uint64) error {
+	if n > uint64(r.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(int64(n), io.SeekCurrent)
+	return err
+}
+
+// skipVarBytes advances r past a variable length byte array, rejecting arrays
+// longer than maxAllowed.
+func skipVarBytes(r *bytes.Reader, maxAllowed uint64, fieldName string) error {
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > maxAllowed {
+		str := fmt.Sprintf("%s is larger than the max allowed size "+
+			"[count %d, max %d]", fieldName, count, maxAllowed)
+		return layoutError("scanTx", str)
+	}
+	return skipBytes(r, count)
+}
+
+// scanTx walks the serialized transaction at the current position of r
+// without deserializing it.  It returns the number of bytes the transaction
+// spans and, for transactions with witness data, the offset of the witness
+// data from the start of the transaction.  The witness offset is zero for
+// transactions without witness data.
+//
+// The same structural checks as wire.MsgTx.Deserialize are performed, so a
+// transaction accepted here is guaranteed to deserialize successfully.
+func scanTx(r *bytes.Reader) (int, int, error) {
+	start := r.Len()
+
+	// Version.
+	if err := skipBytes(r, 4); err != nil {
+		return 0, 0, err
+	}
+
+	inCount, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// A zero input count indicates a transaction with witness data, which
+	// is followed by a flag byte and the actual input count.
+	var hasWitness bool
+	if inCount == 0 {
+		flag, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		if flag != 0x01 {
+			str := fmt.Sprintf("witness tx but flag byte is %x", flag)
+			return 0, 0, layoutError("scanTx", str)
+		}
+		hasWitness = true
+
+		inCount, err = wire.ReadVarInt(r, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Inputs are a previous outpoint, signature script, and sequence.
+	for i := uint64(0); i < inCount; i++ {
+		if err := skipBytes(r, chainhash.HashSize+4); err != nil {
+			return 0, 0, err
+		}
+		err := skipVarBytes(r, maxLazyPayload,
+			"transaction input signature script")
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := skipBytes(r, 4); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Outputs are a value and public key script.
+	outCount, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := uint64(0); i < outCount; i++ {
+		if err := skipBytes(r, 8); err != nil {
+			return 0, 0, err
+		}
+		err := skipVarBytes(r, maxLazyPayload,
+			"transaction output public key script")
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Each input has a witness stack when the transaction has witness
+	// data.
+	var witnessOffset int
+	if hasWitness {
+		var hasWitnessData bool
+		witnessOffset = start - r.Len()
+		for i := uint64(0); i < inCount; i++ {
+			witCount, err := wire.ReadVarInt(r, 0)
+			if err != nil {
+				return 0, 0, err
+			}
+			hasWitnessData = hasWitnessData || witCount != 0
+			if witCount > maxWitnessItemsPerInput {
+				str := fmt.Sprintf("too many witness items to "+
+					"fit into max message size [count %d, "+
+					"max %d]", witCount,
+					maxWitnessItemsPerInput)
+				return 0, 0, layoutError("scanTx", str)
+			}
+			for j := uint64(0); j < witCount; j++ {
+				err := skipVarBytes(r, maxWitnessItemSize,
+					"script witness item")
+				if err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+		if !hasWitnessData {
+			return 0, 0, layoutError("scanTx", "witness tx but no "+
+				"input has witness data")
+		}
+	}
+
+	// Lock time.
+	if err := skipBytes(r, 4); err != nil {
+		return 0, 0, err
+	}
+
+	return start - r.Len(), witnessOffset, nil
+}
+
+// rawTxHash returns the transaction hash of a serialized transaction with the
+// witness data at the passed offset, or no witness data when the offset is
+// zero.  The hash excludes the witness marker and flag bytes and the witness
+// data itself, so it is calculated over the bytes surrounding them without
+// copying the transaction.
+func rawTxHash(serializedTx []byte, witnessOffset int) chainhash.Hash {
+	if witnessOffset == 0 {
+		return chainhash.DoubleHashH(serializedTx)
+	}
+
+	h := sha256.New()
+	h.Write(serializedTx[:4])
+	h.Write(serializedTx[6:witnessOffset])
+	h.Write(serializedTx[len(serializedTx)-4:])
+	first := h.Sum(nil)
+	return chainhash.Hash(sha256.Sum256(first))
+}
+
+// rawTxHasWitness returns whether any input of a serialized transaction with
+// the witness data at the passed offset has a non-empty witness stack.  Empty
+// stacks are encoded as a single zero byte each, so the transaction has
+// witness data if and only if any byte of the witness data is not zero.
+func rawTxHasWitness(serializedTx []byte, witnessOffset int) bool {
+	if witnessOffset == 0 {
+		return false
+	}
+	for _, b := range serializedTx[witnessOffset : len(serializedTx)-4] {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newLazyTx returns a transaction from the free list that is backed by the
+// passed serialized transaction.  The transaction is deserialized on the
+// first call to MsgTx.
+func newLazyTx(serializedTx []byte, witnessOffset int) *Tx {
+	t := txPool.Get().(*Tx)
+	t.serializedTx = serializedTx
+	t.witnessOffset = witnessOffset
+	t.lazy = true
+	t.txIndex = TxIndexUnknown
+	return t
+}
+
+// NewTxFromBytesLazy returns a new instance of a bitcoin transaction backed by
+// the passed serialized bytes without deserializing it.  The layout of the
+// transaction is validated up front, while the wire.MsgTx is only created
+// when MsgTx is first called.  The hashes of the transaction are calculated
+// directly from the serialized bytes, so callers that only need them never
+// pay for the deserialization.
+//
+// The returned transaction references the passed slice rather than copying
+// it, so the caller must not modify the slice for as long as the transaction
+// is in use.  Release may be called once the transaction is no longer needed.
+func NewTxFromBytesLazy(serializedTx []byte) (*Tx, error) {
+	if len(serializedTx) > maxLazyPayload {
+		str := fmt.Sprintf("serialized transaction is larger than the "+
+			"max allowed size [count %d, max %d]",
+			len(serializedTx), maxLazyPayload)
+		return nil, layoutError("NewTxFromBytesLazy", str)
+	}
+
+	r := bytes.NewReader(serializedTx)
+	txLen, witnessOffset, err := scanTx(r)
+	if err != nil {
+		return nil, err
+	}
+	return newLazyTx(serializedTx[:txLen:txLen], witnessOffset), nil
+}
+
+// NewBlockFromBytesLazy returns a new instance of a bitcoin block backed by
+// the passed serialized bytes without deserializing it.  The layout of the
+// block and all of its transactions is validated up front, while the
+// wire.MsgBlock and the wire.MsgTx of each transaction are only created when
+// they are first accessed.  Each wrapped transaction references its own
+// subslice of the block, and the block and transaction hashes are calculated
+// directly from the serialized bytes.
+//
+// The returned block references the passed slice rather than copying it, so
+// the caller must not modify the slice for as long as the block or any of its
+// transactions are in use.  Release may be called once the block is no longer
+// needed so its memory can be reused by later calls.
+func NewBlockFromBytesLazy(serializedBlock []byte) (*Block, error) {
+	if len(serializedBlock) > maxLazyPayload {
+		str := fmt.Sprintf("serialized block is larger than the max "+
+			"allowed size [count %d, max %d]",
+			len(serializedBlock), maxLazyPayload)
+		return nil, layoutError("NewBlockFromBytesLazy", str)
+	}
+
+	r := bytes.NewReader(serializedBlock)
+	if err := skipBytes(r, blockHeaderLen); err != nil {
+		return nil, err
+	}
+	txCount, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prevent more transactions than could possibly fit into a block.
+	if txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", txCount, maxTxPerBlock)
+		return nil, layoutError("NewBlockFromBytesLazy", str)
+	}
+
+	b := blockPool.Get().(*Block)
+	for i := uint64(0); i < txCount; i++ {
+		txStart := len(serializedBlock) - r.Len()
+		txLen, witnessOffset, err := scanTx(r)
+		if err != nil {
+			b.Release()
+			return nil, err
+		}
+
+		txEnd := txStart + txLen
+		tx := newLazyTx(serializedBlock[txStart:txEnd:txEnd],
+			witnessOffset)
+		tx.SetIndex(int(i))
+		b.transactions = append(b.transactions, tx)
+		b.txLocs = append(b.txLocs, wire.TxLoc{
+			TxStart: txStart,
+			TxLen:   txLen,
+		})
+	}
+
+	end := len(serializedBlock) - r.Len()
+	b.serializedBlock = serializedBlock[:end:end]
+	b.blockHeight = BlockHeightUnknown
+	b.txnsGenerated = true
+	b.lazy = true
+	return b, nil
+}
+
+// Release returns the block and all of its wrapped transactions to a free
+// list so their memory can be reused by later calls to NewBlockFromBytesLazy.
+// Neither the block nor any transaction obtained from it may be used after
+// calling Release.
+func (b *Block) Release() {
+	for _, tx := range b.transactions {
+		if tx != nil {
+			tx.Release()
+		}
+	}
+
+	// Keep the backing arrays of the transaction and location slices for
+	// reuse, but drop the references to the released transactions.
+	txns := b.transactions[:cap(b.transactions)]
+	for i := range txns {
+		txns[i] = nil
+	}
+	*b = Block{
+		transactions: txns[:0],
+		txLocs:       b.txLocs[:0],
+	}
+	blockPool.Put(b)
+}
+
+// Release returns the transaction to a free list so its memory can be reused
+// by later lazily parsed transactions.  The transaction may not be used after
+// calling Release.  Transactions obtained from a Block are released along
+// with the block and must not be released individually.
+func (t *Tx) Release() {
+	*t = Tx{}
+	txPool.Put(t)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// lazyTestBlock returns a copy of block 100,000 with an additional witness
+// transaction so both transaction encodings are covered.
+func lazyTestBlock() *wire.MsgBlock {
+	witnessTx := Block100000.Transactions[1].Copy()
+	witnessTx.TxIn[0].Witness = wire.TxWitness{
+		{0x01, 0x02, 0x03},
+		{},
+		bytes.Repeat([]byte{0x04}, 300),
+	}
+
+	block := Block100000
+	block.Transactions = append([]*wire.MsgTx{}, Block100000.Transactions...)
+	block.Transactions = append(block.Transactions, witnessTx)
+	return &block
+}
+
+// TestNewBlockFromBytesLazy ensures lazily parsed blocks and their
+// transactions behave the same as fully deserialized ones.
+func TestNewBlockFromBytesLazy(t *testing.T) {
+	msgBlock := lazyTestBlock()
+	var buf bytes.Buffer
+	if err := msgBlock.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	want, err := ulordutil.NewBlockFromBytes(serialized)
+	if err != nil {
+		t.Fatalf("NewBlockFromBytes: %v", err)
+	}
+	b, err := ulordutil.NewBlockFromBytesLazy(serialized)
+	if err != nil {
+		t.Fatalf("NewBlockFromBytesLazy: %v", err)
+	}
+	defer b.Release()
+
+	if !b.Hash().IsEqual(want.Hash()) {
+		t.Errorf("Hash: mismatched hash - got %v, want %v", b.Hash(),
+			want.Hash())
+	}
+	if b.Height() != ulordutil.BlockHeightUnknown {
+		t.Errorf("Height: got %d, want %d", b.Height(),
+			ulordutil.BlockHeightUnknown)
+	}
+	gotBytes, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if &gotBytes[0] != &serialized[0] || len(gotBytes) != len(serialized) {
+		t.Error("Bytes: serialized block was copied")
+	}
+
+	gotLocs, err := b.TxLoc()
+	if err != nil {
+		t.Fatalf("TxLoc: %v", err)
+	}
+	wantLocs, err := want.TxLoc()
+	if err != nil {
+		t.Fatalf("TxLoc: %v", err)
+	}
+	if !reflect.DeepEqual(gotLocs, wantLocs) {
+		t.Errorf("TxLoc: mismatched locations - got %v, want %v",
+			spew.Sdump(gotLocs), spew.Sdump(wantLocs))
+	}
+
+	// The hashes of the transactions must be calculated from the
+	// serialized bytes.
+	for i, wantTx := range want.Transactions() {
+		tx, err := b.Tx(i)
+		if err != nil {
+			t.Errorf("Tx #%d: %v", i, err)
+			continue
+		}
+		if tx.Index() != i {
+			t.Errorf("Index #%d: got %d", i, tx.Index())
+		}
+		if !tx.Hash().IsEqual(wantTx.Hash()) {
+			t.Errorf("Hash #%d: mismatched hash - got %v, want %v",
+				i, tx.Hash(), wantTx.Hash())
+		}
+		if !tx.WitnessHash().IsEqual(wantTx.WitnessHash()) {
+			t.Errorf("WitnessHash #%d: mismatched hash - got %v, "+
+				"want %v", i, tx.WitnessHash(),
+				wantTx.WitnessHash())
+		}
+		if tx.HasWitness() != wantTx.HasWitness() {
+			t.Errorf("HasWitness #%d: got %v, want %v", i,
+				tx.HasWitness(), wantTx.HasWitness())
+		}
+
+		txBytes, err := tx.Bytes()
+		if err != nil {
+			t.Errorf("Bytes #%d: %v", i, err)
+			continue
+		}
+		loc := wantLocs[i]
+		if !bytes.Equal(txBytes, serialized[loc.TxStart:loc.TxStart+loc.TxLen]) {
+			t.Errorf("Bytes #%d: mismatched bytes", i)
+		}
+	}
+
+	// Deserializing the block must share the transactions.
+	if !reflect.DeepEqual(b.MsgBlock(), want.MsgBlock()) {
+		t.Errorf("MsgBlock: mismatched MsgBlock - got %v, want %v",
+			spew.Sdump(b.MsgBlock()), spew.Sdump(want.MsgBlock()))
+	}
+	for i, tx := range b.Transactions() {
+		if b.MsgBlock().Transactions[i] != tx.MsgTx() {
+			t.Errorf("MsgBlock: transaction #%d is not shared", i)
+		}
+	}
+
+	_, err = b.Tx(len(msgBlock.Transactions))
+	if _, ok := err.(ulordutil.OutOfRangeError); !ok {
+		t.Errorf("Tx: wrong error - got: %v <%T>, want: <%T>", err,
+			err, ulordutil.OutOfRangeError(""))
+	}
+}
+
+// TestLazyErrors ensures truncated and malformed data is rejected up front
+// rather than when it is deserialized.
+func TestLazyErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := lazyTestBlock().Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	// Every truncation of the block must be rejected.
+	for i := 0; i < len(serialized); i++ {
+		_, err := ulordutil.NewBlockFromBytesLazy(serialized[:i])
+		if err == nil {
+			t.Errorf("NewBlockFromBytesLazy(%d bytes): unexpected "+
+				"success", i)
+		}
+	}
+
+	// A witness marker must be followed by the witness flag.
+	tx := Block100000.Transactions[1].Copy()
+	tx.TxIn[0].Witness = wire.TxWitness{{0x01}}
+	buf.Reset()
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	badFlag := buf.Bytes()
+	badFlag[5] = 0x02
+	_, err := ulordutil.NewTxFromBytesLazy(badFlag)
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("NewTxFromBytesLazy: wrong error - got: %v <%T>, "+
+			"want: <%T>", err, err, &wire.MessageError{})
+	}
+
+	// Witness items larger than the wire package allows must be rejected.
+	tx.TxIn[0].Witness = wire.TxWitness{make([]byte, 11001)}
+	buf.Reset()
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	_, err = ulordutil.NewTxFromBytesLazy(buf.Bytes())
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("NewTxFromBytesLazy: wrong error - got: %v <%T>, "+
+			"want: <%T>", err, err, &wire.MessageError{})
+	}
+}
+
+// TestNewTxFromBytesLazy ensures lazily parsed transactions only use the
+// bytes they span and deserialize to the original transaction.
+func TestNewTxFromBytesLazy(t *testing.T) {
+	msgTx := lazyTestBlock().Transactions[4]
+	var buf bytes.Buffer
+	if err := msgTx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	txLen := buf.Len()
+	buf.Write([]byte{0xde, 0xad})
+
+	tx, err := ulordutil.NewTxFromBytesLazy(buf.Bytes())
+	if err != nil {
+		t.Fatalf("NewTxFromBytesLazy: %v", err)
+	}
+	defer tx.Release()
+
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if len(txBytes) != txLen {
+		t.Errorf("Bytes: got %d bytes, want %d", len(txBytes), txLen)
+	}
+	if tx.Index() != ulordutil.TxIndexUnknown {
+		t.Errorf("Index: got %d, want %d", tx.Index(),
+			ulordutil.TxIndexUnknown)
+	}
+	wantHash := msgTx.TxHash()
+	if !tx.Hash().IsEqual(&wantHash) {
+		t.Errorf("Hash: mismatched hash - got %v, want %v", tx.Hash(),
+			wantHash)
+	}
+	if !reflect.DeepEqual(tx.MsgTx(), msgTx) {
+		t.Errorf("MsgTx: mismatched MsgTx - got %v, want %v",
+			spew.Sdump(tx.MsgTx()), spew.Sdump(msgTx))
+	}
+
+	// A precomputed hash must be used instead of hashing again.
+	tx.SetHash(&wantHash)
+	if tx.Hash() != &wantHash {
+		t.Error("SetHash: precomputed hash not used")
+	}
+}
+
+// TestLazyRelease ensures released blocks can be reused for other blocks
+// without retaining any state of the previous one.
+func TestLazyRelease(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Block100000.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	serialized := buf.Bytes()
+	wantHash := Block100000.BlockHash()
+
+	for i := 0; i < 3; i++ {
+		b, err := ulordutil.NewBlockFromBytesLazy(serialized)
+		if err != nil {
+			t.Fatalf("NewBlockFromBytesLazy #%d: %v", i, err)
+		}
+		if !b.Hash().IsEqual(&wantHash) {
+			t.Errorf("Hash #%d: mismatched hash - got %v, want %v",
+				i, b.Hash(), wantHash)
+		}
+		if n := len(b.Transactions()); n != len(Block100000.Transactions) {
+			t.Errorf("Transactions #%d: got %d transactions, want "+
+				"%d", i, n, len(Block100000.Transactions))
+		}
+		if i == 1 {
+			b.SetHeight(100000)
+			b.MsgBlock()
+		}
+		b.Release()
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+// MessageMagic is the string prepended to messages before they are hashed and
+// signed, so a signed message can never be mistaken for a signed transaction.
+const MessageMagic = "Ulord Signed Message:\n"
+
+// ErrMalformedSignature describes an error where a message signature could not
+// be decoded from base64.
+var ErrMalformedSignature = errors.New("malformed base64 encoding of " +
+	"message signature")
+
+// MessageHash returns the hash of a message that is signed by SignMessage.  It
+// is the double sha256 of the message magic and the message, each serialized
+// as a variable length string.
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, MessageMagic)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// SignMessage signs the message with the private key and returns the base64
+// encoded compact signature.  The compressed flag selects whether the public
+// key recovered from the signature is serialized compressed, which determines
+// the pay-to-pubkey-hash address that verifies it.
+func SignMessage(key *ulordec.PrivateKey, message string, compressed bool) (string, error) {
+	sig, err := ulordec.SignCompact(ulordec.S256(), key, MessageHash(message),
+		compressed)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// RecoverMessagePubKey recovers the public key that created the base64 encoded
+// compact signature of the message.  The returned flag reports whether the
+// signature was created for the compressed serialization of the key.
+//
+// Every well-formed compact signature recovers to some public key, so the
+// signature is only proven valid by comparing the key, or an address derived
+// from it, with the expected one.
+func RecoverMessagePubKey(signature, message string) (*ulordec.PublicKey, bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, false, ErrMalformedSignature
+	}
+	return ulordec.RecoverCompact(ulordec.S256(), sig, MessageHash(message))
+}
+
+// RecoverMessageAddress recovers the pay-to-pubkey-hash address for the passed
+// network that created the base64 encoded compact signature of the message.
+func RecoverMessageAddress(signature, message string, net *chaincfg.Params) (*AddressPubKeyHash, error) {
+	pubKey, compressed, err := RecoverMessagePubKey(signature, message)
+	if err != nil {
+		return nil, err
+	}
+	return NewAddressPubKeyHash(messagePubKeyHash(pubKey, compressed), net)
+}
+
+// VerifyMessage returns whether the base64 encoded compact signature of the
+// message was created by the key of the passed pay-to-pubkey-hash address.  An
+// error is returned when the address is not a pay-to-pubkey-hash address or
+// the signature is not valid base64.  Signatures that can't be recovered are
+// reported as not verified rather than as errors, mirroring the reference
+// implementation.
+func VerifyMessage(addr Address, signature, message string) (bool, error) {
+	pkHashAddr, ok := addr.(*AddressPubKeyHash)
+	if !ok {
+		return false, ErrUnknownAddressType
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, ErrMalformedSignature
+	}
+	pubKey, compressed, err := ulordec.RecoverCompact(ulordec.S256(), sig,
+		MessageHash(message))
+	if err != nil {
+		return false, nil
+	}
+
+	pkHash := messagePubKeyHash(pubKey, compressed)
+	return bytes.Equal(pkHash, pkHashAddr.ScriptAddress()), nil
+}
+
+// messagePubKeyHash returns the hash160 of the public key using the
+// serialization a message signature was created for.
+func messagePubKeyHash(pubKey *ulordec.PublicKey, compressed bool) []byte {
+	if compressed {
+		return Hash160(pubKey.SerializeCompressed())
+	}
+	return Hash160(pubKey.SerializeUncompressed())
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestSignMessage ensures signed messages verify against and recover the
+// address of the signing key for both public key serializations.
+func TestSignMessage(t *testing.T) {
+	privKey, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pubKey := privKey.PubKey()
+	const message = "ulord message signing"
+
+	tests := []struct {
+		name       string
+		compressed bool
+		serialized []byte
+	}{
+		{"compressed", true, pubKey.SerializeCompressed()},
+		{"uncompressed", false, pubKey.SerializeUncompressed()},
+	}
+
+	for i, test := range tests {
+		addr, err := ulordutil.NewAddressPubKeyHash(
+			ulordutil.Hash160(test.serialized), &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash #%d (%s): %v", i,
+				test.name, err)
+		}
+
+		sig, err := ulordutil.SignMessage(privKey, message,
+			test.compressed)
+		if err != nil {
+			t.Errorf("SignMessage #%d (%s): unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+
+		ok, err := ulordutil.VerifyMessage(addr, sig, message)
+		if err != nil || !ok {
+			t.Errorf("VerifyMessage #%d (%s): got (%v, %v), want "+
+				"(true, <nil>)", i, test.name, ok, err)
+		}
+		ok, err = ulordutil.VerifyMessage(addr, sig, message+"!")
+		if err != nil || ok {
+			t.Errorf("VerifyMessage #%d (%s): altered message "+
+				"got (%v, %v), want (false, <nil>)", i,
+				test.name, ok, err)
+		}
+
+		recovered, err := ulordutil.RecoverMessageAddress(sig, message,
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("RecoverMessageAddress #%d (%s): unexpected "+
+				"error: %v", i, test.name, err)
+			continue
+		}
+		if recovered.EncodeAddress() != addr.EncodeAddress() {
+			t.Errorf("RecoverMessageAddress #%d (%s): got %s, want "+
+				"%s", i, test.name, recovered.EncodeAddress(),
+				addr.EncodeAddress())
+		}
+
+		key, compressed, err := ulordutil.RecoverMessagePubKey(sig,
+			message)
+		if err != nil {
+			t.Errorf("RecoverMessagePubKey #%d (%s): unexpected "+
+				"error: %v", i, test.name, err)
+			continue
+		}
+		if !key.IsEqual(pubKey) || compressed != test.compressed {
+			t.Errorf("RecoverMessagePubKey #%d (%s): mismatched "+
+				"key", i, test.name)
+		}
+	}
+}
+
+// TestVerifyMessageErrors ensures malformed input is rejected with the
+// expected errors.
+func TestVerifyMessageErrors(t *testing.T) {
+	privKey, err := ulordec.NewPrivateKey(ulordec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	serialized := privKey.PubKey().SerializeCompressed()
+	pkHashAddr, err := ulordutil.NewAddressPubKeyHash(
+		ulordutil.Hash160(serialized), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: %v", err)
+	}
+	scriptHashAddr, err := ulordutil.NewAddressScriptHashFromHash(
+		ulordutil.Hash160(serialized), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: %v", err)
+	}
+	sig, err := ulordutil.SignMessage(privKey, "msg", true)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		addr ulordutil.Address
+		sig  string
+		ok   bool
+		err  error
+	}{
+		{"not p2pkh", scriptHashAddr, sig, false,
+			ulordutil.ErrUnknownAddressType},
+		{"bad base64", pkHashAddr, "!!", false,
+			ulordutil.ErrMalformedSignature},
+		{"short signature", pkHashAddr, "AAAA", false, nil},
+		{"valid", pkHashAddr, sig, true, nil},
+	}
+
+	for i, test := range tests {
+		ok, err := ulordutil.VerifyMessage(test.addr, test.sig, "msg")
+		if ok != test.ok || err != test.err {
+			t.Errorf("VerifyMessage #%d (%s): got (%v, %v), want "+
+				"(%v, %v)", i, test.name, ok, err, test.ok,
+				test.err)
+		}
+	}
+
+	// The message hash must commit to the magic.
+	serializedMsg := append([]byte{byte(len(ulordutil.MessageMagic))},
+		ulordutil.MessageMagic...)
+	serializedMsg = append(serializedMsg, 0x01, 'a')
+	want := chainhash.DoubleHashB(serializedMsg)
+	if got := ulordutil.MessageHash("a"); !bytes.Equal(got, want) {
+		t.Errorf("MessageHash: got %x, want %x", got, want)
+	}
+}
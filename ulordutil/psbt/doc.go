@@ -0,0 +1,40 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package psbt provides an API for working with Partially Signed Bitcoin
+Transactions (BIP0174) over ulord wire transactions.
+
+Overview
+
+A PSBT carries an unsigned transaction together with the per-input and
+per-output metadata that signers need, such as previous outputs, redeem and
+witness scripts, BIP0032 derivation paths, and the partial signatures collected
+so far.  It lets several parties, possibly offline, each contribute to a
+transaction before it is broadcast.
+
+Roles
+
+BIP0174 separates the lifetime of a PSBT into roles, each of which maps onto
+this package as follows:
+
+  - Creator: New and NewFromUnsignedTx build a Packet from an unsigned
+    transaction.
+  - Updater: Updater adds UTXO information, scripts, sighash types, and
+    derivation paths to the inputs and outputs.
+  - Signer: Updater.Sign attaches a signature produced elsewhere after
+    checking it against the information in the input.
+  - Finalizer: Finalize and MaybeFinalizeAll build the final scriptSig and
+    witness for inputs that have enough signatures.
+  - Extractor: Extract produces the network-serializable wire.MsgTx once every
+    input is final.
+
+Serialization
+
+Packets are serialized in the BIP0174 binary key-value format with Serialize
+and B64Encode, and parsed with NewFromRawBytes.  Unknown keys are preserved so
+that packets can be passed through this package without losing data added by
+other software.
+*/
+package psbt
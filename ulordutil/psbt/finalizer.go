@@ -0,0 +1,269 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Finalizer requires provision of a single PSBT input in which all
+// necessary signatures are encoded, and uses it to construct valid final
+// scriptSig and scriptWitness fields.  The supported script types are
+// P2PKH, P2PK, P2SH multisig, P2WKH, P2WSH multisig, and the P2SH-wrapped
+// forms of the two witness types.
+
+import (
+	"bytes"
+
+	"github.com/ulordsuite/ulord/txscript"
+)
+
+// isFinalized considers this input finalized if it contains at least one of
+// the FinalScriptSig or FinalScriptWitness are filled (which only occurs in a
+// successful call to Finalize*).
+func isFinalized(p *Packet, inIndex int) bool {
+	return p.Inputs[inIndex].isFinalized()
+}
+
+// spentPkScript returns the public key script of the output spent by the
+// input at the passed index, or nil if the input carries no UTXO information.
+func spentPkScript(p *Packet, inIndex int) []byte {
+	pInput := &p.Inputs[inIndex]
+	if pInput.WitnessUtxo != nil {
+		return pInput.WitnessUtxo.PkScript
+	}
+	if pInput.NonWitnessUtxo != nil {
+		outIndex := p.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+		if int(outIndex) < len(pInput.NonWitnessUtxo.TxOut) {
+			return pInput.NonWitnessUtxo.TxOut[outIndex].PkScript
+		}
+	}
+	return nil
+}
+
+// multiSigSigs returns the signatures from the passed partial signatures
+// needed to satisfy the passed multisig script, in the order of the public
+// keys in the script as required by OP_CHECKMULTISIG.
+func multiSigSigs(script []byte, partialSigs []*PartialSig) ([][]byte, error) {
+	if !checkIsMultiSigScript(script) {
+		return nil, ErrUnsupportedScriptType
+	}
+	_, nRequired, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return nil, ErrUnsupportedScriptType
+	}
+
+	// The only data pushes in a standard multisig script are the public
+	// keys, in order.
+	pubKeys, err := txscript.PushedData(script)
+	if err != nil {
+		return nil, ErrUnsupportedScriptType
+	}
+
+	sigs := make([][]byte, 0, nRequired)
+	for _, pubKey := range pubKeys {
+		if len(sigs) == nRequired {
+			break
+		}
+		for _, ps := range partialSigs {
+			if bytes.Equal(ps.PubKey, pubKey) {
+				sigs = append(sigs, ps.Signature)
+				break
+			}
+		}
+	}
+	if len(sigs) < nRequired {
+		return nil, ErrNotFinalizable
+	}
+
+	return sigs, nil
+}
+
+// singleSig returns the only partial signature of an input spending a single
+// key output.
+func singleSig(partialSigs []*PartialSig) (*PartialSig, error) {
+	if len(partialSigs) != 1 {
+		return nil, ErrNotFinalizable
+	}
+	return partialSigs[0], nil
+}
+
+// finalScripts builds the final scriptSig and witness for the input at the
+// passed index from the partial signatures and scripts it carries.  Either
+// may be nil when not needed by the spent output.
+func finalScripts(p *Packet, inIndex int) ([]byte, [][]byte, error) {
+	pInput := &p.Inputs[inIndex]
+	pkScript := spentPkScript(p, inIndex)
+	if pkScript == nil {
+		return nil, nil, ErrNotFinalizable
+	}
+	if len(pInput.PartialSigs) == 0 {
+		return nil, nil, ErrNotFinalizable
+	}
+
+	// Outputs paying to a script hash are satisfied by pushing the redeem
+	// script, which may itself be a witness program.
+	var scriptSig []byte
+	script := pkScript
+	if txscript.IsPayToScriptHash(pkScript) {
+		if !checkRedeemScript(pkScript, pInput.RedeemScript) {
+			return nil, nil, ErrNotFinalizable
+		}
+		script = pInput.RedeemScript
+
+		// Legacy P2SH multisig is spent entirely from the scriptSig.
+		// The leading OP_0 is consumed by the OP_CHECKMULTISIG bug.
+		if pInput.WitnessUtxo == nil {
+			sigs, err := multiSigSigs(script, pInput.PartialSigs)
+			if err != nil {
+				return nil, nil, err
+			}
+			builder := txscript.NewScriptBuilder().AddOp(txscript.OP_0)
+			for _, sig := range sigs {
+				builder.AddData(sig)
+			}
+			scriptSig, err = builder.AddData(script).Script()
+			if err != nil {
+				return nil, nil, err
+			}
+			return scriptSig, nil, nil
+		}
+
+		var err error
+		scriptSig, err = txscript.NewScriptBuilder().AddData(script).Script()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch {
+	case pInput.WitnessUtxo != nil && txscript.IsPayToWitnessPubKeyHash(script):
+		ps, err := singleSig(pInput.PartialSigs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return scriptSig, [][]byte{ps.Signature, ps.PubKey}, nil
+
+	case pInput.WitnessUtxo != nil && txscript.IsPayToWitnessScriptHash(script):
+		if !checkWitnessScript(script, pInput.WitnessScript) {
+			return nil, nil, ErrNotFinalizable
+		}
+		sigs, err := multiSigSigs(pInput.WitnessScript, pInput.PartialSigs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The empty first item is consumed by the OP_CHECKMULTISIG
+		// bug.
+		witness := make([][]byte, 0, len(sigs)+2)
+		witness = append(witness, nil)
+		witness = append(witness, sigs...)
+		witness = append(witness, pInput.WitnessScript)
+		return scriptSig, witness, nil
+
+	case pInput.WitnessUtxo != nil:
+		return nil, nil, ErrUnsupportedScriptType
+	}
+
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy:
+		ps, err := singleSig(pInput.PartialSigs)
+		if err != nil {
+			return nil, nil, err
+		}
+		scriptSig, err := txscript.NewScriptBuilder().
+			AddData(ps.Signature).AddData(ps.PubKey).Script()
+		return scriptSig, nil, err
+
+	case txscript.PubKeyTy:
+		ps, err := singleSig(pInput.PartialSigs)
+		if err != nil {
+			return nil, nil, err
+		}
+		scriptSig, err := txscript.NewScriptBuilder().
+			AddData(ps.Signature).Script()
+		return scriptSig, nil, err
+	}
+
+	return nil, nil, ErrUnsupportedScriptType
+}
+
+// isFinalizable checks whether the structure of the entry for the input of
+// the Packet at index inIndex contains sufficient information to finalize
+// this input.
+func isFinalizable(p *Packet, inIndex int) bool {
+	_, _, err := finalScripts(p, inIndex)
+	return err == nil
+}
+
+// MaybeFinalize attempts to finalize the input at index inIndex in the PSBT
+// p, returning true with no error if it succeeds, OR if the input has already
+// been finalized.
+func MaybeFinalize(p *Packet, inIndex int) (bool, error) {
+	if inIndex < 0 || inIndex >= len(p.Inputs) {
+		return false, ErrInvalidPsbtFormat
+	}
+	if isFinalized(p, inIndex) {
+		return true, nil
+	}
+	if !isFinalizable(p, inIndex) {
+		return false, ErrNotFinalizable
+	}
+	if err := Finalize(p, inIndex); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MaybeFinalizeAll attempts to finalize all inputs of the Packet that are not
+// already finalized.  It returns an error if any input can't be finalized.
+func MaybeFinalizeAll(p *Packet) error {
+	for i := range p.UnsignedTx.TxIn {
+		success, err := MaybeFinalize(p, i)
+		if err != nil || !success {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finalize assumes that the provided Packet struct has all partial signatures
+// and redeem scripts/witness scripts already prepared for the specified
+// input, and so removes all temporary data and replaces them with completed
+// scriptSig and witness fields, which are stored in key-types 07 and 08.  The
+// witness/non-witness cases are inferred from the presence or absence of the
+// WitnessUtxo field in the input.
+func Finalize(p *Packet, inIndex int) error {
+	if inIndex < 0 || inIndex >= len(p.Inputs) {
+		return ErrInvalidPsbtFormat
+	}
+	if isFinalized(p, inIndex) {
+		return ErrInputAlreadyFinalized
+	}
+
+	scriptSig, witness, err := finalScripts(p, inIndex)
+	if err != nil {
+		return err
+	}
+
+	pInput := &p.Inputs[inIndex]
+	var finalWitness []byte
+	if witness != nil {
+		finalWitness, err = writeTxWitness(witness)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The signatures and scripts are no longer needed once the final
+	// fields are in place, so they are cleared as required by BIP174.
+	pInput.PartialSigs = nil
+	pInput.SighashType = 0
+	pInput.RedeemScript = nil
+	pInput.WitnessScript = nil
+	pInput.Bip32Derivation = nil
+	pInput.FinalScriptSig = scriptSig
+	pInput.FinalScriptWitness = finalWitness
+
+	return p.SanityCheck()
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+
+	"github.com/ulordsuite/ulord/ulordec"
+)
+
+// PartialSig encapsulates a (public key, signature) pair.  The signature is a
+// DER encoded signature followed by the sighash type byte.
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// PartialSigSorter implements sort.Interface for PartialSig.
+type PartialSigSorter []*PartialSig
+
+func (s PartialSigSorter) Len() int { return len(s) }
+
+func (s PartialSigSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s PartialSigSorter) Less(i, j int) bool {
+	return bytes.Compare(s[i].PubKey, s[j].PubKey) < 0
+}
+
+// validatePubkey checks that the passed bytes are a valid secp256k1 public key.
+func validatePubkey(pubKey []byte) bool {
+	_, err := ulordec.ParsePubKey(pubKey, ulordec.S256())
+	return err == nil
+}
+
+// validateSignature checks that the passed bytes are a DER encoded signature
+// followed by a sighash type byte.
+func validateSignature(sig []byte) bool {
+	if len(sig) < 2 {
+		return false
+	}
+	_, err := ulordec.ParseDERSignature(sig[:len(sig)-1], ulordec.S256())
+	return err == nil
+}
+
+// checkValid checks that both the public key and signature are valid.
+func (ps *PartialSig) checkValid() bool {
+	return validatePubkey(ps.PubKey) && validateSignature(ps.Signature)
+}
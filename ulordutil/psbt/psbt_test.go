@@ -0,0 +1,463 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/chaincfg"
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/ulordec"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// testKey returns a deterministic private key derived from the passed seed
+// byte along with its compressed public key.
+func testKey(seed byte) (*ulordec.PrivateKey, []byte) {
+	keyBytes := bytes.Repeat([]byte{seed}, 32)
+	priv, pub := ulordec.PrivKeyFromBytes(ulordec.S256(), keyBytes)
+	return priv, pub.SerializeCompressed()
+}
+
+// mustScript panics if the passed script builder error is non-nil, allowing
+// scripts to be built inline in test tables.
+func mustScript(script []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// p2pkhScript returns a pay-to-pubkey-hash script for the passed public key.
+func p2pkhScript(pubKey []byte) []byte {
+	addr, err := ulordutil.NewAddressPubKeyHash(ulordutil.Hash160(pubKey),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		panic(err)
+	}
+	return mustScript(txscript.PayToAddrScript(addr))
+}
+
+// p2shScript returns a pay-to-script-hash script for the passed script.
+func p2shScript(script []byte) []byte {
+	addr, err := ulordutil.NewAddressScriptHash(script,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		panic(err)
+	}
+	return mustScript(txscript.PayToAddrScript(addr))
+}
+
+// p2wkhScript returns a version 0 pay-to-witness-pubkey-hash script for the
+// passed public key.
+func p2wkhScript(pubKey []byte) []byte {
+	return mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(ulordutil.Hash160(pubKey)).Script())
+}
+
+// p2wshScript returns a version 0 pay-to-witness-script-hash script for the
+// passed witness script.
+func p2wshScript(script []byte) []byte {
+	return mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(chainhash.HashB(script)).Script())
+}
+
+// multiSigScript returns a 2-of-n multisig script for the passed public keys.
+func multiSigScript(pubKeys ...[]byte) []byte {
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_2)
+	for _, pubKey := range pubKeys {
+		builder.AddData(pubKey)
+	}
+	builder.AddInt64(int64(len(pubKeys)))
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	return mustScript(builder.Script())
+}
+
+// fundingTx returns a transaction with a single output paying the passed
+// amount to the passed script.
+func fundingTx(pkScript []byte, amount int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 7},
+		SignatureScript:  []byte{txscript.OP_TRUE},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	return tx
+}
+
+// spendingPacket returns a packet spending the first output of the passed
+// funding transaction.
+func spendingPacket(t *testing.T, funding *wire.MsgTx) *Packet {
+	_, pub := testKey(0x42)
+	prevOut := &wire.OutPoint{Hash: funding.TxHash(), Index: 0}
+	outputs := []*wire.TxOut{
+		wire.NewTxOut(funding.TxOut[0].Value-1000, p2pkhScript(pub)),
+	}
+	p, err := New([]*wire.OutPoint{prevOut}, outputs, 2, 0,
+		[]uint32{wire.MaxTxInSequenceNum})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	return p
+}
+
+// roundTrip serializes the packet to base64, decodes it again, and ensures
+// the serialization is stable.
+func roundTrip(t *testing.T, p *Packet) *Packet {
+	encoded, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	decoded, err := NewFromRawBytes(bytes.NewReader([]byte(encoded)), true)
+	if err != nil {
+		t.Fatalf("NewFromRawBytes: unexpected error: %v", err)
+	}
+	reencoded, err := decoded.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	if reencoded != encoded {
+		t.Fatalf("round trip mismatch -- got %s, want %s", reencoded,
+			encoded)
+	}
+	return decoded
+}
+
+// TestSerializeRoundTrip ensures a packet carrying every kind of input and
+// output field, including unknown keys, survives serialization unchanged.
+func TestSerializeRoundTrip(t *testing.T) {
+	_, pub1 := testKey(0x01)
+	_, pub2 := testKey(0x02)
+
+	funding := fundingTx(p2pkhScript(pub1), 100000)
+	p := spendingPacket(t, funding)
+	updater, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("NewUpdater: unexpected error: %v", err)
+	}
+	if err := updater.AddInNonWitnessUtxo(funding, 0); err != nil {
+		t.Fatalf("AddInNonWitnessUtxo: unexpected error: %v", err)
+	}
+	if err := updater.AddInSighashType(txscript.SigHashAll, 0); err != nil {
+		t.Fatalf("AddInSighashType: unexpected error: %v", err)
+	}
+	err = updater.AddInBip32Derivation(0xdeadbeef,
+		[]uint32{0x8000002c, 0x80000000, 0x80000000, 0, 1}, pub1, 0)
+	if err != nil {
+		t.Fatalf("AddInBip32Derivation: unexpected error: %v", err)
+	}
+	err = updater.AddOutBip32Derivation(0xdeadbeef, []uint32{1, 2}, pub2, 0)
+	if err != nil {
+		t.Fatalf("AddOutBip32Derivation: unexpected error: %v", err)
+	}
+	if err := updater.AddOutRedeemScript([]byte{0x51}, 0); err != nil {
+		t.Fatalf("AddOutRedeemScript: unexpected error: %v", err)
+	}
+	p.Inputs[0].Unknowns = []*Unknown{{Key: []byte{0xfc, 0x01}, Value: []byte{0x02}}}
+	p.Unknowns = []*Unknown{{Key: []byte{0xfc}, Value: []byte{0x03}}}
+
+	decoded := roundTrip(t, p)
+	in := decoded.Inputs[0]
+	if in.NonWitnessUtxo == nil || in.NonWitnessUtxo.TxHash() != funding.TxHash() {
+		t.Fatal("decoded non-witness utxo does not match")
+	}
+	if in.SighashType != txscript.SigHashAll {
+		t.Fatalf("decoded sighash type -- got %v, want %v",
+			in.SighashType, txscript.SigHashAll)
+	}
+	if len(in.Bip32Derivation) != 1 ||
+		in.Bip32Derivation[0].MasterKeyFingerprint != 0xdeadbeef ||
+		len(in.Bip32Derivation[0].Bip32Path) != 5 {
+
+		t.Fatal("decoded input bip32 derivation does not match")
+	}
+	if len(decoded.Unknowns) != 1 || len(in.Unknowns) != 1 {
+		t.Fatal("unknown keys were not preserved")
+	}
+}
+
+// TestDecodeErrors ensures malformed serializations are rejected with the
+// expected errors.
+func TestDecodeErrors(t *testing.T) {
+	_, pub := testKey(0x01)
+	p := spendingPacket(t, fundingTx(p2pkhScript(pub), 100000))
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	valid := buf.Bytes()
+
+	// Build a packet whose unsigned transaction is signed.
+	signedTx := p.UnsignedTx.Copy()
+	signedTx.TxIn[0].SignatureScript = []byte{txscript.OP_TRUE}
+	var signedBuf bytes.Buffer
+	signedBuf.Write(psbtMagic[:])
+	var txBuf bytes.Buffer
+	signedTx.SerializeNoWitness(&txBuf)
+	serializeKVPairWithType(&signedBuf, uint8(UnsignedTxType), nil,
+		txBuf.Bytes())
+	signedBuf.Write([]byte{0x00, 0x00, 0x00})
+
+	// Replace the separators with a repeated unknown global key.
+	var dupBuf bytes.Buffer
+	dupBuf.Write(valid[:len(valid)-3])
+	serializeKVPair(&dupBuf, []byte{0xfc}, []byte{0x01})
+	serializeKVPair(&dupBuf, []byte{0xfc}, []byte{0x01})
+
+	tests := []struct {
+		name string
+		raw  []byte
+		err  error
+	}{
+		{
+			name: "bad magic",
+			raw:  append([]byte{0x70, 0x73, 0x62, 0x75, 0xff}, valid[5:]...),
+			err:  ErrInvalidMagicBytes,
+		},
+		{
+			name: "signed unsigned tx",
+			raw:  signedBuf.Bytes(),
+			err:  ErrInvalidRawTxSigned,
+		},
+		{
+			name: "missing unsigned tx",
+			raw:  append(append([]byte{}, psbtMagic[:]...), 0x00),
+			err:  ErrInvalidPsbtFormat,
+		},
+		{
+			name: "duplicate global key",
+			raw:  dupBuf.Bytes(),
+			err:  ErrDuplicateKey,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := NewFromRawBytes(bytes.NewReader(test.raw), false)
+		if err != test.err {
+			t.Errorf("NewFromRawBytes #%d (%s): unexpected error -- "+
+				"got %v, want %v", i, test.name, err, test.err)
+		}
+	}
+}
+
+// TestSignFinalizeExtract ensures each supported script type can be signed,
+// finalized, and extracted into a transaction that passes script validation.
+func TestSignFinalizeExtract(t *testing.T) {
+	priv1, pub1 := testKey(0x01)
+	priv2, pub2 := testKey(0x02)
+	_, pub3 := testKey(0x03)
+	multiSig := multiSigScript(pub1, pub2, pub3)
+	p2shP2wkh := p2wkhScript(pub1)
+	p2shP2wsh := p2wshScript(multiSig)
+	const amount = 100000
+
+	tests := []struct {
+		name          string
+		pkScript      []byte
+		witness       bool
+		redeemScript  []byte
+		witnessScript []byte
+		signers       []*ulordec.PrivateKey
+	}{
+		{
+			name:     "p2pkh",
+			pkScript: p2pkhScript(pub1),
+			signers:  []*ulordec.PrivateKey{priv1},
+		},
+		{
+			name:         "p2sh multisig",
+			pkScript:     p2shScript(multiSig),
+			redeemScript: multiSig,
+			signers:      []*ulordec.PrivateKey{priv2, priv1},
+		},
+		{
+			name:     "p2wkh",
+			pkScript: p2wkhScript(pub1),
+			witness:  true,
+			signers:  []*ulordec.PrivateKey{priv1},
+		},
+		{
+			name:         "p2sh-p2wkh",
+			pkScript:     p2shScript(p2shP2wkh),
+			witness:      true,
+			redeemScript: p2shP2wkh,
+			signers:      []*ulordec.PrivateKey{priv1},
+		},
+		{
+			name:          "p2wsh multisig",
+			pkScript:      p2wshScript(multiSig),
+			witness:       true,
+			witnessScript: multiSig,
+			signers:       []*ulordec.PrivateKey{priv2, priv1},
+		},
+		{
+			name:          "p2sh-p2wsh multisig",
+			pkScript:      p2shScript(p2shP2wsh),
+			witness:       true,
+			redeemScript:  p2shP2wsh,
+			witnessScript: multiSig,
+			signers:       []*ulordec.PrivateKey{priv1, priv2},
+		},
+	}
+
+	for _, test := range tests {
+		funding := fundingTx(test.pkScript, amount)
+		p := spendingPacket(t, funding)
+		updater, err := NewUpdater(p)
+		if err != nil {
+			t.Fatalf("%s: NewUpdater: unexpected error: %v",
+				test.name, err)
+		}
+
+		// Witness inputs start out with the full previous transaction
+		// to exercise the conversion performed by Sign.
+		if err := updater.AddInNonWitnessUtxo(funding, 0); err != nil {
+			t.Fatalf("%s: AddInNonWitnessUtxo: unexpected error: %v",
+				test.name, err)
+		}
+
+		// Nothing can be finalized or extracted before signing.
+		if _, err := MaybeFinalize(p, 0); err != ErrNotFinalizable {
+			t.Fatalf("%s: MaybeFinalize: unexpected error -- got "+
+				"%v, want %v", test.name, err, ErrNotFinalizable)
+		}
+		if _, err := Extract(p); err != ErrIncompletePSBT {
+			t.Fatalf("%s: Extract: unexpected error -- got %v, "+
+				"want %v", test.name, err, ErrIncompletePSBT)
+		}
+
+		// The script committed to by the signature depends on the
+		// script type being spent.
+		subScript := test.pkScript
+		switch {
+		case test.witnessScript != nil:
+			subScript = test.witnessScript
+		case test.redeemScript != nil && !test.witness:
+			subScript = test.redeemScript
+		case test.witness:
+			subScript = p2pkhScript(pub1)
+		}
+		sigHashes := txscript.NewTxSigHashes(p.UnsignedTx)
+		for _, key := range test.signers {
+			var sig []byte
+			if test.witness {
+				sig, err = txscript.RawTxInWitnessSignature(
+					p.UnsignedTx, sigHashes, 0, amount,
+					subScript, txscript.SigHashAll, key)
+			} else {
+				sig, err = txscript.RawTxInSignature(
+					p.UnsignedTx, 0, subScript,
+					txscript.SigHashAll, key)
+			}
+			if err != nil {
+				t.Fatalf("%s: unable to sign: %v", test.name,
+					err)
+			}
+
+			pubKey := (*ulordec.PublicKey)(&key.PublicKey)
+			outcome, err := updater.Sign(0, sig,
+				pubKey.SerializeCompressed(), test.redeemScript,
+				test.witnessScript)
+			if err != nil || outcome != SignSuccessful {
+				t.Fatalf("%s: Sign: unexpected result %v: %v",
+					test.name, outcome, err)
+			}
+		}
+		if test.witness && p.Inputs[0].WitnessUtxo == nil {
+			t.Fatalf("%s: witness input was not converted",
+				test.name)
+		}
+
+		// Ensure the signed packet survives serialization, then
+		// finalize the decoded copy.
+		p = roundTrip(t, p)
+		if err := MaybeFinalizeAll(p); err != nil {
+			t.Fatalf("%s: MaybeFinalizeAll: unexpected error: %v",
+				test.name, err)
+		}
+		if err := Finalize(p, 0); err != ErrInputAlreadyFinalized {
+			t.Fatalf("%s: Finalize: unexpected error -- got %v, "+
+				"want %v", test.name, err, ErrInputAlreadyFinalized)
+		}
+		if len(p.Inputs[0].PartialSigs) != 0 {
+			t.Fatalf("%s: partial signatures were not cleared",
+				test.name)
+		}
+		p = roundTrip(t, p)
+
+		tx, err := Extract(p)
+		if err != nil {
+			t.Fatalf("%s: Extract: unexpected error: %v", test.name,
+				err)
+		}
+		vm, err := txscript.NewEngine(test.pkScript, tx, 0,
+			txscript.StandardVerifyFlags, nil, nil, amount)
+		if err != nil {
+			t.Fatalf("%s: NewEngine: unexpected error: %v",
+				test.name, err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("%s: extracted transaction failed to "+
+				"validate: %v", test.name, err)
+		}
+	}
+}
+
+// TestSignErrors ensures signatures which don't correspond to the input are
+// rejected.
+func TestSignErrors(t *testing.T) {
+	priv1, pub1 := testKey(0x01)
+	_, pub2 := testKey(0x02)
+
+	funding := fundingTx(p2wkhScript(pub1), 100000)
+	p := spendingPacket(t, funding)
+	updater, err := NewUpdater(p)
+	if err != nil {
+		t.Fatalf("NewUpdater: unexpected error: %v", err)
+	}
+
+	sig, err := txscript.RawTxInSignature(p.UnsignedTx, 0,
+		p2pkhScript(pub1), txscript.SigHashAll, priv1)
+	if err != nil {
+		t.Fatalf("unable to sign: %v", err)
+	}
+
+	// A signature can't be attached without any UTXO information.
+	if _, err := updater.Sign(0, sig, pub1, nil, nil); err != ErrInvalidPsbtFormat {
+		t.Fatalf("Sign: unexpected error -- got %v, want %v", err,
+			ErrInvalidPsbtFormat)
+	}
+
+	// The full previous transaction must match the outpoint.
+	other := fundingTx(p2wkhScript(pub2), 100000)
+	err = updater.AddInNonWitnessUtxo(other, 0)
+	if err != ErrInvalidPrevOutNonWitnessTransaction {
+		t.Fatalf("AddInNonWitnessUtxo: unexpected error -- got %v, "+
+			"want %v", err, ErrInvalidPrevOutNonWitnessTransaction)
+	}
+
+	if err := updater.AddInWitnessUtxo(funding.TxOut[0], 0); err != nil {
+		t.Fatalf("AddInWitnessUtxo: unexpected error: %v", err)
+	}
+
+	// The key must be the one paid to by the output.
+	if _, err := updater.Sign(0, sig, pub2, nil, nil); err != ErrInvalidSignatureForInput {
+		t.Fatalf("Sign: unexpected error -- got %v, want %v", err,
+			ErrInvalidSignatureForInput)
+	}
+
+	// The signature must use the sighash type required by the input.
+	if err := updater.AddInSighashType(txscript.SigHashSingle, 0); err != nil {
+		t.Fatalf("AddInSighashType: unexpected error: %v", err)
+	}
+	if _, err := updater.Sign(0, sig, pub1, nil, nil); err != ErrInvalidSigHashFlags {
+		t.Fatalf("Sign: unexpected error -- got %v, want %v", err,
+			ErrInvalidSigHashFlags)
+	}
+}
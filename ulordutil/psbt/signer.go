@@ -0,0 +1,127 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// signer encapsulates the role 'Signer' as specified in BIP174; it controls
+// the insertion of signatures; the Sign() function will attempt to insert
+// signatures using Updater.addPartialSignature, after first ensuring the Psbt
+// is in the correct state.
+
+import (
+	"github.com/ulordsuite/ulord/txscript"
+)
+
+// SignOutcome is a enum-like value that expresses the outcome of a call to the
+// Sign method.
+type SignOutcome int
+
+const (
+	// SignSuccessful indicates that the partial signature was successfully
+	// attached.
+	SignSuccessful SignOutcome = 0
+
+	// SignFinalized indicates that this input is already finalized, so the
+	// provided signature was *not* attached.
+	SignFinalized SignOutcome = 1
+
+	// SignInvalid indicates that the provided signature data was not
+	// valid.  In this case an error will also be returned.
+	SignInvalid SignOutcome = -1
+)
+
+// Sign allows the caller to sign a PSBT at a particular input; they must
+// provide a signature and a pubkey, both as byte slices; they can also
+// optionally provide both witnessScript and/or redeemScript, otherwise these
+// arguments must be set as nil (and in that case, they must already be
+// present in the PSBT if required for signing to succeed).
+//
+// This serves as a wrapper around Updater.addPartialSignature; it ensures that
+// the redeemScript and witnessScript are updated as needed (note that the
+// Updater is allowed to add redeemScripts and witnessScripts independently,
+// before signing), and ensures that the right form of utxo field
+// (NonWitnessUtxo or WitnessUtxo) is included in the input so that signature
+// insertion (and then finalization) can take place.
+func (u *Updater) Sign(inIndex int, sig []byte, pubKey []byte,
+	redeemScript []byte, witnessScript []byte) (SignOutcome, error) {
+
+	if err := u.checkInIndex(inIndex); err != nil {
+		return SignInvalid, err
+	}
+	if isFinalized(u.Upsbt, inIndex) {
+		return SignFinalized, nil
+	}
+
+	// Add the redeemScript to the PSBT in preparation.  If it already
+	// exists, it will be overwritten.
+	if redeemScript != nil {
+		err := u.AddInRedeemScript(redeemScript, inIndex)
+		if err != nil {
+			return SignInvalid, err
+		}
+	}
+
+	// Witness inputs only need the spent output rather than the full
+	// previous transaction, so convert the input before attaching a
+	// witness script or signature to it.
+	pInput := &u.Upsbt.Inputs[inIndex]
+	isWitness := witnessScript != nil || pInput.WitnessScript != nil
+	switch {
+	case isWitness:
+		// Only witness inputs carry a witness script.
+
+	case pInput.RedeemScript != nil:
+		isWitness = txscript.IsWitnessProgram(pInput.RedeemScript)
+
+	case pInput.NonWitnessUtxo != nil:
+		outIndex := u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint.Index
+		if int(outIndex) < len(pInput.NonWitnessUtxo.TxOut) {
+			pkScript := pInput.NonWitnessUtxo.TxOut[outIndex].PkScript
+			isWitness = txscript.IsWitnessProgram(pkScript)
+		}
+	}
+	if isWitness && pInput.WitnessUtxo == nil {
+		if err := nonWitnessToWitness(u.Upsbt, inIndex); err != nil {
+			return SignInvalid, err
+		}
+	}
+
+	// Add the witnessScript to the PSBT in preparation.  If it already
+	// exists, it will be overwritten.
+	if witnessScript != nil {
+		err := u.AddInWitnessScript(witnessScript, inIndex)
+		if err != nil {
+			return SignInvalid, err
+		}
+	}
+
+	if err := u.addPartialSignature(inIndex, sig, pubKey); err != nil {
+		return SignInvalid, err
+	}
+
+	return SignSuccessful, nil
+}
+
+// nonWitnessToWitness extracts the TxOut from the existing NonWitnessUtxo
+// field in the given PSBT input and sets it as type witness by replacing the
+// NonWitnessUtxo field with a WitnessUtxo field.  See
+// https://github.com/bitcoin/bitcoin/pull/14197.
+func nonWitnessToWitness(p *Packet, inIndex int) error {
+	pInput := &p.Inputs[inIndex]
+	if pInput.NonWitnessUtxo == nil {
+		return ErrInvalidPsbtFormat
+	}
+
+	prevOut := p.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+	if pInput.NonWitnessUtxo.TxHash() != prevOut.Hash ||
+		int(prevOut.Index) >= len(pInput.NonWitnessUtxo.TxOut) {
+
+		return ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	pInput.WitnessUtxo = pInput.NonWitnessUtxo.TxOut[prevOut.Index]
+	pInput.NonWitnessUtxo = nil
+
+	return p.SanityCheck()
+}
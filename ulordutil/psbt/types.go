@@ -0,0 +1,80 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// GlobalType is the set of types that are used at the global scope level
+// within the PSBT.
+type GlobalType uint8
+
+const (
+	// UnsignedTxType is the global scope key that houses the unsigned
+	// transaction of the PSBT.  The value is a transaction in network
+	// serialization.  The scriptSigs and witnesses for each input must be
+	// empty.  The transaction must be in the old serialization format
+	// (without witnesses).  A PSBT must have a transaction, otherwise it
+	// is invalid.
+	UnsignedTxType GlobalType = 0
+)
+
+// InputType is the set of types that are defined for each input included
+// within the PSBT.
+type InputType uint8
+
+const (
+	// NonWitnessUtxoType has no key data and the value is the full
+	// transaction which contains the output being spent.
+	NonWitnessUtxoType InputType = 0
+
+	// WitnessUtxoType has no key data and the value is the serialized
+	// output being spent.
+	WitnessUtxoType InputType = 1
+
+	// PartialSigType is keyed by the public key whose signature is the
+	// value.  The signature includes the trailing sighash byte.
+	PartialSigType InputType = 2
+
+	// SighashType has no key data and the value is the 32-bit little
+	// endian sighash type that signatures for this input must use.
+	SighashType InputType = 3
+
+	// RedeemScriptInputType has no key data and the value is the redeem
+	// script of a P2SH output being spent.
+	RedeemScriptInputType InputType = 4
+
+	// WitnessScriptInputType has no key data and the value is the witness
+	// script of a P2WSH output being spent.
+	WitnessScriptInputType InputType = 5
+
+	// Bip32DerivationInputType is keyed by a public key and the value is
+	// the master key fingerprint followed by the BIP0032 derivation path
+	// of that key.
+	Bip32DerivationInputType InputType = 6
+
+	// FinalScriptSigType has no key data and the value is the fully
+	// constructed scriptSig of the input.
+	FinalScriptSigType InputType = 7
+
+	// FinalScriptWitnessType has no key data and the value is the fully
+	// constructed witness stack of the input.
+	FinalScriptWitnessType InputType = 8
+)
+
+// OutputType is the set of types defined per output within the PSBT.
+type OutputType uint8
+
+const (
+	// RedeemScriptOutputType has no key data and the value is the redeem
+	// script of the output if it is P2SH.
+	RedeemScriptOutputType OutputType = 0
+
+	// WitnessScriptOutputType has no key data and the value is the witness
+	// script of the output if it is P2WSH.
+	WitnessScriptOutputType OutputType = 1
+
+	// Bip32DerivationOutputType is keyed by a public key and the value is
+	// the master key fingerprint followed by the BIP0032 derivation path
+	// of that key.
+	Bip32DerivationOutputType OutputType = 2
+)
@@ -0,0 +1,343 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Updater requires provision of a single PSBT and is able to add data to
+// both input and output sections.  It can be called repeatedly to add more
+// data.  It also allows addition of signatures via the addPartialSignature
+// function; this is called internally to the package in the Sign() function
+// of Updater, located in signer.go.
+
+import (
+	"bytes"
+
+	"github.com/ulordsuite/ulord/chaincfg/chainhash"
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// Updater encapsulates the role 'Updater' as specified in BIP174; it accepts
+// Psbt structs and has methods to add fields to the inputs and outputs.
+type Updater struct {
+	Upsbt *Packet
+}
+
+// NewUpdater returns a new instance of Updater, if the passed Psbt struct is
+// in a valid form, else an error.
+func NewUpdater(p *Packet) (*Updater, error) {
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return &Updater{Upsbt: p}, nil
+}
+
+// checkInIndex returns an error if the passed index does not refer to an
+// input of the packet.
+func (u *Updater) checkInIndex(inIndex int) error {
+	if inIndex < 0 || inIndex >= len(u.Upsbt.Inputs) {
+		return ErrInvalidPsbtFormat
+	}
+	return nil
+}
+
+// checkOutIndex returns an error if the passed index does not refer to an
+// output of the packet.
+func (u *Updater) checkOutIndex(outIndex int) error {
+	if outIndex < 0 || outIndex >= len(u.Upsbt.Outputs) {
+		return ErrInvalidPsbtFormat
+	}
+	return nil
+}
+
+// AddInNonWitnessUtxo adds the utxo information for an input which is
+// non-witness.  This requires provision of a full transaction (which is the
+// source of the corresponding prevOut), and the input index.  If addition of
+// this key-value pair to the Psbt fails, an error is returned.
+func (u *Updater) AddInNonWitnessUtxo(tx *wire.MsgTx, inIndex int) error {
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+	prevOut := u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+	if tx.TxHash() != prevOut.Hash || int(prevOut.Index) >= len(tx.TxOut) {
+		return ErrInvalidPrevOutNonWitnessTransaction
+	}
+
+	u.Upsbt.Inputs[inIndex].NonWitnessUtxo = tx
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInWitnessUtxo adds the utxo information for an input which is witness.
+// This requires provision of a full transaction *output* (which is the source
+// of the corresponding prevOut); not the full transaction because BIP143 means
+// the output information is sufficient, and the input index.  If addition of
+// this key-value pair to the Psbt fails, an error is returned.
+func (u *Updater) AddInWitnessUtxo(txout *wire.TxOut, inIndex int) error {
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].WitnessUtxo = txout
+	return u.Upsbt.SanityCheck()
+}
+
+// addPartialSignature allows the Updater role to insert fields of type partial
+// signature into a Psbt, consisting of both the pubkey (as keydata) and the
+// ECDSA signature (as value).  Note that the Signer role is encapsulated in
+// this function; signatures are only allowed to be added that follow the
+// sanity-check on signing rules explained in the BIP under `Signer`; if the
+// rules are not satisfied, an ErrInvalidSignatureForInput is returned.
+//
+// NOTE: This function does *not* validate the ECDSA signature itself.
+func (u *Updater) addPartialSignature(inIndex int, sig []byte,
+	pubkey []byte) error {
+
+	partialSig := PartialSig{
+		PubKey: pubkey, Signature: sig,
+	}
+
+	// First validate the passed (sig, pub).
+	if !partialSig.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	pInput := &u.Upsbt.Inputs[inIndex]
+
+	// First check; don't add duplicates.
+	for _, x := range pInput.PartialSigs {
+		if bytes.Equal(x.PubKey, partialSig.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	// Attaching signature without utxo field is not allowed.
+	if pInput.WitnessUtxo == nil && pInput.NonWitnessUtxo == nil {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Next, we perform a series of additional sanity checks.
+	if pInput.NonWitnessUtxo != nil {
+		prevOut := u.Upsbt.UnsignedTx.TxIn[inIndex].PreviousOutPoint
+		if pInput.NonWitnessUtxo.TxHash() != prevOut.Hash ||
+			int(prevOut.Index) >= len(pInput.NonWitnessUtxo.TxOut) {
+
+			return ErrInvalidPrevOutNonWitnessTransaction
+		}
+
+		// To validate that the redeem script matches, we must pull out
+		// the scriptPubKey of the corresponding output and compare
+		// that with the P2SH scriptPubKey that is generated by
+		// redeemScript.
+		if pInput.RedeemScript != nil {
+			outIndex := prevOut.Index
+			pkScript := pInput.NonWitnessUtxo.TxOut[outIndex].PkScript
+			if !checkRedeemScript(pkScript, pInput.RedeemScript) {
+				return ErrInvalidSignatureForInput
+			}
+		}
+
+	} else {
+		// Attaching a witness signature requires the spent output to
+		// be a witness program, either natively or wrapped in P2SH.
+		script := pInput.WitnessUtxo.PkScript
+
+		// If a redeem script is present, it must hash to the P2SH
+		// output and is itself the witness program.
+		if pInput.RedeemScript != nil {
+			if !checkRedeemScript(script, pInput.RedeemScript) {
+				return ErrInvalidSignatureForInput
+			}
+			script = pInput.RedeemScript
+		}
+
+		switch {
+		case txscript.IsPayToWitnessScriptHash(script):
+			if pInput.WitnessScript == nil ||
+				!checkWitnessScript(script, pInput.WitnessScript) {
+
+				return ErrInvalidSignatureForInput
+			}
+
+		case txscript.IsPayToWitnessPubKeyHash(script):
+			// The signature must be from the key the output pays
+			// to.
+			if !bytes.Equal(script[2:], ulordutil.Hash160(pubkey)) {
+				return ErrInvalidSignatureForInput
+			}
+
+		default:
+			return ErrInvalidSignatureForInput
+		}
+	}
+
+	if !checkSigHashFlags(sig, pInput) {
+		return ErrInvalidSigHashFlags
+	}
+
+	pInput.PartialSigs = append(pInput.PartialSigs, &partialSig)
+	return u.Upsbt.SanityCheck()
+}
+
+// checkRedeemScript returns whether the passed P2SH script pays to the hash of
+// the passed redeem script.
+func checkRedeemScript(pkScript, redeemScript []byte) bool {
+	if !txscript.IsPayToScriptHash(pkScript) {
+		return false
+	}
+	return bytes.Equal(pkScript[2:22], ulordutil.Hash160(redeemScript))
+}
+
+// checkWitnessScript returns whether the passed P2WSH script pays to the hash
+// of the passed witness script.
+func checkWitnessScript(pkScript, witnessScript []byte) bool {
+	if !txscript.IsPayToWitnessScriptHash(pkScript) {
+		return false
+	}
+	return bytes.Equal(pkScript[2:], chainhash.HashB(witnessScript))
+}
+
+// AddInSighashType adds the sighash type information for an input.  The
+// sighash type is passed as a 32 bit unsigned integer, along with the index
+// for the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInSighashType(sighashType txscript.SigHashType,
+	inIndex int) error {
+
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].SighashType = sighashType
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInRedeemScript adds the redeem script information for an input.  The
+// redeem script is passed serialized, as a byte slice, along with the index
+// of the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInRedeemScript(redeemScript []byte, inIndex int) error {
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].RedeemScript = redeemScript
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInWitnessScript adds the witness script information for an input.  The
+// witness script is passed serialized, as a byte slice, along with the index
+// of the input.  An error is returned if addition of this key-value pair to
+// the Psbt fails.
+func (u *Updater) AddInWitnessScript(witnessScript []byte, inIndex int) error {
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Inputs[inIndex].WitnessScript = witnessScript
+	return u.Upsbt.SanityCheck()
+}
+
+// AddInBip32Derivation takes a master key fingerprint as defined in BIP32, a
+// BIP32 path as a slice of uint32 values, and a serialized pubkey as a byte
+// slice, along with the integer index of the input, and inserts this data
+// into that input.
+//
+// NOTE: This can be called multiple times for the same input.  An error is
+// returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddInBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, inIndex int) error {
+
+	if err := u.checkInIndex(inIndex); err != nil {
+		return err
+	}
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Inputs[inIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Inputs[inIndex].Bip32Derivation = append(
+		u.Upsbt.Inputs[inIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	return u.Upsbt.SanityCheck()
+}
+
+// AddOutBip32Derivation takes a master key fingerprint as defined in BIP32, a
+// BIP32 path as a slice of uint32 values, and a serialized pubkey as a byte
+// slice, along with the integer index of the output, and inserts this data
+// into that output.
+//
+// NOTE: That this can be called multiple times for the same output.  An error
+// is returned if addition of this key-value pair to the Psbt fails.
+func (u *Updater) AddOutBip32Derivation(masterKeyFingerprint uint32,
+	bip32Path []uint32, pubKeyData []byte, outIndex int) error {
+
+	if err := u.checkOutIndex(outIndex); err != nil {
+		return err
+	}
+
+	bip32Derivation := Bip32Derivation{
+		PubKey:               pubKeyData,
+		MasterKeyFingerprint: masterKeyFingerprint,
+		Bip32Path:            bip32Path,
+	}
+
+	if !bip32Derivation.checkValid() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Don't allow duplicate keys
+	for _, x := range u.Upsbt.Outputs[outIndex].Bip32Derivation {
+		if bytes.Equal(x.PubKey, bip32Derivation.PubKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Outputs[outIndex].Bip32Derivation = append(
+		u.Upsbt.Outputs[outIndex].Bip32Derivation, &bip32Derivation,
+	)
+
+	return u.Upsbt.SanityCheck()
+}
+
+// AddOutRedeemScript takes a redeem script as a byte slice and appends it to
+// the output at index outIndex.
+func (u *Updater) AddOutRedeemScript(redeemScript []byte,
+	outIndex int) error {
+
+	if err := u.checkOutIndex(outIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Outputs[outIndex].RedeemScript = redeemScript
+	return u.Upsbt.SanityCheck()
+}
+
+// AddOutWitnessScript takes a witness script as a byte slice and appends it to
+// the output at index outIndex.
+func (u *Updater) AddOutWitnessScript(witnessScript []byte,
+	outIndex int) error {
+
+	if err := u.checkOutIndex(outIndex); err != nil {
+		return err
+	}
+
+	u.Upsbt.Outputs[outIndex].WitnessScript = witnessScript
+	return u.Upsbt.SanityCheck()
+}
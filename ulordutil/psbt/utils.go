@@ -0,0 +1,197 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/ulordsuite/ulord/txscript"
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// MaxPsbtKeyLength is the maximum length in bytes of a key, including
+	// its type byte, that will be accepted while decoding a PSBT.
+	MaxPsbtKeyLength = 10000
+
+	// MaxPsbtValueLength is the maximum length in bytes of a value that
+	// will be accepted while decoding a PSBT.  It matches the maximum size
+	// of a serialized transaction so a full non-witness UTXO always fits.
+	MaxPsbtValueLength = 4000000
+)
+
+// Unknown is a key-value pair whose type is not understood by this package.
+// Unknown pairs are retained so they survive a decode/encode round trip.
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// getKey reads the next key from the passed reader.  It returns the key type
+// and any key data following it.  A zero-length key marks the end of the
+// current map, in which case a key type of -1 and no error are returned.
+func getKey(r io.Reader) (int, []byte, error) {
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return -1, nil, ErrInvalidPsbtFormat
+	}
+
+	// A zero length key is the separator which terminates a map.
+	if count == 0 {
+		return -1, nil, nil
+	}
+	if count > MaxPsbtKeyLength {
+		return -1, nil, ErrInvalidPsbtFormat
+	}
+
+	// The first byte of the key is its type, and the remainder is key
+	// data specific to that type.
+	keyType := make([]byte, 1)
+	if _, err := io.ReadFull(r, keyType); err != nil {
+		return -1, nil, ErrInvalidPsbtFormat
+	}
+	var keyData []byte
+	if count > 1 {
+		keyData = make([]byte, count-1)
+		if _, err := io.ReadFull(r, keyData); err != nil {
+			return -1, nil, ErrInvalidPsbtFormat
+		}
+	}
+
+	return int(keyType[0]), keyData, nil
+}
+
+// readValue reads a length-prefixed value from the passed reader.
+func readValue(r io.Reader) ([]byte, error) {
+	value, err := wire.ReadVarBytes(r, 0, MaxPsbtValueLength, "PSBT value")
+	if err != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+	return value, nil
+}
+
+// serializeKVPair writes a single key-value pair to the passed writer using
+// the length-prefixed encoding defined by BIP0174.
+func serializeKVPair(w io.Writer, key []byte, value []byte) error {
+	if err := wire.WriteVarBytes(w, 0, key); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, value)
+}
+
+// serializeKVPairWithType writes a key-value pair whose key is made up of the
+// passed type byte followed by the optional key data.
+func serializeKVPairWithType(w io.Writer, kt uint8, keyData []byte,
+	value []byte) error {
+
+	key := make([]byte, 0, 1+len(keyData))
+	key = append(key, kt)
+	key = append(key, keyData...)
+	return serializeKVPair(w, key, value)
+}
+
+// readTxOut parses a transaction output in network serialization: an 8 byte
+// little endian amount followed by a variable length public key script.
+func readTxOut(txout []byte) (*wire.TxOut, error) {
+	if len(txout) < 10 {
+		return nil, ErrInvalidPsbtFormat
+	}
+	value := int64(binary.LittleEndian.Uint64(txout[:8]))
+	script, err := wire.ReadVarBytes(bytes.NewReader(txout[8:]), 0,
+		MaxPsbtValueLength, "pkScript")
+	if err != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+	if 8+wire.VarIntSerializeSize(uint64(len(script)))+len(script) !=
+		len(txout) {
+
+		return nil, ErrInvalidPsbtFormat
+	}
+	return wire.NewTxOut(value, script), nil
+}
+
+// serializeTxOut returns the network serialization of the passed output.
+func serializeTxOut(txout *wire.TxOut) ([]byte, error) {
+	var buf bytes.Buffer
+	var amount [8]byte
+	binary.LittleEndian.PutUint64(amount[:], uint64(txout.Value))
+	buf.Write(amount[:])
+	if err := wire.WriteVarBytes(&buf, 0, txout.PkScript); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readTxWitness parses a serialized witness stack: an item count followed by
+// each length-prefixed item.
+func readTxWitness(wit []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(wit)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	// Each item takes at least one byte, so reject counts which can't
+	// possibly fit before allocating.
+	if count > uint64(len(wit)) {
+		return nil, ErrInvalidPsbtFormat
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, MaxPsbtValueLength,
+			"witness item")
+		if err != nil {
+			return nil, ErrInvalidPsbtFormat
+		}
+	}
+	if r.Len() != 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+	return witness, nil
+}
+
+// writeTxWitness returns the serialization of the passed witness stack in the
+// format expected by readTxWitness.
+func writeTxWitness(wit [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(wit))); err != nil {
+		return nil, err
+	}
+	for _, item := range wit {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// checkSigHashFlags returns whether the sighash type appended to the passed
+// signature matches the sighash type required by the input, if any.
+func checkSigHashFlags(sig []byte, input *PInput) bool {
+	// An input without an explicit sighash type accepts any.
+	if input.SighashType == 0 {
+		return true
+	}
+	return txscript.SigHashType(sig[len(sig)-1]) == input.SighashType
+}
+
+// checkIsMultiSigScript returns whether the passed script is a standard
+// multisig script.
+func checkIsMultiSigScript(script []byte) bool {
+	return txscript.GetScriptClass(script) == txscript.MultiSigTy
+}
+
+// isDuplicateUnknown returns whether an unknown entry with the same key as the
+// passed key already exists in the list.
+func isDuplicateUnknown(unknowns []*Unknown, key []byte) bool {
+	for _, u := range unknowns {
+		if bytes.Equal(u.Key, key) {
+			return true
+		}
+	}
+	return false
+}
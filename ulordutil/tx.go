@@ -6,6 +6,7 @@ package ulordutil
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/ulordsuite/ulord/chaincfg/chainhash"
@@ -21,20 +22,66 @@ const TxIndexUnknown = -1
 // manipulation of raw transactions.  It also memoizes the hash for the
 // transaction on its first access so subsequent accesses don't have to repeat
 // the relatively expensive hashing operations.
+//
+// Transactions created with NewTxFromBytesLazy or obtained from a block
+// created with NewBlockFromBytesLazy are backed by their serialized bytes and
+// are only deserialized when MsgTx is first called.
 type Tx struct {
 	msgTx         *wire.MsgTx     // Underlying MsgTx
+	serializedTx  []byte          // Serialized bytes for the transaction
+	witnessOffset int             // Offset of the witness data in serializedTx
+	lazy          bool            // MsgTx is deserialized from serializedTx
 	txHash        *chainhash.Hash // Cached transaction hash
 	txHashWitness *chainhash.Hash // Cached transaction witness hash
 	txHasWitness  *bool           // If the transaction has witness data
 	txIndex       int             // Position within a block or TxIndexUnknown
 }
 
-// MsgTx returns the underlying wire.MsgTx for the transaction.
+// MsgTx returns the underlying wire.MsgTx for the transaction.  For lazily
+// parsed transactions, the transaction is deserialized on the first call.
 func (t *Tx) MsgTx() *wire.MsgTx {
 	// Return the cached transaction.
+	if t.msgTx != nil || !t.lazy {
+		return t.msgTx
+	}
+
+	// The layout of lazily parsed transactions is validated when they are
+	// created, so deserializing them can not fail.
+	var msgTx wire.MsgTx
+	err := msgTx.Deserialize(bytes.NewReader(t.serializedTx))
+	if err != nil {
+		panic(fmt.Sprintf("lazily parsed transaction failed to "+
+			"deserialize: %v", err))
+	}
+	t.msgTx = &msgTx
 	return t.msgTx
 }
 
+// Bytes returns the serialized bytes for the transaction, including any
+// witness data.  This is equivalent to calling Serialize on the underlying
+// wire.MsgTx, however it caches the result so subsequent calls are more
+// efficient.  For lazily parsed transactions, the bytes the transaction was
+// created from are returned without deserializing it.
+func (t *Tx) Bytes() ([]byte, error) {
+	// Return the cached serialized bytes if they have already been
+	// generated.
+	if len(t.serializedTx) != 0 {
+		return t.serializedTx, nil
+	}
+
+	// Serialize the MsgTx.
+	w := bytes.NewBuffer(make([]byte, 0, t.msgTx.SerializeSize()))
+	err := t.msgTx.Serialize(w)
+	if err != nil {
+		return nil, err
+	}
+	serializedTx := w.Bytes()
+
+	// Cache the serialized bytes and return them.
+	t.serializedTx = serializedTx
+	return serializedTx, nil
+}
+
 // Hash returns the hash of the transaction.  This is equivalent to
 // calling TxHash on the underlying wire.MsgTx, however it caches the
 // result so subsequent calls are more efficient.
@@ -44,8 +91,16 @@ func (t *Tx) Hash() *chainhash.Hash {
 		return t.txHash
 	}
 
+	// Hash lazily parsed transactions from their serialized bytes so they
+	// don't need to be deserialized.
+	var hash chainhash.Hash
+	if t.lazy {
+		hash = rawTxHash(t.serializedTx, t.witnessOffset)
+	} else {
+		hash = t.msgTx.TxHash()
+	}
+
 	// Cache the hash and return it.
-	hash := t.msgTx.TxHash()
 	t.txHash = &hash
 	return &hash
 }
@@ -59,8 +114,20 @@ func (t *Tx) WitnessHash() *chainhash.Hash {
 		return t.txHashWitness
 	}
 
+	// The witness hash of lazily parsed transactions is the hash of their
+	// serialized bytes, or the transaction hash when there is no witness
+	// data.
+	var hash chainhash.Hash
+	switch {
+	case t.lazy && t.HasWitness():
+		hash = chainhash.DoubleHashH(t.serializedTx)
+	case t.lazy:
+		hash = *t.Hash()
+	default:
+		hash = t.msgTx.WitnessHash()
+	}
+
 	// Cache the hash and return it.
-	hash := t.msgTx.WitnessHash()
 	t.txHashWitness = &hash
 	return &hash
 }
@@ -70,15 +137,28 @@ func (t *Tx) WitnessHash() *chainhash.Hash {
 // HasWitness on the underlying wire.MsgTx, however it caches the result so
 // subsequent calls are more efficient.
 func (t *Tx) HasWitness() bool {
-	if t.txHashWitness != nil {
+	if t.txHasWitness != nil {
 		return *t.txHasWitness
 	}
 
-	hasWitness := t.msgTx.HasWitness()
+	var hasWitness bool
+	if t.lazy {
+		hasWitness = rawTxHasWitness(t.serializedTx, t.witnessOffset)
+	} else {
+		hasWitness = t.msgTx.HasWitness()
+	}
 	t.txHasWitness = &hasWitness
 	return hasWitness
 }
 
+// SetHash caches the passed hash as the transaction hash so it is not
+// calculated on the first call to Hash.  It allows callers that already know
+// the hash, such as indexers loading transactions from a database, to avoid
+// hashing them again.  The caller is responsible for the hash being correct.
+func (t *Tx) SetHash(hash *chainhash.Hash) {
+	t.txHash = hash
+}
+
 // Index returns the saved index of the transaction within a block.  This value
 // will be TxIndexUnknown if it hasn't already explicitly been set.
 func (t *Tx) Index() int {
@@ -0,0 +1,128 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil
+
+import (
+	"github.com/ulordsuite/ulord/wire"
+)
+
+const (
+	// witnessScaleFactor is the factor by which the size of the data of a
+	// transaction other than its witness data is scaled to compute its
+	// weight.  It must match blockchain.WitnessScaleFactor.
+	witnessScaleFactor = 4
+
+	// dustInputSize is the size of the typical input spending an output
+	// apart from its signature script or witness.  It consists of the 36
+	// byte previous outpoint, the 1 byte script length, and the 4 byte
+	// sequence.
+	dustInputSize = 41
+
+	// dustSpendScriptSize is the size of the typical signature script or
+	// witness spending an output, which is the 107 bytes of a signature
+	// and a compressed public key redeeming a pay-to-pubkey-hash output.
+	dustSpendScriptSize = 107
+)
+
+// TxWeight returns the weight of the passed transaction, which is the size of
+// its serialization without witness data scaled by the witness scale factor
+// plus the size of its witness data.
+func TxWeight(tx *wire.MsgTx) int64 {
+	baseSize := tx.SerializeSizeStripped()
+	totalSize := tx.SerializeSize()
+
+	// (baseSize * 3) + totalSize
+	return int64((baseSize * (witnessScaleFactor - 1)) + totalSize)
+}
+
+// TxVirtualSize returns the virtual size of the passed transaction, which is
+// its weight divided by the witness scale factor and rounded up.  It equals the
+// serialized size of transactions without witness data.
+func TxVirtualSize(tx *wire.MsgTx) int64 {
+	return (TxWeight(tx) + (witnessScaleFactor - 1)) / witnessScaleFactor
+}
+
+// TxFeeRate returns the fee rate in satoshi per 1000 bytes of virtual size of
+// the passed transaction paying the passed fee.
+func TxFeeRate(tx *wire.MsgTx, fee Amount) Amount {
+	return fee * 1000 / Amount(TxVirtualSize(tx))
+}
+
+// FeeForSize returns the fee a transaction with the passed virtual size pays at
+// the passed fee rate in satoshi per 1000 bytes.  Transactions smaller than 1000
+// bytes pay at least the fee rate when it is positive, and the fee is limited to
+// MaxSatoshi.
+func FeeForSize(size int64, feeRate Amount) Amount {
+	fee := (size * int64(feeRate)) / 1000
+
+	if fee == 0 && feeRate > 0 {
+		fee = int64(feeRate)
+	}
+
+	// Set the fee to the maximum possible value if the calculated fee is
+	// not in the valid range for monetary amounts.
+	if fee < 0 || fee > MaxSatoshi {
+		fee = MaxSatoshi
+	}
+
+	return Amount(fee)
+}
+
+// dustSpendSize returns the size of the passed output plus the size of the
+// typical input spending it, whose signature script or witness is discounted
+// when the output is a witness program.
+func dustSpendSize(txOut *wire.TxOut) int64 {
+	size := txOut.SerializeSize() + dustInputSize
+	if isWitnessProgram(txOut.PkScript) {
+		size += dustSpendScriptSize / witnessScaleFactor
+	} else {
+		size += dustSpendScriptSize
+	}
+	return int64(size)
+}
+
+// IsDust returns whether the passed output is dust at the passed minimum
+// transaction relay fee in satoshi per 1000 bytes.  An output is dust when the
+// fee for creating and spending it at a third of the relay fee exceeds its
+// value, which is below 546 satoshi for a pay-to-pubkey-hash output at the
+// default relay fee of 1000 satoshi.
+//
+// Outputs which can never be spent are always dust, but detecting them requires
+// parsing their scripts, so callers are expected to check them with
+// txscript.IsUnspendable first.
+func IsDust(txOut *wire.TxOut, minRelayTxFee Amount) bool {
+	// The following is equivalent to (value/size) * (1/3) * 1000 without
+	// needing to do floating point math.
+	return txOut.Value*1000/(3*dustSpendSize(txOut)) < int64(minRelayTxFee)
+}
+
+// DustThreshold returns the smallest value of an output paying to the passed
+// script which is not dust at the passed minimum transaction relay fee in
+// satoshi per 1000 bytes.
+func DustThreshold(pkScript []byte, minRelayTxFee Amount) Amount {
+	size := dustSpendSize(&wire.TxOut{PkScript: pkScript})
+	return Amount((3*size*int64(minRelayTxFee) + 999) / 1000)
+}
+
+// isWitnessProgram returns whether the passed script is a witness program,
+// which is a version byte of OP_0 or OP_1 through OP_16 followed by a single
+// data push of 2 to 40 bytes.
+func isWitnessProgram(script []byte) bool {
+	const (
+		op0       = 0x00
+		op1       = 0x51
+		op16      = 0x60
+		minPushed = 2
+		maxPushed = 40
+	)
+
+	if len(script) < minPushed+2 || len(script) > maxPushed+2 {
+		return false
+	}
+	if script[0] != op0 && (script[0] < op1 || script[0] > op16) {
+		return false
+	}
+	return int(script[1]) == len(script)-2
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 The ulordsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ulordutil_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ulordsuite/ulord/wire"
+	"github.com/ulordsuite/ulordutil"
+)
+
+// TestTxSize ensures the weight, virtual size, and fee rate of transactions are
+// computed as expected with and without witness data.
+func TestTxSize(t *testing.T) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, make([]byte, 107), nil))
+	tx.AddTxOut(wire.NewTxOut(1000, make([]byte, 25)))
+
+	// A transaction without witness data has a virtual size equal to its
+	// serialized size.
+	size := int64(tx.SerializeSize())
+	if got := ulordutil.TxWeight(tx); got != size*4 {
+		t.Errorf("TxWeight: got %d, want %d", got, size*4)
+	}
+	if got := ulordutil.TxVirtualSize(tx); got != size {
+		t.Errorf("TxVirtualSize: got %d, want %d", got, size)
+	}
+	fee := ulordutil.Amount(size * 10)
+	if got := ulordutil.TxFeeRate(tx, fee); got != 10000 {
+		t.Errorf("TxFeeRate: got %d, want %d", got, 10000)
+	}
+
+	// Witness data is discounted and the virtual size rounded up.
+	tx.TxIn[0].SignatureScript = nil
+	tx.TxIn[0].Witness = wire.TxWitness{make([]byte, 72), make([]byte, 33)}
+	baseSize := int64(tx.SerializeSizeStripped())
+	totalSize := int64(tx.SerializeSize())
+	weight := baseSize*3 + totalSize
+	if got := ulordutil.TxWeight(tx); got != weight {
+		t.Errorf("TxWeight: got %d, want %d", got, weight)
+	}
+	if got := ulordutil.TxVirtualSize(tx); got != (weight+3)/4 {
+		t.Errorf("TxVirtualSize: got %d, want %d", got, (weight+3)/4)
+	}
+}
+
+// TestFeeForSize ensures fees for virtual sizes are computed as expected.
+func TestFeeForSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		feeRate ulordutil.Amount
+		want    ulordutil.Amount
+	}{
+		{"zero fee rate", 250, 0, 0},
+		{"small transaction pays the fee rate", 250, 1, 1},
+		{"scaled by size", 250, 1000, 250},
+		{"rounded down", 1999, 1000, 1999},
+		{"limited to max", 1e9, ulordutil.MaxSatoshi, ulordutil.MaxSatoshi},
+	}
+
+	for _, test := range tests {
+		got := ulordutil.FeeForSize(test.size, test.feeRate)
+		if got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// TestDust ensures outputs are considered dust at and only below the dust
+// threshold of their scripts.
+func TestDust(t *testing.T) {
+	p2pkh := append([]byte{0x76, 0xa9, 0x14}, bytes.Repeat([]byte{0}, 20)...)
+	p2pkh = append(p2pkh, 0x88, 0xac)
+	p2wpkh := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0}, 20)...)
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		relayFee ulordutil.Amount
+		want     ulordutil.Amount
+	}{
+		{"p2pkh at default relay fee", p2pkh, 1000, 546},
+		{"p2wpkh at default relay fee", p2wpkh, 1000, 294},
+		{"p2pkh at zero relay fee", p2pkh, 0, 0},
+		{"p2pkh at high relay fee", p2pkh, 5000, 2730},
+	}
+
+	for _, test := range tests {
+		threshold := ulordutil.DustThreshold(test.pkScript, test.relayFee)
+		if threshold != test.want {
+			t.Errorf("%s: got threshold %d, want %d", test.name,
+				threshold, test.want)
+			continue
+		}
+
+		txOut := wire.NewTxOut(int64(threshold), test.pkScript)
+		if ulordutil.IsDust(txOut, test.relayFee) {
+			t.Errorf("%s: output of %d is dust", test.name,
+				txOut.Value)
+		}
+		if threshold == 0 {
+			continue
+		}
+		txOut.Value--
+		if !ulordutil.IsDust(txOut, test.relayFee) {
+			t.Errorf("%s: output of %d is not dust", test.name,
+				txOut.Value)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package ulordutil
 import (
 	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/ulordsuite/ulord/ulordec"
 	"github.com/ulordsuite/ulord/chaincfg"
@@ -20,6 +21,17 @@ import (
 // encountered.
 var ErrMalformedPrivateKey = errors.New("malformed private key")
 
+// WrongNetworkError describes an error where a WIF-encoded private key was
+// well formed and passed its checksum, but its network identifier byte is not
+// the one of the network it was expected to belong to.  The value is the
+// network identifier byte of the decoded key.
+type WrongNetworkError byte
+
+func (e WrongNetworkError) Error() string {
+	return fmt.Sprintf("private key is for the wrong network "+
+		"(network id 0x%02x)", byte(e))
+}
+
 // compressMagic is the magic byte used to identify a WIF encoding for
 // an address created from a compressed serialized public key.
 const compressMagic byte = 0x01
@@ -62,6 +74,12 @@ func (w *WIF) IsForNet(net *chaincfg.Params) bool {
 	return w.netID == net.PrivateKeyID
 }
 
+// NetID returns the network identifier byte the private key is encoded with.
+// It is the PrivateKeyID of the network the WIF is associated with.
+func (w *WIF) NetID() byte {
+	return w.netID
+}
+
 // DecodeWIF creates a new WIF structure by decoding the string encoding of
 // the import format.
 //
@@ -82,6 +100,9 @@ func (w *WIF) IsForNet(net *chaincfg.Params) bool {
 // is of an impossible length or the expected compressed pubkey magic number
 // does not equal the expected value of 0x01.  ErrChecksumMismatch is returned
 // if the expected WIF checksum does not match the calculated checksum.
+//
+// DecodeWIF accepts keys for any network.  Use DecodeWIFForNet when the
+// network the key must belong to is known.
 func DecodeWIF(wif string) (*WIF, error) {
 	decoded := base58.Decode(wif)
 	decodedLen := len(decoded)
@@ -121,16 +142,56 @@ func DecodeWIF(wif string) (*WIF, error) {
 	return &WIF{privKey, compress, netID}, nil
 }
 
+// DecodeWIFForNet decodes the string encoding of the import format like
+// DecodeWIF, and additionally ensures the private key is associated with the
+// passed network.  A WrongNetworkError is returned when the key is well formed
+// but encoded for a different network, which allows callers to distinguish it
+// from the ErrChecksumMismatch and ErrMalformedPrivateKey errors describing
+// corrupted input.
+func DecodeWIFForNet(wif string, net *chaincfg.Params) (*WIF, error) {
+	if net == nil {
+		return nil, errors.New("no network")
+	}
+	w, err := DecodeWIF(wif)
+	if err != nil {
+		return nil, err
+	}
+	if !w.IsForNet(net) {
+		return nil, WrongNetworkError(w.netID)
+	}
+	return w, nil
+}
+
 // String creates the Wallet Import Format string encoding of a WIF structure.
 // See DecodeWIF for a detailed breakdown of the format and requirements of
 // a valid WIF string.
 func (w *WIF) String() string {
+	return w.encode(w.CompressPubKey)
+}
+
+// EncodeCompressed returns the Wallet Import Format string encoding of the
+// private key marked for use with a compressed public key, regardless of the
+// value of w.CompressPubKey.
+func (w *WIF) EncodeCompressed() string {
+	return w.encode(true)
+}
+
+// EncodeUncompressed returns the Wallet Import Format string encoding of the
+// private key marked for use with an uncompressed public key, regardless of
+// the value of w.CompressPubKey.
+func (w *WIF) EncodeUncompressed() string {
+	return w.encode(false)
+}
+
+// encode returns the Wallet Import Format string encoding of the private key,
+// including the compressed pubkey magic byte when compress is true.
+func (w *WIF) encode(compress bool) string {
 	// Precalculate size.  Maximum number of bytes before base58 encoding
 	// is one byte for the network, 32 bytes of private key, possibly one
 	// extra byte if the pubkey is to be compressed, and finally four
 	// bytes of checksum.
 	encodeLen := 1 + ulordec.PrivKeyBytesLen + 4
-	if w.CompressPubKey {
+	if compress {
 		encodeLen++
 	}
 
@@ -139,7 +200,7 @@ func (w *WIF) String() string {
 	// Pad and append bytes manually, instead of using Serialize, to
 	// avoid another call to make.
 	a = paddedAppend(ulordec.PrivKeyBytesLen, a, w.PrivKey.D.Bytes())
-	if w.CompressPubKey {
+	if compress {
 		a = append(a, compressMagic)
 	}
 	cksum := chainhash.DoubleHashB(a)[:4]
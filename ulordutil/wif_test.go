@@ -69,3 +69,120 @@ func TestEncodeDecodeWIF(t *testing.T) {
 		}
 	}
 }
+
+// TestDecodeWIFForNet ensures keys are only accepted for the network they are
+// encoded for and that corrupted keys are reported as such rather than as a
+// network mismatch.
+func TestDecodeWIFForNet(t *testing.T) {
+	tests := []struct {
+		name string
+		wif  string
+		net  *chaincfg.Params
+		err  error
+	}{
+		{
+			name: "mainnet key for mainnet",
+			wif:  "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",
+			net:  &chaincfg.MainNetParams,
+		},
+		{
+			name: "testnet key for testnet",
+			wif:  "cV1Y7ARUr9Yx7BR55nTdnR7ZXNJphZtCCMBTEZBJe1hXt2kB684q",
+			net:  &chaincfg.TestNet3Params,
+		},
+		{
+			name: "testnet key for regtest",
+			wif:  "cV1Y7ARUr9Yx7BR55nTdnR7ZXNJphZtCCMBTEZBJe1hXt2kB684q",
+			net:  &chaincfg.RegressionNetParams,
+		},
+		{
+			name: "mainnet key for testnet",
+			wif:  "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",
+			net:  &chaincfg.TestNet3Params,
+			err:  WrongNetworkError(chaincfg.MainNetParams.PrivateKeyID),
+		},
+		{
+			name: "testnet key for mainnet",
+			wif:  "cV1Y7ARUr9Yx7BR55nTdnR7ZXNJphZtCCMBTEZBJe1hXt2kB684q",
+			net:  &chaincfg.MainNetParams,
+			err:  WrongNetworkError(chaincfg.TestNet3Params.PrivateKeyID),
+		},
+		{
+			name: "bad checksum",
+			wif:  "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTK",
+			net:  &chaincfg.TestNet3Params,
+			err:  ErrChecksumMismatch,
+		},
+		{
+			name: "bad length",
+			wif:  "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTL",
+			net:  &chaincfg.MainNetParams,
+			err:  ErrMalformedPrivateKey,
+		},
+	}
+
+	for i, test := range tests {
+		w, err := DecodeWIFForNet(test.wif, test.net)
+		if err != test.err {
+			t.Errorf("DecodeWIFForNet #%d (%s): unexpected error -- "+
+				"got %v, want %v", i, test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !w.IsForNet(test.net) || w.NetID() != test.net.PrivateKeyID {
+			t.Errorf("DecodeWIFForNet #%d (%s): decoded key is not "+
+				"for the requested network", i, test.name)
+			continue
+		}
+		if got := w.String(); got != test.wif {
+			t.Errorf("DecodeWIFForNet #%d (%s): mismatched encoding "+
+				"-- got %s, want %s", i, test.name, got, test.wif)
+		}
+	}
+}
+
+// TestWIFCompressedForms ensures both the compressed and uncompressed forms
+// of a key round trip while keeping the key and network intact.
+func TestWIFCompressedForms(t *testing.T) {
+	const (
+		uncompressed = "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"
+		compressed   = "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"
+	)
+
+	w, err := DecodeWIFForNet(uncompressed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("DecodeWIFForNet: unexpected error: %v", err)
+	}
+	if w.CompressPubKey {
+		t.Fatal("DecodeWIFForNet: uncompressed key decoded as compressed")
+	}
+	if got := w.EncodeUncompressed(); got != uncompressed {
+		t.Errorf("EncodeUncompressed: got %s, want %s", got, uncompressed)
+	}
+	if got := w.EncodeCompressed(); got != compressed {
+		t.Errorf("EncodeCompressed: got %s, want %s", got, compressed)
+	}
+
+	c, err := DecodeWIFForNet(w.EncodeCompressed(), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("DecodeWIFForNet: unexpected error: %v", err)
+	}
+	if !c.CompressPubKey {
+		t.Fatal("DecodeWIFForNet: compressed key decoded as uncompressed")
+	}
+	if c.PrivKey.D.Cmp(w.PrivKey.D) != 0 || c.NetID() != w.NetID() {
+		t.Fatal("DecodeWIFForNet: compressed form changed the key")
+	}
+	if got := c.String(); got != compressed {
+		t.Errorf("String: got %s, want %s", got, compressed)
+	}
+	if got := c.EncodeUncompressed(); got != uncompressed {
+		t.Errorf("EncodeUncompressed: got %s, want %s", got, uncompressed)
+	}
+	if len(c.SerializePubKey()) != ulordec.PubKeyBytesLenCompressed {
+		t.Errorf("SerializePubKey: got %d bytes, want %d",
+			len(c.SerializePubKey()), ulordec.PubKeyBytesLenCompressed)
+	}
+}